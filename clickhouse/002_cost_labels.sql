@@ -0,0 +1,4 @@
+-- Cost allocation labels copied from the run onto every trace event
+USE agentflow;
+
+ALTER TABLE trace_event ADD COLUMN IF NOT EXISTS labels Map(LowCardinality(String), String) AFTER latency_ms;
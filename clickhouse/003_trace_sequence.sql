@@ -0,0 +1,5 @@
+-- Per-run logical sequence issued by the control plane, used to order
+-- events from workers whose clocks disagree
+USE agentflow;
+
+ALTER TABLE trace_event ADD COLUMN IF NOT EXISTS seq UInt64 DEFAULT 0 AFTER ts;
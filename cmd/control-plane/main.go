@@ -6,9 +6,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aos"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/metrics"
 )
 
 func main() {
@@ -26,11 +31,35 @@ func main() {
 		log.Fatalf("Failed to create control plane: %v", err)
 	}
 
+	// Let deletion requests erase trace events when the trace store is reachable
+	if ch, err := db.NewClickHouseDB(&cfg.ClickHouse); err != nil {
+		log.Printf("Trace store unavailable, deletion requests will not erase traces: %v", err)
+	} else {
+		cp.SetTracePurger(aos.NewTraceEraser(ch))
+	}
+
 	// Start control plane
 	if err := cp.Start(ctx); err != nil {
 		log.Fatalf("Failed to start control plane: %v", err)
 	}
 
+	// Alert when steps burn their SLO error budgets too fast
+	if pg, err := db.NewPostgresDB(&cfg.Database); err != nil {
+		log.Printf("Step SLO tracking disabled: %v", err)
+	} else {
+		go aos.NewSLOTracker(pg, cfg.Alerts).Run(ctx, 5*time.Minute)
+
+		// Alert before forecast traffic reaches provider TPM/RPM limits
+		go cas.NewCapacityForecaster(pg, cfg.Alerts).Run(ctx, 5*time.Minute)
+	}
+
+	// Serve metrics
+	var metricsServer *metrics.Server
+	if cfg.Metrics.Enabled {
+		metricsServer = metrics.NewServer(cfg.Metrics.ControlPlaneAddr, aor.Registry, cas.Registry, db.Registry)
+		metricsServer.Start()
+	}
+
 	// Wait for shutdown signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -40,4 +69,10 @@ func main() {
 	if err := cp.Shutdown(ctx); err != nil {
 		log.Printf("Error during shutdown: %v", err)
 	}
+
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			log.Printf("Error during shutdown: %v", err)
+		}
+	}
 }
@@ -6,9 +6,13 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/metrics"
 )
 
 func main() {
@@ -31,6 +35,16 @@ func main() {
 		log.Fatalf("Failed to start worker: %v", err)
 	}
 
+	// Ping providers so calls fail over away from unhealthy ones
+	go worker.RunHealthChecks(ctx, 30*time.Second)
+
+	// Serve metrics
+	var metricsServer *metrics.Server
+	if cfg.Metrics.Enabled {
+		metricsServer = metrics.NewServer(cfg.Metrics.WorkerAddr, aor.Registry, cas.Registry, db.Registry)
+		metricsServer.Start()
+	}
+
 	// Wait for shutdown signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -40,4 +54,10 @@ func main() {
 	if err := worker.Shutdown(ctx); err != nil {
 		log.Printf("Error during shutdown: %v", err)
 	}
+
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			log.Printf("Error during shutdown: %v", err)
+		}
+	}
 }
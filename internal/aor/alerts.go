@@ -0,0 +1,161 @@
+package aor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+)
+
+// AlertNotifier delivers SLA alerts to an external channel
+type AlertNotifier interface {
+	Notify(ctx context.Context, alert SLAAlert) error
+}
+
+// UserNotifier delivers notifications and digests to users who opted in
+type UserNotifier interface {
+	Send(ctx context.Context, notification *Notification) error
+}
+
+// WebhookNotifier posts the alert as JSON to a URL
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify posts the alert payload
+func (wn *WebhookNotifier) Notify(ctx context.Context, alert SLAAlert) error {
+	return postJSON(ctx, wn.client, wn.url, alert)
+}
+
+// Send posts the notification payload
+func (wn *WebhookNotifier) Send(ctx context.Context, notification *Notification) error {
+	return postJSON(ctx, wn.client, wn.url, notification)
+}
+
+// SlackNotifier posts the alert message to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{webhookURL: webhookURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify posts the alert as a Slack message
+func (sn *SlackNotifier) Notify(ctx context.Context, alert SLAAlert) error {
+	icon := ":warning:"
+	if alert.Status == SLAStatusBreached {
+		icon = ":rotating_light:"
+	}
+	return postJSON(ctx, sn.client, sn.webhookURL, map[string]string{"text": icon + " " + alert.Message})
+}
+
+// Send posts the notification as a Slack message addressed to its user
+func (sn *SlackNotifier) Send(ctx context.Context, notification *Notification) error {
+	text := fmt.Sprintf("*%s* (for %s)\n%s", notification.Subject, notification.UserID, notification.Message)
+	return postJSON(ctx, sn.client, sn.webhookURL, map[string]string{"text": text})
+}
+
+// EmailNotifier emails notifications to users who gave an address
+type EmailNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewEmailNotifier(addr, from, username, password string) *EmailNotifier {
+	en := &EmailNotifier{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		en.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return en
+}
+
+// Send emails the notification; users without an address are skipped
+func (en *EmailNotifier) Send(ctx context.Context, notification *Notification) error {
+	if notification.Email == "" {
+		return nil
+	}
+	if err := smtp.SendMail(en.addr, en.auth, en.from, []string{notification.Email}, emailMessage(en.from, notification)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// emailMessage renders a notification as a plain-text email
+func emailMessage(from string, notification *Notification) []byte {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", notification.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.NewReplacer("\r", "", "\n", " ").Replace(notification.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", notification.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(notification.Message, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return []byte(msg.String())
+}
+
+// newUserNotifiers builds the user notification backends enabled in config
+func newUserNotifiers(cfg config.AlertsConfig) []UserNotifier {
+	notifiers := make([]UserNotifier, 0, 3)
+	if cfg.WebhookURL != "" {
+		notifiers = append(notifiers, NewWebhookNotifier(cfg.WebhookURL))
+	}
+	if cfg.SlackWebhookURL != "" {
+		notifiers = append(notifiers, NewSlackNotifier(cfg.SlackWebhookURL))
+	}
+	if cfg.SMTPAddr != "" {
+		notifiers = append(notifiers, NewEmailNotifier(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPUsername, cfg.SMTPPassword))
+	}
+	return notifiers
+}
+
+// newAlertNotifiers builds the notifiers enabled in config
+func newAlertNotifiers(cfg config.AlertsConfig) []AlertNotifier {
+	notifiers := make([]AlertNotifier, 0, 2)
+	if cfg.WebhookURL != "" {
+		notifiers = append(notifiers, NewWebhookNotifier(cfg.WebhookURL))
+	}
+	if cfg.SlackWebhookURL != "" {
+		notifiers = append(notifiers, NewSlackNotifier(cfg.SlackWebhookURL))
+	}
+	return notifiers
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,744 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/pop"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// ApplyAPIVersion is the bundle schema version accepted by Apply
+const ApplyAPIVersion = "agentflow/v1"
+
+// ResourceKind names a declaratively managed resource type
+type ResourceKind string
+
+const (
+	ResourceKindWorkflow ResourceKind = "Workflow"
+	ResourceKindPrompt   ResourceKind = "Prompt"
+	ResourceKindBudget   ResourceKind = "Budget"
+	ResourceKindProvider ResourceKind = "ProviderConfig"
+)
+
+// ApplyAction is what reconciling a resource did, or would do in a dry run
+type ApplyAction string
+
+const (
+	ApplyActionCreate    ApplyAction = "create"
+	ApplyActionUpdate    ApplyAction = "update"
+	ApplyActionUnchanged ApplyAction = "no-op"
+	ApplyActionDelete    ApplyAction = "delete"
+)
+
+// Resource is one declared object in an apply bundle
+type Resource struct {
+	Kind      ResourceKind       `json:"kind"`
+	Name      string             `json:"name"`
+	Spec      json.RawMessage    `json:"spec"`
+	Signature *ResourceSignature `json:"signature,omitempty"` // Workflows and prompts only
+}
+
+// ApplyBundle is a set of resources reconciled together
+type ApplyBundle struct {
+	APIVersion string     `json:"apiVersion"`
+	Resources  []Resource `json:"resources"`
+}
+
+// ApplyRequest reconciles an org's resources with a bundle. Prune deletes
+// resources an earlier apply created that are no longer in the bundle;
+// resources created outside apply are never pruned.
+type ApplyRequest struct {
+	OrgID  uuid.UUID    `json:"org_id"`
+	Bundle *ApplyBundle `json:"bundle"`
+	Prune  bool         `json:"prune"`
+	DryRun bool         `json:"dry_run"`
+}
+
+// ApplyChange reports the outcome for one resource
+type ApplyChange struct {
+	Kind   ResourceKind `json:"kind"`
+	Name   string       `json:"name"`
+	Action ApplyAction  `json:"action"`
+	Detail string       `json:"detail,omitempty"`
+	Signer string       `json:"signer,omitempty"` // Key that signed the applied content
+}
+
+// ApplyResult lists the changes made, or planned when DryRun is set
+type ApplyResult struct {
+	DryRun  bool                `json:"dry_run"`
+	Changes []ApplyChange       `json:"changes"`
+	Summary map[ApplyAction]int `json:"summary"`
+}
+
+// PromptResource declares a prompt template; changes create a new version
+type PromptResource struct {
+	Template string       `json:"template"`
+	Schema   pop.Schema   `json:"schema"`
+	Metadata pop.Metadata `json:"metadata,omitempty"`
+}
+
+// BudgetResource declares a budget scoped to the org, a workflow or a workflow and tag
+type BudgetResource struct {
+	Period     cas.PeriodType `json:"period"`
+	LimitCents int64          `json:"limit_cents"`
+	Workflow   string         `json:"workflow,omitempty"`
+	Tag        string         `json:"tag,omitempty"`
+	Label      string         `json:"label,omitempty"`
+}
+
+// ProviderResource declares a provider/model configuration, named provider/model
+type ProviderResource struct {
+	Enabled                *bool                  `json:"enabled,omitempty"`
+	Config                 map[string]interface{} `json:"config,omitempty"`
+	CostPerTokenPrompt     float64                `json:"cost_per_token_prompt"`
+	CostPerTokenCompletion float64                `json:"cost_per_token_completion"`
+	QPSLimit               int                    `json:"qps_limit,omitempty"`
+	Region                 string                 `json:"region,omitempty"`
+	DataResidency          string                 `json:"data_residency,omitempty"`
+}
+
+// appliedResource is the record of what an earlier apply created
+type appliedResource struct {
+	kind     ResourceKind
+	name     string
+	hash     string
+	objectID string
+}
+
+// ParseApplyBundle decodes and validates a JSON or YAML bundle
+func ParseApplyBundle(data []byte, format string) (*ApplyBundle, error) {
+	var raw interface{}
+	switch format {
+	case "json":
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse bundle: %w", err)
+		}
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse bundle: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported bundle format: %s", format)
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize bundle: %w", err)
+	}
+	var bundle ApplyBundle
+	if err := json.Unmarshal(normalized, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+
+	if err := bundle.Validate(); err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+// Validate checks every resource decodes and names are unique per kind
+func (b *ApplyBundle) Validate() error {
+	if b.APIVersion != "" && b.APIVersion != ApplyAPIVersion {
+		return fmt.Errorf("unsupported apiVersion %q: use %s", b.APIVersion, ApplyAPIVersion)
+	}
+
+	seen := make(map[string]bool, len(b.Resources))
+	var problems []string
+	for i, resource := range b.Resources {
+		label := fmt.Sprintf("resources[%d] %s/%s", i, resource.Kind, resource.Name)
+		if resource.Name == "" {
+			problems = append(problems, fmt.Sprintf("resources[%d]: name is required", i))
+			continue
+		}
+		key := string(resource.Kind) + "/" + resource.Name
+		if seen[key] {
+			problems = append(problems, fmt.Sprintf("%s: declared more than once", label))
+			continue
+		}
+		seen[key] = true
+
+		if _, err := resourceHash(resource); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", label, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid bundle:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// decodeWorkflowResource reads a workflow spec, accepting duration strings as spec files do
+func decodeWorkflowResource(resource Resource) (*WorkflowSpec, error) {
+	spec, err := ParseWorkflowSpec(resource.Spec, "json")
+	if err != nil {
+		return nil, err
+	}
+	if spec.Name != "" && spec.Name != resource.Name {
+		return nil, fmt.Errorf("spec name %q does not match resource name", spec.Name)
+	}
+	spec.Name = resource.Name
+	if len(spec.DAG.Steps) == 0 {
+		return nil, fmt.Errorf("workflow has no steps")
+	}
+	return spec, nil
+}
+
+func decodePromptResource(resource Resource) (*PromptResource, error) {
+	var prompt PromptResource
+	if err := json.Unmarshal(resource.Spec, &prompt); err != nil {
+		return nil, fmt.Errorf("failed to decode prompt: %w", err)
+	}
+	if strings.TrimSpace(prompt.Template) == "" {
+		return nil, fmt.Errorf("prompt template is required")
+	}
+	if err := pop.NewTemplateRenderer().Validate(prompt.Template, prompt.Schema); err != nil {
+		return nil, err
+	}
+	return &prompt, nil
+}
+
+func decodeBudgetResource(resource Resource) (*BudgetResource, error) {
+	var budget BudgetResource
+	if err := json.Unmarshal(resource.Spec, &budget); err != nil {
+		return nil, fmt.Errorf("failed to decode budget: %w", err)
+	}
+	switch budget.Period {
+	case cas.PeriodDaily, cas.PeriodWeekly, cas.PeriodMonthly:
+	default:
+		return nil, fmt.Errorf("budget period must be daily, weekly or monthly")
+	}
+	if budget.LimitCents <= 0 {
+		return nil, fmt.Errorf("budget limit_cents must be positive")
+	}
+	if budget.Tag != "" && budget.Workflow == "" {
+		return nil, fmt.Errorf("tag-scoped budgets require a workflow")
+	}
+	if budget.Label != "" {
+		if budget.Workflow != "" {
+			return nil, fmt.Errorf("label-scoped budgets apply across workflows; drop the workflow")
+		}
+		if _, _, err := cas.ParseCostLabelSelector(budget.Label); err != nil {
+			return nil, err
+		}
+	}
+	return &budget, nil
+}
+
+func decodeProviderResource(resource Resource) (string, string, *ProviderResource, error) {
+	provider, model, ok := strings.Cut(resource.Name, "/")
+	if !ok || provider == "" || model == "" {
+		return "", "", nil, fmt.Errorf("provider config name must be provider/model")
+	}
+	var cfg ProviderResource
+	if err := json.Unmarshal(resource.Spec, &cfg); err != nil {
+		return "", "", nil, fmt.Errorf("failed to decode provider config: %w", err)
+	}
+	if cfg.QPSLimit < 0 {
+		return "", "", nil, fmt.Errorf("qps_limit must not be negative")
+	}
+	return provider, model, &cfg, nil
+}
+
+// resourceHash is the content address of a resource's desired state, stable
+// across key order and formatting in the bundle
+func resourceHash(resource Resource) (string, error) {
+	var canonical interface{}
+	switch resource.Kind {
+	case ResourceKindWorkflow:
+		spec, err := decodeWorkflowResource(resource)
+		if err != nil {
+			return "", err
+		}
+		content, err := CanonicalSpecBytes(spec)
+		if err != nil {
+			return "", err
+		}
+		return db.HashContent(content), nil
+	case ResourceKindPrompt:
+		prompt, err := decodePromptResource(resource)
+		if err != nil {
+			return "", err
+		}
+		content, err := (&pop.PromptTemplate{Name: resource.Name, Template: prompt.Template, Schema: prompt.Schema}).CanonicalBytes()
+		if err != nil {
+			return "", err
+		}
+		return db.HashContent(content), nil
+	case ResourceKindBudget:
+		budget, err := decodeBudgetResource(resource)
+		if err != nil {
+			return "", err
+		}
+		canonical = budget
+	case ResourceKindProvider:
+		_, _, cfg, err := decodeProviderResource(resource)
+		if err != nil {
+			return "", err
+		}
+		canonical = cfg
+	default:
+		return "", fmt.Errorf("unknown kind %q: use Workflow, Prompt, Budget or ProviderConfig", resource.Kind)
+	}
+
+	content, err := json.Marshal(canonical)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal resource: %w", err)
+	}
+	return db.HashContent(content), nil
+}
+
+// Apply reconciles an org's workflows, prompts, budgets and provider configs
+// with a bundle. Applying the same bundle twice makes no changes.
+func (cp *ControlPlane) Apply(ctx context.Context, req *ApplyRequest) (*ApplyResult, error) {
+	if req.Bundle == nil {
+		return nil, fmt.Errorf("bundle is required")
+	}
+	if err := req.Bundle.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Verify every signature before changing anything so a rejected bundle is not half applied
+	signatures, err := cp.verifyBundleSignatures(ctx, req.OrgID, req.Bundle, req.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := cp.listAppliedResources(ctx, req.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ApplyResult{DryRun: req.DryRun, Changes: make([]ApplyChange, 0, len(req.Bundle.Resources)), Summary: make(map[ApplyAction]int)}
+	declared := make(map[string]bool, len(req.Bundle.Resources))
+
+	for _, resource := range req.Bundle.Resources {
+		key := string(resource.Kind) + "/" + resource.Name
+		declared[key] = true
+
+		change, err := cp.applyResource(ctx, req.OrgID, resource, applied[key], signatures[key], req.DryRun)
+		if err != nil {
+			return result, fmt.Errorf("failed to apply %s: %w", key, err)
+		}
+		result.record(*change)
+	}
+
+	if req.Prune {
+		keys := make([]string, 0, len(applied))
+		for key := range applied {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if declared[key] {
+				continue
+			}
+			change, err := cp.pruneResource(ctx, req.OrgID, applied[key], req.DryRun)
+			if err != nil {
+				return result, fmt.Errorf("failed to prune %s: %w", key, err)
+			}
+			result.record(*change)
+		}
+	}
+
+	if !req.DryRun {
+		log.Printf("Applied bundle for org %s: %d created, %d updated, %d deleted, %d unchanged", req.OrgID,
+			result.Summary[ApplyActionCreate], result.Summary[ApplyActionUpdate], result.Summary[ApplyActionDelete], result.Summary[ApplyActionUnchanged])
+	}
+	return result, nil
+}
+
+func (r *ApplyResult) record(change ApplyChange) {
+	r.Changes = append(r.Changes, change)
+	r.Summary[change.Action]++
+}
+
+// applyResource reconciles one resource against its live state
+func (cp *ControlPlane) applyResource(ctx context.Context, orgID uuid.UUID, resource Resource, previous *appliedResource, signature *SignatureRecord, dryRun bool) (*ApplyChange, error) {
+	hash, err := resourceHash(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var change *ApplyChange
+	objectID := ""
+	switch resource.Kind {
+	case ResourceKindWorkflow:
+		change, err = cp.applyWorkflow(ctx, orgID, resource, hash, signature, dryRun)
+	case ResourceKindPrompt:
+		change, err = cp.applyPrompt(ctx, orgID, resource, hash, signature, dryRun)
+	case ResourceKindBudget:
+		change, objectID, err = cp.applyBudget(ctx, orgID, resource, previous, hash, dryRun)
+	case ResourceKindProvider:
+		change, err = cp.applyProvider(ctx, orgID, resource, dryRun)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if signature != nil && change.Action != ApplyActionUnchanged {
+		change.Signer = signature.Key
+	}
+
+	// Record ownership even for no-ops so adopted resources can be pruned later
+	if !dryRun && (previous == nil || previous.hash != hash || change.Action != ApplyActionUnchanged) {
+		if err := cp.saveAppliedResource(ctx, orgID, resource.Kind, resource.Name, hash, objectID); err != nil {
+			return nil, err
+		}
+	}
+	return change, nil
+}
+
+func (cp *ControlPlane) applyWorkflow(ctx context.Context, orgID uuid.UUID, resource Resource, hash string, signature *SignatureRecord, dryRun bool) (*ApplyChange, error) {
+	change := &ApplyChange{Kind: resource.Kind, Name: resource.Name}
+
+	var version int
+	var current string
+	query := `SELECT version, COALESCE(content_hash, '') FROM workflow_spec
+			  WHERE org_id = $1 AND name = $2 ORDER BY version DESC LIMIT 1`
+	err := cp.db.QueryRowContext(ctx, query, orgID, resource.Name).Scan(&version, &current)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		change.Action = ApplyActionCreate
+	case err != nil:
+		return nil, fmt.Errorf("failed to get workflow spec: %w", err)
+	case current == hash:
+		change.Action = ApplyActionUnchanged
+		change.Detail = fmt.Sprintf("v%d", version)
+		return change, nil
+	default:
+		change.Action = ApplyActionUpdate
+	}
+	change.Detail = fmt.Sprintf("v%d", version+1)
+	if dryRun {
+		return change, nil
+	}
+
+	spec, err := decodeWorkflowResource(resource)
+	if err != nil {
+		return nil, err
+	}
+	content, err := CanonicalSpecBytes(spec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cp.blobs.Put(ctx, orgID, db.BlobKindWorkflowSpec, content); err != nil {
+		return nil, err
+	}
+
+	dagJSON, err := json.Marshal(spec.DAG)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal DAG: %w", err)
+	}
+	metadataJSON, err := json.Marshal(spec.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	signatureData, err := signatureJSON(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	insert := `INSERT INTO workflow_spec (id, org_id, name, version, dag, metadata, content_hash, signature)
+			   VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	if _, err := cp.db.ExecContext(ctx, insert, uuid.New(), orgID, resource.Name, version+1, dagJSON, metadataJSON, hash, signatureData); err != nil {
+		return nil, fmt.Errorf("failed to insert workflow spec: %w", err)
+	}
+	if err := cp.registerWebhooks(ctx, orgID, resource.Name, spec.Metadata.Webhooks); err != nil {
+		return nil, err
+	}
+	if err := cp.syncSchedules(ctx, orgID, resource.Name, spec.Metadata.Schedules); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (cp *ControlPlane) applyPrompt(ctx context.Context, orgID uuid.UUID, resource Resource, hash string, signature *SignatureRecord, dryRun bool) (*ApplyChange, error) {
+	change := &ApplyChange{Kind: resource.Kind, Name: resource.Name}
+
+	var version int
+	var current string
+	query := `SELECT version, COALESCE(content_hash, '') FROM prompt_template
+			  WHERE org_id = $1 AND name = $2 ORDER BY version DESC LIMIT 1`
+	err := cp.db.QueryRowContext(ctx, query, orgID, resource.Name).Scan(&version, &current)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		change.Action = ApplyActionCreate
+	case err != nil:
+		return nil, fmt.Errorf("failed to get prompt template: %w", err)
+	case current == hash:
+		change.Action = ApplyActionUnchanged
+		change.Detail = fmt.Sprintf("v%d", version)
+		return change, nil
+	default:
+		change.Action = ApplyActionUpdate
+	}
+	change.Detail = fmt.Sprintf("v%d", version+1)
+	if dryRun {
+		return change, nil
+	}
+
+	prompt, err := decodePromptResource(resource)
+	if err != nil {
+		return nil, err
+	}
+	req := &pop.CreatePromptRequest{
+		Name:     resource.Name,
+		Template: prompt.Template,
+		Schema:   prompt.Schema,
+		Metadata: prompt.Metadata,
+	}
+	// Stored with the version so a signature-requiring control plane accepts it
+	if signature != nil {
+		if req.Signature, err = json.Marshal(signature); err != nil {
+			return nil, fmt.Errorf("failed to marshal signature: %w", err)
+		}
+	}
+	if _, err := cp.prompts.CreatePromptVersion(ctx, orgID, req); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// applyBudget keeps one budget per resource; a scope or period change
+// replaces the budget, a limit change updates it in place
+func (cp *ControlPlane) applyBudget(ctx context.Context, orgID uuid.UUID, resource Resource, previous *appliedResource, hash string, dryRun bool) (*ApplyChange, string, error) {
+	change := &ApplyChange{Kind: resource.Kind, Name: resource.Name}
+	desired, err := decodeBudgetResource(resource)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var existing *cas.Budget
+	if previous != nil && previous.objectID != "" {
+		if id, err := uuid.Parse(previous.objectID); err == nil {
+			if existing, err = cp.budgets.GetBudget(ctx, id); err != nil {
+				existing = nil // Deleted outside apply; recreate it
+			}
+		}
+	}
+
+	if existing != nil && previous.hash == hash {
+		change.Action = ApplyActionUnchanged
+		return change, existing.ID.String(), nil
+	}
+
+	if existing != nil && existing.PeriodType == desired.Period &&
+		stringValue(existing.WorkflowName) == desired.Workflow && stringValue(existing.Tag) == desired.Tag &&
+		stringValue(existing.Label) == desired.Label {
+		change.Action = ApplyActionUpdate
+		change.Detail = fmt.Sprintf("limit %d¢ -> %d¢", existing.LimitCents, desired.LimitCents)
+		if !dryRun {
+			if err := cp.budgets.UpdateBudget(ctx, existing.ID, desired.LimitCents); err != nil {
+				return nil, "", err
+			}
+		}
+		return change, existing.ID.String(), nil
+	}
+
+	change.Action = ApplyActionCreate
+	if existing != nil {
+		change.Action = ApplyActionUpdate
+		change.Detail = "scope or period changed; budget replaced"
+	}
+	if dryRun {
+		return change, "", nil
+	}
+
+	if existing != nil {
+		if err := cp.budgets.DeleteBudget(ctx, existing.ID); err != nil {
+			return nil, "", err
+		}
+	}
+	budget := &cas.Budget{
+		ID:         uuid.New(),
+		OrgID:      orgID,
+		PeriodType: desired.Period,
+		LimitCents: desired.LimitCents,
+		CreatedAt:  time.Now(),
+	}
+	if desired.Workflow != "" {
+		budget.WorkflowName = &desired.Workflow
+	}
+	if desired.Tag != "" {
+		budget.Tag = &desired.Tag
+	}
+	if desired.Label != "" {
+		budget.Label = &desired.Label
+	}
+	cas.SetBudgetPeriod(budget, desired.Period)
+	if _, err := cp.budgets.CreateBudget(ctx, budget); err != nil {
+		return nil, "", err
+	}
+	return change, budget.ID.String(), nil
+}
+
+func (cp *ControlPlane) applyProvider(ctx context.Context, orgID uuid.UUID, resource Resource, dryRun bool) (*ApplyChange, error) {
+	change := &ApplyChange{Kind: resource.Kind, Name: resource.Name}
+	provider, model, desired, err := decodeProviderResource(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := true
+	if desired.Enabled != nil {
+		enabled = *desired.Enabled
+	}
+	qps := desired.QPSLimit
+	if qps == 0 {
+		qps = 100
+	}
+	residency := desired.DataResidency
+	if residency == "" {
+		residency = "global"
+	}
+	config := desired.Config
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal provider config: %w", err)
+	}
+
+	var live ProviderResource
+	var liveConfig []byte
+	var liveEnabled bool
+	query := `SELECT config, COALESCE(cost_per_token_prompt, 0), COALESCE(cost_per_token_completion, 0),
+			  COALESCE(qps_limit, 0), COALESCE(enabled, false), region, data_residency
+			  FROM provider_config WHERE org_id = $1 AND provider_name = $2 AND model_name = $3`
+	err = cp.db.QueryRowContext(ctx, query, orgID, provider, model).Scan(
+		&liveConfig, &live.CostPerTokenPrompt, &live.CostPerTokenCompletion, &live.QPSLimit, &liveEnabled, &live.Region, &live.DataResidency,
+	)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		change.Action = ApplyActionCreate
+	case err != nil:
+		return nil, fmt.Errorf("failed to get provider config: %w", err)
+	default:
+		var liveMap map[string]interface{}
+		_ = json.Unmarshal(liveConfig, &liveMap)
+		liveJSON, _ := json.Marshal(liveMap)
+		if string(liveJSON) == string(configJSON) && liveEnabled == enabled && live.QPSLimit == qps &&
+			live.CostPerTokenPrompt == desired.CostPerTokenPrompt && live.CostPerTokenCompletion == desired.CostPerTokenCompletion &&
+			live.Region == desired.Region && live.DataResidency == residency {
+			change.Action = ApplyActionUnchanged
+			return change, nil
+		}
+		change.Action = ApplyActionUpdate
+	}
+	if dryRun {
+		return change, nil
+	}
+
+	upsert := `INSERT INTO provider_config (org_id, provider_name, model_name, config, cost_per_token_prompt,
+			   cost_per_token_completion, qps_limit, enabled, region, data_residency)
+			   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			   ON CONFLICT (org_id, provider_name, model_name) DO UPDATE SET
+			   config = EXCLUDED.config, cost_per_token_prompt = EXCLUDED.cost_per_token_prompt,
+			   cost_per_token_completion = EXCLUDED.cost_per_token_completion, qps_limit = EXCLUDED.qps_limit,
+			   enabled = EXCLUDED.enabled, region = EXCLUDED.region, data_residency = EXCLUDED.data_residency`
+	_, err = cp.db.ExecContext(ctx, upsert, orgID, provider, model, configJSON, desired.CostPerTokenPrompt,
+		desired.CostPerTokenCompletion, qps, enabled, desired.Region, residency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert provider config: %w", err)
+	}
+	return change, nil
+}
+
+// pruneResource deletes a resource an earlier apply created. Workflow
+// versions that runs reference are kept so run history survives.
+func (cp *ControlPlane) pruneResource(ctx context.Context, orgID uuid.UUID, resource *appliedResource, dryRun bool) (*ApplyChange, error) {
+	change := &ApplyChange{Kind: resource.kind, Name: resource.name, Action: ApplyActionDelete}
+	if dryRun {
+		return change, nil
+	}
+
+	var err error
+	switch resource.kind {
+	case ResourceKindWorkflow:
+		var result sql.Result
+		result, err = cp.db.ExecContext(ctx, `DELETE FROM workflow_spec s WHERE s.org_id = $1 AND s.name = $2
+			AND NOT EXISTS (SELECT 1 FROM workflow_run r WHERE r.workflow_spec_id = s.id)`, orgID, resource.name)
+		if err == nil {
+			var kept int
+			if qerr := cp.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM workflow_spec WHERE org_id = $1 AND name = $2`,
+				orgID, resource.name).Scan(&kept); qerr == nil && kept > 0 {
+				deleted, _ := result.RowsAffected()
+				change.Detail = fmt.Sprintf("%d versions deleted, %d kept for run history", deleted, kept)
+			}
+		}
+	case ResourceKindPrompt:
+		if _, err = cp.db.ExecContext(ctx, `DELETE FROM prompt_deployment WHERE org_id = $1 AND prompt_name = $2`, orgID, resource.name); err == nil {
+			_, err = cp.db.ExecContext(ctx, `DELETE FROM prompt_template WHERE org_id = $1 AND name = $2`, orgID, resource.name)
+		}
+	case ResourceKindBudget:
+		if id, perr := uuid.Parse(resource.objectID); perr == nil {
+			err = cp.budgets.DeleteBudget(ctx, id)
+		}
+	case ResourceKindProvider:
+		provider, model, _ := strings.Cut(resource.name, "/")
+		_, err = cp.db.ExecContext(ctx, `DELETE FROM provider_config WHERE org_id = $1 AND provider_name = $2 AND model_name = $3`,
+			orgID, provider, model)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete %s %s: %w", resource.kind, resource.name, err)
+	}
+
+	if _, err := cp.db.ExecContext(ctx, `DELETE FROM applied_resource WHERE org_id = $1 AND kind = $2 AND name = $3`,
+		orgID, string(resource.kind), resource.name); err != nil {
+		return nil, fmt.Errorf("failed to forget applied resource: %w", err)
+	}
+	return change, nil
+}
+
+func (cp *ControlPlane) listAppliedResources(ctx context.Context, orgID uuid.UUID) (map[string]*appliedResource, error) {
+	query := `SELECT kind, name, content_hash, object_id FROM applied_resource WHERE org_id = $1`
+	rows, err := cp.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied resources: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]*appliedResource)
+	for rows.Next() {
+		var resource appliedResource
+		var kind string
+		if err := rows.Scan(&kind, &resource.name, &resource.hash, &resource.objectID); err != nil {
+			return nil, fmt.Errorf("failed to scan applied resource: %w", err)
+		}
+		resource.kind = ResourceKind(kind)
+		applied[kind+"/"+resource.name] = &resource
+	}
+	return applied, rows.Err()
+}
+
+func (cp *ControlPlane) saveAppliedResource(ctx context.Context, orgID uuid.UUID, kind ResourceKind, name, hash, objectID string) error {
+	query := `INSERT INTO applied_resource (org_id, kind, name, content_hash, object_id, applied_at)
+			  VALUES ($1, $2, $3, $4, $5, NOW())
+			  ON CONFLICT (org_id, kind, name) DO UPDATE SET
+			  content_hash = EXCLUDED.content_hash, object_id = EXCLUDED.object_id, applied_at = NOW()`
+	if _, err := cp.db.ExecContext(ctx, query, orgID, string(kind), name, hash, objectID); err != nil {
+		return fmt.Errorf("failed to record applied resource: %w", err)
+	}
+	return nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,229 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// artifactGCBatchSize bounds how many blobs one delete statement removes
+	artifactGCBatchSize = 500
+	// maxArtifactGCCandidates caps the blobs a dry run lists
+	maxArtifactGCCandidates = 100
+)
+
+// artifactRefsQuery yields an (org_id, hash) row for every reference to a
+// content blob: step inputs and outputs, hashes anywhere in run metadata
+// (pinned specs and prompts, offloaded media), spec and prompt versions,
+// batch datasets and fine-tuning datasets
+const artifactRefsQuery = `
+	SELECT s.org_id, sr.input_ref AS hash FROM step_run sr
+	JOIN workflow_run r ON r.id = sr.workflow_run_id JOIN workflow_spec s ON s.id = r.workflow_spec_id
+	WHERE sr.input_ref IS NOT NULL
+	UNION ALL
+	SELECT s.org_id, sr.output_ref FROM step_run sr
+	JOIN workflow_run r ON r.id = sr.workflow_run_id JOIN workflow_spec s ON s.id = r.workflow_spec_id
+	WHERE sr.output_ref IS NOT NULL
+	UNION ALL
+	SELECT s.org_id, ref #>> '{}' FROM workflow_run r JOIN workflow_spec s ON s.id = r.workflow_spec_id,
+	jsonb_path_query(r.metadata, 'strict $.** ? (@.type() == "string" && @ starts with "sha256:")') ref
+	UNION ALL
+	SELECT org_id, content_hash FROM workflow_spec WHERE content_hash IS NOT NULL
+	UNION ALL
+	SELECT org_id, content_hash FROM prompt_template WHERE content_hash IS NOT NULL
+	UNION ALL
+	SELECT org_id, dataset_ref FROM run_batch WHERE dataset_ref <> ''
+	UNION ALL
+	SELECT org_id, content_hash FROM finetune_dataset`
+
+// ArtifactGCRequest selects what a GC pass covers
+type ArtifactGCRequest struct {
+	OrgID  uuid.UUID     `json:"org_id,omitempty"` // uuid.Nil covers every org
+	DryRun bool          `json:"dry_run,omitempty"`
+	Grace  time.Duration `json:"grace,omitempty"` // Minimum age since a blob was last stored; 0 uses the configured grace
+}
+
+// ArtifactKindUsage is the GC outcome for one blob kind
+type ArtifactKindUsage struct {
+	Blobs          int   `json:"blobs"`
+	Bytes          int64 `json:"bytes"`
+	Reclaimed      int   `json:"reclaimed"`
+	ReclaimedBytes int64 `json:"reclaimed_bytes"`
+}
+
+// ArtifactCandidate is an unreferenced blob old enough to reclaim
+type ArtifactCandidate struct {
+	OrgID     uuid.UUID `json:"org_id"`
+	Hash      string    `json:"hash"`
+	Kind      string    `json:"kind"`
+	SizeBytes int64     `json:"size_bytes"`
+	TouchedAt time.Time `json:"touched_at"`
+}
+
+// ArtifactGCReport summarizes a GC pass. On a dry run the reclaimed counts
+// are what a real pass would delete.
+type ArtifactGCReport struct {
+	DryRun         bool                         `json:"dry_run"`
+	Grace          time.Duration                `json:"grace"`
+	StartedAt      time.Time                    `json:"started_at"`
+	Scanned        int                          `json:"scanned"`
+	Referenced     int                          `json:"referenced"`
+	InGrace        int                          `json:"in_grace"` // Unreferenced but stored too recently to reclaim
+	Reclaimed      int                          `json:"reclaimed"`
+	ReclaimedBytes int64                        `json:"reclaimed_bytes"`
+	ByKind         map[string]ArtifactKindUsage `json:"by_kind"`
+	Candidates     []ArtifactCandidate          `json:"candidates,omitempty"` // Listed on dry runs only
+}
+
+// artifactUsage is a content blob and how many references it has
+type artifactUsage struct {
+	ArtifactCandidate
+	Refs int
+}
+
+// planArtifactGC picks the blobs with no references last stored before the
+// cutoff, and tallies the outcome per kind
+func planArtifactGC(usages []artifactUsage, cutoff time.Time) ([]ArtifactCandidate, *ArtifactGCReport) {
+	report := &ArtifactGCReport{ByKind: make(map[string]ArtifactKindUsage)}
+	candidates := make([]ArtifactCandidate, 0)
+	for _, usage := range usages {
+		report.Scanned++
+		kind := report.ByKind[usage.Kind]
+		kind.Blobs++
+		kind.Bytes += usage.SizeBytes
+
+		switch {
+		case usage.Refs > 0:
+			report.Referenced++
+		case !usage.TouchedAt.Before(cutoff):
+			report.InGrace++
+		default:
+			candidates = append(candidates, usage.ArtifactCandidate)
+			report.Reclaimed++
+			report.ReclaimedBytes += usage.SizeBytes
+			kind.Reclaimed++
+			kind.ReclaimedBytes += usage.SizeBytes
+		}
+		report.ByKind[usage.Kind] = kind
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].SizeBytes > candidates[j].SizeBytes })
+	return candidates, report
+}
+
+// CollectArtifacts reference-counts content blobs and deletes those nothing
+// refers to any more, once the grace period has passed since they were last stored.
+// Blobs referenced by retained runs, spec and prompt versions or datasets are
+// never deleted; a dry run only reports what would be.
+func (cp *ControlPlane) CollectArtifacts(ctx context.Context, req *ArtifactGCRequest) (*ArtifactGCReport, error) {
+	grace := req.Grace
+	if grace <= 0 {
+		grace = cp.cfg.Artifacts.GCGrace
+	}
+	startedAt := time.Now()
+	cutoff := startedAt.Add(-grace)
+
+	query := `WITH refs AS (` + artifactRefsQuery + `),
+			  counts AS (SELECT org_id, hash, COUNT(*) AS n FROM refs GROUP BY org_id, hash)
+			  SELECT b.org_id, b.hash, b.kind, b.size_bytes, b.touched_at, COALESCE(c.n, 0)
+			  FROM content_blob b LEFT JOIN counts c ON c.org_id = b.org_id AND c.hash = b.hash
+			  WHERE $1 = '00000000-0000-0000-0000-000000000000'::uuid OR b.org_id = $1`
+	rows, err := cp.db.QueryContext(ctx, query, req.OrgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count artifact references: %w", err)
+	}
+	usages := make([]artifactUsage, 0)
+	for rows.Next() {
+		var usage artifactUsage
+		if err := rows.Scan(&usage.OrgID, &usage.Hash, &usage.Kind, &usage.SizeBytes, &usage.TouchedAt, &usage.Refs); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan artifact: %w", err)
+		}
+		usages = append(usages, usage)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read artifacts: %w", err)
+	}
+
+	candidates, report := planArtifactGC(usages, cutoff)
+	report.DryRun, report.Grace, report.StartedAt = req.DryRun, grace, startedAt
+	if req.DryRun {
+		if len(candidates) > maxArtifactGCCandidates {
+			candidates = candidates[:maxArtifactGCCandidates]
+		}
+		report.Candidates = candidates
+		return report, nil
+	}
+
+	// References are checked again as blobs are deleted, so a blob referenced
+	// or stored again since it was counted is kept
+	for kind, usage := range report.ByKind {
+		usage.Reclaimed, usage.ReclaimedBytes = 0, 0
+		report.ByKind[kind] = usage
+	}
+	report.Reclaimed, report.ReclaimedBytes = 0, 0
+	byOrg := make(map[uuid.UUID][]string)
+	for _, candidate := range candidates {
+		byOrg[candidate.OrgID] = append(byOrg[candidate.OrgID], candidate.Hash)
+	}
+	deleteQuery := `WITH refs AS (` + artifactRefsQuery + `)
+					DELETE FROM content_blob b
+					WHERE b.org_id = $1 AND b.hash = ANY($2) AND b.touched_at < $3
+					AND NOT EXISTS (SELECT 1 FROM refs WHERE refs.org_id = b.org_id AND refs.hash = b.hash)
+					RETURNING b.kind, b.size_bytes`
+	for orgID, hashes := range byOrg {
+		for start := 0; start < len(hashes); start += artifactGCBatchSize {
+			end := min(start+artifactGCBatchSize, len(hashes))
+			rows, err := cp.db.QueryContext(ctx, deleteQuery, orgID, pq.Array(hashes[start:end]), cutoff)
+			if err != nil {
+				return report, fmt.Errorf("failed to delete artifacts: %w", err)
+			}
+			for rows.Next() {
+				var kind string
+				var size int64
+				if err := rows.Scan(&kind, &size); err != nil {
+					rows.Close()
+					return report, fmt.Errorf("failed to scan deleted artifact: %w", err)
+				}
+				usage := report.ByKind[kind]
+				usage.Reclaimed++
+				usage.ReclaimedBytes += size
+				report.ByKind[kind] = usage
+				report.Reclaimed++
+				report.ReclaimedBytes += size
+				artifactGCBlobs.Inc(kind)
+				artifactGCBytes.Add(float64(size), kind)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return report, fmt.Errorf("failed to read deleted artifacts: %w", err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// collectArtifactsPeriodically runs artifact GC across orgs from the monitor
+// loop at most once per configured interval
+func (cp *ControlPlane) collectArtifactsPeriodically(ctx context.Context) {
+	interval := cp.cfg.Artifacts.GCInterval
+	if interval <= 0 || time.Since(cp.artifactGCAt) < interval {
+		return
+	}
+	cp.artifactGCAt = time.Now()
+
+	report, err := cp.CollectArtifacts(ctx, &ArtifactGCRequest{})
+	if err != nil {
+		log.Printf("Failed to collect unreferenced artifacts: %v", err)
+		return
+	}
+	if report.Reclaimed > 0 {
+		log.Printf("Reclaimed %d unreferenced artifacts (%d bytes) of %d scanned", report.Reclaimed, report.ReclaimedBytes, report.Scanned)
+	}
+}
@@ -0,0 +1,708 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/google/uuid"
+)
+
+// What an assert step does when an assertion fails
+const (
+	AssertOnFailFail   = "fail"   // Fail the step and with it the run
+	AssertOnFailBranch = "branch" // Succeed and run the remediation steps instead of the rest
+)
+
+// Branches an assert step reports in its "branch" output
+const (
+	assertBranchPass        = "pass"
+	assertBranchRemediation = "remediation"
+)
+
+// AssertConfig configures an assert step
+type AssertConfig struct {
+	Assertions  []Assertion `json:"assertions"`
+	OnFail      string      `json:"on_fail"`
+	Remediation []string    `json:"remediation,omitempty"` // Successors run only when an assertion fails
+}
+
+// Assertion checks the values a path selects from the step's inputs. Each
+// assertion sets exactly one of count, range and schema.
+type Assertion struct {
+	Name   string        `json:"name"`
+	Path   string        `json:"path"`
+	Count  *AssertBounds `json:"count,omitempty"`  // Rows in the selected array, or matches of a wildcard path
+	Range  *AssertBounds `json:"range,omitempty"`  // Every selected value is a number within the bounds
+	Schema *valueSchema  `json:"schema,omitempty"` // Every selected value matches the JSON Schema
+
+	path *JSONPath
+}
+
+// AssertBounds is an inclusive range; either end may be open
+type AssertBounds struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// AssertionFailure is one assertion that did not hold
+type AssertionFailure struct {
+	Assertion string `json:"assertion"`
+	Path      string `json:"path"`
+	Message   string `json:"message"`
+}
+
+// AssertionError is returned by an assert step whose assertions failed and
+// that has no remediation branch
+type AssertionError struct {
+	StepID   string             `json:"step_id"`
+	Checked  int                `json:"checked"`
+	Failures []AssertionFailure `json:"failures"`
+}
+
+func (e *AssertionError) Error() string {
+	messages := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		messages = append(messages, fmt.Sprintf("%s: %s", failure.Assertion, failure.Message))
+	}
+	return fmt.Sprintf("step %s failed %d of %d assertions: %s", e.StepID, len(e.Failures), e.Checked, strings.Join(messages, "; "))
+}
+
+// ErrorClass reports failed assertions to telemetry
+func (e *AssertionError) ErrorClass() cas.ErrorClass {
+	return cas.ErrorClassInvalidRequest
+}
+
+// Retryable is false: the same inputs fail the same assertions
+func (e *AssertionError) Retryable() bool {
+	return false
+}
+
+// parseAssertConfig reads an assert step's config
+func parseAssertConfig(config map[string]interface{}) (*AssertConfig, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal assert config: %w", err)
+	}
+	var cfg AssertConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode assert config: %w", err)
+	}
+
+	if len(cfg.Assertions) == 0 {
+		return nil, fmt.Errorf("assert step needs at least one assertion")
+	}
+	names := make(map[string]bool, len(cfg.Assertions))
+	for i := range cfg.Assertions {
+		assertion := &cfg.Assertions[i]
+		if assertion.Name == "" {
+			assertion.Name = fmt.Sprintf("assertion_%d", i+1)
+		}
+		if names[assertion.Name] {
+			return nil, fmt.Errorf("duplicate assertion %s", assertion.Name)
+		}
+		names[assertion.Name] = true
+		if err := assertion.compile(); err != nil {
+			return nil, fmt.Errorf("assertion %s: %w", assertion.Name, err)
+		}
+	}
+
+	switch cfg.OnFail {
+	case "":
+		cfg.OnFail = AssertOnFailFail
+		if len(cfg.Remediation) > 0 {
+			cfg.OnFail = AssertOnFailBranch
+		}
+	case AssertOnFailFail:
+		if len(cfg.Remediation) > 0 {
+			return nil, fmt.Errorf("remediation steps need on_fail: branch")
+		}
+	case AssertOnFailBranch:
+		if len(cfg.Remediation) == 0 {
+			return nil, fmt.Errorf("on_fail: branch needs remediation steps")
+		}
+	default:
+		return nil, fmt.Errorf("unknown on_fail %q: use fail or branch", cfg.OnFail)
+	}
+	return &cfg, nil
+}
+
+// validateAssertStep checks an assert step's config and that its remediation
+// steps follow it directly
+func validateAssertStep(step Step, edges []Edge) error {
+	cfg, err := parseAssertConfig(step.Config)
+	if err != nil {
+		return err
+	}
+	successors := make(map[string]bool)
+	for _, edge := range edges {
+		if edge.From == step.ID {
+			successors[edge.To] = true
+		}
+	}
+	for _, id := range cfg.Remediation {
+		if !successors[id] {
+			return fmt.Errorf("remediation step %s does not depend on %s", id, step.ID)
+		}
+	}
+	return nil
+}
+
+func (a *Assertion) compile() error {
+	path, err := ParseJSONPath(a.Path)
+	if err != nil {
+		return err
+	}
+	a.path = path
+
+	checks := 0
+	for _, set := range []bool{a.Count != nil, a.Range != nil, a.Schema != nil} {
+		if set {
+			checks++
+		}
+	}
+	if checks != 1 {
+		return fmt.Errorf("set exactly one of count, range and schema")
+	}
+	if a.Count != nil {
+		return a.Count.validate()
+	}
+	if a.Range != nil {
+		return a.Range.validate()
+	}
+	return nil
+}
+
+func (b *AssertBounds) validate() error {
+	if b.Min == nil && b.Max == nil {
+		return fmt.Errorf("bounds need a min or max")
+	}
+	if b.Min != nil && b.Max != nil && *b.Min > *b.Max {
+		return fmt.Errorf("min %g is above max %g", *b.Min, *b.Max)
+	}
+	return nil
+}
+
+func (b *AssertBounds) contains(v float64) bool {
+	return (b.Min == nil || v >= *b.Min) && (b.Max == nil || v <= *b.Max)
+}
+
+func (b *AssertBounds) String() string {
+	switch {
+	case b.Min == nil:
+		return fmt.Sprintf("at most %g", *b.Max)
+	case b.Max == nil:
+		return fmt.Sprintf("at least %g", *b.Min)
+	}
+	return fmt.Sprintf("between %g and %g", *b.Min, *b.Max)
+}
+
+// check evaluates the assertion against the step's decoded inputs
+func (a *Assertion) check(doc interface{}) *AssertionFailure {
+	fail := func(format string, args ...interface{}) *AssertionFailure {
+		return &AssertionFailure{Assertion: a.Name, Path: a.Path, Message: fmt.Sprintf(format, args...)}
+	}
+	matches := a.path.Select(doc)
+
+	if a.Count != nil {
+		count := len(matches)
+		if a.path.Definite() {
+			if len(matches) == 0 {
+				return fail("path matched no value")
+			}
+			rows, ok := matches[0].([]interface{})
+			if !ok {
+				return fail("expected an array, got %s", jsonTypeOf(matches[0]))
+			}
+			count = len(rows)
+		}
+		if !a.Count.contains(float64(count)) {
+			return fail("count %d is not %s", count, a.Count)
+		}
+		return nil
+	}
+
+	if len(matches) == 0 {
+		return fail("path matched no value")
+	}
+	for i, value := range matches {
+		at := a.Path
+		if len(matches) > 1 {
+			at = fmt.Sprintf("%s (match %d)", a.Path, i)
+		}
+		if a.Range != nil {
+			n, ok := value.(float64)
+			if !ok {
+				return fail("%s: expected a number, got %s", at, jsonTypeOf(value))
+			}
+			if !a.Range.contains(n) {
+				return fail("%s: %g is not %s", at, n, a.Range)
+			}
+			continue
+		}
+		if violations := a.Schema.violations(value, at); len(violations) > 0 {
+			return fail("%s", strings.Join(violations, "; "))
+		}
+	}
+	return nil
+}
+
+// valueSchema is the subset of JSON Schema assert steps check: type,
+// required, properties, additionalProperties: false, items, enum, minimum,
+// maximum, minLength, maxLength, minItems and maxItems. Other keywords are
+// rejected rather than silently ignored.
+type valueSchema struct {
+	Types                []string
+	Required             []string
+	Properties           map[string]*valueSchema
+	NoAdditional         bool
+	Items                *valueSchema
+	Enum                 []interface{}
+	Minimum, Maximum     *float64
+	MinLength, MaxLength *int
+	MinItems, MaxItems   *int
+}
+
+var schemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// schemaAnnotations are keywords that don't constrain values
+var schemaAnnotations = map[string]bool{"$schema": true, "$id": true, "title": true, "description": true, "examples": true}
+
+func (s *valueSchema) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("schema must be an object: %w", err)
+	}
+	compiled, err := compileValueSchema(raw)
+	if err != nil {
+		return err
+	}
+	*s = *compiled
+	return nil
+}
+
+func compileValueSchema(raw map[string]interface{}) (*valueSchema, error) {
+	s := &valueSchema{}
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := raw[key]
+		var err error
+		switch key {
+		case "type":
+			err = s.setTypes(value)
+		case "required":
+			if s.Required, err = stringList(value); err != nil {
+				return nil, fmt.Errorf("required %w", err)
+			}
+		case "properties":
+			props, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("properties must be an object")
+			}
+			s.Properties = make(map[string]*valueSchema, len(props))
+			for name, prop := range props {
+				propRaw, ok := prop.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("property %s must be a schema object", name)
+				}
+				if s.Properties[name], err = compileValueSchema(propRaw); err != nil {
+					return nil, fmt.Errorf("property %s: %w", name, err)
+				}
+			}
+		case "additionalProperties":
+			allowed, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("additionalProperties must be true or false")
+			}
+			s.NoAdditional = !allowed
+		case "items":
+			itemsRaw, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("items must be a schema object")
+			}
+			if s.Items, err = compileValueSchema(itemsRaw); err != nil {
+				return nil, fmt.Errorf("items: %w", err)
+			}
+		case "enum":
+			enum, ok := value.([]interface{})
+			if !ok || len(enum) == 0 {
+				return nil, fmt.Errorf("enum must be a non-empty list")
+			}
+			s.Enum = enum
+		case "minimum":
+			s.Minimum, err = schemaNumber(key, value)
+		case "maximum":
+			s.Maximum, err = schemaNumber(key, value)
+		case "minLength":
+			s.MinLength, err = schemaCount(key, value)
+		case "maxLength":
+			s.MaxLength, err = schemaCount(key, value)
+		case "minItems":
+			s.MinItems, err = schemaCount(key, value)
+		case "maxItems":
+			s.MaxItems, err = schemaCount(key, value)
+		default:
+			if !schemaAnnotations[key] {
+				return nil, fmt.Errorf("unsupported schema keyword %q", key)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *valueSchema) setTypes(value interface{}) error {
+	types, err := stringList(value)
+	if name, ok := value.(string); ok {
+		types, err = []string{name}, nil
+	}
+	if err != nil || len(types) == 0 {
+		return fmt.Errorf("type must be a string or list of strings")
+	}
+	for _, t := range types {
+		if !schemaTypes[t] {
+			return fmt.Errorf("unknown schema type %q", t)
+		}
+	}
+	s.Types = types
+	return nil
+}
+
+func schemaNumber(key string, value interface{}) (*float64, error) {
+	n, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a number", key)
+	}
+	return &n, nil
+}
+
+func schemaCount(key string, value interface{}) (*int, error) {
+	n, ok := value.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	count := int(n)
+	return &count, nil
+}
+
+// violations lists how a decoded JSON value breaks the schema, each
+// prefixed with where in the value it occurred
+func (s *valueSchema) violations(value interface{}, at string) []string {
+	var found []string
+	add := func(format string, args ...interface{}) {
+		found = append(found, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(s.Types) > 0 && !s.typeMatches(value) {
+		add("expected %s, got %s", strings.Join(s.Types, " or "), jsonTypeOf(value))
+		return found
+	}
+	if len(s.Enum) > 0 {
+		allowed := false
+		for _, option := range s.Enum {
+			if reflect.DeepEqual(option, value) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			add("value is not one of the enum options")
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			add("%g is below the minimum %g", v, *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			add("%g is above the maximum %g", v, *s.Maximum)
+		}
+	case string:
+		length := len([]rune(v))
+		if s.MinLength != nil && length < *s.MinLength {
+			add("length %d is below minLength %d", length, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			add("length %d is above maxLength %d", length, *s.MaxLength)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			add("%d items is below minItems %d", len(v), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			add("%d items is above maxItems %d", len(v), *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				found = append(found, s.Items.violations(item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				add("missing required property %s", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, declared := s.Properties[name]
+			if !declared {
+				if s.NoAdditional {
+					add("unexpected property %s", name)
+				}
+				continue
+			}
+			found = append(found, prop.violations(v[name], at+"."+name)...)
+		}
+	}
+	return found
+}
+
+func (s *valueSchema) typeMatches(value interface{}) bool {
+	actual := jsonTypeOf(value)
+	for _, t := range s.Types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeOf names the JSON Schema type of a decoded JSON value
+func jsonTypeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// AssertExecutor checks upstream outputs before later steps consume them.
+// Assertions select values from the step's inputs with a JSON path and
+// check row counts, value ranges or a JSON Schema:
+//
+//	steps:
+//	  - id: check
+//	    type: assert
+//	    config:
+//	      inputs: {orders: extract.orders}
+//	      assertions:
+//	        - {name: has_rows, path: $.orders, count: {min: 1}}
+//	        - {name: valid_totals, path: "$.orders[*].total", range: {min: 0}}
+//	        - {name: order_shape, path: "$.orders[*]", schema: {type: object, required: [id, total]}}
+//	      on_fail: branch
+//	      remediation: [repair]
+//
+// Failed assertions fail the run, or with on_fail: branch the step succeeds
+// and the scheduler runs only the remediation successors; when every
+// assertion holds only the other successors run. Both outputs are passed
+// and the failures list.
+type AssertExecutor struct {
+	worker *Worker
+}
+
+func NewAssertExecutor(worker *Worker) *AssertExecutor {
+	return &AssertExecutor{worker: worker}
+}
+
+func (e *AssertExecutor) CanHandle(stepType string) bool {
+	return ExecutorType(stepType) == ExecutorTypeAssert
+}
+
+func (e *AssertExecutor) Execute(ctx context.Context, task *Task) (*TaskResult, error) {
+	start := time.Now()
+	cfg, err := parseAssertConfig(task.Node.Config)
+	if err != nil {
+		return nil, err
+	}
+	failures, err := evaluateAssertions(cfg, task.Inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := assertBranchPass
+	switch {
+	case len(failures) == 0:
+		assertSteps.Inc("passed")
+	case cfg.OnFail == AssertOnFailFail:
+		assertSteps.Inc("failed")
+		return nil, &AssertionError{StepID: task.StepID, Checked: len(cfg.Assertions), Failures: failures}
+	default:
+		assertSteps.Inc("remediated")
+		branch = assertBranchRemediation
+	}
+	return &TaskResult{
+		TaskID: task.ID,
+		Status: TaskStatusSucceeded,
+		Output: map[string]interface{}{
+			"passed":   len(failures) == 0,
+			"failures": failures,
+			"branch":   branch,
+		},
+		ExecutedAt: time.Now(),
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// evaluateAssertions returns the assertions that don't hold for the inputs
+func evaluateAssertions(cfg *AssertConfig, inputs map[string]interface{}) ([]AssertionFailure, error) {
+	// Round-trip the inputs so paths see the same types as decoded JSON
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal assert inputs: %w", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode assert inputs: %w", err)
+	}
+
+	failures := make([]AssertionFailure, 0)
+	for i := range cfg.Assertions {
+		if failure := cfg.Assertions[i].check(doc); failure != nil {
+			failures = append(failures, *failure)
+		}
+	}
+	return failures, nil
+}
+
+// assertionSkips returns the steps an assert step's outcome leaves out: the
+// remediation successors when it passed, its other successors when it took
+// the remediation branch, and every step reachable only through those
+func assertionSkips(dag DAG, assertID string, remediation []string, branch string) []string {
+	isRemediation := make(map[string]bool, len(remediation))
+	for _, id := range remediation {
+		isRemediation[id] = true
+	}
+
+	skipped := make(map[string]bool)
+	for _, edge := range dag.Edges {
+		if edge.From == assertID && isRemediation[edge.To] == (branch == assertBranchPass) {
+			skipped[edge.To] = true
+		}
+	}
+
+	// Steps whose every dependency is skipped are skipped too; joins that
+	// also depend on the taken branch still run
+	preds := make(map[string][]string)
+	for _, edge := range dag.Edges {
+		preds[edge.To] = append(preds[edge.To], edge.From)
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, step := range dag.Steps {
+			if skipped[step.ID] || len(preds[step.ID]) == 0 {
+				continue
+			}
+			all := true
+			for _, pred := range preds[step.ID] {
+				if !skipped[pred] {
+					all = false
+					break
+				}
+			}
+			if all {
+				skipped[step.ID] = true
+				changed = true
+			}
+		}
+	}
+
+	skips := make([]string, 0, len(skipped))
+	for _, step := range dag.Steps {
+		if skipped[step.ID] {
+			skips = append(skips, step.ID)
+		}
+	}
+	return skips
+}
+
+// skipUntakenBranch records the steps an assert step's outcome leaves out as
+// skipped, so they are neither run nor waited on
+func (s *Scheduler) skipUntakenBranch(ctx context.Context, runID uuid.UUID, stepID, branch string) error {
+	var specID uuid.UUID
+	var overlayJSON []byte
+	err := s.db.QueryRowContext(ctx, `SELECT workflow_spec_id, dag_overlay FROM workflow_run WHERE id = $1`, runID).Scan(&specID, &overlayJSON)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("workflow run %s not found", runID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get workflow run: %w", err)
+	}
+	var overlay DAGOverlay
+	if err := json.Unmarshal(overlayJSON, &overlay); err != nil {
+		return fmt.Errorf("failed to unmarshal DAG overlay: %w", err)
+	}
+	spec, err := s.getWorkflowSpec(ctx, specID)
+	if err != nil {
+		return fmt.Errorf("failed to get workflow spec: %w", err)
+	}
+	dag := DAG{
+		Steps: append(append([]Step(nil), spec.DAG.Steps...), overlay.Steps...),
+		Edges: append(append([]Edge(nil), spec.DAG.Edges...), overlay.Edges...),
+	}
+
+	// Other step types may output a branch of their own meaning
+	step := findStep(dag.Steps, stepID)
+	if step == nil || ExecutorType(step.Type) != ExecutorTypeAssert {
+		return nil
+	}
+	cfg, err := parseAssertConfig(step.Config)
+	if err != nil {
+		return err
+	}
+
+	skips := assertionSkips(dag, stepID, cfg.Remediation, branch)
+	for _, id := range skips {
+		stepRun := &StepRun{
+			ID:            uuid.New().String(),
+			WorkflowRunID: runID,
+			NodeID:        id,
+			StepID:        id,
+			Attempt:       1,
+			Status:        StepStatusSkipped,
+			CreatedAt:     time.Now(),
+		}
+		if err := s.saveStepRun(ctx, stepRun); err != nil {
+			return fmt.Errorf("failed to save skipped step run: %w", err)
+		}
+	}
+	if len(skips) > 0 {
+		log.Printf("Assert step %s of run %s took the %s branch, skipping %v", stepID, runID, branch, skips)
+	}
+	return nil
+}
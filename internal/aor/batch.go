@@ -0,0 +1,361 @@
+package aor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxBatchRecords bounds the runs a single batch may create
+	maxBatchRecords = 10000
+	// maxBatchErrors bounds the per-record submission errors kept on a batch
+	maxBatchErrors = 100
+	// maxDatasetLineBytes bounds one JSONL record
+	maxDatasetLineBytes = 1 << 20
+)
+
+// BatchRunRequest creates one run per dataset record. Records come from a
+// stored dataset referenced by hash, or inline.
+type BatchRunRequest struct {
+	WorkflowName    string                   `json:"workflow_name"`
+	WorkflowVersion int                      `json:"workflow_version"`
+	Dataset         string                   `json:"dataset,omitempty"`
+	Records         []map[string]interface{} `json:"records,omitempty"`
+	Tags            []string                 `json:"tags"`
+	Labels          map[string]string        `json:"labels,omitempty"`
+	BudgetCents     int64                    `json:"budget_cents"`
+	Priority        int                      `json:"priority"`
+	Environment     string                   `json:"environment,omitempty"`
+}
+
+// BatchRecordError records a dataset record whose run could not be submitted
+type BatchRecordError struct {
+	Record int    `json:"record"`
+	Error  string `json:"error"`
+}
+
+// RunBatch is a group of runs submitted together over a dataset
+type RunBatch struct {
+	ID              uuid.UUID          `json:"id"`
+	OrgID           uuid.UUID          `json:"org_id"`
+	WorkflowName    string             `json:"workflow_name"`
+	WorkflowVersion int                `json:"workflow_version"`
+	DatasetRef      string             `json:"dataset_ref,omitempty"`
+	Tags            map[string]string  `json:"tags"`
+	TotalRecords    int                `json:"total_records"`
+	RejectedRecords int                `json:"rejected_records"`
+	Errors          []BatchRecordError `json:"errors,omitempty"`
+	CreatedAt       time.Time          `json:"created_at"`
+	Progress        *BatchProgress     `json:"progress,omitempty"`
+}
+
+// BatchProgress aggregates the status and cost of a batch's runs
+type BatchProgress struct {
+	Runs        int                    `json:"runs"`
+	ByStatus    map[WorkflowStatus]int `json:"by_status"`
+	Finished    int                    `json:"finished"`
+	PercentDone float64                `json:"percent_done"`
+	CostCents   int64                  `json:"cost_cents"`
+	Status      WorkflowStatus         `json:"status"`
+}
+
+// PutDataset validates and stores a JSONL dataset, returning its reference
+func (cp *ControlPlane) PutDataset(ctx context.Context, orgID uuid.UUID, content []byte) (string, int, error) {
+	records, err := ParseDatasetJSONL(bytes.NewReader(content))
+	if err != nil {
+		return "", 0, err
+	}
+
+	hash, err := cp.blobs.Put(ctx, orgID, db.BlobKindDataset, content)
+	if err != nil {
+		return "", 0, err
+	}
+	return hash, len(records), nil
+}
+
+// SubmitBatch creates a batch and one run per record with the batch's shared
+// tags. Records that fail admission are counted and reported on the batch
+// rather than failing the whole submission.
+func (cp *ControlPlane) SubmitBatch(ctx context.Context, req *BatchRunRequest) (*RunBatch, error) {
+	if err := cas.ValidateCostLabels(req.Labels); err != nil {
+		return nil, err
+	}
+
+	spec, err := cp.getWorkflowSpec(ctx, req.WorkflowName, req.WorkflowVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow spec: %w", err)
+	}
+
+	records, err := cp.batchRecords(ctx, spec.OrgID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &RunBatch{
+		ID:              uuid.New(),
+		OrgID:           spec.OrgID,
+		WorkflowName:    spec.Name,
+		WorkflowVersion: spec.Version,
+		DatasetRef:      req.Dataset,
+		Tags:            parseRunTags(req.Tags),
+		TotalRecords:    len(records),
+		CreatedAt:       time.Now(),
+	}
+	if err := cp.saveRunBatch(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	tags := append(append(make([]string, 0, len(req.Tags)+1), req.Tags...), "batch_id="+batch.ID.String())
+	for i, record := range records {
+		batchID := batch.ID
+		_, err := cp.SubmitWorkflow(ctx, &RunRequest{
+			WorkflowName:    spec.Name,
+			WorkflowVersion: spec.Version,
+			Inputs:          record,
+			Tags:            tags,
+			Labels:          req.Labels,
+			BudgetCents:     req.BudgetCents,
+			Priority:        req.Priority,
+			Trigger:         TriggerBatch,
+			Environment:     req.Environment,
+			BatchID:         &batchID,
+		})
+		if err != nil {
+			batch.RejectedRecords++
+			if len(batch.Errors) < maxBatchErrors {
+				batch.Errors = append(batch.Errors, BatchRecordError{Record: i + 1, Error: err.Error()})
+			}
+		}
+	}
+
+	if batch.RejectedRecords > 0 {
+		log.Printf("Batch %s: %d of %d records were not submitted", batch.ID, batch.RejectedRecords, batch.TotalRecords)
+		if err := cp.updateRunBatchErrors(ctx, batch); err != nil {
+			return nil, err
+		}
+	}
+
+	return batch, nil
+}
+
+// GetRunBatch returns a batch with the current status, progress and cost of its runs
+func (cp *ControlPlane) GetRunBatch(ctx context.Context, batchID uuid.UUID) (*RunBatch, error) {
+	query := `SELECT id, org_id, workflow_name, workflow_version, dataset_ref, tags,
+			  total_records, rejected_records, errors, created_at
+			  FROM run_batch WHERE id = $1`
+
+	batch, err := scanRunBatch(cp.db.QueryRowContext(ctx, query, batchID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("batch %s not found", batchID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := cp.db.QueryContext(ctx,
+		`SELECT status, COUNT(*), COALESCE(SUM(cost_cents), 0) FROM workflow_run WHERE batch_id = $1 GROUP BY status`, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch progress: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[WorkflowStatus]int)
+	var costCents int64
+	for rows.Next() {
+		var status WorkflowStatus
+		var count int
+		var cost int64
+		if err := rows.Scan(&status, &count, &cost); err != nil {
+			return nil, fmt.Errorf("failed to scan batch progress: %w", err)
+		}
+		counts[status] = count
+		costCents += cost
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get batch progress: %w", err)
+	}
+
+	batch.Progress = summarizeBatch(counts, costCents)
+	return batch, nil
+}
+
+// ListRunBatches returns an org's most recent batches without progress
+func (cp *ControlPlane) ListRunBatches(ctx context.Context, orgID uuid.UUID, limit int) ([]RunBatch, error) {
+	limit = db.ListOptions{Limit: limit}.PageSize()
+
+	query := `SELECT id, org_id, workflow_name, workflow_version, dataset_ref, tags,
+			  total_records, rejected_records, errors, created_at
+			  FROM run_batch WHERE org_id = $1 ORDER BY created_at DESC LIMIT $2`
+
+	rows, err := cp.db.QueryContext(ctx, query, orgID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list batches: %w", err)
+	}
+	defer rows.Close()
+
+	batches := make([]RunBatch, 0)
+	for rows.Next() {
+		batch, err := scanRunBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, *batch)
+	}
+	return batches, rows.Err()
+}
+
+// batchRecords resolves a request's records from its dataset or inline list
+func (cp *ControlPlane) batchRecords(ctx context.Context, orgID uuid.UUID, req *BatchRunRequest) ([]map[string]interface{}, error) {
+	records := req.Records
+	if req.Dataset != "" {
+		if len(req.Records) > 0 {
+			return nil, fmt.Errorf("batch takes a dataset or inline records, not both")
+		}
+		blob, err := cp.blobs.Get(ctx, orgID, req.Dataset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load dataset %s: %w", req.Dataset, err)
+		}
+		if blob.Kind != db.BlobKindDataset {
+			return nil, fmt.Errorf("%s is a %s, not a dataset", req.Dataset, blob.Kind)
+		}
+		if records, err = ParseDatasetJSONL(bytes.NewReader(blob.Content)); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("batch has no records")
+	}
+	if len(records) > maxBatchRecords {
+		return nil, fmt.Errorf("batch has %d records; the limit is %d", len(records), maxBatchRecords)
+	}
+	return records, nil
+}
+
+// ParseDatasetJSONL reads one JSON object per line; blank lines are skipped
+func ParseDatasetJSONL(r io.Reader) ([]map[string]interface{}, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxDatasetLineBytes)
+
+	records := make([]map[string]interface{}, 0)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("dataset line %d: record must be a JSON object: %w", line, err)
+		}
+		records = append(records, record)
+		if len(records) > maxBatchRecords {
+			return nil, fmt.Errorf("dataset has more than %d records", maxBatchRecords)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+
+	return records, nil
+}
+
+// summarizeBatch derives progress and an overall status from run counts. A
+// batch is running until every run finishes, then failed if any run failed.
+func summarizeBatch(counts map[WorkflowStatus]int, costCents int64) *BatchProgress {
+	progress := &BatchProgress{ByStatus: counts, CostCents: costCents}
+	for status, count := range counts {
+		progress.Runs += count
+		switch status {
+		case WorkflowStatusCompleted, WorkflowStatusFailed, WorkflowStatusCancelled:
+			progress.Finished += count
+		}
+	}
+	if progress.Runs > 0 {
+		progress.PercentDone = float64(progress.Finished) / float64(progress.Runs) * 100
+	}
+
+	switch {
+	case progress.Runs == 0:
+		progress.Status = WorkflowStatusFailed
+	case progress.Finished < progress.Runs && progress.Finished == 0 && counts[WorkflowStatusRunning] == 0:
+		progress.Status = WorkflowStatusPending
+	case progress.Finished < progress.Runs:
+		progress.Status = WorkflowStatusRunning
+	case counts[WorkflowStatusFailed] > 0:
+		progress.Status = WorkflowStatusFailed
+	case counts[WorkflowStatusCompleted] == 0:
+		progress.Status = WorkflowStatusCancelled
+	default:
+		progress.Status = WorkflowStatusCompleted
+	}
+
+	return progress
+}
+
+func (cp *ControlPlane) saveRunBatch(ctx context.Context, batch *RunBatch) error {
+	tagsJSON, err := json.Marshal(batch.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	query := `INSERT INTO run_batch (id, org_id, workflow_name, workflow_version, dataset_ref, tags, total_records, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err = cp.db.ExecContext(ctx, query,
+		batch.ID, batch.OrgID, batch.WorkflowName, batch.WorkflowVersion, batch.DatasetRef, tagsJSON, batch.TotalRecords, batch.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert run batch: %w", err)
+	}
+	return nil
+}
+
+func (cp *ControlPlane) updateRunBatchErrors(ctx context.Context, batch *RunBatch) error {
+	errorsJSON, err := json.Marshal(batch.Errors)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch errors: %w", err)
+	}
+
+	_, err = cp.db.ExecContext(ctx, `UPDATE run_batch SET rejected_records = $1, errors = $2 WHERE id = $3`,
+		batch.RejectedRecords, errorsJSON, batch.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update run batch: %w", err)
+	}
+	return nil
+}
+
+func scanRunBatch(row interface {
+	Scan(dest ...interface{}) error
+}) (*RunBatch, error) {
+	var batch RunBatch
+	var tagsJSON, errorsJSON []byte
+
+	err := row.Scan(&batch.ID, &batch.OrgID, &batch.WorkflowName, &batch.WorkflowVersion, &batch.DatasetRef, &tagsJSON,
+		&batch.TotalRecords, &batch.RejectedRecords, &errorsJSON, &batch.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan run batch: %w", err)
+	}
+	if err := json.Unmarshal(tagsJSON, &batch.Tags); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+	}
+	if err := json.Unmarshal(errorsJSON, &batch.Errors); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch errors: %w", err)
+	}
+	return &batch, nil
+}
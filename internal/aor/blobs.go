@@ -0,0 +1,116 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// CanonicalSpecBytes returns the bytes a spec is content-addressed by. Identity
+// fields (ID, org, version, timestamps) are excluded so identical content hashes
+// identically across versions.
+func CanonicalSpecBytes(spec *WorkflowSpec) ([]byte, error) {
+	canonical := struct {
+		Name     string   `json:"name"`
+		DAG      DAG      `json:"dag"`
+		Metadata Metadata `json:"metadata"`
+	}{spec.Name, spec.DAG, spec.Metadata}
+
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal spec: %w", err)
+	}
+	return data, nil
+}
+
+// GetBlob returns stored spec or prompt content by hash
+func (cp *ControlPlane) GetBlob(ctx context.Context, orgID uuid.UUID, hash string) (*db.Blob, error) {
+	return cp.blobs.Get(ctx, orgID, hash)
+}
+
+// pinRunContent stores the spec content and returns the spec and prompt hashes
+// a run executes with, for recording in run metadata
+func (cp *ControlPlane) pinRunContent(ctx context.Context, spec *WorkflowSpec) (string, map[string]string, error) {
+	content, err := CanonicalSpecBytes(spec)
+	if err != nil {
+		return "", nil, err
+	}
+
+	specHash, err := cp.blobs.Put(ctx, spec.OrgID, db.BlobKindWorkflowSpec, content)
+	if err != nil {
+		return "", nil, err
+	}
+	if spec.ContentHash != "" && spec.ContentHash != specHash {
+		return "", nil, fmt.Errorf("workflow spec %s v%d content does not match recorded hash %s", spec.Name, spec.Version, spec.ContentHash)
+	}
+
+	promptHashes := make(map[string]string)
+	for _, step := range spec.DAG.Steps {
+		promptRef, _ := step.Config["prompt_ref"].(string)
+		if promptRef == "" {
+			continue
+		}
+		if _, seen := promptHashes[promptRef]; seen {
+			continue
+		}
+
+		hash, err := cp.resolvePromptHash(ctx, spec.OrgID, promptRef)
+		if err != nil {
+			return "", nil, err
+		}
+		if hash != "" {
+			promptHashes[promptRef] = hash
+		}
+	}
+
+	return specHash, promptHashes, nil
+}
+
+// resolvePromptHash finds the content hash of the prompt version a ref resolves
+// to: the pinned version, else the stable deployment, else the latest version
+func (cp *ControlPlane) resolvePromptHash(ctx context.Context, orgID uuid.UUID, promptRef string) (string, error) {
+	name, version := parsePromptRef(promptRef)
+
+	query := `SELECT COALESCE(content_hash, '') FROM prompt_template
+			  WHERE org_id = $1 AND name = $2
+			  AND version = COALESCE($3,
+			      (SELECT stable_version FROM prompt_deployment
+			       WHERE org_id = $1 AND prompt_name = $2 ORDER BY updated_at DESC LIMIT 1),
+			      (SELECT MAX(version) FROM prompt_template WHERE org_id = $1 AND name = $2))`
+
+	var pinned interface{}
+	if version > 0 {
+		pinned = version
+	}
+
+	var hash string
+	err := cp.db.QueryRowContext(ctx, query, orgID, name, pinned).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve prompt hash: %w", err)
+	}
+
+	return hash, nil
+}
+
+// parsePromptRef splits "name@version" into its parts; version is 0 when unpinned
+func parsePromptRef(ref string) (string, int) {
+	name, versionStr, found := strings.Cut(ref, "@")
+	if !found {
+		return ref, 0
+	}
+	version, err := strconv.Atoi(versionStr)
+	if err != nil {
+		return ref, 0
+	}
+	return name, version
+}
@@ -0,0 +1,116 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/google/uuid"
+)
+
+// checkRunBudget evaluates workflow, project and org budgets before a run is
+// created. Runs without an explicit budget are checked at their estimated cost.
+func (cp *ControlPlane) checkRunBudget(ctx context.Context, spec *WorkflowSpec, req *RunRequest, estimatedCents int64) (*cas.BudgetEnforcement, error) {
+	scope := &cas.BudgetScopeRequest{
+		OrgID:        spec.OrgID,
+		WorkflowName: spec.Name,
+		Tags:         parseRunTags(req.Tags),
+		Labels:       req.Labels,
+	}
+
+	requested := req.BudgetCents
+	if requested == 0 {
+		requested = estimatedCents
+	}
+
+	enforcement, err := cp.budgets.CheckScopedBudgets(ctx, scope, requested)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check budgets: %w", err)
+	}
+
+	if !enforcement.Allowed {
+		return enforcement, fmt.Errorf("run throttled by %s budget: %s", enforcement.ThrottledBy, describeEnforcement(enforcement))
+	}
+
+	return enforcement, nil
+}
+
+// enforceRunBudget records step spending against the run's budgets and
+// cancels the run when the most restrictive scope is exhausted
+func (cp *ControlPlane) enforceRunBudget(ctx context.Context, runID uuid.UUID, costCents int64) error {
+	run, err := cp.GetWorkflowRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+
+	scope := &cas.BudgetScopeRequest{
+		OrgID:        run.OrgID,
+		WorkflowName: run.WorkflowName,
+		Tags:         parseRunTags(metadataStrings(run.Metadata, "tags")),
+		Labels:       run.Labels,
+	}
+
+	if err := cp.budgets.RecordScopedSpending(ctx, scope, costCents); err != nil {
+		return fmt.Errorf("failed to record run spending: %w", err)
+	}
+
+	enforcement, err := cp.budgets.CheckScopedBudgets(ctx, scope, 0)
+	if err != nil {
+		return fmt.Errorf("failed to check budgets: %w", err)
+	}
+	cp.publishBudgetThresholds(run, enforcement, costCents)
+
+	if !enforcement.Allowed {
+		message := fmt.Sprintf("%s budget exhausted: %s", enforcement.ThrottledBy, describeEnforcement(enforcement))
+		log.Printf("Run %s throttled: %s", runID, message)
+		if err := cp.recordRunFailure(ctx, runID, newRunFailure(FailureBudget, "", message)); err != nil {
+			return err
+		}
+		return cp.CancelWorkflowRun(ctx, runID)
+	}
+
+	return nil
+}
+
+// GetBudgetUsage reports an org's current period spending and whether its
+// provider calls are being rejected or throttled
+func (cp *ControlPlane) GetBudgetUsage(ctx context.Context, orgID uuid.UUID) (*cas.BudgetUsage, error) {
+	return cp.guard.Usage(ctx, orgID)
+}
+
+// describeEnforcement renders the per-scope budget breakdown
+func describeEnforcement(enforcement *cas.BudgetEnforcement) string {
+	parts := make([]string, 0, len(enforcement.Breakdown))
+	for _, scoped := range enforcement.Breakdown {
+		parts = append(parts, fmt.Sprintf("%s %d/%d cents (%s)", scoped.Scope, scoped.SpentCents, scoped.LimitCents, scoped.Status))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseRunTags converts "key=value" run tags into a map; bare tags map to ""
+func parseRunTags(tags []string) map[string]string {
+	parsed := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(tag, "=")
+		parsed[key] = value
+	}
+	return parsed
+}
+
+// metadataStrings reads a string slice stored in run metadata
+func metadataStrings(metadata map[string]interface{}, key string) []string {
+	raw, ok := metadata[key].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	values := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
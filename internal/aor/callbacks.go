@@ -0,0 +1,405 @@
+package aor
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+	"github.com/google/uuid"
+)
+
+const (
+	// CallbackEventRunFinished is sent once a run reaches a terminal status
+	CallbackEventRunFinished = "run.finished"
+
+	// Headers sent with every callback delivery
+	CallbackSignatureHeader = "X-AgentFlow-Signature"
+	CallbackTimestampHeader = "X-AgentFlow-Timestamp"
+	CallbackDeliveryHeader  = "X-AgentFlow-Delivery"
+	CallbackEventHeader     = "X-AgentFlow-Event"
+
+	// callbackBaseBackoff doubles after each failed delivery up to callbackMaxBackoff
+	callbackBaseBackoff = 30 * time.Second
+	callbackMaxBackoff  = time.Hour
+	// callbackLease keeps other control planes from delivering a claimed callback
+	callbackLease = 5 * time.Minute
+	// callbackBatchSize bounds the deliveries made per monitor tick
+	callbackBatchSize = 50
+	// callbackMaxErrorBody is how much of a failed response body is kept
+	callbackMaxErrorBody = 512
+)
+
+// RunCallback asks the control plane to POST the run result to URL when the
+// run finishes. Redact scrubs PII from outputs first (off, standard, strict).
+type RunCallback struct {
+	URL    string `json:"url"`
+	Redact string `json:"redact,omitempty"`
+}
+
+// RunCallbackPayload is the JSON body delivered to a run's callback URL
+type RunCallbackPayload struct {
+	Event        string            `json:"event"`
+	RunID        uuid.UUID         `json:"run_id"`
+	WorkflowName string            `json:"workflow_name"`
+	Status       WorkflowStatus    `json:"status"`
+	CostCents    int64             `json:"cost_cents"`
+	Output       interface{}       `json:"output,omitempty"`
+	Steps        []RunCallbackStep `json:"steps"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Redactions   map[string]int    `json:"redactions,omitempty"`
+	EndedAt      *time.Time        `json:"ended_at,omitempty"`
+	Attempt      int               `json:"attempt"`
+}
+
+// RunCallbackStep summarizes the final attempt of a step
+type RunCallbackStep struct {
+	NodeID    string `json:"node_id"`
+	Status    string `json:"status"`
+	OutputRef string `json:"output_ref,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// validateRunCallback normalizes a callback request, requiring an absolute
+// https URL; plain http is only accepted for local receivers
+func validateRunCallback(cb *RunCallback) error {
+	parsed, err := url.Parse(cb.URL)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("invalid callback URL %q", cb.URL)
+	}
+	switch parsed.Scheme {
+	case "https":
+	case "http":
+		host := parsed.Hostname()
+		if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return fmt.Errorf("callback URL must use https")
+		}
+	default:
+		return fmt.Errorf("callback URL must use https")
+	}
+
+	if cb.Redact == "" {
+		cb.Redact = string(scl.ScrubLevelOff)
+		return nil
+	}
+	level, err := scl.ParseScrubLevel(cb.Redact)
+	if err != nil {
+		return fmt.Errorf("invalid callback redaction: %w", err)
+	}
+	cb.Redact = string(level)
+	return nil
+}
+
+// saveRunCallback registers a callback to be delivered when the run finishes
+func (cp *ControlPlane) saveRunCallback(ctx context.Context, runID uuid.UUID, cb *RunCallback) error {
+	query := `INSERT INTO run_callback (run_id, url, redact_level) VALUES ($1, $2, $3)`
+	if _, err := cp.db.ExecContext(ctx, query, runID, cb.URL, cb.Redact); err != nil {
+		return fmt.Errorf("failed to save run callback: %w", err)
+	}
+	return nil
+}
+
+type pendingCallback struct {
+	runID    uuid.UUID
+	url      string
+	redact   string
+	attempts int
+}
+
+// deliverRunCallbacks posts results for finished runs with a due callback.
+// Failed deliveries back off exponentially until the attempt limit is hit.
+func (cp *ControlPlane) deliverRunCallbacks(ctx context.Context) {
+	// Claim due callbacks with a lease so concurrent control planes skip them
+	query := `UPDATE run_callback SET next_attempt_at = NOW() + $1::interval, attempts = attempts + 1
+			  WHERE run_id IN (
+				  SELECT c.run_id FROM run_callback c JOIN workflow_run r ON r.id = c.run_id
+				  WHERE c.status = 'pending' AND c.next_attempt_at <= NOW()
+				    AND r.status IN ('succeeded', 'failed', 'canceled', 'partial-success', 'budget_exceeded')
+				  ORDER BY c.next_attempt_at
+				  LIMIT $2
+				  FOR UPDATE OF c SKIP LOCKED
+			  )
+			  RETURNING run_id, url, redact_level, attempts`
+
+	rows, err := cp.db.QueryContext(ctx, query, fmt.Sprintf("%d seconds", int(callbackLease.Seconds())), callbackBatchSize)
+	if err != nil {
+		log.Printf("Failed to claim run callbacks: %v", err)
+		return
+	}
+
+	var due []pendingCallback
+	for rows.Next() {
+		var cb pendingCallback
+		if err := rows.Scan(&cb.runID, &cb.url, &cb.redact, &cb.attempts); err != nil {
+			log.Printf("Failed to scan run callback: %v", err)
+			continue
+		}
+		due = append(due, cb)
+	}
+	rows.Close()
+
+	if len(due) == 0 {
+		return
+	}
+
+	timeout := cp.cfg.Callbacks.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	for _, cb := range due {
+		statusCode, err := cp.deliverRunCallback(ctx, client, cb)
+		cp.recordCallbackAttempt(ctx, cb, statusCode, err)
+	}
+}
+
+func (cp *ControlPlane) deliverRunCallback(ctx context.Context, client *http.Client, cb pendingCallback) (int, error) {
+	payload, err := cp.buildCallbackPayload(ctx, cb.runID, scl.ScrubLevel(cb.redact))
+	if err != nil {
+		return 0, err
+	}
+	payload.Attempt = cb.attempts
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal callback payload: %w", err)
+	}
+	return postCallback(ctx, client, cb.url, cp.cfg.Callbacks.SigningSecret, cb.runID.String(), body, time.Now())
+}
+
+// buildCallbackPayload assembles the run result, scrubbing outputs at the
+// callback's redaction level
+func (cp *ControlPlane) buildCallbackPayload(ctx context.Context, runID uuid.UUID, redact scl.ScrubLevel) (*RunCallbackPayload, error) {
+	run, err := cp.GetWorkflowRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := cp.runOutput(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &RunCallbackPayload{
+		Event:        CallbackEventRunFinished,
+		RunID:        run.ID,
+		WorkflowName: run.WorkflowName,
+		Status:       run.Status,
+		CostCents:    run.CostCents,
+		Output:       output,
+		Steps:        make([]RunCallbackStep, 0),
+		Tags:         run.Tags,
+		Labels:       run.Labels,
+		EndedAt:      run.EndedAt,
+	}
+
+	// Report only the latest attempt of each step
+	query := `SELECT DISTINCT ON (node_id) node_id, status, COALESCE(output_ref, ''), COALESCE(error, '')
+			  FROM step_run WHERE workflow_run_id = $1
+			  ORDER BY node_id, attempt DESC`
+	rows, err := cp.db.QueryContext(ctx, query, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query step runs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var step RunCallbackStep
+		if err := rows.Scan(&step.NodeID, &step.Status, &step.OutputRef, &step.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan step run: %w", err)
+		}
+		payload.Steps = append(payload.Steps, step)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read step runs: %w", err)
+	}
+
+	redactCallbackPayload(payload, cp.redaction.Redactor(ctx, run.OrgID), redact)
+	return payload, nil
+}
+
+// redactCallbackPayload scrubs the output and step errors in place
+func redactCallbackPayload(payload *RunCallbackPayload, redactor *scl.Redactor, level scl.ScrubLevel) {
+	if level == "" || level == scl.ScrubLevelOff {
+		return
+	}
+
+	counts := make(map[string]int)
+	merge := func(found map[string]int) {
+		for kind, n := range found {
+			counts[kind] += n
+		}
+	}
+
+	if payload.Output != nil {
+		scrubbed, found := redactor.Scrub(payload.Output, level)
+		payload.Output = scrubbed
+		merge(found)
+	}
+	for i := range payload.Steps {
+		if payload.Steps[i].Error == "" {
+			continue
+		}
+		scrubbed, found := redactor.Scrub(payload.Steps[i].Error, level)
+		if s, ok := scrubbed.(string); ok {
+			payload.Steps[i].Error = s
+		}
+		merge(found)
+	}
+	if len(counts) > 0 {
+		payload.Redactions = counts
+	}
+}
+
+// recordCallbackAttempt marks a delivery done or schedules its retry
+func (cp *ControlPlane) recordCallbackAttempt(ctx context.Context, cb pendingCallback, statusCode int, deliveryErr error) {
+	var code interface{}
+	if statusCode > 0 {
+		code = statusCode
+	}
+
+	if deliveryErr == nil {
+		runCallbacks.Inc("delivered")
+		query := `UPDATE run_callback SET status = 'delivered', delivered_at = NOW(), last_status_code = $2, last_error = NULL
+				  WHERE run_id = $1`
+		if _, err := cp.db.ExecContext(ctx, query, cb.runID, code); err != nil {
+			log.Printf("Failed to record callback delivery for run %s: %v", cb.runID, err)
+		}
+		return
+	}
+
+	maxAttempts := cp.cfg.Callbacks.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	status := "pending"
+	next := time.Now().Add(callbackBackoff(cb.attempts))
+	if cb.attempts >= maxAttempts {
+		status = "failed"
+		runCallbacks.Inc("failed")
+		log.Printf("Giving up on callback for run %s after %d attempts: %v", cb.runID, cb.attempts, deliveryErr)
+	} else {
+		runCallbacks.Inc("retrying")
+		log.Printf("Callback for run %s failed (attempt %d), retrying at %s: %v", cb.runID, cb.attempts, next.Format(time.RFC3339), deliveryErr)
+	}
+
+	query := `UPDATE run_callback SET status = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5
+			  WHERE run_id = $1`
+	if _, err := cp.db.ExecContext(ctx, query, cb.runID, status, next, code, deliveryErr.Error()); err != nil {
+		log.Printf("Failed to record callback attempt for run %s: %v", cb.runID, err)
+	}
+}
+
+// callbackBackoff is the wait after the given number of failed attempts
+func callbackBackoff(attempts int) time.Duration {
+	backoff := callbackBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= callbackMaxBackoff {
+			return callbackMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// postCallback sends a signed callback body, treating any non-2xx response as a failure
+func postCallback(ctx context.Context, client *http.Client, target, secret, deliveryID string, body []byte, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create callback request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackEventHeader, CallbackEventRunFinished)
+	req.Header.Set(CallbackDeliveryHeader, deliveryID)
+	req.Header.Set(CallbackTimestampHeader, timestamp)
+	if secret != "" {
+		req.Header.Set(CallbackSignatureHeader, "sha256="+SignCallback(secret, timestamp, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send callback: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, callbackMaxErrorBody))
+		return resp.StatusCode, fmt.Errorf("callback returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return resp.StatusCode, nil
+}
+
+// SignCallback computes the hex HMAC-SHA256 of "timestamp.body", the value
+// sent as sha256=<signature> in X-AgentFlow-Signature
+func SignCallback(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCallback checks a callback's signature header and rejects timestamps
+// older than tolerance, for receivers guarding against replays
+func VerifyCallback(secret, signature, timestamp string, body []byte, tolerance time.Duration, now time.Time) error {
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid callback timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.Unix(sent, 0)); tolerance > 0 && (age > tolerance || age < -tolerance) {
+		return fmt.Errorf("callback timestamp outside tolerance")
+	}
+
+	expected := "sha256=" + SignCallback(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("callback signature mismatch")
+	}
+	return nil
+}
+
+// RunCallbackStatus is the delivery state of a run's callback
+type RunCallbackStatus struct {
+	RunID          uuid.UUID  `json:"run_id"`
+	URL            string     `json:"url"`
+	Redact         string     `json:"redact"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// GetRunCallback returns the delivery state of a run's callback
+func (cp *ControlPlane) GetRunCallback(ctx context.Context, runID uuid.UUID) (*RunCallbackStatus, error) {
+	query := `SELECT url, redact_level, status, attempts, next_attempt_at, last_status_code, COALESCE(last_error, ''), delivered_at
+			  FROM run_callback WHERE run_id = $1`
+
+	status := &RunCallbackStatus{RunID: runID}
+	var code sql.NullInt64
+	err := cp.db.QueryRowContext(ctx, query, runID).Scan(
+		&status.URL, &status.Redact, &status.Status, &status.Attempts, &status.NextAttemptAt, &code, &status.LastError, &status.DeliveredAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get run callback: %w", err)
+	}
+	status.LastStatusCode = int(code.Int64)
+	return status, nil
+}
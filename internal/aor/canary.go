@@ -0,0 +1,459 @@
+package aor
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CanaryVerdict is the outcome of comparing a canary to the stable version
+type CanaryVerdict string
+
+const (
+	// CanaryPending means either version has too few finished runs to judge
+	CanaryPending   CanaryVerdict = "pending"
+	CanaryHealthy   CanaryVerdict = "healthy"
+	CanaryRegressed CanaryVerdict = "regressed"
+)
+
+// Defaults for CanaryPolicy fields left at zero
+const (
+	defaultCanaryMinRuns            = 20
+	defaultCanaryMaxSuccessDrop     = 0.05
+	defaultCanaryMaxCostIncrease    = 0.20
+	defaultCanaryMaxLatencyIncrease = 0.25
+)
+
+// CanaryPolicy sets how far a canary may fall behind the stable version.
+// Success rate drop is absolute (0.05 is five points); cost and latency
+// increases are relative to stable (0.2 is 20% more).
+type CanaryPolicy struct {
+	MinRuns            int     `json:"min_runs,omitempty"`
+	MaxSuccessDrop     float64 `json:"max_success_drop,omitempty"`
+	MaxCostIncrease    float64 `json:"max_cost_increase,omitempty"`
+	MaxLatencyIncrease float64 `json:"max_latency_increase,omitempty"`
+	AutoRollback       bool    `json:"auto_rollback,omitempty"`
+}
+
+// WorkflowDeployment splits unpinned run starts for a workflow between the
+// stable spec version and an optional canary version
+type WorkflowDeployment struct {
+	ID              uuid.UUID    `json:"id"`
+	OrgID           uuid.UUID    `json:"org_id"`
+	WorkflowName    string       `json:"workflow_name"`
+	StableVersion   int          `json:"stable_version"`
+	CanaryVersion   *int         `json:"canary_version,omitempty"`
+	CanaryRatio     float64      `json:"canary_ratio,omitempty"`
+	Policy          CanaryPolicy `json:"policy"`
+	CanaryStartedAt *time.Time   `json:"canary_started_at,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// WorkflowDeploymentRequest sets a workflow's stable and canary versions
+type WorkflowDeploymentRequest struct {
+	WorkflowName  string       `json:"workflow_name"`
+	StableVersion int          `json:"stable_version"`
+	CanaryVersion *int         `json:"canary_version,omitempty"`
+	CanaryRatio   float64      `json:"canary_ratio,omitempty"`
+	Policy        CanaryPolicy `json:"policy"`
+}
+
+// VersionRunStats summarizes finished runs of one spec version
+type VersionRunStats struct {
+	Version      int           `json:"version"`
+	Runs         int           `json:"runs"`
+	Succeeded    int           `json:"succeeded"`
+	SuccessRate  float64       `json:"success_rate"`
+	AvgCostCents float64       `json:"avg_cost_cents"`
+	AvgLatency   time.Duration `json:"avg_latency"`
+	P90Latency   time.Duration `json:"p90_latency"`
+}
+
+// CanaryComparison compares a canary to the stable version over runs started
+// since the canary began
+type CanaryComparison struct {
+	WorkflowName string          `json:"workflow_name"`
+	Stable       VersionRunStats `json:"stable"`
+	Canary       VersionRunStats `json:"canary"`
+	Verdict      CanaryVerdict   `json:"verdict"`
+	Reasons      []string        `json:"reasons,omitempty"`
+	Since        time.Time       `json:"since"`
+}
+
+// withDefaults fills unset thresholds
+func (p CanaryPolicy) withDefaults() CanaryPolicy {
+	if p.MinRuns <= 0 {
+		p.MinRuns = defaultCanaryMinRuns
+	}
+	if p.MaxSuccessDrop <= 0 {
+		p.MaxSuccessDrop = defaultCanaryMaxSuccessDrop
+	}
+	if p.MaxCostIncrease <= 0 {
+		p.MaxCostIncrease = defaultCanaryMaxCostIncrease
+	}
+	if p.MaxLatencyIncrease <= 0 {
+		p.MaxLatencyIncrease = defaultCanaryMaxLatencyIncrease
+	}
+	return p
+}
+
+// Validate checks the versions and traffic split
+func (r *WorkflowDeploymentRequest) Validate() error {
+	if r.WorkflowName == "" {
+		return fmt.Errorf("workflow name is required")
+	}
+	if r.StableVersion < 1 {
+		return fmt.Errorf("stable version is required")
+	}
+	if r.CanaryRatio < 0 || r.CanaryRatio > 1 {
+		return fmt.Errorf("canary ratio must be between 0 and 1")
+	}
+	if r.CanaryVersion == nil {
+		if r.CanaryRatio > 0 {
+			return fmt.Errorf("canary ratio requires a canary version")
+		}
+		return nil
+	}
+	if *r.CanaryVersion == r.StableVersion {
+		return fmt.Errorf("canary version must differ from the stable version")
+	}
+	if r.CanaryRatio == 0 {
+		return fmt.Errorf("canary version %d needs a canary ratio above 0", *r.CanaryVersion)
+	}
+	return nil
+}
+
+// pickVersion routes one run start given a uniform random draw in [0, 1)
+func (d *WorkflowDeployment) pickVersion(draw float64) (int, bool) {
+	if d.CanaryVersion != nil && draw < d.CanaryRatio {
+		return *d.CanaryVersion, true
+	}
+	return d.StableVersion, false
+}
+
+// evaluateCanary judges a canary against stable. Cost and latency are only
+// compared once both versions have enough finished runs.
+func evaluateCanary(stable, canary VersionRunStats, policy CanaryPolicy) (CanaryVerdict, []string) {
+	policy = policy.withDefaults()
+	if stable.Runs < policy.MinRuns || canary.Runs < policy.MinRuns {
+		return CanaryPending, []string{fmt.Sprintf("waiting for %d finished runs per version (stable %d, canary %d)",
+			policy.MinRuns, stable.Runs, canary.Runs)}
+	}
+
+	var reasons []string
+	if drop := stable.SuccessRate - canary.SuccessRate; drop > policy.MaxSuccessDrop {
+		reasons = append(reasons, fmt.Sprintf("success rate %.1f%% vs %.1f%% stable",
+			canary.SuccessRate*100, stable.SuccessRate*100))
+	}
+	if stable.AvgCostCents > 0 && canary.AvgCostCents > stable.AvgCostCents*(1+policy.MaxCostIncrease) {
+		reasons = append(reasons, fmt.Sprintf("average cost %.1f¢ vs %.1f¢ stable",
+			canary.AvgCostCents, stable.AvgCostCents))
+	}
+	if stable.P90Latency > 0 && float64(canary.P90Latency) > float64(stable.P90Latency)*(1+policy.MaxLatencyIncrease) {
+		reasons = append(reasons, fmt.Sprintf("p90 latency %s vs %s stable",
+			canary.P90Latency.Round(time.Millisecond), stable.P90Latency.Round(time.Millisecond)))
+	}
+	if len(reasons) > 0 {
+		return CanaryRegressed, reasons
+	}
+	return CanaryHealthy, nil
+}
+
+// DeployWorkflow sets the stable version for unpinned run starts and
+// optionally sends a share of them to a canary version
+func (cp *ControlPlane) DeployWorkflow(ctx context.Context, orgID uuid.UUID, req *WorkflowDeploymentRequest) (*WorkflowDeployment, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	versions := []int{req.StableVersion}
+	if req.CanaryVersion != nil {
+		versions = append(versions, *req.CanaryVersion)
+	}
+	for _, version := range versions {
+		spec, err := cp.getWorkflowSpec(ctx, req.WorkflowName, version)
+		if err != nil || spec.OrgID != orgID {
+			return nil, fmt.Errorf("workflow %s version %d not found", req.WorkflowName, version)
+		}
+	}
+
+	now := time.Now()
+	deployment := &WorkflowDeployment{
+		ID:            uuid.New(),
+		OrgID:         orgID,
+		WorkflowName:  req.WorkflowName,
+		StableVersion: req.StableVersion,
+		CanaryVersion: req.CanaryVersion,
+		CanaryRatio:   req.CanaryRatio,
+		Policy:        req.Policy,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	// A canary's comparison window restarts only when the canary version changes
+	current, err := cp.GetWorkflowDeployment(ctx, orgID, req.WorkflowName)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if req.CanaryVersion != nil {
+		deployment.CanaryStartedAt = &now
+		if current != nil && current.CanaryVersion != nil && *current.CanaryVersion == *req.CanaryVersion {
+			deployment.CanaryStartedAt = current.CanaryStartedAt
+		}
+	}
+
+	if err := cp.saveWorkflowDeployment(ctx, deployment); err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// GetWorkflowDeployment returns a workflow's deployment, or sql.ErrNoRows if
+// it has none and runs start on the latest version
+func (cp *ControlPlane) GetWorkflowDeployment(ctx context.Context, orgID uuid.UUID, workflowName string) (*WorkflowDeployment, error) {
+	query := `SELECT id, org_id, workflow_name, stable_version, canary_version, canary_ratio, policy,
+			  canary_started_at, created_at, updated_at
+			  FROM workflow_deployment WHERE org_id = $1 AND workflow_name = $2`
+	return cp.scanWorkflowDeployment(cp.db.QueryRowContext(ctx, query, orgID, workflowName))
+}
+
+// PromoteWorkflowCanary makes the canary the stable version for all run starts
+func (cp *ControlPlane) PromoteWorkflowCanary(ctx context.Context, orgID uuid.UUID, workflowName string) (*WorkflowDeployment, error) {
+	deployment, err := cp.activeCanary(ctx, orgID, workflowName)
+	if err != nil {
+		return nil, err
+	}
+	deployment.StableVersion = *deployment.CanaryVersion
+	return cp.endCanary(ctx, deployment, "promoted")
+}
+
+// RollbackWorkflowCanary sends all run starts back to the stable version
+func (cp *ControlPlane) RollbackWorkflowCanary(ctx context.Context, orgID uuid.UUID, workflowName string) (*WorkflowDeployment, error) {
+	deployment, err := cp.activeCanary(ctx, orgID, workflowName)
+	if err != nil {
+		return nil, err
+	}
+	return cp.endCanary(ctx, deployment, "rolled back")
+}
+
+// CompareWorkflowCanary compares success rate, cost and latency of runs on
+// the canary and stable versions since the canary started
+func (cp *ControlPlane) CompareWorkflowCanary(ctx context.Context, orgID uuid.UUID, workflowName string) (*CanaryComparison, error) {
+	deployment, err := cp.activeCanary(ctx, orgID, workflowName)
+	if err != nil {
+		return nil, err
+	}
+	return cp.compareCanary(ctx, deployment)
+}
+
+func (cp *ControlPlane) compareCanary(ctx context.Context, deployment *WorkflowDeployment) (*CanaryComparison, error) {
+	since := deployment.UpdatedAt
+	if deployment.CanaryStartedAt != nil {
+		since = *deployment.CanaryStartedAt
+	}
+
+	stats, err := cp.versionRunStats(ctx, deployment.OrgID, deployment.WorkflowName, since,
+		deployment.StableVersion, *deployment.CanaryVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &CanaryComparison{
+		WorkflowName: deployment.WorkflowName,
+		Stable:       stats[deployment.StableVersion],
+		Canary:       stats[*deployment.CanaryVersion],
+		Since:        since,
+	}
+	comparison.Stable.Version = deployment.StableVersion
+	comparison.Canary.Version = *deployment.CanaryVersion
+	comparison.Verdict, comparison.Reasons = evaluateCanary(comparison.Stable, comparison.Canary, deployment.Policy)
+	return comparison, nil
+}
+
+// routeWorkflowVersion picks the spec version for a run that did not pin
+// one: the deployment's stable or canary version, else the latest version
+func (cp *ControlPlane) routeWorkflowVersion(ctx context.Context, workflowName string) (int, bool, error) {
+	query := `SELECT id, org_id, workflow_name, stable_version, canary_version, canary_ratio, policy,
+			  canary_started_at, created_at, updated_at
+			  FROM workflow_deployment WHERE workflow_name = $1`
+	deployment, err := cp.scanWorkflowDeployment(cp.db.QueryRowContext(ctx, query, workflowName))
+	if err == nil {
+		version, canary := deployment.pickVersion(secureRandFloat64())
+		return version, canary, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, err
+	}
+
+	var latest sql.NullInt64
+	if err := cp.db.QueryRowContext(ctx, `SELECT MAX(version) FROM workflow_spec WHERE name = $1`, workflowName).Scan(&latest); err != nil {
+		return 0, false, fmt.Errorf("failed to resolve latest workflow version: %w", err)
+	}
+	if !latest.Valid {
+		return 0, false, fmt.Errorf("workflow %s not found", workflowName)
+	}
+	return int(latest.Int64), false, nil
+}
+
+// checkWorkflowCanaries rolls back regressed canaries whose policy opts in
+func (cp *ControlPlane) checkWorkflowCanaries(ctx context.Context) {
+	query := `SELECT id, org_id, workflow_name, stable_version, canary_version, canary_ratio, policy,
+			  canary_started_at, created_at, updated_at
+			  FROM workflow_deployment WHERE canary_version IS NOT NULL AND (policy->>'auto_rollback')::boolean`
+	rows, err := cp.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("Failed to query workflow canaries: %v", err)
+		return
+	}
+	var deployments []*WorkflowDeployment
+	for rows.Next() {
+		deployment, err := cp.scanWorkflowDeployment(rows)
+		if err != nil {
+			log.Printf("Failed to scan workflow canary: %v", err)
+			continue
+		}
+		deployments = append(deployments, deployment)
+	}
+	_ = rows.Close()
+
+	for _, deployment := range deployments {
+		comparison, err := cp.compareCanary(ctx, deployment)
+		if err != nil {
+			log.Printf("Failed to compare canary for workflow %s: %v", deployment.WorkflowName, err)
+			continue
+		}
+		if comparison.Verdict != CanaryRegressed {
+			continue
+		}
+		log.Printf("Canary v%d of workflow %s regressed: %v", *deployment.CanaryVersion, deployment.WorkflowName, comparison.Reasons)
+		if _, err := cp.endCanary(ctx, deployment, "rolled back automatically"); err != nil {
+			log.Printf("Failed to roll back canary for workflow %s: %v", deployment.WorkflowName, err)
+		}
+	}
+}
+
+func (cp *ControlPlane) activeCanary(ctx context.Context, orgID uuid.UUID, workflowName string) (*WorkflowDeployment, error) {
+	deployment, err := cp.GetWorkflowDeployment(ctx, orgID, workflowName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("workflow %s has no deployment", workflowName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deployment.CanaryVersion == nil {
+		return nil, fmt.Errorf("workflow %s has no active canary", workflowName)
+	}
+	return deployment, nil
+}
+
+func (cp *ControlPlane) endCanary(ctx context.Context, deployment *WorkflowDeployment, outcome string) (*WorkflowDeployment, error) {
+	canary := *deployment.CanaryVersion
+	deployment.CanaryVersion = nil
+	deployment.CanaryRatio = 0
+	deployment.CanaryStartedAt = nil
+	deployment.UpdatedAt = time.Now()
+	if err := cp.saveWorkflowDeployment(ctx, deployment); err != nil {
+		return nil, err
+	}
+	log.Printf("Canary v%d of workflow %s %s; stable is v%d", canary, deployment.WorkflowName, outcome, deployment.StableVersion)
+	if outcome == "promoted" {
+		subject := fmt.Sprintf("Canary v%d of %s promoted", canary, deployment.WorkflowName)
+		message := fmt.Sprintf("Canary v%d of workflow %s was promoted and now serves all runs as stable", canary, deployment.WorkflowName)
+		go cp.notify(context.WithoutCancel(ctx), deployment.OrgID, NotifyCanaryPromotion, subject, message)
+	}
+	return deployment, nil
+}
+
+// versionRunStats summarizes finished runs of two spec versions started since a time
+func (cp *ControlPlane) versionRunStats(ctx context.Context, orgID uuid.UUID, workflowName string, since time.Time, stable, canary int) (map[int]VersionRunStats, error) {
+	query := `SELECT s.version, COUNT(*),
+			  COUNT(*) FILTER (WHERE r.status = 'succeeded'),
+			  COALESCE(AVG(r.cost_cents), 0),
+			  COALESCE(AVG(EXTRACT(EPOCH FROM r.ended_at - r.started_at)), 0),
+			  COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM r.ended_at - r.started_at)), 0)
+			  FROM workflow_run r
+			  JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE s.org_id = $1 AND s.name = $2 AND s.version IN ($3, $4) AND r.created_at >= $5
+			  AND r.status IN ('succeeded', 'failed', 'partial-success') AND r.started_at IS NOT NULL
+			  GROUP BY s.version`
+
+	rows, err := cp.db.QueryContext(ctx, query, orgID, workflowName, stable, canary, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query version run stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make(map[int]VersionRunStats, 2)
+	for rows.Next() {
+		var s VersionRunStats
+		var avgSeconds, p90Seconds float64
+		if err := rows.Scan(&s.Version, &s.Runs, &s.Succeeded, &s.AvgCostCents, &avgSeconds, &p90Seconds); err != nil {
+			return nil, fmt.Errorf("failed to scan version run stats: %w", err)
+		}
+		if s.Runs > 0 {
+			s.SuccessRate = float64(s.Succeeded) / float64(s.Runs)
+		}
+		s.AvgLatency = time.Duration(avgSeconds * float64(time.Second))
+		s.P90Latency = time.Duration(p90Seconds * float64(time.Second))
+		stats[s.Version] = s
+	}
+	return stats, rows.Err()
+}
+
+func (cp *ControlPlane) saveWorkflowDeployment(ctx context.Context, deployment *WorkflowDeployment) error {
+	policyJSON, err := json.Marshal(deployment.Policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal canary policy: %w", err)
+	}
+
+	query := `INSERT INTO workflow_deployment (id, org_id, workflow_name, stable_version, canary_version, canary_ratio,
+			  policy, canary_started_at, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			  ON CONFLICT (org_id, workflow_name)
+			  DO UPDATE SET stable_version = $4, canary_version = $5, canary_ratio = $6, policy = $7,
+			  canary_started_at = $8, updated_at = $10`
+
+	_, err = cp.db.ExecContext(ctx, query,
+		deployment.ID, deployment.OrgID, deployment.WorkflowName, deployment.StableVersion,
+		deployment.CanaryVersion, deployment.CanaryRatio, policyJSON, deployment.CanaryStartedAt,
+		deployment.CreatedAt, deployment.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save workflow deployment: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (cp *ControlPlane) scanWorkflowDeployment(row rowScanner) (*WorkflowDeployment, error) {
+	var d WorkflowDeployment
+	var policyJSON []byte
+	if err := row.Scan(&d.ID, &d.OrgID, &d.WorkflowName, &d.StableVersion, &d.CanaryVersion, &d.CanaryRatio,
+		&policyJSON, &d.CanaryStartedAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(policyJSON, &d.Policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal canary policy: %w", err)
+	}
+	return &d, nil
+}
+
+// secureRandFloat64 returns a uniform random number in [0, 1)
+func secureRandFloat64() float64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return float64(time.Now().UnixNano()%1000) / 1000
+	}
+	return float64(binary.BigEndian.Uint64(b[:])>>11) / (1 << 53)
+}
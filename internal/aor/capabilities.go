@@ -0,0 +1,267 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// CapabilitySubject is where agents and tools announce their capabilities
+const CapabilitySubject = "agentflow.capabilities"
+
+// Capability kinds
+const (
+	CapabilityAgent = "agent"
+	CapabilityTool  = "tool"
+)
+
+// AgentCapability describes what a registered agent or tool accepts and
+// produces. Steps reference agents with config.agent and tools with
+// config.tool_name; schemas are JSON Schema objects.
+type AgentCapability struct {
+	OrgID        uuid.UUID              `json:"org_id"`
+	Kind         string                 `json:"kind"`
+	Name         string                 `json:"name"`
+	Version      string                 `json:"version,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	TaskTypes    []string               `json:"task_types,omitempty"` // Step types that may use it; any when empty
+	InputSchema  map[string]interface{} `json:"input_schema,omitempty"`
+	OutputSchema map[string]interface{} `json:"output_schema,omitempty"`
+	RegisteredAt time.Time              `json:"registered_at"`
+	LastSeenAt   time.Time              `json:"last_seen_at"`
+}
+
+// CapabilityError lists every way a spec's steps disagree with the capability registry
+type CapabilityError struct {
+	Workflow string   `json:"workflow"`
+	Problems []string `json:"problems"`
+}
+
+func (e *CapabilityError) Error() string {
+	return fmt.Sprintf("workflow %s references unavailable or incompatible capabilities:\n  %s", e.Workflow, strings.Join(e.Problems, "\n  "))
+}
+
+// Validate checks a capability before it is registered
+func (c *AgentCapability) Validate() error {
+	if c.Kind != CapabilityAgent && c.Kind != CapabilityTool {
+		return fmt.Errorf("invalid capability kind %q: use agent or tool", c.Kind)
+	}
+	if c.Name == "" {
+		return fmt.Errorf("capability name is required")
+	}
+	for label, schema := range map[string]map[string]interface{}{"input_schema": c.InputSchema, "output_schema": c.OutputSchema} {
+		if schema == nil {
+			continue
+		}
+		if t, ok := schema["type"].(string); ok && t != "object" {
+			return fmt.Errorf("%s must describe an object, not %s", label, t)
+		}
+		if _, ok := schema["properties"]; ok {
+			if _, ok := schema["properties"].(map[string]interface{}); !ok {
+				return fmt.Errorf("%s properties must be an object", label)
+			}
+		}
+	}
+	return nil
+}
+
+// RegisterCapability adds or refreshes a capability in its org's registry
+func (cp *ControlPlane) RegisterCapability(ctx context.Context, capability *AgentCapability) error {
+	if err := capability.Validate(); err != nil {
+		return err
+	}
+	inputJSON, err := json.Marshal(capability.InputSchema)
+	if err != nil {
+		return fmt.Errorf("failed to marshal input schema: %w", err)
+	}
+	outputJSON, err := json.Marshal(capability.OutputSchema)
+	if err != nil {
+		return fmt.Errorf("failed to marshal output schema: %w", err)
+	}
+
+	query := `INSERT INTO agent_capability (org_id, kind, name, version, description, task_types, input_schema, output_schema)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			  ON CONFLICT (org_id, kind, name) DO UPDATE
+			  SET version = EXCLUDED.version, description = EXCLUDED.description, task_types = EXCLUDED.task_types,
+			      input_schema = EXCLUDED.input_schema, output_schema = EXCLUDED.output_schema, last_seen_at = NOW()
+			  RETURNING registered_at, last_seen_at`
+	err = cp.db.QueryRowContext(ctx, query, capability.OrgID, capability.Kind, capability.Name, capability.Version,
+		capability.Description, pq.Array(capability.TaskTypes), inputJSON, outputJSON).
+		Scan(&capability.RegisteredAt, &capability.LastSeenAt)
+	if err != nil {
+		return fmt.Errorf("failed to register capability: %w", err)
+	}
+	capabilityRegistrations.Inc(capability.Kind)
+	return nil
+}
+
+// ListCapabilities returns an org's registered agents and tools, optionally of one kind
+func (cp *ControlPlane) ListCapabilities(ctx context.Context, orgID uuid.UUID, kind string) ([]AgentCapability, error) {
+	query := `SELECT kind, name, version, description, task_types, input_schema, output_schema, registered_at, last_seen_at
+			  FROM agent_capability WHERE org_id = $1 AND ($2 = '' OR kind = $2) ORDER BY kind, name`
+	rows, err := cp.db.QueryContext(ctx, query, orgID, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query capabilities: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	capabilities := make([]AgentCapability, 0)
+	for rows.Next() {
+		capability := AgentCapability{OrgID: orgID}
+		var inputJSON, outputJSON []byte
+		if err := rows.Scan(&capability.Kind, &capability.Name, &capability.Version, &capability.Description,
+			pq.Array(&capability.TaskTypes), &inputJSON, &outputJSON, &capability.RegisteredAt, &capability.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan capability: %w", err)
+		}
+		_ = json.Unmarshal(inputJSON, &capability.InputSchema)
+		_ = json.Unmarshal(outputJSON, &capability.OutputSchema)
+		capabilities = append(capabilities, capability)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read capabilities: %w", err)
+	}
+	return capabilities, nil
+}
+
+// checkCapabilities rejects specs whose steps reference unregistered agents
+// or tools, or whose typed ports don't fit the capability's schemas
+func (cp *ControlPlane) checkCapabilities(ctx context.Context, spec *WorkflowSpec) error {
+	if len(referencedCapabilities(spec.DAG)) == 0 {
+		return nil
+	}
+	capabilities, err := cp.ListCapabilities(ctx, spec.OrgID, "")
+	if err != nil {
+		return err
+	}
+	if problems := ValidateCapabilities(spec.DAG, capabilities); len(problems) > 0 {
+		return &CapabilityError{Workflow: spec.Name, Problems: problems}
+	}
+	return nil
+}
+
+// capabilityRef is an agent or tool a step uses
+type capabilityRef struct {
+	Kind string
+	Name string
+}
+
+// referencedCapabilities returns the agent or tool each step uses, by step ID
+func referencedCapabilities(dag DAG) map[string]capabilityRef {
+	refs := make(map[string]capabilityRef)
+	for _, step := range dag.Steps {
+		if name, ok := step.Config["agent"].(string); ok && name != "" {
+			refs[step.ID] = capabilityRef{Kind: CapabilityAgent, Name: name}
+		} else if name, ok := step.Config["tool_name"].(string); ok && name != "" {
+			refs[step.ID] = capabilityRef{Kind: CapabilityTool, Name: name}
+		}
+	}
+	return refs
+}
+
+// ValidateCapabilities checks a DAG against registered capabilities, returning
+// one actionable problem per mismatch
+func ValidateCapabilities(dag DAG, capabilities []AgentCapability) []string {
+	registry := make(map[capabilityRef]*AgentCapability, len(capabilities))
+	for i := range capabilities {
+		registry[capabilityRef{Kind: capabilities[i].Kind, Name: capabilities[i].Name}] = &capabilities[i]
+	}
+	refs := referencedCapabilities(dag)
+
+	var problems []string
+	for _, step := range dag.Steps {
+		ref, ok := refs[step.ID]
+		if !ok {
+			continue
+		}
+		capability, ok := registry[ref]
+		if !ok {
+			problem := fmt.Sprintf("step %s uses %s %s, which is not registered", step.ID, ref.Kind, ref.Name)
+			if known := registeredNames(capabilities, ref.Kind); len(known) > 0 {
+				problem += fmt.Sprintf(" (registered %ss: %s)", ref.Kind, strings.Join(known, ", "))
+			}
+			problems = append(problems, problem)
+			continue
+		}
+		if len(capability.TaskTypes) > 0 && !containsString(capability.TaskTypes, step.Type) {
+			problems = append(problems, fmt.Sprintf("step %s is a %s step but %s %s only supports %s",
+				step.ID, step.Type, ref.Kind, ref.Name, strings.Join(capability.TaskTypes, ", ")))
+		}
+
+		ports, err := parseStepPorts(step.Config)
+		if err != nil || ports == nil {
+			continue // Port errors are reported by ValidatePorts
+		}
+		problems = append(problems, schemaProblems(step.ID, ref, PortInput, ports.Inputs, capability.InputSchema)...)
+		problems = append(problems, schemaProblems(step.ID, ref, PortOutput, ports.Outputs, capability.OutputSchema)...)
+	}
+	return problems
+}
+
+// schemaProblems compares a step's declared ports with a capability schema.
+// Every required input must be declared, and every declared port must exist
+// in the schema with a compatible type.
+func schemaProblems(stepID string, ref capabilityRef, direction string, ports map[string]PortType, schema map[string]interface{}) []string {
+	if schema == nil {
+		return nil
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	var problems []string
+	if direction == PortInput {
+		required, _ := schema["required"].([]interface{})
+		for _, field := range required {
+			name, _ := field.(string)
+			if _, ok := ports[name]; name != "" && !ok {
+				problems = append(problems, fmt.Sprintf("step %s does not declare input port %s required by %s %s",
+					stepID, name, ref.Kind, ref.Name))
+			}
+		}
+	}
+
+	for _, name := range sortedPortNames(ports) {
+		property, ok := properties[name].(map[string]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("step %s %s port %s is not in the %s schema of %s %s",
+				stepID, direction, name, direction, ref.Kind, ref.Name))
+			continue
+		}
+		schemaType, _ := property["type"].(string)
+		if !portFitsSchemaType(ports[name], schemaType) {
+			problems = append(problems, fmt.Sprintf("step %s %s port %s is %s but %s %s declares %s",
+				stepID, direction, name, ports[name], ref.Kind, ref.Name, schemaType))
+		}
+	}
+	return problems
+}
+
+// portFitsSchemaType reports whether a port type matches a JSON Schema type;
+// json ports and untyped properties accept anything
+func portFitsSchemaType(portType PortType, schemaType string) bool {
+	if schemaType == "" || portType == PortTypeJSON {
+		return true
+	}
+	switch portType {
+	case PortTypeString, PortTypeArtifactRef:
+		return schemaType == "string"
+	case PortTypeEmbedding:
+		return schemaType == "array"
+	}
+	return false
+}
+
+func registeredNames(capabilities []AgentCapability, kind string) []string {
+	var names []string
+	for _, capability := range capabilities {
+		if capability.Kind == kind {
+			names = append(names, capability.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,170 @@
+package aor
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// ErrCassetteMiss is returned when a replayed step has no recorded response
+var ErrCassetteMiss = errors.New("no recorded response for step")
+
+// CassetteEntry is a recorded provider response for one step request
+type CassetteEntry struct {
+	RunID            uuid.UUID              `json:"run_id"`
+	StepID           string                 `json:"step_id"`
+	RequestHash      string                 `json:"request_hash"`
+	Provider         string                 `json:"provider"`
+	Model            string                 `json:"model"`
+	Output           map[string]interface{} `json:"output"`
+	TokensPrompt     int                    `json:"tokens_prompt"`
+	TokensCompletion int                    `json:"tokens_completion"`
+	CostCents        int64                  `json:"cost_cents"`
+	RecordedAt       time.Time              `json:"recorded_at"`
+}
+
+// CassetteStore records raw provider responses encrypted at rest and serves
+// them back during deterministic replay
+type CassetteStore struct {
+	db   *db.PostgresDB
+	aead cipher.AEAD
+}
+
+// NewCassetteStore creates a store; an empty key disables recording and replay
+func NewCassetteStore(pgDB *db.PostgresDB, key string) (*CassetteStore, error) {
+	store := &CassetteStore{db: pgDB}
+	if key == "" {
+		return store, nil
+	}
+
+	derived := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cassette cipher: %w", err)
+	}
+	if store.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, fmt.Errorf("failed to create cassette cipher: %w", err)
+	}
+
+	return store, nil
+}
+
+// Enabled reports whether an encryption key is configured
+func (cs *CassetteStore) Enabled() bool {
+	return cs != nil && cs.aead != nil
+}
+
+// Record stores the response for a step request, replacing earlier attempts
+func (cs *CassetteStore) Record(ctx context.Context, orgID uuid.UUID, entry *CassetteEntry) error {
+	if !cs.Enabled() {
+		return nil
+	}
+
+	nonce, ciphertext, err := cs.seal(entry)
+	if err != nil {
+		return err
+	}
+
+	var org interface{}
+	if orgID != uuid.Nil {
+		org = orgID
+	}
+
+	query := `INSERT INTO llm_cassette (id, org_id, run_id, step_id, request_hash, provider, model, nonce, ciphertext, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			  ON CONFLICT (run_id, step_id, request_hash)
+			  DO UPDATE SET nonce = EXCLUDED.nonce, ciphertext = EXCLUDED.ciphertext, created_at = EXCLUDED.created_at`
+
+	_, err = cs.db.ExecContext(ctx, query,
+		uuid.New(), org, entry.RunID, entry.StepID, entry.RequestHash,
+		entry.Provider, entry.Model, nonce, ciphertext, entry.RecordedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record cassette: %w", err)
+	}
+
+	return nil
+}
+
+// Lookup returns the recorded response for a step request of a past run
+func (cs *CassetteStore) Lookup(ctx context.Context, runID uuid.UUID, stepID, requestHash string) (*CassetteEntry, error) {
+	if !cs.Enabled() {
+		return nil, fmt.Errorf("cassette encryption key not configured")
+	}
+
+	query := `SELECT nonce, ciphertext FROM llm_cassette
+			  WHERE run_id = $1 AND step_id = $2 AND request_hash = $3`
+
+	var nonce, ciphertext []byte
+	err := cs.db.QueryRowContext(ctx, query, runID, stepID, requestHash).Scan(&nonce, &ciphertext)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w %s of run %s", ErrCassetteMiss, stepID, runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cassette: %w", err)
+	}
+
+	return cs.open(nonce, ciphertext)
+}
+
+func (cs *CassetteStore) seal(entry *CassetteEntry) ([]byte, []byte, error) {
+	plaintext, err := json.Marshal(entry)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+
+	nonce := make([]byte, cs.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return nonce, cs.aead.Seal(nil, nonce, plaintext, nil), nil
+}
+
+func (cs *CassetteStore) open(nonce, ciphertext []byte) (*CassetteEntry, error) {
+	plaintext, err := cs.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt cassette: %w", err)
+	}
+
+	var entry CassetteEntry
+	if err := json.Unmarshal(plaintext, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cassette: %w", err)
+	}
+	return &entry, nil
+}
+
+// cassetteFingerprint identifies a provider request so replay only reuses a
+// response when the step would have sent the same request
+func cassetteFingerprint(provider, model, promptRef string, inputs map[string]interface{}) string {
+	data, _ := json.Marshal(struct {
+		Provider  string                 `json:"provider"`
+		Model     string                 `json:"model"`
+		PromptRef string                 `json:"prompt_ref"`
+		Inputs    map[string]interface{} `json:"inputs"`
+	}{provider, model, promptRef, inputs})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// replaySource returns the run a replay run reuses responses from, if any
+func replaySource(metadata map[string]interface{}) uuid.UUID {
+	value, _ := metadata["replay_of"].(string)
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
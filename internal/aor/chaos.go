@@ -0,0 +1,200 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+)
+
+// FaultType is a failure chaos mode can inject into a step
+type FaultType string
+
+const (
+	FaultProviderError   FaultType = "provider_error"
+	FaultTimeout         FaultType = "timeout"
+	FaultMalformedOutput FaultType = "malformed_output"
+)
+
+// malformedContent is returned by injected malformed outputs: JSON cut off
+// mid-value, as a provider returns when it drops a connection
+const malformedContent = `{"result": "incompl`
+
+// FaultRule injects one kind of failure into a share of step attempts.
+// Each attempt is drawn separately, so retries can recover from a fault.
+type FaultRule struct {
+	StepType string        `json:"step_type,omitempty"` // Empty matches every step type
+	Provider string        `json:"provider,omitempty"`  // LLM steps only; empty matches every provider
+	Fault    FaultType     `json:"fault"`
+	Rate     float64       `json:"rate"`
+	Delay    time.Duration `json:"delay,omitempty"` // How long an injected timeout hangs before failing
+}
+
+// ChaosPolicy lists the faults injected into a run's steps. Runs only accept
+// a policy when chaos mode is enabled on the control plane.
+type ChaosPolicy struct {
+	Faults []FaultRule `json:"faults"`
+}
+
+// InjectedFault is the error returned for an injected provider error or
+// timeout. It carries the class a real failure of that kind would have.
+type InjectedFault struct {
+	Fault FaultType
+}
+
+func (e *InjectedFault) Error() string {
+	switch e.Fault {
+	case FaultTimeout:
+		return "chaos: injected timeout: deadline exceeded"
+	default:
+		return "chaos: injected provider error: 503 service unavailable"
+	}
+}
+
+// ErrorClass implements cas.ClassifiedError
+func (e *InjectedFault) ErrorClass() cas.ErrorClass {
+	if e.Fault == FaultTimeout {
+		return cas.ErrorClassTimeout
+	}
+	return cas.ErrorClassServer
+}
+
+// Validate checks fault types and rates
+func (p *ChaosPolicy) Validate() error {
+	if len(p.Faults) == 0 {
+		return fmt.Errorf("chaos policy has no faults")
+	}
+	for _, rule := range p.Faults {
+		switch rule.Fault {
+		case FaultProviderError, FaultTimeout, FaultMalformedOutput:
+		default:
+			return fmt.Errorf("invalid fault %q: use provider_error, timeout or malformed_output", rule.Fault)
+		}
+		if rule.Rate <= 0 || rule.Rate > 1 {
+			return fmt.Errorf("fault %s rate must be above 0 and at most 1", rule.Fault)
+		}
+		if rule.Delay < 0 {
+			return fmt.Errorf("fault %s delay must not be negative", rule.Fault)
+		}
+	}
+	return nil
+}
+
+// ForStep returns the rules that apply to a step type, or nil
+func (p *ChaosPolicy) ForStep(stepType string) *ChaosPolicy {
+	if p == nil {
+		return nil
+	}
+	var faults []FaultRule
+	for _, rule := range p.Faults {
+		if rule.StepType == "" || rule.StepType == stepType {
+			faults = append(faults, rule)
+		}
+	}
+	if len(faults) == 0 {
+		return nil
+	}
+	return &ChaosPolicy{Faults: faults}
+}
+
+// pick draws the fault, if any, for one attempt. Rules are drawn in order and
+// the first that fires wins.
+func (p *ChaosPolicy) pick(provider string, draw func() float64) *FaultRule {
+	if p == nil {
+		return nil
+	}
+	for i := range p.Faults {
+		rule := &p.Faults[i]
+		if rule.Provider != "" && provider != "" && rule.Provider != provider {
+			continue
+		}
+		if draw() < rule.Rate {
+			return rule
+		}
+	}
+	return nil
+}
+
+// inject records a fault and returns the error the attempt fails with. It
+// returns nil for malformed output, which the caller applies to the result.
+func (r *FaultRule) inject(ctx context.Context, stepType string) error {
+	chaosFaults.Inc(stepType, string(r.Fault))
+	if r.Fault == FaultMalformedOutput {
+		return nil
+	}
+	if r.Fault == FaultTimeout && r.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.Delay):
+		}
+	}
+	return &InjectedFault{Fault: r.Fault}
+}
+
+// malformedOutput replaces a non-LLM step's output with a truncated payload
+func malformedOutput() map[string]interface{} {
+	return map[string]interface{}{"body": malformedContent, "chaos": string(FaultMalformedOutput)}
+}
+
+// runChaos returns the chaos policy recorded on a run, or nil
+func runChaos(metadata map[string]interface{}) *ChaosPolicy {
+	raw, ok := metadata["chaos"]
+	if !ok || raw == nil {
+		return nil
+	}
+	if policy, ok := raw.(*ChaosPolicy); ok {
+		return policy
+	}
+	// Runs loaded from the database hold the policy as decoded JSON
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var policy ChaosPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil
+	}
+	return &policy
+}
+
+type chaosContextKey struct{}
+
+// withChaos makes a task's faults visible to provider calls made on its behalf
+func withChaos(ctx context.Context, policy *ChaosPolicy) context.Context {
+	if policy == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, chaosContextKey{}, policy)
+}
+
+func chaosFrom(ctx context.Context) *ChaosPolicy {
+	policy, _ := ctx.Value(chaosContextKey{}).(*ChaosPolicy)
+	return policy
+}
+
+// ParseFaultRules parses CLI fault specs of the form [step_type:]fault=rate,
+// e.g. llm:provider_error=0.2 or timeout=0.05
+func ParseFaultRules(specs []string) ([]FaultRule, error) {
+	rules := make([]FaultRule, 0, len(specs))
+	for _, spec := range specs {
+		target, rateStr, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid fault %q: expected [step_type:]fault=rate", spec)
+		}
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fault rate in %q: %w", spec, err)
+		}
+		rule := FaultRule{Fault: FaultType(target), Rate: rate}
+		if stepType, fault, ok := strings.Cut(target, ":"); ok {
+			rule.StepType, rule.Fault = stepType, FaultType(fault)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
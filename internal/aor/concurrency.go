@@ -0,0 +1,263 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// labelMaxConcurrentRuns lets a workflow spec override the per-workflow cap
+const labelMaxConcurrentRuns = "max_concurrent_runs"
+
+// RunLimits caps concurrently active runs; zero disables a limit
+type RunLimits struct {
+	MaxPerOrg      int `json:"max_per_org"`
+	MaxPerWorkflow int `json:"max_per_workflow"`
+}
+
+// ConcurrencyLimiter tracks active runs per org and workflow in Redis and
+// holds runs over the cap in a per-org pending queue
+type ConcurrencyLimiter struct {
+	redis *redis.Client
+}
+
+func NewConcurrencyLimiter(redisClient *redis.Client) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{redis: redisClient}
+}
+
+// runSlots tracks active run slots and the pending queue
+type runSlots interface {
+	Acquire(ctx context.Context, run *WorkflowRun, limits RunLimits) (bool, error)
+	Release(ctx context.Context, run *WorkflowRun) error
+	Enqueue(ctx context.Context, run *WorkflowRun) error
+	Dequeue(ctx context.Context, orgID, runID uuid.UUID) error
+	Position(ctx context.Context, orgID, runID uuid.UUID) (int, error)
+	Pending(ctx context.Context, orgID uuid.UUID) ([]uuid.UUID, error)
+}
+
+// acquireScript atomically checks both caps and claims a slot
+var acquireScript = redis.NewScript(`
+	if redis.call('SISMEMBER', KEYS[1], ARGV[1]) == 1 then
+		return 1
+	end
+	local orgLimit = tonumber(ARGV[2])
+	local workflowLimit = tonumber(ARGV[3])
+	if orgLimit > 0 and redis.call('SCARD', KEYS[1]) >= orgLimit then
+		return 0
+	end
+	if workflowLimit > 0 and redis.call('SCARD', KEYS[2]) >= workflowLimit then
+		return 0
+	end
+	redis.call('SADD', KEYS[1], ARGV[1])
+	redis.call('SADD', KEYS[2], ARGV[1])
+	return 1
+`)
+
+// Acquire claims an active slot for a run, returning false when a cap is reached
+func (cl *ConcurrencyLimiter) Acquire(ctx context.Context, run *WorkflowRun, limits RunLimits) (bool, error) {
+	keys := []string{cl.orgKey(run.OrgID), cl.workflowKey(run.OrgID, run.WorkflowName)}
+
+	acquired, err := acquireScript.Run(ctx, cl.redis, keys, run.ID.String(), limits.MaxPerOrg, limits.MaxPerWorkflow).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire run slot: %w", err)
+	}
+
+	return acquired == 1, nil
+}
+
+// Release frees a run's active slot and removes it from the pending queue
+func (cl *ConcurrencyLimiter) Release(ctx context.Context, run *WorkflowRun) error {
+	pipe := cl.redis.TxPipeline()
+	pipe.SRem(ctx, cl.orgKey(run.OrgID), run.ID.String())
+	pipe.SRem(ctx, cl.workflowKey(run.OrgID, run.WorkflowName), run.ID.String())
+	pipe.ZRem(ctx, cl.pendingKey(run.OrgID), run.ID.String())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to release run slot: %w", err)
+	}
+	return nil
+}
+
+// Enqueue adds a run to the org's pending queue in submission order
+func (cl *ConcurrencyLimiter) Enqueue(ctx context.Context, run *WorkflowRun) error {
+	err := cl.redis.ZAddNX(ctx, cl.pendingKey(run.OrgID), redis.Z{
+		Score:  float64(time.Now().UnixNano()),
+		Member: run.ID.String(),
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to enqueue pending run: %w", err)
+	}
+	return nil
+}
+
+// Dequeue removes a run from the pending queue
+func (cl *ConcurrencyLimiter) Dequeue(ctx context.Context, orgID, runID uuid.UUID) error {
+	if err := cl.redis.ZRem(ctx, cl.pendingKey(orgID), runID.String()).Err(); err != nil {
+		return fmt.Errorf("failed to dequeue pending run: %w", err)
+	}
+	return nil
+}
+
+// Position returns the 1-based position of a pending run, or 0 if not queued
+func (cl *ConcurrencyLimiter) Position(ctx context.Context, orgID, runID uuid.UUID) (int, error) {
+	rank, err := cl.redis.ZRank(ctx, cl.pendingKey(orgID), runID.String()).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get queue position: %w", err)
+	}
+	return int(rank) + 1, nil
+}
+
+// Pending lists pending run IDs for an org in queue order
+func (cl *ConcurrencyLimiter) Pending(ctx context.Context, orgID uuid.UUID) ([]uuid.UUID, error) {
+	members, err := cl.redis.ZRange(ctx, cl.pendingKey(orgID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending runs: %w", err)
+	}
+
+	runIDs := make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		if id, err := uuid.Parse(member); err == nil {
+			runIDs = append(runIDs, id)
+		}
+	}
+	return runIDs, nil
+}
+
+// runAdmission schedules runs within their concurrency caps, holding the
+// rest pending until a finished run frees a slot
+type runAdmission struct {
+	slots    runSlots
+	getRun   func(ctx context.Context, runID uuid.UUID) (*WorkflowRun, error)
+	schedule func(ctx context.Context, run *WorkflowRun) error
+}
+
+// admit schedules a saved run, holding it in the pending queue if org or
+// workflow caps are reached
+func (a *runAdmission) admit(ctx context.Context, run *WorkflowRun, limits RunLimits) error {
+	acquired, err := a.slots.Acquire(ctx, run, limits)
+	if err != nil {
+		return err
+	}
+	if !acquired {
+		if err := a.slots.Enqueue(ctx, run); err != nil {
+			return err
+		}
+		if run.QueuePosition, err = a.slots.Position(ctx, run.OrgID, run.ID); err != nil {
+			return err
+		}
+		log.Printf("Run %s pending at queue position %d", run.ID, run.QueuePosition)
+		return nil
+	}
+
+	// Submit to scheduler
+	if err := a.schedule(ctx, run); err != nil {
+		_ = a.slots.Release(ctx, run) // Free the slot claimed above
+		return fmt.Errorf("failed to schedule workflow: %w", err)
+	}
+
+	return nil
+}
+
+// release frees a finished run's slot and promotes pending runs
+func (a *runAdmission) release(ctx context.Context, runID uuid.UUID) error {
+	run, err := a.getRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+
+	if err := a.slots.Release(ctx, run); err != nil {
+		return err
+	}
+
+	return a.promote(ctx, run.OrgID)
+}
+
+// promote schedules pending runs, in queue order, that now fit within their caps
+func (a *runAdmission) promote(ctx context.Context, orgID uuid.UUID) error {
+	pending, err := a.slots.Pending(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	for _, runID := range pending {
+		run, err := a.getRun(ctx, runID)
+		if err != nil {
+			log.Printf("Dropping unknown pending run %s: %v", runID, err)
+			_ = a.slots.Dequeue(ctx, orgID, runID)
+			continue
+		}
+
+		acquired, err := a.slots.Acquire(ctx, run, runLimitsFromMetadata(run.Metadata))
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			continue
+		}
+
+		if err := a.slots.Dequeue(ctx, orgID, runID); err != nil {
+			return err
+		}
+		if err := a.schedule(ctx, run); err != nil {
+			_ = a.slots.Release(ctx, run)
+			return fmt.Errorf("failed to schedule pending run %s: %w", runID, err)
+		}
+		log.Printf("Promoted pending run %s", runID)
+	}
+
+	return nil
+}
+
+func (cl *ConcurrencyLimiter) orgKey(orgID uuid.UUID) string {
+	return fmt.Sprintf("runs:active:%s", orgID)
+}
+
+func (cl *ConcurrencyLimiter) workflowKey(orgID uuid.UUID, workflowName string) string {
+	return fmt.Sprintf("runs:active:%s:%s", orgID, workflowName)
+}
+
+func (cl *ConcurrencyLimiter) pendingKey(orgID uuid.UUID) string {
+	return fmt.Sprintf("runs:pending:%s", orgID)
+}
+
+// resolveRunLimits combines configured caps with a per-workflow override label
+func resolveRunLimits(cfg config.SchedulerConfig, spec *WorkflowSpec) RunLimits {
+	limits := RunLimits{
+		MaxPerOrg:      cfg.MaxConcurrentRunsPerOrg,
+		MaxPerWorkflow: cfg.MaxConcurrentRunsPerWorkflow,
+	}
+
+	if spec != nil {
+		if value, ok := spec.Metadata.Labels[labelMaxConcurrentRuns]; ok {
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				limits.MaxPerWorkflow = n
+			}
+		}
+	}
+
+	return limits
+}
+
+// runLimitsFromMetadata restores limits recorded on a run at submission
+func runLimitsFromMetadata(metadata map[string]interface{}) RunLimits {
+	var limits RunLimits
+	raw, ok := metadata["concurrency_limits"].(map[string]interface{})
+	if !ok {
+		return limits
+	}
+	if v, ok := raw["max_per_org"].(float64); ok {
+		limits.MaxPerOrg = int(v)
+	}
+	if v, ok := raw["max_per_workflow"].(float64); ok {
+		limits.MaxPerWorkflow = int(v)
+	}
+	return limits
+}
@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"log"
+	"math"
 	"sync"
 	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/pop"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
 	nats "github.com/nats-io/nats.go"
 	redis "github.com/redis/go-redis/v9"
 )
@@ -23,7 +27,26 @@ type ControlPlane struct {
 	js    nats.JetStreamContext
 
 	scheduler *Scheduler
+	queue     *TenantQueue
 	monitor   *Monitor
+	budgets   *cas.BudgetManager
+	guard     *cas.BudgetGuard
+	limiter   *ConcurrencyLimiter
+	admission *runAdmission
+	blobs     *db.BlobStore
+	policies  *cas.ModelPolicyStore
+	prompts   *pop.Service
+	gitSync   *GitSync
+	captures  *DebugCaptureStore
+	redaction *scl.PatternStore
+	notifiers []AlertNotifier
+	traces    TracePurger
+
+	userNotifiers []UserNotifier
+
+	budgetAlertPct     int // Extra budget.threshold percentage from config
+	retentionCheckedAt time.Time
+	artifactGCAt       time.Time
 
 	mu       sync.RWMutex
 	running  bool
@@ -43,6 +66,9 @@ func NewControlPlane(cfg *config.Config) (*ControlPlane, error) {
 		Password: cfg.Redis.Password,
 		DB:       cfg.Redis.DB,
 	})
+	if cfg.Metrics.Shared {
+		cas.EnableClusterMetrics(redisClient)
+	}
 
 	// Initialize NATS
 	nc, err := nats.Connect(cfg.NATS.URL)
@@ -65,8 +91,38 @@ func NewControlPlane(cfg *config.Config) (*ControlPlane, error) {
 	}
 
 	// Initialize scheduler and monitor
-	cp.scheduler = NewScheduler(pgDB, redisClient, cp.nats, js)
+	lanes, err := BuildLanes(cfg.Scheduler.Lanes, cfg.Scheduler.MaxInFlightTasks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scheduler lanes: %w", err)
+	}
+	cp.queue = NewTenantQueue(redisClient, js, cfg.Scheduler.MaxInFlightTasks, lanes)
+	cp.scheduler = NewScheduler(pgDB, redisClient, cp.nats, js, cp.queue)
+	cp.scheduler.releaseRun = cp.ReleaseRun
+	cp.scheduler.onRunFailure = cp.notifyRunFailure
+	cp.queue.onUnschedulable = cp.failStepRun
+	if cfg.Scheduler.AutoTuneTimeouts {
+		cp.scheduler.timeouts = NewTimeoutTuner(pgDB)
+	}
 	cp.monitor = NewMonitor(cp)
+	cp.budgets = cas.NewBudgetManager(pgDB)
+	cp.guard = cas.NewBudgetGuard(pgDB, redisClient, cfg.Budgets)
+	cp.budgetAlertPct = cas.AlertThresholdPct(cfg.Budgets.AlertThresholdRatio)
+	cp.limiter = NewConcurrencyLimiter(redisClient)
+	cp.admission = &runAdmission{slots: cp.limiter, getRun: cp.GetWorkflowRun, schedule: cp.scheduler.ScheduleWorkflow}
+	cp.blobs = db.NewBlobStore(pgDB)
+	cp.policies = cas.NewModelPolicyStore(pgDB)
+	cp.prompts = pop.NewService(cfg, pgDB)
+	cp.prompts.SetDeploymentListener(cp)
+	cp.captures = NewDebugCaptureStore(redisClient)
+	cp.redaction = scl.NewPatternStore(pgDB, cfg.Redaction)
+	cp.notifiers = newAlertNotifiers(cfg.Alerts)
+	cp.userNotifiers = newUserNotifiers(cfg.Alerts)
+
+	if cfg.GitSync.Enabled {
+		if cp.gitSync, err = NewGitSync(cp, cfg.GitSync); err != nil {
+			return nil, fmt.Errorf("failed to initialize git sync: %w", err)
+		}
+	}
 
 	return cp, nil
 }
@@ -92,6 +148,23 @@ func (cp *ControlPlane) Start(ctx context.Context) error {
 	// Scheduler doesn't need explicit start in this implementation
 	log.Printf("Scheduler initialized")
 
+	// Dispatch queued tasks to workers in weighted fair order across orgs
+	go cp.queue.Run(ctx, cp.shutdown)
+
+	// Apply workflow specs and prompts from the configured Git branch
+	if cp.gitSync != nil {
+		go cp.gitSync.Run(ctx, cp.shutdown)
+	}
+
+	// Charge and close warm-ups no scheduled run claimed
+	go cp.runPrewarmExpiry(ctx)
+
+	// Start runs of workflow cron schedules
+	go cp.runSchedules(ctx)
+
+	// Send users the events held for their hourly digests
+	go cp.runNotificationDigests(ctx)
+
 	// Start monitor
 	if err := cp.monitor.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start monitor: %w", err)
@@ -136,49 +209,335 @@ func (cp *ControlPlane) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+type submitterContextKey struct{}
+
+// WithSubmitter records the authenticated user submitting runs with ctx.
+// Callers set it after authenticating the request, never from request fields.
+func WithSubmitter(ctx context.Context, userID string) context.Context {
+	if userID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, submitterContextKey{}, userID)
+}
+
+func submitterFrom(ctx context.Context) string {
+	userID, _ := ctx.Value(submitterContextKey{}).(string)
+	return userID
+}
+
+// runSubmitter returns the authenticated user recorded on a run, if any
+func runSubmitter(metadata map[string]interface{}) string {
+	userID, _ := metadata["submitted_by"].(string)
+	return userID
+}
+
+// runResidency returns the residency constraint recorded on a run at submission
+func runResidency(metadata map[string]interface{}) *cas.ResidencyConstraint {
+	raw, ok := metadata["residency"]
+	if !ok || raw == nil {
+		return nil
+	}
+	if constraint, ok := raw.(*cas.ResidencyConstraint); ok {
+		return constraint
+	}
+	// Runs loaded from the database hold the constraint as decoded JSON
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var constraint cas.ResidencyConstraint
+	if err := json.Unmarshal(data, &constraint); err != nil {
+		return nil
+	}
+	return &constraint
+}
+
 func (cp *ControlPlane) SubmitWorkflow(ctx context.Context, req *RunRequest) (*WorkflowRun, error) {
+	// Unpinned runs follow the workflow's deployment, including canary traffic
+	canary := false
+	if req.WorkflowVersion == 0 {
+		version, isCanary, err := cp.routeWorkflowVersion(ctx, req.WorkflowName)
+		if err != nil {
+			return nil, err
+		}
+		req.WorkflowVersion, canary = version, isCanary
+	}
+
 	// Get workflow spec
 	spec, err := cp.getWorkflowSpec(ctx, req.WorkflowName, req.WorkflowVersion)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get workflow spec: %w", err)
 	}
 
+	// Reject specs that use providers or models the org does not permit
+	if err := cp.checkModelPolicy(ctx, spec); err != nil {
+		return nil, err
+	}
+	if err := cp.checkSpecSigned(ctx, spec); err != nil {
+		return nil, err
+	}
+	// Fail early on agents and tools that are missing or don't fit their steps
+	if err := cp.checkCapabilities(ctx, spec); err != nil {
+		return nil, err
+	}
+
+	if req.Callback != nil {
+		if err := validateRunCallback(req.Callback); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cas.ValidateCostLabels(req.Labels); err != nil {
+		return nil, err
+	}
+
+	if req.Chaos != nil {
+		if !cp.cfg.Chaos.Enabled {
+			return nil, fmt.Errorf("chaos mode is disabled on this control plane")
+		}
+		if err := req.Chaos.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Apply the selected environment profile's constraints and defaults
+	envName, profile, err := spec.ResolveEnvironment(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		if err := ValidateEnvironmentProviders(spec, envName, profile); err != nil {
+			return nil, err
+		}
+		if req.BudgetCents == 0 {
+			req.BudgetCents = profile.BudgetCents
+		}
+	}
+
+	conversation, err := resolveConversation(spec.DAG, spec.Metadata.Conversation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Project the run's cost, including sampling and ensemble fan-out
+	pricing, err := cp.modelPricing(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	estimate := EstimateSpecCost(spec, pricing)
+	if err := checkEstimatedCost(estimate, req.BudgetCents, req.MaxCostCents); err != nil {
+		return nil, err
+	}
+
+	// Enforce workflow, project and org budgets
+	enforcement, err := cp.checkRunBudget(ctx, spec, req, estimate.TotalCents)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pin the exact spec and prompt content this run executes with
+	specHash, promptHashes, err := cp.pinRunContent(ctx, spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin run content: %w", err)
+	}
+
+	// Large media inputs are kept in the artifact store rather than in run metadata
+	inputs, err := cp.offloadMedia(ctx, spec.OrgID, req.Inputs)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create workflow run
 	run := &WorkflowRun{
 		ID:             uuid.New(),
 		WorkflowSpecID: spec.ID,
+		WorkflowName:   spec.Name,
+		OrgID:          spec.OrgID,
 		Status:         RunStatusQueued,
+		Tags:           parseRunTags(req.Tags),
+		Labels:         req.Labels,
 		Metadata: map[string]interface{}{
-			"inputs":       req.Inputs,
-			"tags":         req.Tags,
-			"budget_cents": req.BudgetCents,
+			"inputs":           inputs,
+			"tags":             req.Tags,
+			"budget_cents":     req.BudgetCents,
+			"budget":           enforcement,
+			"estimated_cents":  estimate.TotalCents,
+			"workflow_version": spec.Version,
+			"spec_hash":        specHash,
+			"prompt_hashes":    promptHashes,
 		},
+		BatchID:   req.BatchID,
 		CreatedAt: time.Now(),
 	}
 
+	if submitter := submitterFrom(ctx); submitter != "" {
+		run.Metadata["submitted_by"] = submitter
+	}
+
+	if req.ReplayOf != nil {
+		run.Metadata["replay_of"] = req.ReplayOf.String()
+	}
+
+	if canary {
+		run.Metadata["canary"] = true
+	}
+
+	// Replays already serve recorded responses, so they never reuse cached steps
+	if req.ReuseSteps && req.ReplayOf == nil {
+		run.Metadata["reuse_steps"] = true
+	}
+
+	if req.Chaos != nil {
+		run.Metadata["chaos"] = req.Chaos
+	}
+
+	// Reproducible runs pin every LLM step so a replay samples the same way
+	if req.Reproducible || req.Seed != nil {
+		seed := int64(secureRandFloat64() * math.MaxInt32)
+		if req.Seed != nil {
+			seed = *req.Seed
+		}
+		repro, err := pinReproducibility(spec, seed)
+		if err != nil {
+			return nil, err
+		}
+		if req.ReplayOf != nil {
+			original, err := cp.GetWorkflowRun(ctx, *req.ReplayOf)
+			if err != nil {
+				return nil, err
+			}
+			repro.Warnings = append(repro.Warnings, replayWarnings(original, repro, cp.cfg.Replay.CassetteKey != "")...)
+		}
+		for _, warning := range repro.Warnings {
+			log.Printf("Run %s reproducibility: %s", run.ID, warning)
+		}
+		run.Metadata["reproducibility"] = repro
+		run.Warnings = repro.Warnings
+	}
+
+	// Conversational runs stay open for follow-up turns; the first pass is turn 1
+	if conversation != nil {
+		run.Metadata["conversation"] = true
+		run.Metadata["turn"] = 1
+	}
+
+	if profile != nil {
+		env := make(map[string]interface{}, len(profile.Env))
+		for k, v := range profile.Env {
+			env[k] = v
+		}
+		run.Metadata["environment"] = envName
+		run.Metadata["env"] = env
+		if profile.MockProvider != "" {
+			run.Metadata["mock_provider"] = profile.MockProvider
+		}
+	}
+
+	// Start the SLA clock at submission so queueing time counts against it
+	if sla := spec.Metadata.SLA; sla != nil && sla.Duration > 0 {
+		deadline := run.CreatedAt.Add(sla.Duration)
+		run.SLADeadline = &deadline
+		run.SLAStatus = SLAStatusOnTrack
+	}
+
+	limits := resolveRunLimits(cp.cfg.Scheduler, spec)
+	run.Metadata["concurrency_limits"] = limits
+
+	trigger := req.Trigger
+	if trigger == "" {
+		trigger = TriggerManual
+	}
+	run.Metadata["trigger"] = trigger
+
+	lane, err := resolveRunLane(cp.queue.lanes, spec, req)
+	if err != nil {
+		return nil, err
+	}
+	run.Metadata["lane"] = lane
+
+	residency, err := spec.Metadata.Residency.Narrow(req.Residency)
+	if err != nil {
+		return nil, err
+	}
+	if residency != nil {
+		run.Metadata["residency"] = residency
+	}
+
+	// Reject or defer runs submitted during an org freeze window
+	freeze, err := cp.matchFreezeWindow(ctx, run, trigger)
+	if err != nil {
+		return nil, err
+	}
+	if freeze != nil && freeze.Mode != FreezeModeQueue {
+		return nil, &FreezeWindowError{Window: *freeze}
+	}
+	if freeze != nil {
+		run.Metadata["frozen_until"] = freeze.EndsAt
+		run.Metadata["freeze_window"] = freeze.Name
+	}
+
 	// Save to database
 	if err := cp.saveWorkflowRun(ctx, run); err != nil {
 		return nil, fmt.Errorf("failed to save workflow run: %w", err)
 	}
 
-	// Submit to scheduler
-	if err := cp.scheduler.ScheduleWorkflow(ctx, run); err != nil {
-		return nil, fmt.Errorf("failed to schedule workflow: %w", err)
+	if conversation != nil {
+		if err := cp.openConversation(ctx, run, conversation, inputs); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Callback != nil {
+		if err := cp.saveRunCallback(ctx, run.ID, req.Callback); err != nil {
+			return nil, err
+		}
+	}
+
+	// A warm-up ahead of this run is charged to it or to its maintenance budget
+	if err := cp.claimPrewarm(ctx, run); err != nil {
+		log.Printf("Run %s could not claim a pre-warm: %v", run.ID, err)
+	}
+
+	cp.publishRunCreated(run, req, spec.Version, estimate.TotalCents)
+
+	if freeze != nil {
+		if err := cp.deferRun(ctx, run, freeze.EndsAt); err != nil {
+			return nil, err
+		}
+		log.Printf("Run %s deferred by freeze window %s until %s", run.ID, freeze.Name, freeze.EndsAt.Format(time.RFC3339))
+		return run, nil
+	}
+
+	if err := cp.admitRun(ctx, run, limits); err != nil {
+		return nil, err
 	}
 
 	return run, nil
 }
 
+// admitRun schedules a saved run, holding it in the pending queue if org or
+// workflow caps are reached
+func (cp *ControlPlane) admitRun(ctx context.Context, run *WorkflowRun, limits RunLimits) error {
+	return cp.admission.admit(ctx, run, limits)
+}
+
+// ReleaseRun frees a finished run's concurrency slot and promotes pending runs
+func (cp *ControlPlane) ReleaseRun(ctx context.Context, runID uuid.UUID) error {
+	return cp.admission.release(ctx, runID)
+}
+
 func (cp *ControlPlane) GetWorkflowRun(ctx context.Context, runID uuid.UUID) (*WorkflowRun, error) {
-	query := `SELECT id, workflow_spec_id, status, started_at, ended_at, cost_cents, metadata, created_at 
-			  FROM workflow_run WHERE id = $1`
+	query := `SELECT r.id, r.workflow_spec_id, s.name, s.org_id, r.status, r.started_at, r.ended_at, 
+			  r.cost_cents, r.metadata, r.tags, r.labels, r.sla_deadline, COALESCE(r.sla_status, ''), r.failure, r.created_at 
+			  FROM workflow_run r JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE r.id = $1`
 
 	var run WorkflowRun
-	var metadataJSON []byte
+	var metadataJSON, tagsJSON, labelsJSON, failureJSON []byte
 
 	err := cp.db.QueryRowContext(ctx, query, runID).Scan(
-		&run.ID, &run.WorkflowSpecID, &run.Status, &run.StartedAt, &run.EndedAt,
-		&run.CostCents, &metadataJSON, &run.CreatedAt,
+		&run.ID, &run.WorkflowSpecID, &run.WorkflowName, &run.OrgID, &run.Status, &run.StartedAt, &run.EndedAt,
+		&run.CostCents, &metadataJSON, &tagsJSON, &labelsJSON, &run.SLADeadline, &run.SLAStatus, &failureJSON, &run.CreatedAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get workflow run: %w", err)
@@ -187,13 +546,30 @@ func (cp *ControlPlane) GetWorkflowRun(ctx context.Context, runID uuid.UUID) (*W
 	if err := json.Unmarshal(metadataJSON, &run.Metadata); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 	}
+	if err := json.Unmarshal(tagsJSON, &run.Tags); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+	}
+	if err := json.Unmarshal(labelsJSON, &run.Labels); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal labels: %w", err)
+	}
+	if len(failureJSON) > 0 {
+		if err := json.Unmarshal(failureJSON, &run.Failure); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal failure: %w", err)
+		}
+	}
+
+	if run.Status == RunStatusQueued {
+		if run.QueuePosition, err = cp.limiter.Position(ctx, run.OrgID, run.ID); err != nil {
+			return nil, err
+		}
+	}
 
 	return &run, nil
 }
 
 func (cp *ControlPlane) CancelWorkflowRun(ctx context.Context, runID uuid.UUID) error {
 	// Update run status
-	query := `UPDATE workflow_run SET status = 'canceled' WHERE id = $1 AND status IN ('queued', 'running')`
+	query := `UPDATE workflow_run SET status = 'canceled' WHERE id = $1 AND status IN ('queued', 'running', 'paused')`
 
 	result, err := cp.db.ExecContext(ctx, query, runID)
 	if err != nil {
@@ -209,37 +585,140 @@ func (cp *ControlPlane) CancelWorkflowRun(ctx context.Context, runID uuid.UUID)
 		return fmt.Errorf("workflow run not found or not in cancellable state")
 	}
 
-	// Send cancellation signal
-	cancelMsg := map[string]interface{}{
-		"run_id": runID.String(),
-		"action": "cancel",
+	// Tasks parked while the run was paused never run
+	if err := cp.queue.DiscardRun(ctx, runID); err != nil {
+		log.Printf("Failed to discard parked tasks of run %s: %v", runID, err)
 	}
+	cp.publishRunSignal(runID, "cancel")
 
-	msgData, _ := json.Marshal(cancelMsg)
-	if _, err := cp.js.Publish("agentflow.signals", msgData); err != nil {
-		log.Printf("Failed to send cancellation signal: %v", err)
+	if err := cp.ReleaseRun(ctx, runID); err != nil {
+		log.Printf("Failed to release concurrency slot for run %s: %v", runID, err)
 	}
 
 	return nil
 }
 
+// RetryStep retries a step of a finished or failed run with optional overrides,
+// moving a failed run back to running so downstream steps can resume
+func (cp *ControlPlane) RetryStep(ctx context.Context, runID uuid.UUID, stepID string, req *StepRetryRequest) (*StepRun, error) {
+	run, err := cp.GetWorkflowRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	if run.Status == RunStatusCancelled || run.Status == "canceled" {
+		return nil, fmt.Errorf("cannot retry step of canceled run %s", runID)
+	}
+
+	stepRun, err := cp.scheduler.RetryStep(ctx, run, stepID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE workflow_run SET status = 'running', ended_at = NULL WHERE id = $1 AND status = 'failed'`
+	if _, err := cp.db.ExecContext(ctx, query, runID); err != nil {
+		return nil, fmt.Errorf("failed to resume workflow run: %w", err)
+	}
+
+	return stepRun, nil
+}
+
+// ReplayRun starts a deterministic replay of a past run: the same spec and
+// inputs execute with LLM steps served from the run's recorded responses
+func (cp *ControlPlane) ReplayRun(ctx context.Context, runID uuid.UUID) (*WorkflowRun, error) {
+	original, err := cp.GetWorkflowRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	version, ok := original.Metadata["workflow_version"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("run %s did not record its workflow version", runID)
+	}
+
+	// Replay is only deterministic against the exact spec the run executed
+	spec, err := cp.getWorkflowSpec(ctx, original.WorkflowName, int(version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow spec: %w", err)
+	}
+	content, err := CanonicalSpecBytes(spec)
+	if err != nil {
+		return nil, err
+	}
+	if recorded, _ := original.Metadata["spec_hash"].(string); recorded != "" && recorded != db.HashContent(content) {
+		return nil, fmt.Errorf("workflow spec %s v%d changed since run %s", spec.Name, spec.Version, runID)
+	}
+
+	inputs, _ := original.Metadata["inputs"].(map[string]interface{})
+	environment, _ := original.Metadata["environment"].(string)
+
+	// Replays are always pinned, to the original's seed when it had one
+	var seed *int64
+	if repro := runReproducibility(original.Metadata); repro != nil {
+		seed = &repro.Seed
+	}
+
+	return cp.SubmitWorkflow(ctx, &RunRequest{
+		WorkflowName:    original.WorkflowName,
+		WorkflowVersion: int(version),
+		Inputs:          inputs,
+		Tags:            metadataStrings(original.Metadata, "tags"),
+		Labels:          original.Labels,
+		ReplayOf:        &runID,
+		Trigger:         TriggerReplay,
+		Environment:     environment,
+		Lane:            runLane(original.Metadata),
+		Residency:       runResidency(original.Metadata),
+		Reproducible:    true,
+		Seed:            seed,
+	})
+}
+
+// LintWorkflow checks a stored workflow spec for anti-patterns, and its step
+// timeouts against the steps' recent durations
+func (cp *ControlPlane) LintWorkflow(ctx context.Context, name string, version int) (*LintReport, error) {
+	spec, err := cp.getWorkflowSpec(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	report := LintWorkflowSpec(spec)
+	history, err := loadStepDurationStats(ctx, cp.db, spec.OrgID, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	report.addTimeoutSuggestions(SuggestSpecTimeouts(spec, history))
+	return report, nil
+}
+
 func (cp *ControlPlane) initStreams() error {
 	streams := []struct {
 		name     string
 		subjects []string
+		maxAge   time.Duration
 	}{
-		{"AGENTFLOW_TASKS", []string{"agentflow.tasks.*"}},
-		{"AGENTFLOW_RESULTS", []string{"agentflow.results.*"}},
-		{"AGENTFLOW_SIGNALS", []string{"agentflow.signals"}},
+		{"AGENTFLOW_TASKS", []string{"agentflow.tasks.*", regionTaskPrefix + "*", workerTaskPrefix + "*"}, 0},
+		{"AGENTFLOW_RESULTS", []string{"agentflow.results.*"}, 0},
+		{"AGENTFLOW_SIGNALS", []string{"agentflow.signals"}, 0},
+		{"AGENTFLOW_CAPABILITIES", []string{CapabilitySubject}, 0},
+		{"AGENTFLOW_EVENTS", []string{EventSubjectPrefix + ">"}, 0},
+		{deadLetterStream, []string{deadLetterSubjectPrefix + "*"}, deadLetterRetention},
 	}
 
 	for _, stream := range streams {
-		_, err := cp.js.AddStream(&nats.StreamConfig{
+		streamConfig := &nats.StreamConfig{
 			Name:     stream.name,
 			Subjects: stream.subjects,
 			MaxAge:   24 * time.Hour,
-		})
-		if err != nil && err != nats.ErrStreamNameAlreadyInUse {
+		}
+		if stream.maxAge > 0 {
+			streamConfig.MaxAge = stream.maxAge
+		}
+		_, err := cp.js.AddStream(streamConfig)
+		if err == nats.ErrStreamNameAlreadyInUse {
+			_, err = cp.js.UpdateStream(streamConfig) // Pick up subjects added since the stream was created
+		}
+		if err != nil {
 			return fmt.Errorf("failed to create stream %s: %w", stream.name, err)
 		}
 	}
@@ -247,15 +726,21 @@ func (cp *ControlPlane) initStreams() error {
 	return nil
 }
 
+// GetWorkflowSpec returns a stored spec as runs execute it, with macros and
+// YAML anchors already expanded at submission
+func (cp *ControlPlane) GetWorkflowSpec(ctx context.Context, name string, version int) (*WorkflowSpec, error) {
+	return cp.getWorkflowSpec(ctx, name, version)
+}
+
 func (cp *ControlPlane) getWorkflowSpec(ctx context.Context, name string, version int) (*WorkflowSpec, error) {
-	query := `SELECT id, org_id, name, version, dag, metadata 
+	query := `SELECT id, org_id, name, version, dag, metadata, COALESCE(content_hash, '')
 			  FROM workflow_spec WHERE name = $1 AND version = $2`
 
 	var spec WorkflowSpec
 	var dagJSON, metadataJSON []byte
 
 	err := cp.db.QueryRowContext(ctx, query, name, version).Scan(
-		&spec.ID, &spec.OrgID, &spec.Name, &spec.Version, &dagJSON, &metadataJSON,
+		&spec.ID, &spec.OrgID, &spec.Name, &spec.Version, &dagJSON, &metadataJSON, &spec.ContentHash,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get workflow spec: %w", err)
@@ -278,11 +763,34 @@ func (cp *ControlPlane) saveWorkflowRun(ctx context.Context, run *WorkflowRun) e
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	query := `INSERT INTO workflow_run (id, workflow_spec_id, status, cost_cents, metadata, created_at)
-			  VALUES ($1, $2, $3, $4, $5, $6)`
+	tags := run.Tags
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	labels := run.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labelsJSON, err := json.Marshal(labels)
+	if err != nil {
+		return fmt.Errorf("failed to marshal labels: %w", err)
+	}
+
+	var slaStatus interface{}
+	if run.SLAStatus != "" {
+		slaStatus = string(run.SLAStatus)
+	}
+
+	query := `INSERT INTO workflow_run (id, workflow_spec_id, status, cost_cents, metadata, tags, labels, sla_deadline, sla_status, batch_id, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
 	_, err = cp.db.ExecContext(ctx, query,
-		run.ID, run.WorkflowSpecID, run.Status, run.CostCents, metadataJSON, run.CreatedAt,
+		run.ID, run.WorkflowSpecID, run.Status, run.CostCents, metadataJSON, tagsJSON, labelsJSON, run.SLADeadline, slaStatus, run.BatchID, run.CreatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert workflow run: %w", err)
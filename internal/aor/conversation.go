@@ -0,0 +1,528 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultConversationMemory = 10
+	defaultConversationIdle   = 30 * time.Minute
+)
+
+// Conversation states
+const (
+	ConversationOpen   = "open"
+	ConversationClosed = "closed"
+)
+
+// Turn statuses
+const (
+	TurnStatusRunning   = "running"
+	TurnStatusCompleted = "completed"
+	TurnStatusFailed    = "failed"
+)
+
+// ConversationSpec makes a workflow conversational: its runs stay open after
+// the first pass, and each follow-up turn re-executes only the listed steps
+// with the turn's inputs and the session memory of earlier turns
+type ConversationSpec struct {
+	Steps       []string `json:"steps,omitempty"`        // Steps re-executed per turn; every step when empty
+	ReplyStep   string   `json:"reply_step,omitempty"`   // Step whose output answers a turn
+	MemoryTurns int      `json:"memory_turns,omitempty"` // Earlier turns passed to each turn as memory
+	MaxTurns    int      `json:"max_turns,omitempty"`    // 0 allows any number of turns
+	IdleTimeout string   `json:"idle_timeout,omitempty"` // Close the conversation after this long without a turn
+}
+
+// Conversation is the session state of an open or closed conversational run
+type Conversation struct {
+	RunID       uuid.UUID            `json:"run_id"`
+	State       string               `json:"state"`
+	Steps       []string             `json:"steps"`
+	ReplyStep   string               `json:"reply_step"`
+	Turns       []ConversationTurn   `json:"turns"`
+	Memory      []ConversationMemory `json:"memory"`
+	CostCents   int64                `json:"cost_cents"`
+	LastTurnAt  time.Time            `json:"last_turn_at"`
+	ClosedAt    *time.Time           `json:"closed_at,omitempty"`
+	CloseReason string               `json:"close_reason,omitempty"`
+}
+
+// ConversationTurn is one round of a conversation; turn 1 is the run's first pass
+type ConversationTurn struct {
+	RunID       uuid.UUID              `json:"run_id"`
+	Turn        int                    `json:"turn"`
+	Status      string                 `json:"status"`
+	Inputs      map[string]interface{} `json:"inputs"`
+	Output      map[string]interface{} `json:"output,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	CostCents   int64                  `json:"cost_cents"`
+	CreatedAt   time.Time              `json:"created_at"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+}
+
+// ConversationMemory is what later turns see of an earlier one
+type ConversationMemory struct {
+	Turn   int                    `json:"turn"`
+	Inputs map[string]interface{} `json:"inputs"`
+	Output map[string]interface{} `json:"output"`
+}
+
+// TurnRequest submits a follow-up turn to an open conversation
+type TurnRequest struct {
+	Inputs       map[string]interface{} `json:"inputs"`
+	MaxCostCents int64                  `json:"max_cost_cents,omitempty"` // Reject the turn if its estimate is higher
+}
+
+// resolvedConversation is a spec's conversation config with defaults applied
+type resolvedConversation struct {
+	Steps       []string
+	ReplyStep   string
+	MemoryTurns int
+	MaxTurns    int
+	IdleTimeout time.Duration
+}
+
+// resolveConversation validates a spec's conversation config, returning nil
+// when the workflow is not conversational
+func resolveConversation(dag DAG, conv *ConversationSpec) (*resolvedConversation, error) {
+	if conv == nil {
+		return nil, nil
+	}
+
+	resolved := &resolvedConversation{
+		Steps:       conv.Steps,
+		ReplyStep:   conv.ReplyStep,
+		MemoryTurns: conv.MemoryTurns,
+		MaxTurns:    conv.MaxTurns,
+		IdleTimeout: defaultConversationIdle,
+	}
+	if resolved.MemoryTurns == 0 {
+		resolved.MemoryTurns = defaultConversationMemory
+	}
+	if resolved.MemoryTurns < 0 || resolved.MaxTurns < 0 {
+		return nil, fmt.Errorf("conversation memory_turns and max_turns must not be negative")
+	}
+	if conv.IdleTimeout != "" {
+		d, err := time.ParseDuration(conv.IdleTimeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid conversation idle_timeout %q", conv.IdleTimeout)
+		}
+		resolved.IdleTimeout = d
+	}
+
+	if len(resolved.Steps) == 0 {
+		for _, step := range dag.Steps {
+			resolved.Steps = append(resolved.Steps, step.ID)
+		}
+	}
+	inTurn := make(map[string]bool, len(resolved.Steps))
+	for _, id := range resolved.Steps {
+		if findStep(dag.Steps, id) == nil {
+			return nil, fmt.Errorf("conversation step %s is not in the workflow", id)
+		}
+		inTurn[id] = true
+	}
+
+	if resolved.ReplyStep == "" {
+		// Default to the one turn step nothing else in the turn depends on
+		var sinks []string
+		for _, id := range resolved.Steps {
+			sink := true
+			for _, edge := range dag.Edges {
+				if edge.From == id && inTurn[edge.To] {
+					sink = false
+				}
+			}
+			if sink {
+				sinks = append(sinks, id)
+			}
+		}
+		if len(sinks) != 1 {
+			return nil, fmt.Errorf("conversation has %d final steps %v; set reply_step", len(sinks), sinks)
+		}
+		resolved.ReplyStep = sinks[0]
+	}
+	if !inTurn[resolved.ReplyStep] {
+		return nil, fmt.Errorf("conversation reply_step %s is not one of its steps", resolved.ReplyStep)
+	}
+
+	return resolved, nil
+}
+
+// turnSpec returns the part of a spec a follow-up turn executes, for estimating its cost
+func turnSpec(spec *WorkflowSpec, steps []string) *WorkflowSpec {
+	sub := *spec
+	sub.DAG = DAG{}
+	for _, id := range steps {
+		if step := findStep(spec.DAG.Steps, id); step != nil {
+			sub.DAG.Steps = append(sub.DAG.Steps, *step)
+		}
+	}
+	return &sub
+}
+
+// runTurn returns the conversation turn a run's tasks belong to, or 0
+func runTurn(metadata map[string]interface{}) int {
+	switch v := metadata["turn"].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// openConversation records the session of a conversational run and its first turn
+func (cp *ControlPlane) openConversation(ctx context.Context, run *WorkflowRun, conv *resolvedConversation, inputs map[string]interface{}) error {
+	inputsJSON, err := json.Marshal(inputs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal turn inputs: %w", err)
+	}
+
+	tx, err := cp.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `INSERT INTO conversation_session (run_id, org_id, steps, reply_step, memory_turns, max_turns, idle_timeout_seconds, turns, last_turn_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, 1, $8)`
+	_, err = tx.ExecContext(ctx, query, run.ID, run.OrgID, pq.Array(conv.Steps), conv.ReplyStep,
+		conv.MemoryTurns, conv.MaxTurns, int64(conv.IdleTimeout.Seconds()), run.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to open conversation: %w", err)
+	}
+	if err := insertTurn(ctx, tx, run.ID, 1, inputsJSON); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit conversation: %w", err)
+	}
+	return nil
+}
+
+func insertTurn(ctx context.Context, tx *sql.Tx, runID uuid.UUID, turn int, inputsJSON []byte) error {
+	query := `INSERT INTO conversation_turn (run_id, turn, status, inputs) VALUES ($1, $2, 'running', $3)`
+	if _, err := tx.ExecContext(ctx, query, runID, turn, inputsJSON); err != nil {
+		return fmt.Errorf("failed to save turn: %w", err)
+	}
+	return nil
+}
+
+// SubmitTurn starts the next turn of an open conversational run. Only the
+// conversation's steps re-execute, with the turn's inputs plus "turn" and
+// "memory" of earlier turns; turns run one at a time.
+func (cp *ControlPlane) SubmitTurn(ctx context.Context, runID uuid.UUID, req *TurnRequest) (*ConversationTurn, error) {
+	run, err := cp.GetWorkflowRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	version, _ := run.Metadata["workflow_version"].(float64)
+	spec, err := cp.getWorkflowSpec(ctx, run.WorkflowName, int(version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow spec: %w", err)
+	}
+	conv, err := resolveConversation(spec.DAG, spec.Metadata.Conversation)
+	if err != nil {
+		return nil, err
+	}
+	if conv == nil {
+		return nil, fmt.Errorf("workflow %s is not conversational", spec.Name)
+	}
+
+	// Turns draw on what is left of the run's budget
+	pricing, err := cp.modelPricing(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	estimate := EstimateSpecCost(turnSpec(spec, conv.Steps), pricing)
+	remaining := int64(0)
+	if budget := runBudgetCents(run.Metadata); budget > 0 {
+		remaining = budget - run.CostCents
+		if remaining <= 0 {
+			return nil, fmt.Errorf("run %s has spent its %d¢ budget", runID, budget)
+		}
+	}
+	if err := checkEstimatedCost(estimate, remaining, req.MaxCostCents); err != nil {
+		return nil, err
+	}
+
+	inputs, err := cp.offloadMedia(ctx, spec.OrgID, req.Inputs)
+	if err != nil {
+		return nil, err
+	}
+	inputsJSON, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal turn inputs: %w", err)
+	}
+
+	tx, err := cp.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var state, lastStatus string
+	var turns, maxTurns int
+	var memoryJSON []byte
+	query := `SELECT s.state, s.turns, s.max_turns, s.memory, COALESCE(t.status, '')
+			  FROM conversation_session s
+			  LEFT JOIN conversation_turn t ON t.run_id = s.run_id AND t.turn = s.turns
+			  WHERE s.run_id = $1 FOR UPDATE OF s`
+	err = tx.QueryRowContext(ctx, query, runID).Scan(&state, &turns, &maxTurns, &memoryJSON, &lastStatus)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("run %s has no conversation", runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if state != ConversationOpen {
+		return nil, fmt.Errorf("conversation %s is closed", runID)
+	}
+	if lastStatus == TurnStatusRunning {
+		return nil, fmt.Errorf("turn %d of conversation %s is still running", turns, runID)
+	}
+	if maxTurns > 0 && turns >= maxTurns {
+		return nil, fmt.Errorf("conversation %s reached its limit of %d turns", runID, maxTurns)
+	}
+
+	turn := &ConversationTurn{RunID: runID, Turn: turns + 1, Status: TurnStatusRunning, Inputs: inputs, CreatedAt: time.Now()}
+	if err := insertTurn(ctx, tx, runID, turn.Turn, inputsJSON); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE conversation_session SET turns = $2, last_turn_at = $3 WHERE run_id = $1`,
+		runID, turn.Turn, turn.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to update conversation: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit turn: %w", err)
+	}
+
+	var memory []ConversationMemory
+	if err := json.Unmarshal(memoryJSON, &memory); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal conversation memory: %w", err)
+	}
+	if err := cp.scheduler.ScheduleTurn(ctx, run, spec, conv.Steps, turn, memory); err != nil {
+		return nil, fmt.Errorf("failed to schedule turn: %w", err)
+	}
+	conversationTurns.Inc("submitted")
+
+	return turn, nil
+}
+
+// ScheduleTurn enqueues the steps of a turn that don't wait on other turn steps
+func (s *Scheduler) ScheduleTurn(ctx context.Context, run *WorkflowRun, spec *WorkflowSpec, steps []string, turn *ConversationTurn, memory []ConversationMemory) error {
+	log.Printf("Scheduling turn %d of run %s", turn.Turn, run.ID)
+
+	turnRun := *run
+	turnRun.Metadata = mergeOverrides(run.Metadata, map[string]interface{}{
+		"turn":        turn.Turn,
+		"turn_inputs": mergeOverrides(turn.Inputs, map[string]interface{}{"turn": turn.Turn, "memory": memory}),
+	})
+
+	inTurn := make(map[string]bool, len(steps))
+	for _, id := range steps {
+		inTurn[id] = true
+	}
+	for _, id := range steps {
+		waits := false
+		for _, edge := range spec.DAG.Edges {
+			if edge.To == id && inTurn[edge.From] {
+				waits = true
+			}
+		}
+		if waits {
+			continue
+		}
+		if err := s.enqueueStep(ctx, &turnRun, *findStep(spec.DAG.Steps, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordTurnResult charges a step's cost to its turn and completes the turn
+// when its reply step finishes, adding it to the session memory
+func (cp *ControlPlane) recordTurnResult(ctx context.Context, result *TaskResult) error {
+	tx, err := cp.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var replyStep string
+	var memoryTurns int
+	var memoryJSON []byte
+	query := `SELECT reply_step, memory_turns, memory FROM conversation_session WHERE run_id = $1 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, query, result.RunID).Scan(&replyStep, &memoryTurns, &memoryJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	update := `UPDATE conversation_turn SET cost_cents = cost_cents + $3 WHERE run_id = $1 AND turn = $2 RETURNING inputs`
+	var inputsJSON []byte
+	if err := tx.QueryRowContext(ctx, update, result.RunID, result.Turn, result.CostCents).Scan(&inputsJSON); err != nil {
+		return fmt.Errorf("failed to charge turn: %w", err)
+	}
+
+	switch {
+	case result.Status == TaskStatusFailed:
+		query = `UPDATE conversation_turn SET status = 'failed', error = $3, completed_at = NOW()
+				 WHERE run_id = $1 AND turn = $2 AND status = 'running'`
+		if _, err := tx.ExecContext(ctx, query, result.RunID, result.Turn, fmt.Sprintf("step %s: %s", result.NodeID, result.Error)); err != nil {
+			return fmt.Errorf("failed to fail turn: %w", err)
+		}
+		conversationTurns.Inc(TurnStatusFailed)
+	case result.NodeID == replyStep:
+		outputJSON, err := json.Marshal(result.Output)
+		if err != nil {
+			return fmt.Errorf("failed to marshal turn output: %w", err)
+		}
+		query = `UPDATE conversation_turn SET status = 'completed', output = $3, completed_at = NOW()
+				 WHERE run_id = $1 AND turn = $2`
+		if _, err := tx.ExecContext(ctx, query, result.RunID, result.Turn, outputJSON); err != nil {
+			return fmt.Errorf("failed to complete turn: %w", err)
+		}
+
+		var memory []ConversationMemory
+		if err := json.Unmarshal(memoryJSON, &memory); err != nil {
+			return fmt.Errorf("failed to unmarshal conversation memory: %w", err)
+		}
+		entry := ConversationMemory{Turn: result.Turn, Output: result.Output}
+		_ = json.Unmarshal(inputsJSON, &entry.Inputs)
+		memoryJSON, err = json.Marshal(appendMemory(memory, entry, memoryTurns))
+		if err != nil {
+			return fmt.Errorf("failed to marshal conversation memory: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE conversation_session SET memory = $2 WHERE run_id = $1`, result.RunID, memoryJSON); err != nil {
+			return fmt.Errorf("failed to update conversation memory: %w", err)
+		}
+		conversationTurns.Inc(TurnStatusCompleted)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit turn result: %w", err)
+	}
+	return nil
+}
+
+// appendMemory adds a turn to the session memory, keeping the last limit turns
+func appendMemory(memory []ConversationMemory, entry ConversationMemory, limit int) []ConversationMemory {
+	memory = append(memory, entry)
+	if limit > 0 && len(memory) > limit {
+		memory = memory[len(memory)-limit:]
+	}
+	return memory
+}
+
+// GetConversation returns a conversational run's session and turns
+func (cp *ControlPlane) GetConversation(ctx context.Context, runID uuid.UUID) (*Conversation, error) {
+	conv := &Conversation{RunID: runID, Turns: make([]ConversationTurn, 0)}
+	var memoryJSON []byte
+	query := `SELECT state, steps, reply_step, memory, last_turn_at, closed_at, close_reason
+			  FROM conversation_session WHERE run_id = $1`
+	err := cp.db.QueryRowContext(ctx, query, runID).Scan(&conv.State, pq.Array(&conv.Steps), &conv.ReplyStep,
+		&memoryJSON, &conv.LastTurnAt, &conv.ClosedAt, &conv.CloseReason)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("run %s has no conversation", runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	if err := json.Unmarshal(memoryJSON, &conv.Memory); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal conversation memory: %w", err)
+	}
+
+	rows, err := cp.db.QueryContext(ctx, `SELECT turn, status, inputs, output, error, cost_cents, created_at, completed_at
+			  FROM conversation_turn WHERE run_id = $1 ORDER BY turn`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query turns: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		turn := ConversationTurn{RunID: runID}
+		var inputsJSON, outputJSON []byte
+		if err := rows.Scan(&turn.Turn, &turn.Status, &inputsJSON, &outputJSON, &turn.Error, &turn.CostCents, &turn.CreatedAt, &turn.CompletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan turn: %w", err)
+		}
+		_ = json.Unmarshal(inputsJSON, &turn.Inputs)
+		if outputJSON != nil {
+			_ = json.Unmarshal(outputJSON, &turn.Output)
+		}
+		conv.CostCents += turn.CostCents
+		conv.Turns = append(conv.Turns, turn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read turns: %w", err)
+	}
+
+	return conv, nil
+}
+
+// CloseConversation ends a conversation, completing its run
+func (cp *ControlPlane) CloseConversation(ctx context.Context, runID uuid.UUID, reason string) error {
+	tx, err := cp.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `UPDATE conversation_session SET state = 'closed', closed_at = NOW(), close_reason = $2
+			  WHERE run_id = $1 AND state = 'open'`
+	result, err := tx.ExecContext(ctx, query, runID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to close conversation: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("conversation %s not found or already closed", runID)
+	}
+
+	query = `UPDATE workflow_run SET status = 'succeeded', ended_at = NOW() WHERE id = $1 AND status = 'running'`
+	if _, err := tx.ExecContext(ctx, query, runID); err != nil {
+		return fmt.Errorf("failed to complete conversational run: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit conversation close: %w", err)
+	}
+
+	if err := cp.ReleaseRun(ctx, runID); err != nil {
+		log.Printf("Failed to release concurrency slot for run %s: %v", runID, err)
+	}
+	return nil
+}
+
+// closeIdleConversations closes conversations with no turn within their idle timeout
+func (cp *ControlPlane) closeIdleConversations(ctx context.Context) {
+	query := `SELECT s.run_id FROM conversation_session s
+			  WHERE s.state = 'open' AND s.last_turn_at < NOW() - make_interval(secs => s.idle_timeout_seconds)
+			  AND NOT EXISTS (SELECT 1 FROM conversation_turn t
+			                  WHERE t.run_id = s.run_id AND t.turn = s.turns AND t.status = 'running')`
+	runIDs, err := cp.queryIDs(ctx, query)
+	if err != nil {
+		log.Printf("Failed to query idle conversations: %v", err)
+		return
+	}
+
+	for _, runID := range runIDs {
+		if err := cp.CloseConversation(ctx, runID, "idle"); err != nil {
+			log.Printf("Failed to close idle conversation %s: %v", runID, err)
+			continue
+		}
+		conversationTurns.Inc("idle_closed")
+	}
+}
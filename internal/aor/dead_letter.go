@@ -0,0 +1,261 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	deadLetterStream = "AGENTFLOW_DLQ"
+	// deadLetterSubjectPrefix is followed by the org ID of the failed task
+	deadLetterSubjectPrefix = "agentflow.dlq."
+	// deadLetterRetention is how long failed tasks can be inspected and requeued
+	deadLetterRetention = 14 * 24 * time.Hour
+
+	defaultDeadLetterLimit = 50
+)
+
+// DeadLetter is a task the worker gave up on, kept with its full payload so
+// it can be inspected and requeued once the cause is fixed
+type DeadLetter struct {
+	ID              uint64    `json:"id"` // DLQ stream sequence
+	Task            Task      `json:"task"`
+	Error           string    `json:"error"`
+	ErrorClass      string    `json:"error_class,omitempty"`
+	FailureCategory string    `json:"failure_category,omitempty"`
+	Attempts        int       `json:"attempts"`
+	Retryable       bool      `json:"retryable"` // The last error was transient, so a requeue may succeed as is
+	WorkerID        string    `json:"worker_id"`
+	FailedAt        time.Time `json:"failed_at"`
+}
+
+// DeadLetterFilter selects DLQ entries, newest first
+type DeadLetterFilter struct {
+	RunID    uuid.UUID `json:"run_id,omitempty"`
+	Workflow string    `json:"workflow,omitempty"`
+	StepID   string    `json:"step_id,omitempty"`
+	Limit    int       `json:"limit,omitempty"`
+}
+
+func (f DeadLetterFilter) matches(dl *DeadLetter) bool {
+	return (f.RunID == uuid.Nil || dl.Task.RunID == f.RunID) &&
+		(f.Workflow == "" || dl.Task.Workflow == f.Workflow) &&
+		(f.StepID == "" || dl.Task.StepID == f.StepID)
+}
+
+func deadLetterSubject(orgID uuid.UUID) string {
+	return deadLetterSubjectPrefix + orgID.String()
+}
+
+// newDeadLetter records a task that failed after the given attempts, or nil
+// when the failure came from the run being canceled
+func newDeadLetter(task *Task, err error, attempts int, workerID string) *DeadLetter {
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return &DeadLetter{
+		Task:            *task,
+		Error:           err.Error(),
+		ErrorClass:      string(cas.ClassifyError(err)),
+		FailureCategory: string(classifyFailure(err, task.Type)),
+		Attempts:        attempts,
+		Retryable:       retryableError(err),
+		WorkerID:        workerID,
+		FailedAt:        time.Now(),
+	}
+}
+
+// deadLetter moves a permanently failed task to the DLQ. Failing to do so
+// is logged rather than failing the step, whose result is recorded anyway.
+func (w *Worker) deadLetter(task *Task, err error, attempts int) {
+	dl := newDeadLetter(task, err, attempts, w.id)
+	if dl == nil {
+		return
+	}
+	data, marshalErr := json.Marshal(dl)
+	if marshalErr != nil {
+		log.Printf("Failed to marshal dead letter for task %s: %v", task.ID, marshalErr)
+		return
+	}
+	if _, pubErr := w.js.Publish(deadLetterSubject(task.OrgID), data); pubErr != nil {
+		log.Printf("Failed to dead-letter task %s: %v", task.ID, pubErr)
+		return
+	}
+	deadLetters.Inc(task.Node.Type)
+}
+
+// ListFailedTasks returns an org's dead-lettered tasks, newest first
+func (cp *ControlPlane) ListFailedTasks(ctx context.Context, orgID uuid.UUID, filter DeadLetterFilter) ([]DeadLetter, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultDeadLetterLimit
+	}
+	page := &deadLetterPage{filter: filter}
+	if err := cp.eachDeadLetter(ctx, orgID, page.add); err != nil {
+		return nil, err
+	}
+	return page.newestFirst(), nil
+}
+
+// deadLetterPage keeps the newest entries matching a filter from entries
+// read oldest first
+type deadLetterPage struct {
+	filter  DeadLetterFilter
+	letters []DeadLetter
+}
+
+func (p *deadLetterPage) add(dl *DeadLetter) {
+	if !p.filter.matches(dl) {
+		return
+	}
+	p.letters = append(p.letters, *dl)
+	if len(p.letters) > p.filter.Limit {
+		p.letters = p.letters[1:]
+	}
+}
+
+func (p *deadLetterPage) newestFirst() []DeadLetter {
+	letters := make([]DeadLetter, 0, len(p.letters))
+	for i := len(p.letters) - 1; i >= 0; i-- {
+		letters = append(letters, p.letters[i])
+	}
+	return letters
+}
+
+// eachDeadLetter calls fn with each of an org's DLQ entries, oldest first.
+// It reads through an ordered consumer filtered to the org's subject, so
+// other orgs' failures are never read.
+func (cp *ControlPlane) eachDeadLetter(ctx context.Context, orgID uuid.UUID, fn func(*DeadLetter)) error {
+	subject := deadLetterSubject(orgID)
+	last, err := cp.js.GetLastMsg(deadLetterStream, subject, nats.Context(ctx))
+	if errors.Is(err, nats.ErrMsgNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read dead letter queue: %w", err)
+	}
+
+	sub, err := cp.js.SubscribeSync(subject, nats.BindStream(deadLetterStream), nats.OrderedConsumer(), nats.DeliverAll())
+	if err != nil {
+		return fmt.Errorf("failed to read dead letter queue: %w", err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	// Entries dead-lettered while reading are left for the next listing
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read dead letter queue: %w", err)
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return fmt.Errorf("failed to read dead letter metadata: %w", err)
+		}
+		var dl DeadLetter
+		if err := json.Unmarshal(msg.Data, &dl); err != nil {
+			return fmt.Errorf("failed to decode failed task %d: %w", meta.Sequence.Stream, err)
+		}
+		dl.ID = meta.Sequence.Stream
+		fn(&dl)
+		if meta.Sequence.Stream >= last.Sequence || meta.NumPending == 0 {
+			return nil
+		}
+	}
+}
+
+// RequeueFailedTask retries a dead-lettered step as a new attempt, with
+// optional overrides for the fixed inputs or config, and removes it from
+// the DLQ
+func (cp *ControlPlane) RequeueFailedTask(ctx context.Context, orgID uuid.UUID, id uint64, req *StepRetryRequest) (*StepRun, error) {
+	dl, err := cp.getDeadLetter(ctx, orgID, id)
+	if errors.Is(err, nats.ErrMsgNotFound) || (err == nil && dl == nil) {
+		return nil, fmt.Errorf("failed task %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if req == nil {
+		req = &StepRetryRequest{}
+	}
+	if req.Reason == "" {
+		req.Reason = fmt.Sprintf("requeued from dead letter queue (%s)", dl.Error)
+	}
+	stepRun, err := cp.RetryStep(ctx, dl.Task.RunID, dl.Task.StepID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to requeue task %s: %w", dl.Task.ID, err)
+	}
+
+	if err := cp.js.DeleteMsg(deadLetterStream, id, nats.Context(ctx)); err != nil {
+		log.Printf("Requeued failed task %d but could not remove it from the dead letter queue: %v", id, err)
+	}
+	log.Printf("Requeued failed task %s of run %s as step run %s", dl.Task.ID, dl.Task.RunID, stepRun.ID)
+	return stepRun, nil
+}
+
+// runDeadLetters returns the sequences of an org's DLQ entries for the given runs
+func (cp *ControlPlane) runDeadLetters(ctx context.Context, orgID uuid.UUID, runIDs []uuid.UUID) ([]uint64, error) {
+	runs := make(map[uuid.UUID]bool, len(runIDs))
+	for _, id := range runIDs {
+		runs[id] = true
+	}
+	seqs := make([]uint64, 0)
+	if len(runs) == 0 {
+		return seqs, nil
+	}
+	err := cp.eachDeadLetter(ctx, orgID, func(dl *DeadLetter) {
+		if runs[dl.Task.RunID] {
+			seqs = append(seqs, dl.ID)
+		}
+	})
+	return seqs, err
+}
+
+// purgeDeadLetters removes an org's DLQ entries for the given runs and
+// returns how many it removed
+func (cp *ControlPlane) purgeDeadLetters(ctx context.Context, orgID uuid.UUID, runIDs []uuid.UUID) (int64, error) {
+	seqs, err := cp.runDeadLetters(ctx, orgID, runIDs)
+	if err != nil {
+		return 0, err
+	}
+	var purged int64
+	for _, seq := range seqs {
+		err := cp.js.DeleteMsg(deadLetterStream, seq, nats.Context(ctx))
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			continue // Requeued or aged out meanwhile
+		}
+		if err != nil {
+			return purged, fmt.Errorf("failed to remove failed task %d: %w", seq, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// getDeadLetter reads one DLQ entry, returning nil for another org's entry
+func (cp *ControlPlane) getDeadLetter(ctx context.Context, orgID uuid.UUID, seq uint64) (*DeadLetter, error) {
+	msg, err := cp.js.GetMsg(deadLetterStream, seq, nats.Context(ctx))
+	if err != nil {
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read failed task %d: %w", seq, err)
+	}
+	if msg.Subject != deadLetterSubject(orgID) {
+		return nil, nil
+	}
+
+	var dl DeadLetter
+	if err := json.Unmarshal(msg.Data, &dl); err != nil {
+		return nil, fmt.Errorf("failed to decode failed task %d: %w", seq, err)
+	}
+	dl.ID = seq
+	return &dl, nil
+}
@@ -0,0 +1,325 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	debugCapturePrefix = "debugcapture:"
+	// defaultDebugCaptureWindow applies when capture is started without a window
+	defaultDebugCaptureWindow = 15 * time.Minute
+	// maxDebugCaptureWindow bounds how long raw traffic can be captured at once
+	maxDebugCaptureWindow = 24 * time.Hour
+	// debugCaptureRetention is how long exchanges are kept after the last capture
+	debugCaptureRetention = 72 * time.Hour
+	// debugCaptureMaxEntries caps the exchanges kept per provider
+	debugCaptureMaxEntries = 500
+	// debugCaptureRefresh is how often workers re-read a provider's capture state
+	debugCaptureRefresh = 10 * time.Second
+)
+
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+	"api-key":             true,
+	"x-goog-api-key":      true,
+}
+
+// DebugCaptureRequest turns on raw request/response capture for a provider
+type DebugCaptureRequest struct {
+	Provider   string        `json:"provider"`
+	Window     time.Duration `json:"window"`      // How long capture stays on; default 15m, at most 24h
+	SampleRate float64       `json:"sample_rate"` // Share of calls captured; default 1
+	EnabledBy  string        `json:"enabled_by"`
+}
+
+// Validate applies defaults and checks the window and sample rate
+func (r *DebugCaptureRequest) Validate() error {
+	r.Provider = strings.ToLower(strings.TrimSpace(r.Provider))
+	if r.Provider == "" {
+		return fmt.Errorf("provider is required")
+	}
+	if r.Window == 0 {
+		r.Window = defaultDebugCaptureWindow
+	}
+	if r.Window < 0 || r.Window > maxDebugCaptureWindow {
+		return fmt.Errorf("capture window must be between 0 and %s", maxDebugCaptureWindow)
+	}
+	if r.SampleRate == 0 {
+		r.SampleRate = 1
+	}
+	if r.SampleRate < 0 || r.SampleRate > 1 {
+		return fmt.Errorf("sample rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// DebugCapture is an active capture window for a provider
+type DebugCapture struct {
+	Provider   string    `json:"provider"`
+	SampleRate float64   `json:"sample_rate"`
+	EnabledBy  string    `json:"enabled_by,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// CapturedMessage is one side of a provider exchange
+type CapturedMessage struct {
+	Method  string            `json:"method,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+}
+
+// ProviderExchange is a captured provider call with headers redacted and
+// bodies PII-scrubbed
+type ProviderExchange struct {
+	ID         uuid.UUID       `json:"id"`
+	Provider   string          `json:"provider"`
+	Model      string          `json:"model"`
+	Request    CapturedMessage `json:"request"`
+	Response   CapturedMessage `json:"response"`
+	Error      string          `json:"error,omitempty"`
+	Latency    time.Duration   `json:"latency"`
+	Redactions map[string]int  `json:"redactions,omitempty"`
+	CapturedAt time.Time       `json:"captured_at"`
+}
+
+// DebugCaptureStore keeps capture windows and captured exchanges in Redis so
+// every worker sees an admin's toggle
+type DebugCaptureStore struct {
+	redis *redis.Client
+
+	mu     sync.Mutex
+	cached map[string]cachedCapture
+}
+
+type cachedCapture struct {
+	capture   *DebugCapture
+	checkedAt time.Time
+}
+
+func NewDebugCaptureStore(redisClient *redis.Client) *DebugCaptureStore {
+	return &DebugCaptureStore{redis: redisClient, cached: make(map[string]cachedCapture)}
+}
+
+func debugCaptureKey(provider string) string {
+	return debugCapturePrefix + "window:" + provider
+}
+
+func debugExchangesKey(provider string) string {
+	return debugCapturePrefix + "exchanges:" + provider
+}
+
+// Start opens a capture window, replacing any current one for the provider
+func (s *DebugCaptureStore) Start(ctx context.Context, req *DebugCaptureRequest) (*DebugCapture, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	capture := &DebugCapture{
+		Provider:   req.Provider,
+		SampleRate: req.SampleRate,
+		EnabledBy:  req.EnabledBy,
+		StartedAt:  now,
+		ExpiresAt:  now.Add(req.Window),
+	}
+	data, err := json.Marshal(capture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode debug capture: %w", err)
+	}
+	if err := s.redis.Set(ctx, debugCaptureKey(req.Provider), data, req.Window).Err(); err != nil {
+		return nil, fmt.Errorf("failed to start debug capture: %w", err)
+	}
+	return capture, nil
+}
+
+// Stop closes a provider's capture window early
+func (s *DebugCaptureStore) Stop(ctx context.Context, provider string) error {
+	if err := s.redis.Del(ctx, debugCaptureKey(strings.ToLower(provider))).Err(); err != nil {
+		return fmt.Errorf("failed to stop debug capture: %w", err)
+	}
+	return nil
+}
+
+// Get returns the provider's active capture window, or nil
+func (s *DebugCaptureStore) Get(ctx context.Context, provider string) (*DebugCapture, error) {
+	data, err := s.redis.Get(ctx, debugCaptureKey(strings.ToLower(provider))).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read debug capture: %w", err)
+	}
+	var capture DebugCapture
+	if err := json.Unmarshal(data, &capture); err != nil {
+		return nil, fmt.Errorf("failed to decode debug capture: %w", err)
+	}
+	return &capture, nil
+}
+
+// active returns the provider's capture window, re-reading Redis at most
+// every few seconds so the check stays off the hot path
+func (s *DebugCaptureStore) active(ctx context.Context, provider string) *DebugCapture {
+	provider = strings.ToLower(provider)
+	now := time.Now()
+
+	s.mu.Lock()
+	cached, ok := s.cached[provider]
+	s.mu.Unlock()
+	if !ok || now.Sub(cached.checkedAt) > debugCaptureRefresh {
+		capture, err := s.Get(ctx, provider)
+		if err != nil {
+			log.Printf("Failed to check debug capture for %s: %v", provider, err)
+		}
+		cached = cachedCapture{capture: capture, checkedAt: now}
+		s.mu.Lock()
+		s.cached[provider] = cached
+		s.mu.Unlock()
+	}
+
+	if cached.capture == nil || now.After(cached.capture.ExpiresAt) {
+		return nil
+	}
+	return cached.capture
+}
+
+// Record redacts and stores an exchange, keeping the newest entries
+func (s *DebugCaptureStore) Record(ctx context.Context, exchange *ProviderExchange) error {
+	redactExchange(exchange)
+	data, err := json.Marshal(exchange)
+	if err != nil {
+		return fmt.Errorf("failed to encode provider exchange: %w", err)
+	}
+
+	key := debugExchangesKey(strings.ToLower(exchange.Provider))
+	pipe := s.redis.TxPipeline()
+	pipe.LPush(ctx, key, data)
+	pipe.LTrim(ctx, key, 0, debugCaptureMaxEntries-1)
+	pipe.Expire(ctx, key, debugCaptureRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store provider exchange: %w", err)
+	}
+	debugCaptures.Inc(exchange.Provider)
+	return nil
+}
+
+// List returns a provider's captured exchanges, newest first
+func (s *DebugCaptureStore) List(ctx context.Context, provider string, limit int) ([]ProviderExchange, error) {
+	if limit <= 0 || limit > debugCaptureMaxEntries {
+		limit = debugCaptureMaxEntries
+	}
+	items, err := s.redis.LRange(ctx, debugExchangesKey(strings.ToLower(provider)), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list provider exchanges: %w", err)
+	}
+	exchanges := make([]ProviderExchange, 0, len(items))
+	for _, item := range items {
+		var exchange ProviderExchange
+		if err := json.Unmarshal([]byte(item), &exchange); err != nil {
+			return nil, fmt.Errorf("failed to decode provider exchange: %w", err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	return exchanges, nil
+}
+
+// redactExchange masks credential headers and scrubs PII from both bodies
+func redactExchange(exchange *ProviderExchange) {
+	redactor := scl.NewRedactor()
+	counts := make(map[string]int)
+	for _, msg := range []*CapturedMessage{&exchange.Request, &exchange.Response} {
+		for name := range msg.Headers {
+			if sensitiveHeaders[strings.ToLower(name)] || secretKeyPattern.MatchString(name) {
+				msg.Headers[name] = "[REDACTED]"
+			}
+		}
+		if msg.Body != nil {
+			scrubbed, found := redactor.Scrub(msg.Body, scl.ScrubLevelStandard)
+			msg.Body = scrubbed
+			for kind, n := range found {
+				counts[kind] += n
+			}
+		}
+	}
+	if exchange.Error != "" {
+		scrubbed, found := redactor.Scrub(exchange.Error, scl.ScrubLevelStandard)
+		if s, ok := scrubbed.(string); ok {
+			exchange.Error = s
+		}
+		for kind, n := range found {
+			counts[kind] += n
+		}
+	}
+	if len(counts) > 0 {
+		exchange.Redactions = counts
+	}
+}
+
+// captureExchange records a provider call when the provider is in a capture
+// window and the call is sampled
+func (w *Worker) captureExchange(ctx context.Context, provider, model string, req, resp CapturedMessage, callErr error, latency time.Duration) {
+	if w == nil || w.captures == nil {
+		return
+	}
+	capture := w.captures.active(ctx, provider)
+	if capture == nil || secureRandFloat64() >= capture.SampleRate {
+		return
+	}
+
+	exchange := &ProviderExchange{
+		ID:         uuid.New(),
+		Provider:   provider,
+		Model:      model,
+		Request:    req,
+		Response:   resp,
+		Latency:    latency,
+		CapturedAt: time.Now(),
+	}
+	if callErr != nil {
+		exchange.Error = callErr.Error()
+	}
+	if err := w.captures.Record(ctx, exchange); err != nil {
+		log.Printf("Failed to capture %s exchange: %v", provider, err)
+	}
+}
+
+// StartDebugCapture turns on raw request/response capture for a provider
+func (cp *ControlPlane) StartDebugCapture(ctx context.Context, req *DebugCaptureRequest) (*DebugCapture, error) {
+	capture, err := cp.captures.Start(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Debug capture for %s enabled by %s until %s at sample rate %.2f",
+		capture.Provider, capture.EnabledBy, capture.ExpiresAt.Format(time.RFC3339), capture.SampleRate)
+	return capture, nil
+}
+
+// StopDebugCapture turns off capture for a provider; captured exchanges are kept
+func (cp *ControlPlane) StopDebugCapture(ctx context.Context, provider string) error {
+	return cp.captures.Stop(ctx, provider)
+}
+
+// GetDebugCapture returns a provider's active capture window, or nil
+func (cp *ControlPlane) GetDebugCapture(ctx context.Context, provider string) (*DebugCapture, error) {
+	return cp.captures.Get(ctx, provider)
+}
+
+// ListProviderExchanges returns the exchanges captured for a provider
+func (cp *ControlPlane) ListProviderExchanges(ctx context.Context, provider string, limit int) ([]ProviderExchange, error) {
+	return cp.captures.List(ctx, provider, limit)
+}
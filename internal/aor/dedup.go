@@ -0,0 +1,259 @@
+package aor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// dedupLockTTL bounds how long followers wait on a leader that disappeared
+	dedupLockTTL = 2 * time.Minute
+	// dedupResultTTL keeps a finished response long enough for followers to read it
+	dedupResultTTL = 30 * time.Second
+	// dedupPollInterval re-checks the shared result in case a notification was missed
+	dedupPollInterval = 250 * time.Millisecond
+
+	dedupDone   = "done"
+	dedupFailed = "failed"
+)
+
+// Dedup outcomes recorded in metrics
+const (
+	DedupOutcomeLeader      = "leader"
+	DedupOutcomeLocalHit    = "local_hit"
+	DedupOutcomeGlobalHit   = "global_hit"
+	DedupOutcomeUnavailable = "unavailable"
+)
+
+// llmCallResult is the shareable outcome of one upstream LLM call
+type llmCallResult struct {
+	Output           map[string]interface{} `json:"output"`
+	CostCents        int64                  `json:"cost_cents"`
+	TokensPrompt     int                    `json:"tokens_prompt"`
+	TokensCompletion int                    `json:"tokens_completion"`
+	TokensReasoning  int                    `json:"tokens_reasoning,omitempty"`
+	ReasoningCents   int64                  `json:"reasoning_cents,omitempty"`
+	TokensCacheRead  int                    `json:"tokens_cache_read,omitempty"`
+	TokensCacheWrite int                    `json:"tokens_cache_write,omitempty"`
+	CacheSavingCents int64                  `json:"cache_saving_cents,omitempty"`
+	Provider         string                 `json:"provider"`
+	Model            string                 `json:"model"`
+}
+
+type inflightCall struct {
+	done   chan struct{}
+	result *llmCallResult
+	err    error
+	dups   int
+}
+
+// CallDeduplicator collapses identical concurrent LLM calls into one upstream
+// request. Callers in the same worker share the call in memory; callers on
+// other workers wait on a Redis lock and read the leader's published result.
+type CallDeduplicator struct {
+	redis *redis.Client
+
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+func NewCallDeduplicator(redisClient *redis.Client) *CallDeduplicator {
+	return &CallDeduplicator{
+		redis: redisClient,
+		calls: make(map[string]*inflightCall),
+	}
+}
+
+// Do runs call once per key among concurrent callers. shared reports whether
+// the result came from another caller's upstream request.
+func (d *CallDeduplicator) Do(ctx context.Context, key string, call func(context.Context) (*llmCallResult, error)) (*llmCallResult, bool, error) {
+	d.mu.Lock()
+	if c, ok := d.calls[key]; ok {
+		c.dups++
+		d.mu.Unlock()
+		select {
+		case <-c.done:
+			llmDedupRequests.Inc(DedupOutcomeLocalHit)
+			return c.result, true, c.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+
+	c := &inflightCall{done: make(chan struct{})}
+	d.calls[key] = c
+	d.mu.Unlock()
+
+	var shared bool
+	c.result, shared, c.err = d.doGlobal(ctx, key, call)
+
+	d.mu.Lock()
+	delete(d.calls, key)
+	d.mu.Unlock()
+	close(c.done)
+
+	return c.result, shared, c.err
+}
+
+// doGlobal elects one worker per key to make the upstream call
+func (d *CallDeduplicator) doGlobal(ctx context.Context, key string, call func(context.Context) (*llmCallResult, error)) (*llmCallResult, bool, error) {
+	if d.redis == nil {
+		llmDedupRequests.Inc(DedupOutcomeLeader)
+		result, err := call(ctx)
+		return result, false, err
+	}
+
+	lockKey, resultKey, channel := "llm:inflight:"+key, "llm:result:"+key, "llm:done:"+key
+	token := uuid.New().String()
+
+	acquired, err := d.redis.SetNX(ctx, lockKey, token, dedupLockTTL).Result()
+	if err != nil {
+		// Deduplication is an optimization; never fail the call because Redis is unavailable
+		log.Printf("LLM dedup unavailable, calling provider directly: %v", err)
+		llmDedupRequests.Inc(DedupOutcomeUnavailable)
+		result, err := call(ctx)
+		return result, false, err
+	}
+
+	if acquired {
+		llmDedupRequests.Inc(DedupOutcomeLeader)
+		return d.lead(ctx, lockKey, resultKey, channel, call)
+	}
+
+	result, err := d.follow(ctx, lockKey, resultKey, channel)
+	if err == nil {
+		llmDedupRequests.Inc(DedupOutcomeGlobalHit)
+		return result, true, nil
+	}
+	if ctx.Err() != nil {
+		return nil, false, ctx.Err()
+	}
+
+	// The leader failed or vanished; make the call ourselves rather than share its error
+	llmDedupRequests.Inc(DedupOutcomeLeader)
+	result, err = call(ctx)
+	return result, false, err
+}
+
+func (d *CallDeduplicator) lead(ctx context.Context, lockKey, resultKey, channel string, call func(context.Context) (*llmCallResult, error)) (*llmCallResult, bool, error) {
+	result, callErr := call(ctx)
+
+	// Publish with a fresh context so a canceled leader still releases followers
+	pubCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status := dedupFailed
+	if callErr == nil {
+		if data, err := json.Marshal(result); err == nil {
+			if err := d.redis.Set(pubCtx, resultKey, data, dedupResultTTL).Err(); err == nil {
+				status = dedupDone
+			}
+		}
+	}
+
+	pipe := d.redis.TxPipeline()
+	pipe.Del(pubCtx, lockKey)
+	pipe.Publish(pubCtx, channel, status)
+	if _, err := pipe.Exec(pubCtx); err != nil {
+		log.Printf("Failed to release LLM dedup lock: %v", err)
+	}
+
+	return result, false, callErr
+}
+
+// follow waits for the leader's result
+func (d *CallDeduplicator) follow(ctx context.Context, lockKey, resultKey, channel string) (*llmCallResult, error) {
+	sub := d.redis.Subscribe(ctx, channel)
+	defer func() { _ = sub.Close() }()
+	messages := sub.Channel()
+
+	ticker := time.NewTicker(dedupPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Checking after subscribing covers a leader that finished in between
+		result, err := d.sharedResult(ctx, resultKey)
+		if err == nil {
+			return result, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+
+		held, err := d.redis.Exists(ctx, lockKey).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to check LLM dedup lock: %w", err)
+		}
+		if held == 0 {
+			// One last look in case the result landed just before the lock was released
+			if result, err := d.sharedResult(ctx, resultKey); err == nil {
+				return result, nil
+			}
+			return nil, fmt.Errorf("leader finished without a shareable result")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case msg := <-messages:
+			if msg != nil && msg.Payload == dedupFailed {
+				return nil, fmt.Errorf("leader call failed")
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *CallDeduplicator) sharedResult(ctx context.Context, resultKey string) (*llmCallResult, error) {
+	data, err := d.redis.Get(ctx, resultKey).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var result llmCallResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode shared LLM result: %w", err)
+	}
+	return &result, nil
+}
+
+// llmDedupKey identifies identical LLM requests: same org, rendered request
+// fingerprint and sampling parameters
+func llmDedupKey(orgID uuid.UUID, fingerprint string, config map[string]interface{}) string {
+	data, _ := json.Marshal(struct {
+		OrgID       uuid.UUID              `json:"org_id"`
+		Fingerprint string                 `json:"fingerprint"`
+		Params      map[string]interface{} `json:"params"`
+	}{orgID, fingerprint, llmCallParams(config)})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// llmCallParams picks the step settings that change a provider's response
+func llmCallParams(config map[string]interface{}) map[string]interface{} {
+	params := make(map[string]interface{})
+	for _, key := range []string{"temperature", "top_p", "max_tokens", "stop", "seed", "tools", "response_format", "system", "sample_index"} {
+		if v, ok := config[key]; ok {
+			params[key] = v
+		}
+	}
+	return params
+}
+
+// dedupEnabled reports whether a step allows sharing responses; steps that
+// want independent samples set "dedup": false
+func dedupEnabled(config map[string]interface{}) bool {
+	enabled, ok := config["dedup"].(bool)
+	return !ok || enabled
+}
@@ -0,0 +1,52 @@
+package aor
+
+import (
+	"maps"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+)
+
+// applyDegradation switches an LLM task to the cheaper model and shorter
+// prompt its org's budget degradation calls for, returning the provider and
+// model to call. The task gets its own copy of the node config.
+func applyDegradation(task *Task, degradation *cas.Degradation, provider, model string) (string, string) {
+	if degradation == nil {
+		return provider, model
+	}
+	for _, action := range degradation.Actions {
+		llmDegradations.Inc(string(action.Type))
+	}
+
+	cheaper, switchModel := degradation.Action(cas.DegradeCheaperModel)
+	if switchModel {
+		provider, model = cheaper.Provider, cheaper.Model
+	}
+	if task.Node == nil {
+		return provider, model
+	}
+
+	config := maps.Clone(task.Node.Config)
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	if switchModel {
+		config["provider"], config["model"] = provider, model
+	}
+	// A shorter context is a token budget whose prompt allowance is the cap,
+	// so the inputs are compressed like any other over-budget prompt
+	if shorter, ok := degradation.Action(cas.DegradeShorterContext); ok {
+		_, maxTokens := callTokens(config)
+		limit := shorter.MaxContextTokens + maxTokens
+		if budget, err := parseTokenBudget(config); err != nil || budget == nil || budget.MaxTotalTokens > limit {
+			config["token_budget"] = map[string]interface{}{
+				"max_total_tokens": float64(limit),
+				"on_exceed":        string(TokenBudgetCompress),
+			}
+		}
+	}
+
+	node := *task.Node
+	node.Config = config
+	task.Node = &node
+	return provider, model
+}
@@ -0,0 +1,384 @@
+package aor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// TracePurger removes run trace events from the trace store. The control
+// plane does not own trace storage, so deletion requests only erase traces
+// when one is attached.
+type TracePurger interface {
+	PurgeRunTraces(ctx context.Context, orgID uuid.UUID, runIDs []uuid.UUID) (int64, error)
+	CountRunTraces(ctx context.Context, orgID uuid.UUID, runIDs []uuid.UUID) (int64, error)
+}
+
+// SetTracePurger attaches the trace store deletion requests erase traces from
+func (cp *ControlPlane) SetTracePurger(traces TracePurger) {
+	cp.traces = traces
+}
+
+type DeletionStatus string
+
+const (
+	DeletionStatusRunning   DeletionStatus = "running"
+	DeletionStatusCompleted DeletionStatus = "completed"
+	DeletionStatusFailed    DeletionStatus = "failed"
+)
+
+// DeletionRequest erases all data associated with a subject identifier. The
+// subject is a key=value pair matched against run tags and labels and context
+// bundle provenance metadata; only its hash is stored.
+type DeletionRequest struct {
+	ID          uuid.UUID       `json:"id"`
+	OrgID       uuid.UUID       `json:"org_id"`
+	SubjectHash string          `json:"subject_hash"`
+	RequestedBy string          `json:"requested_by,omitempty"`
+	Status      DeletionStatus  `json:"status"`
+	Report      *DeletionReport `json:"report,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+// DeletionReport records what a deletion request erased. Digest is an HMAC
+// of the request ID and every other field under a server-side key, so a
+// stored report can be checked for tampering.
+type DeletionReport struct {
+	SubjectHash      string            `json:"subject_hash"`
+	RunIDs           []uuid.UUID       `json:"run_ids"`
+	ContextBundleIDs []uuid.UUID       `json:"context_bundle_ids"`
+	Runs             int64             `json:"runs"`
+	StepRuns         int64             `json:"step_runs"`
+	CacheEntries     int64             `json:"cache_entries"`
+	ContextBundles   int64             `json:"context_bundles"`
+	Blobs            int64             `json:"blobs"`
+	TraceEvents      int64             `json:"trace_events"`
+	Feedback         int64             `json:"feedback"`
+	DatasetExamples  int64             `json:"dataset_examples"`
+	DeadLetters      int64             `json:"dead_letters"`
+	TracesSkipped    bool              `json:"traces_skipped,omitempty"` // No trace store was attached
+	Retained         map[string]string `json:"retained,omitempty"`       // Stores the request does not erase, with the reason
+	CompletedAt      time.Time         `json:"completed_at"`
+	Digest           string            `json:"digest"`
+}
+
+// DeletionVerification is the result of re-checking a completed deletion
+type DeletionVerification struct {
+	RequestID   uuid.UUID        `json:"request_id"`
+	DigestValid bool             `json:"digest_valid"`
+	Remaining   map[string]int64 `json:"remaining"`
+	Verified    bool             `json:"verified"`
+}
+
+// ParseDeletionSubject splits a key=value subject identifier
+func ParseDeletionSubject(subject string) (string, string, error) {
+	key, value, ok := strings.Cut(subject, "=")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if !ok || key == "" || value == "" {
+		return "", "", fmt.Errorf("subject must be key=value, e.g. user_id=u-123")
+	}
+	return key, value, nil
+}
+
+func hashSubject(key, value string) string {
+	return db.HashContent([]byte(key + "=" + value))
+}
+
+// retainedStores are the stores subject deletion leaves in place
+var retainedStores = map[string]string{
+	"response_cache": "cached LLM responses are keyed by prompt and input hashes rather than runs; they expire with their step's response_cache ttl",
+}
+
+// deletionDigest signs a report together with the request it belongs to
+func deletionDigest(key []byte, requestID uuid.UUID, report DeletionReport) (string, error) {
+	if len(key) == 0 {
+		return "", fmt.Errorf("deletion.digest_key is not set")
+	}
+	report.Digest = ""
+	data, err := json.Marshal(report)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode deletion report: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(requestID.String() + "\n"))
+	mac.Write(data)
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (cp *ControlPlane) deletionDigestKey() []byte {
+	return []byte(cp.cfg.Deletion.DigestKey)
+}
+
+// RequestDeletion erases every run, step, cached step output, content blob,
+// trace event, feedback entry, dead-lettered task and context bundle
+// associated with a subject, and returns the completed request with its
+// report. Runs still in flight must be canceled first.
+func (cp *ControlPlane) RequestDeletion(ctx context.Context, orgID uuid.UUID, subject, requestedBy string) (*DeletionRequest, error) {
+	key, value, err := ParseDeletionSubject(subject)
+	if err != nil {
+		return nil, err
+	}
+	// Without a key the report could not be signed after erasing
+	if len(cp.deletionDigestKey()) == 0 {
+		return nil, fmt.Errorf("deletion requests need deletion.digest_key to sign their reports")
+	}
+
+	req := &DeletionRequest{
+		ID:          uuid.New(),
+		OrgID:       orgID,
+		SubjectHash: hashSubject(key, value),
+		RequestedBy: requestedBy,
+		Status:      DeletionStatusRunning,
+		CreatedAt:   time.Now(),
+	}
+	query := `INSERT INTO deletion_request (id, org_id, subject_hash, requested_by, status, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := cp.db.ExecContext(ctx, query, req.ID, req.OrgID, req.SubjectHash, req.RequestedBy, req.Status, req.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to create deletion request: %w", err)
+	}
+
+	report, err := cp.eraseSubject(ctx, orgID, key, value)
+	if err != nil {
+		req.Status = DeletionStatusFailed
+		req.Error = err.Error()
+	} else {
+		report.SubjectHash = req.SubjectHash
+		report.Digest, err = deletionDigest(cp.deletionDigestKey(), req.ID, *report)
+		if err != nil {
+			return nil, err
+		}
+		req.Status = DeletionStatusCompleted
+		req.Report = report
+	}
+
+	completedAt := time.Now()
+	req.CompletedAt = &completedAt
+	reportJSON, err := json.Marshal(req.Report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode deletion report: %w", err)
+	}
+	if req.Report == nil {
+		reportJSON = []byte("{}")
+	}
+	query = `UPDATE deletion_request SET status = $2, report = $3, error = $4, completed_at = $5 WHERE id = $1`
+	if _, err := cp.db.ExecContext(ctx, query, req.ID, req.Status, reportJSON, req.Error, completedAt); err != nil {
+		return nil, fmt.Errorf("failed to record deletion request: %w", err)
+	}
+
+	if req.Status == DeletionStatusFailed {
+		return req, fmt.Errorf("deletion request %s failed: %s", req.ID, req.Error)
+	}
+	runDataErasures.Add(float64(len(req.Report.RunIDs)), "subject_request")
+	return req, nil
+}
+
+// eraseSubject deletes the subject's data from every store but those in
+// retainedStores. Cache entries, traces and dead letters are erased first
+// since they are found through the run IDs the database transaction removes.
+func (cp *ControlPlane) eraseSubject(ctx context.Context, orgID uuid.UUID, key, value string) (*DeletionReport, error) {
+	runIDs, bundleIDs, err := cp.matchSubject(ctx, orgID, key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	var active int
+	query := `SELECT count(*) FROM workflow_run WHERE id = ANY($1) AND status IN ('queued', 'running')`
+	if err := cp.db.QueryRowContext(ctx, query, pq.Array(runIDs)).Scan(&active); err != nil {
+		return nil, fmt.Errorf("failed to check active runs: %w", err)
+	}
+	if active > 0 {
+		return nil, fmt.Errorf("subject has %d queued or running runs; cancel them first", active)
+	}
+
+	report := &DeletionReport{RunIDs: runIDs, ContextBundleIDs: bundleIDs, Retained: retainedStores}
+
+	if report.CacheEntries, err = cp.scheduler.stepCache.PurgeRuns(ctx, runIDs); err != nil {
+		return nil, err
+	}
+	if cp.traces == nil {
+		report.TracesSkipped = true
+	} else if report.TraceEvents, err = cp.traces.PurgeRunTraces(ctx, orgID, runIDs); err != nil {
+		return nil, err
+	}
+	if report.DeadLetters, err = cp.purgeDeadLetters(ctx, orgID, runIDs); err != nil {
+		return nil, err
+	}
+
+	tx, err := cp.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	refs, err := stepRunRefs(ctx, tx, runIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	deletes := []struct {
+		count *int64
+		query string
+		args  []interface{}
+	}{
+		{&report.Feedback, `DELETE FROM trace_feedback WHERE org_id = $1 AND run_id = ANY($2)`, []interface{}{orgID, pq.Array(runIDs)}},
+		{&report.DatasetExamples, `DELETE FROM finetune_dataset_example WHERE run_id = ANY($1)`, []interface{}{pq.Array(runIDs)}},
+		{&report.StepRuns, `DELETE FROM step_run WHERE workflow_run_id = ANY($1)`, []interface{}{pq.Array(runIDs)}},
+		{&report.Runs, `DELETE FROM workflow_run WHERE id = ANY($1)`, []interface{}{pq.Array(runIDs)}},
+		{&report.ContextBundles, `DELETE FROM context_bundle WHERE org_id = $1 AND id = ANY($2)`, []interface{}{orgID, pq.Array(bundleIDs)}},
+	}
+	for _, d := range deletes {
+		result, err := tx.ExecContext(ctx, d.query, d.args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to erase subject data: %w", err)
+		}
+		*d.count, _ = result.RowsAffected()
+	}
+
+	if report.Blobs, err = deleteOrphanedBlobs(ctx, tx, refs); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit deletion: %w", err)
+	}
+	report.CompletedAt = time.Now().UTC()
+	return report, nil
+}
+
+// matchSubject returns the sorted IDs of the org's runs and context bundles
+// associated with a subject
+func (cp *ControlPlane) matchSubject(ctx context.Context, orgID uuid.UUID, key, value string) ([]uuid.UUID, []uuid.UUID, error) {
+	match, err := json.Marshal(map[string]string{key: value})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode subject: %w", err)
+	}
+
+	runIDs, err := cp.queryIDs(ctx, `SELECT r.id FROM workflow_run r
+			  JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE s.org_id = $1 AND (r.tags @> $2::jsonb OR r.labels @> $2::jsonb)
+			  ORDER BY r.id`, orgID, string(match))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bundleIDs, err := cp.queryIDs(ctx, `SELECT id FROM context_bundle
+			  WHERE org_id = $1 AND provenance->'metadata' @> $2::jsonb
+			  ORDER BY id`, orgID, string(match))
+	if err != nil {
+		return nil, nil, err
+	}
+	return runIDs, bundleIDs, nil
+}
+
+// GetDeletionRequest returns a deletion request and its report
+func (cp *ControlPlane) GetDeletionRequest(ctx context.Context, orgID, requestID uuid.UUID) (*DeletionRequest, error) {
+	req := &DeletionRequest{}
+	var reportJSON []byte
+	var completedAt sql.NullTime
+	query := `SELECT id, org_id, subject_hash, requested_by, status, report, error, created_at, completed_at
+			  FROM deletion_request WHERE org_id = $1 AND id = $2`
+	err := cp.db.QueryRowContext(ctx, query, orgID, requestID).Scan(
+		&req.ID, &req.OrgID, &req.SubjectHash, &req.RequestedBy, &req.Status, &reportJSON, &req.Error, &req.CreatedAt, &completedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("deletion request %s not found", requestID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deletion request: %w", err)
+	}
+	if completedAt.Valid {
+		req.CompletedAt = &completedAt.Time
+	}
+	if req.Status == DeletionStatusCompleted {
+		req.Report = &DeletionReport{}
+		if err := json.Unmarshal(reportJSON, req.Report); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal deletion report: %w", err)
+		}
+	}
+	return req, nil
+}
+
+// VerifyDeletion checks a completed deletion request: that the subject given
+// is the one it was made for, that its report is unaltered, and that no data
+// associated with the subject remains in any store
+func (cp *ControlPlane) VerifyDeletion(ctx context.Context, orgID, requestID uuid.UUID, subject string) (*DeletionVerification, error) {
+	key, value, err := ParseDeletionSubject(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := cp.GetDeletionRequest(ctx, orgID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if req.SubjectHash != hashSubject(key, value) {
+		return nil, fmt.Errorf("subject does not match deletion request %s", requestID)
+	}
+	if req.Status != DeletionStatusCompleted {
+		return nil, fmt.Errorf("deletion request %s is %s", requestID, req.Status)
+	}
+
+	digest, err := deletionDigest(cp.deletionDigestKey(), req.ID, *req.Report)
+	if err != nil {
+		return nil, err
+	}
+	verification := &DeletionVerification{
+		RequestID:   req.ID,
+		DigestValid: hmac.Equal([]byte(digest), []byte(req.Report.Digest)),
+		Remaining:   make(map[string]int64),
+	}
+
+	runIDs, bundleIDs, err := cp.matchSubject(ctx, orgID, key, value)
+	if err != nil {
+		return nil, err
+	}
+	verification.Remaining["runs"] = int64(len(runIDs))
+	verification.Remaining["context_bundles"] = int64(len(bundleIDs))
+
+	erased := req.Report.RunIDs
+	var steps, feedback int64
+	query := `SELECT count(*) FROM step_run WHERE workflow_run_id = ANY($1)`
+	if err := cp.db.QueryRowContext(ctx, query, pq.Array(erased)).Scan(&steps); err != nil {
+		return nil, fmt.Errorf("failed to count step runs: %w", err)
+	}
+	query = `SELECT count(*) FROM trace_feedback WHERE org_id = $1 AND run_id = ANY($2)`
+	if err := cp.db.QueryRowContext(ctx, query, orgID, pq.Array(erased)).Scan(&feedback); err != nil {
+		return nil, fmt.Errorf("failed to count feedback: %w", err)
+	}
+	verification.Remaining["step_runs"] = steps
+	verification.Remaining["feedback"] = feedback
+
+	if verification.Remaining["cache_entries"], err = cp.scheduler.stepCache.CountRuns(ctx, erased); err != nil {
+		return nil, err
+	}
+	deadLetters, err := cp.runDeadLetters(ctx, orgID, erased)
+	if err != nil {
+		return nil, err
+	}
+	verification.Remaining["dead_letters"] = int64(len(deadLetters))
+	if cp.traces != nil {
+		if verification.Remaining["trace_events"], err = cp.traces.CountRunTraces(ctx, orgID, erased); err != nil {
+			return nil, err
+		}
+	}
+
+	verification.Verified = verification.DigestValid
+	for _, n := range verification.Remaining {
+		if n > 0 {
+			verification.Verified = false
+		}
+	}
+	return verification, nil
+}
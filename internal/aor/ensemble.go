@@ -0,0 +1,540 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnsembleStrategy selects one candidate from an ensemble's outputs
+type EnsembleStrategy string
+
+const (
+	EnsembleMajorityVote EnsembleStrategy = "majority_vote"
+	EnsembleJudge        EnsembleStrategy = "judge"
+	EnsembleBestScore    EnsembleStrategy = "best_score"
+)
+
+// maxEnsembleMembers bounds the fan-out of a single ensemble step
+const maxEnsembleMembers = 10
+
+// ensembleConfigKeys are consumed by the ensemble itself rather than passed to members
+var ensembleConfigKeys = []string{"members", "samples", "strategy", "judge", "score_key", "min_successes"}
+
+// EnsembleMember is one provider/model the prompt is fanned out to, with
+// optional per-member config overrides such as temperature
+type EnsembleMember struct {
+	Provider  string                 `json:"provider"`
+	Model     string                 `json:"model"`
+	Overrides map[string]interface{} `json:"overrides,omitempty"`
+}
+
+// EnsembleConfig is the parsed config of an ensemble step
+type EnsembleConfig struct {
+	Members      []EnsembleMember `json:"members"`
+	Strategy     EnsembleStrategy `json:"strategy"`
+	Judge        *EnsembleMember  `json:"judge,omitempty"`
+	ScoreKey     string           `json:"score_key,omitempty"`
+	MinSuccesses int              `json:"min_successes"`
+}
+
+// EnsembleCandidate records one member's output and how it fared in selection
+type EnsembleCandidate struct {
+	Provider  string                 `json:"provider"`
+	Model     string                 `json:"model"`
+	Output    map[string]interface{} `json:"output,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	CostCents int64                  `json:"cost_cents"`
+	LatencyMs int64                  `json:"latency_ms"`
+	Votes     int                    `json:"votes,omitempty"`
+	Score     float64                `json:"score,omitempty"`
+}
+
+// EnsembleSelection is the outcome of resolving an ensemble
+type EnsembleSelection struct {
+	Strategy  EnsembleStrategy `json:"strategy"`
+	Selected  int              `json:"selected"`
+	Rationale string           `json:"rationale"`
+}
+
+// defaultSampleTemperature gives best-of-N samples some diversity when the step sets no temperature
+const defaultSampleTemperature = 0.7
+
+// llmSampleCount returns how many completions an LLM step samples
+func llmSampleCount(config map[string]interface{}) int {
+	if n, ok := config["samples"].(float64); ok && n > 1 {
+		return int(n)
+	}
+	if n, ok := config["samples"].(int); ok && n > 1 {
+		return n
+	}
+	return 1
+}
+
+// parseSamplingConfig turns an LLM step with samples > 1 into an ensemble of
+// identical members that differ only in their sample index
+func parseSamplingConfig(config map[string]interface{}) (*EnsembleConfig, error) {
+	samples := llmSampleCount(config)
+	if samples < 2 {
+		return nil, fmt.Errorf("best-of-N sampling requires samples of at least 2")
+	}
+	if samples > maxEnsembleMembers {
+		return nil, fmt.Errorf("best-of-N sampling allows at most %d samples, got %d", maxEnsembleMembers, samples)
+	}
+
+	provider, model := stepProviderModel(config)
+	members := make([]interface{}, 0, samples)
+	for i := 0; i < samples; i++ {
+		member := map[string]interface{}{"provider": provider, "model": model, "sample_index": float64(i)}
+		if _, ok := config["temperature"]; !ok {
+			member["temperature"] = defaultSampleTemperature
+		}
+		members = append(members, member)
+	}
+
+	ensemble := make(map[string]interface{}, len(config)+1)
+	for k, v := range config {
+		ensemble[k] = v
+	}
+	ensemble["members"] = members
+
+	return parseEnsembleConfig(ensemble)
+}
+
+// parseEnsembleConfig reads and validates an ensemble step's config
+func parseEnsembleConfig(config map[string]interface{}) (*EnsembleConfig, error) {
+	cfg := &EnsembleConfig{Strategy: EnsembleMajorityVote, ScoreKey: "score", MinSuccesses: 1}
+
+	rawMembers, ok := config["members"].([]interface{})
+	if !ok || len(rawMembers) < 2 {
+		return nil, fmt.Errorf("ensemble requires at least 2 members")
+	}
+	if len(rawMembers) > maxEnsembleMembers {
+		return nil, fmt.Errorf("ensemble allows at most %d members, got %d", maxEnsembleMembers, len(rawMembers))
+	}
+	for i, raw := range rawMembers {
+		member, err := parseEnsembleMember(raw)
+		if err != nil {
+			return nil, fmt.Errorf("ensemble member %d: %w", i, err)
+		}
+		cfg.Members = append(cfg.Members, *member)
+	}
+
+	if strategy, ok := config["strategy"].(string); ok && strategy != "" {
+		cfg.Strategy = EnsembleStrategy(strategy)
+	}
+	switch cfg.Strategy {
+	case EnsembleMajorityVote, EnsembleBestScore:
+	case EnsembleJudge:
+		raw, ok := config["judge"]
+		if !ok {
+			return nil, fmt.Errorf("judge strategy requires a judge model")
+		}
+		judge, err := parseEnsembleMember(raw)
+		if err != nil {
+			return nil, fmt.Errorf("ensemble judge: %w", err)
+		}
+		cfg.Judge = judge
+	default:
+		return nil, fmt.Errorf("unknown ensemble strategy %q (use majority_vote, judge or best_score)", cfg.Strategy)
+	}
+
+	if key, ok := config["score_key"].(string); ok && key != "" {
+		cfg.ScoreKey = key
+	}
+	if v, ok := config["min_successes"].(float64); ok {
+		n := int(v)
+		if n < 1 || n > len(cfg.Members) {
+			return nil, fmt.Errorf("min_successes must be between 1 and %d", len(cfg.Members))
+		}
+		cfg.MinSuccesses = n
+	}
+
+	return cfg, nil
+}
+
+func parseEnsembleMember(raw interface{}) (*EnsembleMember, error) {
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an object with provider and model")
+	}
+
+	member := &EnsembleMember{Overrides: make(map[string]interface{})}
+	member.Provider, _ = fields["provider"].(string)
+	member.Model, _ = fields["model"].(string)
+	if member.Provider == "" || member.Model == "" {
+		return nil, fmt.Errorf("provider and model are required")
+	}
+	for k, v := range fields {
+		if k != "provider" && k != "model" {
+			member.Overrides[k] = v
+		}
+	}
+
+	return member, nil
+}
+
+// memberConfig builds the LLM step config a member runs with
+func (m EnsembleMember) memberConfig(base map[string]interface{}) map[string]interface{} {
+	config := make(map[string]interface{}, len(base)+len(m.Overrides)+2)
+	for k, v := range base {
+		config[k] = v
+	}
+	for _, key := range ensembleConfigKeys {
+		delete(config, key)
+	}
+	for k, v := range m.Overrides {
+		config[k] = v
+	}
+	config["provider"] = m.Provider
+	config["model"] = m.Model
+	return config
+}
+
+// EnsembleExecutor fans an LLM prompt out to several models and resolves
+// their outputs into one result
+type EnsembleExecutor struct {
+	worker *Worker
+	llm    *LLMExecutor
+}
+
+func NewEnsembleExecutor(worker *Worker, llm *LLMExecutor) *EnsembleExecutor {
+	return &EnsembleExecutor{worker: worker, llm: llm}
+}
+
+func (e *EnsembleExecutor) Execute(ctx context.Context, task *Task) (*TaskResult, error) {
+	start := time.Now()
+
+	if task.Node == nil {
+		return nil, fmt.Errorf("ensemble task %s has no node config", task.ID)
+	}
+	cfg, err := parseEnsembleConfig(task.Node.Config)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Executing ensemble task %s across %d models with %s", task.ID, len(cfg.Members), cfg.Strategy)
+
+	return e.resolve(ctx, task, cfg, "ensemble", start)
+}
+
+// resolve runs every member concurrently and selects one output, recording
+// all candidates and the selection rationale under outputKey
+func (e *EnsembleExecutor) resolve(ctx context.Context, task *Task, cfg *EnsembleConfig, outputKey string, start time.Time) (*TaskResult, error) {
+	candidates := make([]EnsembleCandidate, len(cfg.Members))
+	var wg sync.WaitGroup
+	for i, member := range cfg.Members {
+		wg.Add(1)
+		go func(i int, member EnsembleMember) {
+			defer wg.Done()
+			candidates[i] = e.runMember(ctx, task, member)
+		}(i, member)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	var costCents int64
+	for _, c := range candidates {
+		costCents += c.CostCents
+		if c.Error == "" {
+			succeeded++
+		}
+	}
+	if succeeded < cfg.MinSuccesses {
+		return nil, fmt.Errorf("%s needs %d successful members, got %d", outputKey, cfg.MinSuccesses, succeeded)
+	}
+
+	var selection EnsembleSelection
+	var judgeResult *TaskResult
+	switch cfg.Strategy {
+	case EnsembleJudge:
+		selection, judgeResult = e.judge(ctx, task, cfg, candidates)
+		if judgeResult != nil {
+			costCents += judgeResult.CostCents
+		}
+	case EnsembleBestScore:
+		selection = selectBestScore(candidates, cfg.ScoreKey)
+	default:
+		selection = selectMajority(candidates)
+	}
+
+	winner := candidates[selection.Selected]
+	output := make(map[string]interface{}, len(winner.Output)+1)
+	for k, v := range winner.Output {
+		output[k] = v
+	}
+	output[outputKey] = map[string]interface{}{
+		"strategy":   selection.Strategy,
+		"selected":   selection.Selected,
+		"rationale":  selection.Rationale,
+		"candidates": candidates,
+	}
+
+	ensembleSelections.Inc(string(cfg.Strategy), winner.Provider+"/"+winner.Model)
+
+	return &TaskResult{
+		TaskID:     task.ID,
+		Status:     TaskStatusSucceeded,
+		Output:     output,
+		CostCents:  costCents,
+		Provider:   winner.Provider,
+		Model:      winner.Model,
+		ExecutedAt: time.Now(),
+		Duration:   time.Since(start),
+	}, nil
+}
+
+func (e *EnsembleExecutor) CanHandle(stepType string) bool {
+	return stepType == string(ExecutorTypeEnsemble)
+}
+
+// runMember runs the prompt against one member through the LLM executor so
+// model policy, deduplication and replay cassettes all apply
+func (e *EnsembleExecutor) runMember(ctx context.Context, task *Task, member EnsembleMember) EnsembleCandidate {
+	sub := *task
+	sub.Node = &Node{ID: task.Node.ID, Type: string(ExecutorTypeLLM), Config: member.memberConfig(task.Node.Config)}
+
+	start := time.Now()
+	result, err := e.llm.Execute(ctx, &sub)
+	if result == nil || !result.Replayed {
+		e.worker.reportTelemetry(ctx, &sub, result, err, time.Since(start))
+	}
+
+	candidate := EnsembleCandidate{Provider: member.Provider, Model: member.Model, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		candidate.Error = err.Error()
+		return candidate
+	}
+	candidate.Output = result.Output
+	candidate.CostCents = result.CostCents
+	return candidate
+}
+
+// judge asks the judge model to pick a candidate, falling back to a majority
+// vote when the judge fails or its verdict cannot be parsed
+func (e *EnsembleExecutor) judge(ctx context.Context, task *Task, cfg *EnsembleConfig, candidates []EnsembleCandidate) (EnsembleSelection, *TaskResult) {
+	options := make([]interface{}, 0, len(candidates))
+	for i, c := range candidates {
+		if c.Error != "" {
+			continue
+		}
+		options = append(options, map[string]interface{}{"index": i + 1, "model": c.Provider + "/" + c.Model, "content": candidateText(c)})
+	}
+
+	sub := *task
+	sub.Inputs = map[string]interface{}{
+		"task":       task.Inputs,
+		"candidates": options,
+		"instructions": "Pick the best candidate answer. Reply with its index on the first line, " +
+			"followed by a one-sentence reason.",
+	}
+	judgeConfig := cfg.Judge.memberConfig(task.Node.Config)
+	judgeConfig["prompt_ref"] = "ensemble-judge"
+	sub.Node = &Node{ID: task.Node.ID + ".judge", Type: string(ExecutorTypeLLM), Config: judgeConfig}
+
+	result, err := e.llm.Execute(ctx, &sub)
+	if err != nil {
+		fallback := selectMajority(candidates)
+		fallback.Strategy = EnsembleJudge
+		fallback.Rationale = fmt.Sprintf("judge %s/%s failed (%v); %s", cfg.Judge.Provider, cfg.Judge.Model, err, fallback.Rationale)
+		return fallback, nil
+	}
+
+	verdict := candidateText(EnsembleCandidate{Output: result.Output})
+	selection, ok := parseJudgeVerdict(verdict, candidates)
+	if !ok {
+		fallback := selectMajority(candidates)
+		fallback.Strategy = EnsembleJudge
+		fallback.Rationale = fmt.Sprintf("judge verdict %q named no valid candidate; %s", truncateRationale(verdict), fallback.Rationale)
+		return fallback, result
+	}
+	selection.Rationale = fmt.Sprintf("judge %s/%s: %s", cfg.Judge.Provider, cfg.Judge.Model, selection.Rationale)
+
+	return selection, result
+}
+
+var judgeIndexPattern = regexp.MustCompile(`\d+`)
+
+// parseJudgeVerdict reads the 1-based candidate index the judge chose
+func parseJudgeVerdict(verdict string, candidates []EnsembleCandidate) (EnsembleSelection, bool) {
+	match := judgeIndexPattern.FindString(verdict)
+	if match == "" {
+		return EnsembleSelection{}, false
+	}
+	index, err := strconv.Atoi(match)
+	if err != nil || index < 1 || index > len(candidates) || candidates[index-1].Error != "" {
+		return EnsembleSelection{}, false
+	}
+
+	reason := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(verdict), match))
+	reason = strings.TrimLeft(reason, ".:)- \n")
+	if reason == "" {
+		reason = "no reason given"
+	}
+
+	return EnsembleSelection{Strategy: EnsembleJudge, Selected: index - 1, Rationale: fmt.Sprintf("chose candidate %d: %s", index, truncateRationale(reason))}, true
+}
+
+// selectMajority picks the answer most members agree on; ties go to the
+// earliest listed member
+func selectMajority(candidates []EnsembleCandidate) EnsembleSelection {
+	groups := make(map[string][]int)
+	order := make([]string, 0)
+	for i, c := range candidates {
+		if c.Error != "" {
+			continue
+		}
+		key := normalizeAnswer(candidateText(c))
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	best := ""
+	for _, key := range order {
+		if len(groups[key]) > len(groups[best]) {
+			best = key
+		}
+	}
+
+	succeeded := 0
+	for _, members := range groups {
+		succeeded += len(members)
+	}
+	for _, i := range groups[best] {
+		candidates[i].Votes = len(groups[best])
+	}
+
+	selected := groups[best][0]
+	rationale := fmt.Sprintf("%d of %d successful candidates agreed", len(groups[best]), succeeded)
+	if len(groups[best]) == 1 && succeeded > 1 {
+		rationale = fmt.Sprintf("no agreement among %d candidates; chose the first listed member", succeeded)
+	}
+
+	return EnsembleSelection{Strategy: EnsembleMajorityVote, Selected: selected, Rationale: rationale}
+}
+
+// selectBestScore picks the candidate with the highest self-reported score at
+// scoreKey. Without scores, candidates are ranked by their average similarity
+// to the other answers, which favors the consensus response.
+func selectBestScore(candidates []EnsembleCandidate, scoreKey string) EnsembleSelection {
+	scored := false
+	for i, c := range candidates {
+		if c.Error != "" {
+			continue
+		}
+		if score, ok := outputScore(c.Output, scoreKey); ok {
+			candidates[i].Score = score
+			scored = true
+		}
+	}
+
+	method := fmt.Sprintf("highest %s", scoreKey)
+	if !scored {
+		method = "highest agreement with the other candidates"
+		for i, c := range candidates {
+			if c.Error != "" {
+				continue
+			}
+			total, peers := 0.0, 0
+			for j, other := range candidates {
+				if i == j || other.Error != "" {
+					continue
+				}
+				total += answerSimilarity(candidateText(c), candidateText(other))
+				peers++
+			}
+			if peers > 0 {
+				candidates[i].Score = total / float64(peers)
+			}
+		}
+	}
+
+	ranked := make([]int, 0, len(candidates))
+	for i, c := range candidates {
+		if c.Error == "" {
+			ranked = append(ranked, i)
+		}
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return candidates[ranked[a]].Score > candidates[ranked[b]].Score
+	})
+
+	selected := ranked[0]
+	return EnsembleSelection{
+		Strategy:  EnsembleBestScore,
+		Selected:  selected,
+		Rationale: fmt.Sprintf("%s (%.3f) among %d candidates", method, candidates[selected].Score, len(ranked)),
+	}
+}
+
+// candidateText extracts the answer text from a normalized LLM output
+func candidateText(c EnsembleCandidate) string {
+	for _, key := range []string{"content", "text", "result"} {
+		if text, ok := c.Output[key].(string); ok {
+			return text
+		}
+	}
+	return fmt.Sprint(c.Output)
+}
+
+func outputScore(output map[string]interface{}, key string) (float64, bool) {
+	switch v := output[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func normalizeAnswer(text string) string {
+	return strings.TrimRight(strings.Join(strings.Fields(strings.ToLower(text)), " "), ".!?")
+}
+
+// answerSimilarity is the word-level Jaccard similarity of two answers
+func answerSimilarity(a, b string) float64 {
+	wordsA := strings.Fields(normalizeAnswer(a))
+	wordsB := strings.Fields(normalizeAnswer(b))
+	if len(wordsA) == 0 && len(wordsB) == 0 {
+		return 1
+	}
+
+	set := make(map[string]bool, len(wordsA))
+	for _, w := range wordsA {
+		set[w] = true
+	}
+	union := len(set)
+	intersection := 0
+	seen := make(map[string]bool, len(wordsB))
+	for _, w := range wordsB {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if set[w] {
+			intersection++
+		} else {
+			union++
+		}
+	}
+
+	return float64(intersection) / float64(union)
+}
+
+func truncateRationale(s string) string {
+	const max = 200
+	if len(s) > max {
+		return s[:max] + "..."
+	}
+	return s
+}
@@ -0,0 +1,148 @@
+package aor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+)
+
+// defaultEnvironment is used when a run does not select a profile and the spec defines one by this name
+const defaultEnvironment = "default"
+
+var environmentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// EnvironmentProfile holds the settings a spec uses in one environment such
+// as dev, staging or prod
+type EnvironmentProfile struct {
+	Env         map[string]string    `json:"env,omitempty"`
+	Providers   *ProviderConstraints `json:"providers,omitempty"`
+	BudgetCents int64                `json:"budget_cents,omitempty"`
+
+	// MockProvider answers every LLM call of the environment's runs from the
+	// built-in mock, e.g. mock://?latency=50ms&error_rate=0.05, for hermetic CI
+	MockProvider string `json:"mock_provider,omitempty"`
+}
+
+// ProviderConstraints restrict the provider/model pairs LLM steps may use.
+// Entries use the same "provider/model" glob syntax as org model policies.
+type ProviderConstraints struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// ResolveEnvironment returns the profile a run executes with. An empty name
+// selects the "default" profile if the spec has one.
+func (spec *WorkflowSpec) ResolveEnvironment(name string) (string, *EnvironmentProfile, error) {
+	envs := spec.Metadata.Environments
+	if name == "" {
+		if profile, ok := envs[defaultEnvironment]; ok {
+			return defaultEnvironment, &profile, nil
+		}
+		return "", nil, nil
+	}
+
+	profile, ok := envs[name]
+	if !ok {
+		if len(envs) == 0 {
+			return "", nil, fmt.Errorf("workflow %s does not define environment profiles", spec.Name)
+		}
+		return "", nil, fmt.Errorf("workflow %s has no environment %q (available: %s)", spec.Name, name, strings.Join(environmentNames(envs), ", "))
+	}
+
+	return name, &profile, nil
+}
+
+// ValidateEnvironments checks profile names, env keys and provider constraints
+func ValidateEnvironments(envs map[string]EnvironmentProfile) error {
+	for _, name := range environmentNames(envs) {
+		profile := envs[name]
+		if !environmentNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment name %q", name)
+		}
+		for key := range profile.Env {
+			if key == "" || strings.ContainsAny(key, "= ") {
+				return fmt.Errorf("environment %s: invalid env var name %q", name, key)
+			}
+		}
+		if profile.BudgetCents < 0 {
+			return fmt.Errorf("environment %s: budget_cents cannot be negative", name)
+		}
+		if policy := profile.modelPolicy(); policy != nil {
+			if err := policy.Validate(); err != nil {
+				return fmt.Errorf("environment %s: %w", name, err)
+			}
+		}
+		if profile.MockProvider != "" {
+			if _, err := ParseMockProvider(profile.MockProvider); err != nil {
+				return fmt.Errorf("environment %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateEnvironmentProviders checks every LLM step against the profile's provider constraints
+func ValidateEnvironmentProviders(spec *WorkflowSpec, envName string, profile *EnvironmentProfile) error {
+	policy := profile.modelPolicy()
+	if policy == nil {
+		return nil
+	}
+
+	violations := make([]string, 0)
+	for _, step := range spec.DAG.Steps {
+		for _, target := range stepModelTargets(step) {
+			if v := policy.Check(target[0], target[1]); v != nil {
+				violations = append(violations, fmt.Sprintf("step %s: model %s/%s %s", step.ID, target[0], target[1], v.Reason))
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("workflow %s violates %s environment provider constraints: %s", spec.Name, envName, strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+func (p *EnvironmentProfile) modelPolicy() *cas.ModelPolicy {
+	if p == nil || p.Providers == nil || (len(p.Providers.Allow) == 0 && len(p.Providers.Deny) == 0) {
+		return nil
+	}
+	return &cas.ModelPolicy{Allow: p.Providers.Allow, Deny: p.Providers.Deny}
+}
+
+// stepConfigWithEnv layers a run's environment variables under the step's own env
+func stepConfigWithEnv(config map[string]interface{}, runEnv map[string]interface{}) map[string]interface{} {
+	if len(runEnv) == 0 {
+		return config
+	}
+
+	merged := make(map[string]interface{}, len(config)+1)
+	for k, v := range config {
+		merged[k] = v
+	}
+
+	env := make(map[string]interface{}, len(runEnv))
+	for k, v := range runEnv {
+		env[k] = v
+	}
+	if stepEnv, ok := config["env"].(map[string]interface{}); ok {
+		for k, v := range stepEnv {
+			env[k] = v
+		}
+	}
+	merged["env"] = env
+
+	return merged
+}
+
+func environmentNames(envs map[string]EnvironmentProfile) []string {
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
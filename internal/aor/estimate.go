@@ -0,0 +1,279 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultLLMCallCostCents is assumed for models without configured pricing
+	defaultLLMCallCostCents = 15
+	// defaultPromptTokens and defaultMaxTokens size a call when the step doesn't say
+	defaultPromptTokens = 500
+	defaultMaxTokens    = 500
+)
+
+// ModelPricing is a model's per-token price in dollars
+type ModelPricing struct {
+	PromptPerToken     float64 `json:"prompt_per_token"`
+	CompletionPerToken float64 `json:"completion_per_token"`
+	PerMinute          float64 `json:"per_minute,omitempty"` // Audio models bill by the minute
+}
+
+// StepCostEstimate is the up-front cost of one step, including any
+// multiplication from sampling, ensembles or judge calls
+type StepCostEstimate struct {
+	StepID           string   `json:"step_id"`
+	Type             string   `json:"type"`
+	Calls            int      `json:"calls"`
+	CostCentsPerCall int64    `json:"cost_cents_per_call"`
+	CostCents        int64    `json:"cost_cents"`
+	Notes            []string `json:"notes,omitempty"`
+}
+
+// RunCostEstimate is the projected cost of a run before it starts
+type RunCostEstimate struct {
+	WorkflowName string             `json:"workflow_name"`
+	Environment  string             `json:"environment,omitempty"`
+	Steps        []StepCostEstimate `json:"steps"`
+	TotalCents   int64              `json:"total_cents"`
+}
+
+// RunPreview shows what a submission would cost against the org budget
+// before it is made
+type RunPreview struct {
+	Estimate       *RunCostEstimate  `json:"estimate"`
+	Budget         *cas.BudgetStatus `json:"budget"`
+	RunsAffordable *int64            `json:"runs_affordable,omitempty"` // Nil when the estimate is zero
+	MaxCostCents   int64             `json:"max_cost_cents,omitempty"`
+	ExceedsMaxCost bool              `json:"exceeds_max_cost"`
+}
+
+// EstimateRunCost projects what a run request will cost, using the org's
+// configured provider pricing
+func (cp *ControlPlane) EstimateRunCost(ctx context.Context, req *RunRequest) (*RunCostEstimate, error) {
+	_, estimate, err := cp.estimateRun(ctx, req)
+	return estimate, err
+}
+
+// PreviewRun estimates a run and reports how many such runs fit in what is
+// left of the org's current budget
+func (cp *ControlPlane) PreviewRun(ctx context.Context, req *RunRequest) (*RunPreview, error) {
+	spec, estimate, err := cp.estimateRun(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := cp.budgets.GetStatus(ctx, spec.OrgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budget status: %w", err)
+	}
+
+	return newRunPreview(estimate, status, req.MaxCostCents), nil
+}
+
+func (cp *ControlPlane) estimateRun(ctx context.Context, req *RunRequest) (*WorkflowSpec, *RunCostEstimate, error) {
+	spec, err := cp.getWorkflowSpec(ctx, req.WorkflowName, req.WorkflowVersion)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get workflow spec: %w", err)
+	}
+
+	envName, _, err := spec.ResolveEnvironment(req.Environment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pricing, err := cp.modelPricing(ctx, spec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	estimate := EstimateSpecCost(spec, pricing)
+	estimate.Environment = envName
+	return spec, estimate, nil
+}
+
+func newRunPreview(estimate *RunCostEstimate, status *cas.BudgetStatus, maxCostCents int64) *RunPreview {
+	preview := &RunPreview{
+		Estimate:       estimate,
+		Budget:         status,
+		MaxCostCents:   maxCostCents,
+		ExceedsMaxCost: maxCostCents > 0 && estimate.TotalCents > maxCostCents,
+	}
+	if estimate.TotalCents > 0 {
+		runs := max(status.RemainingCents, 0) / estimate.TotalCents
+		preview.RunsAffordable = &runs
+	}
+	return preview
+}
+
+// checkEstimatedCost rejects runs whose projected cost exceeds their own
+// budget or the submitter's cost ceiling
+func checkEstimatedCost(estimate *RunCostEstimate, budgetCents, maxCostCents int64) error {
+	if budgetCents > 0 && estimate.TotalCents > budgetCents {
+		return fmt.Errorf("estimated run cost %d¢ exceeds run budget %d¢ (%s)", estimate.TotalCents, budgetCents, describeEstimate(estimate))
+	}
+	if maxCostCents > 0 && estimate.TotalCents > maxCostCents {
+		return fmt.Errorf("estimated run cost %d¢ exceeds max cost %d¢ (%s)", estimate.TotalCents, maxCostCents, describeEstimate(estimate))
+	}
+	return nil
+}
+
+func (cp *ControlPlane) modelPricing(ctx context.Context, spec *WorkflowSpec) (map[string]ModelPricing, error) {
+	return loadModelPricing(ctx, cp.db, spec.OrgID)
+}
+
+// loadModelPricing reads the per-token prices of an org's enabled providers
+func loadModelPricing(ctx context.Context, pg *db.PostgresDB, orgID uuid.UUID) (map[string]ModelPricing, error) {
+	query := `SELECT provider_name, model_name, cost_per_token_prompt, cost_per_token_completion, COALESCE(cost_per_minute, 0)
+			  FROM provider_config WHERE org_id = $1 AND enabled = true`
+
+	rows, err := pg.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query model pricing: %w", err)
+	}
+	defer rows.Close()
+
+	pricing := make(map[string]ModelPricing)
+	for rows.Next() {
+		var provider, model string
+		var price ModelPricing
+		if err := rows.Scan(&provider, &model, &price.PromptPerToken, &price.CompletionPerToken, &price.PerMinute); err != nil {
+			return nil, fmt.Errorf("failed to scan model pricing: %w", err)
+		}
+		pricing[pricingKey(provider, model)] = price
+	}
+
+	return pricing, rows.Err()
+}
+
+// EstimateSpecCost projects the cost of one run of a spec. Best-of-N steps
+// cost one call per sample, ensembles one call per member, and judge
+// strategies add the judge call. Models missing from pricing are assumed to
+// cost defaultLLMCallCostCents per call.
+func EstimateSpecCost(spec *WorkflowSpec, pricing map[string]ModelPricing) *RunCostEstimate {
+	estimate := &RunCostEstimate{WorkflowName: spec.Name, Steps: make([]StepCostEstimate, 0)}
+
+	for _, step := range spec.DAG.Steps {
+		stepEstimate := StepCostEstimate{StepID: step.ID, Type: step.Type}
+
+		switch ExecutorType(step.Type) {
+		case ExecutorTypeLLM, ExecutorTypeRAGGenerate:
+			provider, model := stepProviderModel(step.Config)
+			samples := llmSampleCount(step.Config)
+			perCall, note := estimateCallCost(step.Config, provider, model, pricing)
+			stepEstimate.Calls = samples
+			stepEstimate.CostCentsPerCall = perCall
+			stepEstimate.CostCents = perCall * int64(samples)
+			stepEstimate.Notes = appendNote(stepEstimate.Notes, note)
+			if samples > 1 {
+				stepEstimate.Notes = append(stepEstimate.Notes, fmt.Sprintf("best of %d samples multiplies cost by %d", samples, samples))
+				stepEstimate.addJudge(step.Config, pricing, samples)
+			}
+		case ExecutorTypeEnsemble:
+			cfg, err := parseEnsembleConfig(step.Config)
+			if err != nil {
+				stepEstimate.Notes = append(stepEstimate.Notes, fmt.Sprintf("not estimated: %v", err))
+				break
+			}
+			for _, member := range cfg.Members {
+				cost, note := estimateCallCost(member.memberConfig(step.Config), member.Provider, member.Model, pricing)
+				stepEstimate.Calls++
+				stepEstimate.CostCents += cost
+				stepEstimate.Notes = appendNote(stepEstimate.Notes, note)
+			}
+			stepEstimate.CostCentsPerCall = stepEstimate.CostCents / int64(stepEstimate.Calls)
+			stepEstimate.Notes = append(stepEstimate.Notes, fmt.Sprintf("ensemble of %d models", len(cfg.Members)))
+			stepEstimate.addJudge(step.Config, pricing, len(cfg.Members))
+		case ExecutorTypeTranscribe:
+			cost, note := estimateTranscriptionCost(step.Config, pricing)
+			stepEstimate.Calls = 1
+			stepEstimate.CostCentsPerCall = cost
+			stepEstimate.CostCents = cost
+			stepEstimate.Notes = appendNote(stepEstimate.Notes, note)
+		default:
+			continue
+		}
+
+		estimate.Steps = append(estimate.Steps, stepEstimate)
+		estimate.TotalCents += stepEstimate.CostCents
+	}
+
+	return estimate
+}
+
+// addJudge adds the judge call of a judge-resolved step
+func (se *StepCostEstimate) addJudge(config map[string]interface{}, pricing map[string]ModelPricing, candidates int) {
+	if strategy, _ := config["strategy"].(string); EnsembleStrategy(strategy) != EnsembleJudge {
+		return
+	}
+	judge, err := parseEnsembleMember(config["judge"])
+	if err != nil {
+		return
+	}
+
+	// The judge reads every candidate, so its prompt grows with the fan-out
+	judgeConfig := judge.memberConfig(config)
+	promptTokens, maxTokens := callTokens(config)
+	judgeConfig["estimated_prompt_tokens"] = float64(promptTokens + candidates*maxTokens)
+	cost, note := estimateCallCost(judgeConfig, judge.Provider, judge.Model, pricing)
+	se.Calls++
+	se.CostCents += cost
+	se.Notes = appendNote(se.Notes, note)
+	se.Notes = append(se.Notes, fmt.Sprintf("judge %s/%s adds %d¢", judge.Provider, judge.Model, cost))
+}
+
+// estimateCallCost prices one call from the step's token hints
+func estimateCallCost(config map[string]interface{}, provider, model string, pricing map[string]ModelPricing) (int64, string) {
+	price, ok := pricing[pricingKey(provider, model)]
+	if !ok {
+		return defaultLLMCallCostCents, fmt.Sprintf("no pricing for %s/%s; assumed %d¢ per call", provider, model, defaultLLMCallCostCents)
+	}
+
+	promptTokens, maxTokens := callTokens(config)
+	dollars := float64(promptTokens)*price.PromptPerToken + float64(maxTokens)*price.CompletionPerToken
+	return int64(math.Ceil(dollars * 100)), ""
+}
+
+// callTokens reads a call's expected prompt size and completion limit,
+// including any reasoning budget
+func callTokens(config map[string]interface{}) (int, int) {
+	promptTokens, maxTokens := defaultPromptTokens, defaultMaxTokens
+	if v, ok := config["estimated_prompt_tokens"].(float64); ok && v > 0 {
+		promptTokens = int(v)
+	}
+	if v, ok := config["max_tokens"].(float64); ok && v > 0 {
+		maxTokens = int(v)
+	}
+	return promptTokens, maxTokens + reasoningBudget(config)
+}
+
+func describeEstimate(estimate *RunCostEstimate) string {
+	parts := make([]string, 0, len(estimate.Steps))
+	for _, step := range estimate.Steps {
+		parts = append(parts, fmt.Sprintf("%s: %d calls, %d¢", step.StepID, step.Calls, step.CostCents))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func appendNote(notes []string, note string) []string {
+	if note == "" {
+		return notes
+	}
+	for _, existing := range notes {
+		if existing == note {
+			return notes
+		}
+	}
+	return append(notes, note)
+}
+
+func pricingKey(provider, model string) string {
+	return strings.ToLower(provider + "/" + model)
+}
@@ -0,0 +1,318 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/pop"
+	"github.com/google/uuid"
+)
+
+// EventSubjectPrefix is where domain events are published, one subject per
+// event type, e.g. agentflow.events.run.created. Subscribe to
+// agentflow.events.> for all of them.
+const EventSubjectPrefix = "agentflow.events."
+
+// EventSchemaVersion changes only when a payload field is removed or retyped
+const EventSchemaVersion = 1
+
+type EventType string
+
+const (
+	EventRunCreated      EventType = "run.created"
+	EventStepCompleted   EventType = "step.completed"
+	EventBudgetThreshold EventType = "budget.threshold"
+	EventPromptDeployed  EventType = "prompt.deployed"
+)
+
+// Subject is the NATS subject events of this type are published on
+func (t EventType) Subject() string {
+	return EventSubjectPrefix + string(t)
+}
+
+// Event is the envelope every domain event is published in; Data holds the
+// payload documented for its type
+type Event struct {
+	ID            uuid.UUID       `json:"id"`
+	Type          EventType       `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	OrgID         uuid.UUID       `json:"org_id"`
+	Time          time.Time       `json:"time"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// RunCreatedEvent is published when a run is accepted and queued
+type RunCreatedEvent struct {
+	RunID           uuid.UUID         `json:"run_id"`
+	WorkflowName    string            `json:"workflow_name"`
+	WorkflowVersion int               `json:"workflow_version"`
+	Trigger         TriggerType       `json:"trigger"`
+	Environment     string            `json:"environment,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	EstimatedCents  int64             `json:"estimated_cents"`
+	ReplayOf        *uuid.UUID        `json:"replay_of,omitempty"`
+}
+
+// StepCompletedEvent is published when a step attempt finishes, failed or not
+type StepCompletedEvent struct {
+	RunID           uuid.UUID     `json:"run_id"`
+	StepID          string        `json:"step_id"`
+	TaskID          uuid.UUID     `json:"task_id"`
+	Status          TaskStatus    `json:"status"`
+	Error           string        `json:"error,omitempty"`
+	FailureCategory string        `json:"failure_category,omitempty"`
+	Provider        string        `json:"provider,omitempty"`
+	Model           string        `json:"model,omitempty"`
+	CostCents       int64         `json:"cost_cents"`
+	Tokens          int           `json:"tokens"`
+	Duration        time.Duration `json:"duration_ns"`
+	Degradations    []string      `json:"degradations,omitempty"` // Budget degradations applied to the call
+}
+
+// BudgetThresholdEvent is published when spending moves a budget past 75%,
+// 90%, 100% or the configured alert ratio of its limit
+type BudgetThresholdEvent struct {
+	BudgetID       uuid.UUID            `json:"budget_id"`
+	Scope          cas.BudgetScope      `json:"scope"`
+	ThresholdPct   int                  `json:"threshold_pct"`
+	Status         cas.BudgetStatusType `json:"status"`
+	SpentCents     int64                `json:"spent_cents"`
+	LimitCents     int64                `json:"limit_cents"`
+	UtilizationPct float64              `json:"utilization_pct"`
+	RunID          uuid.UUID            `json:"run_id"`
+}
+
+// PromptDeployedEvent is published when a prompt's stable or canary version changes
+type PromptDeployedEvent struct {
+	PromptName    string  `json:"prompt_name"`
+	StableVersion int     `json:"stable_version"`
+	CanaryVersion *int    `json:"canary_version,omitempty"`
+	CanaryRatio   float64 `json:"canary_ratio"`
+}
+
+// EventSchema documents one event type for extension authors
+type EventSchema struct {
+	Type        EventType              `json:"type"`
+	Subject     string                 `json:"subject"`
+	Description string                 `json:"description"`
+	Version     int                    `json:"schema_version"`
+	Data        map[string]interface{} `json:"data"` // JSON Schema of the payload
+}
+
+var eventPayloads = []struct {
+	eventType   EventType
+	description string
+	payload     interface{}
+}{
+	{EventRunCreated, "A run was accepted and queued", RunCreatedEvent{}},
+	{EventStepCompleted, "A step attempt finished, successfully or not", StepCompletedEvent{}},
+	{EventBudgetThreshold, "Spending moved a budget past 75%, 90%, 100% or budgets.alert_threshold_ratio of its limit", BudgetThresholdEvent{}},
+	{EventPromptDeployed, "A prompt's stable or canary version changed", PromptDeployedEvent{}},
+}
+
+// EventSchemas lists every published event type with its payload schema
+func EventSchemas() []EventSchema {
+	schemas := make([]EventSchema, 0, len(eventPayloads))
+	for _, p := range eventPayloads {
+		schemas = append(schemas, EventSchema{
+			Type:        p.eventType,
+			Subject:     p.eventType.Subject(),
+			Description: p.description,
+			Version:     EventSchemaVersion,
+			Data:        jsonSchemaOf(reflect.TypeOf(p.payload)),
+		})
+	}
+	return schemas
+}
+
+// jsonSchemaOf derives a JSON Schema from a payload struct's json tags;
+// fields without omitempty are required
+func jsonSchemaOf(t reflect.Type) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(uuid.UUID{}):
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return jsonSchemaOf(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := make([]string, 0)
+		for i := 0; i < t.NumField(); i++ {
+			name, options, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			properties[name] = jsonSchemaOf(t.Field(i).Type)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties, "required": required}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// newEvent wraps a payload in the event envelope
+func newEvent(eventType EventType, orgID uuid.UUID, payload interface{}) (*Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+	return &Event{
+		ID:            uuid.New(),
+		Type:          eventType,
+		SchemaVersion: EventSchemaVersion,
+		OrgID:         orgID,
+		Time:          time.Now(),
+		Data:          data,
+	}, nil
+}
+
+// publishEvent publishes a domain event. Events are best effort: a failed
+// publish is logged and never fails the operation that raised it.
+func (cp *ControlPlane) publishEvent(eventType EventType, orgID uuid.UUID, payload interface{}) {
+	if cp.js == nil {
+		return
+	}
+	event, err := newEvent(eventType, orgID, payload)
+	if err != nil {
+		log.Printf("Failed to build %s event: %v", eventType, err)
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal %s event: %v", eventType, err)
+		return
+	}
+	if _, err := cp.js.Publish(eventType.Subject(), data); err != nil {
+		log.Printf("Failed to publish %s event: %v", eventType, err)
+	}
+}
+
+// publishRunCreated announces a newly queued run
+func (cp *ControlPlane) publishRunCreated(run *WorkflowRun, req *RunRequest, version int, estimatedCents int64) {
+	environment, _ := run.Metadata["environment"].(string)
+	trigger, _ := run.Metadata["trigger"].(TriggerType)
+	cp.publishEvent(EventRunCreated, run.OrgID, RunCreatedEvent{
+		RunID:           run.ID,
+		WorkflowName:    run.WorkflowName,
+		WorkflowVersion: version,
+		Trigger:         trigger,
+		Environment:     environment,
+		Labels:          run.Labels,
+		EstimatedCents:  estimatedCents,
+		ReplayOf:        req.ReplayOf,
+	})
+}
+
+// publishStepCompleted announces a finished step attempt
+func (cp *ControlPlane) publishStepCompleted(ctx context.Context, result *TaskResult) {
+	run, err := cp.GetWorkflowRun(ctx, result.RunID)
+	if err != nil {
+		log.Printf("Failed to load run %s for step event: %v", result.RunID, err)
+		return
+	}
+	cp.publishEvent(EventStepCompleted, run.OrgID, StepCompletedEvent{
+		RunID:           result.RunID,
+		StepID:          result.NodeID,
+		TaskID:          result.TaskID,
+		Status:          result.Status,
+		Error:           result.Error,
+		FailureCategory: result.FailureCategory,
+		Provider:        result.Provider,
+		Model:           result.Model,
+		CostCents:       result.CostCents,
+		Tokens:          result.TokensPrompt + result.TokensCompletion,
+		Duration:        result.Duration,
+		Degradations:    result.Degradations,
+	})
+}
+
+// budgetThresholds are the utilization percentages that raise budget.threshold
+var budgetThresholds = []int{100, 90, 75}
+
+// budgetAlertThresholds adds the configured alert percentage, if any, to the
+// built-in thresholds, highest first
+func budgetAlertThresholds(alertPct int) []int {
+	if alertPct <= 0 || slices.Contains(budgetThresholds, alertPct) {
+		return budgetThresholds
+	}
+	thresholds := append(slices.Clone(budgetThresholds), alertPct)
+	slices.SortFunc(thresholds, func(a, b int) int { return b - a })
+	return thresholds
+}
+
+// crossedBudgetThreshold returns the highest threshold spending moved a
+// budget past, or 0 when it crossed none
+func crossedBudgetThreshold(thresholds []int, limitCents, beforeCents, afterCents int64) int {
+	if limitCents <= 0 {
+		return 0
+	}
+	for _, threshold := range thresholds {
+		mark := limitCents * int64(threshold) / 100
+		if beforeCents < mark && afterCents >= mark {
+			return threshold
+		}
+	}
+	return 0
+}
+
+// publishBudgetThresholds announces every run budget the step's cost pushed
+// past a threshold
+func (cp *ControlPlane) publishBudgetThresholds(run *WorkflowRun, enforcement *cas.BudgetEnforcement, costCents int64) {
+	thresholds := budgetAlertThresholds(cp.budgetAlertPct)
+	for _, scoped := range enforcement.Breakdown {
+		threshold := crossedBudgetThreshold(thresholds, scoped.LimitCents, scoped.SpentCents-costCents, scoped.SpentCents)
+		if threshold == 0 {
+			continue
+		}
+		cp.publishEvent(EventBudgetThreshold, run.OrgID, BudgetThresholdEvent{
+			BudgetID:       scoped.BudgetID,
+			Scope:          scoped.Scope,
+			ThresholdPct:   threshold,
+			Status:         scoped.Status,
+			SpentCents:     scoped.SpentCents,
+			LimitCents:     scoped.LimitCents,
+			UtilizationPct: scoped.UtilizationPct,
+			RunID:          run.ID,
+		})
+		subject := fmt.Sprintf("%s budget passed %d%%", scoped.Scope, threshold)
+		message := fmt.Sprintf("%s budget passed %d%% of its limit: %d of %d cents spent, last by run %s of %s",
+			scoped.Scope, threshold, scoped.SpentCents, scoped.LimitCents, run.ID, run.WorkflowName)
+		go cp.notify(context.Background(), run.OrgID, NotifyBudgetAlert, subject, message)
+	}
+}
+
+// PromptDeployed implements pop.DeploymentListener
+func (cp *ControlPlane) PromptDeployed(ctx context.Context, deployment *pop.PromptDeployment) {
+	cp.publishEvent(EventPromptDeployed, deployment.OrgID, PromptDeployedEvent{
+		PromptName:    deployment.PromptName,
+		StableVersion: deployment.StableVersion,
+		CanaryVersion: deployment.CanaryVersion,
+		CanaryRatio:   deployment.CanaryRatio,
+	})
+}
@@ -4,7 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
+	"strings"
 	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/google/uuid"
 )
 
 // LLMExecutor handles LLM-based tasks
@@ -20,11 +25,313 @@ func (e *LLMExecutor) Execute(ctx context.Context, task *Task) (*TaskResult, err
 	start := time.Now()
 
 	promptRef := ""
+	provider, model := defaultLLMProvider, defaultLLMModel
 	if task.Node != nil && task.Node.Config != nil {
 		promptRef, _ = task.Node.Config["prompt_ref"].(string)
+		provider, model = stepProviderModel(task.Node.Config)
+	}
+
+	// Hermetic runs answer every LLM call from the built-in mock provider
+	mock, err := mockProviderFor(task, provider)
+	if err != nil {
+		return nil, err
+	}
+	if mock != nil {
+		provider = MockProviderName
+		ctx = withMockProvider(ctx, mock)
+	}
+
+	// Orgs nearing their budget get cheaper, shorter or throttled calls. Replays
+	// keep the recorded call's model so they find its response.
+	var degradation *cas.Degradation
+	if mock == nil && task.ReplayOf == uuid.Nil && e.worker.degrader != nil {
+		degradation, err = e.worker.degrader.Plan(ctx, task.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		provider, model = applyDegradation(task, degradation, provider, model)
+	}
+
+	// Oversized prompts are compressed or rejected before any provider is paid
+	if err := enforceTokenBudget(task); err != nil {
+		return nil, err
+	}
+	prompt, err := renderPrompt(task)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.worker.prepareMedia(ctx, task, prompt, provider); err != nil {
+		return nil, err
+	}
+	ctx = withRenderedPrompt(ctx, prompt)
+
+	// Best-of-N sampling fans out to one sub-task per sample and picks a winner
+	if task.Node != nil && llmSampleCount(task.Node.Config) > 1 {
+		cfg, err := parseSamplingConfig(task.Node.Config)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Executing LLM task %s with prompt %s, best of %d by %s", task.ID, promptRef, len(cfg.Members), cfg.Strategy)
+		return NewEnsembleExecutor(e.worker, e).resolve(ctx, task, cfg, "sampling", start)
 	}
 	log.Printf("Executing LLM task %s with prompt %s", task.ID, promptRef)
 
+	fingerprint := cassetteFingerprint(provider, model, promptRef, task.Inputs)
+	if sample := sampleIndex(task); sample > 0 {
+		// Each sample is recorded and replayed separately
+		fingerprint = cassetteFingerprint(provider, model, fmt.Sprintf("%s#sample-%d", promptRef, sample), task.Inputs)
+	}
+
+	// Deterministic replay reuses the recorded response instead of calling the provider
+	if task.ReplayOf != uuid.Nil {
+		entry, err := e.worker.cassettes.Lookup(ctx, task.ReplayOf, task.StepID, fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("deterministic replay failed: %w", err)
+		}
+		return &TaskResult{
+			TaskID:           task.ID,
+			Status:           TaskStatusSucceeded,
+			Output:           entry.Output,
+			TokensPrompt:     entry.TokensPrompt,
+			TokensCompletion: entry.TokensCompletion,
+			Provider:         entry.Provider,
+			Model:            entry.Model,
+			Replayed:         true,
+			ExecutedAt:       time.Now(),
+			Duration:         time.Since(start),
+		}, nil
+	}
+
+	// Validate the request against the org's model allow/deny lists
+	policy, err := e.worker.policies.Get(ctx, task.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	// The mock never leaves the worker, so model policies, budgets and the
+	// response cache don't apply to it
+	var cacheLookup *responseCacheLookup
+	if mock == nil {
+		if err := policy.CheckModel(provider, model); err != nil {
+			return nil, err
+		}
+
+		// Cached responses cost nothing, so they are served before budget admission
+		if cacheLookup, err = responseCacheKey(task, provider, model, prompt); err != nil {
+			return nil, err
+		}
+		if cacheLookup != nil {
+			if cached := e.worker.cachedResponse(ctx, task, cacheLookup); cached != nil {
+				result := &TaskResult{
+					TaskID:       task.ID,
+					Status:       TaskStatusSucceeded,
+					Output:       cached.Output,
+					Provider:     cached.Provider,
+					Model:        cached.Model,
+					Cached:       true,
+					Degradations: degradation.Labels(),
+					ExecutedAt:   time.Now(),
+					Duration:     time.Since(start),
+				}
+				e.recordCassette(ctx, task, fingerprint, result)
+				return result, nil
+			}
+		}
+
+		if e.worker.budgets != nil {
+			if err := e.worker.budgets.Admit(ctx, task.OrgID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	call := func(ctx context.Context) (*llmCallResult, error) {
+		if mock != nil {
+			return e.callProvider(ctx, provider, model)
+		}
+		return e.callWithFailover(ctx, task, policy, provider, model)
+	}
+
+	// Latency-sensitive steps may hedge slow calls to an alternate provider
+	hedged := false
+	if task.Node != nil {
+		hedge, err := parseHedgePolicy(task.Node.Config)
+		if err != nil {
+			return nil, err
+		}
+		if hedge != nil {
+			if err := e.checkHedge(ctx, task, policy, hedge); err != nil {
+				log.Printf("Hedging disabled for task %s: %v", task.ID, err)
+			} else {
+				primary := call
+				call = func(ctx context.Context) (*llmCallResult, error) {
+					result, outcome, err := e.hedgedCall(ctx, hedge, provider, model, primary)
+					hedged = outcome.Outcome == HedgeOutcomeHedgeWon
+					return result, err
+				}
+			}
+		}
+	}
+
+	var upstream *llmCallResult
+	shared := false
+	if task.Node != nil && dedupEnabled(task.Node.Config) && e.worker.dedup != nil {
+		key := llmDedupKey(task.OrgID, fingerprint, task.Node.Config)
+		upstream, shared, err = e.worker.dedup.Do(ctx, key, call)
+	} else {
+		upstream, err = call(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Refusals and truncations are handled by the step's refusal policy
+	if class, _ := refusalClass(upstream.Output); class != "" {
+		billed := upstream.CostCents
+		if shared {
+			billed = 0
+		}
+		handled, err := e.handleRefusal(ctx, task, policy, upstream, billed)
+		if err != nil {
+			return nil, err
+		}
+		if handled != upstream {
+			upstream, shared = handled, false
+		}
+	}
+
+	result := &TaskResult{
+		TaskID:           task.ID,
+		Status:           TaskStatusSucceeded,
+		Output:           upstream.Output,
+		CostCents:        upstream.CostCents,
+		TokensPrompt:     upstream.TokensPrompt,
+		TokensCompletion: upstream.TokensCompletion,
+		TokensReasoning:  upstream.TokensReasoning,
+		ReasoningCents:   upstream.ReasoningCents,
+		TokensCacheRead:  upstream.TokensCacheRead,
+		TokensCacheWrite: upstream.TokensCacheWrite,
+		CacheSavingCents: upstream.CacheSavingCents,
+		Provider:         upstream.Provider,
+		Model:            upstream.Model,
+		Deduplicated:     shared,
+		Hedged:           hedged && !shared,
+		Degradations:     degradation.Labels(),
+		ExecutedAt:       time.Now(),
+		Duration:         time.Since(start),
+	}
+	if shared {
+		// Only the leading call is billed; followers reused its response
+		result.CostCents, result.ReasoningCents = 0, 0
+		result.TokensCacheRead, result.TokensCacheWrite, result.CacheSavingCents = 0, 0, 0
+		result.Output = make(map[string]interface{}, len(upstream.Output))
+		for k, v := range upstream.Output {
+			result.Output[k] = v
+		}
+	}
+
+	// Refusals and truncations are not worth reusing
+	if cacheLookup != nil {
+		if class, _ := refusalClass(result.Output); class == "" {
+			e.worker.storeResponse(ctx, task, cacheLookup, result)
+		}
+	}
+
+	e.recordCassette(ctx, task, fingerprint, result)
+	return result, nil
+}
+
+// recordCassette records a call's response so its run can be replayed
+func (e *LLMExecutor) recordCassette(ctx context.Context, task *Task, fingerprint string, result *TaskResult) {
+	err := e.worker.cassettes.Record(ctx, task.OrgID, &CassetteEntry{
+		RunID:            task.RunID,
+		StepID:           task.StepID,
+		RequestHash:      fingerprint,
+		Provider:         result.Provider,
+		Model:            result.Model,
+		Output:           result.Output,
+		TokensPrompt:     result.TokensPrompt,
+		TokensCompletion: result.TokensCompletion,
+		CostCents:        result.CostCents,
+		RecordedAt:       result.ExecutedAt,
+	})
+	if err != nil {
+		log.Printf("Failed to record response for task %s: %v", task.ID, err)
+	}
+}
+
+// sampleIndex returns a best-of-N sample's index, or 0 for ordinary calls
+func sampleIndex(task *Task) int {
+	if task.Node == nil {
+		return 0
+	}
+	if v, ok := task.Node.Config["sample_index"].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
+
+// callWithFailover calls the step's provider and, after a server error or
+// timeout, the next-best healthy providers the org's model policy allows
+func (e *LLMExecutor) callWithFailover(ctx context.Context, task *Task, policy *cas.ModelPolicy, provider, model string) (*llmCallResult, error) {
+	if e.worker.failover == nil {
+		return e.callProvider(ctx, provider, model)
+	}
+
+	req := e.routingRequest(task)
+	route, err := e.worker.failover.FailoverRoute(ctx, req, provider, model)
+	if err != nil {
+		// Residency can't be verified without a route, so constrained calls fail
+		if req.Residency != nil {
+			return nil, err
+		}
+		log.Printf("Failover disabled for task %s: %v", task.ID, err)
+		route = &cas.RoutingResponse{ProviderName: provider, ModelName: model}
+	}
+	allowed := make([]cas.Alternative, 0, len(route.Alternatives))
+	for _, alternative := range route.Alternatives {
+		if policy.CheckModel(alternative.ProviderName, alternative.ModelName) == nil {
+			allowed = append(allowed, alternative)
+		}
+	}
+	route.Alternatives = allowed
+
+	var result *llmCallResult
+	attempts, err := e.worker.failover.CallWithFailover(ctx, route, func(ctx context.Context, providerName, modelName string) error {
+		called, err := e.callProvider(ctx, providerName, modelName)
+		if err == nil {
+			result = called
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(attempts) > 1 {
+		log.Printf("Task %s failed over from %s/%s to %s/%s", task.ID, provider, model, result.Provider, result.Model)
+	}
+	return result, nil
+}
+
+// routingRequest describes a task's provider calls to the router
+func (e *LLMExecutor) routingRequest(task *Task) *cas.RoutingRequest {
+	req := &cas.RoutingRequest{OrgID: task.OrgID, WorkflowName: task.Workflow, Residency: task.Residency}
+	if task.Node != nil {
+		if quality, ok := task.Node.Config["quality"].(string); ok {
+			req.QualityTier = cas.QualityTier(quality)
+		}
+	}
+	return req
+}
+
+// callProvider makes the upstream provider request
+func (e *LLMExecutor) callProvider(ctx context.Context, provider, model string) (*llmCallResult, error) {
+	start := time.Now()
+	prompt := renderedPromptFrom(ctx)
+	if mock := mockProviderFrom(ctx); mock != nil {
+		return mock.call(ctx, model, prompt)
+	}
+	request := mockProviderRequest(provider, model, prompt)
+
 	// Simulate processing time
 	select {
 	case <-ctx.Done():
@@ -32,19 +339,149 @@ func (e *LLMExecutor) Execute(ctx context.Context, task *Task) (*TaskResult, err
 	case <-time.After(100 * time.Millisecond):
 	}
 
-	// Mock successful execution
-	return &TaskResult{
-		TaskID:           task.ID,
-		Status:           TaskStatusSucceeded,
-		Output:           map[string]interface{}{"response": "Mock LLM response"},
-		CostCents:        15,
-		TokensPrompt:     100,
-		TokensCompletion: 50,
-		ExecutedAt:       time.Now(),
-		Duration:         time.Since(start),
+	text := "Mock LLM response"
+	if fault := chaosFrom(ctx).pick(provider, secureRandFloat64); fault != nil {
+		if err := fault.inject(ctx, string(ExecutorTypeLLM)); err != nil {
+			e.worker.captureExchange(ctx, provider, model, request, CapturedMessage{}, err, time.Since(start))
+			return nil, err
+		}
+		text = malformedContent
+	}
+
+	// Mock provider payload - in production would come from the provider API
+	promptTokens, thinking := mockPromptTokens+prompt.prefixTokens()+prompt.mediaTokens(), 0
+	if prompt.Reasoning != nil && supportsReasoning(provider, model) {
+		thinking = prompt.Reasoning.budget() / 2
+	}
+	raw := mockProviderResponse(provider, text)
+	mockProviderUsage(provider, raw, promptTokens, mockCompletionTokens+thinking, thinking, e.worker.mockPromptCacheUsage(provider, prompt))
+	e.worker.captureExchange(ctx, provider, model, request,
+		CapturedMessage{Status: 200, Headers: map[string]string{"Content-Type": "application/json"}, Body: raw},
+		nil, time.Since(start))
+	normalized, err := NormalizeLLMResponse(provider, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cached prompt tokens are billed at the provider's cache read and write rates
+	usage := promptCacheUsage(provider, raw)
+	promptCents, saving := cas.PricePromptTokens(provider, promptTokens, usage, mockPromptCentsPerToken)
+	if usage.ReadTokens > 0 {
+		llmPromptCacheTokens.Add(float64(usage.ReadTokens), provider, "read")
+	}
+	if usage.WriteTokens > 0 {
+		llmPromptCacheTokens.Add(float64(usage.WriteTokens), provider, "write")
+	}
+
+	// Thinking tokens are billed as completion tokens but reported separately
+	reasoning := reasoningTokens(provider, raw)
+	completionTokens := mockCompletionTokens + thinking
+	if reasoning > 0 {
+		llmReasoningTokens.Add(float64(reasoning), provider, model)
+	}
+
+	return &llmCallResult{
+		Output:           normalized.ToOutput(),
+		CostCents:        int64(math.Round(promptCents + float64(completionTokens)*mockCompletionCentsPerToken)),
+		TokensPrompt:     promptTokens,
+		TokensCompletion: completionTokens,
+		TokensReasoning:  reasoning,
+		ReasoningCents:   int64(math.Round(float64(reasoning) * mockCompletionCentsPerToken)),
+		TokensCacheRead:  usage.ReadTokens,
+		TokensCacheWrite: usage.WriteTokens,
+		CacheSavingCents: int64(math.Round(saving)),
+		Provider:         provider,
+		Model:            model,
 	}, nil
 }
 
+// checkHedge rejects hedge targets the org's model policy or the run's
+// residency constraint rules out
+func (e *LLMExecutor) checkHedge(ctx context.Context, task *Task, policy *cas.ModelPolicy, hedge *HedgePolicy) error {
+	if err := policy.CheckModel(hedge.Provider, hedge.Model); err != nil {
+		return err
+	}
+	return e.checkResidency(ctx, task, hedge.Provider, hedge.Model)
+}
+
+// checkResidency rejects providers outside the run's residency constraint
+// for calls made outside failover, such as hedges and refusal reroutes
+func (e *LLMExecutor) checkResidency(ctx context.Context, task *Task, provider, model string) error {
+	if task.Residency == nil || e.worker.failover == nil {
+		return nil
+	}
+	return e.worker.failover.CheckResidency(ctx, e.routingRequest(task), provider, model)
+}
+
+// hedgedCall races the primary call against the step's hedge target once the
+// primary has been slower than the configured latency percentile
+func (e *LLMExecutor) hedgedCall(ctx context.Context, hedge *HedgePolicy, provider, model string, primary func(context.Context) (*llmCallResult, error)) (*llmCallResult, hedgeResult, error) {
+	var latency cas.LatencyPercentiles
+	if e.worker.telemetry != nil {
+		latency = e.worker.telemetry.LatencyPercentiles(provider, model)
+	}
+	delay := hedge.delayFor(latency)
+
+	alternate := func(ctx context.Context) (*llmCallResult, error) {
+		return e.callProvider(ctx, hedge.Provider, hedge.Model)
+	}
+	wasted := func(loser *llmCallResult) {
+		llmHedgeWastedCost.Add(float64(loser.CostCents), loser.Provider, loser.Model)
+	}
+
+	result, outcome, err := hedgedCall(ctx, delay, primary, alternate, wasted)
+	llmHedgeRequests.Inc(outcome.Outcome)
+	return result, outcome, err
+}
+
+// Mock call sizes and prices; 100 prompt and 50 completion tokens cost 15¢
+const (
+	mockPromptTokens            = 100
+	mockCompletionTokens        = 50
+	mockPromptCentsPerToken     = 0.1
+	mockCompletionCentsPerToken = 0.1
+)
+
+// mockProviderRequest builds the request a provider client would send
+func mockProviderRequest(provider, model string, prompt *renderedPrompt) CapturedMessage {
+	return CapturedMessage{
+		Method: "POST",
+		URL:    fmt.Sprintf("https://api.%s.example/v1/chat", strings.ToLower(provider)),
+		Headers: map[string]string{
+			"Authorization": "Bearer " + provider + "-api-key",
+			"Content-Type":  "application/json",
+		},
+		Body: prompt.requestBody(provider, model),
+	}
+}
+
+// mockProviderResponse builds a minimal provider-shaped response body
+func mockProviderResponse(provider, text string) map[string]interface{} {
+	switch strings.ToLower(provider) {
+	case "anthropic":
+		return map[string]interface{}{
+			"content":     []interface{}{map[string]interface{}{"type": "text", "text": text}},
+			"stop_reason": "end_turn",
+		}
+	case "google", "gemini":
+		return map[string]interface{}{
+			"candidates": []interface{}{map[string]interface{}{
+				"content":      map[string]interface{}{"parts": []interface{}{map[string]interface{}{"text": text}}},
+				"finishReason": "STOP",
+			}},
+		}
+	case "cohere":
+		return map[string]interface{}{"text": text, "finish_reason": "COMPLETE"}
+	default:
+		return map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{
+				"message":       map[string]interface{}{"role": "assistant", "content": text},
+				"finish_reason": "stop",
+			}},
+		}
+	}
+}
+
 func (e *LLMExecutor) CanHandle(stepType string) bool {
 	return stepType == "llm"
 }
@@ -139,15 +576,27 @@ func NewScriptExecutor(worker *Worker) *ScriptExecutor {
 func (e *ScriptExecutor) Execute(ctx context.Context, task *Task) (*TaskResult, error) {
 	start := time.Now()
 
-	log.Printf("Executing script task %s", task.ID)
+	policy, err := parseSandboxPolicy(task.Node.Config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sandbox config: %w", err)
+	}
+	sandbox := NewSandbox(policy)
+
+	log.Printf("Executing script task %s (egress: %s, %d allowed hosts)", task.ID, policy.Egress, len(policy.AllowHosts))
 
-	// Mock script execution
+	// Mock script execution - in production the script runs in its own network
+	// and mount namespace, connecting through sandbox.DialContext and writing
+	// only where sandbox.CheckWrite allows
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	case <-time.After(300 * time.Millisecond):
 	}
 
+	if err := e.worker.enforceSandbox(ctx, task, sandbox); err != nil {
+		return nil, err
+	}
+
 	return &TaskResult{
 		TaskID:     task.ID,
 		Status:     TaskStatusSucceeded,
@@ -0,0 +1,251 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/google/uuid"
+)
+
+// FailureCategory is the triaged cause of a failed run
+type FailureCategory string
+
+const (
+	FailureProviderError FailureCategory = "provider_error"
+	FailureBudget        FailureCategory = "budget"
+	FailurePolicy        FailureCategory = "policy"
+	FailureSchema        FailureCategory = "schema"
+	FailureToolError     FailureCategory = "tool_error"
+	FailureTimeout       FailureCategory = "timeout"
+	FailureUserInput     FailureCategory = "user_input"
+	FailureScheduling    FailureCategory = "scheduling"
+)
+
+// failureRemediations suggests a first fix for each category
+var failureRemediations = map[FailureCategory]string{
+	FailureProviderError: "transient provider errors usually clear on retry; add retries or a hedge or fallback model to the step",
+	FailureBudget:        "raise the run, workflow or org budget, lower max_tokens and sample counts, or give LLM steps a cheaper budget_fallback model",
+	FailurePolicy:        "the request was blocked by a model, sandbox or content policy; allow the model or adjust the prompt",
+	FailureSchema:        "a step's output did not match its declared ports or an assert step's checks; tighten the prompt, fix the upstream data, or loosen the port type or assertion",
+	FailureToolError:     "a tool, HTTP or script step failed; check the tool's logs and inputs",
+	FailureTimeout:       "raise the step's timeout or reduce its work, e.g. smaller chunks or fewer samples",
+	FailureUserInput:     "the run's inputs were invalid; fix them and resubmit",
+	FailureScheduling:    "no live worker advertises the labels a step's placement selector requires; start workers with those worker.labels or relax the selector",
+}
+
+// RunFailure is the triaged cause of a failed run: the first step failure
+// or the budget that stopped it
+type RunFailure struct {
+	Category    FailureCategory `json:"category"`
+	StepID      string          `json:"step_id,omitempty"`
+	Message     string          `json:"message"`
+	Remediation string          `json:"remediation"`
+}
+
+// FailureRemediation returns the suggested first fix for a failure category
+func FailureRemediation(category FailureCategory) string {
+	return failureRemediations[category]
+}
+
+func newRunFailure(category FailureCategory, stepID, message string) *RunFailure {
+	return &RunFailure{Category: category, StepID: stepID, Message: message, Remediation: failureRemediations[category]}
+}
+
+// classifyFailure triages a step error into a failure category, using the
+// error's type when it has one and its telemetry class otherwise
+func classifyFailure(err error, stepType string) FailureCategory {
+	var (
+		overBudget *TokenBudgetExceededError
+		mediaErr   *MediaError
+		portErr    *PortTypeError
+		refusal    *RefusalError
+		violation  *SandboxViolationError
+		denied     *cas.ModelPolicyViolation
+		pluginErr  *PluginError
+		assertErr  *AssertionError
+	)
+	switch {
+	case errors.As(err, &overBudget):
+		return FailureBudget
+	case errors.As(err, &mediaErr):
+		return FailureUserInput
+	case errors.As(err, &portErr):
+		if portErr.Direction == PortInput {
+			return FailureUserInput
+		}
+		return FailureSchema
+	case errors.As(err, &refusal), errors.As(err, &violation), errors.As(err, &denied):
+		return FailurePolicy
+	case errors.As(err, &pluginErr):
+		return FailureToolError
+	case errors.As(err, &assertErr):
+		return FailureSchema
+	}
+	return categoryForClass(cas.ClassifyError(err), stepType)
+}
+
+// categoryForClass maps a telemetry error class to a failure category
+func categoryForClass(class cas.ErrorClass, stepType string) FailureCategory {
+	switch class {
+	case cas.ErrorClassTimeout:
+		return FailureTimeout
+	case cas.ErrorClassTokenBudget, cas.ErrorClassBudgetThrottled:
+		return FailureBudget
+	case cas.ErrorClassSafetyRefusal, cas.ErrorClassPolicyBlock:
+		return FailurePolicy
+	case cas.ErrorClassInvalidRequest:
+		return FailureUserInput
+	}
+	switch ExecutorType(stepType) {
+	case ExecutorTypeLLM, ExecutorTypeEnsemble, ExecutorTypeRAGGenerate, ExecutorTypeRAGEmbed, ExecutorTypeTranscribe:
+		return FailureProviderError
+	default:
+		return FailureToolError
+	}
+}
+
+// recordRunFailure stores a run's failure; the first failure is the one kept
+func (cp *ControlPlane) recordRunFailure(ctx context.Context, runID uuid.UUID, failure *RunFailure) error {
+	failureJSON, err := json.Marshal(failure)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run failure: %w", err)
+	}
+	query := `UPDATE workflow_run r SET failure_category = $2, failure = $3
+			  FROM workflow_spec s
+			  WHERE r.id = $1 AND s.id = r.workflow_spec_id AND r.failure_category IS NULL
+			  RETURNING s.org_id, s.name`
+	run := &WorkflowRun{ID: runID}
+	err = cp.db.QueryRowContext(ctx, query, runID, string(failure.Category), failureJSON).Scan(&run.OrgID, &run.WorkflowName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil // An earlier failure is already recorded
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record run failure: %w", err)
+	}
+	runFailures.Inc(string(failure.Category))
+	cp.notifyRunFailure(ctx, run, failure)
+	return nil
+}
+
+// recordStepFailure triages a failed task result into its run's failure
+func (cp *ControlPlane) recordStepFailure(ctx context.Context, result *TaskResult) error {
+	category := FailureCategory(result.FailureCategory)
+	if category == "" {
+		category = categoryForClass(cas.ErrorClass(result.ErrorClass), "")
+	}
+	return cp.recordRunFailure(ctx, result.RunID, newRunFailure(category, result.NodeID, result.Error))
+}
+
+// FailureCategoryCount is one category's share of failed runs
+type FailureCategoryCount struct {
+	Category    FailureCategory `json:"category"`
+	Runs        int             `json:"runs"`
+	Share       float64         `json:"share"`
+	Remediation string          `json:"remediation"`
+	Example     string          `json:"example,omitempty"` // Most recent failure message
+}
+
+// WorkflowFailureCount is one workflow's failed runs by category
+type WorkflowFailureCount struct {
+	WorkflowName string                  `json:"workflow_name"`
+	Runs         int                     `json:"runs"`
+	ByCategory   map[FailureCategory]int `json:"by_category"`
+}
+
+// FailureDashboard aggregates an org's failed runs by triaged category
+type FailureDashboard struct {
+	Since      time.Time              `json:"since"`
+	FailedRuns int                    `json:"failed_runs"`
+	Categories []FailureCategoryCount `json:"categories"`
+	Workflows  []WorkflowFailureCount `json:"workflows"`
+}
+
+// GetFailureDashboard summarizes an org's run failures since a time
+func (cp *ControlPlane) GetFailureDashboard(ctx context.Context, orgID uuid.UUID, since time.Time) (*FailureDashboard, error) {
+	query := `SELECT s.name, r.failure_category, COUNT(*),
+			  (ARRAY_AGG(r.failure->>'message' ORDER BY r.created_at DESC))[1]
+			  FROM workflow_run r JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE s.org_id = $1 AND r.created_at >= $2 AND r.failure_category IS NOT NULL
+			  GROUP BY s.name, r.failure_category`
+	rows, err := cp.db.QueryContext(ctx, query, orgID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query run failures: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var counts []failureCount
+	for rows.Next() {
+		var count failureCount
+		var category string
+		if err := rows.Scan(&count.workflow, &category, &count.runs, &count.example); err != nil {
+			return nil, fmt.Errorf("failed to scan run failures: %w", err)
+		}
+		count.category = FailureCategory(category)
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read run failures: %w", err)
+	}
+	return buildFailureDashboard(since, counts), nil
+}
+
+// failureCount is one workflow and category's failed runs
+type failureCount struct {
+	workflow string
+	category FailureCategory
+	runs     int
+	example  string
+}
+
+func buildFailureDashboard(since time.Time, counts []failureCount) *FailureDashboard {
+	dashboard := &FailureDashboard{Since: since, Categories: make([]FailureCategoryCount, 0), Workflows: make([]WorkflowFailureCount, 0)}
+	categories := make(map[FailureCategory]*FailureCategoryCount)
+	workflows := make(map[string]*WorkflowFailureCount)
+	for _, count := range counts {
+		dashboard.FailedRuns += count.runs
+		category, ok := categories[count.category]
+		if !ok {
+			category = &FailureCategoryCount{Category: count.category, Remediation: failureRemediations[count.category]}
+			categories[count.category] = category
+		}
+		category.Runs += count.runs
+		if category.Example == "" {
+			category.Example = count.example
+		}
+
+		workflow, ok := workflows[count.workflow]
+		if !ok {
+			workflow = &WorkflowFailureCount{WorkflowName: count.workflow, ByCategory: make(map[FailureCategory]int)}
+			workflows[count.workflow] = workflow
+		}
+		workflow.Runs += count.runs
+		workflow.ByCategory[count.category] += count.runs
+	}
+
+	for _, category := range categories {
+		category.Share = float64(category.Runs) / float64(dashboard.FailedRuns)
+		dashboard.Categories = append(dashboard.Categories, *category)
+	}
+	sort.Slice(dashboard.Categories, func(i, j int) bool {
+		if dashboard.Categories[i].Runs != dashboard.Categories[j].Runs {
+			return dashboard.Categories[i].Runs > dashboard.Categories[j].Runs
+		}
+		return dashboard.Categories[i].Category < dashboard.Categories[j].Category
+	})
+	for _, workflow := range workflows {
+		dashboard.Workflows = append(dashboard.Workflows, *workflow)
+	}
+	sort.Slice(dashboard.Workflows, func(i, j int) bool {
+		if dashboard.Workflows[i].Runs != dashboard.Workflows[j].Runs {
+			return dashboard.Workflows[i].Runs > dashboard.Workflows[j].Runs
+		}
+		return dashboard.Workflows[i].WorkflowName < dashboard.Workflows[j].WorkflowName
+	})
+	return dashboard
+}
@@ -0,0 +1,212 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// regionTaskPrefix carries tasks placed in one region; each region's
+	// workers share a queue group so every task runs once
+	regionTaskPrefix = "agentflow.tasks.region."
+	// defaultWorkerCapacity is the concurrent tasks a worker advertises when unset
+	defaultWorkerCapacity = 10
+)
+
+var regionNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// StepPlacement constrains which regions' workers may run a step. Regions is
+// a hard residency constraint; Prefer orders the allowed regions by affinity.
+// Selector limits the step to workers advertising all of its labels.
+type StepPlacement struct {
+	Regions  []string          `json:"regions,omitempty"`
+	Prefer   []string          `json:"prefer,omitempty"`
+	Selector map[string]string `json:"selector,omitempty"` // e.g. gpu: "true", toolset: finance
+}
+
+// Validate checks region names and that preferred regions are allowed
+func (p *StepPlacement) Validate() error {
+	allowed := make(map[string]bool, len(p.Regions))
+	for _, region := range p.Regions {
+		if !regionNamePattern.MatchString(region) {
+			return fmt.Errorf("invalid region %q", region)
+		}
+		allowed[region] = true
+	}
+	for _, region := range p.Prefer {
+		if !regionNamePattern.MatchString(region) {
+			return fmt.Errorf("invalid region %q", region)
+		}
+		if len(allowed) > 0 && !allowed[region] {
+			return fmt.Errorf("preferred region %s is not in the allowed regions", region)
+		}
+	}
+	return validateSelector(p.Selector)
+}
+
+// ValidateStepPlacements checks every step's placement constraints
+func ValidateStepPlacements(dag DAG) error {
+	for _, step := range dag.Steps {
+		if step.Placement == nil {
+			continue
+		}
+		if err := step.Placement.Validate(); err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+	}
+	return nil
+}
+
+// WorkerHeartbeat is what workers report every heartbeat interval
+type WorkerHeartbeat struct {
+	WorkerID  string            `json:"worker_id"`
+	Region    string            `json:"region,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Capacity  int               `json:"capacity"`
+	Active    int               `json:"active"` // Tasks executing now
+	Status    string            `json:"status"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// RegionStatus is one region's live worker capacity and queued work
+type RegionStatus struct {
+	Region   string `json:"region"`
+	Workers  int    `json:"workers"`
+	Capacity int    `json:"capacity"`
+	Active   int    `json:"active"`
+	Backlog  int    `json:"backlog"` // Tasks placed in the region not yet picked up
+}
+
+// load is the share of capacity that running and waiting tasks would take
+func (r RegionStatus) load() float64 {
+	if r.Capacity == 0 {
+		return 1
+	}
+	return float64(r.Active+r.Backlog) / float64(r.Capacity)
+}
+
+func regionTaskSubject(region string) string {
+	return regionTaskPrefix + region
+}
+
+func regionConsumer(region string) string {
+	return "workers-region-" + region
+}
+
+// placeTask picks the region to run a placed task in: the first preferred
+// region with spare capacity, otherwise the least loaded allowed region.
+// It returns false when no allowed region has live workers.
+func placeTask(placement *StepPlacement, regions []RegionStatus) (string, bool) {
+	live := make(map[string]RegionStatus, len(regions))
+	for _, region := range regions {
+		if region.Workers == 0 {
+			continue
+		}
+		live[region.Region] = region
+	}
+
+	candidates := make([]RegionStatus, 0, len(live))
+	if len(placement.Regions) > 0 {
+		for _, name := range placement.Regions {
+			if region, ok := live[name]; ok {
+				candidates = append(candidates, region)
+			}
+		}
+	} else {
+		for _, region := range live {
+			candidates = append(candidates, region)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	for _, name := range placement.Prefer {
+		for _, region := range candidates {
+			if region.Region == name && region.load() < 1 {
+				return name, true
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].load() != candidates[j].load() {
+			return candidates[i].load() < candidates[j].load()
+		}
+		return candidates[i].Region < candidates[j].Region
+	})
+	return candidates[0].Region, true
+}
+
+// RegionStatuses reports live workers, capacity and backlog per region from
+// worker heartbeats and the regions' task consumers
+func (cp *ControlPlane) RegionStatuses(ctx context.Context) ([]RegionStatus, error) {
+	return regionStatuses(ctx, cp.redis, cp.js)
+}
+
+func regionStatuses(ctx context.Context, redisClient *redis.Client, js nats.JetStreamContext) ([]RegionStatus, error) {
+	workers, err := liveWorkers(ctx, redisClient)
+	if err != nil {
+		return nil, err
+	}
+
+	byRegion := make(map[string]*RegionStatus)
+	for _, heartbeat := range workers {
+		if heartbeat.Region == "" {
+			continue // Workers without a region only take unplaced tasks
+		}
+		region, ok := byRegion[heartbeat.Region]
+		if !ok {
+			region = &RegionStatus{Region: heartbeat.Region}
+			byRegion[heartbeat.Region] = region
+		}
+		region.Workers++
+		region.Capacity += heartbeat.Capacity
+		region.Active += heartbeat.Active
+	}
+
+	statuses := make([]RegionStatus, 0, len(byRegion))
+	for _, region := range byRegion {
+		if info, err := js.ConsumerInfo("AGENTFLOW_TASKS", regionConsumer(region.Region)); err == nil {
+			region.Backlog = int(info.NumPending)
+		}
+		statuses = append(statuses, *region)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Region < statuses[j].Region })
+	return statuses, nil
+}
+
+// dispatchPlaced publishes a task with placement constraints to its region,
+// returning false when no allowed region has live workers
+func (q *TenantQueue) dispatchPlaced(ctx context.Context, task *Task, data []byte, regions *[]RegionStatus) (bool, error) {
+	if *regions == nil {
+		statuses, err := regionStatuses(ctx, q.redis, q.js)
+		if err != nil {
+			return false, err
+		}
+		*regions = statuses
+	}
+
+	region, ok := placeTask(task.Placement, *regions)
+	if !ok {
+		log.Printf("Task %s waits for workers in regions %v", task.ID, task.Placement.Regions)
+		return false, nil
+	}
+	if _, err := q.js.Publish(regionTaskSubject(region), data); err != nil {
+		return false, err
+	}
+
+	// Count the task against the region for the rest of this dispatch pass
+	for i := range *regions {
+		if (*regions)[i].Region == region {
+			(*regions)[i].Backlog++
+		}
+	}
+	return true, nil
+}
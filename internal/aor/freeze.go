@@ -0,0 +1,317 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// TriggerType identifies what submitted a run
+type TriggerType string
+
+const (
+	TriggerManual  TriggerType = "manual"
+	TriggerCron    TriggerType = "cron"
+	TriggerWebhook TriggerType = "webhook"
+	TriggerReplay  TriggerType = "replay"
+	TriggerBatch   TriggerType = "batch"
+)
+
+// FreezeMode controls what happens to runs submitted during a freeze
+type FreezeMode string
+
+const (
+	FreezeModeReject FreezeMode = "reject"
+	FreezeModeQueue  FreezeMode = "queue"
+)
+
+// frozenRunsKey is a sorted set of deferred run IDs scored by release time
+const frozenRunsKey = "runs:frozen"
+
+// FreezeWindow pauses runs for an org between StartsAt and EndsAt. Empty
+// Workflows and Tags match every run; empty Triggers blocks cron and webhook
+// triggers only so people can still run workflows by hand.
+type FreezeWindow struct {
+	ID        uuid.UUID         `json:"id"`
+	OrgID     uuid.UUID         `json:"org_id"`
+	Name      string            `json:"name"`
+	Reason    string            `json:"reason,omitempty"`
+	StartsAt  time.Time         `json:"starts_at"`
+	EndsAt    time.Time         `json:"ends_at"`
+	Mode      FreezeMode        `json:"mode"`
+	Triggers  []TriggerType     `json:"triggers,omitempty"`
+	Workflows []string          `json:"workflows,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// FreezeWindowError is returned when a freeze window rejects a run
+type FreezeWindowError struct {
+	Window FreezeWindow
+}
+
+func (e *FreezeWindowError) Error() string {
+	msg := fmt.Sprintf("runs are frozen by window %q until %s", e.Window.Name, e.Window.EndsAt.Format(time.RFC3339))
+	if e.Window.Reason != "" {
+		msg += ": " + e.Window.Reason
+	}
+	return msg
+}
+
+// Validate checks a window's time range, mode and triggers
+func (w *FreezeWindow) Validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("freeze window requires a name")
+	}
+	if !w.EndsAt.After(w.StartsAt) {
+		return fmt.Errorf("freeze window must end after it starts")
+	}
+	switch w.Mode {
+	case "":
+		w.Mode = FreezeModeReject
+	case FreezeModeReject, FreezeModeQueue:
+	default:
+		return fmt.Errorf("invalid freeze mode: %s", w.Mode)
+	}
+	for _, trigger := range w.Triggers {
+		switch trigger {
+		case TriggerManual, TriggerCron, TriggerWebhook, TriggerReplay, TriggerBatch:
+		default:
+			return fmt.Errorf("invalid trigger: %s", trigger)
+		}
+	}
+	return nil
+}
+
+// Applies reports whether the window blocks a run at the given time
+func (w *FreezeWindow) Applies(now time.Time, trigger TriggerType, workflowName string, tags map[string]string) bool {
+	if now.Before(w.StartsAt) || !now.Before(w.EndsAt) {
+		return false
+	}
+
+	if trigger == "" {
+		trigger = TriggerManual
+	}
+	triggers := w.Triggers
+	if len(triggers) == 0 {
+		triggers = []TriggerType{TriggerCron, TriggerWebhook}
+	}
+	if !containsTrigger(triggers, trigger) {
+		return false
+	}
+
+	if len(w.Workflows) > 0 && !containsString(w.Workflows, workflowName) {
+		return false
+	}
+	for key, value := range w.Tags {
+		if tags[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchingFreezeWindow picks the window that blocks a run; rejecting windows
+// win over queueing ones, then the latest-ending window
+func matchingFreezeWindow(windows []FreezeWindow, now time.Time, trigger TriggerType, workflowName string, tags map[string]string) *FreezeWindow {
+	var match *FreezeWindow
+	for i := range windows {
+		w := &windows[i]
+		if !w.Applies(now, trigger, workflowName, tags) {
+			continue
+		}
+		switch {
+		case match == nil:
+			match = w
+		case w.Mode == FreezeModeReject && match.Mode != FreezeModeReject:
+			match = w
+		case w.Mode == match.Mode && w.EndsAt.After(match.EndsAt):
+			match = w
+		}
+	}
+	return match
+}
+
+// CreateFreezeWindow stores a freeze window for an org
+func (cp *ControlPlane) CreateFreezeWindow(ctx context.Context, window *FreezeWindow) error {
+	if err := window.Validate(); err != nil {
+		return err
+	}
+	if window.ID == uuid.Nil {
+		window.ID = uuid.New()
+	}
+	window.CreatedAt = time.Now()
+
+	triggersJSON, err := json.Marshal(window.Triggers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal triggers: %w", err)
+	}
+	workflowsJSON, err := json.Marshal(window.Workflows)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflows: %w", err)
+	}
+	tagsJSON, err := json.Marshal(window.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	query := `INSERT INTO freeze_window (id, org_id, name, reason, starts_at, ends_at, mode, triggers, workflows, tags, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err = cp.db.ExecContext(ctx, query,
+		window.ID, window.OrgID, window.Name, window.Reason, window.StartsAt, window.EndsAt, window.Mode,
+		triggersJSON, workflowsJSON, tagsJSON, window.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create freeze window: %w", err)
+	}
+
+	return nil
+}
+
+// ListFreezeWindows returns an org's windows that have not yet ended
+func (cp *ControlPlane) ListFreezeWindows(ctx context.Context, orgID uuid.UUID) ([]FreezeWindow, error) {
+	return cp.queryFreezeWindows(ctx, `SELECT id, org_id, name, reason, starts_at, ends_at, mode, triggers, workflows, tags, created_at
+			  FROM freeze_window WHERE org_id = $1 AND ends_at > $2 ORDER BY starts_at`, orgID, time.Now())
+}
+
+// DeleteFreezeWindow removes a window; runs it deferred are released on the next monitor pass
+func (cp *ControlPlane) DeleteFreezeWindow(ctx context.Context, orgID, windowID uuid.UUID) error {
+	result, err := cp.db.ExecContext(ctx, `DELETE FROM freeze_window WHERE id = $1 AND org_id = $2`, windowID, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete freeze window: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("freeze window %s not found", windowID)
+	}
+	return nil
+}
+
+// matchFreezeWindow returns the active window blocking a new run, if any
+func (cp *ControlPlane) matchFreezeWindow(ctx context.Context, run *WorkflowRun, trigger TriggerType) (*FreezeWindow, error) {
+	now := time.Now()
+	windows, err := cp.queryFreezeWindows(ctx, `SELECT id, org_id, name, reason, starts_at, ends_at, mode, triggers, workflows, tags, created_at
+			  FROM freeze_window WHERE org_id = $1 AND starts_at <= $2 AND ends_at > $2`, run.OrgID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return matchingFreezeWindow(windows, now, trigger, run.WorkflowName, run.Tags), nil
+}
+
+func (cp *ControlPlane) queryFreezeWindows(ctx context.Context, query string, args ...interface{}) ([]FreezeWindow, error) {
+	rows, err := cp.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query freeze windows: %w", err)
+	}
+	defer rows.Close()
+
+	windows := make([]FreezeWindow, 0)
+	for rows.Next() {
+		var w FreezeWindow
+		var triggersJSON, workflowsJSON, tagsJSON []byte
+		if err := rows.Scan(&w.ID, &w.OrgID, &w.Name, &w.Reason, &w.StartsAt, &w.EndsAt, &w.Mode,
+			&triggersJSON, &workflowsJSON, &tagsJSON, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan freeze window: %w", err)
+		}
+		if err := json.Unmarshal(triggersJSON, &w.Triggers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal triggers: %w", err)
+		}
+		if err := json.Unmarshal(workflowsJSON, &w.Workflows); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal workflows: %w", err)
+		}
+		if err := json.Unmarshal(tagsJSON, &w.Tags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+		}
+		windows = append(windows, w)
+	}
+
+	return windows, rows.Err()
+}
+
+// deferRun holds a run until a freeze window ends
+func (cp *ControlPlane) deferRun(ctx context.Context, run *WorkflowRun, until time.Time) error {
+	err := cp.redis.ZAdd(ctx, frozenRunsKey, redis.Z{Score: float64(until.Unix()), Member: run.ID.String()}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to defer run: %w", err)
+	}
+	return nil
+}
+
+// releaseFrozenRuns admits deferred runs no active window still blocks.
+// Every deferred run is re-checked so shortened or deleted windows release
+// runs early, while new or extended windows push them back.
+func (cp *ControlPlane) releaseFrozenRuns(ctx context.Context) {
+	due, err := cp.redis.ZRange(ctx, frozenRunsKey, 0, -1).Result()
+	if err != nil {
+		log.Printf("Failed to read frozen runs: %v", err)
+		return
+	}
+
+	for _, member := range due {
+		runID, err := uuid.Parse(member)
+		if err != nil {
+			_ = cp.redis.ZRem(ctx, frozenRunsKey, member)
+			continue
+		}
+
+		run, err := cp.GetWorkflowRun(ctx, runID)
+		if err != nil {
+			log.Printf("Dropping unknown frozen run %s: %v", runID, err)
+			_ = cp.redis.ZRem(ctx, frozenRunsKey, member)
+			continue
+		}
+		if run.Status != RunStatusQueued {
+			_ = cp.redis.ZRem(ctx, frozenRunsKey, member) // Canceled while frozen
+			continue
+		}
+
+		trigger, _ := run.Metadata["trigger"].(string)
+		freeze, err := cp.matchFreezeWindow(ctx, run, TriggerType(trigger))
+		if err != nil {
+			log.Printf("Failed to check freeze windows for run %s: %v", runID, err)
+			continue
+		}
+		if freeze != nil {
+			// A window that rejects new runs still only defers runs already accepted
+			if err := cp.deferRun(ctx, run, freeze.EndsAt); err != nil {
+				log.Printf("Failed to re-defer run %s: %v", runID, err)
+			}
+			continue
+		}
+
+		if err := cp.redis.ZRem(ctx, frozenRunsKey, member).Err(); err != nil {
+			log.Printf("Failed to release frozen run %s: %v", runID, err)
+			continue
+		}
+		if err := cp.admitRun(ctx, run, runLimitsFromMetadata(run.Metadata)); err != nil {
+			log.Printf("Failed to admit frozen run %s: %v", runID, err)
+			continue
+		}
+		log.Printf("Released run %s after freeze window", runID)
+	}
+}
+
+func containsTrigger(triggers []TriggerType, trigger TriggerType) bool {
+	for _, t := range triggers {
+		if t == trigger {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,476 @@
+package aor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/pop"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// Labels recorded on synced workflow specs and prompt metadata
+const (
+	GitCommitLabel = "git.commit"
+	GitPathLabel   = "git.path"
+)
+
+var templateFieldPattern = regexp.MustCompile(`{{-?\s*\.(\w+)`)
+
+// GitSync applies workflow specs and prompt templates from a Git branch to
+// one org. Each commit is validated as a whole and applied only if every file
+// is valid, so a bad push never leaves the org half-synced.
+type GitSync struct {
+	cp    *ControlPlane
+	cfg   config.GitSyncConfig
+	orgID uuid.UUID
+
+	mu     sync.RWMutex
+	status GitSyncStatus
+}
+
+// GitSyncStatus reports the last sync attempt
+type GitSyncStatus struct {
+	RepoURL      string       `json:"repo_url"`
+	Branch       string       `json:"branch"`
+	Commit       string       `json:"commit,omitempty"`        // Last applied commit
+	FailedCommit string       `json:"failed_commit,omitempty"` // Head commit that failed validation
+	SyncedAt     *time.Time   `json:"synced_at,omitempty"`
+	LastError    string       `json:"last_error,omitempty"`
+	LastResult   *ApplyResult `json:"last_result,omitempty"`
+}
+
+func NewGitSync(cp *ControlPlane, cfg config.GitSyncConfig) (*GitSync, error) {
+	if cfg.RepoURL == "" {
+		return nil, fmt.Errorf("git sync requires repo_url")
+	}
+	orgID, err := uuid.Parse(cfg.OrgID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid git sync org_id: %w", err)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+
+	return &GitSync{
+		cp:     cp,
+		cfg:    cfg,
+		orgID:  orgID,
+		status: GitSyncStatus{RepoURL: redactRepoURL(cfg.RepoURL), Branch: cfg.Branch},
+	}, nil
+}
+
+// Run syncs on startup and then every interval until shutdown
+func (gs *GitSync) Run(ctx context.Context, shutdown <-chan struct{}) {
+	ticker := time.NewTicker(gs.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := gs.Sync(ctx); err != nil {
+			log.Printf("Git sync of %s@%s failed: %v", gs.status.RepoURL, gs.cfg.Branch, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches the branch and applies it if the head commit has not been
+// applied or rejected already. It returns nil when there was nothing to do.
+func (gs *GitSync) Sync(ctx context.Context) (*ApplyResult, error) {
+	head, err := gs.fetch(ctx)
+	if err != nil {
+		gs.recordFailure("", err)
+		return nil, err
+	}
+
+	gs.mu.RLock()
+	seen := head == gs.status.Commit || head == gs.status.FailedCommit
+	gs.mu.RUnlock()
+	if seen {
+		return nil, nil
+	}
+
+	bundle, err := LoadSyncBundle(gs.cfg.CheckoutDir, gs.cfg.WorkflowsDir, gs.cfg.PromptsDir, func(path string) string {
+		commit, err := gs.git(ctx, gs.cfg.CheckoutDir, "log", "-1", "--format=%H", "--", path)
+		if err != nil {
+			return head
+		}
+		return commit
+	})
+	if err != nil {
+		gs.recordFailure(head, err)
+		return nil, fmt.Errorf("commit %s is invalid: %w", shortCommit(head), err)
+	}
+
+	result, err := gs.cp.Apply(ctx, &ApplyRequest{OrgID: gs.orgID, Bundle: bundle, Prune: gs.cfg.Prune})
+	if err != nil {
+		gs.recordFailure("", err) // Apply errors may be transient, so retry this commit next poll
+		return result, fmt.Errorf("failed to apply commit %s: %w", shortCommit(head), err)
+	}
+
+	now := time.Now()
+	gs.mu.Lock()
+	gs.status.Commit = head
+	gs.status.FailedCommit = ""
+	gs.status.SyncedAt = &now
+	gs.status.LastError = ""
+	gs.status.LastResult = result
+	gs.mu.Unlock()
+
+	log.Printf("Git sync applied %s@%s: %d created, %d updated, %d deleted", gs.status.RepoURL, shortCommit(head),
+		result.Summary[ApplyActionCreate], result.Summary[ApplyActionUpdate], result.Summary[ApplyActionDelete])
+	return result, nil
+}
+
+// Status returns a copy of the last sync outcome
+func (gs *GitSync) Status() GitSyncStatus {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.status
+}
+
+func (gs *GitSync) recordFailure(commit string, err error) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if commit != "" {
+		gs.status.FailedCommit = commit
+	}
+	gs.status.LastError = err.Error()
+}
+
+// fetch clones or fast-forwards the checkout to the remote branch and returns its head
+func (gs *GitSync) fetch(ctx context.Context) (string, error) {
+	dir := gs.cfg.CheckoutDir
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0o750); err != nil {
+			return "", fmt.Errorf("failed to create checkout directory: %w", err)
+		}
+		if _, err := gs.git(ctx, "", "clone", "--branch", gs.cfg.Branch, "--single-branch", gs.cfg.RepoURL, dir); err != nil {
+			return "", err
+		}
+	} else {
+		if _, err := gs.git(ctx, dir, "fetch", "origin", gs.cfg.Branch); err != nil {
+			return "", err
+		}
+		if _, err := gs.git(ctx, dir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+	}
+	return gs.git(ctx, dir, "rev-parse", "HEAD")
+}
+
+func (gs *GitSync) git(ctx context.Context, dir string, args ...string) (string, error) {
+	if gs.cfg.Token != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + gs.cfg.Token))
+		args = append([]string{"-c", "http.extraHeader=Authorization: Basic " + credentials}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...) // #nosec G204 - arguments come from service config
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run git %s: %w: %s", gitSubcommand(args), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// LoadSyncBundle builds an apply bundle from workflow specs and prompt
+// templates under root. Workflow files are .yaml, .yml or .json specs named
+// by their name field or file name. Prompts are .tmpl files, whose schema is
+// inferred from the fields they reference, or .yaml/.json documents with a
+// template and schema. commitOf, when set, returns the last commit that
+// touched a file and is recorded for traceability.
+func LoadSyncBundle(root, workflowsDir, promptsDir string, commitOf func(path string) string) (*ApplyBundle, error) {
+	bundle := &ApplyBundle{APIVersion: ApplyAPIVersion}
+	var problems []string
+
+	workflowFiles, err := listSyncFiles(root, workflowsDir, ".yaml", ".yml", ".json")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range workflowFiles {
+		resource, err := loadWorkflowFile(root, path, commitOf)
+		if err == nil {
+			_, err = resourceHash(*resource)
+		}
+		if err == nil {
+			resource.Signature, err = loadSignatureFile(root, path)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		bundle.Resources = append(bundle.Resources, *resource)
+	}
+
+	promptFiles, err := listSyncFiles(root, promptsDir, ".tmpl", ".yaml", ".yml", ".json")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range promptFiles {
+		resource, err := loadPromptFile(root, path, commitOf)
+		if err == nil {
+			_, err = resourceHash(*resource)
+		}
+		if err == nil {
+			resource.Signature, err = loadSignatureFile(root, path)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		bundle.Resources = append(bundle.Resources, *resource)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid files:\n  %s", strings.Join(problems, "\n  "))
+	}
+	if err := bundle.Validate(); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// listSyncFiles returns paths relative to root, sorted so bundles are deterministic
+func listSyncFiles(root, dir string, extensions ...string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	base := filepath.Join(root, dir)
+	if _, err := os.Stat(base); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(base, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		for _, allowed := range extensions {
+			if ext == allowed {
+				rel, err := filepath.Rel(root, path)
+				if err != nil {
+					return err
+				}
+				files = append(files, filepath.ToSlash(rel))
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func loadWorkflowFile(root, path string, commitOf func(string) string) (*Resource, error) {
+	raw, err := readSyncDocument(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := raw["name"].(string)
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	raw["name"] = name
+
+	if commitOf != nil {
+		metadata, _ := raw["metadata"].(map[string]interface{})
+		if metadata == nil {
+			metadata = make(map[string]interface{})
+		}
+		labels, _ := metadata["labels"].(map[string]interface{})
+		if labels == nil {
+			labels = make(map[string]interface{})
+		}
+		labels[GitCommitLabel] = commitOf(path)
+		labels[GitPathLabel] = path
+		metadata["labels"] = labels
+		raw["metadata"] = metadata
+	}
+
+	spec, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode spec: %w", err)
+	}
+	return &Resource{Kind: ResourceKindWorkflow, Name: name, Spec: spec}, nil
+}
+
+func loadPromptFile(root, path string, commitOf func(string) string) (*Resource, error) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	var prompt PromptResource
+	if strings.EqualFold(filepath.Ext(path), ".tmpl") {
+		content, err := os.ReadFile(filepath.Join(root, path)) // #nosec G304 - path comes from walking the checkout
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		prompt.Template = string(content)
+		prompt.Schema = inferTemplateSchema(prompt.Template)
+	} else {
+		raw, err := readSyncDocument(root, path)
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode prompt: %w", err)
+		}
+		if err := json.Unmarshal(encoded, &prompt); err != nil {
+			return nil, fmt.Errorf("failed to decode prompt: %w", err)
+		}
+		if promptName, _ := raw["name"].(string); promptName != "" {
+			name = promptName
+		}
+	}
+
+	if commitOf != nil {
+		if prompt.Metadata == nil {
+			prompt.Metadata = pop.Metadata{}
+		}
+		prompt.Metadata[GitCommitLabel] = commitOf(path)
+		prompt.Metadata[GitPathLabel] = path
+	}
+
+	spec, err := json.Marshal(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode prompt: %w", err)
+	}
+	return &Resource{Kind: ResourceKindPrompt, Name: name, Spec: spec}, nil
+}
+
+// loadSignatureFile reads the detached signature stored next to a file as
+// <file>.sig, if any
+func loadSignatureFile(root, path string) (*ResourceSignature, error) {
+	content, err := os.ReadFile(filepath.Join(root, path+".sig")) // #nosec G304 - path comes from walking the checkout
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+
+	var signature ResourceSignature
+	if err := json.Unmarshal(content, &signature); err != nil {
+		return nil, fmt.Errorf("failed to parse signature: %w", err)
+	}
+	if signature.Key == "" || signature.Signature == "" {
+		return nil, fmt.Errorf("signature file needs key and signature")
+	}
+	return &signature, nil
+}
+
+// readSyncDocument decodes a YAML or JSON file into a JSON-compatible map
+func readSyncDocument(root, path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(filepath.Join(root, path)) // #nosec G304 - path comes from walking the checkout
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(content, &raw)
+	} else {
+		err = yaml.Unmarshal(content, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file: %w", err)
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("file is empty")
+	}
+	return raw, nil
+}
+
+// inferTemplateSchema declares every top-level field a template references as a required string
+func inferTemplateSchema(template string) pop.Schema {
+	schema := pop.Schema{Type: "object", Properties: map[string]pop.Property{}}
+	for _, match := range templateFieldPattern.FindAllStringSubmatch(template, -1) {
+		field := match[1]
+		if _, ok := schema.Properties[field]; ok {
+			continue
+		}
+		schema.Properties[field] = pop.Property{Type: "string"}
+		schema.Required = append(schema.Required, field)
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// gitSubcommand names the git command being run, skipping config flags
+func gitSubcommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-c" {
+			i++
+			continue
+		}
+		return args[i]
+	}
+	return ""
+}
+
+// redactRepoURL drops credentials embedded in a repo URL
+func redactRepoURL(repoURL string) string {
+	scheme, rest, ok := strings.Cut(repoURL, "://")
+	if !ok {
+		return repoURL
+	}
+	if at := strings.LastIndex(rest, "@"); at >= 0 && at < strings.Index(rest+"/", "/") {
+		rest = rest[at+1:]
+	}
+	return scheme + "://" + rest
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// GitSyncStatus returns the state of Git sync for this control plane
+func (cp *ControlPlane) GitSyncStatus() (*GitSyncStatus, error) {
+	if cp.gitSync == nil {
+		return nil, fmt.Errorf("git sync is not enabled")
+	}
+	status := cp.gitSync.Status()
+	return &status, nil
+}
+
+// TriggerGitSync syncs immediately instead of waiting for the next poll
+func (cp *ControlPlane) TriggerGitSync(ctx context.Context) (*ApplyResult, error) {
+	if cp.gitSync == nil {
+		return nil, fmt.Errorf("git sync is not enabled")
+	}
+	return cp.gitSync.Sync(ctx)
+}
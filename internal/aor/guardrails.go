@@ -0,0 +1,76 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// GuardrailKind is the kind of policy a step violated
+type GuardrailKind string
+
+const (
+	GuardrailNetworkEgress   GuardrailKind = "network_egress"
+	GuardrailFilesystemWrite GuardrailKind = "filesystem_write"
+)
+
+// GuardrailEvent records an action a step was blocked from taking
+type GuardrailEvent struct {
+	ID        uuid.UUID     `json:"id"`
+	OrgID     uuid.UUID     `json:"org_id"`
+	RunID     uuid.UUID     `json:"run_id"`
+	StepID    string        `json:"step_id"`
+	Kind      GuardrailKind `json:"kind"`
+	Target    string        `json:"target"` // Host:port or path the step tried to reach
+	Action    string        `json:"action"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// saveGuardrailEvents stores violations so they can be audited per run
+func saveGuardrailEvents(ctx context.Context, pgDB *db.PostgresDB, events []GuardrailEvent) error {
+	query := `INSERT INTO guardrail_event (id, org_id, run_id, step_id, kind, target, action, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	for _, event := range events {
+		_, err := pgDB.ExecContext(ctx, query,
+			event.ID, event.OrgID, event.RunID, event.StepID,
+			string(event.Kind), event.Target, event.Action, event.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save guardrail event: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListGuardrailEvents returns the guardrail violations recorded for a run
+func (cp *ControlPlane) ListGuardrailEvents(ctx context.Context, orgID, runID uuid.UUID) ([]GuardrailEvent, error) {
+	query := `SELECT id, org_id, run_id, step_id, kind, target, action, created_at
+			  FROM guardrail_event WHERE org_id = $1 AND run_id = $2
+			  ORDER BY created_at`
+
+	rows, err := cp.db.QueryContext(ctx, query, orgID, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guardrail events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]GuardrailEvent, 0)
+	for rows.Next() {
+		var event GuardrailEvent
+		var kind string
+		if err := rows.Scan(&event.ID, &event.OrgID, &event.RunID, &event.StepID,
+			&kind, &event.Target, &event.Action, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan guardrail event: %w", err)
+		}
+		event.Kind = GuardrailKind(kind)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list guardrail events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,200 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+)
+
+const (
+	// defaultHedgeDelay is used until enough latency samples have been observed
+	defaultHedgeDelay = 2 * time.Second
+	// minHedgeDelay keeps a hedge from doubling every call to a fast model
+	minHedgeDelay = 50 * time.Millisecond
+	// minHedgeSamples is the number of observed calls needed to trust a percentile
+	minHedgeSamples = 20
+)
+
+// Hedge outcomes recorded in metrics
+const (
+	HedgeOutcomeNotFired   = "not_fired"
+	HedgeOutcomePrimaryWon = "primary_won"
+	HedgeOutcomeHedgeWon   = "hedge_won"
+	HedgeOutcomeBothFailed = "both_failed"
+)
+
+// HedgePolicy sends a second request to an alternate provider when the
+// primary has not answered within a delay, keeping the first success
+type HedgePolicy struct {
+	After    string        `json:"after"`
+	Delay    time.Duration `json:"delay,omitempty"`
+	Provider string        `json:"provider"`
+	Model    string        `json:"model"`
+}
+
+// parseHedgePolicy reads a step's hedge config, returning nil when hedging is off.
+// "after" is either a latency percentile of the primary model (p50, p95, p99)
+// or a fixed duration such as "1500ms".
+func parseHedgePolicy(config map[string]interface{}) (*HedgePolicy, error) {
+	raw, ok := config["hedge"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	if enabled, ok := raw["enabled"].(bool); ok && !enabled {
+		return nil, nil
+	}
+
+	policy := &HedgePolicy{After: "p95"}
+	policy.Provider, _ = raw["provider"].(string)
+	policy.Model, _ = raw["model"].(string)
+	if policy.Provider == "" || policy.Model == "" {
+		return nil, fmt.Errorf("hedge requires an alternate provider and model")
+	}
+
+	if after, ok := raw["after"].(string); ok && after != "" {
+		policy.After = strings.ToLower(after)
+	}
+	switch policy.After {
+	case "p50", "p95", "p99":
+	default:
+		d, err := time.ParseDuration(policy.After)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hedge delay %q: use p50, p95, p99 or a duration", policy.After)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("hedge delay must be positive")
+		}
+		policy.Delay = d
+	}
+
+	return policy, nil
+}
+
+// delayFor resolves the hedge delay from the primary model's observed latencies
+func (p *HedgePolicy) delayFor(latency cas.LatencyPercentiles) time.Duration {
+	if p.Delay > 0 {
+		return p.Delay
+	}
+	if latency.Count < minHedgeSamples {
+		return defaultHedgeDelay
+	}
+
+	var d time.Duration
+	switch p.After {
+	case "p50":
+		d = latency.P50
+	case "p99":
+		d = latency.P99
+	default:
+		d = latency.P95
+	}
+	if d < minHedgeDelay {
+		d = minHedgeDelay
+	}
+	return d
+}
+
+type hedgeAttempt struct {
+	hedge  bool
+	result *llmCallResult
+	err    error
+}
+
+// hedgeResult describes how a hedged call was resolved
+type hedgeResult struct {
+	Outcome string
+	Fired   bool
+}
+
+// hedgedCall runs primary and, once delay passes without a success, the
+// alternate. The first successful response wins and the other attempt is
+// cancelled. A primary that fails before the delay fires the hedge at once.
+// wasted is invoked for a losing attempt that still completed, since its
+// cost was spent for nothing.
+func hedgedCall(ctx context.Context, delay time.Duration, primary, alternate func(context.Context) (*llmCallResult, error), wasted func(*llmCallResult)) (*llmCallResult, hedgeResult, error) {
+	attempts := make(chan hedgeAttempt, 2)
+
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	hedgeCtx, cancelHedge := context.WithCancel(ctx)
+
+	launch := func(ctx context.Context, hedge bool, call func(context.Context) (*llmCallResult, error)) {
+		go func() {
+			result, err := call(ctx)
+			attempts <- hedgeAttempt{hedge: hedge, result: result, err: err}
+		}()
+	}
+	launch(primaryCtx, false, primary)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	outcome := hedgeResult{Outcome: HedgeOutcomeNotFired}
+	pending := 1
+	var firstErr error
+
+	fire := func() {
+		if outcome.Fired {
+			return
+		}
+		outcome.Fired = true
+		pending++
+		launch(hedgeCtx, true, alternate)
+	}
+
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			fire()
+		case attempt := <-attempts:
+			pending--
+			if attempt.err != nil {
+				if firstErr == nil {
+					firstErr = attempt.err
+				}
+				if !attempt.hedge {
+					fire()
+				}
+				continue
+			}
+
+			// Cancel the loser and account for it if it still finishes
+			if attempt.hedge {
+				outcome.Outcome = HedgeOutcomeHedgeWon
+				cancelPrimary()
+			} else {
+				if outcome.Fired {
+					outcome.Outcome = HedgeOutcomePrimaryWon
+				}
+				cancelHedge()
+			}
+			if pending > 0 {
+				go func() {
+					loser := <-attempts
+					if loser.err == nil && wasted != nil {
+						wasted(loser.result)
+					}
+					cancelPrimary()
+					cancelHedge()
+				}()
+			} else {
+				cancelPrimary()
+				cancelHedge()
+			}
+			return attempt.result, outcome, nil
+		case <-ctx.Done():
+			cancelPrimary()
+			cancelHedge()
+			return nil, outcome, ctx.Err()
+		}
+	}
+
+	cancelPrimary()
+	cancelHedge()
+	if outcome.Fired {
+		outcome.Outcome = HedgeOutcomeBothFailed
+	}
+	return nil, outcome, firstErr
+}
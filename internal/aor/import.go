@@ -0,0 +1,578 @@
+package aor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportFormat names an agent framework definition the importer understands
+type ImportFormat string
+
+const (
+	ImportFormatLangGraph ImportFormat = "langgraph"
+	ImportFormatCrewAI    ImportFormat = "crewai"
+)
+
+// LangGraph's virtual entry and exit nodes
+const (
+	langGraphStart = "__start__"
+	langGraphEnd   = "__end__"
+)
+
+// importDefaultRetries matches CrewAI's default max_retry_limit
+const importDefaultRetries = 2
+
+var (
+	stepIDUnsafe        = regexp.MustCompile(`[^a-z0-9_-]+`)
+	crewAIPlaceholder   = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	importProviderHints = []struct{ marker, provider string }{
+		{"openai", "openai"}, {"gpt", "openai"},
+		{"anthropic", "anthropic"}, {"claude", "anthropic"},
+		{"google", "google"}, {"gemini", "google"}, {"vertex", "google"},
+		{"cohere", "cohere"}, {"command-r", "cohere"},
+	}
+)
+
+// ImportIssue is a construct the importer could not convert faithfully
+type ImportIssue struct {
+	Severity   LintSeverity `json:"severity"`
+	Node       string       `json:"node,omitempty"`
+	Construct  string       `json:"construct"`
+	Message    string       `json:"message"`
+	Suggestion string       `json:"suggestion"`
+}
+
+// ImportedPrompt is a prompt template extracted from the source definition
+// that must be registered before the workflow can run
+type ImportedPrompt struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+}
+
+// ImportResult is a converted workflow spec with what could not be converted
+type ImportResult struct {
+	Format  ImportFormat     `json:"format"`
+	Spec    *WorkflowSpec    `json:"spec"`
+	Prompts []ImportedPrompt `json:"prompts,omitempty"`
+	Issues  []ImportIssue    `json:"issues"`
+}
+
+// Blocking reports whether any issue must be fixed before the spec can run
+func (r *ImportResult) Blocking() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == LintSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ImportResult) flag(severity LintSeverity, node, construct, message, suggestion string) {
+	r.Issues = append(r.Issues, ImportIssue{
+		Severity:   severity,
+		Node:       node,
+		Construct:  construct,
+		Message:    message,
+		Suggestion: suggestion,
+	})
+}
+
+// DetectImportFormat guesses the source framework from the document's top-level keys
+func DetectImportFormat(data []byte) (ImportFormat, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("failed to parse definition: %w", err)
+	}
+	_, hasNodes := doc["nodes"]
+	_, hasEdges := doc["edges"]
+	_, hasAgents := doc["agents"]
+	_, hasTasks := doc["tasks"]
+
+	switch {
+	case hasNodes && hasEdges:
+		return ImportFormatLangGraph, nil
+	case hasAgents || hasTasks:
+		return ImportFormatCrewAI, nil
+	default:
+		return "", fmt.Errorf("unrecognized definition: expected LangGraph nodes/edges or CrewAI agents/tasks")
+	}
+}
+
+// ImportWorkflowSpec converts a LangGraph graph JSON or CrewAI crew definition
+// into a workflow spec named name. JSON and YAML input are both accepted.
+func ImportWorkflowSpec(data []byte, format ImportFormat, name string) (*ImportResult, error) {
+	if name == "" {
+		return nil, fmt.Errorf("workflow name is required")
+	}
+
+	result := &ImportResult{
+		Format: format,
+		Spec: &WorkflowSpec{
+			Name:    name,
+			Version: 1,
+			DAG:     DAG{Steps: make([]Step, 0), Edges: make([]Edge, 0)},
+			Metadata: Metadata{
+				Description: fmt.Sprintf("Imported from %s", format),
+				Labels:      map[string]string{"imported_from": string(format)},
+			},
+		},
+		Issues: make([]ImportIssue, 0),
+	}
+
+	var err error
+	switch format {
+	case ImportFormatLangGraph:
+		err = importLangGraph(data, result)
+	case ImportFormatCrewAI:
+		err = importCrewAI(data, result)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q: use langgraph or crewai", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Spec.DAG.Steps) == 0 {
+		return nil, fmt.Errorf("definition contains no steps to import")
+	}
+
+	sort.SliceStable(result.Issues, func(i, j int) bool {
+		return severityRank(result.Issues[i].Severity) < severityRank(result.Issues[j].Severity)
+	})
+	return result, nil
+}
+
+type langGraphDoc struct {
+	Nodes []struct {
+		ID   string      `yaml:"id"`
+		Type string      `yaml:"type"`
+		Data interface{} `yaml:"data"`
+	} `yaml:"nodes"`
+	Edges []struct {
+		Source      string      `yaml:"source"`
+		Target      string      `yaml:"target"`
+		Conditional bool        `yaml:"conditional"`
+		Data        interface{} `yaml:"data"`
+	} `yaml:"edges"`
+}
+
+// importLangGraph converts the graph JSON produced by graph.get_graph().to_json()
+func importLangGraph(data []byte, result *ImportResult) error {
+	var doc langGraphDoc
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse LangGraph definition: %w", err)
+	}
+
+	ids := make(map[string]string, len(doc.Nodes))
+	for _, node := range doc.Nodes {
+		if node.ID == langGraphStart || node.ID == langGraphEnd {
+			continue
+		}
+		step := langGraphStep(node.ID, node.Data, result)
+		ids[node.ID] = step.ID
+		result.Spec.DAG.Steps = append(result.Spec.DAG.Steps, step)
+	}
+
+	conditional := make(map[string][]string)
+	for _, edge := range doc.Edges {
+		if edge.Source == langGraphStart || edge.Target == langGraphEnd {
+			continue
+		}
+		from, okFrom := ids[edge.Source]
+		to, okTo := ids[edge.Target]
+		if !okFrom || !okTo {
+			result.flag(LintSeverityError, edge.Source, "dangling-edge",
+				fmt.Sprintf("edge %s -> %s references an unknown node", edge.Source, edge.Target),
+				"re-export the graph with graph.get_graph().to_json() so every node is included")
+			continue
+		}
+		if edge.Conditional {
+			conditional[edge.Source] = append(conditional[edge.Source], edge.Target)
+		}
+		result.Spec.DAG.Edges = append(result.Spec.DAG.Edges, Edge{From: from, To: to})
+	}
+
+	for _, source := range sortedKeys(conditional) {
+		result.flag(LintSeverityWarning, source, "conditional-edge",
+			fmt.Sprintf("conditional routing from %s to %s was imported as unconditional dependencies; every branch will run",
+				source, strings.Join(conditional[source], ", ")),
+			"add a condition to each branch step, or fold the routing decision into a single llm step")
+	}
+
+	dropCycles(result)
+	return nil
+}
+
+// langGraphStep maps a graph node to a step by the runnable class it wraps
+func langGraphStep(id string, data interface{}, result *ImportResult) Step {
+	step := Step{ID: importStepID(id), Name: id, Config: map[string]interface{}{}}
+
+	className := ""
+	var kwargs map[string]interface{}
+	if fields, ok := data.(map[string]interface{}); ok {
+		if path, ok := fields["id"].([]interface{}); ok && len(path) > 0 {
+			className, _ = path[len(path)-1].(string)
+		}
+		if className == "" {
+			className, _ = fields["name"].(string)
+		}
+		kwargs, _ = fields["kwargs"].(map[string]interface{})
+	}
+
+	switch {
+	case className == "ToolNode" || id == "tools":
+		step.Type = "tool"
+		step.Config["tool_name"] = id
+		result.flag(LintSeverityWarning, id, "tool-node",
+			"ToolNode dispatches to whichever tool the model picks at runtime; AgentFlow tool steps run one named tool",
+			fmt.Sprintf("set config.tool_name on step %s, or add one tool step per tool the graph can call", step.ID))
+	case strings.HasPrefix(className, "Chat") || strings.Contains(strings.ToLower(className), "llm"):
+		step.Type = string(ExecutorTypeLLM)
+		step.Retries = importDefaultRetries
+		step.Config["prompt_ref"] = step.ID
+		model := ""
+		if kwargs != nil {
+			for _, key := range []string{"model", "model_name"} {
+				if m, ok := kwargs[key].(string); ok && m != "" {
+					model = m
+					break
+				}
+			}
+		}
+		applyImportedModel(step.Config, className+" "+model, model)
+		result.flag(LintSeverityInfo, id, "prompt",
+			fmt.Sprintf("model node %s carries no prompt in the graph export", id),
+			fmt.Sprintf("create prompt %s with agentctl prompt create before running", step.ID))
+	default:
+		step.Type = string(ExecutorTypeScript)
+		step.Config["entrypoint"] = id
+		result.flag(LintSeverityError, id, "python-callable",
+			fmt.Sprintf("node %s is Python code (%s) and cannot be converted automatically", id, orDefault(className, "callable")),
+			"port it to a script step (config.entrypoint) or replace it with an llm step and prompt_ref")
+	}
+	return step
+}
+
+// dropCycles removes back edges, since a DAG cannot express agent loops
+func dropCycles(result *ImportResult) {
+	steps := result.Spec.DAG.Steps
+	edges := result.Spec.DAG.Edges
+
+	children := make(map[string][]int)
+	for i, edge := range edges {
+		children[edge.From] = append(children[edge.From], i)
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(steps))
+	back := make(map[int]bool)
+
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = visiting
+		for _, i := range children[id] {
+			switch state[edges[i].To] {
+			case visiting:
+				back[i] = true
+			case unvisited:
+				visit(edges[i].To)
+			}
+		}
+		state[id] = done
+	}
+	for _, step := range steps {
+		if state[step.ID] == unvisited {
+			visit(step.ID)
+		}
+	}
+	if len(back) == 0 {
+		return
+	}
+
+	kept := make([]Edge, 0, len(edges)-len(back))
+	for i, edge := range edges {
+		if !back[i] {
+			kept = append(kept, edge)
+			continue
+		}
+		result.flag(LintSeverityWarning, edge.From, "cycle",
+			fmt.Sprintf("loop edge %s -> %s was dropped; workflow DAGs cannot contain cycles", edge.From, edge.To),
+			fmt.Sprintf("let step %s emit a spawn directive to add follow-up steps, or keep the loop inside a single llm step", edge.From))
+	}
+	result.Spec.DAG.Edges = kept
+}
+
+type crewAIAgent struct {
+	Name             string   `yaml:"name"`
+	Role             string   `yaml:"role"`
+	Goal             string   `yaml:"goal"`
+	Backstory        string   `yaml:"backstory"`
+	LLM              string   `yaml:"llm"`
+	Tools            []string `yaml:"tools"`
+	AllowDelegation  bool     `yaml:"allow_delegation"`
+	MaxRetryLimit    *int     `yaml:"max_retry_limit"`
+	MaxExecutionTime int      `yaml:"max_execution_time"`
+}
+
+type crewAITask struct {
+	Name           string   `yaml:"name"`
+	Description    string   `yaml:"description"`
+	ExpectedOutput string   `yaml:"expected_output"`
+	Agent          string   `yaml:"agent"`
+	Context        []string `yaml:"context"`
+	Tools          []string `yaml:"tools"`
+	AsyncExecution bool     `yaml:"async_execution"`
+	HumanInput     bool     `yaml:"human_input"`
+	OutputFile     string   `yaml:"output_file"`
+}
+
+// importCrewAI converts a crew definition with agents and tasks, given either
+// as name-keyed maps (agents.yaml/tasks.yaml style) or as lists with names
+func importCrewAI(data []byte, result *ImportResult) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse CrewAI definition: %w", err)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("CrewAI definition must be a mapping with agents and tasks")
+	}
+	doc := root.Content[0]
+
+	var agents []crewAIAgent
+	var tasks []crewAITask
+	process := "sequential"
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i].Value, doc.Content[i+1]
+		switch key {
+		case "agents":
+			if err := decodeNamed(value, &agents, func(a *crewAIAgent, name string) { a.Name = name }); err != nil {
+				return fmt.Errorf("failed to parse agents: %w", err)
+			}
+		case "tasks":
+			if err := decodeNamed(value, &tasks, func(t *crewAITask, name string) { t.Name = name }); err != nil {
+				return fmt.Errorf("failed to parse tasks: %w", err)
+			}
+		case "process":
+			process = strings.ToLower(value.Value)
+		}
+	}
+
+	byName := make(map[string]crewAIAgent, len(agents))
+	for _, agent := range agents {
+		byName[agent.Name] = agent
+		if len(agent.Tools) > 0 {
+			result.flag(LintSeverityWarning, agent.Name, "agent-tools",
+				fmt.Sprintf("agent %s uses tools (%s) that are not imported", agent.Name, strings.Join(agent.Tools, ", ")),
+				"add a tool step with config.tool_name ahead of each task that needs the tool's output")
+		}
+		if agent.AllowDelegation {
+			result.flag(LintSeverityWarning, agent.Name, "delegation",
+				fmt.Sprintf("agent %s allows delegation, which has no AgentFlow equivalent", agent.Name),
+				"model the hand-off as an explicit step and edge")
+		}
+	}
+
+	if process == "hierarchical" {
+		result.flag(LintSeverityWarning, "", "hierarchical-process",
+			"hierarchical crews delegate through a manager agent, which is not imported; tasks run in dependency order",
+			"add context lists to tasks so the imported edges express the intended order")
+	}
+
+	stepIDs := make(map[string]string, len(tasks))
+	for _, task := range tasks {
+		stepIDs[task.Name] = importStepID(task.Name)
+	}
+
+	previous := ""
+	for _, task := range tasks {
+		stepID := stepIDs[task.Name]
+		agent, known := byName[task.Agent]
+		if task.Agent != "" && !known {
+			result.flag(LintSeverityError, task.Name, "unknown-agent",
+				fmt.Sprintf("task %s is assigned to unknown agent %s", task.Name, task.Agent),
+				"define the agent under agents or fix the task's agent name")
+		}
+
+		promptName := result.Spec.Name + "-" + stepID
+		step := Step{
+			ID:          stepID,
+			Type:        string(ExecutorTypeLLM),
+			Name:        task.Name,
+			Description: firstLine(task.Description),
+			Retries:     importDefaultRetries,
+			Config:      map[string]interface{}{"prompt_ref": promptName},
+		}
+		if agent.MaxRetryLimit != nil {
+			step.Retries = *agent.MaxRetryLimit
+		}
+		if agent.MaxExecutionTime > 0 {
+			step.Timeout = time.Duration(agent.MaxExecutionTime) * time.Second
+		}
+		if task.Agent != "" {
+			step.Config["agent"] = task.Agent
+		}
+		if agent.LLM != "" {
+			applyImportedModel(step.Config, agent.LLM, agent.LLM)
+		}
+		result.Spec.DAG.Steps = append(result.Spec.DAG.Steps, step)
+		result.Prompts = append(result.Prompts, ImportedPrompt{Name: promptName, Template: crewAIPrompt(agent, task)})
+
+		if len(task.Tools) > 0 {
+			result.flag(LintSeverityWarning, task.Name, "task-tools",
+				fmt.Sprintf("task %s uses tools (%s) that are not imported", task.Name, strings.Join(task.Tools, ", ")),
+				fmt.Sprintf("add tool steps with config.tool_name and edges into %s", stepID))
+		}
+		if task.HumanInput {
+			result.flag(LintSeverityError, task.Name, "human-input",
+				fmt.Sprintf("task %s waits for human input, which runs cannot pause for", task.Name),
+				"drop human_input or split the workflow at this task and start the second half once reviewed")
+		}
+		if task.OutputFile != "" {
+			result.flag(LintSeverityInfo, task.Name, "output-file",
+				fmt.Sprintf("task %s writes %s, which is not imported", task.Name, task.OutputFile),
+				"read the step output from the run result, or add a tool step that stores it")
+		}
+
+		// Sequential crews feed each task the previous output unless context says otherwise
+		dependencies := task.Context
+		if len(dependencies) == 0 && process != "hierarchical" && previous != "" {
+			dependencies = []string{previous}
+		}
+		for _, dep := range dependencies {
+			from, ok := stepIDs[dep]
+			if !ok {
+				result.flag(LintSeverityError, task.Name, "unknown-context",
+					fmt.Sprintf("task %s lists unknown context task %s", task.Name, dep),
+					"fix the name in the task's context list")
+				continue
+			}
+			result.Spec.DAG.Edges = append(result.Spec.DAG.Edges, Edge{From: from, To: stepID})
+		}
+		if !task.AsyncExecution {
+			previous = task.Name
+		}
+	}
+
+	if len(result.Prompts) > 0 {
+		result.flag(LintSeverityInfo, "", "prompts",
+			fmt.Sprintf("%d prompt templates were generated from task descriptions", len(result.Prompts)),
+			"register them with agentctl prompt create, or pass --prompts-dir to write them to files")
+	}
+	dropCycles(result)
+	return nil
+}
+
+// decodeNamed decodes a mapping of name -> definition or a list of definitions
+func decodeNamed[T any](node *yaml.Node, out *[]T, setName func(*T, string)) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			var item T
+			if err := node.Content[i+1].Decode(&item); err != nil {
+				return err
+			}
+			setName(&item, node.Content[i].Value)
+			*out = append(*out, item)
+		}
+	case yaml.SequenceNode:
+		if err := node.Decode(out); err != nil {
+			return err
+		}
+		for i := range *out {
+			var named struct {
+				Name string `yaml:"name"`
+				Role string `yaml:"role"`
+			}
+			_ = node.Content[i].Decode(&named)
+			if named.Name == "" {
+				named.Name = orDefault(named.Role, fmt.Sprintf("item_%d", i+1))
+			}
+			setName(&(*out)[i], named.Name)
+		}
+	default:
+		return fmt.Errorf("expected a mapping or a list")
+	}
+	return nil
+}
+
+// crewAIPrompt builds a template from the agent persona and task, converting
+// CrewAI {placeholders} to template fields
+func crewAIPrompt(agent crewAIAgent, task crewAITask) string {
+	var b strings.Builder
+	if agent.Role != "" {
+		fmt.Fprintf(&b, "You are %s.", strings.TrimSpace(agent.Role))
+		if agent.Backstory != "" {
+			fmt.Fprintf(&b, " %s", strings.TrimSpace(agent.Backstory))
+		}
+		b.WriteString("\n")
+	}
+	if agent.Goal != "" {
+		fmt.Fprintf(&b, "Your goal: %s\n", strings.TrimSpace(agent.Goal))
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Task: %s\n", strings.TrimSpace(task.Description))
+	if task.ExpectedOutput != "" {
+		fmt.Fprintf(&b, "\nExpected output: %s\n", strings.TrimSpace(task.ExpectedOutput))
+	}
+	return crewAIPlaceholder.ReplaceAllString(b.String(), "{{.$1}}")
+}
+
+// applyImportedModel sets provider and model from a model string such as
+// "openai/gpt-4o" or a class name hint such as "ChatAnthropic"
+func applyImportedModel(config map[string]interface{}, hint, model string) {
+	if provider, name, ok := strings.Cut(model, "/"); ok {
+		config["provider"] = provider
+		config["model"] = name
+		return
+	}
+	if model != "" {
+		config["model"] = model
+	}
+	lower := strings.ToLower(hint)
+	for _, h := range importProviderHints {
+		if strings.Contains(lower, h.marker) {
+			config["provider"] = h.provider
+			return
+		}
+	}
+}
+
+func importStepID(name string) string {
+	id := strings.Trim(stepIDUnsafe.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if id == "" {
+		return "step"
+	}
+	return id
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,160 @@
+package aor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a parsed projection over decoded JSON. It supports the subset
+// downstream consumers need: $, .name, ['name'], [n] (negative counts from
+// the end), and the wildcards .* and [*].
+type JSONPath struct {
+	expr     string
+	segments []pathSegment
+}
+
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// ParseJSONPath parses a path such as $.steps.analyze.summary or $.items[*].id
+func ParseJSONPath(expr string) (*JSONPath, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("invalid path %q: must start with $", expr)
+	}
+
+	path := &JSONPath{expr: expr}
+	rest := expr[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			return nil, fmt.Errorf("invalid path %q: recursive descent is not supported", expr)
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid path %q: empty field name", expr)
+			}
+			if name == "*" {
+				path.segments = append(path.segments, pathSegment{wildcard: true})
+			} else {
+				path.segments = append(path.segments, pathSegment{key: name})
+			}
+			rest = rest[end:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed [", expr)
+			}
+			segment, err := parseBracket(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %w", expr, err)
+			}
+			path.segments = append(path.segments, segment)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid path %q: unexpected %q", expr, rest[0])
+		}
+	}
+	return path, nil
+}
+
+func parseBracket(inner string) (pathSegment, error) {
+	inner = strings.TrimSpace(inner)
+	if inner == "*" {
+		return pathSegment{wildcard: true}, nil
+	}
+	if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+		return pathSegment{key: inner[1 : len(inner)-1]}, nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return pathSegment{}, fmt.Errorf("bracket must hold an index, a quoted name or *")
+	}
+	return pathSegment{index: index, isIndex: true}, nil
+}
+
+// String returns the path as written
+func (p *JSONPath) String() string {
+	return p.expr
+}
+
+// Definite reports whether the path selects at most one value
+func (p *JSONPath) Definite() bool {
+	for _, segment := range p.segments {
+		if segment.wildcard {
+			return false
+		}
+	}
+	return true
+}
+
+// root returns the first field the path reads, or "" for $ and wildcards
+func (p *JSONPath) root() string {
+	if len(p.segments) == 0 {
+		return ""
+	}
+	return p.segments[0].key
+}
+
+// Select returns the values the path matches in a decoded JSON document.
+// Wildcards over objects visit keys in sorted order.
+func (p *JSONPath) Select(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+	for _, segment := range p.segments {
+		next := make([]interface{}, 0, len(current))
+		for _, value := range current {
+			next = append(next, segment.apply(value)...)
+		}
+		current = next
+		if len(current) == 0 {
+			break
+		}
+	}
+	return current
+}
+
+func (s pathSegment) apply(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if s.wildcard {
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			matches := make([]interface{}, 0, len(keys))
+			for _, key := range keys {
+				matches = append(matches, v[key])
+			}
+			return matches
+		}
+		if child, ok := v[s.key]; ok && !s.isIndex {
+			return []interface{}{child}
+		}
+	case []interface{}:
+		if s.wildcard {
+			return v
+		}
+		if s.isIndex {
+			index := s.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index >= 0 && index < len(v) {
+				return []interface{}{v[index]}
+			}
+		}
+	}
+	return nil
+}
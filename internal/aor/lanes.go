@@ -0,0 +1,229 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// LaneInteractive is for user-facing runs that someone is waiting on
+	LaneInteractive = "interactive"
+	// LaneBatch is for bulk submissions such as batch runs
+	LaneBatch = "batch"
+	// LaneBackground is for scheduled and maintenance work
+	LaneBackground = "background"
+	// DefaultLane applies to runs that don't name a lane
+	DefaultLane = LaneInteractive
+
+	laneKeyPrefix     = "tasks:lane:"
+	laneSLOPrefix     = "tasks:lane_slo:"
+	laneSLOBucket     = time.Hour
+	laneSLOWindow     = 24 * time.Hour
+	laneSLOBucketTTL  = laneSLOWindow + laneSLOBucket
+	laneSLODispatched = "dispatched"
+	laneSLOWithinGoal = "within_target"
+)
+
+var laneNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// Lane is a dispatch class with its own queue wait objective. A lane's
+// reserved slots can only be used by its tasks, so batch fan-out can never
+// take the capacity interactive runs need.
+type Lane struct {
+	Name            string        `json:"name"`
+	Priority        int           `json:"priority"`
+	TargetWait      time.Duration `json:"target_wait"`
+	ReservedPercent int           `json:"reserved_percent"`
+	ReservedSlots   int           `json:"reserved_slots"`
+}
+
+// LaneStats reports a lane's queue state and how often tasks were dispatched
+// within the lane's target wait over the last 24 hours
+type LaneStats struct {
+	Lane
+	Depth        int64         `json:"depth"`
+	InFlight     int64         `json:"in_flight"`
+	OldestWait   time.Duration `json:"oldest_wait"`
+	Dispatched   int64         `json:"dispatched"`
+	WithinTarget int64         `json:"within_target"`
+	Attainment   float64       `json:"attainment"` // Fraction dispatched within target; 1 when idle
+}
+
+// BuildLanes validates the configured lanes and sizes their reservations
+// against the in-flight cap. Lanes are returned in dispatch order.
+func BuildLanes(cfg map[string]config.LaneConfig, maxInFlight int) ([]Lane, error) {
+	lanes := make([]Lane, 0, len(cfg))
+	total := 0
+	for name, lc := range cfg {
+		if !laneNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid lane name %q", name)
+		}
+		if lc.TargetWait <= 0 {
+			return nil, fmt.Errorf("lane %s must have a positive target_wait", name)
+		}
+		if lc.ReservedPercent < 0 || lc.ReservedPercent > 100 {
+			return nil, fmt.Errorf("lane %s reserved_percent must be between 0 and 100", name)
+		}
+		total += lc.ReservedPercent
+		lanes = append(lanes, Lane{
+			Name:            name,
+			Priority:        lc.Priority,
+			TargetWait:      lc.TargetWait,
+			ReservedPercent: lc.ReservedPercent,
+			ReservedSlots:   maxInFlight * lc.ReservedPercent / 100,
+		})
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("lane reservations add up to %d%% of in-flight capacity", total)
+	}
+	if _, ok := cfg[DefaultLane]; !ok {
+		return nil, fmt.Errorf("the %s lane must be configured", DefaultLane)
+	}
+
+	sort.Slice(lanes, func(i, j int) bool {
+		if lanes[i].Priority != lanes[j].Priority {
+			return lanes[i].Priority < lanes[j].Priority
+		}
+		return lanes[i].Name < lanes[j].Name
+	})
+	return lanes, nil
+}
+
+// resolveRunLane picks a run's lane: an explicit request wins, then the
+// spec's lane, then a default for the trigger
+func resolveRunLane(lanes []Lane, spec *WorkflowSpec, req *RunRequest) (string, error) {
+	known := func(name string) bool {
+		for _, lane := range lanes {
+			if lane.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, name := range []string{req.Lane, spec.Metadata.Lane} {
+		if name == "" {
+			continue
+		}
+		if !known(name) {
+			return "", fmt.Errorf("unknown lane %q", name)
+		}
+		return name, nil
+	}
+
+	switch req.Trigger {
+	case TriggerBatch:
+		if known(LaneBatch) {
+			return LaneBatch, nil
+		}
+	case TriggerCron:
+		if known(LaneBackground) {
+			return LaneBackground, nil
+		}
+	}
+	return DefaultLane, nil
+}
+
+// runLane returns the lane recorded on a run at submission
+func runLane(metadata map[string]interface{}) string {
+	lane, _ := metadata["lane"].(string)
+	return lane
+}
+
+// laneKey scopes a queue key to a lane, e.g. tasks:lane:batch:tenants
+func laneKey(lane, suffix string) string {
+	return laneKeyPrefix + lane + ":" + suffix
+}
+
+func laneSLOKey(lane string, at time.Time) string {
+	return laneSLOPrefix + lane + ":" + strconv.FormatInt(at.Truncate(laneSLOBucket).Unix(), 10)
+}
+
+// laneAttainment is the fraction of dispatches that met the target wait
+func laneAttainment(dispatched, within int64) float64 {
+	if dispatched == 0 {
+		return 1
+	}
+	return float64(within) / float64(dispatched)
+}
+
+// recordLaneWait counts a dispatch against the lane's wait objective
+func (q *TenantQueue) recordLaneWait(ctx context.Context, lane Lane, wait time.Duration) {
+	met := wait <= lane.TargetWait
+	outcome := "missed"
+	if met {
+		outcome = "met"
+	}
+	laneQueueWait.Observe(wait.Seconds(), lane.Name)
+	laneDispatches.Inc(lane.Name, outcome)
+
+	key := laneSLOKey(lane.Name, time.Now())
+	pipe := q.redis.Pipeline()
+	pipe.HIncrBy(ctx, key, laneSLODispatched, 1)
+	if met {
+		pipe.HIncrBy(ctx, key, laneSLOWithinGoal, 1)
+	}
+	pipe.Expire(ctx, key, laneSLOBucketTTL)
+	_, _ = pipe.Exec(ctx)
+}
+
+// LaneStats reports depth, in-flight tasks and SLO attainment for every lane
+func (q *TenantQueue) LaneStats(ctx context.Context) ([]LaneStats, error) {
+	now := time.Now()
+	stats := make([]LaneStats, 0, len(q.lanes))
+	for _, lane := range q.lanes {
+		s := LaneStats{Lane: lane}
+
+		orgs, err := q.redis.ZRange(ctx, laneKey(lane.Name, "tenants"), 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list lane queues: %w", err)
+		}
+		for _, org := range orgs {
+			depth, oldest, err := q.orgQueueState(ctx, lane.Name, org, now)
+			if err != nil {
+				return nil, err
+			}
+			s.Depth += depth
+			if oldest > s.OldestWait {
+				s.OldestWait = oldest
+			}
+		}
+
+		s.InFlight, err = q.redis.ZCount(ctx, laneKey(lane.Name, "inflight"), strconv.FormatInt(now.Unix(), 10), "+inf").Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count in-flight tasks: %w", err)
+		}
+
+		pipe := q.redis.Pipeline()
+		buckets := make([]*redis.MapStringStringCmd, 0, int(laneSLOWindow/laneSLOBucket))
+		for at := now.Add(-laneSLOWindow + laneSLOBucket); !at.After(now); at = at.Add(laneSLOBucket) {
+			buckets = append(buckets, pipe.HGetAll(ctx, laneSLOKey(lane.Name, at)))
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("failed to read lane attainment: %w", err)
+		}
+		for _, bucket := range buckets {
+			counts := bucket.Val()
+			dispatched, _ := strconv.ParseInt(counts[laneSLODispatched], 10, 64)
+			within, _ := strconv.ParseInt(counts[laneSLOWithinGoal], 10, 64)
+			s.Dispatched += dispatched
+			s.WithinTarget += within
+		}
+		s.Attainment = laneAttainment(s.Dispatched, s.WithinTarget)
+
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// GetLaneStats reports per-lane queue wait SLO attainment
+func (cp *ControlPlane) GetLaneStats(ctx context.Context) ([]LaneStats, error) {
+	return cp.queue.LaneStats(ctx)
+}
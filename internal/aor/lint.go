@@ -0,0 +1,432 @@
+package aor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LintSeverity ranks how serious a lint finding is
+type LintSeverity string
+
+const (
+	LintSeverityError   LintSeverity = "error"
+	LintSeverityWarning LintSeverity = "warning"
+	LintSeverityInfo    LintSeverity = "info"
+)
+
+// Lint rule identifiers
+const (
+	LintRuleLLMWithoutRetries = "llm-without-retries"
+	LintRuleMissingTimeout    = "missing-timeout"
+	LintRuleUnboundedFanOut   = "unbounded-fan-out"
+	LintRuleGoldClassifier    = "gold-tier-classification"
+	LintRuleInlineSecret      = "inline-secret"
+	LintRuleMissingBudgetHint = "missing-budget-hint"
+	LintRuleInvalidEnsemble   = "invalid-ensemble"
+	LintRuleInvalidSampling   = "invalid-sampling"
+	LintRuleInvalidHedge      = "invalid-hedge"
+	LintRuleInvalidRefusal    = "invalid-refusal-policy"
+	LintRuleInvalidBudget     = "invalid-token-budget"
+	LintRuleInvalidSandbox    = "invalid-sandbox"
+	LintRuleInvalidRAG        = "invalid-rag-stage"
+	LintRuleInvalidCache      = "invalid-prompt-cache"
+	LintRuleInvalidResponses  = "invalid-response-cache"
+	LintRuleInvalidReasoning  = "invalid-reasoning"
+	LintRuleInvalidMedia      = "invalid-media"
+	LintRuleInvalidTranscribe = "invalid-transcribe"
+	LintRuleInvalidAssert     = "invalid-assert"
+	LintRuleInvalidRetry      = "invalid-retry-policy"
+	LintRuleInvalidFallback   = "invalid-budget-fallback"
+	LintRuleTimeoutHistory    = "timeout-history"
+	LintRuleInvalidPostProc   = "invalid-post-process"
+)
+
+// LintFinding is a single anti-pattern detected in a workflow spec
+type LintFinding struct {
+	Rule       string       `json:"rule"`
+	Severity   LintSeverity `json:"severity"`
+	StepID     string       `json:"step_id,omitempty"`
+	Message    string       `json:"message"`
+	Suggestion string       `json:"suggestion"`
+}
+
+// LintReport collects the findings for a spec
+type LintReport struct {
+	Workflow string        `json:"workflow"`
+	Findings []LintFinding `json:"findings"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+	Infos    int           `json:"infos"`
+}
+
+var (
+	secretKeyPattern   = regexp.MustCompile(`(?i)(secret|token|password|passwd|api[_-]?key|private[_-]?key|credential)`)
+	secretValuePattern = regexp.MustCompile(`^(sk-|sk_live_|ghp_|xox[bap]-|AKIA)[A-Za-z0-9_\-]{8,}`)
+)
+
+// LintWorkflowSpec checks a spec for common anti-patterns
+func LintWorkflowSpec(spec *WorkflowSpec) *LintReport {
+	report := &LintReport{Workflow: spec.Name, Findings: make([]LintFinding, 0)}
+
+	llmSteps := 0
+	budgetHinted := hasBudgetHint(spec.Metadata.Labels)
+
+	for _, step := range spec.DAG.Steps {
+		if ExecutorType(step.Type) == ExecutorTypeLLM {
+			llmSteps++
+			if configHasAny(step.Config, "max_cost_cents", "budget_cents") {
+				budgetHinted = true
+			}
+			if _, ok := step.Config["samples"]; ok && llmSampleCount(step.Config) > 1 {
+				if _, err := parseSamplingConfig(step.Config); err != nil {
+					report.add(LintFinding{
+						Rule:       LintRuleInvalidSampling,
+						Severity:   LintSeverityError,
+						StepID:     step.ID,
+						Message:    fmt.Sprintf("best-of-N sampling config is invalid: %v", err),
+						Suggestion: fmt.Sprintf("use between 2 and %d samples, and a judge when strategy is judge", maxEnsembleMembers),
+					})
+				}
+			}
+			if _, err := parseHedgePolicy(step.Config); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidHedge,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("hedge config is invalid: %v", err),
+					Suggestion: "set hedge.provider, hedge.model and hedge.after to p50, p95, p99 or a duration",
+				})
+			}
+			if _, err := parseRefusalPolicy(step.Config); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidRefusal,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("on_refusal config is invalid: %v", err),
+					Suggestion: "map safety_refusal, policy_block or length to fail, retry, reroute or accept, with reroute: {provider, model} when rerouting",
+				})
+			}
+			if _, err := parseResponseCachePolicy(step.Config); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidResponses,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("response_cache config is invalid: %v", err),
+					Suggestion: "set response_cache to true or {ttl, privacy} with privacy org, project or user",
+				})
+			}
+			if _, err := parseBudgetFallback(step.Config); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidFallback,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("budget_fallback config is invalid: %v", err),
+					Suggestion: "list the cheaper models to degrade to as budget_fallback: [{provider, model}]",
+				})
+			}
+			if _, err := parseTokenBudget(step.Config); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidBudget,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("token_budget config is invalid: %v", err),
+					Suggestion: "set token_budget.max_total_tokens above the step's max_tokens and on_exceed to compress or fail",
+				})
+			}
+			if reasoning, err := parseReasoningConfig(step.Config); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidReasoning,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("reasoning config is invalid: %v", err),
+					Suggestion: "set reasoning.effort to low, medium or high, or reasoning.budget_tokens to at least 1024",
+				})
+			} else if provider, model := stepProviderModel(step.Config); reasoning != nil && !supportsReasoning(provider, model) {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidReasoning,
+					Severity:   LintSeverityWarning,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("%s/%s is not a reasoning model, so reasoning is not sent", provider, model),
+					Suggestion: "use an o-series, Claude extended thinking or Gemini 2.5 model, or remove reasoning",
+				})
+			}
+			if _, err := parsePromptCache(step.Config); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidCache,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("prompt_cache config is invalid: %v", err),
+					Suggestion: "set prompt_cache to true, or to {ttl: 5m} or {ttl: 1h}",
+				})
+			}
+			if _, err := parseMediaPolicy(step.Config); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidMedia,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("media config is invalid: %v", err),
+					Suggestion: "set media.max_bytes to a positive size and media.redaction to allow, block or blur",
+				})
+			}
+			if step.Retries == 0 && !configHasAny(step.Config, "retries", "retry_policy") {
+				report.add(LintFinding{
+					Rule:       LintRuleLLMWithoutRetries,
+					Severity:   LintSeverityWarning,
+					StepID:     step.ID,
+					Message:    "LLM step has no retries; transient provider errors will fail the run",
+					Suggestion: "set retries: 2 or more",
+				})
+			}
+		}
+
+		if _, err := parseNodePolicy(step); err != nil {
+			report.add(LintFinding{
+				Rule:       LintRuleInvalidRetry,
+				Severity:   LintSeverityError,
+				StepID:     step.ID,
+				Message:    fmt.Sprintf("retry policy is invalid: %v", err),
+				Suggestion: fmt.Sprintf("use 0 to %d retries, positive backoffs with max_backoff at least initial_backoff, a multiplier of at least 1 and jitter between 0 and 1", maxStepRetries),
+			})
+		}
+
+		if err := validateRAGConfig(step.Type, step.Config); err != nil {
+			report.add(LintFinding{
+				Rule:       LintRuleInvalidRAG,
+				Severity:   LintSeverityError,
+				StepID:     step.ID,
+				Message:    fmt.Sprintf("%s config is invalid: %v", step.Type, err),
+				Suggestion: "give rag_retrieve a collection, keep overlap below chunk_size and scrub to off, standard or strict",
+			})
+		}
+
+		if ExecutorType(step.Type) == ExecutorTypeTranscribe {
+			if _, err := parseTranscribeConfig(step.Config); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidTranscribe,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("transcribe config is invalid: %v", err),
+					Suggestion: "use provider openai or deepgram and keep chunk_seconds between 30 and 1800",
+				})
+			}
+		}
+
+		if ExecutorType(step.Type) == ExecutorTypeAssert {
+			if err := validateAssertStep(step, spec.DAG.Edges); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidAssert,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("assert config is invalid: %v", err),
+					Suggestion: "give each assertion a $ path and one of count, range or schema, and list only the step's direct successors as remediation",
+				})
+			}
+		}
+
+		if ExecutorType(step.Type) == ExecutorTypeEnsemble {
+			llmSteps++
+			if _, err := parseEnsembleConfig(step.Config); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidEnsemble,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("ensemble config is invalid: %v", err),
+					Suggestion: "list at least 2 members with provider and model, and a judge when strategy is judge",
+				})
+			}
+		}
+
+		if sandboxedStep(step.Type) {
+			if _, err := parseSandboxPolicy(step.Config); err != nil {
+				report.add(LintFinding{
+					Rule:       LintRuleInvalidSandbox,
+					Severity:   LintSeverityError,
+					StepID:     step.ID,
+					Message:    fmt.Sprintf("sandbox config is invalid: %v", err),
+					Suggestion: "set sandbox.network.egress to deny or allow with allow: [host, host:port, *.domain], and absolute sandbox.filesystem.writable paths",
+				})
+			}
+		}
+
+		if step.Timeout == 0 && !configHasAny(step.Config, "timeout", "timeout_ms") {
+			report.add(LintFinding{
+				Rule:       LintRuleMissingTimeout,
+				Severity:   LintSeverityWarning,
+				StepID:     step.ID,
+				Message:    "step has no timeout and falls back to the 30 minute task deadline",
+				Suggestion: "set timeout to the expected upper bound, e.g. timeout: 60s",
+			})
+		}
+
+		if step.Type == "map" && !configHasAny(step.Config, "max_concurrency", "max_items") {
+			report.add(LintFinding{
+				Rule:       LintRuleUnboundedFanOut,
+				Severity:   LintSeverityError,
+				StepID:     step.ID,
+				Message:    "map step fans out over its input without a bound",
+				Suggestion: "set config.max_concurrency and config.max_items",
+			})
+		}
+
+		if isGoldTier(step.Config) && isClassificationStep(step) {
+			report.add(LintFinding{
+				Rule:       LintRuleGoldClassifier,
+				Severity:   LintSeverityWarning,
+				StepID:     step.ID,
+				Message:    "classification step uses Gold quality tier",
+				Suggestion: "use quality: Bronze or Silver; classification rarely benefits from Gold models",
+			})
+		}
+
+		for _, key := range inlineSecrets(step.Config) {
+			report.add(LintFinding{
+				Rule:       LintRuleInlineSecret,
+				Severity:   LintSeverityError,
+				StepID:     step.ID,
+				Message:    fmt.Sprintf("env var %s contains an inlined secret", key),
+				Suggestion: fmt.Sprintf("reference a secret instead, e.g. %s: ${secret:%s}", key, strings.ToLower(key)),
+			})
+		}
+	}
+
+	for _, name := range environmentNames(spec.Metadata.Environments) {
+		profile := spec.Metadata.Environments[name]
+		if profile.BudgetCents > 0 {
+			budgetHinted = true
+		}
+
+		env := make(map[string]interface{}, len(profile.Env))
+		for k, v := range profile.Env {
+			env[k] = v
+		}
+		for _, key := range inlineSecrets(map[string]interface{}{"env": env}) {
+			report.add(LintFinding{
+				Rule:       LintRuleInlineSecret,
+				Severity:   LintSeverityError,
+				Message:    fmt.Sprintf("env var %s in environment %s contains an inlined secret", key, name),
+				Suggestion: fmt.Sprintf("reference a secret instead, e.g. %s: ${secret:%s}", key, strings.ToLower(key)),
+			})
+		}
+	}
+
+	if err := ValidatePostProcessors(spec.Metadata.PostProcess); err != nil {
+		report.add(LintFinding{
+			Rule:       LintRuleInvalidPostProc,
+			Severity:   LintSeverityError,
+			Message:    fmt.Sprintf("post_process config is invalid: %v", err),
+			Suggestion: fmt.Sprintf("use processor types %s", strings.Join(OutputProcessorTypes(), ", ")),
+		})
+	}
+	for i, processor := range spec.Metadata.PostProcess {
+		if source, _ := processor.Config["source_step"].(string); source != "" && findStep(spec.DAG.Steps, source) == nil {
+			report.add(LintFinding{
+				Rule:       LintRuleInvalidPostProc,
+				Severity:   LintSeverityError,
+				Message:    fmt.Sprintf("post_process[%d] %s reads chunks from unknown step %s", i, processor.Type, source),
+				Suggestion: "set source_step to the step that retrieves the chunks, e.g. a rag_retrieve step",
+			})
+		}
+	}
+
+	if llmSteps > 0 && !budgetHinted {
+		report.add(LintFinding{
+			Rule:       LintRuleMissingBudgetHint,
+			Severity:   LintSeverityInfo,
+			Message:    "workflow has LLM steps but no budget hint",
+			Suggestion: "add a budget_cents label, an environment budget_cents or config.max_cost_cents on LLM steps",
+		})
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return severityRank(report.Findings[i].Severity) < severityRank(report.Findings[j].Severity)
+	})
+
+	return report
+}
+
+// Exceeds reports whether any finding is at or above the given severity
+func (r *LintReport) Exceeds(threshold LintSeverity) bool {
+	for _, finding := range r.Findings {
+		if severityRank(finding.Severity) <= severityRank(threshold) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *LintReport) add(finding LintFinding) {
+	r.Findings = append(r.Findings, finding)
+	switch finding.Severity {
+	case LintSeverityError:
+		r.Errors++
+	case LintSeverityWarning:
+		r.Warnings++
+	default:
+		r.Infos++
+	}
+}
+
+func severityRank(severity LintSeverity) int {
+	switch severity {
+	case LintSeverityError:
+		return 0
+	case LintSeverityWarning:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func configHasAny(config map[string]interface{}, keys ...string) bool {
+	for _, key := range keys {
+		if _, ok := config[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hasBudgetHint(labels map[string]string) bool {
+	_, ok := labels["budget_cents"]
+	return ok
+}
+
+func isGoldTier(config map[string]interface{}) bool {
+	for _, key := range []string{"quality", "quality_tier", "tier"} {
+		if tier, ok := config[key].(string); ok && strings.EqualFold(tier, "gold") {
+			return true
+		}
+	}
+	return false
+}
+
+func isClassificationStep(step Step) bool {
+	if task, ok := step.Config["task"].(string); ok && strings.Contains(strings.ToLower(task), "classif") {
+		return true
+	}
+	return strings.Contains(strings.ToLower(step.ID+" "+step.Name), "classif")
+}
+
+// inlineSecrets returns env var names whose values look like literal secrets
+func inlineSecrets(config map[string]interface{}) []string {
+	env, ok := config["env"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0)
+	for key, v := range env {
+		value, ok := v.(string)
+		if !ok || value == "" || strings.HasPrefix(value, "${") || strings.HasPrefix(value, "secret://") {
+			continue
+		}
+		if secretKeyPattern.MatchString(key) || secretValuePattern.MatchString(value) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
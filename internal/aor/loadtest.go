@@ -0,0 +1,367 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aos"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/google/uuid"
+)
+
+const (
+	// LoadTestWorkflow names the synthetic workflow applied for load tests
+	LoadTestWorkflow = "agentflow-loadtest"
+	// loadTestEnvironment routes the synthetic workflow's LLM calls to the mock provider
+	loadTestEnvironment = "loadtest"
+
+	defaultLoadTestMockProvider = "mock://?latency=200ms&seed=loadtest"
+	defaultLoadTestPollInterval = time.Second
+	defaultLoadTestDrainTimeout = 5 * time.Minute
+	maxLoadTestRate             = 1000
+)
+
+// LoadTestConfig describes the synthetic traffic to generate
+type LoadTestConfig struct {
+	OrgID        uuid.UUID     `json:"org_id"`
+	Workflow     string        `json:"workflow"`      // Workflow to submit, the synthetic one when empty
+	Rate         float64       `json:"rate"`          // Runs submitted per second
+	Duration     time.Duration `json:"duration"`      // How long to submit for
+	Steps        int           `json:"steps"`         // LLM steps per synthetic run
+	MockProvider string        `json:"mock_provider"` // Mock answering the synthetic workflow's LLM calls
+	DrainTimeout time.Duration `json:"drain_timeout"` // How long to wait for runs and traces after submitting
+	PollInterval time.Duration `json:"poll_interval"` // Resolution of the run, step and trace timings
+}
+
+// Validate checks the config and fills in defaults
+func (c *LoadTestConfig) Validate() error {
+	if c.Rate <= 0 || c.Rate > maxLoadTestRate {
+		return fmt.Errorf("rate must be above 0 and at most %d runs per second", maxLoadTestRate)
+	}
+	if c.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if c.Steps <= 0 {
+		c.Steps = 3
+	}
+	if c.MockProvider == "" {
+		c.MockProvider = defaultLoadTestMockProvider
+	}
+	if _, err := ParseMockProvider(c.MockProvider); err != nil {
+		return err
+	}
+	if c.DrainTimeout <= 0 {
+		c.DrainTimeout = defaultLoadTestDrainTimeout
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultLoadTestPollInterval
+	}
+	return nil
+}
+
+// SyntheticLoadTestSpec returns a chain of LLM steps whose calls the mock
+// provider answers, so load tests spend nothing on real providers
+func SyntheticLoadTestSpec(cfg LoadTestConfig) *WorkflowSpec {
+	spec := &WorkflowSpec{
+		Name: LoadTestWorkflow,
+		Metadata: Metadata{
+			Description: "Synthetic workflow generated by agentctl loadtest",
+			Labels:      map[string]string{"purpose": "loadtest"},
+			Environments: map[string]EnvironmentProfile{
+				loadTestEnvironment: {MockProvider: cfg.MockProvider},
+			},
+		},
+	}
+	for i := 1; i <= cfg.Steps; i++ {
+		id := fmt.Sprintf("step_%d", i)
+		spec.DAG.Steps = append(spec.DAG.Steps, Step{
+			ID:      id,
+			Type:    string(ExecutorTypeLLM),
+			Name:    id,
+			Config:  map[string]interface{}{"provider": "openai", "model": "gpt-4o-mini", "prompt": "Summarize {{.input}}"},
+			Timeout: time.Minute,
+			Retries: 1,
+		})
+		if i > 1 {
+			spec.DAG.Edges = append(spec.DAG.Edges, Edge{From: fmt.Sprintf("step_%d", i-1), To: id})
+		}
+	}
+	return spec
+}
+
+// RunObservation is what a load test target reports about one run
+type RunObservation struct {
+	Status         WorkflowStatus
+	CreatedAt      time.Time
+	FirstStepAt    *time.Time // When a worker first started one of its steps
+	EndedAt        *time.Time
+	StepsCompleted int
+	TraceEvents    int // Events ingested for the run so far
+}
+
+// LoadTestTarget is the deployment a load test drives
+type LoadTestTarget interface {
+	SubmitWorkflow(ctx context.Context, req *RunRequest) (*WorkflowRun, error)
+	ObserveRun(ctx context.Context, orgID, runID uuid.UUID) (*RunObservation, error)
+}
+
+// LoadTestReport summarizes a load test for capacity planning. Timings
+// measured by polling are accurate to the poll interval.
+type LoadTestReport struct {
+	Config         LoadTestConfig `json:"config"`
+	StartedAt      time.Time      `json:"started_at"`
+	FinishedAt     time.Time      `json:"finished_at"`
+	Submitted      int            `json:"submitted"`
+	SubmitErrors   int            `json:"submit_errors"`
+	AchievedRate   float64        `json:"achieved_rate"` // Runs submitted per second
+	Completed      int            `json:"completed"`
+	Failed         int            `json:"failed"`
+	Unfinished     int            `json:"unfinished"` // Still running when the drain timeout passed
+	StepsCompleted int            `json:"steps_completed"`
+	StepThroughput float64        `json:"step_throughput"` // Steps completed per second
+	TracesMissing  int            `json:"traces_missing"`  // Finished runs with no trace events by the end
+
+	SubmitLatency     cas.LatencyPercentiles `json:"submit_latency"`
+	SchedulingLatency cas.LatencyPercentiles `json:"scheduling_latency"` // Run creation to first step start
+	RunDuration       cas.LatencyPercentiles `json:"run_duration"`
+	TraceLag          cas.LatencyPercentiles `json:"trace_lag"` // Run end to first trace event being queryable
+
+	Errors []string `json:"errors,omitempty"` // A sample of submission errors
+}
+
+type loadTestRun struct {
+	id        uuid.UUID
+	orgID     uuid.UUID
+	observed  *RunObservation
+	finished  bool
+	traceSeen *time.Time
+}
+
+// RunLoadTest submits runs at the configured rate for the configured
+// duration, then follows them until they finish and their traces are
+// ingested or the drain timeout passes
+func RunLoadTest(ctx context.Context, target LoadTestTarget, cfg LoadTestConfig) (*LoadTestReport, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	workflow, environment := cfg.Workflow, ""
+	if workflow == "" {
+		workflow, environment = LoadTestWorkflow, loadTestEnvironment
+	}
+	report := &LoadTestReport{Config: cfg, StartedAt: time.Now()}
+
+	var (
+		mu             sync.Mutex
+		wg             sync.WaitGroup
+		runs           []*loadTestRun
+		submitLatency  []time.Duration
+		submitDeadline = report.StartedAt.Add(cfg.Duration)
+		ticker         = time.NewTicker(time.Duration(float64(time.Second) / cfg.Rate))
+	)
+	defer ticker.Stop()
+
+	// Submissions run concurrently so a slow control plane shows up as
+	// submit latency rather than as a lower rate
+	for submitting := true; submitting; {
+		select {
+		case <-ctx.Done():
+			submitting = false
+		case now := <-ticker.C:
+			if now.After(submitDeadline) {
+				submitting = false
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := &RunRequest{
+					WorkflowName: workflow,
+					Inputs:       map[string]interface{}{"input": fmt.Sprintf("load test payload %s", uuid.NewString())},
+					Tags:         []string{"loadtest"},
+					Labels:       map[string]string{"purpose": "loadtest"},
+					Environment:  environment,
+					Trigger:      TriggerManual,
+				}
+				start := time.Now()
+				run, err := target.SubmitWorkflow(ctx, req)
+				elapsed := time.Since(start)
+
+				mu.Lock()
+				defer mu.Unlock()
+				report.Submitted++
+				if err != nil {
+					report.SubmitErrors++
+					if len(report.Errors) < 10 {
+						report.Errors = append(report.Errors, err.Error())
+					}
+					return
+				}
+				submitLatency = append(submitLatency, elapsed)
+				runs = append(runs, &loadTestRun{id: run.ID, orgID: run.OrgID})
+			}()
+		}
+	}
+	wg.Wait()
+	if elapsed := time.Since(report.StartedAt).Seconds(); elapsed > 0 {
+		report.AchievedRate = float64(report.Submitted) / elapsed
+	}
+
+	drainDeadline := time.Now().Add(cfg.DrainTimeout)
+	for {
+		pending := observeLoadTestRuns(ctx, target, runs)
+		if pending == 0 || time.Now().After(drainDeadline) || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(cfg.PollInterval):
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	summarizeLoadTest(report, runs, submitLatency)
+	return report, nil
+}
+
+// observeLoadTestRuns refreshes unfinished runs and runs awaiting their
+// trace, returning how many are still pending
+func observeLoadTestRuns(ctx context.Context, target LoadTestTarget, runs []*loadTestRun) int {
+	pending := 0
+	for _, run := range runs {
+		if run.finished && run.traceSeen != nil {
+			continue
+		}
+		observed, err := target.ObserveRun(ctx, run.orgID, run.id)
+		if err != nil {
+			log.Printf("Failed to observe load test run %s: %v", run.id, err)
+			pending++
+			continue
+		}
+		now := time.Now()
+		run.observed = observed
+		run.finished = observed.EndedAt != nil && isTerminalStatus(observed.Status)
+		if run.finished && run.traceSeen == nil && observed.TraceEvents > 0 {
+			run.traceSeen = &now
+		}
+		if !run.finished || run.traceSeen == nil {
+			pending++
+		}
+	}
+	return pending
+}
+
+func isTerminalStatus(status WorkflowStatus) bool {
+	switch status {
+	case WorkflowStatusCompleted, WorkflowStatusFailed, WorkflowStatusCancelled, WorkflowStatusBudgetExceeded, "canceled", "partial-success":
+		return true
+	}
+	return false
+}
+
+func summarizeLoadTest(report *LoadTestReport, runs []*loadTestRun, submitLatency []time.Duration) {
+	var scheduling, durations, traceLag []time.Duration
+	var lastEnd time.Time
+	for _, run := range runs {
+		observed := run.observed
+		if observed == nil {
+			report.Unfinished++
+			continue
+		}
+		report.StepsCompleted += observed.StepsCompleted
+		if observed.FirstStepAt != nil {
+			scheduling = append(scheduling, observed.FirstStepAt.Sub(observed.CreatedAt))
+		}
+		if !run.finished {
+			report.Unfinished++
+			continue
+		}
+		if observed.Status == WorkflowStatusCompleted {
+			report.Completed++
+		} else {
+			report.Failed++
+		}
+		durations = append(durations, observed.EndedAt.Sub(observed.CreatedAt))
+		if observed.EndedAt.After(lastEnd) {
+			lastEnd = *observed.EndedAt
+		}
+		if run.traceSeen == nil {
+			report.TracesMissing++
+		} else {
+			traceLag = append(traceLag, run.traceSeen.Sub(*observed.EndedAt))
+		}
+	}
+
+	if lastEnd.IsZero() {
+		lastEnd = report.FinishedAt
+	}
+	if window := lastEnd.Sub(report.StartedAt).Seconds(); window > 0 {
+		report.StepThroughput = float64(report.StepsCompleted) / window
+	}
+	report.SubmitLatency = durationPercentiles(submitLatency)
+	report.SchedulingLatency = durationPercentiles(scheduling)
+	report.RunDuration = durationPercentiles(durations)
+	report.TraceLag = durationPercentiles(traceLag)
+}
+
+// durationPercentiles summarizes exact samples with nearest-rank percentiles
+func durationPercentiles(samples []time.Duration) cas.LatencyPercentiles {
+	if len(samples) == 0 {
+		return cas.LatencyPercentiles{}
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	rank := func(p float64) time.Duration {
+		i := int(p*float64(len(sorted))+0.999999) - 1
+		return sorted[max(0, min(i, len(sorted)-1))]
+	}
+	return cas.LatencyPercentiles{
+		Count: uint64(len(sorted)),
+		Mean:  total / time.Duration(len(sorted)),
+		P50:   rank(0.50),
+		P95:   rank(0.95),
+		P99:   rank(0.99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// deploymentLoadTestTarget drives a deployment through its control plane and
+// reads trace ingestion through the observability service
+type deploymentLoadTestTarget struct {
+	*ControlPlane
+	traces *aos.Service
+}
+
+func NewLoadTestTarget(cp *ControlPlane, traces *aos.Service) LoadTestTarget {
+	return &deploymentLoadTestTarget{ControlPlane: cp, traces: traces}
+}
+
+func (t *deploymentLoadTestTarget) ObserveRun(ctx context.Context, orgID, runID uuid.UUID) (*RunObservation, error) {
+	run, err := t.GetWorkflowRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	observed := &RunObservation{Status: run.Status, CreatedAt: run.CreatedAt, EndedAt: run.EndedAt}
+
+	query := `SELECT MIN(started_at), COUNT(*) FILTER (WHERE status = 'succeeded')
+			  FROM step_run WHERE workflow_run_id = $1`
+	if err := t.db.QueryRowContext(ctx, query, runID).Scan(&observed.FirstStepAt, &observed.StepsCompleted); err != nil {
+		return nil, fmt.Errorf("failed to read step runs: %w", err)
+	}
+
+	if observed.EndedAt != nil && t.traces != nil {
+		trace, err := t.traces.GetRunTrace(ctx, orgID, runID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read run trace: %w", err)
+		}
+		observed.TraceEvents = len(trace.Events)
+	}
+	return observed, nil
+}
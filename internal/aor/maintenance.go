@@ -0,0 +1,260 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// tenantMaintenanceKey holds every maintenance hold, keyed by its scope field
+	tenantMaintenanceKey = "tasks:maintenance"
+
+	maintenanceAllField   = "all"
+	maintenanceLanePrefix = "lane:"
+	maintenanceOrgPrefix  = "org:"
+
+	// maintenanceDrainPoll is how often a drain checks for in-flight tasks
+	maintenanceDrainPoll = time.Second
+)
+
+// MaintenanceScope is what a maintenance hold stops dispatching
+type MaintenanceScope string
+
+const (
+	MaintenanceScopeAll  MaintenanceScope = "all"
+	MaintenanceScopeLane MaintenanceScope = "lane"
+	MaintenanceScopeOrg  MaintenanceScope = "org"
+)
+
+// MaintenanceTarget names the queue or org a hold applies to
+type MaintenanceTarget struct {
+	Scope MaintenanceScope `json:"scope"`
+	Lane  string           `json:"lane,omitempty"`
+	OrgID uuid.UUID        `json:"org_id,omitempty"`
+}
+
+// Validate checks the target against the configured lanes
+func (t MaintenanceTarget) Validate(lanes []Lane) error {
+	switch t.Scope {
+	case MaintenanceScopeAll:
+		return nil
+	case MaintenanceScopeLane:
+		for _, lane := range lanes {
+			if lane.Name == t.Lane {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown lane %q", t.Lane)
+	case MaintenanceScopeOrg:
+		if t.OrgID == uuid.Nil {
+			return fmt.Errorf("org maintenance requires an org ID")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid maintenance scope %q", t.Scope)
+	}
+}
+
+func (t MaintenanceTarget) String() string {
+	switch t.Scope {
+	case MaintenanceScopeLane:
+		return "lane " + t.Lane
+	case MaintenanceScopeOrg:
+		return "org " + t.OrgID.String()
+	default:
+		return "all queues"
+	}
+}
+
+// field is the hold's key in tenantMaintenanceKey, which the dispatcher and
+// dequeue script match on
+func (t MaintenanceTarget) field() string {
+	switch t.Scope {
+	case MaintenanceScopeLane:
+		return maintenanceLanePrefix + t.Lane
+	case MaintenanceScopeOrg:
+		return maintenanceOrgPrefix + t.OrgID.String()
+	default:
+		return maintenanceAllField
+	}
+}
+
+// MaintenanceHold stops dispatching for its target. Tasks keep being
+// accepted and queued, and dispatch picks up where it left off on resume.
+type MaintenanceHold struct {
+	MaintenanceTarget
+	Reason    string    `json:"reason,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// MaintenanceStatus is a hold with the work it is holding back
+type MaintenanceStatus struct {
+	MaintenanceHold
+	Queued   int64 `json:"queued"`    // Tasks accepted while dispatch is held
+	InFlight int64 `json:"in_flight"` // Tasks dispatched before the hold that have not finished
+	Drained  bool  `json:"drained"`
+}
+
+// maintenanceHolds returns the fields of the active holds
+func (q *TenantQueue) maintenanceHolds(ctx context.Context) (map[string]bool, error) {
+	fields, err := q.redis.HKeys(ctx, tenantMaintenanceKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	holds := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		holds[field] = true
+	}
+	return holds, nil
+}
+
+// Hold stops dispatching for the hold's target on every control plane
+func (q *TenantQueue) Hold(ctx context.Context, hold *MaintenanceHold) error {
+	if err := hold.Validate(q.lanes); err != nil {
+		return err
+	}
+	data, err := json.Marshal(hold)
+	if err != nil {
+		return fmt.Errorf("failed to marshal maintenance hold: %w", err)
+	}
+	// Keep the original start time when a target is held twice
+	if err := q.redis.HSetNX(ctx, tenantMaintenanceKey, hold.field(), data).Err(); err != nil {
+		return fmt.Errorf("failed to enter maintenance: %w", err)
+	}
+	return nil
+}
+
+// Release resumes dispatching for a target
+func (q *TenantQueue) Release(ctx context.Context, target MaintenanceTarget) error {
+	removed, err := q.redis.HDel(ctx, tenantMaintenanceKey, target.field()).Result()
+	if err != nil {
+		return fmt.Errorf("failed to exit maintenance: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("%s is not in maintenance", target)
+	}
+	return nil
+}
+
+// MaintenanceStatuses reports every hold with its queued and in-flight tasks
+func (q *TenantQueue) MaintenanceStatuses(ctx context.Context) ([]MaintenanceStatus, error) {
+	raw, err := q.redis.HGetAll(ctx, tenantMaintenanceKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read maintenance holds: %w", err)
+	}
+
+	statuses := make([]MaintenanceStatus, 0, len(raw))
+	for field, value := range raw {
+		var hold MaintenanceHold
+		if err := json.Unmarshal([]byte(value), &hold); err != nil {
+			log.Printf("Skipping malformed maintenance hold %s: %v", field, err)
+			continue
+		}
+		status, err := q.maintenanceStatus(ctx, hold)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].StartedAt.Before(statuses[j].StartedAt) })
+	return statuses, nil
+}
+
+func (q *TenantQueue) maintenanceStatus(ctx context.Context, hold MaintenanceHold) (*MaintenanceStatus, error) {
+	status := &MaintenanceStatus{MaintenanceHold: hold}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	inFlightKey := tenantInFlightKey
+	switch hold.Scope {
+	case MaintenanceScopeLane:
+		inFlightKey = laneKey(hold.Lane, "inflight")
+	case MaintenanceScopeOrg:
+		inFlightKey = tenantOrgInFlightPrefix + hold.OrgID.String()
+	}
+	inFlight, err := q.redis.ZCount(ctx, inFlightKey, now, "+inf").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count in-flight tasks: %w", err)
+	}
+	status.InFlight = inFlight
+	status.Drained = inFlight == 0
+
+	for _, lane := range q.lanes {
+		if hold.Scope == MaintenanceScopeLane && lane.Name != hold.Lane {
+			continue
+		}
+		orgs := []string{hold.OrgID.String()}
+		if hold.Scope != MaintenanceScopeOrg {
+			if orgs, err = q.redis.ZRange(ctx, laneKey(lane.Name, "tenants"), 0, -1).Result(); err != nil {
+				return nil, fmt.Errorf("failed to list lane queues: %w", err)
+			}
+		}
+		for _, org := range orgs {
+			depth, err := q.redis.LLen(ctx, laneKey(lane.Name, "tenant:"+org)).Result()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read queue depth: %w", err)
+			}
+			status.Queued += depth
+		}
+	}
+	return status, nil
+}
+
+// EnterMaintenance stops dispatching tasks for a lane, an org or all queues.
+// Runs are still accepted and their tasks queue until ExitMaintenance.
+func (cp *ControlPlane) EnterMaintenance(ctx context.Context, hold *MaintenanceHold) error {
+	hold.StartedAt = time.Now()
+	if err := cp.queue.Hold(ctx, hold); err != nil {
+		return err
+	}
+	log.Printf("Dispatch for %s is in maintenance: %s", hold.MaintenanceTarget, hold.Reason)
+	return nil
+}
+
+// ExitMaintenance resumes dispatching for a target
+func (cp *ControlPlane) ExitMaintenance(ctx context.Context, target MaintenanceTarget) error {
+	if err := cp.queue.Release(ctx, target); err != nil {
+		return err
+	}
+	log.Printf("Dispatch for %s resumed", target)
+	return nil
+}
+
+// DrainQueue puts a target in maintenance, if it isn't already, and waits
+// until its in-flight tasks finish or the timeout passes. The returned
+// status reports whether the target drained.
+func (cp *ControlPlane) DrainQueue(ctx context.Context, hold *MaintenanceHold, timeout time.Duration) (*MaintenanceStatus, error) {
+	if err := cp.EnterMaintenance(ctx, hold); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(maintenanceDrainPoll)
+	defer ticker.Stop()
+
+	for {
+		status, err := cp.queue.maintenanceStatus(context.WithoutCancel(ctx), *hold)
+		if err != nil {
+			return nil, err
+		}
+		if status.Drained || ctx.Err() != nil {
+			return status, nil
+		}
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetMaintenance lists the active maintenance holds and how far each has drained
+func (cp *ControlPlane) GetMaintenance(ctx context.Context) ([]MaintenanceStatus, error) {
+	return cp.queue.MaintenanceStatuses(ctx)
+}
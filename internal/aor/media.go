@@ -0,0 +1,362 @@
+package aor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"  // Registers GIF for dimension checks
+	_ "image/jpeg" // Registers JPEG for dimension checks
+	_ "image/png"  // Registers PNG for dimension checks
+	"sort"
+	"strings"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+	"github.com/google/uuid"
+)
+
+// Media part types
+const (
+	MediaImage = "image"
+	MediaFile  = "file"
+	MediaAudio = "audio" // Only transcribe steps accept audio
+)
+
+const (
+	// mediaInlineThreshold is the largest media kept inline in run inputs;
+	// larger media is moved to the artifact store on submission
+	mediaInlineThreshold = 256 << 10
+	// maxMediaParts is the most images and files one call may carry
+	maxMediaParts = 20
+	// defaultImageTokens is charged for images whose dimensions can't be read
+	defaultImageTokens = 1000
+	// mediaFileTokens is charged per file, about two document pages
+	mediaFileTokens = 1500
+)
+
+// providerMediaLimits is the largest image or file each provider accepts inline
+var providerMediaLimits = map[string]int{
+	"anthropic": 5 << 20,
+	"openai":    20 << 20,
+	"google":    20 << 20,
+	"gemini":    20 << 20,
+}
+
+// mediaTypes lists the media types models accept, by part type
+var mediaTypes = map[string][]string{
+	MediaImage: {"image/png", "image/jpeg", "image/gif", "image/webp"},
+	MediaFile:  {"application/pdf", "text/plain"},
+	MediaAudio: {"audio/wav", "audio/x-wav", "audio/mpeg", "audio/mp4", "audio/ogg", "audio/webm", "audio/flac"},
+}
+
+// MediaPart is an image or file passed to a model. Any input value shaped
+// {type: image, media_type: image/png, data: <base64>} is sent as media rather
+// than text; url or ref (an artifact store hash) may replace data. Text is OCR
+// output or a caption, which redaction policies check for PII.
+type MediaPart struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Ref       string `json:"ref,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Text      string `json:"text,omitempty"`
+	SizeBytes int    `json:"size_bytes,omitempty"`
+}
+
+// MediaPolicy limits the media a step sends, configured as
+// media: {max_bytes: 1048576, redaction: block|blur|allow}
+type MediaPolicy struct {
+	MaxBytes  int                `json:"max_bytes,omitempty"` // Per part; the provider limit when unset
+	Redaction scl.MediaRedaction `json:"redaction"`
+}
+
+// MediaError is returned when a step's media is too large, unsupported or
+// blocked by its redaction policy; sending it again would fail the same way
+type MediaError struct {
+	StepID string `json:"step_id"`
+	Part   string `json:"part"`
+	Reason string `json:"reason"`
+}
+
+func (e *MediaError) Error() string {
+	return fmt.Sprintf("step %s media %s rejected: %s", e.StepID, e.Part, e.Reason)
+}
+
+// ErrorClass reports rejected media to telemetry
+func (e *MediaError) ErrorClass() cas.ErrorClass {
+	return cas.ErrorClassInvalidRequest
+}
+
+// parseMediaPolicy reads a step's media config
+func parseMediaPolicy(config map[string]interface{}) (*MediaPolicy, error) {
+	policy := &MediaPolicy{Redaction: scl.MediaRedactionAllow}
+	raw, ok := config["media"].(map[string]interface{})
+	if !ok {
+		if _, set := config["media"]; set {
+			return nil, fmt.Errorf("media must be an object with max_bytes or redaction")
+		}
+		return policy, nil
+	}
+	if v, ok := raw["max_bytes"].(float64); ok {
+		if v <= 0 {
+			return nil, fmt.Errorf("media.max_bytes must be positive")
+		}
+		policy.MaxBytes = int(v)
+	}
+	redaction, _ := raw["redaction"].(string)
+	var err error
+	if policy.Redaction, err = scl.ParseMediaRedaction(redaction); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// parseMediaPart reads an input value as media; ok is false for ordinary values
+func parseMediaPart(value interface{}) (*MediaPart, bool, error) {
+	raw, isMap := value.(map[string]interface{})
+	if !isMap {
+		return nil, false, nil
+	}
+	partType, _ := raw["type"].(string)
+	if _, known := mediaTypes[partType]; !known {
+		return nil, false, nil
+	}
+
+	part := &MediaPart{Type: partType}
+	part.MediaType, _ = raw["media_type"].(string)
+	part.Data, _ = raw["data"].(string)
+	part.URL, _ = raw["url"].(string)
+	part.Ref, _ = raw["ref"].(string)
+	part.Name, _ = raw["name"].(string)
+	part.Text, _ = raw["text"].(string)
+	if v, ok := raw["size_bytes"].(float64); ok {
+		part.SizeBytes = int(v)
+	}
+
+	if !containsString(mediaTypes[partType], strings.ToLower(part.MediaType)) {
+		return nil, true, fmt.Errorf("unsupported %s media_type %q: use %s", partType, part.MediaType, strings.Join(mediaTypes[partType], ", "))
+	}
+	if part.Data == "" && part.URL == "" && part.Ref == "" {
+		return nil, true, fmt.Errorf("%s needs data, url or ref", partType)
+	}
+	if part.Ref != "" && !strings.HasPrefix(part.Ref, "sha256:") {
+		return nil, true, fmt.Errorf("%s ref %q is not an artifact store hash", partType, part.Ref)
+	}
+	if part.Data != "" {
+		decoded, err := base64.StdEncoding.DecodeString(part.Data)
+		if err != nil {
+			return nil, true, fmt.Errorf("%s data is not base64: %w", partType, err)
+		}
+		part.SizeBytes = len(decoded)
+	}
+	return part, true, nil
+}
+
+// extractMedia splits task inputs into text inputs and media parts, ordered by input name
+func extractMedia(inputs map[string]interface{}) (map[string]interface{}, []MediaPart, error) {
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []MediaPart
+	text := make(map[string]interface{}, len(inputs))
+	for _, name := range names {
+		part, ok, err := parseMediaPart(inputs[name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("input %s: %w", name, err)
+		}
+		if !ok {
+			text[name] = inputs[name]
+			continue
+		}
+		if part.Name == "" {
+			part.Name = name
+		}
+		parts = append(parts, *part)
+	}
+	if len(parts) > maxMediaParts {
+		return nil, nil, fmt.Errorf("%d media inputs exceed the limit of %d per call", len(parts), maxMediaParts)
+	}
+	return text, parts, nil
+}
+
+// offloadMedia moves inline media larger than mediaInlineThreshold into the
+// artifact store, so run metadata and task payloads carry only its ref
+func (cp *ControlPlane) offloadMedia(ctx context.Context, orgID uuid.UUID, inputs map[string]interface{}) (map[string]interface{}, error) {
+	var offloaded map[string]interface{}
+	for name, value := range inputs {
+		part, ok, err := parseMediaPart(value)
+		if err != nil {
+			return nil, fmt.Errorf("input %s: %w", name, err)
+		}
+		if !ok || part.Data == "" || part.SizeBytes <= mediaInlineThreshold {
+			continue
+		}
+
+		content, _ := base64.StdEncoding.DecodeString(part.Data)
+		hash, err := cp.blobs.Put(ctx, orgID, db.BlobKindMedia, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store media input %s: %w", name, err)
+		}
+		if offloaded == nil {
+			offloaded = make(map[string]interface{}, len(inputs))
+			for k, v := range inputs {
+				offloaded[k] = v
+			}
+		}
+		part.Data, part.Ref = "", hash
+		offloaded[name] = part.value()
+	}
+	if offloaded == nil {
+		return inputs, nil
+	}
+	return offloaded, nil
+}
+
+// value renders a part back into its input form
+func (p MediaPart) value() map[string]interface{} {
+	value := map[string]interface{}{"type": p.Type, "media_type": p.MediaType, "size_bytes": float64(p.SizeBytes)}
+	for key, field := range map[string]string{"data": p.Data, "url": p.URL, "ref": p.Ref, "name": p.Name, "text": p.Text} {
+		if field != "" {
+			value[key] = field
+		}
+	}
+	return value
+}
+
+// prepareMedia loads artifact store media, enforces size limits and applies the
+// step's redaction policy, leaving every part ready to send inline or by URL
+func (w *Worker) prepareMedia(ctx context.Context, task *Task, prompt *renderedPrompt, provider string) error {
+	if len(prompt.Media) == 0 {
+		return nil
+	}
+	var config map[string]interface{}
+	if task.Node != nil {
+		config = task.Node.Config
+	}
+	policy, err := parseMediaPolicy(config)
+	if err != nil {
+		return err
+	}
+	limit := providerMediaLimits[strings.ToLower(provider)]
+	if policy.MaxBytes > 0 && (limit == 0 || policy.MaxBytes < limit) {
+		limit = policy.MaxBytes
+	}
+	redactor := scl.NewRedactor()
+
+	for i := range prompt.Media {
+		part := &prompt.Media[i]
+		reject := func(reason string, args ...interface{}) error {
+			return &MediaError{StepID: task.StepID, Part: part.Name, Reason: fmt.Sprintf(reason, args...)}
+		}
+
+		if part.Type == MediaAudio {
+			return reject("audio must be transcribed by a transcribe step before an LLM step can use it")
+		}
+		if part.Ref != "" {
+			if w.blobs == nil {
+				return fmt.Errorf("media %s references the artifact store, which is not configured", part.Name)
+			}
+			blob, err := w.blobs.Get(ctx, task.OrgID, part.Ref)
+			if err != nil {
+				return fmt.Errorf("failed to load media %s: %w", part.Name, err)
+			}
+			part.Data, part.SizeBytes = base64.StdEncoding.EncodeToString(blob.Content), len(blob.Content)
+		}
+		if part.Data == "" && part.Type == MediaFile && strings.EqualFold(provider, "openai") {
+			return reject("openai only accepts files inline, not by URL")
+		}
+		if limit > 0 && part.SizeBytes > limit {
+			return reject("%d bytes exceeds the %d byte limit for %s", part.SizeBytes, limit, provider)
+		}
+
+		if policy.Redaction == scl.MediaRedactionAllow {
+			continue
+		}
+		found := redactor.MediaPII(part.Text, part.Name)
+		if len(found) == 0 {
+			continue
+		}
+		llmMediaRedactions.Inc(string(policy.Redaction), part.Type)
+		if policy.Redaction == scl.MediaRedactionBlock || part.Type != MediaImage || part.Data == "" {
+			return reject("contains %s", strings.Join(found, ", "))
+		}
+		content, _ := base64.StdEncoding.DecodeString(part.Data)
+		blurred, err := scl.BlurImage(content, part.MediaType)
+		if err != nil {
+			return reject("contains %s and could not be blurred: %v", strings.Join(found, ", "), err)
+		}
+		part.Data, part.SizeBytes, part.Text = base64.StdEncoding.EncodeToString(blurred), len(blurred), ""
+	}
+	return nil
+}
+
+// tokens estimates the prompt tokens a part costs; images are billed by area
+func (p MediaPart) tokens() int {
+	if p.Type == MediaFile {
+		return mediaFileTokens
+	}
+	content, err := base64.StdEncoding.DecodeString(p.Data)
+	if err != nil || len(content) == 0 {
+		return defaultImageTokens
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return defaultImageTokens
+	}
+	return (cfg.Width*cfg.Height)/750 + 1
+}
+
+// dataURL renders inline media as a data URL, or returns its URL
+func (p MediaPart) dataURL() string {
+	if p.Data == "" {
+		return p.URL
+	}
+	return fmt.Sprintf("data:%s;base64,%s", p.MediaType, p.Data)
+}
+
+// mediaContent encodes a user message with media in a provider's content format
+func mediaContent(provider, text string, parts []MediaPart) []interface{} {
+	content := make([]interface{}, 0, len(parts)+1)
+	switch strings.ToLower(provider) {
+	case "anthropic":
+		// Claude reads media best placed before the question
+		for _, part := range parts {
+			source := map[string]interface{}{"type": "base64", "media_type": part.MediaType, "data": part.Data}
+			if part.Data == "" {
+				source = map[string]interface{}{"type": "url", "url": part.URL}
+			}
+			blockType := "image"
+			if part.Type == MediaFile {
+				blockType = "document"
+			}
+			content = append(content, map[string]interface{}{"type": blockType, "source": source})
+		}
+		return append(content, map[string]interface{}{"type": "text", "text": text})
+	case "google", "gemini":
+		for _, part := range parts {
+			if part.Data == "" {
+				content = append(content, map[string]interface{}{"file_data": map[string]interface{}{"mime_type": part.MediaType, "file_uri": part.URL}})
+				continue
+			}
+			content = append(content, map[string]interface{}{"inline_data": map[string]interface{}{"mime_type": part.MediaType, "data": part.Data}})
+		}
+		return append(content, map[string]interface{}{"text": text})
+	default:
+		content = append(content, map[string]interface{}{"type": "text", "text": text})
+		for _, part := range parts {
+			if part.Type == MediaFile {
+				content = append(content, map[string]interface{}{"type": "file", "file": map[string]interface{}{"filename": part.Name, "file_data": part.dataURL()}})
+				continue
+			}
+			content = append(content, map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": part.dataURL()}})
+		}
+		return content
+	}
+}
@@ -0,0 +1,96 @@
+package aor
+
+import "github.com/Siddhant-K-code/agentflow-infrastructure/internal/metrics"
+
+// Registry holds the orchestration metrics served at /metrics by the
+// control plane and worker binaries
+var Registry = metrics.NewRegistry()
+
+var (
+	queueDepth = Registry.NewGauge("agentflow_queue_depth",
+		"Number of tasks waiting in each queue", "queue")
+	leaseAge = Registry.NewGauge("agentflow_task_lease_age_seconds",
+		"Age of the oldest running step lease")
+	tasksProcessed = Registry.NewCounter("agentflow_tasks_processed_total",
+		"Tasks processed by workers by executor type and final status", "type", "status")
+	taskRetries = Registry.NewCounter("agentflow_task_retries_total",
+		"Task execution retries by executor type", "type")
+	deadLetters = Registry.NewCounter("agentflow_dead_letter_tasks_total",
+		"Permanently failed tasks moved to the dead letter queue by executor type", "type")
+	stepDuration = Registry.NewHistogram("agentflow_step_duration_seconds",
+		"Step execution duration by executor type", nil, "type")
+	schedulerLatency = Registry.NewHistogram("agentflow_scheduler_loop_seconds",
+		"Latency of scheduler and monitor loop iterations", nil, "loop")
+	slaTransitions = Registry.NewCounter("agentflow_run_sla_total",
+		"Runs entering each SLA status by workflow", "workflow", "status")
+	spawnedNodes = Registry.NewCounter("agentflow_spawned_nodes_total",
+		"Nodes appended to running DAGs by spawn directives", "workflow")
+	ensembleSelections = Registry.NewCounter("agentflow_ensemble_selections_total",
+		"Ensemble step resolutions by strategy and winning provider/model", "strategy", "winner")
+	llmDedupRequests = Registry.NewCounter("agentflow_llm_dedup_requests_total",
+		"LLM requests by deduplication outcome (leader, local_hit, global_hit, unavailable)", "outcome")
+	llmHedgeRequests = Registry.NewCounter("agentflow_llm_hedge_requests_total",
+		"Hedged LLM requests by outcome (not_fired, primary_won, hedge_won, both_failed)", "outcome")
+	llmHedgeWastedCost = Registry.NewCounter("agentflow_llm_hedge_wasted_cost_cents_total",
+		"Cost of hedge race losers that completed before being cancelled", "provider", "model")
+	llmRefusals = Registry.NewCounter("agentflow_llm_refusals_total",
+		"LLM responses refused, filtered or truncated, by class and the action taken", "class", "action")
+	unschedulableTasks = Registry.NewCounter("agentflow_unschedulable_tasks_total",
+		"Tasks failed because no live worker matched their placement selector, by executor type", "type")
+	budgetDispatches = Registry.NewCounter("agentflow_budget_dispatch_total",
+		"Budget checks of steps at dispatch by action (dispatch, degrade, exceeded)", "action")
+	runCallbacks = Registry.NewCounter("agentflow_run_callbacks_total",
+		"Run result callback deliveries by outcome (delivered, retrying, failed)", "outcome")
+	tenantQueueWait = Registry.NewHistogram("agentflow_tenant_queue_wait_seconds",
+		"Time tasks wait in their org's queue before dispatch", nil, "org")
+	tenantQueueDepth = Registry.NewGauge("agentflow_tenant_queue_depth",
+		"Tasks waiting in each org's queue", "org")
+	stepCacheLookups = Registry.NewCounter("agentflow_step_cache_total",
+		"Step cache lookups and stores by outcome (hit, miss, store)", "outcome")
+	stepCacheSavedCost = Registry.NewCounter("agentflow_step_cache_saved_cents_total",
+		"Original cost of step executions skipped by reusing cached outputs", "workflow")
+	responseCacheLookups = Registry.NewCounter("agentflow_llm_response_cache_total",
+		"LLM response cache lookups and stores by outcome (hit, miss, store)", "outcome")
+	outputPostProcessors = Registry.NewCounter("agentflow_output_post_processors_total",
+		"Post-processors run on final run outputs by processor and outcome (applied, failed)", "processor", "outcome")
+	sandboxViolations = Registry.NewCounter("agentflow_sandbox_violations_total",
+		"Actions blocked by step sandboxes by kind (network_egress, filesystem_write)", "kind")
+	debugCaptures = Registry.NewCounter("agentflow_provider_debug_captures_total",
+		"Provider exchanges captured while debug capture is on", "provider")
+	chaosFaults = Registry.NewCounter("agentflow_chaos_faults_total",
+		"Faults injected by chaos mode by step type and fault", "step_type", "fault")
+	laneQueueWait = Registry.NewHistogram("agentflow_lane_queue_wait_seconds",
+		"Time tasks wait in each priority lane before dispatch", nil, "lane")
+	laneDispatches = Registry.NewCounter("agentflow_lane_dispatches_total",
+		"Tasks dispatched per lane by whether the lane's target wait was met (met, missed)", "lane", "slo")
+	runDataErasures = Registry.NewCounter("agentflow_run_data_erasures_total",
+		"Runs whose data was erased by reason (retention, subject_request)", "reason")
+	llmDegradations = Registry.NewCounter("agentflow_llm_degradations_total",
+		"LLM calls degraded near their org's budget by action (cheaper_model, shorter_context, throttle)", "action")
+	llmTokenBudgets = Registry.NewCounter("agentflow_llm_token_budget_total",
+		"LLM prompts over their step's token budget by outcome (compressed, rejected)", "outcome")
+	conversationTurns = Registry.NewCounter("agentflow_conversation_turns_total",
+		"Conversation turns by event (submitted, completed, failed, idle_closed)", "event")
+	capabilityRegistrations = Registry.NewCounter("agentflow_capability_registrations_total",
+		"Agent and tool capability registrations by kind", "kind")
+	llmPromptCacheTokens = Registry.NewCounter("agentflow_llm_prompt_cache_tokens_total",
+		"Prompt tokens read from or written to provider prompt caches", "provider", "kind")
+	llmReasoningTokens = Registry.NewCounter("agentflow_llm_reasoning_tokens_total",
+		"Thinking tokens billed by reasoning models", "provider", "model")
+	llmMediaRedactions = Registry.NewCounter("agentflow_llm_media_redactions_total",
+		"Images and files found to contain PII, by redaction action", "action", "type")
+	runFailures = Registry.NewCounter("agentflow_run_failures_total",
+		"Failed runs by triaged failure category", "category")
+	transcriptionMinutes = Registry.NewCounter("agentflow_transcription_minutes_total",
+		"Minutes of audio transcribed", "provider", "model")
+	artifactGCBlobs = Registry.NewCounter("agentflow_artifact_gc_reclaimed_blobs_total",
+		"Unreferenced content blobs deleted by artifact GC, by blob kind", "kind")
+	artifactGCBytes = Registry.NewCounter("agentflow_artifact_gc_reclaimed_bytes_total",
+		"Bytes of unreferenced content blobs deleted by artifact GC, by blob kind", "kind")
+	assertSteps = Registry.NewCounter("agentflow_assert_steps_total",
+		"Assert steps by outcome (passed, failed, remediated)", "outcome")
+	notificationsSent = Registry.NewCounter("agentflow_user_notifications_total",
+		"User notifications by delivery mode (immediate, hourly)", "mode")
+	notificationDigests = Registry.NewCounter("agentflow_notification_digests_total",
+		"Hourly notification digests sent to users")
+)
@@ -0,0 +1,243 @@
+package aor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+)
+
+const (
+	// MockProviderName is the provider LLM calls answered by the mock report
+	MockProviderName   = "mock"
+	mockProviderScheme = "mock://"
+)
+
+// MockProvider is the built-in provider for hermetic tests. It is selected
+// with provider: mock on a step, or for every LLM call of a run by an
+// environment profile's mock_provider, and configured by URL query, e.g.
+// mock://?latency=50ms&jitter=10ms&error_rate=0.1&error=rate_limit&cost_cents=2.
+// Responses, latencies and errors are drawn from a hash of the seed, model,
+// prompt and attempt, so the same run behaves the same way on every CI run.
+type MockProvider struct {
+	Latency   time.Duration  `json:"latency,omitempty"`
+	Jitter    time.Duration  `json:"jitter,omitempty"`     // Latency varies by up to this much either way
+	ErrorRate float64        `json:"error_rate,omitempty"` // Share of attempts that fail
+	Error     cas.ErrorClass `json:"error,omitempty"`      // rate_limit, server_error or timeout
+	CostCents *int64         `json:"cost_cents,omitempty"` // Per call; nil prices tokens at the mock rates
+	Seed      string         `json:"seed,omitempty"`
+	Response  interface{}    `json:"response,omitempty"` // Canned reply; a step's mock_response overrides it
+}
+
+// isMockProvider reports whether a step's provider selects the mock
+func isMockProvider(provider string) bool {
+	return provider == MockProviderName || strings.HasPrefix(provider, mockProviderScheme)
+}
+
+// ParseMockProvider reads a mock:// URL; plain "mock" uses the defaults
+func ParseMockProvider(raw string) (*MockProvider, error) {
+	mock := &MockProvider{Error: cas.ErrorClassServer}
+	if raw == MockProviderName {
+		return mock, nil
+	}
+	if !strings.HasPrefix(raw, mockProviderScheme) {
+		return nil, fmt.Errorf("invalid mock provider %q: use mock://?latency=50ms&error_rate=0.1", raw)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mock provider %q: %w", raw, err)
+	}
+
+	query := u.Query()
+	for key := range query {
+		value := query.Get(key)
+		switch key {
+		case "latency", "jitter":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid mock provider %s %q: use a duration like 50ms", key, value)
+			}
+			if key == "latency" {
+				mock.Latency = d
+			} else {
+				mock.Jitter = d
+			}
+		case "error_rate":
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || rate < 0 || rate > 1 {
+				return nil, fmt.Errorf("invalid mock provider error_rate %q: use 0 to 1", value)
+			}
+			mock.ErrorRate = rate
+		case "error":
+			switch cas.ErrorClass(value) {
+			case cas.ErrorClassRateLimit, cas.ErrorClassServer, cas.ErrorClassTimeout:
+				mock.Error = cas.ErrorClass(value)
+			default:
+				return nil, fmt.Errorf("invalid mock provider error %q: use rate_limit, server_error or timeout", value)
+			}
+		case "cost_cents":
+			cents, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || cents < 0 {
+				return nil, fmt.Errorf("invalid mock provider cost_cents %q", value)
+			}
+			mock.CostCents = &cents
+		case "seed":
+			mock.Seed = value
+		case "response":
+			mock.Response = value
+		default:
+			return nil, fmt.Errorf("unknown mock provider option %q", key)
+		}
+	}
+	return mock, nil
+}
+
+// mockProviderFor returns the mock a task's LLM calls go to: the run's
+// environment mock, else the step's own mock provider, else nil
+func mockProviderFor(task *Task, provider string) (*MockProvider, error) {
+	raw := task.MockProvider
+	if raw == "" && isMockProvider(provider) {
+		raw = provider
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	mock, err := ParseMockProvider(raw)
+	if err != nil {
+		return nil, err
+	}
+	if task.Node != nil {
+		if response, ok := task.Node.Config["mock_response"]; ok {
+			mock.Response = response
+		}
+	}
+	return mock, nil
+}
+
+// MockProviderError is a failure injected by the mock provider, classed like
+// the real provider failure it stands in for
+type MockProviderError struct {
+	Class cas.ErrorClass
+}
+
+func (e *MockProviderError) Error() string {
+	switch e.Class {
+	case cas.ErrorClassRateLimit:
+		return "mock provider: 429 rate limit exceeded"
+	case cas.ErrorClassTimeout:
+		return "mock provider: deadline exceeded"
+	default:
+		return "mock provider: 503 service unavailable"
+	}
+}
+
+// ErrorClass implements cas.ClassifiedError
+func (e *MockProviderError) ErrorClass() cas.ErrorClass {
+	return e.Class
+}
+
+// call answers one LLM call. Each retry attempt draws again, so a step can
+// recover from an injected error the way it would from a real one.
+func (m *MockProvider) call(ctx context.Context, model string, prompt *renderedPrompt) (*llmCallResult, error) {
+	key := model + "\x00" + prompt.Prefix + "\x00" + prompt.Suffix
+	attempt := retryAttemptFrom(ctx)
+
+	delay := m.Latency
+	if m.Jitter > 0 {
+		delay += time.Duration((2*m.draw("latency", key, attempt) - 1) * float64(m.Jitter))
+	}
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	if m.ErrorRate > 0 && m.draw("error", key, attempt) < m.ErrorRate {
+		return nil, &MockProviderError{Class: m.Error}
+	}
+
+	var text string
+	switch response := m.Response.(type) {
+	case nil:
+		sum := sha256.Sum256([]byte(m.Seed + "\x00" + key))
+		text = "Mock response " + hex.EncodeToString(sum[:6])
+	case string:
+		text = response
+	default:
+		data, err := json.Marshal(response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode mock response: %w", err)
+		}
+		text = string(data)
+	}
+
+	normalized, err := NormalizeLLMResponse("openai", mockProviderResponse("openai", text))
+	if err != nil {
+		return nil, err
+	}
+	compressor := scl.NewCompressor()
+	promptTokens := compressor.EstimateTokens(prompt.Prefix+prompt.Suffix) + prompt.mediaTokens()
+	completionTokens := compressor.EstimateTokens(text)
+	cost := int64(math.Round(float64(promptTokens)*mockPromptCentsPerToken + float64(completionTokens)*mockCompletionCentsPerToken))
+	if m.CostCents != nil {
+		cost = *m.CostCents
+	}
+
+	return &llmCallResult{
+		Output:           normalized.ToOutput(),
+		CostCents:        cost,
+		TokensPrompt:     promptTokens,
+		TokensCompletion: completionTokens,
+		Provider:         MockProviderName,
+		Model:            model,
+	}, nil
+}
+
+// draw returns a value in [0, 1) fixed by the seed, purpose, call and attempt
+func (m *MockProvider) draw(purpose, key string, attempt int) float64 {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%s", m.Seed, purpose, attempt, key)))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+// runMockProvider returns the mock provider URL recorded on a run, if any
+func runMockProvider(metadata map[string]interface{}) string {
+	raw, _ := metadata["mock_provider"].(string)
+	return raw
+}
+
+type mockProviderContextKey struct{}
+
+func withMockProvider(ctx context.Context, mock *MockProvider) context.Context {
+	return context.WithValue(ctx, mockProviderContextKey{}, mock)
+}
+
+func mockProviderFrom(ctx context.Context) *MockProvider {
+	mock, _ := ctx.Value(mockProviderContextKey{}).(*MockProvider)
+	return mock
+}
+
+type retryAttemptContextKey struct{}
+
+// withRetryAttempt records which of the worker's attempts at a task is running
+func withRetryAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, retryAttemptContextKey{}, attempt)
+}
+
+func retryAttemptFrom(ctx context.Context) int {
+	attempt, _ := ctx.Value(retryAttemptContextKey{}).(int)
+	if attempt == 0 {
+		return 1
+	}
+	return attempt
+}
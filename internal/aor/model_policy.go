@@ -0,0 +1,97 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+)
+
+const (
+	defaultLLMProvider = "openai"
+	defaultLLMModel    = "gpt-3.5-turbo"
+)
+
+// stepProviderModel resolves the provider and model an LLM step runs against
+func stepProviderModel(config map[string]interface{}) (string, string) {
+	provider, model := defaultLLMProvider, defaultLLMModel
+	if p, ok := config["provider"].(string); ok && p != "" {
+		provider = p
+	}
+	if m, ok := config["model"].(string); ok && m != "" {
+		model = m
+	}
+	return provider, model
+}
+
+// stepModelTargets lists the provider/model pairs a step calls: the step's own
+// model, any best-of-N judge, hedge, reroute and budget fallbacks for LLM and
+// rag_generate steps, and every member and judge for ensembles
+func stepModelTargets(step Step) [][2]string {
+	switch ExecutorType(step.Type) {
+	case ExecutorTypeLLM, ExecutorTypeRAGGenerate:
+		provider, model := stepProviderModel(step.Config)
+		targets := [][2]string{{provider, model}}
+		if llmSampleCount(step.Config) > 1 {
+			if judge, err := parseEnsembleMember(step.Config["judge"]); err == nil {
+				targets = append(targets, [2]string{judge.Provider, judge.Model})
+			}
+		}
+		if hedge, err := parseHedgePolicy(step.Config); err == nil && hedge != nil {
+			targets = append(targets, [2]string{hedge.Provider, hedge.Model})
+		}
+		if refusal, err := parseRefusalPolicy(step.Config); err == nil && refusal.Reroute != nil {
+			targets = append(targets, [2]string{refusal.Reroute.Provider, refusal.Reroute.Model})
+		}
+		fallbacks, _ := parseBudgetFallback(step.Config)
+		for _, fallback := range fallbacks {
+			targets = append(targets, [2]string{fallback.Provider, fallback.Model})
+		}
+		return targets
+	case ExecutorTypeEnsemble:
+		targets := make([][2]string, 0)
+		members, _ := step.Config["members"].([]interface{})
+		if judge, ok := step.Config["judge"]; ok {
+			members = append(members, judge)
+		}
+		for _, raw := range members {
+			if member, err := parseEnsembleMember(raw); err == nil {
+				targets = append(targets, [2]string{member.Provider, member.Model})
+			}
+		}
+		return targets
+	default:
+		return nil
+	}
+}
+
+// ValidateSpecModelPolicy checks every LLM step against the org's model policy
+func ValidateSpecModelPolicy(spec *WorkflowSpec, policy *cas.ModelPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	violations := make([]string, 0)
+	for _, step := range spec.DAG.Steps {
+		for _, target := range stepModelTargets(step) {
+			if v := policy.Check(target[0], target[1]); v != nil {
+				violations = append(violations, fmt.Sprintf("step %s: %s", step.ID, v.Error()))
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("workflow %s violates org model policy: %s", spec.Name, strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// checkModelPolicy rejects specs that pin models the org does not permit
+func (cp *ControlPlane) checkModelPolicy(ctx context.Context, spec *WorkflowSpec) error {
+	policy, err := cp.policies.Get(ctx, spec.OrgID)
+	if err != nil {
+		return err
+	}
+	return ValidateSpecModelPolicy(spec, policy)
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	nats "github.com/nats-io/nats.go"
 )
 
@@ -46,6 +47,12 @@ func (m *Monitor) Start(ctx context.Context) error {
 		return err
 	}
 
+	// Subscribe to agent and tool self-registrations
+	_, err = m.cp.js.Subscribe(CapabilitySubject, m.handleCapability, nats.Durable("monitor-capabilities"))
+	if err != nil {
+		return err
+	}
+
 	// Start monitoring loops
 	go m.monitoringLoop(ctx)
 
@@ -77,11 +84,64 @@ func (m *Monitor) handleResult(msg *nats.Msg) {
 
 	log.Printf("Received result for task %s: %s", result.TaskID, result.Status)
 
-	// Results are already processed by the scheduler
-	// This is mainly for monitoring and metrics
+	// Charge step cost to the run's budgets and stop the run once any scope is exhausted
+	if result.RunID != uuid.Nil && result.CostCents > 0 {
+		if err := m.cp.enforceRunBudget(context.Background(), result.RunID, result.CostCents); err != nil {
+			log.Printf("Failed to enforce budget for run %s: %v", result.RunID, err)
+		}
+	}
+
+	// Failed steps are triaged into the run's failure category
+	if result.RunID != uuid.Nil && result.Status == TaskStatusFailed {
+		if err := m.cp.recordStepFailure(context.Background(), &result); err != nil {
+			log.Printf("Failed to record failure of run %s: %v", result.RunID, err)
+		}
+	}
+
+	// Final step outputs become the run's output after the workflow's
+	// post-processors, before completion delivers them
+	if result.RunID != uuid.Nil && result.Status == TaskStatusSucceeded && result.Turn == 0 {
+		if err := m.cp.recordRunOutput(context.Background(), &result); err != nil {
+			log.Printf("Failed to record output of run %s: %v", result.RunID, err)
+		}
+	}
+
+	// Turns of conversational runs track their own cost and replies
+	if result.Turn > 0 {
+		if err := m.cp.recordTurnResult(context.Background(), &result); err != nil {
+			log.Printf("Failed to record turn %d of run %s: %v", result.Turn, result.RunID, err)
+		}
+	}
+
+	if err := m.cp.queue.Complete(context.Background(), result.TaskID, result.OrgID); err != nil {
+		log.Printf("Failed to release dispatch slot for task %s: %v", result.TaskID, err)
+	}
+
+	if err := m.cp.scheduler.ProcessTaskResult(context.Background(), &result); err != nil {
+		log.Printf("Failed to process result for task %s: %v", result.TaskID, err)
+	}
+
+	if result.RunID != uuid.Nil {
+		m.cp.publishStepCompleted(context.Background(), &result)
+	}
+
 	_ = msg.Ack() // Ignore error for monitoring ack
 }
 
+func (m *Monitor) handleCapability(msg *nats.Msg) {
+	var capability AgentCapability
+	if err := json.Unmarshal(msg.Data, &capability); err != nil {
+		log.Printf("Failed to unmarshal capability: %v", err)
+		_ = msg.Term() // Malformed registrations would fail again
+		return
+	}
+
+	if err := m.cp.RegisterCapability(context.Background(), &capability); err != nil {
+		log.Printf("Failed to register %s %s: %v", capability.Kind, capability.Name, err)
+	}
+	_ = msg.Ack()
+}
+
 func (m *Monitor) handleHeartbeat(msg *nats.Msg) {
 	var heartbeat map[string]interface{}
 	if err := json.Unmarshal(msg.Data, &heartbeat); err != nil {
@@ -110,8 +170,18 @@ func (m *Monitor) monitoringLoop(ctx context.Context) {
 		case <-m.shutdown:
 			return
 		case <-ticker.C:
+			start := time.Now()
 			m.checkStuckTasks(ctx)
 			m.checkWorkerHealth(ctx)
+			m.checkRunSLAs(ctx)
+			m.cp.releaseFrozenRuns(ctx)
+			m.cp.deliverRunCallbacks(ctx)
+			m.cp.checkWorkflowCanaries(ctx)
+			m.cp.enforceRetention(ctx)
+			m.cp.collectArtifactsPeriodically(ctx)
+			m.cp.closeIdleConversations(ctx)
+			m.collectQueueMetrics(ctx)
+			schedulerLatency.ObserveDuration(start, "monitor")
 		}
 	}
 }
@@ -159,3 +229,33 @@ func (m *Monitor) checkWorkerHealth(ctx context.Context) {
 
 	// Could implement alerts for low worker count, etc.
 }
+
+// collectQueueMetrics samples queue depth per task subject and the oldest running lease
+func (m *Monitor) collectQueueMetrics(ctx context.Context) {
+	info, err := m.cp.js.StreamInfo("AGENTFLOW_TASKS", &nats.StreamInfoRequest{SubjectsFilter: "agentflow.tasks.>"})
+	if err != nil {
+		log.Printf("Failed to get task stream info: %v", err)
+	} else {
+		for subject, count := range info.State.Subjects {
+			queueDepth.Set(float64(count), subject)
+		}
+	}
+
+	if snapshot, err := m.cp.queue.Snapshot(ctx); err != nil {
+		log.Printf("Failed to read tenant queues: %v", err)
+	} else {
+		for _, tenant := range snapshot.Tenants {
+			tenantQueueDepth.Set(float64(tenant.Depth), tenant.OrgID.String())
+		}
+	}
+
+	query := `SELECT COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(started_at)), 0)
+			  FROM step_run WHERE status = 'running'`
+
+	var age float64
+	if err := m.cp.db.QueryRowContext(ctx, query).Scan(&age); err != nil {
+		log.Printf("Failed to query lease age: %v", err)
+		return
+	}
+	leaseAge.Set(age)
+}
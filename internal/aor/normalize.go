@@ -0,0 +1,284 @@
+package aor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FinishReason is the provider-independent reason a completion stopped
+type FinishReason string
+
+const (
+	FinishReasonStop          FinishReason = "stop"
+	FinishReasonLength        FinishReason = "length"
+	FinishReasonToolCalls     FinishReason = "tool_calls"
+	FinishReasonContentFilter FinishReason = "content_filter"
+	FinishReasonRefusal       FinishReason = "refusal"
+	FinishReasonOther         FinishReason = "other"
+)
+
+// ToolCall is a normalized tool_use / function_call request from a model
+type ToolCall struct {
+	ID        string                 `json:"id,omitempty"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// NormalizedResponse is the consistent shape of an LLM step output. The
+// provider payload is kept under Raw for debugging.
+type NormalizedResponse struct {
+	Content      string                 `json:"content"`
+	ToolCalls    []ToolCall             `json:"tool_calls"`
+	FinishReason FinishReason           `json:"finish_reason"`
+	Refusal      string                 `json:"refusal,omitempty"`
+	Raw          map[string]interface{} `json:"raw,omitempty"`
+}
+
+// NormalizeLLMResponse converts a provider response body into the common shape
+func NormalizeLLMResponse(provider string, raw map[string]interface{}) (*NormalizedResponse, error) {
+	var (
+		resp *NormalizedResponse
+		err  error
+	)
+
+	switch strings.ToLower(provider) {
+	case "anthropic":
+		resp, err = normalizeAnthropic(raw)
+	case "google", "gemini":
+		resp, err = normalizeGoogle(raw)
+	case "cohere":
+		resp, err = normalizeCohere(raw)
+	default:
+		// OpenAI and the many OpenAI-compatible APIs share the chat completions shape
+		resp, err = normalizeOpenAI(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize %s response: %w", provider, err)
+	}
+
+	resp.Raw = raw
+	return resp, nil
+}
+
+// ToOutput renders the response as a step output map
+func (r *NormalizedResponse) ToOutput() map[string]interface{} {
+	toolCalls := make([]interface{}, 0, len(r.ToolCalls))
+	for _, call := range r.ToolCalls {
+		toolCalls = append(toolCalls, map[string]interface{}{
+			"id":        call.ID,
+			"name":      call.Name,
+			"arguments": call.Arguments,
+		})
+	}
+
+	output := map[string]interface{}{
+		"content":       r.Content,
+		"tool_calls":    toolCalls,
+		"finish_reason": string(r.FinishReason),
+		"refusal":       r.Refusal,
+	}
+	if r.Raw != nil {
+		output["raw"] = r.Raw
+	}
+	return output
+}
+
+func normalizeOpenAI(raw map[string]interface{}) (*NormalizedResponse, error) {
+	choices, _ := raw["choices"].([]interface{})
+	if len(choices) == 0 {
+		return nil, fmt.Errorf("response has no choices")
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	message, _ := choice["message"].(map[string]interface{})
+
+	resp := &NormalizedResponse{ToolCalls: make([]ToolCall, 0)}
+	resp.Content, _ = message["content"].(string)
+	resp.Refusal, _ = message["refusal"].(string)
+
+	calls, _ := message["tool_calls"].([]interface{})
+	for _, c := range calls {
+		call, _ := c.(map[string]interface{})
+		fn, _ := call["function"].(map[string]interface{})
+		id, _ := call["id"].(string)
+		name, _ := fn["name"].(string)
+		args, err := decodeArguments(fn["arguments"])
+		if err != nil {
+			return nil, fmt.Errorf("tool call %s: %w", name, err)
+		}
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: id, Name: name, Arguments: args})
+	}
+
+	// Legacy function_call responses carry a single call without an ID
+	if fn, ok := message["function_call"].(map[string]interface{}); ok {
+		name, _ := fn["name"].(string)
+		args, err := decodeArguments(fn["arguments"])
+		if err != nil {
+			return nil, fmt.Errorf("function call %s: %w", name, err)
+		}
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{Name: name, Arguments: args})
+	}
+
+	reason, _ := choice["finish_reason"].(string)
+	switch reason {
+	case "stop":
+		resp.FinishReason = FinishReasonStop
+	case "length":
+		resp.FinishReason = FinishReasonLength
+	case "tool_calls", "function_call":
+		resp.FinishReason = FinishReasonToolCalls
+	case "content_filter":
+		resp.FinishReason = FinishReasonContentFilter
+	default:
+		resp.FinishReason = FinishReasonOther
+	}
+	if resp.Refusal != "" {
+		resp.FinishReason = FinishReasonRefusal
+	}
+
+	return resp, nil
+}
+
+func normalizeAnthropic(raw map[string]interface{}) (*NormalizedResponse, error) {
+	blocks, ok := raw["content"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("response has no content blocks")
+	}
+
+	resp := &NormalizedResponse{ToolCalls: make([]ToolCall, 0)}
+	texts := make([]string, 0)
+	for _, b := range blocks {
+		block, _ := b.(map[string]interface{})
+		switch block["type"] {
+		case "text":
+			if text, ok := block["text"].(string); ok {
+				texts = append(texts, text)
+			}
+		case "tool_use":
+			id, _ := block["id"].(string)
+			name, _ := block["name"].(string)
+			args, err := decodeArguments(block["input"])
+			if err != nil {
+				return nil, fmt.Errorf("tool use %s: %w", name, err)
+			}
+			resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: id, Name: name, Arguments: args})
+		}
+	}
+	resp.Content = strings.Join(texts, "")
+
+	reason, _ := raw["stop_reason"].(string)
+	switch reason {
+	case "end_turn", "stop_sequence":
+		resp.FinishReason = FinishReasonStop
+	case "max_tokens":
+		resp.FinishReason = FinishReasonLength
+	case "tool_use":
+		resp.FinishReason = FinishReasonToolCalls
+	case "refusal":
+		resp.FinishReason = FinishReasonRefusal
+		resp.Refusal = resp.Content
+	default:
+		resp.FinishReason = FinishReasonOther
+	}
+
+	return resp, nil
+}
+
+func normalizeGoogle(raw map[string]interface{}) (*NormalizedResponse, error) {
+	candidates, _ := raw["candidates"].([]interface{})
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("response has no candidates")
+	}
+	candidate, _ := candidates[0].(map[string]interface{})
+	content, _ := candidate["content"].(map[string]interface{})
+	parts, _ := content["parts"].([]interface{})
+
+	resp := &NormalizedResponse{ToolCalls: make([]ToolCall, 0)}
+	texts := make([]string, 0)
+	for _, p := range parts {
+		part, _ := p.(map[string]interface{})
+		if text, ok := part["text"].(string); ok {
+			texts = append(texts, text)
+		}
+		if fn, ok := part["functionCall"].(map[string]interface{}); ok {
+			name, _ := fn["name"].(string)
+			args, err := decodeArguments(fn["args"])
+			if err != nil {
+				return nil, fmt.Errorf("function call %s: %w", name, err)
+			}
+			resp.ToolCalls = append(resp.ToolCalls, ToolCall{Name: name, Arguments: args})
+		}
+	}
+	resp.Content = strings.Join(texts, "")
+
+	reason, _ := candidate["finishReason"].(string)
+	switch reason {
+	case "STOP":
+		resp.FinishReason = FinishReasonStop
+		if len(resp.ToolCalls) > 0 {
+			resp.FinishReason = FinishReasonToolCalls
+		}
+	case "MAX_TOKENS":
+		resp.FinishReason = FinishReasonLength
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT":
+		resp.FinishReason = FinishReasonContentFilter
+	default:
+		resp.FinishReason = FinishReasonOther
+	}
+
+	return resp, nil
+}
+
+func normalizeCohere(raw map[string]interface{}) (*NormalizedResponse, error) {
+	resp := &NormalizedResponse{ToolCalls: make([]ToolCall, 0)}
+	resp.Content, _ = raw["text"].(string)
+
+	calls, _ := raw["tool_calls"].([]interface{})
+	for _, c := range calls {
+		call, _ := c.(map[string]interface{})
+		name, _ := call["name"].(string)
+		args, err := decodeArguments(call["parameters"])
+		if err != nil {
+			return nil, fmt.Errorf("tool call %s: %w", name, err)
+		}
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{Name: name, Arguments: args})
+	}
+
+	reason, _ := raw["finish_reason"].(string)
+	switch reason {
+	case "COMPLETE":
+		resp.FinishReason = FinishReasonStop
+		if len(resp.ToolCalls) > 0 {
+			resp.FinishReason = FinishReasonToolCalls
+		}
+	case "MAX_TOKENS":
+		resp.FinishReason = FinishReasonLength
+	case "ERROR_TOXIC":
+		resp.FinishReason = FinishReasonContentFilter
+	default:
+		resp.FinishReason = FinishReasonOther
+	}
+
+	return resp, nil
+}
+
+// decodeArguments accepts tool arguments as a JSON string or an object
+func decodeArguments(v interface{}) (map[string]interface{}, error) {
+	switch args := v.(type) {
+	case nil:
+		return map[string]interface{}{}, nil
+	case map[string]interface{}:
+		return args, nil
+	case string:
+		if strings.TrimSpace(args) == "" {
+			return map[string]interface{}{}, nil
+		}
+		decoded := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(args), &decoded); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %w", err)
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("unexpected arguments type %T", v)
+	}
+}
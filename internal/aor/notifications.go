@@ -0,0 +1,376 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationCategory is a kind of event users can be notified of
+type NotificationCategory string
+
+const (
+	NotifyRunFailure      NotificationCategory = "run_failure"
+	NotifyBudgetAlert     NotificationCategory = "budget_alert"
+	NotifyCanaryPromotion NotificationCategory = "canary_promotion"
+)
+
+// NotificationCategories lists the categories in digest order
+var NotificationCategories = []NotificationCategory{NotifyRunFailure, NotifyBudgetAlert, NotifyCanaryPromotion}
+
+var notificationLabels = map[NotificationCategory][2]string{
+	NotifyRunFailure:      {"run failure", "run failures"},
+	NotifyBudgetAlert:     {"budget alert", "budget alerts"},
+	NotifyCanaryPromotion: {"canary promotion", "canary promotions"},
+}
+
+// label names a count of notifications of the category
+func (c NotificationCategory) label(n int) string {
+	forms := notificationLabels[c]
+	if n == 1 {
+		return fmt.Sprintf("1 %s", forms[0])
+	}
+	return fmt.Sprintf("%d %s", n, forms[1])
+}
+
+// NotificationMode is how a user receives a category
+type NotificationMode string
+
+const (
+	NotifyImmediate NotificationMode = "immediate"
+	NotifyHourly    NotificationMode = "hourly" // Batched into an hourly digest
+	NotifyMute      NotificationMode = "mute"
+)
+
+const notificationDigestInterval = time.Hour
+
+// NotificationPreferences is how one user of an org wants to be notified.
+// Users are only notified once they have preferences.
+type NotificationPreferences struct {
+	OrgID     uuid.UUID                                 `json:"org_id"`
+	UserID    string                                    `json:"user_id"`
+	Email     string                                    `json:"email,omitempty"` // Where email notifications go; other backends address the user ID
+	Modes     map[NotificationCategory]NotificationMode `json:"modes"`           // Categories not listed are sent immediately
+	UpdatedAt time.Time                                 `json:"updated_at"`
+}
+
+// Validate checks the user, email address and every category's mode
+func (p *NotificationPreferences) Validate() error {
+	if strings.TrimSpace(p.UserID) == "" {
+		return fmt.Errorf("notification preferences need a user ID")
+	}
+	if p.Email != "" {
+		if _, err := mail.ParseAddress(p.Email); err != nil {
+			return fmt.Errorf("invalid email address %q", p.Email)
+		}
+	}
+	for category, mode := range p.Modes {
+		if _, ok := notificationLabels[category]; !ok {
+			return fmt.Errorf("unknown notification category %q: use run_failure, budget_alert or canary_promotion", category)
+		}
+		switch mode {
+		case NotifyImmediate, NotifyHourly, NotifyMute:
+		default:
+			return fmt.Errorf("unknown notification mode %q for %s: use immediate, hourly or mute", mode, category)
+		}
+	}
+	return nil
+}
+
+// Mode returns how the user receives a category
+func (p *NotificationPreferences) Mode(category NotificationCategory) NotificationMode {
+	if mode, ok := p.Modes[category]; ok {
+		return mode
+	}
+	return NotifyImmediate
+}
+
+// Notification is one message to a user: a single event, or a digest of
+// the events held for the user's hourly digest
+type Notification struct {
+	OrgID    uuid.UUID            `json:"org_id"`
+	UserID   string               `json:"user_id"`
+	Email    string               `json:"email,omitempty"`
+	Category NotificationCategory `json:"category,omitempty"` // Empty for digests
+	Subject  string               `json:"subject"`
+	Message  string               `json:"message"`
+	Digest   bool                 `json:"digest,omitempty"`
+	Items    []NotificationItem   `json:"items,omitempty"` // The events a digest covers
+	Time     time.Time            `json:"time"`
+}
+
+// NotificationItem is one event held for a digest
+type NotificationItem struct {
+	Category NotificationCategory `json:"category"`
+	Subject  string               `json:"subject"`
+	Message  string               `json:"message"`
+	Time     time.Time            `json:"time"`
+}
+
+// notificationRecipients splits an org's users into those notified of a
+// category now and those whose digest holds it; muted users are left out
+func notificationRecipients(prefs []*NotificationPreferences, category NotificationCategory) (immediate, digest []*NotificationPreferences) {
+	for _, p := range prefs {
+		switch p.Mode(category) {
+		case NotifyImmediate:
+			immediate = append(immediate, p)
+		case NotifyHourly:
+			digest = append(digest, p)
+		}
+	}
+	return immediate, digest
+}
+
+// buildNotificationDigest summarizes a user's held events by category,
+// oldest first within each
+func buildNotificationDigest(prefs *NotificationPreferences, items []NotificationItem, now time.Time) *Notification {
+	byCategory := make(map[NotificationCategory][]NotificationItem)
+	for _, item := range items {
+		byCategory[item.Category] = append(byCategory[item.Category], item)
+	}
+
+	counts := make([]string, 0, len(byCategory))
+	var body strings.Builder
+	ordered := make([]NotificationItem, 0, len(items))
+	for _, category := range NotificationCategories {
+		held := byCategory[category]
+		if len(held) == 0 {
+			continue
+		}
+		sort.SliceStable(held, func(i, j int) bool { return held[i].Time.Before(held[j].Time) })
+		counts = append(counts, category.label(len(held)))
+
+		if body.Len() > 0 {
+			body.WriteString("\n")
+		}
+		fmt.Fprintf(&body, "%s:\n", category.label(len(held)))
+		for _, item := range held {
+			fmt.Fprintf(&body, "- %s %s\n", item.Time.UTC().Format("15:04"), item.Message)
+		}
+		ordered = append(ordered, held...)
+	}
+
+	return &Notification{
+		OrgID:   prefs.OrgID,
+		UserID:  prefs.UserID,
+		Email:   prefs.Email,
+		Subject: "AgentFlow digest: " + strings.Join(counts, ", "),
+		Message: strings.TrimSuffix(body.String(), "\n"),
+		Digest:  true,
+		Items:   ordered,
+		Time:    now,
+	}
+}
+
+// SetNotificationPreferences validates and stores a user's preferences,
+// replacing any they had
+func (cp *ControlPlane) SetNotificationPreferences(ctx context.Context, prefs *NotificationPreferences) (*NotificationPreferences, error) {
+	if err := prefs.Validate(); err != nil {
+		return nil, err
+	}
+	if prefs.Modes == nil {
+		prefs.Modes = make(map[NotificationCategory]NotificationMode)
+	}
+	modesJSON, err := json.Marshal(prefs.Modes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal notification modes: %w", err)
+	}
+	prefs.UpdatedAt = time.Now()
+
+	query := `INSERT INTO notification_preference (org_id, user_id, email, modes, updated_at)
+			  VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT (org_id, user_id) DO UPDATE SET
+				email = EXCLUDED.email,
+				modes = EXCLUDED.modes,
+				updated_at = EXCLUDED.updated_at`
+	if _, err := cp.db.ExecContext(ctx, query, prefs.OrgID, prefs.UserID, prefs.Email, modesJSON, prefs.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// GetNotificationPreferences returns a user's preferences
+func (cp *ControlPlane) GetNotificationPreferences(ctx context.Context, orgID uuid.UUID, userID string) (*NotificationPreferences, error) {
+	query := `SELECT org_id, user_id, email, modes, updated_at FROM notification_preference WHERE org_id = $1 AND user_id = $2`
+	prefs, err := scanNotificationPreferences(cp.db.QueryRowContext(ctx, query, orgID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no notification preferences for user %s", userID)
+	}
+	return prefs, err
+}
+
+// ListNotificationPreferences returns the preferences of every user of an org
+func (cp *ControlPlane) ListNotificationPreferences(ctx context.Context, orgID uuid.UUID) ([]*NotificationPreferences, error) {
+	query := `SELECT org_id, user_id, email, modes, updated_at FROM notification_preference WHERE org_id = $1 ORDER BY user_id`
+	rows, err := cp.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification preferences: %w", err)
+	}
+	defer rows.Close()
+
+	prefs := make([]*NotificationPreferences, 0)
+	for rows.Next() {
+		p, err := scanNotificationPreferences(rows)
+		if err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, p)
+	}
+	return prefs, rows.Err()
+}
+
+// DeleteNotificationPreferences stops notifying a user and drops their
+// pending digest
+func (cp *ControlPlane) DeleteNotificationPreferences(ctx context.Context, orgID uuid.UUID, userID string) error {
+	result, err := cp.db.ExecContext(ctx, `DELETE FROM notification_preference WHERE org_id = $1 AND user_id = $2`, orgID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete notification preferences: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no notification preferences for user %s", userID)
+	}
+	if _, err := cp.db.ExecContext(ctx, `DELETE FROM notification_digest_item WHERE org_id = $1 AND user_id = $2`, orgID, userID); err != nil {
+		return fmt.Errorf("failed to drop pending digest: %w", err)
+	}
+	return nil
+}
+
+func scanNotificationPreferences(row rowScanner) (*NotificationPreferences, error) {
+	var prefs NotificationPreferences
+	var modesJSON []byte
+	if err := row.Scan(&prefs.OrgID, &prefs.UserID, &prefs.Email, &modesJSON, &prefs.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan notification preferences: %w", err)
+	}
+	if err := json.Unmarshal(modesJSON, &prefs.Modes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal notification modes: %w", err)
+	}
+	return &prefs, nil
+}
+
+// notify tells an org's users of an event by their preferences: now, in
+// their next hourly digest, or not at all
+func (cp *ControlPlane) notify(ctx context.Context, orgID uuid.UUID, category NotificationCategory, subject, message string) {
+	prefs, err := cp.ListNotificationPreferences(ctx, orgID)
+	if err != nil {
+		log.Printf("Failed to load notification preferences for org %s: %v", orgID, err)
+		return
+	}
+	immediate, digest := notificationRecipients(prefs, category)
+
+	now := time.Now()
+	for _, p := range digest {
+		query := `INSERT INTO notification_digest_item (org_id, user_id, category, subject, message, created_at)
+				  VALUES ($1, $2, $3, $4, $5, $6)`
+		if _, err := cp.db.ExecContext(ctx, query, orgID, p.UserID, string(category), subject, message, now); err != nil {
+			log.Printf("Failed to hold %s notification for user %s: %v", category, p.UserID, err)
+		}
+	}
+	for _, p := range immediate {
+		cp.sendNotification(ctx, &Notification{
+			OrgID: orgID, UserID: p.UserID, Email: p.Email, Category: category,
+			Subject: subject, Message: message, Time: now,
+		})
+	}
+	notificationsSent.Add(float64(len(immediate)), string(NotifyImmediate))
+	notificationsSent.Add(float64(len(digest)), string(NotifyHourly))
+}
+
+// sendNotification delivers a notification through every configured backend
+func (cp *ControlPlane) sendNotification(ctx context.Context, notification *Notification) {
+	for _, notifier := range cp.userNotifiers {
+		if err := notifier.Send(ctx, notification); err != nil {
+			log.Printf("Failed to notify user %s: %v", notification.UserID, err)
+		}
+	}
+}
+
+// notifyRunFailure tells users a run failed, with its triaged cause
+func (cp *ControlPlane) notifyRunFailure(ctx context.Context, run *WorkflowRun, failure *RunFailure) {
+	subject := fmt.Sprintf("Run of %s failed: %s", run.WorkflowName, failure.Category)
+	message := fmt.Sprintf("Run %s of %s failed (%s)", run.ID, run.WorkflowName, failure.Category)
+	if failure.StepID != "" {
+		message += " at step " + failure.StepID
+	}
+	message += ": " + failure.Message
+	go cp.notify(context.WithoutCancel(ctx), run.OrgID, NotifyRunFailure, subject, message)
+}
+
+// SendNotificationDigests claims every held event older than the cutoff and
+// sends each user one digest of theirs, returning how many were sent
+func (cp *ControlPlane) SendNotificationDigests(ctx context.Context, cutoff time.Time) (int, error) {
+	query := `DELETE FROM notification_digest_item WHERE created_at <= $1
+			  RETURNING org_id, user_id, category, subject, message, created_at`
+	rows, err := cp.db.QueryContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim digest items: %w", err)
+	}
+	defer rows.Close()
+
+	type recipient struct {
+		orgID  uuid.UUID
+		userID string
+	}
+	held := make(map[recipient][]NotificationItem)
+	order := make([]recipient, 0)
+	for rows.Next() {
+		var r recipient
+		var item NotificationItem
+		var category string
+		if err := rows.Scan(&r.orgID, &r.userID, &category, &item.Subject, &item.Message, &item.Time); err != nil {
+			return 0, fmt.Errorf("failed to scan digest item: %w", err)
+		}
+		item.Category = NotificationCategory(category)
+		if _, ok := held[r]; !ok {
+			order = append(order, r)
+		}
+		held[r] = append(held[r], item)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read digest items: %w", err)
+	}
+
+	sent := 0
+	now := time.Now()
+	for _, r := range order {
+		prefs, err := cp.GetNotificationPreferences(ctx, r.orgID, r.userID)
+		if err != nil {
+			log.Printf("Dropping digest of %d events for user %s: %v", len(held[r]), r.userID, err)
+			continue
+		}
+		cp.sendNotification(ctx, buildNotificationDigest(prefs, held[r], now))
+		sent++
+	}
+	notificationDigests.Add(float64(sent))
+	return sent, nil
+}
+
+// runNotificationDigests sends the held events once an hour
+func (cp *ControlPlane) runNotificationDigests(ctx context.Context) {
+	ticker := time.NewTicker(notificationDigestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cp.shutdown:
+			return
+		case <-ticker.C:
+			if _, err := cp.SendNotificationDigests(ctx, time.Now()); err != nil {
+				log.Printf("Failed to send notification digests: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Built-in output processor types
+const (
+	OutputProcessorMarkdownHTML = "markdown_html"
+	OutputProcessorJSONRepair   = "json_repair"
+	OutputProcessorCitations    = "citations"
+	OutputProcessorProfanity    = "profanity_filter"
+)
+
+func init() {
+	RegisterOutputProcessor(OutputProcessorMarkdownHTML, newMarkdownHTMLProcessor)
+	RegisterOutputProcessor(OutputProcessorJSONRepair, newJSONRepairProcessor)
+	RegisterOutputProcessor(OutputProcessorCitations, newCitationProcessor)
+	RegisterOutputProcessor(OutputProcessorProfanity, newProfanityProcessor)
+}
+
+// markdownHTMLProcessor renders Markdown strings as HTML
+type markdownHTMLProcessor struct{}
+
+func newMarkdownHTMLProcessor(config map[string]interface{}) (OutputProcessor, error) {
+	return markdownHTMLProcessor{}, nil
+}
+
+func (markdownHTMLProcessor) Process(ctx context.Context, value interface{}, env *OutputEnv) (interface{}, error) {
+	return mapStrings(value, func(s string) (interface{}, error) {
+		return markdownToHTML(s), nil
+	})
+}
+
+var (
+	markdownHeading   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	markdownBullet    = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	markdownNumbered  = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	markdownCodeSpan  = regexp.MustCompile("`[^`]+`")
+	markdownStrong    = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	markdownEmphasis  = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	markdownLink      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownSafeLinks = regexp.MustCompile(`^(https?://|mailto:|/|#)`)
+)
+
+// markdownToHTML converts headings, paragraphs, lists, fenced code and
+// inline code, emphasis and links. All text is escaped; links other than
+// http, mailto and relative ones are rendered as plain text.
+func markdownToHTML(src string) string {
+	var out []string
+	var paragraph, code []string
+	list, inCode := "", false
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			out = append(out, "<p>"+markdownInline(strings.Join(paragraph, " "))+"</p>")
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			out = append(out, "</"+list+">")
+			list = ""
+		}
+	}
+	listItem := func(kind, text string) {
+		flushParagraph()
+		if list != kind {
+			closeList()
+			out = append(out, "<"+kind+">")
+			list = kind
+		}
+		out = append(out, "<li>"+markdownInline(text)+"</li>")
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if inCode {
+			if strings.HasPrefix(trimmed, "```") {
+				out = append(out, "<pre><code>"+html.EscapeString(strings.Join(code, "\n"))+"</code></pre>")
+				code, inCode = nil, false
+			} else {
+				code = append(code, line)
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flushParagraph()
+			closeList()
+			inCode = true
+		case trimmed == "":
+			flushParagraph()
+			closeList()
+		case markdownHeading.MatchString(trimmed):
+			flushParagraph()
+			closeList()
+			m := markdownHeading.FindStringSubmatch(trimmed)
+			level := len(m[1])
+			out = append(out, fmt.Sprintf("<h%d>%s</h%d>", level, markdownInline(m[2]), level))
+		case markdownBullet.MatchString(line):
+			listItem("ul", markdownBullet.FindStringSubmatch(line)[1])
+		case markdownNumbered.MatchString(line):
+			listItem("ol", markdownNumbered.FindStringSubmatch(line)[1])
+		default:
+			closeList()
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	if inCode {
+		out = append(out, "<pre><code>"+html.EscapeString(strings.Join(code, "\n"))+"</code></pre>")
+	}
+	flushParagraph()
+	closeList()
+	return strings.Join(out, "\n")
+}
+
+// markdownInline renders inline Markdown, leaving code spans untouched
+func markdownInline(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range markdownCodeSpan.FindAllStringIndex(text, -1) {
+		b.WriteString(markdownEmphasisAndLinks(text[last:span[0]]))
+		b.WriteString("<code>" + html.EscapeString(text[span[0]+1:span[1]-1]) + "</code>")
+		last = span[1]
+	}
+	b.WriteString(markdownEmphasisAndLinks(text[last:]))
+	return b.String()
+}
+
+func markdownEmphasisAndLinks(text string) string {
+	text = html.EscapeString(text)
+	text = markdownLink.ReplaceAllStringFunc(text, func(m string) string {
+		parts := markdownLink.FindStringSubmatch(m)
+		if !markdownSafeLinks.MatchString(parts[2]) {
+			return parts[1]
+		}
+		return `<a href="` + parts[2] + `">` + parts[1] + "</a>"
+	})
+	text = markdownStrong.ReplaceAllString(text, "<strong>$1$2</strong>")
+	return markdownEmphasis.ReplaceAllString(text, "<em>$1</em>")
+}
+
+// jsonRepairProcessor parses JSON-looking strings into values, repairing
+// code fences, surrounding prose, trailing commas and truncation
+type jsonRepairProcessor struct{}
+
+func newJSONRepairProcessor(config map[string]interface{}) (OutputProcessor, error) {
+	return jsonRepairProcessor{}, nil
+}
+
+func (jsonRepairProcessor) Process(ctx context.Context, value interface{}, env *OutputEnv) (interface{}, error) {
+	return mapStrings(value, func(s string) (interface{}, error) {
+		trimmed := strings.TrimSpace(s)
+		if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "```") {
+			return s, nil // Prose is left alone
+		}
+		return repairJSON(trimmed)
+	})
+}
+
+// repairJSON parses possibly malformed JSON from a model response
+func repairJSON(s string) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s[3:], "json")
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(s), &value); err == nil {
+		return value, nil
+	}
+
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return nil, fmt.Errorf("no JSON object or array found")
+	}
+
+	var b strings.Builder
+	var stack []byte
+	inString, escaped := false, false
+	trimTrailingComma := func() {
+		kept := strings.TrimRightFunc(b.String(), unicode.IsSpace)
+		kept = strings.TrimSuffix(kept, ",")
+		b.Reset()
+		b.WriteString(kept)
+	}
+
+scan:
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			b.WriteByte(c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			trimTrailingComma()
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			b.WriteByte(c)
+			if len(stack) == 0 {
+				break scan // Anything after the value is prose
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	// Close what a truncated response left open
+	if inString {
+		if escaped {
+			b.WriteByte('\\')
+		}
+		b.WriteByte('"')
+	}
+	trimTrailingComma()
+	if strings.HasSuffix(b.String(), ":") {
+		b.WriteString("null")
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		b.WriteByte(stack[i])
+	}
+
+	if err := json.Unmarshal([]byte(b.String()), &value); err != nil {
+		return nil, fmt.Errorf("unrepairable JSON: %w", err)
+	}
+	return value, nil
+}
+
+const defaultCitationOverlap = 0.5
+
+// citationProcessor marks sentences supported by retrieved chunks with the
+// chunk's source number and appends the list of cited sources. Chunks come
+// from a step's output, such as a rag_retrieve step or SCL prepared context.
+type citationProcessor struct {
+	sourceStep  string
+	chunksField string
+	minOverlap  float64
+}
+
+// citationChunk accepts RAG chunks ({text, document_id, index}) and SCL
+// context chunks ({id, content})
+type citationChunk struct {
+	ID         string `json:"id"`
+	DocumentID string `json:"document_id"`
+	Index      *int   `json:"index"`
+	Text       string `json:"text"`
+	Content    string `json:"content"`
+}
+
+func (c citationChunk) text() string {
+	if c.Text != "" {
+		return c.Text
+	}
+	return c.Content
+}
+
+func (c citationChunk) label() string {
+	switch {
+	case c.DocumentID != "" && c.Index != nil:
+		return fmt.Sprintf("%s#%d", c.DocumentID, *c.Index)
+	case c.DocumentID != "":
+		return c.DocumentID
+	}
+	return c.ID
+}
+
+func newCitationProcessor(config map[string]interface{}) (OutputProcessor, error) {
+	p := &citationProcessor{chunksField: "chunks", minOverlap: defaultCitationOverlap}
+	p.sourceStep, _ = config["source_step"].(string)
+	if p.sourceStep == "" {
+		return nil, fmt.Errorf("source_step naming the step whose output holds the chunks is required")
+	}
+	if field, ok := config["chunks_field"].(string); ok && field != "" {
+		p.chunksField = field
+	}
+	if overlap, ok := config["min_overlap"]; ok {
+		v, ok := overlap.(float64)
+		if !ok || v <= 0 || v > 1 {
+			return nil, fmt.Errorf("min_overlap must be a number in (0, 1]")
+		}
+		p.minOverlap = v
+	}
+	return p, nil
+}
+
+func (p *citationProcessor) Process(ctx context.Context, value interface{}, env *OutputEnv) (interface{}, error) {
+	source, err := env.StepOutput(ctx, p.sourceStep)
+	if err != nil {
+		return nil, err
+	}
+	obj, _ := source.(map[string]interface{})
+	raw, ok := obj[p.chunksField]
+	if !ok {
+		return nil, fmt.Errorf("step %s output has no %s", p.sourceStep, p.chunksField)
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+	var chunks []citationChunk
+	if err := json.Unmarshal(data, &chunks); err != nil {
+		return nil, fmt.Errorf("step %s %s is not a list of chunks: %w", p.sourceStep, p.chunksField, err)
+	}
+
+	return mapStrings(value, func(s string) (interface{}, error) {
+		return insertCitations(s, chunks, p.minOverlap), nil
+	})
+}
+
+var (
+	citationSentence = regexp.MustCompile(`[^.!?\n]+[.!?]*`)
+	citationWord     = regexp.MustCompile(`[\p{L}\p{N}]{4,}`)
+)
+
+// minCitedSentenceWords keeps short fragments from being matched by chance
+const minCitedSentenceWords = 3
+
+// insertCitations appends [n] to each sentence whose words mostly appear in
+// chunk n, numbering chunks from 1 as the rag_generate prompt does
+func insertCitations(text string, chunks []citationChunk, minOverlap float64) string {
+	chunkWords := make([]map[string]bool, len(chunks))
+	for i, chunk := range chunks {
+		chunkWords[i] = citationWordSet(chunk.text())
+	}
+
+	cited := make(map[int]bool)
+	result := citationSentence.ReplaceAllStringFunc(text, func(sentence string) string {
+		words := citationWordSet(sentence)
+		if len(words) < minCitedSentenceWords {
+			return sentence
+		}
+		best, bestOverlap := -1, 0.0
+		for i, set := range chunkWords {
+			shared := 0
+			for word := range words {
+				if set[word] {
+					shared++
+				}
+			}
+			if overlap := float64(shared) / float64(len(words)); overlap > bestOverlap {
+				best, bestOverlap = i, overlap
+			}
+		}
+		if best < 0 || bestOverlap < minOverlap {
+			return sentence
+		}
+		cited[best] = true
+
+		// The marker goes before the sentence's closing punctuation
+		body := strings.TrimRightFunc(sentence, unicode.IsSpace)
+		trailing := sentence[len(body):]
+		end := strings.TrimRight(body, ".!?")
+		return fmt.Sprintf("%s [%d]%s%s", end, best+1, body[len(end):], trailing)
+	})
+	if len(cited) == 0 {
+		return text
+	}
+
+	var sources strings.Builder
+	sources.WriteString("\n\nSources:")
+	for i, chunk := range chunks {
+		if cited[i] {
+			fmt.Fprintf(&sources, "\n[%d] %s", i+1, chunk.label())
+		}
+	}
+	return strings.TrimRightFunc(result, unicode.IsSpace) + sources.String()
+}
+
+func citationWordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range citationWord.FindAllString(strings.ToLower(text), -1) {
+		words[word] = true
+	}
+	return words
+}
+
+// defaultProfanity is masked unless a workflow allows a word
+var defaultProfanity = []string{
+	"asshole", "bastard", "bitch", "bullshit", "crap", "cunt", "damn",
+	"dick", "fuck", "motherfucker", "piss", "shit", "slut", "whore",
+}
+
+// profanityProcessor masks profane words in strings
+type profanityProcessor struct {
+	pattern *regexp.Regexp
+	mask    string
+}
+
+func newProfanityProcessor(config map[string]interface{}) (OutputProcessor, error) {
+	extra, err := stringList(config["words"])
+	if err != nil {
+		return nil, fmt.Errorf("words: %w", err)
+	}
+	allow, err := stringList(config["allow"])
+	if err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	p := &profanityProcessor{mask: "*"}
+	if mask, ok := config["mask"].(string); ok {
+		if utf8.RuneCountInString(mask) != 1 {
+			return nil, fmt.Errorf("mask must be a single character")
+		}
+		p.mask = mask
+	}
+
+	allowed := make(map[string]bool, len(allow))
+	for _, word := range allow {
+		allowed[strings.ToLower(word)] = true
+	}
+	words := make([]string, 0, len(defaultProfanity)+len(extra))
+	for _, word := range append(append([]string(nil), defaultProfanity...), extra...) {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" && !allowed[word] {
+			words = append(words, regexp.QuoteMeta(word))
+		}
+	}
+	if len(words) > 0 {
+		p.pattern = regexp.MustCompile(`(?i)\b(?:` + strings.Join(words, "|") + `)(?:s|es|ed|ing|er|ers)?\b`)
+	}
+	return p, nil
+}
+
+func (p *profanityProcessor) Process(ctx context.Context, value interface{}, env *OutputEnv) (interface{}, error) {
+	if p.pattern == nil {
+		return value, nil
+	}
+	return mapStrings(value, func(s string) (interface{}, error) {
+		return p.pattern.ReplaceAllStringFunc(s, func(word string) string {
+			return strings.Repeat(p.mask, utf8.RuneCountInString(word))
+		}), nil
+	})
+}
@@ -0,0 +1,398 @@
+package aor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Executor plugins add custom step types without recompiling the worker.
+// A plugin is an executable in the configured plugin directory that speaks
+// JSON-RPC 2.0 over stdio, one JSON object per line. The worker starts each
+// plugin once, calls "describe" to learn its step types, then sends one
+// "execute" call per step; calls may be in flight concurrently and are
+// matched to responses by id. Closing stdin asks the plugin to exit.
+//
+//	-> {"jsonrpc":"2.0","id":1,"method":"describe"}
+//	<- {"jsonrpc":"2.0","id":1,"result":{"name":"jira","version":"1.2.0","protocol_version":1,"step_types":["jira.create_issue"]}}
+//	-> {"jsonrpc":"2.0","id":2,"method":"execute","params":{"step_type":"jira.create_issue","config":{...},"inputs":{...},...}}
+//	<- {"jsonrpc":"2.0","id":2,"result":{"output":{"issue":"OPS-42"}}}
+//
+// A plugin reports a failed step with a JSON-RPC error. Code -32001 marks a
+// permanent failure, which is not retried.
+const PluginProtocolVersion = 1
+
+const (
+	// PluginErrorPermanent marks a step failure that would recur on retry
+	PluginErrorPermanent = -32001
+	// PluginErrorInvalidParams is the JSON-RPC code for a rejected request
+	PluginErrorInvalidParams = -32602
+
+	// pluginDescribeTimeout bounds how long a plugin may take to start and describe itself
+	pluginDescribeTimeout = 10 * time.Second
+	// pluginMaxMessageSize bounds one protocol message
+	pluginMaxMessageSize = 16 << 20
+)
+
+// PluginManifest is a plugin's answer to "describe"
+type PluginManifest struct {
+	Name            string   `json:"name"`
+	Version         string   `json:"version"`
+	ProtocolVersion int      `json:"protocol_version"`
+	StepTypes       []string `json:"step_types"`
+}
+
+// PluginExecuteParams are the parameters of an "execute" call
+type PluginExecuteParams struct {
+	TaskID   uuid.UUID              `json:"task_id"`
+	RunID    uuid.UUID              `json:"run_id"`
+	StepID   string                 `json:"step_id"`
+	OrgID    uuid.UUID              `json:"org_id"`
+	Workflow string                 `json:"workflow,omitempty"`
+	NodeID   string                 `json:"node_id"`
+	StepType string                 `json:"step_type"`
+	Config   map[string]interface{} `json:"config"`
+	Inputs   map[string]interface{} `json:"inputs"`
+	Deadline time.Time              `json:"deadline"`
+}
+
+// PluginExecuteResult is the result of an "execute" call
+type PluginExecuteResult struct {
+	Output           map[string]interface{} `json:"output"`
+	CostCents        int64                  `json:"cost_cents,omitempty"`
+	TokensPrompt     int                    `json:"tokens_prompt,omitempty"`
+	TokensCompletion int                    `json:"tokens_completion,omitempty"`
+	Provider         string                 `json:"provider,omitempty"`
+	Model            string                 `json:"model,omitempty"`
+}
+
+// PluginError is a JSON-RPC error returned by a plugin
+type PluginError struct {
+	Plugin  string          `json:"-"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("plugin %s: %s (code %d)", e.Plugin, e.Message, e.Code)
+}
+
+// Retryable reports whether the same call might succeed if made again
+func (e *PluginError) Retryable() bool {
+	return e.Code != PluginErrorPermanent && e.Code != PluginErrorInvalidParams
+}
+
+type pluginRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      uint64      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type pluginResponse struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *PluginError    `json:"error"`
+}
+
+// Plugin is a running executor plugin process. A plugin that exits is
+// restarted on its next call.
+type Plugin struct {
+	path     string
+	Manifest PluginManifest
+
+	mu      sync.Mutex
+	writeMu sync.Mutex // Serializes requests on stdin without holding mu
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	pending map[uint64]chan *pluginResponse
+	nextID  uint64
+	exited  chan struct{}
+	running bool
+}
+
+func NewPlugin(path string) *Plugin {
+	return &Plugin{path: path}
+}
+
+// Name returns the plugin's name, or its file name before it has described itself
+func (p *Plugin) Name() string {
+	if p.Manifest.Name != "" {
+		return p.Manifest.Name
+	}
+	return filepath.Base(p.path)
+}
+
+// start launches the plugin process; p.mu must be held
+func (p *Plugin) start() error {
+	cmd := exec.Command(p.path)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open plugin stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open plugin stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", p.Name(), err)
+	}
+
+	p.cmd = cmd
+	p.stdin = stdin
+	p.pending = make(map[uint64]chan *pluginResponse)
+	p.exited = make(chan struct{})
+	p.running = true
+	go p.readLoop(cmd, stdout, p.exited)
+	return nil
+}
+
+// readLoop delivers responses to their callers until the plugin exits
+func (p *Plugin) readLoop(cmd *exec.Cmd, stdout io.Reader, exited chan struct{}) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), pluginMaxMessageSize)
+	for scanner.Scan() {
+		var resp pluginResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			log.Printf("Plugin %s wrote an invalid message: %v", p.Name(), err)
+			continue
+		}
+		p.mu.Lock()
+		ch, ok := p.pending[resp.ID]
+		delete(p.pending, resp.ID)
+		p.mu.Unlock()
+		if ok {
+			ch <- &resp
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		// The plugin can no longer be read, so stop it rather than wait
+		// for an exit that may never come
+		log.Printf("Plugin %s output could not be read: %v", p.Name(), err)
+		_ = cmd.Process.Kill()
+	}
+
+	// Mark the plugin stopped before waiting so new calls restart it
+	p.mu.Lock()
+	if p.exited == exited {
+		p.running = false
+		p.pending = nil
+		_ = p.stdin.Close()
+	}
+	p.mu.Unlock()
+	err := cmd.Wait()
+	close(exited)
+	if err != nil {
+		log.Printf("Plugin %s exited: %v", p.Name(), err)
+	}
+}
+
+// Call sends a request and decodes the result into out
+func (p *Plugin) Call(ctx context.Context, method string, params, out interface{}) error {
+	p.mu.Lock()
+	if !p.running {
+		if err := p.start(); err != nil {
+			p.mu.Unlock()
+			return err
+		}
+	}
+	p.nextID++
+	id := p.nextID
+	ch := make(chan *pluginResponse, 1)
+	p.pending[id] = ch
+	exited, stdin := p.exited, p.stdin
+	p.mu.Unlock()
+
+	// A plugin that stops reading blocks the write, so it must not hold
+	// mu, which delivers responses and lets other callers give up
+	data, err := json.Marshal(pluginRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err == nil {
+		p.writeMu.Lock()
+		_, err = stdin.Write(append(data, '\n'))
+		p.writeMu.Unlock()
+	}
+	if err != nil {
+		p.mu.Lock()
+		if p.pending != nil {
+			delete(p.pending, id)
+		}
+		p.mu.Unlock()
+		return fmt.Errorf("failed to send %s to plugin %s: %w", method, p.Name(), err)
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			resp.Error.Plugin = p.Name()
+			return resp.Error
+		}
+		if out == nil {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, out); err != nil {
+			return fmt.Errorf("failed to decode plugin %s %s result: %w", p.Name(), method, err)
+		}
+		return nil
+	case <-exited:
+		return fmt.Errorf("plugin %s exited during %s", p.Name(), method)
+	case <-ctx.Done():
+		p.mu.Lock()
+		if p.pending != nil {
+			delete(p.pending, id)
+		}
+		p.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Close asks the plugin to exit and waits for it, killing it after the
+// context ends
+func (p *Plugin) Close(ctx context.Context) {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return
+	}
+	exited, cmd := p.exited, p.cmd
+	_ = p.stdin.Close()
+	p.mu.Unlock()
+
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		log.Printf("Plugin %s did not exit in time, killing it", p.Name())
+		_ = cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// describe starts the plugin and loads its manifest
+func (p *Plugin) describe(ctx context.Context) error {
+	var manifest PluginManifest
+	if err := p.Call(ctx, "describe", nil, &manifest); err != nil {
+		return err
+	}
+	if manifest.ProtocolVersion != PluginProtocolVersion {
+		return fmt.Errorf("plugin %s speaks protocol version %d, expected %d", p.Name(), manifest.ProtocolVersion, PluginProtocolVersion)
+	}
+	if strings.TrimSpace(manifest.Name) == "" {
+		return fmt.Errorf("plugin %s has no name", p.Name())
+	}
+	if len(manifest.StepTypes) == 0 {
+		return fmt.Errorf("plugin %s declares no step types", manifest.Name)
+	}
+	p.Manifest = manifest
+	return nil
+}
+
+// LoadPlugins starts and describes every executable in dir. Plugins that
+// fail to start or describe themselves are logged and skipped so one broken
+// plugin does not keep the worker down. An empty dir loads nothing.
+func LoadPlugins(dir string) ([]*Plugin, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	plugins := make([]*Plugin, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+
+		plugin := NewPlugin(filepath.Join(dir, entry.Name()))
+		ctx, cancel := context.WithTimeout(context.Background(), pluginDescribeTimeout)
+		err = plugin.describe(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("Skipping plugin %s: %v", entry.Name(), err)
+			closeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+			plugin.Close(closeCtx)
+			cancel()
+			continue
+		}
+		log.Printf("Loaded plugin %s %s with step types %s", plugin.Manifest.Name, plugin.Manifest.Version, strings.Join(plugin.Manifest.StepTypes, ", "))
+		plugins = append(plugins, plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Manifest.Name < plugins[j].Manifest.Name })
+	return plugins, nil
+}
+
+// PluginExecutor runs steps of a plugin's step types
+type PluginExecutor struct {
+	plugin  *Plugin
+	timeout time.Duration
+}
+
+func NewPluginExecutor(plugin *Plugin, timeout time.Duration) *PluginExecutor {
+	return &PluginExecutor{plugin: plugin, timeout: timeout}
+}
+
+func (e *PluginExecutor) Execute(ctx context.Context, task *Task) (*TaskResult, error) {
+	start := time.Now()
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	params := PluginExecuteParams{
+		TaskID:   task.ID,
+		RunID:    task.RunID,
+		StepID:   task.StepID,
+		OrgID:    task.OrgID,
+		Workflow: task.Workflow,
+		NodeID:   task.Node.ID,
+		StepType: task.Node.Type,
+		Config:   task.Node.Config,
+		Inputs:   task.Inputs,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		params.Deadline = deadline
+	}
+
+	log.Printf("Executing %s task %s with plugin %s", task.Node.Type, task.ID, e.plugin.Name())
+	var result PluginExecuteResult
+	if err := e.plugin.Call(ctx, "execute", params, &result); err != nil {
+		return nil, err
+	}
+
+	return &TaskResult{
+		TaskID:           task.ID,
+		Status:           TaskStatusSucceeded,
+		Output:           result.Output,
+		CostCents:        result.CostCents,
+		TokensPrompt:     result.TokensPrompt,
+		TokensCompletion: result.TokensCompletion,
+		Provider:         result.Provider,
+		Model:            result.Model,
+		ExecutedAt:       time.Now(),
+		Duration:         time.Since(start),
+	}, nil
+}
+
+func (e *PluginExecutor) CanHandle(stepType string) bool {
+	return containsString(e.plugin.Manifest.StepTypes, stepType)
+}
@@ -0,0 +1,280 @@
+package aor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+)
+
+// PortType is the kind of value a step port carries
+type PortType string
+
+const (
+	PortTypeString      PortType = "string"
+	PortTypeJSON        PortType = "json"
+	PortTypeArtifactRef PortType = "artifact-ref"
+	PortTypeEmbedding   PortType = "embedding"
+)
+
+// Port directions reported in PortTypeError
+const (
+	PortInput  = "input"
+	PortOutput = "output"
+)
+
+var (
+	portNamePattern    = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,62}$`)
+	artifactRefPattern = regexp.MustCompile(`^(sha256:[0-9a-f]{64}|[a-z][a-z0-9+.-]*://\S+)$`)
+)
+
+// StepPorts declares the typed inputs and outputs of a step, configured as
+// ports: {inputs: {chunks: json}, outputs: {summary: string}}. Inputs are
+// wired from upstream output ports with inputs: {chunks: "chunk.chunks"}.
+type StepPorts struct {
+	Inputs  map[string]PortType `json:"inputs,omitempty"`
+	Outputs map[string]PortType `json:"outputs,omitempty"`
+}
+
+// PortTypeError is returned when a value does not match its port's type
+type PortTypeError struct {
+	StepID    string   `json:"step_id"`
+	StepType  string   `json:"step_type"`
+	Direction string   `json:"direction"`
+	Port      string   `json:"port"`
+	Expected  PortType `json:"expected"`
+	Reason    string   `json:"reason"`
+}
+
+func (e *PortTypeError) Error() string {
+	return fmt.Sprintf("step %s %s port %s expects %s: %s", e.StepID, e.Direction, e.Port, e.Expected, e.Reason)
+}
+
+// ErrorClass reports port mismatches to telemetry
+func (e *PortTypeError) ErrorClass() cas.ErrorClass {
+	return cas.ErrorClassInvalidRequest
+}
+
+// Retryable reports whether running the step again could fix the mismatch;
+// only model output varies between attempts
+func (e *PortTypeError) Retryable() bool {
+	return e.Direction == PortOutput && ExecutorType(e.StepType) == ExecutorTypeLLM
+}
+
+// parseStepPorts reads a step's ports config, returning nil when it declares none
+func parseStepPorts(config map[string]interface{}) (*StepPorts, error) {
+	raw, ok := config["ports"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	ports := &StepPorts{}
+	for _, direction := range []string{"inputs", "outputs"} {
+		declared, ok := raw[direction].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		parsed := make(map[string]PortType, len(declared))
+		for name, value := range declared {
+			if !portNamePattern.MatchString(name) {
+				return nil, fmt.Errorf("invalid port name %q", name)
+			}
+			portType, _ := value.(string)
+			switch PortType(portType) {
+			case PortTypeString, PortTypeJSON, PortTypeArtifactRef, PortTypeEmbedding:
+			default:
+				return nil, fmt.Errorf("port %s has unknown type %q: use string, json, artifact-ref or embedding", name, portType)
+			}
+			parsed[name] = PortType(portType)
+		}
+		if direction == "inputs" {
+			ports.Inputs = parsed
+		} else {
+			ports.Outputs = parsed
+		}
+	}
+	return ports, nil
+}
+
+// transcribePorts are the ports of transcribe steps that declare none
+func transcribePorts() *StepPorts {
+	return &StepPorts{Outputs: map[string]PortType{"transcript": PortTypeString, "segments": PortTypeJSON}}
+}
+
+// assertPorts are the ports of assert steps that declare none
+func assertPorts() *StepPorts {
+	return &StepPorts{Outputs: map[string]PortType{"passed": PortTypeJSON, "failures": PortTypeJSON, "branch": PortTypeString}}
+}
+
+// stepPorts returns a step's declared ports, or the built-in ports of step
+// types whose outputs have a fixed shape
+func stepPorts(stepType string, config map[string]interface{}) (*StepPorts, error) {
+	ports, err := parseStepPorts(config)
+	if err != nil || ports != nil {
+		return ports, err
+	}
+	switch ExecutorType(stepType) {
+	case ExecutorTypeTranscribe:
+		return transcribePorts(), nil
+	case ExecutorTypeAssert:
+		return assertPorts(), nil
+	}
+	return nil, nil
+}
+
+// portBindings reads a step's inputs config mapping input ports to "step.port" sources
+func portBindings(config map[string]interface{}) map[string]string {
+	raw, ok := config["inputs"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	bindings := make(map[string]string, len(raw))
+	for name, value := range raw {
+		if source, ok := value.(string); ok {
+			bindings[name] = source
+		}
+	}
+	return bindings
+}
+
+// portsCompatible reports whether an output of type from can feed an input of type to.
+// json inputs accept any value.
+func portsCompatible(from, to PortType) bool {
+	return from == to || to == PortTypeJSON
+}
+
+// ValidatePorts checks every step's port declarations and that each typed
+// input is wired to a compatible upstream output port
+func ValidatePorts(dag DAG) error {
+	ports := make(map[string]*StepPorts, len(dag.Steps))
+	for _, step := range dag.Steps {
+		declared, err := stepPorts(step.Type, step.Config)
+		if err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+		ports[step.ID] = declared
+	}
+
+	var problems []string
+	for _, step := range dag.Steps {
+		declared := ports[step.ID]
+		if declared == nil {
+			continue
+		}
+		bindings := portBindings(step.Config)
+		for _, name := range sortedPortNames(declared.Inputs) {
+			source, ok := bindings[name]
+			if !ok {
+				continue // Filled from run inputs
+			}
+			fromStep, fromPort, ok := strings.Cut(source, ".")
+			if !ok {
+				problems = append(problems, fmt.Sprintf("step %s input %s: source %q is not step.port", step.ID, name, source))
+				continue
+			}
+			upstream, known := ports[fromStep]
+			if !known {
+				problems = append(problems, fmt.Sprintf("step %s input %s: unknown step %s", step.ID, name, fromStep))
+				continue
+			}
+			if upstream == nil || upstream.Outputs == nil {
+				continue // Untyped upstream steps are checked at runtime only
+			}
+			fromType, ok := upstream.Outputs[fromPort]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("step %s input %s: step %s has no output port %s", step.ID, name, fromStep, fromPort))
+				continue
+			}
+			if !portsCompatible(fromType, declared.Inputs[name]) {
+				problems = append(problems, fmt.Sprintf("step %s input %s expects %s but %s.%s is %s", step.ID, name, declared.Inputs[name], fromStep, fromPort, fromType))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("incompatible ports:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// checkPortValues validates a task's inputs or outputs against its step's ports
+func checkPortValues(task *Task, direction string, values map[string]interface{}) error {
+	if task.Node == nil {
+		return nil
+	}
+	ports, err := stepPorts(task.Node.Type, task.Node.Config)
+	if err != nil || ports == nil {
+		return err
+	}
+	declared := ports.Inputs
+	if direction == PortOutput {
+		declared = ports.Outputs
+	}
+
+	for _, name := range sortedPortNames(declared) {
+		portErr := &PortTypeError{StepID: task.StepID, StepType: task.Node.Type, Direction: direction, Port: name, Expected: declared[name]}
+		value, ok := values[name]
+		if !ok {
+			portErr.Reason = "missing"
+			return portErr
+		}
+		if reason := portValueMismatch(declared[name], value); reason != "" {
+			portErr.Reason = reason
+			return portErr
+		}
+	}
+	return nil
+}
+
+// portValueMismatch describes why a value does not fit a port type, or returns ""
+func portValueMismatch(portType PortType, value interface{}) string {
+	switch portType {
+	case PortTypeString:
+		if _, ok := value.(string); !ok {
+			return fmt.Sprintf("got %T", value)
+		}
+	case PortTypeJSON:
+		if value == nil {
+			return "got null"
+		}
+	case PortTypeArtifactRef:
+		ref, ok := value.(string)
+		if !ok || !artifactRefPattern.MatchString(ref) {
+			return "want a sha256: content hash or a URI"
+		}
+	case PortTypeEmbedding:
+		switch vector := value.(type) {
+		case []float64:
+			if len(vector) == 0 {
+				return "got an empty vector"
+			}
+		case []float32:
+			if len(vector) == 0 {
+				return "got an empty vector"
+			}
+		case []interface{}:
+			if len(vector) == 0 {
+				return "got an empty vector"
+			}
+			for i, component := range vector {
+				if _, ok := component.(float64); !ok {
+					return fmt.Sprintf("component %d is %T, not a number", i, component)
+				}
+			}
+		default:
+			return fmt.Sprintf("got %T, not a vector", value)
+		}
+	}
+	return ""
+}
+
+func sortedPortNames(ports map[string]PortType) []string {
+	names := make([]string, 0, len(ports))
+	for name := range ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
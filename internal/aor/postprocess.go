@@ -0,0 +1,331 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Post-processor outcomes recorded in metrics
+const (
+	PostProcessApplied = "applied"
+	PostProcessFailed  = "failed"
+)
+
+// PostProcessorSpec declares one processor of a workflow's output pipeline,
+// e.g. {type: citations, fields: [answer], config: {source_step: retrieve}}
+type PostProcessorSpec struct {
+	Type   string                 `json:"type"`
+	Fields []string               `json:"fields,omitempty"` // Top-level output fields to process; the whole output when empty
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// OutputProcessor transforms a run's final output, or the value of one of
+// its fields
+type OutputProcessor interface {
+	Process(ctx context.Context, value interface{}, env *OutputEnv) (interface{}, error)
+}
+
+// OutputProcessorFactory builds a processor from its declared config,
+// rejecting invalid config
+type OutputProcessorFactory func(config map[string]interface{}) (OutputProcessor, error)
+
+// OutputEnv gives processors access to the run they process
+type OutputEnv struct {
+	RunID uuid.UUID
+	// StepOutput returns the latest output of one of the run's steps
+	StepOutput func(ctx context.Context, nodeID string) (interface{}, error)
+}
+
+var (
+	outputProcessorsMu sync.RWMutex
+	outputProcessors   = make(map[string]OutputProcessorFactory)
+)
+
+// RegisterOutputProcessor makes a processor type available to workflow
+// post_process pipelines. Registering a type twice panics.
+func RegisterOutputProcessor(name string, factory OutputProcessorFactory) {
+	outputProcessorsMu.Lock()
+	defer outputProcessorsMu.Unlock()
+	if _, taken := outputProcessors[name]; taken {
+		panic(fmt.Sprintf("output processor %s registered twice", name))
+	}
+	outputProcessors[name] = factory
+}
+
+// OutputProcessorTypes lists the registered processor types
+func OutputProcessorTypes() []string {
+	outputProcessorsMu.RLock()
+	defer outputProcessorsMu.RUnlock()
+	types := make([]string, 0, len(outputProcessors))
+	for name := range outputProcessors {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// OutputPipeline chains a workflow's processors in declaration order
+type OutputPipeline struct {
+	specs      []PostProcessorSpec
+	processors []OutputProcessor
+}
+
+// BuildOutputPipeline resolves each declared processor from the registry
+func BuildOutputPipeline(specs []PostProcessorSpec) (*OutputPipeline, error) {
+	pipeline := &OutputPipeline{specs: specs, processors: make([]OutputProcessor, 0, len(specs))}
+	for i, spec := range specs {
+		outputProcessorsMu.RLock()
+		factory, ok := outputProcessors[spec.Type]
+		outputProcessorsMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("post_process[%d]: unknown processor %q: use one of %v", i, spec.Type, OutputProcessorTypes())
+		}
+		processor, err := factory(spec.Config)
+		if err != nil {
+			return nil, fmt.Errorf("post_process[%d] %s: %w", i, spec.Type, err)
+		}
+		pipeline.processors = append(pipeline.processors, processor)
+	}
+	return pipeline, nil
+}
+
+// ValidatePostProcessors checks a workflow's post_process declarations
+func ValidatePostProcessors(specs []PostProcessorSpec) error {
+	_, err := BuildOutputPipeline(specs)
+	return err
+}
+
+// Run passes the output through every processor. A processor that fails is
+// skipped so the run keeps its output as processed so far.
+func (p *OutputPipeline) Run(ctx context.Context, output interface{}, env *OutputEnv) interface{} {
+	for i, processor := range p.processors {
+		spec := p.specs[i]
+		processed, err := applyOutputProcessor(ctx, processor, spec.Fields, output, env)
+		if err != nil {
+			log.Printf("Post-processor %s failed for run %s: %v", spec.Type, env.RunID, err)
+			outputPostProcessors.Inc(spec.Type, PostProcessFailed)
+			continue
+		}
+		outputPostProcessors.Inc(spec.Type, PostProcessApplied)
+		output = processed
+	}
+	return output
+}
+
+// applyOutputProcessor runs a processor on the whole output, or on each
+// listed field of an object output
+func applyOutputProcessor(ctx context.Context, processor OutputProcessor, fields []string, output interface{}, env *OutputEnv) (interface{}, error) {
+	if len(fields) == 0 {
+		return processor.Process(ctx, output, env)
+	}
+	obj, ok := output.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("fields are set but the output is not an object")
+	}
+	processed := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		processed[k] = v
+	}
+	for _, field := range fields {
+		value, ok := obj[field]
+		if !ok {
+			continue
+		}
+		result, err := processor.Process(ctx, value, env)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+		processed[field] = result
+	}
+	return processed, nil
+}
+
+// mapStrings applies fn to every string in a value, descending into objects
+// and lists
+func mapStrings(value interface{}, fn func(string) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case map[string]interface{}:
+		mapped := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result, err := mapStrings(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			mapped[k] = result
+		}
+		return mapped, nil
+	case []interface{}:
+		mapped := make([]interface{}, len(v))
+		for i, item := range v {
+			result, err := mapStrings(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			mapped[i] = result
+		}
+		return mapped, nil
+	}
+	return value, nil
+}
+
+// recordRunOutput stores the output of a run's final step as the run's
+// output, after the workflow's post-processors. Runs with several final
+// steps store each under its node ID.
+func (cp *ControlPlane) recordRunOutput(ctx context.Context, result *TaskResult) error {
+	var orgID uuid.UUID
+	var dagJSON, metadataJSON, overlayJSON []byte
+	query := `SELECT s.org_id, s.dag, s.metadata, r.dag_overlay
+			  FROM workflow_run r JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE r.id = $1`
+	err := cp.db.QueryRowContext(ctx, query, result.RunID).Scan(&orgID, &dagJSON, &metadataJSON, &overlayJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get workflow run: %w", err)
+	}
+
+	var dag DAG
+	if err := json.Unmarshal(dagJSON, &dag); err != nil {
+		return fmt.Errorf("failed to unmarshal DAG: %w", err)
+	}
+	var metadata Metadata
+	if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+		return fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+	if len(overlayJSON) > 0 {
+		var overlay DAGOverlay
+		if err := json.Unmarshal(overlayJSON, &overlay); err != nil {
+			return fmt.Errorf("failed to unmarshal DAG overlay: %w", err)
+		}
+		dag.Steps = append(dag.Steps, overlay.Steps...)
+		dag.Edges = append(dag.Edges, overlay.Edges...)
+	}
+
+	sinks := finalSteps(dag)
+	if !containsString(sinks, result.NodeID) {
+		return nil
+	}
+
+	var output interface{} = result.Output
+	if len(metadata.PostProcess) > 0 {
+		pipeline, err := BuildOutputPipeline(metadata.PostProcess)
+		if err != nil {
+			return err
+		}
+		var steps map[string]interface{}
+		env := &OutputEnv{RunID: result.RunID, StepOutput: func(ctx context.Context, nodeID string) (interface{}, error) {
+			if steps == nil {
+				loaded, err := cp.stepOutputs(ctx, orgID, result.RunID)
+				if err != nil {
+					return nil, err
+				}
+				steps = loaded
+			}
+			return steps[nodeID], nil
+		}}
+		output = pipeline.Run(ctx, output, env)
+	}
+
+	outputJSON, err := json.Marshal(output)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run output: %w", err)
+	}
+	path := []string{"output"}
+	if len(sinks) > 1 {
+		path = append(path, result.NodeID)
+	}
+	update := `UPDATE workflow_run
+			   SET metadata = jsonb_set(
+			       COALESCE(metadata, '{}'::jsonb) || jsonb_build_object('output', COALESCE(metadata->'output', '{}'::jsonb)),
+			       $2, $3)
+			   WHERE id = $1`
+	if _, err := cp.db.ExecContext(ctx, update, result.RunID, pq.Array(path), outputJSON); err != nil {
+		return fmt.Errorf("failed to record run output: %w", err)
+	}
+	return nil
+}
+
+// runOutput returns a run's recorded output. Runs without one, such as runs
+// that finished before outputs were recorded or whose recording failed, fall
+// back to their final steps' outputs, shaped as recordRunOutput stores them
+// but without the workflow's post-processors.
+func (cp *ControlPlane) runOutput(ctx context.Context, run *WorkflowRun) (interface{}, error) {
+	if output, ok := run.Metadata["output"]; ok {
+		return output, nil
+	}
+
+	var dagJSON, overlayJSON []byte
+	query := `SELECT s.dag, r.dag_overlay
+			  FROM workflow_run r JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE r.id = $1`
+	err := cp.db.QueryRowContext(ctx, query, run.ID).Scan(&dagJSON, &overlayJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow run: %w", err)
+	}
+	var dag DAG
+	if err := json.Unmarshal(dagJSON, &dag); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal DAG: %w", err)
+	}
+	if len(overlayJSON) > 0 {
+		var overlay DAGOverlay
+		if err := json.Unmarshal(overlayJSON, &overlay); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal DAG overlay: %w", err)
+		}
+		dag.Steps = append(dag.Steps, overlay.Steps...)
+		dag.Edges = append(dag.Edges, overlay.Edges...)
+	}
+
+	steps, err := cp.stepOutputs(ctx, run.OrgID, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	return sinkOutput(finalSteps(dag), steps), nil
+}
+
+// sinkOutput shapes final step outputs as recordRunOutput stores them: a
+// single final step's output as is, several keyed by node ID
+func sinkOutput(sinks []string, steps map[string]interface{}) interface{} {
+	if len(sinks) == 1 {
+		return steps[sinks[0]]
+	}
+	output := make(map[string]interface{}, len(sinks))
+	for _, sink := range sinks {
+		if value, ok := steps[sink]; ok && value != nil {
+			output[sink] = value
+		}
+	}
+	if len(output) == 0 {
+		return nil
+	}
+	return output
+}
+
+// finalSteps returns the steps no other step depends on
+func finalSteps(dag DAG) []string {
+	hasSuccessor := make(map[string]bool, len(dag.Edges))
+	for _, edge := range dag.Edges {
+		hasSuccessor[edge.From] = true
+	}
+	sinks := make([]string, 0)
+	for _, step := range dag.Steps {
+		if !hasSuccessor[step.ID] {
+			sinks = append(sinks, step.ID)
+		}
+	}
+	return sinks
+}
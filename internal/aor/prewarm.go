@@ -0,0 +1,388 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	nats "github.com/nats-io/nats.go"
+)
+
+const (
+	// prewarmSubject is broadcast to every worker, not queued: each worker warms itself
+	prewarmSubject = "agentflow.prewarm"
+	// maxPrewarmLookahead is how far ahead of a scheduled run warming may start
+	maxPrewarmLookahead = time.Hour
+	// prewarmGrace is how long after its scheduled time a warm-up waits to be claimed
+	prewarmGrace = 15 * time.Minute
+	// prewarmCallCents is the cost of the one-token call that opens a provider connection
+	prewarmCallCents = 1
+)
+
+type PrewarmAttribution string
+
+const (
+	PrewarmAttributeRun         PrewarmAttribution = "run"
+	PrewarmAttributeMaintenance PrewarmAttribution = "maintenance"
+)
+
+type PrewarmStatus string
+
+const (
+	PrewarmWarming PrewarmStatus = "warming"
+	PrewarmWarm    PrewarmStatus = "warm"
+	PrewarmClaimed PrewarmStatus = "claimed"
+	PrewarmExpired PrewarmStatus = "expired"
+)
+
+// PrewarmRequest asks workers to get ready for a scheduled run. Cost is charged
+// to the run that claims the warm-up or, with maintenance attribution, to the
+// maintenance budget. Warm-ups no run claims are charged to the maintenance
+// budget, or the org budget without one.
+type PrewarmRequest struct {
+	WorkflowName        string             `json:"workflow_name"`
+	ScheduledFor        time.Time          `json:"scheduled_for,omitempty"`
+	Cron                string             `json:"cron,omitempty"` // Warm the schedule's next run instead of ScheduledFor
+	Attribution         PrewarmAttribution `json:"attribution,omitempty"`
+	MaintenanceBudgetID *uuid.UUID         `json:"maintenance_budget_id,omitempty"`
+}
+
+// Validate checks the request and resolves a cron schedule to its next run
+func (r *PrewarmRequest) Validate(now time.Time) error {
+	if r.WorkflowName == "" {
+		return fmt.Errorf("workflow name is required")
+	}
+	if r.Cron != "" {
+		next, err := nextCronTime(r.Cron, now, maxPrewarmLookahead)
+		if err != nil {
+			return err
+		}
+		r.ScheduledFor = next
+	}
+	if r.ScheduledFor.IsZero() {
+		return fmt.Errorf("scheduled time or cron schedule is required")
+	}
+	if !r.ScheduledFor.After(now) {
+		return fmt.Errorf("scheduled time %s is in the past", r.ScheduledFor.Format(time.RFC3339))
+	}
+	if r.ScheduledFor.Sub(now) > maxPrewarmLookahead {
+		return fmt.Errorf("scheduled time is more than %s ahead", maxPrewarmLookahead)
+	}
+
+	switch r.Attribution {
+	case "":
+		r.Attribution = PrewarmAttributeRun
+	case PrewarmAttributeRun:
+	case PrewarmAttributeMaintenance:
+		if r.MaintenanceBudgetID == nil {
+			return fmt.Errorf("maintenance attribution requires a maintenance budget")
+		}
+	default:
+		return fmt.Errorf("unknown attribution %q: must be run or maintenance", r.Attribution)
+	}
+	return nil
+}
+
+// PrewarmTargets are what workers warm for a workflow
+type PrewarmTargets struct {
+	Models    []PrewarmModel `json:"models"`
+	StepTypes []string       `json:"step_types"`
+}
+
+type PrewarmModel struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// Prewarm is one warm-up ahead of a scheduled run
+type Prewarm struct {
+	ID                  uuid.UUID          `json:"id"`
+	OrgID               uuid.UUID          `json:"org_id"`
+	WorkflowName        string             `json:"workflow_name"`
+	WorkflowVersion     int                `json:"workflow_version"`
+	ScheduledFor        time.Time          `json:"scheduled_for"`
+	Status              PrewarmStatus      `json:"status"`
+	Attribution         PrewarmAttribution `json:"attribution"`
+	MaintenanceBudgetID *uuid.UUID         `json:"maintenance_budget_id,omitempty"`
+	Targets             PrewarmTargets     `json:"targets"`
+	CostCents           int64              `json:"cost_cents"`
+	RunID               *uuid.UUID         `json:"run_id,omitempty"` // Run that claimed the warm-up
+	WarmedAt            *time.Time         `json:"warmed_at,omitempty"`
+	ExpiresAt           time.Time          `json:"expires_at"`
+	CreatedAt           time.Time          `json:"created_at"`
+}
+
+// prewarmTask is the message workers receive on prewarmSubject
+type prewarmTask struct {
+	PrewarmID uuid.UUID      `json:"prewarm_id"`
+	Targets   PrewarmTargets `json:"targets"`
+}
+
+// prewarmTargets collects the models and step types a workflow's steps use
+func prewarmTargets(spec *WorkflowSpec) PrewarmTargets {
+	targets := PrewarmTargets{Models: make([]PrewarmModel, 0), StepTypes: make([]string, 0)}
+	seenModels := make(map[PrewarmModel]bool)
+	seenTypes := make(map[string]bool)
+	for _, step := range spec.DAG.Steps {
+		if !seenTypes[step.Type] {
+			seenTypes[step.Type] = true
+			targets.StepTypes = append(targets.StepTypes, step.Type)
+		}
+		for _, target := range stepModelTargets(step) {
+			model := PrewarmModel{Provider: target[0], Model: target[1]}
+			if !seenModels[model] {
+				seenModels[model] = true
+				targets.Models = append(targets.Models, model)
+			}
+		}
+	}
+	sort.Strings(targets.StepTypes)
+	return targets
+}
+
+// PrewarmWorkflow asks every worker to start the workflow's plugins and open
+// its provider connections ahead of a scheduled run
+func (cp *ControlPlane) PrewarmWorkflow(ctx context.Context, req *PrewarmRequest) (*Prewarm, error) {
+	now := time.Now()
+	if err := req.Validate(now); err != nil {
+		return nil, err
+	}
+
+	version, _, err := cp.routeWorkflowVersion(ctx, req.WorkflowName)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := cp.getWorkflowSpec(ctx, req.WorkflowName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	prewarm := &Prewarm{
+		ID:                  uuid.New(),
+		OrgID:               spec.OrgID,
+		WorkflowName:        spec.Name,
+		WorkflowVersion:     spec.Version,
+		ScheduledFor:        req.ScheduledFor,
+		Status:              PrewarmWarming,
+		Attribution:         req.Attribution,
+		MaintenanceBudgetID: req.MaintenanceBudgetID,
+		Targets:             prewarmTargets(spec),
+		ExpiresAt:           req.ScheduledFor.Add(prewarmGrace),
+		CreatedAt:           now,
+	}
+	targetsJSON, err := json.Marshal(prewarm.Targets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal prewarm targets: %w", err)
+	}
+
+	query := `INSERT INTO workflow_prewarm (id, org_id, workflow_name, workflow_version, scheduled_for, status,
+			  attribution, maintenance_budget_id, targets, expires_at, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err = cp.db.ExecContext(ctx, query, prewarm.ID, prewarm.OrgID, prewarm.WorkflowName, prewarm.WorkflowVersion,
+		prewarm.ScheduledFor, prewarm.Status, prewarm.Attribution, prewarm.MaintenanceBudgetID, targetsJSON,
+		prewarm.ExpiresAt, prewarm.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save prewarm: %w", err)
+	}
+
+	data, err := json.Marshal(prewarmTask{PrewarmID: prewarm.ID, Targets: prewarm.Targets})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal prewarm task: %w", err)
+	}
+	if err := cp.nats.Publish(prewarmSubject, data); err != nil {
+		return nil, fmt.Errorf("failed to publish prewarm task: %w", err)
+	}
+
+	log.Printf("Pre-warming %s v%d for %s (%d models, %d step types)", prewarm.WorkflowName, prewarm.WorkflowVersion,
+		prewarm.ScheduledFor.Format(time.RFC3339), len(prewarm.Targets.Models), len(prewarm.Targets.StepTypes))
+	return prewarm, nil
+}
+
+// ListPrewarms returns a workflow's warm-ups, newest first; an empty name lists all
+func (cp *ControlPlane) ListPrewarms(ctx context.Context, workflowName string) ([]Prewarm, error) {
+	query := `SELECT id, org_id, workflow_name, workflow_version, scheduled_for, status, attribution,
+			  maintenance_budget_id, targets, cost_cents, run_id, warmed_at, expires_at, created_at
+			  FROM workflow_prewarm WHERE ($1 = '' OR workflow_name = $1)
+			  ORDER BY created_at DESC LIMIT 100`
+
+	rows, err := cp.db.QueryContext(ctx, query, workflowName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prewarms: %w", err)
+	}
+	defer rows.Close()
+
+	prewarms := make([]Prewarm, 0)
+	for rows.Next() {
+		var prewarm Prewarm
+		var targetsJSON []byte
+		if err := rows.Scan(&prewarm.ID, &prewarm.OrgID, &prewarm.WorkflowName, &prewarm.WorkflowVersion,
+			&prewarm.ScheduledFor, &prewarm.Status, &prewarm.Attribution, &prewarm.MaintenanceBudgetID, &targetsJSON,
+			&prewarm.CostCents, &prewarm.RunID, &prewarm.WarmedAt, &prewarm.ExpiresAt, &prewarm.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan prewarm: %w", err)
+		}
+		if err := json.Unmarshal(targetsJSON, &prewarm.Targets); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal prewarm targets: %w", err)
+		}
+		prewarms = append(prewarms, prewarm)
+	}
+	return prewarms, rows.Err()
+}
+
+// claimPrewarm hands the workflow's oldest open warm-up to a new run and
+// charges its cost to the run or the maintenance budget
+func (cp *ControlPlane) claimPrewarm(ctx context.Context, run *WorkflowRun) error {
+	query := `UPDATE workflow_prewarm SET status = 'claimed', run_id = $3
+			  WHERE id = (SELECT id FROM workflow_prewarm
+			              WHERE org_id = $1 AND workflow_name = $2 AND status IN ('warming', 'warm') AND expires_at > NOW()
+			              ORDER BY scheduled_for LIMIT 1 FOR UPDATE SKIP LOCKED)
+			  RETURNING id, attribution, maintenance_budget_id, cost_cents`
+
+	var prewarmID uuid.UUID
+	var attribution PrewarmAttribution
+	var budgetID *uuid.UUID
+	var costCents int64
+	err := cp.db.QueryRowContext(ctx, query, run.OrgID, run.WorkflowName, run.ID).Scan(&prewarmID, &attribution, &budgetID, &costCents)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim prewarm: %w", err)
+	}
+	log.Printf("Run %s claimed pre-warm %s", run.ID, prewarmID)
+	if costCents == 0 {
+		return nil
+	}
+
+	if attribution == PrewarmAttributeMaintenance {
+		return cp.chargeMaintenance(ctx, run.OrgID, budgetID, costCents)
+	}
+	if _, err := cp.db.ExecContext(ctx, `UPDATE workflow_run SET cost_cents = cost_cents + $2 WHERE id = $1`, run.ID, costCents); err != nil {
+		return fmt.Errorf("failed to charge prewarm to run: %w", err)
+	}
+	run.CostCents += costCents
+	return cp.enforceRunBudget(ctx, run.ID, costCents)
+}
+
+// chargeMaintenance records warm-up cost against the maintenance budget, or
+// the org budget without one
+func (cp *ControlPlane) chargeMaintenance(ctx context.Context, orgID uuid.UUID, budgetID *uuid.UUID, costCents int64) error {
+	if budgetID != nil {
+		return cp.budgets.RecordBudgetSpending(ctx, *budgetID, costCents)
+	}
+	return cp.budgets.RecordSpending(ctx, orgID, costCents)
+}
+
+// expirePrewarms closes warm-ups no run claimed and charges their cost
+func (cp *ControlPlane) expirePrewarms(ctx context.Context) error {
+	query := `UPDATE workflow_prewarm SET status = 'expired'
+			  WHERE status IN ('warming', 'warm') AND expires_at <= NOW()
+			  RETURNING id, org_id, maintenance_budget_id, cost_cents`
+
+	rows, err := cp.db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to expire prewarms: %w", err)
+	}
+	type expired struct {
+		id, orgID uuid.UUID
+		budgetID  *uuid.UUID
+		costCents int64
+	}
+	unclaimed := make([]expired, 0)
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.id, &e.orgID, &e.budgetID, &e.costCents); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan expired prewarm: %w", err)
+		}
+		unclaimed = append(unclaimed, e)
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to read expired prewarms: %w", err)
+	}
+
+	for _, e := range unclaimed {
+		log.Printf("Pre-warm %s expired unclaimed", e.id)
+		if e.costCents == 0 {
+			continue
+		}
+		if err := cp.chargeMaintenance(ctx, e.orgID, e.budgetID, e.costCents); err != nil {
+			log.Printf("Failed to charge expired pre-warm %s: %v", e.id, err)
+		}
+	}
+	return nil
+}
+
+// runPrewarmExpiry expires unclaimed warm-ups every minute until shutdown
+func (cp *ControlPlane) runPrewarmExpiry(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cp.shutdown:
+			return
+		case <-ticker.C:
+			if err := cp.expirePrewarms(ctx); err != nil {
+				log.Printf("Failed to expire pre-warms: %v", err)
+			}
+		}
+	}
+}
+
+// handlePrewarm warms this worker for a scheduled run and reports the cost
+func (w *Worker) handlePrewarm(msg *nats.Msg) {
+	var task prewarmTask
+	if err := json.Unmarshal(msg.Data, &task); err != nil {
+		log.Printf("Failed to unmarshal prewarm task: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	costCents := w.warmTargets(ctx, task.Targets)
+	query := `UPDATE workflow_prewarm SET status = 'warm', cost_cents = cost_cents + $2, warmed_at = COALESCE(warmed_at, NOW())
+			  WHERE id = $1 AND status IN ('warming', 'warm')`
+	if _, err := w.db.ExecContext(ctx, query, task.PrewarmID, costCents); err != nil {
+		log.Printf("Failed to record pre-warm %s: %v", task.PrewarmID, err)
+	}
+}
+
+// warmTargets starts the plugins serving the step types and opens a
+// connection to each provider, returning the cost of the warm-up calls
+func (w *Worker) warmTargets(ctx context.Context, targets PrewarmTargets) int64 {
+	stepTypes := make(map[string]bool, len(targets.StepTypes))
+	for _, stepType := range targets.StepTypes {
+		stepTypes[stepType] = true
+	}
+	for _, plugin := range w.plugins {
+		for _, stepType := range plugin.Manifest.StepTypes {
+			if !stepTypes[stepType] {
+				continue
+			}
+			// Describing restarts a plugin that has exited since it was loaded
+			var manifest PluginManifest
+			if err := plugin.Call(ctx, "describe", nil, &manifest); err != nil {
+				log.Printf("Failed to warm plugin %s: %v", plugin.Name(), err)
+			}
+			break
+		}
+	}
+
+	var costCents int64
+	for _, target := range targets.Models {
+		if target.Provider == MockProviderName {
+			continue
+		}
+		// Mock warm-up - in production would send a one-token completion over a pooled connection
+		costCents += prewarmCallCents
+	}
+	return costCents
+}
@@ -0,0 +1,314 @@
+package aor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+)
+
+const (
+	// minCacheablePrefixTokens is the shortest prefix providers will cache
+	minCacheablePrefixTokens = 1024
+	defaultPromptCacheTTL    = "5m"
+)
+
+// PromptCachePolicy marks the stable prefix of a step's prompt, its system
+// prompt, as cacheable by the provider. It is configured as prompt_cache: true
+// or prompt_cache: {ttl: 1h}. Anthropic only caches marked blocks; OpenAI and
+// Gemini cache long prefixes on their own, so marking adds a cache routing key.
+type PromptCachePolicy struct {
+	TTL string `json:"ttl"` // 5m or 1h
+}
+
+// parsePromptCache reads a step's prompt_cache config, returning nil when caching is off
+func parsePromptCache(config map[string]interface{}) (*PromptCachePolicy, error) {
+	switch raw := config["prompt_cache"].(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if !raw {
+			return nil, nil
+		}
+		return &PromptCachePolicy{TTL: defaultPromptCacheTTL}, nil
+	case map[string]interface{}:
+		if enabled, ok := raw["enabled"].(bool); ok && !enabled {
+			return nil, nil
+		}
+		policy := &PromptCachePolicy{TTL: defaultPromptCacheTTL}
+		if ttl, ok := raw["ttl"].(string); ok && ttl != "" {
+			policy.TTL = ttl
+		}
+		if policy.TTL != "5m" && policy.TTL != "1h" {
+			return nil, fmt.Errorf("invalid prompt_cache.ttl %q: use 5m or 1h", policy.TTL)
+		}
+		return policy, nil
+	default:
+		return nil, fmt.Errorf("prompt_cache must be true, false or {ttl: 5m|1h}")
+	}
+}
+
+// renderedPrompt is the prompt of one LLM call, split into the prefix that is
+// the same on every call of the step and the part that varies with inputs
+type renderedPrompt struct {
+	Prefix      string
+	Suffix      string
+	Cache       *PromptCachePolicy // Nil unless the prefix is long enough to cache
+	Reasoning   *ReasoningConfig   // Applied only for models that reason
+	MaxTokens   int                // Answer limit, excluding any reasoning budget
+	Media       []MediaPart        // Images and files sent with the inputs
+	Temperature *float64           // Nil leaves the provider default
+	Seed        *int64             // Sent only to providers that seed sampling
+}
+
+type promptContextKey struct{}
+
+func withRenderedPrompt(ctx context.Context, prompt *renderedPrompt) context.Context {
+	return context.WithValue(ctx, promptContextKey{}, prompt)
+}
+
+func renderedPromptFrom(ctx context.Context) *renderedPrompt {
+	prompt, _ := ctx.Value(promptContextKey{}).(*renderedPrompt)
+	if prompt == nil {
+		return &renderedPrompt{}
+	}
+	return prompt
+}
+
+// renderPrompt splits a task's prompt into the step's system prompt and its inputs
+func renderPrompt(task *Task) (*renderedPrompt, error) {
+	prompt := &renderedPrompt{}
+	inputs, media, err := extractMedia(task.Inputs)
+	if err != nil {
+		return nil, &MediaError{StepID: task.StepID, Part: "inputs", Reason: err.Error()}
+	}
+	prompt.Media = media
+	if len(inputs) > 0 {
+		data, err := json.Marshal(inputs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render prompt inputs: %w", err)
+		}
+		prompt.Suffix = string(data)
+	}
+	if task.Node == nil {
+		return prompt, nil
+	}
+
+	prompt.Prefix, _ = task.Node.Config["system"].(string)
+	if v, ok := task.Node.Config["max_tokens"].(float64); ok && v > 0 {
+		prompt.MaxTokens = int(v)
+	}
+	reasoning, err := parseReasoningConfig(task.Node.Config)
+	if err != nil {
+		return nil, err
+	}
+	prompt.Reasoning = reasoning
+	prompt.Temperature, prompt.Seed = stepSamplingParams(task.Node.Config)
+	cache, err := parsePromptCache(task.Node.Config)
+	if err != nil {
+		return nil, err
+	}
+	// Providers ignore cache markers on short prefixes
+	if cache != nil && prompt.prefixTokens() >= minCacheablePrefixTokens {
+		prompt.Cache = cache
+	}
+	return prompt, nil
+}
+
+func (p *renderedPrompt) prefixTokens() int {
+	return scl.NewCompressor().EstimateTokens(p.Prefix)
+}
+
+func (p *renderedPrompt) mediaTokens() int {
+	tokens := 0
+	for _, part := range p.Media {
+		tokens += part.tokens()
+	}
+	return tokens
+}
+
+// cacheKey identifies the prompt's prefix in a provider's cache
+func (p *renderedPrompt) cacheKey() string {
+	sum := sha256.Sum256([]byte(p.Prefix))
+	return hex.EncodeToString(sum[:16])
+}
+
+// requestBody renders a provider request body, marking the prefix cacheable
+// and translating reasoning parameters for the target model
+func (p *renderedPrompt) requestBody(provider, model string) map[string]interface{} {
+	body := p.messagesBody(provider, model)
+	if p.MaxTokens > 0 {
+		body["max_tokens"] = p.MaxTokens
+	}
+	p.applySampling(provider, body)
+	if p.Reasoning != nil && supportsReasoning(provider, model) {
+		maxTokens := p.MaxTokens
+		if maxTokens == 0 {
+			maxTokens = defaultMaxTokens
+		}
+		applyReasoning(provider, body, p.Reasoning, maxTokens)
+	}
+	return body
+}
+
+// applySampling sets the pinned temperature and seed in each provider's shape
+func (p *renderedPrompt) applySampling(provider string, body map[string]interface{}) {
+	switch strings.ToLower(provider) {
+	case "google", "gemini":
+		generation := make(map[string]interface{})
+		if p.Temperature != nil {
+			generation["temperature"] = *p.Temperature
+		}
+		if p.Seed != nil {
+			generation["seed"] = *p.Seed
+		}
+		if len(generation) > 0 {
+			body["generationConfig"] = generation
+		}
+	case "anthropic":
+		if p.Temperature != nil {
+			body["temperature"] = *p.Temperature
+		}
+	default:
+		if p.Temperature != nil {
+			body["temperature"] = *p.Temperature
+		}
+		if p.Seed != nil {
+			body["seed"] = *p.Seed
+		}
+	}
+}
+
+func (p *renderedPrompt) messagesBody(provider, model string) map[string]interface{} {
+	text := p.Suffix
+	if text == "" {
+		text = "Mock prompt"
+	}
+	user := map[string]interface{}{"role": "user", "content": text}
+	if len(p.Media) > 0 {
+		user["content"] = mediaContent(provider, text, p.Media)
+	}
+
+	switch strings.ToLower(provider) {
+	case "anthropic":
+		body := map[string]interface{}{"model": model, "messages": []interface{}{user}}
+		if p.Prefix != "" {
+			system := map[string]interface{}{"type": "text", "text": p.Prefix}
+			if p.Cache != nil {
+				system["cache_control"] = map[string]interface{}{"type": "ephemeral", "ttl": p.Cache.TTL}
+			}
+			body["system"] = []interface{}{system}
+		}
+		return body
+	default:
+		messages := []interface{}{user}
+		if p.Prefix != "" {
+			messages = []interface{}{map[string]interface{}{"role": "system", "content": p.Prefix}, user}
+		}
+		body := map[string]interface{}{"model": model, "messages": messages}
+		if p.Cache != nil && strings.EqualFold(provider, "openai") {
+			// Routes calls sharing the prefix to the same cache
+			body["prompt_cache_key"] = p.cacheKey()
+		}
+		return body
+	}
+}
+
+// promptCacheUsage reads cached prompt token counts from a provider response
+func promptCacheUsage(provider string, raw map[string]interface{}) cas.PromptCacheUsage {
+	var usage cas.PromptCacheUsage
+	switch strings.ToLower(provider) {
+	case "anthropic":
+		fields, _ := raw["usage"].(map[string]interface{})
+		usage.ReadTokens = intField(fields, "cache_read_input_tokens")
+		usage.WriteTokens = intField(fields, "cache_creation_input_tokens")
+	case "google", "gemini":
+		fields, _ := raw["usageMetadata"].(map[string]interface{})
+		usage.ReadTokens = intField(fields, "cachedContentTokenCount")
+	default:
+		fields, _ := raw["usage"].(map[string]interface{})
+		details, _ := fields["prompt_tokens_details"].(map[string]interface{})
+		usage.ReadTokens = intField(details, "cached_tokens")
+	}
+	return usage
+}
+
+func intField(fields map[string]interface{}, key string) int {
+	switch v := fields[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+// mockPromptCacheUsage simulates a provider's prompt cache: the first call
+// with a cacheable prefix writes it, and calls within the TTL read it
+func (w *Worker) mockPromptCacheUsage(provider string, prompt *renderedPrompt) cas.PromptCacheUsage {
+	if _, ok := cas.ProviderPromptCachePricing(provider); !ok || prompt.Prefix == "" {
+		return cas.PromptCacheUsage{}
+	}
+	// OpenAI and Gemini cache long prefixes without markers
+	automatic := !strings.EqualFold(provider, "anthropic") && prompt.prefixTokens() >= minCacheablePrefixTokens
+	if prompt.Cache == nil && !automatic {
+		return cas.PromptCacheUsage{}
+	}
+
+	ttl := 5 * time.Minute
+	if prompt.Cache != nil && prompt.Cache.TTL == "1h" {
+		ttl = time.Hour
+	}
+	key := strings.ToLower(provider) + ":" + prompt.cacheKey()
+	now := time.Now()
+	expires, warm := w.warmPrefixes.Load(key)
+	w.warmPrefixes.Store(key, now.Add(ttl))
+	if warm && now.Before(expires.(time.Time)) {
+		return cas.PromptCacheUsage{ReadTokens: prompt.prefixTokens()}
+	}
+	if strings.EqualFold(provider, "anthropic") {
+		return cas.PromptCacheUsage{WriteTokens: prompt.prefixTokens()}
+	}
+	return cas.PromptCacheUsage{} // Automatic caches don't bill writes
+}
+
+// mockProviderUsage adds a provider-shaped usage block to a mock response
+func mockProviderUsage(provider string, raw map[string]interface{}, promptTokens, completionTokens, reasoning int, usage cas.PromptCacheUsage) {
+	switch strings.ToLower(provider) {
+	case "anthropic":
+		if reasoning > 0 {
+			// Claude returns summarized thinking but bills every thinking token
+			blocks, _ := raw["content"].([]interface{})
+			thinking := map[string]interface{}{"type": "thinking", "thinking": "Mock thinking summary", "signature": "mock"}
+			raw["content"] = append([]interface{}{thinking}, blocks...)
+		}
+		raw["usage"] = map[string]interface{}{
+			"input_tokens":                float64(promptTokens - usage.ReadTokens - usage.WriteTokens),
+			"output_tokens":               float64(completionTokens),
+			"cache_read_input_tokens":     float64(usage.ReadTokens),
+			"cache_creation_input_tokens": float64(usage.WriteTokens),
+		}
+	case "google", "gemini":
+		raw["usageMetadata"] = map[string]interface{}{
+			"promptTokenCount":        float64(promptTokens),
+			"candidatesTokenCount":    float64(completionTokens),
+			"cachedContentTokenCount": float64(usage.ReadTokens),
+			"thoughtsTokenCount":      float64(reasoning),
+		}
+	case "cohere":
+	default:
+		raw["usage"] = map[string]interface{}{
+			"prompt_tokens":             float64(promptTokens),
+			"completion_tokens":         float64(completionTokens),
+			"prompt_tokens_details":     map[string]interface{}{"cached_tokens": float64(usage.ReadTokens)},
+			"completion_tokens_details": map[string]interface{}{"reasoning_tokens": float64(reasoning)},
+		}
+	}
+}
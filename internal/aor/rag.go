@@ -0,0 +1,456 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/pop"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+)
+
+const (
+	defaultChunkSize    = 800
+	defaultChunkOverlap = 100
+	defaultRetrieveTopK = 5
+	defaultRAGPrompt    = "rag-answer"
+	// embedBatchSize keeps embedding requests under provider input limits
+	embedBatchSize = 100
+	// localEmbeddingModel is used when no embeddings provider is configured
+	localEmbeddingModel = "local-hash-256"
+	localEmbeddingDims  = 256
+)
+
+// RAGDocument is a source document entering a RAG pipeline
+type RAGDocument struct {
+	ID       string                 `json:"id"`
+	Text     string                 `json:"text"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// RAGCitation points an answer back at a retrieved chunk
+type RAGCitation struct {
+	Source     int     `json:"source"`
+	DocumentID string  `json:"document_id"`
+	ChunkIndex int     `json:"chunk_index"`
+	Score      float64 `json:"score"`
+}
+
+// RAGExecutor runs the built-in RAG stages. Each stage is its own step, so it
+// is traced, retried and costed like any other; a pipeline is declared as
+//
+//	steps:
+//	  - {id: ingest, type: rag_ingest}
+//	  - {id: chunk, type: rag_chunk}
+//	  - {id: embed, type: rag_embed, config: {collection: handbook}}
+//	  - {id: retrieve, type: rag_retrieve, config: {collection: handbook, top_k: 4}}
+//	  - {id: answer, type: rag_generate, config: {model: gpt-4o-mini}}
+//
+// Ingest runs documents through SCL sanitization and PII scrubbing, embed
+// stores chunks in the org's vector store and generate answers through the
+// LLM executor, so model policy, token budgets and replay all apply.
+type RAGExecutor struct {
+	worker    *Worker
+	llm       *LLMExecutor
+	store     *VectorStore
+	embedder  pop.EmbeddingClient
+	model     string
+	sanitizer *scl.Sanitizer
+	redactor  *scl.Redactor
+}
+
+func NewRAGExecutor(worker *Worker, llm *LLMExecutor) *RAGExecutor {
+	e := &RAGExecutor{
+		worker:    worker,
+		llm:       llm,
+		store:     NewVectorStore(worker.db),
+		model:     localEmbeddingModel,
+		sanitizer: scl.NewSanitizer(),
+		redactor:  scl.NewRedactor(),
+	}
+	if worker.cfg.Embeddings.APIKey != "" {
+		e.embedder = pop.NewHTTPEmbeddingClient(worker.cfg.Embeddings)
+		e.model = worker.cfg.Embeddings.Model
+	}
+	return e
+}
+
+func (e *RAGExecutor) Execute(ctx context.Context, task *Task) (*TaskResult, error) {
+	start := time.Now()
+	if err := validateRAGConfig(task.Node.Type, task.Node.Config); err != nil {
+		return nil, err
+	}
+
+	var output map[string]interface{}
+	var costCents int64
+	var err error
+	switch ExecutorType(task.Node.Type) {
+	case ExecutorTypeRAGIngest:
+		output, err = e.ingest(task)
+	case ExecutorTypeRAGChunk:
+		output, err = e.chunk(task)
+	case ExecutorTypeRAGEmbed:
+		output, costCents, err = e.embedChunks(ctx, task)
+	case ExecutorTypeRAGRetrieve:
+		output, costCents, err = e.retrieve(ctx, task)
+	case ExecutorTypeRAGGenerate:
+		return e.generate(ctx, task)
+	default:
+		return nil, fmt.Errorf("unknown RAG stage %s", task.Node.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &TaskResult{
+		TaskID:     task.ID,
+		Status:     TaskStatusSucceeded,
+		Output:     output,
+		CostCents:  costCents,
+		ExecutedAt: time.Now(),
+		Duration:   time.Since(start),
+	}, nil
+}
+
+func (e *RAGExecutor) CanHandle(stepType string) bool {
+	switch ExecutorType(stepType) {
+	case ExecutorTypeRAGIngest, ExecutorTypeRAGChunk, ExecutorTypeRAGEmbed, ExecutorTypeRAGRetrieve, ExecutorTypeRAGGenerate:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateRAGConfig checks a RAG stage's config
+func validateRAGConfig(stepType string, config map[string]interface{}) error {
+	switch ExecutorType(stepType) {
+	case ExecutorTypeRAGIngest:
+		if level, ok := config["scrub"].(string); ok {
+			if _, err := scl.ParseScrubLevel(level); err != nil {
+				return err
+			}
+		}
+	case ExecutorTypeRAGChunk:
+		size, overlap := chunkSettings(config)
+		if size <= 0 {
+			return fmt.Errorf("chunk_size must be positive")
+		}
+		if overlap < 0 || overlap >= size {
+			return fmt.Errorf("overlap must be at least 0 and less than chunk_size %d", size)
+		}
+	case ExecutorTypeRAGRetrieve:
+		if collection, _ := config["collection"].(string); collection == "" {
+			return fmt.Errorf("rag_retrieve requires a collection")
+		}
+		if v, ok := config["top_k"].(float64); ok && v < 1 {
+			return fmt.Errorf("top_k must be at least 1")
+		}
+	}
+	return nil
+}
+
+// ingest cleans documents with SCL before they are chunked and indexed
+func (e *RAGExecutor) ingest(task *Task) (map[string]interface{}, error) {
+	level := scl.ScrubLevelStandard
+	if v, ok := task.Node.Config["scrub"].(string); ok {
+		level, _ = scl.ParseScrubLevel(v)
+	}
+
+	var documents []RAGDocument
+	if raw, ok := task.Inputs["documents"]; ok {
+		if err := decodeRAGInput(raw, &documents); err != nil {
+			return nil, fmt.Errorf("invalid documents input: %w", err)
+		}
+	} else if text, ok := task.Inputs["text"].(string); ok {
+		id, _ := task.Inputs["document_id"].(string)
+		documents = []RAGDocument{{ID: id, Text: text}}
+	} else {
+		return nil, fmt.Errorf("rag_ingest needs a documents or text input")
+	}
+
+	cleaned := make([]RAGDocument, 0, len(documents))
+	warnings := make([]string, 0)
+	redactions := make(map[string]int)
+	for _, doc := range documents {
+		sanitized, warns, err := e.sanitizer.Sanitize(doc.Text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sanitize document %s: %w", doc.ID, err)
+		}
+		warnings = append(warnings, warns...)
+		scrubbed, counts := e.redactor.Scrub(sanitized, level)
+		for piiType, n := range counts {
+			redactions[piiType] += n
+		}
+
+		doc.Text, _ = scrubbed.(string)
+		if strings.TrimSpace(doc.Text) == "" {
+			continue
+		}
+		if doc.ID == "" {
+			doc.ID = db.HashContent([]byte(doc.Text))
+		}
+		cleaned = append(cleaned, doc)
+	}
+
+	return map[string]interface{}{
+		"documents":  cleaned,
+		"warnings":   warnings,
+		"redactions": redactions,
+	}, nil
+}
+
+// chunk splits documents into overlapping chunks at word boundaries
+func (e *RAGExecutor) chunk(task *Task) (map[string]interface{}, error) {
+	var documents []RAGDocument
+	if err := decodeRAGInput(task.Inputs["documents"], &documents); err != nil {
+		return nil, fmt.Errorf("invalid documents input: %w", err)
+	}
+
+	size, overlap := chunkSettings(task.Node.Config)
+	chunks := make([]RAGChunk, 0)
+	for _, doc := range documents {
+		for i, text := range chunkText(doc.Text, size, overlap) {
+			chunks = append(chunks, RAGChunk{DocumentID: doc.ID, Index: i, Text: text, Metadata: doc.Metadata})
+		}
+	}
+	return map[string]interface{}{"chunks": chunks}, nil
+}
+
+// embedChunks embeds chunks and, when the step names a collection, indexes them
+func (e *RAGExecutor) embedChunks(ctx context.Context, task *Task) (map[string]interface{}, int64, error) {
+	var chunks []RAGChunk
+	if err := decodeRAGInput(task.Inputs["chunks"], &chunks); err != nil {
+		return nil, 0, fmt.Errorf("invalid chunks input: %w", err)
+	}
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	model, vectors, costCents, err := e.embed(ctx, task.Node.Config, texts)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range chunks {
+		chunks[i].Embedding = vectors[i]
+	}
+
+	output := map[string]interface{}{"model": model, "count": len(chunks)}
+	collection, _ := task.Node.Config["collection"].(string)
+	if collection == "" {
+		output["chunks"] = chunks
+		return output, costCents, nil
+	}
+
+	if err := e.store.Upsert(ctx, task.OrgID, collection, model, chunks); err != nil {
+		return nil, 0, err
+	}
+	output["collection"] = collection
+	return output, costCents, nil
+}
+
+// retrieve finds the chunks of a collection closest to the query
+func (e *RAGExecutor) retrieve(ctx context.Context, task *Task) (map[string]interface{}, int64, error) {
+	query, _ := task.Inputs["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return nil, 0, fmt.Errorf("rag_retrieve needs a query input")
+	}
+
+	model, vectors, costCents, err := e.embed(ctx, task.Node.Config, []string{query})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	collection, _ := task.Node.Config["collection"].(string)
+	topK := defaultRetrieveTopK
+	if v, ok := task.Node.Config["top_k"].(float64); ok {
+		topK = int(v)
+	}
+	minScore, _ := task.Node.Config["min_score"].(float64)
+
+	chunks, err := e.store.Search(ctx, task.OrgID, collection, model, vectors[0], topK, minScore)
+	if err != nil {
+		return nil, 0, err
+	}
+	return map[string]interface{}{"query": query, "collection": collection, "chunks": chunks}, costCents, nil
+}
+
+// generate answers the query from retrieved chunks with numbered sources
+func (e *RAGExecutor) generate(ctx context.Context, task *Task) (*TaskResult, error) {
+	query, _ := task.Inputs["query"].(string)
+	var chunks []RAGChunk
+	if raw, ok := task.Inputs["chunks"]; ok {
+		if err := decodeRAGInput(raw, &chunks); err != nil {
+			return nil, fmt.Errorf("invalid chunks input: %w", err)
+		}
+	}
+
+	var sources strings.Builder
+	citations := make([]RAGCitation, 0, len(chunks))
+	for i, chunk := range chunks {
+		fmt.Fprintf(&sources, "[%d] %s\n\n", i+1, chunk.Text)
+		citations = append(citations, RAGCitation{Source: i + 1, DocumentID: chunk.DocumentID, ChunkIndex: chunk.Index, Score: chunk.Score})
+	}
+
+	config := make(map[string]interface{}, len(task.Node.Config)+1)
+	for k, v := range task.Node.Config {
+		config[k] = v
+	}
+	if ref, _ := config["prompt_ref"].(string); ref == "" {
+		config["prompt_ref"] = defaultRAGPrompt
+	}
+
+	sub := *task
+	sub.Node = &Node{ID: task.Node.ID, Type: string(ExecutorTypeLLM), Config: config}
+	sub.Inputs = map[string]interface{}{
+		"query":   query,
+		"context": strings.TrimSpace(sources.String()),
+		"instructions": "Answer the query using only the numbered sources in the context. " +
+			"Cite sources as [n]. Say so if the sources do not contain the answer.",
+	}
+
+	start := time.Now()
+	result, err := e.llm.Execute(ctx, &sub)
+	if (result == nil || !result.Replayed) && task.Chaos == nil {
+		e.worker.reportTelemetry(ctx, &sub, result, err, time.Since(start))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(map[string]interface{}, len(result.Output)+1)
+	for k, v := range result.Output {
+		output[k] = v
+	}
+	output["citations"] = citations
+	result.Output = output
+	return result, nil
+}
+
+// embed computes vectors for texts with the configured embeddings provider,
+// falling back to local feature hashing when none is configured
+func (e *RAGExecutor) embed(ctx context.Context, config map[string]interface{}, texts []string) (string, [][]float64, int64, error) {
+	if e.embedder == nil {
+		vectors := make([][]float64, len(texts))
+		for i, text := range texts {
+			vectors[i] = hashEmbedding(text)
+		}
+		return localEmbeddingModel, vectors, 0, nil
+	}
+
+	model := e.model
+	if m, ok := config["embedding_model"].(string); ok && m != "" {
+		model = m
+	}
+
+	vectors := make([][]float64, 0, len(texts))
+	tokens := 0
+	for start := 0; start < len(texts); start += embedBatchSize {
+		end := start + embedBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		result, err := e.embedder.Embed(ctx, model, texts[start:end])
+		if err != nil {
+			return "", nil, 0, fmt.Errorf("failed to embed: %w", err)
+		}
+		if len(result.Vectors) != end-start {
+			return "", nil, 0, fmt.Errorf("embeddings provider returned %d vectors for %d texts", len(result.Vectors), end-start)
+		}
+		vectors = append(vectors, result.Vectors...)
+		tokens += result.Tokens
+	}
+
+	return model, vectors, int64(math.Ceil(pop.EmbeddingCostCents(model, tokens))), nil
+}
+
+// chunkSettings reads a chunk step's size and overlap in characters
+func chunkSettings(config map[string]interface{}) (int, int) {
+	size, overlap := defaultChunkSize, defaultChunkOverlap
+	if v, ok := config["chunk_size"].(float64); ok {
+		size = int(v)
+	}
+	if v, ok := config["overlap"].(float64); ok {
+		overlap = int(v)
+	}
+	return size, overlap
+}
+
+// chunkText splits text into chunks of at most size characters, breaking at
+// the last space in the back half of each window and starting each chunk
+// overlap characters before the previous one ended
+func chunkText(text string, size, overlap int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	chunks := make([]string, 0, len(runes)/size+1)
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			chunks = append(chunks, strings.TrimSpace(string(runes[start:])))
+			break
+		}
+		for i := end; i > start+size/2; i-- {
+			if unicode.IsSpace(runes[i]) {
+				end = i
+				break
+			}
+		}
+		chunks = append(chunks, strings.TrimSpace(string(runes[start:end])))
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		// Start the overlap on a word boundary
+		for i := next; i < end; i++ {
+			if unicode.IsSpace(runes[i]) {
+				next = i + 1
+				break
+			}
+		}
+		start = next
+	}
+	return chunks
+}
+
+// hashEmbedding maps text to a normalized bag-of-words vector by feature
+// hashing, good enough for lexical retrieval in development
+func hashEmbedding(text string) []float64 {
+	vector := make([]float64, localEmbeddingDims)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(word))
+		vector[h.Sum32()%localEmbeddingDims]++
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+	return vector
+}
+
+// decodeRAGInput converts a JSON-decoded input into a typed value
+func decodeRAGInput(raw interface{}, into interface{}) error {
+	if raw == nil {
+		return fmt.Errorf("missing")
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, into)
+}
@@ -0,0 +1,171 @@
+package aor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReasoningEffort is the provider-independent amount of thinking a reasoning model does
+type ReasoningEffort string
+
+const (
+	ReasoningEffortLow    ReasoningEffort = "low"
+	ReasoningEffortMedium ReasoningEffort = "medium"
+	ReasoningEffortHigh   ReasoningEffort = "high"
+)
+
+// minReasoningBudget is the smallest thinking budget providers accept
+const minReasoningBudget = 1024
+
+// effortBudgets translates effort levels for providers that take a token budget
+var effortBudgets = map[ReasoningEffort]int{
+	ReasoningEffortLow:    2048,
+	ReasoningEffortMedium: 8192,
+	ReasoningEffortHigh:   24576,
+}
+
+// ReasoningConfig asks a reasoning model to think before answering, configured
+// as reasoning: {effort: medium} or reasoning: {budget_tokens: 8000}. Effort
+// and budget are translated for each provider: OpenAI o-series models take an
+// effort, while Claude extended thinking and Gemini thinking take a budget.
+// Thinking tokens are billed as completion tokens on top of max_tokens.
+type ReasoningConfig struct {
+	Effort       ReasoningEffort `json:"effort,omitempty"`
+	BudgetTokens int             `json:"budget_tokens,omitempty"`
+}
+
+// parseReasoningConfig reads a step's reasoning config, returning nil when it has none
+func parseReasoningConfig(config map[string]interface{}) (*ReasoningConfig, error) {
+	raw, ok := config["reasoning"].(map[string]interface{})
+	if !ok {
+		if _, set := config["reasoning"]; set {
+			return nil, fmt.Errorf("reasoning must be an object with effort or budget_tokens")
+		}
+		return nil, nil
+	}
+
+	reasoning := &ReasoningConfig{}
+	if effort, ok := raw["effort"].(string); ok {
+		reasoning.Effort = ReasoningEffort(effort)
+		if _, known := effortBudgets[reasoning.Effort]; !known {
+			return nil, fmt.Errorf("invalid reasoning.effort %q: use low, medium or high", effort)
+		}
+	}
+	if v, ok := raw["budget_tokens"].(float64); ok {
+		reasoning.BudgetTokens = int(v)
+		if reasoning.BudgetTokens < minReasoningBudget {
+			return nil, fmt.Errorf("reasoning.budget_tokens must be at least %d", minReasoningBudget)
+		}
+	}
+	if reasoning.Effort == "" && reasoning.BudgetTokens == 0 {
+		return nil, fmt.Errorf("reasoning needs effort or budget_tokens")
+	}
+	return reasoning, nil
+}
+
+// budget returns the thinking token budget, derived from the effort when not set
+func (r *ReasoningConfig) budget() int {
+	if r.BudgetTokens > 0 {
+		return r.BudgetTokens
+	}
+	return effortBudgets[r.Effort]
+}
+
+// effort returns the effort level, derived from the budget when not set
+func (r *ReasoningConfig) effort() ReasoningEffort {
+	if r.Effort != "" {
+		return r.Effort
+	}
+	switch {
+	case r.BudgetTokens <= effortBudgets[ReasoningEffortLow]:
+		return ReasoningEffortLow
+	case r.BudgetTokens <= effortBudgets[ReasoningEffortMedium]:
+		return ReasoningEffortMedium
+	default:
+		return ReasoningEffortHigh
+	}
+}
+
+// reasoningBudget returns the thinking tokens a step reserves, or 0
+func reasoningBudget(config map[string]interface{}) int {
+	reasoning, err := parseReasoningConfig(config)
+	if err != nil || reasoning == nil {
+		return 0
+	}
+	return reasoning.budget()
+}
+
+// supportsReasoning reports whether a model accepts reasoning parameters
+func supportsReasoning(provider, model string) bool {
+	model = strings.ToLower(model)
+	switch strings.ToLower(provider) {
+	case "openai":
+		return strings.HasPrefix(model, "o1") || strings.HasPrefix(model, "o3") ||
+			strings.HasPrefix(model, "o4") || strings.HasPrefix(model, "gpt-5")
+	case "anthropic":
+		return strings.Contains(model, "claude-3-7") || strings.Contains(model, "opus-4") ||
+			strings.Contains(model, "sonnet-4") || strings.Contains(model, "haiku-4")
+	case "google", "gemini":
+		return strings.HasPrefix(model, "gemini-2.5") || strings.HasPrefix(model, "gemini-3")
+	default:
+		return false
+	}
+}
+
+// applyReasoning translates a reasoning config into a provider request body.
+// maxTokens is the answer limit; the thinking budget is added on top since
+// providers count thinking against their output limit.
+func applyReasoning(provider string, body map[string]interface{}, reasoning *ReasoningConfig, maxTokens int) {
+	switch strings.ToLower(provider) {
+	case "openai":
+		body["reasoning_effort"] = string(reasoning.effort())
+		body["max_completion_tokens"] = maxTokens + reasoning.budget()
+		delete(body, "max_tokens")
+		delete(body, "temperature") // Reasoning models only accept the default
+	case "anthropic":
+		body["thinking"] = map[string]interface{}{"type": "enabled", "budget_tokens": reasoning.budget()}
+		body["max_tokens"] = maxTokens + reasoning.budget()
+		delete(body, "temperature") // Extended thinking requires the default
+	case "google", "gemini":
+		generation, _ := body["generationConfig"].(map[string]interface{})
+		if generation == nil {
+			generation = make(map[string]interface{})
+		}
+		generation["thinkingConfig"] = map[string]interface{}{"thinkingBudget": reasoning.budget(), "includeThoughts": false}
+		generation["maxOutputTokens"] = maxTokens + reasoning.budget()
+		body["generationConfig"] = generation
+	}
+}
+
+// reasoningTokens reads the thinking tokens a provider response billed. Claude
+// bills full thinking but returns a summary, so its thinking is what output
+// tokens exceed the visible text by.
+func reasoningTokens(provider string, raw map[string]interface{}) int {
+	switch strings.ToLower(provider) {
+	case "anthropic":
+		blocks, _ := raw["content"].([]interface{})
+		thought, textChars := false, 0
+		for _, b := range blocks {
+			block, _ := b.(map[string]interface{})
+			switch block["type"] {
+			case "thinking", "redacted_thinking":
+				thought = true
+			case "text":
+				text, _ := block["text"].(string)
+				textChars += len(text)
+			}
+		}
+		fields, _ := raw["usage"].(map[string]interface{})
+		if extra := intField(fields, "output_tokens") - (textChars+3)/4; thought && extra > 0 {
+			return extra
+		}
+		return 0
+	case "google", "gemini":
+		fields, _ := raw["usageMetadata"].(map[string]interface{})
+		return intField(fields, "thoughtsTokenCount")
+	default:
+		fields, _ := raw["usage"].(map[string]interface{})
+		details, _ := fields["completion_tokens_details"].(map[string]interface{})
+		return intField(details, "reasoning_tokens")
+	}
+}
@@ -0,0 +1,183 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+)
+
+// RefusalAction is what a step does when a provider refuses or truncates a completion
+type RefusalAction string
+
+const (
+	RefusalActionFail    RefusalAction = "fail"
+	RefusalActionRetry   RefusalAction = "retry"
+	RefusalActionReroute RefusalAction = "reroute"
+	RefusalActionAccept  RefusalAction = "accept"
+)
+
+// defaultRefusalActions fails fast on refusals, since resending the same
+// prompt is refused again, and keeps truncated output as before
+var defaultRefusalActions = map[cas.ErrorClass]RefusalAction{
+	cas.ErrorClassSafetyRefusal: RefusalActionFail,
+	cas.ErrorClassPolicyBlock:   RefusalActionFail,
+	cas.ErrorClassLength:        RefusalActionAccept,
+}
+
+// RefusalError is returned when a provider declines to complete a request
+type RefusalError struct {
+	Class    cas.ErrorClass `json:"error_class"`
+	Provider string         `json:"provider"`
+	Model    string         `json:"model"`
+	Reason   string         `json:"reason,omitempty"`
+	Action   RefusalAction  `json:"action"`
+}
+
+func (e *RefusalError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%s/%s declined the request: %s", e.Provider, e.Model, e.Class)
+	}
+	return fmt.Sprintf("%s/%s declined the request: %s: %s", e.Provider, e.Model, e.Class, e.Reason)
+}
+
+// ErrorClass reports the refusal class to telemetry
+func (e *RefusalError) ErrorClass() cas.ErrorClass {
+	return e.Class
+}
+
+// Retryable reports whether the step's policy asked for the task to be retried
+func (e *RefusalError) Retryable() bool {
+	return e.Action == RefusalActionRetry
+}
+
+// RefusalPolicy maps refusal classes to actions for a step, configured as
+// on_refusal: {safety_refusal: reroute, reroute: {provider, model}}
+type RefusalPolicy struct {
+	Actions map[cas.ErrorClass]RefusalAction `json:"actions"`
+	Reroute *EnsembleMember                  `json:"reroute,omitempty"`
+}
+
+// parseRefusalPolicy reads a step's on_refusal config over the defaults
+func parseRefusalPolicy(config map[string]interface{}) (*RefusalPolicy, error) {
+	policy := &RefusalPolicy{Actions: make(map[cas.ErrorClass]RefusalAction, len(defaultRefusalActions))}
+	for class, action := range defaultRefusalActions {
+		policy.Actions[class] = action
+	}
+
+	raw, ok := config["on_refusal"].(map[string]interface{})
+	if !ok {
+		return policy, nil
+	}
+
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "reroute" {
+			target, err := parseEnsembleMember(raw[key])
+			if err != nil {
+				return nil, fmt.Errorf("on_refusal reroute: %w", err)
+			}
+			policy.Reroute = target
+			continue
+		}
+
+		class := cas.ErrorClass(key)
+		if _, known := defaultRefusalActions[class]; !known {
+			return nil, fmt.Errorf("unknown refusal class %q", key)
+		}
+		value, _ := raw[key].(string)
+		action := RefusalAction(value)
+		switch action {
+		case RefusalActionFail, RefusalActionRetry, RefusalActionReroute, RefusalActionAccept:
+		default:
+			return nil, fmt.Errorf("invalid action %q for %s", value, key)
+		}
+		policy.Actions[class] = action
+	}
+
+	for class, action := range policy.Actions {
+		if action == RefusalActionReroute && policy.Reroute == nil {
+			return nil, fmt.Errorf("%s reroutes but on_refusal.reroute has no provider and model", class)
+		}
+	}
+
+	return policy, nil
+}
+
+// refusalClass classifies a normalized LLM output, returning "" for a normal completion
+func refusalClass(output map[string]interface{}) (cas.ErrorClass, string) {
+	reason, _ := output["finish_reason"].(string)
+	switch FinishReason(reason) {
+	case FinishReasonRefusal:
+		refusal, _ := output["refusal"].(string)
+		return cas.ErrorClassSafetyRefusal, refusal
+	case FinishReasonContentFilter:
+		return cas.ErrorClassPolicyBlock, "content filter"
+	case FinishReasonLength:
+		return cas.ErrorClassLength, "max tokens reached"
+	default:
+		return "", ""
+	}
+}
+
+// handleRefusal applies the step's refusal policy to a refused or truncated
+// response. billed is the cost of the refused call charged to this task.
+func (e *LLMExecutor) handleRefusal(ctx context.Context, task *Task, modelPolicy *cas.ModelPolicy, refused *llmCallResult, billed int64) (*llmCallResult, error) {
+	class, reason := refusalClass(refused.Output)
+	if class == "" {
+		return refused, nil
+	}
+
+	config := map[string]interface{}{}
+	if task.Node != nil && task.Node.Config != nil {
+		config = task.Node.Config
+	}
+	policy, err := parseRefusalPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+
+	action := policy.Actions[class]
+	llmRefusals.Inc(string(class), string(action))
+
+	switch action {
+	case RefusalActionAccept:
+		return refused, nil
+	case RefusalActionReroute:
+		target := policy.Reroute
+		if err := modelPolicy.CheckModel(target.Provider, target.Model); err != nil {
+			return nil, err
+		}
+		if err := e.checkResidency(ctx, task, target.Provider, target.Model); err != nil {
+			return nil, err
+		}
+		log.Printf("Task %s: %s/%s returned %s, rerouting to %s/%s", task.ID, refused.Provider, refused.Model, class, target.Provider, target.Model)
+
+		rerouted, err := e.callProvider(ctx, target.Provider, target.Model)
+		if err != nil {
+			return nil, err
+		}
+		// A second refusal is final; there is nowhere left to reroute
+		if again, againReason := refusalClass(rerouted.Output); again != "" && policy.Actions[again] != RefusalActionAccept {
+			llmRefusals.Inc(string(again), string(RefusalActionFail))
+			return nil, &RefusalError{Class: again, Provider: rerouted.Provider, Model: rerouted.Model, Reason: againReason, Action: RefusalActionFail}
+		}
+
+		rerouted.CostCents += billed
+		rerouted.Output["rerouted_from"] = map[string]interface{}{
+			"provider":    refused.Provider,
+			"model":       refused.Model,
+			"error_class": string(class),
+		}
+		return rerouted, nil
+	default:
+		return nil, &RefusalError{Class: class, Provider: refused.Provider, Model: refused.Model, Reason: reason, Action: action}
+	}
+}
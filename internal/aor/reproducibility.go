@@ -0,0 +1,199 @@
+package aor
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+)
+
+// seedProviders accept a sampling seed; the rest can only be pinned to a temperature
+var seedProviders = map[string]bool{
+	"openai":         true,
+	"google":         true,
+	"gemini":         true,
+	"cohere":         true,
+	MockProviderName: true,
+}
+
+// Reproducibility records what a reproducible run's LLM steps were pinned to.
+// Each step gets its own seed derived from the run's seed, so adding a step
+// does not change the seeds of the others.
+type Reproducibility struct {
+	Seed       int64                 `json:"seed"`
+	Steps      map[string]PinnedStep `json:"steps"`
+	ConfigHash string                `json:"config_hash"` // Hash of the spec and every pin
+	Warnings   []string              `json:"warnings,omitempty"`
+}
+
+// PinnedStep is the provider, model and sampling settings one step runs with
+type PinnedStep struct {
+	Provider    string  `json:"provider"`
+	Model       string  `json:"model"`
+	Temperature float64 `json:"temperature"`
+	Seed        *int64  `json:"seed,omitempty"` // Nil when the provider cannot seed sampling
+}
+
+// pinReproducibility pins every LLM step of a spec and warns about the steps
+// whose output can still vary between runs
+func pinReproducibility(spec *WorkflowSpec, seed int64) (*Reproducibility, error) {
+	repro := &Reproducibility{Seed: seed, Steps: make(map[string]PinnedStep)}
+	for _, step := range spec.DAG.Steps {
+		switch ExecutorType(step.Type) {
+		case ExecutorTypeLLM, ExecutorTypeRAGGenerate:
+		case ExecutorTypeEnsemble:
+			repro.Warnings = append(repro.Warnings, fmt.Sprintf("step %s: ensemble members are not pinned and may vary", step.ID))
+			continue
+		case ExecutorTypeHTTP:
+			repro.Warnings = append(repro.Warnings, fmt.Sprintf("step %s: http responses depend on external state", step.ID))
+			continue
+		default:
+			continue
+		}
+
+		provider, model := stepProviderModel(step.Config)
+		pin := PinnedStep{Provider: provider, Model: model}
+		if temperature, ok := step.Config["temperature"].(float64); ok {
+			pin.Temperature = temperature
+		} else if llmSampleCount(step.Config) > 1 {
+			pin.Temperature = defaultSampleTemperature // Samples need diversity; the seed keeps it repeatable
+		}
+		if seedProviders[strings.ToLower(provider)] || isMockProvider(provider) {
+			stepSeed := deriveStepSeed(seed, step.ID)
+			pin.Seed = &stepSeed
+		} else {
+			repro.Warnings = append(repro.Warnings, fmt.Sprintf("step %s: %s does not support seeds; temperature %.1f reduces but does not remove variation", step.ID, provider, pin.Temperature))
+		}
+		if reasoning, err := parseReasoningConfig(step.Config); err == nil && reasoning != nil && supportsReasoning(provider, model) {
+			repro.Warnings = append(repro.Warnings, fmt.Sprintf("step %s: reasoning models ignore temperature", step.ID))
+		}
+		if hedge, err := parseHedgePolicy(step.Config); err == nil && hedge != nil {
+			repro.Warnings = append(repro.Warnings, fmt.Sprintf("step %s: hedged calls may be answered by %s/%s", step.ID, hedge.Provider, hedge.Model))
+		}
+		repro.Steps[step.ID] = pin
+	}
+
+	content, err := CanonicalSpecBytes(spec)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := json.Marshal(struct {
+		Seed  int64                 `json:"seed"`
+		Steps map[string]PinnedStep `json:"steps"`
+	}{repro.Seed, repro.Steps})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pins: %w", err)
+	}
+	repro.ConfigHash = db.HashContent(append(content, pins...))
+	return repro, nil
+}
+
+// deriveStepSeed gives each step a stable seed within the range providers accept
+func deriveStepSeed(seed int64, stepID string) int64 {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%s", seed, stepID)))
+	return int64(binary.BigEndian.Uint32(sum[:4]) & math.MaxInt32)
+}
+
+// replayWarnings explains why a replay may not reproduce the original run
+func replayWarnings(original *WorkflowRun, repro *Reproducibility, recording bool) []string {
+	var warnings []string
+	if !recording {
+		warnings = append(warnings, "response recording is off, so LLM steps are called again instead of replayed")
+	}
+	recorded := runReproducibility(original.Metadata)
+	if recorded == nil {
+		return append(warnings, fmt.Sprintf("run %s was not reproducible: its seeds and temperatures were not pinned", original.ID))
+	}
+	if recorded.ConfigHash == repro.ConfigHash {
+		return warnings
+	}
+
+	changed := make([]string, 0)
+	for stepID, pin := range repro.Steps {
+		if previous, ok := recorded.Steps[stepID]; !ok || !samePin(previous, pin) {
+			changed = append(changed, stepID)
+		}
+	}
+	sort.Strings(changed)
+	if len(changed) == 0 {
+		return append(warnings, fmt.Sprintf("pinned config changed since run %s", original.ID))
+	}
+	return append(warnings, fmt.Sprintf("pinned config changed since run %s for steps %s", original.ID, strings.Join(changed, ", ")))
+}
+
+func samePin(a, b PinnedStep) bool {
+	if a.Provider != b.Provider || a.Model != b.Model || a.Temperature != b.Temperature {
+		return false
+	}
+	if a.Seed == nil || b.Seed == nil {
+		return a.Seed == nil && b.Seed == nil
+	}
+	return *a.Seed == *b.Seed
+}
+
+// pinStepConfig applies a step's pins on top of its config
+func pinStepConfig(config map[string]interface{}, repro *Reproducibility, stepID string) map[string]interface{} {
+	if repro == nil {
+		return config
+	}
+	pin, ok := repro.Steps[stepID]
+	if !ok {
+		return config
+	}
+
+	pinned := make(map[string]interface{}, len(config)+4)
+	for k, v := range config {
+		pinned[k] = v
+	}
+	pinned["provider"] = pin.Provider
+	pinned["model"] = pin.Model
+	pinned["temperature"] = pin.Temperature
+	if pin.Seed != nil {
+		pinned["seed"] = float64(*pin.Seed)
+	}
+	return pinned
+}
+
+// runReproducibility returns the pins recorded on a run, or nil
+func runReproducibility(metadata map[string]interface{}) *Reproducibility {
+	raw, ok := metadata["reproducibility"]
+	if !ok || raw == nil {
+		return nil
+	}
+	if repro, ok := raw.(*Reproducibility); ok {
+		return repro
+	}
+	// Runs loaded from the database hold the pins as decoded JSON
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var repro Reproducibility
+	if err := json.Unmarshal(data, &repro); err != nil {
+		return nil
+	}
+	return &repro
+}
+
+// stepSamplingParams reads a step's temperature and seed; a best-of-N sample
+// offsets the seed by its index so samples differ but repeat
+func stepSamplingParams(config map[string]interface{}) (*float64, *int64) {
+	var temperature *float64
+	if t, ok := config["temperature"].(float64); ok {
+		temperature = &t
+	}
+	var seed *int64
+	if s, ok := config["seed"].(float64); ok {
+		value := int64(s)
+		if index, ok := config["sample_index"].(float64); ok {
+			value += int64(index)
+		}
+		seed = &value
+	}
+	return temperature, seed
+}
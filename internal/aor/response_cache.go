@@ -0,0 +1,172 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+)
+
+// Response cache outcomes recorded in metrics
+const (
+	ResponseCacheHit   = "hit"
+	ResponseCacheMiss  = "miss"
+	ResponseCacheStore = "store"
+)
+
+const defaultResponseCacheTTL = time.Hour
+
+// ResponseCachePolicy opts an LLM step into reusing provider responses for
+// the same prompt, inputs and model config. Responses are shared within the
+// org, the workflow (project) or the run's authenticated submitter.
+type ResponseCachePolicy struct {
+	TTL     time.Duration
+	Privacy cas.PrivacyLevel
+}
+
+// parseResponseCachePolicy reads a step's "response_cache" setting: true, or
+// {ttl, privacy: org|project|user}. Steps without it are not cached.
+func parseResponseCachePolicy(config map[string]interface{}) (*ResponseCachePolicy, error) {
+	policy := &ResponseCachePolicy{TTL: defaultResponseCacheTTL, Privacy: cas.PrivacyOrg}
+
+	switch raw := config["response_cache"].(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		if !raw {
+			return nil, nil
+		}
+		return policy, nil
+	case map[string]interface{}:
+		if enabled, ok := raw["enabled"].(bool); ok && !enabled {
+			return nil, nil
+		}
+		if value, ok := raw["ttl"].(string); ok && value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid response_cache ttl %q", value)
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("response_cache ttl must be positive")
+			}
+			policy.TTL = d
+		}
+		if privacy, ok := raw["privacy"].(string); ok && privacy != "" {
+			policy.Privacy = cas.PrivacyLevel(privacy)
+		}
+		switch policy.Privacy {
+		case cas.PrivacyOrg, cas.PrivacyProject, cas.PrivacyUser:
+		default:
+			return nil, fmt.Errorf("unknown response_cache privacy %q: use org, project or user", policy.Privacy)
+		}
+		return policy, nil
+	}
+	return nil, fmt.Errorf("response_cache must be true or {ttl, privacy}")
+}
+
+// scope returns who shares the task's cached responses; ok is false when the
+// task lacks what its privacy level needs
+func (p *ResponseCachePolicy) scope(task *Task) (cas.CacheScope, bool) {
+	scope := cas.CacheScope{Level: p.Privacy}
+	switch p.Privacy {
+	case cas.PrivacyProject:
+		scope.ID = task.Workflow
+	case cas.PrivacyUser:
+		// Labels are caller-supplied, so only the authenticated submitter may
+		// name whose responses are shared
+		scope.ID = task.Submitter
+	}
+	return scope, p.Privacy == cas.PrivacyOrg || scope.ID != ""
+}
+
+// responseCacheLookup is a cacheable call's key and where it is stored
+type responseCacheLookup struct {
+	policy     *ResponseCachePolicy
+	scope      cas.CacheScope
+	promptHash string
+	inputHash  string
+}
+
+// responseCacheKey hashes the call's rendered prompt, model config and inputs,
+// or returns nil when the call should not be cached
+func responseCacheKey(task *Task, provider, model string, prompt *renderedPrompt) (*responseCacheLookup, error) {
+	if task.Node == nil || task.Chaos != nil || sampleIndex(task) > 0 {
+		return nil, nil // Chaos faults and best-of-N samples must reach the provider
+	}
+	policy, err := parseResponseCachePolicy(task.Node.Config)
+	if err != nil || policy == nil {
+		return nil, err
+	}
+	scope, ok := policy.scope(task)
+	if !ok {
+		return nil, nil
+	}
+
+	params := llmCallParams(task.Node.Config)
+	if prompt.Reasoning != nil {
+		params["reasoning"] = prompt.Reasoning
+	}
+	key := cas.CacheKey{
+		Provider: provider,
+		Model:    model,
+		Prompt:   prompt.Prefix,
+		Params:   params,
+		Inputs:   task.Inputs,
+	}
+	promptHash, inputHash, err := key.Hashes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash response cache key: %w", err)
+	}
+	return &responseCacheLookup{policy: policy, scope: scope, promptHash: promptHash, inputHash: inputHash}, nil
+}
+
+// cachedLLMResponse is a call's output with the provider and model that
+// produced it, as stored in the response cache
+type cachedLLMResponse struct {
+	Output   map[string]interface{}
+	Provider string
+	Model    string
+}
+
+// cachedResponse returns the stored response for a call, or nil on a miss.
+// Cache errors are logged and treated as misses so the provider is still called.
+func (w *Worker) cachedResponse(ctx context.Context, task *Task, lookup *responseCacheLookup) *cachedLLMResponse {
+	response, err := w.responses.Get(ctx, task.OrgID, lookup.scope, lookup.promptHash, lookup.inputHash)
+	if err != nil {
+		log.Printf("Response cache lookup failed for task %s: %v", task.ID, err)
+		return nil
+	}
+	output, ok := response.Response["output"].(map[string]interface{})
+	if !response.Hit || !ok {
+		responseCacheLookups.Inc(ResponseCacheMiss)
+		return nil
+	}
+	responseCacheLookups.Inc(ResponseCacheHit)
+	cached := &cachedLLMResponse{Output: output}
+	cached.Provider, _ = response.Response["provider"].(string)
+	cached.Model, _ = response.Response["model"].(string)
+	return cached
+}
+
+// storeResponse caches a successful call's output with its provider and model
+func (w *Worker) storeResponse(ctx context.Context, task *Task, lookup *responseCacheLookup, result *TaskResult) {
+	err := w.responses.Put(ctx, task.OrgID, &cas.CacheRequest{
+		PromptHash: lookup.promptHash,
+		InputHash:  lookup.inputHash,
+		Response: map[string]interface{}{
+			"output":   result.Output,
+			"provider": result.Provider,
+			"model":    result.Model,
+		},
+		TTL:    lookup.policy.TTL,
+		Policy: cas.CachePolicy{Enabled: true, TTL: lookup.policy.TTL, PrivacyLevel: lookup.policy.Privacy},
+		Scope:  lookup.scope,
+	})
+	if err != nil {
+		log.Printf("Failed to cache response for task %s: %v", task.ID, err)
+		return
+	}
+	responseCacheLookups.Inc(ResponseCacheStore)
+}
@@ -0,0 +1,198 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// retentionInterval is how often expired run inputs and outputs are purged
+	retentionInterval = time.Hour
+	// retentionBatchSize bounds how many runs one purge transaction touches
+	retentionBatchSize = 500
+)
+
+// RetentionPolicy bounds how long an org keeps run inputs and outputs. Run
+// records, status and cost are kept; only the payloads are removed.
+type RetentionPolicy struct {
+	OrgID     uuid.UUID `json:"org_id"`
+	RunIODays int       `json:"run_io_days"` // 0 keeps inputs and outputs indefinitely
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// GetRetentionPolicy returns an org's retention policy, or the keep-forever
+// default when none is set
+func (cp *ControlPlane) GetRetentionPolicy(ctx context.Context, orgID uuid.UUID) (*RetentionPolicy, error) {
+	policy := &RetentionPolicy{OrgID: orgID}
+	query := `SELECT run_io_days, updated_at FROM org_retention_policy WHERE org_id = $1`
+	err := cp.db.QueryRowContext(ctx, query, orgID).Scan(&policy.RunIODays, &policy.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get retention policy: %w", err)
+	}
+	return policy, nil
+}
+
+// SetRetentionPolicy creates or replaces an org's retention policy
+func (cp *ControlPlane) SetRetentionPolicy(ctx context.Context, policy *RetentionPolicy) error {
+	if policy.RunIODays < 0 {
+		return fmt.Errorf("run_io_days must not be negative")
+	}
+
+	policy.UpdatedAt = time.Now()
+	query := `INSERT INTO org_retention_policy (org_id, run_io_days, updated_at)
+			  VALUES ($1, $2, $3)
+			  ON CONFLICT (org_id) DO UPDATE SET run_io_days = EXCLUDED.run_io_days, updated_at = EXCLUDED.updated_at`
+	if _, err := cp.db.ExecContext(ctx, query, policy.OrgID, policy.RunIODays, policy.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to set retention policy: %w", err)
+	}
+	return nil
+}
+
+// enforceRetention purges the inputs and outputs of finished runs older than
+// their org's retention period. It runs from the monitor loop at most once
+// per retentionInterval.
+func (cp *ControlPlane) enforceRetention(ctx context.Context) {
+	if time.Since(cp.retentionCheckedAt) < retentionInterval {
+		return
+	}
+	cp.retentionCheckedAt = time.Now()
+
+	query := `SELECT r.id
+			  FROM workflow_run r
+			  JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  JOIN org_retention_policy p ON p.org_id = s.org_id
+			  WHERE p.run_io_days > 0
+			  AND r.ended_at < NOW() - make_interval(days => p.run_io_days)
+			  AND (r.metadata ? 'inputs' OR r.metadata ? 'output'
+			       OR EXISTS (SELECT 1 FROM step_run sr WHERE sr.workflow_run_id = r.id
+			                  AND (sr.input_ref IS NOT NULL OR sr.output_ref IS NOT NULL)))
+			  LIMIT $1`
+
+	for {
+		runIDs, err := cp.queryIDs(ctx, query, retentionBatchSize)
+		if err != nil {
+			log.Printf("Failed to find runs past retention: %v", err)
+			return
+		}
+		if len(runIDs) == 0 {
+			return
+		}
+
+		blobs, err := cp.purgeRunIO(ctx, runIDs)
+		if err != nil {
+			log.Printf("Failed to purge run inputs and outputs: %v", err)
+			return
+		}
+		runDataErasures.Add(float64(len(runIDs)), "retention")
+		log.Printf("Purged inputs and outputs of %d runs past retention (%d blobs)", len(runIDs), blobs)
+
+		if len(runIDs) < retentionBatchSize {
+			return
+		}
+	}
+}
+
+// purgeRunIO removes the inputs and outputs of runs while keeping the run
+// records, and returns how many content blobs were deleted
+func (cp *ControlPlane) purgeRunIO(ctx context.Context, runIDs []uuid.UUID) (int64, error) {
+	tx, err := cp.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	refs, err := stepRunRefs(ctx, tx, runIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `UPDATE workflow_run
+			  SET metadata = (metadata - 'inputs' - 'output') || jsonb_build_object('io_purged_at', NOW())
+			  WHERE id = ANY($1)`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(runIDs)); err != nil {
+		return 0, fmt.Errorf("failed to purge run inputs and outputs: %w", err)
+	}
+
+	query = `UPDATE step_run SET input_ref = NULL, output_ref = NULL WHERE workflow_run_id = ANY($1)`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(runIDs)); err != nil {
+		return 0, fmt.Errorf("failed to purge step inputs and outputs: %w", err)
+	}
+
+	blobs, err := deleteOrphanedBlobs(ctx, tx, refs)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit retention purge: %w", err)
+	}
+	return blobs, nil
+}
+
+func (cp *ControlPlane) queryIDs(ctx context.Context, query string, args ...interface{}) ([]uuid.UUID, error) {
+	rows, err := cp.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ids: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// stepRunRefs returns the distinct content blob hashes the runs' steps point at
+func stepRunRefs(ctx context.Context, tx *sql.Tx, runIDs []uuid.UUID) ([]string, error) {
+	query := `SELECT DISTINCT ref FROM (
+				  SELECT input_ref AS ref FROM step_run WHERE workflow_run_id = ANY($1)
+				  UNION ALL
+				  SELECT output_ref FROM step_run WHERE workflow_run_id = ANY($1)
+			  ) refs WHERE ref IS NOT NULL AND ref <> ''`
+	rows, err := tx.QueryContext(ctx, query, pq.Array(runIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read step refs: %w", err)
+	}
+	defer rows.Close()
+
+	refs := make([]string, 0)
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			return nil, fmt.Errorf("failed to scan step ref: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// deleteOrphanedBlobs deletes the given content blobs once no step refers to
+// them. Blobs are content addressed, so another run with identical input
+// keeps its copy. Workflow specs and prompt templates are never step data.
+func deleteOrphanedBlobs(ctx context.Context, tx *sql.Tx, refs []string) (int64, error) {
+	if len(refs) == 0 {
+		return 0, nil
+	}
+
+	query := `DELETE FROM content_blob b
+			  WHERE b.hash = ANY($1)
+			  AND b.kind NOT IN ('workflow_spec', 'prompt_template')
+			  AND NOT EXISTS (SELECT 1 FROM step_run s WHERE s.input_ref = b.hash OR s.output_ref = b.hash)`
+	result, err := tx.ExecContext(ctx, query, pq.Array(refs))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete content blobs: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
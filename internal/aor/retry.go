@@ -0,0 +1,190 @@
+package aor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+)
+
+const (
+	defaultMaxRetries      = 2
+	defaultRetryBackoff    = time.Second
+	defaultMaxRetryBackoff = 30 * time.Second
+	defaultRetryMultiplier = 2.0
+	defaultRetryJitter     = 0.2
+	maxStepRetries         = 10
+)
+
+// NodePolicy controls how the worker retries a failed step
+type NodePolicy struct {
+	MaxRetries     int           `json:"max_retries"`
+	InitialBackoff time.Duration `json:"initial_backoff"`
+	MaxBackoff     time.Duration `json:"max_backoff"`
+	Multiplier     float64       `json:"multiplier"`
+	Jitter         float64       `json:"jitter"` // Share of each backoff that is randomized, 0 to 1
+}
+
+func defaultNodePolicy() *NodePolicy {
+	return &NodePolicy{
+		MaxRetries:     defaultMaxRetries,
+		InitialBackoff: defaultRetryBackoff,
+		MaxBackoff:     defaultMaxRetryBackoff,
+		Multiplier:     defaultRetryMultiplier,
+		Jitter:         defaultRetryJitter,
+	}
+}
+
+// parseNodePolicy reads a step's retry policy. The retry count comes from
+// retry_policy.max_retries, then config retries, then the step's retries;
+// a retry_policy block also tunes the backoff, e.g.
+// {max_retries: 4, initial_backoff: 500ms, max_backoff: 1m, multiplier: 3, jitter: 0.5}.
+func parseNodePolicy(step Step) (*NodePolicy, error) {
+	policy := defaultNodePolicy()
+	if step.Retries > 0 {
+		policy.MaxRetries = step.Retries
+	}
+	if raw, ok := step.Config["retries"]; ok {
+		n, ok := policyNumber(raw)
+		if !ok {
+			return nil, fmt.Errorf("retries must be a number")
+		}
+		policy.MaxRetries = int(n)
+	}
+
+	raw, ok := step.Config["retry_policy"].(map[string]interface{})
+	if !ok {
+		if _, set := step.Config["retry_policy"]; set {
+			return nil, fmt.Errorf("retry_policy must be an object")
+		}
+		return policy, policy.validate()
+	}
+	if value, ok := raw["max_retries"]; ok {
+		n, ok := policyNumber(value)
+		if !ok {
+			return nil, fmt.Errorf("retry_policy.max_retries must be a number")
+		}
+		policy.MaxRetries = int(n)
+	}
+	for key, target := range map[string]*time.Duration{"initial_backoff": &policy.InitialBackoff, "max_backoff": &policy.MaxBackoff} {
+		value, ok := raw[key].(string)
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry_policy.%s %q: use a duration like 500ms", key, value)
+		}
+		*target = d
+	}
+	if value, ok := raw["multiplier"]; ok {
+		if policy.Multiplier, ok = policyNumber(value); !ok {
+			return nil, fmt.Errorf("retry_policy.multiplier must be a number")
+		}
+	}
+	if value, ok := raw["jitter"]; ok {
+		if policy.Jitter, ok = policyNumber(value); !ok {
+			return nil, fmt.Errorf("retry_policy.jitter must be a number")
+		}
+	}
+	return policy, policy.validate()
+}
+
+// stepNodePolicy returns the step's retry policy, falling back to the
+// default for a policy the spec got wrong
+func stepNodePolicy(step Step) *NodePolicy {
+	policy, err := parseNodePolicy(step)
+	if err != nil {
+		log.Printf("Invalid retry policy on step %s, using the default: %v", step.ID, err)
+		return defaultNodePolicy()
+	}
+	return policy
+}
+
+func (p *NodePolicy) validate() error {
+	switch {
+	case p.MaxRetries < 0 || p.MaxRetries > maxStepRetries:
+		return fmt.Errorf("retries must be between 0 and %d", maxStepRetries)
+	case p.InitialBackoff <= 0:
+		return fmt.Errorf("retry initial_backoff must be positive")
+	case p.MaxBackoff < p.InitialBackoff:
+		return fmt.Errorf("retry max_backoff must be at least initial_backoff")
+	case p.Multiplier < 1:
+		return fmt.Errorf("retry multiplier must be at least 1")
+	case p.Jitter < 0 || p.Jitter > 1:
+		return fmt.Errorf("retry jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// backoff is the wait before the given retry, 1 being the first. Jitter
+// spreads out retries of steps that failed together, such as during a
+// provider outage.
+func (p *NodePolicy) backoff(retry int, random func() float64) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry-1))
+	d = math.Min(d, float64(p.MaxBackoff))
+	d *= 1 - p.Jitter + 2*p.Jitter*random()
+	return time.Duration(math.Min(d, float64(p.MaxBackoff)))
+}
+
+func policyNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// retryableError reports whether a failed attempt might succeed if made
+// again. Rate limits, server errors and timeouts are transient; invalid
+// requests and failures the step's own policy makes final are not.
+func retryableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var refusal *RefusalError
+	if errors.As(err, &refusal) {
+		// Unless asked to retry, the same prompt would be refused again
+		return refusal.Retryable()
+	}
+	var violation *SandboxViolationError
+	if errors.As(err, &violation) {
+		// The same code would be blocked again
+		return false
+	}
+	var pluginErr *PluginError
+	if errors.As(err, &pluginErr) {
+		return pluginErr.Retryable()
+	}
+	var portErr *PortTypeError
+	if errors.As(err, &portErr) {
+		return portErr.Retryable()
+	}
+	var assertErr *AssertionError
+	if errors.As(err, &assertErr) {
+		return assertErr.Retryable()
+	}
+	var mediaErr *MediaError
+	if errors.As(err, &mediaErr) {
+		return false
+	}
+	var overBudget *TokenBudgetExceededError
+	if errors.As(err, &overBudget) {
+		// The same inputs would be over budget again
+		return false
+	}
+	var exhausted *cas.BudgetExhaustedError
+	if errors.As(err, &exhausted) {
+		// A throttled call gets through later; a rejected one waits for the next period
+		return exhausted.RetryAfter > 0
+	}
+	return cas.ClassifyError(err) != cas.ErrorClassInvalidRequest
+}
@@ -0,0 +1,229 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+
+	"github.com/google/uuid"
+)
+
+// StepBudgetAction is what dispatch does with a step given what is left of
+// its run's budget
+type StepBudgetAction string
+
+const (
+	StepBudgetDispatch StepBudgetAction = "dispatch"
+	StepBudgetDegrade  StepBudgetAction = "degrade"
+	StepBudgetExceeded StepBudgetAction = "exceeded"
+)
+
+// StepBudgetPlan is the budget decision for one step about to be dispatched
+type StepBudgetPlan struct {
+	Action        StepBudgetAction       `json:"action"`
+	SpentCents    int64                  `json:"spent_cents"`
+	BudgetCents   int64                  `json:"budget_cents"`
+	EstimateCents int64                  `json:"estimate_cents"` // Of the model dispatched, or the step's own when exceeded
+	Config        map[string]interface{} `json:"config,omitempty"`
+	Message       string                 `json:"message,omitempty"`
+}
+
+// parseBudgetFallback reads the cheaper models, in the order they are tried,
+// that a step may be degraded to when its own model doesn't fit the budget
+func parseBudgetFallback(config map[string]interface{}) ([]*EnsembleMember, error) {
+	raw, ok := config["budget_fallback"]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		list = []interface{}{raw}
+	}
+
+	fallbacks := make([]*EnsembleMember, 0, len(list))
+	for i, item := range list {
+		member, err := parseEnsembleMember(item)
+		if err != nil {
+			return nil, fmt.Errorf("budget_fallback %d: %w", i+1, err)
+		}
+		fallbacks = append(fallbacks, member)
+	}
+	return fallbacks, nil
+}
+
+// degradedConfig is a step's config switched to a fallback model
+func degradedConfig(config map[string]interface{}, fallback *EnsembleMember) map[string]interface{} {
+	degraded := maps.Clone(config)
+	maps.Copy(degraded, fallback.Overrides)
+	degraded["provider"] = fallback.Provider
+	degraded["model"] = fallback.Model
+	delete(degraded, "budget_fallback")
+	return degraded
+}
+
+// PlanStepBudget decides whether a step fits in what is left of its run's
+// budget: as configured, on the first budget_fallback model that fits, or not
+// at all. Runs without a budget and steps that cost nothing always dispatch.
+func PlanStepBudget(step Step, spentCents, budgetCents int64, pricing map[string]ModelPricing) *StepBudgetPlan {
+	plan := &StepBudgetPlan{Action: StepBudgetDispatch, SpentCents: spentCents, BudgetCents: budgetCents, Config: step.Config}
+	if budgetCents <= 0 {
+		return plan
+	}
+
+	estimate := func(config map[string]interface{}) int64 {
+		single := &WorkflowSpec{DAG: DAG{Steps: []Step{{ID: step.ID, Type: step.Type, Config: config}}}}
+		return EstimateSpecCost(single, pricing).TotalCents
+	}
+	plan.EstimateCents = estimate(step.Config)
+	if plan.EstimateCents == 0 || spentCents+plan.EstimateCents <= budgetCents {
+		return plan
+	}
+
+	remaining := max(budgetCents-spentCents, 0)
+	provider, model := stepProviderModel(step.Config)
+	fallbacks, err := parseBudgetFallback(step.Config)
+	if err != nil {
+		log.Printf("Ignoring budget_fallback of step %s: %v", step.ID, err)
+	}
+	switch ExecutorType(step.Type) {
+	case ExecutorTypeLLM, ExecutorTypeRAGGenerate:
+		for _, fallback := range fallbacks {
+			config := degradedConfig(step.Config, fallback)
+			if cost := estimate(config); spentCents+cost <= budgetCents {
+				plan.Action, plan.Config, plan.EstimateCents = StepBudgetDegrade, config, cost
+				plan.Message = fmt.Sprintf("step %s degraded from %s/%s to %s/%s: %d¢ of the run's %d¢ budget left",
+					step.ID, provider, model, fallback.Provider, fallback.Model, remaining, budgetCents)
+				return plan
+			}
+		}
+	}
+
+	plan.Action, plan.Config = StepBudgetExceeded, nil
+	plan.Message = fmt.Sprintf("step %s needs an estimated %d¢ but only %d¢ of the run's %d¢ budget is left",
+		step.ID, plan.EstimateCents, remaining, budgetCents)
+	if len(fallbacks) > 0 {
+		plan.Message += ", and no budget_fallback model fits"
+	}
+	return plan
+}
+
+// runBudgetCents reads a run's budget from its metadata, which holds an
+// int64 on runs just submitted and a JSON number on runs loaded back
+func runBudgetCents(metadata map[string]interface{}) int64 {
+	switch v := metadata["budget_cents"].(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return int64(f)
+	}
+	return 0
+}
+
+// budgetedStep reports whether a step type calls a paid model
+func budgetedStep(stepType string) bool {
+	switch ExecutorType(stepType) {
+	case ExecutorTypeLLM, ExecutorTypeRAGGenerate, ExecutorTypeEnsemble, ExecutorTypeTranscribe:
+		return true
+	}
+	return false
+}
+
+// planNodeBudget checks a node about to be dispatched against its run's
+// budget, switching the node to its fallback model when degraded. Steps
+// dispatched together are each checked against the spending settled so far.
+// It returns nil when there is nothing to check or the check is unavailable,
+// in which case the node dispatches as configured.
+func (s *Scheduler) planNodeBudget(ctx context.Context, run *WorkflowRun, node *Node) *StepBudgetPlan {
+	budget := runBudgetCents(run.Metadata)
+	if budget <= 0 || !budgetedStep(node.Type) {
+		return nil
+	}
+
+	spent, err := s.runSpentCents(ctx, run.ID)
+	if err == nil {
+		var pricing map[string]ModelPricing
+		if pricing, err = loadModelPricing(ctx, s.db, run.OrgID); err == nil {
+			plan := PlanStepBudget(Step{ID: node.ID, Type: node.Type, Config: node.Config}, spent, budget, pricing)
+			budgetDispatches.Inc(string(plan.Action))
+			if plan.Action == StepBudgetDegrade {
+				log.Printf("Run %s: %s", run.ID, plan.Message)
+				node.Config = plan.Config
+			}
+			return plan
+		}
+	}
+	log.Printf("Budget check unavailable for step %s of run %s, dispatching: %v", node.ID, run.ID, err)
+	return nil
+}
+
+// checkStepBudget degrades a step that would take its run over budget, or
+// ends the run when no fallback fits, reporting whether to dispatch the step
+func (s *Scheduler) checkStepBudget(ctx context.Context, run *WorkflowRun, stepRun *StepRun, node *Node) (bool, error) {
+	plan := s.planNodeBudget(ctx, run, node)
+	if plan == nil || plan.Action != StepBudgetExceeded {
+		return true, nil
+	}
+	log.Printf("Run %s exceeded its budget: %s", run.ID, plan.Message)
+	return false, s.stopRunOverBudget(ctx, run, stepRun.ID, node.ID, plan.Message)
+}
+
+// runSpentCents is what a run has spent so far: warm-up spending charged to
+// the run plus the cost of its finished steps
+func (s *Scheduler) runSpentCents(ctx context.Context, runID uuid.UUID) (int64, error) {
+	query := `SELECT r.cost_cents + COALESCE((SELECT SUM(cost_cents) FROM step_run WHERE workflow_run_id = r.id), 0)
+			  FROM workflow_run r WHERE r.id = $1`
+	var spent int64
+	if err := s.db.QueryRowContext(ctx, query, runID).Scan(&spent); err != nil {
+		return 0, fmt.Errorf("failed to read run spending: %w", err)
+	}
+	return spent, nil
+}
+
+// stopRunOverBudget ends a run with the budget_exceeded status, recording
+// the step that didn't fit as its failure. Steps already running finish.
+func (s *Scheduler) stopRunOverBudget(ctx context.Context, run *WorkflowRun, stepRunID, stepID, message string) error {
+	failure := newRunFailure(FailureBudget, stepID, message)
+	failureJSON, err := json.Marshal(failure)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run failure: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `UPDATE step_run SET status = 'canceled', error = $2, ended_at = NOW() WHERE id = $1`,
+		stepRunID, message); err != nil {
+		return fmt.Errorf("failed to cancel step run: %w", err)
+	}
+	query := `UPDATE workflow_run SET status = 'budget_exceeded', ended_at = NOW(),
+			  failure_category = COALESCE(failure_category, $2), failure = COALESCE(failure, $3)
+			  WHERE id = $1 AND status IN ('queued', 'running')`
+	result, err := s.db.ExecContext(ctx, query, run.ID, string(failure.Category), failureJSON)
+	if err != nil {
+		return fmt.Errorf("failed to stop run over budget: %w", err)
+	}
+	run.Status = WorkflowStatusBudgetExceeded
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	runFailures.Inc(string(failure.Category))
+	if s.onRunFailure != nil {
+		s.onRunFailure(ctx, run, failure)
+	}
+	msgData, _ := json.Marshal(map[string]interface{}{"run_id": run.ID.String(), "action": "cancel"})
+	if _, err := s.js.Publish("agentflow.signals", msgData); err != nil {
+		log.Printf("Failed to send cancel signal: %v", err)
+	}
+	if err := s.finishRun(ctx, run.ID); err != nil {
+		log.Printf("Failed to finish run %s: %v", run.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// maxExtractPaths bounds how many fields one bulk extraction projects
+const maxExtractPaths = 20
+
+// RunOutputProjection is the part of a run's output selected by a path.
+// Definite paths yield the value itself; wildcard paths yield a list.
+type RunOutputProjection struct {
+	RunID uuid.UUID   `json:"run_id"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+	Found bool        `json:"found"`
+}
+
+// RunOutputRow holds the projected fields of one run, keyed by path
+type RunOutputRow struct {
+	RunID        uuid.UUID              `json:"run_id"`
+	WorkflowName string                 `json:"workflow_name"`
+	Status       WorkflowStatus         `json:"status"`
+	CreatedAt    time.Time              `json:"created_at"`
+	Values       map[string]interface{} `json:"values"`
+}
+
+// RunOutputExtract is one page of projected outputs across runs
+type RunOutputExtract struct {
+	Paths      []string       `json:"paths"`
+	Rows       []RunOutputRow `json:"rows"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// GetRunOutput projects a run's output document. The document holds the
+// run's final output under $.output and each step's latest output under
+// $.steps.<node_id>, e.g. $.steps.analyze.summary.
+func (cp *ControlPlane) GetRunOutput(ctx context.Context, runID uuid.UUID, expr string) (*RunOutputProjection, error) {
+	path, err := ParseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := cp.GetWorkflowRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := cp.runOutputDocument(ctx, run, []*JSONPath{path})
+	if err != nil {
+		return nil, err
+	}
+
+	projection := &RunOutputProjection{RunID: run.ID, Path: path.String()}
+	projection.Value, projection.Found = projectOutput(path, doc)
+	return projection, nil
+}
+
+// ExtractRunOutputs projects the same paths from every run matching a
+// filter, one page at a time
+func (cp *ControlPlane) ExtractRunOutputs(ctx context.Context, orgID uuid.UUID, filter RunFilter, exprs []string) (*RunOutputExtract, error) {
+	paths, err := parseExtractPaths(exprs)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := cp.ListRuns(ctx, orgID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	extract := &RunOutputExtract{
+		Paths:      make([]string, 0, len(paths)),
+		Rows:       make([]RunOutputRow, 0, len(page.Runs)),
+		NextCursor: page.NextCursor,
+	}
+	for _, path := range paths {
+		extract.Paths = append(extract.Paths, path.String())
+	}
+
+	for i := range page.Runs {
+		run := &page.Runs[i]
+		doc, err := cp.runOutputDocument(ctx, run, paths)
+		if err != nil {
+			return nil, err
+		}
+		row := RunOutputRow{
+			RunID:        run.ID,
+			WorkflowName: run.WorkflowName,
+			Status:       run.Status,
+			CreatedAt:    run.CreatedAt,
+			Values:       make(map[string]interface{}, len(paths)),
+		}
+		for _, path := range paths {
+			row.Values[path.String()], _ = projectOutput(path, doc)
+		}
+		extract.Rows = append(extract.Rows, row)
+	}
+	return extract, nil
+}
+
+func parseExtractPaths(exprs []string) ([]*JSONPath, error) {
+	if len(exprs) == 0 {
+		return nil, fmt.Errorf("at least one path is required")
+	}
+	if len(exprs) > maxExtractPaths {
+		return nil, fmt.Errorf("at most %d paths can be extracted at once", maxExtractPaths)
+	}
+	paths := make([]*JSONPath, 0, len(exprs))
+	for _, expr := range exprs {
+		path, err := ParseJSONPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// projectOutput applies a path to an output document
+func projectOutput(path *JSONPath, doc map[string]interface{}) (interface{}, bool) {
+	matches := path.Select(doc)
+	if path.Definite() {
+		if len(matches) == 0 {
+			return nil, false
+		}
+		return matches[0], true
+	}
+	return matches, len(matches) > 0
+}
+
+// runOutputDocument assembles the document paths are evaluated against.
+// Outputs are only loaded when a path can reach them.
+func (cp *ControlPlane) runOutputDocument(ctx context.Context, run *WorkflowRun, paths []*JSONPath) (map[string]interface{}, error) {
+	doc := map[string]interface{}{"output": nil}
+
+	needsOutput, needsSteps := false, false
+	for _, path := range paths {
+		root := path.root()
+		if root == "" || root == "output" {
+			needsOutput = true
+		}
+		if root == "" || root == "steps" {
+			needsSteps = true
+		}
+	}
+	if needsOutput {
+		output, err := cp.runOutput(ctx, run)
+		if err != nil {
+			return nil, err
+		}
+		doc["output"] = output
+	}
+	if !needsSteps {
+		return doc, nil
+	}
+
+	steps, err := cp.stepOutputs(ctx, run.OrgID, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	doc["steps"] = steps
+	return doc, nil
+}
+
+// stepOutputs loads the output of the latest attempt of each step
+func (cp *ControlPlane) stepOutputs(ctx context.Context, orgID, runID uuid.UUID) (map[string]interface{}, error) {
+	query := `SELECT DISTINCT ON (node_id) node_id, COALESCE(output_ref, '')
+			  FROM step_run WHERE workflow_run_id = $1
+			  ORDER BY node_id, attempt DESC`
+	rows, err := cp.db.QueryContext(ctx, query, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query step outputs: %w", err)
+	}
+	defer rows.Close()
+
+	refs := make(map[string]string)
+	for rows.Next() {
+		var nodeID, ref string
+		if err := rows.Scan(&nodeID, &ref); err != nil {
+			return nil, fmt.Errorf("failed to scan step output: %w", err)
+		}
+		refs[nodeID] = ref
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read step outputs: %w", err)
+	}
+
+	outputs := make(map[string]interface{}, len(refs))
+	for nodeID, ref := range refs {
+		if !strings.HasPrefix(ref, "sha256:") {
+			outputs[nodeID] = nil
+			continue
+		}
+		blob, err := cp.blobs.Get(ctx, orgID, ref)
+		if errors.Is(err, db.ErrBlobNotFound) {
+			outputs[nodeID] = nil
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load output of step %s: %w", nodeID, err)
+		}
+		var output interface{}
+		if err := json.Unmarshal(blob.Content, &output); err != nil {
+			return nil, fmt.Errorf("failed to decode output of step %s: %w", nodeID, err)
+		}
+		outputs[nodeID] = output
+	}
+	return outputs, nil
+}
@@ -0,0 +1,244 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// tenantPausedRunsKey holds the IDs of paused runs, whose tasks the
+	// dispatcher parks instead of publishing
+	tenantPausedRunsKey = "tasks:paused_runs"
+	// tenantParkedPrefix keys the queue entries parked for each paused run
+	tenantParkedPrefix = "tasks:parked:"
+)
+
+// parkTaskScript sets aside a popped task while its run is still paused and
+// frees the dispatch slot the pop took. It returns 0, leaving the task to be
+// published, when the run was resumed since the dispatcher read the paused runs.
+var parkTaskScript = redis.NewScript(`
+	if redis.call('SISMEMBER', KEYS[1], ARGV[1]) == 0 then
+		return 0
+	end
+	redis.call('RPUSH', KEYS[2], ARGV[2])
+	for i = 3, #KEYS do
+		redis.call('ZREM', KEYS[i], ARGV[3])
+	end
+	return 1
+`)
+
+// RunPause records why and when a run was paused
+type RunPause struct {
+	PausedAt     time.Time      `json:"paused_at"`
+	Reason       string         `json:"reason,omitempty"`
+	ResumeStatus WorkflowStatus `json:"resume_status,omitempty"` // Status the run returns to
+}
+
+// RunResume reports a resumed run and how many parked tasks went back on
+// the queue
+type RunResume struct {
+	RunID        uuid.UUID     `json:"run_id"`
+	PausedFor    time.Duration `json:"paused_for"`
+	ResumedTasks int           `json:"resumed_tasks"`
+}
+
+// runPause returns the pause recorded in a run's metadata, if any
+func runPause(metadata map[string]interface{}) *RunPause {
+	raw, ok := metadata["pause"]
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var pause RunPause
+	if err := json.Unmarshal(data, &pause); err != nil {
+		return nil
+	}
+	return &pause
+}
+
+// PauseRun makes the dispatcher park the run's ready tasks, reporting
+// whether the run was not paused already
+func (q *TenantQueue) PauseRun(ctx context.Context, runID uuid.UUID) (bool, error) {
+	added, err := q.redis.SAdd(ctx, tenantPausedRunsKey, runID.String()).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to pause run dispatch: %w", err)
+	}
+	return added == 1, nil
+}
+
+// ResumeRun puts a run's parked tasks back at the head of their org's queues,
+// in the order they were parked, and returns how many there were. The hold is
+// lifted first, so nothing is parked while the tasks are moved.
+func (q *TenantQueue) ResumeRun(ctx context.Context, runID uuid.UUID) (int, error) {
+	if err := q.redis.SRem(ctx, tenantPausedRunsKey, runID.String()).Err(); err != nil {
+		return 0, fmt.Errorf("failed to resume run dispatch: %w", err)
+	}
+
+	// Moving from the tail to the head of the queue keeps the parked order
+	parkedKey := tenantParkedPrefix + runID.String()
+	resumed := 0
+	for {
+		entry, err := q.redis.RPop(ctx, parkedKey).Result()
+		if err == redis.Nil {
+			return resumed, nil
+		}
+		if err != nil {
+			return resumed, fmt.Errorf("failed to read parked tasks: %w", err)
+		}
+		queued, err := decodeQueueEntry(entry)
+		if err != nil {
+			log.Printf("Dropping malformed parked task of run %s: %v", runID, err)
+			continue
+		}
+
+		lane := q.lane(queued.Task.Lane).Name
+		org := queued.Task.OrgID.String()
+		vtime, _ := q.redis.Get(ctx, laneKey(lane, "vtime")).Float64()
+		pipe := q.redis.TxPipeline()
+		pipe.LPush(ctx, laneKey(lane, "tenant:"+org), entry)
+		pipe.ZAddNX(ctx, laneKey(lane, "tenants"), redis.Z{Score: vtime, Member: org})
+		if _, err := pipe.Exec(ctx); err != nil {
+			return resumed, fmt.Errorf("failed to requeue parked task %s: %w", queued.Task.ID, err)
+		}
+		resumed++
+	}
+}
+
+// DiscardRun drops a run's parked tasks, for a paused run that is canceled
+func (q *TenantQueue) DiscardRun(ctx context.Context, runID uuid.UUID) error {
+	pipe := q.redis.TxPipeline()
+	pipe.SRem(ctx, tenantPausedRunsKey, runID.String())
+	pipe.Del(ctx, tenantParkedPrefix+runID.String())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to discard parked tasks: %w", err)
+	}
+	return nil
+}
+
+// pausedRuns returns the IDs of paused runs
+func (q *TenantQueue) pausedRuns(ctx context.Context) (map[string]bool, error) {
+	members, err := q.redis.SMembers(ctx, tenantPausedRunsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	paused := make(map[string]bool, len(members))
+	for _, member := range members {
+		paused[member] = true
+	}
+	return paused, nil
+}
+
+// park sets aside a popped task of a paused run, reporting false when the
+// run is no longer paused and the task should be published
+func (q *TenantQueue) park(ctx context.Context, lane, org string, task *Task, entry string) (bool, error) {
+	keys := []string{tenantPausedRunsKey, tenantParkedPrefix + task.RunID.String(),
+		tenantInFlightKey, laneKey(lane, "inflight"), tenantOrgInFlightPrefix + org}
+	parked, err := parkTaskScript.Run(ctx, q.redis, keys, task.RunID.String(), entry, task.ID.String()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to park task: %w", err)
+	}
+	return parked == 1, nil
+}
+
+// PauseWorkflowRun halts a queued or running run. Steps already executing
+// finish and record their results; steps that become ready are held until
+// the run resumes, so no step state is lost.
+func (cp *ControlPlane) PauseWorkflowRun(ctx context.Context, runID uuid.UUID, reason string) (*RunPause, error) {
+	pause := &RunPause{PausedAt: time.Now(), Reason: reason}
+	pauseJSON, err := json.Marshal(pause)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pause: %w", err)
+	}
+
+	// Hold dispatch first, so no task slips out between the update and the hold
+	held, err := cp.queue.PauseRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	query := `UPDATE workflow_run r SET status = 'paused',
+			  metadata = jsonb_set(COALESCE(r.metadata, '{}'), '{pause}', $2::jsonb || jsonb_build_object('resume_status', prev.status))
+			  FROM (SELECT id, status FROM workflow_run WHERE id = $1 FOR UPDATE) prev
+			  WHERE r.id = prev.id AND prev.status IN ('queued', 'running')
+			  RETURNING prev.status`
+	var status string
+	err = cp.db.QueryRowContext(ctx, query, runID, pauseJSON).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = fmt.Errorf("workflow run not found or not in pausable state")
+	}
+	if err != nil {
+		// Only lift a hold this call placed; an already paused run stays held
+		if held {
+			if _, resumeErr := cp.queue.ResumeRun(ctx, runID); resumeErr != nil {
+				log.Printf("Failed to release dispatch hold of run %s: %v", runID, resumeErr)
+			}
+		}
+		return nil, fmt.Errorf("failed to pause workflow run: %w", err)
+	}
+	pause.ResumeStatus = WorkflowStatus(status)
+
+	cp.publishRunSignal(runID, "pause")
+	log.Printf("Paused workflow run %s: %s", runID, reason)
+	return pause, nil
+}
+
+// ResumeWorkflowRun continues a paused run from the steps that became ready
+// while it was paused
+func (cp *ControlPlane) ResumeWorkflowRun(ctx context.Context, runID uuid.UUID) (*RunResume, error) {
+	run, err := cp.GetWorkflowRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status != WorkflowStatusPaused {
+		return nil, fmt.Errorf("workflow run %s is %s, not paused", runID, run.Status)
+	}
+
+	status := WorkflowStatusRunning
+	pause := runPause(run.Metadata)
+	if pause != nil && pause.ResumeStatus != "" {
+		status = pause.ResumeStatus
+	}
+	query := `UPDATE workflow_run SET status = $2, metadata = metadata - 'pause'
+			  WHERE id = $1 AND status = 'paused'`
+	result, err := cp.db.ExecContext(ctx, query, runID, string(status))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume workflow run: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("workflow run %s was resumed or canceled concurrently", runID)
+	}
+
+	resumed, err := cp.queue.ResumeRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	resume := &RunResume{RunID: runID, ResumedTasks: resumed}
+	if pause != nil {
+		resume.PausedFor = time.Since(pause.PausedAt)
+	}
+	cp.publishRunSignal(runID, "resume")
+	log.Printf("Resumed workflow run %s with %d parked tasks", runID, resumed)
+	return resume, nil
+}
+
+// publishRunSignal tells workers about a change to a run's lifecycle
+func (cp *ControlPlane) publishRunSignal(runID uuid.UUID, action string) {
+	msgData, _ := json.Marshal(map[string]interface{}{
+		"run_id": runID.String(),
+		"action": action,
+	})
+	if _, err := cp.js.Publish("agentflow.signals", msgData); err != nil {
+		log.Printf("Failed to send %s signal: %v", action, err)
+	}
+}
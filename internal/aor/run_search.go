@@ -0,0 +1,346 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// runFilterFields are the fields a run filter expression can reference
+var runFilterFields = map[string]db.FilterField{
+	"status":     {Column: "r.status", Type: db.FilterString},
+	"workflow":   {Column: "s.name", Type: db.FilterString},
+	"cost_cents": {Column: "r.cost_cents", Type: db.FilterInt},
+	"created_at": {Column: "r.created_at", Type: db.FilterTime},
+	"started_at": {Column: "r.started_at", Type: db.FilterTime},
+	"ended_at":   {Column: "r.ended_at", Type: db.FilterTime},
+}
+
+// RunFilter selects workflow runs; zero-valued fields are ignored
+type RunFilter struct {
+	Status       WorkflowStatus    `json:"status,omitempty"`
+	WorkflowName string            `json:"workflow_name,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Since        *time.Time        `json:"since,omitempty"`
+	Until        *time.Time        `json:"until,omitempty"`
+	MinCostCents int64             `json:"min_cost_cents,omitempty"`
+	Expr         string            `json:"filter,omitempty"` // Filter expression, e.g. status=failed,cost_cents>=100
+	Cursor       string            `json:"cursor,omitempty"`
+	Limit        int               `json:"limit,omitempty"`
+}
+
+// RunPage is one page of runs, newest first
+type RunPage struct {
+	Runs []WorkflowRun `json:"runs"`
+	db.PageMeta
+}
+
+// SavedRunFilter is a named filter stored for a user
+type SavedRunFilter struct {
+	ID        uuid.UUID `json:"id"`
+	OrgID     uuid.UUID `json:"org_id"`
+	Owner     string    `json:"owner"`
+	Name      string    `json:"name"`
+	Filter    RunFilter `json:"filter"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListRuns returns an org's runs matching a filter using keyset pagination
+func (cp *ControlPlane) ListRuns(ctx context.Context, orgID uuid.UUID, filter RunFilter) (*RunPage, error) {
+	query, args, limit, err := buildRunQuery(orgID, filter)
+	if err != nil {
+		return nil, err
+	}
+	conditions, countArgs, err := runConditions(orgID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := cp.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow runs: %w", err)
+	}
+	defer rows.Close()
+
+	page := &RunPage{Runs: make([]WorkflowRun, 0, limit)}
+	for rows.Next() {
+		var run WorkflowRun
+		var startedAt sql.NullTime
+		var metadataJSON, tagsJSON []byte
+
+		if err := rows.Scan(
+			&run.ID, &run.WorkflowSpecID, &run.WorkflowName, &run.OrgID, &run.Status, &startedAt, &run.EndedAt,
+			&run.CostCents, &metadataJSON, &tagsJSON, &run.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan workflow run: %w", err)
+		}
+		if startedAt.Valid {
+			run.StartedAt = startedAt.Time
+		}
+		if err := json.Unmarshal(metadataJSON, &run.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+		if err := json.Unmarshal(tagsJSON, &run.Tags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+		}
+
+		page.Runs = append(page.Runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list workflow runs: %w", err)
+	}
+
+	// One extra row is fetched to learn whether another page exists
+	if len(page.Runs) > limit {
+		page.Runs = page.Runs[:limit]
+		last := page.Runs[limit-1]
+		page.NextCursor = db.EncodeCursor(last.CreatedAt, last.ID)
+	}
+
+	page.TotalEstimate, err = cp.db.EstimateTotal(ctx, `SELECT 1 FROM workflow_run r JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE `+strings.Join(conditions, " AND "), countArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// SetRunTags merges tags into a run; an empty value removes the tag
+func (cp *ControlPlane) SetRunTags(ctx context.Context, runID uuid.UUID, tags map[string]string) (map[string]string, error) {
+	var tagsJSON []byte
+	err := cp.db.QueryRowContext(ctx, `SELECT tags FROM workflow_run WHERE id = $1`, runID).Scan(&tagsJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get run tags: %w", err)
+	}
+
+	merged := make(map[string]string)
+	if err := json.Unmarshal(tagsJSON, &merged); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+	}
+	for key, value := range tags {
+		if value == "" {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = value
+	}
+
+	updated, err := json.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tags: %w", err)
+	}
+	if _, err := cp.db.ExecContext(ctx, `UPDATE workflow_run SET tags = $1 WHERE id = $2`, updated, runID); err != nil {
+		return nil, fmt.Errorf("failed to update run tags: %w", err)
+	}
+
+	return merged, nil
+}
+
+// SaveRunFilter creates or replaces a user's named filter
+func (cp *ControlPlane) SaveRunFilter(ctx context.Context, saved *SavedRunFilter) error {
+	if saved.Name == "" || saved.Owner == "" {
+		return fmt.Errorf("saved filter requires a name and owner")
+	}
+	if _, err := buildRunFilterClauses(saved.Filter); err != nil {
+		return err
+	}
+
+	// Cursors are positions in a specific listing, not part of a reusable filter
+	saved.Filter.Cursor = ""
+	filterJSON, err := json.Marshal(saved.Filter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal filter: %w", err)
+	}
+
+	if saved.ID == uuid.Nil {
+		saved.ID = uuid.New()
+	}
+	now := time.Now()
+	saved.UpdatedAt = now
+
+	query := `INSERT INTO saved_run_filter (id, org_id, owner, name, filter, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $6)
+			  ON CONFLICT (org_id, owner, name) DO UPDATE SET filter = EXCLUDED.filter, updated_at = EXCLUDED.updated_at
+			  RETURNING id, created_at`
+
+	err = cp.db.QueryRowContext(ctx, query, saved.ID, saved.OrgID, saved.Owner, saved.Name, filterJSON, now).
+		Scan(&saved.ID, &saved.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save run filter: %w", err)
+	}
+
+	return nil
+}
+
+// ListRunFilters returns a user's saved filters
+func (cp *ControlPlane) ListRunFilters(ctx context.Context, orgID uuid.UUID, owner string) ([]SavedRunFilter, error) {
+	query := `SELECT id, org_id, owner, name, filter, created_at, updated_at
+			  FROM saved_run_filter WHERE org_id = $1 AND owner = $2 ORDER BY name`
+
+	rows, err := cp.db.QueryContext(ctx, query, orgID, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list run filters: %w", err)
+	}
+	defer rows.Close()
+
+	filters := make([]SavedRunFilter, 0)
+	for rows.Next() {
+		var saved SavedRunFilter
+		var filterJSON []byte
+		if err := rows.Scan(&saved.ID, &saved.OrgID, &saved.Owner, &saved.Name, &filterJSON, &saved.CreatedAt, &saved.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan run filter: %w", err)
+		}
+		if err := json.Unmarshal(filterJSON, &saved.Filter); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal filter: %w", err)
+		}
+		filters = append(filters, saved)
+	}
+
+	return filters, rows.Err()
+}
+
+// ListRunsWithSavedFilter runs a saved filter, applying a cursor and limit for paging
+func (cp *ControlPlane) ListRunsWithSavedFilter(ctx context.Context, orgID uuid.UUID, owner, name, cursor string, limit int) (*RunPage, error) {
+	var filterJSON []byte
+	query := `SELECT filter FROM saved_run_filter WHERE org_id = $1 AND owner = $2 AND name = $3`
+	if err := cp.db.QueryRowContext(ctx, query, orgID, owner, name).Scan(&filterJSON); err != nil {
+		return nil, fmt.Errorf("failed to get run filter %s: %w", name, err)
+	}
+
+	var filter RunFilter
+	if err := json.Unmarshal(filterJSON, &filter); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal filter: %w", err)
+	}
+	filter.Cursor = cursor
+	if limit > 0 {
+		filter.Limit = limit
+	}
+
+	return cp.ListRuns(ctx, orgID, filter)
+}
+
+// DeleteRunFilter removes a user's saved filter
+func (cp *ControlPlane) DeleteRunFilter(ctx context.Context, orgID uuid.UUID, owner, name string) error {
+	result, err := cp.db.ExecContext(ctx, `DELETE FROM saved_run_filter WHERE org_id = $1 AND owner = $2 AND name = $3`, orgID, owner, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete run filter: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("run filter %s not found", name)
+	}
+	return nil
+}
+
+// buildRunQuery renders the list query for a filter; it returns the
+// effective page size and fetches one extra row to detect the next page
+func buildRunQuery(orgID uuid.UUID, filter RunFilter) (string, []interface{}, int, error) {
+	conditions, args, err := runConditions(orgID, filter)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	limit := db.ListOptions{Limit: filter.Limit}.PageSize()
+
+	if filter.Cursor != "" {
+		createdAt, id, err := db.DecodeCursor(filter.Cursor)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		args = append(args, createdAt, id)
+		conditions = append(conditions, fmt.Sprintf("(r.created_at, r.id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	args = append(args, limit+1)
+	query := `SELECT r.id, r.workflow_spec_id, s.name, s.org_id, r.status, r.started_at, r.ended_at,
+			  r.cost_cents, r.metadata, r.tags, r.created_at
+			  FROM workflow_run r JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE ` + strings.Join(conditions, " AND ") + `
+			  ORDER BY r.created_at DESC, r.id DESC
+			  LIMIT $` + strconv.Itoa(len(args))
+
+	return query, args, limit, nil
+}
+
+// runConditions returns the WHERE conditions selecting an org's runs that
+// match a filter, regardless of page
+func runConditions(orgID uuid.UUID, filter RunFilter) ([]string, []interface{}, error) {
+	clauses, err := buildRunFilterClauses(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	exprClauses, err := db.ParseFilter(filter.Expr, runFilterFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, clause := range exprClauses {
+		clauses = append(clauses, runFilterClause{clause.SQL, clause.Arg})
+	}
+
+	args := []interface{}{orgID}
+	conditions := []string{"s.org_id = $1"}
+	for _, clause := range clauses {
+		args = append(args, clause.arg)
+		conditions = append(conditions, strings.ReplaceAll(clause.sql, "?", "$"+strconv.Itoa(len(args))))
+	}
+	return conditions, args, nil
+}
+
+type runFilterClause struct {
+	sql string
+	arg interface{}
+}
+
+// buildRunFilterClauses validates a filter and returns its conditions with
+// "?" standing in for the positional parameter
+func buildRunFilterClauses(filter RunFilter) ([]runFilterClause, error) {
+	clauses := make([]runFilterClause, 0)
+
+	if filter.Status != "" {
+		if !validRunStatus(filter.Status) {
+			return nil, fmt.Errorf("invalid run status: %s", filter.Status)
+		}
+		clauses = append(clauses, runFilterClause{"r.status = ?", string(filter.Status)})
+	}
+	if filter.WorkflowName != "" {
+		clauses = append(clauses, runFilterClause{"s.name = ?", filter.WorkflowName})
+	}
+	if len(filter.Tags) > 0 {
+		tagsJSON, err := json.Marshal(filter.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal tag filter: %w", err)
+		}
+		clauses = append(clauses, runFilterClause{"r.tags @> ?::jsonb", string(tagsJSON)})
+	}
+	if filter.Since != nil {
+		clauses = append(clauses, runFilterClause{"r.created_at >= ?", *filter.Since})
+	}
+	if filter.Until != nil {
+		clauses = append(clauses, runFilterClause{"r.created_at < ?", *filter.Until})
+	}
+	if filter.Since != nil && filter.Until != nil && !filter.Until.After(*filter.Since) {
+		return nil, fmt.Errorf("invalid time range: until must be after since")
+	}
+	if filter.MinCostCents > 0 {
+		clauses = append(clauses, runFilterClause{"r.cost_cents >= ?", filter.MinCostCents})
+	}
+
+	return clauses, nil
+}
+
+func validRunStatus(status WorkflowStatus) bool {
+	switch status {
+	case WorkflowStatusPending, WorkflowStatusRunning, WorkflowStatusCompleted, WorkflowStatusFailed, WorkflowStatusCancelled,
+		WorkflowStatusPaused, WorkflowStatusBudgetExceeded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/google/uuid"
+)
+
+// EgressMode is a sandbox's default for outbound connections
+type EgressMode string
+
+const (
+	EgressDeny  EgressMode = "deny"
+	EgressAllow EgressMode = "allow"
+)
+
+// sandboxWorkDir is the working directory relative paths resolve against,
+// and the only writable path by default
+const sandboxWorkDir = "/tmp"
+
+// SandboxPolicy isolates a script step, configured as
+// sandbox: {network: {egress: deny, allow: ["api.github.com:443", "*.corp.example"]},
+// filesystem: {read_only: true, writable: ["/tmp"]}}. Steps without a sandbox
+// block get deny-all egress and a read-only filesystem apart from /tmp.
+type SandboxPolicy struct {
+	Egress     EgressMode `json:"egress"`
+	AllowHosts []string   `json:"allow_hosts,omitempty"`
+	ReadOnly   bool       `json:"read_only"`
+	Writable   []string   `json:"writable,omitempty"`
+}
+
+// SandboxViolationError fails a step that tried to act outside its sandbox.
+// It is not retried, since the same code would be blocked again.
+type SandboxViolationError struct {
+	Kind   GuardrailKind `json:"kind"`
+	Target string        `json:"target"`
+}
+
+func (e *SandboxViolationError) Error() string {
+	switch e.Kind {
+	case GuardrailFilesystemWrite:
+		return fmt.Sprintf("sandbox blocked write to %s", e.Target)
+	default:
+		return fmt.Sprintf("sandbox blocked network egress to %s", e.Target)
+	}
+}
+
+// ErrorClass reports sandbox violations as policy blocks
+func (e *SandboxViolationError) ErrorClass() cas.ErrorClass {
+	return cas.ErrorClassPolicyBlock
+}
+
+// sandboxedStep reports whether a step type runs user code in a sandbox
+func sandboxedStep(stepType string) bool {
+	switch ExecutorType(stepType) {
+	case ExecutorTypeScript, ExecutorTypeWASM:
+		return true
+	}
+	return false
+}
+
+// parseSandboxPolicy reads a step's sandbox config over the deny-all defaults
+func parseSandboxPolicy(config map[string]interface{}) (*SandboxPolicy, error) {
+	policy := &SandboxPolicy{Egress: EgressDeny, ReadOnly: true, Writable: []string{sandboxWorkDir}}
+
+	raw, ok := config["sandbox"]
+	if !ok {
+		return policy, nil
+	}
+	sandbox, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("sandbox must be a map")
+	}
+
+	if network, ok := sandbox["network"].(map[string]interface{}); ok {
+		if egress, ok := network["egress"].(string); ok {
+			switch EgressMode(egress) {
+			case EgressDeny, EgressAllow:
+				policy.Egress = EgressMode(egress)
+			default:
+				return nil, fmt.Errorf("invalid egress %q: use deny or allow", egress)
+			}
+		}
+		hosts, err := stringList(network["allow"])
+		if err != nil {
+			return nil, fmt.Errorf("network.allow: %w", err)
+		}
+		for _, host := range hosts {
+			if host == "" || strings.Contains(host, "/") {
+				return nil, fmt.Errorf("invalid allowed host %q: use host, host:port or *.domain", host)
+			}
+			policy.AllowHosts = append(policy.AllowHosts, strings.ToLower(host))
+		}
+	} else if _, ok := sandbox["network"]; ok {
+		return nil, fmt.Errorf("sandbox.network must be a map")
+	}
+
+	if fs, ok := sandbox["filesystem"].(map[string]interface{}); ok {
+		if readOnly, ok := fs["read_only"].(bool); ok {
+			policy.ReadOnly = readOnly
+		}
+		if _, ok := fs["writable"]; ok {
+			paths, err := stringList(fs["writable"])
+			if err != nil {
+				return nil, fmt.Errorf("filesystem.writable: %w", err)
+			}
+			policy.Writable = policy.Writable[:0]
+			for _, path := range paths {
+				if !filepath.IsAbs(path) {
+					return nil, fmt.Errorf("writable path %q must be absolute", path)
+				}
+				policy.Writable = append(policy.Writable, filepath.Clean(path))
+			}
+		}
+	} else if _, ok := sandbox["filesystem"]; ok {
+		return nil, fmt.Errorf("sandbox.filesystem must be a map")
+	}
+
+	return policy, nil
+}
+
+func stringList(raw interface{}) ([]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be a list")
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("entries must be strings")
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+// allowsEgress reports whether a host:port address may be reached. Allowlist
+// entries match a host on any port, a host:port exactly, or *.domain for
+// any subdomain.
+func (p *SandboxPolicy) allowsEgress(addr string) bool {
+	if p.Egress == EgressAllow {
+		return true
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, entry := range p.AllowHosts {
+		entryHost, entryPort, err := net.SplitHostPort(entry)
+		if err != nil {
+			entryHost, entryPort = entry, ""
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(entryHost, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == entryHost {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsWrite reports whether a path may be written
+func (p *SandboxPolicy) allowsWrite(path string) bool {
+	if !p.ReadOnly {
+		return true
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(sandboxWorkDir, path)
+	}
+	path = filepath.Clean(path)
+	for _, dir := range p.Writable {
+		if path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Sandbox enforces a step's policy while its code runs and collects the
+// actions it blocked
+type Sandbox struct {
+	policy *SandboxPolicy
+	dialer net.Dialer
+
+	mu         sync.Mutex
+	violations []SandboxViolationError
+}
+
+func NewSandbox(policy *SandboxPolicy) *Sandbox {
+	return &Sandbox{policy: policy, dialer: net.Dialer{Timeout: 30 * time.Second}}
+}
+
+// DialContext opens outbound connections for sandboxed code, refusing any
+// address outside the allowlist
+func (s *Sandbox) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if !s.policy.allowsEgress(addr) {
+		return nil, s.block(GuardrailNetworkEgress, addr)
+	}
+	return s.dialer.DialContext(ctx, network, addr)
+}
+
+// CheckWrite refuses writes outside the writable paths
+func (s *Sandbox) CheckWrite(path string) error {
+	if !s.policy.allowsWrite(path) {
+		return s.block(GuardrailFilesystemWrite, path)
+	}
+	return nil
+}
+
+func (s *Sandbox) block(kind GuardrailKind, target string) error {
+	violation := SandboxViolationError{Kind: kind, Target: target}
+	s.mu.Lock()
+	s.violations = append(s.violations, violation)
+	s.mu.Unlock()
+	sandboxViolations.Inc(string(kind))
+	return &violation
+}
+
+// Violations returns the actions blocked so far
+func (s *Sandbox) Violations() []SandboxViolationError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SandboxViolationError(nil), s.violations...)
+}
+
+// enforceSandbox records a step's blocked actions as guardrail events and
+// fails the step if there were any, even when its code handled the refusal
+func (w *Worker) enforceSandbox(ctx context.Context, task *Task, sandbox *Sandbox) error {
+	violations := sandbox.Violations()
+	if len(violations) == 0 {
+		return nil
+	}
+
+	events := make([]GuardrailEvent, 0, len(violations))
+	for _, v := range violations {
+		log.Printf("Sandbox blocked %s to %s in step %s of run %s", v.Kind, v.Target, task.NodeID, task.RunID)
+		events = append(events, GuardrailEvent{
+			ID:        uuid.New(),
+			OrgID:     task.OrgID,
+			RunID:     task.RunID,
+			StepID:    task.NodeID,
+			Kind:      v.Kind,
+			Target:    v.Target,
+			Action:    "blocked",
+			CreatedAt: time.Now(),
+		})
+	}
+	if w.db != nil {
+		if err := saveGuardrailEvents(ctx, w.db, events); err != nil {
+			log.Printf("Failed to record guardrail events for run %s: %v", task.RunID, err)
+		}
+	}
+	return &violations[0]
+}
@@ -2,7 +2,6 @@ package aor
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"log"
@@ -14,23 +13,35 @@ import (
 )
 
 type Scheduler struct {
-	db    *db.PostgresDB
-	redis *redis.Client
-	nats  *nats.Conn
-	js    nats.JetStreamContext
+	db        *db.PostgresDB
+	redis     *redis.Client
+	nats      *nats.Conn
+	js        nats.JetStreamContext
+	queue     *TenantQueue
+	stepCache *StepCache
+
+	// releaseRun frees the concurrency slot of a run the scheduler ends
+	releaseRun func(ctx context.Context, runID uuid.UUID) error
+	// onRunFailure tells users about a run the scheduler failed
+	onRunFailure func(ctx context.Context, run *WorkflowRun, failure *RunFailure)
+	// timeouts tunes step timeouts from history; nil uses configured timeouts
+	timeouts *TimeoutTuner
 }
 
-func NewScheduler(pgDB *db.PostgresDB, redisClient *redis.Client, natsConn *nats.Conn, js nats.JetStreamContext) *Scheduler {
+func NewScheduler(pgDB *db.PostgresDB, redisClient *redis.Client, natsConn *nats.Conn, js nats.JetStreamContext, queue *TenantQueue) *Scheduler {
 	return &Scheduler{
-		db:    pgDB,
-		redis: redisClient,
-		nats:  natsConn,
-		js:    js,
+		db:        pgDB,
+		redis:     redisClient,
+		nats:      natsConn,
+		js:        js,
+		queue:     queue,
+		stepCache: NewStepCache(redisClient),
 	}
 }
 
 func (s *Scheduler) ScheduleWorkflow(ctx context.Context, run *WorkflowRun) error {
 	log.Printf("Scheduling workflow run %s", run.ID)
+	defer schedulerLatency.ObserveDuration(time.Now(), "schedule")
 
 	// Get workflow spec
 	spec, err := s.getWorkflowSpec(ctx, run.WorkflowSpecID)
@@ -45,62 +56,130 @@ func (s *Scheduler) ScheduleWorkflow(ctx context.Context, run *WorkflowRun) erro
 			continue // Skip steps with dependencies for now
 		}
 
-		stepRun := &StepRun{
-			ID:            uuid.New().String(),
-			WorkflowRunID: run.ID,
-			NodeID:        step.ID,
-			StepID:        step.ID,
-			Attempt:       1,
-			Status:        StepStatusQueued,
-			CreatedAt:     time.Now(),
+		if err := s.enqueueStep(ctx, run, step); err != nil {
+			return err
 		}
+	}
 
-		if err := s.saveStepRun(ctx, stepRun); err != nil {
-			return fmt.Errorf("failed to save step run: %w", err)
-		}
+	return nil
+}
 
-		// Create and enqueue task
-		taskID, _ := uuid.Parse(stepRun.ID)
-		if taskID == uuid.Nil {
-			taskID = uuid.New()
-		}
+// enqueueStep creates a step run for a ready step and publishes its task
+func (s *Scheduler) enqueueStep(ctx context.Context, run *WorkflowRun, step Step) error {
+	// A run stopped over budget while its ready steps were enqueued takes no more
+	if run.Status == WorkflowStatusBudgetExceeded {
+		return nil
+	}
 
-		node := &Node{
-			ID:     step.ID,
-			Type:   step.Type,
-			Config: step.Config,
-		}
+	stepRun := &StepRun{
+		ID:            uuid.New().String(),
+		WorkflowRunID: run.ID,
+		NodeID:        step.ID,
+		StepID:        step.ID,
+		Attempt:       1,
+		Status:        StepStatusQueued,
+		CreatedAt:     time.Now(),
+	}
 
-		task := &Task{
-			ID:         taskID,
-			RunID:      run.ID,
-			StepID:     step.ID,
-			NodeID:     step.ID,
-			Type:       step.Type,
-			Attempt:    1,
-			Node:       node,
-			Inputs:     s.resolveInputs(ctx, run, node),
-			Priority:   1,
-			CreatedAt:  time.Now(),
-			DeadlineAt: &[]time.Time{time.Now().Add(30 * time.Minute)}[0],
-		}
+	if err := s.saveStepRun(ctx, stepRun); err != nil {
+		return fmt.Errorf("failed to save step run: %w", err)
+	}
+
+	// Create and enqueue task
+	taskID, _ := uuid.Parse(stepRun.ID)
+	if taskID == uuid.Nil {
+		taskID = uuid.New()
+	}
+
+	runEnv, _ := run.Metadata["env"].(map[string]interface{})
+	node := &Node{
+		ID:      step.ID,
+		Type:    step.Type,
+		Config:  pinStepConfig(stepConfigWithEnv(step.Config, runEnv), runReproducibility(run.Metadata), step.ID),
+		Policy:  stepNodePolicy(step),
+		Timeout: s.stepTimeout(ctx, run, step),
+	}
+
+	task := &Task{
+		ID:         taskID,
+		RunID:      run.ID,
+		OrgID:      run.OrgID,
+		Workflow:   run.WorkflowName,
+		StepID:     step.ID,
+		NodeID:     step.ID,
+		Type:       step.Type,
+		Attempt:    1,
+		Node:       node,
+		Inputs:     s.resolveInputs(ctx, run, node),
+		Priority:   1,
+		CreatedAt:  time.Now(),
+		DeadlineAt: &[]time.Time{time.Now().Add(30 * time.Minute)}[0],
+		ReplayOf:   replaySource(run.Metadata),
+		Labels:     run.Labels,
+		Lane:       runLane(run.Metadata),
+		Chaos:      runChaos(run.Metadata).ForStep(step.Type),
+		Turn:       runTurn(run.Metadata),
+		Placement:  step.Placement,
+		Submitter:  runSubmitter(run.Metadata),
+		Residency:  runResidency(run.Metadata),
+
+		MockProvider: runMockProvider(run.Metadata),
+	}
 
-		if err := s.enqueueTask(ctx, task); err != nil {
-			return fmt.Errorf("failed to enqueue task: %w", err)
+	// Follow-up conversation turns carry their own inputs and session memory
+	if turnInputs, ok := run.Metadata["turn_inputs"].(map[string]interface{}); ok {
+		task.Inputs = mergeOverrides(task.Inputs, turnInputs)
+	}
+
+	// Chaos runs neither reuse nor store outputs, so injected faults can't leak into other runs
+	if reuseSteps(run.Metadata) && task.Chaos == nil && stepCacheable(node) {
+		reused, err := s.reuseCachedStep(ctx, task)
+		if err != nil {
+			log.Printf("Step cache unavailable for task %s, executing: %v", task.ID, err)
+		}
+		if reused {
+			return nil
 		}
 	}
 
+	// Steps that would take the run over its budget are degraded or end the run
+	if dispatch, err := s.checkStepBudget(ctx, run, stepRun, node); err != nil || !dispatch {
+		return err
+	}
+
+	if err := s.enqueueTask(ctx, task); err != nil {
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
 	return nil
 }
 
 func (s *Scheduler) ProcessTaskResult(ctx context.Context, result *TaskResult) error {
 	log.Printf("Processing task result for task %s", result.TaskID)
+	defer schedulerLatency.ObserveDuration(time.Now(), "process_result")
 
 	// Update step run
 	if err := s.updateStepRun(ctx, result); err != nil {
 		return fmt.Errorf("failed to update step run: %w", err)
 	}
 
+	// Grow the running DAG with any nodes the step asked to spawn
+	if result.Status == TaskStatusSucceeded {
+		if directive, err := extractSpawnDirective(result.Output); err != nil {
+			return fmt.Errorf("invalid spawn directive from step %s: %w", result.NodeID, err)
+		} else if directive != nil {
+			if err := s.applySpawn(ctx, result.RunID, result.NodeID, directive); err != nil {
+				return fmt.Errorf("failed to spawn nodes from step %s: %w", result.NodeID, err)
+			}
+		}
+		// Assert steps leave out the branch their outcome didn't take
+		if branch, ok := result.Output["branch"].(string); ok {
+			if err := s.skipUntakenBranch(ctx, result.RunID, result.NodeID, branch); err != nil {
+				return fmt.Errorf("failed to branch after step %s: %w", result.NodeID, err)
+			}
+		}
+	}
+
 	// Check if workflow is complete
 	if err := s.checkWorkflowCompletion(ctx, result); err != nil {
 		return fmt.Errorf("failed to check workflow completion: %w", err)
@@ -155,18 +234,8 @@ func (s *Scheduler) resolveInputs(ctx context.Context, run *WorkflowRun, node *N
 func (s *Scheduler) enqueueTask(ctx context.Context, task *Task) error {
 	log.Printf("Enqueuing task %s", task.ID)
 
-	// Serialize task
-	taskData, err := json.Marshal(task)
-	if err != nil {
-		return fmt.Errorf("failed to marshal task: %w", err)
-	}
-
-	// Publish to NATS
-	if _, err := s.js.Publish("agentflow.tasks", taskData); err != nil {
-		return fmt.Errorf("failed to publish task: %w", err)
-	}
-
-	return nil
+	// Queue in the org's partition; the dispatcher publishes to NATS in fair order
+	return s.queue.Push(ctx, task)
 }
 
 func (s *Scheduler) updateStepRun(ctx context.Context, result *TaskResult) error {
@@ -175,9 +244,41 @@ func (s *Scheduler) updateStepRun(ctx context.Context, result *TaskResult) error
 	return nil
 }
 
+// checkWorkflowCompletion ends a run once none of its steps is queued or
+// running, and frees its concurrency slot. Conversational runs stay open
+// until their conversation closes.
 func (s *Scheduler) checkWorkflowCompletion(ctx context.Context, result *TaskResult) error {
 	log.Printf("Checking workflow completion for task %s", result.TaskID)
-	// Mock implementation
+	if result.RunID == uuid.Nil || result.Turn > 0 {
+		return nil
+	}
+
+	query := `UPDATE workflow_run r
+			  SET status = CASE WHEN EXISTS (SELECT 1 FROM step_run WHERE workflow_run_id = r.id AND status = 'failed')
+			                    THEN 'failed' ELSE 'succeeded' END,
+			      ended_at = NOW()
+			  WHERE r.id = $1 AND r.status = 'running'
+			  AND NOT COALESCE((r.metadata->>'conversation')::boolean, false)
+			  AND NOT EXISTS (SELECT 1 FROM step_run WHERE workflow_run_id = r.id AND status IN ('queued', 'running'))`
+	completed, err := s.db.ExecContext(ctx, query, result.RunID)
+	if err != nil {
+		return fmt.Errorf("failed to complete workflow run: %w", err)
+	}
+	if n, _ := completed.RowsAffected(); n == 0 {
+		return nil
+	}
+	return s.finishRun(ctx, result.RunID)
+}
+
+// finishRun frees the concurrency slot of a run that reached a terminal
+// state, so the next pending run can start
+func (s *Scheduler) finishRun(ctx context.Context, runID uuid.UUID) error {
+	if s.releaseRun == nil {
+		return nil
+	}
+	if err := s.releaseRun(ctx, runID); err != nil {
+		return fmt.Errorf("failed to release concurrency slot for run %s: %w", runID, err)
+	}
 	return nil
 }
 
@@ -238,6 +339,115 @@ func (s *Scheduler) RetryFailedStep(ctx context.Context, stepRunID string) error
 	return nil
 }
 
+// RetryStep creates a new attempt of a step with optional input and config
+// overrides; downstream steps resume once the new attempt succeeds
+func (s *Scheduler) RetryStep(ctx context.Context, run *WorkflowRun, stepID string, req *StepRetryRequest) (*StepRun, error) {
+	log.Printf("Manually retrying step %s of run %s", stepID, run.ID)
+
+	if req == nil {
+		req = &StepRetryRequest{}
+	}
+
+	spec, err := s.getWorkflowSpec(ctx, run.WorkflowSpecID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow spec: %w", err)
+	}
+
+	step := findStep(spec.DAG.Steps, stepID)
+	if step == nil {
+		return nil, fmt.Errorf("step %s not found in workflow %s", stepID, spec.Name)
+	}
+
+	previous, err := s.getLatestStepRun(ctx, run.ID, stepID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get step run: %w", err)
+	}
+	if previous.Status == StepStatusQueued || previous.Status == StepStatusRunning {
+		return nil, fmt.Errorf("step %s is still %s", stepID, previous.Status)
+	}
+
+	node := &Node{
+		ID:      step.ID,
+		Type:    step.Type,
+		Config:  mergeOverrides(pinStepConfig(step.Config, runReproducibility(run.Metadata), step.ID), req.Config),
+		Policy:  stepNodePolicy(*step),
+		Timeout: s.stepTimeout(ctx, run, *step),
+	}
+	inputs := mergeOverrides(s.resolveInputs(ctx, run, node), req.Inputs)
+
+	// A retry that doesn't fit the run's budget is refused, leaving the run as it is
+	if plan := s.planNodeBudget(ctx, run, node); plan != nil && plan.Action == StepBudgetExceeded {
+		return nil, fmt.Errorf("retry exceeds the run's budget: %s", plan.Message)
+	}
+
+	retryStepRun := &StepRun{
+		ID:            uuid.New().String(),
+		WorkflowRunID: run.ID,
+		NodeID:        step.ID,
+		StepID:        step.ID,
+		Attempt:       previous.Attempt + 1,
+		Status:        StepStatusQueued,
+		Input:         inputs,
+		RetryOf:       previous.ID,
+		Overrides:     req,
+		CreatedAt:     time.Now(),
+	}
+
+	if err := s.saveStepRun(ctx, retryStepRun); err != nil {
+		return nil, fmt.Errorf("failed to save retry step run: %w", err)
+	}
+
+	taskID, _ := uuid.Parse(retryStepRun.ID)
+	task := &Task{
+		ID:         taskID,
+		RunID:      run.ID,
+		OrgID:      run.OrgID,
+		Workflow:   run.WorkflowName,
+		StepID:     step.ID,
+		NodeID:     step.ID,
+		Type:       step.Type,
+		Attempt:    retryStepRun.Attempt,
+		Node:       node,
+		Inputs:     inputs,
+		Config:     node.Config,
+		Priority:   2, // Higher priority for retries
+		CreatedAt:  time.Now(),
+		DeadlineAt: &[]time.Time{time.Now().Add(30 * time.Minute)}[0],
+		ReplayOf:   replaySource(run.Metadata),
+		Labels:     run.Labels,
+		Lane:       runLane(run.Metadata),
+		Chaos:      runChaos(run.Metadata).ForStep(step.Type),
+		Placement:  step.Placement,
+		Submitter:  runSubmitter(run.Metadata),
+		Residency:  runResidency(run.Metadata),
+
+		MockProvider: runMockProvider(run.Metadata),
+	}
+
+	if err := s.enqueueTask(ctx, task); err != nil {
+		return nil, fmt.Errorf("failed to enqueue retry task: %w", err)
+	}
+
+	if downstream := downstreamSteps(stepID, spec.DAG.Edges); len(downstream) > 0 {
+		log.Printf("Steps %v will resume after step %s succeeds", downstream, stepID)
+	}
+
+	return retryStepRun, nil
+}
+
+func (s *Scheduler) getLatestStepRun(ctx context.Context, runID uuid.UUID, stepID string) (*StepRun, error) {
+	// Mock implementation - in production would select the highest attempt from step_run
+	return &StepRun{
+		ID:            uuid.New().String(),
+		WorkflowRunID: runID,
+		NodeID:        stepID,
+		StepID:        stepID,
+		Attempt:       1,
+		Status:        StepStatusFailed,
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
 func (s *Scheduler) getStepRun(ctx context.Context, stepRunID string) (*StepRun, error) {
 	// Mock implementation
 	runID := uuid.New()
@@ -264,3 +474,54 @@ func (s *Scheduler) GetWorkflowStatus(ctx context.Context, runID uuid.UUID) (*Wo
 		Steps:          []StepRun{},
 	}, nil
 }
+
+// stepTimeout returns the per-attempt timeout a step is dispatched with
+func (s *Scheduler) stepTimeout(ctx context.Context, run *WorkflowRun, step Step) time.Duration {
+	if s.timeouts == nil {
+		return step.Timeout
+	}
+	return s.timeouts.Timeout(ctx, run.OrgID, run.WorkflowName, step)
+}
+
+// findStep returns the step with the given ID, or nil
+func findStep(steps []Step, stepID string) *Step {
+	for i := range steps {
+		if steps[i].ID == stepID {
+			return &steps[i]
+		}
+	}
+	return nil
+}
+
+// downstreamSteps returns every step transitively reachable from stepID
+func downstreamSteps(stepID string, edges []Edge) []string {
+	visited := map[string]bool{stepID: true}
+	queue := []string{stepID}
+	result := make([]string, 0)
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, edge := range edges {
+			if edge.From == current && !visited[edge.To] {
+				visited[edge.To] = true
+				result = append(result, edge.To)
+				queue = append(queue, edge.To)
+			}
+		}
+	}
+
+	return result
+}
+
+// mergeOverrides returns a copy of base with overrides applied on top
+func mergeOverrides(base, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
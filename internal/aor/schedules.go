@@ -0,0 +1,470 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// scheduleTickInterval is how often the control plane looks for due schedules
+	scheduleTickInterval = 30 * time.Second
+	// scheduleGrace is how late a run may start and still count as on time
+	// under the skip policy
+	scheduleGrace = 2 * time.Minute
+	// maxScheduleCatchup caps the missed runs the all policy starts at once
+	maxScheduleCatchup = 10
+	// maxScheduleLookahead bounds the search for a schedule's next run
+	maxScheduleLookahead = 366 * 24 * time.Hour
+)
+
+var scheduleNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ScheduleCatchup decides what happens to runs missed while the control
+// plane was down or the schedule was paused
+type ScheduleCatchup string
+
+const (
+	ScheduleCatchupSkip   ScheduleCatchup = "skip"   // Drop missed runs
+	ScheduleCatchupLatest ScheduleCatchup = "latest" // Start one run for the most recent missed time
+	ScheduleCatchupAll    ScheduleCatchup = "all"    // Start every missed run, up to maxScheduleCatchup
+)
+
+// ScheduleTrigger declares a cron schedule that starts runs of the workflow
+// with fixed inputs
+type ScheduleTrigger struct {
+	Name     string                 `json:"name"`
+	Cron     string                 `json:"cron"`               // minute hour day-of-month month day-of-week
+	Timezone string                 `json:"timezone,omitempty"` // IANA zone the cron fields are in, UTC when unset
+	Catchup  ScheduleCatchup        `json:"catchup,omitempty"`  // Defaults to skip
+	Inputs   map[string]interface{} `json:"inputs,omitempty"`
+	Lane     string                 `json:"lane,omitempty"`
+}
+
+// Validate checks the schedule's name, cron expression, timezone and policy
+func (s *ScheduleTrigger) Validate() error {
+	if !scheduleNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid schedule name %q", s.Name)
+	}
+	if _, err := parseCronSchedule(s.Cron); err != nil {
+		return fmt.Errorf("schedule %s: %w", s.Name, err)
+	}
+	if _, err := s.location(); err != nil {
+		return fmt.Errorf("schedule %s: %w", s.Name, err)
+	}
+	switch s.Catchup {
+	case "", ScheduleCatchupSkip, ScheduleCatchupLatest, ScheduleCatchupAll:
+		return nil
+	default:
+		return fmt.Errorf("schedule %s: invalid catchup policy %q", s.Name, s.Catchup)
+	}
+}
+
+func (s *ScheduleTrigger) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q", s.Timezone)
+	}
+	return loc, nil
+}
+
+// ValidateScheduleTriggers checks every schedule and that names are unique
+func ValidateScheduleTriggers(schedules []ScheduleTrigger) error {
+	seen := make(map[string]bool, len(schedules))
+	for i := range schedules {
+		if err := schedules[i].Validate(); err != nil {
+			return err
+		}
+		if seen[schedules[i].Name] {
+			return fmt.Errorf("duplicate schedule %s", schedules[i].Name)
+		}
+		seen[schedules[i].Name] = true
+	}
+	return nil
+}
+
+// NextRun returns the schedule's first run time after from
+func (s *ScheduleTrigger) NextRun(from time.Time) (time.Time, error) {
+	loc, err := s.location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return nextCronTime(s.Cron, from.In(loc), maxScheduleLookahead)
+}
+
+// dueRuns returns the run times to start now for a schedule whose next run
+// was due at nextRunAt, applying its catchup policy, and the run after now
+func (s *ScheduleTrigger) dueRuns(nextRunAt, now time.Time) ([]time.Time, time.Time, error) {
+	if nextRunAt.After(now) {
+		return nil, nextRunAt, nil
+	}
+
+	cron, err := parseCronSchedule(s.Cron)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	loc, err := s.location()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// Walk the missed minutes once, keeping only as many as the policy can start
+	missed := []time.Time{nextRunAt}
+	t := nextRunAt.In(loc).Truncate(time.Minute).Add(time.Minute)
+	for ; !t.After(now); t = t.Add(time.Minute) {
+		if cron.matches(t) {
+			missed = append(missed, t)
+			if len(missed) > maxScheduleCatchup {
+				missed = missed[1:]
+			}
+		}
+	}
+	next, err := cron.next(now.In(loc), maxScheduleLookahead)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	latest := missed[len(missed)-1]
+	switch s.Catchup {
+	case ScheduleCatchupAll:
+		return missed, next, nil
+	case ScheduleCatchupLatest:
+		return []time.Time{latest}, next, nil
+	default:
+		if now.Sub(latest) <= scheduleGrace {
+			return []time.Time{latest}, next, nil
+		}
+		return nil, next, nil
+	}
+}
+
+// WorkflowSchedule is a persisted schedule and when it runs next
+type WorkflowSchedule struct {
+	OrgID        uuid.UUID       `json:"org_id"`
+	WorkflowName string          `json:"workflow_name"`
+	Schedule     ScheduleTrigger `json:"schedule"`
+	Enabled      bool            `json:"enabled"`
+	NextRunAt    time.Time       `json:"next_run_at"`
+	LastRunAt    *time.Time      `json:"last_run_at,omitempty"`
+	LastRunID    *uuid.UUID      `json:"last_run_id,omitempty"`
+}
+
+// syncSchedules persists the schedules a newly applied workflow version
+// declares and removes the ones it dropped. Unchanged schedules keep their
+// next run; changed ones are rescheduled from now.
+func (cp *ControlPlane) syncSchedules(ctx context.Context, orgID uuid.UUID, workflowName string, schedules []ScheduleTrigger) error {
+	names := make([]string, 0, len(schedules))
+	for i := range schedules {
+		schedule := schedules[i]
+		next, err := schedule.NextRun(time.Now())
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", schedule.Name, err)
+		}
+		specJSON, err := json.Marshal(schedule)
+		if err != nil {
+			return fmt.Errorf("failed to marshal schedule: %w", err)
+		}
+
+		query := `INSERT INTO workflow_schedule (org_id, workflow_name, name, spec, next_run_at)
+				  VALUES ($1, $2, $3, $4, $5)
+				  ON CONFLICT (org_id, workflow_name, name) DO UPDATE SET
+				      next_run_at = CASE WHEN workflow_schedule.spec->>'cron' = EXCLUDED.spec->>'cron'
+				                          AND COALESCE(workflow_schedule.spec->>'timezone', '') = COALESCE(EXCLUDED.spec->>'timezone', '')
+				                         THEN workflow_schedule.next_run_at ELSE EXCLUDED.next_run_at END,
+				      spec = EXCLUDED.spec`
+		if _, err := cp.db.ExecContext(ctx, query, orgID, workflowName, schedule.Name, specJSON, next); err != nil {
+			return fmt.Errorf("failed to save schedule %s: %w", schedule.Name, err)
+		}
+		names = append(names, schedule.Name)
+	}
+
+	query := `DELETE FROM workflow_schedule WHERE org_id = $1 AND workflow_name = $2 AND NOT (name = ANY($3::text[]))`
+	if _, err := cp.db.ExecContext(ctx, query, orgID, workflowName, pq.Array(names)); err != nil {
+		return fmt.Errorf("failed to remove dropped schedules: %w", err)
+	}
+	return nil
+}
+
+// ListSchedules returns an org's workflow schedules
+func (cp *ControlPlane) ListSchedules(ctx context.Context, orgID uuid.UUID) ([]WorkflowSchedule, error) {
+	query := `SELECT org_id, workflow_name, spec, enabled, next_run_at, last_run_at, last_run_id
+			  FROM workflow_schedule WHERE org_id = $1 ORDER BY next_run_at`
+	rows, err := cp.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := make([]WorkflowSchedule, 0)
+	for rows.Next() {
+		schedule, err := scanWorkflowSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *schedule)
+	}
+	return schedules, rows.Err()
+}
+
+// SetScheduleEnabled pauses or resumes a schedule. Resuming applies the
+// schedule's catchup policy to the runs missed while it was paused.
+func (cp *ControlPlane) SetScheduleEnabled(ctx context.Context, orgID uuid.UUID, workflowName, name string, enabled bool) error {
+	query := `UPDATE workflow_schedule SET enabled = $4 WHERE org_id = $1 AND workflow_name = $2 AND name = $3`
+	result, err := cp.db.ExecContext(ctx, query, orgID, workflowName, name, enabled)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("schedule not found: %s/%s", workflowName, name)
+	}
+	return nil
+}
+
+func scanWorkflowSchedule(row interface{ Scan(...interface{}) error }) (*WorkflowSchedule, error) {
+	var schedule WorkflowSchedule
+	var specJSON []byte
+	var lastRunAt sql.NullTime
+	var lastRunID uuid.NullUUID
+	if err := row.Scan(&schedule.OrgID, &schedule.WorkflowName, &specJSON, &schedule.Enabled,
+		&schedule.NextRunAt, &lastRunAt, &lastRunID); err != nil {
+		return nil, fmt.Errorf("failed to scan schedule: %w", err)
+	}
+	if err := json.Unmarshal(specJSON, &schedule.Schedule); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schedule: %w", err)
+	}
+	if lastRunAt.Valid {
+		schedule.LastRunAt = &lastRunAt.Time
+	}
+	if lastRunID.Valid {
+		schedule.LastRunID = &lastRunID.UUID
+	}
+	return &schedule, nil
+}
+
+// runSchedules starts due scheduled runs until shutdown
+func (cp *ControlPlane) runSchedules(ctx context.Context) {
+	ticker := time.NewTicker(scheduleTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cp.shutdown:
+			return
+		case <-ticker.C:
+			if err := cp.fireDueSchedules(ctx, time.Now()); err != nil {
+				log.Printf("Failed to start scheduled runs: %v", err)
+			}
+		}
+	}
+}
+
+// fireDueSchedules claims due schedules, advances them past now and starts
+// their runs. Rows are locked while claimed so each due time starts once
+// across control planes.
+func (cp *ControlPlane) fireDueSchedules(ctx context.Context, now time.Time) error {
+	tx, err := cp.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `SELECT org_id, workflow_name, spec, enabled, next_run_at, last_run_at, last_run_id
+			  FROM workflow_schedule WHERE enabled AND next_run_at <= $1
+			  ORDER BY next_run_at FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, now)
+	if err != nil {
+		return fmt.Errorf("failed to query due schedules: %w", err)
+	}
+	due := make([]WorkflowSchedule, 0)
+	for rows.Next() {
+		schedule, err := scanWorkflowSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		due = append(due, *schedule)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read due schedules: %w", err)
+	}
+
+	type scheduledRun struct {
+		schedule WorkflowSchedule
+		at       time.Time
+	}
+	runs := make([]scheduledRun, 0, len(due))
+	for _, schedule := range due {
+		times, next, err := schedule.Schedule.dueRuns(schedule.NextRunAt, now)
+		if err != nil {
+			log.Printf("Disabling schedule %s/%s: %v", schedule.WorkflowName, schedule.Schedule.Name, err)
+			if _, err := tx.ExecContext(ctx, `UPDATE workflow_schedule SET enabled = FALSE
+				WHERE org_id = $1 AND workflow_name = $2 AND name = $3`,
+				schedule.OrgID, schedule.WorkflowName, schedule.Schedule.Name); err != nil {
+				return fmt.Errorf("failed to disable schedule: %w", err)
+			}
+			continue
+		}
+		if len(times) == 0 {
+			log.Printf("Skipping missed runs of schedule %s/%s", schedule.WorkflowName, schedule.Schedule.Name)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE workflow_schedule SET next_run_at = $4
+			WHERE org_id = $1 AND workflow_name = $2 AND name = $3`,
+			schedule.OrgID, schedule.WorkflowName, schedule.Schedule.Name, next); err != nil {
+			return fmt.Errorf("failed to advance schedule: %w", err)
+		}
+		for _, at := range times {
+			runs = append(runs, scheduledRun{schedule: schedule, at: at})
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit schedules: %w", err)
+	}
+
+	// Runs start after the schedule has advanced, so a crash here drops the
+	// run rather than starting it twice
+	for _, r := range runs {
+		run, err := cp.SubmitWorkflow(ctx, &RunRequest{
+			WorkflowName: r.schedule.WorkflowName,
+			Inputs:       r.schedule.Schedule.Inputs,
+			Trigger:      TriggerCron,
+			Lane:         r.schedule.Schedule.Lane,
+			Labels: map[string]string{
+				"schedule":      r.schedule.Schedule.Name,
+				"scheduled_for": r.at.UTC().Format(time.RFC3339),
+			},
+		})
+		if err != nil {
+			log.Printf("Failed to start scheduled run of %s/%s for %s: %v",
+				r.schedule.WorkflowName, r.schedule.Schedule.Name, r.at.Format(time.RFC3339), err)
+			continue
+		}
+		query := `UPDATE workflow_schedule SET last_run_at = $4, last_run_id = $5
+				  WHERE org_id = $1 AND workflow_name = $2 AND name = $3`
+		if _, err := cp.db.ExecContext(ctx, query, r.schedule.OrgID, r.schedule.WorkflowName,
+			r.schedule.Schedule.Name, r.at, run.ID); err != nil {
+			log.Printf("Failed to record scheduled run %s: %v", run.ID, err)
+		}
+	}
+	return nil
+}
+
+// cronSchedule holds the allowed values of each of the five cron fields
+type cronSchedule struct {
+	fields [5]map[int]bool
+	// anyDay is set when day-of-month or day-of-week is *, so the two
+	// fields must both match; otherwise either one matching is enough
+	anyDay bool
+}
+
+// parseCronSchedule parses a five-field cron expression (minute hour
+// day-of-month month day-of-week). Day-of-week 7 is Sunday, like 0.
+func parseCronSchedule(expr string) (cronSchedule, error) {
+	var cron cronSchedule
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cron, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return cron, fmt.Errorf("invalid cron field %q: %w", field, err)
+		}
+		cron.fields[i] = set
+	}
+	if cron.fields[4][7] {
+		cron.fields[4][0] = true
+	}
+	cron.anyDay = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*")
+	return cron, nil
+}
+
+// matches reports whether t's minute, in t's location, is a scheduled time.
+// As in standard cron, a restricted day-of-month and day-of-week match
+// when either does, so "0 0 1 * 1" runs on the 1st and on every Monday.
+func (c cronSchedule) matches(t time.Time) bool {
+	day := c.fields[2][t.Day()] && c.fields[4][int(t.Weekday())]
+	if !c.anyDay {
+		day = c.fields[2][t.Day()] || c.fields[4][int(t.Weekday())]
+	}
+	return c.fields[0][t.Minute()] && c.fields[1][t.Hour()] && c.fields[3][int(t.Month())] && day
+}
+
+// next returns the first scheduled minute after from, searching no further than within
+func (c cronSchedule) next(from time.Time, within time.Duration) (time.Time, error) {
+	for t := from.Truncate(time.Minute).Add(time.Minute); !t.After(from.Add(within)); t = t.Add(time.Minute) {
+		if c.matches(t) {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cron schedule has no run within %s", within)
+}
+
+// nextCronTime returns the first minute after from matching a five-field
+// cron expression, searching no further than within
+func nextCronTime(expr string, from time.Time, within time.Duration) (time.Time, error) {
+	cron, err := parseCronSchedule(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next, err := cron.next(from, within)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cron schedule %q has no run within %s", expr, within)
+	}
+	return next, nil
+}
+
+// parseCronField expands *, n, a-b and their /step forms, comma separated
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+			start, err := strconv.Atoi(startPart)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", startPart)
+			}
+			lo, hi = start, start
+			if isRange {
+				if hi, err = strconv.Atoi(endPart); err != nil {
+					return nil, fmt.Errorf("invalid value %q", endPart)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%d-%d is outside %d-%d", lo, hi, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,235 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// workerTaskPrefix carries tasks sent to one worker whose labels match
+	// the step's selector
+	workerTaskPrefix = "agentflow.tasks.worker."
+	// selectorWaitTimeout is how long a task waits for a matching worker
+	// to join before its step fails as unschedulable
+	selectorWaitTimeout = 5 * time.Minute
+	// regionLabel selects on a worker's region when it doesn't set the label itself
+	regionLabel = "region"
+)
+
+var (
+	selectorKeyPattern   = regexp.MustCompile(`^[a-z0-9]([a-z0-9._/-]{0,61}[a-z0-9])?$`)
+	selectorValuePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?$`)
+)
+
+// validateSelector checks a placement selector's label keys and values
+func validateSelector(selector map[string]string) error {
+	for key, value := range selector {
+		if !selectorKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid selector label %q", key)
+		}
+		if !selectorValuePattern.MatchString(value) {
+			return fmt.Errorf("invalid value %q for selector label %s", value, key)
+		}
+	}
+	return nil
+}
+
+// describeSelector renders a selector as sorted key=value pairs
+func describeSelector(selector map[string]string) string {
+	pairs := make([]string, 0, len(selector))
+	for key, value := range selector {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Label returns a label the worker advertises; the region label falls back
+// to the worker's region
+func (h WorkerHeartbeat) Label(key string) (string, bool) {
+	if value, ok := h.Labels[key]; ok {
+		return value, true
+	}
+	if key == regionLabel && h.Region != "" {
+		return h.Region, true
+	}
+	return "", false
+}
+
+// Matches reports whether the worker advertises every label of a selector
+func (h WorkerHeartbeat) Matches(selector map[string]string) bool {
+	for key, want := range selector {
+		if value, ok := h.Label(key); !ok || value != want {
+			return false
+		}
+	}
+	return true
+}
+
+// load is the share of the worker's capacity in use
+func (h WorkerHeartbeat) load() float64 {
+	if h.Capacity <= 0 {
+		return 1
+	}
+	return float64(h.Active) / float64(h.Capacity)
+}
+
+// selectWorker picks the worker to run a task with a selector: the least
+// loaded matching worker in an allowed region, preferred regions first
+// while they have spare capacity. When none matches it returns why.
+func selectWorker(placement *StepPlacement, workers []WorkerHeartbeat) (string, string) {
+	allowed := make(map[string]bool, len(placement.Regions))
+	for _, region := range placement.Regions {
+		allowed[region] = true
+	}
+
+	candidates := make([]WorkerHeartbeat, 0, len(workers))
+	labelled := 0
+	for _, worker := range workers {
+		if !worker.Matches(placement.Selector) {
+			continue
+		}
+		labelled++
+		if len(allowed) == 0 || allowed[worker.Region] {
+			candidates = append(candidates, worker)
+		}
+	}
+	if len(candidates) == 0 {
+		reason := fmt.Sprintf("no live worker matches selector %s (%d live workers)", describeSelector(placement.Selector), len(workers))
+		if labelled > 0 {
+			reason = fmt.Sprintf("%d live workers match selector %s, but none in regions %s",
+				labelled, describeSelector(placement.Selector), strings.Join(placement.Regions, ", "))
+		}
+		return "", reason
+	}
+
+	rank := func(w WorkerHeartbeat) int {
+		for i, region := range placement.Prefer {
+			if w.Region == region && w.load() < 1 {
+				return i
+			}
+		}
+		return len(placement.Prefer)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if ri, rj := rank(candidates[i]), rank(candidates[j]); ri != rj {
+			return ri < rj
+		}
+		if candidates[i].load() != candidates[j].load() {
+			return candidates[i].load() < candidates[j].load()
+		}
+		return candidates[i].WorkerID < candidates[j].WorkerID
+	})
+	return candidates[0].WorkerID, ""
+}
+
+func workerTaskSubject(workerID string) string {
+	return workerTaskPrefix + workerID
+}
+
+// liveWorkers returns the last heartbeat of every worker that reported recently
+func liveWorkers(ctx context.Context, redisClient *redis.Client) ([]WorkerHeartbeat, error) {
+	keys, err := redisClient.Keys(ctx, "worker:*").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workers: %w", err)
+	}
+
+	workers := make([]WorkerHeartbeat, 0, len(keys))
+	for _, key := range keys {
+		raw, err := redisClient.Get(ctx, key).Result()
+		if err != nil {
+			continue // Heartbeat expired since listing
+		}
+		var heartbeat WorkerHeartbeat
+		if err := json.Unmarshal([]byte(raw), &heartbeat); err != nil || heartbeat.WorkerID == "" {
+			continue
+		}
+		workers = append(workers, heartbeat)
+	}
+	return workers, nil
+}
+
+// dispatchSelected sends a task with a selector to a matching worker. A task
+// nobody can take waits for matching workers to join, then fails with the
+// reason; it returns false while the task should stay queued.
+func (q *TenantQueue) dispatchSelected(ctx context.Context, queued *queuedTask, data []byte, workers *[]WorkerHeartbeat) (bool, error) {
+	if *workers == nil {
+		live, err := liveWorkers(ctx, q.redis)
+		if err != nil {
+			return false, err
+		}
+		*workers = live
+	}
+
+	task := queued.Task
+	workerID, reason := selectWorker(task.Placement, *workers)
+	if workerID == "" {
+		if time.Since(queued.EnqueuedAt) < selectorWaitTimeout {
+			log.Printf("Task %s waits for a worker: %s", task.ID, reason)
+			return false, nil
+		}
+		return true, q.failUnschedulable(ctx, task, reason)
+	}
+	if _, err := q.js.Publish(workerTaskSubject(workerID), data); err != nil {
+		return false, err
+	}
+
+	// Count the task against the worker for the rest of this dispatch pass
+	for i := range *workers {
+		if (*workers)[i].WorkerID == workerID {
+			(*workers)[i].Active++
+		}
+	}
+	return true, nil
+}
+
+// failUnschedulable fails a task no worker can run. The result goes through
+// the normal result path, which frees its dispatch slot and triages the run.
+func (q *TenantQueue) failUnschedulable(ctx context.Context, task *Task, reason string) error {
+	message := "scheduling failed: " + reason
+	log.Printf("Task %s of run %s is unschedulable: %s", task.ID, task.RunID, reason)
+	unschedulableTasks.Inc(task.Type)
+	if q.onUnschedulable != nil {
+		if err := q.onUnschedulable(ctx, task, message); err != nil {
+			return err
+		}
+	}
+
+	result := &TaskResult{
+		TaskID:          task.ID,
+		RunID:           task.RunID,
+		OrgID:           task.OrgID,
+		NodeID:          task.NodeID,
+		Status:          TaskStatusFailed,
+		Error:           message,
+		ErrorClass:      string(cas.ErrorClassInvalidRequest),
+		FailureCategory: string(FailureScheduling),
+		ExecutedAt:      time.Now(),
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+	if _, err := q.js.Publish("agentflow.results", data); err != nil {
+		return fmt.Errorf("failed to publish scheduling failure: %w", err)
+	}
+	return nil
+}
+
+// failStepRun records why a step never ran on its step run
+func (cp *ControlPlane) failStepRun(ctx context.Context, task *Task, message string) error {
+	query := `UPDATE step_run SET status = 'failed', error = $2, ended_at = NOW() WHERE id = $1 AND status = 'queued'`
+	if _, err := cp.db.ExecContext(ctx, query, task.ID, message); err != nil {
+		return fmt.Errorf("failed to fail step run: %w", err)
+	}
+	return nil
+}
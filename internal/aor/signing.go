@@ -0,0 +1,368 @@
+package aor
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// Signature algorithms accepted for org signing keys. ECDSA P-256 keys are
+// what cosign generates, so payloads signed with cosign sign-blob verify too.
+const (
+	SignatureAlgorithmEd25519   = "ed25519"
+	SignatureAlgorithmECDSAP256 = "ecdsa-p256"
+)
+
+// Signature verification outcomes recorded in the audit log
+const (
+	SignatureVerified = "verified"
+	SignatureUnsigned = "unsigned"
+	SignatureRejected = "rejected"
+)
+
+// signaturePayloadVersion prefixes every signed payload so signatures cannot
+// be replayed across formats
+const signaturePayloadVersion = "agentflow-signature/v1"
+
+// SigningKey is an org public key that workflow specs and prompts may be signed with
+type SigningKey struct {
+	ID        uuid.UUID  `json:"id"`
+	OrgID     uuid.UUID  `json:"org_id"`
+	Name      string     `json:"name"` // Signer identity recorded on signed resources
+	Algorithm string     `json:"algorithm"`
+	PublicKey string     `json:"public_key"` // PEM encoded PKIX public key
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ResourceSignature is a detached signature over a resource's signed content
+type ResourceSignature struct {
+	Key       string `json:"key"`       // Name of the org signing key
+	Signature string `json:"signature"` // Base64 encoded
+}
+
+// SignatureRecord is the verified signer stored with a workflow spec or prompt version
+type SignatureRecord struct {
+	Key         string    `json:"key"`
+	Algorithm   string    `json:"algorithm"`
+	ContentHash string    `json:"content_hash"`
+	VerifiedAt  time.Time `json:"verified_at"`
+}
+
+// SignedContentHash returns the hash a resource's signature covers. Labels
+// git sync adds are left out, since the commit is not known when signing.
+func SignedContentHash(resource Resource) (string, error) {
+	switch resource.Kind {
+	case ResourceKindWorkflow:
+		spec, err := decodeWorkflowResource(resource)
+		if err != nil {
+			return "", err
+		}
+		if spec.Metadata.Labels != nil {
+			labels := make(map[string]string, len(spec.Metadata.Labels))
+			for k, v := range spec.Metadata.Labels {
+				if k != GitCommitLabel && k != GitPathLabel {
+					labels[k] = v
+				}
+			}
+			spec.Metadata.Labels = labels
+		}
+		content, err := CanonicalSpecBytes(spec)
+		if err != nil {
+			return "", err
+		}
+		return db.HashContent(content), nil
+	case ResourceKindPrompt:
+		return resourceHash(resource)
+	default:
+		return "", fmt.Errorf("only workflows and prompts can be signed")
+	}
+}
+
+// SignaturePayload is the exact byte string a resource signature signs
+func SignaturePayload(kind ResourceKind, name, contentHash string) []byte {
+	return []byte(fmt.Sprintf("%s\nkind: %s\nname: %s\ncontent: %s\n", signaturePayloadVersion, kind, name, contentHash))
+}
+
+// ParsePublicKey decodes a PEM public key and returns it with its algorithm
+func ParsePublicKey(pemData string) (crypto.PublicKey, string, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, "", fmt.Errorf("public key must be PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return k, SignatureAlgorithmEd25519, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, "", fmt.Errorf("ECDSA keys must use P-256")
+		}
+		return k, SignatureAlgorithmECDSAP256, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported key type %T: use ed25519 or ECDSA P-256", key)
+	}
+}
+
+// SignResource signs a workflow or prompt resource with a PEM encoded PKCS#8
+// private key, attaching the signature under the given key name
+func SignResource(resource *Resource, keyName string, privateKeyPEM []byte) error {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return fmt.Errorf("private key must be PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	hash, err := SignedContentHash(*resource)
+	if err != nil {
+		return err
+	}
+	payload := SignaturePayload(resource.Kind, resource.Name, hash)
+
+	var signature []byte
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, payload)
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(payload)
+		if signature, err = ecdsa.SignASN1(rand.Reader, k, digest[:]); err != nil {
+			return fmt.Errorf("failed to sign: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T: use ed25519 or ECDSA P-256", key)
+	}
+
+	resource.Signature = &ResourceSignature{Key: keyName, Signature: base64.StdEncoding.EncodeToString(signature)}
+	return nil
+}
+
+// verifySignature checks a signature over payload with a PEM public key
+func verifySignature(publicKeyPEM string, payload []byte, encoded string) error {
+	key, _, err := ParsePublicKey(publicKeyPEM)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("signature is not valid base64")
+	}
+
+	valid := false
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(k, payload, signature)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(payload)
+		valid = ecdsa.VerifyASN1(k, digest[:], signature)
+	}
+	if !valid {
+		return fmt.Errorf("signature does not match content")
+	}
+	return nil
+}
+
+// AddSigningKey registers an org public key
+func (cp *ControlPlane) AddSigningKey(ctx context.Context, key *SigningKey) error {
+	if strings.TrimSpace(key.Name) == "" {
+		return fmt.Errorf("signing key name is required")
+	}
+	_, algorithm, err := ParsePublicKey(key.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	key.ID = uuid.New()
+	key.Algorithm = algorithm
+	key.CreatedAt = time.Now()
+	query := `INSERT INTO signing_key (id, org_id, name, algorithm, public_key, created_by, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := cp.db.ExecContext(ctx, query, key.ID, key.OrgID, key.Name, key.Algorithm, key.PublicKey, key.CreatedBy, key.CreatedAt); err != nil {
+		return fmt.Errorf("failed to add signing key: %w", err)
+	}
+	return nil
+}
+
+// ListSigningKeys returns an org's signing keys, including revoked ones
+func (cp *ControlPlane) ListSigningKeys(ctx context.Context, orgID uuid.UUID) ([]SigningKey, error) {
+	query := `SELECT id, org_id, name, algorithm, public_key, created_by, created_at, revoked_at
+			  FROM signing_key WHERE org_id = $1 ORDER BY name`
+	rows, err := cp.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]SigningKey, 0)
+	for rows.Next() {
+		var key SigningKey
+		var revokedAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.OrgID, &key.Name, &key.Algorithm, &key.PublicKey, &key.CreatedBy, &key.CreatedAt, &revokedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+		if revokedAt.Valid {
+			key.RevokedAt = &revokedAt.Time
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeSigningKey stops a key from verifying new submissions. Resources it
+// already signed keep their signature records.
+func (cp *ControlPlane) RevokeSigningKey(ctx context.Context, orgID uuid.UUID, name string) error {
+	query := `UPDATE signing_key SET revoked_at = NOW() WHERE org_id = $1 AND name = $2 AND revoked_at IS NULL`
+	result, err := cp.db.ExecContext(ctx, query, orgID, name)
+	if err != nil {
+		return fmt.Errorf("failed to revoke signing key: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("active signing key %s not found", name)
+	}
+	return nil
+}
+
+func (cp *ControlPlane) activeSigningKey(ctx context.Context, orgID uuid.UUID, name string) (*SigningKey, error) {
+	key := &SigningKey{OrgID: orgID, Name: name}
+	query := `SELECT id, algorithm, public_key FROM signing_key
+			  WHERE org_id = $1 AND name = $2 AND revoked_at IS NULL`
+	err := cp.db.QueryRowContext(ctx, query, orgID, name).Scan(&key.ID, &key.Algorithm, &key.PublicKey)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unknown or revoked signing key %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing key: %w", err)
+	}
+	return key, nil
+}
+
+// verifyBundleSignatures checks the signatures of a bundle's workflows and
+// prompts before anything is applied, and returns the verified signers by
+// resource. A signature that fails to verify is always rejected; unsigned
+// resources are rejected only when the control plane requires signatures.
+func (cp *ControlPlane) verifyBundleSignatures(ctx context.Context, orgID uuid.UUID, bundle *ApplyBundle, dryRun bool) (map[string]*SignatureRecord, error) {
+	records := make(map[string]*SignatureRecord)
+	var problems []string
+
+	for _, resource := range bundle.Resources {
+		if resource.Kind != ResourceKindWorkflow && resource.Kind != ResourceKindPrompt {
+			continue
+		}
+		key := string(resource.Kind) + "/" + resource.Name
+		hash, err := SignedContentHash(resource)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		record, outcome, err := cp.verifyResource(ctx, orgID, resource, hash)
+		if !dryRun && (resource.Signature != nil || cp.cfg.Signing.RequireSignatures) {
+			keyName, detail := "", ""
+			if resource.Signature != nil {
+				keyName = resource.Signature.Key
+			}
+			if err != nil {
+				detail = err.Error()
+			}
+			cp.recordSignatureAudit(ctx, orgID, resource, hash, keyName, outcome, detail)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		if record != nil {
+			records[key] = record
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("signature verification failed:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return records, nil
+}
+
+func (cp *ControlPlane) verifyResource(ctx context.Context, orgID uuid.UUID, resource Resource, hash string) (*SignatureRecord, string, error) {
+	if resource.Signature == nil {
+		if cp.cfg.Signing.RequireSignatures {
+			return nil, SignatureUnsigned, fmt.Errorf("unsigned; this control plane requires signed workflows and prompts")
+		}
+		return nil, SignatureUnsigned, nil
+	}
+
+	signingKey, err := cp.activeSigningKey(ctx, orgID, resource.Signature.Key)
+	if err != nil {
+		return nil, SignatureRejected, err
+	}
+	payload := SignaturePayload(resource.Kind, resource.Name, hash)
+	if err := verifySignature(signingKey.PublicKey, payload, resource.Signature.Signature); err != nil {
+		return nil, SignatureRejected, err
+	}
+
+	return &SignatureRecord{
+		Key:         signingKey.Name,
+		Algorithm:   signingKey.Algorithm,
+		ContentHash: hash,
+		VerifiedAt:  time.Now(),
+	}, SignatureVerified, nil
+}
+
+// recordSignatureAudit logs a verification outcome; audit failures are
+// logged rather than failing the submission
+func (cp *ControlPlane) recordSignatureAudit(ctx context.Context, orgID uuid.UUID, resource Resource, hash, keyName, outcome, detail string) {
+	log.Printf("Signature %s for %s/%s in org %s (key %q): %s", outcome, resource.Kind, resource.Name, orgID, keyName, detail)
+	query := `INSERT INTO signature_audit (org_id, kind, name, content_hash, key_name, outcome, detail)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := cp.db.ExecContext(ctx, query, orgID, resource.Kind, resource.Name, hash, keyName, outcome, detail); err != nil {
+		log.Printf("Failed to record signature audit: %v", err)
+	}
+}
+
+// signatureJSON encodes a signature record for its column, or NULL when unsigned
+func signatureJSON(record *SignatureRecord) (interface{}, error) {
+	if record == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signature: %w", err)
+	}
+	return data, nil
+}
+
+// checkSpecSigned rejects runs of unsigned specs when signatures are required
+func (cp *ControlPlane) checkSpecSigned(ctx context.Context, spec *WorkflowSpec) error {
+	if !cp.cfg.Signing.RequireSignatures {
+		return nil
+	}
+	var signed bool
+	query := `SELECT signature IS NOT NULL FROM workflow_spec WHERE id = $1`
+	if err := cp.db.QueryRowContext(ctx, query, spec.ID).Scan(&signed); err != nil {
+		return fmt.Errorf("failed to check spec signature: %w", err)
+	}
+	if !signed {
+		return fmt.Errorf("workflow %s v%d is unsigned; this control plane only runs signed workflows", spec.Name, spec.Version)
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SLAStatus tracks a run against its workflow's end-to-end SLA
+type SLAStatus string
+
+const (
+	SLAStatusOnTrack  SLAStatus = "on_track"
+	SLAStatusAtRisk   SLAStatus = "at_risk"
+	SLAStatusBreached SLAStatus = "breached"
+	SLAStatusMet      SLAStatus = "met"
+)
+
+const (
+	// defaultSLAAtRiskRatio flags a run once this fraction of its SLA has elapsed
+	defaultSLAAtRiskRatio = 0.8
+	// defaultStepEstimate is used for steps without completed history
+	defaultStepEstimate = 30 * time.Second
+	// slaHistoryWindow bounds the step history used for predictions
+	slaHistoryWindow = 30 * 24 * time.Hour
+)
+
+// SLASpec declares a workflow's end-to-end SLA
+type SLASpec struct {
+	Duration time.Duration `json:"duration"`
+	// AtRiskRatio marks a run at risk once this fraction of the SLA has
+	// elapsed, even if the prediction still fits; defaults to 0.8
+	AtRiskRatio float64 `json:"at_risk_ratio,omitempty"`
+}
+
+// SLAAlert is emitted when a run becomes at risk or breaches its SLA
+type SLAAlert struct {
+	RunID               uuid.UUID `json:"run_id"`
+	OrgID               uuid.UUID `json:"org_id"`
+	WorkflowName        string    `json:"workflow_name"`
+	Status              SLAStatus `json:"status"`
+	Deadline            time.Time `json:"deadline"`
+	Elapsed             string    `json:"elapsed"`
+	PredictedCompletion time.Time `json:"predicted_completion,omitempty"`
+	Message             string    `json:"message"`
+	Timestamp           time.Time `json:"timestamp"`
+}
+
+// slaRunState is the input to an SLA evaluation
+type slaRunState struct {
+	CreatedAt          time.Time
+	EndedAt            *time.Time
+	Deadline           time.Time
+	AtRiskRatio        float64
+	PredictedRemaining time.Duration
+}
+
+// evaluateSLA classifies a run; finished runs are final, active runs are
+// at risk when the prediction overshoots or most of the SLA is used
+func evaluateSLA(state slaRunState, now time.Time) SLAStatus {
+	if state.EndedAt != nil {
+		if state.EndedAt.After(state.Deadline) {
+			return SLAStatusBreached
+		}
+		return SLAStatusMet
+	}
+
+	if now.After(state.Deadline) {
+		return SLAStatusBreached
+	}
+
+	if now.Add(state.PredictedRemaining).After(state.Deadline) {
+		return SLAStatusAtRisk
+	}
+
+	ratio := state.AtRiskRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = defaultSLAAtRiskRatio
+	}
+	budget := state.Deadline.Sub(state.CreatedAt)
+	if budget > 0 && float64(now.Sub(state.CreatedAt)) >= ratio*float64(budget) {
+		return SLAStatusAtRisk
+	}
+
+	return SLAStatusOnTrack
+}
+
+// predictRemainingDuration estimates time to completion as the critical path
+// through unfinished steps, using historical durations per step
+func predictRemainingDuration(dag DAG, completed map[string]bool, running, history map[string]time.Duration) time.Duration {
+	preds := make(map[string][]string)
+	for _, edge := range dag.Edges {
+		preds[edge.To] = append(preds[edge.To], edge.From)
+	}
+
+	remaining := func(stepID string) time.Duration {
+		if completed[stepID] {
+			return 0
+		}
+		estimate, ok := history[stepID]
+		if !ok {
+			estimate = defaultStepEstimate
+		}
+		if elapsed, ok := running[stepID]; ok {
+			if elapsed >= estimate {
+				return 0
+			}
+			return estimate - elapsed
+		}
+		return estimate
+	}
+
+	finish := make(map[string]time.Duration)
+	visiting := make(map[string]bool)
+	var finishAt func(stepID string) time.Duration
+	finishAt = func(stepID string) time.Duration {
+		if d, ok := finish[stepID]; ok {
+			return d
+		}
+		if visiting[stepID] {
+			return 0 // Cycles are rejected at submission; don't recurse forever
+		}
+		visiting[stepID] = true
+
+		var start time.Duration
+		for _, pred := range preds[stepID] {
+			if d := finishAt(pred); d > start {
+				start = d
+			}
+		}
+		finish[stepID] = start + remaining(stepID)
+		return finish[stepID]
+	}
+
+	var total time.Duration
+	for _, step := range dag.Steps {
+		if d := finishAt(step.ID); d > total {
+			total = d
+		}
+	}
+	return total
+}
+
+// checkRunSLAs re-evaluates runs whose SLA outcome is not yet final
+func (m *Monitor) checkRunSLAs(ctx context.Context) {
+	query := `SELECT r.id, s.org_id, s.name, s.dag, s.metadata, r.created_at, r.ended_at, r.sla_deadline, r.sla_status
+			  FROM workflow_run r JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE r.sla_deadline IS NOT NULL AND r.sla_status IN ('on_track', 'at_risk')`
+
+	rows, err := m.cp.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("Failed to query SLA runs: %v", err)
+		return
+	}
+
+	type slaRun struct {
+		run         WorkflowRun
+		dag         DAG
+		atRiskRatio float64
+	}
+
+	runs := make([]slaRun, 0)
+	for rows.Next() {
+		var r slaRun
+		var dagJSON, metadataJSON []byte
+		var deadline time.Time
+		if err := rows.Scan(&r.run.ID, &r.run.OrgID, &r.run.WorkflowName, &dagJSON, &metadataJSON,
+			&r.run.CreatedAt, &r.run.EndedAt, &deadline, &r.run.SLAStatus); err != nil {
+			continue
+		}
+		r.run.SLADeadline = &deadline
+		if err := json.Unmarshal(dagJSON, &r.dag); err != nil {
+			continue
+		}
+		var metadata Metadata
+		if err := json.Unmarshal(metadataJSON, &metadata); err == nil && metadata.SLA != nil {
+			r.atRiskRatio = metadata.SLA.AtRiskRatio
+		}
+		runs = append(runs, r)
+	}
+	_ = rows.Close()
+
+	now := time.Now()
+	histories := make(map[string]map[string]time.Duration)
+	for _, r := range runs {
+		key := r.run.OrgID.String() + "/" + r.run.WorkflowName
+		history, ok := histories[key]
+		if !ok {
+			if history, err = m.stepDurationHistory(ctx, r.run.OrgID, r.run.WorkflowName); err != nil {
+				log.Printf("Failed to load step history for %s: %v", r.run.WorkflowName, err)
+			}
+			histories[key] = history
+		}
+
+		var predicted time.Duration
+		if r.run.EndedAt == nil {
+			completed, running, err := m.stepProgress(ctx, r.run.ID, now)
+			if err != nil {
+				log.Printf("Failed to load step progress for run %s: %v", r.run.ID, err)
+				continue
+			}
+			predicted = predictRemainingDuration(r.dag, completed, running, history)
+		}
+
+		status := evaluateSLA(slaRunState{
+			CreatedAt:          r.run.CreatedAt,
+			EndedAt:            r.run.EndedAt,
+			Deadline:           *r.run.SLADeadline,
+			AtRiskRatio:        r.atRiskRatio,
+			PredictedRemaining: predicted,
+		}, now)
+		if status == r.run.SLAStatus {
+			continue
+		}
+
+		if err := m.cp.updateRunSLAStatus(ctx, r.run.ID, r.run.SLAStatus, status); err != nil {
+			log.Printf("Failed to update SLA status for run %s: %v", r.run.ID, err)
+			continue
+		}
+		slaTransitions.Inc(r.run.WorkflowName, string(status))
+
+		if status == SLAStatusAtRisk || status == SLAStatusBreached {
+			alert := SLAAlert{
+				RunID:        r.run.ID,
+				OrgID:        r.run.OrgID,
+				WorkflowName: r.run.WorkflowName,
+				Status:       status,
+				Deadline:     *r.run.SLADeadline,
+				Elapsed:      now.Sub(r.run.CreatedAt).Round(time.Second).String(),
+				Timestamp:    now,
+			}
+			if predicted > 0 {
+				alert.PredictedCompletion = now.Add(predicted)
+			}
+			alert.Message = slaAlertMessage(alert)
+			m.cp.notifySLA(ctx, alert)
+		}
+	}
+}
+
+// stepDurationHistory returns the p90 duration of each step in recent successful runs
+func (m *Monitor) stepDurationHistory(ctx context.Context, orgID uuid.UUID, workflowName string) (map[string]time.Duration, error) {
+	query := `SELECT sr.node_id,
+			  percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM sr.ended_at - sr.started_at))
+			  FROM step_run sr
+			  JOIN workflow_run r ON r.id = sr.workflow_run_id
+			  JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE s.org_id = $1 AND s.name = $2 AND sr.status = 'succeeded'
+			  AND sr.started_at IS NOT NULL AND sr.ended_at > $3
+			  GROUP BY sr.node_id`
+
+	rows, err := m.cp.db.QueryContext(ctx, query, orgID, workflowName, time.Now().Add(-slaHistoryWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query step durations: %w", err)
+	}
+	defer rows.Close()
+
+	history := make(map[string]time.Duration)
+	for rows.Next() {
+		var nodeID string
+		var seconds float64
+		if err := rows.Scan(&nodeID, &seconds); err != nil {
+			return nil, fmt.Errorf("failed to scan step duration: %w", err)
+		}
+		history[nodeID] = time.Duration(seconds * float64(time.Second))
+	}
+
+	return history, rows.Err()
+}
+
+// stepProgress returns completed steps and the elapsed time of running steps
+func (m *Monitor) stepProgress(ctx context.Context, runID uuid.UUID, now time.Time) (map[string]bool, map[string]time.Duration, error) {
+	query := `SELECT node_id, status, started_at FROM step_run WHERE workflow_run_id = $1`
+
+	rows, err := m.cp.db.QueryContext(ctx, query, runID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query step runs: %w", err)
+	}
+	defer rows.Close()
+
+	completed := make(map[string]bool)
+	running := make(map[string]time.Duration)
+	for rows.Next() {
+		var nodeID, status string
+		var startedAt *time.Time
+		if err := rows.Scan(&nodeID, &status, &startedAt); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan step run: %w", err)
+		}
+		switch {
+		case status == "succeeded":
+			completed[nodeID] = true
+		case status == "running" && startedAt != nil:
+			running[nodeID] = now.Sub(*startedAt)
+		}
+	}
+
+	return completed, running, rows.Err()
+}
+
+// updateRunSLAStatus moves a run to a new SLA status if nobody else already did
+func (cp *ControlPlane) updateRunSLAStatus(ctx context.Context, runID uuid.UUID, from, to SLAStatus) error {
+	query := `UPDATE workflow_run SET sla_status = $1 WHERE id = $2 AND sla_status = $3`
+	if _, err := cp.db.ExecContext(ctx, query, to, runID, from); err != nil {
+		return fmt.Errorf("failed to update SLA status: %w", err)
+	}
+	return nil
+}
+
+// notifySLA sends an SLA alert to every configured notifier
+func (cp *ControlPlane) notifySLA(ctx context.Context, alert SLAAlert) {
+	log.Printf("SLA alert: %s", alert.Message)
+	for _, notifier := range cp.notifiers {
+		if err := notifier.Notify(ctx, alert); err != nil {
+			log.Printf("Failed to send SLA alert for run %s: %v", alert.RunID, err)
+		}
+	}
+}
+
+func slaAlertMessage(alert SLAAlert) string {
+	switch alert.Status {
+	case SLAStatusBreached:
+		return fmt.Sprintf("Run %s of %s breached its SLA (deadline %s, elapsed %s)",
+			alert.RunID, alert.WorkflowName, alert.Deadline.Format(time.RFC3339), alert.Elapsed)
+	default:
+		msg := fmt.Sprintf("Run %s of %s is at risk of breaching its SLA (deadline %s, elapsed %s)",
+			alert.RunID, alert.WorkflowName, alert.Deadline.Format(time.RFC3339), alert.Elapsed)
+		if !alert.PredictedCompletion.IsZero() {
+			msg += fmt.Sprintf(", predicted completion %s", alert.PredictedCompletion.Format(time.RFC3339))
+		}
+		return msg
+	}
+}
@@ -0,0 +1,84 @@
+package aor
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultSLOWindow is the rolling window step SLOs are measured over unless set
+const DefaultSLOWindow = 30 * 24 * time.Hour
+
+// StepSLO declares a step's service level objectives. Latency and cost are
+// p95 objectives: 95% of attempts must finish within LatencyP95 and cost at
+// most CostP95Cents. SuccessRate is the share of attempts that must succeed.
+// AOS tracks attainment and error budget burn over the rolling Window.
+type StepSLO struct {
+	LatencyP95   time.Duration `json:"latency_p95,omitempty"`
+	CostP95Cents int64         `json:"cost_p95_cents,omitempty"`
+	SuccessRate  float64       `json:"success_rate,omitempty"` // e.g. 0.99
+	Window       time.Duration `json:"window,omitempty"`
+}
+
+// Validate checks an SLO declares at least one objective within range
+func (s *StepSLO) Validate() error {
+	if s.LatencyP95 == 0 && s.CostP95Cents == 0 && s.SuccessRate == 0 {
+		return fmt.Errorf("slo must set latency_p95, cost_p95_cents or success_rate")
+	}
+	if s.LatencyP95 < 0 {
+		return fmt.Errorf("invalid slo latency_p95 %s", s.LatencyP95)
+	}
+	if s.CostP95Cents < 0 {
+		return fmt.Errorf("invalid slo cost_p95_cents %d", s.CostP95Cents)
+	}
+	if s.SuccessRate < 0 || s.SuccessRate >= 1 {
+		return fmt.Errorf("invalid slo success_rate %g: use a fraction below 1, e.g. 0.99", s.SuccessRate)
+	}
+	if s.Window < 0 || (s.Window > 0 && s.Window < time.Hour) {
+		return fmt.Errorf("invalid slo window %s: use at least 1h", s.Window)
+	}
+	return nil
+}
+
+// ValidateStepSLOs checks every SLO declared in a DAG
+func ValidateStepSLOs(dag DAG) error {
+	for _, step := range dag.Steps {
+		if step.SLO == nil {
+			continue
+		}
+		if err := step.SLO.Validate(); err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+	}
+	return nil
+}
+
+// normalizeSLODurations converts string step slo latency_p95 and window to nanoseconds
+func normalizeSLODurations(raw map[string]interface{}) error {
+	dag, ok := raw["dag"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	steps, _ := dag["steps"].([]interface{})
+	for _, s := range steps {
+		step, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		slo, ok := step["slo"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"latency_p95", "window"} {
+			value, ok := slo[key].(string)
+			if !ok {
+				continue
+			}
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid slo %s %q for step %v: %w", key, value, step["id"], err)
+			}
+			slo[key] = int64(d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package aor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// spawnSeparator namespaces spawned node IDs under their parent
+	spawnSeparator = "."
+	// spawnHardMaxNodes caps nodes per directive regardless of step config
+	spawnHardMaxNodes = 100
+	// spawnHardMaxTotal caps the size of a run's DAG after spawning
+	spawnHardMaxTotal = 1000
+	// spawnHardMaxDepth caps how deeply spawned nodes may spawn again
+	spawnHardMaxDepth = 3
+)
+
+// SpawnDirective is returned by a step under output["spawn"] to append
+// nodes to the running DAG. Node and edge IDs are local to the directive.
+type SpawnDirective struct {
+	Nodes []Step `json:"nodes"`
+	Edges []Edge `json:"edges,omitempty"`
+}
+
+// SpawnPolicy bounds what a step may spawn. Steps opt in with a "spawn"
+// config block, e.g. {"max_nodes": 20, "allowed_types": ["llm", "tool"]}.
+type SpawnPolicy struct {
+	MaxNodes     int      `json:"max_nodes"`
+	MaxTotal     int      `json:"max_total"`
+	MaxDepth     int      `json:"max_depth"`
+	AllowedTypes []string `json:"allowed_types,omitempty"`
+}
+
+// DAGOverlay records nodes and edges added to a run at runtime
+type DAGOverlay struct {
+	Steps []Step `json:"steps"`
+	Edges []Edge `json:"edges"`
+}
+
+// spawnPolicyFor returns the step's spawn policy, or nil if it may not spawn
+func spawnPolicyFor(step Step) *SpawnPolicy {
+	raw, ok := step.Config["spawn"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	policy := &SpawnPolicy{MaxNodes: 10, MaxTotal: spawnHardMaxTotal, MaxDepth: 1}
+	if v, ok := raw["max_nodes"].(float64); ok && v > 0 {
+		policy.MaxNodes = int(v)
+	}
+	if v, ok := raw["max_total"].(float64); ok && v > 0 {
+		policy.MaxTotal = int(v)
+	}
+	if v, ok := raw["max_depth"].(float64); ok && v > 0 {
+		policy.MaxDepth = int(v)
+	}
+	if types, ok := raw["allowed_types"].([]interface{}); ok {
+		for _, t := range types {
+			if s, ok := t.(string); ok {
+				policy.AllowedTypes = append(policy.AllowedTypes, s)
+			}
+		}
+	}
+
+	policy.MaxNodes = min(policy.MaxNodes, spawnHardMaxNodes)
+	policy.MaxTotal = min(policy.MaxTotal, spawnHardMaxTotal)
+	policy.MaxDepth = min(policy.MaxDepth, spawnHardMaxDepth)
+
+	return policy
+}
+
+// extractSpawnDirective reads a spawn directive from step output, if present
+func extractSpawnDirective(output map[string]interface{}) (*SpawnDirective, error) {
+	raw, ok := output["spawn"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal spawn directive: %w", err)
+	}
+
+	var directive SpawnDirective
+	if err := json.Unmarshal(data, &directive); err != nil {
+		return nil, fmt.Errorf("failed to decode spawn directive: %w", err)
+	}
+
+	return &directive, nil
+}
+
+// expandDAG validates a directive against the parent's policy and returns the
+// DAG with the new nodes appended plus the spawned steps ready to run.
+// Spawned roots depend on the parent, and spawned leaves become dependencies
+// of the parent's existing successors so downstream joins wait for them.
+func expandDAG(dag DAG, parentID string, directive *SpawnDirective) (DAG, []Step, error) {
+	parent := findStep(dag.Steps, parentID)
+	if parent == nil {
+		return dag, nil, fmt.Errorf("unknown parent step %s", parentID)
+	}
+
+	policy := spawnPolicyFor(*parent)
+	if policy == nil {
+		return dag, nil, fmt.Errorf("step %s is not allowed to spawn nodes", parentID)
+	}
+	if len(directive.Nodes) == 0 {
+		return dag, nil, fmt.Errorf("spawn directive has no nodes")
+	}
+	if len(directive.Nodes) > policy.MaxNodes {
+		return dag, nil, fmt.Errorf("spawn directive has %d nodes, policy allows %d", len(directive.Nodes), policy.MaxNodes)
+	}
+	if total := len(dag.Steps) + len(directive.Nodes); total > policy.MaxTotal {
+		return dag, nil, fmt.Errorf("spawning would grow the DAG to %d nodes, policy allows %d", total, policy.MaxTotal)
+	}
+	if depth := strings.Count(parentID, spawnSeparator) + 1; depth > policy.MaxDepth {
+		return dag, nil, fmt.Errorf("spawn depth %d exceeds policy limit %d", depth, policy.MaxDepth)
+	}
+
+	existing := make(map[string]bool, len(dag.Steps))
+	for _, step := range dag.Steps {
+		existing[step.ID] = true
+	}
+
+	local := make(map[string]string, len(directive.Nodes))
+	spawned := make([]Step, 0, len(directive.Nodes))
+	for _, node := range directive.Nodes {
+		if node.ID == "" || strings.Contains(node.ID, spawnSeparator) {
+			return dag, nil, fmt.Errorf("invalid spawned node id %q", node.ID)
+		}
+		if _, dup := local[node.ID]; dup {
+			return dag, nil, fmt.Errorf("duplicate spawned node id %s", node.ID)
+		}
+		if len(policy.AllowedTypes) > 0 && !containsString(policy.AllowedTypes, node.Type) {
+			return dag, nil, fmt.Errorf("spawned node %s has disallowed type %s", node.ID, node.Type)
+		}
+
+		id := parentID + spawnSeparator + node.ID
+		if existing[id] {
+			return dag, nil, fmt.Errorf("spawned node %s already exists", id)
+		}
+		local[node.ID] = id
+		node.ID = id
+		spawned = append(spawned, node)
+	}
+
+	hasIncoming := make(map[string]bool)
+	hasOutgoing := make(map[string]bool)
+	edges := make([]Edge, 0, len(directive.Edges)+2*len(spawned))
+	for _, edge := range directive.Edges {
+		from, okFrom := local[edge.From]
+		to, okTo := local[edge.To]
+		if !okFrom || !okTo {
+			return dag, nil, fmt.Errorf("spawned edge %s -> %s must connect spawned nodes", edge.From, edge.To)
+		}
+		edges = append(edges, Edge{From: from, To: to})
+		hasIncoming[to] = true
+		hasOutgoing[from] = true
+	}
+
+	successors := make([]string, 0)
+	for _, edge := range dag.Edges {
+		if edge.From == parentID {
+			successors = append(successors, edge.To)
+		}
+	}
+
+	ready := make([]Step, 0)
+	for _, step := range spawned {
+		if !hasIncoming[step.ID] {
+			edges = append(edges, Edge{From: parentID, To: step.ID})
+			ready = append(ready, step)
+		}
+		if !hasOutgoing[step.ID] {
+			for _, successor := range successors {
+				edges = append(edges, Edge{From: step.ID, To: successor})
+			}
+		}
+	}
+
+	expanded := DAG{
+		Steps: append(append([]Step(nil), dag.Steps...), spawned...),
+		Edges: append(append([]Edge(nil), dag.Edges...), edges...),
+	}
+	if err := checkAcyclic(expanded); err != nil {
+		return dag, nil, err
+	}
+
+	return expanded, ready, nil
+}
+
+// applySpawn appends spawned nodes to a run's DAG overlay and enqueues the
+// nodes that only depend on the finished parent
+func (s *Scheduler) applySpawn(ctx context.Context, runID uuid.UUID, parentID string, directive *SpawnDirective) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	run := &WorkflowRun{ID: runID}
+	var metadataJSON, labelsJSON, overlayJSON []byte
+	query := `SELECT r.workflow_spec_id, s.org_id, s.name, r.metadata, r.labels, r.dag_overlay
+			  FROM workflow_run r JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE r.id = $1 FOR UPDATE OF r`
+	err = tx.QueryRowContext(ctx, query, runID).Scan(&run.WorkflowSpecID, &run.OrgID, &run.WorkflowName, &metadataJSON, &labelsJSON, &overlayJSON)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("workflow run %s not found", runID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get workflow run: %w", err)
+	}
+	if err := json.Unmarshal(metadataJSON, &run.Metadata); err != nil {
+		return fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+	if err := json.Unmarshal(labelsJSON, &run.Labels); err != nil {
+		return fmt.Errorf("failed to unmarshal labels: %w", err)
+	}
+
+	var overlay DAGOverlay
+	if err := json.Unmarshal(overlayJSON, &overlay); err != nil {
+		return fmt.Errorf("failed to unmarshal DAG overlay: %w", err)
+	}
+
+	spec, err := s.getWorkflowSpec(ctx, run.WorkflowSpecID)
+	if err != nil {
+		return fmt.Errorf("failed to get workflow spec: %w", err)
+	}
+	current := DAG{
+		Steps: append(append([]Step(nil), spec.DAG.Steps...), overlay.Steps...),
+		Edges: append(append([]Edge(nil), spec.DAG.Edges...), overlay.Edges...),
+	}
+
+	expanded, ready, err := expandDAG(current, parentID, directive)
+	if err != nil {
+		return err
+	}
+
+	overlay.Steps = expanded.Steps[len(spec.DAG.Steps):]
+	overlay.Edges = expanded.Edges[len(spec.DAG.Edges):]
+	updated, err := json.Marshal(overlay)
+	if err != nil {
+		return fmt.Errorf("failed to marshal DAG overlay: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE workflow_run SET dag_overlay = $1 WHERE id = $2`, updated, runID); err != nil {
+		return fmt.Errorf("failed to update DAG overlay: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit DAG overlay: %w", err)
+	}
+
+	for _, step := range ready {
+		if err := s.enqueueStep(ctx, run, step); err != nil {
+			return err
+		}
+	}
+	spawnedNodes.Add(float64(len(directive.Nodes)), run.WorkflowName)
+
+	return nil
+}
+
+// checkAcyclic verifies a DAG has no cycles using Kahn's algorithm
+func checkAcyclic(dag DAG) error {
+	inDegree := make(map[string]int, len(dag.Steps))
+	next := make(map[string][]string)
+	for _, step := range dag.Steps {
+		inDegree[step.ID] += 0
+	}
+	for _, edge := range dag.Edges {
+		inDegree[edge.To]++
+		next[edge.From] = append(next[edge.From], edge.To)
+	}
+
+	queue := make([]string, 0)
+	for id, degree := range inDegree {
+		if degree == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	visited := 0
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, to := range next[id] {
+			inDegree[to]--
+			if inDegree[to] == 0 {
+				queue = append(queue, to)
+			}
+		}
+	}
+
+	if visited != len(inDegree) {
+		return fmt.Errorf("spawned nodes would create a cycle")
+	}
+	return nil
+}
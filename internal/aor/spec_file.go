@@ -0,0 +1,223 @@
+package aor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadWorkflowSpecFile reads a workflow spec from a JSON or YAML file
+func LoadWorkflowSpecFile(path string) (*WorkflowSpec, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - caller-provided spec path
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec file: %w", err)
+	}
+
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+
+	return ParseWorkflowSpec(data, format)
+}
+
+// ParseWorkflowSpec decodes a workflow spec. YAML documents may use duration
+// strings such as "30s" for step timeouts, and anchors and merge keys. Specs
+// in either format may define macros, expanded before the spec is validated.
+func ParseWorkflowSpec(data []byte, format string) (*WorkflowSpec, error) {
+	var raw map[string]interface{}
+
+	switch format {
+	case "json":
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse spec: %w", err)
+		}
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse spec: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported spec format: %s", format)
+	}
+
+	if err := expandSpecMacros(raw); err != nil {
+		return nil, fmt.Errorf("failed to expand spec macros: %w", err)
+	}
+	if err := normalizeStepDurations(raw); err != nil {
+		return nil, err
+	}
+	if err := normalizeSLADuration(raw); err != nil {
+		return nil, err
+	}
+	if err := normalizeSLODurations(raw); err != nil {
+		return nil, err
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize spec: %w", err)
+	}
+
+	var spec WorkflowSpec
+	if err := json.Unmarshal(normalized, &spec); err != nil {
+		return nil, fmt.Errorf("failed to decode spec: %w", err)
+	}
+
+	if err := ValidateEnvironments(spec.Metadata.Environments); err != nil {
+		return nil, err
+	}
+	if err := ValidatePorts(spec.DAG); err != nil {
+		return nil, err
+	}
+	if err := ValidateStepSLOs(spec.DAG); err != nil {
+		return nil, err
+	}
+	if err := ValidateStepPlacements(spec.DAG); err != nil {
+		return nil, err
+	}
+	if err := ValidateWebhookTriggers(spec.Metadata.Webhooks); err != nil {
+		return nil, err
+	}
+	if err := ValidateScheduleTriggers(spec.Metadata.Schedules); err != nil {
+		return nil, err
+	}
+	if err := ValidatePostProcessors(spec.Metadata.PostProcess); err != nil {
+		return nil, err
+	}
+	if _, err := resolveConversation(spec.DAG, spec.Metadata.Conversation); err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+// normalizeStepDurations converts string step timeouts to nanoseconds
+func normalizeStepDurations(raw map[string]interface{}) error {
+	dag, ok := raw["dag"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	steps, ok := dag["steps"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	for _, s := range steps {
+		step, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, ok := step["timeout"].(string)
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q for step %v: %w", value, step["id"], err)
+		}
+		step["timeout"] = int64(d)
+	}
+
+	return nil
+}
+
+// normalizeSLADuration converts a string metadata.sla.duration to nanoseconds
+func normalizeSLADuration(raw map[string]interface{}) error {
+	metadata, ok := raw["metadata"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	sla, ok := metadata["sla"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	value, ok := sla["duration"].(string)
+	if !ok {
+		return nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid SLA duration %q: %w", value, err)
+	}
+	sla["duration"] = int64(d)
+
+	return nil
+}
+
+// MarshalWorkflowSpec encodes a spec as an authoring file in the format read
+// by ParseWorkflowSpec, writing step timeouts as duration strings and leaving
+// out server-assigned fields
+func MarshalWorkflowSpec(spec *WorkflowSpec, format string) ([]byte, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal spec: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to marshal spec: %w", err)
+	}
+
+	for _, key := range []string{"id", "org_id", "created", "updated", "content_hash"} {
+		delete(raw, key)
+	}
+	if metadata, ok := raw["metadata"].(map[string]interface{}); ok {
+		for key, value := range metadata {
+			if value == nil || value == "" {
+				delete(metadata, key)
+			}
+		}
+	}
+	if dag, ok := raw["dag"].(map[string]interface{}); ok {
+		steps, _ := dag["steps"].([]interface{})
+		for _, s := range steps {
+			step, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if timeout, ok := step["timeout"].(float64); ok && timeout > 0 {
+				step["timeout"] = time.Duration(timeout).String()
+			} else {
+				delete(step, "timeout")
+			}
+			if step["conditions"] == nil {
+				delete(step, "conditions")
+			}
+			if step["description"] == "" {
+				delete(step, "description")
+			}
+			if slo, ok := step["slo"].(map[string]interface{}); ok {
+				for _, key := range []string{"latency_p95", "window"} {
+					if d, ok := slo[key].(float64); ok {
+						slo[key] = time.Duration(d).String()
+					}
+				}
+			}
+		}
+	}
+
+	switch format {
+	case "json":
+		out, err := json.MarshalIndent(raw, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal spec: %w", err)
+		}
+		return append(out, '\n'), nil
+	case "yaml", "yml":
+		var out bytes.Buffer
+		encoder := yaml.NewEncoder(&out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(raw); err != nil {
+			return nil, fmt.Errorf("failed to marshal spec: %w", err)
+		}
+		return out.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported spec format: %s", format)
+	}
+}
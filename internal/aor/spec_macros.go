@@ -0,0 +1,197 @@
+package aor
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// specMacrosKey holds a spec's reusable definitions, keyed by name
+	specMacrosKey = "macros"
+	// macroUseKey references one macro, or a list of them, from any object
+	macroUseKey = "$use"
+)
+
+var macroNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]{0,63}$`)
+
+// expandSpecMacros replaces macro references in a raw spec with the macro
+// bodies and drops the macros block. An object holding only {$use: name}
+// becomes the macro itself, and a list macro used as a list item is spliced
+// into the list. Otherwise the used macros are merged into the object in
+// order, its own keys taking precedence; nested objects merge key by key.
+// Macros may use other macros, but not themselves.
+func expandSpecMacros(raw map[string]interface{}) error {
+	macros := map[string]interface{}{}
+	if defs, ok := raw[specMacrosKey]; ok {
+		if macros, ok = defs.(map[string]interface{}); !ok {
+			return fmt.Errorf("macros must map names to definitions")
+		}
+		for name := range macros {
+			if !macroNamePattern.MatchString(name) {
+				return fmt.Errorf("invalid macro name %q: use letters, digits, '_', '.' and '-'", name)
+			}
+		}
+		delete(raw, specMacrosKey)
+	}
+
+	e := &macroExpander{macros: macros, resolved: make(map[string]interface{})}
+	for key, value := range raw {
+		expanded, err := e.expand(value, nil)
+		if err != nil {
+			return err
+		}
+		raw[key] = expanded
+	}
+	return nil
+}
+
+type macroExpander struct {
+	macros   map[string]interface{}
+	resolved map[string]interface{} // Macro bodies with their own references expanded
+}
+
+// resolve returns a copy of a macro's expanded body; stack is the chain of
+// macros being expanded, used to report cycles
+func (e *macroExpander) resolve(name string, stack []string) (interface{}, error) {
+	if slices.Contains(stack, name) {
+		return nil, fmt.Errorf("macro cycle: %s", strings.Join(append(stack, name), " -> "))
+	}
+	if body, ok := e.resolved[name]; ok {
+		return copyMacroValue(body), nil
+	}
+	body, ok := e.macros[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown macro %q", name)
+	}
+
+	expanded, err := e.expand(body, append(slices.Clone(stack), name))
+	if err != nil {
+		return nil, err
+	}
+	e.resolved[name] = expanded
+	return copyMacroValue(expanded), nil
+}
+
+func (e *macroExpander) expand(value interface{}, stack []string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		names, err := macroUses(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 1 && len(v) == 1 {
+			return e.resolve(names[0], stack)
+		}
+
+		out := make(map[string]interface{}, len(v))
+		for _, name := range names {
+			body, err := e.resolve(name, stack)
+			if err != nil {
+				return nil, err
+			}
+			fields, ok := body.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("macro %q is not an object, so it cannot be merged with other keys", name)
+			}
+			mergeMacroFields(out, fields)
+		}
+		own := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if key == macroUseKey {
+				continue
+			}
+			expanded, err := e.expand(item, stack)
+			if err != nil {
+				return nil, err
+			}
+			own[key] = expanded
+		}
+		mergeMacroFields(out, own)
+		return out, nil
+
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			expanded, err := e.expand(item, stack)
+			if err != nil {
+				return nil, err
+			}
+			if items, ok := expanded.([]interface{}); ok && isMacroReference(item) {
+				out = append(out, items...)
+				continue
+			}
+			out = append(out, expanded)
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+// macroUses returns the macros an object references
+func macroUses(object map[string]interface{}) ([]string, error) {
+	use, ok := object[macroUseKey]
+	if !ok {
+		return nil, nil
+	}
+	switch v := use.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		names := make([]string, 0, len(v))
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must name macros", macroUseKey)
+			}
+			names = append(names, name)
+		}
+		return names, nil
+	}
+	return nil, fmt.Errorf("%s must be a macro name or a list of names", macroUseKey)
+}
+
+func isMacroReference(value interface{}) bool {
+	object, ok := value.(map[string]interface{})
+	if !ok || len(object) != 1 {
+		return false
+	}
+	_, ok = object[macroUseKey].(string)
+	return ok
+}
+
+// mergeMacroFields sets fields on dst, merging objects present in both
+func mergeMacroFields(dst, fields map[string]interface{}) {
+	for key, value := range fields {
+		existing, ok := dst[key].(map[string]interface{})
+		override, isObject := value.(map[string]interface{})
+		if ok && isObject {
+			merged := copyMacroValue(existing).(map[string]interface{})
+			mergeMacroFields(merged, override)
+			dst[key] = merged
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// copyMacroValue deep-copies a decoded value, so every use of a macro can be
+// changed independently by later normalization
+func copyMacroValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = copyMacroValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = copyMacroValue(item)
+		}
+		return out
+	}
+	return value
+}
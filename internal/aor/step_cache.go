@@ -0,0 +1,230 @@
+package aor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	stepCachePrefix = "stepcache:"
+	// stepCacheRunPrefix indexes the entries each run produced so they can be
+	// purged with the run
+	stepCacheRunPrefix = "stepcache:run:"
+	// stepCacheTTL bounds how long a step output can be reused
+	stepCacheTTL = 7 * 24 * time.Hour
+)
+
+// Step cache outcomes recorded in metrics
+const (
+	StepCacheHit   = "hit"
+	StepCacheMiss  = "miss"
+	StepCacheStore = "store"
+)
+
+// cachedStep is a successful step output that later runs may reuse
+type cachedStep struct {
+	Output      map[string]interface{} `json:"output"`
+	CostCents   int64                  `json:"cost_cents"`
+	Provider    string                 `json:"provider,omitempty"`
+	Model       string                 `json:"model,omitempty"`
+	SourceRunID uuid.UUID              `json:"source_run_id"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// StepCache memoizes step outputs by node config and resolved inputs. When a
+// run opts in, a step whose config and inputs match an earlier execution is
+// completed from the cache, so re-running a workflow only executes the steps
+// downstream of a change.
+type StepCache struct {
+	redis *redis.Client
+}
+
+func NewStepCache(redisClient *redis.Client) *StepCache {
+	return &StepCache{redis: redisClient}
+}
+
+// Get returns the cached output for a key, or nil on a miss
+func (c *StepCache) Get(ctx context.Context, key string) (*cachedStep, error) {
+	data, err := c.redis.Get(ctx, stepCachePrefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read step cache: %w", err)
+	}
+	var entry cachedStep
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode cached step: %w", err)
+	}
+	return &entry, nil
+}
+
+// Put stores a successful task result under the task's cache key
+func (c *StepCache) Put(ctx context.Context, task *Task, result *TaskResult) error {
+	data, err := json.Marshal(cachedStep{
+		Output:      result.Output,
+		CostCents:   result.CostCents,
+		Provider:    result.Provider,
+		Model:       result.Model,
+		SourceRunID: task.RunID,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode cached step: %w", err)
+	}
+	runKey := stepCacheRunPrefix + task.RunID.String()
+	pipe := c.redis.TxPipeline()
+	pipe.Set(ctx, stepCachePrefix+task.CacheKey, data, stepCacheTTL)
+	pipe.SAdd(ctx, runKey, task.CacheKey)
+	pipe.Expire(ctx, runKey, stepCacheTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to write step cache: %w", err)
+	}
+	stepCacheLookups.Inc(StepCacheStore)
+	return nil
+}
+
+// PurgeRuns removes every cache entry the given runs produced and returns how
+// many were deleted
+func (c *StepCache) PurgeRuns(ctx context.Context, runIDs []uuid.UUID) (int64, error) {
+	var purged int64
+	for _, runID := range runIDs {
+		runKey := stepCacheRunPrefix + runID.String()
+		cacheKeys, err := c.redis.SMembers(ctx, runKey).Result()
+		if err != nil {
+			return purged, fmt.Errorf("failed to read step cache index: %w", err)
+		}
+
+		keys := make([]string, 0, len(cacheKeys)+1)
+		for _, cacheKey := range cacheKeys {
+			keys = append(keys, stepCachePrefix+cacheKey)
+		}
+		keys = append(keys, runKey)
+
+		deleted, err := c.redis.Del(ctx, keys...).Result()
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge step cache: %w", err)
+		}
+		if deleted > 0 && len(cacheKeys) > 0 {
+			purged += deleted - 1 // The index itself is not an entry
+		}
+	}
+	return purged, nil
+}
+
+// CountRuns returns how many cache entries the given runs still have
+func (c *StepCache) CountRuns(ctx context.Context, runIDs []uuid.UUID) (int64, error) {
+	var remaining int64
+	for _, runID := range runIDs {
+		cacheKeys, err := c.redis.SMembers(ctx, stepCacheRunPrefix+runID.String()).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read step cache index: %w", err)
+		}
+		for _, cacheKey := range cacheKeys {
+			n, err := c.redis.Exists(ctx, stepCachePrefix+cacheKey).Result()
+			if err != nil {
+				return 0, fmt.Errorf("failed to read step cache: %w", err)
+			}
+			remaining += n
+		}
+	}
+	return remaining, nil
+}
+
+// stepCacheKey identifies a step execution by org, workflow, step, node type,
+// config and resolved inputs. The spec version is left out so steps that did
+// not change between versions still match.
+func stepCacheKey(orgID uuid.UUID, workflow string, node *Node, inputs map[string]interface{}) (string, error) {
+	data, err := json.Marshal(struct {
+		OrgID    uuid.UUID              `json:"org_id"`
+		Workflow string                 `json:"workflow"`
+		StepID   string                 `json:"step_id"`
+		Type     string                 `json:"type"`
+		Config   map[string]interface{} `json:"config"`
+		Inputs   map[string]interface{} `json:"inputs"`
+	}{orgID, workflow, node.ID, node.Type, node.Config, inputs})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash step: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// stepCacheable reports whether a step's output may be reused. Steps opt in
+// or out with "cache"; HTTP calls and sub-workflows are skipped by default
+// since they may have side effects.
+func stepCacheable(node *Node) bool {
+	if enabled, ok := node.Config["cache"].(bool); ok {
+		return enabled
+	}
+	switch ExecutorType(node.Type) {
+	case ExecutorTypeHTTP, ExecutorTypeWorkflow:
+		return false
+	}
+	return true
+}
+
+// reuseSteps reports whether a run asked to reuse cached step outputs
+func reuseSteps(metadata map[string]interface{}) bool {
+	reuse, _ := metadata["reuse_steps"].(bool)
+	return reuse
+}
+
+// reuseCachedStep completes a task from the step cache when an earlier run
+// executed the same step with the same inputs. On a miss the task is tagged
+// so the worker stores its output.
+func (s *Scheduler) reuseCachedStep(ctx context.Context, task *Task) (bool, error) {
+	key, err := stepCacheKey(task.OrgID, task.Workflow, task.Node, task.Inputs)
+	if err != nil {
+		return false, err
+	}
+	task.CacheKey = key
+
+	entry, err := s.stepCache.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		stepCacheLookups.Inc(StepCacheMiss)
+		return false, nil
+	}
+	if err := s.completeFromCache(ctx, task, entry); err != nil {
+		return false, err
+	}
+	stepCacheLookups.Inc(StepCacheHit)
+	log.Printf("Reused output of step %s from run %s", task.NodeID, entry.SourceRunID)
+	return true, nil
+}
+
+// completeFromCache publishes a cached output as the task's result so it
+// flows through the same completion path as an executed step
+func (s *Scheduler) completeFromCache(ctx context.Context, task *Task, entry *cachedStep) error {
+	result := &TaskResult{
+		TaskID:     task.ID,
+		RunID:      task.RunID,
+		NodeID:     task.NodeID,
+		Status:     TaskStatusSucceeded,
+		Output:     entry.Output,
+		ExecutedAt: time.Now(),
+		Provider:   entry.Provider,
+		Model:      entry.Model,
+		Cached:     true,
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached result: %w", err)
+	}
+	if _, err := s.js.Publish("agentflow.results", data); err != nil {
+		return fmt.Errorf("failed to publish cached result: %w", err)
+	}
+	stepCacheSavedCost.Add(float64(entry.CostCents), task.Workflow)
+	return nil
+}
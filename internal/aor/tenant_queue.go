@@ -0,0 +1,563 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	nats "github.com/nats-io/nats.go"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	tenantWeightsKey  = "tasks:weights"
+	tenantInFlightKey = "tasks:inflight"
+	// tenantOrgInFlightPrefix keys each org's dispatched tasks, for draining
+	tenantOrgInFlightPrefix = "tasks:inflight:org:"
+
+	// Queues from before lanes existed; drained into the default lane on start
+	legacyQueuesKey   = "tasks:tenants"
+	legacyQueuePrefix = "tasks:tenant:"
+
+	// DefaultTenantWeight applies to orgs without an admin-set weight
+	DefaultTenantWeight = 1
+	// MaxTenantWeight bounds how far one org can be favored over another
+	MaxTenantWeight = 1000
+	// tenantStride is the virtual time an org with weight 1 is charged per task
+	tenantStride = 1000000.0
+	// tenantInFlightLease frees a dispatch slot whose result never arrived
+	tenantInFlightLease = 30 * time.Minute
+	// tenantDispatchInterval is how often the dispatcher drains the queues
+	tenantDispatchInterval = 100 * time.Millisecond
+	// tenantDispatchBatch bounds the tasks published per dispatch pass
+	tenantDispatchBatch = 200
+)
+
+// pushTaskScript appends a task to its org's queue and activates the org at
+// the current virtual time, so idle orgs don't bank credit while away
+var pushTaskScript = redis.NewScript(`
+	redis.call('RPUSH', KEYS[2], ARGV[2])
+	if not redis.call('ZSCORE', KEYS[1], ARGV[1]) then
+		local vtime = tonumber(redis.call('GET', KEYS[3]) or '0')
+		redis.call('ZADD', KEYS[1], vtime, ARGV[1])
+	end
+	return redis.call('LLEN', KEYS[2])
+`)
+
+// popTaskScript takes the next task in a lane from the org with the lowest
+// virtual time and charges the org a stride inversely proportional to its
+// weight. Orgs in maintenance, listed in KEYS[5], are passed over. KEYS[6..]
+// are every lane's in-flight set with its reserved slots in ARGV[9..];
+// ARGV[7] is the position of the popping lane. Nothing is returned while the
+// in-flight cap is reached, or while the lane has used its own reservation
+// and the capacity no lane reserves is taken.
+var popTaskScript = redis.NewScript(`
+	local now = tonumber(ARGV[3])
+	redis.call('ZREMRANGEBYSCORE', KEYS[4], '-inf', now)
+	local maxInFlight = tonumber(ARGV[4])
+	local lane = tonumber(ARGV[7])
+	if maxInFlight > 0 then
+		if redis.call('ZCARD', KEYS[4]) >= maxInFlight then
+			return false
+		end
+		local shared, sharedUsed, own, ownReserved = maxInFlight, 0, 0, 0
+		for i = 6, #KEYS do
+			redis.call('ZREMRANGEBYSCORE', KEYS[i], '-inf', now)
+			local reserved = tonumber(ARGV[i + 3])
+			local count = redis.call('ZCARD', KEYS[i])
+			shared = shared - reserved
+			if count > reserved then
+				sharedUsed = sharedUsed + count - reserved
+			end
+			if i - 5 == lane then
+				own, ownReserved = count, reserved
+			end
+		end
+		if own >= ownReserved and sharedUsed >= shared then
+			return false
+		end
+	end
+
+	local skipped = 0
+	while true do
+		local head = redis.call('ZRANGE', KEYS[1], skipped, skipped, 'WITHSCORES')
+		if #head == 0 then
+			return false
+		end
+		local org, pass = head[1], tonumber(head[2])
+		local queue = ARGV[1] .. org
+		local entry = false
+		if redis.call('HEXISTS', KEYS[5], 'org:' .. org) == 1 then
+			skipped = skipped + 1
+		else
+			entry = redis.call('LPOP', queue)
+			if not entry then
+				redis.call('ZREM', KEYS[1], org)
+			end
+		end
+		if entry then
+			local weight = tonumber(redis.call('HGET', KEYS[2], org) or ARGV[2])
+			if weight == nil or weight < 1 then
+				weight = tonumber(ARGV[2])
+			end
+			redis.call('SET', KEYS[3], pass)
+			if redis.call('LLEN', queue) == 0 then
+				redis.call('ZREM', KEYS[1], org)
+			else
+				redis.call('ZADD', KEYS[1], pass + tonumber(ARGV[6]) / weight, org)
+			end
+			local taskID = string.match(entry, '^(%S+)')
+			local orgInFlight = ARGV[8] .. org
+			redis.call('ZADD', KEYS[4], now + tonumber(ARGV[5]), taskID)
+			redis.call('ZADD', KEYS[5 + lane], now + tonumber(ARGV[5]), taskID)
+			redis.call('ZADD', orgInFlight, now + tonumber(ARGV[5]), taskID)
+			redis.call('EXPIRE', orgInFlight, tonumber(ARGV[5]))
+			return {org, entry}
+		end
+	end
+`)
+
+// TenantQueue partitions ready tasks by lane and org in Redis and dispatches
+// them to the task stream. Lanes are drained in priority order within their
+// reserved capacity; inside a lane, weighted fair scheduling keeps one org's
+// fan-out waiting behind its own tasks instead of starving other orgs.
+type TenantQueue struct {
+	redis       *redis.Client
+	js          nats.JetStreamContext
+	maxInFlight int
+	lanes       []Lane
+
+	// onUnschedulable records a task no worker can run before its failure is published
+	onUnschedulable func(ctx context.Context, task *Task, message string) error
+}
+
+func NewTenantQueue(redisClient *redis.Client, js nats.JetStreamContext, maxInFlight int, lanes []Lane) *TenantQueue {
+	return &TenantQueue{redis: redisClient, js: js, maxInFlight: maxInFlight, lanes: lanes}
+}
+
+// queuedTask is a task waiting in its org's queue
+type queuedTask struct {
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	Task       *Task     `json:"task"`
+}
+
+// TenantQueueStats describes one org's partition of the task queue, summed
+// across lanes
+type TenantQueueStats struct {
+	OrgID          uuid.UUID     `json:"org_id"`
+	Weight         int           `json:"weight"`
+	Depth          int64         `json:"depth"`
+	OldestWait     time.Duration `json:"oldest_wait"`
+	VirtualTime    float64       `json:"virtual_time,omitempty"`
+	CustomWeighted bool          `json:"custom_weighted"`
+}
+
+// TenantQueueSnapshot is the state of all org partitions
+type TenantQueueSnapshot struct {
+	Tenants     []TenantQueueStats `json:"tenants"`
+	InFlight    int64              `json:"in_flight"`
+	MaxInFlight int                `json:"max_in_flight"`
+}
+
+// Push adds a ready task to its org's queue in the task's lane
+func (q *TenantQueue) Push(ctx context.Context, task *Task) error {
+	entry, err := encodeQueueEntry(task, time.Now())
+	if err != nil {
+		return err
+	}
+
+	lane := q.lane(task.Lane).Name
+	org := task.OrgID.String()
+	keys := []string{laneKey(lane, "tenants"), laneKey(lane, "tenant:"+org), laneKey(lane, "vtime")}
+	if err := pushTaskScript.Run(ctx, q.redis, keys, org, entry).Err(); err != nil {
+		return fmt.Errorf("failed to queue task: %w", err)
+	}
+	return nil
+}
+
+// lane returns the named lane, falling back to the default lane for tasks
+// queued under a lane that is no longer configured
+func (q *TenantQueue) lane(name string) Lane {
+	var fallback Lane
+	for _, lane := range q.lanes {
+		if lane.Name == name {
+			return lane
+		}
+		if lane.Name == DefaultLane {
+			fallback = lane
+		}
+	}
+	return fallback
+}
+
+// Complete frees the dispatch slot held by a task once its result arrives
+func (q *TenantQueue) Complete(ctx context.Context, taskID, orgID uuid.UUID) error {
+	pipe := q.redis.Pipeline()
+	pipe.ZRem(ctx, tenantInFlightKey, taskID.String())
+	if orgID != uuid.Nil {
+		pipe.ZRem(ctx, tenantOrgInFlightPrefix+orgID.String(), taskID.String())
+	}
+	for _, lane := range q.lanes {
+		pipe.ZRem(ctx, laneKey(lane.Name, "inflight"), taskID.String())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to release task slot: %w", err)
+	}
+	return nil
+}
+
+// Run dispatches queued tasks until ctx is done or shutdown is closed
+func (q *TenantQueue) Run(ctx context.Context, shutdown <-chan struct{}) {
+	q.drainLegacyQueues(ctx)
+
+	ticker := time.NewTicker(tenantDispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-shutdown:
+			return
+		case <-ticker.C:
+			q.dispatch(ctx)
+		}
+	}
+}
+
+// dispatch publishes queued tasks lane by lane in priority order, in fair
+// order within each lane, until the lanes are empty or out of capacity or
+// the batch is used up
+func (q *TenantQueue) dispatch(ctx context.Context) {
+	holds, err := q.maintenanceHolds(ctx)
+	if err != nil {
+		log.Printf("Failed to read maintenance holds: %v", err)
+		return
+	}
+	if holds[maintenanceAllField] {
+		return
+	}
+	paused, err := q.pausedRuns(ctx)
+	if err != nil {
+		log.Printf("Failed to read paused runs: %v", err)
+		return
+	}
+
+	budget := tenantDispatchBatch
+	var regions []RegionStatus    // Loaded on the first placed task of the pass
+	var workers []WorkerHeartbeat // Loaded on the first task with a selector
+	for i, lane := range q.lanes {
+		if holds[maintenanceLanePrefix+lane.Name] {
+			continue
+		}
+		for ; budget > 0; budget-- {
+			org, entry, ok, err := q.pop(ctx, i)
+			if err != nil {
+				log.Printf("Failed to dequeue task from lane %s: %v", lane.Name, err)
+				return
+			}
+			if !ok {
+				break
+			}
+
+			queued, err := decodeQueueEntry(entry)
+			if err != nil {
+				log.Printf("Dropping malformed queued task for org %s: %v", org, err)
+				continue
+			}
+			if paused[queued.Task.RunID.String()] {
+				parked, err := q.park(ctx, lane.Name, org, queued.Task, entry)
+				if err != nil {
+					log.Printf("Failed to park task %s of paused run %s, requeueing: %v", queued.Task.ID, queued.Task.RunID, err)
+					q.requeue(ctx, lane.Name, org, queued.Task.ID, entry)
+					return
+				}
+				if parked {
+					continue
+				}
+			}
+			wait := time.Since(queued.EnqueuedAt)
+			tenantQueueWait.Observe(wait.Seconds(), org)
+			q.recordLaneWait(ctx, lane, wait)
+
+			data, err := json.Marshal(queued.Task)
+			if err != nil {
+				log.Printf("Dropping task %s: failed to marshal: %v", queued.Task.ID, err)
+				continue
+			}
+			if queued.Task.Placement != nil && len(queued.Task.Placement.Selector) > 0 {
+				sent, err := q.dispatchSelected(ctx, queued, data, &workers)
+				if err != nil {
+					log.Printf("Failed to dispatch task %s to a matching worker, requeueing: %v", queued.Task.ID, err)
+					q.requeue(ctx, lane.Name, org, queued.Task.ID, entry)
+					return
+				}
+				if !sent {
+					q.requeue(ctx, lane.Name, org, queued.Task.ID, entry)
+					break // Retry the lane next pass, when matching workers may have joined
+				}
+				continue
+			}
+			if queued.Task.Placement != nil {
+				placed, err := q.dispatchPlaced(ctx, queued.Task, data, &regions)
+				if err != nil {
+					log.Printf("Failed to place task %s, requeueing: %v", queued.Task.ID, err)
+					q.requeue(ctx, lane.Name, org, queued.Task.ID, entry)
+					return
+				}
+				if !placed {
+					q.requeue(ctx, lane.Name, org, queued.Task.ID, entry)
+					break // Retry the lane next pass, when workers may have joined
+				}
+				continue
+			}
+			if _, err := q.js.Publish("agentflow.tasks", data); err != nil {
+				log.Printf("Failed to publish task %s, requeueing: %v", queued.Task.ID, err)
+				q.requeue(ctx, lane.Name, org, queued.Task.ID, entry)
+				return
+			}
+		}
+	}
+}
+
+func (q *TenantQueue) pop(ctx context.Context, laneIndex int) (string, string, bool, error) {
+	lane := q.lanes[laneIndex].Name
+	keys := []string{laneKey(lane, "tenants"), tenantWeightsKey, laneKey(lane, "vtime"), tenantInFlightKey, tenantMaintenanceKey}
+	args := []interface{}{laneKey(lane, "tenant:"), DefaultTenantWeight, time.Now().Unix(), q.maxInFlight,
+		int64(tenantInFlightLease.Seconds()), tenantStride, laneIndex + 1, tenantOrgInFlightPrefix}
+	for _, l := range q.lanes {
+		keys = append(keys, laneKey(l.Name, "inflight"))
+		args = append(args, l.ReservedSlots)
+	}
+	result, err := popTaskScript.Run(ctx, q.redis, keys, args...).StringSlice()
+	if err == redis.Nil {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+	if len(result) != 2 {
+		return "", "", false, fmt.Errorf("unexpected dequeue result")
+	}
+	return result[0], result[1], true, nil
+}
+
+// requeue puts an undelivered task back at the head of its org's queue
+func (q *TenantQueue) requeue(ctx context.Context, lane, org string, taskID uuid.UUID, entry string) {
+	vtime, _ := q.redis.Get(ctx, laneKey(lane, "vtime")).Float64()
+
+	pipe := q.redis.TxPipeline()
+	pipe.LPush(ctx, laneKey(lane, "tenant:"+org), entry)
+	pipe.ZAddNX(ctx, laneKey(lane, "tenants"), redis.Z{Score: vtime, Member: org})
+	pipe.ZRem(ctx, tenantInFlightKey, taskID.String())
+	pipe.ZRem(ctx, laneKey(lane, "inflight"), taskID.String())
+	pipe.ZRem(ctx, tenantOrgInFlightPrefix+org, taskID.String())
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to requeue task %s: %v", taskID, err)
+	}
+}
+
+// drainLegacyQueues moves tasks queued before lanes existed into the default
+// lane so an upgrade doesn't strand them
+func (q *TenantQueue) drainLegacyQueues(ctx context.Context) {
+	orgs, err := q.redis.ZRange(ctx, legacyQueuesKey, 0, -1).Result()
+	if err != nil || len(orgs) == 0 {
+		return
+	}
+
+	lane := q.lane(DefaultLane).Name
+	vtime, _ := q.redis.Get(ctx, laneKey(lane, "vtime")).Float64()
+	for _, org := range orgs {
+		moved := 0
+		for {
+			err := q.redis.LMove(ctx, legacyQueuePrefix+org, laneKey(lane, "tenant:"+org), "LEFT", "RIGHT").Err()
+			if err == redis.Nil {
+				break
+			}
+			if err != nil {
+				log.Printf("Failed to move queued tasks for org %s into lane %s: %v", org, lane, err)
+				return
+			}
+			moved++
+		}
+		if moved > 0 {
+			q.redis.ZAddNX(ctx, laneKey(lane, "tenants"), redis.Z{Score: vtime, Member: org})
+			log.Printf("Moved %d queued tasks for org %s into lane %s", moved, org, lane)
+		}
+		q.redis.ZRem(ctx, legacyQueuesKey, org)
+	}
+}
+
+// SetWeight changes an org's share of dispatch capacity; it takes effect on
+// the next dispatch across all control planes
+func (q *TenantQueue) SetWeight(ctx context.Context, orgID uuid.UUID, weight int) error {
+	if err := validateTenantWeight(weight); err != nil {
+		return err
+	}
+	if err := q.redis.HSet(ctx, tenantWeightsKey, orgID.String(), weight).Err(); err != nil {
+		return fmt.Errorf("failed to set tenant weight: %w", err)
+	}
+	return nil
+}
+
+// ResetWeight returns an org to the default weight
+func (q *TenantQueue) ResetWeight(ctx context.Context, orgID uuid.UUID) error {
+	if err := q.redis.HDel(ctx, tenantWeightsKey, orgID.String()).Err(); err != nil {
+		return fmt.Errorf("failed to reset tenant weight: %w", err)
+	}
+	return nil
+}
+
+// Snapshot reports depth, oldest wait and weight for every org that has
+// queued tasks or a custom weight
+func (q *TenantQueue) Snapshot(ctx context.Context) (*TenantQueueSnapshot, error) {
+	weights, err := q.redis.HGetAll(ctx, tenantWeightsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant weights: %w", err)
+	}
+
+	stats := make(map[string]*TenantQueueStats)
+	statsFor := func(org string) *TenantQueueStats {
+		if s, ok := stats[org]; ok {
+			return s
+		}
+		id, err := uuid.Parse(org)
+		if err != nil {
+			return nil
+		}
+		s := &TenantQueueStats{OrgID: id, Weight: DefaultTenantWeight}
+		stats[org] = s
+		return s
+	}
+	for org, value := range weights {
+		if s := statsFor(org); s != nil {
+			if weight, err := strconv.Atoi(value); err == nil {
+				s.Weight = weight
+				s.CustomWeighted = true
+			}
+		}
+	}
+
+	now := time.Now()
+	for _, lane := range q.lanes {
+		active, err := q.redis.ZRangeWithScores(ctx, laneKey(lane.Name, "tenants"), 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tenant queues: %w", err)
+		}
+		for _, z := range active {
+			org, _ := z.Member.(string)
+			s := statsFor(org)
+			if s == nil {
+				continue
+			}
+			// Virtual time is per lane; report the org's position in its highest priority lane
+			if s.Depth == 0 {
+				s.VirtualTime = z.Score
+			}
+			depth, oldest, err := q.orgQueueState(ctx, lane.Name, org, now)
+			if err != nil {
+				return nil, err
+			}
+			s.Depth += depth
+			if oldest > s.OldestWait {
+				s.OldestWait = oldest
+			}
+		}
+	}
+
+	inFlight, err := q.redis.ZCount(ctx, tenantInFlightKey, strconv.FormatInt(now.Unix(), 10), "+inf").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count in-flight tasks: %w", err)
+	}
+
+	snapshot := &TenantQueueSnapshot{Tenants: make([]TenantQueueStats, 0, len(stats)), InFlight: inFlight, MaxInFlight: q.maxInFlight}
+	for _, s := range stats {
+		snapshot.Tenants = append(snapshot.Tenants, *s)
+	}
+	sort.Slice(snapshot.Tenants, func(i, j int) bool {
+		a, b := snapshot.Tenants[i], snapshot.Tenants[j]
+		if a.Depth != b.Depth {
+			return a.Depth > b.Depth
+		}
+		return a.OrgID.String() < b.OrgID.String()
+	})
+	return snapshot, nil
+}
+
+// orgQueueState returns the depth and oldest wait of an org's queue in a lane
+func (q *TenantQueue) orgQueueState(ctx context.Context, lane, org string, now time.Time) (int64, time.Duration, error) {
+	queue := laneKey(lane, "tenant:"+org)
+	depth, err := q.redis.LLen(ctx, queue).Result()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read queue depth: %w", err)
+	}
+	head, err := q.redis.LIndex(ctx, queue, 0).Result()
+	if err == redis.Nil {
+		return depth, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read queue head: %w", err)
+	}
+	queued, err := decodeQueueEntry(head)
+	if err != nil {
+		return depth, 0, nil
+	}
+	return depth, now.Sub(queued.EnqueuedAt), nil
+}
+
+func validateTenantWeight(weight int) error {
+	if weight < 1 || weight > MaxTenantWeight {
+		return fmt.Errorf("tenant weight must be between 1 and %d", MaxTenantWeight)
+	}
+	return nil
+}
+
+// encodeQueueEntry prefixes the task ID so the dispatch script can track the
+// in-flight task without decoding JSON
+func encodeQueueEntry(task *Task, enqueuedAt time.Time) (string, error) {
+	data, err := json.Marshal(queuedTask{EnqueuedAt: enqueuedAt, Task: task})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal task: %w", err)
+	}
+	return task.ID.String() + " " + string(data), nil
+}
+
+func decodeQueueEntry(entry string) (*queuedTask, error) {
+	_, data, ok := strings.Cut(entry, " ")
+	if !ok {
+		return nil, fmt.Errorf("missing task ID prefix")
+	}
+	var queued queuedTask
+	if err := json.Unmarshal([]byte(data), &queued); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal queued task: %w", err)
+	}
+	if queued.Task == nil {
+		return nil, fmt.Errorf("queued entry has no task")
+	}
+	return &queued, nil
+}
+
+// SetTenantWeight adjusts an org's share of task dispatch at runtime
+func (cp *ControlPlane) SetTenantWeight(ctx context.Context, orgID uuid.UUID, weight int) error {
+	if err := cp.queue.SetWeight(ctx, orgID, weight); err != nil {
+		return err
+	}
+	log.Printf("Set dispatch weight for org %s to %d", orgID, weight)
+	return nil
+}
+
+// ResetTenantWeight returns an org to the default dispatch weight
+func (cp *ControlPlane) ResetTenantWeight(ctx context.Context, orgID uuid.UUID) error {
+	return cp.queue.ResetWeight(ctx, orgID)
+}
+
+// GetTenantQueues reports per-org queue depth, wait and weight
+func (cp *ControlPlane) GetTenantQueues(ctx context.Context) (*TenantQueueSnapshot, error) {
+	return cp.queue.Snapshot(ctx)
+}
@@ -0,0 +1,208 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	// minTimeoutSamples is how many recent attempts a step needs before its
+	// history is trusted over its configured timeout
+	minTimeoutSamples = 20
+	// timeoutHeadroom multiplies p99 so a slow but healthy attempt finishes
+	timeoutHeadroom = 1.5
+	// timeoutSlack is how far above the suggestion a timeout may be before
+	// it is reported as letting hung steps hold a worker
+	timeoutSlack    = 4
+	minTunedTimeout = 5 * time.Second
+	maxTunedTimeout = 30 * time.Minute // The task deadline
+	// timeoutHistoryTTL bounds how often dispatch re-reads step durations
+	timeoutHistoryTTL = 10 * time.Minute
+)
+
+// StepDurationStats summarizes a step's recent attempt durations
+type StepDurationStats struct {
+	StepID   string        `json:"step_id"`
+	Samples  int           `json:"samples"` // Successful attempts
+	P50      time.Duration `json:"p50"`
+	P95      time.Duration `json:"p95"`
+	P99      time.Duration `json:"p99"`
+	Max      time.Duration `json:"max"`
+	TimedOut int           `json:"timed_out"` // Failed attempts that hit a timeout or deadline
+}
+
+// TimeoutSuggestion is a step timeout tuned from the step's duration history
+type TimeoutSuggestion struct {
+	StepID    string            `json:"step_id"`
+	Current   time.Duration     `json:"current"` // 0 when the step has none
+	Suggested time.Duration     `json:"suggested"`
+	Stats     StepDurationStats `json:"stats"`
+	Reason    string            `json:"reason"`
+	Premature bool              `json:"premature"` // The current timeout cuts off healthy attempts
+}
+
+// attemptContext bounds one attempt of a step by the step's timeout
+func attemptContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// SuggestStepTimeout tunes a step's timeout to p99 with headroom. It returns
+// nil when there is too little history or the current timeout is close enough.
+func SuggestStepTimeout(step Step, stats StepDurationStats) *TimeoutSuggestion {
+	if stats.Samples < minTimeoutSamples || stats.P99 <= 0 {
+		return nil
+	}
+	suggested := time.Duration(float64(stats.P99) * timeoutHeadroom)
+	suggested = min(max(suggested, minTunedTimeout), maxTunedTimeout)
+	suggested = (suggested + time.Second - 1).Truncate(time.Second)
+
+	suggestion := &TimeoutSuggestion{StepID: step.ID, Current: step.Timeout, Suggested: suggested, Stats: stats}
+	switch current := step.Timeout; {
+	case current == 0:
+		suggestion.Reason = fmt.Sprintf("no timeout; p99 over %d attempts is %s", stats.Samples, stats.P99.Round(time.Millisecond))
+	case current < stats.P95:
+		suggestion.Premature = true
+		suggestion.Reason = fmt.Sprintf("timeout %s is below p95 %s, so over 5%% of healthy attempts time out", current, stats.P95.Round(time.Millisecond))
+	case current < stats.P99 || (stats.TimedOut > 0 && current < suggested):
+		suggestion.Premature = true
+		suggestion.Reason = fmt.Sprintf("timeout %s leaves no headroom over p99 %s; %d attempts timed out", current, stats.P99.Round(time.Millisecond), stats.TimedOut)
+	case current > suggested*timeoutSlack:
+		suggestion.Reason = fmt.Sprintf("timeout %s is over %dx p99 %s, so hung attempts hold a worker that long", current, timeoutSlack, stats.P99.Round(time.Millisecond))
+	default:
+		return nil
+	}
+	return suggestion
+}
+
+// SuggestSpecTimeouts tunes the timeout of every step with enough history
+func SuggestSpecTimeouts(spec *WorkflowSpec, history map[string]StepDurationStats) []TimeoutSuggestion {
+	suggestions := make([]TimeoutSuggestion, 0)
+	for _, step := range spec.DAG.Steps {
+		if suggestion := SuggestStepTimeout(step, history[step.ID]); suggestion != nil {
+			suggestions = append(suggestions, *suggestion)
+		}
+	}
+	return suggestions
+}
+
+// addTimeoutSuggestions reports tuned timeouts, warning about the ones that
+// cut off healthy attempts
+func (r *LintReport) addTimeoutSuggestions(suggestions []TimeoutSuggestion) {
+	for _, suggestion := range suggestions {
+		severity := LintSeverityInfo
+		if suggestion.Premature {
+			severity = LintSeverityWarning
+		}
+		r.add(LintFinding{
+			Rule:     LintRuleTimeoutHistory,
+			Severity: severity,
+			StepID:   suggestion.StepID,
+			Message:  suggestion.Reason,
+			Suggestion: fmt.Sprintf("set timeout: %s, p99 with %.1fx headroom, or enable scheduler.auto_tune_timeouts",
+				suggestion.Suggested, timeoutHeadroom),
+		})
+	}
+}
+
+// loadStepDurationStats reads a workflow's step attempt durations over the
+// SLA history window
+func loadStepDurationStats(ctx context.Context, pg *db.PostgresDB, orgID uuid.UUID, workflowName string) (map[string]StepDurationStats, error) {
+	query := `SELECT sr.node_id,
+			  COUNT(*) FILTER (WHERE sr.status = 'succeeded'),
+			  COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM sr.ended_at - sr.started_at)) FILTER (WHERE sr.status = 'succeeded'), 0),
+			  COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM sr.ended_at - sr.started_at)) FILTER (WHERE sr.status = 'succeeded'), 0),
+			  COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM sr.ended_at - sr.started_at)) FILTER (WHERE sr.status = 'succeeded'), 0),
+			  COALESCE(MAX(EXTRACT(EPOCH FROM sr.ended_at - sr.started_at)) FILTER (WHERE sr.status = 'succeeded'), 0),
+			  COUNT(*) FILTER (WHERE sr.status = 'failed' AND (sr.error ILIKE '%deadline exceeded%' OR sr.error ILIKE '%timeout%' OR sr.error ILIKE '%timed out%'))
+			  FROM step_run sr
+			  JOIN workflow_run r ON r.id = sr.workflow_run_id
+			  JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE s.org_id = $1 AND s.name = $2 AND sr.status IN ('succeeded', 'failed')
+			  AND sr.started_at IS NOT NULL AND sr.ended_at > $3
+			  GROUP BY sr.node_id`
+
+	rows, err := pg.QueryContext(ctx, query, orgID, workflowName, time.Now().Add(-slaHistoryWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query step durations: %w", err)
+	}
+	defer rows.Close()
+
+	seconds := func(s float64) time.Duration { return time.Duration(s * float64(time.Second)) }
+	history := make(map[string]StepDurationStats)
+	for rows.Next() {
+		var stats StepDurationStats
+		var p50, p95, p99, maxSeconds float64
+		if err := rows.Scan(&stats.StepID, &stats.Samples, &p50, &p95, &p99, &maxSeconds, &stats.TimedOut); err != nil {
+			return nil, fmt.Errorf("failed to scan step durations: %w", err)
+		}
+		stats.P50, stats.P95, stats.P99, stats.Max = seconds(p50), seconds(p95), seconds(p99), seconds(maxSeconds)
+		history[stats.StepID] = stats
+	}
+	return history, rows.Err()
+}
+
+// SuggestStepTimeouts tunes the timeouts of a stored workflow version from
+// its steps' recent durations
+func (cp *ControlPlane) SuggestStepTimeouts(ctx context.Context, name string, version int) ([]TimeoutSuggestion, error) {
+	spec, err := cp.getWorkflowSpec(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	history, err := loadStepDurationStats(ctx, cp.db, spec.OrgID, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	return SuggestSpecTimeouts(spec, history), nil
+}
+
+type cachedStepDurations struct {
+	history   map[string]StepDurationStats
+	expiresAt time.Time
+}
+
+// TimeoutTuner applies tuned timeouts at dispatch when auto-tuning is enabled
+type TimeoutTuner struct {
+	db *db.PostgresDB
+
+	mu    sync.Mutex
+	cache map[string]cachedStepDurations
+}
+
+func NewTimeoutTuner(pg *db.PostgresDB) *TimeoutTuner {
+	return &TimeoutTuner{db: pg, cache: make(map[string]cachedStepDurations)}
+}
+
+// Timeout returns the timeout a step runs with: the tuned one when its
+// history suggests a change, otherwise the configured one
+func (t *TimeoutTuner) Timeout(ctx context.Context, orgID uuid.UUID, workflowName string, step Step) time.Duration {
+	key := orgID.String() + "/" + workflowName
+	t.mu.Lock()
+	cached, ok := t.cache[key]
+	t.mu.Unlock()
+
+	if !ok || time.Now().After(cached.expiresAt) {
+		history, err := loadStepDurationStats(ctx, t.db, orgID, workflowName)
+		if err != nil {
+			log.Printf("Timeout tuning unavailable for workflow %s, using configured timeouts: %v", workflowName, err)
+			return step.Timeout
+		}
+		cached = cachedStepDurations{history: history, expiresAt: time.Now().Add(timeoutHistoryTTL)}
+		t.mu.Lock()
+		t.cache[key] = cached
+		t.mu.Unlock()
+	}
+
+	if suggestion := SuggestStepTimeout(step, cached.history[step.ID]); suggestion != nil {
+		return suggestion.Suggested
+	}
+	return step.Timeout
+}
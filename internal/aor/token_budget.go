@@ -0,0 +1,132 @@
+package aor
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+)
+
+// TokenBudgetAction is what a step does when its prompt is over budget
+type TokenBudgetAction string
+
+const (
+	TokenBudgetCompress TokenBudgetAction = "compress"
+	TokenBudgetFail     TokenBudgetAction = "fail"
+)
+
+// TokenBudget caps the prompt and completion tokens of one LLM call, configured
+// as token_budget: {max_total_tokens: 8000, on_exceed: compress}. The step's
+// max_tokens is reserved for the completion and the rest is left for the prompt.
+type TokenBudget struct {
+	MaxTotalTokens int               `json:"max_total_tokens"`
+	OnExceed       TokenBudgetAction `json:"on_exceed"`
+}
+
+// TokenBudgetExceededError is returned when a prompt does not fit its step's
+// token budget; sending it again would not fit either
+type TokenBudgetExceededError struct {
+	PromptTokens   int  `json:"prompt_tokens"`
+	AllowedTokens  int  `json:"allowed_tokens"`
+	MaxTotalTokens int  `json:"max_total_tokens"`
+	Compressed     bool `json:"compressed"`
+}
+
+func (e *TokenBudgetExceededError) Error() string {
+	if e.Compressed {
+		return fmt.Sprintf("token budget exceeded: prompt needs ~%d tokens after compression but max_total_tokens %d leaves %d", e.PromptTokens, e.MaxTotalTokens, e.AllowedTokens)
+	}
+	return fmt.Sprintf("token budget exceeded: prompt needs ~%d tokens but max_total_tokens %d leaves %d", e.PromptTokens, e.MaxTotalTokens, e.AllowedTokens)
+}
+
+// ErrorClass reports token budget failures to telemetry
+func (e *TokenBudgetExceededError) ErrorClass() cas.ErrorClass {
+	return cas.ErrorClassTokenBudget
+}
+
+// Unsent implements cas.UnsentError
+func (e *TokenBudgetExceededError) Unsent() {}
+
+// parseTokenBudget reads a step's token_budget config, returning nil when it has none
+func parseTokenBudget(config map[string]interface{}) (*TokenBudget, error) {
+	raw, ok := config["token_budget"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	budget := &TokenBudget{OnExceed: TokenBudgetCompress}
+	if v, ok := raw["max_total_tokens"].(float64); ok {
+		budget.MaxTotalTokens = int(v)
+	}
+	if budget.MaxTotalTokens <= 0 {
+		return nil, fmt.Errorf("token_budget.max_total_tokens must be positive")
+	}
+	if _, maxTokens := callTokens(config); maxTokens >= budget.MaxTotalTokens {
+		return nil, fmt.Errorf("max_tokens and reasoning budget (%d) leave no room for the prompt in max_total_tokens %d", maxTokens, budget.MaxTotalTokens)
+	}
+
+	if action, ok := raw["on_exceed"].(string); ok && action != "" {
+		budget.OnExceed = TokenBudgetAction(action)
+	}
+	switch budget.OnExceed {
+	case TokenBudgetCompress, TokenBudgetFail:
+	default:
+		return nil, fmt.Errorf("invalid token_budget.on_exceed %q: use compress or fail", budget.OnExceed)
+	}
+
+	return budget, nil
+}
+
+// enforceTokenBudget fits a task's inputs into its step's prompt allowance,
+// compressing them in place or failing before the provider is called
+func enforceTokenBudget(task *Task) error {
+	if task.Node == nil {
+		return nil
+	}
+	budget, err := parseTokenBudget(task.Node.Config)
+	if err != nil || budget == nil {
+		return err
+	}
+
+	_, maxTokens := callTokens(task.Node.Config)
+	allowed := budget.MaxTotalTokens - maxTokens
+	// Media can't be compressed, so only text inputs are trimmed to fit
+	text, media, err := extractMedia(task.Inputs)
+	if err != nil {
+		return nil // Invalid media is reported when the prompt is rendered
+	}
+	mediaTokens := (&renderedPrompt{Media: media}).mediaTokens()
+	compressor := scl.NewCompressor()
+	promptTokens := compressor.EstimateTokens(text) + mediaTokens
+	if promptTokens <= allowed {
+		return nil
+	}
+
+	exceeded := &TokenBudgetExceededError{PromptTokens: promptTokens, AllowedTokens: allowed, MaxTotalTokens: budget.MaxTotalTokens}
+	if budget.OnExceed == TokenBudgetFail {
+		llmTokenBudgets.Inc("rejected")
+		return exceeded
+	}
+
+	compressed, result := compressor.Compress(text, allowed-mediaTokens)
+	if !result.Fits {
+		exceeded.PromptTokens, exceeded.Compressed = result.Tokens+mediaTokens, true
+		llmTokenBudgets.Inc("rejected")
+		return exceeded
+	}
+	inputs, _ := compressed.(map[string]interface{})
+	if inputs == nil {
+		inputs = make(map[string]interface{}, len(task.Inputs))
+	}
+	for name, value := range task.Inputs {
+		if _, isText := text[name]; !isText {
+			inputs[name] = value
+		}
+	}
+	task.Inputs = inputs
+	llmTokenBudgets.Inc("compressed")
+	log.Printf("Compressed inputs of task %s from ~%d to ~%d tokens (%d fields trimmed, %d items dropped)",
+		task.ID, result.OriginalTokens, result.Tokens, result.TrimmedFields, result.DroppedItems)
+	return nil
+}
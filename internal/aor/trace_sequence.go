@@ -0,0 +1,34 @@
+package aor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// traceSequenceRetention keeps a run's counter alive well past its last event
+const traceSequenceRetention = 7 * 24 * time.Hour
+
+func traceSequenceKey(runID uuid.UUID) string {
+	return "trace:seq:" + runID.String()
+}
+
+// NextTraceSequences reserves n consecutive trace sequence numbers for a run
+// and returns the first. Sequences are monotonic per run across all workers,
+// so trace timelines can be ordered without trusting worker clocks.
+func (cp *ControlPlane) NextTraceSequences(ctx context.Context, runID uuid.UUID, n int) (uint64, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("sequence count must be positive")
+	}
+
+	key := traceSequenceKey(runID)
+	pipe := cp.redis.TxPipeline()
+	last := pipe.IncrBy(ctx, key, int64(n))
+	pipe.Expire(ctx, key, traceSequenceRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to issue trace sequence: %w", err)
+	}
+	return uint64(last.Val()) - uint64(n) + 1, nil
+}
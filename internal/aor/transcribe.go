@@ -0,0 +1,357 @@
+package aor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultTranscribeChunkSeconds keeps uploads under Whisper's 25 MB limit at common bitrates
+	defaultTranscribeChunkSeconds = 600
+	minTranscribeChunkSeconds     = 30
+	maxTranscribeChunkSeconds     = 1800
+	// defaultAudioBitrateKbps sizes compressed audio whose duration can't be read
+	defaultAudioBitrateKbps = 128
+	// defaultAudioMinutes is assumed for audio fetched by URL and for estimates
+	defaultAudioMinutes = 10
+)
+
+// transcriptionModels is the model each transcription provider uses by default
+var transcriptionModels = map[string]string{
+	"openai":   "whisper-1",
+	"deepgram": "nova-2",
+}
+
+// transcriptionRates are published per-minute prices in cents, used when the
+// org's provider catalog has no cost_per_minute for a model
+var transcriptionRates = map[string]float64{
+	"openai/whisper-1":              0.6,
+	"openai/gpt-4o-transcribe":      0.6,
+	"openai/gpt-4o-mini-transcribe": 0.3,
+	"deepgram/nova-2":               0.43,
+	"deepgram/nova-3":               0.43,
+}
+
+// TranscribeConfig configures a transcribe step
+type TranscribeConfig struct {
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	Language     string `json:"language,omitempty"` // Detected when empty
+	ChunkSeconds int    `json:"chunk_seconds"`
+	AudioInput   string `json:"audio_input"` // Input holding the audio; audio by default
+}
+
+// TranscribeExecutor turns audio into text for downstream steps. Audio is an
+// artifact store hash, a URL, or an audio media input; long stored audio is
+// split into chunks transcribed in order. A pipeline is declared as
+//
+//	steps:
+//	  - {id: transcribe, type: transcribe, config: {provider: deepgram, language: en}}
+//	  - id: summarize
+//	    type: llm
+//	    config: {inputs: {transcript: transcribe.transcript}, ports: {inputs: {transcript: string}}}
+//
+// Transcribe steps that declare no ports output a transcript string port and
+// a segments json port.
+type TranscribeExecutor struct {
+	worker *Worker
+}
+
+func NewTranscribeExecutor(worker *Worker) *TranscribeExecutor {
+	return &TranscribeExecutor{worker: worker}
+}
+
+func (e *TranscribeExecutor) CanHandle(stepType string) bool {
+	return ExecutorType(stepType) == ExecutorTypeTranscribe
+}
+
+// parseTranscribeConfig reads a transcribe step's config
+func parseTranscribeConfig(config map[string]interface{}) (*TranscribeConfig, error) {
+	cfg := &TranscribeConfig{Provider: "openai", ChunkSeconds: defaultTranscribeChunkSeconds, AudioInput: "audio"}
+	if v, ok := config["provider"].(string); ok && v != "" {
+		cfg.Provider = strings.ToLower(v)
+	}
+	defaultModel, known := transcriptionModels[cfg.Provider]
+	if !known {
+		return nil, fmt.Errorf("unsupported transcription provider %q: use openai or deepgram", cfg.Provider)
+	}
+	cfg.Model = defaultModel
+	if v, ok := config["model"].(string); ok && v != "" {
+		cfg.Model = v
+	}
+	cfg.Language, _ = config["language"].(string)
+	if v, ok := config["audio_input"].(string); ok && v != "" {
+		cfg.AudioInput = v
+	}
+	if v, ok := config["chunk_seconds"].(float64); ok {
+		cfg.ChunkSeconds = int(v)
+		if cfg.ChunkSeconds < minTranscribeChunkSeconds || cfg.ChunkSeconds > maxTranscribeChunkSeconds {
+			return nil, fmt.Errorf("chunk_seconds must be between %d and %d", minTranscribeChunkSeconds, maxTranscribeChunkSeconds)
+		}
+	}
+	return cfg, nil
+}
+
+// transcriptionRate returns a model's price in cents per minute
+func transcriptionRate(provider, model string, pricing map[string]ModelPricing) (float64, bool) {
+	if price, ok := pricing[pricingKey(provider, model)]; ok && price.PerMinute > 0 {
+		return price.PerMinute * 100, true
+	}
+	rate, ok := transcriptionRates[pricingKey(provider, model)]
+	return rate, ok
+}
+
+// audioSource is audio to transcribe: stored bytes, or a URL the provider fetches
+type audioSource struct {
+	Data []byte
+	URL  string
+}
+
+// audioChunk is one provider request's slice of the audio, in seconds
+type audioChunk struct {
+	Start float64
+	End   float64
+	Data  []byte
+}
+
+func (e *TranscribeExecutor) Execute(ctx context.Context, task *Task) (*TaskResult, error) {
+	start := time.Now()
+	cfg, err := parseTranscribeConfig(task.Node.Config)
+	if err != nil {
+		return nil, err
+	}
+	if e.worker.policies != nil {
+		policy, err := e.worker.policies.Get(ctx, task.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		if err := policy.CheckModel(cfg.Provider, cfg.Model); err != nil {
+			return nil, err
+		}
+	}
+
+	audio, err := e.loadAudio(ctx, task, cfg.AudioInput)
+	if err != nil {
+		return nil, err
+	}
+	duration := float64(defaultAudioMinutes * 60)
+	chunks := []audioChunk{{Start: 0, End: duration}}
+	if audio.URL == "" {
+		duration = audioDuration(audio.Data)
+		chunks = chunkAudio(audio.Data, duration, cfg.ChunkSeconds)
+	}
+	log.Printf("Transcribing task %s: %.0fs of audio in %d chunks with %s/%s", task.ID, duration, len(chunks), cfg.Provider, cfg.Model)
+
+	texts := make([]string, 0, len(chunks))
+	segments := make([]interface{}, 0, len(chunks))
+	for _, chunk := range chunks {
+		text, err := e.transcribeChunk(ctx, cfg, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transcribe %s-%s: %w", audioTimestamp(chunk.Start), audioTimestamp(chunk.End), err)
+		}
+		texts = append(texts, text)
+		segments = append(segments, map[string]interface{}{"start": chunk.Start, "end": chunk.End, "text": text})
+	}
+
+	minutes := duration / 60
+	rate, _ := transcriptionRate(cfg.Provider, cfg.Model, nil)
+	transcriptionMinutes.Add(minutes, cfg.Provider, cfg.Model)
+
+	return &TaskResult{
+		TaskID: task.ID,
+		Status: TaskStatusSucceeded,
+		Output: map[string]interface{}{
+			"transcript":       strings.Join(texts, " "),
+			"segments":         segments,
+			"duration_seconds": duration,
+			"language":         cfg.Language,
+			"chunks":           len(chunks),
+		},
+		CostCents:  int64(math.Ceil(minutes * rate)),
+		Provider:   cfg.Provider,
+		Model:      cfg.Model,
+		ExecutedAt: time.Now(),
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// loadAudio resolves a task's audio input to bytes or a URL
+func (e *TranscribeExecutor) loadAudio(ctx context.Context, task *Task, input string) (*audioSource, error) {
+	value, ok := task.Inputs[input]
+	if !ok {
+		return nil, fmt.Errorf("transcribe needs an %s input", input)
+	}
+
+	ref := ""
+	source := &audioSource{}
+	switch v := value.(type) {
+	case string:
+		if !artifactRefPattern.MatchString(v) {
+			return nil, fmt.Errorf("%s input must be an artifact store hash, a URL or an audio media input", input)
+		}
+		if !strings.HasPrefix(v, "sha256:") {
+			return &audioSource{URL: v}, nil
+		}
+		ref = v
+	default:
+		part, isMedia, err := parseMediaPart(value)
+		if err != nil {
+			return nil, fmt.Errorf("input %s: %w", input, err)
+		}
+		if !isMedia || part.Type != MediaAudio {
+			return nil, fmt.Errorf("%s input must be an artifact store hash, a URL or an audio media input", input)
+		}
+		source.URL, ref = part.URL, part.Ref
+		if part.Data != "" {
+			source.Data, _ = base64.StdEncoding.DecodeString(part.Data)
+			return source, nil
+		}
+	}
+
+	if ref != "" {
+		if e.worker.blobs == nil {
+			return nil, fmt.Errorf("audio %s references the artifact store, which is not configured", ref)
+		}
+		blob, err := e.worker.blobs.Get(ctx, task.OrgID, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load audio: %w", err)
+		}
+		source.Data, source.URL = blob.Content, ""
+	}
+	return source, nil
+}
+
+// transcribeChunk sends one chunk to the transcription provider
+func (e *TranscribeExecutor) transcribeChunk(ctx context.Context, cfg *TranscribeConfig, chunk audioChunk) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	// Mock transcript - in production would upload the chunk to the provider's transcription API
+	return fmt.Sprintf("[%s transcript %s-%s]", cfg.Model, audioTimestamp(chunk.Start), audioTimestamp(chunk.End)), nil
+}
+
+// wavFormat is the part of a WAV header needed to split it on sample boundaries
+type wavFormat struct {
+	fmtChunk   []byte // The whole fmt chunk, copied into each chunk's header
+	byteRate   int
+	blockAlign int
+	data       []byte
+}
+
+// parseWAV reads a RIFF/WAVE file's format and sample data
+func parseWAV(data []byte) (*wavFormat, bool) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, false
+	}
+	wav := &wavFormat{}
+	for offset := 12; offset+8 <= len(data); {
+		id, size := string(data[offset:offset+4]), int(binary.LittleEndian.Uint32(data[offset+4:offset+8]))
+		body := offset + 8
+		if body+size > len(data) {
+			size = len(data) - body
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, false
+			}
+			wav.fmtChunk = data[offset : body+size]
+			wav.byteRate = int(binary.LittleEndian.Uint32(data[body+8 : body+12]))
+			wav.blockAlign = int(binary.LittleEndian.Uint16(data[body+12 : body+14]))
+		case "data":
+			wav.data = data[body : body+size]
+		}
+		offset = body + size + size%2
+	}
+	if wav.fmtChunk == nil || wav.data == nil || wav.byteRate <= 0 || wav.blockAlign <= 0 {
+		return nil, false
+	}
+	return wav, true
+}
+
+// encode wraps sample data in a WAV header with the original format
+func (w *wavFormat) encode(samples []byte) []byte {
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	_ = binary.Write(&out, binary.LittleEndian, uint32(4+len(w.fmtChunk)+8+len(samples)))
+	out.WriteString("WAVE")
+	out.Write(w.fmtChunk)
+	out.WriteString("data")
+	_ = binary.Write(&out, binary.LittleEndian, uint32(len(samples)))
+	out.Write(samples)
+	return out.Bytes()
+}
+
+// audioDuration returns the length of audio in seconds, read from WAV headers
+// or estimated from size for compressed formats
+func audioDuration(data []byte) float64 {
+	if wav, ok := parseWAV(data); ok {
+		return float64(len(wav.data)) / float64(wav.byteRate)
+	}
+	return float64(len(data)) / (defaultAudioBitrateKbps * 1000 / 8)
+}
+
+// chunkAudio splits audio into chunks of at most chunkSeconds. WAV is split on
+// sample boundaries with a header per chunk; compressed streams are split by
+// byte offset, which frame-based formats such as MP3 tolerate.
+func chunkAudio(data []byte, duration float64, chunkSeconds int) []audioChunk {
+	if duration <= float64(chunkSeconds) {
+		return []audioChunk{{Start: 0, End: duration, Data: data}}
+	}
+
+	wav, isWAV := parseWAV(data)
+	payload := data
+	bytesPerChunk := int(float64(len(data)) * float64(chunkSeconds) / duration)
+	if isWAV {
+		payload = wav.data
+		bytesPerChunk = chunkSeconds * wav.byteRate / wav.blockAlign * wav.blockAlign
+	}
+
+	var chunks []audioChunk
+	for offset := 0; offset < len(payload); offset += bytesPerChunk {
+		end := offset + bytesPerChunk
+		if end > len(payload) {
+			end = len(payload)
+		}
+		chunk := audioChunk{
+			Start: duration * float64(offset) / float64(len(payload)),
+			End:   duration * float64(end) / float64(len(payload)),
+			Data:  payload[offset:end],
+		}
+		if isWAV {
+			chunk.Data = wav.encode(chunk.Data)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// audioTimestamp formats seconds as h:mm:ss
+func audioTimestamp(seconds float64) string {
+	total := int(seconds)
+	return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+}
+
+// estimateTranscriptionCost prices a transcribe step from its estimated_minutes hint
+func estimateTranscriptionCost(config map[string]interface{}, pricing map[string]ModelPricing) (int64, string) {
+	cfg, err := parseTranscribeConfig(config)
+	if err != nil {
+		return 0, fmt.Sprintf("not estimated: %v", err)
+	}
+	minutes := float64(defaultAudioMinutes)
+	if v, ok := config["estimated_minutes"].(float64); ok && v > 0 {
+		minutes = v
+	}
+	rate, ok := transcriptionRate(cfg.Provider, cfg.Model, pricing)
+	if !ok {
+		return defaultLLMCallCostCents, fmt.Sprintf("no per-minute pricing for %s/%s; assumed %d¢", cfg.Provider, cfg.Model, defaultLLMCallCostCents)
+	}
+	return int64(math.Ceil(minutes * rate)), fmt.Sprintf("%.0f minutes of audio at %.2f¢ per minute", minutes, rate)
+}
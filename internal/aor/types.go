@@ -2,6 +2,7 @@ package aor
 
 import (
 	"context"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
 	"github.com/google/uuid"
 	"time"
 )
@@ -16,6 +17,8 @@ type WorkflowSpec struct {
 	Metadata Metadata  `json:"metadata" db:"metadata"`
 	Created  time.Time `json:"created" db:"created"`
 	Updated  time.Time `json:"updated" db:"updated"`
+
+	ContentHash string `json:"content_hash,omitempty" db:"content_hash"`
 }
 
 // DAG represents a directed acyclic graph
@@ -34,6 +37,8 @@ type Step struct {
 	Timeout     time.Duration          `json:"timeout"`
 	Retries     int                    `json:"retries"`
 	Conditions  []Condition            `json:"conditions"`
+	SLO         *StepSLO               `json:"slo,omitempty"`
+	Placement   *StepPlacement         `json:"placement,omitempty"` // Regions and worker labels that may run the step
 }
 
 // Edge represents a dependency between steps
@@ -54,6 +59,19 @@ type Metadata struct {
 	Tags        []string          `json:"tags"`
 	Labels      map[string]string `json:"labels"`
 	Author      string            `json:"author"`
+	SLA         *SLASpec          `json:"sla,omitempty"`
+	Lane        string            `json:"lane,omitempty"` // Scheduling lane for runs that don't request one
+
+	Residency *cas.ResidencyConstraint `json:"residency,omitempty"` // Where providers called by LLM steps may be hosted
+
+	Conversation *ConversationSpec `json:"conversation,omitempty"`
+
+	Environments map[string]EnvironmentProfile `json:"environments,omitempty"`
+
+	Webhooks  []WebhookTrigger  `json:"webhooks,omitempty"`  // Webhooks that start runs
+	Schedules []ScheduleTrigger `json:"schedules,omitempty"` // Cron schedules that start runs
+
+	PostProcess []PostProcessorSpec `json:"post_process,omitempty"` // Processors applied to the final output before it is stored
 }
 
 // WorkflowRun represents an execution instance
@@ -61,6 +79,7 @@ type WorkflowRun struct {
 	ID             uuid.UUID              `json:"id" db:"id"`
 	WorkflowID     uuid.UUID              `json:"workflow_id" db:"workflow_id"`
 	WorkflowSpecID uuid.UUID              `json:"workflow_spec_id" db:"workflow_spec_id"`
+	WorkflowName   string                 `json:"workflow_name,omitempty" db:"workflow_name"`
 	OrgID          uuid.UUID              `json:"org_id" db:"org_id"`
 	Status         WorkflowStatus         `json:"status" db:"status"`
 	Input          map[string]interface{} `json:"input" db:"input"`
@@ -72,7 +91,15 @@ type WorkflowRun struct {
 	CreatedAt      time.Time              `json:"created_at" db:"created_at"`
 	CostCents      int64                  `json:"cost_cents" db:"cost_cents"`
 	Metadata       map[string]interface{} `json:"metadata" db:"metadata"`
+	Tags           map[string]string      `json:"tags,omitempty" db:"tags"`
+	Labels         map[string]string      `json:"labels,omitempty" db:"labels"`
+	SLADeadline    *time.Time             `json:"sla_deadline,omitempty" db:"sla_deadline"`
+	SLAStatus      SLAStatus              `json:"sla_status,omitempty" db:"sla_status"`
+	BatchID        *uuid.UUID             `json:"batch_id,omitempty" db:"batch_id"`
 	Steps          []StepRun              `json:"steps" db:"steps"`
+	QueuePosition  int                    `json:"queue_position,omitempty" db:"-"`
+	Failure        *RunFailure            `json:"failure,omitempty" db:"failure"`
+	Warnings       []string               `json:"warnings,omitempty" db:"-"`
 }
 
 // StepRun represents a step execution
@@ -90,12 +117,23 @@ type StepRun struct {
 	CreatedAt     time.Time              `json:"created_at"`
 	Attempt       int                    `json:"attempt"`
 	Attempts      int                    `json:"attempts"`
+	RetryOf       string                 `json:"retry_of,omitempty"`
+	Overrides     *StepRetryRequest      `json:"overrides,omitempty"`
+}
+
+// StepRetryRequest manually retries a step, optionally overriding its inputs or config
+type StepRetryRequest struct {
+	Inputs map[string]interface{} `json:"inputs,omitempty"`
+	Config map[string]interface{} `json:"config,omitempty"`
+	Reason string                 `json:"reason,omitempty"`
 }
 
 // Task represents a unit of work
 type Task struct {
 	ID          uuid.UUID              `json:"id"`
 	RunID       uuid.UUID              `json:"run_id"`
+	OrgID       uuid.UUID              `json:"org_id,omitempty"`
+	Workflow    string                 `json:"workflow,omitempty"`
 	StepID      string                 `json:"step_id"`
 	NodeID      string                 `json:"node_id"`
 	Type        string                 `json:"type"`
@@ -108,19 +146,49 @@ type Task struct {
 	CreatedAt   time.Time              `json:"created_at"`
 	ScheduledAt time.Time              `json:"scheduled_at"`
 	DeadlineAt  *time.Time             `json:"deadline_at,omitempty"`
+	ReplayOf    uuid.UUID              `json:"replay_of"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Lane        string                 `json:"lane,omitempty"`
+	CacheKey    string                 `json:"cache_key,omitempty"` // Set when the step's output should be stored for reuse
+	Chaos       *ChaosPolicy           `json:"chaos,omitempty"`     // Faults to inject, in chaos mode only
+	Turn        int                    `json:"turn,omitempty"`      // Conversation turn, for conversational runs
+	Placement   *StepPlacement         `json:"placement,omitempty"` // Set for steps restricted to or preferring regions
+	Submitter   string                 `json:"submitter,omitempty"` // Authenticated user who submitted the run
+
+	MockProvider string                   `json:"mock_provider,omitempty"` // mock:// URL answering every LLM call, from the run's environment
+	Residency    *cas.ResidencyConstraint `json:"residency,omitempty"`     // Where LLM providers may be hosted, from the run
 }
 
 // TaskResult represents the result of task execution
 type TaskResult struct {
 	TaskID           uuid.UUID              `json:"task_id"`
+	RunID            uuid.UUID              `json:"run_id,omitempty"`
+	OrgID            uuid.UUID              `json:"org_id,omitempty"` // Releases the org's dispatch slot
+	NodeID           string                 `json:"node_id,omitempty"`
 	Status           TaskStatus             `json:"status"`
 	Output           map[string]interface{} `json:"output"`
 	Error            string                 `json:"error,omitempty"`
+	ErrorClass       string                 `json:"error_class,omitempty"`
+	FailureCategory  string                 `json:"failure_category,omitempty"`
 	ExecutedAt       time.Time              `json:"executed_at"`
 	Duration         time.Duration          `json:"duration"`
 	CostCents        int64                  `json:"cost_cents"`
 	TokensPrompt     int                    `json:"tokens_prompt"`
 	TokensCompletion int                    `json:"tokens_completion"`
+	TokensReasoning  int                    `json:"tokens_reasoning,omitempty"`   // Thinking tokens, included in TokensCompletion
+	ReasoningCents   int64                  `json:"reasoning_cents,omitempty"`    // Part of CostCents spent on thinking
+	TokensCacheRead  int                    `json:"tokens_cache_read,omitempty"`  // Prompt tokens read from the provider's cache
+	TokensCacheWrite int                    `json:"tokens_cache_write,omitempty"` // Prompt tokens written to the provider's cache
+	CacheSavingCents int64                  `json:"cache_saving_cents,omitempty"` // Saved by cached prompt pricing; negative while writing
+	Provider         string                 `json:"provider,omitempty"`
+	Model            string                 `json:"model,omitempty"`
+	Replayed         bool                   `json:"replayed,omitempty"`
+	Deduplicated     bool                   `json:"deduplicated,omitempty"`
+	Hedged           bool                   `json:"hedged,omitempty"`
+	Cached           bool                   `json:"cached,omitempty"`
+	Turn             int                    `json:"turn,omitempty"`
+	Attempts         int                    `json:"attempts,omitempty"`     // Executions the worker made, retries included
+	Degradations     []string               `json:"degradations,omitempty"` // Budget degradations applied to the call
 }
 
 // Executor interface for different step types
@@ -138,6 +206,9 @@ const (
 	WorkflowStatusCompleted WorkflowStatus = "completed"
 	WorkflowStatusFailed    WorkflowStatus = "failed"
 	WorkflowStatusCancelled WorkflowStatus = "cancelled"
+	WorkflowStatusPaused    WorkflowStatus = "paused"
+
+	WorkflowStatusBudgetExceeded WorkflowStatus = "budget_exceeded"
 )
 
 // Legacy aliases for compatibility
@@ -179,11 +250,21 @@ const (
 type ExecutorType string
 
 const (
-	ExecutorTypeLLM      ExecutorType = "llm"
-	ExecutorTypeHTTP     ExecutorType = "http"
-	ExecutorTypeScript   ExecutorType = "script"
-	ExecutorTypeWASM     ExecutorType = "wasm"
-	ExecutorTypeWorkflow ExecutorType = "workflow"
+	ExecutorTypeLLM        ExecutorType = "llm"
+	ExecutorTypeHTTP       ExecutorType = "http"
+	ExecutorTypeScript     ExecutorType = "script"
+	ExecutorTypeWASM       ExecutorType = "wasm"
+	ExecutorTypeWorkflow   ExecutorType = "workflow"
+	ExecutorTypeEnsemble   ExecutorType = "ensemble"
+	ExecutorTypeTranscribe ExecutorType = "transcribe"
+	ExecutorTypeAssert     ExecutorType = "assert"
+
+	// RAG pipeline stages: ingest -> chunk -> embed -> retrieve -> generate
+	ExecutorTypeRAGIngest   ExecutorType = "rag_ingest"
+	ExecutorTypeRAGChunk    ExecutorType = "rag_chunk"
+	ExecutorTypeRAGEmbed    ExecutorType = "rag_embed"
+	ExecutorTypeRAGRetrieve ExecutorType = "rag_retrieve"
+	ExecutorTypeRAGGenerate ExecutorType = "rag_generate"
 )
 
 // RunRequest represents a workflow execution request
@@ -192,8 +273,22 @@ type RunRequest struct {
 	WorkflowVersion int                    `json:"workflow_version"`
 	Inputs          map[string]interface{} `json:"inputs"`
 	Tags            []string               `json:"tags"`
+	Labels          map[string]string      `json:"labels,omitempty"`
 	BudgetCents     int64                  `json:"budget_cents"`
+	MaxCostCents    int64                  `json:"max_cost_cents,omitempty"` // Reject the run if its estimate is higher
 	Priority        int                    `json:"priority"`
+	ReplayOf        *uuid.UUID             `json:"replay_of,omitempty"`
+	Trigger         TriggerType            `json:"trigger,omitempty"`
+	Environment     string                 `json:"environment,omitempty"`
+	BatchID         *uuid.UUID             `json:"batch_id,omitempty"`
+	Callback        *RunCallback           `json:"callback,omitempty"`
+	Lane            string                 `json:"lane,omitempty"`
+	ReuseSteps      bool                   `json:"reuse_steps,omitempty"`  // Reuse outputs of steps whose config and inputs are unchanged
+	Chaos           *ChaosPolicy           `json:"chaos,omitempty"`        // Faults to inject; requires chaos mode
+	Reproducible    bool                   `json:"reproducible,omitempty"` // Pin seeds, temperatures and models of LLM steps
+	Seed            *int64                 `json:"seed,omitempty"`         // Run seed; implies reproducible, random when unset
+
+	Residency *cas.ResidencyConstraint `json:"residency,omitempty"` // Narrows the workflow's residency constraint for this run
 }
 
 // Node represents a workflow node (for scheduler compatibility)
@@ -203,4 +298,6 @@ type Node struct {
 	Config   map[string]interface{} `json:"config"`
 	Status   string                 `json:"status"`
 	Children []string               `json:"children"`
+	Policy   *NodePolicy            `json:"policy,omitempty"`  // Retry policy, the default when unset
+	Timeout  time.Duration          `json:"timeout,omitempty"` // Per-attempt limit, none when unset
 }
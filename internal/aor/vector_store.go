@@ -0,0 +1,119 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/pop"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// RAGChunk is a piece of a document as it moves through a RAG pipeline
+type RAGChunk struct {
+	DocumentID string                 `json:"document_id"`
+	Index      int                    `json:"index"`
+	Text       string                 `json:"text"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Embedding  []float64              `json:"embedding,omitempty"`
+	Score      float64                `json:"score,omitempty"`
+}
+
+// VectorStore keeps embedded chunks per org and collection in Postgres and
+// ranks them by cosine similarity
+type VectorStore struct {
+	db *db.PostgresDB
+}
+
+func NewVectorStore(pgDB *db.PostgresDB) *VectorStore {
+	return &VectorStore{db: pgDB}
+}
+
+// Upsert stores chunks in a collection, replacing chunks with the same document and index
+func (vs *VectorStore) Upsert(ctx context.Context, orgID uuid.UUID, collection, model string, chunks []RAGChunk) error {
+	tx, err := vs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `INSERT INTO rag_chunk (org_id, collection, document_id, chunk_index, content, embedding, embedding_model, metadata)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			  ON CONFLICT (org_id, collection, document_id, chunk_index) DO UPDATE
+			  SET content = EXCLUDED.content, embedding = EXCLUDED.embedding,
+			      embedding_model = EXCLUDED.embedding_model, metadata = EXCLUDED.metadata, created_at = NOW()`
+	for _, chunk := range chunks {
+		metadata, err := json.Marshal(chunk.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal chunk metadata: %w", err)
+		}
+		if chunk.Metadata == nil {
+			metadata = []byte("{}")
+		}
+		_, err = tx.ExecContext(ctx, query, orgID, collection, chunk.DocumentID, chunk.Index, chunk.Text,
+			pq.Float64Array(chunk.Embedding), model, metadata)
+		if err != nil {
+			return fmt.Errorf("failed to store chunk: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit chunks: %w", err)
+	}
+	return nil
+}
+
+// Search returns the topK chunks of a collection most similar to a query
+// vector embedded with the same model, dropping those below minScore
+func (vs *VectorStore) Search(ctx context.Context, orgID uuid.UUID, collection, model string, vector []float64, topK int, minScore float64) ([]RAGChunk, error) {
+	query := `SELECT document_id, chunk_index, content, embedding, metadata
+			  FROM rag_chunk WHERE org_id = $1 AND collection = $2 AND embedding_model = $3`
+
+	rows, err := vs.db.QueryContext(ctx, query, orgID, collection, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search collection: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var matches []RAGChunk
+	for rows.Next() {
+		var chunk RAGChunk
+		var embedding pq.Float64Array
+		var metadata []byte
+		if err := rows.Scan(&chunk.DocumentID, &chunk.Index, &chunk.Text, &embedding, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to scan chunk: %w", err)
+		}
+		score, err := pop.CosineSimilarity(vector, embedding)
+		if err != nil || score < minScore {
+			continue
+		}
+		_ = json.Unmarshal(metadata, &chunk.Metadata)
+		chunk.Score = score
+		matches = append(matches, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+
+	return topChunks(matches, topK), nil
+}
+
+// topChunks orders chunks by score, keeping the best k
+func topChunks(chunks []RAGChunk, k int) []RAGChunk {
+	sort.SliceStable(chunks, func(i, j int) bool {
+		if chunks[i].Score != chunks[j].Score {
+			return chunks[i].Score > chunks[j].Score
+		}
+		if chunks[i].DocumentID != chunks[j].DocumentID {
+			return chunks[i].DocumentID < chunks[j].DocumentID
+		}
+		return chunks[i].Index < chunks[j].Index
+	})
+	if len(chunks) > k {
+		chunks = chunks[:k]
+	}
+	return chunks
+}
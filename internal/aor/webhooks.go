@@ -0,0 +1,312 @@
+package aor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// webhookTimestampTolerance rejects deliveries signed too long ago, so a
+	// captured request cannot be replayed later
+	webhookTimestampTolerance = 5 * time.Minute
+	// webhookDeliveryTTL remembers delivery IDs long enough to outlast the tolerance
+	webhookDeliveryTTL = 2 * webhookTimestampTolerance
+	// webhookPayloadInput receives the whole payload when a webhook maps no inputs
+	webhookPayloadInput = "payload"
+)
+
+var webhookNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// WebhookTrigger declares a webhook that starts runs of the workflow. Inputs
+// maps workflow input names to JSONPath expressions over the payload.
+type WebhookTrigger struct {
+	Name   string            `json:"name"`
+	Inputs map[string]string `json:"inputs,omitempty"`
+	Lane   string            `json:"lane,omitempty"`
+}
+
+// ValidateWebhookTriggers checks webhook names and input paths
+func ValidateWebhookTriggers(webhooks []WebhookTrigger) error {
+	seen := make(map[string]bool, len(webhooks))
+	for _, webhook := range webhooks {
+		if !webhookNamePattern.MatchString(webhook.Name) {
+			return fmt.Errorf("invalid webhook name %q", webhook.Name)
+		}
+		if seen[webhook.Name] {
+			return fmt.Errorf("duplicate webhook %s", webhook.Name)
+		}
+		seen[webhook.Name] = true
+		for input, expr := range webhook.Inputs {
+			if _, err := ParseJSONPath(expr); err != nil {
+				return fmt.Errorf("webhook %s input %s: %w", webhook.Name, input, err)
+			}
+		}
+	}
+	return nil
+}
+
+// MapInputs builds run inputs from a decoded payload. Paths matching nothing
+// leave the input unset; paths with wildcards produce a list.
+func (w *WebhookTrigger) MapInputs(payload interface{}) (map[string]interface{}, error) {
+	if len(w.Inputs) == 0 {
+		return map[string]interface{}{webhookPayloadInput: payload}, nil
+	}
+
+	inputs := make(map[string]interface{}, len(w.Inputs))
+	for input, expr := range w.Inputs {
+		path, err := ParseJSONPath(expr)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %s input %s: %w", w.Name, input, err)
+		}
+		values := path.Select(payload)
+		switch {
+		case len(values) == 0:
+			continue
+		case path.Definite():
+			inputs[input] = values[0]
+		default:
+			inputs[input] = values
+		}
+	}
+	return inputs, nil
+}
+
+// Webhook is a registered webhook and the workflow it triggers. The secret
+// itself is never listed.
+type Webhook struct {
+	OrgID        uuid.UUID  `json:"org_id"`
+	Name         string     `json:"name"`
+	WorkflowName string     `json:"workflow_name"`
+	HasSecret    bool       `json:"has_secret"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RotatedAt    *time.Time `json:"rotated_at,omitempty"`
+}
+
+// WebhookDelivery is an incoming webhook request. Signature and Timestamp
+// carry the X-AgentFlow-Signature and X-AgentFlow-Timestamp headers, signed
+// the same way as run callbacks.
+type WebhookDelivery struct {
+	DeliveryID string `json:"delivery_id,omitempty"` // X-AgentFlow-Delivery; signed, and repeated IDs are ignored
+	Signature  string `json:"signature"`
+	Timestamp  string `json:"timestamp"`
+	Body       []byte `json:"-"`
+}
+
+// DuplicateDeliveryError is returned for a delivery ID already received;
+// RunID is the run the first delivery started, once it has started
+type DuplicateDeliveryError struct {
+	DeliveryID string
+	RunID      uuid.UUID
+}
+
+func (e *DuplicateDeliveryError) Error() string {
+	if e.RunID == uuid.Nil {
+		return fmt.Sprintf("webhook delivery %s already received", e.DeliveryID)
+	}
+	return fmt.Sprintf("webhook delivery %s already started run %s", e.DeliveryID, e.RunID)
+}
+
+// SignWebhookDelivery computes a delivery's hex HMAC-SHA256. Deliveries with
+// an ID sign "timestamp.id.body", so the ID deduplicating them can't be
+// altered; deliveries without one sign "timestamp.body" like run callbacks.
+func SignWebhookDelivery(secret, timestamp, deliveryID string, body []byte) string {
+	if deliveryID == "" {
+		return SignCallback(secret, timestamp, body)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(deliveryID))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks a delivery's signature against the webhook secret and
+// rejects timestamps outside the tolerance
+func VerifyWebhook(secret string, delivery *WebhookDelivery, now time.Time) error {
+	sent, err := strconv.ParseInt(delivery.Timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp %q", delivery.Timestamp)
+	}
+	if age := now.Sub(time.Unix(sent, 0)); age > webhookTimestampTolerance || age < -webhookTimestampTolerance {
+		return fmt.Errorf("webhook timestamp outside tolerance")
+	}
+
+	expected := "sha256=" + SignWebhookDelivery(secret, delivery.Timestamp, delivery.DeliveryID, delivery.Body)
+	if !hmac.Equal([]byte(expected), []byte(delivery.Signature)) {
+		return fmt.Errorf("webhook signature mismatch")
+	}
+	return nil
+}
+
+// registerWebhooks claims the webhooks a newly applied workflow version
+// declares. A webhook name triggers one workflow per org.
+func (cp *ControlPlane) registerWebhooks(ctx context.Context, orgID uuid.UUID, workflowName string, webhooks []WebhookTrigger) error {
+	for _, webhook := range webhooks {
+		query := `INSERT INTO workflow_webhook (org_id, name, workflow_name)
+				  VALUES ($1, $2, $3)
+				  ON CONFLICT (org_id, name) DO UPDATE SET workflow_name = workflow_webhook.workflow_name
+				  RETURNING workflow_name`
+		var owner string
+		if err := cp.db.QueryRowContext(ctx, query, orgID, webhook.Name, workflowName).Scan(&owner); err != nil {
+			return fmt.Errorf("failed to register webhook %s: %w", webhook.Name, err)
+		}
+		if owner != workflowName {
+			return fmt.Errorf("webhook %s already triggers workflow %s", webhook.Name, owner)
+		}
+	}
+	return nil
+}
+
+// SetWebhookSecret sets the secret deliveries to a webhook are signed with,
+// generating one when secret is empty, and returns it
+func (cp *ControlPlane) SetWebhookSecret(ctx context.Context, orgID uuid.UUID, name, secret string) (string, error) {
+	if secret == "" {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		secret = "whsec_" + hex.EncodeToString(raw)
+	}
+
+	query := `UPDATE workflow_webhook SET secret = $3, rotated_at = NOW() WHERE org_id = $1 AND name = $2`
+	result, err := cp.db.ExecContext(ctx, query, orgID, name, secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to set webhook secret: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return "", fmt.Errorf("webhook not found: %s", name)
+	}
+	log.Printf("Rotated secret of webhook %s for org %s", name, orgID)
+	return secret, nil
+}
+
+// ListWebhooks returns an org's registered webhooks
+func (cp *ControlPlane) ListWebhooks(ctx context.Context, orgID uuid.UUID) ([]Webhook, error) {
+	query := `SELECT org_id, name, workflow_name, secret != '', created_at, rotated_at
+			  FROM workflow_webhook WHERE org_id = $1 ORDER BY name`
+	rows, err := cp.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := make([]Webhook, 0)
+	for rows.Next() {
+		var webhook Webhook
+		if err := rows.Scan(&webhook.OrgID, &webhook.Name, &webhook.WorkflowName, &webhook.HasSecret,
+			&webhook.CreatedAt, &webhook.RotatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// TriggerWebhook validates a delivery and starts a run of the webhook's
+// workflow with inputs mapped from the payload. A repeated delivery ID
+// returns a DuplicateDeliveryError without starting another run.
+func (cp *ControlPlane) TriggerWebhook(ctx context.Context, orgID uuid.UUID, name string, delivery *WebhookDelivery) (*WorkflowRun, error) {
+	var workflowName, secret string
+	query := `SELECT workflow_name, secret FROM workflow_webhook WHERE org_id = $1 AND name = $2`
+	err := cp.db.QueryRowContext(ctx, query, orgID, name).Scan(&workflowName, &secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("webhook not found: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("webhook %s has no secret; set one before sending deliveries", name)
+	}
+	if err := VerifyWebhook(secret, delivery, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if delivery.DeliveryID == "" {
+		return cp.startWebhookRun(ctx, workflowName, name, delivery)
+	}
+
+	// Claim the delivery ID so concurrent repeats start no second run
+	key := fmt.Sprintf("webhook:delivery:%s:%s:%s", orgID, name, delivery.DeliveryID)
+	first, err := cp.redis.SetNX(ctx, key, "", webhookDeliveryTTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	if !first {
+		log.Printf("Ignoring repeated delivery %s of webhook %s", delivery.DeliveryID, name)
+		duplicate := &DuplicateDeliveryError{DeliveryID: delivery.DeliveryID}
+		if runID, err := cp.redis.Get(ctx, key).Result(); err == nil {
+			duplicate.RunID, _ = uuid.Parse(runID)
+		}
+		return nil, duplicate
+	}
+
+	run, err := cp.startWebhookRun(ctx, workflowName, name, delivery)
+	if err != nil {
+		// Give up the claim so the sender's retry of this delivery goes through
+		if delErr := cp.redis.Del(ctx, key).Err(); delErr != nil {
+			log.Printf("Failed to release delivery %s of webhook %s: %v", delivery.DeliveryID, name, delErr)
+		}
+		return nil, err
+	}
+	if err := cp.redis.Set(ctx, key, run.ID.String(), webhookDeliveryTTL).Err(); err != nil {
+		log.Printf("Failed to record run of delivery %s of webhook %s: %v", delivery.DeliveryID, name, err)
+	}
+	return run, nil
+}
+
+// startWebhookRun submits a run of the webhook's workflow for a verified delivery
+func (cp *ControlPlane) startWebhookRun(ctx context.Context, workflowName, name string, delivery *WebhookDelivery) (*WorkflowRun, error) {
+	// The version the deployment routes to decides how the payload maps to
+	// inputs, and whether the workflow still declares the webhook at all
+	version, _, err := cp.routeWorkflowVersion(ctx, workflowName)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := cp.getWorkflowSpec(ctx, workflowName, version)
+	if err != nil {
+		return nil, err
+	}
+	var trigger *WebhookTrigger
+	for i := range spec.Metadata.Webhooks {
+		if spec.Metadata.Webhooks[i].Name == name {
+			trigger = &spec.Metadata.Webhooks[i]
+		}
+	}
+	if trigger == nil {
+		return nil, fmt.Errorf("workflow %s v%d no longer declares webhook %s", workflowName, version, name)
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(delivery.Body, &payload); err != nil {
+		return nil, fmt.Errorf("webhook payload is not JSON: %w", err)
+	}
+	inputs, err := trigger.MapInputs(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return cp.SubmitWorkflow(ctx, &RunRequest{
+		WorkflowName:    workflowName,
+		WorkflowVersion: version,
+		Inputs:          inputs,
+		Trigger:         TriggerWebhook,
+		Lane:            trigger.Lane,
+		Labels:          map[string]string{"webhook": name},
+	})
+}
@@ -3,12 +3,15 @@ package aor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
 	nats "github.com/nats-io/nats.go"
@@ -24,6 +27,22 @@ type Worker struct {
 	js    nats.JetStreamContext
 
 	executors map[ExecutorType]Executor
+	telemetry *cas.TelemetryStore
+	router    callTelemetry
+	failover  providerFailover
+	cassettes *CassetteStore
+	policies  *cas.ModelPolicyStore
+	budgets   *cas.BudgetGuard
+	degrader  *cas.DegradationController
+	dedup     *CallDeduplicator
+	stepCache *StepCache
+	responses *cas.CacheManager
+	captures  *DebugCaptureStore
+	blobs     *db.BlobStore
+	plugins   []*Plugin
+
+	warmPrefixes sync.Map     // Mock provider prompt caches, prefix key to expiry
+	active       atomic.Int64 // Tasks executing now, reported with heartbeats
 
 	mu       sync.RWMutex
 	running  bool
@@ -45,6 +64,9 @@ func NewWorker(cfg *config.Config) (*Worker, error) {
 		Password: cfg.Redis.Password,
 		DB:       cfg.Redis.DB,
 	})
+	if cfg.Metrics.Shared {
+		cas.EnableClusterMetrics(redisClient)
+	}
 
 	// Initialize NATS
 	nc, err := nats.Connect(cfg.NATS.URL)
@@ -57,6 +79,13 @@ func NewWorker(cfg *config.Config) (*Worker, error) {
 		return nil, fmt.Errorf("failed to get JetStream context: %w", err)
 	}
 
+	cassettes, err := NewCassetteStore(pgDB, cfg.Replay.CassetteKey)
+	if err != nil {
+		return nil, err
+	}
+
+	budgets := cas.NewBudgetGuard(pgDB, redisClient, cfg.Budgets)
+	router := cas.NewProviderRouter(pgDB, redisClient)
 	worker := &Worker{
 		id:        uuid.New().String(),
 		cfg:       cfg,
@@ -66,12 +95,47 @@ func NewWorker(cfg *config.Config) (*Worker, error) {
 		js:        js,
 		shutdown:  make(chan struct{}),
 		executors: make(map[ExecutorType]Executor),
+		telemetry: cas.NewTelemetryStore(pgDB, redisClient),
+		router:    router,
+		failover:  router,
+		cassettes: cassettes,
+		policies:  cas.NewModelPolicyStore(pgDB),
+		budgets:   budgets,
+		degrader:  cas.NewDegradationController(budgets, cfg.Budgets.Degradation),
+		dedup:     NewCallDeduplicator(redisClient),
+		stepCache: NewStepCache(redisClient),
+		responses: cas.NewCacheManager(redisClient),
+		captures:  NewDebugCaptureStore(redisClient),
+		blobs:     db.NewBlobStore(pgDB),
 	}
 
 	// Initialize executors
-	worker.executors[ExecutorTypeLLM] = NewLLMExecutor(worker)
+	llm := NewLLMExecutor(worker)
+	worker.executors[ExecutorTypeLLM] = llm
+	worker.executors[ExecutorTypeEnsemble] = NewEnsembleExecutor(worker, llm)
 	worker.executors[ExecutorTypeHTTP] = NewHTTPExecutor(worker)
 	worker.executors[ExecutorTypeScript] = NewScriptExecutor(worker)
+	worker.executors[ExecutorTypeTranscribe] = NewTranscribeExecutor(worker)
+	worker.executors[ExecutorTypeAssert] = NewAssertExecutor(worker)
+	rag := NewRAGExecutor(worker, llm)
+	for _, stage := range []ExecutorType{ExecutorTypeRAGIngest, ExecutorTypeRAGChunk, ExecutorTypeRAGEmbed, ExecutorTypeRAGRetrieve, ExecutorTypeRAGGenerate} {
+		worker.executors[stage] = rag
+	}
+
+	// Custom step types come from executor plugins; built-in types cannot be overridden
+	if worker.plugins, err = LoadPlugins(cfg.Plugins.Dir); err != nil {
+		return nil, err
+	}
+	for _, plugin := range worker.plugins {
+		executor := NewPluginExecutor(plugin, cfg.Plugins.CallTimeout)
+		for _, stepType := range plugin.Manifest.StepTypes {
+			if existing, taken := worker.executors[ExecutorType(stepType)]; taken {
+				log.Printf("Plugin %s step type %s is already handled by %T, skipping", plugin.Name(), stepType, existing)
+				continue
+			}
+			worker.executors[ExecutorType(stepType)] = executor
+		}
+	}
 
 	return worker, nil
 }
@@ -100,6 +164,25 @@ func (w *Worker) Start(ctx context.Context) error {
 		}
 	}
 
+	// Every worker warms itself ahead of scheduled runs
+	if _, err := w.nats.Subscribe(prewarmSubject, w.handlePrewarm); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", prewarmSubject, err)
+	}
+
+	// Regional workers also take tasks placed in their region; the queue
+	// group delivers each one to a single worker
+	if region := w.cfg.Worker.Region; region != "" {
+		subject := regionTaskSubject(region)
+		if _, err := w.js.QueueSubscribe(subject, regionConsumer(region), w.handleTask, nats.Durable(regionConsumer(region))); err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+		}
+	}
+
+	// Tasks whose selector matches this worker's labels are sent to it alone
+	if _, err := w.js.Subscribe(workerTaskSubject(w.id), w.handleTask, nats.Durable("worker-direct-"+w.id)); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", workerTaskSubject(w.id), err)
+	}
+
 	// Start heartbeat
 	go w.heartbeatLoop(ctx)
 
@@ -107,6 +190,12 @@ func (w *Worker) Start(ctx context.Context) error {
 	return nil
 }
 
+// RunHealthChecks pings the providers the worker fails over between on an
+// interval until ctx is done
+func (w *Worker) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	w.failover.RunHealthChecks(ctx, interval)
+}
+
 func (w *Worker) Shutdown(ctx context.Context) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -118,6 +207,10 @@ func (w *Worker) Shutdown(ctx context.Context) error {
 	close(w.shutdown)
 	w.running = false
 
+	for _, plugin := range w.plugins {
+		plugin.Close(ctx)
+	}
+
 	// Close connections
 	if w.nats != nil {
 		w.nats.Close()
@@ -141,6 +234,9 @@ func (w *Worker) handleTask(msg *nats.Msg) {
 		return
 	}
 
+	w.active.Add(1)
+	defer w.active.Add(-1)
+
 	deadline := time.Now().Add(30 * time.Minute)
 	if task.DeadlineAt != nil {
 		deadline = *task.DeadlineAt
@@ -156,13 +252,28 @@ func (w *Worker) handleTask(msg *nats.Msg) {
 	}
 
 	// Execute task
-	result, err := w.executeTask(ctx, &task)
+	result, attempts, err := w.executeTask(ctx, &task)
 	if err != nil {
 		log.Printf("Failed to execute task %s: %v", task.ID, err)
 		result = &TaskResult{
-			TaskID: task.ID,
-			Status: TaskStatusFailed,
-			Error:  err.Error(),
+			TaskID:          task.ID,
+			Status:          TaskStatusFailed,
+			Error:           err.Error(),
+			ErrorClass:      string(cas.ClassifyError(err)),
+			FailureCategory: string(classifyFailure(err, task.Type)),
+			Attempts:        attempts,
+		}
+		w.deadLetter(&task, err, attempts)
+	}
+	result.RunID = task.RunID
+	result.OrgID = task.OrgID
+	result.NodeID = task.NodeID
+	result.Turn = task.Turn
+	tasksProcessed.Inc(task.Type, string(result.Status))
+
+	if task.CacheKey != "" && result.Status == TaskStatusSucceeded {
+		if err := w.stepCache.Put(ctx, &task, result); err != nil {
+			log.Printf("Failed to cache output of task %s: %v", task.ID, err)
 		}
 	}
 
@@ -183,36 +294,157 @@ func (w *Worker) handleTask(msg *nats.Msg) {
 	_ = msg.Ack() // Ignore ack error
 }
 
-func (w *Worker) executeTask(ctx context.Context, task *Task) (*TaskResult, error) {
+// executeTask runs a task under its node's retry policy, returning the
+// number of attempts made alongside the outcome
+func (w *Worker) executeTask(ctx context.Context, task *Task) (*TaskResult, int, error) {
 	executor, exists := w.executors[ExecutorType(task.Node.Type)]
 	if !exists {
-		return nil, fmt.Errorf("no executor for node type %s", task.Node.Type)
+		return nil, 0, fmt.Errorf("no executor for node type %s", task.Node.Type)
 	}
 
-	// Add retry logic
-	maxRetries := 3 // Default retry count
+	// Provider calls read LLM faults from the context; other step types are
+	// faulted around the executor below
+	ctx = withChaos(ctx, task.Chaos)
+	faultStep := ExecutorType(task.Node.Type) != ExecutorTypeLLM && ExecutorType(task.Node.Type) != ExecutorTypeEnsemble &&
+		ExecutorType(task.Node.Type) != ExecutorTypeRAGGenerate
+
+	// Typed inputs are checked once; the same inputs would fail every attempt
+	if err := checkPortValues(task, PortInput, task.Inputs); err != nil {
+		return nil, 0, err
+	}
+
+	policy := task.Node.Policy
+	if policy == nil {
+		policy = defaultNodePolicy()
+	}
+	maxAttempts := policy.MaxRetries + 1
 
 	var lastErr error
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		result, err := executor.Execute(ctx, task)
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			taskRetries.Inc(task.Node.Type)
+		}
+
+		start := time.Now()
+		var fault *FaultRule
+		if faultStep {
+			fault = task.Chaos.pick("", secureRandFloat64)
+		}
+		var result *TaskResult
+		var err error
+		attemptCtx, cancel := attemptContext(ctx, task.Node.Timeout)
+		if fault != nil && fault.Fault != FaultMalformedOutput {
+			err = fault.inject(attemptCtx, task.Node.Type)
+		} else {
+			result, err = executor.Execute(withRetryAttempt(attemptCtx, attempt), task)
+			if fault != nil && err == nil {
+				_ = fault.inject(attemptCtx, task.Node.Type)
+				result.Output = malformedOutput()
+			}
+		}
+		if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("step timed out after %s: %w", task.Node.Timeout, err)
+		}
+		cancel()
+		stepDuration.ObserveDuration(start, task.Node.Type)
+		// Sampled steps report telemetry per sample instead of for the aggregate.
+		// Chaos runs are left out so injected faults don't skew provider routing.
+		if ExecutorType(task.Node.Type) == ExecutorTypeLLM && llmSampleCount(task.Node.Config) == 1 && (result == nil || !result.Replayed) && task.Chaos == nil {
+			w.reportTelemetry(ctx, task, result, err, time.Since(start))
+		}
 		if err == nil {
-			return result, nil
+			if err = checkPortValues(task, PortOutput, result.Output); err == nil {
+				result.Attempts = attempt
+				return result, attempt, nil
+			}
 		}
 
 		lastErr = err
-		if attempt < maxRetries {
-			// Exponential backoff
-			backoff := time.Duration(attempt*attempt) * time.Second
+		if !retryableError(err) {
+			return nil, attempt, err
+		}
+		if attempt < maxAttempts {
+			backoff := policy.backoff(attempt, secureRandFloat64)
+			log.Printf("Task %s attempt %d/%d failed, retrying in %s: %v", task.ID, attempt, maxAttempts, backoff.Round(time.Millisecond), err)
 			select {
 			case <-ctx.Done():
-				return nil, ctx.Err()
+				return nil, attempt, ctx.Err()
 			case <-time.After(backoff):
 				continue
 			}
 		}
 	}
 
-	return nil, fmt.Errorf("task failed after %d attempts: %w", maxRetries, lastErr)
+	return nil, maxAttempts, fmt.Errorf("task failed after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// callTelemetry takes the outcome of every provider call, updating routing
+// rewards along with the telemetry store
+type callTelemetry interface {
+	RecordTelemetry(ctx context.Context, record *cas.ProviderTelemetry) error
+}
+
+// providerFailover retries a step's provider call on the next-best healthy
+// provider after server errors and timeouts, recording each attempt's outcome
+// in provider health. Only providers within the run's residency are called.
+type providerFailover interface {
+	FailoverRoute(ctx context.Context, req *cas.RoutingRequest, providerName, modelName string) (*cas.RoutingResponse, error)
+	CheckResidency(ctx context.Context, req *cas.RoutingRequest, providerName, modelName string) error
+	CallWithFailover(ctx context.Context, response *cas.RoutingResponse, call cas.ProviderCall) ([]cas.FailoverAttempt, error)
+	RunHealthChecks(ctx context.Context, interval time.Duration)
+}
+
+// reportTelemetry feeds observed provider latency, errors and cost back to
+// CAS. Failed calls are attributed to the provider the step would have
+// called, so success rates count failures too.
+func (w *Worker) reportTelemetry(ctx context.Context, task *Task, result *TaskResult, execErr error, elapsed time.Duration) {
+	record := &cas.ProviderTelemetry{
+		OrgID:        task.OrgID,
+		WorkflowName: task.Workflow,
+		Labels:       task.Labels,
+		Latency:      elapsed,
+		ErrorClass:   cas.ClassifyError(execErr),
+	}
+
+	record.ProviderName, record.ModelName = defaultLLMProvider, defaultLLMModel
+	if task.Node != nil && task.Node.Config != nil {
+		record.ProviderName, record.ModelName = stepProviderModel(task.Node.Config)
+		if quality, ok := task.Node.Config["quality"].(string); ok {
+			record.QualityTier = cas.QualityTier(quality)
+		}
+	}
+	if mock, _ := mockProviderFor(task, record.ProviderName); mock != nil {
+		record.ProviderName = MockProviderName
+	}
+
+	if result != nil {
+		if result.Cached {
+			return // Served from the response cache without a provider call
+		}
+		if result.Provider != "" {
+			record.ProviderName = result.Provider
+		}
+		if result.Model != "" {
+			record.ModelName = result.Model
+		}
+		record.CostCents = result.CostCents
+		record.TokensUsed = result.TokensPrompt + result.TokensCompletion
+		record.PromptTokens = result.TokensPrompt
+		record.ReasoningTokens, record.ReasoningCents = result.TokensReasoning, result.ReasoningCents
+		record.CacheReadTokens, record.CacheWriteTokens = result.TokensCacheRead, result.TokensCacheWrite
+		record.CacheSavingCents = result.CacheSavingCents
+		if result.Duration > 0 {
+			record.Latency = result.Duration
+		}
+	}
+
+	if cas.IsUnsent(execErr) {
+		return // Budget rejections, throttles and policy denials never reached the provider
+	}
+
+	if err := w.router.RecordTelemetry(ctx, record); err != nil {
+		log.Printf("Failed to record provider telemetry: %v", err)
+	}
 }
 
 func (w *Worker) updateStepStatus(ctx context.Context, stepID uuid.UUID, status StepStatus, workerID string) error {
@@ -232,12 +464,12 @@ func (w *Worker) updateStepWithResult(ctx context.Context, result *TaskResult) e
 
 	query := `UPDATE step_run SET 
 			  status = $1, ended_at = $2, error = $3, cost_cents = $4, 
-			  tokens_prompt = $5, tokens_completion = $6
+			  tokens_prompt = $5, tokens_completion = $6, attempts = $8
 			  WHERE id = $7`
 
 	_, err := w.db.ExecContext(ctx, query,
 		result.Status, now, result.Error, result.CostCents,
-		result.TokensPrompt, result.TokensCompletion, result.TaskID,
+		result.TokensPrompt, result.TokensCompletion, result.TaskID, result.Attempts,
 	)
 	return err
 }
@@ -269,10 +501,18 @@ func (w *Worker) heartbeatLoop(ctx context.Context) {
 }
 
 func (w *Worker) sendHeartbeat(ctx context.Context) {
-	heartbeat := map[string]interface{}{
-		"worker_id": w.id,
-		"timestamp": time.Now(),
-		"status":    "healthy",
+	capacity := w.cfg.Worker.Capacity
+	if capacity <= 0 {
+		capacity = defaultWorkerCapacity
+	}
+	heartbeat := WorkerHeartbeat{
+		WorkerID:  w.id,
+		Region:    w.cfg.Worker.Region,
+		Labels:    w.cfg.Worker.Labels,
+		Capacity:  capacity,
+		Active:    int(w.active.Load()),
+		Status:    "healthy",
+		Timestamp: time.Now(),
 	}
 
 	data, _ := json.Marshal(heartbeat)
@@ -0,0 +1,94 @@
+package aor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// workflowFilterFields are the fields a workflow filter expression can reference
+var workflowFilterFields = map[string]db.FilterField{
+	"name":       {Column: "name", Type: db.FilterString},
+	"version":    {Column: "version", Type: db.FilterInt},
+	"created_at": {Column: "created_at", Type: db.FilterTime},
+}
+
+// WorkflowPage is one page of workflow spec versions, newest first
+type WorkflowPage struct {
+	Workflows []WorkflowSpec `json:"workflows"`
+	db.PageMeta
+}
+
+// ListWorkflows returns an org's workflow spec versions using keyset pagination
+func (cp *ControlPlane) ListWorkflows(ctx context.Context, orgID uuid.UUID, opts db.ListOptions) (*WorkflowPage, error) {
+	clauses, err := db.ParseFilter(opts.Filter, workflowFilterFields)
+	if err != nil {
+		return nil, err
+	}
+	conditions, args := db.PostgresConditions(clauses, []interface{}{orgID})
+	conditions = append([]string{"org_id = $1"}, conditions...)
+
+	total, err := cp.db.EstimateTotal(ctx, `SELECT 1 FROM workflow_spec WHERE `+strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Cursor != "" {
+		createdAt, id, err := db.DecodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, createdAt, id)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	limit := opts.PageSize()
+	args = append(args, limit+1)
+	query := `SELECT id, org_id, name, version, dag, metadata, created_at, COALESCE(content_hash, '')
+			  FROM workflow_spec
+			  WHERE ` + strings.Join(conditions, " AND ") + `
+			  ORDER BY created_at DESC, id DESC
+			  LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := cp.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflows: %w", err)
+	}
+	defer rows.Close()
+
+	page := &WorkflowPage{Workflows: make([]WorkflowSpec, 0, limit)}
+	for rows.Next() {
+		var spec WorkflowSpec
+		var dagJSON, metadataJSON []byte
+		if err := rows.Scan(
+			&spec.ID, &spec.OrgID, &spec.Name, &spec.Version, &dagJSON, &metadataJSON, &spec.Created, &spec.ContentHash,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan workflow spec: %w", err)
+		}
+		if err := json.Unmarshal(dagJSON, &spec.DAG); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal DAG: %w", err)
+		}
+		if err := json.Unmarshal(metadataJSON, &spec.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+		page.Workflows = append(page.Workflows, spec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list workflows: %w", err)
+	}
+
+	// One extra row is fetched to learn whether another page exists
+	if len(page.Workflows) > limit {
+		page.Workflows = page.Workflows[:limit]
+		last := page.Workflows[limit-1]
+		page.NextCursor = db.EncodeCursor(last.Created, last.ID)
+	}
+	page.TotalEstimate = total
+
+	return page, nil
+}
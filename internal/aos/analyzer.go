@@ -7,8 +7,20 @@ import (
 	"time"
 
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
 )
 
+// traceFilterFields are the fields a trace filter expression can reference
+var traceFilterFields = map[string]db.FilterField{
+	"event_type":   {Column: "event_type", Type: db.FilterString},
+	"provider":     {Column: "provider", Type: db.FilterString},
+	"model":        {Column: "model", Type: db.FilterString},
+	"quality_tier": {Column: "quality_tier", Type: db.FilterString},
+	"cost_cents":   {Column: "cost_cents", Type: db.FilterInt},
+	"latency_ms":   {Column: "latency_ms", Type: db.FilterInt},
+	"ts":           {Column: "ts", Type: db.FilterTime},
+}
+
 type TraceAnalyzer struct {
 	clickhouse *db.ClickHouseDB
 }
@@ -22,19 +34,31 @@ func NewTraceAnalyzer(ch *db.ClickHouseDB) *TraceAnalyzer {
 // QueryEvents retrieves trace events based on query parameters
 func (ta *TraceAnalyzer) QueryEvents(ctx context.Context, query *TraceQuery) ([]TraceEvent, int64, error) {
 	// Build WHERE clause
-	whereClause, args := ta.buildWhereClause(query)
+	whereClause, args, err := ta.buildWhereClause(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = db.DefaultPageSize
+	}
+	offset, err := query.position()
+	if err != nil {
+		return nil, 0, err
+	}
 
 	// Build main query
 	sqlQuery := fmt.Sprintf(`
 		SELECT 
-			org_id, run_id, step_id, ts, event_type, payload,
+			org_id, run_id, step_id, ts, seq, event_type, payload,
 			cost_cents, tokens_prompt, tokens_completion,
-			provider, model, quality_tier, latency_ms
+			provider, model, quality_tier, latency_ms, labels
 		FROM trace_event 
 		WHERE %s
 		ORDER BY ts DESC
 		LIMIT %d OFFSET %d
-	`, whereClause, query.Limit, query.Offset)
+	`, whereClause, limit, offset)
 
 	// Execute query
 	rows, err := ta.clickhouse.Query(ctx, sqlQuery, args...)
@@ -50,10 +74,10 @@ func (ta *TraceAnalyzer) QueryEvents(ctx context.Context, query *TraceQuery) ([]
 		var payloadStr string
 
 		err := rows.Scan(
-			&event.OrgID, &event.RunID, &event.StepID, &event.Timestamp,
+			&event.OrgID, &event.RunID, &event.StepID, &event.Timestamp, &event.Sequence,
 			&event.EventType, &payloadStr, &event.CostCents,
 			&event.TokensPrompt, &event.TokensCompletion,
-			&event.Provider, &event.Model, &event.QualityTier, &event.LatencyMs,
+			&event.Provider, &event.Model, &event.QualityTier, &event.LatencyMs, &event.Labels,
 		)
 		if err != nil {
 			continue // Skip malformed rows
@@ -134,8 +158,9 @@ func (ta *TraceAnalyzer) GenerateSummary(ctx context.Context, events []TraceEven
 	return summary, nil
 }
 
-// GetCostBreakdown retrieves cost breakdown data
-func (ta *TraceAnalyzer) GetCostBreakdown(ctx context.Context, query string) ([]CostBreakdown, error) {
+// GetCostBreakdown retrieves cost breakdown data. The query selects one
+// column per dimension followed by the cost and event count.
+func (ta *TraceAnalyzer) GetCostBreakdown(ctx context.Context, query string, dimensions []string) ([]CostBreakdown, error) {
 	rows, err := ta.clickhouse.Query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute cost query: %w", err)
@@ -153,24 +178,28 @@ func (ta *TraceAnalyzer) GetCostBreakdown(ctx context.Context, query string) ([]
 	}, 0)
 
 	for rows.Next() {
-		var provider, model string
+		values := make([]string, len(dimensions))
 		var cost, count int64
 
-		err := rows.Scan(&provider, &model, &cost, &count)
-		if err != nil {
+		dest := make([]interface{}, 0, len(dimensions)+2)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &cost, &count)
+		if err := rows.Scan(dest...); err != nil {
 			continue
 		}
 
-		dimensions := map[string]string{
-			"provider": provider,
-			"model":    model,
+		row := make(map[string]string, len(dimensions))
+		for i, dimension := range dimensions {
+			row[dimension] = values[i]
 		}
 
 		tempResults = append(tempResults, struct {
 			dimensions map[string]string
 			cost       int64
 			count      int64
-		}{dimensions, cost, count})
+		}{row, cost, count})
 
 		totalCost += cost
 	}
@@ -305,6 +334,49 @@ func (ta *TraceAnalyzer) GetQualityMetrics(ctx context.Context, req *QualityDrif
 	return metrics, nil
 }
 
+// GetRefusalRates counts model calls that ended in a refusal, content filter
+// or length stop, grouped by prompt and version. Calls are classified by the
+// error_class recorded on error events or the finish_reason of model_io events.
+func (ta *TraceAnalyzer) GetRefusalRates(ctx context.Context, orgID uuid.UUID, startTime, endTime time.Time) ([]RefusalRate, error) {
+	query := `
+		SELECT
+			JSONExtractString(payload, 'prompt_ref') AS prompt_ref,
+			JSONExtractInt(payload, 'prompt_version') AS prompt_version,
+			count() AS calls,
+			countIf(JSONExtractString(payload, 'error_class') = 'safety_refusal' OR JSONExtractString(payload, 'finish_reason') = 'refusal'),
+			countIf(JSONExtractString(payload, 'error_class') = 'policy_block' OR JSONExtractString(payload, 'finish_reason') = 'content_filter'),
+			countIf(JSONExtractString(payload, 'error_class') = 'length' OR JSONExtractString(payload, 'finish_reason') = 'length')
+		FROM trace_event
+		WHERE org_id = ? AND event_type IN ('model_io', 'error')
+		AND ts >= ? AND ts < ?
+		GROUP BY prompt_ref, prompt_version
+		HAVING prompt_ref != ''
+		ORDER BY prompt_ref, prompt_version
+	`
+
+	rows, err := ta.clickhouse.Query(ctx, query, orgID, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refusal rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := make([]RefusalRate, 0)
+	for rows.Next() {
+		var rate RefusalRate
+		var calls, safety, policy, length uint64
+		if err := rows.Scan(&rate.PromptRef, &rate.PromptVersion, &calls, &safety, &policy, &length); err != nil {
+			return nil, fmt.Errorf("failed to scan refusal rate: %w", err)
+		}
+		rate.Calls, rate.SafetyRefusals, rate.PolicyBlocks, rate.LengthStops = int64(calls), int64(safety), int64(policy), int64(length)
+		if rate.Calls > 0 {
+			rate.RefusalPct = float64(rate.SafetyRefusals+rate.PolicyBlocks) / float64(rate.Calls) * 100
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, rows.Err()
+}
+
 // QueryMetrics retrieves aggregated metrics
 func (ta *TraceAnalyzer) QueryMetrics(ctx context.Context, query *MetricsQuery) ([]MetricSeries, error) {
 	series := make([]MetricSeries, 0)
@@ -372,7 +444,15 @@ func (ta *TraceAnalyzer) getMetricSeries(ctx context.Context, metric string, que
 
 // Helper methods
 
-func (ta *TraceAnalyzer) buildWhereClause(query *TraceQuery) (string, []interface{}) {
+// position returns the offset of the first event to return
+func (q *TraceQuery) position() (int, error) {
+	if q.Cursor != "" {
+		return db.DecodeOffsetCursor(q.Cursor)
+	}
+	return q.Offset, nil
+}
+
+func (ta *TraceAnalyzer) buildWhereClause(query *TraceQuery) (string, []interface{}, error) {
 	conditions := []string{"org_id = ?"}
 	args := []interface{}{query.OrgID}
 
@@ -411,7 +491,16 @@ func (ta *TraceAnalyzer) buildWhereClause(query *TraceQuery) (string, []interfac
 		args = append(args, *query.Model)
 	}
 
-	return strings.Join(conditions, " AND "), args
+	clauses, err := db.ParseFilter(query.Filter, traceFilterFields)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, clause := range clauses {
+		conditions = append(conditions, clause.SQL)
+		args = append(args, clause.Arg)
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
 }
 
 func (ta *TraceAnalyzer) buildRequestCountQuery(query *MetricsQuery) string {
@@ -0,0 +1,404 @@
+package aos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// TraceExportStatus tracks an archival export job
+type TraceExportStatus string
+
+const (
+	TraceExportPending   TraceExportStatus = "pending"
+	TraceExportRunning   TraceExportStatus = "running"
+	TraceExportSucceeded TraceExportStatus = "succeeded"
+	TraceExportFailed    TraceExportStatus = "failed"
+)
+
+// Query engines that archived traces can be registered with
+const (
+	ArchiveEngineAthena = "athena"
+	ArchiveEngineDuckDB = "duckdb"
+)
+
+// archiveTable is the external table name used for registration
+const archiveTable = "agentflow_trace_archive"
+
+// archiveColumns are exported per row; org_id and dt come from the partition path
+var archiveColumns = []struct{ name, athenaType string }{
+	{"run_id", "string"},
+	{"step_id", "string"},
+	{"ts", "timestamp"},
+	{"seq", "bigint"},
+	{"event_type", "string"},
+	{"payload", "string"},
+	{"cost_cents", "bigint"},
+	{"tokens_prompt", "int"},
+	{"tokens_completion", "int"},
+	{"provider", "string"},
+	{"model", "string"},
+	{"quality_tier", "string"},
+	{"latency_ms", "int"},
+	{"labels", "map<string,string>"},
+}
+
+// TraceExportRequest selects whole UTC days [StartDate, EndDate) to archive
+type TraceExportRequest struct {
+	OrgID     uuid.UUID `json:"org_id"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+}
+
+// TraceExportPartition is one Parquet file written by an export
+type TraceExportPartition struct {
+	Date string `json:"date"`
+	Path string `json:"path"`
+	Rows uint64 `json:"rows"`
+}
+
+// TraceExport is an archival export job and its progress
+type TraceExport struct {
+	ID           uuid.UUID              `json:"id"`
+	OrgID        uuid.UUID              `json:"org_id"`
+	StartDate    time.Time              `json:"start_date"`
+	EndDate      time.Time              `json:"end_date"`
+	Status       TraceExportStatus      `json:"status"`
+	Location     string                 `json:"location"`
+	Partitions   []TraceExportPartition `json:"partitions"`
+	RowsExported uint64                 `json:"rows_exported"`
+	Error        string                 `json:"error,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+	CompletedAt  *time.Time             `json:"completed_at,omitempty"`
+}
+
+// TraceArchiver writes aged trace events to org/date partitioned Parquet
+// files in object storage. ClickHouse writes the files itself through its
+// s3 and file table functions, so rows never pass through this process.
+type TraceArchiver struct {
+	clickhouse *db.ClickHouseDB
+	postgres   *db.PostgresDB
+	storage    config.StorageConfig
+	prefix     string
+	minAge     time.Duration
+}
+
+func NewTraceArchiver(ch *db.ClickHouseDB, pg *db.PostgresDB, storage config.StorageConfig, traces config.TracesConfig) *TraceArchiver {
+	return &TraceArchiver{
+		clickhouse: ch,
+		postgres:   pg,
+		storage:    storage,
+		prefix:     strings.Trim(traces.ArchivePrefix, "/"),
+		minAge:     traces.ArchiveAfter,
+	}
+}
+
+// Start records an export job and runs it in the background
+func (ta *TraceArchiver) Start(ctx context.Context, req TraceExportRequest) (*TraceExport, error) {
+	start, end, err := ta.exportRange(req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	export := &TraceExport{
+		ID:         uuid.New(),
+		OrgID:      req.OrgID,
+		StartDate:  start,
+		EndDate:    end,
+		Status:     TraceExportPending,
+		Location:   ta.Location(),
+		Partitions: make([]TraceExportPartition, 0),
+		CreatedAt:  time.Now(),
+	}
+
+	query := `INSERT INTO trace_export (id, org_id, start_date, end_date, status, location, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = ta.postgres.ExecContext(ctx, query, export.ID, export.OrgID, export.StartDate, export.EndDate,
+		string(export.Status), export.Location, export.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace export: %w", err)
+	}
+
+	go ta.run(context.Background(), *export)
+
+	return export, nil
+}
+
+// Get returns an export job
+func (ta *TraceArchiver) Get(ctx context.Context, orgID, exportID uuid.UUID) (*TraceExport, error) {
+	exports, err := ta.query(ctx, `WHERE org_id = $1 AND id = $2`, orgID, exportID)
+	if err != nil {
+		return nil, err
+	}
+	if len(exports) == 0 {
+		return nil, fmt.Errorf("trace export not found: %s", exportID)
+	}
+	return &exports[0], nil
+}
+
+// List returns an org's export jobs, newest first
+func (ta *TraceArchiver) List(ctx context.Context, orgID uuid.UUID, limit int) ([]TraceExport, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return ta.query(ctx, `WHERE org_id = $1 ORDER BY created_at DESC LIMIT $2`, orgID, limit)
+}
+
+// RegistrationDDL returns statements registering an org's archived partitions
+// with an external query engine
+func (ta *TraceArchiver) RegistrationDDL(ctx context.Context, orgID uuid.UUID, engine string) ([]string, error) {
+	exports, err := ta.query(ctx, `WHERE org_id = $1 AND status = $2 ORDER BY start_date`, orgID, string(TraceExportSucceeded))
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := make([]TraceExportPartition, 0)
+	for _, export := range exports {
+		partitions = append(partitions, export.Partitions...)
+	}
+
+	return TraceArchiveDDL(engine, ta.Location(), orgID, partitions)
+}
+
+// Location is the archive root in the form external engines expect
+func (ta *TraceArchiver) Location() string {
+	switch ta.storage.Type {
+	case "s3":
+		return fmt.Sprintf("s3://%s/%s", ta.storage.Bucket, ta.prefix)
+	case "gcs":
+		return fmt.Sprintf("gs://%s/%s", ta.storage.Bucket, ta.prefix)
+	default:
+		return ta.prefix
+	}
+}
+
+func (ta *TraceArchiver) run(ctx context.Context, export TraceExport) {
+	export.Status = TraceExportRunning
+	ta.save(ctx, &export)
+
+	for day := export.StartDate; day.Before(export.EndDate); day = day.AddDate(0, 0, 1) {
+		partition, err := ta.exportDay(ctx, export.OrgID, day)
+		if err != nil {
+			export.Status = TraceExportFailed
+			export.Error = err.Error()
+			break
+		}
+		if partition != nil {
+			export.Partitions = append(export.Partitions, *partition)
+			export.RowsExported += partition.Rows
+			ta.save(ctx, &export)
+		}
+	}
+
+	if export.Status != TraceExportFailed {
+		export.Status = TraceExportSucceeded
+	}
+	now := time.Now()
+	export.CompletedAt = &now
+	ta.save(ctx, &export)
+}
+
+// exportDay writes one org/date partition, skipping days without events
+func (ta *TraceArchiver) exportDay(ctx context.Context, orgID uuid.UUID, day time.Time) (*TraceExportPartition, error) {
+	date := day.Format("2006-01-02")
+
+	var rows uint64
+	countQuery := `SELECT count() FROM trace_event WHERE org_id = ? AND toDate(ts) = toDate(?)`
+	if err := ta.clickhouse.QueryRow(ctx, countQuery, orgID, date).Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to count trace events for %s: %w", date, err)
+	}
+	if rows == 0 {
+		return nil, nil
+	}
+
+	objectPath := archivePartitionPath(ta.prefix, orgID, date)
+	target, settings, err := ta.tableFunction(objectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(archiveColumns))
+	for _, column := range archiveColumns {
+		if column.name == "payload" {
+			columns = append(columns, "toString(payload) AS payload")
+			continue
+		}
+		columns = append(columns, column.name)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO FUNCTION %s
+		SELECT %s FROM trace_event
+		WHERE org_id = ? AND toDate(ts) = toDate(?)
+		ORDER BY ts
+		SETTINGS %s`, target, strings.Join(columns, ", "), settings)
+	if err := ta.clickhouse.Exec(ctx, query, orgID, date); err != nil {
+		return nil, fmt.Errorf("failed to export traces for %s: %w", date, err)
+	}
+
+	return &TraceExportPartition{Date: date, Path: objectPath, Rows: rows}, nil
+}
+
+// tableFunction returns the ClickHouse table function that writes a Parquet
+// object and the setting that lets re-runs overwrite it
+func (ta *TraceArchiver) tableFunction(objectPath string) (string, string, error) {
+	credentials := ""
+	if ta.storage.AccessKeyID != "" {
+		credentials = fmt.Sprintf("%s, %s, ", quoteCHString(ta.storage.AccessKeyID), quoteCHString(ta.storage.SecretAccessKey))
+	}
+
+	switch ta.storage.Type {
+	case "s3":
+		endpoint := ta.storage.Endpoint
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", ta.storage.Bucket, ta.storage.Region)
+		} else {
+			endpoint = strings.TrimRight(endpoint, "/") + "/" + ta.storage.Bucket
+		}
+		url := endpoint + "/" + objectPath
+		return fmt.Sprintf("s3(%s, %s'Parquet')", quoteCHString(url), credentials), "s3_truncate_on_insert = 1", nil
+	case "gcs":
+		// GCS is reached through its S3-compatible XML API with HMAC keys
+		endpoint := ta.storage.Endpoint
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+		url := strings.TrimRight(endpoint, "/") + "/" + ta.storage.Bucket + "/" + objectPath
+		return fmt.Sprintf("s3(%s, %s'Parquet')", quoteCHString(url), credentials), "s3_truncate_on_insert = 1", nil
+	case "local":
+		// Written under the ClickHouse server's user_files directory
+		return fmt.Sprintf("file(%s, 'Parquet')", quoteCHString(objectPath)), "engine_file_truncate_on_insert = 1", nil
+	default:
+		return "", "", fmt.Errorf("unsupported storage type for trace archival: %s", ta.storage.Type)
+	}
+}
+
+// exportRange normalizes the requested days and keeps exports to traces older
+// than the configured minimum age. A zero range exports the most recent eligible day.
+func (ta *TraceArchiver) exportRange(req TraceExportRequest, now time.Time) (time.Time, time.Time, error) {
+	cutoff := truncateDay(now.Add(-ta.minAge))
+
+	start, end := truncateDay(req.StartDate), truncateDay(req.EndDate)
+	if req.StartDate.IsZero() && req.EndDate.IsZero() {
+		return cutoff.AddDate(0, 0, -1), cutoff, nil
+	}
+	if req.EndDate.IsZero() {
+		end = cutoff
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("export end date must be after start date")
+	}
+	if end.After(cutoff) {
+		return time.Time{}, time.Time{}, fmt.Errorf("traces newer than %s are not archived; end date must be on or before %s",
+			ta.minAge, cutoff.Format("2006-01-02"))
+	}
+	if end.Sub(start) > 366*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("export range cannot exceed one year")
+	}
+
+	return start, end, nil
+}
+
+func (ta *TraceArchiver) save(ctx context.Context, export *TraceExport) {
+	partitions, err := json.Marshal(export.Partitions)
+	if err != nil {
+		log.Printf("Failed to marshal trace export partitions: %v", err)
+		return
+	}
+
+	query := `UPDATE trace_export SET status = $1, partitions = $2, rows_exported = $3, error = $4, completed_at = $5
+			  WHERE id = $6`
+	_, err = ta.postgres.ExecContext(ctx, query, string(export.Status), partitions, int64(export.RowsExported),
+		export.Error, export.CompletedAt, export.ID)
+	if err != nil {
+		log.Printf("Failed to update trace export %s: %v", export.ID, err)
+	}
+}
+
+func (ta *TraceArchiver) query(ctx context.Context, where string, args ...interface{}) ([]TraceExport, error) {
+	query := `SELECT id, org_id, start_date, end_date, status, location, partitions, rows_exported, error, created_at, completed_at
+			  FROM trace_export ` + where
+
+	rows, err := ta.postgres.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trace exports: %w", err)
+	}
+	defer rows.Close()
+
+	exports := make([]TraceExport, 0)
+	for rows.Next() {
+		var export TraceExport
+		var status string
+		var partitions []byte
+		var rowsExported int64
+		if err := rows.Scan(&export.ID, &export.OrgID, &export.StartDate, &export.EndDate, &status, &export.Location,
+			&partitions, &rowsExported, &export.Error, &export.CreatedAt, &export.CompletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan trace export: %w", err)
+		}
+		export.Status = TraceExportStatus(status)
+		export.RowsExported = uint64(rowsExported)
+		if err := json.Unmarshal(partitions, &export.Partitions); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal trace export partitions: %w", err)
+		}
+		exports = append(exports, export)
+	}
+
+	return exports, rows.Err()
+}
+
+// TraceArchiveDDL builds statements that expose archived Parquet partitions
+// to Athena or DuckDB. Partitions follow the Hive org_id=/dt= layout.
+func TraceArchiveDDL(engine, location string, orgID uuid.UUID, partitions []TraceExportPartition) ([]string, error) {
+	location = strings.TrimRight(location, "/")
+
+	switch strings.ToLower(engine) {
+	case ArchiveEngineAthena:
+		columns := make([]string, 0, len(archiveColumns))
+		for _, column := range archiveColumns {
+			columns = append(columns, fmt.Sprintf("  `%s` %s", column.name, column.athenaType))
+		}
+		statements := []string{fmt.Sprintf("CREATE EXTERNAL TABLE IF NOT EXISTS %s (\n%s\n)\nPARTITIONED BY (`org_id` string, `dt` string)\nSTORED AS PARQUET\nLOCATION '%s/'",
+			archiveTable, strings.Join(columns, ",\n"), location)}
+
+		if len(partitions) > 0 {
+			specs := make([]string, 0, len(partitions))
+			for _, partition := range partitions {
+				specs = append(specs, fmt.Sprintf("  PARTITION (org_id = '%s', dt = '%s') LOCATION '%s/'",
+					orgID, partition.Date, archivePartitionDir(location, orgID, partition.Date)))
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD IF NOT EXISTS\n%s", archiveTable, strings.Join(specs, "\n")))
+		}
+		return statements, nil
+	case ArchiveEngineDuckDB:
+		glob := fmt.Sprintf("%s/org_id=%s/*/*.parquet", location, orgID)
+		return []string{fmt.Sprintf("CREATE OR REPLACE VIEW %s AS SELECT * FROM read_parquet('%s', hive_partitioning = true)", archiveTable, glob)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported query engine: %s (use athena or duckdb)", engine)
+	}
+}
+
+func archivePartitionDir(root string, orgID uuid.UUID, date string) string {
+	return fmt.Sprintf("%s/org_id=%s/dt=%s", root, orgID, date)
+}
+
+func archivePartitionPath(prefix string, orgID uuid.UUID, date string) string {
+	return strings.TrimPrefix(path.Join(archivePartitionDir(prefix, orgID, date), "traces.parquet"), "/")
+}
+
+func truncateDay(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+func quoteCHString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
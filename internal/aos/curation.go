@@ -0,0 +1,626 @@
+package aos
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+	"github.com/google/uuid"
+)
+
+// FineTuneFormat is a provider's fine-tuning file layout
+type FineTuneFormat string
+
+const (
+	FineTuneOpenAI    FineTuneFormat = "openai"    // {"messages": [...]} chat JSONL
+	FineTuneAnthropic FineTuneFormat = "anthropic" // {"system": ..., "messages": [...]} as used by Bedrock
+	FineTuneGemini    FineTuneFormat = "gemini"    // {"systemInstruction": ..., "contents": [...]} as used by Vertex AI
+)
+
+// maxCurationCandidates bounds how many model calls one curation scans
+const maxCurationCandidates = 100000
+
+// Reasons a candidate model call is left out of a dataset
+const (
+	DropNoOutput           = "no_output"
+	DropUnsupportedContent = "unsupported_content"
+	DropLowEvalScore       = "low_eval_score"
+	DropNotHumanApproved   = "not_human_approved"
+	DropHumanRejected      = "human_rejected"
+	DropPII                = "pii"
+	DropDuplicate          = "duplicate"
+	DropOverLimit          = "over_limit"
+)
+
+// CurationFilter selects which model calls become training examples
+type CurationFilter struct {
+	StartTime            *time.Time `json:"start_time,omitempty"`
+	EndTime              *time.Time `json:"end_time,omitempty"`
+	Provider             string     `json:"provider,omitempty"`
+	Model                string     `json:"model,omitempty"`
+	MinEvalScore         *float64   `json:"min_eval_score,omitempty"`
+	RequireHumanApproval bool       `json:"require_human_approval"`
+	AllowPII             bool       `json:"allow_pii"` // By default calls with detected or scrubbed PII are dropped
+	MaxExamples          int        `json:"max_examples,omitempty"`
+}
+
+// CreateFineTuneDatasetRequest curates a new version of a named dataset
+type CreateFineTuneDatasetRequest struct {
+	OrgID  uuid.UUID      `json:"org_id"`
+	Name   string         `json:"name"`
+	Format FineTuneFormat `json:"format"`
+	Filter CurationFilter `json:"filter"`
+}
+
+// CurationStats reports how many candidates were kept and why the rest were dropped
+type CurationStats struct {
+	Candidates int            `json:"candidates"`
+	Selected   int            `json:"selected"`
+	Dropped    map[string]int `json:"dropped"`
+}
+
+// FineTuneDataset is a stored, versioned fine-tuning file
+type FineTuneDataset struct {
+	ID           uuid.UUID      `json:"id"`
+	OrgID        uuid.UUID      `json:"org_id"`
+	Name         string         `json:"name"`
+	Version      int            `json:"version"`
+	Format       FineTuneFormat `json:"format"`
+	Filter       CurationFilter `json:"filter"`
+	Stats        CurationStats  `json:"stats"`
+	ExampleCount int            `json:"example_count"`
+	ContentHash  string         `json:"content_hash"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// ChatMessage is a provider-neutral training message
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// CuratedExample is a selected model call as a conversation ending in the model's reply
+type CuratedExample struct {
+	RunID     uuid.UUID     `json:"run_id"`
+	StepID    uuid.UUID     `json:"step_id"`
+	Messages  []ChatMessage `json:"messages"`
+	EvalScore *float64      `json:"eval_score,omitempty"`
+}
+
+// feedbackKey identifies the model call feedback applies to
+type feedbackKey struct {
+	runID  uuid.UUID
+	stepID uuid.UUID
+}
+
+// callFeedback is the feedback known for one model call
+type callFeedback struct {
+	eval       *float64
+	human      *float64
+	correction string
+}
+
+// ParseFineTuneFormat validates a user-supplied fine-tuning format
+func ParseFineTuneFormat(format string) (FineTuneFormat, error) {
+	switch f := FineTuneFormat(strings.ToLower(format)); f {
+	case FineTuneOpenAI, FineTuneAnthropic, FineTuneGemini:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported fine-tuning format %q: use openai, anthropic or gemini", format)
+	}
+}
+
+// CurateExamples turns model calls into deduplicated training examples.
+// Human rejections of a call or its run always exclude it unless a reviewer
+// supplied a correction; when several calls share the same conversation the
+// one with the best eval score is kept.
+func CurateExamples(records []RunExportRecord, feedback map[feedbackKey]callFeedback, filter CurationFilter) ([]CuratedExample, CurationStats) {
+	stats := CurationStats{Candidates: len(records), Dropped: make(map[string]int)}
+	redactor := scl.NewRedactor()
+
+	candidates := make([]CuratedExample, 0, len(records))
+	for _, record := range records {
+		messages, reason := trainingMessages(record)
+		if reason != "" {
+			stats.Dropped[reason]++
+			continue
+		}
+
+		scores := feedback[feedbackKey{record.RunID, record.StepID}]
+		eval := scores.eval
+		if eval == nil {
+			eval = payloadEvalScore(record.Metadata)
+		}
+		human := scores.human
+		if human == nil {
+			human = feedback[feedbackKey{record.RunID, uuid.Nil}].human // Rating of the run as a whole
+		}
+
+		// A reviewer's correction is the reply the model should have given,
+		// which makes a rejected call a good example
+		if scores.correction != "" {
+			messages[len(messages)-1].Content = scores.correction
+			approved := 1.0
+			human = &approved
+		}
+
+		switch {
+		case human != nil && *human < 0.5:
+			stats.Dropped[DropHumanRejected]++
+			continue
+		case filter.RequireHumanApproval && human == nil:
+			stats.Dropped[DropNotHumanApproved]++
+			continue
+		case filter.MinEvalScore != nil && (eval == nil || *eval < *filter.MinEvalScore):
+			stats.Dropped[DropLowEvalScore]++
+			continue
+		case !filter.AllowPII && containsPII(redactor, messages):
+			stats.Dropped[DropPII]++
+			continue
+		}
+
+		candidates = append(candidates, CuratedExample{RunID: record.RunID, StepID: record.StepID, Messages: messages, EvalScore: eval})
+	}
+
+	// Visit the best scored calls first so duplicates keep the strongest example
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scoreOf(candidates[order[a]]) > scoreOf(candidates[order[b]])
+	})
+
+	keep := make([]bool, len(candidates))
+	seen := make(map[string]bool, len(candidates))
+	kept := 0
+	for _, i := range order {
+		key := conversationKey(candidates[i].Messages)
+		if seen[key] {
+			stats.Dropped[DropDuplicate]++
+			continue
+		}
+		seen[key] = true
+		if filter.MaxExamples > 0 && kept >= filter.MaxExamples {
+			stats.Dropped[DropOverLimit]++
+			continue
+		}
+		keep[i] = true
+		kept++
+	}
+
+	examples := make([]CuratedExample, 0, kept)
+	for i, example := range candidates {
+		if keep[i] {
+			examples = append(examples, example)
+		}
+	}
+	stats.Selected = len(examples)
+	return examples, stats
+}
+
+// trainingMessages converts a call's prompt and output into chat messages,
+// or returns why the call cannot be used
+func trainingMessages(record RunExportRecord) ([]ChatMessage, string) {
+	var messages []ChatMessage
+	switch prompt := record.Prompt.(type) {
+	case string:
+		if strings.TrimSpace(prompt) == "" {
+			return nil, DropUnsupportedContent
+		}
+		messages = append(messages, ChatMessage{Role: "user", Content: prompt})
+	case []interface{}:
+		for _, item := range prompt {
+			message, ok := chatMessage(item)
+			if !ok {
+				return nil, DropUnsupportedContent
+			}
+			messages = append(messages, message)
+		}
+	default:
+		return nil, DropUnsupportedContent
+	}
+	if len(messages) == 0 || messages[len(messages)-1].Role == "assistant" {
+		return nil, DropUnsupportedContent
+	}
+
+	var reply string
+	switch output := record.Output.(type) {
+	case nil:
+		return nil, DropNoOutput
+	case string:
+		reply = output
+	case map[string]interface{}:
+		content, ok := output["content"].(string)
+		if !ok {
+			return nil, DropUnsupportedContent
+		}
+		reply = content
+	default:
+		return nil, DropUnsupportedContent
+	}
+	if strings.TrimSpace(reply) == "" {
+		return nil, DropNoOutput
+	}
+
+	return append(messages, ChatMessage{Role: "assistant", Content: reply}), ""
+}
+
+// chatMessage reads a {"role", "content"} message with text content; tool
+// calls and multimodal content have no portable training representation
+func chatMessage(value interface{}) (ChatMessage, bool) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return ChatMessage{}, false
+	}
+	content, ok := fields["content"].(string)
+	if !ok {
+		return ChatMessage{}, false
+	}
+	role, _ := fields["role"].(string)
+	switch role {
+	case "system", "user", "assistant":
+		return ChatMessage{Role: role, Content: content}, true
+	default:
+		return ChatMessage{}, false
+	}
+}
+
+func payloadEvalScore(metadata map[string]interface{}) *float64 {
+	if score, ok := metadata["eval_score"].(float64); ok {
+		return &score
+	}
+	return nil
+}
+
+// containsPII reports PII the scrubber would catch, or placeholders left by
+// scrubbing at ingestion, which would teach the model to emit them
+func containsPII(redactor *scl.Redactor, messages []ChatMessage) bool {
+	for _, message := range messages {
+		if strings.Contains(message.Content, "[REDACTED_") {
+			return true
+		}
+		if _, counts := redactor.Scrub(message.Content, scl.ScrubLevelStandard); len(counts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func scoreOf(example CuratedExample) float64 {
+	if example.EvalScore == nil {
+		return -1
+	}
+	return *example.EvalScore
+}
+
+// conversationKey hashes a conversation ignoring case and whitespace so
+// trivially different copies count as duplicates
+func conversationKey(messages []ChatMessage) string {
+	hash := sha256.New()
+	for _, message := range messages {
+		hash.Write([]byte(message.Role))
+		hash.Write([]byte{0})
+		hash.Write([]byte(strings.ToLower(strings.Join(strings.Fields(message.Content), " "))))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// WriteFineTuneFile writes examples as JSONL in a provider's fine-tuning format
+func WriteFineTuneFile(w io.Writer, examples []CuratedExample, format FineTuneFormat) error {
+	buf := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	for _, example := range examples {
+		var line interface{}
+		switch format {
+		case FineTuneOpenAI:
+			line = map[string]interface{}{"messages": example.Messages}
+		case FineTuneAnthropic:
+			line = anthropicExample(example.Messages)
+		case FineTuneGemini:
+			line = geminiExample(example.Messages)
+		default:
+			return fmt.Errorf("unsupported fine-tuning format: %s", format)
+		}
+		if err := encoder.Encode(line); err != nil {
+			return fmt.Errorf("failed to encode example: %w", err)
+		}
+	}
+	return buf.Flush()
+}
+
+// anthropicExample moves system messages to the top-level system field
+func anthropicExample(messages []ChatMessage) map[string]interface{} {
+	var system []string
+	turns := make([]ChatMessage, 0, len(messages))
+	for _, message := range messages {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+			continue
+		}
+		turns = append(turns, message)
+	}
+
+	example := map[string]interface{}{"messages": turns}
+	if len(system) > 0 {
+		example["system"] = strings.Join(system, "\n\n")
+	}
+	return example
+}
+
+// geminiExample uses Gemini's contents/parts layout, where the assistant role is "model"
+func geminiExample(messages []ChatMessage) map[string]interface{} {
+	type part struct {
+		Text string `json:"text"`
+	}
+	type content struct {
+		Role  string `json:"role"`
+		Parts []part `json:"parts"`
+	}
+
+	var system []part
+	contents := make([]content, 0, len(messages))
+	for _, message := range messages {
+		switch message.Role {
+		case "system":
+			system = append(system, part{Text: message.Content})
+		case "assistant":
+			contents = append(contents, content{Role: "model", Parts: []part{{Text: message.Content}}})
+		default:
+			contents = append(contents, content{Role: "user", Parts: []part{{Text: message.Content}}})
+		}
+	}
+
+	example := map[string]interface{}{"contents": contents}
+	if len(system) > 0 {
+		example["systemInstruction"] = content{Role: "system", Parts: system}
+	}
+	return example
+}
+
+// DatasetCurator builds versioned fine-tuning datasets from production
+// traces and records which runs each version was built from
+type DatasetCurator struct {
+	analyzer *TraceAnalyzer
+	postgres *db.PostgresDB
+	blobs    *db.BlobStore
+}
+
+func NewDatasetCurator(analyzer *TraceAnalyzer, pg *db.PostgresDB) *DatasetCurator {
+	return &DatasetCurator{analyzer: analyzer, postgres: pg, blobs: db.NewBlobStore(pg)}
+}
+
+// Create curates model calls matching the filter into the next version of a dataset
+func (dc *DatasetCurator) Create(ctx context.Context, req *CreateFineTuneDatasetRequest) (*FineTuneDataset, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("dataset name is required")
+	}
+	format, err := ParseFineTuneFormat(string(req.Format))
+	if err != nil {
+		return nil, err
+	}
+
+	eventType := EventTypeModelIO
+	query := &TraceQuery{
+		OrgID:     req.OrgID,
+		StartTime: req.Filter.StartTime,
+		EndTime:   req.Filter.EndTime,
+		EventType: &eventType,
+		Limit:     maxCurationCandidates,
+	}
+	if req.Filter.Provider != "" {
+		query.Provider = &req.Filter.Provider
+	}
+	if req.Filter.Model != "" {
+		query.Model = &req.Filter.Model
+	}
+	events, _, err := dc.analyzer.QueryEvents(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query model calls: %w", err)
+	}
+
+	records := RunExportRecords(events)
+	feedback, err := dc.loadFeedback(ctx, req.OrgID, req.Filter.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	examples, stats := CurateExamples(records, feedback, req.Filter)
+	if len(examples) == 0 {
+		return nil, fmt.Errorf("no model calls matched the filter (%d candidates)", stats.Candidates)
+	}
+
+	var content bytes.Buffer
+	if err := WriteFineTuneFile(&content, examples, format); err != nil {
+		return nil, err
+	}
+	hash, err := dc.blobs.Put(ctx, req.OrgID, db.BlobKindFineTuneDataset, content.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	dataset := &FineTuneDataset{
+		ID:           uuid.New(),
+		OrgID:        req.OrgID,
+		Name:         req.Name,
+		Format:       format,
+		Filter:       req.Filter,
+		Stats:        stats,
+		ExampleCount: len(examples),
+		ContentHash:  hash,
+		CreatedAt:    time.Now(),
+	}
+	if err := dc.save(ctx, dataset, examples); err != nil {
+		return nil, err
+	}
+	return dataset, nil
+}
+
+// save stores a dataset version and its lineage in one transaction
+func (dc *DatasetCurator) save(ctx context.Context, dataset *FineTuneDataset, examples []CuratedExample) error {
+	filterJSON, err := json.Marshal(dataset.Filter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal filter: %w", err)
+	}
+	statsJSON, err := json.Marshal(dataset.Stats)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats: %w", err)
+	}
+
+	tx, err := dc.postgres.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) + 1 FROM finetune_dataset WHERE org_id = $1 AND name = $2`,
+		dataset.OrgID, dataset.Name).Scan(&dataset.Version)
+	if err != nil {
+		return fmt.Errorf("failed to get next dataset version: %w", err)
+	}
+
+	insert := `INSERT INTO finetune_dataset (id, org_id, name, version, format, filter, stats, example_count, content_hash, created_at)
+			   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err = tx.ExecContext(ctx, insert, dataset.ID, dataset.OrgID, dataset.Name, dataset.Version, string(dataset.Format),
+		filterJSON, statsJSON, dataset.ExampleCount, dataset.ContentHash, dataset.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create dataset: %w", err)
+	}
+
+	for _, example := range examples {
+		_, err := tx.ExecContext(ctx, `INSERT INTO finetune_dataset_example (dataset_id, run_id, step_id) VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING`, dataset.ID, example.RunID, example.StepID)
+		if err != nil {
+			return fmt.Errorf("failed to record dataset lineage: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit dataset: %w", err)
+	}
+	return nil
+}
+
+func (dc *DatasetCurator) loadFeedback(ctx context.Context, orgID uuid.UUID, since *time.Time) (map[feedbackKey]callFeedback, error) {
+	query := `SELECT run_id, step_id, source, score, correction FROM trace_feedback WHERE org_id = $1`
+	args := []interface{}{orgID}
+	if since != nil {
+		query += ` AND created_at >= $2`
+		args = append(args, *since)
+	}
+
+	rows, err := dc.postgres.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feedback: %w", err)
+	}
+	defer rows.Close()
+
+	feedback := make(map[feedbackKey]callFeedback)
+	for rows.Next() {
+		var key feedbackKey
+		var source string
+		var score float64
+		var correction string
+		if err := rows.Scan(&key.runID, &key.stepID, &source, &score, &correction); err != nil {
+			return nil, fmt.Errorf("failed to scan feedback: %w", err)
+		}
+		entry := feedback[key]
+		if FeedbackSource(source) == FeedbackHuman {
+			entry.human = &score
+			entry.correction = correction
+		} else {
+			entry.eval = &score
+		}
+		feedback[key] = entry
+	}
+	return feedback, rows.Err()
+}
+
+// Get returns a dataset version's metadata and file content
+func (dc *DatasetCurator) Get(ctx context.Context, orgID uuid.UUID, name string, version int) (*FineTuneDataset, []byte, error) {
+	datasets, err := dc.query(ctx, `WHERE org_id = $1 AND name = $2 AND version = $3`, orgID, name, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(datasets) == 0 {
+		return nil, nil, fmt.Errorf("dataset %s version %d not found", name, version)
+	}
+
+	blob, err := dc.blobs.Get(ctx, orgID, datasets[0].ContentHash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load dataset content: %w", err)
+	}
+	return &datasets[0], blob.Content, nil
+}
+
+// List returns an org's datasets, newest version first
+func (dc *DatasetCurator) List(ctx context.Context, orgID uuid.UUID) ([]FineTuneDataset, error) {
+	return dc.query(ctx, `WHERE org_id = $1 ORDER BY name, version DESC`, orgID)
+}
+
+// DatasetsForRun returns the dataset versions a run contributed examples to
+func (dc *DatasetCurator) DatasetsForRun(ctx context.Context, orgID, runID uuid.UUID) ([]FineTuneDataset, error) {
+	return dc.query(ctx, `WHERE org_id = $1 AND id IN (SELECT dataset_id FROM finetune_dataset_example WHERE run_id = $2)
+		ORDER BY name, version DESC`, orgID, runID)
+}
+
+// Runs returns the runs a dataset version was built from
+func (dc *DatasetCurator) Runs(ctx context.Context, orgID, datasetID uuid.UUID) ([]uuid.UUID, error) {
+	query := `SELECT DISTINCT e.run_id FROM finetune_dataset_example e
+			  JOIN finetune_dataset d ON d.id = e.dataset_id
+			  WHERE d.org_id = $1 AND e.dataset_id = $2 ORDER BY e.run_id`
+	rows, err := dc.postgres.QueryContext(ctx, query, orgID, datasetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dataset runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []uuid.UUID
+	for rows.Next() {
+		var runID uuid.UUID
+		if err := rows.Scan(&runID); err != nil {
+			return nil, fmt.Errorf("failed to scan dataset run: %w", err)
+		}
+		runs = append(runs, runID)
+	}
+	return runs, rows.Err()
+}
+
+func (dc *DatasetCurator) query(ctx context.Context, where string, args ...interface{}) ([]FineTuneDataset, error) {
+	query := `SELECT id, org_id, name, version, format, filter, stats, example_count, content_hash, created_at
+			  FROM finetune_dataset ` + where
+	rows, err := dc.postgres.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query datasets: %w", err)
+	}
+	defer rows.Close()
+
+	var datasets []FineTuneDataset
+	for rows.Next() {
+		var dataset FineTuneDataset
+		var format string
+		var filterJSON, statsJSON []byte
+		err := rows.Scan(&dataset.ID, &dataset.OrgID, &dataset.Name, &dataset.Version, &format, &filterJSON, &statsJSON,
+			&dataset.ExampleCount, &dataset.ContentHash, &dataset.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dataset: %w", err)
+		}
+		dataset.Format = FineTuneFormat(format)
+		_ = json.Unmarshal(filterJSON, &dataset.Filter)
+		_ = json.Unmarshal(statsJSON, &dataset.Stats)
+		datasets = append(datasets, dataset)
+	}
+	return datasets, rows.Err()
+}
@@ -0,0 +1,57 @@
+package aos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// TraceEraser permanently removes the trace events of runs, for data subject
+// deletion requests
+type TraceEraser struct {
+	clickhouse *db.ClickHouseDB
+}
+
+func NewTraceEraser(ch *db.ClickHouseDB) *TraceEraser {
+	return &TraceEraser{clickhouse: ch}
+}
+
+// PurgeRunTraces deletes every trace event of the given runs and returns how
+// many were removed. The delete is applied synchronously so a follow-up
+// count reflects it.
+func (te *TraceEraser) PurgeRunTraces(ctx context.Context, orgID uuid.UUID, runIDs []uuid.UUID) (int64, error) {
+	if len(runIDs) == 0 {
+		return 0, nil
+	}
+
+	count, err := te.CountRunTraces(ctx, orgID, runIDs)
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	query := `ALTER TABLE trace_event DELETE WHERE org_id = ? AND run_id IN ?
+			  SETTINGS mutations_sync = 1`
+	if err := te.clickhouse.Exec(ctx, query, orgID, runIDs); err != nil {
+		return 0, fmt.Errorf("failed to purge trace events: %w", err)
+	}
+	return count, nil
+}
+
+// CountRunTraces returns how many trace events the given runs still have
+func (te *TraceEraser) CountRunTraces(ctx context.Context, orgID uuid.UUID, runIDs []uuid.UUID) (int64, error) {
+	if len(runIDs) == 0 {
+		return 0, nil
+	}
+
+	var count uint64
+	query := `SELECT count() FROM trace_event WHERE org_id = ? AND run_id IN ?`
+	if err := te.clickhouse.QueryRow(ctx, query, orgID, runIDs).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count trace events: %w", err)
+	}
+	return int64(count), nil
+}
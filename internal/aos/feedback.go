@@ -0,0 +1,292 @@
+package aos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// FeedbackSource says who scored a model call
+type FeedbackSource string
+
+const (
+	FeedbackHuman FeedbackSource = "human"
+	FeedbackEval  FeedbackSource = "eval"
+)
+
+// FeedbackRating is a thumbs up or down from a reviewer
+type FeedbackRating string
+
+const (
+	RatingUp   FeedbackRating = "up"
+	RatingDown FeedbackRating = "down"
+)
+
+// TraceFeedback scores a model call, or a whole run when StepID is nil.
+// Human feedback is 1 for approved and 0 for rejected; eval scores come from
+// automated graders. The call's provider, model and prompt are copied from
+// its trace so feedback can be aggregated without joining trace storage.
+type TraceFeedback struct {
+	OrgID         uuid.UUID              `json:"org_id"`
+	RunID         uuid.UUID              `json:"run_id"`
+	StepID        uuid.UUID              `json:"step_id"`
+	Source        FeedbackSource         `json:"source"`
+	Score         float64                `json:"score"`
+	Correction    string                 `json:"correction,omitempty"`
+	Comment       string                 `json:"comment,omitempty"`
+	CreatedBy     string                 `json:"created_by,omitempty"`
+	Provider      string                 `json:"provider,omitempty"`
+	Model         string                 `json:"model,omitempty"`
+	PromptRef     string                 `json:"prompt_ref,omitempty"`
+	PromptVersion int                    `json:"prompt_version,omitempty"`
+	Inputs        map[string]interface{} `json:"inputs,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+}
+
+// FeedbackRequest is a reviewer's rating of a run or one of its steps,
+// optionally with the output the step should have produced
+type FeedbackRequest struct {
+	OrgID      uuid.UUID      `json:"org_id"`
+	RunID      uuid.UUID      `json:"run_id"`
+	StepID     *uuid.UUID     `json:"step_id,omitempty"`
+	Rating     FeedbackRating `json:"rating"`
+	Correction string         `json:"correction,omitempty"`
+	Comment    string         `json:"comment,omitempty"`
+	CreatedBy  string         `json:"created_by,omitempty"`
+}
+
+// FeedbackSummary aggregates human feedback for one prompt version
+type FeedbackSummary struct {
+	PromptRef     string  `json:"prompt_ref"`
+	PromptVersion int     `json:"prompt_version"`
+	Ratings       int64   `json:"ratings"`
+	Approvals     int64   `json:"approvals"`
+	Rejections    int64   `json:"rejections"`
+	Corrections   int64   `json:"corrections"`
+	ApprovalRate  float64 `json:"approval_rate"`
+}
+
+// ValidateFeedback checks a feedback score before it is stored
+func ValidateFeedback(feedback *TraceFeedback) error {
+	if feedback.Source != FeedbackHuman && feedback.Source != FeedbackEval {
+		return fmt.Errorf("feedback source must be human or eval")
+	}
+	if feedback.Score < 0 || feedback.Score > 1 {
+		return fmt.Errorf("feedback score must be between 0 and 1")
+	}
+	if feedback.Correction != "" && feedback.StepID == uuid.Nil {
+		return fmt.Errorf("corrections apply to a step, not a whole run")
+	}
+	return nil
+}
+
+// NewFeedback converts a reviewer's request into feedback to store
+func NewFeedback(req *FeedbackRequest) (*TraceFeedback, error) {
+	feedback := &TraceFeedback{
+		OrgID:      req.OrgID,
+		RunID:      req.RunID,
+		Source:     FeedbackHuman,
+		Correction: strings.TrimSpace(req.Correction),
+		Comment:    req.Comment,
+		CreatedBy:  req.CreatedBy,
+		CreatedAt:  time.Now(),
+	}
+	if req.StepID != nil {
+		feedback.StepID = *req.StepID
+	}
+
+	switch req.Rating {
+	case RatingUp:
+		feedback.Score = 1
+	case RatingDown:
+		feedback.Score = 0
+	default:
+		return nil, fmt.Errorf("rating must be up or down")
+	}
+	if err := ValidateFeedback(feedback); err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
+
+// FeedbackStore persists ratings and corrections for runs and model calls
+type FeedbackStore struct {
+	analyzer *TraceAnalyzer
+	postgres *db.PostgresDB
+}
+
+func NewFeedbackStore(analyzer *TraceAnalyzer, pg *db.PostgresDB) *FeedbackStore {
+	return &FeedbackStore{analyzer: analyzer, postgres: pg}
+}
+
+// Submit records a reviewer's rating. Step feedback is attached to the
+// step's model call so it carries the provider, model, prompt and inputs.
+func (fs *FeedbackStore) Submit(ctx context.Context, req *FeedbackRequest) (*TraceFeedback, error) {
+	feedback, err := NewFeedback(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.StepID != nil {
+		if err := fs.attachCall(ctx, feedback); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := fs.Record(ctx, feedback); err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
+
+// attachCall copies the step's model call context onto its feedback
+func (fs *FeedbackStore) attachCall(ctx context.Context, feedback *TraceFeedback) error {
+	eventType := EventTypeModelIO
+	events, _, err := fs.analyzer.QueryEvents(ctx, &TraceQuery{
+		OrgID:     feedback.OrgID,
+		RunID:     &feedback.RunID,
+		StepID:    &feedback.StepID,
+		EventType: &eventType,
+		Limit:     1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find model call: %w", err)
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("no model call recorded for step %s of run %s", feedback.StepID, feedback.RunID)
+	}
+
+	call := events[0]
+	feedback.Provider = call.Provider
+	feedback.Model = call.Model
+	feedback.PromptRef, _ = call.Payload["prompt_ref"].(string)
+	if version, ok := call.Payload["prompt_version"].(float64); ok {
+		feedback.PromptVersion = int(version)
+	}
+	feedback.Inputs, _ = call.Payload["inputs"].(map[string]interface{})
+	return nil
+}
+
+// Record stores feedback, replacing earlier feedback from the same source
+func (fs *FeedbackStore) Record(ctx context.Context, feedback *TraceFeedback) error {
+	if err := ValidateFeedback(feedback); err != nil {
+		return err
+	}
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now()
+	}
+	inputs := feedback.Inputs
+	if inputs == nil {
+		inputs = map[string]interface{}{}
+	}
+	inputsJSON, err := json.Marshal(inputs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal inputs: %w", err)
+	}
+
+	query := `INSERT INTO trace_feedback (org_id, run_id, step_id, source, score, correction, comment, created_by,
+			  provider, model, prompt_ref, prompt_version, inputs, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			  ON CONFLICT (org_id, run_id, step_id, source) DO UPDATE SET
+			  score = EXCLUDED.score, correction = EXCLUDED.correction, comment = EXCLUDED.comment,
+			  created_by = EXCLUDED.created_by, provider = EXCLUDED.provider, model = EXCLUDED.model,
+			  prompt_ref = EXCLUDED.prompt_ref, prompt_version = EXCLUDED.prompt_version,
+			  inputs = EXCLUDED.inputs, created_at = EXCLUDED.created_at`
+	_, err = fs.postgres.ExecContext(ctx, query, feedback.OrgID, feedback.RunID, feedback.StepID, string(feedback.Source),
+		feedback.Score, feedback.Correction, feedback.Comment, feedback.CreatedBy, feedback.Provider, feedback.Model,
+		feedback.PromptRef, feedback.PromptVersion, inputsJSON, feedback.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record feedback: %w", err)
+	}
+	return nil
+}
+
+// ListForRun returns all feedback on a run and its steps
+func (fs *FeedbackStore) ListForRun(ctx context.Context, orgID, runID uuid.UUID) ([]TraceFeedback, error) {
+	return fs.query(ctx, `WHERE org_id = $1 AND run_id = $2 ORDER BY created_at`, orgID, runID)
+}
+
+// Corrections returns reviewer corrections for a prompt, newest first
+func (fs *FeedbackStore) Corrections(ctx context.Context, orgID uuid.UUID, promptRef string, since time.Time, limit int) ([]TraceFeedback, error) {
+	return fs.query(ctx, `WHERE org_id = $1 AND prompt_ref = $2 AND source = 'human' AND correction != ''
+		AND created_at >= $3 ORDER BY created_at DESC LIMIT $4`, orgID, promptRef, since, limit)
+}
+
+// Summary aggregates human ratings per prompt version over a time range
+func (fs *FeedbackStore) Summary(ctx context.Context, orgID uuid.UUID, startTime, endTime time.Time) ([]FeedbackSummary, error) {
+	query := `SELECT prompt_ref, prompt_version, COUNT(*),
+			  COUNT(*) FILTER (WHERE score >= 0.5), COUNT(*) FILTER (WHERE score < 0.5),
+			  COUNT(*) FILTER (WHERE correction != '')
+			  FROM trace_feedback
+			  WHERE org_id = $1 AND source = 'human' AND prompt_ref != '' AND created_at >= $2 AND created_at < $3
+			  GROUP BY prompt_ref, prompt_version
+			  ORDER BY prompt_ref, prompt_version`
+
+	rows, err := fs.postgres.QueryContext(ctx, query, orgID, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize feedback: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := make([]FeedbackSummary, 0)
+	for rows.Next() {
+		var summary FeedbackSummary
+		if err := rows.Scan(&summary.PromptRef, &summary.PromptVersion, &summary.Ratings,
+			&summary.Approvals, &summary.Rejections, &summary.Corrections); err != nil {
+			return nil, fmt.Errorf("failed to scan feedback summary: %w", err)
+		}
+		if summary.Ratings > 0 {
+			summary.ApprovalRate = float64(summary.Approvals) / float64(summary.Ratings)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// approvalRate returns the share of approving human ratings for a prompt in a window
+func (fs *FeedbackStore) approvalRate(ctx context.Context, orgID uuid.UUID, promptRef string, startTime, endTime time.Time) (float64, int64, error) {
+	query := `SELECT COUNT(*), COUNT(*) FILTER (WHERE score >= 0.5) FROM trace_feedback
+			  WHERE org_id = $1 AND source = 'human' AND prompt_ref = $2 AND created_at >= $3 AND created_at < $4`
+
+	var ratings, approvals int64
+	if err := fs.postgres.QueryRowContext(ctx, query, orgID, promptRef, startTime, endTime).Scan(&ratings, &approvals); err != nil {
+		return 0, 0, fmt.Errorf("failed to get approval rate: %w", err)
+	}
+	if ratings == 0 {
+		return 0, 0, nil
+	}
+	return float64(approvals) / float64(ratings), ratings, nil
+}
+
+func (fs *FeedbackStore) query(ctx context.Context, where string, args ...interface{}) ([]TraceFeedback, error) {
+	query := `SELECT org_id, run_id, step_id, source, score, correction, comment, created_by,
+			  provider, model, prompt_ref, prompt_version, inputs, created_at
+			  FROM trace_feedback ` + where
+	rows, err := fs.postgres.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feedback: %w", err)
+	}
+	defer rows.Close()
+
+	feedback := make([]TraceFeedback, 0)
+	for rows.Next() {
+		var entry TraceFeedback
+		var source string
+		var inputsJSON []byte
+		err := rows.Scan(&entry.OrgID, &entry.RunID, &entry.StepID, &source, &entry.Score, &entry.Correction,
+			&entry.Comment, &entry.CreatedBy, &entry.Provider, &entry.Model, &entry.PromptRef, &entry.PromptVersion,
+			&inputsJSON, &entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan feedback: %w", err)
+		}
+		entry.Source = FeedbackSource(source)
+		_ = json.Unmarshal(inputsJSON, &entry.Inputs)
+		feedback = append(feedback, entry)
+	}
+	return feedback, rows.Err()
+}
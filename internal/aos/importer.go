@@ -0,0 +1,257 @@
+package aos
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageExportFormat identifies the provider export a usage file came from
+type UsageExportFormat string
+
+const (
+	UsageFormatOpenAI    UsageExportFormat = "openai"
+	UsageFormatAnthropic UsageExportFormat = "anthropic"
+)
+
+// UsageRecord is a single normalized row of historical provider usage
+type UsageRecord struct {
+	Row              int       `json:"row"` // Line of the export the record was read from
+	Timestamp        time.Time `json:"timestamp"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	TokensPrompt     int64     `json:"tokens_prompt"`
+	TokensCompletion int64     `json:"tokens_completion"`
+	CostCents        int64     `json:"cost_cents"`
+	Requests         int64     `json:"requests"`
+}
+
+// ImportResult summarizes a usage backfill
+type ImportResult struct {
+	Format         UsageExportFormat `json:"format"`
+	Rows           int               `json:"rows"`
+	Imported       int               `json:"imported"`
+	Skipped        int               `json:"skipped"`
+	Duplicates     int               `json:"duplicates,omitempty"` // Rows an earlier import of the same file already imported
+	FileHash       string            `json:"file_hash,omitempty"`
+	TotalCostCents int64             `json:"total_cost_cents"`
+	TotalTokens    int64             `json:"total_tokens"`
+	StartTime      time.Time         `json:"start_time,omitempty"`
+	EndTime        time.Time         `json:"end_time,omitempty"`
+	Errors         []string          `json:"errors,omitempty"`
+}
+
+// usageColumns lists accepted header names for each normalized field
+type usageColumns struct {
+	timestamp        []string
+	model            []string
+	tokensPrompt     []string
+	tokensCompletion []string
+	cost             []string
+	requests         []string
+}
+
+var usageExportColumns = map[UsageExportFormat]usageColumns{
+	UsageFormatOpenAI: {
+		timestamp:        []string{"timestamp", "start_time", "date", "usage_date"},
+		model:            []string{"model", "snapshot_id", "line_item"},
+		tokensPrompt:     []string{"input_tokens", "n_context_tokens_total", "context_tokens"},
+		tokensCompletion: []string{"output_tokens", "n_generated_tokens_total", "generated_tokens"},
+		cost:             []string{"cost", "amount_value", "cost_usd"},
+		requests:         []string{"num_model_requests", "n_requests", "requests"},
+	},
+	UsageFormatAnthropic: {
+		timestamp:        []string{"usage_date_utc", "date", "timestamp", "starting_at"},
+		model:            []string{"model", "model_version"},
+		tokensPrompt:     []string{"usage_input_tokens_no_cache", "input_tokens", "prompt_tokens"},
+		tokensCompletion: []string{"usage_output_tokens", "output_tokens", "completion_tokens"},
+		cost:             []string{"cost_usd", "cost", "amount"},
+		requests:         []string{"requests", "request_count"},
+	},
+}
+
+var usageTimestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"01/02/2006",
+}
+
+// ParseUsageExport reads a provider usage CSV export into normalized records.
+// Malformed rows are skipped and reported in the result rather than aborting.
+func ParseUsageExport(r io.Reader, format UsageExportFormat) ([]UsageRecord, *ImportResult, error) {
+	columns, ok := usageExportColumns[format]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported usage export format: %s", format)
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	tsCol := findColumn(index, columns.timestamp)
+	modelCol := findColumn(index, columns.model)
+	if tsCol < 0 || modelCol < 0 {
+		return nil, nil, fmt.Errorf("%s export must include timestamp and model columns", format)
+	}
+	promptCol := findColumn(index, columns.tokensPrompt)
+	completionCol := findColumn(index, columns.tokensCompletion)
+	costCol := findColumn(index, columns.cost)
+	requestsCol := findColumn(index, columns.requests)
+
+	result := &ImportResult{Format: format}
+	records := make([]UsageRecord, 0)
+
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		result.Rows++
+		if err != nil {
+			result.Skipped++
+			result.Errors = append(result.Errors, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+
+		record, err := parseUsageRow(row, format, tsCol, modelCol, promptCol, completionCol, costCol, requestsCol)
+		if err != nil {
+			result.Skipped++
+			result.Errors = append(result.Errors, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		record.Row = line
+
+		records = append(records, *record)
+		result.Imported++
+		result.TotalCostCents += record.CostCents
+		result.TotalTokens += record.TokensPrompt + record.TokensCompletion
+		if result.StartTime.IsZero() || record.Timestamp.Before(result.StartTime) {
+			result.StartTime = record.Timestamp
+		}
+		if record.Timestamp.After(result.EndTime) {
+			result.EndTime = record.Timestamp
+		}
+	}
+
+	return records, result, nil
+}
+
+// ToTraceEvent converts a usage record into a backfill trace event
+func (r *UsageRecord) ToTraceEvent(orgID uuid.UUID, format UsageExportFormat) TraceEvent {
+	return TraceEvent{
+		OrgID:            orgID,
+		Timestamp:        r.Timestamp,
+		EventType:        EventTypeUsageBackfill,
+		Payload:          map[string]interface{}{"source": "backfill", "format": string(format), "requests": r.Requests},
+		CostCents:        r.CostCents,
+		TokensPrompt:     clampInt32(r.TokensPrompt),
+		TokensCompletion: clampInt32(r.TokensCompletion),
+		Provider:         r.Provider,
+		Model:            r.Model,
+	}
+}
+
+func parseUsageRow(row []string, format UsageExportFormat, tsCol, modelCol, promptCol, completionCol, costCol, requestsCol int) (*UsageRecord, error) {
+	ts, err := parseUsageTimestamp(cell(row, tsCol))
+	if err != nil {
+		return nil, err
+	}
+
+	model := cell(row, modelCol)
+	if model == "" {
+		return nil, fmt.Errorf("missing model")
+	}
+
+	record := &UsageRecord{
+		Timestamp: ts,
+		Provider:  string(format),
+		Model:     model,
+	}
+
+	if record.TokensPrompt, err = parseUsageInt(cell(row, promptCol)); err != nil {
+		return nil, fmt.Errorf("invalid input tokens: %w", err)
+	}
+	if record.TokensCompletion, err = parseUsageInt(cell(row, completionCol)); err != nil {
+		return nil, fmt.Errorf("invalid output tokens: %w", err)
+	}
+	if record.Requests, err = parseUsageInt(cell(row, requestsCol)); err != nil {
+		return nil, fmt.Errorf("invalid request count: %w", err)
+	}
+
+	if costStr := strings.TrimPrefix(cell(row, costCol), "$"); costStr != "" {
+		cost, err := strconv.ParseFloat(costStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cost: %w", err)
+		}
+		record.CostCents = int64(math.Round(cost * 100))
+	}
+
+	return record, nil
+}
+
+func parseUsageTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("missing timestamp")
+	}
+
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0).UTC(), nil
+	}
+
+	for _, layout := range usageTimestampLayouts {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", value)
+}
+
+func parseUsageInt(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(strings.ReplaceAll(value, ",", ""), 10, 64)
+}
+
+func findColumn(index map[string]int, names []string) int {
+	for _, name := range names {
+		if i, ok := index[name]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func cell(row []string, col int) string {
+	if col < 0 || col >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[col])
+}
+
+func clampInt32(v int64) int32 {
+	if v > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(v)
+}
@@ -0,0 +1,137 @@
+package aos
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// runLabelCacheSize bounds the run label cache. Labels are fixed at run
+// creation so entries never go stale; the cache is simply reset when full.
+const runLabelCacheSize = 10000
+
+// labelGroupPrefix selects a cost label as a cost breakdown dimension, e.g. label:team
+const labelGroupPrefix = "label:"
+
+var labelKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,62}$`)
+
+// costGroupColumns maps cost breakdown dimensions to trace_event columns
+var costGroupColumns = map[string]string{
+	"provider":     "provider",
+	"model":        "model",
+	"quality_tier": "quality_tier",
+	"event_type":   "event_type",
+}
+
+// RunLabelResolver looks up the cost allocation labels a run was created
+// with so every trace event from the run carries them
+type RunLabelResolver struct {
+	postgres *db.PostgresDB
+
+	mu    sync.RWMutex
+	cache map[uuid.UUID]map[string]string
+}
+
+func NewRunLabelResolver(pg *db.PostgresDB) *RunLabelResolver {
+	return &RunLabelResolver{
+		postgres: pg,
+		cache:    make(map[uuid.UUID]map[string]string),
+	}
+}
+
+// Labels returns a run's cost labels. Lookup failures return nil rather than
+// an error so a database outage never blocks trace ingestion.
+func (rl *RunLabelResolver) Labels(ctx context.Context, orgID, runID uuid.UUID) map[string]string {
+	rl.mu.RLock()
+	labels, ok := rl.cache[runID]
+	rl.mu.RUnlock()
+	if ok {
+		return labels
+	}
+	if rl.postgres == nil {
+		return nil
+	}
+
+	query := `SELECT r.labels FROM workflow_run r JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE r.id = $1 AND s.org_id = $2`
+	var labelsJSON []byte
+	err := rl.postgres.QueryRowContext(ctx, query, runID, orgID).Scan(&labelsJSON)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		labels = map[string]string{}
+	case err != nil:
+		return nil
+	default:
+		if err := json.Unmarshal(labelsJSON, &labels); err != nil {
+			return nil
+		}
+	}
+
+	rl.mu.Lock()
+	if len(rl.cache) >= runLabelCacheSize {
+		rl.cache = make(map[uuid.UUID]map[string]string)
+	}
+	rl.cache[runID] = labels
+	rl.mu.Unlock()
+
+	return labels
+}
+
+// Apply copies the run's labels onto an event. Labels set by the emitter are
+// kept unless the run defines the same key, so spend cannot be re-attributed.
+func (rl *RunLabelResolver) Apply(ctx context.Context, event *TraceEvent) {
+	if event.RunID == uuid.Nil {
+		return
+	}
+	runLabels := rl.Labels(ctx, event.OrgID, event.RunID)
+	if len(runLabels) == 0 {
+		return
+	}
+
+	merged := make(map[string]string, len(event.Labels)+len(runLabels))
+	for k, v := range event.Labels {
+		merged[k] = v
+	}
+	for k, v := range runLabels {
+		merged[k] = v
+	}
+	event.Labels = merged
+}
+
+// costGroupBy resolves cost breakdown dimensions to SQL expressions. Plain
+// names select trace_event columns and label:<key> selects a cost label;
+// runs without the label are grouped under an empty value.
+func costGroupBy(groupBy []string) ([]string, []string, error) {
+	if len(groupBy) == 0 {
+		groupBy = []string{"provider", "model"}
+	}
+
+	names := make([]string, 0, len(groupBy))
+	exprs := make([]string, 0, len(groupBy))
+	for _, dimension := range groupBy {
+		if key, ok := strings.CutPrefix(dimension, labelGroupPrefix); ok {
+			if !labelKeyPattern.MatchString(key) {
+				return nil, nil, fmt.Errorf("invalid cost label %q", key)
+			}
+			names = append(names, dimension)
+			exprs = append(exprs, fmt.Sprintf("labels['%s']", key))
+			continue
+		}
+
+		column, ok := costGroupColumns[dimension]
+		if !ok {
+			return nil, nil, fmt.Errorf("unsupported cost dimension %q: use provider, model, quality_tier, event_type or label:<key>", dimension)
+		}
+		names = append(names, dimension)
+		exprs = append(exprs, column)
+	}
+	return names, exprs, nil
+}
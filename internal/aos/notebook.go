@@ -0,0 +1,271 @@
+package aos
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RunExportFormat selects how a run's trace is exported for offline analysis
+type RunExportFormat string
+
+const (
+	RunExportJSONL    RunExportFormat = "jsonl"
+	RunExportNotebook RunExportFormat = "ipynb"
+)
+
+// Payload keys model_io events carry their request and response under,
+// in order of preference
+var (
+	promptPayloadKeys = []string{"messages", "prompt", "input"}
+	outputPayloadKeys = []string{"output", "response", "completion"}
+)
+
+// RunExportRecord is one model call from a run, shaped for dataset curation
+type RunExportRecord struct {
+	RunID            uuid.UUID              `json:"run_id"`
+	StepID           uuid.UUID              `json:"step_id"`
+	Timestamp        time.Time              `json:"timestamp"`
+	Provider         string                 `json:"provider"`
+	Model            string                 `json:"model"`
+	Prompt           interface{}            `json:"prompt"`
+	Output           interface{}            `json:"output"`
+	TokensPrompt     int32                  `json:"tokens_prompt"`
+	TokensCompletion int32                  `json:"tokens_completion"`
+	CostCents        int64                  `json:"cost_cents"`
+	LatencyMs        int32                  `json:"latency_ms"`
+	QualityTier      string                 `json:"quality_tier,omitempty"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ParseRunExportFormat validates a user-supplied export format
+func ParseRunExportFormat(format string) (RunExportFormat, error) {
+	switch RunExportFormat(strings.ToLower(format)) {
+	case RunExportJSONL:
+		return RunExportJSONL, nil
+	case RunExportNotebook, "notebook":
+		return RunExportNotebook, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q: use jsonl or ipynb", format)
+	}
+}
+
+// RunExportRecords extracts a run's model calls in time order. Payloads are
+// exported as stored, which means already scrubbed per the org's capture settings.
+func RunExportRecords(events []TraceEvent) []RunExportRecord {
+	var records []RunExportRecord
+	for _, event := range events {
+		if event.EventType != EventTypeModelIO {
+			continue
+		}
+
+		record := RunExportRecord{
+			RunID:            event.RunID,
+			StepID:           event.StepID,
+			Timestamp:        event.Timestamp,
+			Provider:         event.Provider,
+			Model:            event.Model,
+			TokensPrompt:     event.TokensPrompt,
+			TokensCompletion: event.TokensCompletion,
+			CostCents:        event.CostCents,
+			LatencyMs:        event.LatencyMs,
+			QualityTier:      event.QualityTier,
+		}
+
+		used := make(map[string]bool)
+		record.Prompt = firstPayloadValue(event.Payload, promptPayloadKeys, used)
+		record.Output = firstPayloadValue(event.Payload, outputPayloadKeys, used)
+		for key, value := range event.Payload {
+			if used[key] {
+				continue
+			}
+			if record.Metadata == nil {
+				record.Metadata = make(map[string]interface{})
+			}
+			record.Metadata[key] = value
+		}
+
+		records = append(records, record)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records
+}
+
+func firstPayloadValue(payload map[string]interface{}, keys []string, used map[string]bool) interface{} {
+	for _, key := range keys {
+		if value, ok := payload[key]; ok {
+			used[key] = true
+			return value
+		}
+	}
+	return nil
+}
+
+// WriteRunExport writes a run's model calls as JSONL or a Jupyter notebook
+func WriteRunExport(w io.Writer, runID uuid.UUID, events []TraceEvent, format RunExportFormat) error {
+	records := RunExportRecords(events)
+
+	switch format {
+	case RunExportJSONL:
+		return writeRunJSONL(w, records)
+	case RunExportNotebook:
+		return writeRunNotebook(w, runID, records)
+	default:
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+func writeRunJSONL(w io.Writer, records []RunExportRecord) error {
+	buf := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+	}
+	return buf.Flush()
+}
+
+// notebookCell is a cell in the nbformat 4 schema. Code cells must carry a
+// null execution_count and empty outputs, which markdown cells must not have.
+type notebookCell map[string]interface{}
+
+type notebook struct {
+	Cells         []notebookCell         `json:"cells"`
+	Metadata      map[string]interface{} `json:"metadata"`
+	NBFormat      int                    `json:"nbformat"`
+	NBFormatMinor int                    `json:"nbformat_minor"`
+}
+
+// writeRunNotebook renders a notebook with a run summary, the records loaded
+// into a pandas DataFrame and one section per model call
+func writeRunNotebook(w io.Writer, runID uuid.UUID, records []RunExportRecord) error {
+	var totalCost int64
+	var totalTokens int64
+	for _, record := range records {
+		totalCost += record.CostCents
+		totalTokens += int64(record.TokensPrompt) + int64(record.TokensCompletion)
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to encode records: %w", err)
+	}
+
+	cells := []notebookCell{
+		markdownCell(fmt.Sprintf("# Run %s\n\n%d model calls, %d tokens, $%.2f total cost.",
+			runID, len(records), totalTokens, float64(totalCost)/100)),
+		codeCell("import json\nimport pandas as pd\n\n" +
+			"records = json.loads(" + pythonStringLiteral(string(data)) + ")\n" +
+			"df = pd.DataFrame(records)\n" +
+			"if not df.empty:\n" +
+			"    df[\"timestamp\"] = pd.to_datetime(df[\"timestamp\"])\n" +
+			"df"),
+		codeCell("df if df.empty else df.groupby([\"provider\", \"model\"])[[\"tokens_prompt\", \"tokens_completion\", \"cost_cents\", \"latency_ms\"]].sum()"),
+	}
+
+	for i, record := range records {
+		header := fmt.Sprintf("## Call %d: %s/%s\n\nStep `%s` at %s — %d prompt + %d completion tokens, %d¢, %dms",
+			i+1, record.Provider, record.Model, record.StepID, record.Timestamp.UTC().Format(time.RFC3339),
+			record.TokensPrompt, record.TokensCompletion, record.CostCents, record.LatencyMs)
+		cells = append(cells, markdownCell(header+"\n\n### Prompt\n\n"+fencedValue(record.Prompt)+"\n\n### Output\n\n"+fencedValue(record.Output)))
+	}
+
+	nb := notebook{
+		Cells: cells,
+		Metadata: map[string]interface{}{
+			"kernelspec":    map[string]string{"name": "python3", "display_name": "Python 3", "language": "python"},
+			"language_info": map[string]string{"name": "python"},
+			"agentflow":     map[string]string{"run_id": runID.String()},
+		},
+		NBFormat:      4,
+		NBFormatMinor: 4,
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(nb); err != nil {
+		return fmt.Errorf("failed to encode notebook: %w", err)
+	}
+	return nil
+}
+
+func markdownCell(text string) notebookCell {
+	return notebookCell{"cell_type": "markdown", "metadata": map[string]interface{}{}, "source": notebookSource(text)}
+}
+
+func codeCell(code string) notebookCell {
+	return notebookCell{
+		"cell_type":       "code",
+		"metadata":        map[string]interface{}{},
+		"source":          notebookSource(code),
+		"execution_count": nil,
+		"outputs":         []interface{}{},
+	}
+}
+
+// notebookSource splits text into lines that keep their newlines, as nbformat expects
+func notebookSource(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// fencedValue renders a prompt or output for markdown: strings as text and
+// structured values such as chat messages as JSON
+func fencedValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "_not captured_"
+	case string:
+		fence := codeFence(v)
+		return fence + "text\n" + v + "\n" + fence
+	default:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		fence := codeFence(string(data))
+		return fence + "json\n" + string(data) + "\n" + fence
+	}
+}
+
+// codeFence returns a backtick fence longer than any run of backticks in
+// text, so model output containing markdown cannot close the block early
+func codeFence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// pythonStringLiteral quotes s for Python source; the escapes a JSON string
+// literal uses are all valid in Python string literals
+func pythonStringLiteral(s string) string {
+	quoted, _ := json.Marshal(s) // Marshaling a string cannot fail
+	return string(quoted)
+}
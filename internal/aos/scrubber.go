@@ -0,0 +1,167 @@
+package aos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+	"github.com/google/uuid"
+)
+
+// payloadCaptureCacheTTL bounds how long a settings change takes to reach ingestion
+const payloadCaptureCacheTTL = time.Minute
+
+// PayloadCaptureSettings control how an org's trace payloads are persisted
+type PayloadCaptureSettings struct {
+	OrgID           uuid.UUID      `json:"org_id"`
+	ScrubLevel      scl.ScrubLevel `json:"scrub_level"`
+	CapturePayloads bool           `json:"capture_payloads"`
+	UpdatedAt       time.Time      `json:"updated_at,omitempty"`
+}
+
+type cachedCaptureSettings struct {
+	settings  PayloadCaptureSettings
+	expiresAt time.Time
+}
+
+// PayloadScrubber removes PII from trace payloads before they are written,
+// using the org's capture settings or the configured defaults, and the org's
+// custom entity types
+type PayloadScrubber struct {
+	postgres *db.PostgresDB
+	patterns *scl.PatternStore
+	defaults PayloadCaptureSettings
+
+	mu    sync.RWMutex
+	cache map[uuid.UUID]cachedCaptureSettings
+}
+
+func NewPayloadScrubber(pg *db.PostgresDB, cfg config.TracesConfig, patterns *scl.PatternStore) *PayloadScrubber {
+	level, err := scl.ParseScrubLevel(cfg.ScrubLevel)
+	if err != nil {
+		log.Printf("Invalid traces.scrub_level, using standard: %v", err)
+		level = scl.ScrubLevelStandard
+	}
+
+	return &PayloadScrubber{
+		postgres: pg,
+		patterns: patterns,
+		defaults: PayloadCaptureSettings{ScrubLevel: level, CapturePayloads: cfg.CapturePayloads},
+		cache:    make(map[uuid.UUID]cachedCaptureSettings),
+	}
+}
+
+// Scrub applies the org's capture settings to an event's payload in place.
+// The payload map is replaced rather than mutated so callers' data is untouched.
+func (ps *PayloadScrubber) Scrub(ctx context.Context, event *TraceEvent) {
+	if len(event.Payload) == 0 {
+		return
+	}
+
+	settings := ps.Settings(ctx, event.OrgID)
+	event.Payload = scrubPayload(ps.patterns.Redactor(ctx, event.OrgID), event.Payload, settings)
+}
+
+// Settings returns the org's effective capture settings. Lookup failures fall
+// back to the configured defaults so ingestion never stores unscrubbed data
+// because of a settings outage.
+func (ps *PayloadScrubber) Settings(ctx context.Context, orgID uuid.UUID) PayloadCaptureSettings {
+	ps.mu.RLock()
+	cached, ok := ps.cache[orgID]
+	ps.mu.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.settings
+	}
+
+	settings, err := ps.load(ctx, orgID)
+	if err != nil {
+		log.Printf("Failed to load payload capture settings for org %s, using defaults: %v", orgID, err)
+		settings = ps.defaults
+		settings.OrgID = orgID
+	}
+
+	ps.mu.Lock()
+	ps.cache[orgID] = cachedCaptureSettings{settings: settings, expiresAt: time.Now().Add(payloadCaptureCacheTTL)}
+	ps.mu.Unlock()
+
+	return settings
+}
+
+// Update stores an org's capture settings and applies them immediately
+func (ps *PayloadScrubber) Update(ctx context.Context, settings PayloadCaptureSettings) (*PayloadCaptureSettings, error) {
+	level, err := scl.ParseScrubLevel(string(settings.ScrubLevel))
+	if err != nil {
+		return nil, err
+	}
+	settings.ScrubLevel = level
+	settings.UpdatedAt = time.Now()
+
+	query := `INSERT INTO trace_payload_capture (org_id, scrub_level, capture_payloads, updated_at)
+			  VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (org_id) DO UPDATE SET
+				scrub_level = EXCLUDED.scrub_level,
+				capture_payloads = EXCLUDED.capture_payloads,
+				updated_at = EXCLUDED.updated_at`
+	_, err = ps.postgres.ExecContext(ctx, query, settings.OrgID, string(settings.ScrubLevel), settings.CapturePayloads, settings.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save payload capture settings: %w", err)
+	}
+
+	ps.mu.Lock()
+	ps.cache[settings.OrgID] = cachedCaptureSettings{settings: settings, expiresAt: time.Now().Add(payloadCaptureCacheTTL)}
+	ps.mu.Unlock()
+
+	return &settings, nil
+}
+
+func (ps *PayloadScrubber) load(ctx context.Context, orgID uuid.UUID) (PayloadCaptureSettings, error) {
+	settings := ps.defaults
+	settings.OrgID = orgID
+	if ps.postgres == nil {
+		return settings, nil
+	}
+
+	var level string
+	query := `SELECT scrub_level, capture_payloads, updated_at FROM trace_payload_capture WHERE org_id = $1`
+	err := ps.postgres.QueryRowContext(ctx, query, orgID).Scan(&level, &settings.CapturePayloads, &settings.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, fmt.Errorf("failed to query payload capture settings: %w", err)
+	}
+
+	settings.ScrubLevel = scl.ScrubLevel(level)
+	return settings, nil
+}
+
+// scrubPayload returns the payload to persist under the given settings
+func scrubPayload(redactor *scl.Redactor, payload map[string]interface{}, settings PayloadCaptureSettings) map[string]interface{} {
+	if !settings.CapturePayloads {
+		return map[string]interface{}{"payload_capture": "disabled"}
+	}
+
+	scrubbed, counts := redactor.Scrub(payload, settings.ScrubLevel)
+	result, ok := scrubbed.(map[string]interface{})
+	if !ok {
+		return payload
+	}
+
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if total > 0 {
+		result["pii_redacted"] = total
+		result["pii_redacted_types"] = counts
+	}
+
+	return result
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"github.com/google/uuid"
+	"io"
 	"time"
 
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
 )
 
 type Service struct {
@@ -15,8 +17,15 @@ type Service struct {
 	clickhouse *db.ClickHouseDB
 	postgres   *db.PostgresDB
 	collector  *EventCollector
+	scrubber   *PayloadScrubber
+	archiver   *TraceArchiver
 	analyzer   *TraceAnalyzer
 	replayer   *Replayer
+	curator    *DatasetCurator
+	feedback   *FeedbackStore
+	slos       *SLOTracker
+	views      *AnalyticsViewStore
+	importer   *UsageImporter
 }
 
 func NewService(cfg *config.Config, ch *db.ClickHouseDB, pg *db.PostgresDB) *Service {
@@ -26,9 +35,16 @@ func NewService(cfg *config.Config, ch *db.ClickHouseDB, pg *db.PostgresDB) *Ser
 		postgres:   pg,
 	}
 
-	service.collector = NewEventCollector(ch)
+	service.scrubber = NewPayloadScrubber(pg, cfg.Traces, scl.NewPatternStore(pg, cfg.Redaction))
+	service.collector = NewEventCollector(ch, db.BatchWriterConfigFrom(&cfg.ClickHouse), service.scrubber, NewRunLabelResolver(pg))
+	service.archiver = NewTraceArchiver(ch, pg, cfg.Storage, cfg.Traces)
 	service.analyzer = NewTraceAnalyzer(ch)
 	service.replayer = NewReplayer(pg, ch)
+	service.curator = NewDatasetCurator(service.analyzer, pg)
+	service.feedback = NewFeedbackStore(service.analyzer, pg)
+	service.slos = NewSLOTracker(pg, cfg.Alerts)
+	service.views = NewAnalyticsViewStore(ch, pg)
+	service.importer = NewUsageImporter(service.collector, pg, db.BatchWriterConfigFrom(&cfg.ClickHouse).BufferSize)
 
 	return service
 }
@@ -43,6 +59,48 @@ func (s *Service) IngestEvents(ctx context.Context, events []TraceEvent) error {
 	return s.collector.IngestBatch(ctx, events)
 }
 
+// GetIngestionStats returns trace writer counters, including dropped rows
+func (s *Service) GetIngestionStats() db.BatchWriterStats {
+	return s.collector.Stats()
+}
+
+// GetPayloadCapture returns the org's effective trace payload scrubbing settings
+func (s *Service) GetPayloadCapture(ctx context.Context, orgID uuid.UUID) PayloadCaptureSettings {
+	return s.scrubber.Settings(ctx, orgID)
+}
+
+// SetPayloadCapture sets the org's scrub level and whether payloads are captured at all
+func (s *Service) SetPayloadCapture(ctx context.Context, settings PayloadCaptureSettings) (*PayloadCaptureSettings, error) {
+	return s.scrubber.Update(ctx, settings)
+}
+
+// StartTraceExport archives aged trace events to Parquet in object storage
+func (s *Service) StartTraceExport(ctx context.Context, req TraceExportRequest) (*TraceExport, error) {
+	return s.archiver.Start(ctx, req)
+}
+
+// GetTraceExport returns the progress of a trace export
+func (s *Service) GetTraceExport(ctx context.Context, orgID, exportID uuid.UUID) (*TraceExport, error) {
+	return s.archiver.Get(ctx, orgID, exportID)
+}
+
+// ListTraceExports returns an org's recent trace exports
+func (s *Service) ListTraceExports(ctx context.Context, orgID uuid.UUID, limit int) ([]TraceExport, error) {
+	return s.archiver.List(ctx, orgID, limit)
+}
+
+// GetTraceArchiveDDL returns statements that register archived traces with Athena or DuckDB
+func (s *Service) GetTraceArchiveDDL(ctx context.Context, orgID uuid.UUID, engine string) ([]string, error) {
+	return s.archiver.RegistrationDDL(ctx, orgID, engine)
+}
+
+// ImportUsage backfills historical provider usage from a CSV export into the
+// trace store and charges it to the org budgets covering each record's
+// period. Rows an earlier import of the same file added are skipped.
+func (s *Service) ImportUsage(ctx context.Context, orgID uuid.UUID, format UsageExportFormat, r io.Reader) (*ImportResult, error) {
+	return s.importer.Import(ctx, orgID, format, r)
+}
+
 // QueryTrace retrieves trace events based on query parameters
 func (s *Service) QueryTrace(ctx context.Context, query *TraceQuery) (*TraceResponse, error) {
 	events, totalCount, err := s.analyzer.QueryEvents(ctx, query)
@@ -55,14 +113,20 @@ func (s *Service) QueryTrace(ctx context.Context, query *TraceQuery) (*TraceResp
 		return nil, fmt.Errorf("failed to generate summary: %w", err)
 	}
 
-	return &TraceResponse{
+	response := &TraceResponse{
 		Events:     events,
 		TotalCount: totalCount,
 		Summary:    *summary,
-	}, nil
+	}
+	response.TotalEstimate = totalCount
+	if offset, _ := query.position(); len(events) > 0 && int64(offset+len(events)) < totalCount {
+		response.NextCursor = db.EncodeOffsetCursor(offset + len(events))
+	}
+	return response, nil
 }
 
-// GetRunTrace retrieves the complete trace for a specific workflow run
+// GetRunTrace retrieves the complete trace for a specific workflow run, in
+// timeline order
 func (s *Service) GetRunTrace(ctx context.Context, orgID, runID uuid.UUID) (*TraceResponse, error) {
 	query := &TraceQuery{
 		OrgID: orgID,
@@ -70,7 +134,80 @@ func (s *Service) GetRunTrace(ctx context.Context, orgID, runID uuid.UUID) (*Tra
 		Limit: 10000, // Large limit for complete trace
 	}
 
-	return s.QueryTrace(ctx, query)
+	trace, err := s.QueryTrace(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	times := OrderTimeline(trace.Events)
+	trace.Summary.Duration, trace.Summary.StepDurations = timelineDurations(trace.Events, times)
+	return trace, nil
+}
+
+// ExportRunTrace writes a run's model calls as JSONL or a Jupyter notebook for offline analysis
+func (s *Service) ExportRunTrace(ctx context.Context, orgID, runID uuid.UUID, format RunExportFormat, w io.Writer) error {
+	trace, err := s.GetRunTrace(ctx, orgID, runID)
+	if err != nil {
+		return err
+	}
+	if len(trace.Events) == 0 {
+		return fmt.Errorf("no trace found for run %s", runID)
+	}
+
+	return WriteRunExport(w, runID, trace.Events, format)
+}
+
+// SubmitFeedback records a reviewer's thumbs up or down on a run or step.
+// The returned feedback names the step's provider and model so callers can
+// forward it to CAS as a routing reward.
+func (s *Service) SubmitFeedback(ctx context.Context, req *FeedbackRequest) (*TraceFeedback, error) {
+	return s.feedback.Submit(ctx, req)
+}
+
+// RecordFeedback stores a human rating or eval score for a model call
+func (s *Service) RecordFeedback(ctx context.Context, feedback *TraceFeedback) error {
+	return s.feedback.Record(ctx, feedback)
+}
+
+// ListRunFeedback returns the feedback left on a run and its steps
+func (s *Service) ListRunFeedback(ctx context.Context, orgID, runID uuid.UUID) ([]TraceFeedback, error) {
+	return s.feedback.ListForRun(ctx, orgID, runID)
+}
+
+// GetFeedbackSummary reports human approval per prompt version
+func (s *Service) GetFeedbackSummary(ctx context.Context, orgID uuid.UUID, startTime, endTime time.Time) ([]FeedbackSummary, error) {
+	return s.feedback.Summary(ctx, orgID, startTime, endTime)
+}
+
+// GetFeedbackCorrections returns reviewer-corrected outputs for a prompt,
+// for turning into eval cases
+func (s *Service) GetFeedbackCorrections(ctx context.Context, orgID uuid.UUID, promptRef string, since time.Time, limit int) ([]TraceFeedback, error) {
+	return s.feedback.Corrections(ctx, orgID, promptRef, since, limit)
+}
+
+// CreateFineTuneDataset curates production model calls into a new dataset version
+func (s *Service) CreateFineTuneDataset(ctx context.Context, req *CreateFineTuneDatasetRequest) (*FineTuneDataset, error) {
+	return s.curator.Create(ctx, req)
+}
+
+// GetFineTuneDataset returns a dataset version and its fine-tuning file
+func (s *Service) GetFineTuneDataset(ctx context.Context, orgID uuid.UUID, name string, version int) (*FineTuneDataset, []byte, error) {
+	return s.curator.Get(ctx, orgID, name, version)
+}
+
+// ListFineTuneDatasets returns an org's fine-tuning datasets
+func (s *Service) ListFineTuneDatasets(ctx context.Context, orgID uuid.UUID) ([]FineTuneDataset, error) {
+	return s.curator.List(ctx, orgID)
+}
+
+// GetFineTuneDatasetRuns returns the runs a dataset version was built from
+func (s *Service) GetFineTuneDatasetRuns(ctx context.Context, orgID, datasetID uuid.UUID) ([]uuid.UUID, error) {
+	return s.curator.Runs(ctx, orgID, datasetID)
+}
+
+// GetRunFineTuneDatasets returns the dataset versions a run contributed to
+func (s *Service) GetRunFineTuneDatasets(ctx context.Context, orgID, runID uuid.UUID) ([]FineTuneDataset, error) {
+	return s.curator.DatasetsForRun(ctx, orgID, runID)
 }
 
 // ReplayRun replays a workflow run for debugging and comparison
@@ -97,10 +234,13 @@ func (s *Service) ReplayRun(ctx context.Context, orgID uuid.UUID, req *ReplayReq
 // AnalyzeCosts performs cost analysis and returns breakdown and trends
 func (s *Service) AnalyzeCosts(ctx context.Context, req *CostAnalysisRequest) (*CostAnalysisResponse, error) {
 	// Build cost query
-	query := s.buildCostQuery(req)
+	query, dimensions, err := s.buildCostQuery(req)
+	if err != nil {
+		return nil, err
+	}
 
 	// Execute query
-	breakdown, err := s.analyzer.GetCostBreakdown(ctx, query)
+	breakdown, err := s.analyzer.GetCostBreakdown(ctx, query, dimensions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cost breakdown: %w", err)
 	}
@@ -143,6 +283,15 @@ func (s *Service) AnalyzeQualityDrift(ctx context.Context, req *QualityDriftRequ
 		return nil, fmt.Errorf("failed to get quality metrics: %w", err)
 	}
 
+	// Human approval for the current window against the one before it
+	approval, err := s.humanApprovalMetric(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if approval != nil {
+		metrics = append(metrics, *approval)
+	}
+
 	// Calculate drift score
 	driftScore := s.calculateDriftScore(metrics)
 
@@ -164,6 +313,42 @@ func (s *Service) AnalyzeQualityDrift(ctx context.Context, req *QualityDriftRequ
 	}, nil
 }
 
+// humanApprovalMetric compares the prompt's approval rate over the request
+// window with the window before it. It returns nil when either window has no ratings.
+func (s *Service) humanApprovalMetric(ctx context.Context, req *QualityDriftRequest) (*QualityMetric, error) {
+	windowDays := req.WindowDays
+	if windowDays <= 0 {
+		windowDays = 7
+	}
+	now := time.Now()
+	windowStart := now.AddDate(0, 0, -windowDays)
+	baselineStart := windowStart.AddDate(0, 0, -windowDays)
+
+	current, ratings, err := s.feedback.approvalRate(ctx, req.OrgID, req.PromptName, windowStart, now)
+	if err != nil {
+		return nil, err
+	}
+	baseline, baselineRatings, err := s.feedback.approvalRate(ctx, req.OrgID, req.PromptName, baselineStart, windowStart)
+	if err != nil {
+		return nil, err
+	}
+	if ratings == 0 || baselineRatings == 0 {
+		return nil, nil
+	}
+
+	change := 0.0
+	if baseline > 0 {
+		change = (current - baseline) / baseline
+	}
+	return &QualityMetric{
+		Name:      "human_approval",
+		Current:   current,
+		Baseline:  baseline,
+		Change:    change,
+		Timestamp: now,
+	}, nil
+}
+
 // QueryMetrics retrieves aggregated metrics
 func (s *Service) QueryMetrics(ctx context.Context, query *MetricsQuery) (*MetricsResponse, error) {
 	series, err := s.analyzer.QueryMetrics(ctx, query)
@@ -176,6 +361,75 @@ func (s *Service) QueryMetrics(ctx context.Context, query *MetricsQuery) (*Metri
 	}, nil
 }
 
+// GetRefusalRates reports refusal, content filter and truncation rates per prompt version
+func (s *Service) GetRefusalRates(ctx context.Context, orgID uuid.UUID, startTime, endTime time.Time) ([]RefusalRate, error) {
+	return s.analyzer.GetRefusalRates(ctx, orgID, startTime, endTime)
+}
+
+// GetSLACompliance reports SLA outcomes per workflow for runs created in a time range.
+// Compliance only counts runs with a final outcome.
+func (s *Service) GetSLACompliance(ctx context.Context, orgID uuid.UUID, startTime, endTime time.Time) ([]SLACompliance, error) {
+	query := `SELECT s.name,
+			  COUNT(*),
+			  COUNT(*) FILTER (WHERE r.sla_status = 'met'),
+			  COUNT(*) FILTER (WHERE r.sla_status = 'breached'),
+			  COUNT(*) FILTER (WHERE r.sla_status = 'at_risk'),
+			  COUNT(*) FILTER (WHERE r.sla_status = 'on_track'),
+			  COALESCE(AVG(EXTRACT(EPOCH FROM r.ended_at - r.created_at)) FILTER (WHERE r.ended_at IS NOT NULL), 0)
+			  FROM workflow_run r JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE s.org_id = $1 AND r.sla_deadline IS NOT NULL
+			  AND r.created_at >= $2 AND r.created_at < $3
+			  GROUP BY s.name
+			  ORDER BY s.name`
+
+	rows, err := s.postgres.QueryContext(ctx, query, orgID, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SLA compliance: %w", err)
+	}
+	defer rows.Close()
+
+	compliance := make([]SLACompliance, 0)
+	for rows.Next() {
+		var c SLACompliance
+		if err := rows.Scan(&c.WorkflowName, &c.Runs, &c.Met, &c.Breached, &c.AtRisk, &c.OnTrack, &c.AvgDurationSec); err != nil {
+			return nil, fmt.Errorf("failed to scan SLA compliance: %w", err)
+		}
+		if finished := c.Met + c.Breached; finished > 0 {
+			c.CompliancePct = float64(c.Met) / float64(finished) * 100
+		}
+		compliance = append(compliance, c)
+	}
+
+	return compliance, rows.Err()
+}
+
+// GetStepSLOs reports attainment, error budget and burn rate of every step
+// SLO declared in an org's latest workflow versions, optionally for one workflow
+func (s *Service) GetStepSLOs(ctx context.Context, orgID uuid.UUID, workflowName string) ([]StepSLOStatus, error) {
+	return s.slos.Status(ctx, orgID, workflowName)
+}
+
+// CreateAnalyticsView defines a per-org rollup of trace events backed by a
+// ClickHouse materialized view
+func (s *Service) CreateAnalyticsView(ctx context.Context, req *CreateAnalyticsViewRequest) (*AnalyticsView, error) {
+	return s.views.Create(ctx, req)
+}
+
+// ListAnalyticsViews returns an org's analytics views
+func (s *Service) ListAnalyticsViews(ctx context.Context, orgID uuid.UUID) ([]AnalyticsView, error) {
+	return s.views.List(ctx, orgID)
+}
+
+// DropAnalyticsView removes an analytics view and its rollup
+func (s *Service) DropAnalyticsView(ctx context.Context, orgID uuid.UUID, name string) error {
+	return s.views.Drop(ctx, orgID, name)
+}
+
+// QueryAnalyticsView reads an analytics view with bound parameters
+func (s *Service) QueryAnalyticsView(ctx context.Context, req *AnalyticsViewQuery) (*AnalyticsViewResult, error) {
+	return s.views.Query(ctx, req)
+}
+
 // GetDashboardData retrieves data for observability dashboards
 func (s *Service) GetDashboardData(ctx context.Context, orgID uuid.UUID, timeRange string) (map[string]interface{}, error) {
 	endTime := time.Now()
@@ -235,18 +489,29 @@ func (s *Service) GetDashboardData(ctx context.Context, orgID uuid.UUID, timeRan
 		return nil, fmt.Errorf("failed to get recent errors: %w", err)
 	}
 
+	slaCompliance, err := s.GetSLACompliance(ctx, orgID, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SLA compliance: %w", err)
+	}
+
 	return map[string]interface{}{
-		"overview":      overview,
-		"cost_analysis": costAnalysis,
-		"recent_errors": errors,
-		"time_range":    timeRange,
-		"generated_at":  time.Now(),
+		"overview":       overview,
+		"cost_analysis":  costAnalysis,
+		"recent_errors":  errors,
+		"sla_compliance": slaCompliance,
+		"time_range":     timeRange,
+		"generated_at":   time.Now(),
 	}, nil
 }
 
 // Helper methods
 
-func (s *Service) buildCostQuery(req *CostAnalysisRequest) string {
+func (s *Service) buildCostQuery(req *CostAnalysisRequest) (string, []string, error) {
+	dimensions, exprs, err := costGroupBy(req.GroupBy)
+	if err != nil {
+		return "", nil, err
+	}
+
 	// Build ClickHouse query for cost analysis
 	// This is a simplified version - production would be more sophisticated
 	query := fmt.Sprintf(`
@@ -262,14 +527,14 @@ func (s *Service) buildCostQuery(req *CostAnalysisRequest) string {
 		GROUP BY %s
 		ORDER BY total_cost DESC
 	`,
-		joinGroupBy(req.GroupBy),
+		joinGroupBy(exprs),
 		req.OrgID.String(),
 		req.StartTime.Format("2006-01-02 15:04:05"),
 		req.EndTime.Format("2006-01-02 15:04:05"),
-		joinGroupBy(req.GroupBy),
+		joinGroupBy(exprs),
 	)
 
-	return query
+	return query, dimensions, nil
 }
 
 func (s *Service) calculateDriftScore(metrics []QualityMetric) float64 {
@@ -0,0 +1,642 @@
+package aos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const openAIUsageExport = `timestamp,model,input_tokens,output_tokens,cost,num_model_requests
+2025-03-01,gpt-4o,"1,200",300,$0.45,4
+2025-03-02T10:00:00,gpt-4o-mini,800,200,0.02,2
+not-a-date,gpt-4o,10,10,0.01,1
+2025-03-03,,10,10,0.01,1
+1741132800,gpt-4o,100,50,1.10,1
+`
+
+func TestParseUsageExport(t *testing.T) {
+	t.Run("normalizes rows", func(t *testing.T) {
+		records, result, err := ParseUsageExport(strings.NewReader(openAIUsageExport), UsageFormatOpenAI)
+		require.NoError(t, err)
+
+		assert.Equal(t, 5, result.Rows)
+		assert.Equal(t, 3, result.Imported)
+		assert.Equal(t, 2, result.Skipped)
+		assert.Len(t, result.Errors, 2)
+		assert.Contains(t, result.Errors[0], "line 4")
+		assert.Contains(t, result.Errors[1], "missing model")
+
+		require.Len(t, records, 3)
+		assert.Equal(t, UsageRecord{
+			Row: 2, Timestamp: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Provider: "openai", Model: "gpt-4o",
+			TokensPrompt: 1200, TokensCompletion: 300, CostCents: 45, Requests: 4,
+		}, records[0])
+		assert.Equal(t, 3, records[1].Row)
+		assert.Equal(t, 6, records[2].Row)
+		assert.Equal(t, time.Unix(1741132800, 0).UTC(), records[2].Timestamp)
+
+		assert.Equal(t, int64(45+2+110), result.TotalCostCents)
+		assert.Equal(t, int64(1500+1000+150), result.TotalTokens)
+		assert.Equal(t, records[0].Timestamp, result.StartTime)
+		assert.Equal(t, records[2].Timestamp, result.EndTime)
+	})
+
+	t.Run("reads anthropic column names", func(t *testing.T) {
+		export := "usage_date_utc,model_version,usage_input_tokens_no_cache,usage_output_tokens,cost_usd\n2025-03-01,claude-3-5-sonnet,100,40,0.5\n"
+		records, result, err := ParseUsageExport(strings.NewReader(export), UsageFormatAnthropic)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Imported)
+		assert.Equal(t, "anthropic", records[0].Provider)
+		assert.Equal(t, int64(40), records[0].TokensCompletion)
+		assert.Equal(t, int64(50), records[0].CostCents)
+	})
+
+	t.Run("rejects unusable exports", func(t *testing.T) {
+		_, _, err := ParseUsageExport(strings.NewReader(openAIUsageExport), "gemini")
+		assert.Error(t, err)
+		_, _, err = ParseUsageExport(strings.NewReader("cost,requests\n1,1\n"), UsageFormatOpenAI)
+		assert.Error(t, err)
+	})
+}
+
+func TestImportUsage(t *testing.T) {
+	ctx := context.Background()
+	orgID := uuid.New()
+	export := "timestamp,model,input_tokens,output_tokens,cost\n"
+	for day := 1; day <= 5; day++ {
+		export += fmt.Sprintf("2025-03-%02d,gpt-4o,100,50,0.10\n", day)
+	}
+
+	t.Run("flushes between chunks", func(t *testing.T) {
+		sink := &fakeUsageSink{}
+		ledger := newFakeUsageLedger()
+		importer := &UsageImporter{sink: sink, ledger: ledger, chunk: 2}
+
+		result, err := importer.Import(ctx, orgID, UsageFormatOpenAI, strings.NewReader(export))
+		require.NoError(t, err)
+		assert.Equal(t, []int{2, 2, 1}, sink.batches)
+		assert.Equal(t, 3, sink.flushes)
+		assert.Equal(t, 5, result.Imported)
+		assert.Equal(t, int64(50), ledger.charged)
+		assert.NotEmpty(t, result.FileHash)
+		assert.Equal(t, result.FileHash+":2", sink.events[0].Payload["import_key"])
+	})
+
+	t.Run("re-importing resumes a failed import without charging twice", func(t *testing.T) {
+		sink := &fakeUsageSink{failAt: 2}
+		ledger := newFakeUsageLedger()
+		importer := &UsageImporter{sink: sink, ledger: ledger, chunk: 2}
+
+		result, err := importer.Import(ctx, orgID, UsageFormatOpenAI, strings.NewReader(export))
+		assert.Error(t, err)
+		assert.Equal(t, 2, result.Imported, "only the first chunk was imported")
+		assert.Equal(t, int64(20), result.TotalCostCents)
+		assert.Equal(t, int64(20), ledger.charged)
+
+		sink.failAt = 0
+		result, err = importer.Import(ctx, orgID, UsageFormatOpenAI, strings.NewReader(export))
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.Imported)
+		assert.Equal(t, 2, result.Duplicates)
+		assert.Equal(t, int64(30), result.TotalCostCents)
+		assert.Equal(t, int64(50), ledger.charged)
+
+		result, err = importer.Import(ctx, orgID, UsageFormatOpenAI, strings.NewReader(export))
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.Imported)
+		assert.Equal(t, 5, result.Duplicates)
+		assert.Equal(t, int64(50), ledger.charged)
+		assert.Len(t, sink.events, 5, "every row reached the trace store once")
+	})
+
+	t.Run("chunks fit the trace writer buffer", func(t *testing.T) {
+		assert.Equal(t, defaultUsageImportChunk, NewUsageImporter(nil, nil, 10000).chunk)
+		assert.Equal(t, 500, NewUsageImporter(nil, nil, 500).chunk)
+	})
+}
+
+func TestFineTuneCuration(t *testing.T) {
+	call := func(prompt, output interface{}, metadata map[string]interface{}) RunExportRecord {
+		return RunExportRecord{RunID: uuid.New(), StepID: uuid.New(), Provider: "openai", Model: "gpt-4o",
+			Prompt: prompt, Output: output, Metadata: metadata}
+	}
+	score := func(v float64) *float64 { return &v }
+	system := []interface{}{
+		map[string]interface{}{"role": "system", "content": "You summarize memos."},
+		map[string]interface{}{"role": "user", "content": "Summarize the memo"},
+	}
+
+	t.Run("drops unusable, rejected and duplicate calls", func(t *testing.T) {
+		best := call(system, map[string]interface{}{"content": "The memo moves the launch."}, map[string]interface{}{"eval_score": 0.9})
+		copied := call([]interface{}{
+			map[string]interface{}{"role": "system", "content": "You  summarize MEMOS."},
+			map[string]interface{}{"role": "user", "content": "summarize the memo"},
+		}, "the memo moves the launch.", map[string]interface{}{"eval_score": 0.5})
+		noOutput := call("Summarize the memo", nil, nil)
+		toolCall := call([]interface{}{map[string]interface{}{"role": "tool", "content": "{}"}}, "done", nil)
+		rejected := call("Translate the memo", "Le mémo", nil)
+		corrected := call("Title the memo", "Memo", nil)
+		personal := call("Email jane.doe@example.com the memo", "Sent", nil)
+
+		feedback := map[feedbackKey]callFeedback{
+			{rejected.RunID, uuid.Nil}:          {human: score(0)},
+			{corrected.RunID, corrected.StepID}: {human: score(0), correction: "Launch moved to May"},
+		}
+		examples, stats := CurateExamples([]RunExportRecord{copied, best, noOutput, toolCall, rejected, corrected, personal}, feedback, CurationFilter{})
+
+		assert.Equal(t, 7, stats.Candidates)
+		assert.Equal(t, 2, stats.Selected)
+		assert.Equal(t, map[string]int{DropDuplicate: 1, DropNoOutput: 1, DropUnsupportedContent: 1, DropHumanRejected: 1, DropPII: 1}, stats.Dropped)
+		require.Len(t, examples, 2)
+		assert.Equal(t, best.RunID, examples[0].RunID, "the better scored copy is kept")
+		assert.Equal(t, corrected.RunID, examples[1].RunID)
+		assert.Equal(t, ChatMessage{Role: "assistant", Content: "Launch moved to May"}, examples[1].Messages[1])
+	})
+
+	t.Run("applies score, approval and size filters", func(t *testing.T) {
+		approved := call("Summarize A", "A", map[string]interface{}{"eval_score": 0.8})
+		unrated := call("Summarize B", "B", map[string]interface{}{"eval_score": 0.95})
+		weak := call("Summarize C", "C", map[string]interface{}{"eval_score": 0.2})
+		records := []RunExportRecord{approved, unrated, weak}
+		feedback := map[feedbackKey]callFeedback{{approved.RunID, approved.StepID}: {human: score(1)}}
+
+		_, stats := CurateExamples(records, feedback, CurationFilter{MinEvalScore: score(0.5)})
+		assert.Equal(t, map[string]int{DropLowEvalScore: 1}, stats.Dropped)
+
+		examples, stats := CurateExamples(records, feedback, CurationFilter{RequireHumanApproval: true})
+		assert.Equal(t, 2, stats.Dropped[DropNotHumanApproved])
+		require.Len(t, examples, 1)
+		assert.Equal(t, approved.RunID, examples[0].RunID)
+
+		examples, stats = CurateExamples(records, feedback, CurationFilter{MaxExamples: 1})
+		assert.Equal(t, 2, stats.Dropped[DropOverLimit])
+		require.Len(t, examples, 1)
+		assert.Equal(t, unrated.RunID, examples[0].RunID, "the limit keeps the best scored calls")
+	})
+
+	t.Run("writes each provider's layout", func(t *testing.T) {
+		examples := []CuratedExample{{Messages: []ChatMessage{
+			{Role: "system", Content: "You summarize memos."},
+			{Role: "user", Content: "Summarize the memo"},
+			{Role: "assistant", Content: "The launch moved."},
+		}}}
+		write := func(format FineTuneFormat) string {
+			var out strings.Builder
+			require.NoError(t, WriteFineTuneFile(&out, examples, format))
+			return out.String()
+		}
+
+		assert.Equal(t, `{"messages":[{"role":"system","content":"You summarize memos."},{"role":"user","content":"Summarize the memo"},{"role":"assistant","content":"The launch moved."}]}`+"\n", write(FineTuneOpenAI))
+		assert.Equal(t, `{"messages":[{"role":"user","content":"Summarize the memo"},{"role":"assistant","content":"The launch moved."}],"system":"You summarize memos."}`+"\n", write(FineTuneAnthropic))
+		assert.Equal(t, `{"contents":[{"role":"user","parts":[{"text":"Summarize the memo"}]},{"role":"model","parts":[{"text":"The launch moved."}]}],"systemInstruction":{"role":"system","parts":[{"text":"You summarize memos."}]}}`+"\n", write(FineTuneGemini))
+
+		format, err := ParseFineTuneFormat("Gemini")
+		assert.NoError(t, err)
+		assert.Equal(t, FineTuneGemini, format)
+		_, err = ParseFineTuneFormat("llama")
+		assert.Error(t, err)
+	})
+}
+
+func TestTraceArchive(t *testing.T) {
+	orgID := uuid.MustParse("6f1c2d3e-4a5b-4c6d-8e7f-001122334455")
+	now := time.Date(2025, 3, 10, 15, 30, 0, 0, time.UTC)
+	archiver := NewTraceArchiver(nil, nil, config.StorageConfig{Type: "s3", Bucket: "traces", Region: "us-east-1"},
+		config.TracesConfig{ArchivePrefix: "/archive/", ArchiveAfter: 48 * time.Hour})
+
+	t.Run("zero range exports the latest eligible day", func(t *testing.T) {
+		start, end, err := archiver.exportRange(TraceExportRequest{}, now)
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 3, 7, 0, 0, 0, 0, time.UTC), start)
+		assert.Equal(t, time.Date(2025, 3, 8, 0, 0, 0, 0, time.UTC), end)
+	})
+
+	t.Run("dates are truncated to whole UTC days", func(t *testing.T) {
+		start, end, err := archiver.exportRange(TraceExportRequest{
+			StartDate: time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC),
+		}, now)
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), start)
+		assert.Equal(t, time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC), end)
+	})
+
+	t.Run("missing end date runs to the cutoff", func(t *testing.T) {
+		_, end, err := archiver.exportRange(TraceExportRequest{StartDate: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)}, now)
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 3, 8, 0, 0, 0, 0, time.UTC), end)
+	})
+
+	t.Run("rejects invalid ranges", func(t *testing.T) {
+		day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+
+		_, _, err := archiver.exportRange(TraceExportRequest{StartDate: day(5), EndDate: day(5)}, now)
+		assert.ErrorContains(t, err, "must be after start date")
+
+		_, _, err = archiver.exportRange(TraceExportRequest{StartDate: day(5), EndDate: day(9)}, now)
+		assert.ErrorContains(t, err, "on or before 2025-03-08")
+
+		_, _, err = archiver.exportRange(TraceExportRequest{StartDate: day(1).AddDate(-2, 0, 0), EndDate: day(1)}, now)
+		assert.ErrorContains(t, err, "cannot exceed one year")
+	})
+
+	t.Run("partitions use the hive layout", func(t *testing.T) {
+		assert.Equal(t, "s3://traces/archive", archiver.Location())
+		assert.Equal(t, "archive/org_id="+orgID.String()+"/dt=2025-03-01/traces.parquet",
+			archivePartitionPath(archiver.prefix, orgID, "2025-03-01"))
+		assert.Equal(t, "org_id="+orgID.String()+"/dt=2025-03-01/traces.parquet",
+			archivePartitionPath("", orgID, "2025-03-01"))
+	})
+
+	t.Run("table function targets the configured storage", func(t *testing.T) {
+		fn, setting, err := archiver.tableFunction("archive/x.parquet")
+		require.NoError(t, err)
+		assert.Equal(t, "s3('https://traces.s3.us-east-1.amazonaws.com/archive/x.parquet', 'Parquet')", fn)
+		assert.Equal(t, "s3_truncate_on_insert = 1", setting)
+
+		minio := NewTraceArchiver(nil, nil, config.StorageConfig{Type: "s3", Bucket: "traces", Endpoint: "http://minio:9000/",
+			AccessKeyID: "key", SecretAccessKey: "it's"}, config.TracesConfig{})
+		fn, _, err = minio.tableFunction("x.parquet")
+		require.NoError(t, err)
+		assert.Equal(t, `s3('http://minio:9000/traces/x.parquet', 'key', 'it\'s', 'Parquet')`, fn)
+
+		local := NewTraceArchiver(nil, nil, config.StorageConfig{Type: "local"}, config.TracesConfig{})
+		fn, setting, err = local.tableFunction("x.parquet")
+		require.NoError(t, err)
+		assert.Equal(t, "file('x.parquet', 'Parquet')", fn)
+		assert.Equal(t, "engine_file_truncate_on_insert = 1", setting)
+
+		_, _, err = NewTraceArchiver(nil, nil, config.StorageConfig{Type: "azure"}, config.TracesConfig{}).tableFunction("x.parquet")
+		assert.Error(t, err)
+	})
+
+	t.Run("athena registers each partition", func(t *testing.T) {
+		statements, err := TraceArchiveDDL("Athena", "s3://traces/archive/", orgID, []TraceExportPartition{{Date: "2025-03-01"}, {Date: "2025-03-02"}})
+		require.NoError(t, err)
+		require.Len(t, statements, 2)
+		assert.Contains(t, statements[0], "CREATE EXTERNAL TABLE IF NOT EXISTS agentflow_trace_archive")
+		assert.Contains(t, statements[0], "LOCATION 's3://traces/archive/'")
+		assert.Contains(t, statements[1], "PARTITION (org_id = '"+orgID.String()+"', dt = '2025-03-02') LOCATION 's3://traces/archive/org_id="+orgID.String()+"/dt=2025-03-02/'")
+
+		statements, err = TraceArchiveDDL(ArchiveEngineAthena, "s3://traces/archive", orgID, nil)
+		require.NoError(t, err)
+		assert.Len(t, statements, 1)
+	})
+
+	t.Run("duckdb reads the org glob", func(t *testing.T) {
+		statements, err := TraceArchiveDDL(ArchiveEngineDuckDB, "/data/archive", orgID, nil)
+		require.NoError(t, err)
+		require.Len(t, statements, 1)
+		assert.Contains(t, statements[0], "read_parquet('/data/archive/org_id="+orgID.String()+"/*/*.parquet', hive_partitioning = true)")
+	})
+
+	t.Run("rejects unknown engines", func(t *testing.T) {
+		_, err := TraceArchiveDDL("bigquery", "s3://traces", orgID, nil)
+		assert.ErrorContains(t, err, "unsupported query engine")
+	})
+}
+
+func TestPayloadScrubbing(t *testing.T) {
+	redactor := scl.NewRedactor()
+	payload := map[string]interface{}{
+		"prompt":   "Email jane@example.com or call 555-123-4567 about SSN 123-45-6789",
+		"messages": []interface{}{"see https://internal.example.com/doc", map[string]interface{}{"to": "bob@example.com"}},
+		"tokens":   42,
+	}
+
+	t.Run("standard level redacts common PII", func(t *testing.T) {
+		result := scrubPayload(redactor, payload, PayloadCaptureSettings{ScrubLevel: scl.ScrubLevelStandard, CapturePayloads: true})
+
+		assert.Equal(t, "Email [REDACTED_EMAIL] or call [REDACTED_PHONE] about SSN [REDACTED_SSN]", result["prompt"])
+		messages := result["messages"].([]interface{})
+		assert.Equal(t, "see https://internal.example.com/doc", messages[0], "urls are strict-only")
+		assert.Equal(t, "[REDACTED_EMAIL]", messages[1].(map[string]interface{})["to"])
+		assert.Equal(t, 42, result["tokens"])
+		assert.Equal(t, 4, result["pii_redacted"])
+		assert.Equal(t, map[string]int{"email": 2, "phone": 1, "ssn": 1}, result["pii_redacted_types"])
+
+		assert.Contains(t, payload["prompt"], "jane@example.com", "caller's payload is untouched")
+	})
+
+	t.Run("strict level also redacts urls", func(t *testing.T) {
+		result := scrubPayload(redactor, payload, PayloadCaptureSettings{ScrubLevel: scl.ScrubLevelStrict, CapturePayloads: true})
+		messages := result["messages"].([]interface{})
+		assert.Equal(t, "see [REDACTED_URL]", messages[0])
+	})
+
+	t.Run("off level stores the payload as is", func(t *testing.T) {
+		result := scrubPayload(redactor, payload, PayloadCaptureSettings{ScrubLevel: scl.ScrubLevelOff, CapturePayloads: true})
+		assert.Equal(t, payload["prompt"], result["prompt"])
+		assert.NotContains(t, result, "pii_redacted")
+	})
+
+	t.Run("disabled capture drops the payload", func(t *testing.T) {
+		result := scrubPayload(redactor, payload, PayloadCaptureSettings{ScrubLevel: scl.ScrubLevelStrict})
+		assert.Equal(t, map[string]interface{}{"payload_capture": "disabled"}, result)
+	})
+
+	t.Run("settings fall back to configured defaults", func(t *testing.T) {
+		orgID := uuid.New()
+		scrubber := NewPayloadScrubber(nil, config.TracesConfig{ScrubLevel: "bogus", CapturePayloads: true}, nil)
+		settings := scrubber.Settings(context.Background(), orgID)
+		assert.Equal(t, orgID, settings.OrgID)
+		assert.Equal(t, scl.ScrubLevelStandard, settings.ScrubLevel)
+		assert.True(t, settings.CapturePayloads)
+	})
+
+	t.Run("parses scrub levels", func(t *testing.T) {
+		level, err := scl.ParseScrubLevel(" Strict ")
+		require.NoError(t, err)
+		assert.Equal(t, scl.ScrubLevelStrict, level)
+
+		level, err = scl.ParseScrubLevel("")
+		require.NoError(t, err)
+		assert.Equal(t, scl.ScrubLevelStandard, level)
+
+		_, err = scl.ParseScrubLevel("paranoid")
+		assert.Error(t, err)
+	})
+}
+
+func TestRunExport(t *testing.T) {
+	runID := uuid.New()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []TraceEvent{
+		{RunID: runID, Timestamp: base.Add(2 * time.Second), EventType: EventTypeModelIO, Provider: "openai", Model: "gpt-4o",
+			Payload:      map[string]interface{}{"prompt": "second", "response": "Here:\n```go\nfmt.Println()\n```", "temperature": 0.2},
+			TokensPrompt: 10, TokensCompletion: 5, CostCents: 150},
+		{RunID: runID, Timestamp: base, EventType: EventTypeStarted},
+		{RunID: runID, Timestamp: base.Add(time.Second), EventType: EventTypeModelIO, Provider: "anthropic", Model: "claude",
+			Payload:      map[string]interface{}{"messages": []interface{}{"hi"}, "prompt": "ignored", "output": "hello"},
+			TokensPrompt: 3, TokensCompletion: 2, CostCents: 50},
+	}
+
+	t.Run("extracts model calls in time order", func(t *testing.T) {
+		records := RunExportRecords(events)
+		require.Len(t, records, 2)
+
+		assert.Equal(t, "claude", records[0].Model)
+		assert.Equal(t, []interface{}{"hi"}, records[0].Prompt, "messages take precedence over prompt")
+		assert.Equal(t, "hello", records[0].Output)
+		assert.Equal(t, map[string]interface{}{"prompt": "ignored"}, records[0].Metadata)
+
+		assert.Equal(t, "second", records[1].Prompt)
+		assert.Equal(t, map[string]interface{}{"temperature": 0.2}, records[1].Metadata)
+	})
+
+	t.Run("writes one JSON record per line", func(t *testing.T) {
+		var buf strings.Builder
+		require.NoError(t, WriteRunExport(&buf, runID, events, RunExportJSONL))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 2)
+		var record RunExportRecord
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+		assert.Equal(t, "gpt-4o", record.Model)
+		assert.Equal(t, int64(150), record.CostCents)
+	})
+
+	t.Run("writes a valid nbformat 4 notebook", func(t *testing.T) {
+		var buf strings.Builder
+		require.NoError(t, WriteRunExport(&buf, runID, events, RunExportNotebook))
+
+		var nb struct {
+			Cells []struct {
+				CellType       string        `json:"cell_type"`
+				Source         []string      `json:"source"`
+				ExecutionCount *int          `json:"execution_count"`
+				Outputs        []interface{} `json:"outputs"`
+			} `json:"cells"`
+			NBFormat int `json:"nbformat"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(buf.String()), &nb))
+		assert.Equal(t, 4, nb.NBFormat)
+		require.Len(t, nb.Cells, 5)
+
+		summary := strings.Join(nb.Cells[0].Source, "")
+		assert.Equal(t, "markdown", nb.Cells[0].CellType)
+		assert.Contains(t, summary, "2 model calls, 20 tokens, $2.00 total cost")
+
+		assert.Equal(t, "code", nb.Cells[1].CellType)
+		assert.NotNil(t, nb.Cells[1].Outputs)
+		assert.Contains(t, strings.Join(nb.Cells[1].Source, ""), "pd.DataFrame(records)")
+
+		call := strings.Join(nb.Cells[4].Source, "")
+		assert.Contains(t, call, "## Call 2: openai/gpt-4o")
+		assert.Contains(t, call, "````text\nHere:\n```go", "fence is longer than the output's own fences")
+	})
+
+	t.Run("parses export formats", func(t *testing.T) {
+		format, err := ParseRunExportFormat("JSONL")
+		require.NoError(t, err)
+		assert.Equal(t, RunExportJSONL, format)
+
+		format, err = ParseRunExportFormat("notebook")
+		require.NoError(t, err)
+		assert.Equal(t, RunExportNotebook, format)
+
+		_, err = ParseRunExportFormat("csv")
+		assert.Error(t, err)
+	})
+}
+
+func TestOrderTimeline(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return base.Add(time.Duration(seconds) * time.Second) }
+	stepA, stepB := uuid.New(), uuid.New()
+
+	// The worker that completed step A runs five seconds behind the one that started it
+	events := []TraceEvent{
+		{StepID: stepB, Sequence: 3, Timestamp: at(20), EventType: EventTypeStarted},
+		{StepID: stepA, Sequence: 2, Timestamp: at(5), EventType: EventTypeCompleted},
+		{StepID: stepB, Timestamp: at(15), EventType: EventTypeLog},
+		{StepID: stepA, Sequence: 1, Timestamp: at(10), EventType: EventTypeStarted},
+		{StepID: stepB, Sequence: 4, Timestamp: at(26), EventType: EventTypeCompleted},
+	}
+
+	times := OrderTimeline(events)
+
+	t.Run("orders by sequence and merges unsequenced events by time", func(t *testing.T) {
+		order := make([]string, len(events))
+		for i, event := range events {
+			order[i] = fmt.Sprintf("%d:%s", event.Sequence, event.EventType)
+		}
+		assert.Equal(t, []string{"1:started", "2:completed", "0:log", "3:started", "4:completed"}, order)
+	})
+
+	t.Run("effective times never run backwards", func(t *testing.T) {
+		assert.Equal(t, []time.Time{at(10), at(10), at(15), at(20), at(26)}, times)
+	})
+
+	t.Run("durations use effective times", func(t *testing.T) {
+		total, steps := timelineDurations(events, times)
+		assert.Equal(t, 16*time.Second, total)
+		assert.Equal(t, map[string]time.Duration{stepA.String(): 0, stepB.String(): 6 * time.Second}, steps)
+	})
+
+	t.Run("unfinished steps are left out", func(t *testing.T) {
+		open := []TraceEvent{{StepID: stepA, Sequence: 1, Timestamp: at(0), EventType: EventTypeStarted}}
+		total, steps := timelineDurations(open, OrderTimeline(open))
+		assert.Zero(t, total)
+		assert.Empty(t, steps)
+
+		total, steps = timelineDurations(nil, nil)
+		assert.Zero(t, total)
+		assert.Nil(t, steps)
+	})
+}
+
+func TestAnalyticsViews(t *testing.T) {
+	orgID := uuid.MustParse("6f1c2d3e-4a5b-4c6d-8e7f-001122334455")
+	view := &AnalyticsView{
+		OrgID:      orgID,
+		Name:       "daily_cost",
+		Dimensions: []string{"day", "model", "label:team.name"},
+		Measures:   []string{"events", "errors", "cost_cents", "latency_ms"},
+	}
+	table := "analytics_view_6f1c2d3e4a5b4c6d8e7f001122334455_daily_cost"
+
+	t.Run("validates definitions", func(t *testing.T) {
+		valid := CreateAnalyticsViewRequest{Name: "daily_cost", Dimensions: []string{"day"}, Measures: []string{"events"}}
+		assert.NoError(t, valid.Validate())
+
+		cases := map[string]CreateAnalyticsViewRequest{
+			"invalid view name":      {Name: "Daily-Cost", Dimensions: []string{"day"}, Measures: []string{"events"}},
+			"at least one dimension": {Name: "v", Measures: []string{"events"}},
+			"unsupported dimension":  {Name: "v", Dimensions: []string{"ts"}, Measures: []string{"events"}},
+			"invalid cost label":     {Name: "v", Dimensions: []string{"label:a'b"}, Measures: []string{"events"}},
+			"duplicate dimension":    {Name: "v", Dimensions: []string{"model", "model"}, Measures: []string{"events"}},
+			"at least one measure":   {Name: "v", Dimensions: []string{"day"}},
+			"unsupported measure":    {Name: "v", Dimensions: []string{"day"}, Measures: []string{"avg(cost_cents)"}},
+			"duplicate measure":      {Name: "v", Dimensions: []string{"day"}, Measures: []string{"events", "events"}},
+		}
+		for want, req := range cases {
+			assert.ErrorContains(t, req.Validate(), want)
+		}
+	})
+
+	t.Run("builds a summing rollup", func(t *testing.T) {
+		statements, err := AnalyticsViewDDL(view)
+		require.NoError(t, err)
+		require.Len(t, statements, 2)
+
+		assert.Equal(t, "CREATE TABLE IF NOT EXISTS "+table+" (day Date, model LowCardinality(String), label_team_name LowCardinality(String), "+
+			"events Int64, errors Int64, cost_cents Int64, latency_ms Int64) ENGINE = SummingMergeTree() ORDER BY (day, model, label_team_name)", statements[0])
+		assert.Contains(t, statements[1], "CREATE MATERIALIZED VIEW IF NOT EXISTS "+table+"_mv TO "+table)
+		assert.Contains(t, statements[1], "labels['team.name'] AS label_team_name")
+		assert.Contains(t, statements[1], "toInt64(countIf(event_type = 'error')) AS errors")
+		assert.Contains(t, statements[1], "WHERE org_id = '"+orgID.String()+"' GROUP BY day, model, label_team_name")
+	})
+
+	t.Run("binds query parameters", func(t *testing.T) {
+		from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+		query, args, err := buildViewQuery(view, &AnalyticsViewQuery{
+			Params: map[string]string{"model": "gpt-4o' OR 1=1 --"},
+			From:   &from,
+			Limit:  50000,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "SELECT toString(day), toString(model), toString(label_team_name), toInt64(sum(events)), toInt64(sum(errors)), "+
+			"toInt64(sum(cost_cents)), toInt64(sum(latency_ms)) FROM "+table+" WHERE toString(model) = ? AND day >= ? "+
+			"GROUP BY day, model, label_team_name ORDER BY day, model, label_team_name LIMIT 10000", query)
+		assert.Equal(t, []interface{}{"gpt-4o' OR 1=1 --", from}, args)
+	})
+
+	t.Run("rejects unknown parameters", func(t *testing.T) {
+		_, _, err := buildViewQuery(view, &AnalyticsViewQuery{Params: map[string]string{"provider": "openai"}})
+		assert.ErrorContains(t, err, `no dimension "provider"`)
+
+		untimed := &AnalyticsView{Name: "by_model", Dimensions: []string{"model"}, Measures: []string{"events"}}
+		to := time.Now()
+		_, _, err = buildViewQuery(untimed, &AnalyticsViewQuery{To: &to})
+		assert.ErrorContains(t, err, "no day or hour dimension")
+
+		query, _, err := buildViewQuery(untimed, &AnalyticsViewQuery{})
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(query, fmt.Sprintf("LIMIT %d", defaultViewQueryLimit)))
+	})
+
+	t.Run("derives rates from measures", func(t *testing.T) {
+		row := newViewRow(view, []string{"2025-03-01", "gpt-4o", "search"}, []int64{4, 1, 200, 1000})
+		assert.Equal(t, "gpt-4o", row.Dimensions["model"])
+		assert.Equal(t, int64(200), row.Measures["cost_cents"])
+		require.NotNil(t, row.ErrorRate)
+		assert.InDelta(t, 0.25, *row.ErrorRate, 1e-9)
+		require.NotNil(t, row.AvgLatencyMs)
+		assert.InDelta(t, 250, *row.AvgLatencyMs, 1e-9)
+
+		empty := newViewRow(view, []string{"2025-03-01", "gpt-4o", "search"}, []int64{0, 0, 0, 0})
+		assert.Nil(t, empty.ErrorRate)
+		assert.Nil(t, empty.AvgLatencyMs)
+	})
+}
+
+// fakeUsageSink collects ingested events; failAt fails that ingest call (1-based)
+type fakeUsageSink struct {
+	events  []TraceEvent
+	batches []int
+	flushes int
+	calls   int
+	failAt  int
+}
+
+func (f *fakeUsageSink) IngestBatch(ctx context.Context, events []TraceEvent) error {
+	f.calls++
+	if f.calls == f.failAt {
+		return errors.New("trace event buffer full")
+	}
+	f.events = append(f.events, events...)
+	f.batches = append(f.batches, len(events))
+	return nil
+}
+
+func (f *fakeUsageSink) Flush(ctx context.Context) error {
+	f.flushes++
+	return nil
+}
+
+// fakeUsageLedger keeps imported rows in memory and sums what it charged
+type fakeUsageLedger struct {
+	rows    map[string]bool
+	charged int64
+}
+
+func newFakeUsageLedger() *fakeUsageLedger {
+	return &fakeUsageLedger{rows: make(map[string]bool)}
+}
+
+func (f *fakeUsageLedger) Imported(ctx context.Context, orgID uuid.UUID, fileHash string, rows []int64) (map[int64]bool, error) {
+	imported := make(map[int64]bool)
+	for _, row := range rows {
+		if f.rows[f.key(orgID, fileHash, row)] {
+			imported[row] = true
+		}
+	}
+	return imported, nil
+}
+
+func (f *fakeUsageLedger) Record(ctx context.Context, orgID uuid.UUID, fileHash string, records []UsageRecord) error {
+	for _, record := range records {
+		key := f.key(orgID, fileHash, int64(record.Row))
+		if !f.rows[key] {
+			f.rows[key] = true
+			f.charged += record.CostCents
+		}
+	}
+	return nil
+}
+
+func (f *fakeUsageLedger) key(orgID uuid.UUID, fileHash string, row int64) string {
+	return fmt.Sprintf("%s/%s/%d", orgID, fileHash, row)
+}
@@ -0,0 +1,384 @@
+package aos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// Burn rates that raise alerts, after the multiwindow scheme of the SRE
+// workbook: a page spends 2% of a 30 day error budget within an hour, a
+// ticket 5% within six hours
+const (
+	pageBurnRate     = 14.4
+	ticketBurnRate   = 6.0
+	defaultSLOWindow = 30 * 24 * time.Hour
+	sloAlertCooldown = time.Hour
+	p95Target        = 0.95
+)
+
+type SLOObjective string
+
+const (
+	SLOLatency SLOObjective = "latency"
+	SLOCost    SLOObjective = "cost"
+	SLOSuccess SLOObjective = "success_rate"
+)
+
+type SLOAlertSeverity string
+
+const (
+	SLOAlertPage   SLOAlertSeverity = "page"
+	SLOAlertTicket SLOAlertSeverity = "ticket"
+)
+
+// StepSLOSpec is a step's slo block as declared in its workflow spec (aor.StepSLO)
+type StepSLOSpec struct {
+	LatencyP95   time.Duration `json:"latency_p95,omitempty"`
+	CostP95Cents int64         `json:"cost_p95_cents,omitempty"`
+	SuccessRate  float64       `json:"success_rate,omitempty"`
+	Window       time.Duration `json:"window,omitempty"`
+}
+
+// SLOAttainment is how one objective of a step fared over its window
+type SLOAttainment struct {
+	Objective               SLOObjective     `json:"objective"`
+	Target                  float64          `json:"target"`              // Share of attempts that must be good
+	Threshold               string           `json:"threshold,omitempty"` // Latency or cost an attempt must stay within
+	Attempts                int64            `json:"attempts"`
+	Bad                     int64            `json:"bad"`
+	AttainmentPct           float64          `json:"attainment_pct"`
+	ErrorBudgetRemainingPct float64          `json:"error_budget_remaining_pct"` // Negative once the budget is spent
+	BurnRate1h              float64          `json:"burn_rate_1h"`
+	BurnRate6h              float64          `json:"burn_rate_6h"`
+	Alert                   SLOAlertSeverity `json:"alert,omitempty"`
+}
+
+// StepSLOStatus reports every objective declared on one step
+type StepSLOStatus struct {
+	OrgID        uuid.UUID       `json:"org_id"`
+	WorkflowName string          `json:"workflow_name"`
+	StepID       string          `json:"step_id"`
+	Window       string          `json:"window"`
+	Objectives   []SLOAttainment `json:"objectives"`
+}
+
+// SLOBurnAlert is sent when a step spends its error budget too fast
+type SLOBurnAlert struct {
+	OrgID        uuid.UUID        `json:"org_id"`
+	WorkflowName string           `json:"workflow_name"`
+	StepID       string           `json:"step_id"`
+	Objective    SLOObjective     `json:"objective"`
+	Severity     SLOAlertSeverity `json:"severity"`
+	BurnRate     float64          `json:"burn_rate"`
+	Message      string           `json:"message"`
+	Timestamp    time.Time        `json:"timestamp"`
+}
+
+// sloCounts are finished attempts and the bad ones per objective in one window
+type sloCounts struct {
+	attempts, failed, slow, costly int64
+}
+
+func (c sloCounts) bad(objective SLOObjective) int64 {
+	switch objective {
+	case SLOLatency:
+		return c.slow
+	case SLOCost:
+		return c.costly
+	default:
+		return c.failed
+	}
+}
+
+// SLOTracker computes step SLO attainment from step runs and alerts on fast burn
+type SLOTracker struct {
+	postgres *db.PostgresDB
+	alerts   config.AlertsConfig
+	client   *http.Client
+
+	mu        sync.Mutex
+	lastAlert map[string]time.Time
+}
+
+func NewSLOTracker(pg *db.PostgresDB, alerts config.AlertsConfig) *SLOTracker {
+	return &SLOTracker{
+		postgres:  pg,
+		alerts:    alerts,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		lastAlert: make(map[string]time.Time),
+	}
+}
+
+// Status computes SLO attainment for every step of an org's latest workflow
+// versions that declares an slo; uuid.Nil covers all orgs
+func (st *SLOTracker) Status(ctx context.Context, orgID uuid.UUID, workflowName string) ([]StepSLOStatus, error) {
+	query := `SELECT DISTINCT ON (org_id, name) org_id, name, dag FROM workflow_spec
+			  WHERE ($1 = '00000000-0000-0000-0000-000000000000'::uuid OR org_id = $1) AND ($2 = '' OR name = $2)
+			  ORDER BY org_id, name, version DESC`
+
+	rows, err := st.postgres.QueryContext(ctx, query, orgID, workflowName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workflow specs: %w", err)
+	}
+	type declared struct {
+		orgID    uuid.UUID
+		workflow string
+		stepID   string
+		slo      StepSLOSpec
+	}
+	targets := make([]declared, 0)
+	for rows.Next() {
+		var org uuid.UUID
+		var name string
+		var dagJSON []byte
+		if err := rows.Scan(&org, &name, &dagJSON); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan workflow spec: %w", err)
+		}
+		var dag struct {
+			Steps []struct {
+				ID  string       `json:"id"`
+				SLO *StepSLOSpec `json:"slo"`
+			} `json:"steps"`
+		}
+		if err := json.Unmarshal(dagJSON, &dag); err != nil {
+			continue // Specs that fail to decode declare no SLOs we can read
+		}
+		for _, step := range dag.Steps {
+			if step.SLO != nil {
+				targets = append(targets, declared{org, name, step.ID, *step.SLO})
+			}
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read workflow specs: %w", err)
+	}
+
+	now := time.Now()
+	statuses := make([]StepSLOStatus, 0, len(targets))
+	for _, target := range targets {
+		counts, err := st.stepCounts(ctx, target.orgID, target.workflow, target.stepID, target.slo, now)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, EvaluateStepSLO(target.orgID, target.workflow, target.stepID, target.slo, counts))
+	}
+	return statuses, nil
+}
+
+// stepCounts counts a step's finished attempts over the SLO window, the last
+// six hours and the last hour
+func (st *SLOTracker) stepCounts(ctx context.Context, orgID uuid.UUID, workflow, stepID string, slo StepSLOSpec, now time.Time) ([3]sloCounts, error) {
+	query := `SELECT
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $4),
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $4 AND sr.status = 'failed'),
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $4 AND $7::float8 > 0 AND sr.ended_at - sr.started_at > $7::float8 * INTERVAL '1 second'),
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $4 AND $8::bigint > 0 AND sr.cost_cents > $8::bigint),
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $5),
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $5 AND sr.status = 'failed'),
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $5 AND $7::float8 > 0 AND sr.ended_at - sr.started_at > $7::float8 * INTERVAL '1 second'),
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $5 AND $8::bigint > 0 AND sr.cost_cents > $8::bigint),
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $6),
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $6 AND sr.status = 'failed'),
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $6 AND $7::float8 > 0 AND sr.ended_at - sr.started_at > $7::float8 * INTERVAL '1 second'),
+			  COUNT(*) FILTER (WHERE sr.ended_at >= $6 AND $8::bigint > 0 AND sr.cost_cents > $8::bigint)
+			  FROM step_run sr
+			  JOIN workflow_run r ON r.id = sr.workflow_run_id
+			  JOIN workflow_spec s ON s.id = r.workflow_spec_id
+			  WHERE s.org_id = $1 AND s.name = $2 AND sr.node_id = $3
+			  AND sr.status IN ('succeeded', 'failed') AND sr.started_at IS NOT NULL
+			  AND sr.ended_at >= LEAST($4, $5)`
+
+	window := slo.Window
+	if window == 0 {
+		window = defaultSLOWindow
+	}
+	var counts [3]sloCounts
+	err := st.postgres.QueryRowContext(ctx, query, orgID, workflow, stepID,
+		now.Add(-window), now.Add(-6*time.Hour), now.Add(-time.Hour),
+		slo.LatencyP95.Seconds(), slo.CostP95Cents,
+	).Scan(
+		&counts[0].attempts, &counts[0].failed, &counts[0].slow, &counts[0].costly,
+		&counts[1].attempts, &counts[1].failed, &counts[1].slow, &counts[1].costly,
+		&counts[2].attempts, &counts[2].failed, &counts[2].slow, &counts[2].costly,
+	)
+	if err != nil {
+		return counts, fmt.Errorf("failed to count attempts of step %s/%s: %w", workflow, stepID, err)
+	}
+	return counts, nil
+}
+
+// EvaluateStepSLO turns attempt counts for the SLO window, last six hours
+// and last hour into attainment, error budget and burn rate per objective
+func EvaluateStepSLO(orgID uuid.UUID, workflow, stepID string, slo StepSLOSpec, counts [3]sloCounts) StepSLOStatus {
+	window := slo.Window
+	if window == 0 {
+		window = defaultSLOWindow
+	}
+	status := StepSLOStatus{OrgID: orgID, WorkflowName: workflow, StepID: stepID, Window: window.String()}
+
+	if slo.LatencyP95 > 0 {
+		status.Objectives = append(status.Objectives, evaluateObjective(SLOLatency, p95Target, slo.LatencyP95.String(), counts))
+	}
+	if slo.CostP95Cents > 0 {
+		status.Objectives = append(status.Objectives, evaluateObjective(SLOCost, p95Target, fmt.Sprintf("%d¢", slo.CostP95Cents), counts))
+	}
+	if slo.SuccessRate > 0 {
+		status.Objectives = append(status.Objectives, evaluateObjective(SLOSuccess, slo.SuccessRate, "", counts))
+	}
+	return status
+}
+
+func evaluateObjective(objective SLOObjective, target float64, threshold string, counts [3]sloCounts) SLOAttainment {
+	window, sixHours, hour := counts[0], counts[1], counts[2]
+	attainment := SLOAttainment{
+		Objective:               objective,
+		Target:                  target,
+		Threshold:               threshold,
+		Attempts:                window.attempts,
+		Bad:                     window.bad(objective),
+		AttainmentPct:           100,
+		ErrorBudgetRemainingPct: 100,
+		BurnRate1h:              burnRate(hour.bad(objective), hour.attempts, target),
+		BurnRate6h:              burnRate(sixHours.bad(objective), sixHours.attempts, target),
+	}
+	if attainment.Attempts > 0 {
+		attainment.AttainmentPct = float64(attainment.Attempts-attainment.Bad) / float64(attainment.Attempts) * 100
+		budget := float64(attainment.Attempts) * (1 - target)
+		attainment.ErrorBudgetRemainingPct = (1 - float64(attainment.Bad)/budget) * 100
+	}
+
+	switch {
+	case attainment.BurnRate1h >= pageBurnRate:
+		attainment.Alert = SLOAlertPage
+	case attainment.BurnRate6h >= ticketBurnRate:
+		attainment.Alert = SLOAlertTicket
+	}
+	return attainment
+}
+
+// burnRate is how many times faster than sustainable the error budget is spent
+func burnRate(bad, attempts int64, target float64) float64 {
+	if attempts == 0 || target >= 1 {
+		return 0
+	}
+	return float64(bad) / float64(attempts) / (1 - target)
+}
+
+// Run checks every org's step SLOs on an interval and alerts on fast burn
+func (st *SLOTracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := st.Check(ctx); err != nil {
+				log.Printf("Failed to check step SLOs: %v", err)
+			}
+		}
+	}
+}
+
+// Check evaluates all step SLOs once and sends alerts for those burning too fast
+func (st *SLOTracker) Check(ctx context.Context) error {
+	statuses, err := st.Status(ctx, uuid.Nil, "")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, status := range statuses {
+		for _, objective := range status.Objectives {
+			if objective.Alert == "" || !st.shouldAlert(status, objective, now) {
+				continue
+			}
+			st.notify(ctx, sloBurnAlert(status, objective, now))
+		}
+	}
+	return nil
+}
+
+// shouldAlert rate-limits alerts to one per step objective and severity per cooldown
+func (st *SLOTracker) shouldAlert(status StepSLOStatus, objective SLOAttainment, now time.Time) bool {
+	key := fmt.Sprintf("%s/%s/%s/%s/%s", status.OrgID, status.WorkflowName, status.StepID, objective.Objective, objective.Alert)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if last, ok := st.lastAlert[key]; ok && now.Sub(last) < sloAlertCooldown {
+		return false
+	}
+	st.lastAlert[key] = now
+	return true
+}
+
+func sloBurnAlert(status StepSLOStatus, objective SLOAttainment, now time.Time) SLOBurnAlert {
+	rate, window := objective.BurnRate1h, "hour"
+	if objective.Alert == SLOAlertTicket {
+		rate, window = objective.BurnRate6h, "6 hours"
+	}
+	return SLOBurnAlert{
+		OrgID:        status.OrgID,
+		WorkflowName: status.WorkflowName,
+		StepID:       status.StepID,
+		Objective:    objective.Objective,
+		Severity:     objective.Alert,
+		BurnRate:     rate,
+		Message: fmt.Sprintf("Step %s of %s is burning its %s error budget %.1fx too fast over the last %s (%.1f%% of budget left)",
+			status.StepID, status.WorkflowName, objective.Objective, rate, window, objective.ErrorBudgetRemainingPct),
+		Timestamp: now,
+	}
+}
+
+// notify sends a burn alert to the configured webhook and Slack channel
+func (st *SLOTracker) notify(ctx context.Context, alert SLOBurnAlert) {
+	log.Printf("SLO alert: %s", alert.Message)
+	if st.alerts.WebhookURL != "" {
+		if err := st.post(ctx, st.alerts.WebhookURL, alert); err != nil {
+			log.Printf("Failed to send SLO alert for step %s: %v", alert.StepID, err)
+		}
+	}
+	if st.alerts.SlackWebhookURL != "" {
+		icon := ":warning:"
+		if alert.Severity == SLOAlertPage {
+			icon = ":rotating_light:"
+		}
+		if err := st.post(ctx, st.alerts.SlackWebhookURL, map[string]string{"text": icon + " " + alert.Message}); err != nil {
+			log.Printf("Failed to send SLO alert for step %s: %v", alert.StepID, err)
+		}
+	}
+}
+
+func (st *SLOTracker) post(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := st.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package aos
+
+import (
+	"sort"
+	"time"
+)
+
+// OrderTimeline sorts a run's events into the order they happened and returns
+// each event's effective time. Workers' clocks can disagree, so events carrying
+// a control plane sequence are ordered by it, and their effective time is
+// never earlier than that of the event sequenced before them. Unsequenced
+// events, written before sequencing existed, are merged in by timestamp.
+func OrderTimeline(events []TraceEvent) []time.Time {
+	sequenced := make([]TraceEvent, 0, len(events))
+	unsequenced := make([]TraceEvent, 0)
+	for _, event := range events {
+		if event.Sequence > 0 {
+			sequenced = append(sequenced, event)
+		} else {
+			unsequenced = append(unsequenced, event)
+		}
+	}
+	sort.SliceStable(sequenced, func(i, j int) bool {
+		if sequenced[i].Sequence != sequenced[j].Sequence {
+			return sequenced[i].Sequence < sequenced[j].Sequence
+		}
+		return sequenced[i].Timestamp.Before(sequenced[j].Timestamp)
+	})
+	sort.SliceStable(unsequenced, func(i, j int) bool {
+		return unsequenced[i].Timestamp.Before(unsequenced[j].Timestamp)
+	})
+
+	effective := make([]time.Time, len(sequenced))
+	for i, event := range sequenced {
+		effective[i] = event.Timestamp
+		if i > 0 && effective[i].Before(effective[i-1]) {
+			effective[i] = effective[i-1]
+		}
+	}
+
+	times := make([]time.Time, 0, len(events))
+	i, j := 0, 0
+	for k := range events {
+		if j >= len(unsequenced) || (i < len(sequenced) && !unsequenced[j].Timestamp.Before(effective[i])) {
+			events[k] = sequenced[i]
+			times = append(times, effective[i])
+			i++
+			continue
+		}
+		events[k] = unsequenced[j]
+		times = append(times, unsequenced[j].Timestamp)
+		j++
+	}
+	return times
+}
+
+// timelineDurations measures the run and each step on an ordered timeline.
+// A step runs from its first started event to its last completed, error or
+// canceled event; steps that never finished are left out.
+func timelineDurations(events []TraceEvent, times []time.Time) (time.Duration, map[string]time.Duration) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	starts := make(map[string]time.Time)
+	ends := make(map[string]time.Time)
+	for i, event := range events {
+		step := event.StepID.String()
+		switch event.EventType {
+		case EventTypeStarted:
+			if _, ok := starts[step]; !ok {
+				starts[step] = times[i]
+			}
+		case EventTypeCompleted, EventTypeError, EventTypeCanceled:
+			ends[step] = times[i]
+		}
+	}
+
+	steps := make(map[string]time.Duration)
+	for step, start := range starts {
+		if end, ok := ends[step]; ok && !end.Before(start) {
+			steps[step] = end.Sub(start)
+		}
+	}
+
+	return times[len(times)-1].Sub(times[0]), steps
+}
@@ -3,6 +3,7 @@ package aos
 import (
 	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
 	"github.com/google/uuid"
 )
 
@@ -12,6 +13,7 @@ type TraceEvent struct {
 	RunID            uuid.UUID              `json:"run_id" ch:"run_id"`
 	StepID           uuid.UUID              `json:"step_id" ch:"step_id"`
 	Timestamp        time.Time              `json:"timestamp" ch:"ts"`
+	Sequence         uint64                 `json:"sequence,omitempty" ch:"seq"` // Per-run order issued by the control plane; 0 if unsequenced
 	EventType        string                 `json:"event_type" ch:"event_type"`
 	Payload          map[string]interface{} `json:"payload" ch:"payload"`
 	CostCents        int64                  `json:"cost_cents" ch:"cost_cents"`
@@ -21,6 +23,7 @@ type TraceEvent struct {
 	Model            string                 `json:"model" ch:"model"`
 	QualityTier      string                 `json:"quality_tier" ch:"quality_tier"`
 	LatencyMs        int32                  `json:"latency_ms" ch:"latency_ms"`
+	Labels           map[string]string      `json:"labels,omitempty" ch:"labels"`
 }
 
 // EventType constants
@@ -34,6 +37,8 @@ const (
 	EventTypeError     = "error"
 	EventTypeCanceled  = "canceled"
 	EventTypeHeartbeat = "heartbeat"
+
+	EventTypeUsageBackfill = "usage_backfill"
 )
 
 // TraceQuery represents a query for trace data
@@ -46,6 +51,8 @@ type TraceQuery struct {
 	EventType *string    `json:"event_type,omitempty"`
 	Provider  *string    `json:"provider,omitempty"`
 	Model     *string    `json:"model,omitempty"`
+	Filter    string     `json:"filter,omitempty"` // Filter expression, e.g. event_type=error,cost_cents>=10
+	Cursor    string     `json:"cursor,omitempty"` // Takes precedence over Offset
 	Limit     int        `json:"limit,omitempty"`
 	Offset    int        `json:"offset,omitempty"`
 }
@@ -55,6 +62,7 @@ type TraceResponse struct {
 	Events     []TraceEvent `json:"events"`
 	TotalCount int64        `json:"total_count"`
 	Summary    TraceSummary `json:"summary"`
+	db.PageMeta
 }
 
 // TraceSummary provides aggregate information about a trace
@@ -67,6 +75,11 @@ type TraceSummary struct {
 	ErrorCount        int64            `json:"error_count"`
 	ProviderBreakdown map[string]int64 `json:"provider_breakdown"`
 	ModelBreakdown    map[string]int64 `json:"model_breakdown"`
+
+	// Run traces only: wall time from the first to the last event and per
+	// step from start to finish, measured on the reconstructed timeline
+	Duration      time.Duration            `json:"duration,omitempty"`
+	StepDurations map[string]time.Duration `json:"step_durations,omitempty"`
 }
 
 // ReplayRequest represents a request to replay a workflow run
@@ -140,7 +153,7 @@ type CostAnalysisRequest struct {
 	OrgID     uuid.UUID              `json:"org_id"`
 	StartTime time.Time              `json:"start_time"`
 	EndTime   time.Time              `json:"end_time"`
-	GroupBy   []string               `json:"group_by"` // workflow, prompt_version, provider, model, quality_tier
+	GroupBy   []string               `json:"group_by"` // provider, model, quality_tier, event_type, label:<key>
 	Filters   map[string]interface{} `json:"filters,omitempty"`
 }
 
@@ -241,3 +254,26 @@ type MetricDataPoint struct {
 	Timestamp time.Time `json:"timestamp"`
 	Value     float64   `json:"value"`
 }
+
+// RefusalRate summarizes refused, filtered and truncated model calls for one prompt version
+type RefusalRate struct {
+	PromptRef      string  `json:"prompt_ref"`
+	PromptVersion  int64   `json:"prompt_version"`
+	Calls          int64   `json:"calls"`
+	SafetyRefusals int64   `json:"safety_refusals"`
+	PolicyBlocks   int64   `json:"policy_blocks"`
+	LengthStops    int64   `json:"length_stops"`
+	RefusalPct     float64 `json:"refusal_pct"`
+}
+
+// SLACompliance summarizes SLA outcomes for a workflow over a time range
+type SLACompliance struct {
+	WorkflowName   string  `json:"workflow_name"`
+	Runs           int64   `json:"runs"`
+	Met            int64   `json:"met"`
+	Breached       int64   `json:"breached"`
+	AtRisk         int64   `json:"at_risk"`
+	OnTrack        int64   `json:"on_track"`
+	CompliancePct  float64 `json:"compliance_pct"`
+	AvgDurationSec float64 `json:"avg_duration_sec"`
+}
@@ -0,0 +1,178 @@
+package aos
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// defaultUsageImportChunk is how many rows are ingested between trace writer
+// flushes, kept well inside the writer's buffer
+const defaultUsageImportChunk = 1000
+
+// usageEventSink receives backfilled usage events
+type usageEventSink interface {
+	IngestBatch(ctx context.Context, events []TraceEvent) error
+	Flush(ctx context.Context) error
+}
+
+// usageImportLedger remembers which rows of an export were imported, keyed
+// by file hash and row, and charges their spend to the org's budgets
+type usageImportLedger interface {
+	Imported(ctx context.Context, orgID uuid.UUID, fileHash string, rows []int64) (map[int64]bool, error)
+	Record(ctx context.Context, orgID uuid.UUID, fileHash string, records []UsageRecord) error
+}
+
+// UsageImporter backfills provider usage exports in chunks. Each chunk is
+// flushed to the trace store before its rows are recorded and charged, so an
+// import that fails part-way can be re-run and resumes where it stopped.
+type UsageImporter struct {
+	sink   usageEventSink
+	ledger usageImportLedger
+	chunk  int
+}
+
+func NewUsageImporter(sink usageEventSink, pg *db.PostgresDB, bufferSize int) *UsageImporter {
+	chunk := defaultUsageImportChunk
+	if bufferSize > 0 && bufferSize < chunk {
+		chunk = bufferSize
+	}
+	return &UsageImporter{sink: sink, ledger: &pgUsageImportLedger{db: pg}, chunk: chunk}
+}
+
+// Import parses an export and imports the rows no earlier import of the same
+// file imported. The result counts what this import added.
+func (i *UsageImporter) Import(ctx context.Context, orgID uuid.UUID, format UsageExportFormat, r io.Reader) (*ImportResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage export: %w", err)
+	}
+	records, result, err := ParseUsageExport(bytes.NewReader(data), format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse usage export: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	result.FileHash = hex.EncodeToString(sum[:])
+
+	for start := 0; start < len(records); start += i.chunk {
+		chunk := records[start:min(start+i.chunk, len(records))]
+		if err := i.importChunk(ctx, orgID, format, result, chunk); err != nil {
+			// Rows of later chunks were not imported
+			for _, record := range records[start:] {
+				result.exclude(record)
+			}
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// importChunk writes a chunk's new rows to the trace store, then records and
+// charges them
+func (i *UsageImporter) importChunk(ctx context.Context, orgID uuid.UUID, format UsageExportFormat, result *ImportResult, chunk []UsageRecord) error {
+	rows := make([]int64, len(chunk))
+	for j, record := range chunk {
+		rows[j] = int64(record.Row)
+	}
+	imported, err := i.ledger.Imported(ctx, orgID, result.FileHash, rows)
+	if err != nil {
+		return err
+	}
+
+	fresh := make([]UsageRecord, 0, len(chunk))
+	events := make([]TraceEvent, 0, len(chunk))
+	for _, record := range chunk {
+		if imported[int64(record.Row)] {
+			result.Duplicates++
+			result.exclude(record)
+			continue
+		}
+		event := record.ToTraceEvent(orgID, format)
+		event.Payload["import_key"] = fmt.Sprintf("%s:%d", result.FileHash, record.Row)
+		fresh = append(fresh, record)
+		events = append(events, event)
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	if err := i.sink.IngestBatch(ctx, events); err != nil {
+		return fmt.Errorf("failed to ingest usage events: %w", err)
+	}
+	if err := i.sink.Flush(ctx); err != nil {
+		return fmt.Errorf("failed to flush usage events: %w", err)
+	}
+	return i.ledger.Record(ctx, orgID, result.FileHash, fresh)
+}
+
+// exclude removes a record this import did not add from the result's totals
+func (r *ImportResult) exclude(record UsageRecord) {
+	r.Imported--
+	r.TotalCostCents -= record.CostCents
+	r.TotalTokens -= record.TokensPrompt + record.TokensCompletion
+}
+
+// pgUsageImportLedger keeps imported rows in Postgres
+type pgUsageImportLedger struct {
+	db *db.PostgresDB
+}
+
+func (l *pgUsageImportLedger) Imported(ctx context.Context, orgID uuid.UUID, fileHash string, rows []int64) (map[int64]bool, error) {
+	query := `SELECT row_id FROM usage_import_row WHERE org_id = $1 AND file_hash = $2 AND row_id = ANY($3)`
+	result, err := l.db.QueryContext(ctx, query, orgID, fileHash, pq.Array(rows))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query imported rows: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	imported := make(map[int64]bool)
+	for result.Next() {
+		var row int64
+		if err := result.Scan(&row); err != nil {
+			return nil, fmt.Errorf("failed to scan imported row: %w", err)
+		}
+		imported[row] = true
+	}
+	return imported, result.Err()
+}
+
+// Record marks rows imported and charges each new row's spend to the org
+// budget covering its period, in one transaction
+func (l *pgUsageImportLedger) Record(ctx context.Context, orgID uuid.UUID, fileHash string, records []UsageRecord) error {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	insert := `INSERT INTO usage_import_row (org_id, file_hash, row_id, cost_cents) VALUES ($1, $2, $3, $4)
+			   ON CONFLICT DO NOTHING`
+	charge := `UPDATE budget SET spent_cents = spent_cents + $1 
+			   WHERE org_id = $2 AND project_id IS NULL AND workflow_name IS NULL 
+			   AND period_start <= $3 AND period_end > $3`
+	for _, record := range records {
+		inserted, err := tx.ExecContext(ctx, insert, orgID, fileHash, record.Row, record.CostCents)
+		if err != nil {
+			return fmt.Errorf("failed to record imported row: %w", err)
+		}
+		if n, _ := inserted.RowsAffected(); n == 0 || record.CostCents == 0 {
+			continue // Imported concurrently, or nothing to charge
+		}
+		if _, err := tx.ExecContext(ctx, charge, record.CostCents, orgID, record.Timestamp); err != nil {
+			return fmt.Errorf("failed to apply backfilled spend to budget: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit usage import: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,481 @@
+package aos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// defaultViewQueryLimit and maxViewQueryLimit bound the rows a view query returns
+	defaultViewQueryLimit = 1000
+	maxViewQueryLimit     = 10000
+)
+
+var analyticsViewNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,47}$`)
+
+// viewDimensionColumns maps view dimensions to the trace_event expressions
+// they aggregate by; label:<key> dimensions select a cost label
+var viewDimensionColumns = map[string]string{
+	"day":          "toDate(ts)",
+	"hour":         "toStartOfHour(ts)",
+	"provider":     "provider",
+	"model":        "model",
+	"quality_tier": "quality_tier",
+	"event_type":   "event_type",
+	"prompt_ref":   "JSONExtractString(payload, 'prompt_ref')",
+}
+
+// viewMeasureColumns maps view measures to their aggregates. Every measure is
+// a sum or count, so rows merge correctly in a SummingMergeTree.
+var viewMeasureColumns = map[string]string{
+	"events":            "count()",
+	"errors":            "countIf(event_type = 'error')",
+	"cost_cents":        "sum(cost_cents)",
+	"tokens_prompt":     "sum(tokens_prompt)",
+	"tokens_completion": "sum(tokens_completion)",
+	"latency_ms":        "sum(latency_ms)",
+}
+
+// AnalyticsView is an admin-defined rollup of an org's trace events,
+// maintained by a ClickHouse materialized view so dashboards query
+// pre-aggregated rows instead of scanning trace_event
+type AnalyticsView struct {
+	ID          uuid.UUID `json:"id"`
+	OrgID       uuid.UUID `json:"org_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Dimensions  []string  `json:"dimensions"` // day, hour, provider, model, quality_tier, event_type, prompt_ref, label:<key>
+	Measures    []string  `json:"measures"`   // events, errors, cost_cents, tokens_prompt, tokens_completion, latency_ms
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateAnalyticsViewRequest defines a view. Backfill aggregates events
+// stored before the view was created; events ingested late with earlier
+// timestamps may then be counted twice.
+type CreateAnalyticsViewRequest struct {
+	OrgID       uuid.UUID `json:"org_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Dimensions  []string  `json:"dimensions"`
+	Measures    []string  `json:"measures"`
+	Backfill    bool      `json:"backfill"`
+}
+
+// AnalyticsViewQuery binds a view's parameters. Params filter dimensions by
+// exact value; From and To bound the day or hour dimension.
+type AnalyticsViewQuery struct {
+	OrgID  uuid.UUID         `json:"org_id"`
+	Name   string            `json:"name"`
+	Params map[string]string `json:"params,omitempty"`
+	From   *time.Time        `json:"from,omitempty"`
+	To     *time.Time        `json:"to,omitempty"`
+	Limit  int               `json:"limit,omitempty"`
+}
+
+// AnalyticsViewRow is one aggregated row of a view. Error rate and average
+// latency are derived when the view measures events with errors or latency.
+type AnalyticsViewRow struct {
+	Dimensions   map[string]string `json:"dimensions"`
+	Measures     map[string]int64  `json:"measures"`
+	ErrorRate    *float64          `json:"error_rate,omitempty"`
+	AvgLatencyMs *float64          `json:"avg_latency_ms,omitempty"`
+}
+
+// AnalyticsViewResult is the response of a view query
+type AnalyticsViewResult struct {
+	View AnalyticsView      `json:"view"`
+	Rows []AnalyticsViewRow `json:"rows"`
+}
+
+// Validate checks the view's name, dimensions and measures
+func (r *CreateAnalyticsViewRequest) Validate() error {
+	if !analyticsViewNamePattern.MatchString(r.Name) {
+		return fmt.Errorf("invalid view name %q: use lowercase letters, digits and underscores", r.Name)
+	}
+	if len(r.Dimensions) == 0 {
+		return fmt.Errorf("a view needs at least one dimension")
+	}
+	if _, err := viewDimensionExprs(r.Dimensions); err != nil {
+		return err
+	}
+	if len(r.Measures) == 0 {
+		return fmt.Errorf("a view needs at least one measure")
+	}
+	seen := make(map[string]bool, len(r.Measures))
+	for _, measure := range r.Measures {
+		if _, ok := viewMeasureColumns[measure]; !ok {
+			return fmt.Errorf("unsupported measure %q: use events, errors, cost_cents, tokens_prompt, tokens_completion or latency_ms", measure)
+		}
+		if seen[measure] {
+			return fmt.Errorf("duplicate measure %q", measure)
+		}
+		seen[measure] = true
+	}
+	return nil
+}
+
+// viewDimensionExprs resolves view dimensions to SQL expressions
+func viewDimensionExprs(dimensions []string) ([]string, error) {
+	exprs := make([]string, 0, len(dimensions))
+	seen := make(map[string]bool, len(dimensions))
+	for _, dimension := range dimensions {
+		if seen[viewColumn(dimension)] {
+			return nil, fmt.Errorf("duplicate dimension %q", dimension)
+		}
+		seen[viewColumn(dimension)] = true
+
+		if key, ok := strings.CutPrefix(dimension, labelGroupPrefix); ok {
+			if !labelKeyPattern.MatchString(key) {
+				return nil, fmt.Errorf("invalid cost label %q", key)
+			}
+			exprs = append(exprs, fmt.Sprintf("labels['%s']", key))
+			continue
+		}
+		expr, ok := viewDimensionColumns[dimension]
+		if !ok {
+			return nil, fmt.Errorf("unsupported dimension %q: use day, hour, provider, model, quality_tier, event_type, prompt_ref or label:<key>", dimension)
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+// viewColumn is the target table column holding a dimension, e.g. label_customer
+func viewColumn(dimension string) string {
+	if key, ok := strings.CutPrefix(dimension, labelGroupPrefix); ok {
+		return "label_" + strings.NewReplacer(".", "_", "-", "_").Replace(key)
+	}
+	return dimension
+}
+
+// viewTable is the ClickHouse table a view's rollup is stored in. The org is
+// part of the name so orgs can reuse view names.
+func viewTable(orgID uuid.UUID, name string) string {
+	return "analytics_view_" + strings.ReplaceAll(orgID.String(), "-", "") + "_" + name
+}
+
+// viewTimeColumn returns the view's day or hour dimension, if any
+func viewTimeColumn(view *AnalyticsView) string {
+	for _, dimension := range view.Dimensions {
+		if dimension == "day" || dimension == "hour" {
+			return dimension
+		}
+	}
+	return ""
+}
+
+// viewAggregation returns the SELECT list that rolls trace events up into a
+// view's columns and the columns it groups by
+func viewAggregation(view *AnalyticsView) (string, string, error) {
+	exprs, err := viewDimensionExprs(view.Dimensions)
+	if err != nil {
+		return "", "", err
+	}
+	keys := make([]string, 0, len(view.Dimensions))
+	selects := make([]string, 0, len(view.Dimensions)+len(view.Measures))
+	for i, dimension := range view.Dimensions {
+		keys = append(keys, viewColumn(dimension))
+		selects = append(selects, fmt.Sprintf("%s AS %s", exprs[i], viewColumn(dimension)))
+	}
+	for _, measure := range view.Measures {
+		selects = append(selects, fmt.Sprintf("toInt64(%s) AS %s", viewMeasureColumns[measure], measure))
+	}
+	return strings.Join(selects, ", "), strings.Join(keys, ", "), nil
+}
+
+// AnalyticsViewDDL returns the statements creating a view's target table and
+// the materialized view feeding it from trace_event
+func AnalyticsViewDDL(view *AnalyticsView) ([]string, error) {
+	selects, keys, err := viewAggregation(view)
+	if err != nil {
+		return nil, err
+	}
+	table := viewTable(view.OrgID, view.Name)
+
+	columns := make([]string, 0, len(view.Dimensions)+len(view.Measures))
+	for _, dimension := range view.Dimensions {
+		columnType := "LowCardinality(String)"
+		switch dimension {
+		case "day":
+			columnType = "Date"
+		case "hour":
+			columnType = "DateTime"
+		}
+		columns = append(columns, fmt.Sprintf("%s %s", viewColumn(dimension), columnType))
+	}
+	for _, measure := range view.Measures {
+		columns = append(columns, measure+" Int64")
+	}
+
+	return []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = SummingMergeTree() ORDER BY (%s)",
+			table, strings.Join(columns, ", "), keys),
+		fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s_mv TO %s AS SELECT %s FROM trace_event WHERE org_id = '%s' GROUP BY %s",
+			table, table, selects, view.OrgID, keys),
+	}, nil
+}
+
+// AnalyticsViewStore manages view definitions in Postgres and their
+// materialized views in ClickHouse
+type AnalyticsViewStore struct {
+	clickhouse *db.ClickHouseDB
+	postgres   *db.PostgresDB
+}
+
+func NewAnalyticsViewStore(ch *db.ClickHouseDB, pg *db.PostgresDB) *AnalyticsViewStore {
+	return &AnalyticsViewStore{clickhouse: ch, postgres: pg}
+}
+
+// Create records a view and creates its materialized view, optionally
+// aggregating the events already stored
+func (vs *AnalyticsViewStore) Create(ctx context.Context, req *CreateAnalyticsViewRequest) (*AnalyticsView, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	view := &AnalyticsView{
+		ID:          uuid.New(),
+		OrgID:       req.OrgID,
+		Name:        req.Name,
+		Description: req.Description,
+		Dimensions:  req.Dimensions,
+		Measures:    req.Measures,
+		CreatedAt:   time.Now(),
+	}
+	statements, err := AnalyticsViewDDL(view)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO analytics_view (id, org_id, name, description, dimensions, measures, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = vs.postgres.ExecContext(ctx, query, view.ID, view.OrgID, view.Name, view.Description,
+		pq.Array(view.Dimensions), pq.Array(view.Measures), view.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, fmt.Errorf("analytics view %s already exists", view.Name)
+		}
+		return nil, fmt.Errorf("failed to create analytics view: %w", err)
+	}
+
+	for _, statement := range statements {
+		if err := vs.clickhouse.Exec(ctx, statement); err != nil {
+			_ = vs.drop(ctx, view) // Don't leave a definition without its rollup
+			return nil, fmt.Errorf("failed to create materialized view: %w", err)
+		}
+	}
+
+	if req.Backfill {
+		if err := vs.backfill(ctx, view); err != nil {
+			return nil, err
+		}
+	}
+	return view, nil
+}
+
+// backfill aggregates the events stored before the materialized view existed
+func (vs *AnalyticsViewStore) backfill(ctx context.Context, view *AnalyticsView) error {
+	selects, keys, err := viewAggregation(view)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s SELECT %s FROM trace_event WHERE org_id = ? AND ts < ? GROUP BY %s",
+		viewTable(view.OrgID, view.Name), selects, keys)
+	if err := vs.clickhouse.Exec(ctx, query, view.OrgID, view.CreatedAt); err != nil {
+		return fmt.Errorf("failed to backfill analytics view: %w", err)
+	}
+	return nil
+}
+
+// Get returns an org's view by name
+func (vs *AnalyticsViewStore) Get(ctx context.Context, orgID uuid.UUID, name string) (*AnalyticsView, error) {
+	views, err := vs.query(ctx, `WHERE org_id = $1 AND name = $2`, orgID, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(views) == 0 {
+		return nil, fmt.Errorf("analytics view not found: %s", name)
+	}
+	return &views[0], nil
+}
+
+// List returns an org's views by name
+func (vs *AnalyticsViewStore) List(ctx context.Context, orgID uuid.UUID) ([]AnalyticsView, error) {
+	return vs.query(ctx, `WHERE org_id = $1 ORDER BY name`, orgID)
+}
+
+// Drop removes a view with its materialized view and rollup table
+func (vs *AnalyticsViewStore) Drop(ctx context.Context, orgID uuid.UUID, name string) error {
+	view, err := vs.Get(ctx, orgID, name)
+	if err != nil {
+		return err
+	}
+	return vs.drop(ctx, view)
+}
+
+func (vs *AnalyticsViewStore) drop(ctx context.Context, view *AnalyticsView) error {
+	table := viewTable(view.OrgID, view.Name)
+	for _, statement := range []string{"DROP VIEW IF EXISTS " + table + "_mv", "DROP TABLE IF EXISTS " + table} {
+		if err := vs.clickhouse.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("failed to drop materialized view: %w", err)
+		}
+	}
+	if _, err := vs.postgres.ExecContext(ctx, `DELETE FROM analytics_view WHERE id = $1`, view.ID); err != nil {
+		return fmt.Errorf("failed to delete analytics view: %w", err)
+	}
+	return nil
+}
+
+// Query reads a view's rollup with the caller's parameters bound as query
+// arguments, so clients never supply SQL
+func (vs *AnalyticsViewStore) Query(ctx context.Context, req *AnalyticsViewQuery) (*AnalyticsViewResult, error) {
+	view, err := vs.Get(ctx, req.OrgID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := buildViewQuery(view, req)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := vs.clickhouse.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query analytics view: %w", err)
+	}
+	defer rows.Close()
+
+	result := &AnalyticsViewResult{View: *view, Rows: make([]AnalyticsViewRow, 0)}
+	for rows.Next() {
+		dimensions := make([]string, len(view.Dimensions))
+		measures := make([]int64, len(view.Measures))
+		dest := make([]interface{}, 0, len(dimensions)+len(measures))
+		for i := range dimensions {
+			dest = append(dest, &dimensions[i])
+		}
+		for i := range measures {
+			dest = append(dest, &measures[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan analytics view row: %w", err)
+		}
+		result.Rows = append(result.Rows, newViewRow(view, dimensions, measures))
+	}
+	return result, rows.Err()
+}
+
+// buildViewQuery aggregates a view's rows, filtering on bound parameters
+func buildViewQuery(view *AnalyticsView, req *AnalyticsViewQuery) (string, []interface{}, error) {
+	dimensions := make(map[string]bool, len(view.Dimensions))
+	selects := make([]string, 0, len(view.Dimensions)+len(view.Measures))
+	keys := make([]string, 0, len(view.Dimensions))
+	for _, dimension := range view.Dimensions {
+		dimensions[dimension] = true
+		selects = append(selects, fmt.Sprintf("toString(%s)", viewColumn(dimension)))
+		keys = append(keys, viewColumn(dimension))
+	}
+	for _, measure := range view.Measures {
+		selects = append(selects, fmt.Sprintf("toInt64(sum(%s))", measure))
+	}
+
+	conditions := make([]string, 0, len(req.Params)+2)
+	args := make([]interface{}, 0, len(req.Params)+2)
+	for _, dimension := range view.Dimensions {
+		value, ok := req.Params[dimension]
+		if !ok {
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("toString(%s) = ?", viewColumn(dimension)))
+		args = append(args, value)
+	}
+	for param := range req.Params {
+		if !dimensions[param] {
+			return "", nil, fmt.Errorf("view %s has no dimension %q", view.Name, param)
+		}
+	}
+
+	if req.From != nil || req.To != nil {
+		column := viewTimeColumn(view)
+		if column == "" {
+			return "", nil, fmt.Errorf("view %s has no day or hour dimension to filter by time", view.Name)
+		}
+		if req.From != nil {
+			conditions = append(conditions, column+" >= ?")
+			args = append(args, *req.From)
+		}
+		if req.To != nil {
+			conditions = append(conditions, column+" < ?")
+			args = append(args, *req.To)
+		}
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultViewQueryLimit
+	}
+	if limit > maxViewQueryLimit {
+		limit = maxViewQueryLimit
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), viewTable(view.OrgID, view.Name))
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" GROUP BY %s ORDER BY %s LIMIT %d", strings.Join(keys, ", "), strings.Join(keys, ", "), limit)
+	return query, args, nil
+}
+
+func newViewRow(view *AnalyticsView, dimensions []string, measures []int64) AnalyticsViewRow {
+	row := AnalyticsViewRow{
+		Dimensions: make(map[string]string, len(dimensions)),
+		Measures:   make(map[string]int64, len(measures)),
+	}
+	for i, dimension := range view.Dimensions {
+		row.Dimensions[dimension] = dimensions[i]
+	}
+	for i, measure := range view.Measures {
+		row.Measures[measure] = measures[i]
+	}
+
+	events, ok := row.Measures["events"]
+	if !ok || events == 0 {
+		return row
+	}
+	if errs, ok := row.Measures["errors"]; ok {
+		rate := float64(errs) / float64(events)
+		row.ErrorRate = &rate
+	}
+	if latency, ok := row.Measures["latency_ms"]; ok {
+		avg := float64(latency) / float64(events)
+		row.AvgLatencyMs = &avg
+	}
+	return row
+}
+
+func (vs *AnalyticsViewStore) query(ctx context.Context, where string, args ...interface{}) ([]AnalyticsView, error) {
+	rows, err := vs.postgres.QueryContext(ctx,
+		`SELECT id, org_id, name, description, dimensions, measures, created_at FROM analytics_view `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query analytics views: %w", err)
+	}
+	defer rows.Close()
+
+	views := make([]AnalyticsView, 0)
+	for rows.Next() {
+		var view AnalyticsView
+		if err := rows.Scan(&view.ID, &view.OrgID, &view.Name, &view.Description,
+			pq.Array(&view.Dimensions), pq.Array(&view.Measures), &view.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan analytics view: %w", err)
+		}
+		views = append(views, view)
+	}
+	return views, rows.Err()
+}
@@ -5,11 +5,14 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"math"
+	"sync"
 	"time"
 )
 
-// MultiArmedBandit implements Upper Confidence Bound (UCB) algorithm for provider selection
+// MultiArmedBandit implements Upper Confidence Bound (UCB) algorithm for provider selection.
+// It is safe for concurrent use.
 type MultiArmedBandit struct {
+	mu   sync.Mutex
 	arms map[string]*BanditArm
 	c    float64 // Exploration parameter
 }
@@ -39,6 +42,9 @@ func (mab *MultiArmedBandit) SelectProvider(ctx context.Context, scoredProviders
 		return scoredProviders[0]
 	}
 
+	mab.mu.Lock()
+	defer mab.mu.Unlock()
+
 	totalPulls := mab.getTotalPulls()
 
 	// If we haven't tried all arms yet, try untried ones first
@@ -86,6 +92,10 @@ func (mab *MultiArmedBandit) SelectProvider(ctx context.Context, scoredProviders
 // UpdateReward updates the reward for a provider based on performance
 func (mab *MultiArmedBandit) UpdateReward(providerName, modelName string, reward float64) {
 	key := providerName + ":" + modelName
+
+	mab.mu.Lock()
+	defer mab.mu.Unlock()
+
 	arm, exists := mab.arms[key]
 	if !exists {
 		mab.initializeArm(key)
@@ -140,8 +150,20 @@ func (mab *MultiArmedBandit) CalculateReward(actualCost, estimatedCost int64, ac
 	return reward
 }
 
+// FeedbackReward converts a human rating of a call's output into a reward.
+// A rejected output is penalized like a failed call.
+func (mab *MultiArmedBandit) FeedbackReward(approved bool) float64 {
+	if approved {
+		return 1.0
+	}
+	return -1.0
+}
+
 // GetArmStats returns statistics for all arms
 func (mab *MultiArmedBandit) GetArmStats() map[string]BanditArm {
+	mab.mu.Lock()
+	defer mab.mu.Unlock()
+
 	stats := make(map[string]BanditArm)
 	for key, arm := range mab.arms {
 		stats[key] = *arm
@@ -152,6 +174,10 @@ func (mab *MultiArmedBandit) GetArmStats() map[string]BanditArm {
 // ResetArm resets statistics for a specific provider
 func (mab *MultiArmedBandit) ResetArm(providerName, modelName string) {
 	key := providerName + ":" + modelName
+
+	mab.mu.Lock()
+	defer mab.mu.Unlock()
+
 	if arm, exists := mab.arms[key]; exists {
 		arm.Pulls = 0
 		arm.TotalReward = 0
@@ -162,6 +188,9 @@ func (mab *MultiArmedBandit) ResetArm(providerName, modelName string) {
 
 // DecayRewards applies time-based decay to rewards to adapt to changing conditions
 func (mab *MultiArmedBandit) DecayRewards(decayFactor float64) {
+	mab.mu.Lock()
+	defer mab.mu.Unlock()
+
 	for _, arm := range mab.arms {
 		arm.TotalReward *= decayFactor
 		if arm.Pulls > 0 {
@@ -196,6 +225,7 @@ func (mab *MultiArmedBandit) getTotalPulls() int {
 
 // EpsilonGreedy implements epsilon-greedy algorithm as an alternative to UCB
 type EpsilonGreedy struct {
+	mu      sync.Mutex
 	arms    map[string]*BanditArm
 	epsilon float64 // Exploration probability
 }
@@ -222,6 +252,9 @@ func (eg *EpsilonGreedy) SelectProvider(ctx context.Context, scoredProviders []S
 		return scoredProviders[secureRandInt(len(scoredProviders))]
 	}
 
+	eg.mu.Lock()
+	defer eg.mu.Unlock()
+
 	// Exploit: select best performing provider
 	bestProvider := scoredProviders[0]
 	bestReward := -math.Inf(1)
@@ -256,6 +289,10 @@ func (eg *EpsilonGreedy) SelectProvider(ctx context.Context, scoredProviders []S
 
 func (eg *EpsilonGreedy) UpdateReward(providerName, modelName string, reward float64) {
 	key := providerName + ":" + modelName
+
+	eg.mu.Lock()
+	defer eg.mu.Unlock()
+
 	arm, exists := eg.arms[key]
 	if !exists {
 		eg.arms[key] = &BanditArm{
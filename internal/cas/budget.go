@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
+	"strings"
 	"time"
 
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
@@ -22,12 +23,12 @@ func NewBudgetManager(pg *db.PostgresDB) *BudgetManager {
 
 // CreateBudget creates a new budget
 func (bm *BudgetManager) CreateBudget(ctx context.Context, budget *Budget) (*Budget, error) {
-	query := `INSERT INTO budget (id, org_id, project_id, period_type, limit_cents, spent_cents, period_start, period_end, created_at)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	query := `INSERT INTO budget (id, org_id, project_id, workflow_name, tag, label, period_type, limit_cents, spent_cents, period_start, period_end, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 			  RETURNING id`
 
 	err := bm.postgres.QueryRowContext(ctx, query,
-		budget.ID, budget.OrgID, budget.ProjectID, budget.PeriodType,
+		budget.ID, budget.OrgID, budget.ProjectID, budget.WorkflowName, budget.Tag, budget.Label, budget.PeriodType,
 		budget.LimitCents, budget.SpentCents, budget.PeriodStart, budget.PeriodEnd, budget.CreatedAt,
 	).Scan(&budget.ID)
 
@@ -40,12 +41,12 @@ func (bm *BudgetManager) CreateBudget(ctx context.Context, budget *Budget) (*Bud
 
 // GetBudget retrieves a budget by ID
 func (bm *BudgetManager) GetBudget(ctx context.Context, budgetID uuid.UUID) (*Budget, error) {
-	query := `SELECT id, org_id, project_id, period_type, limit_cents, spent_cents, period_start, period_end, created_at
+	query := `SELECT id, org_id, project_id, workflow_name, tag, label, period_type, limit_cents, spent_cents, period_start, period_end, created_at
 			  FROM budget WHERE id = $1`
 
 	var budget Budget
 	err := bm.postgres.QueryRowContext(ctx, query, budgetID).Scan(
-		&budget.ID, &budget.OrgID, &budget.ProjectID, &budget.PeriodType,
+		&budget.ID, &budget.OrgID, &budget.ProjectID, &budget.WorkflowName, &budget.Tag, &budget.Label, &budget.PeriodType,
 		&budget.LimitCents, &budget.SpentCents, &budget.PeriodStart, &budget.PeriodEnd, &budget.CreatedAt,
 	)
 
@@ -62,22 +63,22 @@ func (bm *BudgetManager) GetCurrentBudget(ctx context.Context, orgID uuid.UUID,
 	var args []interface{}
 
 	if projectID != nil {
-		query = `SELECT id, org_id, project_id, period_type, limit_cents, spent_cents, period_start, period_end, created_at
+		query = `SELECT id, org_id, project_id, workflow_name, tag, label, period_type, limit_cents, spent_cents, period_start, period_end, created_at
 				 FROM budget 
-				 WHERE org_id = $1 AND project_id = $2 AND period_start <= NOW() AND period_end > NOW()
+				 WHERE org_id = $1 AND project_id = $2 AND workflow_name IS NULL AND label IS NULL AND period_start <= NOW() AND period_end > NOW()
 				 ORDER BY created_at DESC LIMIT 1`
 		args = []interface{}{orgID, *projectID}
 	} else {
-		query = `SELECT id, org_id, project_id, period_type, limit_cents, spent_cents, period_start, period_end, created_at
+		query = `SELECT id, org_id, project_id, workflow_name, tag, label, period_type, limit_cents, spent_cents, period_start, period_end, created_at
 				 FROM budget 
-				 WHERE org_id = $1 AND project_id IS NULL AND period_start <= NOW() AND period_end > NOW()
+				 WHERE org_id = $1 AND project_id IS NULL AND workflow_name IS NULL AND label IS NULL AND period_start <= NOW() AND period_end > NOW()
 				 ORDER BY created_at DESC LIMIT 1`
 		args = []interface{}{orgID}
 	}
 
 	var budget Budget
 	err := bm.postgres.QueryRowContext(ctx, query, args...).Scan(
-		&budget.ID, &budget.OrgID, &budget.ProjectID, &budget.PeriodType,
+		&budget.ID, &budget.OrgID, &budget.ProjectID, &budget.WorkflowName, &budget.Tag, &budget.Label, &budget.PeriodType,
 		&budget.LimitCents, &budget.SpentCents, &budget.PeriodStart, &budget.PeriodEnd, &budget.CreatedAt,
 	)
 
@@ -99,37 +100,187 @@ func (bm *BudgetManager) CheckBudget(ctx context.Context, orgID uuid.UUID, reque
 		}
 	}
 
-	status := &BudgetStatus{
-		BudgetID:       budget.ID,
-		LimitCents:     budget.LimitCents,
-		SpentCents:     budget.SpentCents,
-		RemainingCents: budget.LimitCents - budget.SpentCents,
-		PeriodStart:    budget.PeriodStart,
-		PeriodEnd:      budget.PeriodEnd,
+	return computeBudgetStatus(budget, requestedAmount), nil
+}
+
+// GetWorkflowBudgets retrieves active budgets scoped to a workflow name
+func (bm *BudgetManager) GetWorkflowBudgets(ctx context.Context, orgID uuid.UUID, workflowName string) ([]Budget, error) {
+	query := `SELECT id, org_id, project_id, workflow_name, tag, label, period_type, limit_cents, spent_cents, period_start, period_end, created_at
+			  FROM budget 
+			  WHERE org_id = $1 AND workflow_name = $2 AND period_start <= NOW() AND period_end > NOW()
+			  ORDER BY created_at DESC`
+
+	rows, err := bm.postgres.QueryContext(ctx, query, orgID, workflowName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow budgets: %w", err)
 	}
+	defer func() { _ = rows.Close() }()
 
-	// Calculate utilization percentage
-	if budget.LimitCents > 0 {
-		status.UtilizationPct = float64(budget.SpentCents) / float64(budget.LimitCents) * 100
+	budgets := make([]Budget, 0)
+	for rows.Next() {
+		var budget Budget
+		err := rows.Scan(
+			&budget.ID, &budget.OrgID, &budget.ProjectID, &budget.WorkflowName, &budget.Tag, &budget.Label, &budget.PeriodType,
+			&budget.LimitCents, &budget.SpentCents, &budget.PeriodStart, &budget.PeriodEnd, &budget.CreatedAt,
+		)
+		if err != nil {
+			continue
+		}
+		budgets = append(budgets, budget)
 	}
 
-	// Determine status
-	if status.RemainingCents <= 0 {
-		status.Status = BudgetStatusExceeded
-	} else if status.UtilizationPct >= 90 {
-		status.Status = BudgetStatusCritical
-	} else if status.UtilizationPct >= 75 {
-		status.Status = BudgetStatusWarning
-	} else {
-		status.Status = BudgetStatusHealthy
+	return budgets, nil
+}
+
+// GetLabelBudgets retrieves active budgets scoped to a cost label
+func (bm *BudgetManager) GetLabelBudgets(ctx context.Context, orgID uuid.UUID) ([]Budget, error) {
+	query := `SELECT id, org_id, project_id, workflow_name, tag, label, period_type, limit_cents, spent_cents, period_start, period_end, created_at
+			  FROM budget 
+			  WHERE org_id = $1 AND label IS NOT NULL AND period_start <= NOW() AND period_end > NOW()
+			  ORDER BY created_at DESC`
+
+	rows, err := bm.postgres.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get label budgets: %w", err)
 	}
+	defer func() { _ = rows.Close() }()
 
-	// Check if requested amount would exceed budget
-	if requestedAmount > 0 && status.RemainingCents < requestedAmount {
-		status.Status = BudgetStatusExceeded
+	budgets := make([]Budget, 0)
+	for rows.Next() {
+		var budget Budget
+		err := rows.Scan(
+			&budget.ID, &budget.OrgID, &budget.ProjectID, &budget.WorkflowName, &budget.Tag, &budget.Label, &budget.PeriodType,
+			&budget.LimitCents, &budget.SpentCents, &budget.PeriodStart, &budget.PeriodEnd, &budget.CreatedAt,
+		)
+		if err != nil {
+			continue
+		}
+		budgets = append(budgets, budget)
+	}
+
+	return budgets, nil
+}
+
+// GetScopedBudgets collects every active budget applying to a run: org,
+// project, workflow (optionally narrowed by tag) and cost label
+func (bm *BudgetManager) GetScopedBudgets(ctx context.Context, scope *BudgetScopeRequest) ([]ScopedBudget, error) {
+	scoped := make([]ScopedBudget, 0)
+
+	if budget, err := bm.GetCurrentBudget(ctx, scope.OrgID, nil); err == nil {
+		scoped = append(scoped, ScopedBudget{Scope: BudgetScopeOrg, Budget: *budget})
+	}
+
+	if scope.ProjectID != nil {
+		if budget, err := bm.GetCurrentBudget(ctx, scope.OrgID, scope.ProjectID); err == nil {
+			scoped = append(scoped, ScopedBudget{Scope: BudgetScopeProject, Budget: *budget})
+		}
+	}
+
+	if scope.WorkflowName != "" {
+		budgets, err := bm.GetWorkflowBudgets(ctx, scope.OrgID, scope.WorkflowName)
+		if err != nil {
+			return nil, err
+		}
+		for _, budget := range budgets {
+			if budget.AppliesTo(scope) {
+				scoped = append(scoped, ScopedBudget{Scope: BudgetScopeWorkflow, Budget: budget})
+			}
+		}
+	}
+
+	if len(scope.Labels) > 0 {
+		budgets, err := bm.GetLabelBudgets(ctx, scope.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		for _, budget := range budgets {
+			if budget.MatchesLabels(scope.Labels) {
+				scoped = append(scoped, ScopedBudget{Scope: BudgetScopeLabel, Budget: budget})
+			}
+		}
+	}
+
+	return scoped, nil
+}
+
+// CheckScopedBudgets evaluates all budgets applying to a run; the most
+// restrictive one decides whether the run may proceed
+func (bm *BudgetManager) CheckScopedBudgets(ctx context.Context, scope *BudgetScopeRequest, requestedAmount int64) (*BudgetEnforcement, error) {
+	budgets, err := bm.GetScopedBudgets(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scoped budgets: %w", err)
+	}
+
+	return EvaluateScopedBudgets(budgets, requestedAmount), nil
+}
+
+// RecordScopedSpending records spending against every budget applying to a run
+func (bm *BudgetManager) RecordScopedSpending(ctx context.Context, scope *BudgetScopeRequest, amountCents int64) error {
+	budgets, err := bm.GetScopedBudgets(ctx, scope)
+	if err != nil {
+		return fmt.Errorf("failed to get scoped budgets: %w", err)
+	}
+
+	query := `UPDATE budget SET spent_cents = spent_cents + $1 WHERE id = $2`
+	for _, scoped := range budgets {
+		if _, err := bm.postgres.ExecContext(ctx, query, amountCents, scoped.Budget.ID); err != nil {
+			return fmt.Errorf("failed to record %s spending: %w", scoped.Scope, err)
+		}
+
+		newSpent := scoped.Budget.SpentCents + amountCents
+		if newSpent > scoped.Budget.LimitCents {
+			bm.sendBudgetAlert(ctx, &scoped.Budget, newSpent)
+		}
 	}
 
-	return status, nil
+	return nil
+}
+
+// EvaluateScopedBudgets computes per-scope status and picks the most
+// restrictive budget, i.e. the one with the least remaining headroom. A
+// refused run is throttled by the exceeded budget with the least headroom.
+func EvaluateScopedBudgets(budgets []ScopedBudget, requestedAmount int64) *BudgetEnforcement {
+	enforcement := &BudgetEnforcement{
+		Allowed:   true,
+		Breakdown: make([]ScopedBudgetStatus, 0, len(budgets)),
+	}
+
+	var throttledRemaining int64
+	for i := range budgets {
+		status := computeBudgetStatus(&budgets[i].Budget, requestedAmount)
+		scoped := ScopedBudgetStatus{Scope: budgets[i].Scope, BudgetStatus: *status}
+		enforcement.Breakdown = append(enforcement.Breakdown, scoped)
+
+		if enforcement.Effective == nil || status.RemainingCents < enforcement.Effective.RemainingCents {
+			effective := scoped
+			enforcement.Effective = &effective
+		}
+
+		if status.Status == BudgetStatusExceeded && (enforcement.Allowed || status.RemainingCents < throttledRemaining) {
+			enforcement.Allowed = false
+			enforcement.ThrottledBy = budgets[i].Scope
+			throttledRemaining = status.RemainingCents
+		}
+	}
+
+	return enforcement
+}
+
+// AppliesTo reports whether a workflow budget matches the run's tags
+func (b *Budget) AppliesTo(scope *BudgetScopeRequest) bool {
+	if b.WorkflowName != nil && *b.WorkflowName != scope.WorkflowName {
+		return false
+	}
+	if b.Tag == nil || *b.Tag == "" {
+		return true
+	}
+
+	key, value, found := strings.Cut(*b.Tag, "=")
+	if !found {
+		_, ok := scope.Tags[key]
+		return ok
+	}
+	return scope.Tags[key] == value
 }
 
 // GetStatus retrieves budget status for an organization
@@ -160,6 +311,26 @@ func (bm *BudgetManager) RecordSpending(ctx context.Context, orgID uuid.UUID, am
 	return nil
 }
 
+// RecordBudgetSpending records spending against one budget, such as a
+// maintenance budget that is not matched by scope
+func (bm *BudgetManager) RecordBudgetSpending(ctx context.Context, budgetID uuid.UUID, amountCents int64) error {
+	budget, err := bm.GetBudget(ctx, budgetID)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE budget SET spent_cents = spent_cents + $1 WHERE id = $2`
+	if _, err := bm.postgres.ExecContext(ctx, query, amountCents, budgetID); err != nil {
+		return fmt.Errorf("failed to record spending: %w", err)
+	}
+
+	newSpent := budget.SpentCents + amountCents
+	if newSpent > budget.LimitCents {
+		bm.sendBudgetAlert(ctx, budget, newSpent)
+	}
+	return nil
+}
+
 // UpdateBudget updates an existing budget
 func (bm *BudgetManager) UpdateBudget(ctx context.Context, budgetID uuid.UUID, limitCents int64) error {
 	query := `UPDATE budget SET limit_cents = $1 WHERE id = $2`
@@ -171,33 +342,79 @@ func (bm *BudgetManager) UpdateBudget(ctx context.Context, budgetID uuid.UUID, l
 	return nil
 }
 
-// ListBudgets lists all budgets for an organization
-func (bm *BudgetManager) ListBudgets(ctx context.Context, orgID uuid.UUID) ([]Budget, error) {
-	query := `SELECT id, org_id, project_id, period_type, limit_cents, spent_cents, period_start, period_end, created_at
+// budgetFilterFields are the fields a budget filter expression can reference
+var budgetFilterFields = map[string]db.FilterField{
+	"period_type":   {Column: "period_type", Type: db.FilterString},
+	"workflow_name": {Column: "workflow_name", Type: db.FilterString},
+	"tag":           {Column: "tag", Type: db.FilterString},
+	"label":         {Column: "label", Type: db.FilterString},
+	"limit_cents":   {Column: "limit_cents", Type: db.FilterInt},
+	"spent_cents":   {Column: "spent_cents", Type: db.FilterInt},
+	"created_at":    {Column: "created_at", Type: db.FilterTime},
+}
+
+// ListBudgets lists an organization's budgets, newest first, using keyset pagination
+func (bm *BudgetManager) ListBudgets(ctx context.Context, orgID uuid.UUID, opts db.ListOptions) (*BudgetPage, error) {
+	clauses, err := db.ParseFilter(opts.Filter, budgetFilterFields)
+	if err != nil {
+		return nil, err
+	}
+	conditions, args := db.PostgresConditions(clauses, []interface{}{orgID})
+	conditions = append([]string{"org_id = $1"}, conditions...)
+
+	total, err := bm.postgres.EstimateTotal(ctx, `SELECT 1 FROM budget WHERE `+strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Cursor != "" {
+		createdAt, id, err := db.DecodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, createdAt, id)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	limit := opts.PageSize()
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`SELECT id, org_id, project_id, workflow_name, tag, label, period_type, limit_cents, spent_cents, period_start, period_end, created_at
 			  FROM budget 
-			  WHERE org_id = $1 
-			  ORDER BY created_at DESC`
+			  WHERE %s
+			  ORDER BY created_at DESC, id DESC
+			  LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
 
-	rows, err := bm.postgres.QueryContext(ctx, query, orgID)
+	rows, err := bm.postgres.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list budgets: %w", err)
 	}
 	defer func() { _ = rows.Close() }()
 
-	budgets := make([]Budget, 0)
+	page := &BudgetPage{Budgets: make([]Budget, 0, limit)}
 	for rows.Next() {
 		var budget Budget
 		err := rows.Scan(
-			&budget.ID, &budget.OrgID, &budget.ProjectID, &budget.PeriodType,
+			&budget.ID, &budget.OrgID, &budget.ProjectID, &budget.WorkflowName, &budget.Tag, &budget.Label, &budget.PeriodType,
 			&budget.LimitCents, &budget.SpentCents, &budget.PeriodStart, &budget.PeriodEnd, &budget.CreatedAt,
 		)
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
 		}
-		budgets = append(budgets, budget)
+		page.Budgets = append(page.Budgets, budget)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
 	}
 
-	return budgets, nil
+	// One extra row is fetched to learn whether another page exists
+	if len(page.Budgets) > limit {
+		page.Budgets = page.Budgets[:limit]
+		last := page.Budgets[limit-1]
+		page.NextCursor = db.EncodeCursor(last.CreatedAt, last.ID)
+	}
+	page.TotalEstimate = total
+
+	return page, nil
 }
 
 // DeleteBudget deletes a budget
@@ -233,6 +450,40 @@ func (bm *BudgetManager) GetBudgetHistory(ctx context.Context, budgetID uuid.UUI
 
 // Helper methods
 
+func computeBudgetStatus(budget *Budget, requestedAmount int64) *BudgetStatus {
+	status := &BudgetStatus{
+		BudgetID:       budget.ID,
+		LimitCents:     budget.LimitCents,
+		SpentCents:     budget.SpentCents,
+		RemainingCents: budget.LimitCents - budget.SpentCents,
+		PeriodStart:    budget.PeriodStart,
+		PeriodEnd:      budget.PeriodEnd,
+	}
+
+	// Calculate utilization percentage
+	if budget.LimitCents > 0 {
+		status.UtilizationPct = float64(budget.SpentCents) / float64(budget.LimitCents) * 100
+	}
+
+	// Determine status
+	if status.RemainingCents <= 0 {
+		status.Status = BudgetStatusExceeded
+	} else if status.UtilizationPct >= 90 {
+		status.Status = BudgetStatusCritical
+	} else if status.UtilizationPct >= 75 {
+		status.Status = BudgetStatusWarning
+	} else {
+		status.Status = BudgetStatusHealthy
+	}
+
+	// Check if requested amount would exceed budget
+	if requestedAmount > 0 && status.RemainingCents < requestedAmount {
+		status.Status = BudgetStatusExceeded
+	}
+
+	return status
+}
+
 func (bm *BudgetManager) createDefaultBudget(ctx context.Context, orgID uuid.UUID) (*Budget, error) {
 	now := time.Now()
 	budget := &Budget{
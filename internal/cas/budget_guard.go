@@ -0,0 +1,210 @@
+package cas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// Budget enforcement modes once an org's period limit is reached
+const (
+	BudgetEnforcementReject   = "reject"   // Fail provider calls until the period ends or the limit is raised
+	BudgetEnforcementThrottle = "throttle" // Let one provider call through per throttle interval
+)
+
+const (
+	defaultBudgetThrottleInterval = 30 * time.Second
+	// budgetGuardCacheTTL bounds how stale the org budget checked per call may be
+	budgetGuardCacheTTL  = 15 * time.Second
+	budgetThrottlePrefix = "budget:throttle:"
+)
+
+// BudgetExhaustedError is returned for a provider call made after the org's
+// period budget ran out. Throttled calls carry when the next may go through.
+type BudgetExhaustedError struct {
+	OrgID      uuid.UUID
+	SpentCents int64
+	LimitCents int64
+	RetryAfter time.Duration // Set when the call was throttled rather than rejected
+}
+
+func (e *BudgetExhaustedError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("org budget exhausted (%d/%d cents used), throttled: next call allowed in %s",
+			e.SpentCents, e.LimitCents, e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("org budget exhausted: %d/%d cents used", e.SpentCents, e.LimitCents)
+}
+
+// ErrorClass implements ClassifiedError. Throttled calls are retried later,
+// but they are not provider rate limits.
+func (e *BudgetExhaustedError) ErrorClass() ErrorClass {
+	if e.RetryAfter > 0 {
+		return ErrorClassBudgetThrottled
+	}
+	return ErrorClassTokenBudget
+}
+
+// Unsent implements UnsentError
+func (e *BudgetExhaustedError) Unsent() {}
+
+// BudgetUsage is an org's current period spending and how it is enforced
+type BudgetUsage struct {
+	OrgID             uuid.UUID     `json:"org_id"`
+	Enforcement       string        `json:"enforcement"`
+	AlertThresholdPct int           `json:"alert_threshold_pct,omitempty"`
+	Budget            *BudgetStatus `json:"budget,omitempty"` // Nil when the org has no active budget
+	Throttled         bool          `json:"throttled"`
+}
+
+type cachedBudgetStatus struct {
+	status    *BudgetStatus
+	expiresAt time.Time
+}
+
+// BudgetGuard enforces org budgets on each routed provider call
+type BudgetGuard struct {
+	budgets          *BudgetManager
+	redis            *redis.Client
+	enforcement      string
+	throttleInterval time.Duration
+	alertPct         int
+
+	mu    sync.Mutex
+	cache map[uuid.UUID]cachedBudgetStatus
+}
+
+func NewBudgetGuard(pg *db.PostgresDB, redisClient *redis.Client, cfg config.BudgetsConfig) *BudgetGuard {
+	enforcement := cfg.Enforcement
+	if enforcement != BudgetEnforcementReject && enforcement != BudgetEnforcementThrottle {
+		if enforcement != "" {
+			log.Printf("Unknown budget enforcement %q, rejecting calls over budget", enforcement)
+		}
+		enforcement = BudgetEnforcementReject
+	}
+	interval := cfg.ThrottleInterval
+	if interval <= 0 {
+		interval = defaultBudgetThrottleInterval
+	}
+
+	return &BudgetGuard{
+		budgets:          NewBudgetManager(pg),
+		redis:            redisClient,
+		enforcement:      enforcement,
+		throttleInterval: interval,
+		alertPct:         AlertThresholdPct(cfg.AlertThresholdRatio),
+		cache:            make(map[uuid.UUID]cachedBudgetStatus),
+	}
+}
+
+// AlertThresholdPct converts a configured alert ratio to a utilization
+// percentage, or 0 when it is unset or out of range
+func AlertThresholdPct(ratio float64) int {
+	if ratio <= 0 || ratio >= 1 {
+		return 0
+	}
+	return int(ratio*100 + 0.5)
+}
+
+// Admit checks the org's current budget before a provider call
+func (g *BudgetGuard) Admit(ctx context.Context, orgID uuid.UUID) error {
+	status, err := g.status(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if status == nil {
+		return nil // Orgs without a budget are not limited
+	}
+	return g.Enforce(ctx, orgID, status)
+}
+
+// Enforce rejects or throttles a call when the org budget status is exceeded
+func (g *BudgetGuard) Enforce(ctx context.Context, orgID uuid.UUID, status *BudgetStatus) error {
+	if status.Status != BudgetStatusExceeded {
+		return nil
+	}
+	exhausted := &BudgetExhaustedError{OrgID: orgID, SpentCents: status.SpentCents, LimitCents: status.LimitCents}
+	if g.enforcement != BudgetEnforcementThrottle {
+		return exhausted
+	}
+
+	wait, err := g.throttle(ctx, budgetThrottlePrefix+orgID.String(), g.throttleInterval)
+	if err != nil || wait == 0 {
+		return err
+	}
+	exhausted.RetryAfter = wait
+	return exhausted
+}
+
+// throttle lets the first call of each interval through and returns how long
+// the others must wait, or 0 for the call that claimed the interval
+func (g *BudgetGuard) throttle(ctx context.Context, key string, interval time.Duration) (time.Duration, error) {
+	claimed, err := g.redis.SetNX(ctx, key, 1, interval).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to throttle org calls: %w", err)
+	}
+	if claimed {
+		return 0, nil
+	}
+	if ttl, err := g.redis.PTTL(ctx, key).Result(); err == nil && ttl > 0 {
+		return ttl, nil
+	}
+	return interval, nil
+}
+
+// Usage reports an org's current period spending, bypassing the cache
+func (g *BudgetGuard) Usage(ctx context.Context, orgID uuid.UUID) (*BudgetUsage, error) {
+	status, err := g.load(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &BudgetUsage{OrgID: orgID, Enforcement: g.enforcement, AlertThresholdPct: g.alertPct, Budget: status}
+	if status != nil && status.Status == BudgetStatusExceeded && g.enforcement == BudgetEnforcementThrottle {
+		n, err := g.redis.Exists(ctx, budgetThrottlePrefix+orgID.String()).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to check budget throttle: %w", err)
+		}
+		usage.Throttled = n > 0
+	}
+	return usage, nil
+}
+
+func (g *BudgetGuard) status(ctx context.Context, orgID uuid.UUID) (*BudgetStatus, error) {
+	g.mu.Lock()
+	cached, ok := g.cache[orgID]
+	g.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.status, nil
+	}
+
+	status, err := g.load(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	g.cache[orgID] = cachedBudgetStatus{status: status, expiresAt: time.Now().Add(budgetGuardCacheTTL)}
+	g.mu.Unlock()
+	return status, nil
+}
+
+// load reads the org's active budget, returning nil when it has none
+func (g *BudgetGuard) load(ctx context.Context, orgID uuid.UUID) (*BudgetStatus, error) {
+	budget, err := g.budgets.GetCurrentBudget(ctx, orgID, nil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return computeBudgetStatus(budget, 0), nil
+}
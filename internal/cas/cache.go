@@ -22,13 +22,63 @@ func NewCacheManager(redisClient *redis.Client) *CacheManager {
 	}
 }
 
-// Get retrieves a cached response
-func (cm *CacheManager) Get(ctx context.Context, orgID uuid.UUID, promptHash, inputHash string) (*CacheResponse, error) {
-	key := cm.buildCacheKey(orgID, promptHash, inputHash)
+// CacheKey is what a provider response depends on: the prompt, the model
+// config and the step inputs
+type CacheKey struct {
+	Provider string                 `json:"provider"`
+	Model    string                 `json:"model"`
+	Prompt   string                 `json:"prompt"`
+	Params   map[string]interface{} `json:"params,omitempty"` // Call settings such as temperature and max_tokens
+	Inputs   map[string]interface{} `json:"inputs,omitempty"`
+}
+
+// Hashes returns the hash of the prompt with its model config, and of the inputs
+func (k CacheKey) Hashes() (promptHash, inputHash string, err error) {
+	promptHash, err = contentHash(struct {
+		Provider string                 `json:"provider"`
+		Model    string                 `json:"model"`
+		Prompt   string                 `json:"prompt"`
+		Params   map[string]interface{} `json:"params"`
+	}{k.Provider, k.Model, k.Prompt, k.Params})
+	if err != nil {
+		return "", "", err
+	}
+	inputHash, err = contentHash(k.Inputs)
+	return promptHash, inputHash, err
+}
+
+// CacheScope is who shares a cached response: the whole org, one project or
+// one user. Entries of one scope are never served to another.
+type CacheScope struct {
+	Level PrivacyLevel `json:"level"`
+	ID    string       `json:"id,omitempty"` // Project or user ID; empty for org scope
+}
+
+// segment is the scope's part of a cache key
+func (s CacheScope) segment() (string, error) {
+	switch s.Level {
+	case PrivacyOrg:
+		return string(PrivacyOrg), nil
+	case PrivacyProject, PrivacyUser:
+		if s.ID == "" {
+			return "", fmt.Errorf("%s cache scope needs an ID", s.Level)
+		}
+		return fmt.Sprintf("%s:%s", s.Level, s.ID), nil
+	}
+	return "", fmt.Errorf("privacy level %s not allowed for caching", s.Level)
+}
+
+// Get retrieves a cached response, counting the lookup as a hit or miss
+func (cm *CacheManager) Get(ctx context.Context, orgID uuid.UUID, scope CacheScope, promptHash, inputHash string) (*CacheResponse, error) {
+	key, err := cm.buildCacheKey(orgID, scope, promptHash, inputHash)
+	if err != nil {
+		return nil, err
+	}
 
 	result, err := cm.redis.Get(ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
+			cm.updateCacheStats(ctx, orgID, "miss")
 			return &CacheResponse{Hit: false}, nil
 		}
 		return nil, fmt.Errorf("failed to get from cache: %w", err)
@@ -43,8 +93,10 @@ func (cm *CacheManager) Get(ctx context.Context, orgID uuid.UUID, promptHash, in
 	if time.Now().After(cachedData.ExpiresAt) {
 		// Remove expired entry
 		cm.redis.Del(ctx, key)
+		cm.updateCacheStats(ctx, orgID, "miss")
 		return &CacheResponse{Hit: false}, nil
 	}
+	cm.updateCacheStats(ctx, orgID, "hit")
 
 	return &CacheResponse{
 		Hit:       true,
@@ -60,17 +112,27 @@ func (cm *CacheManager) Put(ctx context.Context, orgID uuid.UUID, req *CacheRequ
 		return nil // Caching disabled
 	}
 
-	key := cm.buildCacheKey(orgID, req.PromptHash, req.InputHash)
-
 	// Check privacy level
 	if !cm.isPrivacyLevelAllowed(req.Policy.PrivacyLevel, orgID) {
 		return fmt.Errorf("privacy level %s not allowed for caching", req.Policy.PrivacyLevel)
 	}
+	scope := req.Scope
+	if scope.Level == "" {
+		scope.Level = req.Policy.PrivacyLevel
+	}
+	key, err := cm.buildCacheKey(orgID, scope, req.PromptHash, req.InputHash)
+	if err != nil {
+		return err
+	}
+	ttl := req.TTL
+	if ttl <= 0 {
+		ttl = req.Policy.TTL
+	}
 
 	cachedData := CachedData{
 		Response:  req.Response,
 		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(req.TTL),
+		ExpiresAt: time.Now().Add(ttl),
 		Policy:    req.Policy,
 	}
 
@@ -80,7 +142,7 @@ func (cm *CacheManager) Put(ctx context.Context, orgID uuid.UUID, req *CacheRequ
 	}
 
 	// Store with TTL
-	err = cm.redis.Set(ctx, key, data, req.TTL).Err()
+	err = cm.redis.Set(ctx, key, data, ttl).Err()
 	if err != nil {
 		return fmt.Errorf("failed to store in cache: %w", err)
 	}
@@ -92,8 +154,11 @@ func (cm *CacheManager) Put(ctx context.Context, orgID uuid.UUID, req *CacheRequ
 }
 
 // Delete removes an entry from cache
-func (cm *CacheManager) Delete(ctx context.Context, orgID uuid.UUID, promptHash, inputHash string) error {
-	key := cm.buildCacheKey(orgID, promptHash, inputHash)
+func (cm *CacheManager) Delete(ctx context.Context, orgID uuid.UUID, scope CacheScope, promptHash, inputHash string) error {
+	key, err := cm.buildCacheKey(orgID, scope, promptHash, inputHash)
+	if err != nil {
+		return err
+	}
 	return cm.redis.Del(ctx, key).Err()
 }
 
@@ -165,6 +230,10 @@ func (cm *CacheManager) Flush(ctx context.Context) error {
 
 // GenerateHash generates a hash for cache key
 func (cm *CacheManager) GenerateHash(content interface{}) (string, error) {
+	return contentHash(content)
+}
+
+func contentHash(content interface{}) (string, error) {
 	data, err := json.Marshal(content)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal content: %w", err)
@@ -207,8 +276,12 @@ func (cm *CacheManager) GetCachePolicy(orgID uuid.UUID, qualityTier QualityTier,
 
 // Helper methods
 
-func (cm *CacheManager) buildCacheKey(orgID uuid.UUID, promptHash, inputHash string) string {
-	return fmt.Sprintf("cache:%s:%s:%s", orgID.String(), promptHash, inputHash)
+func (cm *CacheManager) buildCacheKey(orgID uuid.UUID, scope CacheScope, promptHash, inputHash string) (string, error) {
+	segment, err := scope.segment()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("cache:%s:%s:%s:%s", orgID.String(), segment, promptHash, inputHash), nil
 }
 
 func (cm *CacheManager) isPrivacyLevelAllowed(level PrivacyLevel, orgID uuid.UUID) bool {
@@ -0,0 +1,170 @@
+package cas
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/metrics"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	clusterRequestsKey      = "metrics:provider_requests"
+	clusterLatencySeriesKey = "metrics:provider_latency_series"
+	clusterLatencyPrefix    = "metrics:provider_latency:"
+	clusterLatencySumField  = "sum_us"
+	// clusterSeriesSep joins label values in Redis hash fields and set members
+	clusterSeriesSep = "|"
+	// clusterScrapeTimeout bounds the Redis reads made while serving /metrics
+	clusterScrapeTimeout = 2 * time.Second
+)
+
+// clusterRedis is the client shared provider metrics are mirrored to; nil
+// keeps metrics process-local
+var clusterRedis atomic.Pointer[redis.Client]
+
+// EnableClusterMetrics mirrors provider request counters and latency
+// histograms into Redis, so every replica behind a load balancer serves the
+// same cluster-wide agentflow_cluster_* series
+func EnableClusterMetrics(redisClient *redis.Client) {
+	clusterRedis.Store(redisClient)
+}
+
+func init() {
+	Registry.NewCounterFunc("agentflow_cluster_provider_requests_total",
+		"Provider calls by outcome error class across all replicas", collectClusterRequests,
+		"provider", "model", "error_class")
+	Registry.NewGaugeFunc("agentflow_cluster_provider_latency_seconds",
+		"Provider call latency percentiles across all replicas", collectClusterLatency,
+		"provider", "model", "quantile")
+}
+
+// mirrorClusterMetrics queues the shared counter updates for a telemetry
+// record on a pipeline; latencies are recorded for successful calls only
+func mirrorClusterMetrics(ctx context.Context, pipe redis.Pipeliner, record *ProviderTelemetry, errorClass ErrorClass) {
+	series := record.ProviderName + clusterSeriesSep + record.ModelName
+	pipe.HIncrBy(ctx, clusterRequestsKey, series+clusterSeriesSep+string(errorClass), 1)
+
+	if errorClass != ErrorClassNone {
+		return
+	}
+	latency := record.Latency
+	if latency < 0 {
+		latency = 0
+	}
+	pipe.SAdd(ctx, clusterLatencySeriesKey, series)
+	pipe.HIncrBy(ctx, clusterLatencyPrefix+series, strconv.Itoa(latencyBucket(latency)), 1)
+	pipe.HIncrBy(ctx, clusterLatencyPrefix+series, clusterLatencySumField, latency.Microseconds())
+}
+
+func collectClusterRequests() []metrics.Sample {
+	client := clusterRedis.Load()
+	if client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterScrapeTimeout)
+	defer cancel()
+
+	fields, err := client.HGetAll(ctx, clusterRequestsKey).Result()
+	if err != nil {
+		log.Printf("Failed to read cluster provider requests: %v", err)
+		return nil
+	}
+
+	samples := make([]metrics.Sample, 0, len(fields))
+	for field, value := range fields {
+		labels := strings.SplitN(field, clusterSeriesSep, 3)
+		count, err := strconv.ParseFloat(value, 64)
+		if len(labels) != 3 || err != nil {
+			continue
+		}
+		samples = append(samples, metrics.Sample{LabelValues: labels, Value: count})
+	}
+	return samples
+}
+
+func collectClusterLatency() []metrics.Sample {
+	client := clusterRedis.Load()
+	if client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterScrapeTimeout)
+	defer cancel()
+
+	percentiles, err := clusterLatencyPercentiles(ctx, client)
+	if err != nil {
+		log.Printf("Failed to read cluster provider latency: %v", err)
+		return nil
+	}
+
+	samples := make([]metrics.Sample, 0, len(percentiles)*3)
+	for series, p := range percentiles {
+		labels := strings.SplitN(series, clusterSeriesSep, 2)
+		if len(labels) != 2 || p.Count == 0 {
+			continue
+		}
+		samples = append(samples,
+			metrics.Sample{LabelValues: []string{labels[0], labels[1], "0.5"}, Value: p.P50.Seconds()},
+			metrics.Sample{LabelValues: []string{labels[0], labels[1], "0.95"}, Value: p.P95.Seconds()},
+			metrics.Sample{LabelValues: []string{labels[0], labels[1], "0.99"}, Value: p.P99.Seconds()},
+		)
+	}
+	return samples
+}
+
+// clusterLatencyPercentiles merges every replica's latency buckets per provider/model
+func clusterLatencyPercentiles(ctx context.Context, client *redis.Client) (map[string]LatencyPercentiles, error) {
+	series, err := client.SMembers(ctx, clusterLatencySeriesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(series))
+	for i, s := range series {
+		cmds[i] = pipe.HGetAll(ctx, clusterLatencyPrefix+s)
+	}
+	if len(series) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make(map[string]LatencyPercentiles, len(series))
+	for i, s := range series {
+		result[s] = histogramFromFields(cmds[i].Val()).percentiles()
+	}
+	return result, nil
+}
+
+// histogramFromFields rebuilds a latency histogram from its Redis hash. The
+// maximum is approximated by the highest populated bucket.
+func histogramFromFields(fields map[string]string) *latencyHistogram {
+	h := newLatencyHistogram()
+	for field, value := range fields {
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		if field == clusterLatencySumField {
+			h.sum = time.Duration(n) * time.Microsecond
+			continue
+		}
+		idx, err := strconv.Atoi(field)
+		if err != nil || idx < 0 || idx >= len(h.counts) {
+			continue
+		}
+		h.counts[idx] += n
+		h.total += n
+		if value := latencyBucketValue(idx); n > 0 && value > h.max {
+			h.max = value
+		}
+	}
+	return h
+}
@@ -0,0 +1,212 @@
+package cas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/google/uuid"
+)
+
+// DegradationActionType is one way calls are cheapened near the budget limit
+type DegradationActionType string
+
+const (
+	DegradeCheaperModel   DegradationActionType = "cheaper_model"
+	DegradeShorterContext DegradationActionType = "shorter_context"
+	DegradeThrottle       DegradationActionType = "throttle"
+)
+
+const degradationThrottlePrefix = "budget:degrade:"
+
+// DegradationAction is applied to an org's calls once its policy triggers
+type DegradationAction struct {
+	Type             DegradationActionType `json:"type"`
+	Provider         string                `json:"provider,omitempty"`           // cheaper_model target
+	Model            string                `json:"model,omitempty"`              // cheaper_model target
+	MaxContextTokens int                   `json:"max_context_tokens,omitempty"` // Prompt tokens left to shorter_context calls
+	Interval         time.Duration         `json:"interval,omitempty"`           // Gap between throttled calls
+}
+
+// String labels the action as recorded on degraded calls
+func (a DegradationAction) String() string {
+	switch a.Type {
+	case DegradeCheaperModel:
+		return fmt.Sprintf("%s:%s/%s", a.Type, a.Provider, a.Model)
+	case DegradeShorterContext:
+		return fmt.Sprintf("%s:%d", a.Type, a.MaxContextTokens)
+	case DegradeThrottle:
+		return fmt.Sprintf("%s:%s", a.Type, a.Interval)
+	}
+	return string(a.Type)
+}
+
+// DegradationPolicy applies its actions to the calls of an org whose budget
+// utilization reached TriggerRatio
+type DegradationPolicy struct {
+	TriggerRatio float64             `json:"trigger_ratio"`
+	Actions      []DegradationAction `json:"actions"`
+}
+
+// Validate checks the trigger and every action
+func (p *DegradationPolicy) Validate() error {
+	if p.TriggerRatio <= 0 || p.TriggerRatio > 1 {
+		return fmt.Errorf("degradation trigger_ratio must be in (0, 1], got %g", p.TriggerRatio)
+	}
+	seen := make(map[DegradationActionType]bool, len(p.Actions))
+	for _, action := range p.Actions {
+		if seen[action.Type] {
+			return fmt.Errorf("duplicate degradation action %s", action.Type)
+		}
+		seen[action.Type] = true
+		switch action.Type {
+		case DegradeCheaperModel:
+			if action.Provider == "" || action.Model == "" {
+				return fmt.Errorf("cheaper_model degradation needs a provider and model")
+			}
+		case DegradeShorterContext:
+			if action.MaxContextTokens <= 0 {
+				return fmt.Errorf("shorter_context degradation needs a positive max_context_tokens")
+			}
+		case DegradeThrottle:
+			if action.Interval <= 0 {
+				return fmt.Errorf("throttle degradation needs a positive interval")
+			}
+		default:
+			return fmt.Errorf("unknown degradation action %q: use cheaper_model, shorter_context or throttle", action.Type)
+		}
+	}
+	return nil
+}
+
+// Active returns the actions that apply at a budget status, or nil below
+// the trigger
+func (p *DegradationPolicy) Active(status *BudgetStatus) *Degradation {
+	if p == nil || status == nil || status.LimitCents <= 0 || len(p.Actions) == 0 {
+		return nil
+	}
+	if status.UtilizationPct < p.TriggerRatio*100 {
+		return nil
+	}
+	return &Degradation{UtilizationPct: status.UtilizationPct, Actions: p.Actions}
+}
+
+// Degradation is the set of actions applied to one call
+type Degradation struct {
+	UtilizationPct float64             `json:"utilization_pct"`
+	Actions        []DegradationAction `json:"actions"`
+}
+
+// Action returns the degradation's action of a type, if it has one
+func (d *Degradation) Action(actionType DegradationActionType) (DegradationAction, bool) {
+	if d == nil {
+		return DegradationAction{}, false
+	}
+	for _, action := range d.Actions {
+		if action.Type == actionType {
+			return action, true
+		}
+	}
+	return DegradationAction{}, false
+}
+
+// Labels lists the applied actions for the call's trace
+func (d *Degradation) Labels() []string {
+	if d == nil {
+		return nil
+	}
+	labels := make([]string, 0, len(d.Actions))
+	for _, action := range d.Actions {
+		labels = append(labels, action.String())
+	}
+	return labels
+}
+
+// DegradationThrottledError is returned for a call held back by a throttle
+// degradation; it is retried once the interval passes
+type DegradationThrottledError struct {
+	OrgID          uuid.UUID
+	UtilizationPct float64
+	RetryAfter     time.Duration
+}
+
+func (e *DegradationThrottledError) Error() string {
+	return fmt.Sprintf("org budget %.1f%% used, calls throttled: next call allowed in %s",
+		e.UtilizationPct, e.RetryAfter.Round(time.Second))
+}
+
+// ErrorClass implements ClassifiedError; the throttle is our budget policy,
+// not a provider rate limit
+func (e *DegradationThrottledError) ErrorClass() ErrorClass {
+	return ErrorClassBudgetThrottled
+}
+
+// Unsent implements UnsentError
+func (e *DegradationThrottledError) Unsent() {}
+
+// DegradationController decides which degradations apply to an org's calls
+// from its current budget utilization
+type DegradationController struct {
+	guard  *BudgetGuard
+	policy *DegradationPolicy
+}
+
+func NewDegradationController(guard *BudgetGuard, cfg config.DegradationConfig) *DegradationController {
+	if cfg.TriggerRatio == 0 {
+		return &DegradationController{guard: guard}
+	}
+
+	policy := &DegradationPolicy{TriggerRatio: cfg.TriggerRatio, Actions: make([]DegradationAction, 0, len(cfg.Actions))}
+	for _, a := range cfg.Actions {
+		policy.Actions = append(policy.Actions, DegradationAction{
+			Type:             DegradationActionType(a.Type),
+			Provider:         a.Provider,
+			Model:            a.Model,
+			MaxContextTokens: a.MaxContextTokens,
+			Interval:         a.Interval,
+		})
+	}
+	if err := policy.Validate(); err != nil {
+		log.Printf("Ignoring budget degradation policy from config: %v", err)
+		policy = nil
+	}
+	return &DegradationController{guard: guard, policy: policy}
+}
+
+// Plan returns the degradation to apply to an org's next call, or nil when
+// none applies. A throttled call is refused with a DegradationThrottledError.
+func (c *DegradationController) Plan(ctx context.Context, orgID uuid.UUID) (*Degradation, error) {
+	if c.policy == nil {
+		return nil, nil
+	}
+	status, err := c.guard.status(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return c.apply(ctx, orgID, c.policy.Active(status))
+}
+
+// PlanForStatus is Plan for a budget status the caller already has
+func (c *DegradationController) PlanForStatus(ctx context.Context, orgID uuid.UUID, status *BudgetStatus) (*Degradation, error) {
+	if c.policy == nil {
+		return nil, nil
+	}
+	return c.apply(ctx, orgID, c.policy.Active(status))
+}
+
+func (c *DegradationController) apply(ctx context.Context, orgID uuid.UUID, degradation *Degradation) (*Degradation, error) {
+	throttle, ok := degradation.Action(DegradeThrottle)
+	if !ok {
+		return degradation, nil
+	}
+	wait, err := c.guard.throttle(ctx, degradationThrottlePrefix+orgID.String(), throttle.Interval)
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		return nil, &DegradationThrottledError{OrgID: orgID, UtilizationPct: degradation.UtilizationPct, RetryAfter: wait}
+	}
+	return degradation, nil
+}
@@ -0,0 +1,456 @@
+package cas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultForecastLookback is the recent traffic a forecast is fitted to
+	defaultForecastLookback = 6 * time.Hour
+	// defaultForecastHorizon is how far ahead usage is forecast
+	defaultForecastHorizon = time.Hour
+	// capacityWarningUtilization and capacityCriticalUtilization are the
+	// shares of a provider limit the forecast peak may reach before alerting
+	capacityWarningUtilization  = 0.8
+	capacityCriticalUtilization = 0.95
+	// capacityAlertCooldown limits alerts to one per provider/model and level
+	capacityAlertCooldown = time.Hour
+	// routingHeadroomUtilization is the forecast utilization below which a
+	// model is suggested as a routing alternative
+	routingHeadroomUtilization = 0.5
+)
+
+type CapacityLevel string
+
+const (
+	CapacityOK       CapacityLevel = "ok"
+	CapacityWarning  CapacityLevel = "warning"
+	CapacityCritical CapacityLevel = "critical"
+)
+
+// UsageMinute is one minute of calls to a provider/model
+type UsageMinute struct {
+	Minute   time.Time `json:"minute"`
+	Tokens   int64     `json:"tokens"`
+	Requests int64     `json:"requests"`
+}
+
+// ProviderLimits are a provider/model's tokens- and requests-per-minute
+// limits, read from tpm_limit and rpm_limit in its config. Without rpm_limit
+// the QPS limit is used; zero means no limit is known.
+type ProviderLimits struct {
+	ProviderName string `json:"provider_name"`
+	ModelName    string `json:"model_name"`
+	TPM          int64  `json:"tpm_limit"`
+	RPM          int64  `json:"rpm_limit"`
+}
+
+func providerLimits(provider ProviderConfig) ProviderLimits {
+	limits := ProviderLimits{ProviderName: provider.ProviderName, ModelName: provider.ModelName}
+	if tpm, ok := provider.Config["tpm_limit"].(float64); ok {
+		limits.TPM = int64(tpm)
+	}
+	if rpm, ok := provider.Config["rpm_limit"].(float64); ok {
+		limits.RPM = int64(rpm)
+	} else if provider.QPSLimit > 0 {
+		limits.RPM = int64(provider.QPSLimit) * 60
+	}
+	return limits
+}
+
+// ProviderForecast is a provider/model's forecast peak usage against its limits
+type ProviderForecast struct {
+	ProviderName   string         `json:"provider_name"`
+	ModelName      string         `json:"model_name"`
+	Limits         ProviderLimits `json:"limits"`
+	CurrentTPM     float64        `json:"current_tpm"` // Mean over the last 15 minutes
+	CurrentRPM     float64        `json:"current_rpm"`
+	ForecastTPM    float64        `json:"forecast_tpm"` // Expected peak minute within the horizon
+	ForecastRPM    float64        `json:"forecast_rpm"`
+	TPMUtilization float64        `json:"tpm_utilization,omitempty"` // Forecast peak as a share of the limit
+	RPMUtilization float64        `json:"rpm_utilization,omitempty"`
+	Level          CapacityLevel  `json:"level"`
+	Suggestions    []string       `json:"suggestions,omitempty"`
+	Horizon        time.Duration  `json:"horizon"`
+}
+
+// utilization is the larger of the token and request utilization
+func (f *ProviderForecast) utilization() float64 {
+	return math.Max(f.TPMUtilization, f.RPMUtilization)
+}
+
+// forecastPeak projects a per-minute series horizon minutes ahead with a
+// least-squares trend, scaled by how far the series' p95 minute sits above
+// its mean so bursty traffic forecasts a higher peak
+func forecastPeak(series []float64, horizon int) float64 {
+	n := len(series)
+	if n == 0 {
+		return 0
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range series {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	mean := sumY / float64(n)
+	if mean == 0 {
+		return 0
+	}
+	slope := 0.0
+	if denominator := float64(n)*sumXX - sumX*sumX; denominator != 0 {
+		slope = (float64(n)*sumXY - sumX*sumY) / denominator
+	}
+	intercept := mean - slope*sumX/float64(n)
+	projected := math.Max(intercept+slope*float64(n-1+horizon), 0)
+
+	sorted := append([]float64(nil), series...)
+	sort.Float64s(sorted)
+	burst := sorted[int(math.Ceil(0.95*float64(n)))-1] / mean
+	return projected * math.Max(burst, 1)
+}
+
+// buildProviderForecasts forecasts each enabled provider/model, grades it
+// against its limits and suggests quota increases or routing changes for
+// those approaching them
+func buildProviderForecasts(usage map[string][]UsageMinute, limits []ProviderLimits, lookback, horizon time.Duration, now time.Time) []ProviderForecast {
+	minutes := int(lookback / time.Minute)
+	horizonMinutes := int(horizon / time.Minute)
+	start := now.Truncate(time.Minute).Add(-lookback)
+
+	forecasts := make([]ProviderForecast, 0, len(limits))
+	for _, limit := range limits {
+		tokens := make([]float64, minutes)
+		requests := make([]float64, minutes)
+		for _, minute := range usage[limit.ProviderName+":"+limit.ModelName] {
+			i := int(minute.Minute.Sub(start) / time.Minute)
+			if i < 0 || i >= minutes {
+				continue
+			}
+			tokens[i] += float64(minute.Tokens)
+			requests[i] += float64(minute.Requests)
+		}
+
+		forecast := ProviderForecast{
+			ProviderName: limit.ProviderName,
+			ModelName:    limit.ModelName,
+			Limits:       limit,
+			CurrentTPM:   recentMean(tokens, 15),
+			CurrentRPM:   recentMean(requests, 15),
+			ForecastTPM:  forecastPeak(tokens, horizonMinutes),
+			ForecastRPM:  forecastPeak(requests, horizonMinutes),
+			Level:        CapacityOK,
+			Horizon:      horizon,
+		}
+		if limit.TPM > 0 {
+			forecast.TPMUtilization = forecast.ForecastTPM / float64(limit.TPM)
+		}
+		if limit.RPM > 0 {
+			forecast.RPMUtilization = forecast.ForecastRPM / float64(limit.RPM)
+		}
+		switch utilization := forecast.utilization(); {
+		case utilization >= capacityCriticalUtilization:
+			forecast.Level = CapacityCritical
+		case utilization >= capacityWarningUtilization:
+			forecast.Level = CapacityWarning
+		}
+		forecasts = append(forecasts, forecast)
+	}
+
+	for i := range forecasts {
+		if forecasts[i].Level != CapacityOK {
+			forecasts[i].Suggestions = capacitySuggestions(&forecasts[i], forecasts)
+		}
+	}
+	sort.Slice(forecasts, func(i, j int) bool { return forecasts[i].utilization() > forecasts[j].utilization() })
+	return forecasts
+}
+
+// capacitySuggestions proposes the quota that keeps the forecast under the
+// warning threshold, and models with headroom to take the excess traffic
+func capacitySuggestions(forecast *ProviderForecast, all []ProviderForecast) []string {
+	suggestions := make([]string, 0, 3)
+	name := forecast.ProviderName + "/" + forecast.ModelName
+	if forecast.TPMUtilization >= capacityWarningUtilization {
+		suggestions = append(suggestions, fmt.Sprintf("Request a TPM quota increase for %s to at least %d (limit %d)",
+			name, roundUpQuota(forecast.ForecastTPM/capacityWarningUtilization, 1000), forecast.Limits.TPM))
+	}
+	if forecast.RPMUtilization >= capacityWarningUtilization {
+		suggestions = append(suggestions, fmt.Sprintf("Request an RPM quota increase for %s to at least %d (limit %d)",
+			name, roundUpQuota(forecast.ForecastRPM/capacityWarningUtilization, 10), forecast.Limits.RPM))
+	}
+
+	// Share of traffic to move so the binding limit drops back to the warning threshold
+	excessTPM := forecast.ForecastTPM * (1 - capacityWarningUtilization/forecast.utilization())
+	if excessTPM <= 0 {
+		return suggestions
+	}
+	for _, alternative := range all {
+		if alternative.ProviderName == forecast.ProviderName && alternative.ModelName == forecast.ModelName {
+			continue
+		}
+		if alternative.Limits.TPM == 0 || alternative.utilization() >= routingHeadroomUtilization {
+			continue
+		}
+		spare := routingHeadroomUtilization*float64(alternative.Limits.TPM) - alternative.ForecastTPM
+		if spare < excessTPM {
+			continue
+		}
+		suggestions = append(suggestions, fmt.Sprintf("Route about %.0f TPM from %s to %s/%s, which has %.0f TPM of forecast headroom",
+			excessTPM, name, alternative.ProviderName, alternative.ModelName, spare))
+		break
+	}
+	return suggestions
+}
+
+func recentMean(series []float64, minutes int) float64 {
+	if len(series) < minutes {
+		minutes = len(series)
+	}
+	if minutes == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range series[len(series)-minutes:] {
+		sum += v
+	}
+	return sum / float64(minutes)
+}
+
+func roundUpQuota(value float64, step int64) int64 {
+	return int64(math.Ceil(value/float64(step))) * step
+}
+
+// CapacityAlert is sent when a provider/model's forecast approaches its limits
+type CapacityAlert struct {
+	OrgID        uuid.UUID      `json:"org_id"`
+	ProviderName string         `json:"provider_name"`
+	ModelName    string         `json:"model_name"`
+	Level        CapacityLevel  `json:"level"`
+	ForecastTPM  float64        `json:"forecast_tpm"`
+	ForecastRPM  float64        `json:"forecast_rpm"`
+	Limits       ProviderLimits `json:"limits"`
+	Suggestions  []string       `json:"suggestions,omitempty"`
+	Message      string         `json:"message"`
+	Timestamp    time.Time      `json:"timestamp"`
+}
+
+// CapacityForecaster forecasts per-provider usage from recent telemetry and
+// alerts before forecast traffic reaches provider TPM/RPM limits
+type CapacityForecaster struct {
+	postgres *db.PostgresDB
+	alerts   config.AlertsConfig
+	client   *http.Client
+
+	mu        sync.Mutex
+	lastAlert map[string]time.Time
+}
+
+func NewCapacityForecaster(pg *db.PostgresDB, alerts config.AlertsConfig) *CapacityForecaster {
+	return &CapacityForecaster{
+		postgres:  pg,
+		alerts:    alerts,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		lastAlert: make(map[string]time.Time),
+	}
+}
+
+// Forecast forecasts an org's usage of each enabled provider/model over the
+// horizon from traffic in the lookback window
+func (cf *CapacityForecaster) Forecast(ctx context.Context, orgID uuid.UUID, lookback, horizon time.Duration) ([]ProviderForecast, error) {
+	if lookback <= 0 {
+		lookback = defaultForecastLookback
+	}
+	if horizon <= 0 {
+		horizon = defaultForecastHorizon
+	}
+	now := time.Now()
+
+	limitsQuery := `SELECT provider_name, model_name, config, qps_limit FROM provider_config
+					WHERE org_id = $1 AND enabled = true ORDER BY provider_name, model_name`
+	rows, err := cf.postgres.QueryContext(ctx, limitsQuery, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query providers: %w", err)
+	}
+	limits := make([]ProviderLimits, 0)
+	for rows.Next() {
+		var provider ProviderConfig
+		var configJSON []byte
+		if err := rows.Scan(&provider.ProviderName, &provider.ModelName, &configJSON, &provider.QPSLimit); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan provider: %w", err)
+		}
+		_ = json.Unmarshal(configJSON, &provider.Config) // Providers without config have no TPM limit
+		limits = append(limits, providerLimits(provider))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read providers: %w", err)
+	}
+
+	usageQuery := `SELECT provider_name, model_name, date_trunc('minute', recorded_at), SUM(tokens_used), COUNT(*)
+				   FROM provider_telemetry WHERE org_id = $1 AND recorded_at >= $2
+				   GROUP BY 1, 2, 3`
+	rows, err = cf.postgres.QueryContext(ctx, usageQuery, orgID, now.Truncate(time.Minute).Add(-lookback))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query provider usage: %w", err)
+	}
+	defer rows.Close()
+	usage := make(map[string][]UsageMinute)
+	for rows.Next() {
+		var providerName, modelName string
+		var minute UsageMinute
+		if err := rows.Scan(&providerName, &modelName, &minute.Minute, &minute.Tokens, &minute.Requests); err != nil {
+			return nil, fmt.Errorf("failed to scan provider usage: %w", err)
+		}
+		key := providerName + ":" + modelName
+		usage[key] = append(usage[key], minute)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read provider usage: %w", err)
+	}
+
+	return buildProviderForecasts(usage, limits, lookback, horizon, now), nil
+}
+
+// Run checks forecasts on an interval until ctx is done
+func (cf *CapacityForecaster) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cf.Check(ctx); err != nil {
+				log.Printf("Failed to check provider capacity: %v", err)
+			}
+		}
+	}
+}
+
+// Check forecasts every org's provider usage once and alerts on those
+// approaching their limits
+func (cf *CapacityForecaster) Check(ctx context.Context) error {
+	rows, err := cf.postgres.QueryContext(ctx, `SELECT DISTINCT org_id FROM provider_config WHERE enabled = true`)
+	if err != nil {
+		return fmt.Errorf("failed to query orgs: %w", err)
+	}
+	orgs := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var orgID uuid.UUID
+		if err := rows.Scan(&orgID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan org: %w", err)
+		}
+		orgs = append(orgs, orgID)
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, orgID := range orgs {
+		forecasts, err := cf.Forecast(ctx, orgID, defaultForecastLookback, defaultForecastHorizon)
+		if err != nil {
+			log.Printf("Failed to forecast provider usage for org %s: %v", orgID, err)
+			continue
+		}
+		for i := range forecasts {
+			if forecasts[i].Level == CapacityOK || !cf.shouldAlert(orgID, &forecasts[i], now) {
+				continue
+			}
+			cf.notify(ctx, capacityAlert(orgID, &forecasts[i], now))
+		}
+	}
+	return nil
+}
+
+func (cf *CapacityForecaster) shouldAlert(orgID uuid.UUID, forecast *ProviderForecast, now time.Time) bool {
+	key := fmt.Sprintf("%s/%s/%s/%s", orgID, forecast.ProviderName, forecast.ModelName, forecast.Level)
+
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	if last, ok := cf.lastAlert[key]; ok && now.Sub(last) < capacityAlertCooldown {
+		return false
+	}
+	cf.lastAlert[key] = now
+	return true
+}
+
+func capacityAlert(orgID uuid.UUID, forecast *ProviderForecast, now time.Time) CapacityAlert {
+	return CapacityAlert{
+		OrgID:        orgID,
+		ProviderName: forecast.ProviderName,
+		ModelName:    forecast.ModelName,
+		Level:        forecast.Level,
+		ForecastTPM:  forecast.ForecastTPM,
+		ForecastRPM:  forecast.ForecastRPM,
+		Limits:       forecast.Limits,
+		Suggestions:  forecast.Suggestions,
+		Message: fmt.Sprintf("%s/%s is forecast to reach %.0f%% of its rate limits within %s (%.0f TPM, %.0f RPM)",
+			forecast.ProviderName, forecast.ModelName, forecast.utilization()*100, forecast.Horizon,
+			forecast.ForecastTPM, forecast.ForecastRPM),
+		Timestamp: now,
+	}
+}
+
+// notify sends a capacity alert to the configured webhook and Slack channel
+func (cf *CapacityForecaster) notify(ctx context.Context, alert CapacityAlert) {
+	log.Printf("Capacity alert: %s", alert.Message)
+	if cf.alerts.WebhookURL != "" {
+		if err := cf.post(ctx, cf.alerts.WebhookURL, alert); err != nil {
+			log.Printf("Failed to send capacity alert for %s/%s: %v", alert.ProviderName, alert.ModelName, err)
+		}
+	}
+	if cf.alerts.SlackWebhookURL != "" {
+		icon := ":warning:"
+		if alert.Level == CapacityCritical {
+			icon = ":rotating_light:"
+		}
+		text := icon + " " + alert.Message
+		for _, suggestion := range alert.Suggestions {
+			text += "\n• " + suggestion
+		}
+		if err := cf.post(ctx, cf.alerts.SlackWebhookURL, map[string]string{"text": text}); err != nil {
+			log.Printf("Failed to send capacity alert for %s/%s: %v", alert.ProviderName, alert.ModelName, err)
+		}
+	}
+}
+
+func (cf *CapacityForecaster) post(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := cf.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
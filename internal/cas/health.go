@@ -0,0 +1,370 @@
+package cas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// unhealthyPingFailures consecutive failed pings mark a provider unhealthy
+	unhealthyPingFailures = 3
+	// healthWindowSize is the number of recent calls the error rate covers
+	healthWindowSize = 50
+	// healthMinCalls is the number of calls needed before the error rate counts
+	healthMinCalls = 10
+	// unhealthyErrorRate is the share of server errors and timeouts that marks a provider unhealthy
+	unhealthyErrorRate = 0.5
+)
+
+// defaultHealthURLs are pinged unless a provider's config sets health_url
+var defaultHealthURLs = map[string]string{
+	providerOpenAI:    "https://api.openai.com/v1/models",
+	providerAnthropic: "https://api.anthropic.com/v1/models",
+	providerGoogle:    "https://generativelanguage.googleapis.com/v1beta/models",
+	providerCohere:    "https://api.cohere.com/v1/models",
+}
+
+// ProviderHealth is the current health of one provider/model
+type ProviderHealth struct {
+	ProviderName            string    `json:"provider_name"`
+	ModelName               string    `json:"model_name"`
+	Healthy                 bool      `json:"healthy"`
+	Reason                  string    `json:"reason,omitempty"`
+	ConsecutivePingFailures int       `json:"consecutive_ping_failures"`
+	ErrorRate               float64   `json:"error_rate"` // Share of recent calls failing with server errors or timeouts
+	Calls                   int       `json:"calls"`
+	LastPing                time.Time `json:"last_ping,omitempty"`
+	LastError               string    `json:"last_error,omitempty"`
+}
+
+// HealthProber checks whether a provider's API is reachable
+type HealthProber interface {
+	Ping(ctx context.Context, provider ProviderConfig) error
+}
+
+// HTTPHealthProber pings a provider's models endpoint. Any response below 500
+// counts as reachable: auth errors still mean the API is up.
+type HTTPHealthProber struct {
+	client *http.Client
+}
+
+func NewHTTPHealthProber(timeout time.Duration) *HTTPHealthProber {
+	return &HTTPHealthProber{client: &http.Client{Timeout: timeout}}
+}
+
+// Ping implements HealthProber
+func (p *HTTPHealthProber) Ping(ctx context.Context, provider ProviderConfig) error {
+	url, _ := provider.Config["health_url"].(string)
+	if url == "" {
+		url = defaultHealthURLs[provider.ProviderName]
+	}
+	if url == "" {
+		return nil // Nothing to ping; call outcomes alone decide health
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create health request: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// providerHealthState tracks pings and recent call outcomes of one provider/model
+type providerHealthState struct {
+	health   ProviderHealth
+	outcomes []bool // Ring of recent calls; true when the call failed
+	next     int
+}
+
+// HealthChecker tracks provider health from periodic pings and the error
+// rate of recent calls. Providers it has never seen are healthy.
+type HealthChecker struct {
+	prober HealthProber
+
+	mu     sync.RWMutex
+	states map[string]*providerHealthState
+}
+
+func NewHealthChecker(prober HealthProber) *HealthChecker {
+	return &HealthChecker{prober: prober, states: make(map[string]*providerHealthState)}
+}
+
+// IsHealthy reports whether calls should be routed to a provider/model
+func (hc *HealthChecker) IsHealthy(providerName, modelName string) bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	state, ok := hc.states[providerName+":"+modelName]
+	return !ok || state.health.Healthy
+}
+
+// Health returns the health of every provider/model seen so far
+func (hc *HealthChecker) Health() []ProviderHealth {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	health := make([]ProviderHealth, 0, len(hc.states))
+	for _, state := range hc.states {
+		health = append(health, state.health)
+	}
+	sort.Slice(health, func(i, j int) bool {
+		if health[i].ProviderName != health[j].ProviderName {
+			return health[i].ProviderName < health[j].ProviderName
+		}
+		return health[i].ModelName < health[j].ModelName
+	})
+	return health
+}
+
+// ObserveCall records a call outcome; only server errors and timeouts count
+// against a provider's health
+func (hc *HealthChecker) ObserveCall(providerName, modelName string, errorClass ErrorClass) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	state := hc.state(providerName, modelName)
+
+	failed := errorClass == ErrorClassServer || errorClass == ErrorClassTimeout
+	if len(state.outcomes) < healthWindowSize {
+		state.outcomes = append(state.outcomes, failed)
+	} else {
+		state.outcomes[state.next] = failed
+		state.next = (state.next + 1) % healthWindowSize
+	}
+	if failed {
+		state.health.LastError = string(errorClass)
+	}
+	hc.evaluate(state)
+}
+
+// Check pings every provider once and updates their health
+func (hc *HealthChecker) Check(ctx context.Context, providers []ProviderConfig) {
+	for _, provider := range providers {
+		err := hc.prober.Ping(ctx, provider)
+
+		hc.mu.Lock()
+		state := hc.state(provider.ProviderName, provider.ModelName)
+		state.health.LastPing = time.Now()
+		if err != nil {
+			state.health.ConsecutivePingFailures++
+			state.health.LastError = err.Error()
+		} else {
+			state.health.ConsecutivePingFailures = 0
+		}
+		wasHealthy := state.health.Healthy
+		hc.evaluate(state)
+		if wasHealthy != state.health.Healthy {
+			log.Printf("Provider %s/%s is now %s", provider.ProviderName, provider.ModelName, healthLabel(state.health))
+		}
+		hc.mu.Unlock()
+	}
+}
+
+// Run pings the providers returned by list on an interval until ctx is done
+func (hc *HealthChecker) Run(ctx context.Context, interval time.Duration, list func(context.Context) ([]ProviderConfig, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			providers, err := list(ctx)
+			if err != nil {
+				log.Printf("Failed to list providers for health checks: %v", err)
+				continue
+			}
+			hc.Check(ctx, providers)
+		}
+	}
+}
+
+func (hc *HealthChecker) state(providerName, modelName string) *providerHealthState {
+	key := providerName + ":" + modelName
+	state, ok := hc.states[key]
+	if !ok {
+		state = &providerHealthState{health: ProviderHealth{ProviderName: providerName, ModelName: modelName, Healthy: true}}
+		hc.states[key] = state
+	}
+	return state
+}
+
+// evaluate recomputes health from ping failures and the call error rate
+func (hc *HealthChecker) evaluate(state *providerHealthState) {
+	failures := 0
+	for _, failed := range state.outcomes {
+		if failed {
+			failures++
+		}
+	}
+	health := &state.health
+	health.Calls = len(state.outcomes)
+	health.ErrorRate = 0
+	if health.Calls > 0 {
+		health.ErrorRate = float64(failures) / float64(health.Calls)
+	}
+
+	switch {
+	case health.ConsecutivePingFailures >= unhealthyPingFailures:
+		health.Healthy = false
+		health.Reason = fmt.Sprintf("%d consecutive health checks failed", health.ConsecutivePingFailures)
+	case health.Calls >= healthMinCalls && health.ErrorRate >= unhealthyErrorRate:
+		health.Healthy = false
+		health.Reason = fmt.Sprintf("%.0f%% of the last %d calls failed", health.ErrorRate*100, health.Calls)
+	default:
+		health.Healthy = true
+		health.Reason = ""
+	}
+
+	healthy := 0.0
+	if health.Healthy {
+		healthy = 1
+	}
+	providerHealthy.Set(healthy, health.ProviderName, health.ModelName)
+}
+
+func healthLabel(health ProviderHealth) string {
+	if health.Healthy {
+		return "healthy"
+	}
+	return "unhealthy: " + health.Reason
+}
+
+// failoverAlternatives is how many next-best providers a pinned call may fail over to
+const failoverAlternatives = 3
+
+// FailoverRoute routes a call pinned to a provider/model, such as an LLM
+// step's configured model, ranking the org's other healthy providers for the
+// request's quality tier as its failover alternatives. Pinned providers and
+// alternatives outside the request's residency constraint are rejected.
+func (pr *ProviderRouter) FailoverRoute(ctx context.Context, req *RoutingRequest, providerName, modelName string) (*RoutingResponse, error) {
+	if err := pr.CheckResidency(ctx, req, providerName, modelName); err != nil {
+		return nil, err
+	}
+	providers, err := pr.GetAvailableProviders(ctx, req.OrgID, req.QualityTier)
+	if err != nil {
+		return nil, err
+	}
+	providers, _ = FilterByResidency(providers, req.Residency)
+	return pr.rankFailover(ctx, req, providers, providerName, modelName), nil
+}
+
+// CheckResidency rejects a provider/model hosted outside the request's
+// residency constraint
+func (pr *ProviderRouter) CheckResidency(ctx context.Context, req *RoutingRequest, providerName, modelName string) error {
+	if req.Residency == nil {
+		return nil
+	}
+	providers, err := pr.GetAllProviders(ctx, req.OrgID)
+	if err != nil {
+		return err
+	}
+	return checkPinnedResidency(providers, req.Residency, providerName, modelName)
+}
+
+func (pr *ProviderRouter) rankFailover(ctx context.Context, req *RoutingRequest, providers []ProviderConfig, providerName, modelName string) *RoutingResponse {
+	scored := make([]ScoredProvider, 0, len(providers))
+	for _, provider := range providers {
+		if provider.ProviderName == providerName && provider.ModelName == modelName {
+			continue
+		}
+		if !pr.health.IsHealthy(provider.ProviderName, provider.ModelName) {
+			continue
+		}
+		// Pinned calls were admitted against the budget already, so cost doesn't rank alternatives
+		score, reason := pr.scoreProvider(ctx, provider, req, &BudgetStatus{})
+		scored = append(scored, ScoredProvider{Provider: provider, Score: score, Reason: reason})
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+
+	response := &RoutingResponse{ProviderName: providerName, ModelName: modelName, Reason: "pinned by caller"}
+	for i := 0; i < len(scored) && i < failoverAlternatives; i++ {
+		response.Alternatives = append(response.Alternatives, Alternative{
+			ProviderName:     scored[i].Provider.ProviderName,
+			ModelName:        scored[i].Provider.ModelName,
+			EstimatedCost:    pr.estimateCost(scored[i].Provider, req.PromptTokens, req.MaxTokens),
+			EstimatedLatency: pr.estimateLatency(ctx, scored[i].Provider),
+			QualityScore:     pr.getQualityScore(scored[i].Provider, req.QualityTier),
+			Reason:           scored[i].Reason,
+		})
+	}
+	return response
+}
+
+// ProviderCall makes one call to a provider/model chosen by the router
+type ProviderCall func(ctx context.Context, providerName, modelName string) error
+
+// FailoverAttempt records one provider tried for a request
+type FailoverAttempt struct {
+	ProviderName string     `json:"provider_name"`
+	ModelName    string     `json:"model_name"`
+	ErrorClass   ErrorClass `json:"error_class"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// CallWithFailover calls the routed provider and, when it fails with a server
+// error or timeout, retries against the next-best healthy alternative. Other
+// errors would fail the same way anywhere, so they are returned as is. Each
+// attempt's outcome updates provider health.
+func (pr *ProviderRouter) CallWithFailover(ctx context.Context, response *RoutingResponse, call ProviderCall) ([]FailoverAttempt, error) {
+	candidates := []Alternative{{ProviderName: response.ProviderName, ModelName: response.ModelName}}
+	candidates = append(candidates, response.Alternatives...)
+
+	attempts := make([]FailoverAttempt, 0, len(candidates))
+	var lastErr error
+	for i, candidate := range candidates {
+		if i > 0 && !pr.health.IsHealthy(candidate.ProviderName, candidate.ModelName) {
+			continue
+		}
+		// The routed provider took its rate limit token when the request was routed
+		var err error
+		if i > 0 {
+			err = pr.limiter.Wait(ctx, candidate.ProviderName, candidate.ModelName)
+		}
+		if err == nil {
+			err = call(ctx, candidate.ProviderName, candidate.ModelName)
+		}
+		class := ClassifyError(err)
+		if class != ErrorClassRateLimit {
+			pr.health.ObserveCall(candidate.ProviderName, candidate.ModelName, class)
+		}
+
+		attempt := FailoverAttempt{ProviderName: candidate.ProviderName, ModelName: candidate.ModelName, ErrorClass: class}
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		attempts = append(attempts, attempt)
+		if err == nil {
+			if i > 0 {
+				providerFailovers.Inc(response.ProviderName, candidate.ProviderName)
+			}
+			return attempts, nil
+		}
+		lastErr = err
+		var limited *RateLimitError
+		if errors.As(err, &limited) {
+			continue // Try the next alternative rather than queue longer
+		}
+		if class != ErrorClassServer && class != ErrorClassTimeout {
+			return attempts, err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return attempts, fmt.Errorf("all %d providers failed: %w", len(attempts), lastErr)
+}
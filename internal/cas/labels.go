@@ -0,0 +1,62 @@
+package cas
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxCostLabels bounds how many cost allocation labels a run may carry
+const maxCostLabels = 16
+
+// maxCostLabelValueLen bounds label values so they stay usable as group-by keys
+const maxCostLabelValueLen = 256
+
+// Label keys are lowercase so team=search and Team=search are not split in reports
+var costLabelKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,62}$`)
+
+// ValidateCostLabels checks cost allocation labels such as team, feature or
+// customer_id supplied at run creation
+func ValidateCostLabels(labels map[string]string) error {
+	if len(labels) > maxCostLabels {
+		return fmt.Errorf("at most %d cost labels are allowed, got %d", maxCostLabels, len(labels))
+	}
+	for key, value := range labels {
+		if !costLabelKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid cost label key %q: use lowercase letters, digits, '_', '.' or '-'", key)
+		}
+		if value == "" {
+			return fmt.Errorf("cost label %s has an empty value", key)
+		}
+		if len(value) > maxCostLabelValueLen {
+			return fmt.Errorf("cost label %s is longer than %d characters", key, maxCostLabelValueLen)
+		}
+	}
+	return nil
+}
+
+// ParseCostLabelSelector parses a budget's label selector: key=value matches
+// runs with that label value and a bare key matches any run carrying the label
+func ParseCostLabelSelector(selector string) (string, string, error) {
+	key, value, _ := strings.Cut(selector, "=")
+	if !costLabelKeyPattern.MatchString(key) {
+		return "", "", fmt.Errorf("invalid cost label key %q", key)
+	}
+	return key, value, nil
+}
+
+// MatchesLabels reports whether a label-scoped budget applies to a run's labels
+func (b *Budget) MatchesLabels(labels map[string]string) bool {
+	if b.Label == nil || *b.Label == "" {
+		return false
+	}
+	key, value, err := ParseCostLabelSelector(*b.Label)
+	if err != nil {
+		return false
+	}
+	actual, ok := labels[key]
+	if !ok {
+		return false
+	}
+	return value == "" || actual == value
+}
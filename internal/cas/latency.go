@@ -0,0 +1,173 @@
+package cas
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyPrecision is the relative error of recorded latencies (2%)
+	latencyPrecision = 0.02
+	// latencyMaxValue bounds tracked latencies; slower calls land in the last bucket
+	latencyMaxValue = 10 * time.Minute
+)
+
+var (
+	latencyLogBase    = math.Log1p(latencyPrecision)
+	latencyBucketSize = int(math.Ceil(math.Log(float64(latencyMaxValue.Microseconds()))/latencyLogBase)) + 1
+)
+
+// LatencyPercentiles summarizes a latency distribution
+type LatencyPercentiles struct {
+	Count uint64        `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// latencyHistogram is a log-bucketed histogram in the style of HDR histograms:
+// fixed memory, constant-time recording and bounded relative error
+type latencyHistogram struct {
+	counts []uint64
+	total  uint64
+	sum    time.Duration
+	max    time.Duration
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]uint64, latencyBucketSize)}
+}
+
+func (h *latencyHistogram) record(latency time.Duration) {
+	if latency < 0 {
+		latency = 0
+	}
+	h.counts[latencyBucket(latency)]++
+	h.total++
+	h.sum += latency
+	if latency > h.max {
+		h.max = latency
+	}
+}
+
+// quantile returns the representative latency of the bucket holding the q-th sample
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(h.total)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			value := latencyBucketValue(i)
+			if value > h.max {
+				value = h.max
+			}
+			return value
+		}
+	}
+	return h.max
+}
+
+func (h *latencyHistogram) percentiles() LatencyPercentiles {
+	p := LatencyPercentiles{Count: h.total, Max: h.max}
+	if h.total == 0 {
+		return p
+	}
+	p.Mean = h.sum / time.Duration(h.total)
+	p.P50 = h.quantile(0.50)
+	p.P95 = h.quantile(0.95)
+	p.P99 = h.quantile(0.99)
+	return p
+}
+
+func latencyBucket(latency time.Duration) int {
+	us := latency.Microseconds()
+	if us < 1 {
+		return 0
+	}
+	idx := int(math.Log(float64(us))/latencyLogBase) + 1
+	if idx >= latencyBucketSize {
+		return latencyBucketSize - 1
+	}
+	return idx
+}
+
+// latencyBucketValue is the midpoint of a bucket's range
+func latencyBucketValue(idx int) time.Duration {
+	if idx == 0 {
+		return 0
+	}
+	lower := math.Exp(float64(idx-1) * latencyLogBase)
+	upper := math.Exp(float64(idx) * latencyLogBase)
+	return time.Duration((lower + upper) / 2 * float64(time.Microsecond))
+}
+
+// LatencyTracker keeps a latency histogram per provider/model and is safe
+// for concurrent use
+type LatencyTracker struct {
+	mu     sync.RWMutex
+	series map[string]*latencyHistogram
+}
+
+func NewLatencyTracker() *LatencyTracker {
+	return &LatencyTracker{series: make(map[string]*latencyHistogram)}
+}
+
+// Record adds a latency sample for a provider/model
+func (lt *LatencyTracker) Record(providerName, modelName string, latency time.Duration) LatencyPercentiles {
+	key := providerName + ":" + modelName
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	h, ok := lt.series[key]
+	if !ok {
+		h = newLatencyHistogram()
+		lt.series[key] = h
+	}
+	h.record(latency)
+
+	return h.percentiles()
+}
+
+// Percentiles returns the current distribution for a provider/model
+func (lt *LatencyTracker) Percentiles(providerName, modelName string) LatencyPercentiles {
+	lt.mu.RLock()
+	defer lt.mu.RUnlock()
+
+	if h, ok := lt.series[providerName+":"+modelName]; ok {
+		return h.percentiles()
+	}
+	return LatencyPercentiles{}
+}
+
+// exactPercentiles computes percentiles over a small sample by sorting
+func exactPercentiles(latencies []time.Duration) (p50, p95, p99 time.Duration) {
+	if len(latencies) == 0 {
+		return 0, 0, 0
+	}
+
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	at := func(q float64) time.Duration {
+		idx := int(math.Ceil(q*float64(len(sorted)))) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		return sorted[idx]
+	}
+
+	return at(0.50), at(0.95), at(0.99)
+}
@@ -0,0 +1,19 @@
+package cas
+
+import "github.com/Siddhant-K-code/agentflow-infrastructure/internal/metrics"
+
+// Registry holds provider metrics served at /metrics alongside orchestration metrics
+var Registry = metrics.NewRegistry()
+
+var (
+	providerLatency = Registry.NewGauge("agentflow_provider_latency_seconds",
+		"Provider call latency percentiles observed by this process", "provider", "model", "quantile")
+	providerRequests = Registry.NewCounter("agentflow_provider_requests_total",
+		"Provider calls by outcome error class", "provider", "model", "error_class")
+	providerHealthy = Registry.NewGauge("agentflow_provider_healthy",
+		"1 when a provider/model is healthy and receives routed calls", "provider", "model")
+	providerFailovers = Registry.NewCounter("agentflow_provider_failovers_total",
+		"Calls retried on another provider after a server error or timeout", "from_provider", "to_provider")
+	providerRateLimited = Registry.NewCounter("agentflow_provider_rate_limited_total",
+		"Requests over a provider's QPS limit by outcome (queued, rejected)", "provider", "model", "outcome")
+)
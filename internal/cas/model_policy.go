@@ -0,0 +1,207 @@
+package cas
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+const constraintModelPolicy = "model_policy"
+
+// ModelPolicy restricts which providers and models an org may ever use.
+// Entries are "provider" or "provider/model" and may contain glob patterns,
+// e.g. "anthropic/*" or "openai/gpt-4*". Deny entries take precedence; a
+// non-empty allow list permits only matching models.
+type ModelPolicy struct {
+	OrgID     uuid.UUID `json:"org_id" db:"org_id"`
+	Allow     []string  `json:"allow,omitempty" db:"allow"`
+	Deny      []string  `json:"deny,omitempty" db:"deny"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ModelPolicyViolation identifies the policy entry that rejected a model
+type ModelPolicyViolation struct {
+	ProviderName string `json:"provider_name"`
+	ModelName    string `json:"model_name"`
+	Rule         string `json:"rule"`
+	Reason       string `json:"reason"`
+}
+
+func (v *ModelPolicyViolation) Error() string {
+	return fmt.Sprintf("model %s/%s is not permitted by org model policy: %s", v.ProviderName, v.ModelName, v.Reason)
+}
+
+// Unsent implements UnsentError
+func (v *ModelPolicyViolation) Unsent() {}
+
+// Validate rejects malformed policy entries
+func (mp *ModelPolicy) Validate() error {
+	for _, entry := range append(append([]string{}, mp.Allow...), mp.Deny...) {
+		if strings.TrimSpace(entry) == "" {
+			return fmt.Errorf("empty model policy entry")
+		}
+		if _, err := path.Match(strings.ToLower(entry), ""); err != nil {
+			return fmt.Errorf("invalid model policy entry %q: %w", entry, err)
+		}
+	}
+	return nil
+}
+
+// Check returns a violation if the policy does not permit the model
+func (mp *ModelPolicy) Check(providerName, modelName string) *ModelPolicyViolation {
+	if mp == nil {
+		return nil
+	}
+
+	for _, entry := range mp.Deny {
+		if modelPolicyMatch(entry, providerName, modelName) {
+			return &ModelPolicyViolation{
+				ProviderName: providerName,
+				ModelName:    modelName,
+				Rule:         "deny:" + entry,
+				Reason:       fmt.Sprintf("denied by deny list entry %q", entry),
+			}
+		}
+	}
+
+	if len(mp.Allow) == 0 {
+		return nil
+	}
+	for _, entry := range mp.Allow {
+		if modelPolicyMatch(entry, providerName, modelName) {
+			return nil
+		}
+	}
+
+	return &ModelPolicyViolation{
+		ProviderName: providerName,
+		ModelName:    modelName,
+		Rule:         "allow",
+		Reason:       fmt.Sprintf("not in allow list: %s", strings.Join(mp.Allow, ", ")),
+	}
+}
+
+// CheckModel returns the violation as an error, or nil if permitted
+func (mp *ModelPolicy) CheckModel(providerName, modelName string) error {
+	if v := mp.Check(providerName, modelName); v != nil {
+		return v
+	}
+	return nil
+}
+
+// FilterByModelPolicy splits providers into those the policy permits and
+// denials for the rest
+func FilterByModelPolicy(providers []ProviderConfig, policy *ModelPolicy) ([]ProviderConfig, []ProviderDenial) {
+	allowed := make([]ProviderConfig, 0, len(providers))
+	denials := make([]ProviderDenial, 0)
+
+	for _, provider := range providers {
+		if v := policy.Check(provider.ProviderName, provider.ModelName); v != nil {
+			denials = append(denials, ProviderDenial{
+				ProviderName: provider.ProviderName,
+				ModelName:    provider.ModelName,
+				Constraint:   constraintModelPolicy,
+				Reason:       v.Reason,
+			})
+			continue
+		}
+		allowed = append(allowed, provider)
+	}
+
+	return allowed, denials
+}
+
+// modelPolicyMatch matches "provider" or "provider/model" globs case-insensitively
+func modelPolicyMatch(entry, providerName, modelName string) bool {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	providerPattern, modelPattern, hasModel := strings.Cut(entry, "/")
+
+	if ok, _ := path.Match(providerPattern, strings.ToLower(providerName)); !ok {
+		return false
+	}
+	if !hasModel {
+		return true
+	}
+	ok, _ := path.Match(modelPattern, strings.ToLower(modelName))
+	return ok
+}
+
+// ModelPolicyStore persists org model policies
+type ModelPolicyStore struct {
+	postgres *db.PostgresDB
+}
+
+func NewModelPolicyStore(pg *db.PostgresDB) *ModelPolicyStore {
+	return &ModelPolicyStore{postgres: pg}
+}
+
+// Get returns an org's model policy, or nil if none is configured
+func (ms *ModelPolicyStore) Get(ctx context.Context, orgID uuid.UUID) (*ModelPolicy, error) {
+	if ms == nil || ms.postgres == nil {
+		return nil, nil
+	}
+
+	query := `SELECT org_id, allow, deny, updated_at FROM org_model_policy WHERE org_id = $1`
+
+	var policy ModelPolicy
+	var allowJSON, denyJSON []byte
+	err := ms.postgres.QueryRowContext(ctx, query, orgID).Scan(&policy.OrgID, &allowJSON, &denyJSON, &policy.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get model policy: %w", err)
+	}
+
+	if err := json.Unmarshal(allowJSON, &policy.Allow); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal allow list: %w", err)
+	}
+	if err := json.Unmarshal(denyJSON, &policy.Deny); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal deny list: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// Set replaces an org's model policy
+func (ms *ModelPolicyStore) Set(ctx context.Context, policy *ModelPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	allowJSON, err := json.Marshal(nonNilStrings(policy.Allow))
+	if err != nil {
+		return fmt.Errorf("failed to marshal allow list: %w", err)
+	}
+	denyJSON, err := json.Marshal(nonNilStrings(policy.Deny))
+	if err != nil {
+		return fmt.Errorf("failed to marshal deny list: %w", err)
+	}
+	policy.UpdatedAt = time.Now()
+
+	query := `INSERT INTO org_model_policy (org_id, allow, deny, updated_at)
+			  VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (org_id) DO UPDATE SET
+			  allow = EXCLUDED.allow, deny = EXCLUDED.deny, updated_at = EXCLUDED.updated_at`
+
+	if _, err := ms.postgres.ExecContext(ctx, query, policy.OrgID, allowJSON, denyJSON, policy.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save model policy: %w", err)
+	}
+
+	return nil
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
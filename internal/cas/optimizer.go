@@ -260,15 +260,7 @@ func (o *Optimizer) analyzeSchedulingOptimization(ctx context.Context, orgID uui
 // Helper methods for analysis
 
 func (o *Optimizer) getCacheStats(ctx context.Context, orgID uuid.UUID) (*CacheStats, error) {
-	// Mock cache stats - in production would get from CacheManager
-	return &CacheStats{
-		OrgID:   orgID,
-		Hits:    1000,
-		Misses:  3000,
-		Puts:    1200,
-		HitRate: 0.25,
-		Size:    1200,
-	}, nil
+	return NewCacheManager(o.redis).GetStats(ctx, orgID)
 }
 
 func (o *Optimizer) analyzeDuplicateRequests(ctx context.Context, orgID uuid.UUID, timeRange time.Duration) float64 {
@@ -0,0 +1,92 @@
+package cas
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PromptCachePricing is how a provider bills prompt tokens written to and
+// read from its prompt cache, as multiples of its normal prompt price
+type PromptCachePricing struct {
+	WriteMultiplier float64 `json:"write_multiplier"`
+	ReadMultiplier  float64 `json:"read_multiplier"`
+}
+
+// promptCachePricing holds published cache pricing for providers that cache prompt prefixes
+var promptCachePricing = map[string]PromptCachePricing{
+	"anthropic": {WriteMultiplier: 1.25, ReadMultiplier: 0.1},
+	"openai":    {WriteMultiplier: 1, ReadMultiplier: 0.5},
+	"google":    {WriteMultiplier: 1, ReadMultiplier: 0.25},
+	"gemini":    {WriteMultiplier: 1, ReadMultiplier: 0.25},
+}
+
+// ProviderPromptCachePricing returns a provider's cache pricing, or false if it has no prompt cache
+func ProviderPromptCachePricing(provider string) (PromptCachePricing, bool) {
+	pricing, ok := promptCachePricing[strings.ToLower(provider)]
+	return pricing, ok
+}
+
+// PromptCacheUsage is the part of a call's prompt tokens served from or written to the provider's cache
+type PromptCacheUsage struct {
+	ReadTokens  int `json:"cache_read_tokens"`
+	WriteTokens int `json:"cache_write_tokens"`
+}
+
+// PricePromptTokens prices a call's prompt tokens at centsPerToken, billing
+// cached tokens at the provider's cache rates. It returns the cost and the
+// saving against sending the whole prompt uncached; cache writes can make the
+// saving negative.
+func PricePromptTokens(provider string, promptTokens int, usage PromptCacheUsage, centsPerToken float64) (float64, float64) {
+	uncached := float64(promptTokens) * centsPerToken
+	pricing, ok := ProviderPromptCachePricing(provider)
+	if !ok {
+		return uncached, 0
+	}
+
+	regular := promptTokens - usage.ReadTokens - usage.WriteTokens
+	if regular < 0 {
+		regular = 0
+	}
+	cost := centsPerToken * (float64(regular) +
+		float64(usage.ReadTokens)*pricing.ReadMultiplier +
+		float64(usage.WriteTokens)*pricing.WriteMultiplier)
+	return cost, uncached - cost
+}
+
+// PromptCacheSavings summarizes how much provider prompt caching saved an org
+type PromptCacheSavings struct {
+	Calls        int64     `json:"calls"`
+	CachedCalls  int64     `json:"cached_calls"` // Calls that read part of their prompt from the cache
+	PromptTokens int64     `json:"prompt_tokens"`
+	ReadTokens   int64     `json:"cache_read_tokens"`
+	WriteTokens  int64     `json:"cache_write_tokens"`
+	ReadShare    float64   `json:"read_share"` // Share of prompt tokens served from the cache
+	SavingCents  int64     `json:"saving_cents"`
+	Since        time.Time `json:"since"`
+}
+
+// promptCacheWindow is how far back the optimization report looks for cache savings
+const promptCacheWindow = 30 * 24 * time.Hour
+
+// PromptCacheSavings totals the realized prompt cache savings recorded in provider telemetry since a time
+func (o *Optimizer) PromptCacheSavings(ctx context.Context, orgID uuid.UUID, since time.Time) (*PromptCacheSavings, error) {
+	query := `SELECT COUNT(*), COUNT(*) FILTER (WHERE cache_read_tokens > 0),
+			  COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(cache_read_tokens), 0),
+			  COALESCE(SUM(cache_write_tokens), 0), COALESCE(SUM(cache_saving_cents), 0)
+			  FROM provider_telemetry WHERE org_id = $1 AND recorded_at >= $2 AND error_class = 'none'`
+
+	savings := &PromptCacheSavings{Since: since}
+	err := o.postgres.QueryRowContext(ctx, query, orgID, since).Scan(&savings.Calls, &savings.CachedCalls,
+		&savings.PromptTokens, &savings.ReadTokens, &savings.WriteTokens, &savings.SavingCents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prompt cache savings: %w", err)
+	}
+	if savings.PromptTokens > 0 {
+		savings.ReadShare = float64(savings.ReadTokens) / float64(savings.PromptTokens)
+	}
+	return savings, nil
+}
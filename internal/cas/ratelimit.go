@@ -0,0 +1,185 @@
+package cas
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/metrics"
+)
+
+// defaultRateLimitWait is how long a request may queue for a provider token
+// before it is rejected
+const defaultRateLimitWait = 2 * time.Second
+
+// RateLimitError is returned when a provider's QPS limit leaves no token
+// within the allowed wait
+type RateLimitError struct {
+	ProviderName string
+	ModelName    string
+	LimitQPS     int
+	Wait         time.Duration // Time until a token would have been available
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit of %d QPS for %s/%s exceeded, next token in %s",
+		e.LimitQPS, e.ProviderName, e.ModelName, e.Wait.Round(time.Millisecond))
+}
+
+// ErrorClass implements ClassifiedError
+func (e *RateLimitError) ErrorClass() ErrorClass {
+	return ErrorClassRateLimit
+}
+
+// tokenBucket refills at rate tokens per second up to burst. Tokens go
+// negative while requests queue for future tokens.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = minFloat(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it, or
+// false without taking one when the wait would exceed maxWait
+func (b *tokenBucket) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	b.refill(now)
+	wait := time.Duration(0)
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// utilization is the share of the burst in use; above 1 when requests queue
+func (b *tokenBucket) utilization(now time.Time) float64 {
+	b.refill(now)
+	return (b.burst - b.tokens) / b.burst
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// RateLimiter enforces each provider/model's QPSLimit with a token bucket.
+// Requests over the limit queue for up to maxWait and are rejected after.
+type RateLimiter struct {
+	maxWait time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func NewRateLimiter(maxWait time.Duration) *RateLimiter {
+	return &RateLimiter{maxWait: maxWait, buckets: make(map[string]*tokenBucket)}
+}
+
+// providerRateLimiter is shared by every router in the process, since
+// provider QPS limits apply to the process's traffic as a whole
+var providerRateLimiter = NewRateLimiter(defaultRateLimitWait)
+
+func init() {
+	Registry.NewGaugeFunc("agentflow_provider_rate_limit_utilization",
+		"Share of each provider's QPS burst in use; above 1 while requests queue", providerRateLimiter.collectUtilization,
+		"provider", "model")
+}
+
+// Configure sets a provider/model's bucket from its QPSLimit; limits of zero
+// or less leave it unlimited
+func (rl *RateLimiter) Configure(provider ProviderConfig) {
+	key := provider.ProviderName + ":" + provider.ModelName
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if provider.QPSLimit <= 0 {
+		delete(rl.buckets, key)
+		return
+	}
+	rate := float64(provider.QPSLimit)
+	if bucket, ok := rl.buckets[key]; ok {
+		bucket.rate, bucket.burst = rate, rate
+		bucket.tokens = minFloat(bucket.tokens, rate)
+		return
+	}
+	rl.buckets[key] = &tokenBucket{rate: rate, burst: rate, tokens: rate, last: time.Now()}
+}
+
+// Wait blocks until the provider/model has a token, or returns a
+// RateLimitError when none frees up within the limiter's maximum wait
+func (rl *RateLimiter) Wait(ctx context.Context, providerName, modelName string) error {
+	rl.mu.Lock()
+	bucket, ok := rl.buckets[providerName+":"+modelName]
+	if !ok {
+		rl.mu.Unlock()
+		return nil
+	}
+	wait, reserved := bucket.reserve(time.Now(), rl.maxWait)
+	limit := int(bucket.rate)
+	rl.mu.Unlock()
+
+	if !reserved {
+		providerRateLimited.Inc(providerName, modelName, "rejected")
+		return &RateLimitError{ProviderName: providerName, ModelName: modelName, LimitQPS: limit, Wait: wait}
+	}
+	if wait == 0 {
+		return nil
+	}
+
+	providerRateLimited.Inc(providerName, modelName, "queued")
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		rl.mu.Lock()
+		bucket.tokens++ // Give back the unused token
+		rl.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Utilization returns the share of each limited provider/model's burst in use
+func (rl *RateLimiter) Utilization() map[string]float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	utilization := make(map[string]float64, len(rl.buckets))
+	for key, bucket := range rl.buckets {
+		utilization[key] = bucket.utilization(now)
+	}
+	return utilization
+}
+
+func (rl *RateLimiter) collectUtilization() []metrics.Sample {
+	utilization := rl.Utilization()
+	keys := make([]string, 0, len(utilization))
+	for key := range utilization {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	samples := make([]metrics.Sample, 0, len(keys))
+	for _, key := range keys {
+		providerName, modelName, _ := strings.Cut(key, ":")
+		samples = append(samples, metrics.Sample{LabelValues: []string{providerName, modelName}, Value: utilization[key]})
+	}
+	return samples
+}
@@ -0,0 +1,267 @@
+package cas
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BaselineWindow is the spend window measured when a suggestion is accepted
+const BaselineWindow = 7 * 24 * time.Hour
+
+// SaveSuggestions persists generated suggestions. A suggestion already seen for
+// the org (same fingerprint) is refreshed while open and otherwise left alone,
+// so dismissed suggestions do not resurface.
+func (o *Optimizer) SaveSuggestions(ctx context.Context, orgID uuid.UUID, suggestions []OptimizationSuggestion) error {
+	query := `INSERT INTO optimization_suggestion (id, org_id, fingerprint, type, title, description,
+			  potential_saving_cents, confidence, impact, actions, metadata, status, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			  ON CONFLICT (org_id, fingerprint) DO UPDATE SET
+			  potential_saving_cents = EXCLUDED.potential_saving_cents,
+			  confidence = EXCLUDED.confidence,
+			  description = EXCLUDED.description
+			  WHERE optimization_suggestion.status = 'open'`
+
+	for _, suggestion := range suggestions {
+		actionsJSON, err := json.Marshal(suggestion.Actions)
+		if err != nil {
+			return fmt.Errorf("failed to marshal actions: %w", err)
+		}
+		metadataJSON, err := json.Marshal(suggestion.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+
+		_, err = o.postgres.ExecContext(ctx, query,
+			uuid.New(), orgID, suggestion.Fingerprint(), suggestion.Type, suggestion.Title, suggestion.Description,
+			suggestion.PotentialSaving, suggestion.Confidence, suggestion.Impact, actionsJSON, metadataJSON,
+			SuggestionStatusOpen, time.Now(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save suggestion: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListSuggestions returns persisted suggestions, optionally filtered by status
+func (o *Optimizer) ListSuggestions(ctx context.Context, orgID uuid.UUID, status SuggestionStatus) ([]OptimizationSuggestion, error) {
+	query := `SELECT id, org_id, type, title, description, potential_saving_cents, confidence, impact,
+			  actions, metadata, status, COALESCE(dismiss_reason, ''), baseline_daily_cents,
+			  realized_saving_cents, created_at, decided_at
+			  FROM optimization_suggestion
+			  WHERE org_id = $1 AND ($2 = '' OR status = $2)
+			  ORDER BY potential_saving_cents DESC`
+
+	rows, err := o.postgres.QueryContext(ctx, query, orgID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query suggestions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	suggestions := make([]OptimizationSuggestion, 0)
+	for rows.Next() {
+		suggestion, err := scanSuggestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, *suggestion)
+	}
+
+	return suggestions, rows.Err()
+}
+
+// AcceptSuggestion marks a suggestion accepted and snapshots the org's recent
+// daily spend as the baseline realized savings are measured against
+func (o *Optimizer) AcceptSuggestion(ctx context.Context, orgID, suggestionID uuid.UUID) (*OptimizationSuggestion, error) {
+	now := time.Now()
+	spent, err := o.spend(ctx, orgID, now.Add(-BaselineWindow), now)
+	if err != nil {
+		return nil, err
+	}
+	baseline := AverageDailySpend(spent, BaselineWindow)
+
+	query := `UPDATE optimization_suggestion
+			  SET status = $3, baseline_daily_cents = $4, decided_at = $5
+			  WHERE org_id = $1 AND id = $2 AND status = 'open'`
+
+	return o.decide(ctx, orgID, suggestionID, query, SuggestionStatusAccepted, baseline, now)
+}
+
+// DismissSuggestion marks a suggestion dismissed with an optional reason
+func (o *Optimizer) DismissSuggestion(ctx context.Context, orgID, suggestionID uuid.UUID, reason string) (*OptimizationSuggestion, error) {
+	query := `UPDATE optimization_suggestion
+			  SET status = $3, dismiss_reason = $4, decided_at = $5
+			  WHERE org_id = $1 AND id = $2 AND status = 'open'`
+
+	return o.decide(ctx, orgID, suggestionID, query, SuggestionStatusDismissed, reason, time.Now())
+}
+
+// BuildOptimizationReport refreshes realized savings for accepted suggestions
+// and summarizes projected versus actual impact
+func (o *Optimizer) BuildOptimizationReport(ctx context.Context, orgID uuid.UUID) (*OptimizationReport, error) {
+	suggestions, err := o.ListSuggestions(ctx, orgID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range suggestions {
+		s := &suggestions[i]
+		if s.Status != SuggestionStatusAccepted || s.DecidedAt == nil {
+			continue
+		}
+
+		spent, err := o.spend(ctx, orgID, *s.DecidedAt, now)
+		if err != nil {
+			return nil, err
+		}
+		s.RealizedSaving = computeRealizedSaving(s.BaselineDailyCents, spent, now.Sub(*s.DecidedAt))
+
+		query := `UPDATE optimization_suggestion SET realized_saving_cents = $2 WHERE id = $1`
+		if _, err := o.postgres.ExecContext(ctx, query, s.ID, s.RealizedSaving); err != nil {
+			return nil, fmt.Errorf("failed to update realized saving: %w", err)
+		}
+	}
+
+	report := summarizeSuggestions(orgID, suggestions, now)
+	if report.PromptCache, err = o.PromptCacheSavings(ctx, orgID, now.Add(-promptCacheWindow)); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Fingerprint identifies a suggestion across generations by type and details
+func (s *OptimizationSuggestion) Fingerprint() string {
+	metadataJSON, _ := json.Marshal(s.Metadata)
+	sum := sha256.Sum256(append([]byte(string(s.Type)+"|"+s.Title+"|"), metadataJSON...))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (o *Optimizer) decide(ctx context.Context, orgID, suggestionID uuid.UUID, query string, status SuggestionStatus, value interface{}, decidedAt time.Time) (*OptimizationSuggestion, error) {
+	result, err := o.postgres.ExecContext(ctx, query, orgID, suggestionID, status, value, decidedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update suggestion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("suggestion %s not found or already decided", suggestionID)
+	}
+
+	return o.getSuggestion(ctx, orgID, suggestionID)
+}
+
+func (o *Optimizer) getSuggestion(ctx context.Context, orgID, suggestionID uuid.UUID) (*OptimizationSuggestion, error) {
+	query := `SELECT id, org_id, type, title, description, potential_saving_cents, confidence, impact,
+			  actions, metadata, status, COALESCE(dismiss_reason, ''), baseline_daily_cents,
+			  realized_saving_cents, created_at, decided_at
+			  FROM optimization_suggestion WHERE org_id = $1 AND id = $2`
+
+	suggestion, err := scanSuggestion(o.postgres.QueryRowContext(ctx, query, orgID, suggestionID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("suggestion %s not found", suggestionID)
+	}
+	return suggestion, err
+}
+
+// spend returns the org's provider spend in cents over a period
+func (o *Optimizer) spend(ctx context.Context, orgID uuid.UUID, from, to time.Time) (int64, error) {
+	query := `SELECT COALESCE(SUM(cost_cents), 0) FROM provider_telemetry
+			  WHERE org_id = $1 AND recorded_at >= $2 AND recorded_at < $3`
+
+	var total int64
+	if err := o.postgres.QueryRowContext(ctx, query, orgID, from, to).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to query spend: %w", err)
+	}
+	return total, nil
+}
+
+// AverageDailySpend spreads spend over a window of at least one day
+func AverageDailySpend(totalCents int64, window time.Duration) float64 {
+	days := window.Hours() / 24
+	if days < 1 {
+		days = 1
+	}
+	return float64(totalCents) / days
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSuggestion(row rowScanner) (*OptimizationSuggestion, error) {
+	var s OptimizationSuggestion
+	var actionsJSON, metadataJSON []byte
+	var decidedAt sql.NullTime
+
+	err := row.Scan(
+		&s.ID, &s.OrgID, &s.Type, &s.Title, &s.Description, &s.PotentialSaving, &s.Confidence, &s.Impact,
+		&actionsJSON, &metadataJSON, &s.Status, &s.DismissReason, &s.BaselineDailyCents,
+		&s.RealizedSaving, &s.CreatedAt, &decidedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(actionsJSON, &s.Actions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal actions: %w", err)
+	}
+	if err := json.Unmarshal(metadataJSON, &s.Metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+	if decidedAt.Valid {
+		s.DecidedAt = &decidedAt.Time
+	}
+
+	return &s, nil
+}
+
+// computeRealizedSaving estimates savings since acceptance as what the
+// baseline daily spend would have cost over the elapsed period, less what
+// was actually spent. Using the actual period keeps a few hours of spend
+// from being averaged over a whole day.
+func computeRealizedSaving(baselineDaily float64, spentCents int64, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	saving := baselineDaily*elapsed.Hours()/24 - float64(spentCents)
+	if saving <= 0 {
+		return 0
+	}
+	return int64(math.Round(saving))
+}
+
+func summarizeSuggestions(orgID uuid.UUID, suggestions []OptimizationSuggestion, now time.Time) *OptimizationReport {
+	report := &OptimizationReport{
+		OrgID:       orgID,
+		Suggestions: suggestions,
+		ByStatus:    make(map[SuggestionStatus]int),
+		GeneratedAt: now,
+	}
+
+	for _, s := range suggestions {
+		report.ByStatus[s.Status]++
+		switch s.Status {
+		case SuggestionStatusOpen:
+			report.OpenProjectedCents += s.PotentialSaving
+		case SuggestionStatusAccepted:
+			report.AcceptedProjectedCents += s.PotentialSaving
+			report.RealizedSavingCents += s.RealizedSaving
+		}
+	}
+
+	return report
+}
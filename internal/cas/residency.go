@@ -0,0 +1,165 @@
+package cas
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	constraintRegion        = "region"
+	constraintDataResidency = "data_residency"
+
+	residencyGlobal = "global"
+)
+
+// Check reports whether a provider satisfies the residency constraint and,
+// if not, which constraint eliminated it
+func (rc *ResidencyConstraint) Check(provider ProviderConfig) *ProviderDenial {
+	if rc == nil {
+		return nil
+	}
+
+	if rc.DataResidency != "" && !strings.EqualFold(rc.DataResidency, residencyGlobal) {
+		if !strings.EqualFold(provider.DataResidency, rc.DataResidency) {
+			residency := provider.DataResidency
+			if residency == "" {
+				residency = "unspecified"
+			}
+			return &ProviderDenial{
+				ProviderName: provider.ProviderName,
+				ModelName:    provider.ModelName,
+				Constraint:   constraintDataResidency,
+				Reason:       fmt.Sprintf("data residency %s does not satisfy required %s", residency, rc.DataResidency),
+			}
+		}
+	}
+
+	if len(rc.AllowedRegions) > 0 {
+		if provider.Region == "" {
+			return &ProviderDenial{
+				ProviderName: provider.ProviderName,
+				ModelName:    provider.ModelName,
+				Constraint:   constraintRegion,
+				Reason:       fmt.Sprintf("hosting region unspecified, allowed regions: %s", strings.Join(rc.AllowedRegions, ", ")),
+			}
+		}
+
+		if !regionAllowed(provider.Region, rc.AllowedRegions) {
+			return &ProviderDenial{
+				ProviderName: provider.ProviderName,
+				ModelName:    provider.ModelName,
+				Constraint:   constraintRegion,
+				Reason:       fmt.Sprintf("hosting region %s not in allowed regions: %s", provider.Region, strings.Join(rc.AllowedRegions, ", ")),
+			}
+		}
+	}
+
+	return nil
+}
+
+// Narrow combines a workflow's constraint with a run's. The run may restrict
+// where its calls go but not loosen the workflow's constraint.
+func (rc *ResidencyConstraint) Narrow(run *ResidencyConstraint) (*ResidencyConstraint, error) {
+	if run == nil {
+		return rc, nil
+	}
+	if rc == nil {
+		return run, nil
+	}
+
+	narrowed := *rc
+	if run.DataResidency != "" && !strings.EqualFold(run.DataResidency, residencyGlobal) {
+		if rc.DataResidency != "" && !strings.EqualFold(rc.DataResidency, residencyGlobal) && !strings.EqualFold(rc.DataResidency, run.DataResidency) {
+			return nil, fmt.Errorf("data residency %s conflicts with the workflow's %s", run.DataResidency, rc.DataResidency)
+		}
+		narrowed.DataResidency = run.DataResidency
+	}
+
+	if len(run.AllowedRegions) > 0 {
+		if len(rc.AllowedRegions) > 0 {
+			for _, region := range run.AllowedRegions {
+				if !regionAllowed(region, rc.AllowedRegions) {
+					return nil, fmt.Errorf("region %s is outside the workflow's allowed regions: %s", region, strings.Join(rc.AllowedRegions, ", "))
+				}
+			}
+		}
+		narrowed.AllowedRegions = run.AllowedRegions
+	}
+	return &narrowed, nil
+}
+
+// ResidencyViolation rejects a call pinned to a provider hosted outside the
+// request's residency constraint
+type ResidencyViolation struct {
+	Denial ProviderDenial
+}
+
+func (v *ResidencyViolation) Error() string {
+	return fmt.Sprintf("provider %s/%s violates %s constraint: %s", v.Denial.ProviderName, v.Denial.ModelName, v.Denial.Constraint, v.Denial.Reason)
+}
+
+// ErrorClass implements ClassifiedError
+func (v *ResidencyViolation) ErrorClass() ErrorClass { return ErrorClassPolicyBlock }
+
+// Unsent implements UnsentError
+func (v *ResidencyViolation) Unsent() {}
+
+// checkPinnedResidency checks a provider/model a call is pinned to. Models
+// the org hasn't configured have no known hosting, so they only pass
+// unconstrained requests.
+func checkPinnedResidency(providers []ProviderConfig, constraint *ResidencyConstraint, providerName, modelName string) error {
+	pinned := ProviderConfig{ProviderName: providerName, ModelName: modelName}
+	for _, provider := range providers {
+		if provider.ProviderName == providerName && provider.ModelName == modelName {
+			pinned = provider
+			break
+		}
+	}
+	if denial := constraint.Check(pinned); denial != nil {
+		return &ResidencyViolation{Denial: *denial}
+	}
+	return nil
+}
+
+// FilterByResidency splits providers into those satisfying the residency
+// constraint and denials for the rest
+func FilterByResidency(providers []ProviderConfig, constraint *ResidencyConstraint) ([]ProviderConfig, []ProviderDenial) {
+	allowed := make([]ProviderConfig, 0, len(providers))
+	denials := make([]ProviderDenial, 0)
+
+	for _, provider := range providers {
+		if denial := constraint.Check(provider); denial != nil {
+			denials = append(denials, *denial)
+			continue
+		}
+		allowed = append(allowed, provider)
+	}
+
+	return allowed, denials
+}
+
+// FormatDenials renders denials as a human-readable explanation
+func FormatDenials(denials []ProviderDenial) string {
+	parts := make([]string, 0, len(denials))
+	for _, d := range denials {
+		parts = append(parts, fmt.Sprintf("%s/%s denied by %s constraint (%s)", d.ProviderName, d.ModelName, d.Constraint, d.Reason))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// regionAllowed matches a region against allowed entries; a trailing "*"
+// matches any region with that prefix (e.g. "eu-*")
+func regionAllowed(region string, allowedRegions []string) bool {
+	for _, allowed := range allowedRegions {
+		if strings.HasSuffix(allowed, "*") {
+			if strings.HasPrefix(strings.ToLower(region), strings.ToLower(strings.TrimSuffix(allowed, "*"))) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(region, allowed) {
+			return true
+		}
+	}
+	return false
+}
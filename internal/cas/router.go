@@ -20,23 +20,29 @@ const (
 )
 
 type ProviderRouter struct {
-	postgres *db.PostgresDB
-	redis    *redis.Client
-	bandit   *MultiArmedBandit
+	postgres  *db.PostgresDB
+	redis     *redis.Client
+	bandit    *MultiArmedBandit
+	telemetry *TelemetryStore
+	health    *HealthChecker
+	limiter   *RateLimiter
 }
 
 func NewProviderRouter(pg *db.PostgresDB, redisClient *redis.Client) *ProviderRouter {
 	return &ProviderRouter{
-		postgres: pg,
-		redis:    redisClient,
-		bandit:   NewMultiArmedBandit(),
+		postgres:  pg,
+		redis:     redisClient,
+		bandit:    NewMultiArmedBandit(),
+		telemetry: NewTelemetryStore(pg, redisClient),
+		health:    NewHealthChecker(NewHTTPHealthProber(5 * time.Second)),
+		limiter:   providerRateLimiter,
 	}
 }
 
 // GetAvailableProviders retrieves providers available for a quality tier
 func (pr *ProviderRouter) GetAvailableProviders(ctx context.Context, orgID uuid.UUID, qualityTier QualityTier) ([]ProviderConfig, error) {
 	query := `SELECT id, org_id, provider_name, model_name, config, 
-			  cost_per_token_prompt, cost_per_token_completion, qps_limit, enabled, created_at
+			  cost_per_token_prompt, cost_per_token_completion, qps_limit, region, data_residency, enabled, created_at
 			  FROM provider_config 
 			  WHERE org_id = $1 AND enabled = true`
 
@@ -54,7 +60,7 @@ func (pr *ProviderRouter) GetAvailableProviders(ctx context.Context, orgID uuid.
 		err := rows.Scan(
 			&provider.ID, &provider.OrgID, &provider.ProviderName, &provider.ModelName,
 			&configJSON, &provider.CostPerTokenPrompt, &provider.CostPerTokenCompletion,
-			&provider.QPSLimit, &provider.Enabled, &provider.CreatedAt,
+			&provider.QPSLimit, &provider.Region, &provider.DataResidency, &provider.Enabled, &provider.CreatedAt,
 		)
 		if err != nil {
 			continue
@@ -63,6 +69,7 @@ func (pr *ProviderRouter) GetAvailableProviders(ctx context.Context, orgID uuid.
 		if err := json.Unmarshal(configJSON, &provider.Config); err != nil {
 			continue
 		}
+		pr.limiter.Configure(provider)
 
 		// Filter by quality tier
 		if pr.isProviderSuitableForQuality(provider, qualityTier) {
@@ -76,7 +83,7 @@ func (pr *ProviderRouter) GetAvailableProviders(ctx context.Context, orgID uuid.
 // GetAllProviders retrieves all providers for an organization
 func (pr *ProviderRouter) GetAllProviders(ctx context.Context, orgID uuid.UUID) ([]ProviderConfig, error) {
 	query := `SELECT id, org_id, provider_name, model_name, config, 
-			  cost_per_token_prompt, cost_per_token_completion, qps_limit, enabled, created_at
+			  cost_per_token_prompt, cost_per_token_completion, qps_limit, region, data_residency, enabled, created_at
 			  FROM provider_config 
 			  WHERE org_id = $1`
 
@@ -94,7 +101,7 @@ func (pr *ProviderRouter) GetAllProviders(ctx context.Context, orgID uuid.UUID)
 		err := rows.Scan(
 			&provider.ID, &provider.OrgID, &provider.ProviderName, &provider.ModelName,
 			&configJSON, &provider.CostPerTokenPrompt, &provider.CostPerTokenCompletion,
-			&provider.QPSLimit, &provider.Enabled, &provider.CreatedAt,
+			&provider.QPSLimit, &provider.Region, &provider.DataResidency, &provider.Enabled, &provider.CreatedAt,
 		)
 		if err != nil {
 			continue
@@ -116,6 +123,18 @@ func (pr *ProviderRouter) SelectOptimalProvider(ctx context.Context, req *Routin
 		return nil, fmt.Errorf("no providers available")
 	}
 
+	// Unhealthy providers get no traffic until their health checks recover
+	healthy := make([]ProviderConfig, 0, len(providers))
+	for _, provider := range providers {
+		if pr.health.IsHealthy(provider.ProviderName, provider.ModelName) {
+			healthy = append(healthy, provider)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil, fmt.Errorf("no healthy providers available")
+	}
+	providers = healthy
+
 	// Score each provider
 	scoredProviders := make([]ScoredProvider, 0, len(providers))
 	for _, provider := range providers {
@@ -196,20 +215,49 @@ func (pr *ProviderRouter) GetProviderMetrics(ctx context.Context, orgID uuid.UUI
 		metric := ProviderMetrics{
 			ProviderName:     provider.ProviderName,
 			ModelName:        provider.ModelName,
-			AvgLatency:       pr.estimateLatency(ctx, provider),
-			P95Latency:       pr.estimateLatency(ctx, provider) * 2,            // Mock P95
 			SuccessRate:      0.95 + (float64(time.Now().UnixNano()%10) / 100), // Mock success rate
 			AvgCostPerToken:  (provider.CostPerTokenPrompt + provider.CostPerTokenCompletion) / 2,
 			QualityScore:     pr.getQualityScore(provider, QualityGold),
 			ReliabilityScore: 0.9 + (float64(time.Now().UnixNano()%10) / 100),
 			LastUpdated:      time.Now(),
 		}
+		pr.fillLatencyPercentiles(ctx, provider, &metric)
 		metrics = append(metrics, metric)
 	}
 
 	return metrics, nil
 }
 
+// fillLatencyPercentiles prefers the shared rolling window, then this process's
+// histograms, and only falls back to static estimates without any samples
+func (pr *ProviderRouter) fillLatencyPercentiles(ctx context.Context, provider ProviderConfig, metric *ProviderMetrics) {
+	if stats := pr.rollingStats(ctx, provider); stats.Sufficient() && stats.AvgLatency > 0 {
+		metric.AvgLatency = stats.AvgLatency
+		metric.P50Latency = stats.P50Latency
+		metric.P95Latency = stats.P95Latency
+		metric.P99Latency = stats.P99Latency
+		metric.LatencySamples = uint64(stats.Samples)
+		return
+	}
+
+	if pr.telemetry != nil {
+		if p := pr.telemetry.LatencyPercentiles(provider.ProviderName, provider.ModelName); p.Count > 0 {
+			metric.AvgLatency = p.Mean
+			metric.P50Latency = p.P50
+			metric.P95Latency = p.P95
+			metric.P99Latency = p.P99
+			metric.LatencySamples = p.Count
+			return
+		}
+	}
+
+	estimate := pr.estimateLatency(ctx, provider)
+	metric.AvgLatency = estimate
+	metric.P50Latency = estimate
+	metric.P95Latency = estimate * 2
+	metric.P99Latency = estimate * 3
+}
+
 // Helper methods
 
 type ScoredProvider struct {
@@ -321,7 +369,11 @@ func (pr *ProviderRouter) estimateCost(provider ProviderConfig, promptTokens, ma
 }
 
 func (pr *ProviderRouter) estimateLatency(ctx context.Context, provider ProviderConfig) time.Duration {
-	// Mock latency estimation - in production would use historical data
+	if stats := pr.rollingStats(ctx, provider); stats.Sufficient() && stats.AvgLatency > 0 {
+		return stats.AvgLatency
+	}
+
+	// Fall back to static estimates until enough telemetry is collected
 	baseLatency := 1000 * time.Millisecond
 
 	switch provider.ProviderName {
@@ -374,7 +426,11 @@ func (pr *ProviderRouter) calculateLatencyScore(estimatedLatency, slaLatency tim
 }
 
 func (pr *ProviderRouter) getReliabilityScore(ctx context.Context, provider ProviderConfig) float64 {
-	// Mock reliability scoring - in production would use historical data
+	if stats := pr.rollingStats(ctx, provider); stats.Sufficient() {
+		return stats.SuccessRate
+	}
+
+	// Fall back to static estimates until enough telemetry is collected
 	baseReliability := 0.95
 
 	// Provider-specific reliability
@@ -391,3 +447,75 @@ func (pr *ProviderRouter) getReliabilityScore(ctx context.Context, provider Prov
 
 	return baseReliability
 }
+
+func (pr *ProviderRouter) rollingStats(ctx context.Context, provider ProviderConfig) *RollingStats {
+	if pr.telemetry == nil {
+		return nil
+	}
+
+	stats, err := pr.telemetry.GetRollingStats(ctx, provider.ProviderName, provider.ModelName)
+	if err != nil {
+		return nil
+	}
+	return stats
+}
+
+// RecordTelemetry stores observed call telemetry and feeds it back into the
+// bandit. Provider health is left to CallWithFailover, which sees every
+// attempt rather than only a call's final outcome.
+func (pr *ProviderRouter) RecordTelemetry(ctx context.Context, record *ProviderTelemetry) error {
+	if err := pr.telemetry.Record(ctx, record); err != nil {
+		return err
+	}
+
+	success := record.ErrorClass == ErrorClassNone || record.ErrorClass == ""
+	reward := pr.bandit.CalculateReward(record.CostCents, record.EstimatedCost, record.Latency, record.EstimatedLatency, success)
+	pr.bandit.UpdateReward(record.ProviderName, record.ModelName, reward)
+
+	return nil
+}
+
+// WaitForRateLimit queues for a token under the provider/model's QPS limit
+func (pr *ProviderRouter) WaitForRateLimit(ctx context.Context, providerName, modelName string) error {
+	return pr.limiter.Wait(ctx, providerName, modelName)
+}
+
+// GetProviderHealth returns the health of every provider/model seen so far
+func (pr *ProviderRouter) GetProviderHealth() []ProviderHealth {
+	return pr.health.Health()
+}
+
+// RunHealthChecks pings every enabled provider on an interval until ctx is done
+func (pr *ProviderRouter) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	pr.health.Run(ctx, interval, pr.enabledProviders)
+}
+
+// enabledProviders lists each enabled provider/model once across orgs
+func (pr *ProviderRouter) enabledProviders(ctx context.Context) ([]ProviderConfig, error) {
+	query := `SELECT DISTINCT ON (provider_name, model_name) provider_name, model_name, config
+			  FROM provider_config WHERE enabled = true
+			  ORDER BY provider_name, model_name, created_at DESC`
+
+	rows, err := pr.postgres.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query providers: %w", err)
+	}
+	defer rows.Close()
+
+	providers := make([]ProviderConfig, 0)
+	for rows.Next() {
+		var provider ProviderConfig
+		var configJSON []byte
+		if err := rows.Scan(&provider.ProviderName, &provider.ModelName, &configJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan provider: %w", err)
+		}
+		_ = json.Unmarshal(configJSON, &provider.Config) // Providers without config use the default health URL
+		providers = append(providers, provider)
+	}
+	return providers, rows.Err()
+}
+
+// RecordFeedback feeds a human rating of a call's output back into the bandit
+func (pr *ProviderRouter) RecordFeedback(providerName, modelName string, approved bool) {
+	pr.bandit.UpdateReward(providerName, modelName, pr.bandit.FeedbackReward(approved))
+}
@@ -0,0 +1,475 @@
+package cas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+// RuleOperator compares a request field against a rule value
+type RuleOperator string
+
+const (
+	RuleOpEq  RuleOperator = "="
+	RuleOpNe  RuleOperator = "!="
+	RuleOpLt  RuleOperator = "<"
+	RuleOpLte RuleOperator = "<="
+	RuleOpGt  RuleOperator = ">"
+	RuleOpGte RuleOperator = ">="
+	RuleOpIn  RuleOperator = "in"
+)
+
+// RuleCondition is a single comparison; all conditions of a rule must hold
+type RuleCondition struct {
+	Field    string       `json:"field"`
+	Operator RuleOperator `json:"operator"`
+	Value    string       `json:"value"`
+}
+
+// RuleAction pins routing to matching providers. Model matches any model
+// name containing the value, so "haiku" selects "claude-3-haiku".
+type RuleAction struct {
+	ProviderName string `json:"provider,omitempty"`
+	ModelName    string `json:"model,omitempty"`
+}
+
+// RoutingRule is a user-authored routing policy evaluated before scoring.
+// Lower priority values are evaluated first.
+type RoutingRule struct {
+	ID         uuid.UUID       `json:"id" db:"id"`
+	OrgID      uuid.UUID       `json:"org_id" db:"org_id"`
+	Name       string          `json:"name" db:"name"`
+	Expression string          `json:"expression" db:"expression"`
+	Priority   int             `json:"priority" db:"priority"`
+	Conditions []RuleCondition `json:"conditions" db:"-"`
+	Action     RuleAction      `json:"action" db:"-"`
+	Enabled    bool            `json:"enabled" db:"enabled"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+// RuleSimulation summarizes replaying historical traffic through rules
+type RuleSimulation struct {
+	OrgID              uuid.UUID              `json:"org_id"`
+	Window             time.Duration          `json:"window"`
+	Requests           int                    `json:"requests"`
+	Matched            int                    `json:"matched"`
+	BaselineCostCents  int64                  `json:"baseline_cost_cents"`
+	ProjectedCostCents int64                  `json:"projected_cost_cents"`
+	BaselineQuality    float64                `json:"baseline_quality"`
+	ProjectedQuality   float64                `json:"projected_quality"`
+	ByRule             map[string]*RuleImpact `json:"by_rule"`
+}
+
+// RuleImpact is the simulated effect of a single rule
+type RuleImpact struct {
+	Matched            int     `json:"matched"`
+	BaselineCostCents  int64   `json:"baseline_cost_cents"`
+	ProjectedCostCents int64   `json:"projected_cost_cents"`
+	QualityDelta       float64 `json:"quality_delta"`
+}
+
+var ruleConditionPattern = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_.]*)\s*(<=|>=|!=|=|<|>|\s+in\s+)\s*(.+)$`)
+
+// ParseRoutingRule parses an expression such as
+// "if workflow=support-triage and tokens<500 then model=haiku"
+func ParseRoutingRule(name, expression string) (*RoutingRule, error) {
+	expr := strings.TrimSpace(expression)
+	lower := strings.ToLower(expr)
+	if !strings.HasPrefix(lower, "if ") {
+		return nil, fmt.Errorf("rule must start with \"if\"")
+	}
+
+	thenIdx := strings.Index(lower, " then ")
+	if thenIdx < 0 {
+		return nil, fmt.Errorf("rule is missing \"then\" clause")
+	}
+
+	rule := &RoutingRule{
+		Name:       name,
+		Expression: expr,
+		Enabled:    true,
+	}
+
+	for _, part := range splitKeyword(expr[3:thenIdx], " and ") {
+		condition, err := parseRuleCondition(part)
+		if err != nil {
+			return nil, err
+		}
+		rule.Conditions = append(rule.Conditions, *condition)
+	}
+
+	for _, part := range strings.Split(expr[thenIdx+len(" then "):], ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("invalid action %q", part)
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "provider":
+			rule.Action.ProviderName = strings.TrimSpace(value)
+		case "model":
+			rule.Action.ModelName = strings.TrimSpace(value)
+		default:
+			return nil, fmt.Errorf("unsupported action %q", key)
+		}
+	}
+
+	return rule, nil
+}
+
+func parseRuleCondition(s string) (*RuleCondition, error) {
+	m := ruleConditionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, fmt.Errorf("invalid condition %q", s)
+	}
+
+	condition := &RuleCondition{
+		Field:    strings.ToLower(m[1]),
+		Operator: RuleOperator(strings.TrimSpace(m[2])),
+		Value:    strings.TrimSpace(m[3]),
+	}
+
+	switch condition.Operator {
+	case RuleOpLt, RuleOpLte, RuleOpGt, RuleOpGte:
+		if _, err := strconv.ParseFloat(condition.Value, 64); err != nil {
+			return nil, fmt.Errorf("condition %q needs a numeric value", s)
+		}
+	case RuleOpIn:
+		condition.Value = strings.Trim(condition.Value, "()")
+	}
+
+	return condition, nil
+}
+
+// splitKeyword splits on a case-insensitive keyword
+func splitKeyword(s, keyword string) []string {
+	parts := make([]string, 0)
+	lower := strings.ToLower(s)
+	for {
+		idx := strings.Index(lower, keyword)
+		if idx < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:idx])
+		s, lower = s[idx+len(keyword):], lower[idx+len(keyword):]
+	}
+}
+
+// Matches reports whether every condition holds for the request
+func (r *RoutingRule) Matches(req *RoutingRequest) bool {
+	if !r.Enabled {
+		return false
+	}
+	for _, condition := range r.Conditions {
+		if !condition.matches(ruleFieldValue(req, condition.Field)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c RuleCondition) matches(actual string) bool {
+	switch c.Operator {
+	case RuleOpEq:
+		return strings.EqualFold(actual, c.Value)
+	case RuleOpNe:
+		return !strings.EqualFold(actual, c.Value)
+	case RuleOpIn:
+		for _, v := range strings.Split(c.Value, ",") {
+			if strings.EqualFold(actual, strings.TrimSpace(v)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	a, err := strconv.ParseFloat(actual, 64)
+	if err != nil {
+		return false
+	}
+	b, _ := strconv.ParseFloat(c.Value, 64)
+
+	switch c.Operator {
+	case RuleOpLt:
+		return a < b
+	case RuleOpLte:
+		return a <= b
+	case RuleOpGt:
+		return a > b
+	case RuleOpGte:
+		return a >= b
+	}
+	return false
+}
+
+// ruleFieldValue resolves a condition field against a request
+func ruleFieldValue(req *RoutingRequest, field string) string {
+	switch field {
+	case "workflow":
+		if req.WorkflowName != "" {
+			return req.WorkflowName
+		}
+	case "tokens", "prompt_tokens":
+		return strconv.Itoa(req.PromptTokens)
+	case "max_tokens":
+		return strconv.Itoa(req.MaxTokens)
+	case "quality", "quality_tier":
+		return string(req.QualityTier)
+	}
+
+	if v, ok := req.Context[field]; ok {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// Filter returns the providers the action allows
+func (a RuleAction) Filter(providers []ProviderConfig) []ProviderConfig {
+	matched := make([]ProviderConfig, 0)
+	for _, provider := range providers {
+		if a.ProviderName != "" && !strings.EqualFold(provider.ProviderName, a.ProviderName) {
+			continue
+		}
+		if a.ModelName != "" && !strings.Contains(strings.ToLower(provider.ModelName), strings.ToLower(a.ModelName)) {
+			continue
+		}
+		matched = append(matched, provider)
+	}
+	return matched
+}
+
+// ApplyRoutingRules narrows providers using the first matching rule. A rule
+// whose action matches no available provider is skipped so routing can fall
+// back to scoring.
+func ApplyRoutingRules(rules []RoutingRule, req *RoutingRequest, providers []ProviderConfig) ([]ProviderConfig, *RoutingRule) {
+	for i := range rules {
+		if !rules[i].Matches(req) {
+			continue
+		}
+		if filtered := rules[i].Action.Filter(providers); len(filtered) > 0 {
+			return filtered, &rules[i]
+		}
+	}
+	return providers, nil
+}
+
+// RuleEngine stores routing rules and simulates them against past traffic
+type RuleEngine struct {
+	postgres *db.PostgresDB
+}
+
+func NewRuleEngine(pg *db.PostgresDB) *RuleEngine {
+	return &RuleEngine{postgres: pg}
+}
+
+// CreateRule persists a routing rule
+func (re *RuleEngine) CreateRule(ctx context.Context, rule *RoutingRule) error {
+	if rule.ID == uuid.Nil {
+		rule.ID = uuid.New()
+	}
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = time.Now()
+	}
+
+	conditionsJSON, err := json.Marshal(rule.Conditions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal conditions: %w", err)
+	}
+	actionJSON, err := json.Marshal(rule.Action)
+	if err != nil {
+		return fmt.Errorf("failed to marshal action: %w", err)
+	}
+
+	query := `INSERT INTO routing_rule (id, org_id, name, expression, priority, conditions, action, enabled, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err = re.postgres.ExecContext(ctx, query,
+		rule.ID, rule.OrgID, rule.Name, rule.Expression, rule.Priority,
+		conditionsJSON, actionJSON, rule.Enabled, rule.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create routing rule: %w", err)
+	}
+
+	return nil
+}
+
+// ListRules returns an org's rules in evaluation order
+func (re *RuleEngine) ListRules(ctx context.Context, orgID uuid.UUID) ([]RoutingRule, error) {
+	query := `SELECT id, org_id, name, expression, priority, conditions, action, enabled, created_at
+			  FROM routing_rule WHERE org_id = $1
+			  ORDER BY priority ASC, created_at ASC`
+
+	rows, err := re.postgres.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query routing rules: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	rules := make([]RoutingRule, 0)
+	for rows.Next() {
+		var rule RoutingRule
+		var conditionsJSON, actionJSON []byte
+
+		err := rows.Scan(&rule.ID, &rule.OrgID, &rule.Name, &rule.Expression, &rule.Priority,
+			&conditionsJSON, &actionJSON, &rule.Enabled, &rule.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan routing rule: %w", err)
+		}
+		if err := json.Unmarshal(conditionsJSON, &rule.Conditions); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal conditions: %w", err)
+		}
+		if err := json.Unmarshal(actionJSON, &rule.Action); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal action: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// DeleteRule removes a routing rule
+func (re *RuleEngine) DeleteRule(ctx context.Context, orgID, ruleID uuid.UUID) error {
+	result, err := re.postgres.ExecContext(ctx, `DELETE FROM routing_rule WHERE org_id = $1 AND id = $2`, orgID, ruleID)
+	if err != nil {
+		return fmt.Errorf("failed to delete routing rule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("routing rule %s not found", ruleID)
+	}
+
+	return nil
+}
+
+// TrafficSamples loads recorded provider calls for replay
+func (re *RuleEngine) TrafficSamples(ctx context.Context, orgID uuid.UUID, window time.Duration) ([]ProviderTelemetry, error) {
+	query := `SELECT provider_name, model_name, COALESCE(workflow_name, ''), COALESCE(quality_tier, ''),
+			  tokens_used, cost_cents, latency_ms, error_class, recorded_at
+			  FROM provider_telemetry
+			  WHERE org_id = $1 AND recorded_at >= $2`
+
+	rows, err := re.postgres.QueryContext(ctx, query, orgID, time.Now().Add(-window))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query traffic: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	samples := make([]ProviderTelemetry, 0)
+	for rows.Next() {
+		var sample ProviderTelemetry
+		var latencyMs int64
+
+		err := rows.Scan(&sample.ProviderName, &sample.ModelName, &sample.WorkflowName, &sample.QualityTier,
+			&sample.TokensUsed, &sample.CostCents, &latencyMs, &sample.ErrorClass, &sample.RecordedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan traffic sample: %w", err)
+		}
+		sample.Latency = time.Duration(latencyMs) * time.Millisecond
+		samples = append(samples, sample)
+	}
+
+	return samples, rows.Err()
+}
+
+// simulateRules replays samples through rules, routing each matched request
+// to the cheapest provider its rule allows
+func simulateRules(rules []RoutingRule, samples []ProviderTelemetry, providers []ProviderConfig, quality func(ProviderConfig, QualityTier) float64) *RuleSimulation {
+	sorted := append([]RoutingRule(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority < sorted[j].Priority })
+
+	configs := make(map[string]ProviderConfig, len(providers))
+	for _, provider := range providers {
+		configs[provider.ProviderName+"/"+provider.ModelName] = provider
+	}
+
+	sim := &RuleSimulation{ByRule: make(map[string]*RuleImpact)}
+	var baselineQuality, projectedQuality float64
+
+	for _, sample := range samples {
+		sim.Requests++
+		sim.BaselineCostCents += sample.CostCents
+
+		original, known := configs[sample.ProviderName+"/"+sample.ModelName]
+		if !known {
+			original = ProviderConfig{ProviderName: sample.ProviderName, ModelName: sample.ModelName}
+		}
+		origQuality := quality(original, sample.QualityTier)
+		baselineQuality += origQuality
+
+		req := &RoutingRequest{
+			WorkflowName: sample.WorkflowName,
+			QualityTier:  sample.QualityTier,
+			PromptTokens: sample.TokensUsed,
+		}
+		filtered, rule := ApplyRoutingRules(sorted, req, providers)
+		if rule == nil {
+			sim.ProjectedCostCents += sample.CostCents
+			projectedQuality += origQuality
+			continue
+		}
+
+		target := cheapestProvider(filtered)
+		projected := projectSampleCost(sample, original, known, target)
+		targetQuality := quality(target, sample.QualityTier)
+
+		sim.Matched++
+		sim.ProjectedCostCents += projected
+		projectedQuality += targetQuality
+
+		impact, ok := sim.ByRule[rule.Name]
+		if !ok {
+			impact = &RuleImpact{}
+			sim.ByRule[rule.Name] = impact
+		}
+		impact.Matched++
+		impact.BaselineCostCents += sample.CostCents
+		impact.ProjectedCostCents += projected
+		impact.QualityDelta += targetQuality - origQuality
+	}
+
+	if sim.Requests > 0 {
+		sim.BaselineQuality = baselineQuality / float64(sim.Requests)
+		sim.ProjectedQuality = projectedQuality / float64(sim.Requests)
+	}
+	for _, impact := range sim.ByRule {
+		impact.QualityDelta /= float64(impact.Matched)
+	}
+
+	return sim
+}
+
+func cheapestProvider(providers []ProviderConfig) ProviderConfig {
+	cheapest := providers[0]
+	for _, provider := range providers[1:] {
+		if blendedTokenCost(provider) < blendedTokenCost(cheapest) {
+			cheapest = provider
+		}
+	}
+	return cheapest
+}
+
+func blendedTokenCost(provider ProviderConfig) float64 {
+	return (provider.CostPerTokenPrompt + provider.CostPerTokenCompletion) / 2
+}
+
+// projectSampleCost scales the observed cost by the price ratio when the
+// original pricing is known and estimates from token counts otherwise
+func projectSampleCost(sample ProviderTelemetry, original ProviderConfig, known bool, target ProviderConfig) int64 {
+	if known && blendedTokenCost(original) > 0 {
+		return int64(math.Round(float64(sample.CostCents) * blendedTokenCost(target) / blendedTokenCost(original)))
+	}
+	return int64(math.Round(float64(sample.TokensUsed) * blendedTokenCost(target) * 100))
+}
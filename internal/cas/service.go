@@ -17,9 +17,15 @@ type Service struct {
 	redis     *redis.Client
 	router    *ProviderRouter
 	budgetMgr *BudgetManager
+	guard     *BudgetGuard
+	degrader  *DegradationController
 	cache     *CacheManager
 	quotaMgr  *QuotaManager
 	optimizer *Optimizer
+	rules     *RuleEngine
+	policies  *ModelPolicyStore
+
+	forecaster *CapacityForecaster
 }
 
 func NewService(cfg *config.Config, pg *db.PostgresDB, redisClient *redis.Client) *Service {
@@ -31,9 +37,14 @@ func NewService(cfg *config.Config, pg *db.PostgresDB, redisClient *redis.Client
 
 	service.router = NewProviderRouter(pg, redisClient)
 	service.budgetMgr = NewBudgetManager(pg)
+	service.guard = NewBudgetGuard(pg, redisClient, cfg.Budgets)
+	service.degrader = NewDegradationController(service.guard, cfg.Budgets.Degradation)
 	service.cache = NewCacheManager(redisClient)
 	service.quotaMgr = NewQuotaManager(redisClient)
 	service.optimizer = NewOptimizer(pg, redisClient)
+	service.rules = NewRuleEngine(pg)
+	service.policies = NewModelPolicyStore(pg)
+	service.forecaster = NewCapacityForecaster(pg, cfg.Alerts)
 
 	return service
 }
@@ -46,8 +57,19 @@ func (s *Service) RouteRequest(ctx context.Context, req *RoutingRequest) (*Routi
 		return nil, fmt.Errorf("failed to check budget: %w", err)
 	}
 
-	if budgetStatus.Status == BudgetStatusExceeded {
-		return nil, fmt.Errorf("budget exceeded: %d/%d cents used", budgetStatus.SpentCents, budgetStatus.LimitCents)
+	if err := s.guard.Enforce(ctx, req.OrgID, budgetStatus); err != nil {
+		return nil, err
+	}
+
+	// Orgs nearing their budget get cheaper, shorter or throttled calls
+	degradation, err := s.degrader.PlanForStatus(ctx, req.OrgID, budgetStatus)
+	if err != nil {
+		return nil, err
+	}
+	if action, ok := degradation.Action(DegradeShorterContext); ok && req.PromptTokens > action.MaxContextTokens {
+		shortened := *req
+		shortened.PromptTokens = action.MaxContextTokens
+		req = &shortened
 	}
 
 	// Get available providers
@@ -56,6 +78,23 @@ func (s *Service) RouteRequest(ctx context.Context, req *RoutingRequest) (*Routi
 		return nil, fmt.Errorf("failed to get providers: %w", err)
 	}
 
+	// Filter by region and data residency
+	providers, denials := FilterByResidency(providers, req.Residency)
+	if len(providers) == 0 && len(denials) > 0 {
+		return nil, fmt.Errorf("no providers satisfy residency constraints: %s", FormatDenials(denials))
+	}
+
+	// Enforce the org's model allow/deny lists
+	policy, err := s.policies.Get(ctx, req.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	providers, policyDenials := FilterByModelPolicy(providers, policy)
+	denials = append(denials, policyDenials...)
+	if len(providers) == 0 && len(policyDenials) > 0 {
+		return nil, fmt.Errorf("no providers permitted by org model policy: %s", FormatDenials(policyDenials))
+	}
+
 	// Filter by quota availability
 	availableProviders := make([]ProviderConfig, 0)
 	for _, provider := range providers {
@@ -73,11 +112,37 @@ func (s *Service) RouteRequest(ctx context.Context, req *RoutingRequest) (*Routi
 		return nil, fmt.Errorf("no available providers within quota limits")
 	}
 
+	// Apply user-authored routing rules before scoring
+	rules, err := s.rules.ListRules(ctx, req.OrgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load routing rules: %w", err)
+	}
+	availableProviders, matchedRule := ApplyRoutingRules(rules, req, availableProviders)
+	if action, ok := degradation.Action(DegradeCheaperModel); ok {
+		for _, provider := range availableProviders {
+			if provider.ProviderName == action.Provider && provider.ModelName == action.Model {
+				availableProviders = []ProviderConfig{provider}
+				break
+			}
+		}
+	}
+
 	// Route to optimal provider
 	response, err := s.router.SelectOptimalProvider(ctx, req, availableProviders, budgetStatus)
 	if err != nil {
 		return nil, fmt.Errorf("failed to select provider: %w", err)
 	}
+	response.Denials = denials
+	response.Degradations = degradation.Labels()
+	if matchedRule != nil {
+		response.MatchedRule = matchedRule.Name
+		response.Reason = fmt.Sprintf("routing rule %q; %s", matchedRule.Name, response.Reason)
+	}
+
+	// Queue for the provider's QPS limit, rejecting when the queue is too long
+	if err := s.router.WaitForRateLimit(ctx, response.ProviderName, response.ModelName); err != nil {
+		return nil, fmt.Errorf("failed to route request: %w", err)
+	}
 
 	// Reserve quota
 	if err := s.quotaMgr.ReserveQuota(ctx, response.ProviderName, response.ModelName); err != nil {
@@ -89,8 +154,8 @@ func (s *Service) RouteRequest(ctx context.Context, req *RoutingRequest) (*Routi
 }
 
 // CacheGet retrieves a cached response
-func (s *Service) CacheGet(ctx context.Context, orgID uuid.UUID, promptHash, inputHash string) (*CacheResponse, error) {
-	return s.cache.Get(ctx, orgID, promptHash, inputHash)
+func (s *Service) CacheGet(ctx context.Context, orgID uuid.UUID, scope CacheScope, promptHash, inputHash string) (*CacheResponse, error) {
+	return s.cache.Get(ctx, orgID, scope, promptHash, inputHash)
 }
 
 // CachePut stores a response in cache
@@ -113,11 +178,52 @@ func (s *Service) RecordUsage(ctx context.Context, orgID uuid.UUID, providerName
 	return nil
 }
 
+// RecordTelemetry records observed latency, error class and cost for a provider call
+func (s *Service) RecordTelemetry(ctx context.Context, record *ProviderTelemetry) error {
+	if err := s.router.RecordTelemetry(ctx, record); err != nil {
+		return fmt.Errorf("failed to record telemetry: %w", err)
+	}
+	return nil
+}
+
+// CallWithFailover makes a routed call, retrying on the next-best healthy
+// alternative when a provider fails with a server error or timeout
+func (s *Service) CallWithFailover(ctx context.Context, response *RoutingResponse, call ProviderCall) ([]FailoverAttempt, error) {
+	return s.router.CallWithFailover(ctx, response, call)
+}
+
+// GetProviderHealth returns the health of every provider/model seen so far
+func (s *Service) GetProviderHealth(ctx context.Context) []ProviderHealth {
+	return s.router.GetProviderHealth()
+}
+
+// ForecastProviderUsage forecasts the org's peak tokens and requests per
+// minute for each provider/model against its limits
+func (s *Service) ForecastProviderUsage(ctx context.Context, orgID uuid.UUID, lookback, horizon time.Duration) ([]ProviderForecast, error) {
+	return s.forecaster.Forecast(ctx, orgID, lookback, horizon)
+}
+
+// RecordFeedback uses a human thumbs up or down on a call's output as a
+// routing reward for the provider and model that produced it
+func (s *Service) RecordFeedback(ctx context.Context, providerName, modelName string, approved bool) error {
+	if providerName == "" || modelName == "" {
+		return fmt.Errorf("provider and model are required")
+	}
+	s.router.RecordFeedback(providerName, modelName, approved)
+	return nil
+}
+
 // GetBudgetStatus retrieves current budget status
 func (s *Service) GetBudgetStatus(ctx context.Context, orgID uuid.UUID) (*BudgetStatus, error) {
 	return s.budgetMgr.GetStatus(ctx, orgID)
 }
 
+// GetBudgetUsage reports an org's period spending and whether its calls are
+// being rejected or throttled
+func (s *Service) GetBudgetUsage(ctx context.Context, orgID uuid.UUID) (*BudgetUsage, error) {
+	return s.guard.Usage(ctx, orgID)
+}
+
 // GetQuotaStatus retrieves current quota status for all providers
 func (s *Service) GetQuotaStatus(ctx context.Context, orgID uuid.UUID) ([]QuotaStatus, error) {
 	providers, err := s.router.GetAllProviders(ctx, orgID)
@@ -137,9 +243,95 @@ func (s *Service) GetQuotaStatus(ctx context.Context, orgID uuid.UUID) ([]QuotaS
 	return statuses, nil
 }
 
-// GetOptimizationSuggestions provides cost optimization recommendations
+// GetOptimizationSuggestions generates cost optimization recommendations,
+// persists them and returns the suggestions still open for review
 func (s *Service) GetOptimizationSuggestions(ctx context.Context, orgID uuid.UUID, timeRange time.Duration) ([]OptimizationSuggestion, error) {
-	return s.optimizer.GenerateSuggestions(ctx, orgID, timeRange)
+	suggestions, err := s.optimizer.GenerateSuggestions(ctx, orgID, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.optimizer.SaveSuggestions(ctx, orgID, suggestions); err != nil {
+		return nil, err
+	}
+
+	return s.optimizer.ListSuggestions(ctx, orgID, SuggestionStatusOpen)
+}
+
+// AcceptSuggestion accepts a suggestion and starts tracking its realized savings
+func (s *Service) AcceptSuggestion(ctx context.Context, orgID, suggestionID uuid.UUID) (*OptimizationSuggestion, error) {
+	return s.optimizer.AcceptSuggestion(ctx, orgID, suggestionID)
+}
+
+// DismissSuggestion dismisses a suggestion so it is not offered again
+func (s *Service) DismissSuggestion(ctx context.Context, orgID, suggestionID uuid.UUID, reason string) (*OptimizationSuggestion, error) {
+	return s.optimizer.DismissSuggestion(ctx, orgID, suggestionID, reason)
+}
+
+// GetOptimizationReport shows projected versus realized savings
+func (s *Service) GetOptimizationReport(ctx context.Context, orgID uuid.UUID) (*OptimizationReport, error) {
+	return s.optimizer.BuildOptimizationReport(ctx, orgID)
+}
+
+// GetModelPolicy returns the org's model allow/deny lists, or nil if unset
+func (s *Service) GetModelPolicy(ctx context.Context, orgID uuid.UUID) (*ModelPolicy, error) {
+	return s.policies.Get(ctx, orgID)
+}
+
+// SetModelPolicy replaces the org's model allow/deny lists
+func (s *Service) SetModelPolicy(ctx context.Context, policy *ModelPolicy) error {
+	return s.policies.Set(ctx, policy)
+}
+
+// CreateRoutingRule parses and stores a routing rule expression
+func (s *Service) CreateRoutingRule(ctx context.Context, orgID uuid.UUID, name, expression string, priority int) (*RoutingRule, error) {
+	rule, err := ParseRoutingRule(name, expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid routing rule: %w", err)
+	}
+	rule.OrgID = orgID
+	rule.Priority = priority
+
+	if err := s.rules.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// ListRoutingRules returns an org's routing rules in evaluation order
+func (s *Service) ListRoutingRules(ctx context.Context, orgID uuid.UUID) ([]RoutingRule, error) {
+	return s.rules.ListRules(ctx, orgID)
+}
+
+// DeleteRoutingRule removes a routing rule
+func (s *Service) DeleteRoutingRule(ctx context.Context, orgID, ruleID uuid.UUID) error {
+	return s.rules.DeleteRule(ctx, orgID, ruleID)
+}
+
+// SimulateRoutingRules replays recent traffic through proposed rules and
+// reports the projected cost and quality impact
+func (s *Service) SimulateRoutingRules(ctx context.Context, orgID uuid.UUID, rules []RoutingRule, window time.Duration) (*RuleSimulation, error) {
+	samples, err := s.rules.TrafficSamples(ctx, orgID, window)
+	if err != nil {
+		return nil, err
+	}
+
+	providers, err := s.router.GetAllProviders(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get providers: %w", err)
+	}
+
+	sim := simulateRules(rules, samples, providers, s.router.getQualityScore)
+	sim.OrgID = orgID
+	sim.Window = window
+
+	return sim, nil
+}
+
+// ListBudgets lists an organization's budgets one page at a time
+func (s *Service) ListBudgets(ctx context.Context, orgID uuid.UUID, opts db.ListOptions) (*BudgetPage, error) {
+	return s.budgetMgr.ListBudgets(ctx, orgID, opts)
 }
 
 // CreateBudget creates a new budget
@@ -154,29 +346,62 @@ func (s *Service) CreateBudget(ctx context.Context, orgID uuid.UUID, periodType
 		CreatedAt:  time.Now(),
 	}
 
-	// Set period boundaries
-	now := time.Now()
-	switch periodType {
-	case PeriodDaily:
-		budget.PeriodStart = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		budget.PeriodEnd = budget.PeriodStart.Add(24 * time.Hour)
-	case PeriodWeekly:
-		// Start of week (Monday)
-		weekday := int(now.Weekday())
-		if weekday == 0 {
-			weekday = 7 // Sunday = 7
-		}
-		daysToMonday := weekday - 1
-		budget.PeriodStart = time.Date(now.Year(), now.Month(), now.Day()-daysToMonday, 0, 0, 0, 0, now.Location())
-		budget.PeriodEnd = budget.PeriodStart.Add(7 * 24 * time.Hour)
-	case PeriodMonthly:
-		budget.PeriodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-		budget.PeriodEnd = budget.PeriodStart.AddDate(0, 1, 0)
+	SetBudgetPeriod(budget, periodType)
+
+	return s.budgetMgr.CreateBudget(ctx, budget)
+}
+
+// CreateWorkflowBudget creates a budget scoped to a workflow name and optional tag (key=value)
+func (s *Service) CreateWorkflowBudget(ctx context.Context, orgID uuid.UUID, workflowName, tag string, periodType PeriodType, limitCents int64) (*Budget, error) {
+	if workflowName == "" {
+		return nil, fmt.Errorf("workflow name is required")
 	}
 
+	budget := &Budget{
+		ID:           uuid.New(),
+		OrgID:        orgID,
+		WorkflowName: &workflowName,
+		PeriodType:   periodType,
+		LimitCents:   limitCents,
+		SpentCents:   0,
+		CreatedAt:    time.Now(),
+	}
+	if tag != "" {
+		budget.Tag = &tag
+	}
+
+	SetBudgetPeriod(budget, periodType)
+
 	return s.budgetMgr.CreateBudget(ctx, budget)
 }
 
+// CreateLabelBudget creates a budget for runs carrying a cost label. The
+// selector is key=value, or a bare key to cap all runs carrying the label.
+func (s *Service) CreateLabelBudget(ctx context.Context, orgID uuid.UUID, selector string, periodType PeriodType, limitCents int64) (*Budget, error) {
+	if _, _, err := ParseCostLabelSelector(selector); err != nil {
+		return nil, err
+	}
+
+	budget := &Budget{
+		ID:         uuid.New(),
+		OrgID:      orgID,
+		Label:      &selector,
+		PeriodType: periodType,
+		LimitCents: limitCents,
+		SpentCents: 0,
+		CreatedAt:  time.Now(),
+	}
+
+	SetBudgetPeriod(budget, periodType)
+
+	return s.budgetMgr.CreateBudget(ctx, budget)
+}
+
+// CheckRunBudget checks workflow, project and org budgets for a run; the most restrictive applies
+func (s *Service) CheckRunBudget(ctx context.Context, scope *BudgetScopeRequest, requestedCents int64) (*BudgetEnforcement, error) {
+	return s.budgetMgr.CheckScopedBudgets(ctx, scope, requestedCents)
+}
+
 // UpdateProviderConfig updates provider configuration
 func (s *Service) UpdateProviderConfig(ctx context.Context, orgID uuid.UUID, providerName, modelName string, config map[string]interface{}) error {
 	return s.router.UpdateProviderConfig(ctx, orgID, providerName, modelName, config)
@@ -310,3 +535,25 @@ func (s *Service) Shutdown(ctx context.Context) error {
 
 	return nil
 }
+
+// SetBudgetPeriod sets period boundaries for a budget starting now
+func SetBudgetPeriod(budget *Budget, periodType PeriodType) {
+	now := time.Now()
+	switch periodType {
+	case PeriodDaily:
+		budget.PeriodStart = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		budget.PeriodEnd = budget.PeriodStart.Add(24 * time.Hour)
+	case PeriodWeekly:
+		// Start of week (Monday)
+		weekday := int(now.Weekday())
+		if weekday == 0 {
+			weekday = 7 // Sunday = 7
+		}
+		daysToMonday := weekday - 1
+		budget.PeriodStart = time.Date(now.Year(), now.Month(), now.Day()-daysToMonday, 0, 0, 0, 0, now.Location())
+		budget.PeriodEnd = budget.PeriodStart.Add(7 * 24 * time.Hour)
+	case PeriodMonthly:
+		budget.PeriodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		budget.PeriodEnd = budget.PeriodStart.AddDate(0, 1, 0)
+	}
+}
@@ -2,9 +2,14 @@ package cas
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -135,6 +140,92 @@ func TestBudgetManagement(t *testing.T) {
 	})
 }
 
+func TestBudgetGuard(t *testing.T) {
+	orgID := uuid.New()
+
+	t.Run("rejects calls once the org budget is exceeded", func(t *testing.T) {
+		guard := NewBudgetGuard(nil, nil, config.BudgetsConfig{})
+		assert.Equal(t, BudgetEnforcementReject, guard.enforcement)
+		assert.NoError(t, guard.Enforce(context.Background(), orgID, &BudgetStatus{Status: BudgetStatusCritical}))
+
+		err := guard.Enforce(context.Background(), orgID, &BudgetStatus{Status: BudgetStatusExceeded, SpentCents: 1200, LimitCents: 1000})
+		var exhausted *BudgetExhaustedError
+		require.ErrorAs(t, err, &exhausted)
+		assert.Equal(t, "org budget exhausted: 1200/1000 cents used", err.Error())
+		assert.Equal(t, ErrorClassTokenBudget, ClassifyError(err))
+	})
+
+	t.Run("throttled calls are retried later", func(t *testing.T) {
+		err := &BudgetExhaustedError{SpentCents: 1200, LimitCents: 1000, RetryAfter: 12 * time.Second}
+		assert.Equal(t, ErrorClassBudgetThrottled, ClassifyError(err))
+		assert.True(t, IsUnsent(fmt.Errorf("admission failed: %w", err)))
+		assert.Contains(t, err.Error(), "next call allowed in 12s")
+	})
+
+	t.Run("normalizes config", func(t *testing.T) {
+		guard := NewBudgetGuard(nil, nil, config.BudgetsConfig{Enforcement: "pause", AlertThresholdRatio: 0.5})
+		assert.Equal(t, BudgetEnforcementReject, guard.enforcement)
+		assert.Equal(t, defaultBudgetThrottleInterval, guard.throttleInterval)
+		assert.Equal(t, 50, guard.alertPct)
+
+		assert.Equal(t, 80, AlertThresholdPct(0.8))
+		assert.Zero(t, AlertThresholdPct(0))
+		assert.Zero(t, AlertThresholdPct(1.5))
+	})
+}
+
+func TestBudgetDegradation(t *testing.T) {
+	policy := &DegradationPolicy{TriggerRatio: 0.8, Actions: []DegradationAction{
+		{Type: DegradeCheaperModel, Provider: "openai", Model: "gpt-4o-mini"},
+		{Type: DegradeShorterContext, MaxContextTokens: 2000},
+		{Type: DegradeThrottle, Interval: 10 * time.Second},
+	}}
+	require.NoError(t, policy.Validate())
+
+	t.Run("applies from the trigger utilization", func(t *testing.T) {
+		assert.Nil(t, policy.Active(&BudgetStatus{LimitCents: 1000, SpentCents: 700, UtilizationPct: 70}))
+		assert.Nil(t, policy.Active(nil))
+
+		degradation := policy.Active(&BudgetStatus{LimitCents: 1000, SpentCents: 850, UtilizationPct: 85})
+		require.NotNil(t, degradation)
+		action, ok := degradation.Action(DegradeCheaperModel)
+		assert.True(t, ok)
+		assert.Equal(t, "gpt-4o-mini", action.Model)
+		assert.Equal(t, []string{"cheaper_model:openai/gpt-4o-mini", "shorter_context:2000", "throttle:10s"}, degradation.Labels())
+	})
+
+	t.Run("nil degradations apply nothing", func(t *testing.T) {
+		var degradation *Degradation
+		_, ok := degradation.Action(DegradeThrottle)
+		assert.False(t, ok)
+		assert.Nil(t, degradation.Labels())
+	})
+
+	t.Run("validates actions", func(t *testing.T) {
+		assert.Error(t, (&DegradationPolicy{TriggerRatio: 1.5}).Validate())
+		assert.Error(t, (&DegradationPolicy{TriggerRatio: 0.9, Actions: []DegradationAction{{Type: DegradeCheaperModel, Provider: "openai"}}}).Validate())
+		assert.Error(t, (&DegradationPolicy{TriggerRatio: 0.9, Actions: []DegradationAction{{Type: DegradeShorterContext}}}).Validate())
+		assert.Error(t, (&DegradationPolicy{TriggerRatio: 0.9, Actions: []DegradationAction{{Type: "skip_step"}}}).Validate())
+		assert.Error(t, (&DegradationPolicy{TriggerRatio: 0.9, Actions: []DegradationAction{
+			{Type: DegradeThrottle, Interval: time.Second}, {Type: DegradeThrottle, Interval: time.Minute}}}).Validate())
+	})
+
+	t.Run("invalid config disables degradation", func(t *testing.T) {
+		controller := NewDegradationController(nil, config.DegradationConfig{TriggerRatio: 0.9,
+			Actions: []config.DegradationActionConfig{{Type: "cheaper_model"}}})
+		degradation, err := controller.Plan(context.Background(), uuid.New())
+		assert.NoError(t, err)
+		assert.Nil(t, degradation)
+	})
+
+	t.Run("throttled calls are retried", func(t *testing.T) {
+		err := &DegradationThrottledError{UtilizationPct: 92.5, RetryAfter: 4 * time.Second}
+		assert.Equal(t, ErrorClassBudgetThrottled, ClassifyError(err))
+		assert.True(t, IsUnsent(err))
+		assert.Equal(t, "org budget 92.5% used, calls throttled: next call allowed in 4s", err.Error())
+	})
+}
+
 func TestQuotaManagement(t *testing.T) {
 	t.Run("QuotaStatus", func(t *testing.T) {
 		status := &QuotaStatus{
@@ -232,6 +323,62 @@ func TestCaching(t *testing.T) {
 		assert.Equal(t, PrivacyProject, policy.PrivacyLevel)
 		assert.NotNil(t, policy.Conditions)
 	})
+
+	t.Run("CacheKeyHashes", func(t *testing.T) {
+		key := CacheKey{
+			Provider: "openai",
+			Model:    "gpt-4",
+			Prompt:   "Summarize the document",
+			Params:   map[string]interface{}{"temperature": 0.0},
+			Inputs:   map[string]interface{}{"document": "quarterly report"},
+		}
+		promptHash, inputHash, err := key.Hashes()
+		require.NoError(t, err)
+
+		same := key
+		same.Params = map[string]interface{}{"temperature": 0.0}
+		again, _, _ := same.Hashes()
+		assert.Equal(t, promptHash, again)
+
+		// The model config is part of the prompt hash
+		other := key
+		other.Model = "gpt-3.5-turbo"
+		changed, otherInputs, _ := other.Hashes()
+		assert.NotEqual(t, promptHash, changed)
+		assert.Equal(t, inputHash, otherInputs)
+
+		other = key
+		other.Params = map[string]interface{}{"temperature": 0.7}
+		changed, _, _ = other.Hashes()
+		assert.NotEqual(t, promptHash, changed)
+
+		other = key
+		other.Inputs = map[string]interface{}{"document": "annual report"}
+		unchanged, changedInputs, _ := other.Hashes()
+		assert.Equal(t, promptHash, unchanged)
+		assert.NotEqual(t, inputHash, changedInputs)
+	})
+
+	t.Run("CacheScopes", func(t *testing.T) {
+		cm := NewCacheManager(nil)
+		orgID := uuid.New()
+
+		key, err := cm.buildCacheKey(orgID, CacheScope{Level: PrivacyOrg}, "p", "i")
+		require.NoError(t, err)
+		assert.Equal(t, "cache:"+orgID.String()+":org:p:i", key)
+
+		project, err := cm.buildCacheKey(orgID, CacheScope{Level: PrivacyProject, ID: "doc-summarizer"}, "p", "i")
+		require.NoError(t, err)
+		user, err := cm.buildCacheKey(orgID, CacheScope{Level: PrivacyUser, ID: "alice"}, "p", "i")
+		require.NoError(t, err)
+		assert.NotEqual(t, key, project)
+		assert.NotEqual(t, project, user)
+
+		_, err = cm.buildCacheKey(orgID, CacheScope{Level: PrivacyUser}, "p", "i")
+		assert.Error(t, err)
+		_, err = cm.buildCacheKey(orgID, CacheScope{Level: PrivacyPublic}, "p", "i")
+		assert.Error(t, err)
+	})
 }
 
 func TestMultiArmedBandit(t *testing.T) {
@@ -418,6 +565,752 @@ func TestBatchProcessing(t *testing.T) {
 	})
 }
 
+func TestResidencyConstraints(t *testing.T) {
+	providers := []ProviderConfig{
+		{ProviderName: "openai", ModelName: "gpt-4", Region: "us-east-1", DataResidency: "us"},
+		{ProviderName: "anthropic", ModelName: "claude-3-opus", Region: "eu-west-1", DataResidency: "eu"},
+		{ProviderName: "google", ModelName: "gemini-pro"},
+	}
+
+	t.Run("NoConstraint", func(t *testing.T) {
+		allowed, denials := FilterByResidency(providers, nil)
+		assert.Len(t, allowed, 3)
+		assert.Empty(t, denials)
+	})
+
+	t.Run("DataResidency", func(t *testing.T) {
+		allowed, denials := FilterByResidency(providers, &ResidencyConstraint{DataResidency: "eu"})
+		require.Len(t, allowed, 1)
+		assert.Equal(t, "anthropic", allowed[0].ProviderName)
+		require.Len(t, denials, 2)
+		assert.Equal(t, "data_residency", denials[0].Constraint)
+		assert.Contains(t, denials[1].Reason, "unspecified")
+	})
+
+	t.Run("AllowedRegionsWildcard", func(t *testing.T) {
+		allowed, denials := FilterByResidency(providers, &ResidencyConstraint{AllowedRegions: []string{"eu-*"}})
+		require.Len(t, allowed, 1)
+		assert.Equal(t, "eu-west-1", allowed[0].Region)
+		require.Len(t, denials, 2)
+		assert.Equal(t, "region", denials[0].Constraint)
+		assert.Contains(t, FormatDenials(denials), "openai/gpt-4 denied by region constraint")
+	})
+
+	t.Run("RunsNarrowWorkflowConstraints", func(t *testing.T) {
+		workflow := &ResidencyConstraint{AllowedRegions: []string{"eu-*"}, DataResidency: "eu"}
+
+		narrowed, err := workflow.Narrow(&ResidencyConstraint{AllowedRegions: []string{"eu-west-1"}})
+		require.NoError(t, err)
+		assert.Equal(t, &ResidencyConstraint{AllowedRegions: []string{"eu-west-1"}, DataResidency: "eu"}, narrowed)
+
+		_, err = workflow.Narrow(&ResidencyConstraint{AllowedRegions: []string{"us-east-1"}})
+		assert.Error(t, err, "runs can't widen the workflow's regions")
+		_, err = workflow.Narrow(&ResidencyConstraint{DataResidency: "us"})
+		assert.Error(t, err)
+
+		narrowed, err = (*ResidencyConstraint)(nil).Narrow(&ResidencyConstraint{DataResidency: "us"})
+		require.NoError(t, err)
+		assert.Equal(t, "us", narrowed.DataResidency)
+		narrowed, err = workflow.Narrow(nil)
+		require.NoError(t, err)
+		assert.Same(t, workflow, narrowed)
+	})
+
+	t.Run("PinnedProviders", func(t *testing.T) {
+		constraint := &ResidencyConstraint{DataResidency: "eu"}
+		assert.NoError(t, checkPinnedResidency(providers, constraint, "anthropic", "claude-3-opus"))
+
+		err := checkPinnedResidency(providers, constraint, "openai", "gpt-4")
+		var violation *ResidencyViolation
+		require.ErrorAs(t, err, &violation)
+		assert.Equal(t, "data_residency", violation.Denial.Constraint)
+		assert.Equal(t, ErrorClassPolicyBlock, ClassifyError(err))
+		assert.True(t, IsUnsent(err), "violations are caught before the provider is called")
+
+		err = checkPinnedResidency(providers, constraint, "mistral", "mistral-large")
+		assert.ErrorContains(t, err, "unspecified", "unconfigured models have no known hosting")
+		assert.NoError(t, checkPinnedResidency(providers, nil, "mistral", "mistral-large"))
+	})
+}
+
+func TestProviderTelemetry(t *testing.T) {
+	t.Run("RollingStats", func(t *testing.T) {
+		records := make([]ProviderTelemetry, 0)
+		for i := 0; i < 8; i++ {
+			records = append(records, ProviderTelemetry{Latency: 400 * time.Millisecond, ErrorClass: ErrorClassNone, CostCents: 2})
+		}
+		records = append(records,
+			ProviderTelemetry{Latency: 30 * time.Second, ErrorClass: ErrorClassTimeout},
+			ProviderTelemetry{Latency: 50 * time.Millisecond, ErrorClass: ErrorClassRateLimit},
+		)
+
+		stats := ComputeRollingStats("openai", "gpt-4", records)
+		assert.Equal(t, 10, stats.Samples)
+		assert.Equal(t, 400*time.Millisecond, stats.AvgLatency)
+		assert.InDelta(t, 0.8, stats.SuccessRate, 0.0001)
+		assert.Equal(t, int64(16), stats.TotalCostCents)
+		assert.Equal(t, 1, stats.ErrorCounts[ErrorClassTimeout])
+		assert.True(t, stats.Sufficient())
+
+		assert.False(t, ComputeRollingStats("openai", "gpt-4", records[:3]).Sufficient())
+	})
+
+	t.Run("ClassifyError", func(t *testing.T) {
+		assert.Equal(t, ErrorClassNone, ClassifyError(nil))
+		assert.Equal(t, ErrorClassTimeout, ClassifyError(context.DeadlineExceeded))
+		assert.Equal(t, ErrorClassRateLimit, ClassifyError(fmt.Errorf("API error 429: slow down")))
+		assert.Equal(t, ErrorClassServer, ClassifyError(fmt.Errorf("API error 503: service unavailable")))
+		assert.Equal(t, ErrorClassOther, ClassifyError(fmt.Errorf("connection reset")))
+	})
+}
+
+func TestScopedBudgets(t *testing.T) {
+	workflow := "support-triage"
+	tag := "team=data"
+
+	t.Run("MostRestrictiveScopeApplies", func(t *testing.T) {
+		budgets := []ScopedBudget{
+			{Scope: BudgetScopeOrg, Budget: Budget{ID: uuid.New(), LimitCents: 100000, SpentCents: 10000}},
+			{Scope: BudgetScopeWorkflow, Budget: Budget{ID: uuid.New(), WorkflowName: &workflow, LimitCents: 5000, SpentCents: 4900}},
+		}
+
+		enforcement := EvaluateScopedBudgets(budgets, 0)
+		assert.True(t, enforcement.Allowed)
+		require.NotNil(t, enforcement.Effective)
+		assert.Equal(t, BudgetScopeWorkflow, enforcement.Effective.Scope)
+		assert.Equal(t, int64(100), enforcement.Effective.RemainingCents)
+		assert.Len(t, enforcement.Breakdown, 2)
+
+		enforcement = EvaluateScopedBudgets(budgets, 500)
+		assert.False(t, enforcement.Allowed)
+		assert.Equal(t, BudgetScopeWorkflow, enforcement.ThrottledBy)
+		assert.Equal(t, BudgetStatusHealthy, enforcement.Breakdown[0].Status)
+	})
+
+	t.Run("ThrottledByLeastHeadroom", func(t *testing.T) {
+		budgets := []ScopedBudget{
+			{Scope: BudgetScopeOrg, Budget: Budget{ID: uuid.New(), LimitCents: 100000, SpentCents: 99800}},
+			{Scope: BudgetScopeWorkflow, Budget: Budget{ID: uuid.New(), WorkflowName: &workflow, LimitCents: 5000, SpentCents: 5300}},
+			{Scope: BudgetScopeLabel, Budget: Budget{ID: uuid.New(), LimitCents: 2000, SpentCents: 1900}},
+		}
+
+		// All three are exceeded by the request; the overspent workflow budget has the least headroom
+		enforcement := EvaluateScopedBudgets(budgets, 500)
+		assert.False(t, enforcement.Allowed)
+		assert.Equal(t, BudgetScopeWorkflow, enforcement.ThrottledBy)
+		assert.Equal(t, BudgetScopeWorkflow, enforcement.Effective.Scope)
+
+		// Order does not matter
+		budgets[0], budgets[1] = budgets[1], budgets[0]
+		assert.Equal(t, BudgetScopeWorkflow, EvaluateScopedBudgets(budgets, 500).ThrottledBy)
+		budgets[1].Budget.SpentCents = 100500
+		assert.Equal(t, BudgetScopeOrg, EvaluateScopedBudgets(budgets, 500).ThrottledBy)
+	})
+
+	t.Run("TagMatching", func(t *testing.T) {
+		budget := &Budget{WorkflowName: &workflow, Tag: &tag}
+
+		assert.True(t, budget.AppliesTo(&BudgetScopeRequest{WorkflowName: workflow, Tags: map[string]string{"team": "data"}}))
+		assert.False(t, budget.AppliesTo(&BudgetScopeRequest{WorkflowName: workflow, Tags: map[string]string{"team": "ml"}}))
+		assert.False(t, budget.AppliesTo(&BudgetScopeRequest{WorkflowName: "other", Tags: map[string]string{"team": "data"}}))
+		assert.True(t, (&Budget{WorkflowName: &workflow}).AppliesTo(&BudgetScopeRequest{WorkflowName: workflow}))
+	})
+}
+
+func TestOptimizationSuggestionTracking(t *testing.T) {
+	t.Run("fingerprint is stable across generations", func(t *testing.T) {
+		a := OptimizationSuggestion{Type: OptimizationCaching, Title: "Implement caching", PotentialSaving: 100}
+		b := OptimizationSuggestion{Type: OptimizationCaching, Title: "Implement caching", PotentialSaving: 250}
+		c := OptimizationSuggestion{Type: OptimizationBatching, Title: "Implement caching"}
+
+		assert.Equal(t, a.Fingerprint(), b.Fingerprint())
+		assert.NotEqual(t, a.Fingerprint(), c.Fingerprint())
+	})
+
+	t.Run("realized saving", func(t *testing.T) {
+		assert.Equal(t, int64(300), computeRealizedSaving(500, 1200, 72*time.Hour))
+		assert.Equal(t, int64(0), computeRealizedSaving(400, 1500, 72*time.Hour))
+		assert.Equal(t, int64(0), computeRealizedSaving(500, 400, 0))
+
+		// Two hours after accepting, $0.30 spent against a $4.80/day baseline saved $0.10
+		assert.Equal(t, int64(10), computeRealizedSaving(480, 30, 2*time.Hour))
+
+		assert.Equal(t, 500.0, AverageDailySpend(3500, 7*24*time.Hour))
+		assert.Equal(t, 30.0, AverageDailySpend(30, 2*time.Hour), "windows under a day are not scaled up")
+	})
+
+	t.Run("report totals", func(t *testing.T) {
+		orgID := uuid.New()
+		report := summarizeSuggestions(orgID, []OptimizationSuggestion{
+			{Status: SuggestionStatusOpen, PotentialSaving: 100},
+			{Status: SuggestionStatusAccepted, PotentialSaving: 200, RealizedSaving: 150},
+			{Status: SuggestionStatusDismissed, PotentialSaving: 50},
+		}, time.Now())
+
+		assert.Equal(t, int64(100), report.OpenProjectedCents)
+		assert.Equal(t, int64(200), report.AcceptedProjectedCents)
+		assert.Equal(t, int64(150), report.RealizedSavingCents)
+		assert.Equal(t, 1, report.ByStatus[SuggestionStatusDismissed])
+	})
+}
+
+func TestRoutingRules(t *testing.T) {
+	t.Run("parse expression", func(t *testing.T) {
+		rule, err := ParseRoutingRule("triage", "if workflow=support-triage and tokens<500 then model=haiku")
+		require.NoError(t, err)
+		require.Len(t, rule.Conditions, 2)
+		assert.Equal(t, RuleCondition{Field: "workflow", Operator: RuleOpEq, Value: "support-triage"}, rule.Conditions[0])
+		assert.Equal(t, RuleCondition{Field: "tokens", Operator: RuleOpLt, Value: "500"}, rule.Conditions[1])
+		assert.Equal(t, "haiku", rule.Action.ModelName)
+
+		rule, err = ParseRoutingRule("tiers", "IF quality in (Bronze, Silver) THEN provider=openai, model=gpt-3.5")
+		require.NoError(t, err)
+		assert.Equal(t, RuleOpIn, rule.Conditions[0].Operator)
+		assert.Equal(t, "openai", rule.Action.ProviderName)
+
+		_, err = ParseRoutingRule("bad", "workflow=x then model=y")
+		assert.Error(t, err)
+		_, err = ParseRoutingRule("bad", "if tokens<many then model=y")
+		assert.Error(t, err)
+		_, err = ParseRoutingRule("bad", "if tokens<5 then region=eu")
+		assert.Error(t, err)
+	})
+
+	providers := []ProviderConfig{
+		{ProviderName: "openai", ModelName: "gpt-4", CostPerTokenPrompt: 0.03, CostPerTokenCompletion: 0.06},
+		{ProviderName: "anthropic", ModelName: "claude-3-haiku", CostPerTokenPrompt: 0.00025, CostPerTokenCompletion: 0.00125},
+	}
+	rule, err := ParseRoutingRule("triage", "if workflow=support-triage and tokens<500 then model=haiku")
+	require.NoError(t, err)
+	rules := []RoutingRule{*rule}
+
+	t.Run("apply narrows providers", func(t *testing.T) {
+		filtered, matched := ApplyRoutingRules(rules, &RoutingRequest{WorkflowName: "support-triage", PromptTokens: 200}, providers)
+		require.NotNil(t, matched)
+		require.Len(t, filtered, 1)
+		assert.Equal(t, "claude-3-haiku", filtered[0].ModelName)
+
+		filtered, matched = ApplyRoutingRules(rules, &RoutingRequest{WorkflowName: "support-triage", PromptTokens: 800}, providers)
+		assert.Nil(t, matched)
+		assert.Len(t, filtered, 2)
+	})
+
+	t.Run("simulation", func(t *testing.T) {
+		samples := []ProviderTelemetry{
+			{ProviderName: "openai", ModelName: "gpt-4", WorkflowName: "support-triage", TokensUsed: 300, CostCents: 1000},
+			{ProviderName: "openai", ModelName: "gpt-4", WorkflowName: "research", TokensUsed: 300, CostCents: 1000},
+		}
+		quality := func(p ProviderConfig, _ QualityTier) float64 {
+			if p.ModelName == "gpt-4" {
+				return 1.0
+			}
+			return 0.8
+		}
+
+		sim := simulateRules(rules, samples, providers, quality)
+		assert.Equal(t, 2, sim.Requests)
+		assert.Equal(t, 1, sim.Matched)
+		assert.Equal(t, int64(2000), sim.BaselineCostCents)
+		assert.Less(t, sim.ProjectedCostCents, sim.BaselineCostCents)
+		assert.InDelta(t, 0.9, sim.ProjectedQuality, 0.001)
+		assert.InDelta(t, -0.2, sim.ByRule["triage"].QualityDelta, 0.001)
+	})
+}
+
+func TestModelPolicy(t *testing.T) {
+	policy := &ModelPolicy{
+		Allow: []string{"anthropic/*", "openai/gpt-4*"},
+		Deny:  []string{"anthropic/*-opus"},
+	}
+
+	t.Run("allow and deny", func(t *testing.T) {
+		assert.Nil(t, policy.Check("anthropic", "claude-3-haiku"))
+		assert.Nil(t, policy.Check("OpenAI", "gpt-4-turbo"))
+
+		v := policy.Check("anthropic", "claude-3-opus")
+		require.NotNil(t, v)
+		assert.Equal(t, "deny:anthropic/*-opus", v.Rule)
+		assert.Contains(t, v.Error(), "anthropic/claude-3-opus")
+
+		v = policy.Check("google", "gemini-pro")
+		require.NotNil(t, v)
+		assert.Equal(t, "allow", v.Rule)
+	})
+
+	t.Run("nil policy permits everything", func(t *testing.T) {
+		var none *ModelPolicy
+		assert.NoError(t, none.CheckModel("cohere", "command"))
+	})
+
+	t.Run("filter providers", func(t *testing.T) {
+		allowed, denials := FilterByModelPolicy([]ProviderConfig{
+			{ProviderName: "anthropic", ModelName: "claude-3-haiku"},
+			{ProviderName: "anthropic", ModelName: "claude-3-opus"},
+			{ProviderName: "cohere", ModelName: "command"},
+		}, policy)
+		assert.Len(t, allowed, 1)
+		assert.Len(t, denials, 2)
+		assert.Equal(t, "model_policy", denials[0].Constraint)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		assert.NoError(t, policy.Validate())
+		assert.Error(t, (&ModelPolicy{Deny: []string{"openai/[gpt"}}).Validate())
+	})
+}
+
+func TestLatencyTracker(t *testing.T) {
+	t.Run("percentiles within histogram precision", func(t *testing.T) {
+		tracker := NewLatencyTracker()
+		for i := 1; i <= 1000; i++ {
+			tracker.Record("openai", "gpt-4", time.Duration(i)*time.Millisecond)
+		}
+
+		p := tracker.Percentiles("openai", "gpt-4")
+		assert.Equal(t, uint64(1000), p.Count)
+		assert.InEpsilon(t, float64(500*time.Millisecond), float64(p.P50), 0.03)
+		assert.InEpsilon(t, float64(950*time.Millisecond), float64(p.P95), 0.03)
+		assert.InEpsilon(t, float64(990*time.Millisecond), float64(p.P99), 0.03)
+		assert.Equal(t, 1000*time.Millisecond, p.Max)
+		assert.Equal(t, time.Duration(500500)*time.Microsecond, p.Mean)
+	})
+
+	t.Run("unknown series is empty", func(t *testing.T) {
+		p := NewLatencyTracker().Percentiles("openai", "gpt-4")
+		assert.Zero(t, p.Count)
+		assert.Zero(t, p.P99)
+	})
+
+	t.Run("concurrent recording", func(t *testing.T) {
+		tracker := NewLatencyTracker()
+		bandit := NewMultiArmedBandit()
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					tracker.Record("anthropic", "claude-3", 100*time.Millisecond)
+					bandit.UpdateReward("anthropic", "claude-3", 0.5)
+					_ = tracker.Percentiles("anthropic", "claude-3")
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, uint64(4000), tracker.Percentiles("anthropic", "claude-3").Count)
+		assert.Equal(t, 4000, bandit.GetArmStats()["anthropic:claude-3"].Pulls)
+	})
+
+	t.Run("rolling stats percentiles", func(t *testing.T) {
+		records := make([]ProviderTelemetry, 0, 101)
+		for i := 1; i <= 100; i++ {
+			records = append(records, ProviderTelemetry{Latency: time.Duration(i) * time.Millisecond, ErrorClass: ErrorClassNone})
+		}
+		records = append(records, ProviderTelemetry{Latency: time.Minute, ErrorClass: ErrorClassTimeout})
+
+		stats := ComputeRollingStats("openai", "gpt-4", records)
+		assert.Equal(t, 50*time.Millisecond, stats.P50Latency)
+		assert.Equal(t, 95*time.Millisecond, stats.P95Latency)
+		assert.Equal(t, 99*time.Millisecond, stats.P99Latency)
+	})
+}
+
+func TestClusterMetrics(t *testing.T) {
+	t.Run("merged replica buckets match a single histogram", func(t *testing.T) {
+		local := NewLatencyTracker()
+		replicas := []map[string]uint64{{}, {}}
+
+		for i := 1; i <= 200; i++ {
+			latency := time.Duration(i*5) * time.Millisecond
+			local.Record("openai", "gpt-4", latency)
+
+			// Alternate samples between two replicas the way HINCRBY would store them
+			fields := replicas[i%2]
+			fields[strconv.Itoa(latencyBucket(latency))]++
+			fields[clusterLatencySumField] += uint64(latency.Microseconds())
+		}
+
+		merged := make(map[string]string)
+		for _, fields := range replicas {
+			for field, n := range fields {
+				prev, _ := strconv.ParseUint(merged[field], 10, 64)
+				merged[field] = strconv.FormatUint(prev+n, 10)
+			}
+		}
+
+		want := local.Percentiles("openai", "gpt-4")
+		got := histogramFromFields(merged).percentiles()
+		assert.Equal(t, want.Count, got.Count)
+		assert.Equal(t, want.Mean, got.Mean)
+		assert.Equal(t, want.P50, got.P50)
+		assert.Equal(t, want.P95, got.P95)
+		assert.InEpsilon(t, float64(want.P99), float64(got.P99), latencyPrecision)
+	})
+
+	t.Run("collectors are empty until enabled", func(t *testing.T) {
+		assert.Nil(t, collectClusterRequests())
+		assert.Nil(t, collectClusterLatency())
+	})
+
+	t.Run("cluster series are exposed at /metrics", func(t *testing.T) {
+		var buf strings.Builder
+		require.NoError(t, Registry.WriteText(&buf))
+		assert.Contains(t, buf.String(), "# TYPE agentflow_cluster_provider_requests_total counter")
+		assert.Contains(t, buf.String(), "# TYPE agentflow_cluster_provider_latency_seconds gauge")
+	})
+}
+
+func TestFeedbackReward(t *testing.T) {
+	router := NewProviderRouter(nil, nil)
+
+	t.Run("ApprovalRewardsArm", func(t *testing.T) {
+		router.RecordFeedback("openai", "gpt-4", true)
+		router.RecordFeedback("openai", "gpt-4", true)
+
+		arm := router.bandit.GetArmStats()["openai:gpt-4"]
+		assert.Equal(t, 2, arm.Pulls)
+		assert.InDelta(t, 1.0, arm.AverageReward, 0.001)
+	})
+
+	t.Run("RejectionPenalizesArm", func(t *testing.T) {
+		router.RecordFeedback("anthropic", "claude-3-haiku", true)
+		router.RecordFeedback("anthropic", "claude-3-haiku", false)
+
+		arm := router.bandit.GetArmStats()["anthropic:claude-3-haiku"]
+		assert.Equal(t, 2, arm.Pulls)
+		assert.InDelta(t, 0.0, arm.AverageReward, 0.001)
+	})
+}
+
+func TestCostLabels(t *testing.T) {
+	t.Run("Validation", func(t *testing.T) {
+		assert.NoError(t, ValidateCostLabels(nil))
+		assert.NoError(t, ValidateCostLabels(map[string]string{"team": "search", "customer_id": "acme-42"}))
+		assert.Error(t, ValidateCostLabels(map[string]string{"Team": "search"}))
+		assert.Error(t, ValidateCostLabels(map[string]string{"team": ""}))
+		assert.Error(t, ValidateCostLabels(map[string]string{"team name": "search"}))
+
+		tooMany := make(map[string]string)
+		for i := 0; i <= maxCostLabels; i++ {
+			tooMany[fmt.Sprintf("label%d", i)] = "x"
+		}
+		assert.Error(t, ValidateCostLabels(tooMany))
+	})
+
+	t.Run("LabelBudgetMatching", func(t *testing.T) {
+		exact := "team=search"
+		anyValue := "customer_id"
+		labels := map[string]string{"team": "search", "customer_id": "acme"}
+
+		assert.True(t, (&Budget{Label: &exact}).MatchesLabels(labels))
+		assert.False(t, (&Budget{Label: &exact}).MatchesLabels(map[string]string{"team": "ads"}))
+		assert.True(t, (&Budget{Label: &anyValue}).MatchesLabels(labels))
+		assert.False(t, (&Budget{Label: &anyValue}).MatchesLabels(map[string]string{"team": "search"}))
+		assert.False(t, (&Budget{}).MatchesLabels(labels))
+	})
+
+	t.Run("LabelBudgetThrottles", func(t *testing.T) {
+		label := "team=search"
+		budgets := []ScopedBudget{
+			{Scope: BudgetScopeOrg, Budget: Budget{ID: uuid.New(), LimitCents: 100000}},
+			{Scope: BudgetScopeLabel, Budget: Budget{ID: uuid.New(), Label: &label, LimitCents: 1000, SpentCents: 990}},
+		}
+
+		enforcement := EvaluateScopedBudgets(budgets, 50)
+		assert.False(t, enforcement.Allowed)
+		assert.Equal(t, BudgetScopeLabel, enforcement.ThrottledBy)
+	})
+}
+
+func TestPromptCachePricing(t *testing.T) {
+	t.Run("ReadsAreDiscounted", func(t *testing.T) {
+		cost, saving := PricePromptTokens("anthropic", 2000, PromptCacheUsage{ReadTokens: 1500}, 0.1)
+		assert.InDelta(t, 65, cost, 0.001) // 500 regular + 1500 at 10%
+		assert.InDelta(t, 135, saving, 0.001)
+	})
+
+	t.Run("WritesCostExtra", func(t *testing.T) {
+		cost, saving := PricePromptTokens("anthropic", 2000, PromptCacheUsage{WriteTokens: 1500}, 0.1)
+		assert.InDelta(t, 237.5, cost, 0.001)
+		assert.InDelta(t, -37.5, saving, 0.001)
+	})
+
+	t.Run("ProvidersWithoutCacheBillEveryToken", func(t *testing.T) {
+		cost, saving := PricePromptTokens("cohere", 2000, PromptCacheUsage{ReadTokens: 1500}, 0.1)
+		assert.InDelta(t, 200, cost, 0.001)
+		assert.Zero(t, saving)
+
+		_, ok := ProviderPromptCachePricing("OpenAI")
+		assert.True(t, ok)
+	})
+}
+
+type fakeProber struct {
+	down map[string]bool
+}
+
+func (f *fakeProber) Ping(ctx context.Context, provider ProviderConfig) error {
+	if f.down[provider.ProviderName] {
+		return fmt.Errorf("health check returned 503")
+	}
+	return nil
+}
+
+func TestProviderHealth(t *testing.T) {
+	openai := ProviderConfig{ProviderName: "openai", ModelName: "gpt-4"}
+	anthropic := ProviderConfig{ProviderName: "anthropic", ModelName: "claude-3-haiku"}
+
+	t.Run("ConsecutivePingFailures", func(t *testing.T) {
+		prober := &fakeProber{down: map[string]bool{"openai": true}}
+		checker := NewHealthChecker(prober)
+
+		for i := 0; i < unhealthyPingFailures-1; i++ {
+			checker.Check(context.Background(), []ProviderConfig{openai, anthropic})
+		}
+		assert.True(t, checker.IsHealthy("openai", "gpt-4"))
+
+		checker.Check(context.Background(), []ProviderConfig{openai, anthropic})
+		assert.False(t, checker.IsHealthy("openai", "gpt-4"))
+		assert.True(t, checker.IsHealthy("anthropic", "claude-3-haiku"))
+
+		prober.down["openai"] = false
+		checker.Check(context.Background(), []ProviderConfig{openai})
+		assert.True(t, checker.IsHealthy("openai", "gpt-4"))
+	})
+
+	t.Run("CallErrorRate", func(t *testing.T) {
+		checker := NewHealthChecker(&fakeProber{})
+		for i := 0; i < healthMinCalls; i++ {
+			checker.ObserveCall("openai", "gpt-4", ErrorClassRateLimit)
+		}
+		assert.True(t, checker.IsHealthy("openai", "gpt-4"), "rate limits are not outages")
+
+		for i := 0; i < healthMinCalls; i++ {
+			checker.ObserveCall("openai", "gpt-4", ErrorClassServer)
+		}
+		health := checker.Health()
+		require.Len(t, health, 1)
+		assert.False(t, health[0].Healthy)
+		assert.InDelta(t, 0.5, health[0].ErrorRate, 0.001)
+	})
+
+	t.Run("SelectionSkipsUnhealthy", func(t *testing.T) {
+		router := NewProviderRouter(nil, nil)
+		router.health = NewHealthChecker(&fakeProber{down: map[string]bool{"openai": true}})
+		for i := 0; i < unhealthyPingFailures; i++ {
+			router.health.Check(context.Background(), []ProviderConfig{openai})
+		}
+
+		_, err := router.SelectOptimalProvider(context.Background(), &RoutingRequest{}, []ProviderConfig{openai}, nil)
+		assert.ErrorContains(t, err, "no healthy providers")
+	})
+
+	t.Run("FailoverOnServerError", func(t *testing.T) {
+		router := NewProviderRouter(nil, nil)
+		response := &RoutingResponse{ProviderName: "openai", ModelName: "gpt-4", Alternatives: []Alternative{
+			{ProviderName: "anthropic", ModelName: "claude-3-haiku"},
+		}}
+
+		called := make([]string, 0)
+		attempts, err := router.CallWithFailover(context.Background(), response, func(ctx context.Context, provider, model string) error {
+			called = append(called, provider)
+			if provider == "openai" {
+				return fmt.Errorf("API error 503: service unavailable")
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"openai", "anthropic"}, called)
+		require.Len(t, attempts, 2)
+		assert.Equal(t, ErrorClassServer, attempts[0].ErrorClass)
+		assert.Equal(t, ErrorClassNone, attempts[1].ErrorClass)
+	})
+
+	t.Run("NoFailoverOnInvalidRequest", func(t *testing.T) {
+		router := NewProviderRouter(nil, nil)
+		response := &RoutingResponse{ProviderName: "openai", ModelName: "gpt-4", Alternatives: []Alternative{
+			{ProviderName: "anthropic", ModelName: "claude-3-haiku"},
+		}}
+
+		attempts, err := router.CallWithFailover(context.Background(), response, func(ctx context.Context, provider, model string) error {
+			return fmt.Errorf("API error 400: invalid request")
+		})
+		assert.Error(t, err)
+		assert.Len(t, attempts, 1)
+	})
+
+	t.Run("FailoverRecordsEachAttempt", func(t *testing.T) {
+		router := NewProviderRouter(nil, nil)
+		response := &RoutingResponse{ProviderName: "openai", ModelName: "gpt-4"}
+		for i := 0; i < healthMinCalls; i++ {
+			_, err := router.CallWithFailover(context.Background(), response, func(ctx context.Context, provider, model string) error {
+				return fmt.Errorf("API error 503: service unavailable")
+			})
+			assert.Error(t, err)
+		}
+
+		health := router.GetProviderHealth()
+		require.Len(t, health, 1)
+		assert.Equal(t, healthMinCalls, health[0].Calls)
+		assert.False(t, health[0].Healthy)
+	})
+
+	t.Run("FailoverRouteRanksAlternatives", func(t *testing.T) {
+		router := NewProviderRouter(nil, nil)
+		router.health = NewHealthChecker(&fakeProber{down: map[string]bool{"cohere": true}})
+		cohere := ProviderConfig{ProviderName: "cohere", ModelName: "command-r"}
+		for i := 0; i < unhealthyPingFailures; i++ {
+			router.health.Check(context.Background(), []ProviderConfig{cohere})
+		}
+		providers := []ProviderConfig{
+			openai,
+			anthropic,
+			cohere,
+			{ProviderName: "google", ModelName: "gemini-pro"},
+			{ProviderName: "openai", ModelName: "gpt-3.5-turbo"},
+			{ProviderName: "anthropic", ModelName: "claude-3-sonnet"},
+		}
+
+		response := router.rankFailover(context.Background(), &RoutingRequest{}, providers, "openai", "gpt-4")
+		assert.Equal(t, "openai", response.ProviderName)
+		assert.Equal(t, "gpt-4", response.ModelName)
+		require.Len(t, response.Alternatives, failoverAlternatives)
+		for _, alternative := range response.Alternatives {
+			assert.False(t, alternative.ProviderName == "openai" && alternative.ModelName == "gpt-4", "the pinned provider is not its own alternative")
+			assert.NotEqual(t, "cohere", alternative.ProviderName, "unhealthy providers are skipped")
+		}
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	provider := ProviderConfig{ProviderName: "openai", ModelName: "gpt-4", QPSLimit: 2}
+
+	t.Run("TokenBucketRefills", func(t *testing.T) {
+		now := time.Now()
+		bucket := &tokenBucket{rate: 2, burst: 2, tokens: 2, last: now}
+
+		for i := 0; i < 2; i++ {
+			wait, ok := bucket.reserve(now, 0)
+			assert.True(t, ok)
+			assert.Zero(t, wait)
+		}
+		wait, ok := bucket.reserve(now, 0)
+		assert.False(t, ok)
+		assert.Equal(t, 500*time.Millisecond, wait)
+		assert.InDelta(t, 1.0, bucket.utilization(now), 0.001)
+
+		wait, ok = bucket.reserve(now.Add(500*time.Millisecond), 0)
+		assert.True(t, ok)
+		assert.Zero(t, wait)
+	})
+
+	t.Run("RejectsOverLimit", func(t *testing.T) {
+		limiter := NewRateLimiter(0)
+		limiter.Configure(provider)
+
+		require.NoError(t, limiter.Wait(context.Background(), "openai", "gpt-4"))
+		require.NoError(t, limiter.Wait(context.Background(), "openai", "gpt-4"))
+		err := limiter.Wait(context.Background(), "openai", "gpt-4")
+
+		var limited *RateLimitError
+		require.ErrorAs(t, err, &limited)
+		assert.Equal(t, 2, limited.LimitQPS)
+		assert.Equal(t, ErrorClassRateLimit, ClassifyError(err))
+	})
+
+	t.Run("QueuesWithinMaxWait", func(t *testing.T) {
+		limiter := NewRateLimiter(time.Second)
+		limiter.Configure(ProviderConfig{ProviderName: "openai", ModelName: "gpt-4", QPSLimit: 20})
+		for i := 0; i < 20; i++ {
+			require.NoError(t, limiter.Wait(context.Background(), "openai", "gpt-4"))
+		}
+
+		start := time.Now()
+		require.NoError(t, limiter.Wait(context.Background(), "openai", "gpt-4"))
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("UnlimitedWithoutQPSLimit", func(t *testing.T) {
+		limiter := NewRateLimiter(0)
+		limiter.Configure(ProviderConfig{ProviderName: "anthropic", ModelName: "claude-3-haiku"})
+		for i := 0; i < 100; i++ {
+			require.NoError(t, limiter.Wait(context.Background(), "anthropic", "claude-3-haiku"))
+		}
+		assert.Empty(t, limiter.Utilization())
+	})
+}
+
+func TestProviderForecast(t *testing.T) {
+	t.Run("limits", func(t *testing.T) {
+		limits := providerLimits(ProviderConfig{ProviderName: "openai", ModelName: "gpt-4o",
+			Config: map[string]interface{}{"tpm_limit": float64(800000)}, QPSLimit: 50})
+		assert.Equal(t, int64(800000), limits.TPM)
+		assert.Equal(t, int64(3000), limits.RPM, "rpm falls back to the QPS limit")
+
+		limits = providerLimits(ProviderConfig{Config: map[string]interface{}{"rpm_limit": float64(500)}, QPSLimit: 50})
+		assert.Equal(t, int64(500), limits.RPM)
+		assert.Zero(t, limits.TPM)
+	})
+
+	t.Run("peak", func(t *testing.T) {
+		flat := make([]float64, 60)
+		rising := make([]float64, 60)
+		for i := range flat {
+			flat[i] = 1000
+			rising[i] = float64(1000 + 10*i)
+		}
+		assert.InDelta(t, 1000, forecastPeak(flat, 60), 0.001)
+		assert.GreaterOrEqual(t, forecastPeak(rising, 60), 1000+10*119-0.001, "a rising trend is extrapolated to the horizon")
+		assert.Greater(t, forecastPeak(rising, 60), forecastPeak(rising, 0))
+		assert.Zero(t, forecastPeak(make([]float64, 60), 60))
+
+		bursty := make([]float64, 60)
+		for i := range bursty {
+			bursty[i] = 1000
+			if i%10 == 0 {
+				bursty[i] = 5000
+			}
+		}
+		assert.Greater(t, forecastPeak(bursty, 60), 1400.0, "bursty traffic forecasts its peaks, not its mean")
+	})
+
+	t.Run("alerts and suggestions", func(t *testing.T) {
+		now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		usage := map[string][]UsageMinute{}
+		for i := 1; i <= 60; i++ {
+			minute := now.Add(-time.Duration(i) * time.Minute)
+			usage["openai:gpt-4o"] = append(usage["openai:gpt-4o"], UsageMinute{Minute: minute, Tokens: 90000, Requests: 100})
+			usage["anthropic:claude"] = append(usage["anthropic:claude"], UsageMinute{Minute: minute, Tokens: 10000, Requests: 10})
+		}
+		limits := []ProviderLimits{
+			{ProviderName: "anthropic", ModelName: "claude", TPM: 400000, RPM: 4000},
+			{ProviderName: "openai", ModelName: "gpt-4o", TPM: 100000, RPM: 10000},
+			{ProviderName: "cohere", ModelName: "command"},
+		}
+
+		forecasts := buildProviderForecasts(usage, limits, time.Hour, time.Hour, now)
+		require.Len(t, forecasts, 3)
+		hot := forecasts[0]
+		assert.Equal(t, "gpt-4o", hot.ModelName, "forecasts are ordered by utilization")
+		assert.Equal(t, CapacityWarning, hot.Level)
+		assert.InDelta(t, 90000, hot.ForecastTPM, 1)
+		assert.InDelta(t, 90000, hot.CurrentTPM, 1)
+		require.Len(t, hot.Suggestions, 2)
+		assert.Contains(t, hot.Suggestions[0], "to at least 113000")
+		assert.Contains(t, hot.Suggestions[1], "anthropic/claude")
+
+		assert.Equal(t, CapacityOK, forecasts[1].Level)
+		assert.Empty(t, forecasts[1].Suggestions)
+		assert.Equal(t, CapacityOK, forecasts[2].Level, "models without limits or traffic never alert")
+
+		limits[1].TPM = 90000
+		forecasts = buildProviderForecasts(usage, limits, time.Hour, time.Hour, now)
+		assert.Equal(t, CapacityCritical, forecasts[0].Level)
+	})
+}
+
 // Benchmark tests
 func BenchmarkProviderSelection(b *testing.B) {
 	bandit := NewMultiArmedBandit()
@@ -0,0 +1,249 @@
+package cas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// telemetryWindowSize is the number of recent calls kept per provider/model
+	telemetryWindowSize = 200
+	// telemetryMinSamples is the number of samples required before rolling
+	// data replaces the static estimates
+	telemetryMinSamples = 10
+)
+
+// TelemetryStore persists per-call provider telemetry and maintains rolling
+// windows used by the router for latency and reliability estimates
+type TelemetryStore struct {
+	postgres *db.PostgresDB
+	redis    *redis.Client
+	latency  *LatencyTracker
+}
+
+func NewTelemetryStore(pg *db.PostgresDB, redisClient *redis.Client) *TelemetryStore {
+	return &TelemetryStore{
+		postgres: pg,
+		redis:    redisClient,
+		latency:  NewLatencyTracker(),
+	}
+}
+
+// Record stores a telemetry sample and appends it to the rolling window
+func (ts *TelemetryStore) Record(ctx context.Context, record *ProviderTelemetry) error {
+	if record.ID == uuid.Nil {
+		record.ID = uuid.New()
+	}
+	if record.RecordedAt.IsZero() {
+		record.RecordedAt = time.Now()
+	}
+
+	ts.observe(record)
+
+	if ts.postgres != nil {
+		var orgID interface{}
+		if record.OrgID != uuid.Nil {
+			orgID = record.OrgID
+		}
+
+		labels := record.Labels
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labelsJSON, err := json.Marshal(labels)
+		if err != nil {
+			return fmt.Errorf("failed to marshal labels: %w", err)
+		}
+
+		query := `INSERT INTO provider_telemetry (id, org_id, provider_name, model_name, latency_ms,
+				  error_class, cost_cents, tokens_used, workflow_name, quality_tier, labels, recorded_at,
+				  prompt_tokens, cache_read_tokens, cache_write_tokens, cache_saving_cents, reasoning_tokens, reasoning_cents)
+				  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`
+
+		_, err = ts.postgres.ExecContext(ctx, query,
+			record.ID, orgID, record.ProviderName, record.ModelName, record.Latency.Milliseconds(),
+			record.ErrorClass, record.CostCents, record.TokensUsed, record.WorkflowName, record.QualityTier,
+			labelsJSON, record.RecordedAt,
+			record.PromptTokens, record.CacheReadTokens, record.CacheWriteTokens, record.CacheSavingCents,
+			record.ReasoningTokens, record.ReasoningCents,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert telemetry: %w", err)
+		}
+	}
+
+	if ts.redis != nil {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal telemetry: %w", err)
+		}
+
+		key := ts.buildWindowKey(record.ProviderName, record.ModelName)
+		pipe := ts.redis.Pipeline()
+		pipe.LPush(ctx, key, data)
+		pipe.LTrim(ctx, key, 0, telemetryWindowSize-1)
+		pipe.Expire(ctx, key, 24*time.Hour)
+		if clusterRedis.Load() != nil {
+			errorClass := record.ErrorClass
+			if errorClass == "" {
+				errorClass = ErrorClassNone
+			}
+			mirrorClusterMetrics(ctx, pipe, record, errorClass)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to update telemetry window: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// LatencyPercentiles returns the latency distribution observed by this process
+// since startup, independent of the rolling window
+func (ts *TelemetryStore) LatencyPercentiles(providerName, modelName string) LatencyPercentiles {
+	return ts.latency.Percentiles(providerName, modelName)
+}
+
+// observe feeds successful call latencies into the in-process histograms and
+// refreshes the exported percentile gauges
+func (ts *TelemetryStore) observe(record *ProviderTelemetry) {
+	errorClass := record.ErrorClass
+	if errorClass == "" {
+		errorClass = ErrorClassNone
+	}
+	providerRequests.Inc(record.ProviderName, record.ModelName, string(errorClass))
+
+	if errorClass != ErrorClassNone {
+		return
+	}
+
+	p := ts.latency.Record(record.ProviderName, record.ModelName, record.Latency)
+	providerLatency.Set(p.P50.Seconds(), record.ProviderName, record.ModelName, "0.5")
+	providerLatency.Set(p.P95.Seconds(), record.ProviderName, record.ModelName, "0.95")
+	providerLatency.Set(p.P99.Seconds(), record.ProviderName, record.ModelName, "0.99")
+}
+
+// GetRollingStats returns aggregate statistics over the rolling window
+func (ts *TelemetryStore) GetRollingStats(ctx context.Context, providerName, modelName string) (*RollingStats, error) {
+	if ts.redis == nil {
+		return &RollingStats{ProviderName: providerName, ModelName: modelName}, nil
+	}
+
+	values, err := ts.redis.LRange(ctx, ts.buildWindowKey(providerName, modelName), 0, -1).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read telemetry window: %w", err)
+	}
+
+	records := make([]ProviderTelemetry, 0, len(values))
+	for _, value := range values {
+		var record ProviderTelemetry
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return ComputeRollingStats(providerName, modelName, records), nil
+}
+
+// ComputeRollingStats aggregates a window of telemetry samples
+func ComputeRollingStats(providerName, modelName string, records []ProviderTelemetry) *RollingStats {
+	stats := &RollingStats{
+		ProviderName: providerName,
+		ModelName:    modelName,
+		ErrorCounts:  make(map[ErrorClass]int),
+	}
+
+	var totalLatency time.Duration
+	latencies := make([]time.Duration, 0, len(records))
+	for _, record := range records {
+		stats.Samples++
+		stats.TotalCostCents += record.CostCents
+		if record.ErrorClass == ErrorClassNone || record.ErrorClass == "" {
+			latencies = append(latencies, record.Latency)
+			totalLatency += record.Latency
+			continue
+		}
+		stats.ErrorCounts[record.ErrorClass]++
+	}
+
+	successes := len(latencies)
+	if successes > 0 {
+		stats.AvgLatency = totalLatency / time.Duration(successes)
+		stats.P50Latency, stats.P95Latency, stats.P99Latency = exactPercentiles(latencies)
+	}
+	if stats.Samples > 0 {
+		stats.SuccessRate = float64(successes) / float64(stats.Samples)
+	}
+
+	return stats
+}
+
+// Sufficient reports whether the window holds enough samples to trust
+func (rs *RollingStats) Sufficient() bool {
+	return rs != nil && rs.Samples >= telemetryMinSamples
+}
+
+// ClassifiedError is implemented by errors that carry their own class, such
+// as refusals detected from a provider response body
+type ClassifiedError interface {
+	error
+	ErrorClass() ErrorClass
+}
+
+// UnsentError is implemented by errors that stop a call before it reaches the
+// provider, such as budget rejections and throttles. They say nothing about
+// the provider, so they are kept out of its telemetry.
+type UnsentError interface {
+	error
+	Unsent()
+}
+
+// IsUnsent reports whether err stopped a call before it reached the provider
+func IsUnsent(err error) bool {
+	var unsent UnsentError
+	return errors.As(err, &unsent)
+}
+
+// ClassifyError maps a provider call error to an error class
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassNone
+	}
+
+	var classified ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.ErrorClass()
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "deadline"):
+		return ErrorClassTimeout
+	case strings.Contains(msg, "rate limit") || strings.Contains(msg, "429"):
+		return ErrorClassRateLimit
+	case strings.Contains(msg, "500") || strings.Contains(msg, "502") ||
+		strings.Contains(msg, "503") || strings.Contains(msg, "unavailable"):
+		return ErrorClassServer
+	case strings.Contains(msg, "400") || strings.Contains(msg, "invalid"):
+		return ErrorClassInvalidRequest
+	default:
+		return ErrorClassOther
+	}
+}
+
+func (ts *TelemetryStore) buildWindowKey(providerName, modelName string) string {
+	return fmt.Sprintf("telemetry:%s:%s", providerName, modelName)
+}
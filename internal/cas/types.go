@@ -3,20 +3,30 @@ package cas
 import (
 	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
 	"github.com/google/uuid"
 )
 
 // Budget represents spending limits and controls
 type Budget struct {
-	ID          uuid.UUID  `json:"id" db:"id"`
-	OrgID       uuid.UUID  `json:"org_id" db:"org_id"`
-	ProjectID   *uuid.UUID `json:"project_id" db:"project_id"`
-	PeriodType  PeriodType `json:"period_type" db:"period_type"`
-	LimitCents  int64      `json:"limit_cents" db:"limit_cents"`
-	SpentCents  int64      `json:"spent_cents" db:"spent_cents"`
-	PeriodStart time.Time  `json:"period_start" db:"period_start"`
-	PeriodEnd   time.Time  `json:"period_end" db:"period_end"`
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	ID           uuid.UUID  `json:"id" db:"id"`
+	OrgID        uuid.UUID  `json:"org_id" db:"org_id"`
+	ProjectID    *uuid.UUID `json:"project_id" db:"project_id"`
+	WorkflowName *string    `json:"workflow_name,omitempty" db:"workflow_name"`
+	Tag          *string    `json:"tag,omitempty" db:"tag"`
+	Label        *string    `json:"label,omitempty" db:"label"`
+	PeriodType   PeriodType `json:"period_type" db:"period_type"`
+	LimitCents   int64      `json:"limit_cents" db:"limit_cents"`
+	SpentCents   int64      `json:"spent_cents" db:"spent_cents"`
+	PeriodStart  time.Time  `json:"period_start" db:"period_start"`
+	PeriodEnd    time.Time  `json:"period_end" db:"period_end"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// BudgetPage is one page of budgets, newest first
+type BudgetPage struct {
+	Budgets []Budget `json:"budgets"`
+	db.PageMeta
 }
 
 type PeriodType string
@@ -27,6 +37,44 @@ const (
 	PeriodMonthly PeriodType = "monthly"
 )
 
+type BudgetScope string
+
+const (
+	BudgetScopeOrg      BudgetScope = "org"
+	BudgetScopeProject  BudgetScope = "project"
+	BudgetScopeWorkflow BudgetScope = "workflow"
+	BudgetScopeLabel    BudgetScope = "label"
+)
+
+// BudgetScopeRequest identifies the run whose applicable budgets are checked
+type BudgetScopeRequest struct {
+	OrgID        uuid.UUID         `json:"org_id"`
+	ProjectID    *uuid.UUID        `json:"project_id,omitempty"`
+	WorkflowName string            `json:"workflow_name,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// ScopedBudget is a budget together with the scope it applies at
+type ScopedBudget struct {
+	Scope  BudgetScope `json:"scope"`
+	Budget Budget      `json:"budget"`
+}
+
+// ScopedBudgetStatus is the status of a single budget scope
+type ScopedBudgetStatus struct {
+	Scope BudgetScope `json:"scope"`
+	BudgetStatus
+}
+
+// BudgetEnforcement is the combined outcome of checking all budget scopes
+type BudgetEnforcement struct {
+	Allowed     bool                 `json:"allowed"`
+	ThrottledBy BudgetScope          `json:"throttled_by,omitempty"`
+	Effective   *ScopedBudgetStatus  `json:"effective,omitempty"`
+	Breakdown   []ScopedBudgetStatus `json:"breakdown"`
+}
+
 // ProviderConfig represents configuration for a model provider
 type ProviderConfig struct {
 	ID                     uuid.UUID              `json:"id" db:"id"`
@@ -37,6 +85,8 @@ type ProviderConfig struct {
 	CostPerTokenPrompt     float64                `json:"cost_per_token_prompt" db:"cost_per_token_prompt"`
 	CostPerTokenCompletion float64                `json:"cost_per_token_completion" db:"cost_per_token_completion"`
 	QPSLimit               int                    `json:"qps_limit" db:"qps_limit"`
+	Region                 string                 `json:"region,omitempty" db:"region"`
+	DataResidency          string                 `json:"data_residency,omitempty" db:"data_residency"`
 	Enabled                bool                   `json:"enabled" db:"enabled"`
 	CreatedAt              time.Time              `json:"created_at" db:"created_at"`
 }
@@ -44,15 +94,31 @@ type ProviderConfig struct {
 // RoutingRequest represents a request for provider/model selection
 type RoutingRequest struct {
 	OrgID        uuid.UUID              `json:"org_id"`
+	WorkflowName string                 `json:"workflow_name,omitempty"`
 	QualityTier  QualityTier            `json:"quality_tier"`
 	PromptTokens int                    `json:"prompt_tokens"`
 	MaxTokens    int                    `json:"max_tokens"`
 	LatencySLA   time.Duration          `json:"latency_sla,omitempty"`
 	BudgetCents  int64                  `json:"budget_cents,omitempty"`
 	Constraints  map[string]interface{} `json:"constraints,omitempty"`
+	Residency    *ResidencyConstraint   `json:"residency,omitempty"`
 	Context      map[string]interface{} `json:"context,omitempty"`
 }
 
+// ResidencyConstraint restricts routing to providers hosted in allowed regions
+type ResidencyConstraint struct {
+	AllowedRegions []string `json:"allowed_regions,omitempty"`
+	DataResidency  string   `json:"data_residency,omitempty"`
+}
+
+// ProviderDenial explains why a provider was excluded from routing
+type ProviderDenial struct {
+	ProviderName string `json:"provider_name"`
+	ModelName    string `json:"model_name"`
+	Constraint   string `json:"constraint"`
+	Reason       string `json:"reason"`
+}
+
 type QualityTier string
 
 const (
@@ -71,6 +137,9 @@ type RoutingResponse struct {
 	Confidence       float64                `json:"confidence"`
 	Reason           string                 `json:"reason"`
 	Alternatives     []Alternative          `json:"alternatives,omitempty"`
+	Denials          []ProviderDenial       `json:"denials,omitempty"`
+	MatchedRule      string                 `json:"matched_rule,omitempty"`
+	Degradations     []string               `json:"degradations,omitempty"` // Budget degradations applied to the route
 }
 
 type Alternative struct {
@@ -88,8 +157,9 @@ type CacheRequest struct {
 	PromptHash string                 `json:"prompt_hash"`
 	InputHash  string                 `json:"input_hash"`
 	Response   map[string]interface{} `json:"response"`
-	TTL        time.Duration          `json:"ttl"`
+	TTL        time.Duration          `json:"ttl"` // Policy TTL when zero
 	Policy     CachePolicy            `json:"policy"`
+	Scope      CacheScope             `json:"scope,omitempty"` // Org scope at the policy's privacy level when unset
 }
 
 type CachePolicy struct {
@@ -151,6 +221,8 @@ const (
 
 // OptimizationSuggestion represents a cost optimization recommendation
 type OptimizationSuggestion struct {
+	ID              uuid.UUID              `json:"id,omitempty" db:"id"`
+	OrgID           uuid.UUID              `json:"org_id,omitempty" db:"org_id"`
 	Type            OptimizationType       `json:"type"`
 	Title           string                 `json:"title"`
 	Description     string                 `json:"description"`
@@ -159,6 +231,34 @@ type OptimizationSuggestion struct {
 	Impact          ImpactLevel            `json:"impact"`
 	Actions         []string               `json:"actions"`
 	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+
+	Status             SuggestionStatus `json:"status,omitempty" db:"status"`
+	DismissReason      string           `json:"dismiss_reason,omitempty" db:"dismiss_reason"`
+	BaselineDailyCents float64          `json:"baseline_daily_cents,omitempty" db:"baseline_daily_cents"`
+	RealizedSaving     int64            `json:"realized_saving_cents" db:"realized_saving_cents"`
+	CreatedAt          time.Time        `json:"created_at,omitempty" db:"created_at"`
+	DecidedAt          *time.Time       `json:"decided_at,omitempty" db:"decided_at"`
+}
+
+// SuggestionStatus tracks the review state of a persisted suggestion
+type SuggestionStatus string
+
+const (
+	SuggestionStatusOpen      SuggestionStatus = "open"
+	SuggestionStatusAccepted  SuggestionStatus = "accepted"
+	SuggestionStatusDismissed SuggestionStatus = "dismissed"
+)
+
+// OptimizationReport compares projected and realized savings over time
+type OptimizationReport struct {
+	OrgID                  uuid.UUID                `json:"org_id"`
+	Suggestions            []OptimizationSuggestion `json:"suggestions"`
+	ByStatus               map[SuggestionStatus]int `json:"by_status"`
+	OpenProjectedCents     int64                    `json:"open_projected_cents"`
+	AcceptedProjectedCents int64                    `json:"accepted_projected_cents"`
+	RealizedSavingCents    int64                    `json:"realized_saving_cents"`
+	PromptCache            *PromptCacheSavings      `json:"prompt_cache,omitempty"` // Realized savings from provider prompt caching
+	GeneratedAt            time.Time                `json:"generated_at"`
 }
 
 type OptimizationType string
@@ -184,7 +284,10 @@ type ProviderMetrics struct {
 	ProviderName     string        `json:"provider_name"`
 	ModelName        string        `json:"model_name"`
 	AvgLatency       time.Duration `json:"avg_latency"`
+	P50Latency       time.Duration `json:"p50_latency"`
 	P95Latency       time.Duration `json:"p95_latency"`
+	P99Latency       time.Duration `json:"p99_latency"`
+	LatencySamples   uint64        `json:"latency_samples"`
 	SuccessRate      float64       `json:"success_rate"`
 	AvgCostPerToken  float64       `json:"avg_cost_per_token"`
 	QualityScore     float64       `json:"quality_score"`
@@ -233,3 +336,61 @@ type BatchSummary struct {
 	TotalCostCents  int64 `json:"total_cost_cents"`
 	TotalSavings    int64 `json:"total_savings_cents"`
 }
+
+// ProviderTelemetry represents the observed outcome of a single provider call
+type ProviderTelemetry struct {
+	ID               uuid.UUID         `json:"id" db:"id"`
+	OrgID            uuid.UUID         `json:"org_id" db:"org_id"`
+	ProviderName     string            `json:"provider_name" db:"provider_name"`
+	ModelName        string            `json:"model_name" db:"model_name"`
+	Latency          time.Duration     `json:"latency" db:"latency_ms"`
+	ErrorClass       ErrorClass        `json:"error_class" db:"error_class"`
+	CostCents        int64             `json:"cost_cents" db:"cost_cents"`
+	TokensUsed       int               `json:"tokens_used" db:"tokens_used"`
+	PromptTokens     int               `json:"prompt_tokens,omitempty" db:"prompt_tokens"`
+	CacheReadTokens  int               `json:"cache_read_tokens,omitempty" db:"cache_read_tokens"`
+	CacheWriteTokens int               `json:"cache_write_tokens,omitempty" db:"cache_write_tokens"`
+	CacheSavingCents int64             `json:"cache_saving_cents,omitempty" db:"cache_saving_cents"`
+	ReasoningTokens  int               `json:"reasoning_tokens,omitempty" db:"reasoning_tokens"`
+	ReasoningCents   int64             `json:"reasoning_cents,omitempty" db:"reasoning_cents"`
+	WorkflowName     string            `json:"workflow_name,omitempty" db:"workflow_name"`
+	QualityTier      QualityTier       `json:"quality_tier,omitempty" db:"quality_tier"`
+	Labels           map[string]string `json:"labels,omitempty" db:"labels"`
+	EstimatedCost    int64             `json:"estimated_cost_cents,omitempty" db:"-"`
+	EstimatedLatency time.Duration     `json:"estimated_latency,omitempty" db:"-"`
+	RecordedAt       time.Time         `json:"recorded_at" db:"recorded_at"`
+}
+
+type ErrorClass string
+
+const (
+	ErrorClassNone           ErrorClass = "none"
+	ErrorClassTimeout        ErrorClass = "timeout"
+	ErrorClassRateLimit      ErrorClass = "rate_limit"
+	ErrorClassServer         ErrorClass = "server_error"
+	ErrorClassInvalidRequest ErrorClass = "invalid_request"
+	ErrorClassOther          ErrorClass = "other"
+
+	// Content outcomes: the provider answered but declined or truncated
+	ErrorClassSafetyRefusal ErrorClass = "safety_refusal"
+	ErrorClassPolicyBlock   ErrorClass = "policy_block"
+	ErrorClassLength        ErrorClass = "length"
+
+	// Rejected before reaching the provider
+	ErrorClassTokenBudget     ErrorClass = "token_budget_exceeded"
+	ErrorClassBudgetThrottled ErrorClass = "budget_throttled"
+)
+
+// RollingStats represents aggregated telemetry over the recent window
+type RollingStats struct {
+	ProviderName   string             `json:"provider_name"`
+	ModelName      string             `json:"model_name"`
+	Samples        int                `json:"samples"`
+	AvgLatency     time.Duration      `json:"avg_latency"`
+	P50Latency     time.Duration      `json:"p50_latency"`
+	P95Latency     time.Duration      `json:"p95_latency"`
+	P99Latency     time.Duration      `json:"p99_latency"`
+	SuccessRate    float64            `json:"success_rate"`
+	TotalCostCents int64              `json:"total_cost_cents"`
+	ErrorCounts    map[ErrorClass]int `json:"error_counts"`
+}
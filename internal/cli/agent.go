@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/spf13/cobra"
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Manage the registry of agent and tool capabilities",
+	Long: `Agents and tools register their input/output schemas and supported step types.
+Workflows whose steps reference an agent (config.agent) or tool (config.tool_name)
+that is missing or incompatible are rejected at submission.`,
+}
+
+var agentRegisterCmd = &cobra.Command{
+	Use:   "register",
+	Short: "Register an agent or tool from a JSON capability file",
+	Long: `Register or refresh a capability, e.g. agentctl agent register -f researcher.json with
+  {"kind": "agent", "name": "researcher", "task_types": ["llm"],
+   "input_schema": {"type": "object", "properties": {"topic": {"type": "string"}}, "required": ["topic"]}}`,
+	RunE: runAgentRegister,
+}
+
+var agentListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered agents and tools",
+	RunE:  runAgentList,
+}
+
+func init() {
+	agentRegisterCmd.Flags().StringP("file", "f", "", "Capability JSON file")
+	_ = agentRegisterCmd.MarkFlagRequired("file")
+	agentListCmd.Flags().String("kind", "", "Only list agents or tools")
+	agentListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	agentCmd.AddCommand(agentRegisterCmd)
+	agentCmd.AddCommand(agentListCmd)
+}
+
+func runAgentRegister(cmd *cobra.Command, args []string) error {
+	file, _ := cmd.Flags().GetString("file")
+	if err := validateFilePath(file); err != nil {
+		return fmt.Errorf("invalid file path: %w", err)
+	}
+	data, err := os.ReadFile(file) // #nosec G304 - path validated above
+	if err != nil {
+		return fmt.Errorf("failed to read capability: %w", err)
+	}
+
+	var capability aor.AgentCapability
+	if err := json.Unmarshal(data, &capability); err != nil {
+		return fmt.Errorf("failed to parse capability: %w", err)
+	}
+	if err := capability.Validate(); err != nil {
+		return err
+	}
+
+	// Mock registration - in production would call aor.ControlPlane.RegisterCapability
+	fmt.Printf("Registered %s %s", capability.Kind, capability.Name)
+	if capability.Version != "" {
+		fmt.Printf(" %s", capability.Version)
+	}
+	fmt.Println()
+	return nil
+}
+
+func runAgentList(cmd *cobra.Command, args []string) error {
+	kind, _ := cmd.Flags().GetString("kind")
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock registry - in production would call aor.ControlPlane.ListCapabilities
+	capabilities := []aor.AgentCapability{
+		{Kind: aor.CapabilityAgent, Name: "researcher", Version: "1.4.0", TaskTypes: []string{"llm"},
+			InputSchema: map[string]interface{}{"type": "object", "required": []interface{}{"topic"}},
+			LastSeenAt:  time.Now().Add(-3 * time.Minute)},
+		{Kind: aor.CapabilityTool, Name: "web_search", Version: "2.0.1", TaskTypes: []string{"tool"},
+			LastSeenAt: time.Now().Add(-40 * time.Second)},
+	}
+	if kind != "" {
+		filtered := capabilities[:0]
+		for _, capability := range capabilities {
+			if capability.Kind == kind {
+				filtered = append(filtered, capability)
+			}
+		}
+		capabilities = filtered
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(capabilities, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format capabilities: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(capabilities) == 0 {
+		fmt.Println("No capabilities registered")
+		return nil
+	}
+	fmt.Printf("%-6s %-20s %-10s %-15s %s\n", "KIND", "NAME", "VERSION", "STEP TYPES", "LAST SEEN")
+	for _, capability := range capabilities {
+		taskTypes := strings.Join(capability.TaskTypes, ",")
+		if taskTypes == "" {
+			taskTypes = "any"
+		}
+		fmt.Printf("%-6s %-20s %-10s %-15s %s\n", capability.Kind, capability.Name, capability.Version,
+			taskTypes, capability.LastSeenAt.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
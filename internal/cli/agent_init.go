@@ -0,0 +1,825 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Scaffold new AgentFlow projects",
+}
+
+var initAgentCmd = &cobra.Command{
+	Use:   "agent [name]",
+	Short: "Generate an agent skeleton in Go, Python or TypeScript",
+	Long: `Generate an agent service implementing the AgentFlow agent contract:
+
+  GET  /health   liveness, {"status": "ok"}
+  POST /execute  run one step, {task_id, run_id, step_id, step_type, config, inputs} -> {output} or {error}
+  GET  /status   name, version and task counts
+
+The skeleton includes a Dockerfile, sample tests and a capability.json to
+register the agent with agentctl agent register -f.
+
+Examples:
+  agentctl init agent researcher --lang python
+  agentctl init agent summarizer --lang typescript --dir agents/summarizer --port 9000`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInitAgent,
+}
+
+func init() {
+	initAgentCmd.Flags().String("lang", "go", fmt.Sprintf("Language of the skeleton (%s)", strings.Join(agentLanguageNames(), ", ")))
+	initAgentCmd.Flags().String("dir", "", "Directory to generate into (default ./<name>)")
+	initAgentCmd.Flags().Int("port", defaultAgentPort, "Port the agent listens on")
+	initAgentCmd.Flags().Bool("force", false, "Overwrite files in a non-empty directory")
+
+	initCmd.AddCommand(initAgentCmd)
+}
+
+func runInitAgent(cmd *cobra.Command, args []string) error {
+	lang, _ := cmd.Flags().GetString("lang")
+	dir, _ := cmd.Flags().GetString("dir")
+	port, _ := cmd.Flags().GetInt("port")
+	force, _ := cmd.Flags().GetBool("force")
+	if dir == "" {
+		dir = args[0]
+	}
+	if err := validateFilePath(dir); err != nil {
+		return fmt.Errorf("invalid directory: %w", err)
+	}
+	if !force {
+		if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+			return fmt.Errorf("directory %s is not empty, use --force to overwrite", dir)
+		}
+	}
+
+	files, err := generateAgent(agentTemplateData{Name: args[0], Version: "0.1.0", Port: port}, lang)
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		full := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o750); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(full, files[path], 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", full, err)
+		}
+	}
+
+	language := agentLanguages[lang]
+	fmt.Printf("Generated %s agent %s in %s\n", lang, args[0], dir)
+	for _, path := range paths {
+		fmt.Printf("  %s\n", path)
+	}
+	fmt.Printf("\nRun it with:   cd %s && %s\n", dir, language.Run)
+	fmt.Printf("Test it with:  cd %s && %s\n", dir, language.Test)
+	return nil
+}
+
+const defaultAgentPort = 8000
+
+var agentNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,62}$`)
+
+// agentContract is the HTTP contract every generated agent implements. The
+// templates of all languages render their routes from it, so a contract
+// change is made here once.
+type agentContract struct {
+	HealthPath  string
+	ExecutePath string
+	StatusPath  string
+}
+
+var agentHTTPContract = agentContract{
+	HealthPath:  "/health",
+	ExecutePath: "/execute",
+	StatusPath:  "/status",
+}
+
+// agentTemplateData is what the templates of a skeleton are rendered with
+type agentTemplateData struct {
+	Name     string
+	Version  string
+	Port     int
+	Contract agentContract
+	Language agentLanguage
+}
+
+// agentLanguage is one language's skeleton: its files by path and how to run
+// and test it
+type agentLanguage struct {
+	Name  string
+	Run   string
+	Test  string
+	Files map[string]string
+}
+
+// agentCommonFiles are generated for every language
+var agentCommonFiles = map[string]string{
+	"README.md":       agentReadmeTemplate,
+	"capability.json": agentCapabilityTemplate,
+}
+
+var agentLanguages = map[string]agentLanguage{
+	"go": {
+		Name: "Go",
+		Run:  "go run .",
+		Test: "go test ./...",
+		Files: map[string]string{
+			"go.mod":       goAgentModTemplate,
+			"main.go":      goAgentMainTemplate,
+			"main_test.go": goAgentTestTemplate,
+			"Dockerfile":   goAgentDockerfileTemplate,
+		},
+	},
+	"python": {
+		Name: "Python",
+		Run:  "python3 app.py",
+		Test: "python3 -m unittest",
+		Files: map[string]string{
+			"app.py":      pythonAgentAppTemplate,
+			"test_app.py": pythonAgentTestTemplate,
+			"Dockerfile":  pythonAgentDockerfileTemplate,
+		},
+	},
+	"typescript": {
+		Name: "TypeScript",
+		Run:  "npm install && npm start",
+		Test: "npm install && npm test",
+		Files: map[string]string{
+			"package.json":      tsAgentPackageTemplate,
+			"tsconfig.json":     tsAgentTSConfigTemplate,
+			"src/agent.ts":      tsAgentServerTemplate,
+			"src/main.ts":       tsAgentMainTemplate,
+			"src/agent.test.ts": tsAgentTestTemplate,
+			"Dockerfile":        tsAgentDockerfileTemplate,
+			".dockerignore":     tsAgentDockerignoreTemplate,
+		},
+	},
+}
+
+func agentLanguageNames() []string {
+	names := make([]string, 0, len(agentLanguages))
+	for name := range agentLanguages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// generateAgent renders a language's skeleton, returning file contents by path
+func generateAgent(data agentTemplateData, lang string) (map[string][]byte, error) {
+	if !agentNamePattern.MatchString(data.Name) {
+		return nil, fmt.Errorf("invalid agent name %q: use lowercase letters, digits and hyphens, starting with a letter", data.Name)
+	}
+	if data.Port <= 0 || data.Port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", data.Port)
+	}
+	language, ok := agentLanguages[lang]
+	if !ok {
+		return nil, fmt.Errorf("unknown language %q: use %s", lang, strings.Join(agentLanguageNames(), ", "))
+	}
+	data.Contract = agentHTTPContract
+	data.Language = language
+
+	files := make(map[string][]byte, len(language.Files)+len(agentCommonFiles))
+	for _, set := range []map[string]string{agentCommonFiles, language.Files} {
+		for path, text := range set {
+			tmpl, err := template.New(path).Funcs(agentTemplateFuncs).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+			}
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				return nil, fmt.Errorf("failed to render %s: %w", path, err)
+			}
+			files[path] = buf.Bytes()
+		}
+	}
+	return files, nil
+}
+
+var agentTemplateFuncs = template.FuncMap{
+	// tag renders a Go struct tag, which raw-string templates cannot contain
+	"tag": func(name string) string { return "`json:\"" + name + "\"`" },
+	// ident turns the agent name into an identifier part, e.g. doc-search to DocSearch
+	"ident": func(name string) string {
+		parts := strings.Split(name, "-")
+		for i, part := range parts {
+			if part != "" {
+				parts[i] = strings.ToUpper(part[:1]) + part[1:]
+			}
+		}
+		return strings.Join(parts, "")
+	},
+}
+
+const agentReadmeTemplate = `# {{.Name}}
+
+An AgentFlow agent written in {{.Language.Name}}. It serves the agent contract on
+port {{.Port}} (override with the PORT environment variable):
+
+| Method | Path | Purpose |
+|--------|------|---------|
+| GET | {{.Contract.HealthPath}} | Liveness, returns {"status": "ok"} |
+| POST | {{.Contract.ExecutePath}} | Runs one step and returns {"output": {...}}, or {"error": "..."} with status 422 |
+| GET | {{.Contract.StatusPath}} | Name, version and active, completed and failed task counts |
+
+Put the agent's logic in the execute function; the generated one echoes the
+step inputs.
+
+    # Run locally
+    {{.Language.Run}}
+
+    # Run the sample tests
+    {{.Language.Test}}
+
+    # Build the image
+    docker build -t {{.Name}} .
+
+Register the agent so workflows can reference it with config.agent:
+
+    agentctl agent register -f capability.json
+`
+
+const agentCapabilityTemplate = `{
+  "kind": "agent",
+  "name": "{{.Name}}",
+  "version": "{{.Version}}",
+  "description": "{{.Name}} agent",
+  "input_schema": {"type": "object"},
+  "output_schema": {"type": "object"}
+}
+`
+
+const goAgentModTemplate = `module {{.Name}}
+
+go 1.22
+`
+
+const goAgentMainTemplate = `// Command {{.Name}} is an AgentFlow agent.
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	agentName    = "{{.Name}}"
+	agentVersion = "{{.Version}}"
+)
+
+// ExecuteRequest is one step the worker asks the agent to run
+type ExecuteRequest struct {
+	TaskID   string                 {{tag "task_id"}}
+	RunID    string                 {{tag "run_id"}}
+	StepID   string                 {{tag "step_id"}}
+	StepType string                 {{tag "step_type"}}
+	Config   map[string]interface{} {{tag "config"}}
+	Inputs   map[string]interface{} {{tag "inputs"}}
+}
+
+// ExecuteResponse is a step's output, or its error when it failed
+type ExecuteResponse struct {
+	Output map[string]interface{} {{tag "output,omitempty"}}
+	Error  string                 {{tag "error,omitempty"}}
+}
+
+// StatusResponse reports the agent's version and task counts
+type StatusResponse struct {
+	Name           string    {{tag "name"}}
+	Version        string    {{tag "version"}}
+	ActiveTasks    int64     {{tag "active_tasks"}}
+	CompletedTasks int64     {{tag "completed_tasks"}}
+	FailedTasks    int64     {{tag "failed_tasks"}}
+	StartedAt      time.Time {{tag "started_at"}}
+}
+
+// Agent serves the AgentFlow agent contract
+type Agent struct {
+	startedAt time.Time
+	active    atomic.Int64
+	completed atomic.Int64
+	failed    atomic.Int64
+}
+
+func NewAgent() *Agent {
+	return &Agent{startedAt: time.Now()}
+}
+
+// Execute runs one step. Replace it with the agent's logic.
+func (a *Agent) Execute(req ExecuteRequest) (map[string]interface{}, error) {
+	return map[string]interface{}{"echo": req.Inputs}, nil
+}
+
+// Handler routes the contract's endpoints
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET {{.Contract.HealthPath}}", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("POST {{.Contract.ExecutePath}}", a.handleExecute)
+	mux.HandleFunc("GET {{.Contract.StatusPath}}", a.handleStatus)
+	return mux
+}
+
+func (a *Agent) handleExecute(w http.ResponseWriter, r *http.Request) {
+	var req ExecuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ExecuteResponse{Error: "invalid request: " + err.Error()})
+		return
+	}
+
+	a.active.Add(1)
+	output, err := a.Execute(req)
+	a.active.Add(-1)
+	if err != nil {
+		a.failed.Add(1)
+		writeJSON(w, http.StatusUnprocessableEntity, ExecuteResponse{Error: err.Error()})
+		return
+	}
+	a.completed.Add(1)
+	writeJSON(w, http.StatusOK, ExecuteResponse{Output: output})
+}
+
+func (a *Agent) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, StatusResponse{
+		Name:           agentName,
+		Version:        agentVersion,
+		ActiveTasks:    a.active.Load(),
+		CompletedTasks: a.completed.Load(),
+		FailedTasks:    a.failed.Load(),
+		StartedAt:      a.startedAt,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "{{.Port}}"
+	}
+	server := &http.Server{Addr: ":" + port, Handler: NewAgent().Handler(), ReadHeaderTimeout: 10 * time.Second}
+	log.Printf("%s %s listening on :%s", agentName, agentVersion, port)
+	log.Fatal(server.ListenAndServe())
+}
+`
+
+const goAgentTestTemplate = `package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAgentContract(t *testing.T) {
+	server := httptest.NewServer(NewAgent().Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "{{.Contract.HealthPath}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("health returned %d", resp.StatusCode)
+	}
+
+	body := strings.NewReader("{\"task_id\": \"t1\", \"step_type\": \"{{.Name}}\", \"inputs\": {\"topic\": \"go\"}}")
+	resp, err = http.Post(server.URL+"{{.Contract.ExecutePath}}", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result ExecuteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || result.Error != "" {
+		t.Fatalf("execute returned %d: %s", resp.StatusCode, result.Error)
+	}
+	if echo, _ := result.Output["echo"].(map[string]interface{}); echo["topic"] != "go" {
+		t.Fatalf("unexpected output %v", result.Output)
+	}
+
+	resp, err = http.Post(server.URL+"{{.Contract.ExecutePath}}", "application/json", strings.NewReader("not json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid request returned %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "{{.Contract.StatusPath}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status StatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if status.Name != agentName || status.CompletedTasks != 1 || status.ActiveTasks != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+`
+
+const goAgentDockerfileTemplate = `FROM golang:1.22-alpine AS builder
+WORKDIR /src
+COPY go.mod ./
+COPY *.go ./
+RUN CGO_ENABLED=0 go build -o /agent .
+
+FROM alpine:3.20
+RUN adduser -D -u 1001 agent
+USER agent
+COPY --from=builder /agent /usr/local/bin/agent
+ENV PORT={{.Port}}
+EXPOSE {{.Port}}
+HEALTHCHECK --interval=30s --timeout=3s \
+    CMD wget --no-verbose --tries=1 --spider http://localhost:{{.Port}}{{.Contract.HealthPath}} || exit 1
+ENTRYPOINT ["/usr/local/bin/agent"]
+`
+
+const pythonAgentAppTemplate = `"""{{.Name}} is an AgentFlow agent."""
+
+import json
+import os
+import threading
+from datetime import datetime, timezone
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+
+AGENT_NAME = "{{.Name}}"
+AGENT_VERSION = "{{.Version}}"
+
+
+class Agent:
+    """Serves the AgentFlow agent contract."""
+
+    def __init__(self):
+        self.started_at = datetime.now(timezone.utc)
+        self._lock = threading.Lock()
+        self.active = 0
+        self.completed = 0
+        self.failed = 0
+
+    def execute(self, request):
+        """Runs one step. Replace it with the agent's logic."""
+        return {"echo": request.get("inputs", {})}
+
+    def status(self):
+        with self._lock:
+            return {
+                "name": AGENT_NAME,
+                "version": AGENT_VERSION,
+                "active_tasks": self.active,
+                "completed_tasks": self.completed,
+                "failed_tasks": self.failed,
+                "started_at": self.started_at.isoformat(),
+            }
+
+    def _count(self, field, delta):
+        with self._lock:
+            setattr(self, field, getattr(self, field) + delta)
+
+
+def make_handler(agent):
+    class Handler(BaseHTTPRequestHandler):
+        def do_GET(self):
+            if self.path == "{{.Contract.HealthPath}}":
+                self._reply(200, {"status": "ok"})
+            elif self.path == "{{.Contract.StatusPath}}":
+                self._reply(200, agent.status())
+            else:
+                self._reply(404, {"error": "not found"})
+
+        def do_POST(self):
+            if self.path != "{{.Contract.ExecutePath}}":
+                self._reply(404, {"error": "not found"})
+                return
+            try:
+                length = int(self.headers.get("Content-Length", 0))
+                request = json.loads(self.rfile.read(length))
+                if not isinstance(request, dict):
+                    raise ValueError("request must be an object")
+            except ValueError as err:
+                self._reply(400, {"error": f"invalid request: {err}"})
+                return
+
+            agent._count("active", 1)
+            try:
+                output = agent.execute(request)
+            except Exception as err:  # Step failures are reported, not raised
+                agent._count("failed", 1)
+                self._reply(422, {"error": str(err)})
+                return
+            finally:
+                agent._count("active", -1)
+            agent._count("completed", 1)
+            self._reply(200, {"output": output})
+
+        def _reply(self, status, body):
+            data = json.dumps(body).encode()
+            self.send_response(status)
+            self.send_header("Content-Type", "application/json")
+            self.send_header("Content-Length", str(len(data)))
+            self.end_headers()
+            self.wfile.write(data)
+
+        def log_message(self, format, *args):
+            pass
+
+    return Handler
+
+
+def serve(port):
+    return ThreadingHTTPServer(("", port), make_handler(Agent()))
+
+
+if __name__ == "__main__":
+    port = int(os.environ.get("PORT", "{{.Port}}"))
+    print(f"{AGENT_NAME} {AGENT_VERSION} listening on :{port}")
+    serve(port).serve_forever()
+`
+
+const pythonAgentTestTemplate = `import json
+import threading
+import unittest
+import urllib.error
+import urllib.request
+
+from app import AGENT_NAME, serve
+
+
+class AgentContractTest(unittest.TestCase):
+    def setUp(self):
+        self.server = serve(0)
+        self.url = f"http://127.0.0.1:{self.server.server_address[1]}"
+        threading.Thread(target=self.server.serve_forever, daemon=True).start()
+
+    def tearDown(self):
+        self.server.shutdown()
+        self.server.server_close()
+
+    def request(self, method, path, body=None):
+        data = body if isinstance(body, bytes) or body is None else json.dumps(body).encode()
+        req = urllib.request.Request(self.url + path, data=data, method=method,
+                                     headers={"Content-Type": "application/json"})
+        try:
+            with urllib.request.urlopen(req) as resp:
+                return resp.status, json.loads(resp.read())
+        except urllib.error.HTTPError as err:
+            return err.code, json.loads(err.read())
+
+    def test_contract(self):
+        self.assertEqual(self.request("GET", "{{.Contract.HealthPath}}"), (200, {"status": "ok"}))
+
+        status, body = self.request("POST", "{{.Contract.ExecutePath}}",
+                                    {"task_id": "t1", "step_type": "{{.Name}}", "inputs": {"topic": "python"}})
+        self.assertEqual(status, 200)
+        self.assertEqual(body["output"]["echo"], {"topic": "python"})
+
+        status, body = self.request("POST", "{{.Contract.ExecutePath}}", b"not json")
+        self.assertEqual(status, 400)
+        self.assertIn("error", body)
+
+        status, body = self.request("GET", "{{.Contract.StatusPath}}")
+        self.assertEqual(status, 200)
+        self.assertEqual(body["name"], AGENT_NAME)
+        self.assertEqual(body["completed_tasks"], 1)
+        self.assertEqual(body["active_tasks"], 0)
+
+
+if __name__ == "__main__":
+    unittest.main()
+`
+
+const pythonAgentDockerfileTemplate = `FROM python:3.12-slim
+RUN useradd --uid 1001 --create-home agent
+WORKDIR /app
+COPY app.py ./
+USER agent
+ENV PORT={{.Port}}
+EXPOSE {{.Port}}
+HEALTHCHECK --interval=30s --timeout=3s \
+    CMD python3 -c "import urllib.request; urllib.request.urlopen('http://localhost:{{.Port}}{{.Contract.HealthPath}}')" || exit 1
+CMD ["python3", "app.py"]
+`
+
+const tsAgentPackageTemplate = `{
+  "name": "{{.Name}}",
+  "version": "{{.Version}}",
+  "private": true,
+  "scripts": {
+    "build": "tsc",
+    "start": "tsc && node dist/main.js",
+    "test": "tsc && node --test dist/agent.test.js"
+  },
+  "devDependencies": {
+    "@types/node": "^20.11.0",
+    "typescript": "^5.4.0"
+  }
+}
+`
+
+const tsAgentTSConfigTemplate = `{
+  "compilerOptions": {
+    "target": "ES2022",
+    "module": "commonjs",
+    "rootDir": "src",
+    "outDir": "dist",
+    "strict": true,
+    "esModuleInterop": true
+  },
+  "include": ["src"]
+}
+`
+
+const tsAgentServerTemplate = `import { createServer, IncomingMessage, Server, ServerResponse } from "node:http";
+
+export const AGENT_NAME = "{{.Name}}";
+export const AGENT_VERSION = "{{.Version}}";
+
+/** One step the worker asks the agent to run. */
+export interface ExecuteRequest {
+  task_id: string;
+  run_id?: string;
+  step_id?: string;
+  step_type?: string;
+  config?: Record<string, unknown>;
+  inputs?: Record<string, unknown>;
+}
+
+/** Serves the AgentFlow agent contract. */
+export class {{ident .Name}}Agent {
+  readonly startedAt = new Date();
+  active = 0;
+  completed = 0;
+  failed = 0;
+
+  /** Runs one step. Replace it with the agent's logic. */
+  async execute(request: ExecuteRequest): Promise<Record<string, unknown>> {
+    return { echo: request.inputs ?? {} };
+  }
+
+  status() {
+    return {
+      name: AGENT_NAME,
+      version: AGENT_VERSION,
+      active_tasks: this.active,
+      completed_tasks: this.completed,
+      failed_tasks: this.failed,
+      started_at: this.startedAt.toISOString(),
+    };
+  }
+
+  server(): Server {
+    return createServer((req, res) => {
+      this.route(req, res).catch((err) => reply(res, 500, { error: String(err) }));
+    });
+  }
+
+  private async route(req: IncomingMessage, res: ServerResponse) {
+    if (req.method === "GET" && req.url === "{{.Contract.HealthPath}}") {
+      return reply(res, 200, { status: "ok" });
+    }
+    if (req.method === "GET" && req.url === "{{.Contract.StatusPath}}") {
+      return reply(res, 200, this.status());
+    }
+    if (req.method !== "POST" || req.url !== "{{.Contract.ExecutePath}}") {
+      return reply(res, 404, { error: "not found" });
+    }
+
+    let request: ExecuteRequest;
+    try {
+      request = JSON.parse(await readBody(req));
+    } catch (err) {
+      return reply(res, 400, { error: "invalid request: " + String(err) });
+    }
+
+    this.active++;
+    try {
+      const output = await this.execute(request);
+      this.completed++;
+      return reply(res, 200, { output });
+    } catch (err) {
+      this.failed++;
+      return reply(res, 422, { error: String(err) });
+    } finally {
+      this.active--;
+    }
+  }
+}
+
+function readBody(req: IncomingMessage): Promise<string> {
+  return new Promise((resolve, reject) => {
+    const chunks: Buffer[] = [];
+    req.on("data", (chunk: Buffer) => chunks.push(chunk));
+    req.on("end", () => resolve(Buffer.concat(chunks).toString()));
+    req.on("error", reject);
+  });
+}
+
+function reply(res: ServerResponse, status: number, body: unknown) {
+  res.writeHead(status, { "Content-Type": "application/json" });
+  res.end(JSON.stringify(body));
+}
+`
+
+const tsAgentMainTemplate = `import { AGENT_NAME, AGENT_VERSION, {{ident .Name}}Agent } from "./agent";
+
+const port = Number(process.env.PORT ?? "{{.Port}}");
+new {{ident .Name}}Agent().server().listen(port, () => {
+  console.log(AGENT_NAME + " " + AGENT_VERSION + " listening on :" + port);
+});
+`
+
+const tsAgentTestTemplate = `import assert from "node:assert/strict";
+import { AddressInfo } from "node:net";
+import { test } from "node:test";
+import { AGENT_NAME, {{ident .Name}}Agent } from "./agent";
+
+test("agent contract", async () => {
+  const server = new {{ident .Name}}Agent().server().listen(0);
+  await new Promise((resolve) => server.once("listening", resolve));
+  const url = "http://127.0.0.1:" + (server.address() as AddressInfo).port;
+
+  try {
+    let res = await fetch(url + "{{.Contract.HealthPath}}");
+    assert.equal(res.status, 200);
+    assert.deepEqual(await res.json(), { status: "ok" });
+
+    res = await fetch(url + "{{.Contract.ExecutePath}}", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ task_id: "t1", step_type: "{{.Name}}", inputs: { topic: "typescript" } }),
+    });
+    assert.equal(res.status, 200);
+    const result = (await res.json()) as { output: unknown };
+    assert.deepEqual(result.output, { echo: { topic: "typescript" } });
+
+    res = await fetch(url + "{{.Contract.ExecutePath}}", { method: "POST", body: "not json" });
+    assert.equal(res.status, 400);
+
+    res = await fetch(url + "{{.Contract.StatusPath}}");
+    const status = (await res.json()) as { name: string; completed_tasks: number; active_tasks: number };
+    assert.equal(status.name, AGENT_NAME);
+    assert.equal(status.completed_tasks, 1);
+    assert.equal(status.active_tasks, 0);
+  } finally {
+    server.close();
+  }
+});
+`
+
+const tsAgentDockerfileTemplate = `FROM node:20-alpine AS builder
+WORKDIR /app
+COPY package.json tsconfig.json ./
+RUN npm install
+COPY src ./src
+RUN npx tsc
+
+FROM node:20-alpine
+WORKDIR /app
+COPY --from=builder /app/dist ./dist
+USER node
+ENV PORT={{.Port}}
+EXPOSE {{.Port}}
+HEALTHCHECK --interval=30s --timeout=3s \
+    CMD wget --no-verbose --tries=1 --spider http://localhost:{{.Port}}{{.Contract.HealthPath}} || exit 1
+CMD ["node", "dist/main.js"]
+`
+
+const tsAgentDockerignoreTemplate = `node_modules
+dist
+`
@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aos"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var analyticsCmd = &cobra.Command{
+	Use:   "analytics",
+	Short: "Manage per-org analytics views over traces",
+	Long: `Analytics views roll trace events up into a ClickHouse materialized view per org,
+so dashboards query pre-aggregated rows with bound parameters instead of ad-hoc SQL, e.g.
+  agentctl analytics create customer_daily_cost --dimension day --dimension label:customer --measure cost_cents
+  agentctl analytics query customer_daily_cost --param label:customer=acme --from 2024-06-01`,
+}
+
+var analyticsCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create an analytics view",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAnalyticsCreate,
+}
+
+var analyticsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List analytics views",
+	RunE:  runAnalyticsList,
+}
+
+var analyticsDropCmd = &cobra.Command{
+	Use:   "drop [name]",
+	Short: "Drop an analytics view and its rollup",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAnalyticsDrop,
+}
+
+var analyticsQueryCmd = &cobra.Command{
+	Use:   "query [name]",
+	Short: "Query an analytics view",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAnalyticsQuery,
+}
+
+func init() {
+	analyticsCreateCmd.Flags().StringArrayP("dimension", "d", nil, "Dimension to group by (day, hour, provider, model, quality_tier, event_type, prompt_ref, label:<key>)")
+	analyticsCreateCmd.Flags().StringArrayP("measure", "m", nil, "Measure to aggregate (events, errors, cost_cents, tokens_prompt, tokens_completion, latency_ms)")
+	analyticsCreateCmd.Flags().String("description", "", "What the view is for")
+	analyticsCreateCmd.Flags().Bool("backfill", true, "Aggregate events stored before the view was created")
+	analyticsListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	analyticsQueryCmd.Flags().StringArrayP("param", "p", nil, "Dimension filter as dimension=value")
+	analyticsQueryCmd.Flags().String("from", "", "Start date or time, inclusive (YYYY-MM-DD or RFC3339)")
+	analyticsQueryCmd.Flags().String("to", "", "End date or time, exclusive (YYYY-MM-DD or RFC3339)")
+	analyticsQueryCmd.Flags().Int("limit", 0, "Maximum rows (default 1000)")
+	analyticsQueryCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	analyticsCmd.AddCommand(analyticsCreateCmd)
+	analyticsCmd.AddCommand(analyticsListCmd)
+	analyticsCmd.AddCommand(analyticsDropCmd)
+	analyticsCmd.AddCommand(analyticsQueryCmd)
+}
+
+func runAnalyticsCreate(cmd *cobra.Command, args []string) error {
+	dimensions, _ := cmd.Flags().GetStringArray("dimension")
+	measures, _ := cmd.Flags().GetStringArray("measure")
+	description, _ := cmd.Flags().GetString("description")
+	backfill, _ := cmd.Flags().GetBool("backfill")
+
+	req := &aos.CreateAnalyticsViewRequest{
+		Name:        args[0],
+		Description: description,
+		Dimensions:  dimensions,
+		Measures:    measures,
+		Backfill:    backfill,
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	// Mock creation - in production would call aos.Service.CreateAnalyticsView
+	view := aos.AnalyticsView{ID: uuid.New(), Name: req.Name, Dimensions: req.Dimensions, Measures: req.Measures, CreatedAt: time.Now()}
+	fmt.Printf("Created analytics view %s by %s measuring %s\n", view.Name,
+		strings.Join(view.Dimensions, ", "), strings.Join(view.Measures, ", "))
+	if req.Backfill {
+		fmt.Println("Backfilled from stored trace events")
+	}
+	return nil
+}
+
+func runAnalyticsList(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock views - in production would call aos.Service.ListAnalyticsViews
+	views := []aos.AnalyticsView{
+		{ID: uuid.New(), Name: "customer_daily_cost", Description: "Spend per customer per day",
+			Dimensions: []string{"day", "label:customer"}, Measures: []string{"cost_cents", "events"}, CreatedAt: time.Now().Add(-72 * time.Hour)},
+		{ID: uuid.New(), Name: "prompt_errors", Description: "Error rate per prompt",
+			Dimensions: []string{"day", "prompt_ref"}, Measures: []string{"events", "errors"}, CreatedAt: time.Now().Add(-24 * time.Hour)},
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(views, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal analytics views: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-22s %-26s %-24s %s\n", "NAME", "DIMENSIONS", "MEASURES", "DESCRIPTION")
+	for _, view := range views {
+		fmt.Printf("%-22s %-26s %-24s %s\n", view.Name, strings.Join(view.Dimensions, ","),
+			strings.Join(view.Measures, ","), view.Description)
+	}
+	return nil
+}
+
+func runAnalyticsDrop(cmd *cobra.Command, args []string) error {
+	// Mock drop - in production would call aos.Service.DropAnalyticsView
+	fmt.Printf("Dropped analytics view %s\n", args[0])
+	return nil
+}
+
+func runAnalyticsQuery(cmd *cobra.Command, args []string) error {
+	params, _ := cmd.Flags().GetStringArray("param")
+	from, _ := cmd.Flags().GetString("from")
+	to, _ := cmd.Flags().GetString("to")
+	limit, _ := cmd.Flags().GetInt("limit")
+	output, _ := cmd.Flags().GetString("output")
+
+	req := &aos.AnalyticsViewQuery{Name: args[0], Params: make(map[string]string), Limit: limit}
+	for _, param := range params {
+		dimension, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return fmt.Errorf("invalid --param %q: use dimension=value", param)
+		}
+		req.Params[dimension] = value
+	}
+	var err error
+	if req.From, err = parseViewTime(from); err != nil {
+		return fmt.Errorf("invalid --from: %w", err)
+	}
+	if req.To, err = parseViewTime(to); err != nil {
+		return fmt.Errorf("invalid --to: %w", err)
+	}
+
+	// Mock result - in production would call aos.Service.QueryAnalyticsView
+	view := aos.AnalyticsView{Name: req.Name, Dimensions: []string{"day", "label:customer"}, Measures: []string{"cost_cents", "events"}}
+	result := aos.AnalyticsViewResult{View: view, Rows: []aos.AnalyticsViewRow{
+		{Dimensions: map[string]string{"day": "2024-06-01", "label:customer": "acme"}, Measures: map[string]int64{"cost_cents": 18240, "events": 5120}},
+		{Dimensions: map[string]string{"day": "2024-06-02", "label:customer": "acme"}, Measures: map[string]int64{"cost_cents": 20915, "events": 5873}},
+	}}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal analytics view rows: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	columns := append(append([]string{}, view.Dimensions...), view.Measures...)
+	for _, column := range columns {
+		fmt.Printf("%-20s ", strings.ToUpper(column))
+	}
+	fmt.Println()
+	for _, row := range result.Rows {
+		for _, dimension := range view.Dimensions {
+			fmt.Printf("%-20s ", row.Dimensions[dimension])
+		}
+		for _, measure := range view.Measures {
+			fmt.Printf("%-20d ", row.Measures[measure])
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// parseViewTime accepts a date or an RFC3339 time; empty leaves the bound open
+func parseViewTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
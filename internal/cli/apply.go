@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/spf13/cobra"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Reconcile workflows, prompts, budgets and provider configs with a bundle",
+	Long: `Declaratively manage resources from a bundle file, e.g.
+  agentctl apply -f agentflow.yaml --dry-run
+  agentctl apply -f agentflow.yaml --prune
+
+Each resource is created, updated or left unchanged so applying the same
+bundle twice is a no-op. With --prune, resources an earlier apply created
+that are missing from the bundle are deleted.`,
+	RunE: runApply,
+}
+
+func init() {
+	applyCmd.Flags().StringP("file", "f", "", "Bundle file (YAML or JSON)")
+	applyCmd.Flags().Bool("prune", false, "Delete previously applied resources missing from the bundle")
+	applyCmd.Flags().Bool("dry-run", false, "Show the plan without making changes")
+	_ = applyCmd.MarkFlagRequired("file")
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	file, _ := cmd.Flags().GetString("file")
+	prune, _ := cmd.Flags().GetBool("prune")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	if err := validateFilePath(file); err != nil {
+		return fmt.Errorf("invalid file path: %w", err)
+	}
+	data, err := os.ReadFile(file) // #nosec G304 - path validated above
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		format = "json"
+	}
+	bundle, err := aor.ParseApplyBundle(data, format)
+	if err != nil {
+		return err
+	}
+
+	// Mock apply - in production would call aor.ControlPlane.Apply
+	mode := "Applying"
+	if dryRun {
+		mode = "Planning"
+	}
+	fmt.Printf("%s %d resources from %s (prune: %t)\n", mode, len(bundle.Resources), file, prune)
+	for _, resource := range bundle.Resources {
+		signer := ""
+		if resource.Signature != nil {
+			signer = "signed by " + resource.Signature.Key
+		}
+		fmt.Printf("  %-15s %-30s %-9s %s\n", resource.Kind, resource.Name, aor.ApplyActionUnchanged, signer)
+	}
+	fmt.Printf("Summary: 0 created, 0 updated, 0 deleted, %d unchanged\n", len(bundle.Resources))
+
+	return nil
+}
@@ -6,6 +6,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -34,6 +37,13 @@ var budgetStatusCmd = &cobra.Command{
 	RunE:  runBudgetStatus,
 }
 
+var budgetUsageCmd = &cobra.Command{
+	Use:   "usage [org-id]",
+	Short: "Show an org's period spending and whether its calls are rejected or throttled",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBudgetUsage,
+}
+
 var budgetUpdateCmd = &cobra.Command{
 	Use:   "update [budget-id] [amount]",
 	Short: "Update budget limit",
@@ -54,33 +64,69 @@ var budgetAnalyzeCmd = &cobra.Command{
 	RunE:  runBudgetAnalyze,
 }
 
+var budgetRecommendationsCmd = &cobra.Command{
+	Use:   "recommendations",
+	Short: "List optimization recommendations and their realized savings",
+	RunE:  runBudgetRecommendations,
+}
+
+var budgetAcceptCmd = &cobra.Command{
+	Use:   "accept [suggestion-id]",
+	Short: "Accept an optimization recommendation and track its savings",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBudgetAccept,
+}
+
+var budgetDismissCmd = &cobra.Command{
+	Use:   "dismiss [suggestion-id]",
+	Short: "Dismiss an optimization recommendation",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBudgetDismiss,
+}
+
 func init() {
 	// Create command flags
 	budgetCreateCmd.Flags().StringP("period", "p", "monthly", "Budget period (daily, weekly, monthly)")
 	budgetCreateCmd.Flags().StringP("project", "", "", "Project ID (optional)")
 	budgetCreateCmd.Flags().StringP("description", "d", "", "Budget description")
+	budgetCreateCmd.Flags().StringP("workflow", "w", "", "Scope budget to a workflow name (optional)")
+	budgetCreateCmd.Flags().StringP("tag", "t", "", "Narrow a workflow budget to runs with tag key=value (optional)")
+	budgetCreateCmd.Flags().StringP("label", "l", "", "Scope the budget to runs with cost label key=value, or any value of key (optional)")
 
 	// List command flags
 	budgetListCmd.Flags().StringP("status", "s", "", "Filter by status (healthy, warning, critical, exceeded)")
 	budgetListCmd.Flags().BoolP("active", "a", false, "Show only active budgets")
+	addListFlags(budgetListCmd, 20, "period_type=monthly,spent_cents>=1000")
+	budgetListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
 
 	// Status command flags
 	budgetStatusCmd.Flags().StringP("project", "p", "", "Project ID (optional)")
 	budgetStatusCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
 
+	// Usage command flags
+	budgetUsageCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
 	// Analyze command flags
 	budgetAnalyzeCmd.Flags().StringP("period", "p", "30d", "Analysis period")
-	budgetAnalyzeCmd.Flags().StringSliceP("group-by", "g", []string{"provider"}, "Group by (provider, model, workflow, quality)")
+	budgetAnalyzeCmd.Flags().StringSliceP("group-by", "g", []string{"provider"}, "Group by (provider, model, quality_tier, label:<key>)")
 	budgetAnalyzeCmd.Flags().BoolP("trends", "t", false, "Show spending trends")
 	budgetAnalyzeCmd.Flags().BoolP("forecast", "f", false, "Show spending forecast")
 
+	// Recommendation flags
+	budgetRecommendationsCmd.Flags().StringP("status", "s", "", "Filter by status (open, accepted, dismissed)")
+	budgetDismissCmd.Flags().StringP("reason", "r", "", "Reason for dismissing")
+
 	// Add subcommands
 	budgetCmd.AddCommand(budgetCreateCmd)
 	budgetCmd.AddCommand(budgetListCmd)
 	budgetCmd.AddCommand(budgetStatusCmd)
+	budgetCmd.AddCommand(budgetUsageCmd)
 	budgetCmd.AddCommand(budgetUpdateCmd)
 	budgetCmd.AddCommand(budgetDeleteCmd)
 	budgetCmd.AddCommand(budgetAnalyzeCmd)
+	budgetCmd.AddCommand(budgetRecommendationsCmd)
+	budgetCmd.AddCommand(budgetAcceptCmd)
+	budgetCmd.AddCommand(budgetDismissCmd)
 }
 
 func runBudgetCreate(cmd *cobra.Command, args []string) error {
@@ -109,6 +155,21 @@ func runBudgetCreate(cmd *cobra.Command, args []string) error {
 	period, _ := cmd.Flags().GetString("period")
 	project, _ := cmd.Flags().GetString("project")
 	description, _ := cmd.Flags().GetString("description")
+	workflow, _ := cmd.Flags().GetString("workflow")
+	tag, _ := cmd.Flags().GetString("tag")
+	label, _ := cmd.Flags().GetString("label")
+
+	if tag != "" && workflow == "" {
+		return fmt.Errorf("--tag requires --workflow")
+	}
+	if label != "" {
+		if workflow != "" || project != "" {
+			return fmt.Errorf("--label budgets apply across projects and workflows")
+		}
+		if _, _, err := cas.ParseCostLabelSelector(label); err != nil {
+			return err
+		}
+	}
 
 	fmt.Printf("Creating budget:\n")
 	fmt.Printf("  Amount: $%.2f\n", float64(amountCents)/100)
@@ -116,6 +177,15 @@ func runBudgetCreate(cmd *cobra.Command, args []string) error {
 	if project != "" {
 		fmt.Printf("  Project: %s\n", project)
 	}
+	if workflow != "" {
+		fmt.Printf("  Workflow: %s\n", workflow)
+	}
+	if tag != "" {
+		fmt.Printf("  Tag: %s\n", tag)
+	}
+	if label != "" {
+		fmt.Printf("  Cost label: %s\n", label)
+	}
 	if description != "" {
 		fmt.Printf("  Description: %s\n", description)
 	}
@@ -155,15 +225,22 @@ func runBudgetCreate(cmd *cobra.Command, args []string) error {
 func runBudgetList(cmd *cobra.Command, args []string) error {
 	statusFilter, _ := cmd.Flags().GetString("status")
 	activeOnly, _ := cmd.Flags().GetBool("active")
+	output, _ := cmd.Flags().GetString("output")
+	opts := listOptionsFromFlags(cmd)
 
-	fmt.Printf("Listing budgets")
-	if statusFilter != "" {
-		fmt.Printf(" (status: %s)", statusFilter)
-	}
-	if activeOnly {
-		fmt.Printf(" (active only)")
+	if output != "json" {
+		fmt.Printf("Listing budgets")
+		if statusFilter != "" {
+			fmt.Printf(" (status: %s)", statusFilter)
+		}
+		if activeOnly {
+			fmt.Printf(" (active only)")
+		}
+		if opts.Filter != "" {
+			fmt.Printf(" (filter: %s)", opts.Filter)
+		}
+		fmt.Println()
 	}
-	fmt.Println()
 
 	// Mock budget list
 	budgets := []map[string]interface{}{
@@ -202,16 +279,25 @@ func runBudgetList(cmd *cobra.Command, args []string) error {
 		},
 	}
 
+	matched := make([]map[string]interface{}, 0, len(budgets))
+	for _, budget := range budgets {
+		if statusFilter == "" || budget["status"] == statusFilter {
+			matched = append(matched, budget)
+		}
+	}
+	meta := db.PageMeta{TotalEstimate: int64(len(matched))}
+
+	if output == "json" {
+		return printListJSON("budgets", matched, meta, opts.Fields)
+	}
+
 	// Print table header
 	fmt.Printf("%-20s %-8s %-10s %-10s %-10s %-12s %-10s\n",
 		"BUDGET ID", "PERIOD", "LIMIT", "SPENT", "REMAINING", "UTILIZATION", "STATUS")
 	fmt.Println("-------------------------------------------------------------------------------------")
 
 	// Print budgets
-	for _, budget := range budgets {
-		if statusFilter != "" && budget["status"] != statusFilter {
-			continue
-		}
+	for _, budget := range matched {
 
 		limit := budget["limit"].(int)
 		spent := budget["spent"].(int)
@@ -242,6 +328,7 @@ func runBudgetList(cmd *cobra.Command, args []string) error {
 			statusDisplay,
 		)
 	}
+	printPageFooter(len(matched), meta)
 
 	return nil
 }
@@ -328,6 +415,65 @@ func runBudgetStatus(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runBudgetUsage(cmd *cobra.Command, args []string) error {
+	orgID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid org ID: %w", err)
+	}
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock usage - in production would call aor.ControlPlane.GetBudgetUsage
+	now := time.Now()
+	periodStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	usage := &cas.BudgetUsage{
+		OrgID:             orgID,
+		Enforcement:       cas.BudgetEnforcementThrottle,
+		AlertThresholdPct: 50,
+		Budget: &cas.BudgetStatus{
+			BudgetID:       uuid.New(),
+			LimitCents:     50000,
+			SpentCents:     50210,
+			RemainingCents: -210,
+			UtilizationPct: 100.4,
+			PeriodStart:    periodStart,
+			PeriodEnd:      periodStart.AddDate(0, 1, 0),
+			Status:         cas.BudgetStatusExceeded,
+		},
+		Throttled: true,
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(usage, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Org: %s\n", usage.OrgID)
+	fmt.Printf("Enforcement: %s\n", usage.Enforcement)
+	if usage.AlertThresholdPct > 0 {
+		fmt.Printf("Alert threshold: %d%%\n", usage.AlertThresholdPct)
+	}
+	if usage.Budget == nil {
+		fmt.Println("No active org budget; provider calls are not limited")
+		return nil
+	}
+	budget := usage.Budget
+	fmt.Printf("Period: %s to %s\n", budget.PeriodStart.Format("2006-01-02"), budget.PeriodEnd.Format("2006-01-02"))
+	fmt.Printf("Spent: $%.2f of $%.2f (%.1f%%)\n", float64(budget.SpentCents)/100, float64(budget.LimitCents)/100, budget.UtilizationPct)
+	fmt.Printf("Status: %s\n", budget.Status)
+	if budget.Status == cas.BudgetStatusExceeded {
+		if usage.Enforcement == cas.BudgetEnforcementThrottle {
+			fmt.Printf("Provider calls are throttled (throttle active: %t)\n", usage.Throttled)
+		} else {
+			fmt.Println("Provider calls are rejected until the period ends or the limit is raised")
+		}
+	}
+	return nil
+}
+
 func runBudgetUpdate(cmd *cobra.Command, args []string) error {
 	budgetID := args[0]
 	amountStr := args[1]
@@ -427,3 +573,84 @@ func runBudgetAnalyze(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runBudgetRecommendations(cmd *cobra.Command, args []string) error {
+	statusFilter, _ := cmd.Flags().GetString("status")
+
+	// Mock recommendations - in production would call cas.Service.GetOptimizationReport
+	recommendations := []struct {
+		ID        string
+		Title     string
+		Status    string
+		Projected float64
+		Realized  float64
+	}{
+		{"6f1c2a4e-1b7d-4c55-9a0e-2d3f4b5c6d7e", "Switch simple tasks to cheaper models", "accepted", 125.00, 96.40},
+		{"8a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d", "Implement response caching", "open", 75.00, 0},
+		{"1d2e3f4a-5b6c-4d7e-8f9a-0b1c2d3e4f5a", "Optimize prompt lengths", "dismissed", 50.00, 0},
+	}
+
+	fmt.Printf("%-38s %-40s %-10s %-12s %-10s\n", "ID", "TITLE", "STATUS", "PROJECTED", "REALIZED")
+	fmt.Printf("%-38s %-40s %-10s %-12s %-10s\n", "--", "-----", "------", "---------", "--------")
+
+	var openProjected, acceptedProjected, realized float64
+	for _, r := range recommendations {
+		if statusFilter != "" && r.Status != statusFilter {
+			continue
+		}
+		fmt.Printf("%-38s %-40s %-10s $%-11.2f $%-9.2f\n", r.ID, r.Title, r.Status, r.Projected, r.Realized)
+
+		switch r.Status {
+		case "open":
+			openProjected += r.Projected
+		case "accepted":
+			acceptedProjected += r.Projected
+			realized += r.Realized
+		}
+	}
+
+	fmt.Println("\nImpact:")
+	fmt.Printf("  Open (projected):     $%.2f/month\n", openProjected)
+	fmt.Printf("  Accepted (projected): $%.2f/month\n", acceptedProjected)
+	fmt.Printf("  Accepted (realized):  $%.2f to date\n", realized)
+
+	// Mock prompt cache savings - in production would come from the report's prompt_cache section
+	promptCache := cas.PromptCacheSavings{Calls: 48210, CachedCalls: 31877, PromptTokens: 96_400_000,
+		ReadTokens: 58_100_000, WriteTokens: 4_300_000, SavingCents: 13850}
+	promptCache.ReadShare = float64(promptCache.ReadTokens) / float64(promptCache.PromptTokens)
+	fmt.Println("\nProvider prompt caching (last 30 days):")
+	fmt.Printf("  Calls reading cache:  %d of %d\n", promptCache.CachedCalls, promptCache.Calls)
+	fmt.Printf("  Prompt tokens cached: %.1f%% (%d read, %d written)\n", promptCache.ReadShare*100, promptCache.ReadTokens, promptCache.WriteTokens)
+	fmt.Printf("  Realized saving:      $%.2f\n", float64(promptCache.SavingCents)/100)
+
+	return nil
+}
+
+func runBudgetAccept(cmd *cobra.Command, args []string) error {
+	suggestionID := args[0]
+
+	fmt.Printf("Accepting recommendation: %s\n", suggestionID)
+
+	// Mock accept - in production would call cas.Service.AcceptSuggestion
+	suggestion := &cas.OptimizationSuggestion{Status: cas.SuggestionStatusAccepted,
+		BaselineDailyCents: cas.AverageDailySpend(29169, cas.BaselineWindow)}
+	fmt.Printf("Baseline daily spend: $%.2f (average over the last %d days)\n", suggestion.BaselineDailyCents/100, int(cas.BaselineWindow.Hours()/24))
+	fmt.Printf("Recommendation accepted. Realized savings will be tracked against the baseline.\n")
+
+	return nil
+}
+
+func runBudgetDismiss(cmd *cobra.Command, args []string) error {
+	suggestionID := args[0]
+	reason, _ := cmd.Flags().GetString("reason")
+
+	fmt.Printf("Dismissing recommendation: %s\n", suggestionID)
+	if reason != "" {
+		fmt.Printf("Reason: %s\n", reason)
+	}
+
+	// Mock dismiss - in production would call cas.Service.DismissSuggestion
+	fmt.Printf("Recommendation dismissed.\n")
+
+	return nil
+}
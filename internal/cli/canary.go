@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/spf13/cobra"
+)
+
+var workflowDeployCmd = &cobra.Command{
+	Use:   "deploy [workflow-name]",
+	Short: "Set the spec version new runs start on, optionally with a canary",
+	Long: `Set the stable spec version for runs submitted without --version and send a
+share of them to a canary version, e.g.
+  agentctl workflow deploy doc-summarizer --stable 3 --canary 4 --canary-ratio 0.1 --auto-rollback`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWorkflowDeploy,
+}
+
+var workflowCanaryCmd = &cobra.Command{
+	Use:   "canary",
+	Short: "Compare, promote or roll back a workflow canary",
+}
+
+var workflowCanaryStatusCmd = &cobra.Command{
+	Use:   "status [workflow-name]",
+	Short: "Compare success rate, cost and latency of the canary against stable",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runWorkflowCanaryStatus,
+}
+
+var workflowCanaryPromoteCmd = &cobra.Command{
+	Use:   "promote [workflow-name]",
+	Short: "Make the canary version stable for all new runs",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runWorkflowCanaryPromote,
+}
+
+var workflowCanaryRollbackCmd = &cobra.Command{
+	Use:   "rollback [workflow-name]",
+	Short: "Send all new runs back to the stable version",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runWorkflowCanaryRollback,
+}
+
+func init() {
+	workflowDeployCmd.Flags().Int("stable", 0, "Stable spec version")
+	workflowDeployCmd.Flags().Int("canary", 0, "Canary spec version")
+	workflowDeployCmd.Flags().Float64("canary-ratio", 0, "Share of new runs sent to the canary (0.0-1.0)")
+	workflowDeployCmd.Flags().Int("min-runs", 0, "Finished runs per version before the canary is judged (default 20)")
+	workflowDeployCmd.Flags().Float64("max-success-drop", 0, "Largest tolerated drop in success rate, e.g. 0.05 for five points")
+	workflowDeployCmd.Flags().Float64("max-cost-increase", 0, "Largest tolerated relative increase in average cost, e.g. 0.2")
+	workflowDeployCmd.Flags().Float64("max-latency-increase", 0, "Largest tolerated relative increase in p90 latency, e.g. 0.25")
+	workflowDeployCmd.Flags().Bool("auto-rollback", false, "Roll back automatically when the canary regresses")
+	workflowCanaryStatusCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	workflowCanaryCmd.AddCommand(workflowCanaryStatusCmd)
+	workflowCanaryCmd.AddCommand(workflowCanaryPromoteCmd)
+	workflowCanaryCmd.AddCommand(workflowCanaryRollbackCmd)
+	workflowCmd.AddCommand(workflowDeployCmd)
+	workflowCmd.AddCommand(workflowCanaryCmd)
+}
+
+func runWorkflowDeploy(cmd *cobra.Command, args []string) error {
+	stable, _ := cmd.Flags().GetInt("stable")
+	canary, _ := cmd.Flags().GetInt("canary")
+	canaryRatio, _ := cmd.Flags().GetFloat64("canary-ratio")
+	minRuns, _ := cmd.Flags().GetInt("min-runs")
+	maxSuccessDrop, _ := cmd.Flags().GetFloat64("max-success-drop")
+	maxCostIncrease, _ := cmd.Flags().GetFloat64("max-cost-increase")
+	maxLatencyIncrease, _ := cmd.Flags().GetFloat64("max-latency-increase")
+	autoRollback, _ := cmd.Flags().GetBool("auto-rollback")
+
+	req := &aor.WorkflowDeploymentRequest{
+		WorkflowName:  args[0],
+		StableVersion: stable,
+		CanaryRatio:   canaryRatio,
+		Policy: aor.CanaryPolicy{
+			MinRuns:            minRuns,
+			MaxSuccessDrop:     maxSuccessDrop,
+			MaxCostIncrease:    maxCostIncrease,
+			MaxLatencyIncrease: maxLatencyIncrease,
+			AutoRollback:       autoRollback,
+		},
+	}
+	if canary > 0 {
+		req.CanaryVersion = &canary
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	// Mock deployment - in production would call aor.ControlPlane.DeployWorkflow
+	fmt.Printf("Deployed workflow: %s\n", req.WorkflowName)
+	fmt.Printf("Stable version: %d\n", req.StableVersion)
+	if req.CanaryVersion != nil {
+		fmt.Printf("Canary version: %d\n", *req.CanaryVersion)
+		fmt.Printf("Traffic split: %.1f%% stable, %.1f%% canary\n", (1-canaryRatio)*100, canaryRatio*100)
+		if autoRollback {
+			fmt.Println("Auto-rollback: enabled")
+		}
+	}
+	return nil
+}
+
+func runWorkflowCanaryStatus(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock comparison - in production would call aor.ControlPlane.CompareWorkflowCanary
+	comparison := aor.CanaryComparison{
+		WorkflowName: args[0],
+		Stable: aor.VersionRunStats{Version: 3, Runs: 412, Succeeded: 398, SuccessRate: 398.0 / 412,
+			AvgCostCents: 4.2, AvgLatency: 38 * time.Second, P90Latency: 61 * time.Second},
+		Canary: aor.VersionRunStats{Version: 4, Runs: 47, Succeeded: 46, SuccessRate: 46.0 / 47,
+			AvgCostCents: 3.6, AvgLatency: 35 * time.Second, P90Latency: 58 * time.Second},
+		Verdict: aor.CanaryHealthy,
+		Since:   time.Now().Add(-6 * time.Hour),
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format comparison: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Workflow: %s (canary since %s)\n\n", comparison.WorkflowName, comparison.Since.Format(time.RFC3339))
+	fmt.Printf("%-8s %-8s %-6s %-10s %-10s %-12s %s\n", "ROLE", "VERSION", "RUNS", "SUCCESS", "AVG COST", "AVG LATENCY", "P90 LATENCY")
+	fmt.Println("--------------------------------------------------------------------------")
+	for _, row := range []struct {
+		role  string
+		stats aor.VersionRunStats
+	}{{"stable", comparison.Stable}, {"canary", comparison.Canary}} {
+		fmt.Printf("%-8s v%-7d %-6d %-10s %-10s %-12s %s\n", row.role, row.stats.Version, row.stats.Runs,
+			fmt.Sprintf("%.1f%%", row.stats.SuccessRate*100), fmt.Sprintf("%.1f¢", row.stats.AvgCostCents),
+			row.stats.AvgLatency.Round(time.Second), row.stats.P90Latency.Round(time.Second))
+	}
+
+	fmt.Printf("\nVerdict: %s\n", comparison.Verdict)
+	for _, reason := range comparison.Reasons {
+		fmt.Printf("  - %s\n", reason)
+	}
+	return nil
+}
+
+func runWorkflowCanaryPromote(cmd *cobra.Command, args []string) error {
+	// Mock promotion - in production would call aor.ControlPlane.PromoteWorkflowCanary
+	fmt.Printf("Promoted canary of workflow %s; all new runs now start on the canary version\n", args[0])
+	return nil
+}
+
+func runWorkflowCanaryRollback(cmd *cobra.Command, args []string) error {
+	// Mock rollback - in production would call aor.ControlPlane.RollbackWorkflowCanary
+	fmt.Printf("Rolled back canary of workflow %s; all new runs now start on the stable version\n", args[0])
+	return nil
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var providerCmd = &cobra.Command{
+	Use:   "provider",
+	Short: "Debug LLM provider traffic",
+}
+
+var providerCaptureCmd = &cobra.Command{
+	Use:   "capture",
+	Short: "Capture raw provider requests and responses for a limited window",
+	Long: `Admins can capture raw HTTP exchanges with one provider to diagnose formatting
+bugs. Credential headers are redacted and bodies are PII-scrubbed before storage.`,
+}
+
+var providerCaptureStartCmd = &cobra.Command{
+	Use:   "start [provider]",
+	Short: "Start capturing a provider's exchanges",
+	Long:  `Start capturing, e.g. agentctl provider capture start anthropic --window 30m --sample-rate 0.1`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProviderCaptureStart,
+}
+
+var providerCaptureStopCmd = &cobra.Command{
+	Use:   "stop [provider]",
+	Short: "Stop capturing before the window ends; captured exchanges are kept",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProviderCaptureStop,
+}
+
+var providerCaptureListCmd = &cobra.Command{
+	Use:   "list [provider]",
+	Short: "List a provider's captured exchanges, newest first",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProviderCaptureList,
+}
+
+var providerHealthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Show provider health used to skip providers when routing",
+	Long: `Providers are pinged periodically and their recent calls tracked. A provider is
+unhealthy after 3 failed pings in a row or when half of its last calls failed with
+server errors or timeouts; routing skips it and fails over until it recovers.`,
+	RunE: runProviderHealth,
+}
+
+func init() {
+	providerHealthCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	providerCaptureStartCmd.Flags().Duration("window", 15*time.Minute, "How long to capture (at most 24h)")
+	providerCaptureStartCmd.Flags().Float64("sample-rate", 1, "Share of calls to capture (0.0-1.0)")
+	providerCaptureListCmd.Flags().IntP("limit", "l", 20, "Number of exchanges to return")
+	providerCaptureListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	providerCaptureCmd.AddCommand(providerCaptureStartCmd)
+	providerCaptureCmd.AddCommand(providerCaptureStopCmd)
+	providerCaptureCmd.AddCommand(providerCaptureListCmd)
+	providerCmd.AddCommand(providerCaptureCmd)
+	providerCmd.AddCommand(providerHealthCmd)
+}
+
+func runProviderHealth(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock health - in production would call cas.Service.GetProviderHealth
+	lastPing := time.Now().Add(-20 * time.Second)
+	health := []cas.ProviderHealth{
+		{ProviderName: "anthropic", ModelName: "claude-3-haiku", Healthy: true, ErrorRate: 0.02, Calls: 50, LastPing: lastPing},
+		{ProviderName: "openai", ModelName: "gpt-4", Healthy: false, Reason: "3 consecutive health checks failed",
+			ConsecutivePingFailures: 3, ErrorRate: 0.38, Calls: 50, LastPing: lastPing, LastError: "health check returned 503"},
+		{ProviderName: "openai", ModelName: "gpt-3.5-turbo", Healthy: true, ErrorRate: 0.04, Calls: 50, LastPing: lastPing},
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(health, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format provider health: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-10s %-16s %-9s %-7s %-10s %s\n", "PROVIDER", "MODEL", "STATUS", "ERRORS", "LAST PING", "REASON")
+	for _, h := range health {
+		status := "healthy"
+		if !h.Healthy {
+			status = "unhealthy"
+		}
+		fmt.Printf("%-10s %-16s %-9s %-7s %-10s %s\n", h.ProviderName, h.ModelName, status,
+			fmt.Sprintf("%.0f%%", h.ErrorRate*100), time.Since(h.LastPing).Round(time.Second), h.Reason)
+	}
+	return nil
+}
+
+func runProviderCaptureStart(cmd *cobra.Command, args []string) error {
+	window, _ := cmd.Flags().GetDuration("window")
+	sampleRate, _ := cmd.Flags().GetFloat64("sample-rate")
+
+	req := &aor.DebugCaptureRequest{Provider: args[0], Window: window, SampleRate: sampleRate}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	// Mock start - in production would call aor.ControlPlane.StartDebugCapture
+	expires := time.Now().Add(req.Window)
+	fmt.Printf("Capturing %s exchanges until %s (sample rate %.0f%%)\n", req.Provider, expires.Format(time.RFC3339), req.SampleRate*100)
+	fmt.Println("Credential headers are redacted and bodies PII-scrubbed before storage")
+	fmt.Printf("View with 'agentctl provider capture list %s'\n", req.Provider)
+	return nil
+}
+
+func runProviderCaptureStop(cmd *cobra.Command, args []string) error {
+	// Mock stop - in production would call aor.ControlPlane.StopDebugCapture
+	fmt.Printf("Stopped capturing %s exchanges\n", args[0])
+	return nil
+}
+
+func runProviderCaptureList(cmd *cobra.Command, args []string) error {
+	limit, _ := cmd.Flags().GetInt("limit")
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock exchanges - in production would call aor.ControlPlane.ListProviderExchanges
+	exchanges := []aor.ProviderExchange{
+		{
+			ID: uuid.New(), Provider: args[0], Model: "claude-3-haiku",
+			Request: aor.CapturedMessage{Method: "POST", URL: "https://api.anthropic.com/v1/messages",
+				Headers: map[string]string{"x-api-key": "[REDACTED]", "Content-Type": "application/json"},
+				Body:    map[string]interface{}{"model": "claude-3-haiku", "max_tokens": 512}},
+			Response: aor.CapturedMessage{Status: 200,
+				Body: map[string]interface{}{"stop_reason": "end_turn", "content": []interface{}{map[string]interface{}{"type": "text", "text": "Contact [REDACTED_EMAIL]"}}}},
+			Latency: 840 * time.Millisecond, Redactions: map[string]int{"email": 1}, CapturedAt: time.Now().Add(-2 * time.Minute),
+		},
+		{
+			ID: uuid.New(), Provider: args[0], Model: "claude-3-haiku",
+			Request:  aor.CapturedMessage{Method: "POST", URL: "https://api.anthropic.com/v1/messages"},
+			Response: aor.CapturedMessage{Status: 529},
+			Error:    "overloaded_error", Latency: 210 * time.Millisecond, CapturedAt: time.Now().Add(-5 * time.Minute),
+		},
+	}
+	if limit > 0 && len(exchanges) > limit {
+		exchanges = exchanges[:limit]
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(exchanges, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format exchanges: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-20s %-18s %-7s %-10s %s\n", "CAPTURED", "MODEL", "STATUS", "LATENCY", "ERROR")
+	for _, exchange := range exchanges {
+		fmt.Printf("%-20s %-18s %-7d %-10s %s\n", exchange.CapturedAt.Format("2006-01-02 15:04:05"),
+			exchange.Model, exchange.Response.Status, exchange.Latency.Round(time.Millisecond), exchange.Error)
+	}
+	fmt.Println("\nUse -o json for full redacted requests and responses")
+	return nil
+}
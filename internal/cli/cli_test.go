@@ -2,12 +2,20 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"go/format"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -351,6 +359,149 @@ func TestEnvironmentVariables(t *testing.T) {
 	})
 }
 
+func TestAgentTemplates(t *testing.T) {
+	data := agentTemplateData{Name: "doc-search", Version: "0.1.0", Port: 9000}
+	sources := map[string][]string{
+		"go":         {"main.go", "main_test.go"},
+		"python":     {"app.py", "test_app.py"},
+		"typescript": {"src/agent.ts", "src/agent.test.ts"},
+	}
+
+	for _, lang := range agentLanguageNames() {
+		t.Run(lang, func(t *testing.T) {
+			files, err := generateAgent(data, lang)
+			require.NoError(t, err)
+			for _, path := range []string{"README.md", "capability.json", "Dockerfile"} {
+				assert.Contains(t, files, path)
+			}
+			assert.Contains(t, string(files["Dockerfile"]), "9000"+agentHTTPContract.HealthPath)
+
+			// Every server and its sample test cover the whole contract
+			require.Contains(t, sources, lang)
+			for _, path := range sources[lang] {
+				for _, route := range []string{agentHTTPContract.HealthPath, agentHTTPContract.ExecutePath, agentHTTPContract.StatusPath} {
+					assert.Contains(t, string(files[path]), route+`"`, "%s lacks %s", path, route)
+				}
+			}
+
+			var capability aor.AgentCapability
+			require.NoError(t, json.Unmarshal(files["capability.json"], &capability))
+			assert.NoError(t, capability.Validate())
+			assert.Equal(t, "doc-search", capability.Name)
+		})
+	}
+
+	t.Run("GoSourcesAreFormatted", func(t *testing.T) {
+		files, err := generateAgent(data, "go")
+		require.NoError(t, err)
+		for _, path := range []string{"main.go", "main_test.go"} {
+			formatted, err := format.Source(files[path])
+			require.NoError(t, err, path)
+			assert.Equal(t, string(formatted), string(files[path]), path)
+		}
+	})
+
+	t.Run("RejectsInvalidInput", func(t *testing.T) {
+		_, err := generateAgent(data, "rust")
+		assert.Error(t, err)
+		_, err = generateAgent(agentTemplateData{Name: "Doc Search", Port: 9000}, "go")
+		assert.Error(t, err)
+		_, err = generateAgent(agentTemplateData{Name: "doc-search", Port: 70000}, "go")
+		assert.Error(t, err)
+	})
+}
+
+func TestDoctorChecks(t *testing.T) {
+	ctx := context.Background()
+	client := &http.Client{Timeout: doctorTimeout}
+
+	t.Run("EndpointAndClockSkew", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Date", time.Now().Add(2*time.Minute).UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		checks := checkEndpoint(ctx, client, server.URL)
+		require.Len(t, checks, 2)
+		assert.Equal(t, doctorPass, checks[0].Status)
+		assert.Contains(t, checks[0].Detail, "answered 200")
+		assert.Equal(t, "clock skew", checks[1].Name)
+		assert.Equal(t, doctorFail, checks[1].Status)
+		assert.NotEmpty(t, checks[1].Fix)
+	})
+
+	t.Run("RejectedToken", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		checks := checkEndpoint(ctx, client, server.URL)
+		assert.Equal(t, doctorFail, checks[0].Status)
+		assert.Contains(t, checks[0].Fix, "config login")
+		assert.Equal(t, doctorPass, checks[1].Status)
+	})
+
+	t.Run("UnreachableEndpoint", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		checks := checkEndpoint(ctx, client, server.URL)
+		require.Len(t, checks, 1)
+		assert.Equal(t, doctorFail, checks[0].Status)
+		assert.Contains(t, checks[0].Detail, "unreachable")
+
+		checks = checkEndpoint(ctx, client, "")
+		assert.Equal(t, doctorSkip, checks[0].Status)
+	})
+
+	t.Run("ClockSkewAllowsPrecision", func(t *testing.T) {
+		now := time.Now()
+		assert.Equal(t, doctorPass, checkClockSkew("server", now, now.Add(-30*time.Second), time.Second).Status)
+		assert.Equal(t, doctorFail, checkClockSkew("server", now, now.Add(-32*time.Second), time.Second).Status)
+	})
+
+	t.Run("ProviderKeyProbe", func(t *testing.T) {
+		statuses := map[string]int{"good": http.StatusOK, "limited": http.StatusTooManyRequests, "bad": http.StatusUnauthorized, "down": http.StatusBadGateway}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(statuses[r.Header.Get("x-api-key")])
+		}))
+		defer server.Close()
+		probe := providerProbe{EnvVar: "TEST_API_KEY", URL: server.URL,
+			Auth: func(req *http.Request, key string) { req.Header.Set("x-api-key", key) }}
+
+		check := probeProviderKey(ctx, client, "test", probe, "good")
+		assert.Equal(t, doctorPass, check.Status)
+		assert.Equal(t, "test key", check.Name)
+
+		check = probeProviderKey(ctx, client, "test", probe, "limited")
+		assert.Equal(t, doctorPass, check.Status)
+		assert.Contains(t, check.Detail, "rate limited")
+
+		check = probeProviderKey(ctx, client, "test", probe, "bad")
+		assert.Equal(t, doctorFail, check.Status)
+		assert.Contains(t, check.Fix, "TEST_API_KEY")
+
+		check = probeProviderKey(ctx, client, "test", probe, "down")
+		assert.Equal(t, doctorFail, check.Status)
+		assert.Contains(t, check.Detail, "502")
+	})
+
+	t.Run("LatestMigration", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"001_init.up.sql", "001_init.down.sql", "012_views.up.sql", "037_analytics.up.sql", "notes.up.sql"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+		}
+		latest, err := latestMigration(dir)
+		require.NoError(t, err)
+		assert.Equal(t, 37, latest)
+
+		_, err = latestMigration(t.TempDir())
+		assert.Error(t, err)
+	})
+}
+
 // Helper functions
 func findSubcommand(parent *cobra.Command, name string) *cobra.Command {
 	for _, cmd := range parent.Commands() {
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var runTurnCmd = &cobra.Command{
+	Use:   "turn [run-id]",
+	Short: "Send a follow-up turn to a conversational run",
+	Long: `Re-execute a conversational run's turn steps with new inputs and the session
+memory of earlier turns, e.g. agentctl run turn 7c1e... --message "and in French?"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRunTurn,
+}
+
+var runCloseCmd = &cobra.Command{
+	Use:   "close [run-id]",
+	Short: "Close a conversational run, completing it",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRunClose,
+}
+
+var runConversationCmd = &cobra.Command{
+	Use:   "conversation [run-id]",
+	Short: "Show a conversational run's turns and their cost",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRunConversation,
+}
+
+func init() {
+	runTurnCmd.Flags().StringP("message", "m", "", "Turn message, passed as the message input")
+	runTurnCmd.Flags().StringToStringP("input", "i", nil, "Turn input key=value (repeatable)")
+	runTurnCmd.Flags().Int64("max-cost", 0, "Reject the turn if its estimated cost in cents is higher")
+	runConversationCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	runCmd.AddCommand(runTurnCmd)
+	runCmd.AddCommand(runCloseCmd)
+	runCmd.AddCommand(runConversationCmd)
+}
+
+func runRunTurn(cmd *cobra.Command, args []string) error {
+	runID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %w", err)
+	}
+	message, _ := cmd.Flags().GetString("message")
+	inputFlags, _ := cmd.Flags().GetStringToString("input")
+	maxCost, _ := cmd.Flags().GetInt64("max-cost")
+
+	req := &aor.TurnRequest{Inputs: make(map[string]interface{}, len(inputFlags)+1), MaxCostCents: maxCost}
+	for k, v := range inputFlags {
+		req.Inputs[k] = v
+	}
+	if message != "" {
+		req.Inputs["message"] = message
+	}
+	if len(req.Inputs) == 0 {
+		return fmt.Errorf("a turn needs --message or at least one --input")
+	}
+
+	// Mock turn - in production would call aor.ControlPlane.SubmitTurn
+	turn := &aor.ConversationTurn{RunID: runID, Turn: 2, Status: aor.TurnStatusRunning, Inputs: req.Inputs, CreatedAt: time.Now()}
+
+	fmt.Printf("Turn %d of run %s started\n", turn.Turn, runID)
+	fmt.Printf("Follow it with: agentctl run conversation %s\n", runID)
+	return nil
+}
+
+func runRunClose(cmd *cobra.Command, args []string) error {
+	runID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %w", err)
+	}
+
+	// Mock close - in production would call aor.ControlPlane.CloseConversation
+	fmt.Printf("Closed conversation %s\n", runID)
+	return nil
+}
+
+func runRunConversation(cmd *cobra.Command, args []string) error {
+	runID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %w", err)
+	}
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock conversation - in production would call aor.ControlPlane.GetConversation
+	now := time.Now()
+	first, second := now.Add(-9*time.Minute), now.Add(-2*time.Minute)
+	conv := &aor.Conversation{
+		RunID:     runID,
+		State:     aor.ConversationOpen,
+		Steps:     []string{"retrieve", "answer"},
+		ReplyStep: "answer",
+		Turns: []aor.ConversationTurn{
+			{RunID: runID, Turn: 1, Status: aor.TurnStatusCompleted, CostCents: 14, CreatedAt: now.Add(-10 * time.Minute), CompletedAt: &first,
+				Inputs: map[string]interface{}{"message": "Summarize the Q3 incident report"}},
+			{RunID: runID, Turn: 2, Status: aor.TurnStatusCompleted, CostCents: 6, CreatedAt: now.Add(-3 * time.Minute), CompletedAt: &second,
+				Inputs: map[string]interface{}{"message": "Which services were affected?"}},
+		},
+		LastTurnAt: now.Add(-3 * time.Minute),
+	}
+	for _, turn := range conv.Turns {
+		conv.CostCents += turn.CostCents
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(conv, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format conversation: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Conversation %s (%s, reply step %s)\n", runID, conv.State, conv.ReplyStep)
+	fmt.Printf("%-5s %-10s %-20s %-8s %s\n", "TURN", "STATUS", "STARTED", "COST", "INPUTS")
+	for _, turn := range conv.Turns {
+		inputs, _ := json.Marshal(turn.Inputs)
+		fmt.Printf("%-5d %-10s %-20s %-8s %s\n", turn.Turn, turn.Status, turn.CreatedAt.Format("2006-01-02 15:04:05"),
+			fmt.Sprintf("%d¢", turn.CostCents), string(inputs))
+	}
+	fmt.Printf("Total: %d¢ over %d turns\n", conv.CostCents, len(conv.Turns))
+	return nil
+}
@@ -0,0 +1,288 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var dataCmd = &cobra.Command{
+	Use:   "data",
+	Short: "Manage data retention and subject deletion requests",
+}
+
+var dataRetentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Show or set how long run inputs and outputs are kept",
+	Long: `Run inputs and outputs older than the retention period are purged hourly.
+Run records, status and cost are kept. 0 days keeps inputs and outputs indefinitely.`,
+	RunE: runDataRetention,
+}
+
+var dataDeleteCmd = &cobra.Command{
+	Use:   "delete [key=value]",
+	Short: "Erase all data associated with a subject identifier",
+	Long: `Erase every run tagged or labeled with the subject, with its steps, cached step
+outputs, content blobs, traces, feedback and dead-lettered tasks, and every
+context bundle whose provenance metadata carries it, e.g.
+agentctl data delete user_id=u-123. Cached LLM responses are not erased; they
+expire with their step's response cache TTL. The control plane signs each
+report with deletion.digest_key and refuses requests without it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDataDelete,
+}
+
+var dataDeletionCmd = &cobra.Command{
+	Use:   "deletion [request-id]",
+	Short: "Show a deletion request's report",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDataDeletion,
+}
+
+var dataVerifyCmd = &cobra.Command{
+	Use:   "verify [request-id] [key=value]",
+	Short: "Check that a deletion report is intact and no data for the subject remains",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runDataVerify,
+}
+
+var dataGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Reclaim content blobs no run, spec, prompt or dataset refers to",
+	Long: `Delete content blobs with no remaining references once they have gone unstored
+for the grace period. Blobs referenced by retained runs are never deleted.
+Use --dry-run to list what would be reclaimed.`,
+	RunE: runDataGC,
+}
+
+func init() {
+	dataRetentionCmd.Flags().Int("run-io-days", -1, "Days to keep run inputs and outputs (0 keeps them indefinitely)")
+	dataDeleteCmd.Flags().Bool("yes", false, "Confirm the deletion; it cannot be undone")
+	dataDeletionCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	dataGCCmd.Flags().Bool("dry-run", false, "Report what would be reclaimed without deleting")
+	dataGCCmd.Flags().Duration("grace", 0, "Minimum time since a blob was last stored (default: configured grace)")
+	dataGCCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	dataCmd.AddCommand(dataRetentionCmd)
+	dataCmd.AddCommand(dataDeleteCmd)
+	dataCmd.AddCommand(dataDeletionCmd)
+	dataCmd.AddCommand(dataVerifyCmd)
+	dataCmd.AddCommand(dataGCCmd)
+}
+
+func runDataRetention(cmd *cobra.Command, args []string) error {
+	days, _ := cmd.Flags().GetInt("run-io-days")
+
+	if cmd.Flags().Changed("run-io-days") {
+		if days < 0 {
+			return fmt.Errorf("--run-io-days must not be negative")
+		}
+		// Mock set - in production would call aor.ControlPlane.SetRetentionPolicy
+		if days == 0 {
+			fmt.Println("Run inputs and outputs will be kept indefinitely")
+		} else {
+			fmt.Printf("Run inputs and outputs will be purged %d days after a run ends\n", days)
+		}
+		return nil
+	}
+
+	// Mock policy - in production would call aor.ControlPlane.GetRetentionPolicy
+	policy := aor.RetentionPolicy{RunIODays: 30, UpdatedAt: time.Now().Add(-72 * time.Hour)}
+	if policy.RunIODays == 0 {
+		fmt.Println("Run inputs and outputs: kept indefinitely")
+	} else {
+		fmt.Printf("Run inputs and outputs: purged %d days after a run ends\n", policy.RunIODays)
+	}
+	fmt.Printf("Updated: %s\n", policy.UpdatedAt.Format(time.RFC3339))
+	return nil
+}
+
+func runDataDelete(cmd *cobra.Command, args []string) error {
+	key, value, err := aor.ParseDeletionSubject(args[0])
+	if err != nil {
+		return err
+	}
+	if confirmed, _ := cmd.Flags().GetBool("yes"); !confirmed {
+		return fmt.Errorf("deleting all data for %s=%s cannot be undone; re-run with --yes", key, value)
+	}
+
+	// Mock deletion - in production would call aor.ControlPlane.RequestDeletion
+	req := mockDeletionRequest(key, value)
+	printDeletionReport(req)
+	fmt.Printf("\nKeep the request ID; verify later with 'agentctl data verify %s %s=%s'\n", req.ID, key, value)
+	return nil
+}
+
+func runDataDeletion(cmd *cobra.Command, args []string) error {
+	requestID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid request ID: %w", err)
+	}
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock request - in production would call aor.ControlPlane.GetDeletionRequest
+	req := mockDeletionRequest("user_id", "u-123")
+	req.ID = requestID
+
+	if output == "json" {
+		data, err := json.MarshalIndent(req, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format deletion request: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	printDeletionReport(req)
+	return nil
+}
+
+func runDataVerify(cmd *cobra.Command, args []string) error {
+	requestID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid request ID: %w", err)
+	}
+	if _, _, err := aor.ParseDeletionSubject(args[1]); err != nil {
+		return err
+	}
+
+	// Mock verification - in production would call aor.ControlPlane.VerifyDeletion
+	verification := aor.DeletionVerification{
+		RequestID:   requestID,
+		DigestValid: true,
+		Remaining:   map[string]int64{"runs": 0, "step_runs": 0, "cache_entries": 0, "trace_events": 0, "feedback": 0, "dead_letters": 0, "context_bundles": 0},
+		Verified:    true,
+	}
+
+	if verification.DigestValid {
+		fmt.Println("Report digest: intact")
+	} else {
+		fmt.Println("Report digest: DOES NOT MATCH")
+	}
+	for _, store := range []string{"runs", "step_runs", "cache_entries", "trace_events", "feedback", "dead_letters", "context_bundles"} {
+		fmt.Printf("  %-16s %d remaining\n", store, verification.Remaining[store])
+	}
+	if !verification.Verified {
+		return fmt.Errorf("deletion %s could not be verified", requestID)
+	}
+	fmt.Printf("Deletion %s verified\n", requestID)
+	return nil
+}
+
+func mockDeletionRequest(key, value string) *aor.DeletionRequest {
+	subjectHash := db.HashContent([]byte(key + "=" + value))
+	completed := time.Now()
+	return &aor.DeletionRequest{
+		ID: uuid.New(), SubjectHash: subjectHash, Status: aor.DeletionStatusCompleted,
+		CreatedAt: completed.Add(-4 * time.Second), CompletedAt: &completed,
+		Report: &aor.DeletionReport{
+			SubjectHash: subjectHash, RunIDs: []uuid.UUID{uuid.New(), uuid.New()},
+			Runs: 2, StepRuns: 7, CacheEntries: 3, ContextBundles: 1, Blobs: 4, TraceEvents: 58, Feedback: 1, DeadLetters: 2,
+			Retained:    map[string]string{"response_cache": "cached LLM responses are keyed by prompt and input hashes rather than runs; they expire with their step's response_cache ttl"},
+			CompletedAt: completed, Digest: "hmac-sha256:9f2c4e1ab07d5c3e8a6f0b21d94e7c5a3b8f1e06d2c7a94b5e3f80c1d6a2b7e4",
+		},
+	}
+}
+
+func printDeletionReport(req *aor.DeletionRequest) {
+	fmt.Printf("Deletion request %s: %s\n", req.ID, req.Status)
+	if req.Error != "" {
+		fmt.Printf("Error: %s\n", req.Error)
+	}
+	report := req.Report
+	if report == nil {
+		return
+	}
+	fmt.Printf("  Runs:             %d\n", report.Runs)
+	fmt.Printf("  Step runs:        %d\n", report.StepRuns)
+	fmt.Printf("  Cache entries:    %d\n", report.CacheEntries)
+	fmt.Printf("  Content blobs:    %d\n", report.Blobs)
+	if report.TracesSkipped {
+		fmt.Println("  Trace events:     skipped (no trace store attached)")
+	} else {
+		fmt.Printf("  Trace events:     %d\n", report.TraceEvents)
+	}
+	fmt.Printf("  Feedback:         %d\n", report.Feedback)
+	fmt.Printf("  Dataset examples: %d\n", report.DatasetExamples)
+	fmt.Printf("  Dead letters:     %d\n", report.DeadLetters)
+	fmt.Printf("  Context bundles:  %d\n", report.ContextBundles)
+	stores := make([]string, 0, len(report.Retained))
+	for store := range report.Retained {
+		stores = append(stores, store)
+	}
+	sort.Strings(stores)
+	for _, store := range stores {
+		fmt.Printf("Kept %s: %s\n", store, report.Retained[store])
+	}
+	fmt.Printf("Digest: %s\n", report.Digest)
+}
+
+func runDataGC(cmd *cobra.Command, args []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	grace, _ := cmd.Flags().GetDuration("grace")
+	output, _ := cmd.Flags().GetString("output")
+	if grace < 0 {
+		return fmt.Errorf("--grace must not be negative")
+	}
+	if grace == 0 {
+		grace = 24 * time.Hour
+	}
+
+	// Mock GC - in production would call aor.ControlPlane.CollectArtifacts
+	report := &aor.ArtifactGCReport{
+		DryRun: dryRun, Grace: grace, StartedAt: time.Now(),
+		Scanned: 1842, Referenced: 1610, InGrace: 57, Reclaimed: 175, ReclaimedBytes: 48_213_504,
+		ByKind: map[string]aor.ArtifactKindUsage{
+			"media":         {Blobs: 1204, Bytes: 312_475_648, Reclaimed: 121, ReclaimedBytes: 30_408_704},
+			"dataset":       {Blobs: 596, Bytes: 96_468_992, Reclaimed: 54, ReclaimedBytes: 17_804_800},
+			"workflow_spec": {Blobs: 42, Bytes: 389_120},
+		},
+	}
+	if dryRun {
+		orgID := uuid.New()
+		report.Candidates = []aor.ArtifactCandidate{
+			{OrgID: orgID, Hash: "sha256:4b1e07c9d2a35f8e6c0b9d74a1e2f5c83d6b0a9e7f4c21d58b3e6a0f9c7d2e41", Kind: "media",
+				SizeBytes: 2_097_152, TouchedAt: time.Now().Add(-9 * 24 * time.Hour)},
+			{OrgID: orgID, Hash: "sha256:a83f2d0c6e91b457d3c8e0f1a2b69d4e7c5f08b3a1d6e92c4f7b0a5d8e3c1f62", Kind: "dataset",
+				SizeBytes: 786_432, TouchedAt: time.Now().Add(-3 * 24 * time.Hour)},
+		}
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format GC report: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	verb := "Reclaimed"
+	if report.DryRun {
+		verb = "Would reclaim"
+	}
+	fmt.Printf("%s %d of %d blobs (%d bytes); %d referenced, %d within the %s grace period\n",
+		verb, report.Reclaimed, report.Scanned, report.ReclaimedBytes, report.Referenced, report.InGrace, report.Grace)
+	fmt.Printf("\n%-14s %8s %14s %10s %16s\n", "KIND", "BLOBS", "BYTES", "RECLAIMED", "RECLAIMED BYTES")
+	kinds := make([]string, 0, len(report.ByKind))
+	for kind := range report.ByKind {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		usage := report.ByKind[kind]
+		fmt.Printf("%-14s %8d %14d %10d %16d\n", kind, usage.Blobs, usage.Bytes, usage.Reclaimed, usage.ReclaimedBytes)
+	}
+	if len(report.Candidates) > 0 {
+		fmt.Printf("\n%-73s %-12s %10s %s\n", "HASH", "KIND", "BYTES", "LAST STORED")
+		for _, candidate := range report.Candidates {
+			fmt.Printf("%-73s %-12s %10d %s\n", candidate.Hash, candidate.Kind, candidate.SizeBytes, candidate.TouchedAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
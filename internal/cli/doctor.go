@@ -0,0 +1,351 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the CLI and server environment",
+	Long: `Check connectivity to the configured endpoint, provider API keys and clock skew, printing a pass/fail report with fixes.
+With --server, also check Postgres, ClickHouse and NATS from the server's config and compare the database schema with the migrations on disk.`,
+	RunE: runDoctor,
+}
+
+const (
+	doctorPass = "pass"
+	doctorFail = "fail"
+	doctorSkip = "skip"
+
+	doctorTimeout = 5 * time.Second
+	maxClockSkew  = 30 * time.Second
+)
+
+// doctorCheck is one line of the doctor report
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// providerProbe is a free, authenticated call that shows whether a key is valid
+type providerProbe struct {
+	EnvVar string
+	URL    string
+	Auth   func(req *http.Request, key string)
+}
+
+var providerProbes = map[string]providerProbe{
+	"openai": {EnvVar: "OPENAI_API_KEY", URL: "https://api.openai.com/v1/models",
+		Auth: func(req *http.Request, key string) { req.Header.Set("Authorization", "Bearer "+key) }},
+	"anthropic": {EnvVar: "ANTHROPIC_API_KEY", URL: "https://api.anthropic.com/v1/models",
+		Auth: func(req *http.Request, key string) {
+			req.Header.Set("x-api-key", key)
+			req.Header.Set("anthropic-version", "2023-06-01")
+		}},
+	"google": {EnvVar: "GEMINI_API_KEY", URL: "https://generativelanguage.googleapis.com/v1beta/models",
+		Auth: func(req *http.Request, key string) { req.Header.Set("x-goog-api-key", key) }},
+}
+
+func init() {
+	doctorCmd.Flags().Bool("server", false, "Also check the server's databases, NATS and schema version")
+	doctorCmd.Flags().String("migrations", "migrations", "Migrations directory the schema version is compared with")
+	doctorCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	serverSide, _ := cmd.Flags().GetBool("server")
+	migrationsDir, _ := cmd.Flags().GetString("migrations")
+	output, _ := cmd.Flags().GetString("output")
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), 6*doctorTimeout)
+	defer cancel()
+	client := &http.Client{Timeout: doctorTimeout}
+
+	checks := []doctorCheck{checkCLIConfig()}
+	checks = append(checks, checkEndpoint(ctx, client, viper.GetString("endpoint"))...)
+	checks = append(checks, checkProviderKeys(ctx, client)...)
+	if serverSide {
+		checks = append(checks, checkServerDependencies(ctx, migrationsDir)...)
+	}
+
+	failed := 0
+	for _, check := range checks {
+		if check.Status == doctorFail {
+			failed++
+		}
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(checks, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format doctor report: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("%-6s %-22s %s\n", "STATUS", "CHECK", "DETAIL")
+		for _, check := range checks {
+			fmt.Printf("%-6s %-22s %s\n", strings.ToUpper(check.Status), check.Name, check.Detail)
+		}
+		if failed > 0 {
+			fmt.Println("\nFixes:")
+			for _, check := range checks {
+				if check.Status == doctorFail && check.Fix != "" {
+					fmt.Printf("  %s: %s\n", check.Name, check.Fix)
+				}
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+func checkCLIConfig() doctorCheck {
+	check := doctorCheck{Name: "config", Status: doctorPass}
+	var missing []string
+	for _, key := range []string{"endpoint", "org", "token"} {
+		if viper.GetString(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+	source := viper.ConfigFileUsed()
+	if source == "" {
+		source = "flags and environment"
+	}
+	if len(missing) > 0 {
+		check.Status = doctorFail
+		check.Detail = fmt.Sprintf("%s not set (from %s)", strings.Join(missing, ", "), source)
+		check.Fix = "run agentctl config login --endpoint <url> --org <org-id> --token <token>"
+		return check
+	}
+	check.Detail = "endpoint, org and token set (from " + source + ")"
+	return check
+}
+
+// checkEndpoint checks the endpoint answers and compares its clock with ours
+func checkEndpoint(ctx context.Context, client *http.Client, endpoint string) []doctorCheck {
+	if endpoint == "" {
+		return []doctorCheck{{Name: "server", Status: doctorSkip, Detail: "no endpoint configured"}}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return []doctorCheck{{Name: "server", Status: doctorFail, Detail: fmt.Sprintf("invalid endpoint %q: %v", endpoint, err),
+			Fix: "set a full URL, e.g. agentctl config set endpoint https://agentflow.example.com"}}
+	}
+	if token := viper.GetString("token"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	sent := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return []doctorCheck{{Name: "server", Status: doctorFail, Detail: fmt.Sprintf("%s unreachable: %v", endpoint, err),
+			Fix: "check the endpoint URL, VPN or proxy settings, and that the control plane is running"}}
+	}
+	_ = resp.Body.Close()
+	latency := time.Since(sent)
+
+	checks := []doctorCheck{{Name: "server", Status: doctorPass,
+		Detail: fmt.Sprintf("%s answered %d in %s", endpoint, resp.StatusCode, latency.Round(time.Millisecond))}}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		checks[0].Status = doctorFail
+		checks[0].Fix = "the token was rejected; run agentctl config login with a current token"
+	}
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return append(checks, doctorCheck{Name: "clock skew", Status: doctorSkip, Detail: "server sent no Date header"})
+	}
+	// The Date header is stamped somewhere between sending and receiving
+	return append(checks, checkClockSkew("server", sent.Add(latency/2), serverTime, time.Second))
+}
+
+// checkClockSkew compares two clocks, allowing for the precision of the remote one
+func checkClockSkew(remote string, local, remoteTime time.Time, precision time.Duration) doctorCheck {
+	skew := remoteTime.Sub(local)
+	if skew < 0 {
+		skew = -skew
+	}
+	check := doctorCheck{Name: "clock skew", Status: doctorPass,
+		Detail: fmt.Sprintf("%s clock within %s of local", remote, (skew + precision).Round(time.Second))}
+	if skew > maxClockSkew+precision {
+		check.Status = doctorFail
+		check.Detail = fmt.Sprintf("%s clock is %s off local", remote, skew.Round(time.Second))
+		check.Fix = "enable NTP (timedatectl set-ntp true); skew breaks token expiry, budget windows and trace ordering"
+	}
+	return check
+}
+
+// checkProviderKeys probes each provider whose key is in the environment
+func checkProviderKeys(ctx context.Context, client *http.Client) []doctorCheck {
+	names := make([]string, 0, len(providerProbes))
+	for name := range providerProbes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var checks []doctorCheck
+	for _, name := range names {
+		probe := providerProbes[name]
+		key := os.Getenv(probe.EnvVar)
+		if key == "" {
+			continue
+		}
+		checks = append(checks, probeProviderKey(ctx, client, name, probe, key))
+	}
+	if len(checks) == 0 {
+		envVars := make([]string, 0, len(names))
+		for _, name := range names {
+			envVars = append(envVars, providerProbes[name].EnvVar)
+		}
+		checks = append(checks, doctorCheck{Name: "provider keys", Status: doctorSkip,
+			Detail: "none of " + strings.Join(envVars, ", ") + " set"})
+	}
+	return checks
+}
+
+func probeProviderKey(ctx context.Context, client *http.Client, name string, probe providerProbe, key string) doctorCheck {
+	check := doctorCheck{Name: name + " key", Status: doctorPass}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probe.URL, nil)
+	if err != nil {
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("failed to build probe: %v", err)
+		return check
+	}
+	probe.Auth(req, key)
+	resp, err := client.Do(req)
+	if err != nil {
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("probe failed: %v", err)
+		check.Fix = "check outbound access to " + req.URL.Host
+		return check
+	}
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		check.Detail = probe.EnvVar + " accepted"
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("%s rejected (%d)", probe.EnvVar, resp.StatusCode)
+		check.Fix = "create a new key in the " + name + " console and update " + probe.EnvVar
+	case resp.StatusCode == http.StatusTooManyRequests:
+		check.Detail = probe.EnvVar + " accepted but rate limited"
+	default:
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("probe returned %d", resp.StatusCode)
+		check.Fix = "check the " + name + " status page and retry"
+	}
+	return check
+}
+
+// checkServerDependencies checks the stores and bus the server's config points at
+func checkServerDependencies(ctx context.Context, migrationsDir string) []doctorCheck {
+	cfg, err := config.Load()
+	if err != nil {
+		return []doctorCheck{{Name: "server config", Status: doctorFail, Detail: err.Error(),
+			Fix: "run doctor where the server's config.yaml or environment is available"}}
+	}
+
+	var checks []doctorCheck
+	postgres, err := db.NewPostgresDB(&cfg.Database)
+	if err != nil {
+		checks = append(checks, doctorCheck{Name: "postgres", Status: doctorFail, Detail: err.Error(),
+			Fix: fmt.Sprintf("check database.* settings and that Postgres accepts connections on %s:%d", cfg.Database.Host, cfg.Database.Port)})
+	} else {
+		defer func() { _ = postgres.Close() }()
+		checks = append(checks, doctorCheck{Name: "postgres", Status: doctorPass,
+			Detail: fmt.Sprintf("connected to %s on %s:%d", cfg.Database.Database, cfg.Database.Host, cfg.Database.Port)})
+		checks = append(checks, checkSchemaVersion(ctx, postgres, migrationsDir))
+
+		var dbTime time.Time
+		if err := postgres.QueryRowContext(ctx, `SELECT now()`).Scan(&dbTime); err == nil {
+			checks = append(checks, checkClockSkew("postgres", time.Now(), dbTime, 0))
+		}
+	}
+
+	clickhouse, err := db.NewClickHouseDB(&cfg.ClickHouse)
+	if err != nil {
+		checks = append(checks, doctorCheck{Name: "clickhouse", Status: doctorFail, Detail: err.Error(),
+			Fix: fmt.Sprintf("check clickhouse.* settings and that ClickHouse's native port is reachable on %s:%d", cfg.ClickHouse.Host, cfg.ClickHouse.Port)})
+	} else {
+		_ = clickhouse.Close()
+		checks = append(checks, doctorCheck{Name: "clickhouse", Status: doctorPass,
+			Detail: fmt.Sprintf("connected to %s on %s:%d", cfg.ClickHouse.Database, cfg.ClickHouse.Host, cfg.ClickHouse.Port)})
+	}
+
+	nc, err := nats.Connect(cfg.NATS.URL, nats.Timeout(doctorTimeout))
+	if err != nil {
+		checks = append(checks, doctorCheck{Name: "nats", Status: doctorFail, Detail: err.Error(),
+			Fix: "check nats.url (" + cfg.NATS.URL + ") and that the NATS server is running"})
+	} else {
+		checks = append(checks, doctorCheck{Name: "nats", Status: doctorPass, Detail: "connected to " + nc.ConnectedUrlRedacted()})
+		nc.Close()
+	}
+	return checks
+}
+
+// checkSchemaVersion compares the applied migration with the newest one on disk
+func checkSchemaVersion(ctx context.Context, postgres *db.PostgresDB, migrationsDir string) doctorCheck {
+	check := doctorCheck{Name: "schema version", Status: doctorPass}
+	latest, err := latestMigration(migrationsDir)
+	if err != nil {
+		check.Status, check.Detail = doctorSkip, err.Error()
+		return check
+	}
+
+	var version int
+	var dirty bool
+	if err := postgres.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty); err != nil {
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("failed to read schema_migrations: %v", err)
+		check.Fix = "start the control plane once to apply migrations"
+		return check
+	}
+	switch {
+	case dirty:
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("migration %03d failed part way (dirty)", version)
+		check.Fix = fmt.Sprintf("fix the schema by hand, then run migrate force %d", version)
+	case version < latest:
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("database at %03d, migrations go to %03d", version, latest)
+		check.Fix = "restart the control plane to apply pending migrations"
+	case version > latest:
+		check.Status, check.Detail = doctorFail, fmt.Sprintf("database at %03d is newer than this build's %03d", version, latest)
+		check.Fix = "upgrade agentctl and the server to the release that added migration " + fmt.Sprintf("%03d", version)
+	default:
+		check.Detail = fmt.Sprintf("database at %03d, up to date", version)
+	}
+	return check
+}
+
+// latestMigration returns the highest version among NNN_name.up.sql files
+func latestMigration(dir string) (int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	latest := 0
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(filepath.Base(file), "_")
+		if version, err := strconv.Atoi(prefix); err == nil && version > latest {
+			latest = version
+		}
+	}
+	if latest == 0 {
+		return 0, fmt.Errorf("no migrations found in %s", dir)
+	}
+	return latest, nil
+}
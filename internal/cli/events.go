@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/spf13/cobra"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Inspect the domain events published for extensions",
+	Long: `The control plane publishes domain events on NATS subjects under agentflow.events, one subject per event type.
+Each message is an envelope with id, type, schema_version, org_id, time and a data payload described by the event's schema.`,
+}
+
+var eventsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List event types and their subjects",
+	RunE:  runEventsList,
+}
+
+var eventsSchemaCmd = &cobra.Command{
+	Use:   "schema [event-type]",
+	Short: "Print the JSON Schema of an event's payload",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runEventsSchema,
+}
+
+func init() {
+	eventsListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	eventsCmd.AddCommand(eventsListCmd)
+	eventsCmd.AddCommand(eventsSchemaCmd)
+}
+
+func runEventsList(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	schemas := aor.EventSchemas()
+
+	if output == "json" {
+		data, err := json.MarshalIndent(schemas, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal event schemas: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-18s %-34s %s\n", "TYPE", "SUBJECT", "DESCRIPTION")
+	for _, schema := range schemas {
+		fmt.Printf("%-18s %-34s %s\n", schema.Type, schema.Subject, schema.Description)
+	}
+	fmt.Printf("\nSubscribe to %s> for every event (schema version %d)\n", aor.EventSubjectPrefix, aor.EventSchemaVersion)
+	return nil
+}
+
+func runEventsSchema(cmd *cobra.Command, args []string) error {
+	for _, schema := range aor.EventSchemas() {
+		if string(schema.Type) != args[0] {
+			continue
+		}
+		data, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal event schema: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	return fmt.Errorf("unknown event type %q: see agentctl events list", args[0])
+}
@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aos"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var finetuneCmd = &cobra.Command{
+	Use:   "finetune",
+	Short: "Curate fine-tuning datasets from production traces",
+	Long: `Select high-quality prompt/response pairs from traces, dedupe them and write
+provider-specific fine-tuning files, e.g.
+  agentctl finetune create support-replies --format openai --since 720h --min-eval-score 0.8
+  agentctl finetune lineage <run-id>
+
+Calls with detected or scrubbed PII and calls a reviewer rejected are always
+left out. Rate calls with 'agentctl trace feedback'.`,
+}
+
+var finetuneCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Curate a new version of a fine-tuning dataset",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runFinetuneCreate,
+}
+
+var finetuneListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List fine-tuning datasets",
+	RunE:  runFinetuneList,
+}
+
+var finetuneRunsCmd = &cobra.Command{
+	Use:   "runs [dataset-id]",
+	Short: "List the runs a dataset version was built from",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runFinetuneRuns,
+}
+
+var finetuneLineageCmd = &cobra.Command{
+	Use:   "lineage [run-id]",
+	Short: "List the dataset versions a run contributed to",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runFinetuneLineage,
+}
+
+func init() {
+	finetuneCreateCmd.Flags().StringP("format", "f", string(aos.FineTuneOpenAI), "File format (openai, anthropic, gemini)")
+	finetuneCreateCmd.Flags().String("since", "168h", "Only use calls from this long ago onward")
+	finetuneCreateCmd.Flags().StringP("provider", "p", "", "Only use calls to this provider")
+	finetuneCreateCmd.Flags().StringP("model", "m", "", "Only use calls to this model")
+	finetuneCreateCmd.Flags().Float64("min-eval-score", 0, "Minimum eval score (0 disables)")
+	finetuneCreateCmd.Flags().Bool("human-approved", false, "Only use calls a reviewer approved")
+	finetuneCreateCmd.Flags().Bool("allow-pii", false, "Keep calls with detected or scrubbed PII")
+	finetuneCreateCmd.Flags().Int("max-examples", 0, "Maximum examples (0 for no limit)")
+	finetuneListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	finetuneCmd.AddCommand(finetuneCreateCmd)
+	finetuneCmd.AddCommand(finetuneListCmd)
+	finetuneCmd.AddCommand(finetuneRunsCmd)
+	finetuneCmd.AddCommand(finetuneLineageCmd)
+}
+
+func runFinetuneCreate(cmd *cobra.Command, args []string) error {
+	formatFlag, _ := cmd.Flags().GetString("format")
+	since, _ := cmd.Flags().GetString("since")
+	provider, _ := cmd.Flags().GetString("provider")
+	model, _ := cmd.Flags().GetString("model")
+	minEval, _ := cmd.Flags().GetFloat64("min-eval-score")
+	humanApproved, _ := cmd.Flags().GetBool("human-approved")
+	allowPII, _ := cmd.Flags().GetBool("allow-pii")
+	maxExamples, _ := cmd.Flags().GetInt("max-examples")
+
+	format, err := aos.ParseFineTuneFormat(formatFlag)
+	if err != nil {
+		return err
+	}
+	window, err := time.ParseDuration(since)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	if minEval < 0 || minEval > 1 {
+		return fmt.Errorf("--min-eval-score must be between 0 and 1")
+	}
+
+	start := time.Now().Add(-window)
+	req := aos.CreateFineTuneDatasetRequest{
+		Name:   args[0],
+		Format: format,
+		Filter: aos.CurationFilter{
+			StartTime:            &start,
+			Provider:             provider,
+			Model:                model,
+			RequireHumanApproval: humanApproved,
+			AllowPII:             allowPII,
+			MaxExamples:          maxExamples,
+		},
+	}
+	if minEval > 0 {
+		req.Filter.MinEvalScore = &minEval
+	}
+
+	// Mock curation - in production would call aos.Service.CreateFineTuneDataset
+	dataset := aos.FineTuneDataset{
+		ID:      uuid.New(),
+		Name:    req.Name,
+		Version: 3,
+		Format:  req.Format,
+		Filter:  req.Filter,
+		Stats: aos.CurationStats{
+			Candidates: 4210,
+			Selected:   1187,
+			Dropped: map[string]int{
+				aos.DropLowEvalScore:       1904,
+				aos.DropPII:                312,
+				aos.DropDuplicate:          688,
+				aos.DropHumanRejected:      41,
+				aos.DropNoOutput:           63,
+				aos.DropUnsupportedContent: 15,
+			},
+		},
+		ExampleCount: 1187,
+		ContentHash:  "sha256:9c1e4f0a7d2b8e3c5f6a1b0d9e8c7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e",
+		CreatedAt:    time.Now(),
+	}
+
+	fmt.Printf("Created %s v%d (%s): %d examples from %d candidate calls\n",
+		dataset.Name, dataset.Version, dataset.Format, dataset.ExampleCount, dataset.Stats.Candidates)
+	for _, reason := range []string{aos.DropLowEvalScore, aos.DropNotHumanApproved, aos.DropHumanRejected, aos.DropPII,
+		aos.DropDuplicate, aos.DropNoOutput, aos.DropUnsupportedContent, aos.DropOverLimit} {
+		if count := dataset.Stats.Dropped[reason]; count > 0 {
+			fmt.Printf("  dropped %-20s %d\n", reason, count)
+		}
+	}
+	fmt.Printf("Content: %s\n", dataset.ContentHash)
+	return nil
+}
+
+func runFinetuneList(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock list - in production would call aos.Service.ListFineTuneDatasets
+	datasets := []aos.FineTuneDataset{
+		{ID: uuid.New(), Name: "support-replies", Version: 3, Format: aos.FineTuneOpenAI, ExampleCount: 1187, CreatedAt: time.Now().Add(-2 * time.Hour)},
+		{ID: uuid.New(), Name: "support-replies", Version: 2, Format: aos.FineTuneOpenAI, ExampleCount: 902, CreatedAt: time.Now().Add(-14 * 24 * time.Hour)},
+		{ID: uuid.New(), Name: "triage", Version: 1, Format: aos.FineTuneAnthropic, ExampleCount: 430, CreatedAt: time.Now().Add(-30 * 24 * time.Hour)},
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(datasets, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-36s %-18s %-8s %-10s %-9s %s\n", "ID", "NAME", "VERSION", "FORMAT", "EXAMPLES", "CREATED")
+	for _, dataset := range datasets {
+		fmt.Printf("%-36s %-18s %-8d %-10s %-9d %s\n", dataset.ID, dataset.Name, dataset.Version, dataset.Format,
+			dataset.ExampleCount, dataset.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+func runFinetuneRuns(cmd *cobra.Command, args []string) error {
+	datasetID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid dataset ID: %w", err)
+	}
+
+	// Mock lineage - in production would call aos.Service.GetFineTuneDatasetRuns
+	fmt.Printf("Runs in dataset %s:\n", datasetID)
+	for i := 0; i < 3; i++ {
+		fmt.Printf("  %s\n", uuid.New())
+	}
+	return nil
+}
+
+func runFinetuneLineage(cmd *cobra.Command, args []string) error {
+	runID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %w", err)
+	}
+
+	// Mock lineage - in production would call aos.Service.GetRunFineTuneDatasets
+	fmt.Printf("Run %s contributed to:\n", runID)
+	fmt.Printf("  support-replies v2 (openai)\n")
+	fmt.Printf("  support-replies v3 (openai)\n")
+	return nil
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/spf13/cobra"
+)
+
+var routeForecastCmd = &cobra.Command{
+	Use:   "forecast",
+	Short: "Forecast provider TPM/RPM needs against their limits",
+	Long: `Forecast each provider/model's peak tokens and requests per minute from recent
+traffic and compare them with the limits set as tpm_limit and rpm_limit in the
+provider config. Models forecast above 80% of a limit get quota increase and
+routing suggestions; the control plane alerts on them every 5 minutes.`,
+	RunE: runRouteForecast,
+}
+
+func init() {
+	routeForecastCmd.Flags().Duration("lookback", 6*time.Hour, "Recent traffic the forecast is fitted to")
+	routeForecastCmd.Flags().Duration("horizon", time.Hour, "How far ahead to forecast")
+	routeForecastCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	routeCmd.AddCommand(routeForecastCmd)
+}
+
+func runRouteForecast(cmd *cobra.Command, args []string) error {
+	horizon, _ := cmd.Flags().GetDuration("horizon")
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock forecasts - in production would call cas.Service.ForecastProviderUsage
+	forecasts := []cas.ProviderForecast{
+		{ProviderName: "openai", ModelName: "gpt-4o", Limits: cas.ProviderLimits{TPM: 800000, RPM: 10000},
+			CurrentTPM: 512000, CurrentRPM: 4100, ForecastTPM: 731000, ForecastRPM: 5900,
+			TPMUtilization: 0.914, RPMUtilization: 0.59, Level: cas.CapacityWarning, Horizon: horizon,
+			Suggestions: []string{
+				"Request a TPM quota increase for openai/gpt-4o to at least 914000 (limit 800000)",
+				"Route about 91000 TPM from openai/gpt-4o to anthropic/claude-3-5-sonnet, which has 212000 TPM of forecast headroom",
+			}},
+		{ProviderName: "anthropic", ModelName: "claude-3-5-sonnet", Limits: cas.ProviderLimits{TPM: 400000, RPM: 4000},
+			CurrentTPM: 41000, CurrentRPM: 380, ForecastTPM: 58000, ForecastRPM: 520,
+			TPMUtilization: 0.145, RPMUtilization: 0.13, Level: cas.CapacityOK, Horizon: horizon},
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(forecasts, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal forecasts: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Forecast peak per minute over the next %s\n\n", horizon)
+	fmt.Printf("%-30s %-10s %-22s %-18s %s\n", "PROVIDER/MODEL", "LEVEL", "TPM (NOW/PEAK/LIMIT)", "RPM (NOW/PEAK/LIMIT)", "UTILIZATION")
+	for _, forecast := range forecasts {
+		utilization := forecast.TPMUtilization
+		if forecast.RPMUtilization > utilization {
+			utilization = forecast.RPMUtilization
+		}
+		fmt.Printf("%-30s %-10s %-22s %-18s %.0f%%\n", forecast.ProviderName+"/"+forecast.ModelName, forecast.Level,
+			fmt.Sprintf("%.0f/%.0f/%d", forecast.CurrentTPM, forecast.ForecastTPM, forecast.Limits.TPM),
+			fmt.Sprintf("%.0f/%.0f/%d", forecast.CurrentRPM, forecast.ForecastRPM, forecast.Limits.RPM),
+			utilization*100)
+	}
+	for _, forecast := range forecasts {
+		for _, suggestion := range forecast.Suggestions {
+			fmt.Printf("\n  - %s", suggestion)
+		}
+	}
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/spf13/cobra"
+)
+
+var freezeCmd = &cobra.Command{
+	Use:   "freeze",
+	Short: "Manage run freeze windows",
+	Long:  "Pause cron and webhook triggered runs during maintenance or deploy freezes, rejecting or queueing them until the window ends",
+}
+
+var freezeCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a freeze window",
+	Long: `Create a freeze window, e.g.
+  agentctl freeze create deploy-freeze --start 2024-06-01T18:00:00Z --duration 4h --tag env=prod --mode queue`,
+	Args: cobra.ExactArgs(1),
+	RunE: runFreezeCreate,
+}
+
+var freezeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List current and upcoming freeze windows",
+	RunE:  runFreezeList,
+}
+
+var freezeDeleteCmd = &cobra.Command{
+	Use:   "delete [window-id]",
+	Short: "Delete a freeze window and release runs it deferred",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runFreezeDelete,
+}
+
+func init() {
+	freezeCreateCmd.Flags().String("start", "", "Window start as RFC3339 (default: now)")
+	freezeCreateCmd.Flags().String("end", "", "Window end as RFC3339")
+	freezeCreateCmd.Flags().Duration("duration", 0, "Window length, used when --end is not set")
+	freezeCreateCmd.Flags().String("mode", string(aor.FreezeModeReject), "What to do with blocked runs (reject, queue)")
+	freezeCreateCmd.Flags().StringSlice("trigger", nil, "Triggers to block (manual, cron, webhook, replay; default: cron,webhook)")
+	freezeCreateCmd.Flags().StringSlice("workflow", nil, "Only block these workflows")
+	freezeCreateCmd.Flags().StringToString("tag", nil, "Only block runs with these tags")
+	freezeCreateCmd.Flags().String("reason", "", "Reason shown to blocked submitters")
+
+	freezeCmd.AddCommand(freezeCreateCmd)
+	freezeCmd.AddCommand(freezeListCmd)
+	freezeCmd.AddCommand(freezeDeleteCmd)
+}
+
+func runFreezeCreate(cmd *cobra.Command, args []string) error {
+	startStr, _ := cmd.Flags().GetString("start")
+	endStr, _ := cmd.Flags().GetString("end")
+	duration, _ := cmd.Flags().GetDuration("duration")
+	mode, _ := cmd.Flags().GetString("mode")
+	triggers, _ := cmd.Flags().GetStringSlice("trigger")
+	workflows, _ := cmd.Flags().GetStringSlice("workflow")
+	tags, _ := cmd.Flags().GetStringToString("tag")
+	reason, _ := cmd.Flags().GetString("reason")
+
+	window := &aor.FreezeWindow{
+		Name:      args[0],
+		Reason:    reason,
+		StartsAt:  time.Now(),
+		Mode:      aor.FreezeMode(mode),
+		Workflows: workflows,
+		Tags:      tags,
+	}
+	for _, trigger := range triggers {
+		window.Triggers = append(window.Triggers, aor.TriggerType(trigger))
+	}
+
+	if startStr != "" {
+		start, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			return fmt.Errorf("invalid --start: %w", err)
+		}
+		window.StartsAt = start
+	}
+	switch {
+	case endStr != "":
+		end, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			return fmt.Errorf("invalid --end: %w", err)
+		}
+		window.EndsAt = end
+	case duration > 0:
+		window.EndsAt = window.StartsAt.Add(duration)
+	default:
+		return fmt.Errorf("either --end or --duration is required")
+	}
+
+	if err := window.Validate(); err != nil {
+		return err
+	}
+
+	// Mock create - in production would call aor.ControlPlane.CreateFreezeWindow
+	data, err := json.MarshalIndent(window, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format freeze window: %w", err)
+	}
+	fmt.Printf("Created freeze window:\n%s\n", data)
+	return nil
+}
+
+func runFreezeList(cmd *cobra.Command, args []string) error {
+	// Mock listing - in production would call aor.ControlPlane.ListFreezeWindows
+	now := time.Now()
+	windows := []aor.FreezeWindow{
+		{Name: "deploy-freeze", StartsAt: now.Add(-time.Hour), EndsAt: now.Add(3 * time.Hour),
+			Mode: aor.FreezeModeQueue, Tags: map[string]string{"env": "prod"}, Reason: "Quarterly release"},
+		{Name: "db-maintenance", StartsAt: now.Add(48 * time.Hour), EndsAt: now.Add(50 * time.Hour),
+			Mode: aor.FreezeModeReject, Triggers: []aor.TriggerType{aor.TriggerCron}},
+	}
+
+	fmt.Printf("%-16s %-8s %-18s %-18s %-16s %s\n", "NAME", "MODE", "STARTS", "ENDS", "TRIGGERS", "SCOPE")
+	fmt.Println("--------------------------------------------------------------------------------------------")
+	for _, w := range windows {
+		triggers := "cron,webhook"
+		if len(w.Triggers) > 0 {
+			names := make([]string, 0, len(w.Triggers))
+			for _, t := range w.Triggers {
+				names = append(names, string(t))
+			}
+			triggers = strings.Join(names, ",")
+		}
+		scope := "all runs"
+		if len(w.Workflows) > 0 || len(w.Tags) > 0 {
+			scope = strings.TrimPrefix(strings.Join(w.Workflows, ",")+" "+formatTags(w.Tags), " ")
+		}
+		fmt.Printf("%-16s %-8s %-18s %-18s %-16s %s\n", w.Name, w.Mode,
+			w.StartsAt.Format("2006-01-02 15:04"), w.EndsAt.Format("2006-01-02 15:04"), triggers, scope)
+	}
+	return nil
+}
+
+func runFreezeDelete(cmd *cobra.Command, args []string) error {
+	// Mock delete - in production would call aor.ControlPlane.DeleteFreezeWindow
+	fmt.Printf("Deleted freeze window: %s\n", args[0])
+	return nil
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import [definition-file]",
+	Short: "Convert a LangGraph or CrewAI definition into a workflow spec",
+	Long: `Convert an agent framework definition into an AgentFlow workflow spec, e.g.
+  agentctl import --format langgraph graph.json -o workflow.yaml
+  agentctl import --format crewai crew.yaml --name research-crew --prompts-dir prompts
+
+LangGraph input is the JSON from graph.get_graph().to_json(). CrewAI input is a
+YAML or JSON document with agents and tasks. Constructs without an AgentFlow
+equivalent are reported with a suggested fix.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+func init() {
+	importCmd.Flags().String("format", "", "Source format (langgraph, crewai; default: detect)")
+	importCmd.Flags().String("name", "", "Workflow name (default: file name)")
+	importCmd.Flags().StringP("output", "o", "", "Write the spec to this file instead of stdout (.json for JSON)")
+	importCmd.Flags().String("prompts-dir", "", "Write generated prompt templates to this directory")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	source := args[0]
+	format, _ := cmd.Flags().GetString("format")
+	name, _ := cmd.Flags().GetString("name")
+	output, _ := cmd.Flags().GetString("output")
+	promptsDir, _ := cmd.Flags().GetString("prompts-dir")
+
+	if err := validateFilePath(source); err != nil {
+		return fmt.Errorf("invalid file path: %w", err)
+	}
+	data, err := os.ReadFile(source) // #nosec G304 - path validated above
+	if err != nil {
+		return fmt.Errorf("failed to read definition: %w", err)
+	}
+
+	importFormat := aor.ImportFormat(strings.ToLower(format))
+	if importFormat == "" {
+		if importFormat, err = aor.DetectImportFormat(data); err != nil {
+			return err
+		}
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	}
+
+	result, err := aor.ImportWorkflowSpec(data, importFormat, name)
+	if err != nil {
+		return err
+	}
+
+	specFormat := "yaml"
+	if strings.EqualFold(filepath.Ext(output), ".json") {
+		specFormat = "json"
+	}
+	spec, err := aor.MarshalWorkflowSpec(result.Spec, specFormat)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		fmt.Print(string(spec))
+	} else {
+		if err := validateFilePath(output); err != nil {
+			return fmt.Errorf("invalid output path: %w", err)
+		}
+		if err := os.WriteFile(output, spec, 0600); err != nil {
+			return fmt.Errorf("failed to write spec: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s spec with %d steps to %s\n", importFormat, len(result.Spec.DAG.Steps), output)
+	}
+
+	if promptsDir != "" && len(result.Prompts) > 0 {
+		if err := writeImportedPrompts(promptsDir, result.Prompts); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d prompt templates to %s\n", len(result.Prompts), promptsDir)
+	}
+
+	printImportIssues(result)
+	return nil
+}
+
+func writeImportedPrompts(dir string, prompts []aor.ImportedPrompt) error {
+	if err := validateFilePath(dir); err != nil {
+		return fmt.Errorf("invalid prompts directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("failed to create prompts directory: %w", err)
+	}
+	for _, prompt := range prompts {
+		path := filepath.Join(dir, prompt.Name+".tmpl")
+		if err := os.WriteFile(path, []byte(prompt.Template), 0600); err != nil {
+			return fmt.Errorf("failed to write prompt %s: %w", prompt.Name, err)
+		}
+	}
+	return nil
+}
+
+// printImportIssues reports unconverted constructs on stderr so stdout stays a valid spec
+func printImportIssues(result *aor.ImportResult) {
+	if len(result.Issues) == 0 {
+		fmt.Fprintln(os.Stderr, "Imported without issues")
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "\n%-8s %-22s %-20s %s\n", "SEVERITY", "CONSTRUCT", "NODE", "MESSAGE")
+	fmt.Fprintln(os.Stderr, strings.Repeat("-", 100))
+	blocking := 0
+	for _, issue := range result.Issues {
+		node := issue.Node
+		if node == "" {
+			node = "-"
+		}
+		if issue.Severity == aor.LintSeverityError {
+			blocking++
+		}
+		fmt.Fprintf(os.Stderr, "%-8s %-22s %-20s %s\n", issue.Severity, issue.Construct, node, issue.Message)
+		fmt.Fprintf(os.Stderr, "%-8s %-22s %-20s fix: %s\n", "", "", "", issue.Suggestion)
+	}
+	if blocking > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d issues must be fixed before the workflow can run\n", blocking)
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// addListFlags registers the paging, filtering and field selection flags
+// every list command shares
+func addListFlags(cmd *cobra.Command, defaultLimit int, filterExample string) {
+	cmd.Flags().String("cursor", "", "Continue from a previous page's cursor")
+	cmd.Flags().StringP("filter", "f", "", "Filter expression, e.g. "+filterExample)
+	cmd.Flags().StringSlice("fields", nil, "Fields to include in JSON output, e.g. id,name")
+	cmd.Flags().IntP("limit", "l", defaultLimit, "Number of results to return")
+}
+
+func listOptionsFromFlags(cmd *cobra.Command) db.ListOptions {
+	var opts db.ListOptions
+	opts.Cursor, _ = cmd.Flags().GetString("cursor")
+	opts.Filter, _ = cmd.Flags().GetString("filter")
+	opts.Fields, _ = cmd.Flags().GetStringSlice("fields")
+	opts.Limit, _ = cmd.Flags().GetInt("limit")
+	return opts
+}
+
+// printListJSON prints a list response envelope with each item reduced to
+// the selected fields
+func printListJSON(key string, items interface{}, meta db.PageMeta, fields []string) error {
+	selected, err := db.SelectFields(items, fields)
+	if err != nil {
+		return err
+	}
+	envelope := map[string]interface{}{
+		key:              selected,
+		"total_estimate": meta.TotalEstimate,
+	}
+	if meta.NextCursor != "" {
+		envelope["next_cursor"] = meta.NextCursor
+	}
+
+	data, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format %s: %w", key, err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// printPageFooter tells the user how much of the listing they have seen and
+// how to fetch the next page
+func printPageFooter(shown int, meta db.PageMeta) {
+	fmt.Printf("\nShowing %d of about %d\n", shown, meta.TotalEstimate)
+	if meta.NextCursor != "" {
+		fmt.Printf("More results: --cursor %s\n", meta.NextCursor)
+	}
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aos"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var loadtestCmd = &cobra.Command{
+	Use:   "loadtest",
+	Short: "Generate synthetic workflow runs against a deployment and report capacity",
+	Long: `Submit runs at a target rate, answered by the mock provider, and measure
+scheduling latency, step throughput and trace ingestion lag, e.g.
+  agentctl loadtest --org <org-id> --rate 20 --duration 5m --steps 4
+
+Runs where the server's config.yaml or environment is available, like doctor --server.
+Without --workflow, a synthetic workflow of LLM steps is applied to the org first.`,
+	RunE: runLoadtest,
+}
+
+func init() {
+	loadtestCmd.Flags().Float64P("rate", "r", 5, "Runs submitted per second")
+	loadtestCmd.Flags().DurationP("duration", "d", time.Minute, "How long to submit runs for")
+	loadtestCmd.Flags().Int("steps", 3, "LLM steps per synthetic run")
+	loadtestCmd.Flags().StringP("workflow", "w", "", "Submit this workflow instead of the synthetic one")
+	loadtestCmd.Flags().String("mock-provider", "mock://?latency=200ms&seed=loadtest", "Mock provider answering the synthetic workflow's LLM calls")
+	loadtestCmd.Flags().Duration("drain-timeout", 5*time.Minute, "How long to wait for runs and traces after submitting")
+	loadtestCmd.Flags().Duration("poll-interval", time.Second, "How often runs are checked; bounds timing accuracy")
+	loadtestCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+}
+
+func runLoadtest(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	orgID, err := uuid.Parse(viper.GetString("org"))
+	if err != nil {
+		return fmt.Errorf("--org must be the ID of the org to load: %w", err)
+	}
+
+	ltCfg := aor.LoadTestConfig{OrgID: orgID}
+	ltCfg.Rate, _ = cmd.Flags().GetFloat64("rate")
+	ltCfg.Duration, _ = cmd.Flags().GetDuration("duration")
+	ltCfg.Steps, _ = cmd.Flags().GetInt("steps")
+	ltCfg.Workflow, _ = cmd.Flags().GetString("workflow")
+	ltCfg.MockProvider, _ = cmd.Flags().GetString("mock-provider")
+	ltCfg.DrainTimeout, _ = cmd.Flags().GetDuration("drain-timeout")
+	ltCfg.PollInterval, _ = cmd.Flags().GetDuration("poll-interval")
+	if err := ltCfg.Validate(); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load server config: %w", err)
+	}
+	cp, err := aor.NewControlPlane(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to deployment: %w", err)
+	}
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	defer func() { _ = cp.Shutdown(ctx) }()
+
+	var traces *aos.Service
+	postgres, pgErr := db.NewPostgresDB(&cfg.Database)
+	clickhouse, chErr := db.NewClickHouseDB(&cfg.ClickHouse)
+	if pgErr != nil || chErr != nil {
+		fmt.Println("Trace store unavailable; trace ingestion lag will not be measured")
+	} else {
+		defer func() { _ = postgres.Close() }()
+		defer func() { _ = clickhouse.Close() }()
+		traces = aos.NewService(cfg, clickhouse, postgres)
+	}
+
+	if ltCfg.Workflow == "" {
+		spec, err := aor.MarshalWorkflowSpec(aor.SyntheticLoadTestSpec(ltCfg), "json")
+		if err != nil {
+			return err
+		}
+		bundle := &aor.ApplyBundle{APIVersion: aor.ApplyAPIVersion, Resources: []aor.Resource{
+			{Kind: aor.ResourceKindWorkflow, Name: aor.LoadTestWorkflow, Spec: spec},
+		}}
+		if _, err := cp.Apply(ctx, &aor.ApplyRequest{OrgID: orgID, Bundle: bundle}); err != nil {
+			return fmt.Errorf("failed to apply synthetic workflow: %w", err)
+		}
+	}
+
+	fmt.Printf("Submitting %.1f runs/s for %s...\n", ltCfg.Rate, ltCfg.Duration)
+	report, err := aor.RunLoadTest(ctx, aor.NewLoadTestTarget(cp, traces), ltCfg)
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format load test report: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("\nLoad test of %s, %s\n", workflowLabel(ltCfg.Workflow), report.FinishedAt.Sub(report.StartedAt).Round(time.Second))
+	fmt.Printf("  Submitted:       %d (%.2f runs/s, %d errors)\n", report.Submitted, report.AchievedRate, report.SubmitErrors)
+	fmt.Printf("  Finished:        %d completed, %d failed, %d unfinished\n", report.Completed, report.Failed, report.Unfinished)
+	fmt.Printf("  Step throughput: %.2f steps/s (%d steps)\n", report.StepThroughput, report.StepsCompleted)
+	if traces != nil {
+		fmt.Printf("  Traces missing:  %d\n", report.TracesMissing)
+	}
+	fmt.Printf("\n%-20s %8s %10s %10s %10s %10s\n", "LATENCY", "COUNT", "P50", "P95", "P99", "MAX")
+	for _, row := range []struct {
+		name string
+		p    cas.LatencyPercentiles
+	}{
+		{"submit", report.SubmitLatency},
+		{"scheduling", report.SchedulingLatency},
+		{"run duration", report.RunDuration},
+		{"trace lag", report.TraceLag},
+	} {
+		fmt.Printf("%-20s %8d %10s %10s %10s %10s\n", row.name, row.p.Count, row.p.P50.Round(time.Millisecond),
+			row.p.P95.Round(time.Millisecond), row.p.P99.Round(time.Millisecond), row.p.Max.Round(time.Millisecond))
+	}
+	for _, msg := range report.Errors {
+		fmt.Printf("\nSubmit error: %s", msg)
+	}
+	if len(report.Errors) > 0 {
+		fmt.Println()
+	}
+	return nil
+}
+
+func workflowLabel(workflow string) string {
+	if workflow == "" {
+		return aor.LoadTestWorkflow + " (synthetic)"
+	}
+	return workflow
+}
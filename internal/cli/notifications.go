@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/spf13/cobra"
+)
+
+var notificationsCmd = &cobra.Command{
+	Use:   "notifications",
+	Short: "Manage how users are notified of run failures, budget alerts and canary promotions",
+	Long: `Each user chooses per category whether to be notified immediately, in an
+hourly digest, or not at all. Notifications go to the configured webhook and
+Slack backends, and by email to users who give an address. Users without
+preferences are not notified.`,
+}
+
+var notificationsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the notification preferences of the org's users",
+	RunE:  runNotificationsList,
+}
+
+var notificationsGetCmd = &cobra.Command{
+	Use:   "get [user-id]",
+	Short: "Show a user's notification preferences",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runNotificationsGet,
+}
+
+var notificationsSetCmd = &cobra.Command{
+	Use:   "set [user-id]",
+	Short: "Set a user's notification preferences",
+	Long: `Set how a user receives each category: immediate, hourly or mute.
+Categories not given are sent immediately.
+
+Examples:
+  agentctl notifications set alice --email alice@example.com --run-failure hourly --canary-promotion mute`,
+	Args: cobra.ExactArgs(1),
+	RunE: runNotificationsSet,
+}
+
+var notificationsRemoveCmd = &cobra.Command{
+	Use:   "remove [user-id]",
+	Short: "Stop notifying a user and drop their pending digest",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runNotificationsRemove,
+}
+
+// notificationFlags maps each category to its set flag
+var notificationFlags = map[aor.NotificationCategory]string{
+	aor.NotifyRunFailure:      "run-failure",
+	aor.NotifyBudgetAlert:     "budget-alert",
+	aor.NotifyCanaryPromotion: "canary-promotion",
+}
+
+func init() {
+	notificationsListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	notificationsGetCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	notificationsSetCmd.Flags().String("email", "", "Address for email notifications")
+	for _, category := range aor.NotificationCategories {
+		notificationsSetCmd.Flags().String(notificationFlags[category], "", fmt.Sprintf("How %s notifications are sent (immediate, hourly, mute)", category))
+	}
+
+	notificationsCmd.AddCommand(notificationsListCmd)
+	notificationsCmd.AddCommand(notificationsGetCmd)
+	notificationsCmd.AddCommand(notificationsSetCmd)
+	notificationsCmd.AddCommand(notificationsRemoveCmd)
+}
+
+func runNotificationsList(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock preferences - in production would call aor.ControlPlane.ListNotificationPreferences
+	prefs := []*aor.NotificationPreferences{
+		mockNotificationPreferences("alice"),
+		{UserID: "bob", Modes: map[aor.NotificationCategory]aor.NotificationMode{aor.NotifyBudgetAlert: aor.NotifyMute},
+			UpdatedAt: time.Now().Add(-48 * time.Hour)},
+	}
+	return printNotificationPreferences(prefs, output)
+}
+
+func runNotificationsGet(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock preferences - in production would call aor.ControlPlane.GetNotificationPreferences
+	return printNotificationPreferences([]*aor.NotificationPreferences{mockNotificationPreferences(args[0])}, output)
+}
+
+func runNotificationsSet(cmd *cobra.Command, args []string) error {
+	email, _ := cmd.Flags().GetString("email")
+	prefs := &aor.NotificationPreferences{UserID: args[0], Email: email, Modes: make(map[aor.NotificationCategory]aor.NotificationMode)}
+	for _, category := range aor.NotificationCategories {
+		if mode, _ := cmd.Flags().GetString(notificationFlags[category]); mode != "" {
+			prefs.Modes[category] = aor.NotificationMode(mode)
+		}
+	}
+	if err := prefs.Validate(); err != nil {
+		return err
+	}
+
+	// Mock save - in production would call aor.ControlPlane.SetNotificationPreferences
+	fmt.Printf("Notification preferences for %s saved\n", prefs.UserID)
+	for _, category := range aor.NotificationCategories {
+		fmt.Printf("  %-18s %s\n", category, prefs.Mode(category))
+	}
+	return nil
+}
+
+func runNotificationsRemove(cmd *cobra.Command, args []string) error {
+	// Mock delete - in production would call aor.ControlPlane.DeleteNotificationPreferences
+	fmt.Printf("User %s will no longer be notified\n", args[0])
+	return nil
+}
+
+func mockNotificationPreferences(userID string) *aor.NotificationPreferences {
+	return &aor.NotificationPreferences{
+		UserID: userID,
+		Email:  userID + "@example.com",
+		Modes: map[aor.NotificationCategory]aor.NotificationMode{
+			aor.NotifyRunFailure:      aor.NotifyHourly,
+			aor.NotifyCanaryPromotion: aor.NotifyMute,
+		},
+		UpdatedAt: time.Now().Add(-3 * time.Hour),
+	}
+}
+
+func printNotificationPreferences(prefs []*aor.NotificationPreferences, output string) error {
+	if output == "json" {
+		data, err := json.MarshalIndent(prefs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal notification preferences: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-12s %-24s %-12s %-12s %-17s %s\n", "USER", "EMAIL", "RUN_FAILURE", "BUDGET_ALERT", "CANARY_PROMOTION", "UPDATED")
+	for _, p := range prefs {
+		email := p.Email
+		if email == "" {
+			email = "-"
+		}
+		fmt.Printf("%-12s %-24s %-12s %-12s %-17s %s\n", p.UserID, email,
+			p.Mode(aor.NotifyRunFailure), p.Mode(aor.NotifyBudgetAlert), p.Mode(aor.NotifyCanaryPromotion),
+			p.UpdatedAt.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var workflowPrewarmCmd = &cobra.Command{
+	Use:   "prewarm [workflow-name]",
+	Short: "Warm workers ahead of a scheduled run",
+	Long: `Start the workflow's executor plugins and open its provider connections on every
+worker up to an hour before a scheduled run, e.g.
+  agentctl workflow prewarm nightly_report --cron "0 2 * * *"
+The warm-up cost is charged to the run that claims it, or with --attribution
+maintenance to the given maintenance budget. Unclaimed warm-ups expire 15m after
+their scheduled time.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWorkflowPrewarm,
+}
+
+var workflowPrewarmListCmd = &cobra.Command{
+	Use:   "list [workflow-name]",
+	Short: "List warm-ups and who paid for them",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runWorkflowPrewarmList,
+}
+
+func init() {
+	workflowPrewarmCmd.Flags().String("at", "", "Scheduled run time (RFC3339)")
+	workflowPrewarmCmd.Flags().String("cron", "", "Warm the next run of this cron schedule instead of --at")
+	workflowPrewarmCmd.Flags().String("attribution", "run", "Who pays for the warm-up (run, maintenance)")
+	workflowPrewarmCmd.Flags().String("maintenance-budget", "", "Budget ID charged for maintenance warm-ups and unclaimed ones")
+	workflowPrewarmListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	workflowPrewarmCmd.AddCommand(workflowPrewarmListCmd)
+	workflowCmd.AddCommand(workflowPrewarmCmd)
+}
+
+func runWorkflowPrewarm(cmd *cobra.Command, args []string) error {
+	at, _ := cmd.Flags().GetString("at")
+	cron, _ := cmd.Flags().GetString("cron")
+	attribution, _ := cmd.Flags().GetString("attribution")
+	budget, _ := cmd.Flags().GetString("maintenance-budget")
+
+	req := &aor.PrewarmRequest{WorkflowName: args[0], Cron: cron, Attribution: aor.PrewarmAttribution(attribution)}
+	if at != "" {
+		scheduled, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return fmt.Errorf("invalid --at time: %w", err)
+		}
+		req.ScheduledFor = scheduled
+	}
+	if budget != "" {
+		budgetID, err := uuid.Parse(budget)
+		if err != nil {
+			return fmt.Errorf("invalid maintenance budget ID: %w", err)
+		}
+		req.MaintenanceBudgetID = &budgetID
+	}
+	if err := req.Validate(time.Now()); err != nil {
+		return err
+	}
+
+	// Mock warm-up - in production would call aor.ControlPlane.PrewarmWorkflow
+	fmt.Printf("Pre-warming %s for its run at %s\n", req.WorkflowName, req.ScheduledFor.Format(time.RFC3339))
+	fmt.Println("Workers: starting 1 executor plugin, opening connections to 2 provider models")
+	if req.Attribution == aor.PrewarmAttributeMaintenance {
+		fmt.Printf("Cost is charged to maintenance budget %s\n", req.MaintenanceBudgetID)
+	} else {
+		fmt.Println("Cost is charged to the run that claims the warm-up")
+	}
+	return nil
+}
+
+func runWorkflowPrewarmList(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock warm-ups - in production would call aor.ControlPlane.ListPrewarms
+	now := time.Now()
+	runID := uuid.New()
+	warmedAt := now.Add(-62 * time.Minute)
+	prewarms := []aor.Prewarm{
+		{ID: uuid.New(), WorkflowName: "nightly_report", WorkflowVersion: 4, ScheduledFor: now.Add(25 * time.Minute),
+			Status: aor.PrewarmWarm, Attribution: aor.PrewarmAttributeRun, CostCents: 6, WarmedAt: &warmedAt,
+			Targets: aor.PrewarmTargets{Models: []aor.PrewarmModel{{Provider: "openai", Model: "gpt-4"}}, StepTypes: []string{"llm"}}},
+		{ID: uuid.New(), WorkflowName: "nightly_report", WorkflowVersion: 4, ScheduledFor: now.Add(-time.Hour),
+			Status: aor.PrewarmClaimed, Attribution: aor.PrewarmAttributeRun, CostCents: 6, RunID: &runID, WarmedAt: &warmedAt,
+			Targets: aor.PrewarmTargets{Models: []aor.PrewarmModel{{Provider: "openai", Model: "gpt-4"}}, StepTypes: []string{"llm"}}},
+	}
+	if len(args) == 1 {
+		filtered := prewarms[:0]
+		for _, prewarm := range prewarms {
+			if prewarm.WorkflowName == args[0] {
+				filtered = append(filtered, prewarm)
+			}
+		}
+		prewarms = filtered
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(prewarms, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal warm-ups: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-16s %-20s %-8s %-12s %6s %s\n", "WORKFLOW", "SCHEDULED", "STATUS", "CHARGED TO", "COST", "RUN")
+	for _, prewarm := range prewarms {
+		run := "-"
+		if prewarm.RunID != nil {
+			run = prewarm.RunID.String()
+		}
+		fmt.Printf("%-16s %-20s %-8s %-12s %5d¢ %s\n", prewarm.WorkflowName, prewarm.ScheduledFor.Format("2006-01-02 15:04"),
+			prewarm.Status, prewarm.Attribution, prewarm.CostCents, run)
+	}
+	return nil
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
 	"github.com/spf13/cobra"
 )
 
@@ -56,6 +58,13 @@ var promptTestCmd = &cobra.Command{
 	RunE:  runPromptTest,
 }
 
+var promptHistoryCmd = &cobra.Command{
+	Use:   "history [name]",
+	Short: "Show evaluation score trends across versions",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPromptHistory,
+}
+
 func init() {
 	// Create command flags
 	promptCreateCmd.Flags().StringP("template", "t", "", "Prompt template content")
@@ -65,8 +74,8 @@ func init() {
 	promptCreateCmd.Flags().StringToStringP("metadata", "m", nil, "Metadata as key=value pairs")
 
 	// List command flags
-	promptListCmd.Flags().IntP("limit", "l", 20, "Number of results to return")
-	promptListCmd.Flags().StringP("filter", "f", "", "Filter by name pattern")
+	addListFlags(promptListCmd, 20, "name~analyzer,version>=2")
+	promptListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
 
 	// Get command flags
 	promptGetCmd.Flags().StringP("output", "o", "yaml", "Output format (yaml, json)")
@@ -76,6 +85,12 @@ func init() {
 	promptEvalCmd.Flags().IntP("parallel", "p", 1, "Parallel evaluation workers")
 	promptEvalCmd.Flags().BoolP("wait", "w", false, "Wait for evaluation completion")
 
+	// History command flags
+	promptHistoryCmd.Flags().StringP("suite", "s", "", "Filter by evaluation suite")
+	promptHistoryCmd.Flags().StringP("since", "", "30d", "Lookback window")
+	promptHistoryCmd.Flags().IntP("limit", "l", 50, "Maximum runs to show")
+	promptHistoryCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
 	// Deploy command flags
 	promptDeployCmd.Flags().IntP("stable", "", 0, "Stable version to deploy")
 	promptDeployCmd.Flags().IntP("canary", "", 0, "Canary version to deploy")
@@ -91,6 +106,7 @@ func init() {
 	promptCmd.AddCommand(promptListCmd)
 	promptCmd.AddCommand(promptGetCmd)
 	promptCmd.AddCommand(promptEvalCmd)
+	promptCmd.AddCommand(promptHistoryCmd)
 	promptCmd.AddCommand(promptDeployCmd)
 	promptCmd.AddCommand(promptTestCmd)
 }
@@ -164,10 +180,12 @@ func runPromptCreate(cmd *cobra.Command, args []string) error {
 }
 
 func runPromptList(cmd *cobra.Command, args []string) error {
-	limit, _ := cmd.Flags().GetInt("limit")
-	filter, _ := cmd.Flags().GetString("filter")
+	opts := listOptionsFromFlags(cmd)
+	output, _ := cmd.Flags().GetString("output")
 
-	fmt.Printf("Listing prompts (limit: %d, filter: %s)\n", limit, filter)
+	if output != "json" {
+		fmt.Printf("Listing prompts (limit: %d, filter: %s)\n", opts.Limit, opts.Filter)
+	}
 
 	// Mock prompt list
 	prompts := []map[string]interface{}{
@@ -194,15 +212,26 @@ func runPromptList(cmd *cobra.Command, args []string) error {
 		},
 	}
 
+	// Mock filtering handles name~pattern; the service accepts the full expression syntax
+	pattern, _ := strings.CutPrefix(opts.Filter, "name~")
+	matched := make([]map[string]interface{}, 0, len(prompts))
+	for _, prompt := range prompts {
+		if pattern == "" || contains(prompt["name"].(string), pattern) {
+			matched = append(matched, prompt)
+		}
+	}
+	meta := db.PageMeta{TotalEstimate: int64(len(matched))}
+
+	if output == "json" {
+		return printListJSON("prompts", matched, meta, opts.Fields)
+	}
+
 	// Print table header
 	fmt.Printf("%-20s %-8s %-12s %-20s %-10s\n", "NAME", "VERSION", "DEPLOYED", "UPDATED", "STATUS")
 	fmt.Println("------------------------------------------------------------------------")
 
 	// Print prompts
-	for _, prompt := range prompts {
-		if filter != "" && !contains(prompt["name"].(string), filter) {
-			continue
-		}
+	for _, prompt := range matched {
 
 		updatedAt, _ := time.Parse(time.RFC3339, prompt["updated_at"].(string))
 		deployed := "No"
@@ -218,6 +247,7 @@ func runPromptList(cmd *cobra.Command, args []string) error {
 			"Active",
 		)
 	}
+	printPageFooter(len(matched), meta)
 
 	return nil
 }
@@ -339,6 +369,44 @@ func runPromptEval(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runPromptHistory(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	suite, _ := cmd.Flags().GetString("suite")
+	since, _ := cmd.Flags().GetString("since")
+	limit, _ := cmd.Flags().GetInt("limit")
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock history - in production would call pop.Service.GetEvalHistory
+	versions := []map[string]interface{}{
+		{"prompt_version": 3, "runs": 4, "average_score": 0.82, "pass_rate": 0.80, "score_delta": 0.0},
+		{"prompt_version": 4, "runs": 6, "average_score": 0.88, "pass_rate": 0.87, "score_delta": 0.06},
+		{"prompt_version": 5, "runs": 2, "average_score": 0.79, "pass_rate": 0.75, "score_delta": -0.09},
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(map[string]interface{}{"prompt_name": name, "versions": versions}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal history: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Evaluation history for %s (since %s, up to %d runs)\n", name, since, limit)
+	if suite != "" {
+		fmt.Printf("Suite: %s\n", suite)
+	}
+
+	fmt.Printf("\n%-8s %-6s %-10s %-10s %-8s\n", "VERSION", "RUNS", "AVG SCORE", "PASS RATE", "DELTA")
+	fmt.Printf("%-8s %-6s %-10s %-10s %-8s\n", "-------", "----", "---------", "---------", "-----")
+	for _, v := range versions {
+		fmt.Printf("v%-7d %-6d %-10.2f %-10.1f %+.2f\n",
+			v["prompt_version"], v["runs"], v["average_score"], v["pass_rate"].(float64)*100, v["score_delta"])
+	}
+
+	return nil
+}
+
 func runPromptDeploy(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/pop"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var promptExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export prompts with all versions, suites and deployments",
+	Long:  `Write a bundle that prompt import can load into another org or environment, e.g. agentctl prompt export --name summarizer --suite summaries -f prompts.json`,
+	RunE:  runPromptExport,
+}
+
+var promptImportCmd = &cobra.Command{
+	Use:   "import [bundle-file]",
+	Short: "Import a prompt bundle, resolving conflicts by strategy",
+	Long: `Load a bundle written by prompt export. Existing prompt versions, suites and deployments are resolved by --strategy:
+  skip         keep what the target already has (default)
+  overwrite    replace the target's versions, suites and deployments in place
+  new-version  append imported versions after the target's latest and merge suite cases`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPromptImport,
+}
+
+func init() {
+	promptExportCmd.Flags().StringSliceP("name", "n", nil, "Prompt to export (repeatable, default all)")
+	promptExportCmd.Flags().StringSliceP("suite", "s", nil, "Evaluation suite to export (repeatable, default all)")
+	promptExportCmd.Flags().StringP("file", "f", "", "Write the bundle to a file instead of stdout")
+
+	promptImportCmd.Flags().String("strategy", string(pop.ConflictSkip), "Conflict strategy (skip, overwrite, new-version)")
+	promptImportCmd.Flags().Bool("dry-run", false, "Show what would be imported without writing")
+	promptImportCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	promptCmd.AddCommand(promptExportCmd)
+	promptCmd.AddCommand(promptImportCmd)
+}
+
+func runPromptExport(cmd *cobra.Command, args []string) error {
+	names, _ := cmd.Flags().GetStringSlice("name")
+	suites, _ := cmd.Flags().GetStringSlice("suite")
+	file, _ := cmd.Flags().GetString("file")
+
+	// Mock export - in production would call pop.Service.ExportPrompts
+	if len(names) == 0 {
+		names = []string{"summarizer"}
+	}
+	if len(suites) == 0 {
+		suites = []string{"summaries"}
+	}
+	bundle := pop.PromptBundle{Format: 1, SourceOrgID: uuid.New(), ExportedAt: time.Now()}
+	for _, name := range names {
+		for version := 1; version <= 2; version++ {
+			bundle.Prompts = append(bundle.Prompts, pop.PromptTemplate{
+				ID: uuid.New(), Name: name, Version: version,
+				Template:  fmt.Sprintf("Summarize in %d sentences: {{.text}}", 4-version),
+				Schema:    pop.Schema{Type: "object", Properties: map[string]pop.Property{"text": {Type: "string"}}, Required: []string{"text"}},
+				CreatedAt: time.Now().Add(-time.Duration(3-version) * 24 * time.Hour),
+			})
+		}
+		bundle.Deployments = append(bundle.Deployments, pop.PromptDeployment{ID: uuid.New(), PromptName: name, StableVersion: 2})
+	}
+	for _, name := range suites {
+		bundle.Suites = append(bundle.Suites, pop.PromptSuite{ID: uuid.New(), Name: name, Cases: []pop.TestCase{
+			{ID: "short-article", Input: map[string]interface{}{"text": "..."}, Scoring: pop.ScoringConfig{Type: pop.ScoringContains}},
+		}})
+	}
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+	if file == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	if err := validateFilePath(file); err != nil {
+		return fmt.Errorf("invalid bundle path: %w", err)
+	}
+	if err := os.WriteFile(file, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d prompt versions, %d suites and %d deployments to %s\n",
+		len(bundle.Prompts), len(bundle.Suites), len(bundle.Deployments), file)
+	return nil
+}
+
+func runPromptImport(cmd *cobra.Command, args []string) error {
+	strategyFlag, _ := cmd.Flags().GetString("strategy")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	output, _ := cmd.Flags().GetString("output")
+
+	strategy, err := pop.ParseConflictStrategy(strategyFlag)
+	if err != nil {
+		return err
+	}
+	if err := validateFilePath(args[0]); err != nil {
+		return fmt.Errorf("invalid bundle path: %w", err)
+	}
+	data, err := os.ReadFile(args[0]) // #nosec G304 - path validated above
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+	var bundle pop.PromptBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return fmt.Errorf("failed to parse bundle: %w", err)
+	}
+
+	// Mock import into an empty org - in production would call pop.Service.ImportPrompts
+	result := pop.PromptImportResult{Strategy: strategy, DryRun: dryRun, Summary: make(map[pop.ImportAction]int)}
+	for _, prompt := range bundle.Prompts {
+		result.Items = append(result.Items, pop.PromptImportItem{Kind: "prompt", Name: prompt.Name,
+			SourceVersion: prompt.Version, Version: prompt.Version, Action: pop.ImportCreated})
+	}
+	for _, suite := range bundle.Suites {
+		result.Items = append(result.Items, pop.PromptImportItem{Kind: "suite", Name: suite.Name, Action: pop.ImportCreated})
+	}
+	for _, deployment := range bundle.Deployments {
+		result.Items = append(result.Items, pop.PromptImportItem{Kind: "deployment", Name: deployment.PromptName,
+			SourceVersion: deployment.StableVersion, Version: deployment.StableVersion, Action: pop.ImportCreated})
+	}
+	for _, item := range result.Items {
+		result.Summary[item.Action]++
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal import result: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if dryRun {
+		fmt.Printf("Dry run, nothing written (strategy %s)\n\n", strategy)
+	} else {
+		fmt.Printf("Imported bundle from org %s (strategy %s)\n\n", bundle.SourceOrgID, strategy)
+	}
+	fmt.Printf("%-11s %-24s %-8s %-8s %s\n", "KIND", "NAME", "SOURCE", "TARGET", "ACTION")
+	for _, item := range result.Items {
+		source, target := "-", "-"
+		if item.SourceVersion > 0 {
+			source, target = fmt.Sprintf("v%d", item.SourceVersion), fmt.Sprintf("v%d", item.Version)
+		}
+		fmt.Printf("%-11s %-24s %-8s %-8s %s\n", item.Kind, item.Name, source, target, item.Action)
+	}
+
+	actions := make([]string, 0, len(result.Summary))
+	for action := range result.Summary {
+		actions = append(actions, string(action))
+	}
+	sort.Strings(actions)
+	fmt.Println()
+	for _, action := range actions {
+		fmt.Printf("%s: %d\n", action, result.Summary[pop.ImportAction(action)])
+	}
+	return nil
+}
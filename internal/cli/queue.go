@@ -0,0 +1,253 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Inspect and tune the per-org task queues",
+	Long:  "Ready tasks are queued per lane and org and dispatched with weighted fair scheduling, so one org's fan-out cannot starve the others",
+}
+
+var queueLanesCmd = &cobra.Command{
+	Use:   "lanes",
+	Short: "Show reserved capacity, depth and wait SLO attainment per lane",
+	Long: `Lanes are dispatched in priority order and each holds back a share of
+in-flight capacity, so interactive runs are not stuck behind batch jobs.
+Attainment is the share of tasks dispatched within the lane's target wait
+over the last 24 hours.`,
+	RunE: runQueueLanes,
+}
+
+var queueStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show queue depth, oldest wait and weight per org",
+	RunE:  runQueueStatus,
+}
+
+var queueSetWeightCmd = &cobra.Command{
+	Use:   "set-weight [org-id] [weight]",
+	Short: "Set an org's share of dispatch capacity",
+	Long: `Set an org's dispatch weight. An org with weight 4 is dispatched four tasks
+for every one dispatched to a weight 1 org while both have work queued, e.g.
+  agentctl queue set-weight 7c0e1a52-... 4
+  agentctl queue set-weight 7c0e1a52-... --reset`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runQueueSetWeight,
+}
+
+var queuePauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Put a lane, an org or all queues into maintenance mode",
+	Long: `Stop dispatching tasks for a lane, an org or every queue. Runs are still
+accepted and their tasks queue until resumed, e.g.
+  agentctl queue pause --lane batch --reason "ClickHouse upgrade"
+  agentctl queue pause --all`,
+	RunE: runQueuePause,
+}
+
+var queueDrainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Pause dispatch and wait for in-flight tasks to finish",
+	Long: `Put the target into maintenance mode and wait until the tasks already
+dispatched for it have finished, so workers can be upgraded safely, e.g.
+  agentctl queue drain --all --timeout 10m`,
+	RunE: runQueueDrain,
+}
+
+var queueResumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume dispatch for a lane, an org or all queues",
+	RunE:  runQueueResume,
+}
+
+func init() {
+	queueSetWeightCmd.Flags().Bool("reset", false, "Return the org to the default weight")
+	for _, cmd := range []*cobra.Command{queuePauseCmd, queueDrainCmd, queueResumeCmd} {
+		cmd.Flags().String("lane", "", "Lane to hold")
+		cmd.Flags().String("org", "", "Org ID to hold")
+		cmd.Flags().Bool("all", false, "Hold every queue")
+	}
+	for _, cmd := range []*cobra.Command{queuePauseCmd, queueDrainCmd} {
+		cmd.Flags().String("reason", "", "Why dispatch is paused, shown in agentctl status")
+	}
+	queueDrainCmd.Flags().Duration("timeout", 10*time.Minute, "How long to wait for in-flight tasks")
+
+	queueCmd.AddCommand(queueStatusCmd)
+	queueCmd.AddCommand(queueLanesCmd)
+	queueCmd.AddCommand(queueSetWeightCmd)
+	queueCmd.AddCommand(queuePauseCmd)
+	queueCmd.AddCommand(queueDrainCmd)
+	queueCmd.AddCommand(queueResumeCmd)
+}
+
+// maintenanceTarget reads exactly one of --lane, --org and --all
+func maintenanceTarget(cmd *cobra.Command) (aor.MaintenanceTarget, error) {
+	lane, _ := cmd.Flags().GetString("lane")
+	org, _ := cmd.Flags().GetString("org")
+	all, _ := cmd.Flags().GetBool("all")
+
+	var targets []aor.MaintenanceTarget
+	if lane != "" {
+		targets = append(targets, aor.MaintenanceTarget{Scope: aor.MaintenanceScopeLane, Lane: lane})
+	}
+	if org != "" {
+		orgID, err := uuid.Parse(org)
+		if err != nil {
+			return aor.MaintenanceTarget{}, fmt.Errorf("invalid org ID: %w", err)
+		}
+		targets = append(targets, aor.MaintenanceTarget{Scope: aor.MaintenanceScopeOrg, OrgID: orgID})
+	}
+	if all {
+		targets = append(targets, aor.MaintenanceTarget{Scope: aor.MaintenanceScopeAll})
+	}
+	if len(targets) != 1 {
+		return aor.MaintenanceTarget{}, fmt.Errorf("exactly one of --lane, --org or --all is required")
+	}
+	return targets[0], nil
+}
+
+func runQueuePause(cmd *cobra.Command, args []string) error {
+	target, err := maintenanceTarget(cmd)
+	if err != nil {
+		return err
+	}
+	reason, _ := cmd.Flags().GetString("reason")
+
+	// Mock hold - in production would call aor.ControlPlane.EnterMaintenance
+	fmt.Printf("Dispatch for %s is paused; new tasks will queue until resumed\n", target)
+	if reason != "" {
+		fmt.Printf("Reason: %s\n", reason)
+	}
+	return nil
+}
+
+func runQueueDrain(cmd *cobra.Command, args []string) error {
+	target, err := maintenanceTarget(cmd)
+	if err != nil {
+		return err
+	}
+	reason, _ := cmd.Flags().GetString("reason")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	// Mock drain - in production would call aor.ControlPlane.DrainQueue
+	status := aor.MaintenanceStatus{
+		MaintenanceHold: aor.MaintenanceHold{MaintenanceTarget: target, Reason: reason, StartedAt: time.Now()},
+		Queued:          42,
+		InFlight:        0,
+		Drained:         true,
+	}
+	fmt.Printf("Dispatch for %s is paused, waiting up to %s for in-flight tasks\n", status.MaintenanceTarget, timeout)
+	if !status.Drained {
+		return fmt.Errorf("%d tasks still in flight after %s", status.InFlight, timeout)
+	}
+	fmt.Printf("Drained: no tasks in flight, %d queued until resume\n", status.Queued)
+	return nil
+}
+
+func runQueueResume(cmd *cobra.Command, args []string) error {
+	target, err := maintenanceTarget(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Mock resume - in production would call aor.ControlPlane.ExitMaintenance
+	fmt.Printf("Dispatch for %s resumed\n", target)
+	return nil
+}
+
+func runQueueStatus(cmd *cobra.Command, args []string) error {
+	// Mock snapshot - in production would call aor.ControlPlane.GetTenantQueues
+	snapshot := aor.TenantQueueSnapshot{
+		Tenants: []aor.TenantQueueStats{
+			{OrgID: uuid.MustParse("7c0e1a52-9d0b-4c7e-8f11-2b8a6f3d4e01"), Weight: 1, Depth: 12840, OldestWait: 94 * time.Second},
+			{OrgID: uuid.MustParse("1f4d2b63-5a8e-4f90-b7c2-9e0d1a2b3c04"), Weight: 4, Depth: 35, OldestWait: 2 * time.Second, CustomWeighted: true},
+			{OrgID: uuid.MustParse("b3a9c8d7-2e1f-4a6b-9c0d-5e4f3a2b1c07"), Weight: 1, Depth: 0, CustomWeighted: false},
+		},
+		InFlight:    500,
+		MaxInFlight: 500,
+	}
+
+	fmt.Printf("In flight: %d/%d\n\n", snapshot.InFlight, snapshot.MaxInFlight)
+	fmt.Printf("%-38s %-8s %-10s %s\n", "ORG", "WEIGHT", "DEPTH", "OLDEST WAIT")
+	fmt.Println("------------------------------------------------------------------------")
+	for _, tenant := range snapshot.Tenants {
+		weight := strconv.Itoa(tenant.Weight)
+		if tenant.CustomWeighted {
+			weight += "*"
+		}
+		wait := "-"
+		if tenant.Depth > 0 {
+			wait = tenant.OldestWait.Round(time.Second).String()
+		}
+		fmt.Printf("%-38s %-8s %-10d %s\n", tenant.OrgID, weight, tenant.Depth, wait)
+	}
+	fmt.Println("\n* weight set by an admin")
+	return nil
+}
+
+func runQueueLanes(cmd *cobra.Command, args []string) error {
+	// Mock stats - in production would call aor.ControlPlane.GetLaneStats
+	stats := []aor.LaneStats{
+		{Lane: aor.Lane{Name: aor.LaneInteractive, Priority: 0, TargetWait: 5 * time.Second, ReservedPercent: 40, ReservedSlots: 200},
+			Depth: 3, InFlight: 184, OldestWait: 800 * time.Millisecond, Dispatched: 48210, WithinTarget: 48102},
+		{Lane: aor.Lane{Name: aor.LaneBatch, Priority: 1, TargetWait: 10 * time.Minute, ReservedPercent: 20, ReservedSlots: 100},
+			Depth: 12840, InFlight: 291, OldestWait: 7 * time.Minute, Dispatched: 310442, WithinTarget: 296017},
+		{Lane: aor.Lane{Name: aor.LaneBackground, Priority: 2, TargetWait: time.Hour, ReservedPercent: 5, ReservedSlots: 25},
+			Depth: 420, InFlight: 25, OldestWait: 41 * time.Minute, Dispatched: 9120, WithinTarget: 9120},
+	}
+
+	fmt.Printf("%-12s %-10s %-12s %-10s %-10s %-12s %s\n", "LANE", "TARGET", "RESERVED", "IN FLIGHT", "DEPTH", "OLDEST WAIT", "ATTAINMENT")
+	fmt.Println("------------------------------------------------------------------------------------------")
+	for _, s := range stats {
+		if s.Dispatched > 0 {
+			s.Attainment = float64(s.WithinTarget) / float64(s.Dispatched)
+		} else {
+			s.Attainment = 1
+		}
+		wait := "-"
+		if s.Depth > 0 {
+			wait = s.OldestWait.Round(time.Second).String()
+		}
+		reserved := fmt.Sprintf("%d (%d%%)", s.ReservedSlots, s.ReservedPercent)
+		fmt.Printf("%-12s %-10s %-12s %-10d %-10d %-12s %.2f%%\n",
+			s.Name, s.TargetWait, reserved, s.InFlight, s.Depth, wait, s.Attainment*100)
+	}
+	return nil
+}
+
+func runQueueSetWeight(cmd *cobra.Command, args []string) error {
+	orgID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid org ID: %w", err)
+	}
+
+	reset, _ := cmd.Flags().GetBool("reset")
+	if reset {
+		// Mock reset - in production would call aor.ControlPlane.ResetTenantWeight
+		fmt.Printf("Reset dispatch weight for org %s to %d\n", orgID, aor.DefaultTenantWeight)
+		return nil
+	}
+
+	if len(args) != 2 {
+		return fmt.Errorf("weight is required unless --reset is set")
+	}
+	weight, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid weight: %w", err)
+	}
+	if weight < 1 || weight > aor.MaxTenantWeight {
+		return fmt.Errorf("weight must be between 1 and %d", aor.MaxTenantWeight)
+	}
+
+	// Mock update - in production would call aor.ControlPlane.SetTenantWeight
+	fmt.Printf("Set dispatch weight for org %s to %d\n", orgID, weight)
+	return nil
+}
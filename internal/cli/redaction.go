@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+	"github.com/spf13/cobra"
+)
+
+var dataPIICmd = &cobra.Command{
+	Use:   "pii",
+	Short: "Manage custom PII entity types redacted alongside the built-in ones",
+	Long: `Custom entity types such as employee IDs or contract numbers are detected before
+the built-in detectors at every scrub level, in traces, run callbacks and
+ingested context. Patterns are Go regular expressions; try one with
+'agentctl data pii test' before adding it.`,
+}
+
+var dataPIIListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List custom PII entity types",
+	RunE:  runDataPIIList,
+}
+
+var dataPIISetCmd = &cobra.Command{
+	Use:   "set [name] [pattern]",
+	Short: "Add or replace a custom PII entity type",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runDataPIISet,
+}
+
+var dataPIIRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a custom PII entity type",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDataPIIRemove,
+}
+
+var dataPIITestCmd = &cobra.Command{
+	Use:   "test [name] [pattern] [sample...]",
+	Short: "Show what a pattern redacts in sample text without saving it",
+	Args:  cobra.MinimumNArgs(3),
+	RunE:  runDataPIITest,
+}
+
+func init() {
+	dataPIIListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	dataPIISetCmd.Flags().String("replacement", "", "Placeholder for matches (default [REDACTED_<NAME>])")
+	dataPIITestCmd.Flags().String("replacement", "", "Placeholder for matches (default [REDACTED_<NAME>])")
+	dataPIITestCmd.Flags().String("level", "standard", "Scrub level to apply alongside the pattern (standard, strict)")
+	dataPIITestCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	dataPIICmd.AddCommand(dataPIIListCmd)
+	dataPIICmd.AddCommand(dataPIISetCmd)
+	dataPIICmd.AddCommand(dataPIIRemoveCmd)
+	dataPIICmd.AddCommand(dataPIITestCmd)
+	dataCmd.AddCommand(dataPIICmd)
+}
+
+func runDataPIIList(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock patterns - in production would call scl.Service.ListRedactionPatterns
+	patterns := []scl.RedactionPattern{
+		{Name: "contract_number", Pattern: `\bCN-\d{4}-\d{6}\b`, UpdatedAt: time.Now().Add(-72 * time.Hour)},
+		{Name: "employee_id", Pattern: `\bEMP\d{6}\b`, Replacement: "[EMPLOYEE]", UpdatedAt: time.Now().Add(-2 * time.Hour)},
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(patterns, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal redaction patterns: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-20s %-30s %-20s %s\n", "NAME", "PATTERN", "REPLACEMENT", "UPDATED")
+	for _, pattern := range patterns {
+		replacement := pattern.Replacement
+		if replacement == "" {
+			replacement = "[REDACTED_" + strings.ToUpper(pattern.Name) + "]"
+		}
+		fmt.Printf("%-20s %-30s %-20s %s\n", pattern.Name, pattern.Pattern, replacement, pattern.UpdatedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func runDataPIISet(cmd *cobra.Command, args []string) error {
+	replacement, _ := cmd.Flags().GetString("replacement")
+	pattern := scl.RedactionPattern{Name: args[0], Pattern: args[1], Replacement: replacement}
+	if err := scl.ValidateRedactionPattern(pattern); err != nil {
+		return err
+	}
+
+	// Mock save - in production would call scl.Service.SetRedactionPattern
+	fmt.Printf("PII entity type %s saved; it applies to new data within a minute\n", pattern.Name)
+	return nil
+}
+
+func runDataPIIRemove(cmd *cobra.Command, args []string) error {
+	// Mock delete - in production would call scl.Service.DeleteRedactionPattern
+	fmt.Printf("PII entity type %s removed\n", args[0])
+	return nil
+}
+
+func runDataPIITest(cmd *cobra.Command, args []string) error {
+	replacement, _ := cmd.Flags().GetString("replacement")
+	value, _ := cmd.Flags().GetString("level")
+	output, _ := cmd.Flags().GetString("output")
+	level, err := scl.ParseScrubLevel(value)
+	if err != nil {
+		return err
+	}
+
+	// Previewed against the built-ins only - in production would call
+	// scl.Service.TestRedactionPattern to include the org's other patterns
+	pattern := scl.RedactionPattern{Name: args[0], Pattern: args[1], Replacement: replacement}
+	test, err := scl.PreviewRedactionPattern(nil, pattern, args[2:], level)
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(test, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal pattern test: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	for i, sample := range test.Samples {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Sample:   %s\n", sample.Text)
+		if len(sample.Matches) == 0 {
+			fmt.Println("Matches:  none")
+		} else {
+			fmt.Printf("Matches:  %s\n", strings.Join(sample.Matches, ", "))
+		}
+		fmt.Printf("Redacted: %s\n", sample.Redacted)
+		if len(sample.Counts) > 0 && sample.Counts[pattern.Name] < len(sample.Matches) {
+			fmt.Printf("Note: other detectors claimed some matches first: %v\n", sample.Counts)
+		}
+	}
+	return nil
+}
@@ -52,11 +52,31 @@ func init() {
 
 	// Add subcommands
 	rootCmd.AddCommand(workflowCmd)
+	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(promptCmd)
 	rootCmd.AddCommand(traceCmd)
 	rootCmd.AddCommand(budgetCmd)
+	rootCmd.AddCommand(routeCmd)
+	rootCmd.AddCommand(providerCmd)
+	rootCmd.AddCommand(freezeCmd)
+	rootCmd.AddCommand(queueCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(finetuneCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(dataCmd)
+	rootCmd.AddCommand(signCmd)
+	rootCmd.AddCommand(signingKeyCmd)
+	rootCmd.AddCommand(agentCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(loadtestCmd)
+	rootCmd.AddCommand(eventsCmd)
+	rootCmd.AddCommand(sloCmd)
+	rootCmd.AddCommand(analyticsCmd)
+	rootCmd.AddCommand(notificationsCmd)
 }
 
 // initConfig reads in config file and ENV variables if set.
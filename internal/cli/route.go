@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/spf13/cobra"
+)
+
+var routeCmd = &cobra.Command{
+	Use:   "route",
+	Short: "Manage routing rules",
+	Long:  "Author declarative routing rules evaluated before provider scoring and simulate their impact",
+}
+
+var routeAddCmd = &cobra.Command{
+	Use:   "add [name] [expression]",
+	Short: "Add a routing rule",
+	Long:  `Add a routing rule, e.g. agentflow route add triage-haiku "if workflow=support-triage and tokens<500 then model=haiku"`,
+	Args:  cobra.ExactArgs(2),
+	RunE:  runRouteAdd,
+}
+
+var routeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List routing rules in evaluation order",
+	RunE:  runRouteList,
+}
+
+var routeDeleteCmd = &cobra.Command{
+	Use:   "delete [rule-id]",
+	Short: "Delete a routing rule",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRouteDelete,
+}
+
+var routePolicyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Show or set the org's model allow/deny lists",
+	Long: `Show or set which providers and models the org may use. Entries are "provider"
+or "provider/model" and may use globs, e.g. --allow "anthropic/*" --deny "openai/gpt-4*".`,
+	RunE: runRoutePolicy,
+}
+
+var routeSimulateCmd = &cobra.Command{
+	Use:   "simulate [expression...]",
+	Short: "Replay recent traffic through proposed rules",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runRouteSimulate,
+}
+
+func init() {
+	routeAddCmd.Flags().IntP("priority", "p", 100, "Evaluation priority (lower runs first)")
+	routeSimulateCmd.Flags().StringP("window", "w", "7d", "Traffic window to replay")
+	routePolicyCmd.Flags().StringSlice("allow", nil, "Replace the allow list")
+	routePolicyCmd.Flags().StringSlice("deny", nil, "Replace the deny list")
+
+	routeCmd.AddCommand(routeAddCmd)
+	routeCmd.AddCommand(routeListCmd)
+	routeCmd.AddCommand(routeDeleteCmd)
+	routeCmd.AddCommand(routeSimulateCmd)
+	routeCmd.AddCommand(routePolicyCmd)
+}
+
+func runRouteAdd(cmd *cobra.Command, args []string) error {
+	priority, _ := cmd.Flags().GetInt("priority")
+
+	rule, err := cas.ParseRoutingRule(args[0], args[1])
+	if err != nil {
+		return fmt.Errorf("invalid rule: %w", err)
+	}
+	rule.Priority = priority
+
+	fmt.Printf("Adding routing rule: %s (priority %d)\n", rule.Name, rule.Priority)
+	for _, condition := range rule.Conditions {
+		fmt.Printf("  when %s %s %s\n", condition.Field, condition.Operator, condition.Value)
+	}
+	fmt.Printf("  route to provider=%q model=%q\n", rule.Action.ProviderName, rule.Action.ModelName)
+
+	// Mock create - in production would call cas.Service.CreateRoutingRule
+	fmt.Printf("Routing rule created successfully!\n")
+
+	return nil
+}
+
+func runRouteList(cmd *cobra.Command, args []string) error {
+	// Mock rules - in production would call cas.Service.ListRoutingRules
+	rules := []struct {
+		ID         string
+		Name       string
+		Priority   int
+		Expression string
+	}{
+		{"3b9f1c2d-7a4e-4f6b-9c8d-1e2f3a4b5c6d", "triage-haiku", 10, "if workflow=support-triage and tokens<500 then model=haiku"},
+		{"5c6d7e8f-9a0b-4c1d-8e2f-3a4b5c6d7e8f", "bronze-openai", 100, "if quality=Bronze then provider=openai, model=gpt-3.5-turbo"},
+	}
+
+	fmt.Printf("%-38s %-16s %-9s %s\n", "ID", "NAME", "PRIORITY", "EXPRESSION")
+	fmt.Printf("%-38s %-16s %-9s %s\n", "--", "----", "--------", "----------")
+	for _, r := range rules {
+		fmt.Printf("%-38s %-16s %-9d %s\n", r.ID, r.Name, r.Priority, r.Expression)
+	}
+
+	return nil
+}
+
+func runRouteDelete(cmd *cobra.Command, args []string) error {
+	fmt.Printf("Deleting routing rule: %s\n", args[0])
+
+	// Mock delete - in production would call cas.Service.DeleteRoutingRule
+	fmt.Printf("Routing rule deleted successfully!\n")
+
+	return nil
+}
+
+func runRouteSimulate(cmd *cobra.Command, args []string) error {
+	window, _ := cmd.Flags().GetString("window")
+
+	for i, expression := range args {
+		if _, err := cas.ParseRoutingRule(fmt.Sprintf("proposed-%d", i+1), expression); err != nil {
+			return fmt.Errorf("invalid rule %q: %w", expression, err)
+		}
+	}
+
+	fmt.Printf("Replaying last %s of traffic through %d proposed rule(s)\n", window, len(args))
+
+	// Mock simulation - in production would call cas.Service.SimulateRoutingRules
+	fmt.Println("\nSimulation Results:")
+	fmt.Println("===================")
+	fmt.Printf("Requests replayed: 12,480\n")
+	fmt.Printf("Matched by rules:  3,912 (31.3%%)\n")
+	fmt.Printf("Cost:    $412.50 -> $318.20 (-22.9%%)\n")
+	fmt.Printf("Quality: 0.87 -> 0.84 (-0.03)\n")
+
+	fmt.Println("\nBy Rule:")
+	for i := range args {
+		fmt.Printf("  proposed-%d: 3,912 matched, $142.10 -> $47.80, quality -0.10\n", i+1)
+	}
+
+	return nil
+}
+
+func runRoutePolicy(cmd *cobra.Command, args []string) error {
+	allow, _ := cmd.Flags().GetStringSlice("allow")
+	deny, _ := cmd.Flags().GetStringSlice("deny")
+
+	if !cmd.Flags().Changed("allow") && !cmd.Flags().Changed("deny") {
+		// Mock policy - in production would call cas.Service.GetModelPolicy
+		fmt.Println("Model Policy:")
+		fmt.Println("=============")
+		fmt.Printf("Allow: anthropic/*, openai/gpt-4o*\n")
+		fmt.Printf("Deny:  openai/gpt-4o-realtime*\n")
+		return nil
+	}
+
+	policy := &cas.ModelPolicy{Allow: allow, Deny: deny}
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updating model policy\n")
+	fmt.Printf("Allow: %v\n", policy.Allow)
+	fmt.Printf("Deny:  %v\n", policy.Deny)
+
+	// Mock update - in production would call cas.Service.SetModelPolicy
+	fmt.Printf("Model policy updated successfully!\n")
+
+	return nil
+}
@@ -0,0 +1,734 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Search and tag workflow runs",
+	Long:  "List runs by status, workflow, tags, time range and cost, tag runs and manage saved filters",
+}
+
+var runListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List workflow runs",
+	Long:  `List workflow runs newest first, e.g. agentctl run list --status failed --tag team=data`,
+	RunE:  runRunList,
+}
+
+var runTagCmd = &cobra.Command{
+	Use:   "tag [run-id] [key=value...]",
+	Short: "Add or remove run tags",
+	Long:  "Merge tags into a run; pass key= with no value to remove a tag",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runRunTag,
+}
+
+var runFilterCmd = &cobra.Command{
+	Use:   "filter",
+	Short: "Manage saved run filters",
+}
+
+var runFilterSaveCmd = &cobra.Command{
+	Use:   "save [name]",
+	Short: "Save the given list flags as a named filter",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRunFilterSave,
+}
+
+var runFilterListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved run filters",
+	RunE:  runRunFilterList,
+}
+
+var runFilterDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a saved run filter",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRunFilterDelete,
+}
+
+var runBatchStartCmd = &cobra.Command{
+	Use:   "batch-start",
+	Short: "Start one run per record of a JSONL dataset",
+	Long:  `Submit a batch of runs sharing tags and a batch ID, e.g. agentctl run batch-start --workflow summarize --dataset tickets.jsonl`,
+	RunE:  runRunBatchStart,
+}
+
+var runBatchStatusCmd = &cobra.Command{
+	Use:   "batch-status [batch-id]",
+	Short: "Show progress and cost of a batch",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRunBatchStatus,
+}
+
+var runOutputCmd = &cobra.Command{
+	Use:   "output [run-id]",
+	Short: "Print the part of a run's output selected by a JSONPath",
+	Long: `Project a run's output document, which holds the final output under $.output
+and each step's latest output under $.steps.<step>, e.g.
+  agentctl run output 7c1e... --path '$.steps.analyze.summary'`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRunOutput,
+}
+
+var runExtractCmd = &cobra.Command{
+	Use:   "extract",
+	Short: "Extract output fields from every run matching a filter",
+	Long:  `Print one JSON line per run with the selected fields, e.g. agentctl run extract -w triage --path '$.steps.classify.label' --path '$.output.priority'`,
+	RunE:  runRunExtract,
+}
+
+var runGuardrailsCmd = &cobra.Command{
+	Use:   "guardrails [run-id]",
+	Short: "List actions a run's steps were blocked from taking by their sandbox",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRunGuardrails,
+}
+
+var runFailuresCmd = &cobra.Command{
+	Use:   "failures",
+	Short: "Summarize failed runs by cause with suggested fixes",
+	Long:  `Group failed runs by triaged category (provider_error, budget, policy, schema, tool_error, timeout, user_input), e.g. agentctl run failures --since 7d`,
+	RunE:  runRunFailures,
+}
+
+var runFailedTasksCmd = &cobra.Command{
+	Use:   "failed-tasks",
+	Short: "List tasks in the dead letter queue",
+	Long:  `Show tasks that failed permanently or exhausted their retries, with their error context, e.g. agentctl run failed-tasks --workflow triage`,
+	RunE:  runRunFailedTasks,
+}
+
+var runRequeueCmd = &cobra.Command{
+	Use:   "requeue [failed-task-id]",
+	Short: "Requeue a failed task from the dead letter queue",
+	Long:  "Retry the step of a dead-lettered task as a new attempt, optionally overriding its inputs or config, once the cause is fixed",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRunRequeue,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{runListCmd, runFilterSaveCmd, runExtractCmd} {
+		cmd.Flags().StringP("status", "s", "", "Filter by status")
+		cmd.Flags().StringP("workflow", "w", "", "Filter by workflow name")
+		cmd.Flags().StringToStringP("tag", "t", nil, "Filter by tag key=value (repeatable)")
+		cmd.Flags().String("since", "", "Runs created since a duration ago (24h) or RFC3339 time")
+		cmd.Flags().String("until", "", "Runs created before a duration ago or RFC3339 time")
+		cmd.Flags().Int64("min-cost", 0, "Minimum run cost in cents")
+	}
+	addListFlags(runListCmd, 20, "status=failed,cost_cents>=100")
+	runListCmd.Flags().String("saved", "", "Use a saved filter")
+	runListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	runBatchStartCmd.Flags().StringP("workflow", "w", "", "Workflow name")
+	runBatchStartCmd.Flags().Int("version", 0, "Workflow version (default latest)")
+	runBatchStartCmd.Flags().StringP("dataset", "d", "", "JSONL file with one input object per line")
+	runBatchStartCmd.Flags().StringSliceP("tag", "t", nil, "Tag key=value applied to every run (repeatable)")
+	runBatchStartCmd.Flags().StringToStringP("label", "l", nil, "Cost allocation label key=value applied to every run (repeatable)")
+	runBatchStartCmd.Flags().Int64("budget", 0, "Per-run budget in cents")
+	runBatchStartCmd.Flags().String("env", "", "Environment profile")
+	_ = runBatchStartCmd.MarkFlagRequired("workflow")
+	_ = runBatchStartCmd.MarkFlagRequired("dataset")
+	runBatchStatusCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	runGuardrailsCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	runFailuresCmd.Flags().String("since", "24h", "Failures since a duration ago (24h) or RFC3339 time")
+	runFailuresCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	runFailedTasksCmd.Flags().String("run", "", "Only tasks of this run")
+	runFailedTasksCmd.Flags().StringP("workflow", "w", "", "Only tasks of this workflow")
+	runFailedTasksCmd.Flags().String("step", "", "Only tasks of this step")
+	runFailedTasksCmd.Flags().IntP("limit", "l", 50, "Maximum number of tasks to list")
+	runFailedTasksCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	runRequeueCmd.Flags().StringP("inputs", "i", "", "Input overrides as JSON")
+	runRequeueCmd.Flags().StringP("config", "c", "", "Step config overrides as JSON")
+	runRequeueCmd.Flags().StringP("reason", "r", "", "Reason for the requeue")
+	runOutputCmd.Flags().StringP("path", "p", "$", "JSONPath to project, e.g. $.steps.analyze.summary")
+	runExtractCmd.Flags().StringArrayP("path", "p", nil, "JSONPath to extract (repeatable)")
+	runExtractCmd.Flags().String("cursor", "", "Continue from a previous page's cursor")
+	runExtractCmd.Flags().IntP("limit", "l", 50, "Number of runs to extract from")
+	_ = runExtractCmd.MarkFlagRequired("path")
+
+	runFilterCmd.AddCommand(runFilterSaveCmd)
+	runFilterCmd.AddCommand(runFilterListCmd)
+	runFilterCmd.AddCommand(runFilterDeleteCmd)
+
+	runCmd.AddCommand(runListCmd)
+	runCmd.AddCommand(runTagCmd)
+	runCmd.AddCommand(runFilterCmd)
+	runCmd.AddCommand(runBatchStartCmd)
+	runCmd.AddCommand(runBatchStatusCmd)
+	runCmd.AddCommand(runGuardrailsCmd)
+	runCmd.AddCommand(runFailuresCmd)
+	runCmd.AddCommand(runFailedTasksCmd)
+	runCmd.AddCommand(runRequeueCmd)
+	runCmd.AddCommand(runOutputCmd)
+	runCmd.AddCommand(runExtractCmd)
+}
+
+func runRunList(cmd *cobra.Command, args []string) error {
+	filter, err := runFilterFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	opts := listOptionsFromFlags(cmd)
+	filter.Expr, filter.Cursor, filter.Limit = opts.Filter, opts.Cursor, opts.Limit
+	savedName, _ := cmd.Flags().GetString("saved")
+	output, _ := cmd.Flags().GetString("output")
+
+	if savedName != "" {
+		fmt.Printf("Using saved filter: %s\n", savedName)
+	}
+
+	// Mock listing - in production would call aor.ControlPlane.ListRuns or ListRunsWithSavedFilter
+	now := time.Now()
+	runs := []aor.WorkflowRun{
+		{WorkflowName: "document_analysis", Status: aor.WorkflowStatusFailed, CostCents: 250,
+			Tags: map[string]string{"team": "data"}, CreatedAt: now.Add(-2 * time.Hour)},
+		{WorkflowName: "data_extraction", Status: aor.WorkflowStatusCompleted, CostCents: 120,
+			Tags: map[string]string{"team": "data", "env": "prod"}, CreatedAt: now.Add(-3 * time.Hour)},
+		{WorkflowName: "content_generation", Status: aor.WorkflowStatusFailed, CostCents: 500,
+			Tags: map[string]string{"team": "marketing"}, CreatedAt: now.Add(-5 * time.Hour)},
+	}
+
+	page := &aor.RunPage{Runs: make([]aor.WorkflowRun, 0, len(runs))}
+	for _, run := range runs {
+		if mockRunMatches(run, filter) {
+			page.Runs = append(page.Runs, run)
+		}
+	}
+	page.TotalEstimate = int64(len(page.Runs))
+
+	if output == "json" {
+		return printListJSON("runs", page.Runs, page.PageMeta, opts.Fields)
+	}
+
+	fmt.Printf("%-20s %-12s %-18s %-10s %s\n", "WORKFLOW", "STATUS", "CREATED", "COST", "TAGS")
+	fmt.Println("--------------------------------------------------------------------------------")
+	for _, run := range page.Runs {
+		fmt.Printf("%-20s %-12s %-18s $%-9.2f %s\n",
+			run.WorkflowName, run.Status, run.CreatedAt.Format("2006-01-02 15:04"),
+			float64(run.CostCents)/100, formatTags(run.Tags))
+	}
+	printPageFooter(len(page.Runs), page.PageMeta)
+
+	return nil
+}
+
+func runRunTag(cmd *cobra.Command, args []string) error {
+	runID := args[0]
+
+	tags := make(map[string]string, len(args)-1)
+	for _, arg := range args[1:] {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid tag %q: expected key=value", arg)
+		}
+		tags[key] = value
+	}
+
+	// Mock update - in production would call aor.ControlPlane.SetRunTags
+	fmt.Printf("Updated tags on run %s: %s\n", runID, formatTags(tags))
+	return nil
+}
+
+func runRunFilterSave(cmd *cobra.Command, args []string) error {
+	filter, err := runFilterFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(filter)
+	if err != nil {
+		return fmt.Errorf("failed to format filter: %w", err)
+	}
+
+	// Mock save - in production would call aor.ControlPlane.SaveRunFilter
+	fmt.Printf("Saved run filter %s: %s\n", args[0], data)
+	fmt.Printf("Use 'agentctl run list --saved %s' to apply it\n", args[0])
+	return nil
+}
+
+func runRunFilterList(cmd *cobra.Command, args []string) error {
+	// Mock listing - in production would call aor.ControlPlane.ListRunFilters
+	fmt.Printf("%-20s %s\n", "NAME", "FILTER")
+	fmt.Printf("%-20s %s\n", "failed-data", `{"status":"failed","tags":{"team":"data"}}`)
+	fmt.Printf("%-20s %s\n", "expensive", `{"min_cost_cents":1000}`)
+	return nil
+}
+
+func runRunFilterDelete(cmd *cobra.Command, args []string) error {
+	// Mock delete - in production would call aor.ControlPlane.DeleteRunFilter
+	fmt.Printf("Deleted run filter: %s\n", args[0])
+	return nil
+}
+
+func runRunBatchStart(cmd *cobra.Command, args []string) error {
+	workflow, _ := cmd.Flags().GetString("workflow")
+	dataset, _ := cmd.Flags().GetString("dataset")
+	tags, _ := cmd.Flags().GetStringSlice("tag")
+	labels, _ := cmd.Flags().GetStringToString("label")
+
+	for _, tag := range tags {
+		if key, _, ok := strings.Cut(tag, "="); !ok || key == "" {
+			return fmt.Errorf("invalid tag %q: expected key=value", tag)
+		}
+	}
+	if err := cas.ValidateCostLabels(labels); err != nil {
+		return err
+	}
+
+	file, err := os.Open(dataset) // #nosec G304 - user-provided dataset path
+	if err != nil {
+		return fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer file.Close()
+
+	records, err := aor.ParseDatasetJSONL(file)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("dataset %s has no records", dataset)
+	}
+
+	// Mock submission - in production would upload with aor.ControlPlane.PutDataset and call SubmitBatch
+	batchID := uuid.New()
+	fmt.Printf("Started batch %s\n", batchID)
+	fmt.Printf("  Workflow: %s\n", workflow)
+	fmt.Printf("  Records: %d\n", len(records))
+	if len(tags) > 0 {
+		fmt.Printf("  Tags: %s\n", strings.Join(tags, ", "))
+	}
+	if len(labels) > 0 {
+		fmt.Printf("  Cost labels: %s\n", formatTags(labels))
+	}
+	fmt.Printf("\nTrack progress with 'agentctl run batch-status %s'\n", batchID)
+	fmt.Printf("List its runs with 'agentctl run list --tag batch_id=%s'\n", batchID)
+	return nil
+}
+
+func runRunBatchStatus(cmd *cobra.Command, args []string) error {
+	batchID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid batch ID: %w", err)
+	}
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock status - in production would call aor.ControlPlane.GetRunBatch
+	batch := aor.RunBatch{
+		ID:              batchID,
+		WorkflowName:    "document_analysis",
+		Tags:            map[string]string{"team": "data"},
+		TotalRecords:    500,
+		RejectedRecords: 2,
+		Errors:          []aor.BatchRecordError{{Record: 17, Error: "estimated cost 620 cents exceeds budget 500 cents"}},
+		CreatedAt:       time.Now().Add(-40 * time.Minute),
+		Progress: &aor.BatchProgress{
+			Runs: 498,
+			ByStatus: map[aor.WorkflowStatus]int{
+				aor.WorkflowStatusCompleted: 401,
+				aor.WorkflowStatusFailed:    7,
+				aor.WorkflowStatusRunning:   20,
+				aor.WorkflowStatusPending:   70,
+			},
+			Finished:  408,
+			CostCents: 61240,
+			Status:    aor.WorkflowStatusRunning,
+		},
+	}
+	batch.Progress.PercentDone = float64(batch.Progress.Finished) / float64(batch.Progress.Runs) * 100
+
+	if output == "json" {
+		data, err := json.MarshalIndent(batch, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format batch: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	progress := batch.Progress
+	fmt.Printf("Batch %s (%s)\n", batch.ID, batch.WorkflowName)
+	fmt.Printf("  Status: %s\n", progress.Status)
+	fmt.Printf("  Progress: %d/%d runs finished (%.1f%%)\n", progress.Finished, progress.Runs, progress.PercentDone)
+	for _, status := range []aor.WorkflowStatus{aor.WorkflowStatusPending, aor.WorkflowStatusRunning, aor.WorkflowStatusCompleted, aor.WorkflowStatusFailed, aor.WorkflowStatusCancelled} {
+		if n := progress.ByStatus[status]; n > 0 {
+			fmt.Printf("    %-10s %d\n", status, n)
+		}
+	}
+	fmt.Printf("  Cost: $%.2f\n", float64(progress.CostCents)/100)
+	if batch.RejectedRecords > 0 {
+		fmt.Printf("  Rejected records: %d of %d\n", batch.RejectedRecords, batch.TotalRecords)
+		for _, e := range batch.Errors {
+			fmt.Printf("    record %d: %s\n", e.Record, e.Error)
+		}
+	}
+	return nil
+}
+
+func runRunOutput(cmd *cobra.Command, args []string) error {
+	runID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %w", err)
+	}
+	expr, _ := cmd.Flags().GetString("path")
+	path, err := aor.ParseJSONPath(expr)
+	if err != nil {
+		return err
+	}
+
+	// Mock projection - in production would call aor.ControlPlane.GetRunOutput
+	projection := aor.RunOutputProjection{RunID: runID, Path: path.String()}
+	matches := path.Select(mockRunOutputDocument(0))
+	if path.Definite() {
+		if len(matches) > 0 {
+			projection.Value, projection.Found = matches[0], true
+		}
+	} else {
+		projection.Value, projection.Found = matches, len(matches) > 0
+	}
+
+	if !projection.Found {
+		return fmt.Errorf("path %s matched nothing in run %s", projection.Path, runID)
+	}
+	data, err := json.MarshalIndent(projection.Value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format output: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func runRunExtract(cmd *cobra.Command, args []string) error {
+	filter, err := runFilterFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	filter.Cursor, _ = cmd.Flags().GetString("cursor")
+	filter.Limit, _ = cmd.Flags().GetInt("limit")
+	exprs, _ := cmd.Flags().GetStringArray("path")
+
+	paths := make([]*aor.JSONPath, 0, len(exprs))
+	for _, expr := range exprs {
+		path, err := aor.ParseJSONPath(expr)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+	}
+
+	// Mock extraction - in production would call aor.ControlPlane.ExtractRunOutputs
+	now := time.Now()
+	for i, status := range []aor.WorkflowStatus{aor.WorkflowStatusCompleted, aor.WorkflowStatusCompleted, aor.WorkflowStatusFailed} {
+		row := aor.RunOutputRow{
+			RunID:        uuid.New(),
+			WorkflowName: "document_analysis",
+			Status:       status,
+			CreatedAt:    now.Add(-time.Duration(i+1) * time.Hour),
+			Values:       make(map[string]interface{}, len(paths)),
+		}
+		doc := mockRunOutputDocument(i)
+		for _, path := range paths {
+			matches := path.Select(doc)
+			switch {
+			case !path.Definite():
+				row.Values[path.String()] = matches
+			case len(matches) > 0:
+				row.Values[path.String()] = matches[0]
+			default:
+				row.Values[path.String()] = nil
+			}
+		}
+		data, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("failed to format row: %w", err)
+		}
+		fmt.Println(string(data))
+	}
+	return nil
+}
+
+// mockRunOutputDocument stands in for a run's output document in mock commands
+func mockRunOutputDocument(i int) map[string]interface{} {
+	labels := []string{"invoice", "contract", "receipt"}
+	return map[string]interface{}{
+		"output": map[string]interface{}{"priority": []interface{}{"high", "low", "medium"}[i%3]},
+		"steps": map[string]interface{}{
+			"analyze":  map[string]interface{}{"summary": "Quarterly report covering revenue and churn", "tokens": 812.0},
+			"classify": map[string]interface{}{"label": labels[i%len(labels)], "confidence": 0.93},
+		},
+	}
+}
+
+func runRunGuardrails(cmd *cobra.Command, args []string) error {
+	runID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %w", err)
+	}
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock events - in production would call aor.ControlPlane.ListGuardrailEvents
+	events := []aor.GuardrailEvent{
+		{ID: uuid.New(), RunID: runID, StepID: "parse_attachments", Kind: aor.GuardrailNetworkEgress,
+			Target: "paste.example.net:443", Action: "blocked", CreatedAt: time.Now().Add(-12 * time.Minute)},
+		{ID: uuid.New(), RunID: runID, StepID: "parse_attachments", Kind: aor.GuardrailFilesystemWrite,
+			Target: "/etc/cron.d/job", Action: "blocked", CreatedAt: time.Now().Add(-12 * time.Minute)},
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(events, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format guardrail events: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(events) == 0 {
+		fmt.Printf("No guardrail events for run %s\n", runID)
+		return nil
+	}
+	fmt.Printf("%-20s %-20s %-18s %-8s %s\n", "TIME", "STEP", "KIND", "ACTION", "TARGET")
+	for _, event := range events {
+		fmt.Printf("%-20s %-20s %-18s %-8s %s\n", event.CreatedAt.Format("2006-01-02 15:04:05"),
+			event.StepID, event.Kind, event.Action, event.Target)
+	}
+	return nil
+}
+
+func runRunFailedTasks(cmd *cobra.Command, args []string) error {
+	runFlag, _ := cmd.Flags().GetString("run")
+	workflow, _ := cmd.Flags().GetString("workflow")
+	step, _ := cmd.Flags().GetString("step")
+	limit, _ := cmd.Flags().GetInt("limit")
+	output, _ := cmd.Flags().GetString("output")
+
+	filter := aor.DeadLetterFilter{Workflow: workflow, StepID: step, Limit: limit}
+	if runFlag != "" {
+		runID, err := uuid.Parse(runFlag)
+		if err != nil {
+			return fmt.Errorf("invalid run ID: %w", err)
+		}
+		filter.RunID = runID
+	}
+
+	// Mock dead letters - in production would call aor.ControlPlane.ListFailedTasks
+	runID := filter.RunID
+	if runID == uuid.Nil {
+		runID = uuid.New()
+	}
+	letters := []aor.DeadLetter{
+		{ID: 1042, Task: aor.Task{ID: uuid.New(), RunID: runID, Workflow: "ticket-triage", StepID: "classify", Type: "llm"},
+			Error: "task failed after 3 attempts: provider openai returned 503: service unavailable", ErrorClass: string(cas.ErrorClassServer),
+			FailureCategory: string(aor.FailureProviderError), Attempts: 3, Retryable: true, WorkerID: "worker-7", FailedAt: time.Now().Add(-25 * time.Minute)},
+		{ID: 1017, Task: aor.Task{ID: uuid.New(), RunID: runID, Workflow: "ticket-triage", StepID: "lookup_account", Type: "tool"},
+			Error: "plugin crm returned 400: invalid account id", ErrorClass: string(cas.ErrorClassInvalidRequest),
+			FailureCategory: string(aor.FailureToolError), Attempts: 1, WorkerID: "worker-2", FailedAt: time.Now().Add(-3 * time.Hour)},
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(letters, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format failed tasks: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(letters) == 0 {
+		fmt.Println("No failed tasks in the dead letter queue")
+		return nil
+	}
+	fmt.Printf("%-6s %-20s %-38s %-16s %-8s %-9s %s\n", "ID", "FAILED", "RUN", "STEP", "ATTEMPTS", "RETRYABLE", "ERROR")
+	for _, dl := range letters {
+		fmt.Printf("%-6d %-20s %-38s %-16s %-8d %-9t %s\n", dl.ID, dl.FailedAt.Format("2006-01-02 15:04:05"),
+			dl.Task.RunID, dl.Task.StepID, dl.Attempts, dl.Retryable, dl.Error)
+	}
+	fmt.Println("\nRequeue a task with 'agentctl run requeue <id>' once its cause is fixed")
+	return nil
+}
+
+func runRunRequeue(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid failed task ID: %w", err)
+	}
+	inputsStr, _ := cmd.Flags().GetString("inputs")
+	configStr, _ := cmd.Flags().GetString("config")
+	reason, _ := cmd.Flags().GetString("reason")
+
+	req := &aor.StepRetryRequest{Reason: reason}
+	if inputsStr != "" {
+		if err := json.Unmarshal([]byte(inputsStr), &req.Inputs); err != nil {
+			return fmt.Errorf("failed to parse inputs: %w", err)
+		}
+	}
+	if configStr != "" {
+		if err := json.Unmarshal([]byte(configStr), &req.Config); err != nil {
+			return fmt.Errorf("failed to parse config: %w", err)
+		}
+	}
+
+	// Mock requeue - in production would call aor.ControlPlane.RequeueFailedTask
+	stepRun := aor.StepRun{ID: uuid.New().String(), WorkflowRunID: uuid.New(), StepID: "classify", Attempt: 2,
+		Status: aor.StepStatusQueued, Overrides: req}
+	fmt.Printf("Requeued failed task %d as attempt %d of step %s\n", id, stepRun.Attempt, stepRun.StepID)
+	if len(req.Inputs) > 0 || len(req.Config) > 0 {
+		fmt.Println("  With input or config overrides")
+	}
+	fmt.Printf("Use 'agentctl workflow status %s' to check progress\n", stepRun.WorkflowRunID)
+	return nil
+}
+
+func runRunFailures(cmd *cobra.Command, args []string) error {
+	sinceFlag, _ := cmd.Flags().GetString("since")
+	output, _ := cmd.Flags().GetString("output")
+	since, err := parseTimeFlag(sinceFlag, time.Now())
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	// Mock dashboard - in production would call aor.ControlPlane.GetFailureDashboard
+	dashboard := aor.FailureDashboard{
+		Since:      since,
+		FailedRuns: 14,
+		Categories: []aor.FailureCategoryCount{
+			{Category: aor.FailureProviderError, Runs: 8, Share: 8.0 / 14,
+				Remediation: aor.FailureRemediation(aor.FailureProviderError),
+				Example:     "provider openai returned 503: service unavailable"},
+			{Category: aor.FailureBudget, Runs: 4, Share: 4.0 / 14,
+				Remediation: aor.FailureRemediation(aor.FailureBudget),
+				Example:     "run budget exhausted: spent 500 of 500 cents"},
+			{Category: aor.FailureSchema, Runs: 2, Share: 2.0 / 14,
+				Remediation: aor.FailureRemediation(aor.FailureSchema),
+				Example:     "step classify output port label: expected string, got number"},
+		},
+		Workflows: []aor.WorkflowFailureCount{
+			{WorkflowName: "document-analysis", Runs: 9, ByCategory: map[aor.FailureCategory]int{aor.FailureProviderError: 6, aor.FailureBudget: 3}},
+			{WorkflowName: "ticket-triage", Runs: 5, ByCategory: map[aor.FailureCategory]int{aor.FailureProviderError: 2, aor.FailureBudget: 1, aor.FailureSchema: 2}},
+		},
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(dashboard, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format failure dashboard: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if dashboard.FailedRuns == 0 {
+		fmt.Printf("No failed runs since %s\n", since.Format(time.RFC3339))
+		return nil
+	}
+	fmt.Printf("%d failed runs since %s\n\n", dashboard.FailedRuns, since.Format(time.RFC3339))
+	fmt.Printf("%-16s %-6s %-6s %s\n", "CATEGORY", "RUNS", "SHARE", "EXAMPLE")
+	for _, category := range dashboard.Categories {
+		fmt.Printf("%-16s %-6d %-6s %s\n", category.Category, category.Runs,
+			fmt.Sprintf("%.0f%%", category.Share*100), category.Example)
+	}
+
+	fmt.Printf("\n%-24s %-6s %s\n", "WORKFLOW", "RUNS", "CATEGORIES")
+	for _, workflow := range dashboard.Workflows {
+		parts := make([]string, 0, len(workflow.ByCategory))
+		for category, runs := range workflow.ByCategory {
+			parts = append(parts, fmt.Sprintf("%s=%d", category, runs))
+		}
+		sort.Strings(parts)
+		fmt.Printf("%-24s %-6d %s\n", workflow.WorkflowName, workflow.Runs, strings.Join(parts, " "))
+	}
+
+	fmt.Println("\nSuggested fixes:")
+	for _, category := range dashboard.Categories {
+		fmt.Printf("  %s: %s\n", category.Category, category.Remediation)
+	}
+	return nil
+}
+
+// runFilterFromFlags builds a run filter from the shared list flags
+func runFilterFromFlags(cmd *cobra.Command) (aor.RunFilter, error) {
+	var filter aor.RunFilter
+
+	status, _ := cmd.Flags().GetString("status")
+	filter.Status = aor.WorkflowStatus(status)
+	filter.WorkflowName, _ = cmd.Flags().GetString("workflow")
+	filter.Tags, _ = cmd.Flags().GetStringToString("tag")
+	filter.MinCostCents, _ = cmd.Flags().GetInt64("min-cost")
+
+	now := time.Now()
+	for flag, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value, _ := cmd.Flags().GetString(flag)
+		if value == "" {
+			continue
+		}
+		ts, err := parseTimeFlag(value, now)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --%s: %w", flag, err)
+		}
+		*target = &ts
+	}
+
+	return filter, nil
+}
+
+// parseTimeFlag accepts a duration before now (24h, 7d) or an RFC3339 time
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339, value); err == nil {
+		return ts, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		if _, err := fmt.Sscanf(days, "%d", &n); err == nil {
+			return now.Add(-time.Duration(n) * 24 * time.Hour), nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a duration or RFC3339 time: %s", value)
+	}
+	return now.Add(-d), nil
+}
+
+func mockRunMatches(run aor.WorkflowRun, filter aor.RunFilter) bool {
+	if filter.Status != "" && run.Status != filter.Status {
+		return false
+	}
+	if filter.WorkflowName != "" && run.WorkflowName != filter.WorkflowName {
+		return false
+	}
+	for key, value := range filter.Tags {
+		if run.Tags[key] != value {
+			return false
+		}
+	}
+	if filter.Since != nil && run.CreatedAt.Before(*filter.Since) {
+		return false
+	}
+	if filter.Until != nil && !run.CreatedAt.Before(*filter.Until) {
+		return false
+	}
+	return run.CostCents >= filter.MinCostCents
+}
+
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
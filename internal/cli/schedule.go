@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var workflowScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage cron schedules that trigger workflows",
+	Long: `Workflows declare cron schedules in metadata.schedules:
+  schedules:
+    - name: nightly
+      cron: "0 2 * * *"
+      timezone: Europe/Berlin
+      catchup: latest
+      inputs: {window: 24h}
+Runs missed while the control plane was down or the schedule was paused are
+dropped (catchup: skip, the default), started once (latest) or all started,
+up to 10 (all).`,
+}
+
+var workflowScheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List workflow schedules and their next runs",
+	RunE:  runWorkflowScheduleList,
+}
+
+var workflowSchedulePauseCmd = &cobra.Command{
+	Use:   "pause [workflow-name] [schedule-name]",
+	Short: "Stop a schedule from starting runs",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runWorkflowScheduleEnable(false),
+}
+
+var workflowScheduleResumeCmd = &cobra.Command{
+	Use:   "resume [workflow-name] [schedule-name]",
+	Short: "Resume a paused schedule, applying its catchup policy",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runWorkflowScheduleEnable(true),
+}
+
+func init() {
+	workflowScheduleListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	workflowScheduleCmd.AddCommand(workflowScheduleListCmd)
+	workflowScheduleCmd.AddCommand(workflowSchedulePauseCmd)
+	workflowScheduleCmd.AddCommand(workflowScheduleResumeCmd)
+	workflowCmd.AddCommand(workflowScheduleCmd)
+}
+
+func runWorkflowScheduleList(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock schedules - in production would call aor.ControlPlane.ListSchedules
+	nightly := aor.ScheduleTrigger{Name: "nightly", Cron: "0 2 * * *", Timezone: "Europe/Berlin", Catchup: aor.ScheduleCatchupLatest}
+	hourly := aor.ScheduleTrigger{Name: "hourly-sync", Cron: "15 * * * *"}
+	lastRun, lastRunID := time.Now().Add(-22*time.Hour).Truncate(time.Hour), uuid.New()
+	schedules := []aor.WorkflowSchedule{
+		{WorkflowName: "crm_sync", Schedule: hourly, Enabled: true, NextRunAt: time.Now().Truncate(time.Hour).Add(75 * time.Minute)},
+		{WorkflowName: "nightly_report", Schedule: nightly, Enabled: true, NextRunAt: lastRun.Add(24 * time.Hour),
+			LastRunAt: &lastRun, LastRunID: &lastRunID},
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(schedules, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal schedules: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-18s %-14s %-14s %-16s %-8s %-8s %s\n", "WORKFLOW", "SCHEDULE", "CRON", "TIMEZONE", "CATCHUP", "STATE", "NEXT RUN")
+	for _, schedule := range schedules {
+		timezone, catchup, state := schedule.Schedule.Timezone, schedule.Schedule.Catchup, "active"
+		if timezone == "" {
+			timezone = "UTC"
+		}
+		if catchup == "" {
+			catchup = aor.ScheduleCatchupSkip
+		}
+		if !schedule.Enabled {
+			state = "paused"
+		}
+		fmt.Printf("%-18s %-14s %-14s %-16s %-8s %-8s %s\n", schedule.WorkflowName, schedule.Schedule.Name,
+			schedule.Schedule.Cron, timezone, catchup, state, schedule.NextRunAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func runWorkflowScheduleEnable(enabled bool) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		// Mock update - in production would call aor.ControlPlane.SetScheduleEnabled
+		if enabled {
+			fmt.Printf("Resumed schedule %s of %s\n", args[1], args[0])
+		} else {
+			fmt.Printf("Paused schedule %s of %s\n", args[1], args[0])
+		}
+		return nil
+	}
+}
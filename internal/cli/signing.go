@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var signCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Sign the workflows and prompts in a bundle",
+	Long: `Sign every workflow and prompt in a bundle with an ed25519 or ECDSA P-256
+PKCS#8 private key, e.g.
+  openssl genpkey -algorithm ed25519 -out release.pem
+  openssl pkey -in release.pem -pubout -out release.pub
+  agentctl signing-key add release --public-key release.pub
+  agentctl sign -f agentflow.yaml --key release.pem --key-name release --out signed.json
+
+To sign with cosign instead, write each resource's payload with --print-payload,
+sign it with cosign sign-blob and add the base64 signature to the resource.
+For git sync, store a resource's signature as JSON next to its file in <file>.sig.`,
+	RunE: runSign,
+}
+
+var signingKeyCmd = &cobra.Command{
+	Use:   "signing-key",
+	Short: "Manage the public keys workflows and prompts are signed with",
+}
+
+var signingKeyAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Register a PEM public key; its name is recorded as the signer",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSigningKeyAdd,
+}
+
+var signingKeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List signing keys",
+	RunE:  runSigningKeyList,
+}
+
+var signingKeyRevokeCmd = &cobra.Command{
+	Use:   "revoke [name]",
+	Short: "Stop accepting new signatures from a key",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSigningKeyRevoke,
+}
+
+func init() {
+	signCmd.Flags().StringP("file", "f", "", "Bundle file (YAML or JSON)")
+	signCmd.Flags().String("key", "", "PEM encoded PKCS#8 private key")
+	signCmd.Flags().String("key-name", "", "Name the public key is registered under")
+	signCmd.Flags().String("out", "", "Write the signed bundle as JSON to this file instead of stdout")
+	signCmd.Flags().Bool("print-payload", false, "Print each resource's signing payload instead of signing")
+	_ = signCmd.MarkFlagRequired("file")
+
+	signingKeyAddCmd.Flags().String("public-key", "", "PEM encoded public key file")
+	_ = signingKeyAddCmd.MarkFlagRequired("public-key")
+
+	signingKeyCmd.AddCommand(signingKeyAddCmd)
+	signingKeyCmd.AddCommand(signingKeyListCmd)
+	signingKeyCmd.AddCommand(signingKeyRevokeCmd)
+}
+
+func runSign(cmd *cobra.Command, args []string) error {
+	file, _ := cmd.Flags().GetString("file")
+	keyFile, _ := cmd.Flags().GetString("key")
+	keyName, _ := cmd.Flags().GetString("key-name")
+	out, _ := cmd.Flags().GetString("out")
+	printPayload, _ := cmd.Flags().GetBool("print-payload")
+
+	if err := validateFilePath(file); err != nil {
+		return fmt.Errorf("invalid file path: %w", err)
+	}
+	data, err := os.ReadFile(file) // #nosec G304 - path validated above
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		format = "json"
+	}
+	bundle, err := aor.ParseApplyBundle(data, format)
+	if err != nil {
+		return err
+	}
+
+	if printPayload {
+		for _, resource := range bundle.Resources {
+			hash, err := aor.SignedContentHash(resource)
+			if err != nil {
+				continue // Only workflows and prompts are signed
+			}
+			fmt.Printf("--- %s/%s\n%s", resource.Kind, resource.Name, aor.SignaturePayload(resource.Kind, resource.Name, hash))
+		}
+		return nil
+	}
+
+	if keyFile == "" || keyName == "" {
+		return fmt.Errorf("--key and --key-name are required to sign")
+	}
+	if err := validateFilePath(keyFile); err != nil {
+		return fmt.Errorf("invalid key path: %w", err)
+	}
+	privateKey, err := os.ReadFile(keyFile) // #nosec G304 - path validated above
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	signed := 0
+	for i := range bundle.Resources {
+		resource := &bundle.Resources[i]
+		if resource.Kind != aor.ResourceKindWorkflow && resource.Kind != aor.ResourceKindPrompt {
+			continue
+		}
+		if err := aor.SignResource(resource, keyName, privateKey); err != nil {
+			return fmt.Errorf("failed to sign %s/%s: %w", resource.Kind, resource.Name, err)
+		}
+		signed++
+	}
+
+	encoded, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format bundle: %w", err)
+	}
+	if out == "" {
+		fmt.Println(string(encoded))
+		return nil
+	}
+	if err := validateFilePath(out); err != nil {
+		return fmt.Errorf("invalid output path: %w", err)
+	}
+	if err := os.WriteFile(out, append(encoded, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write signed bundle: %w", err)
+	}
+	fmt.Printf("Signed %d resources with %s; wrote %s\n", signed, keyName, out)
+	return nil
+}
+
+func runSigningKeyAdd(cmd *cobra.Command, args []string) error {
+	publicKeyFile, _ := cmd.Flags().GetString("public-key")
+	if err := validateFilePath(publicKeyFile); err != nil {
+		return fmt.Errorf("invalid key path: %w", err)
+	}
+	publicKey, err := os.ReadFile(publicKeyFile) // #nosec G304 - path validated above
+	if err != nil {
+		return fmt.Errorf("failed to read public key: %w", err)
+	}
+	_, algorithm, err := aor.ParsePublicKey(string(publicKey))
+	if err != nil {
+		return err
+	}
+
+	// Mock add - in production would call aor.ControlPlane.AddSigningKey
+	fmt.Printf("Added %s signing key %s\n", algorithm, args[0])
+	return nil
+}
+
+func runSigningKeyList(cmd *cobra.Command, args []string) error {
+	// Mock keys - in production would call aor.ControlPlane.ListSigningKeys
+	revoked := time.Now().Add(-30 * 24 * time.Hour)
+	keys := []aor.SigningKey{
+		{ID: uuid.New(), Name: "release", Algorithm: aor.SignatureAlgorithmEd25519, CreatedBy: "platform@example.com", CreatedAt: time.Now().Add(-90 * 24 * time.Hour)},
+		{ID: uuid.New(), Name: "ci-cosign", Algorithm: aor.SignatureAlgorithmECDSAP256, CreatedBy: "ci@example.com", CreatedAt: time.Now().Add(-60 * 24 * time.Hour)},
+		{ID: uuid.New(), Name: "legacy", Algorithm: aor.SignatureAlgorithmEd25519, CreatedAt: time.Now().Add(-400 * 24 * time.Hour), RevokedAt: &revoked},
+	}
+
+	fmt.Printf("%-12s %-11s %-22s %-12s %s\n", "NAME", "ALGORITHM", "CREATED BY", "CREATED", "STATUS")
+	for _, key := range keys {
+		status := "active"
+		if key.RevokedAt != nil {
+			status = "revoked " + key.RevokedAt.Format("2006-01-02")
+		}
+		fmt.Printf("%-12s %-11s %-22s %-12s %s\n", key.Name, key.Algorithm, key.CreatedBy, key.CreatedAt.Format("2006-01-02"), status)
+	}
+	return nil
+}
+
+func runSigningKeyRevoke(cmd *cobra.Command, args []string) error {
+	// Mock revoke - in production would call aor.ControlPlane.RevokeSigningKey
+	fmt.Printf("Revoked signing key %s; resources it already signed keep their signatures\n", args[0])
+	return nil
+}
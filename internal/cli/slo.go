@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aos"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var sloCmd = &cobra.Command{
+	Use:   "slo",
+	Short: "Inspect step service level objectives",
+	Long: `Steps declare SLOs in their spec with an slo block, e.g.
+  slo: {latency_p95: 30s, cost_p95_cents: 5, success_rate: 0.99, window: 720h}
+Attainment and error budget burn are tracked over the rolling window; burning
+14.4x too fast over an hour pages and 6x over six hours raises a ticket.`,
+}
+
+var sloStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show SLO attainment and error budget burn per step",
+	RunE:  runSLOStatus,
+}
+
+func init() {
+	sloStatusCmd.Flags().StringP("workflow", "w", "", "Only show steps of this workflow")
+	sloStatusCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	sloCmd.AddCommand(sloStatusCmd)
+}
+
+func runSLOStatus(cmd *cobra.Command, args []string) error {
+	workflow, _ := cmd.Flags().GetString("workflow")
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock status - in production would call aos.Service.GetStepSLOs
+	orgID := uuid.New()
+	statuses := []aos.StepSLOStatus{
+		{OrgID: orgID, WorkflowName: "document_analysis", StepID: "summarize", Window: "720h0m0s", Objectives: []aos.SLOAttainment{
+			{Objective: aos.SLOLatency, Target: 0.95, Threshold: "30s", Attempts: 12480, Bad: 411, AttainmentPct: 96.7,
+				ErrorBudgetRemainingPct: 34.1, BurnRate1h: 1.2, BurnRate6h: 0.9},
+			{Objective: aos.SLOSuccess, Target: 0.99, Attempts: 12480, Bad: 212, AttainmentPct: 98.3,
+				ErrorBudgetRemainingPct: -69.9, BurnRate1h: 16.8, BurnRate6h: 7.4, Alert: aos.SLOAlertPage},
+		}},
+		{OrgID: orgID, WorkflowName: "support_triage", StepID: "classify", Window: "168h0m0s", Objectives: []aos.SLOAttainment{
+			{Objective: aos.SLOCost, Target: 0.95, Threshold: "2¢", Attempts: 5310, Bad: 97, AttainmentPct: 98.2,
+				ErrorBudgetRemainingPct: 63.5, BurnRate1h: 0.4, BurnRate6h: 0.3},
+		}},
+	}
+	if workflow != "" {
+		filtered := statuses[:0]
+		for _, status := range statuses {
+			if status.WorkflowName == workflow {
+				filtered = append(filtered, status)
+			}
+		}
+		statuses = filtered
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal SLO status: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-20s %-12s %-13s %-9s %9s %9s %8s %8s %s\n",
+		"WORKFLOW", "STEP", "OBJECTIVE", "TARGET", "ATTAINED", "BUDGET", "BURN 1H", "BURN 6H", "ALERT")
+	for _, status := range statuses {
+		for _, objective := range status.Objectives {
+			target := fmt.Sprintf("%.1f%%", objective.Target*100)
+			if objective.Threshold != "" {
+				target = "p95 " + objective.Threshold
+			}
+			alert := string(objective.Alert)
+			if alert == "" {
+				alert = "-"
+			}
+			fmt.Printf("%-20s %-12s %-13s %-9s %8.2f%% %8.1f%% %8.1fx %7.1fx %s\n",
+				status.WorkflowName, status.StepID, objective.Objective, target,
+				objective.AttainmentPct, objective.ErrorBudgetRemainingPct, objective.BurnRate1h, objective.BurnRate6h, alert)
+		}
+	}
+	return nil
+}
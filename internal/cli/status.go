@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +21,8 @@ func init() {
 	statusCmd.Flags().BoolP("services", "s", false, "Show service status")
 	statusCmd.Flags().BoolP("metrics", "m", false, "Show system metrics")
 	statusCmd.Flags().BoolP("quotas", "q", false, "Show quota status")
+	statusCmd.Flags().BoolP("regions", "r", false, "Show worker capacity and backlog per region")
+	statusCmd.Flags().Bool("maintenance", false, "Show queues in maintenance mode")
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
@@ -27,6 +30,8 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	showServices, _ := cmd.Flags().GetBool("services")
 	showMetrics, _ := cmd.Flags().GetBool("metrics")
 	showQuotas, _ := cmd.Flags().GetBool("quotas")
+	showRegions, _ := cmd.Flags().GetBool("regions")
+	showMaintenance, _ := cmd.Flags().GetBool("maintenance")
 
 	// Mock system status data
 	status := map[string]interface{}{
@@ -112,6 +117,16 @@ func runStatus(cmd *cobra.Command, args []string) error {
 				"status":      "healthy",
 			},
 		},
+		// Mock regions - in production would call aor.ControlPlane.RegionStatuses
+		"regions": []aor.RegionStatus{
+			{Region: "eu-west-1", Workers: 3, Capacity: 30, Active: 12, Backlog: 2},
+			{Region: "us-east-1", Workers: 2, Capacity: 20, Active: 18, Backlog: 7},
+		},
+		// Mock holds - in production would call aor.ControlPlane.GetMaintenance
+		"maintenance": []aor.MaintenanceStatus{
+			{MaintenanceHold: aor.MaintenanceHold{MaintenanceTarget: aor.MaintenanceTarget{Scope: aor.MaintenanceScopeLane, Lane: aor.LaneBatch},
+				Reason: "ClickHouse upgrade", StartedAt: time.Now().Add(-12 * time.Minute)}, Queued: 318, InFlight: 4},
+		},
 		"alerts": []map[string]interface{}{
 			{
 				"severity":  "warning",
@@ -207,6 +222,32 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if showRegions || output == "detailed" {
+		fmt.Println("\nWorker Regions:")
+		fmt.Println("---------------")
+		fmt.Printf("%-12s %-8s %-9s %-7s %-8s\n", "REGION", "WORKERS", "CAPACITY", "ACTIVE", "BACKLOG")
+		for _, region := range status["regions"].([]aor.RegionStatus) {
+			fmt.Printf("%-12s %-8d %-9d %-7d %-8d\n", region.Region, region.Workers, region.Capacity, region.Active, region.Backlog)
+		}
+	}
+
+	holds := status["maintenance"].([]aor.MaintenanceStatus)
+	if showMaintenance || output == "detailed" || len(holds) > 0 {
+		fmt.Println("\nMaintenance:")
+		fmt.Println("------------")
+		if len(holds) == 0 {
+			fmt.Println("No queues in maintenance")
+		}
+		for _, hold := range holds {
+			drain := fmt.Sprintf("draining, %d in flight", hold.InFlight)
+			if hold.Drained {
+				drain = "drained"
+			}
+			fmt.Printf("%s paused %s ago (%s), %d queued, %s\n", hold.MaintenanceTarget,
+				time.Since(hold.StartedAt).Truncate(time.Minute), hold.Reason, hold.Queued, drain)
+		}
+	}
+
 	// Show alerts
 	alerts := status["alerts"].([]map[string]interface{})
 	if len(alerts) > 0 {
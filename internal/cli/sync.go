@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync workflow specs and prompts from Git",
+	Long: `The control plane can watch a Git branch for workflow specs and prompt
+templates and apply each new commit, recording the commit SHA on every spec
+version it creates. Use validate in CI to check a branch before merging.`,
+}
+
+var syncValidateCmd = &cobra.Command{
+	Use:   "validate [repo-dir]",
+	Short: "Validate the workflow specs and prompts a sync would apply",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runSyncValidate,
+}
+
+var syncStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the last synced commit",
+	RunE:  runSyncStatus,
+}
+
+var syncTriggerCmd = &cobra.Command{
+	Use:   "trigger",
+	Short: "Sync now instead of waiting for the next poll",
+	RunE:  runSyncTrigger,
+}
+
+func init() {
+	syncValidateCmd.Flags().String("workflows-dir", "workflows", "Directory of workflow specs")
+	syncValidateCmd.Flags().String("prompts-dir", "prompts", "Directory of prompt templates")
+
+	syncCmd.AddCommand(syncValidateCmd)
+	syncCmd.AddCommand(syncStatusCmd)
+	syncCmd.AddCommand(syncTriggerCmd)
+}
+
+func runSyncValidate(cmd *cobra.Command, args []string) error {
+	root := "."
+	if len(args) > 0 {
+		root = args[0]
+	}
+	workflowsDir, _ := cmd.Flags().GetString("workflows-dir")
+	promptsDir, _ := cmd.Flags().GetString("prompts-dir")
+
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", root)
+	}
+
+	bundle, err := aor.LoadSyncBundle(root, workflowsDir, promptsDir, nil)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[aor.ResourceKind]int)
+	for _, resource := range bundle.Resources {
+		counts[resource.Kind]++
+	}
+	fmt.Printf("OK: %d workflows, %d prompts\n", counts[aor.ResourceKindWorkflow], counts[aor.ResourceKindPrompt])
+	return nil
+}
+
+func runSyncStatus(cmd *cobra.Command, args []string) error {
+	// Mock status - in production would call aor.ControlPlane.GitSyncStatus
+	fmt.Println("Repository: https://github.com/acme/agentflow-config@main")
+	fmt.Println("Commit:     3f9c2a1b7e4d")
+	fmt.Println("Synced at:  2024-01-15 10:30:00")
+	fmt.Println("Last apply: 1 created, 2 updated, 0 deleted, 9 unchanged")
+
+	return nil
+}
+
+func runSyncTrigger(cmd *cobra.Command, args []string) error {
+	// Mock trigger - in production would call aor.ControlPlane.TriggerGitSync
+	fmt.Println("Synced commit 3f9c2a1b7e4d: 0 created, 1 updated, 0 deleted, 11 unchanged")
+
+	return nil
+}
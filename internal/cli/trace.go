@@ -3,8 +3,13 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aos"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -48,7 +53,104 @@ var traceAnalyzeCmd = &cobra.Command{
 	RunE:  runTraceAnalyze,
 }
 
+var traceImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Backfill historical provider usage",
+	Long:  "Import provider usage exports (OpenAI usage CSV, Anthropic console export) into the trace and cost store",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTraceImport,
+}
+
+var traceCaptureCmd = &cobra.Command{
+	Use:   "capture",
+	Short: "Show or set trace payload PII scrubbing",
+	Long:  "Control how prompts and outputs are scrubbed of PII before traces are persisted, or disable payload capture entirely",
+	RunE:  runTraceCapture,
+}
+
+var traceExportCmd = &cobra.Command{
+	Use:   "export [run-id]",
+	Short: "Export a run's trace, or archive aged traces to Parquet",
+	Long: `Export a run's model calls for offline analysis or fine-tuning dataset curation, e.g.
+  agentctl trace export <run-id> --format jsonl -o run.jsonl
+  agentctl trace export <run-id> --format ipynb -o run.ipynb
+
+The start, status and ddl subcommands export trace events older than the
+retention window to org/date partitioned Parquet files and register them with
+Athena or DuckDB.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runTraceExportRun,
+}
+
+var traceExportStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start a trace export",
+	RunE:  runTraceExportStart,
+}
+
+var traceExportStatusCmd = &cobra.Command{
+	Use:   "status [export-id]",
+	Short: "Show the progress of a trace export",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTraceExportStatus,
+}
+
+var traceFeedbackCmd = &cobra.Command{
+	Use:   "feedback [run-id] [step-id]",
+	Short: "Rate a run or model call, optionally with a corrected output",
+	Long: `Give a run or one of its model calls a thumbs up or down, or record an eval score for a call.
+Omit the step ID to rate the whole run. A correction is the output the step should have
+produced; it feeds fine-tuning datasets and prompt eval suites. Ratings of a step are also
+used as a routing reward for the step's provider and model.
+
+e.g. agentctl trace feedback <run-id> <step-id> --reject --correction-file fixed.txt`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runTraceFeedback,
+}
+
+var traceRefusalsCmd = &cobra.Command{
+	Use:   "refusals",
+	Short: "Show refusal and content filter rates per prompt version",
+	RunE:  runTraceRefusals,
+}
+
+var traceExportDDLCmd = &cobra.Command{
+	Use:   "ddl",
+	Short: "Print statements that register archived traces with a query engine",
+	RunE:  runTraceExportDDL,
+}
+
 func init() {
+	// Import command flags
+	traceCaptureCmd.Flags().String("scrub-level", "", "Scrub level (off, standard, strict)")
+	traceCaptureCmd.Flags().Bool("payloads", true, "Capture prompt and output payloads")
+	traceCaptureCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	traceExportStartCmd.Flags().String("from", "", "First day to export (YYYY-MM-DD)")
+	traceExportStartCmd.Flags().String("to", "", "Day after the last day to export (YYYY-MM-DD)")
+	traceExportStatusCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	traceExportDDLCmd.Flags().StringP("engine", "e", aos.ArchiveEngineAthena, "Query engine (athena, duckdb)")
+	traceExportDDLCmd.Flags().String("location", "s3://agentflow-artifacts/traces", "Archive root location")
+	traceExportCmd.Flags().StringP("format", "f", string(aos.RunExportJSONL), "Export format (jsonl, ipynb)")
+	traceExportCmd.Flags().StringP("output", "o", "", "Write to this file instead of stdout")
+	traceExportCmd.AddCommand(traceExportStartCmd)
+	traceExportCmd.AddCommand(traceExportStatusCmd)
+	traceExportCmd.AddCommand(traceExportDDLCmd)
+
+	traceFeedbackCmd.Flags().Bool("approve", false, "Thumbs up: approve the output as a good example")
+	traceFeedbackCmd.Flags().Bool("reject", false, "Thumbs down: reject the output so it is never used for training")
+	traceFeedbackCmd.Flags().Float64("eval-score", -1, "Record an eval score between 0 and 1 instead of a rating")
+	traceFeedbackCmd.Flags().String("correction", "", "The output the step should have produced")
+	traceFeedbackCmd.Flags().String("correction-file", "", "Read the corrected output from a file")
+	traceFeedbackCmd.Flags().String("comment", "", "Reason for the rating")
+
+	traceRefusalsCmd.Flags().StringP("start", "s", "24h", "Window to report (duration ago)")
+	traceRefusalsCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
+	traceImportCmd.Flags().StringP("format", "f", "openai", "Export format (openai, anthropic)")
+	traceImportCmd.Flags().BoolP("dry-run", "d", false, "Parse and summarize without importing")
+	traceImportCmd.Flags().StringP("output", "o", "summary", "Output format (summary, json)")
+
 	// Get command flags
 	traceGetCmd.Flags().StringP("output", "o", "table", "Output format (table, json, timeline)")
 	traceGetCmd.Flags().StringP("filter", "f", "", "Filter events by type")
@@ -60,10 +162,11 @@ func init() {
 	traceQueryCmd.Flags().StringP("event-type", "t", "", "Filter by event type")
 	traceQueryCmd.Flags().StringP("provider", "p", "", "Filter by provider")
 	traceQueryCmd.Flags().StringP("model", "m", "", "Filter by model")
-	traceQueryCmd.Flags().IntP("limit", "l", 100, "Maximum number of events")
+	addListFlags(traceQueryCmd, 100, "event_type=error,cost_cents>=10")
+	traceQueryCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
 
 	// Replay command flags
-	traceReplayCmd.Flags().StringP("mode", "m", "shadow", "Replay mode (shadow, live, debug)")
+	traceReplayCmd.Flags().StringP("mode", "m", "shadow", "Replay mode (shadow, live, debug, deterministic)")
 	traceReplayCmd.Flags().StringSliceP("steps", "s", nil, "Specific steps to replay")
 	traceReplayCmd.Flags().BoolP("dry-run", "d", false, "Dry run mode")
 	traceReplayCmd.Flags().BoolP("wait", "w", false, "Wait for replay completion")
@@ -86,6 +189,11 @@ func init() {
 	traceCmd.AddCommand(traceReplayCmd)
 	traceCmd.AddCommand(traceDiffCmd)
 	traceCmd.AddCommand(traceAnalyzeCmd)
+	traceCmd.AddCommand(traceImportCmd)
+	traceCmd.AddCommand(traceCaptureCmd)
+	traceCmd.AddCommand(traceExportCmd)
+	traceCmd.AddCommand(traceFeedbackCmd)
+	traceCmd.AddCommand(traceRefusalsCmd)
 }
 
 func runTraceGet(cmd *cobra.Command, args []string) error {
@@ -215,7 +323,25 @@ func runTraceQuery(cmd *cobra.Command, args []string) error {
 	eventType, _ := cmd.Flags().GetString("event-type")
 	provider, _ := cmd.Flags().GetString("provider")
 	model, _ := cmd.Flags().GetString("model")
-	limit, _ := cmd.Flags().GetInt("limit")
+	output, _ := cmd.Flags().GetString("output")
+	opts := listOptionsFromFlags(cmd)
+
+	// Mock query results - in production would call aos.Service.QueryTrace
+	events := make([]aos.TraceEvent, 0, 5)
+	for i := 0; i < 5; i++ {
+		events = append(events, aos.TraceEvent{
+			Timestamp: time.Now().Add(-time.Duration(i*10) * time.Minute),
+			EventType: aos.EventTypeModelIO,
+			Provider:  "openai",
+			Model:     "gpt-4",
+			CostCents: 75,
+		})
+	}
+	meta := db.PageMeta{TotalEstimate: 25, NextCursor: db.EncodeOffsetCursor(len(events))}
+
+	if output == "json" {
+		return printListJSON("events", events, meta, opts.Fields)
+	}
 
 	fmt.Printf("Querying traces:\n")
 	fmt.Printf("  Time range: %s to %s\n", start, end)
@@ -228,24 +354,27 @@ func runTraceQuery(cmd *cobra.Command, args []string) error {
 	if model != "" {
 		fmt.Printf("  Model: %s\n", model)
 	}
-	fmt.Printf("  Limit: %d\n", limit)
+	if opts.Filter != "" {
+		fmt.Printf("  Filter: %s\n", opts.Filter)
+	}
+	fmt.Printf("  Limit: %d\n", opts.Limit)
 
-	// Mock query results
-	fmt.Printf("\nFound 25 matching events:\n")
+	fmt.Printf("\nFound %d matching events:\n", meta.TotalEstimate)
 	fmt.Printf("%-20s %-12s %-15s %-10s %-10s\n", "TIMESTAMP", "EVENT", "PROVIDER", "MODEL", "COST")
 	fmt.Println("------------------------------------------------------------------------")
 
-	for i := 0; i < 5; i++ {
-		timestamp := time.Now().Add(-time.Duration(i*10) * time.Minute)
-		fmt.Printf("%-20s %-12s %-15s %-10s %-10s\n",
-			timestamp.Format("2006-01-02 15:04:05"),
-			"model_io",
-			"openai",
-			"gpt-4",
-			"$0.75",
+	for _, event := range events {
+		fmt.Printf("%-20s %-12s %-15s %-10s $%-9.2f\n",
+			event.Timestamp.Format("2006-01-02 15:04:05"),
+			event.EventType,
+			event.Provider,
+			event.Model,
+			float64(event.CostCents)/100,
 		)
 	}
 
+	printPageFooter(len(events), meta)
+
 	fmt.Printf("\nSummary:\n")
 	fmt.Printf("  Total events: 25\n")
 	fmt.Printf("  Total cost: $18.75\n")
@@ -264,6 +393,9 @@ func runTraceReplay(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Replaying workflow run: %s\n", runID)
 	fmt.Printf("Mode: %s\n", mode)
+	if mode == "deterministic" {
+		fmt.Printf("LLM steps reuse recorded provider responses; no providers will be called\n")
+	}
 	if len(steps) > 0 {
 		fmt.Printf("Steps: %v\n", steps)
 	}
@@ -468,3 +600,362 @@ func runTraceAnalyze(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runTraceImport(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	format, _ := cmd.Flags().GetString("format")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	output, _ := cmd.Flags().GetString("output")
+
+	if err := validateFilePath(path); err != nil {
+		return fmt.Errorf("invalid file path: %w", err)
+	}
+
+	file, err := os.Open(path) // #nosec G304 - path validated above
+	if err != nil {
+		return fmt.Errorf("failed to open usage export: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	_, result, err := aos.ParseUsageExport(file, aos.UsageExportFormat(format))
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		outputBytes, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format JSON: %w", err)
+		}
+		fmt.Println(string(outputBytes))
+	} else {
+		fmt.Printf("Usage export: %s (%s)\n", path, format)
+		fmt.Printf("  Rows: %d\n", result.Rows)
+		fmt.Printf("  Importable: %d\n", result.Imported)
+		fmt.Printf("  Skipped: %d\n", result.Skipped)
+		fmt.Printf("  Total cost: $%.2f\n", float64(result.TotalCostCents)/100)
+		fmt.Printf("  Total tokens: %d\n", result.TotalTokens)
+		if result.Imported > 0 {
+			fmt.Printf("  Period: %s to %s\n", result.StartTime.Format("2006-01-02"), result.EndTime.Format("2006-01-02"))
+		}
+		for _, e := range result.Errors {
+			fmt.Printf("  Warning: %s\n", e)
+		}
+	}
+
+	if dryRun {
+		fmt.Println("\nDry run: no data imported")
+		return nil
+	}
+
+	// Mock upload - in production would POST the file to the import endpoint
+	fmt.Printf("\nImported %d usage records as backfill events\n", result.Imported)
+	return nil
+}
+
+func runTraceCapture(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock settings - in production would call the payload capture endpoint
+	settings := aos.PayloadCaptureSettings{ScrubLevel: scl.ScrubLevelStandard, CapturePayloads: true}
+
+	changed := false
+	if cmd.Flags().Changed("scrub-level") {
+		value, _ := cmd.Flags().GetString("scrub-level")
+		level, err := scl.ParseScrubLevel(value)
+		if err != nil {
+			return err
+		}
+		settings.ScrubLevel = level
+		changed = true
+	}
+	if cmd.Flags().Changed("payloads") {
+		settings.CapturePayloads, _ = cmd.Flags().GetBool("payloads")
+		changed = true
+	}
+	if changed {
+		settings.UpdatedAt = time.Now()
+	}
+
+	if output == "json" {
+		outputBytes, err := json.MarshalIndent(settings, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format JSON: %w", err)
+		}
+		fmt.Println(string(outputBytes))
+		return nil
+	}
+
+	if changed {
+		fmt.Println("Trace payload capture updated")
+	}
+	capture := "enabled"
+	if !settings.CapturePayloads {
+		capture = "disabled (only metadata is stored)"
+	}
+	fmt.Printf("  Payload capture: %s\n", capture)
+	fmt.Printf("  Scrub level: %s\n", settings.ScrubLevel)
+	return nil
+}
+
+func runTraceExportRun(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmd.Help()
+	}
+	runID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %w", err)
+	}
+	format, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+
+	exportFormat, err := aos.ParseRunExportFormat(format)
+	if err != nil {
+		return err
+	}
+
+	// Mock trace - in production would call aos.Service.ExportRunTrace
+	stepID := uuid.New()
+	events := []aos.TraceEvent{
+		{
+			RunID:            runID,
+			StepID:           stepID,
+			Timestamp:        time.Now().Add(-4*time.Minute - 30*time.Second),
+			EventType:        aos.EventTypeModelIO,
+			Payload:          map[string]interface{}{"prompt": "Summarize the attached document.", "output": "The document describes...", "step_name": "llm_analysis"},
+			CostCents:        75,
+			TokensPrompt:     150,
+			TokensCompletion: 200,
+			Provider:         "openai",
+			Model:            "gpt-4",
+			LatencyMs:        24000,
+		},
+	}
+
+	w := os.Stdout
+	if output != "" {
+		if err := validateFilePath(output); err != nil {
+			return fmt.Errorf("invalid output path: %w", err)
+		}
+		file, err := os.Create(output) // #nosec G304 - path validated above
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer func() { _ = file.Close() }()
+		w = file
+	}
+
+	if err := aos.WriteRunExport(w, runID, events, exportFormat); err != nil {
+		return err
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d model calls to %s\n", len(events), output)
+	}
+	return nil
+}
+
+func runTraceFeedback(cmd *cobra.Command, args []string) error {
+	runID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %w", err)
+	}
+	var stepID *uuid.UUID
+	if len(args) == 2 {
+		id, err := uuid.Parse(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid step ID: %w", err)
+		}
+		stepID = &id
+	}
+	approve, _ := cmd.Flags().GetBool("approve")
+	reject, _ := cmd.Flags().GetBool("reject")
+	evalScore, _ := cmd.Flags().GetFloat64("eval-score")
+	correction, _ := cmd.Flags().GetString("correction")
+	correctionFile, _ := cmd.Flags().GetString("correction-file")
+	comment, _ := cmd.Flags().GetString("comment")
+
+	if correctionFile != "" {
+		if correction != "" {
+			return fmt.Errorf("use only one of --correction and --correction-file")
+		}
+		if err := validateFilePath(correctionFile); err != nil {
+			return fmt.Errorf("invalid correction file: %w", err)
+		}
+		data, err := os.ReadFile(correctionFile) // #nosec G304 - path validated above
+		if err != nil {
+			return fmt.Errorf("failed to read correction file: %w", err)
+		}
+		correction = string(data)
+	}
+
+	var feedback *aos.TraceFeedback
+	switch {
+	case (approve || reject) && approve != reject && evalScore < 0:
+		rating := aos.RatingUp
+		if reject {
+			rating = aos.RatingDown
+		}
+		feedback, err = aos.NewFeedback(&aos.FeedbackRequest{
+			RunID:      runID,
+			StepID:     stepID,
+			Rating:     rating,
+			Correction: correction,
+			Comment:    comment,
+		})
+		if err != nil {
+			return err
+		}
+	case !approve && !reject && evalScore >= 0:
+		if stepID == nil {
+			return fmt.Errorf("eval scores apply to a step, not a whole run")
+		}
+		feedback = &aos.TraceFeedback{RunID: runID, StepID: *stepID, Source: aos.FeedbackEval, Score: evalScore,
+			Correction: correction, Comment: comment}
+		if err := aos.ValidateFeedback(feedback); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("use exactly one of --approve, --reject or --eval-score")
+	}
+
+	// Mock feedback - in production would call aos.Service.SubmitFeedback and
+	// forward step ratings to cas.Service.RecordFeedback
+	target := fmt.Sprintf("run %s", runID)
+	if stepID != nil {
+		target = fmt.Sprintf("step %s of run %s", *stepID, runID)
+	}
+	fmt.Printf("Recorded %s feedback %.2f for %s\n", feedback.Source, feedback.Score, target)
+	if feedback.Correction != "" {
+		fmt.Printf("Stored a %d-character correction\n", len(feedback.Correction))
+	}
+	return nil
+}
+
+func runTraceExportStart(cmd *cobra.Command, args []string) error {
+	from, _ := cmd.Flags().GetString("from")
+	to, _ := cmd.Flags().GetString("to")
+
+	req := aos.TraceExportRequest{}
+	for _, f := range []struct {
+		value  string
+		target *time.Time
+	}{{from, &req.StartDate}, {to, &req.EndDate}} {
+		if f.value == "" {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", f.value)
+		if err != nil {
+			return fmt.Errorf("invalid date %q: %w", f.value, err)
+		}
+		*f.target = day
+	}
+	if !req.StartDate.IsZero() && !req.EndDate.IsZero() && !req.EndDate.After(req.StartDate) {
+		return fmt.Errorf("--to must be after --from")
+	}
+
+	// Mock export - in production would call the trace export endpoint
+	exportID := uuid.New()
+	fmt.Printf("Trace export started: %s\n", exportID)
+	fmt.Printf("  Status: %s\n", aos.TraceExportPending)
+	fmt.Printf("Use 'agentctl trace export status %s' to follow progress\n", exportID)
+	return nil
+}
+
+func runTraceExportStatus(cmd *cobra.Command, args []string) error {
+	exportID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid export ID: %w", err)
+	}
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock status - in production would call the trace export endpoint
+	completed := time.Now()
+	export := aos.TraceExport{
+		ID:           exportID,
+		StartDate:    time.Now().AddDate(0, 0, -32).UTC().Truncate(24 * time.Hour),
+		EndDate:      time.Now().AddDate(0, 0, -30).UTC().Truncate(24 * time.Hour),
+		Status:       aos.TraceExportSucceeded,
+		Location:     "s3://agentflow-artifacts/traces",
+		RowsExported: 18432,
+		CompletedAt:  &completed,
+	}
+	for day := export.StartDate; day.Before(export.EndDate); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		export.Partitions = append(export.Partitions, aos.TraceExportPartition{
+			Date: date,
+			Path: fmt.Sprintf("traces/org_id=%s/dt=%s/traces.parquet", uuid.Nil, date),
+			Rows: export.RowsExported / 2,
+		})
+	}
+
+	if output == "json" {
+		outputBytes, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format JSON: %w", err)
+		}
+		fmt.Println(string(outputBytes))
+		return nil
+	}
+
+	fmt.Printf("Trace export %s\n", export.ID)
+	fmt.Printf("  Status: %s\n", export.Status)
+	fmt.Printf("  Range: %s to %s\n", export.StartDate.Format("2006-01-02"), export.EndDate.Format("2006-01-02"))
+	fmt.Printf("  Location: %s\n", export.Location)
+	fmt.Printf("  Rows exported: %d\n", export.RowsExported)
+	for _, partition := range export.Partitions {
+		fmt.Printf("  %s  %8d rows  %s\n", partition.Date, partition.Rows, partition.Path)
+	}
+	return nil
+}
+
+func runTraceExportDDL(cmd *cobra.Command, args []string) error {
+	engine, _ := cmd.Flags().GetString("engine")
+	location, _ := cmd.Flags().GetString("location")
+
+	// Mock partitions - in production would come from the org's completed exports
+	statements, err := aos.TraceArchiveDDL(engine, location, uuid.Nil, nil)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		fmt.Printf("%s;\n\n", statement)
+	}
+	return nil
+}
+
+func runTraceRefusals(cmd *cobra.Command, args []string) error {
+	start, _ := cmd.Flags().GetString("start")
+	output, _ := cmd.Flags().GetString("output")
+
+	window, err := time.ParseDuration(start)
+	if err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+
+	// Mock rates - in production would call the refusal rates endpoint
+	rates := []aos.RefusalRate{
+		{PromptRef: "document_analyzer", PromptVersion: 3, Calls: 1240, SafetyRefusals: 4, PolicyBlocks: 1, LengthStops: 22},
+		{PromptRef: "document_analyzer", PromptVersion: 4, Calls: 860, SafetyRefusals: 31, PolicyBlocks: 9, LengthStops: 3},
+		{PromptRef: "support_reply", PromptVersion: 7, Calls: 3105, SafetyRefusals: 2, PolicyBlocks: 0, LengthStops: 41},
+	}
+	for i := range rates {
+		rates[i].RefusalPct = float64(rates[i].SafetyRefusals+rates[i].PolicyBlocks) / float64(rates[i].Calls) * 100
+	}
+
+	if output == "json" {
+		outputBytes, err := json.MarshalIndent(rates, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format JSON: %w", err)
+		}
+		fmt.Println(string(outputBytes))
+		return nil
+	}
+
+	fmt.Printf("Refusal rates over the last %s\n\n", window)
+	fmt.Printf("%-20s %-8s %8s %8s %8s %8s %9s\n", "PROMPT", "VERSION", "CALLS", "REFUSED", "BLOCKED", "LENGTH", "REFUSAL%")
+	for _, rate := range rates {
+		fmt.Printf("%-20s %-8d %8d %8d %8d %8d %8.2f%%\n",
+			rate.PromptRef, rate.PromptVersion, rate.Calls, rate.SafetyRefusals, rate.PolicyBlocks, rate.LengthStops, rate.RefusalPct)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var workflowWebhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Manage webhooks that trigger workflows",
+	Long: `Workflows declare webhooks in metadata.webhooks, mapping payload fields to inputs:
+  webhooks:
+    - name: github-push
+      inputs:
+        repo: $.repository.full_name
+        commits: $.commits[*].id
+Deliveries are signed like run callbacks: X-AgentFlow-Signature carries
+sha256=HMAC-SHA256(secret, "<X-AgentFlow-Timestamp>.<body>"). Deliveries
+sending an X-AgentFlow-Delivery ID, which repeats are ignored by, sign
+"<X-AgentFlow-Timestamp>.<X-AgentFlow-Delivery>.<body>" instead.`,
+}
+
+var workflowWebhookListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered webhooks",
+	RunE:  runWorkflowWebhookList,
+}
+
+var workflowWebhookSecretCmd = &cobra.Command{
+	Use:   "secret [webhook-name]",
+	Short: "Set or rotate a webhook's signing secret",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runWorkflowWebhookSecret,
+}
+
+func init() {
+	workflowWebhookListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	workflowWebhookSecretCmd.Flags().String("secret", "", "Secret to use (generated when empty)")
+
+	workflowWebhookCmd.AddCommand(workflowWebhookListCmd)
+	workflowWebhookCmd.AddCommand(workflowWebhookSecretCmd)
+	workflowCmd.AddCommand(workflowWebhookCmd)
+}
+
+func runWorkflowWebhookList(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock webhooks - in production would call aor.ControlPlane.ListWebhooks
+	rotated := time.Now().Add(-48 * time.Hour)
+	webhooks := []aor.Webhook{
+		{OrgID: uuid.New(), Name: "github-push", WorkflowName: "code_review", HasSecret: true,
+			CreatedAt: time.Now().Add(-720 * time.Hour), RotatedAt: &rotated},
+		{OrgID: uuid.New(), Name: "zendesk-ticket", WorkflowName: "ticket_triage", CreatedAt: time.Now().Add(-2 * time.Hour)},
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(webhooks, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal webhooks: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-20s %-20s %-8s %s\n", "NAME", "WORKFLOW", "SECRET", "ROTATED")
+	for _, webhook := range webhooks {
+		secret, rotated := "unset", "-"
+		if webhook.HasSecret {
+			secret = "set"
+		}
+		if webhook.RotatedAt != nil {
+			rotated = webhook.RotatedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%-20s %-20s %-8s %s\n", webhook.Name, webhook.WorkflowName, secret, rotated)
+	}
+	return nil
+}
+
+func runWorkflowWebhookSecret(cmd *cobra.Command, args []string) error {
+	secret, _ := cmd.Flags().GetString("secret")
+
+	// Mock rotation - in production would call aor.ControlPlane.SetWebhookSecret
+	if secret == "" {
+		secret = "whsec_" + uuid.New().String()
+	}
+	fmt.Printf("Secret of webhook %s set; deliveries signed with the old secret are rejected\n", args[0])
+	fmt.Printf("Secret: %s\n", secret)
+	return nil
+}
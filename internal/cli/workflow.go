@@ -1,11 +1,21 @@
 package cli
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
+	"mime"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/aor"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/cas"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -42,6 +52,21 @@ var workflowCancelCmd = &cobra.Command{
 	RunE:  runWorkflowCancel,
 }
 
+var workflowPauseCmd = &cobra.Command{
+	Use:   "pause [run-id]",
+	Short: "Pause a workflow run",
+	Long:  "Hold a run's ready steps, e.g. while a provider is down; steps already executing finish and keep their results",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runWorkflowPause,
+}
+
+var workflowResumeCmd = &cobra.Command{
+	Use:   "resume [run-id]",
+	Short: "Resume a paused workflow run from the steps held while it was paused",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runWorkflowResume,
+}
+
 var workflowLogsCmd = &cobra.Command{
 	Use:   "logs [run-id]",
 	Short: "Get workflow run logs",
@@ -49,31 +74,108 @@ var workflowLogsCmd = &cobra.Command{
 	RunE:  runWorkflowLogs,
 }
 
+var workflowRetryCmd = &cobra.Command{
+	Use:   "retry [run-id] [step-id]",
+	Short: "Retry a step of a workflow run",
+	Long:  "Create a new attempt of a step, optionally overriding its inputs or config, and resume downstream execution",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runWorkflowRetry,
+}
+
+var workflowLintCmd = &cobra.Command{
+	Use:   "lint [spec-file]",
+	Short: "Check a workflow spec for anti-patterns",
+	Long:  "Flag LLM steps without retries, missing timeouts, unbounded fan-out, Gold-tier classification, inlined secrets and missing budget hints",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runWorkflowLint,
+}
+
+var workflowEstimateCmd = &cobra.Command{
+	Use:   "estimate [spec-file]",
+	Short: "Estimate the cost of one run of a workflow spec",
+	Long:  "Project run cost up front, including best-of-N sampling, ensemble fan-out and judge calls",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runWorkflowEstimate,
+}
+
+var workflowExpandCmd = &cobra.Command{
+	Use:   "expand [spec-file]",
+	Short: "Print a workflow spec with macros and YAML anchors expanded",
+	Long:  "Show the spec exactly as it is stored and run, to debug macro and anchor expansion",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runWorkflowExpand,
+}
+
+var workflowTimeoutsCmd = &cobra.Command{
+	Use:   "timeouts [workflow-name]",
+	Short: "Suggest step timeouts from recent step durations",
+	Long:  "Tune each step's timeout to its p99 duration with headroom, flagging timeouts that cut off healthy attempts or let hung steps hold workers",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runWorkflowTimeouts,
+}
+
 func init() {
 	// Submit command flags
 	workflowSubmitCmd.Flags().StringP("version", "v", "", "Workflow version (default: latest)")
 	workflowSubmitCmd.Flags().StringP("inputs", "i", "{}", "Input parameters as JSON")
 	workflowSubmitCmd.Flags().StringP("inputs-file", "f", "", "Input parameters from file")
+	workflowSubmitCmd.Flags().StringToString("media", nil, "Image, file or audio inputs as name=path, e.g. screenshot=./error.png")
 	workflowSubmitCmd.Flags().Int64P("budget", "b", 0, "Budget limit in cents")
+	workflowSubmitCmd.Flags().Int64("max-cost", 0, "Fail submission if the estimated run cost exceeds this many cents")
 	workflowSubmitCmd.Flags().StringToStringP("tags", "t", nil, "Tags as key=value pairs")
+	workflowSubmitCmd.Flags().StringToStringP("label", "l", nil, "Cost allocation labels as key=value pairs, e.g. team=search,customer_id=acme")
+	workflowSubmitCmd.Flags().StringP("env", "e", "", "Environment profile from the spec (e.g. dev, staging, prod)")
+	workflowSubmitCmd.Flags().Bool("reuse-steps", false, "Reuse outputs of steps whose config and inputs match an earlier run")
+	workflowSubmitCmd.Flags().Bool("reproducible", false, "Pin seeds, temperatures and models of LLM steps so the run can be reproduced")
+	workflowSubmitCmd.Flags().Int64("seed", 0, "Run seed for a reproducible run (default random)")
+	workflowSubmitCmd.Flags().StringSlice("chaos", nil, "Inject faults as [step_type:]fault=rate, e.g. llm:provider_error=0.2 (requires chaos mode)")
+	workflowSubmitCmd.Flags().String("lane", "", "Scheduling lane (interactive, batch, background); defaults to the spec's lane")
+	workflowSubmitCmd.Flags().String("callback-url", "", "URL to POST the signed run result to when the run finishes")
+	workflowSubmitCmd.Flags().String("callback-redact", "off", "PII scrubbing applied to callback outputs (off, standard, strict)")
 	workflowSubmitCmd.Flags().BoolP("wait", "w", false, "Wait for completion")
 	workflowSubmitCmd.Flags().DurationP("timeout", "", 30*time.Minute, "Wait timeout")
 
 	// List command flags
 	workflowListCmd.Flags().StringP("status", "s", "", "Filter by status")
-	workflowListCmd.Flags().IntP("limit", "l", 20, "Number of results to return")
+	addListFlags(workflowListCmd, 20, "workflow=etl,cost_cents>=100")
+	workflowListCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
 	workflowListCmd.Flags().StringP("since", "", "24h", "Show runs since duration")
 
 	// Logs command flags
 	workflowLogsCmd.Flags().BoolP("follow", "f", false, "Follow log output")
 	workflowLogsCmd.Flags().IntP("tail", "t", 100, "Number of recent log lines")
 
+	// Pause command flags
+	workflowPauseCmd.Flags().StringP("reason", "r", "", "Why the run is paused, shown in its status")
+
+	// Retry command flags
+	workflowRetryCmd.Flags().StringP("inputs", "i", "", "Input overrides as JSON")
+	workflowRetryCmd.Flags().StringP("config", "c", "", "Step config overrides as JSON")
+	workflowRetryCmd.Flags().StringP("reason", "r", "", "Reason for the manual retry")
+
+	// Lint command flags
+	workflowLintCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	workflowEstimateCmd.Flags().StringP("env", "e", "", "Environment profile from the spec")
+	workflowEstimateCmd.Flags().Int64P("budget", "b", 0, "Run budget in cents to check the estimate against")
+	workflowEstimateCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+	workflowLintCmd.Flags().String("fail-on", "error", "Exit non-zero on findings at or above severity (error, warning, info)")
+	workflowExpandCmd.Flags().StringP("output", "o", "yaml", "Output format (yaml, json)")
+	workflowTimeoutsCmd.Flags().Int("version", 0, "Workflow version (default: latest)")
+	workflowTimeoutsCmd.Flags().StringP("output", "o", "table", "Output format (table, json)")
+
 	// Add subcommands
 	workflowCmd.AddCommand(workflowSubmitCmd)
 	workflowCmd.AddCommand(workflowStatusCmd)
 	workflowCmd.AddCommand(workflowListCmd)
 	workflowCmd.AddCommand(workflowCancelCmd)
+	workflowCmd.AddCommand(workflowPauseCmd)
+	workflowCmd.AddCommand(workflowResumeCmd)
 	workflowCmd.AddCommand(workflowLogsCmd)
+	workflowCmd.AddCommand(workflowRetryCmd)
+	workflowCmd.AddCommand(workflowLintCmd)
+	workflowCmd.AddCommand(workflowEstimateCmd)
+	workflowCmd.AddCommand(workflowExpandCmd)
+	workflowCmd.AddCommand(workflowTimeoutsCmd)
 }
 
 func runWorkflowSubmit(cmd *cobra.Command, args []string) error {
@@ -102,9 +204,31 @@ func runWorkflowSubmit(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	media, _ := cmd.Flags().GetStringToString("media")
+	for name, path := range media {
+		part, err := readMediaInput(path)
+		if err != nil {
+			return fmt.Errorf("media input %s: %w", name, err)
+		}
+		if inputs == nil {
+			inputs = make(map[string]interface{})
+		}
+		inputs[name] = part
+	}
+
 	version, _ := cmd.Flags().GetString("version")
 	budget, _ := cmd.Flags().GetInt64("budget")
+	maxCost, _ := cmd.Flags().GetInt64("max-cost")
 	tags, _ := cmd.Flags().GetStringToString("tags")
+	labels, _ := cmd.Flags().GetStringToString("label")
+	environment, _ := cmd.Flags().GetString("env")
+	lane, _ := cmd.Flags().GetString("lane")
+	reuseSteps, _ := cmd.Flags().GetBool("reuse-steps")
+	reproducible, _ := cmd.Flags().GetBool("reproducible")
+	seed, _ := cmd.Flags().GetInt64("seed")
+	chaos, _ := cmd.Flags().GetStringSlice("chaos")
+	callbackURL, _ := cmd.Flags().GetString("callback-url")
+	callbackRedact, _ := cmd.Flags().GetString("callback-redact")
 	wait, _ := cmd.Flags().GetBool("wait")
 	timeout, _ := cmd.Flags().GetDuration("timeout")
 
@@ -127,11 +251,98 @@ func runWorkflowSubmit(cmd *cobra.Command, args []string) error {
 		request["budget_cents"] = budget
 	}
 
+	if maxCost > 0 {
+		request["max_cost_cents"] = maxCost
+	}
+
+	if len(labels) > 0 {
+		if err := cas.ValidateCostLabels(labels); err != nil {
+			return err
+		}
+		request["labels"] = labels
+	}
+
+	if environment != "" {
+		request["environment"] = environment
+	}
+
+	if lane != "" {
+		request["lane"] = lane
+	}
+
+	if reuseSteps {
+		request["reuse_steps"] = true
+	}
+
+	if cmd.Flags().Changed("seed") {
+		reproducible = true
+		request["seed"] = seed
+	}
+	if reproducible {
+		request["reproducible"] = true
+	}
+
+	if len(chaos) > 0 {
+		faults, err := aor.ParseFaultRules(chaos)
+		if err != nil {
+			return err
+		}
+		policy := &aor.ChaosPolicy{Faults: faults}
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+		request["chaos"] = policy
+	}
+
+	if callbackURL != "" {
+		request["callback"] = map[string]string{"url": callbackURL, "redact": callbackRedact}
+	}
+
+	// Mock preview - in production would call aor.ControlPlane.PreviewRun
+	estimate := &aor.RunCostEstimate{WorkflowName: workflowName, Environment: environment, TotalCents: 42}
+	runsAffordable := int64(446)
+	preview := &aor.RunPreview{
+		Estimate: estimate,
+		Budget: &cas.BudgetStatus{LimitCents: 50000, SpentCents: 31250, RemainingCents: 18750,
+			UtilizationPct: 62.5, Status: cas.BudgetStatusHealthy},
+		RunsAffordable: &runsAffordable,
+		MaxCostCents:   maxCost,
+		ExceedsMaxCost: maxCost > 0 && estimate.TotalCents > maxCost,
+	}
+	printRunPreview(preview)
+	if preview.ExceedsMaxCost {
+		return fmt.Errorf("estimated run cost %d¢ exceeds --max-cost %d¢; not submitted", estimate.TotalCents, maxCost)
+	}
+
 	// Submit workflow (mock implementation)
 	runID := "run_" + fmt.Sprintf("%d", time.Now().Unix())
 
 	fmt.Printf("Submitted workflow: %s\n", workflowName)
+	if environment != "" {
+		fmt.Printf("Environment: %s\n", environment)
+	}
 	fmt.Printf("Run ID: %s\n", runID)
+	if lane != "" {
+		fmt.Printf("Lane: %s\n", lane)
+	}
+	if reuseSteps {
+		fmt.Println("Step reuse: unchanged steps are served from earlier runs")
+	}
+	if reproducible {
+		if !cmd.Flags().Changed("seed") {
+			seed = time.Now().UnixNano() & math.MaxInt32
+		}
+		fmt.Printf("Reproducible: seed %d\n", seed)
+	}
+	if len(chaos) > 0 {
+		fmt.Printf("Chaos faults: %s\n", strings.Join(chaos, ", "))
+	}
+	if len(labels) > 0 {
+		fmt.Printf("Cost labels: %s\n", formatTags(labels))
+	}
+	if callbackURL != "" {
+		fmt.Printf("Callback: %s (redaction: %s)\n", callbackURL, callbackRedact)
+	}
 
 	if wait {
 		fmt.Printf("Waiting for completion (timeout: %v)...\n", timeout)
@@ -145,6 +356,18 @@ func runWorkflowSubmit(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// printRunPreview shows the estimated cost of a submission against the org budget
+func printRunPreview(preview *aor.RunPreview) {
+	budget := preview.Budget
+	fmt.Printf("Estimated cost per run: %d¢\n", preview.Estimate.TotalCents)
+	fmt.Printf("Org budget: $%.2f of $%.2f used (%.1f%%, %s)\n",
+		float64(budget.SpentCents)/100, float64(budget.LimitCents)/100, budget.UtilizationPct, budget.Status)
+	if preview.RunsAffordable != nil {
+		fmt.Printf("Runs that fit in the remaining $%.2f: %d\n", float64(max(budget.RemainingCents, 0))/100, *preview.RunsAffordable)
+	}
+	fmt.Println()
+}
+
 func runWorkflowStatus(cmd *cobra.Command, args []string) error {
 	runID := args[0]
 
@@ -159,6 +382,10 @@ func runWorkflowStatus(cmd *cobra.Command, args []string) error {
 			"current_step":    "llm_analysis",
 		},
 		"cost_cents": 150,
+		"sla": map[string]interface{}{
+			"deadline": time.Now().Add(25 * time.Minute).Format(time.RFC3339),
+			"status":   "on_track",
+		},
 		"metadata": map[string]interface{}{
 			"workflow_name": "document_analysis",
 			"version":       1,
@@ -176,10 +403,16 @@ func runWorkflowStatus(cmd *cobra.Command, args []string) error {
 
 func runWorkflowList(cmd *cobra.Command, args []string) error {
 	statusFilter, _ := cmd.Flags().GetString("status")
-	limit, _ := cmd.Flags().GetInt("limit")
 	since, _ := cmd.Flags().GetString("since")
+	output, _ := cmd.Flags().GetString("output")
+	opts := listOptionsFromFlags(cmd)
 
-	fmt.Printf("Listing workflows (status: %s, limit: %d, since: %s)\n", statusFilter, limit, since)
+	if output != "json" {
+		fmt.Printf("Listing workflows (status: %s, limit: %d, since: %s)\n", statusFilter, opts.Limit, since)
+		if opts.Filter != "" {
+			fmt.Printf("Filter: %s\n", opts.Filter)
+		}
+	}
 
 	// Mock workflow list
 	workflows := []map[string]interface{}{
@@ -208,15 +441,24 @@ func runWorkflowList(cmd *cobra.Command, args []string) error {
 		},
 	}
 
+	matched := make([]map[string]interface{}, 0, len(workflows))
+	for _, wf := range workflows {
+		if statusFilter == "" || wf["status"] == statusFilter {
+			matched = append(matched, wf)
+		}
+	}
+	meta := db.PageMeta{TotalEstimate: int64(len(matched))}
+
+	if output == "json" {
+		return printListJSON("runs", matched, meta, opts.Fields)
+	}
+
 	// Print table header
 	fmt.Printf("%-20s %-20s %-12s %-20s %-10s\n", "RUN ID", "WORKFLOW", "STATUS", "STARTED", "COST")
 	fmt.Println("--------------------------------------------------------------------------------")
 
 	// Print workflows
-	for _, wf := range workflows {
-		if statusFilter != "" && wf["status"] != statusFilter {
-			continue
-		}
+	for _, wf := range matched {
 
 		startedAt, _ := time.Parse(time.RFC3339, wf["started_at"].(string))
 		cost := wf["cost_cents"].(int)
@@ -229,6 +471,7 @@ func runWorkflowList(cmd *cobra.Command, args []string) error {
 			float64(cost)/100,
 		)
 	}
+	printPageFooter(len(matched), meta)
 
 	return nil
 }
@@ -245,6 +488,36 @@ func runWorkflowCancel(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runWorkflowPause(cmd *cobra.Command, args []string) error {
+	runID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %w", err)
+	}
+	reason, _ := cmd.Flags().GetString("reason")
+
+	// Mock pause - in production would call aor.ControlPlane.PauseWorkflowRun
+	pause := aor.RunPause{PausedAt: time.Now(), Reason: reason, ResumeStatus: aor.WorkflowStatusRunning}
+	fmt.Printf("Workflow run %s paused at %s\n", runID, pause.PausedAt.Format(time.RFC3339))
+	if pause.Reason != "" {
+		fmt.Printf("  Reason: %s\n", pause.Reason)
+	}
+	fmt.Println("  Steps already executing will finish; resume with 'agentctl workflow resume " + runID.String() + "'")
+	return nil
+}
+
+func runWorkflowResume(cmd *cobra.Command, args []string) error {
+	runID, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %w", err)
+	}
+
+	// Mock resume - in production would call aor.ControlPlane.ResumeWorkflowRun
+	resume := aor.RunResume{RunID: runID, PausedFor: 42 * time.Minute, ResumedTasks: 3}
+	fmt.Printf("Workflow run %s resumed after %s\n", resume.RunID, resume.PausedFor.Round(time.Second))
+	fmt.Printf("  %d held steps queued for execution\n", resume.ResumedTasks)
+	return nil
+}
+
 func runWorkflowLogs(cmd *cobra.Command, args []string) error {
 	runID := args[0]
 	follow, _ := cmd.Flags().GetBool("follow")
@@ -278,3 +551,238 @@ func runWorkflowLogs(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runWorkflowRetry(cmd *cobra.Command, args []string) error {
+	runID := args[0]
+	stepID := args[1]
+
+	inputsStr, _ := cmd.Flags().GetString("inputs")
+	configStr, _ := cmd.Flags().GetString("config")
+	reason, _ := cmd.Flags().GetString("reason")
+
+	request := map[string]interface{}{}
+	if inputsStr != "" {
+		var inputs map[string]interface{}
+		if err := json.Unmarshal([]byte(inputsStr), &inputs); err != nil {
+			return fmt.Errorf("failed to parse inputs: %w", err)
+		}
+		request["inputs"] = inputs
+	}
+	if configStr != "" {
+		var config map[string]interface{}
+		if err := json.Unmarshal([]byte(configStr), &config); err != nil {
+			return fmt.Errorf("failed to parse config: %w", err)
+		}
+		request["config"] = config
+	}
+	if reason != "" {
+		request["reason"] = reason
+	}
+
+	// Retry step (mock implementation)
+	fmt.Printf("Retrying step %s of workflow run %s\n", stepID, runID)
+	if len(request) > 0 {
+		output, err := json.MarshalIndent(request, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format overrides: %w", err)
+		}
+		fmt.Printf("Overrides:\n%s\n", string(output))
+	}
+	fmt.Printf("Step attempt queued; downstream steps resume once it succeeds\n")
+	fmt.Printf("Use 'agentctl workflow status %s' to check progress\n", runID)
+
+	return nil
+}
+
+func runWorkflowLint(cmd *cobra.Command, args []string) error {
+	specFile := args[0]
+	output, _ := cmd.Flags().GetString("output")
+	failOn, _ := cmd.Flags().GetString("fail-on")
+
+	if err := validateFilePath(specFile); err != nil {
+		return fmt.Errorf("invalid file path: %w", err)
+	}
+
+	spec, err := aor.LoadWorkflowSpecFile(specFile)
+	if err != nil {
+		return err
+	}
+
+	report := aor.LintWorkflowSpec(spec)
+
+	if output == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format lint report: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		if len(report.Findings) == 0 {
+			fmt.Printf("No issues found in %s\n", specFile)
+			return nil
+		}
+
+		fmt.Printf("%-8s %-26s %-20s %s\n", "SEVERITY", "RULE", "STEP", "MESSAGE")
+		fmt.Println(strings.Repeat("-", 100))
+		for _, finding := range report.Findings {
+			step := finding.StepID
+			if step == "" {
+				step = "-"
+			}
+			fmt.Printf("%-8s %-26s %-20s %s\n", finding.Severity, finding.Rule, step, finding.Message)
+			fmt.Printf("%-8s %-26s %-20s fix: %s\n", "", "", "", finding.Suggestion)
+		}
+		fmt.Printf("\n%d errors, %d warnings, %d info\n", report.Errors, report.Warnings, report.Infos)
+	}
+
+	if report.Exceeds(aor.LintSeverity(failOn)) {
+		return fmt.Errorf("lint found issues at or above %s severity", failOn)
+	}
+
+	return nil
+}
+
+func runWorkflowEstimate(cmd *cobra.Command, args []string) error {
+	specFile := args[0]
+	environment, _ := cmd.Flags().GetString("env")
+	budget, _ := cmd.Flags().GetInt64("budget")
+	output, _ := cmd.Flags().GetString("output")
+
+	if err := validateFilePath(specFile); err != nil {
+		return fmt.Errorf("invalid file path: %w", err)
+	}
+
+	spec, err := aor.LoadWorkflowSpecFile(specFile)
+	if err != nil {
+		return err
+	}
+	envName, profile, err := spec.ResolveEnvironment(environment)
+	if err != nil {
+		return err
+	}
+	if budget == 0 && profile != nil {
+		budget = profile.BudgetCents
+	}
+
+	// Mock pricing - in production would call the cost estimation endpoint with org pricing
+	estimate := aor.EstimateSpecCost(spec, nil)
+	estimate.Environment = envName
+
+	if output == "json" {
+		data, err := json.MarshalIndent(estimate, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format estimate: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("%-20s %-10s %6s %10s %10s\n", "STEP", "TYPE", "CALLS", "PER CALL", "COST")
+		fmt.Println(strings.Repeat("-", 60))
+		for _, step := range estimate.Steps {
+			fmt.Printf("%-20s %-10s %6d %9d¢ %9d¢\n", step.StepID, step.Type, step.Calls, step.CostCentsPerCall, step.CostCents)
+			for _, note := range step.Notes {
+				fmt.Printf("%-20s %s\n", "", note)
+			}
+		}
+		fmt.Printf("\nEstimated total: %d¢\n", estimate.TotalCents)
+	}
+
+	if budget > 0 && estimate.TotalCents > budget {
+		return fmt.Errorf("estimated cost %d¢ exceeds budget %d¢", estimate.TotalCents, budget)
+	}
+	return nil
+}
+
+func runWorkflowExpand(cmd *cobra.Command, args []string) error {
+	specFile := args[0]
+	output, _ := cmd.Flags().GetString("output")
+
+	if err := validateFilePath(specFile); err != nil {
+		return fmt.Errorf("invalid file path: %w", err)
+	}
+
+	// Stored specs are expanded the same way at submission; aor.ControlPlane.GetWorkflowSpec returns them
+	spec, err := aor.LoadWorkflowSpecFile(specFile)
+	if err != nil {
+		return err
+	}
+	data, err := aor.MarshalWorkflowSpec(spec, output)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+func runWorkflowTimeouts(cmd *cobra.Command, args []string) error {
+	workflowName := args[0]
+	output, _ := cmd.Flags().GetString("output")
+
+	// Mock history - in production would call aor.ControlPlane.SuggestStepTimeouts
+	spec := &aor.WorkflowSpec{Name: workflowName, DAG: aor.DAG{Steps: []aor.Step{
+		{ID: "extract", Type: "llm", Timeout: 10 * time.Second},
+		{ID: "summarize", Type: "llm"},
+		{ID: "publish", Type: "http", Timeout: 30 * time.Minute},
+	}}}
+	suggestions := aor.SuggestSpecTimeouts(spec, map[string]aor.StepDurationStats{
+		"extract":   {StepID: "extract", Samples: 412, P50: 6 * time.Second, P95: 11 * time.Second, P99: 14 * time.Second, Max: 19 * time.Second, TimedOut: 23},
+		"summarize": {StepID: "summarize", Samples: 398, P50: 4 * time.Second, P95: 9 * time.Second, P99: 12 * time.Second, Max: 15 * time.Second},
+		"publish":   {StepID: "publish", Samples: 397, P50: 300 * time.Millisecond, P95: 900 * time.Millisecond, P99: 2 * time.Second, Max: 4 * time.Second},
+	})
+
+	if output == "json" {
+		data, err := json.MarshalIndent(suggestions, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format timeout suggestions: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(suggestions) == 0 {
+		fmt.Printf("Step timeouts of %s match their recent durations\n", workflowName)
+		return nil
+	}
+	fmt.Printf("%-20s %10s %10s %8s %10s %10s\n", "STEP", "CURRENT", "SUGGESTED", "SAMPLES", "P95", "P99")
+	fmt.Println(strings.Repeat("-", 75))
+	for _, s := range suggestions {
+		current := "none"
+		if s.Current > 0 {
+			current = s.Current.String()
+		}
+		fmt.Printf("%-20s %10s %10s %8d %10s %10s\n", s.StepID, current, s.Suggested, s.Stats.Samples,
+			s.Stats.P95.Round(time.Millisecond), s.Stats.P99.Round(time.Millisecond))
+		fmt.Printf("%-20s %s\n", "", s.Reason)
+	}
+	fmt.Println("\nSet these as step timeouts, or enable scheduler.auto_tune_timeouts to apply them at dispatch")
+	return nil
+}
+
+// readMediaInput loads an image or file as an inline media input value
+func readMediaInput(path string) (map[string]interface{}, error) {
+	if err := validateFilePath(path); err != nil {
+		return nil, fmt.Errorf("invalid file path: %w", err)
+	}
+	data, err := os.ReadFile(path) // #nosec G304 - path validated above
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+
+	mediaType := http.DetectContentType(data)
+	if byExt := mime.TypeByExtension(filepath.Ext(path)); byExt != "" {
+		mediaType = byExt
+	}
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	partType := aor.MediaFile
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		partType = aor.MediaImage
+	case strings.HasPrefix(mediaType, "audio/"):
+		partType = aor.MediaAudio
+	}
+	return map[string]interface{}{
+		"type":       partType,
+		"media_type": mediaType,
+		"data":       base64.StdEncoding.EncodeToString(data),
+		"name":       filepath.Base(path),
+	}, nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,8 +14,24 @@ type Config struct {
 	Redis      RedisConfig      `mapstructure:"redis"`
 	NATS       NATSConfig       `mapstructure:"nats"`
 	Server     ServerConfig     `mapstructure:"server"`
+	Scheduler  SchedulerConfig  `mapstructure:"scheduler"`
+	Metrics    MetricsConfig    `mapstructure:"metrics"`
+	Replay     ReplayConfig     `mapstructure:"replay"`
+	Embeddings EmbeddingsConfig `mapstructure:"embeddings"`
 	Storage    StorageConfig    `mapstructure:"storage"`
 	Auth       AuthConfig       `mapstructure:"auth"`
+	Alerts     AlertsConfig     `mapstructure:"alerts"`
+	Callbacks  CallbacksConfig  `mapstructure:"callbacks"`
+	Traces     TracesConfig     `mapstructure:"traces"`
+	GitSync    GitSyncConfig    `mapstructure:"git_sync"`
+	Chaos      ChaosConfig      `mapstructure:"chaos"`
+	Plugins    PluginsConfig    `mapstructure:"plugins"`
+	Signing    SigningConfig    `mapstructure:"signing"`
+	Worker     WorkerConfig     `mapstructure:"worker"`
+	Artifacts  ArtifactsConfig  `mapstructure:"artifacts"`
+	Redaction  RedactionConfig  `mapstructure:"redaction"`
+	Budgets    BudgetsConfig    `mapstructure:"budgets"`
+	Deletion   DeletionConfig   `mapstructure:"deletion"`
 }
 
 type DatabaseConfig struct {
@@ -27,11 +44,15 @@ type DatabaseConfig struct {
 }
 
 type ClickHouseConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	User     string `mapstructure:"user"`
-	Password string `mapstructure:"password"`
-	Database string `mapstructure:"database"`
+	Host          string        `mapstructure:"host"`
+	Port          int           `mapstructure:"port"`
+	User          string        `mapstructure:"user"`
+	Password      string        `mapstructure:"password"`
+	Database      string        `mapstructure:"database"`
+	BatchSize     int           `mapstructure:"batch_size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	BufferSize    int           `mapstructure:"buffer_size"`
+	MaxRetries    int           `mapstructure:"max_retries"`
 }
 
 type RedisConfig struct {
@@ -50,10 +71,48 @@ type ServerConfig struct {
 	Port int    `mapstructure:"port"`
 }
 
+type SchedulerConfig struct {
+	MaxConcurrentRunsPerOrg      int  `mapstructure:"max_concurrent_runs_per_org"`
+	MaxConcurrentRunsPerWorkflow int  `mapstructure:"max_concurrent_runs_per_workflow"`
+	MaxInFlightTasks             int  `mapstructure:"max_inflight_tasks"` // Dispatched tasks awaiting results; 0 disables fair queueing backpressure
+	AutoTuneTimeouts             bool `mapstructure:"auto_tune_timeouts"` // Replace step timeouts with ones tuned from recent p99 durations
+
+	Lanes map[string]LaneConfig `mapstructure:"lanes"` // Priority lanes keyed by name
+}
+
+// LaneConfig sets a priority lane's queue wait objective and the share of
+// in-flight capacity held back for it
+type LaneConfig struct {
+	Priority        int           `mapstructure:"priority"` // Lower values dispatch first
+	TargetWait      time.Duration `mapstructure:"target_wait"`
+	ReservedPercent int           `mapstructure:"reserved_percent"` // Share of max_inflight_tasks only this lane may use
+}
+
+type MetricsConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	ControlPlaneAddr string `mapstructure:"control_plane_addr"`
+	WorkerAddr       string `mapstructure:"worker_addr"`
+	Shared           bool   `mapstructure:"shared"` // Aggregate provider metrics across replicas in Redis
+}
+
+type ReplayConfig struct {
+	CassetteKey string `mapstructure:"cassette_key"` // Empty disables response recording
+}
+
+type EmbeddingsConfig struct {
+	Provider string `mapstructure:"provider"` // openai or any OpenAI-compatible endpoint
+	BaseURL  string `mapstructure:"base_url"`
+	APIKey   string `mapstructure:"api_key"` // Empty falls back to lexical similarity
+	Model    string `mapstructure:"model"`
+}
+
 type StorageConfig struct {
-	Type   string `mapstructure:"type"` // s3, gcs, local
-	Bucket string `mapstructure:"bucket"`
-	Region string `mapstructure:"region"`
+	Type            string `mapstructure:"type"` // s3, gcs, local
+	Bucket          string `mapstructure:"bucket"`
+	Region          string `mapstructure:"region"`
+	Endpoint        string `mapstructure:"endpoint"`          // Overrides the provider endpoint, e.g. for MinIO
+	AccessKeyID     string `mapstructure:"access_key_id"`     // Empty uses the ClickHouse server's credentials
+	SecretAccessKey string `mapstructure:"secret_access_key"` // HMAC secret for gcs
 }
 
 type AuthConfig struct {
@@ -61,6 +120,106 @@ type AuthConfig struct {
 	JWTSecret  string `mapstructure:"jwt_secret"`
 }
 
+type AlertsConfig struct {
+	WebhookURL      string `mapstructure:"webhook_url"`       // Empty disables generic webhook alerts
+	SlackWebhookURL string `mapstructure:"slack_webhook_url"` // Empty disables Slack alerts
+
+	// User notifications and digests are also emailed when an SMTP server is set
+	SMTPAddr     string `mapstructure:"smtp_addr"` // host:port; empty disables email
+	SMTPFrom     string `mapstructure:"smtp_from"`
+	SMTPUsername string `mapstructure:"smtp_username"` // Empty sends without authentication
+	SMTPPassword string `mapstructure:"smtp_password"`
+}
+
+type BudgetsConfig struct {
+	Enforcement         string        `mapstructure:"enforcement"`           // reject or throttle provider calls once an org budget is spent
+	ThrottleInterval    time.Duration `mapstructure:"throttle_interval"`     // Gap between an exhausted org's calls when throttling
+	AlertThresholdRatio float64       `mapstructure:"alert_threshold_ratio"` // Utilization raising an extra budget.threshold event, e.g. 0.5; 0 disables
+
+	Degradation DegradationConfig `mapstructure:"degradation"`
+}
+
+// DegradationConfig degrades provider calls of orgs nearing their budget
+type DegradationConfig struct {
+	TriggerRatio float64                   `mapstructure:"trigger_ratio"` // Org budget utilization that starts degrading calls, e.g. 0.9; 0 disables
+	Actions      []DegradationActionConfig `mapstructure:"actions"`
+}
+
+type DegradationActionConfig struct {
+	Type             string        `mapstructure:"type"` // cheaper_model, shorter_context or throttle
+	Provider         string        `mapstructure:"provider"`
+	Model            string        `mapstructure:"model"`
+	MaxContextTokens int           `mapstructure:"max_context_tokens"` // Prompt tokens left to shorter_context calls
+	Interval         time.Duration `mapstructure:"interval"`           // Gap between an org's throttled calls
+}
+
+type CallbacksConfig struct {
+	SigningSecret string        `mapstructure:"signing_secret"` // HMAC key for X-AgentFlow-Signature; empty sends unsigned callbacks
+	MaxAttempts   int           `mapstructure:"max_attempts"`   // Deliveries tried before a callback is marked failed
+	Timeout       time.Duration `mapstructure:"timeout"`        // Per-delivery HTTP timeout
+}
+
+type TracesConfig struct {
+	ScrubLevel      string `mapstructure:"scrub_level"`      // off, standard or strict; orgs may override
+	CapturePayloads bool   `mapstructure:"capture_payloads"` // false stores trace metadata without payloads
+
+	ArchivePrefix string        `mapstructure:"archive_prefix"` // Object storage prefix for Parquet exports
+	ArchiveAfter  time.Duration `mapstructure:"archive_after"`  // Minimum trace age before export
+}
+
+type GitSyncConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	RepoURL      string        `mapstructure:"repo_url"`
+	Branch       string        `mapstructure:"branch"`
+	Token        string        `mapstructure:"token"`  // Sent as an HTTP auth header, never stored in the checkout
+	OrgID        string        `mapstructure:"org_id"` // Org the synced resources are applied to
+	WorkflowsDir string        `mapstructure:"workflows_dir"`
+	PromptsDir   string        `mapstructure:"prompts_dir"`
+	CheckoutDir  string        `mapstructure:"checkout_dir"`
+	Interval     time.Duration `mapstructure:"interval"`
+	Prune        bool          `mapstructure:"prune"` // Delete synced resources removed from the repo
+}
+
+type ChaosConfig struct {
+	Enabled bool `mapstructure:"enabled"` // Accept fault injection policies on runs; keep off in production
+}
+
+type PluginsConfig struct {
+	Dir         string        `mapstructure:"dir"`          // Directory scanned for executor plugins; empty disables plugins
+	CallTimeout time.Duration `mapstructure:"call_timeout"` // Upper bound on one plugin step execution
+}
+
+type DeletionConfig struct {
+	DigestKey string `mapstructure:"digest_key"` // HMAC key for deletion report digests; deletion requests are refused without it
+}
+
+type SigningConfig struct {
+	RequireSignatures bool `mapstructure:"require_signatures"` // Reject unsigned workflow specs and prompts on apply, run submission, prompt creation and bundle import
+}
+
+type WorkerConfig struct {
+	Region   string            `mapstructure:"region"`   // Empty takes only tasks without placement constraints
+	Labels   map[string]string `mapstructure:"labels"`   // Reported with heartbeats and matched by step selectors, e.g. gpu or toolset
+	Capacity int               `mapstructure:"capacity"` // Concurrent tasks the worker advertises to the scheduler
+}
+
+type ArtifactsConfig struct {
+	GCInterval time.Duration `mapstructure:"gc_interval"` // How often unreferenced content blobs are reclaimed; 0 disables GC
+	GCGrace    time.Duration `mapstructure:"gc_grace"`    // Minimum blob age before GC may reclaim it, covering uploads not yet referenced
+}
+
+// RedactionConfig adds PII entity types to the built-in detectors for every
+// org; orgs may register more of their own
+type RedactionConfig struct {
+	Patterns []RedactionPatternConfig `mapstructure:"patterns"`
+}
+
+type RedactionPatternConfig struct {
+	Name        string `mapstructure:"name"`        // Entity type, e.g. employee_id
+	Pattern     string `mapstructure:"pattern"`     // Go regular expression
+	Replacement string `mapstructure:"replacement"` // Defaults to [REDACTED_<NAME>]
+}
+
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -104,6 +263,10 @@ func setDefaults() {
 	viper.SetDefault("clickhouse.user", getEnvOrDefault("CLICKHOUSE_USER", "default"))
 	viper.SetDefault("clickhouse.password", getEnvOrDefault("CLICKHOUSE_PASSWORD", ""))
 	viper.SetDefault("clickhouse.database", getEnvOrDefault("CLICKHOUSE_DB", "agentflow"))
+	viper.SetDefault("clickhouse.batch_size", 1000)
+	viper.SetDefault("clickhouse.flush_interval", "5s")
+	viper.SetDefault("clickhouse.buffer_size", 10000)
+	viper.SetDefault("clickhouse.max_retries", 3)
 
 	// Redis defaults
 	viper.SetDefault("redis.host", getEnvOrDefault("REDIS_HOST", "localhost"))
@@ -118,14 +281,104 @@ func setDefaults() {
 	viper.SetDefault("server.host", "0.0.0.0")
 	viper.SetDefault("server.port", 8080)
 
+	// Scheduler defaults (0 disables the limit)
+	viper.SetDefault("scheduler.max_concurrent_runs_per_org", 100)
+	viper.SetDefault("scheduler.max_concurrent_runs_per_workflow", 20)
+	viper.SetDefault("scheduler.max_inflight_tasks", 500)
+	viper.SetDefault("scheduler.auto_tune_timeouts", false)
+	viper.SetDefault("scheduler.lanes.interactive.priority", 0)
+	viper.SetDefault("scheduler.lanes.interactive.target_wait", "5s")
+	viper.SetDefault("scheduler.lanes.interactive.reserved_percent", 40)
+	viper.SetDefault("scheduler.lanes.batch.priority", 1)
+	viper.SetDefault("scheduler.lanes.batch.target_wait", "10m")
+	viper.SetDefault("scheduler.lanes.batch.reserved_percent", 20)
+	viper.SetDefault("scheduler.lanes.background.priority", 2)
+	viper.SetDefault("scheduler.lanes.background.target_wait", "1h")
+	viper.SetDefault("scheduler.lanes.background.reserved_percent", 5)
+
+	// Metrics defaults
+	viper.SetDefault("metrics.enabled", true)
+	viper.SetDefault("metrics.control_plane_addr", ":9090")
+	viper.SetDefault("metrics.worker_addr", ":9091")
+	viper.SetDefault("metrics.shared", true)
+
+	// Replay defaults
+	viper.SetDefault("replay.cassette_key", getEnvOrDefault("CASSETTE_KEY", ""))
+
+	// Embeddings defaults
+	viper.SetDefault("embeddings.provider", "openai")
+	viper.SetDefault("embeddings.base_url", getEnvOrDefault("EMBEDDINGS_BASE_URL", "https://api.openai.com/v1"))
+	viper.SetDefault("embeddings.api_key", getEnvOrDefault("EMBEDDINGS_API_KEY", ""))
+	viper.SetDefault("embeddings.model", "text-embedding-3-small")
+
 	// Storage defaults
 	viper.SetDefault("storage.type", "local")
 	viper.SetDefault("storage.bucket", "agentflow-artifacts")
 	viper.SetDefault("storage.region", "us-east-1")
+	viper.SetDefault("storage.endpoint", getEnvOrDefault("STORAGE_ENDPOINT", ""))
+	viper.SetDefault("storage.access_key_id", getEnvOrDefault("STORAGE_ACCESS_KEY_ID", ""))
+	viper.SetDefault("storage.secret_access_key", getEnvOrDefault("STORAGE_SECRET_ACCESS_KEY", ""))
 
 	// Auth defaults
 	viper.SetDefault("auth.openfga_url", getEnvOrDefault("OPENFGA_URL", "http://localhost:8080"))
 	viper.SetDefault("auth.jwt_secret", getEnvOrDefault("JWT_SECRET", "your-secret-key"))
+
+	// Alert defaults
+	viper.SetDefault("alerts.webhook_url", getEnvOrDefault("ALERT_WEBHOOK_URL", ""))
+	viper.SetDefault("alerts.slack_webhook_url", getEnvOrDefault("SLACK_WEBHOOK_URL", ""))
+	viper.SetDefault("alerts.smtp_addr", getEnvOrDefault("SMTP_ADDR", ""))
+	viper.SetDefault("alerts.smtp_from", getEnvOrDefault("SMTP_FROM", "agentflow@localhost"))
+	viper.SetDefault("alerts.smtp_username", getEnvOrDefault("SMTP_USERNAME", ""))
+	viper.SetDefault("alerts.smtp_password", getEnvOrDefault("SMTP_PASSWORD", ""))
+
+	// Org budget enforcement defaults
+	viper.SetDefault("budgets.enforcement", "reject")
+	viper.SetDefault("budgets.throttle_interval", "30s")
+	viper.SetDefault("budgets.alert_threshold_ratio", 0)
+
+	// Run callback defaults
+	viper.SetDefault("callbacks.signing_secret", getEnvOrDefault("CALLBACK_SIGNING_SECRET", ""))
+	viper.SetDefault("callbacks.max_attempts", 8)
+	viper.SetDefault("callbacks.timeout", "10s")
+
+	// Trace payload defaults
+	viper.SetDefault("traces.scrub_level", getEnvOrDefault("TRACE_SCRUB_LEVEL", "standard"))
+	viper.SetDefault("traces.capture_payloads", true)
+	viper.SetDefault("traces.archive_prefix", "traces")
+	viper.SetDefault("traces.archive_after", "720h")
+
+	// Git sync defaults
+	viper.SetDefault("git_sync.enabled", false)
+	viper.SetDefault("git_sync.repo_url", getEnvOrDefault("GIT_SYNC_REPO_URL", ""))
+	viper.SetDefault("git_sync.branch", "main")
+	viper.SetDefault("git_sync.token", getEnvOrDefault("GIT_SYNC_TOKEN", ""))
+	viper.SetDefault("git_sync.org_id", getEnvOrDefault("GIT_SYNC_ORG_ID", ""))
+	viper.SetDefault("git_sync.workflows_dir", "workflows")
+	viper.SetDefault("git_sync.prompts_dir", "prompts")
+	viper.SetDefault("git_sync.checkout_dir", "/var/lib/agentflow/git-sync")
+	viper.SetDefault("git_sync.interval", "60s")
+	viper.SetDefault("git_sync.prune", false)
+
+	// Chaos mode defaults
+	viper.SetDefault("chaos.enabled", getEnvOrDefault("AGENTFLOW_CHAOS", "") == "true")
+
+	// Executor plugin defaults
+	viper.SetDefault("plugins.dir", getEnvOrDefault("AGENTFLOW_PLUGIN_DIR", ""))
+	viper.SetDefault("plugins.call_timeout", "10m")
+
+	// Signing defaults
+	viper.SetDefault("signing.require_signatures", getEnvOrDefault("AGENTFLOW_REQUIRE_SIGNATURES", "") == "true")
+
+	// Worker federation defaults
+	viper.SetDefault("worker.region", getEnvOrDefault("AGENTFLOW_REGION", ""))
+	viper.SetDefault("worker.capacity", 10)
+
+	// Artifact GC defaults
+	viper.SetDefault("artifacts.gc_interval", "6h")
+	viper.SetDefault("artifacts.gc_grace", "24h")
+
+	// Data deletion defaults
+	viper.SetDefault("deletion.digest_key", getEnvOrDefault("DELETION_DIGEST_KEY", ""))
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Blob kinds stored in the content-addressed store
+const (
+	BlobKindWorkflowSpec    = "workflow_spec"
+	BlobKindPromptTemplate  = "prompt_template"
+	BlobKindDataset         = "dataset"
+	BlobKindFineTuneDataset = "finetune_dataset"
+	BlobKindMedia           = "media"
+)
+
+// ErrBlobNotFound is returned when no blob matches a hash
+var ErrBlobNotFound = errors.New("blob not found")
+
+// Blob is an immutable piece of content addressed by its SHA-256 hash
+type Blob struct {
+	OrgID     uuid.UUID `json:"org_id"`
+	Hash      string    `json:"hash"`
+	Kind      string    `json:"kind"`
+	SizeBytes int64     `json:"size_bytes"`
+	Content   []byte    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BlobStore persists content-addressed specs and templates in Postgres
+type BlobStore struct {
+	postgres *PostgresDB
+}
+
+func NewBlobStore(pg *PostgresDB) *BlobStore {
+	return &BlobStore{postgres: pg}
+}
+
+// HashContent returns the content address of a byte slice
+func HashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Put stores content and returns its hash. Storing the same bytes again only
+// refreshes touched_at, which keeps artifact GC off content about to be referenced.
+func (bs *BlobStore) Put(ctx context.Context, orgID uuid.UUID, kind string, content []byte) (string, error) {
+	hash := HashContent(content)
+
+	query := `INSERT INTO content_blob (org_id, hash, kind, size_bytes, content, created_at, touched_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $6)
+			  ON CONFLICT (org_id, hash) DO UPDATE SET touched_at = EXCLUDED.touched_at`
+
+	_, err := bs.postgres.ExecContext(ctx, query, orgID, hash, kind, len(content), content, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to store blob: %w", err)
+	}
+
+	return hash, nil
+}
+
+// Get loads a blob by hash and verifies its content still matches
+func (bs *BlobStore) Get(ctx context.Context, orgID uuid.UUID, hash string) (*Blob, error) {
+	query := `SELECT org_id, hash, kind, size_bytes, content, created_at
+			  FROM content_blob WHERE org_id = $1 AND hash = $2`
+
+	var blob Blob
+	err := bs.postgres.QueryRowContext(ctx, query, orgID, hash).Scan(
+		&blob.OrgID, &blob.Hash, &blob.Kind, &blob.SizeBytes, &blob.Content, &blob.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob: %w", err)
+	}
+
+	if HashContent(blob.Content) != blob.Hash {
+		return nil, fmt.Errorf("blob %s failed integrity check", hash)
+	}
+
+	return &blob, nil
+}
@@ -44,6 +44,7 @@ func (db *ClickHouseDB) InitSchema(ctx context.Context, schemaPath string) error
 			run_id UUID,
 			step_id UUID,
 			ts DateTime64(3),
+			seq UInt64 DEFAULT 0,
 			event_type LowCardinality(String),
 			payload JSON,
 			cost_cents Int64 DEFAULT 0,
@@ -52,7 +53,8 @@ func (db *ClickHouseDB) InitSchema(ctx context.Context, schemaPath string) error
 			provider LowCardinality(String) DEFAULT '',
 			model LowCardinality(String) DEFAULT '',
 			quality_tier LowCardinality(String) DEFAULT '',
-			latency_ms Int32 DEFAULT 0
+			latency_ms Int32 DEFAULT 0,
+			labels Map(LowCardinality(String), String)
 		) ENGINE = MergeTree()
 		PARTITION BY toDate(ts)
 		ORDER BY (org_id, run_id, ts)
@@ -0,0 +1,301 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+)
+
+// BatchWriterConfig tunes buffering and retry behaviour of a BatchWriter
+type BatchWriterConfig struct {
+	MaxBatchSize  int
+	FlushInterval time.Duration
+	BufferSize    int
+	MaxRetries    int
+	RetryBackoff  time.Duration
+}
+
+// BatchWriterStats reports cumulative writer counters
+type BatchWriterStats struct {
+	Enqueued int64 `json:"enqueued"`
+	Written  int64 `json:"written"`
+	Dropped  int64 `json:"dropped"`
+	Batches  int64 `json:"batches"`
+	Retries  int64 `json:"retries"`
+	Pending  int   `json:"pending"`
+}
+
+// BatchWriter buffers rows in memory and inserts them into ClickHouse in
+// batches from a background goroutine so callers never block on the database
+type BatchWriter struct {
+	conn  *ClickHouseDB
+	query string
+	table string
+	cfg   BatchWriterConfig
+
+	rows    chan []interface{}
+	flushCh chan chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex // Orders Write against Close so no row lands after the final drain
+	closed  bool
+
+	enqueued atomic.Int64
+	written  atomic.Int64
+	dropped  atomic.Int64
+	batches  atomic.Int64
+	retries  atomic.Int64
+}
+
+// BatchWriterConfigFrom builds writer settings from ClickHouse config, applying defaults
+func BatchWriterConfigFrom(cfg *config.ClickHouseConfig) BatchWriterConfig {
+	writerCfg := BatchWriterConfig{
+		MaxBatchSize:  cfg.BatchSize,
+		FlushInterval: cfg.FlushInterval,
+		BufferSize:    cfg.BufferSize,
+		MaxRetries:    cfg.MaxRetries,
+		RetryBackoff:  500 * time.Millisecond,
+	}
+
+	if writerCfg.MaxBatchSize <= 0 {
+		writerCfg.MaxBatchSize = 1000
+	}
+	if writerCfg.FlushInterval <= 0 {
+		writerCfg.FlushInterval = 5 * time.Second
+	}
+	if writerCfg.BufferSize <= 0 {
+		writerCfg.BufferSize = 10 * writerCfg.MaxBatchSize
+	}
+	if writerCfg.MaxRetries < 0 {
+		writerCfg.MaxRetries = 0
+	}
+
+	return writerCfg
+}
+
+// NewBatchWriter creates a writer for an INSERT query and starts its flush loop
+func NewBatchWriter(conn *ClickHouseDB, query string, cfg BatchWriterConfig) *BatchWriter {
+	w := &BatchWriter{
+		conn:    conn,
+		query:   query,
+		table:   insertTable(query),
+		cfg:     cfg,
+		rows:    make(chan []interface{}, cfg.BufferSize),
+		flushCh: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w
+}
+
+// Write enqueues a row without blocking; it returns false and counts the row
+// as dropped when the buffer is full or the writer is closed
+func (w *BatchWriter) Write(row ...interface{}) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		w.drop(1)
+		return false
+	}
+
+	select {
+	case w.rows <- row:
+		w.enqueued.Add(1)
+		return true
+	default:
+		w.drop(1)
+		return false
+	}
+}
+
+// Flush forces buffered rows to be written and waits for completion
+func (w *BatchWriter) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case w.flushCh <- ack:
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the writer counters
+func (w *BatchWriter) Stats() BatchWriterStats {
+	return BatchWriterStats{
+		Enqueued: w.enqueued.Load(),
+		Written:  w.written.Load(),
+		Dropped:  w.dropped.Load(),
+		Batches:  w.batches.Load(),
+		Retries:  w.retries.Load(),
+		Pending:  len(w.rows),
+	}
+}
+
+// Close stops accepting rows, flushes what is buffered and stops the loop
+func (w *BatchWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *BatchWriter) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]interface{}, 0, w.cfg.MaxBatchSize)
+
+	for {
+		select {
+		case row := <-w.rows:
+			batch = append(batch, row)
+			if len(batch) >= w.cfg.MaxBatchSize {
+				batch = w.send(batch)
+			}
+
+		case <-ticker.C:
+			batch = w.send(batch)
+
+		case ack := <-w.flushCh:
+			batch = w.drain(batch)
+			batch = w.send(batch)
+			close(ack)
+
+		case <-w.done:
+			batch = w.drain(batch)
+			w.send(batch)
+			return
+		}
+	}
+}
+
+// drain moves every buffered row into the pending batch, sending full batches
+func (w *BatchWriter) drain(batch [][]interface{}) [][]interface{} {
+	for {
+		select {
+		case row := <-w.rows:
+			batch = append(batch, row)
+			if len(batch) >= w.cfg.MaxBatchSize {
+				batch = w.send(batch)
+			}
+		default:
+			return batch
+		}
+	}
+}
+
+// send inserts a batch, retrying with backoff on failure; rows are counted as
+// dropped once retries are exhausted. It returns the emptied batch slice.
+func (w *BatchWriter) send(batch [][]interface{}) [][]interface{} {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	var err error
+	for attempt := 0; attempt <= w.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			w.retries.Add(1)
+			batchWriterRetries.Add(float64(len(batch)), w.table)
+			time.Sleep(w.cfg.RetryBackoff * time.Duration(attempt))
+
+			// Re-establish the connection before retrying
+			pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_ = w.conn.Ping(pingCtx)
+			cancel()
+		}
+
+		var appended int
+		if appended, err = w.insert(batch); err == nil {
+			w.written.Add(int64(appended))
+			batchWriterRows.Add(float64(appended), w.table, "flushed")
+			w.drop(len(batch) - appended)
+			w.batches.Add(1)
+			return batch[:0]
+		}
+	}
+
+	w.drop(len(batch))
+	log.Printf("Dropped %d rows after %d attempts: %v", len(batch), w.cfg.MaxRetries+1, err)
+	return batch[:0]
+}
+
+// drop counts rows that will never reach ClickHouse
+func (w *BatchWriter) drop(n int) {
+	if n <= 0 {
+		return
+	}
+	w.dropped.Add(int64(n))
+	batchWriterRows.Add(float64(n), w.table, "dropped")
+}
+
+// insertTable names the table an INSERT query writes to, for metric labels
+func insertTable(query string) string {
+	fields := strings.Fields(query)
+	for i, field := range fields {
+		if strings.EqualFold(field, "INTO") && i+1 < len(fields) {
+			return strings.TrimSuffix(fields[i+1], "(")
+		}
+	}
+	return "unknown"
+}
+
+// insert sends rows as a single batch and returns how many were appended
+func (w *BatchWriter) insert(rows [][]interface{}) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	batch, err := w.conn.PrepareBatch(ctx, w.query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare batch: %w", err)
+	}
+
+	appended := 0
+	for _, row := range rows {
+		if err := batch.Append(row...); err != nil {
+			continue // Skip rows that fail to append
+		}
+		appended++
+	}
+
+	if err := batch.Send(); err != nil {
+		return 0, fmt.Errorf("failed to send batch: %w", err)
+	}
+
+	return appended, nil
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchWriter(t *testing.T) {
+	cfg := BatchWriterConfig{MaxBatchSize: 50, FlushInterval: time.Hour, BufferSize: 100000}
+
+	t.Run("close flushes buffered rows", func(t *testing.T) {
+		conn := &fakeClickHouse{}
+		w := NewBatchWriter(&ClickHouseDB{Conn: conn}, "INSERT INTO trace_event", cfg)
+		for i := 0; i < 120; i++ {
+			assert.True(t, w.Write(i))
+		}
+
+		assert.NoError(t, w.Close(context.Background()))
+		assert.Equal(t, 120, conn.rows())
+		stats := w.Stats()
+		assert.Equal(t, int64(120), stats.Written)
+		assert.Zero(t, stats.Dropped)
+
+		assert.False(t, w.Write(121), "closed writers take no rows")
+		assert.Equal(t, int64(1), w.Stats().Dropped)
+	})
+
+	t.Run("rows written while closing are flushed or counted as dropped", func(t *testing.T) {
+		conn := &fakeClickHouse{}
+		w := NewBatchWriter(&ClickHouseDB{Conn: conn}, "INSERT INTO trace_event", cfg)
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					w.Write(i)
+				}
+			}()
+		}
+		time.Sleep(time.Millisecond)
+		assert.NoError(t, w.Close(context.Background()))
+		wg.Wait()
+
+		stats := w.Stats()
+		assert.Equal(t, int64(8*500), stats.Written+stats.Dropped, "every row is either written or dropped")
+		assert.Equal(t, stats.Enqueued, stats.Written)
+		assert.Equal(t, int(stats.Written), conn.rows())
+	})
+
+	t.Run("flush writes buffered rows", func(t *testing.T) {
+		conn := &fakeClickHouse{}
+		w := NewBatchWriter(&ClickHouseDB{Conn: conn}, "INSERT INTO trace_event", cfg)
+		defer func() { _ = w.Close(context.Background()) }()
+
+		w.Write(1)
+		w.Write(2)
+		assert.NoError(t, w.Flush(context.Background()))
+		assert.Equal(t, 2, conn.rows())
+		assert.Equal(t, int64(1), w.Stats().Batches)
+	})
+
+	t.Run("exports flushed retried and dropped rows", func(t *testing.T) {
+		flushed := batchWriterRows.Value("batch_metrics", "flushed")
+		retried := batchWriterRetries.Value("batch_metrics")
+		dropped := batchWriterRows.Value("batch_metrics", "dropped")
+
+		conn := &fakeClickHouse{failures: 1}
+		w := NewBatchWriter(&ClickHouseDB{Conn: conn}, "INSERT INTO batch_metrics (org_id, ts) VALUES", BatchWriterConfig{
+			MaxBatchSize: 50, FlushInterval: time.Hour, BufferSize: 100, MaxRetries: 1,
+		})
+		for i := 0; i < 3; i++ {
+			w.Write(i)
+		}
+		assert.NoError(t, w.Close(context.Background()))
+		w.Write(4)
+
+		assert.Equal(t, flushed+3, batchWriterRows.Value("batch_metrics", "flushed"))
+		assert.Equal(t, retried+3, batchWriterRetries.Value("batch_metrics"))
+		assert.Equal(t, dropped+1, batchWriterRows.Value("batch_metrics", "dropped"))
+		assert.Equal(t, "unknown", insertTable("SELECT 1"))
+	})
+}
+
+// fakeClickHouse accepts batches in memory
+type fakeClickHouse struct {
+	driver.Conn
+	mu       sync.Mutex
+	sent     int
+	failures int // Sends to fail before accepting batches
+}
+
+func (f *fakeClickHouse) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	return &fakeBatch{conn: f}, nil
+}
+
+func (f *fakeClickHouse) Ping(ctx context.Context) error { return nil }
+
+func (f *fakeClickHouse) rows() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sent
+}
+
+type fakeBatch struct {
+	driver.Batch
+	conn *fakeClickHouse
+	rows int
+}
+
+func (b *fakeBatch) Append(v ...any) error {
+	b.rows++
+	return nil
+}
+
+func (b *fakeBatch) Send() error {
+	b.conn.mu.Lock()
+	defer b.conn.mu.Unlock()
+	if b.conn.failures > 0 {
+		b.conn.failures--
+		return errors.New("connection reset")
+	}
+	b.conn.sent += b.rows
+	return nil
+}
@@ -0,0 +1,13 @@
+package db
+
+import "github.com/Siddhant-K-code/agentflow-infrastructure/internal/metrics"
+
+// Registry holds ClickHouse ingestion metrics served at /metrics alongside orchestration metrics
+var Registry = metrics.NewRegistry()
+
+var (
+	batchWriterRows = Registry.NewCounter("agentflow_clickhouse_rows_total",
+		"Rows handled by ClickHouse batch writers by outcome (flushed, dropped)", "table", "outcome")
+	batchWriterRetries = Registry.NewCounter("agentflow_clickhouse_retried_rows_total",
+		"Rows resent after a failed ClickHouse batch insert", "table")
+)
@@ -0,0 +1,278 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+	// totalEstimateCap bounds how many rows are counted for total_estimate
+	totalEstimateCap = 10000
+)
+
+// ListOptions are the paging, filtering and field selection parameters every
+// list endpoint accepts
+type ListOptions struct {
+	Cursor string   `json:"cursor,omitempty"`
+	Limit  int      `json:"limit,omitempty"`
+	Filter string   `json:"filter,omitempty"` // e.g. status=failed,cost_cents>=100,name~etl
+	Fields []string `json:"fields,omitempty"` // Top-level or dotted fields to return; empty returns all
+}
+
+// PageSize returns the effective page size
+func (o ListOptions) PageSize() int {
+	if o.Limit <= 0 {
+		return DefaultPageSize
+	}
+	if o.Limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return o.Limit
+}
+
+// PageMeta is the envelope metadata every list response carries.
+// TotalEstimate counts matching rows up to a cap, so large listings stay cheap.
+type PageMeta struct {
+	NextCursor    string `json:"next_cursor,omitempty"`
+	TotalEstimate int64  `json:"total_estimate"`
+}
+
+// FilterType is how a filter value is parsed
+type FilterType int
+
+const (
+	FilterString FilterType = iota
+	FilterInt
+	FilterTime
+)
+
+// FilterField maps a filterable field to its column
+type FilterField struct {
+	Column string
+	Type   FilterType
+}
+
+// FilterClause is one parsed filter condition with "?" standing in for its
+// parameter
+type FilterClause struct {
+	SQL string
+	Arg interface{}
+}
+
+// filterOps are checked in order so two-character operators win
+var filterOps = []string{"!=", ">=", "<=", "=", ">", "<", "~"}
+
+// ParseFilter parses a comma-separated filter expression such as
+// status=failed,cost_cents>=100,name~etl. Operators are =, !=, >, >=, <, <=
+// and ~ (substring, strings only); times are RFC3339.
+func ParseFilter(expr string, fields map[string]FilterField) ([]FilterClause, error) {
+	clauses := make([]FilterClause, 0)
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		name, op, value := "", "", ""
+		for _, candidate := range filterOps {
+			if i := strings.Index(term, candidate); i > 0 {
+				name, op, value = strings.TrimSpace(term[:i]), candidate, strings.TrimSpace(term[i+len(candidate):])
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("invalid filter %q: expected field, operator and value", term)
+		}
+
+		field, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid filter %q: unknown field %s", term, name)
+		}
+
+		clause, err := field.clause(op, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %w", term, err)
+		}
+		clauses = append(clauses, clause)
+	}
+	return clauses, nil
+}
+
+func (f FilterField) clause(op, value string) (FilterClause, error) {
+	if op == "~" {
+		if f.Type != FilterString {
+			return FilterClause{}, fmt.Errorf("~ only applies to text fields")
+		}
+		return FilterClause{SQL: f.Column + " ILIKE ?", Arg: "%" + value + "%"}, nil
+	}
+
+	var arg interface{}
+	switch f.Type {
+	case FilterInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return FilterClause{}, fmt.Errorf("expected an integer")
+		}
+		arg = n
+	case FilterTime:
+		ts, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return FilterClause{}, fmt.Errorf("expected an RFC3339 time")
+		}
+		arg = ts
+	default:
+		arg = value
+	}
+	return FilterClause{SQL: f.Column + " " + op + " ?", Arg: arg}, nil
+}
+
+// PostgresConditions appends clauses to args, rewriting each "?" to the
+// matching positional parameter
+func PostgresConditions(clauses []FilterClause, args []interface{}) ([]string, []interface{}) {
+	conditions := make([]string, 0, len(clauses))
+	for _, clause := range clauses {
+		args = append(args, clause.Arg)
+		conditions = append(conditions, strings.ReplaceAll(clause.SQL, "?", "$"+strconv.Itoa(len(args))))
+	}
+	return conditions, args
+}
+
+// EncodeCursor encodes the position after a row in a (created_at, id)
+// keyset listing as an opaque token
+func EncodeCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor reverses EncodeCursor
+func DecodeCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	nanos, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	return time.Unix(0, n), id, nil
+}
+
+// EncodeOffsetCursor encodes a position for listings without a unique sort
+// key, such as trace events
+func EncodeOffsetCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o|" + strconv.Itoa(offset)))
+}
+
+// DecodeOffsetCursor reverses EncodeOffsetCursor
+func DecodeOffsetCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor: %w", err)
+	}
+	value, ok := strings.CutPrefix(string(raw), "o|")
+	if !ok {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return offset, nil
+}
+
+// EstimateTotal counts the rows a listing query matches, stopping at a cap.
+// The query must select from the filtered set without ORDER BY or LIMIT.
+func (db *PostgresDB) EstimateTotal(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	countQuery := fmt.Sprintf(`SELECT count(*) FROM (%s LIMIT %d) AS matched`, query, totalEstimateCap)
+	var total int64
+	if err := db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to estimate total: %w", err)
+	}
+	return total, nil
+}
+
+// SelectFields reduces a response to the requested fields by their JSON
+// names. Each item of a list is reduced on its own; dotted names select
+// nested fields. No fields returns the value unchanged.
+func SelectFields(v interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode response: %w", err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if items, ok := decoded.([]interface{}); ok {
+		selected := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			selected = append(selected, selectObjectFields(item, fields))
+		}
+		return selected, nil
+	}
+	return selectObjectFields(decoded, fields), nil
+}
+
+func selectObjectFields(v interface{}, fields []string) interface{} {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+
+	selected := make(map[string]interface{})
+	for _, field := range fields {
+		parts := strings.Split(strings.TrimSpace(field), ".")
+		var value interface{} = obj
+		found := true
+		for _, part := range parts {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				found = false
+				break
+			}
+			if value, ok = m[part]; !ok {
+				found = false
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		target := selected
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := target[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				target[part] = next
+			}
+			target = next
+		}
+		target[parts[len(parts)-1]] = value
+	}
+	return selected
+}
@@ -0,0 +1,407 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are histogram buckets in seconds suited to task and step latencies
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeGauge     metricType = "gauge"
+	typeHistogram metricType = "histogram"
+)
+
+// collector is implemented by every metric family in a registry
+type collector interface {
+	describe() (name, help string, kind metricType)
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families and renders them in the Prometheus text format
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// NewCounter registers a counter family with the given label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{family: newFamily(name, help, labels)}
+	r.register(c)
+	return c
+}
+
+// NewGauge registers a gauge family with the given label names
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{family: newFamily(name, help, labels)}
+	r.register(g)
+	return g
+}
+
+// NewHistogram registers a histogram family; nil buckets uses DefaultBuckets
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	h := &Histogram{family: newFamily(name, help, labels), buckets: sorted, series: make(map[string]*histogramSeries)}
+	r.register(h)
+	return h
+}
+
+// Sample is one series value produced by a function-backed metric
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// NewCounterFunc registers a counter family whose series are produced by
+// collect at scrape time, for values aggregated outside this process
+func (r *Registry) NewCounterFunc(name, help string, collect func() []Sample, labels ...string) {
+	r.register(&funcCollector{family: newFamily(name, help, labels), kind: typeCounter, collect: collect})
+}
+
+// NewGaugeFunc registers a gauge family whose series are produced by collect at scrape time
+func (r *Registry) NewGaugeFunc(name, help string, collect func() []Sample, labels ...string) {
+	r.register(&funcCollector{family: newFamily(name, help, labels), kind: typeGauge, collect: collect})
+}
+
+func (r *Registry) register(c collector) {
+	name, _, _ := c.describe()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.collectors[name]; exists {
+		panic(fmt.Sprintf("metric %s already registered", name))
+	}
+	r.collectors[name] = c
+}
+
+// WriteText renders every registered family in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]collector, 0, len(names))
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		name, help, kind := c.describe()
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, kind)
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry at a /metrics style endpoint
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WriteText(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Handler serves several registries as a single exposition
+func Handler(registries ...*Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		for _, r := range registries {
+			if err := r.WriteText(w); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	})
+}
+
+// family holds the shared name, help and label schema of a metric
+type family struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func newFamily(name, help string, labels []string) family {
+	return family{name: name, help: help, labels: labels}
+}
+
+// key encodes label values into a stable series key
+func (f family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelString renders label pairs, appending any extra pairs such as "le"
+func (f family) labelString(key string, extra ...string) string {
+	pairs := make([]string, 0, len(f.labels)+len(extra)/2)
+	if len(f.labels) > 0 {
+		values := strings.Split(key, "\xff")
+		for i, label := range f.labels {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", label, values[i]))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", extra[i], extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a monotonically increasing value per label set
+type Counter struct {
+	family
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// Inc adds one to the series for the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the series by delta; negative deltas are ignored
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]float64)
+	}
+	c.values[key] += delta
+}
+
+// Value returns the current value of a series
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) describe() (string, string, metricType) {
+	return c.name, c.help, typeCounter
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelString(key), formatFloat(c.values[key]))
+	}
+}
+
+// Gauge is a value per label set that can go up and down
+type Gauge struct {
+	family
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// Set replaces the series value
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	key := g.key(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.values == nil {
+		g.values = make(map[string]float64)
+	}
+	g.values[key] = value
+}
+
+// Add adjusts the series value by delta
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	key := g.key(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.values == nil {
+		g.values = make(map[string]float64)
+	}
+	g.values[key] += delta
+}
+
+// Value returns the current value of a series
+func (g *Gauge) Value(labelValues ...string) float64 {
+	key := g.key(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.values[key]
+}
+
+func (g *Gauge) describe() (string, string, metricType) {
+	return g.name, g.help, typeGauge
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelString(key), formatFloat(g.values[key]))
+	}
+}
+
+// funcCollector renders samples returned by a callback
+type funcCollector struct {
+	family
+	kind    metricType
+	collect func() []Sample
+}
+
+func (f *funcCollector) describe() (string, string, metricType) {
+	return f.name, f.help, f.kind
+}
+
+func (f *funcCollector) write(w *bufio.Writer) {
+	values := make(map[string]float64)
+	for _, sample := range f.collect() {
+		if len(sample.LabelValues) != len(f.labels) {
+			continue
+		}
+		values[f.key(sample.LabelValues)] += sample.Value
+	}
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelString(key), formatFloat(values[key]))
+	}
+}
+
+// Histogram counts observations into cumulative buckets per label set
+type Histogram struct {
+	family
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe records a value for the given label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// ObserveDuration records the seconds elapsed since start
+func (h *Histogram) ObserveDuration(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count returns the number of observations of a series
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) describe() (string, string, metricType) {
+	return h.name, h.help, typeHistogram
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(key, "le", formatFloat(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(key), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(key), s.count)
+	}
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Server exposes one or more registries over HTTP at /metrics
+type Server struct {
+	server *http.Server
+}
+
+func NewServer(addr string, registries ...*Registry) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(registries...))
+
+	return &Server{
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// Start listens in the background until Shutdown is called
+func (s *Server) Start() {
+	go func() {
+		log.Printf("Serving metrics on %s/metrics", s.server.Addr)
+		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Metrics server stopped: %v", err)
+		}
+	}()
+}
+
+// Shutdown gracefully stops the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown metrics server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package pop
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+)
+
+// embeddingPricePerMillion is the USD price per million input tokens
+var embeddingPricePerMillion = map[string]float64{
+	"text-embedding-3-small": 0.02,
+	"text-embedding-3-large": 0.13,
+	"text-embedding-ada-002": 0.10,
+}
+
+// defaultEmbeddingPrice applies to models missing from the price table
+const defaultEmbeddingPrice = 0.10
+
+// EmbeddingResult holds vectors for a batch of inputs and the tokens billed
+type EmbeddingResult struct {
+	Vectors [][]float64 `json:"vectors"`
+	Tokens  int         `json:"tokens"`
+}
+
+// EmbeddingClient computes embeddings for texts with a given model
+type EmbeddingClient interface {
+	Embed(ctx context.Context, model string, texts []string) (*EmbeddingResult, error)
+}
+
+// HTTPEmbeddingClient calls an OpenAI-compatible /embeddings endpoint
+type HTTPEmbeddingClient struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+func NewHTTPEmbeddingClient(cfg config.EmbeddingsConfig) *HTTPEmbeddingClient {
+	return &HTTPEmbeddingClient{
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		apiKey:  cfg.APIKey,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Embed requests embeddings for texts in a single call
+func (c *HTTPEmbeddingClient) Embed(ctx context.Context, model string, texts []string) (*EmbeddingResult, error) {
+	body, err := json.Marshal(map[string]interface{}{"model": model, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embeddings endpoint: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embeddings endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var payload struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+	if len(payload.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(payload.Data))
+	}
+
+	result := &EmbeddingResult{Vectors: make([][]float64, len(texts)), Tokens: payload.Usage.TotalTokens}
+	for _, item := range payload.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		result.Vectors[item.Index] = item.Embedding
+	}
+
+	return result, nil
+}
+
+// EmbeddingScorer scores outputs by cosine similarity of embeddings and
+// caches expected-output vectors across cases and runs
+type EmbeddingScorer struct {
+	client       EmbeddingClient
+	defaultModel string
+
+	mu    sync.RWMutex
+	cache map[string][]float64
+}
+
+func NewEmbeddingScorer(client EmbeddingClient, defaultModel string) *EmbeddingScorer {
+	return &EmbeddingScorer{
+		client:       client,
+		defaultModel: defaultModel,
+		cache:        make(map[string][]float64),
+	}
+}
+
+// Similarity embeds both texts with the model and returns their cosine
+// similarity and the embedding cost in cents
+func (es *EmbeddingScorer) Similarity(ctx context.Context, model, actual, expected string) (float64, float64, error) {
+	if model == "" {
+		model = es.defaultModel
+	}
+
+	key := embeddingCacheKey(model, expected)
+	es.mu.RLock()
+	expectedVec, cached := es.cache[key]
+	es.mu.RUnlock()
+
+	texts := []string{actual}
+	if !cached {
+		texts = append(texts, expected)
+	}
+
+	result, err := es.client.Embed(ctx, model, texts)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if !cached {
+		expectedVec = result.Vectors[1]
+		es.mu.Lock()
+		es.cache[key] = expectedVec
+		es.mu.Unlock()
+	}
+
+	similarity, err := CosineSimilarity(result.Vectors[0], expectedVec)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return similarity, EmbeddingCostCents(model, result.Tokens), nil
+}
+
+func embeddingCacheKey(model, text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return model + ":" + hex.EncodeToString(sum[:])
+}
+
+// EmbeddingCostCents prices embedding tokens in fractional cents
+func EmbeddingCostCents(model string, tokens int) float64 {
+	price, ok := embeddingPricePerMillion[model]
+	if !ok {
+		price = defaultEmbeddingPrice
+	}
+	return float64(tokens) / 1e6 * price * 100
+}
+
+// CosineSimilarity returns the cosine of the angle between two vectors
+func CosineSimilarity(a, b []float64) (float64, error) {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0, fmt.Errorf("embedding dimensions differ: %d vs %d", len(a), len(b))
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0, nil
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB)), nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"regexp"
 	"strings"
 	"time"
@@ -12,15 +13,17 @@ import (
 )
 
 type Evaluator struct {
-	db *db.PostgresDB
+	db         *db.PostgresDB
+	embeddings *EmbeddingScorer
 }
 
 func NewEvaluator(database *db.PostgresDB) *Evaluator {
 	return &Evaluator{db: database}
 }
 
-// EvaluateCase evaluates a single test case
-func (e *Evaluator) EvaluateCase(ctx context.Context, testCase TestCase, actualOutput interface{}) (*EvaluationResult, error) {
+// EvaluateCase evaluates a single test case. embeddingModel is the suite's
+// embedding model, used unless the case's scoring config names its own.
+func (e *Evaluator) EvaluateCase(ctx context.Context, testCase TestCase, actualOutput interface{}, embeddingModel string) (*EvaluationResult, error) {
 	start := time.Now()
 
 	result := &EvaluationResult{
@@ -32,7 +35,14 @@ func (e *Evaluator) EvaluateCase(ctx context.Context, testCase TestCase, actualO
 	}
 
 	// Score based on scoring configuration
-	score, passed, err := e.scoreOutput(actualOutput, testCase.Expected, testCase.Scoring)
+	var score float64
+	var passed bool
+	var err error
+	if testCase.Scoring.Type == ScoringEmbedding {
+		score, passed, result.EmbeddingCostCents, err = e.scoreEmbedding(ctx, actualOutput, testCase.Expected.Output, testCase.Scoring.Config, embeddingModel)
+	} else {
+		score, passed, err = e.scoreOutput(actualOutput, testCase.Expected, testCase.Scoring)
+	}
 	if err != nil {
 		result.Error = err.Error()
 		result.Score = 0
@@ -58,8 +68,6 @@ func (e *Evaluator) scoreOutput(actual interface{}, expected Expected, scoring S
 		return e.scoreSchema(actual, expected.Schema)
 	case ScoringLLMJudge:
 		return e.scoreLLMJudge(actual, expected, scoring.Config)
-	case ScoringEmbedding:
-		return e.scoreEmbedding(actual, expected.Output, scoring.Config)
 	default:
 		return 0, false, fmt.Errorf("unknown scoring type: %s", scoring.Type)
 	}
@@ -163,26 +171,35 @@ func (e *Evaluator) scoreLLMJudge(actual interface{}, expected Expected, config
 	return 0.3, false, nil // Mock poor score
 }
 
-func (e *Evaluator) scoreEmbedding(actual, expected interface{}, config map[string]interface{}) (float64, bool, error) {
-	// Mock embedding similarity implementation
-	// In production, this would compute embedding similarity
-
+// scoreEmbedding scores by cosine similarity of embeddings and returns the
+// embedding cost in cents. Without an embeddings client it falls back to
+// lexical similarity.
+func (e *Evaluator) scoreEmbedding(ctx context.Context, actual, expected interface{}, config map[string]interface{}, embeddingModel string) (float64, bool, float64, error) {
 	threshold, ok := config["threshold"].(float64)
 	if !ok {
 		threshold = 0.8
 	}
+	if model, ok := config["model"].(string); ok && model != "" {
+		embeddingModel = model
+	}
 
 	actualStr := fmt.Sprintf("%v", actual)
 	expectedStr := fmt.Sprintf("%v", expected)
 
-	// Mock similarity calculation (simple string similarity)
-	similarity := e.calculateStringSimilarity(actualStr, expectedStr)
+	if e.embeddings == nil {
+		similarity := e.calculateStringSimilarity(actualStr, expectedStr)
+		return similarity, similarity >= threshold, 0, nil
+	}
+
+	similarity, costCents, err := e.embeddings.Similarity(ctx, embeddingModel, actualStr, expectedStr)
+	if err != nil {
+		return 0, false, 0, fmt.Errorf("failed to compute embedding similarity: %w", err)
+	}
 
-	passed := similarity >= threshold
-	return similarity, passed, nil
+	return similarity, similarity >= threshold, costCents, nil
 }
 
-// calculateStringSimilarity provides a mock string similarity calculation
+// calculateStringSimilarity computes word-level Jaccard similarity
 func (e *Evaluator) calculateStringSimilarity(s1, s2 string) float64 {
 	if s1 == s2 {
 		return 1.0
@@ -225,3 +242,32 @@ func (e *Evaluator) calculateStringSimilarity(s1, s2 string) float64 {
 
 	return float64(intersection) / float64(union)
 }
+
+// SummarizeResults aggregates case results, rounding fractional embedding
+// cost up into the run's total
+func SummarizeResults(results []EvaluationResult) EvaluationSummary {
+	summary := EvaluationSummary{TotalCases: len(results)}
+	if len(results) == 0 {
+		return summary
+	}
+
+	var totalScore float64
+	var totalLatency time.Duration
+	for _, result := range results {
+		if result.Passed {
+			summary.PassedCases++
+		} else {
+			summary.FailedCases++
+		}
+		totalScore += result.Score
+		totalLatency += result.Latency
+		summary.TotalCost += result.CostCents
+		summary.EmbeddingCostCents += result.EmbeddingCostCents
+	}
+
+	summary.AverageScore = totalScore / float64(len(results))
+	summary.AverageLatency = totalLatency / time.Duration(len(results))
+	summary.TotalCost += int64(math.Ceil(summary.EmbeddingCostCents))
+
+	return summary
+}
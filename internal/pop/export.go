@@ -0,0 +1,513 @@
+package pop
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// promptBundleFormat is bumped when PromptBundle changes incompatibly
+const promptBundleFormat = 1
+
+// PromptBundle carries prompts with all their versions, suites and
+// deployments from one org or environment to another
+type PromptBundle struct {
+	Format      int                `json:"format"`
+	SourceOrgID uuid.UUID          `json:"source_org_id"`
+	ExportedAt  time.Time          `json:"exported_at"`
+	Prompts     []PromptTemplate   `json:"prompts"`
+	Suites      []PromptSuite      `json:"suites"`
+	Deployments []PromptDeployment `json:"deployments"`
+}
+
+// PromptExportRequest selects what to export; empty lists export everything
+type PromptExportRequest struct {
+	Names  []string `json:"names,omitempty"`
+	Suites []string `json:"suites,omitempty"`
+}
+
+// ConflictStrategy decides what an import does with prompts, suites and
+// deployments that already exist in the target org
+type ConflictStrategy string
+
+const (
+	ConflictSkip       ConflictStrategy = "skip"        // Keep the target's copy
+	ConflictOverwrite  ConflictStrategy = "overwrite"   // Replace the target's copy in place
+	ConflictNewVersion ConflictStrategy = "new-version" // Append imported prompts after the target's latest version
+)
+
+// ParseConflictStrategy validates a conflict strategy; empty means skip
+func ParseConflictStrategy(value string) (ConflictStrategy, error) {
+	switch ConflictStrategy(value) {
+	case "":
+		return ConflictSkip, nil
+	case ConflictSkip, ConflictOverwrite, ConflictNewVersion:
+		return ConflictStrategy(value), nil
+	default:
+		return "", fmt.Errorf("invalid conflict strategy %q: use skip, overwrite or new-version", value)
+	}
+}
+
+// ImportAction is what an import did with one item of a bundle
+type ImportAction string
+
+const (
+	ImportCreated     ImportAction = "created"
+	ImportOverwritten ImportAction = "overwritten"
+	ImportMerged      ImportAction = "merged" // Suite cases added to an existing suite
+	ImportSkipped     ImportAction = "skipped"
+	ImportUnchanged   ImportAction = "unchanged"
+)
+
+// PromptImportRequest imports a bundle; a dry run reports the plan without writing
+type PromptImportRequest struct {
+	Bundle   *PromptBundle    `json:"bundle"`
+	Strategy ConflictStrategy `json:"strategy"`
+	DryRun   bool             `json:"dry_run"`
+}
+
+// PromptImportItem is the outcome for one prompt version, suite or deployment
+type PromptImportItem struct {
+	Kind          string       `json:"kind"` // prompt, suite or deployment
+	Name          string       `json:"name"`
+	SourceVersion int          `json:"source_version,omitempty"`
+	Version       int          `json:"version,omitempty"` // Version in the target org
+	Action        ImportAction `json:"action"`
+}
+
+// PromptImportResult lists what an import did, or would do on a dry run
+type PromptImportResult struct {
+	Strategy ConflictStrategy     `json:"strategy"`
+	DryRun   bool                 `json:"dry_run"`
+	Items    []PromptImportItem   `json:"items"`
+	Summary  map[ImportAction]int `json:"summary"`
+}
+
+// ExportPrompts bundles every version of the selected prompts with their
+// deployments and the selected evaluation suites
+func (s *Service) ExportPrompts(ctx context.Context, orgID uuid.UUID, req *PromptExportRequest) (*PromptBundle, error) {
+	prompts, err := s.listPromptVersions(ctx, orgID, req.Names)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Names) > 0 {
+		found := make(map[string]bool)
+		for _, prompt := range prompts {
+			found[prompt.Name] = true
+		}
+		for _, name := range req.Names {
+			if !found[name] {
+				return nil, fmt.Errorf("prompt %s not found", name)
+			}
+		}
+	}
+
+	bundle := &PromptBundle{
+		Format:      promptBundleFormat,
+		SourceOrgID: orgID,
+		ExportedAt:  time.Now(),
+		Prompts:     prompts,
+		Suites:      make([]PromptSuite, 0),
+		Deployments: make([]PromptDeployment, 0),
+	}
+
+	exported := make(map[string]bool)
+	for _, prompt := range prompts {
+		if exported[prompt.Name] {
+			continue
+		}
+		exported[prompt.Name] = true
+		deployment, err := s.getDeployment(ctx, orgID, prompt.Name)
+		if err == nil {
+			bundle.Deployments = append(bundle.Deployments, *deployment)
+		}
+	}
+
+	suiteQuery := `SELECT name FROM prompt_suite WHERE org_id = $1 AND (cardinality($2::text[]) = 0 OR name = ANY($2)) ORDER BY name`
+	rows, err := s.db.QueryContext(ctx, suiteQuery, orgID, pq.Array(req.Suites))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list suites: %w", err)
+	}
+	var suiteNames []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan suite: %w", err)
+		}
+		suiteNames = append(suiteNames, name)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list suites: %w", err)
+	}
+	if len(suiteNames) < len(req.Suites) {
+		return nil, fmt.Errorf("suites not found: want %v, found %v", req.Suites, suiteNames)
+	}
+	for _, name := range suiteNames {
+		suite, err := s.getSuite(ctx, orgID, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get suite %s: %w", name, err)
+		}
+		bundle.Suites = append(bundle.Suites, *suite)
+	}
+
+	return bundle, nil
+}
+
+// ImportPrompts copies a bundle into an org, resolving conflicts with the
+// existing prompts, suites and deployments by the request's strategy
+func (s *Service) ImportPrompts(ctx context.Context, orgID uuid.UUID, req *PromptImportRequest) (*PromptImportResult, error) {
+	if req.Bundle == nil {
+		return nil, fmt.Errorf("bundle is required")
+	}
+	if req.Bundle.Format != promptBundleFormat {
+		return nil, fmt.Errorf("unsupported bundle format %d, expected %d", req.Bundle.Format, promptBundleFormat)
+	}
+	strategy, err := ParseConflictStrategy(string(req.Strategy))
+	if err != nil {
+		return nil, err
+	}
+	// Bundles carry no signatures, so signed prompts can only arrive through apply
+	if s.signaturesRequired() && len(req.Bundle.Prompts) > 0 {
+		return nil, fmt.Errorf("prompt bundles are unsigned; this control plane only accepts signed prompts through apply")
+	}
+	// Validate everything before writing anything
+	for _, prompt := range req.Bundle.Prompts {
+		if err := s.renderer.Validate(prompt.Template, prompt.Schema); err != nil {
+			return nil, fmt.Errorf("prompt %s v%d failed validation: %w", prompt.Name, prompt.Version, err)
+		}
+	}
+
+	result := &PromptImportResult{Strategy: strategy, DryRun: req.DryRun, Items: make([]PromptImportItem, 0)}
+	versionMaps := make(map[string]map[int]int)
+	for name, incoming := range groupPromptVersions(req.Bundle.Prompts) {
+		existing, err := s.listPromptVersions(ctx, orgID, []string{name})
+		if err != nil {
+			return nil, err
+		}
+		items, versions := planPromptImport(existing, incoming, strategy)
+		versionMaps[name] = versions
+		if !req.DryRun {
+			if err := s.applyPromptImport(ctx, orgID, req.Bundle.SourceOrgID, incoming, items); err != nil {
+				return nil, err
+			}
+		}
+		result.Items = append(result.Items, items...)
+	}
+
+	for _, suite := range req.Bundle.Suites {
+		existing, err := s.getSuite(ctx, orgID, suite.Name)
+		if err != nil {
+			existing = nil
+		}
+		item, cases := planSuiteImport(existing, suite, strategy)
+		if !req.DryRun {
+			if err := s.applySuiteImport(ctx, orgID, suite, item.Action, cases); err != nil {
+				return nil, err
+			}
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	for _, deployment := range req.Bundle.Deployments {
+		existing, err := s.getDeployment(ctx, orgID, deployment.PromptName)
+		if err != nil {
+			existing = nil
+		}
+		item, planned := planDeploymentImport(existing, deployment, versionMaps[deployment.PromptName], strategy)
+		if !req.DryRun && (item.Action == ImportCreated || item.Action == ImportOverwritten) {
+			planned.ID, planned.OrgID = uuid.New(), orgID
+			planned.CreatedAt, planned.UpdatedAt = time.Now(), time.Now()
+			if err := s.saveDeployment(ctx, planned); err != nil {
+				return nil, fmt.Errorf("failed to import deployment of %s: %w", deployment.PromptName, err)
+			}
+			s.notifyDeployed(ctx, planned)
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	sort.SliceStable(result.Items, func(i, j int) bool {
+		a, b := result.Items[i], result.Items[j]
+		if a.Kind != b.Kind {
+			return importKindOrder[a.Kind] < importKindOrder[b.Kind]
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.SourceVersion < b.SourceVersion
+	})
+	result.Summary = make(map[ImportAction]int)
+	for _, item := range result.Items {
+		result.Summary[item.Action]++
+	}
+	return result, nil
+}
+
+var importKindOrder = map[string]int{"prompt": 0, "suite": 1, "deployment": 2}
+
+func groupPromptVersions(prompts []PromptTemplate) map[string][]PromptTemplate {
+	groups := make(map[string][]PromptTemplate)
+	for _, prompt := range prompts {
+		groups[prompt.Name] = append(groups[prompt.Name], prompt)
+	}
+	for _, versions := range groups {
+		sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	}
+	return groups
+}
+
+// planPromptImport decides each incoming version's action and target version.
+// skip and overwrite keep version numbers; new-version appends anything not
+// already present after the target's latest version. The returned map takes
+// source versions to target versions so deployments can follow them.
+func planPromptImport(existing, incoming []PromptTemplate, strategy ConflictStrategy) ([]PromptImportItem, map[int]int) {
+	byVersion := make(map[int]PromptTemplate, len(existing))
+	next := 1
+	for _, prompt := range existing {
+		byVersion[prompt.Version] = prompt
+		if prompt.Version >= next {
+			next = prompt.Version + 1
+		}
+	}
+
+	items := make([]PromptImportItem, 0, len(incoming))
+	versions := make(map[int]int, len(incoming))
+	for _, prompt := range incoming {
+		item := PromptImportItem{Kind: "prompt", Name: prompt.Name, SourceVersion: prompt.Version, Version: prompt.Version}
+		current, taken := byVersion[prompt.Version]
+		switch {
+		case strategy == ConflictNewVersion:
+			if match, ok := findSameContent(existing, prompt); ok {
+				item.Version, item.Action = match, ImportUnchanged
+			} else {
+				item.Version, item.Action = next, ImportCreated
+				next++
+			}
+		case !taken:
+			item.Action = ImportCreated
+		case sameContent(current, prompt):
+			item.Action = ImportUnchanged
+		case strategy == ConflictOverwrite:
+			item.Action = ImportOverwritten
+		default:
+			item.Action = ImportSkipped
+		}
+		versions[prompt.Version] = item.Version
+		items = append(items, item)
+	}
+	return items, versions
+}
+
+func findSameContent(existing []PromptTemplate, prompt PromptTemplate) (int, bool) {
+	for _, candidate := range existing {
+		if sameContent(candidate, prompt) {
+			return candidate.Version, true
+		}
+	}
+	return 0, false
+}
+
+// sameContent compares the content prompts are addressed by, ignoring version and metadata
+func sameContent(a, b PromptTemplate) bool {
+	left, err := a.CanonicalBytes()
+	if err != nil {
+		return false
+	}
+	right, err := b.CanonicalBytes()
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(left, right)
+}
+
+// planSuiteImport decides a suite's action; suites are not versioned, so
+// new-version merges the imported cases into the existing suite
+func planSuiteImport(existing *PromptSuite, suite PromptSuite, strategy ConflictStrategy) (PromptImportItem, []TestCase) {
+	item := PromptImportItem{Kind: "suite", Name: suite.Name}
+	switch {
+	case existing == nil:
+		item.Action = ImportCreated
+		return item, suite.Cases
+	case sameCases(existing.Cases, suite.Cases):
+		item.Action = ImportUnchanged
+	case strategy == ConflictOverwrite:
+		item.Action = ImportOverwritten
+		return item, suite.Cases
+	case strategy == ConflictNewVersion:
+		merged := mergeSuiteCases(existing.Cases, suite.Cases)
+		if len(merged) == len(existing.Cases) {
+			item.Action = ImportUnchanged
+			return item, nil
+		}
+		item.Action = ImportMerged
+		return item, merged
+	default:
+		item.Action = ImportSkipped
+	}
+	return item, nil
+}
+
+func sameCases(a, b []TestCase) bool {
+	left, errA := json.Marshal(a)
+	right, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(left, right)
+}
+
+// planDeploymentImport maps a deployment's versions into the target org and
+// decides whether it replaces the target's deployment
+func planDeploymentImport(existing *PromptDeployment, deployment PromptDeployment, versions map[int]int, strategy ConflictStrategy) (PromptImportItem, *PromptDeployment) {
+	planned := deployment
+	if version, ok := versions[deployment.StableVersion]; ok {
+		planned.StableVersion = version
+	}
+	if deployment.CanaryVersion != nil {
+		canary := *deployment.CanaryVersion
+		if version, ok := versions[canary]; ok {
+			canary = version
+		}
+		planned.CanaryVersion = &canary
+	}
+
+	item := PromptImportItem{Kind: "deployment", Name: deployment.PromptName, SourceVersion: deployment.StableVersion, Version: planned.StableVersion}
+	switch {
+	case existing == nil:
+		item.Action = ImportCreated
+	case sameDeployment(*existing, planned):
+		item.Action = ImportUnchanged
+	case strategy == ConflictSkip:
+		item.Action = ImportSkipped
+	default:
+		item.Action = ImportOverwritten
+	}
+	return item, &planned
+}
+
+func sameDeployment(a, b PromptDeployment) bool {
+	if a.StableVersion != b.StableVersion || a.CanaryRatio != b.CanaryRatio {
+		return false
+	}
+	if a.CanaryVersion == nil || b.CanaryVersion == nil {
+		return a.CanaryVersion == nil && b.CanaryVersion == nil
+	}
+	return *a.CanaryVersion == *b.CanaryVersion
+}
+
+func (s *Service) applyPromptImport(ctx context.Context, orgID, sourceOrgID uuid.UUID, incoming []PromptTemplate, items []PromptImportItem) error {
+	for i, item := range items {
+		if item.Action != ImportCreated && item.Action != ImportOverwritten {
+			continue
+		}
+		prompt := incoming[i]
+		prompt.ID, prompt.OrgID, prompt.Version, prompt.CreatedAt = uuid.New(), orgID, item.Version, time.Now()
+		metadata := make(Metadata, len(prompt.Metadata)+2)
+		for key, value := range prompt.Metadata {
+			metadata[key] = value
+		}
+		metadata["imported_from_org"] = sourceOrgID.String()
+		metadata["imported_from_version"] = item.SourceVersion
+		prompt.Metadata = metadata
+
+		content, err := prompt.CanonicalBytes()
+		if err != nil {
+			return err
+		}
+		if prompt.ContentHash, err = s.blobs.Put(ctx, orgID, db.BlobKindPromptTemplate, content); err != nil {
+			return fmt.Errorf("failed to store prompt content: %w", err)
+		}
+
+		if item.Action == ImportCreated {
+			err = s.savePromptTemplate(ctx, &prompt, nil)
+		} else {
+			err = s.overwritePromptTemplate(ctx, &prompt)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to import prompt %s v%d: %w", prompt.Name, item.Version, err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) overwritePromptTemplate(ctx context.Context, prompt *PromptTemplate) error {
+	schemaJSON, err := json.Marshal(prompt.Schema)
+	if err != nil {
+		return err
+	}
+	metadataJSON, err := json.Marshal(prompt.Metadata)
+	if err != nil {
+		return err
+	}
+
+	// The old version's signature does not cover the new content
+	query := `UPDATE prompt_template SET template = $4, schema = $5, metadata = $6, content_hash = $7, signature = NULL
+			  WHERE org_id = $1 AND name = $2 AND version = $3`
+	_, err = s.db.ExecContext(ctx, query,
+		prompt.OrgID, prompt.Name, prompt.Version, prompt.Template, schemaJSON, metadataJSON, prompt.ContentHash,
+	)
+	return err
+}
+
+func (s *Service) applySuiteImport(ctx context.Context, orgID uuid.UUID, suite PromptSuite, action ImportAction, cases []TestCase) error {
+	switch action {
+	case ImportCreated:
+		suite.ID, suite.OrgID, suite.CreatedAt = uuid.New(), orgID, time.Now()
+		if err := s.saveSuite(ctx, &suite); err != nil {
+			return fmt.Errorf("failed to import suite %s: %w", suite.Name, err)
+		}
+	case ImportOverwritten, ImportMerged:
+		casesJSON, err := json.Marshal(cases)
+		if err != nil {
+			return fmt.Errorf("failed to marshal suite cases: %w", err)
+		}
+		query := `UPDATE prompt_suite SET cases = $3, embedding_model = COALESCE(NULLIF($4, ''), embedding_model) WHERE org_id = $1 AND name = $2`
+		if _, err := s.db.ExecContext(ctx, query, orgID, suite.Name, casesJSON, suite.EmbeddingModel); err != nil {
+			return fmt.Errorf("failed to import suite %s: %w", suite.Name, err)
+		}
+	}
+	return nil
+}
+
+// listPromptVersions returns every version of the named prompts, or of all
+// the org's prompts when names is empty, ordered by name and version
+func (s *Service) listPromptVersions(ctx context.Context, orgID uuid.UUID, names []string) ([]PromptTemplate, error) {
+	query := `SELECT id, org_id, name, version, template, schema, metadata, created_at, COALESCE(content_hash, '')
+			  FROM prompt_template
+			  WHERE org_id = $1 AND (cardinality($2::text[]) = 0 OR name = ANY($2))
+			  ORDER BY name, version`
+
+	rows, err := s.db.QueryContext(ctx, query, orgID, pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt versions: %w", err)
+	}
+	defer rows.Close()
+
+	prompts := make([]PromptTemplate, 0)
+	for rows.Next() {
+		var prompt PromptTemplate
+		var schemaJSON, metadataJSON []byte
+		if err := rows.Scan(
+			&prompt.ID, &prompt.OrgID, &prompt.Name, &prompt.Version,
+			&prompt.Template, &schemaJSON, &metadataJSON, &prompt.CreatedAt, &prompt.ContentHash,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan prompt template: %w", err)
+		}
+		if err := json.Unmarshal(schemaJSON, &prompt.Schema); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal schema: %w", err)
+		}
+		if err := json.Unmarshal(metadataJSON, &prompt.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+		prompts = append(prompts, prompt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list prompt versions: %w", err)
+	}
+	return prompts, nil
+}
@@ -0,0 +1,81 @@
+package pop
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// feedbackCaseThreshold is the similarity a new output needs to a reviewer's
+// correction for a feedback case to pass
+const feedbackCaseThreshold = 0.8
+
+// FeedbackTestCase turns a reviewer's corrected output for a step into an
+// eval case. Outputs are scored by similarity to the correction rather than
+// exact match, since a correction is one good answer among many.
+func FeedbackTestCase(stepID uuid.UUID, inputs map[string]interface{}, correction string) TestCase {
+	return TestCase{
+		ID:       "feedback-" + stepID.String(),
+		Input:    inputs,
+		Expected: Expected{Output: correction},
+		Scoring: ScoringConfig{
+			Type:   ScoringEmbedding,
+			Config: map[string]interface{}{"threshold": feedbackCaseThreshold},
+		},
+	}
+}
+
+// AddSuiteCases appends cases to a suite, creating it if needed. Cases whose
+// ID is already in the suite are skipped, so feedback can be re-imported safely.
+// It returns the number of cases added.
+func (s *Service) AddSuiteCases(ctx context.Context, orgID uuid.UUID, name string, cases []TestCase) (int, error) {
+	suite, err := s.getSuite(ctx, orgID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		merged := mergeSuiteCases(nil, cases)
+		if _, err := s.CreateSuite(ctx, orgID, name, merged); err != nil {
+			return 0, err
+		}
+		return len(merged), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get suite: %w", err)
+	}
+
+	merged := mergeSuiteCases(suite.Cases, cases)
+	added := len(merged) - len(suite.Cases)
+	if added == 0 {
+		return 0, nil
+	}
+
+	casesJSON, err := json.Marshal(merged)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal cases: %w", err)
+	}
+	query := `UPDATE prompt_suite SET cases = $3 WHERE org_id = $1 AND name = $2`
+	if _, err := s.db.ExecContext(ctx, query, orgID, name, casesJSON); err != nil {
+		return 0, fmt.Errorf("failed to update suite: %w", err)
+	}
+	return added, nil
+}
+
+// mergeSuiteCases appends cases whose IDs are not already present
+func mergeSuiteCases(existing, cases []TestCase) []TestCase {
+	seen := make(map[string]bool, len(existing)+len(cases))
+	merged := make([]TestCase, 0, len(existing)+len(cases))
+	for _, tc := range existing {
+		seen[tc.ID] = true
+		merged = append(merged, tc)
+	}
+	for _, tc := range cases {
+		if seen[tc.ID] {
+			continue
+		}
+		seen[tc.ID] = true
+		merged = append(merged, tc)
+	}
+	return merged
+}
@@ -0,0 +1,145 @@
+package pop
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// defaultEvalHistoryLimit caps the runs returned when no limit is given
+const defaultEvalHistoryLimit = 200
+
+// saveEvaluationRun persists a finished run and its per-case results
+func (s *Service) saveEvaluationRun(ctx context.Context, run *EvaluationRun) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `INSERT INTO evaluation_run (id, org_id, prompt_id, prompt_name, prompt_version, suite_id,
+			  suite_name, model, status, total_cases, passed_cases, average_score, total_cost_cents,
+			  started_at, completed_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
+
+	_, err = tx.ExecContext(ctx, query,
+		run.ID, run.OrgID, run.PromptID, run.PromptName, run.PromptVersion, run.SuiteID,
+		run.SuiteName, run.Model, run.Status, run.Summary.TotalCases, run.Summary.PassedCases,
+		run.Summary.AverageScore, run.Summary.TotalCost, run.StartedAt, run.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert evaluation run: %w", err)
+	}
+
+	caseQuery := `INSERT INTO evaluation_case_result (run_id, case_id, score, passed, error,
+				  latency_ms, cost_cents, embedding_cost_cents)
+				  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	for _, result := range run.Results {
+		_, err := tx.ExecContext(ctx, caseQuery,
+			run.ID, result.CaseID, result.Score, result.Passed, result.Error,
+			result.Latency.Milliseconds(), result.CostCents, result.EmbeddingCostCents,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert case result: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit evaluation run: %w", err)
+	}
+
+	return nil
+}
+
+// GetEvalHistory returns a prompt's completed evaluation runs oldest first
+// with per-version score trends
+func (s *Service) GetEvalHistory(ctx context.Context, orgID uuid.UUID, promptName string, q EvalHistoryQuery) (*EvalHistory, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultEvalHistoryLimit
+	}
+
+	query := `SELECT id, prompt_version, suite_name, COALESCE(model, ''), total_cases, passed_cases,
+			  average_score, total_cost_cents, completed_at
+			  FROM evaluation_run
+			  WHERE org_id = $1 AND prompt_name = $2 AND status = $3
+			  AND ($4 = '' OR suite_name = $4) AND completed_at >= $5
+			  ORDER BY completed_at DESC
+			  LIMIT $6`
+
+	rows, err := s.db.QueryContext(ctx, query, orgID, promptName, EvaluationStatusCompleted, q.SuiteName, q.Since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query evaluation history: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	points := make([]EvalHistoryPoint, 0)
+	for rows.Next() {
+		var point EvalHistoryPoint
+		var passed int
+		err := rows.Scan(&point.RunID, &point.PromptVersion, &point.SuiteName, &point.Model, &point.TotalCases,
+			&passed, &point.AverageScore, &point.CostCents, &point.CompletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan evaluation run: %w", err)
+		}
+		if point.TotalCases > 0 {
+			point.PassRate = float64(passed) / float64(point.TotalCases)
+		}
+		points = append(points, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Rows are fetched newest first so the limit keeps recent runs
+	sort.SliceStable(points, func(i, j int) bool { return points[i].CompletedAt.Before(points[j].CompletedAt) })
+
+	return &EvalHistory{
+		PromptName: promptName,
+		Points:     points,
+		Versions:   buildVersionTrends(points),
+	}, nil
+}
+
+// buildVersionTrends aggregates history points per prompt version
+func buildVersionTrends(points []EvalHistoryPoint) []VersionTrend {
+	byVersion := make(map[int]*VersionTrend)
+	passRates := make(map[int]float64)
+
+	for _, point := range points {
+		trend, ok := byVersion[point.PromptVersion]
+		if !ok {
+			trend = &VersionTrend{PromptVersion: point.PromptVersion, MinScore: point.AverageScore, MaxScore: point.AverageScore}
+			byVersion[point.PromptVersion] = trend
+		}
+		trend.Runs++
+		trend.AverageScore += point.AverageScore
+		passRates[point.PromptVersion] += point.PassRate
+		if point.AverageScore < trend.MinScore {
+			trend.MinScore = point.AverageScore
+		}
+		if point.AverageScore > trend.MaxScore {
+			trend.MaxScore = point.AverageScore
+		}
+		if point.CompletedAt.After(trend.LastRunAt) {
+			trend.LastRunAt = point.CompletedAt
+		}
+	}
+
+	trends := make([]VersionTrend, 0, len(byVersion))
+	for version, trend := range byVersion {
+		trend.AverageScore /= float64(trend.Runs)
+		trend.PassRate = passRates[version] / float64(trend.Runs)
+		trends = append(trends, *trend)
+	}
+	sort.Slice(trends, func(i, j int) bool { return trends[i].PromptVersion < trends[j].PromptVersion })
+
+	for i := 1; i < len(trends); i++ {
+		trends[i].ScoreDelta = trends[i].AverageScore - trends[i-1].AverageScore
+	}
+
+	return trends
+}
@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"strings"
 	"text/template"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
 )
 
+// chunksKey is the template data key holding context chunks
+const chunksKey = "chunks"
+
 type TemplateRenderer struct {
 	funcMap template.FuncMap
 }
@@ -21,6 +26,7 @@ func NewTemplateRenderer() *TemplateRenderer {
 			"join":     strings.Join,
 			"contains": strings.Contains,
 			"default":  defaultValue,
+			"fence":    scl.FenceChunk,
 		},
 	}
 }
@@ -35,6 +41,9 @@ func (r *TemplateRenderer) Validate(templateText string, schema Schema) error {
 
 	// Create dummy data based on schema
 	dummyData := r.createDummyData(schema)
+	if _, ok := dummyData[chunksKey]; !ok {
+		dummyData[chunksKey] = []scl.ContextChunk{{ID: "chunk_0", Content: "test_chunk", TrustTier: scl.TrustTierLow}}
+	}
 
 	// Try to execute template with dummy data
 	var buf bytes.Buffer
@@ -65,6 +74,42 @@ func (r *TemplateRenderer) Render(templateText string, data map[string]interface
 	return buf.String(), nil
 }
 
+// RenderWithContext renders the system and user templates with context
+// chunks available as .chunks. Low-trust chunks must go through fence in the
+// user template; rendering fails if one lands anywhere else.
+func (r *TemplateRenderer) RenderWithContext(systemText, templateText string, data map[string]interface{}, chunks []scl.ContextChunk) (string, string, error) {
+	if chunks == nil {
+		chunks = []scl.ContextChunk{}
+	}
+	if _, ok := data[chunksKey]; ok && len(chunks) > 0 {
+		return "", "", fmt.Errorf("input %q is reserved for context chunks", chunksKey)
+	}
+	withChunks := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		withChunks[k] = v
+	}
+	if _, ok := withChunks[chunksKey]; !ok {
+		withChunks[chunksKey] = chunks
+	}
+
+	var system string
+	if systemText != "" {
+		var err error
+		if system, err = r.Render(systemText, withChunks); err != nil {
+			return "", "", fmt.Errorf("system %w", err)
+		}
+	}
+	user, err := r.Render(templateText, withChunks)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := scl.CheckChunkPlacement(system, user, chunks); err != nil {
+		return "", "", fmt.Errorf("trust placement violation: %w", err)
+	}
+	return system, user, nil
+}
+
 // createDummyData creates dummy data for template validation
 func (r *TemplateRenderer) createDummyData(schema Schema) map[string]interface{} {
 	data := make(map[string]interface{})
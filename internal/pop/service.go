@@ -6,6 +6,8 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
@@ -18,19 +20,43 @@ type Service struct {
 	db        *db.PostgresDB
 	renderer  *TemplateRenderer
 	evaluator *Evaluator
+	blobs     *db.BlobStore
+	listener  DeploymentListener
+}
+
+// DeploymentListener is told about every deployment change. The service has
+// no event bus of its own, so the control plane attaches one.
+type DeploymentListener interface {
+	PromptDeployed(ctx context.Context, deployment *PromptDeployment)
+}
+
+// SetDeploymentListener attaches the listener deployment changes are reported to
+func (s *Service) SetDeploymentListener(listener DeploymentListener) {
+	s.listener = listener
 }
 
 func NewService(cfg *config.Config, database *db.PostgresDB) *Service {
+	evaluator := NewEvaluator(database)
+	if cfg != nil && cfg.Embeddings.APIKey != "" {
+		evaluator.embeddings = NewEmbeddingScorer(NewHTTPEmbeddingClient(cfg.Embeddings), cfg.Embeddings.Model)
+	}
+
 	return &Service{
 		cfg:       cfg,
 		db:        database,
 		renderer:  NewTemplateRenderer(),
-		evaluator: NewEvaluator(database),
+		evaluator: evaluator,
+		blobs:     db.NewBlobStore(database),
 	}
 }
 
-// CreatePromptVersion creates a new version of a prompt template
+// CreatePromptVersion creates a new version of a prompt template. When the
+// control plane requires signatures, only verified signed prompts are accepted.
 func (s *Service) CreatePromptVersion(ctx context.Context, orgID uuid.UUID, req *CreatePromptRequest) (*PromptTemplate, error) {
+	if s.signaturesRequired() && len(req.Signature) == 0 {
+		return nil, fmt.Errorf("prompt %s is unsigned; this control plane only accepts signed prompts through apply", req.Name)
+	}
+
 	// Get next version number
 	nextVersion, err := s.getNextVersion(ctx, orgID, req.Name)
 	if err != nil {
@@ -54,16 +80,47 @@ func (s *Service) CreatePromptVersion(ctx context.Context, orgID uuid.UUID, req
 		CreatedAt: time.Now(),
 	}
 
-	if err := s.savePromptTemplate(ctx, prompt); err != nil {
+	// Store the template content-addressed so runs can pin the exact bytes
+	content, err := prompt.CanonicalBytes()
+	if err != nil {
+		return nil, err
+	}
+	if prompt.ContentHash, err = s.blobs.Put(ctx, orgID, db.BlobKindPromptTemplate, content); err != nil {
+		return nil, fmt.Errorf("failed to store prompt content: %w", err)
+	}
+
+	if err := s.savePromptTemplate(ctx, prompt, req.Signature); err != nil {
 		return nil, fmt.Errorf("failed to save prompt template: %w", err)
 	}
 
 	return prompt, nil
 }
 
+// signaturesRequired reports whether prompts must be signed to be stored
+func (s *Service) signaturesRequired() bool {
+	return s.cfg != nil && s.cfg.Signing.RequireSignatures
+}
+
+// GetPromptByHash retrieves the exact prompt content stored under a hash
+func (s *Service) GetPromptByHash(ctx context.Context, orgID uuid.UUID, hash string) (*PromptTemplate, error) {
+	blob, err := s.blobs.Get(ctx, orgID, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var prompt PromptTemplate
+	if err := json.Unmarshal(blob.Content, &prompt); err != nil {
+		return nil, fmt.Errorf("failed to decode prompt content: %w", err)
+	}
+	prompt.OrgID = orgID
+	prompt.ContentHash = blob.Hash
+
+	return &prompt, nil
+}
+
 // GetPromptTemplate retrieves a specific prompt template version
 func (s *Service) GetPromptTemplate(ctx context.Context, orgID uuid.UUID, name string, version int) (*PromptTemplate, error) {
-	query := `SELECT id, org_id, name, version, template, schema, metadata, created_at
+	query := `SELECT id, org_id, name, version, template, schema, metadata, created_at, COALESCE(content_hash, '')
 			  FROM prompt_template 
 			  WHERE org_id = $1 AND name = $2 AND version = $3`
 
@@ -72,7 +129,7 @@ func (s *Service) GetPromptTemplate(ctx context.Context, orgID uuid.UUID, name s
 
 	err := s.db.QueryRowContext(ctx, query, orgID, name, version).Scan(
 		&prompt.ID, &prompt.OrgID, &prompt.Name, &prompt.Version,
-		&prompt.Template, &schemaJSON, &metadataJSON, &prompt.CreatedAt,
+		&prompt.Template, &schemaJSON, &metadataJSON, &prompt.CreatedAt, &prompt.ContentHash,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get prompt template: %w", err)
@@ -89,6 +146,83 @@ func (s *Service) GetPromptTemplate(ctx context.Context, orgID uuid.UUID, name s
 	return &prompt, nil
 }
 
+// promptFilterFields are the fields a prompt filter expression can reference
+var promptFilterFields = map[string]db.FilterField{
+	"name":       {Column: "name", Type: db.FilterString},
+	"version":    {Column: "version", Type: db.FilterInt},
+	"created_at": {Column: "created_at", Type: db.FilterTime},
+}
+
+// ListPrompts returns an org's prompt versions, newest first, using keyset pagination
+func (s *Service) ListPrompts(ctx context.Context, orgID uuid.UUID, opts db.ListOptions) (*PromptPage, error) {
+	clauses, err := db.ParseFilter(opts.Filter, promptFilterFields)
+	if err != nil {
+		return nil, err
+	}
+	conditions, args := db.PostgresConditions(clauses, []interface{}{orgID})
+	conditions = append([]string{"org_id = $1"}, conditions...)
+
+	total, err := s.db.EstimateTotal(ctx, `SELECT 1 FROM prompt_template WHERE `+strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Cursor != "" {
+		createdAt, id, err := db.DecodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, createdAt, id)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	limit := opts.PageSize()
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`SELECT id, org_id, name, version, template, schema, metadata, created_at, COALESCE(content_hash, '')
+			  FROM prompt_template
+			  WHERE %s
+			  ORDER BY created_at DESC, id DESC
+			  LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+	defer rows.Close()
+
+	page := &PromptPage{Prompts: make([]PromptTemplate, 0, limit)}
+	for rows.Next() {
+		var prompt PromptTemplate
+		var schemaJSON, metadataJSON []byte
+		if err := rows.Scan(
+			&prompt.ID, &prompt.OrgID, &prompt.Name, &prompt.Version,
+			&prompt.Template, &schemaJSON, &metadataJSON, &prompt.CreatedAt, &prompt.ContentHash,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan prompt template: %w", err)
+		}
+		if err := json.Unmarshal(schemaJSON, &prompt.Schema); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal schema: %w", err)
+		}
+		if err := json.Unmarshal(metadataJSON, &prompt.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+		page.Prompts = append(page.Prompts, prompt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+
+	// One extra row is fetched to learn whether another page exists
+	if len(page.Prompts) > limit {
+		page.Prompts = page.Prompts[:limit]
+		last := page.Prompts[limit-1]
+		page.NextCursor = db.EncodeCursor(last.CreatedAt, last.ID)
+	}
+	page.TotalEstimate = total
+
+	return page, nil
+}
+
 // ResolvePrompt resolves a prompt request to a rendered prompt
 func (s *Service) ResolvePrompt(ctx context.Context, orgID uuid.UUID, req *PromptRequest) (*PromptResponse, error) {
 	// Determine version to use
@@ -126,23 +260,26 @@ func (s *Service) ResolvePrompt(ctx context.Context, orgID uuid.UUID, req *Promp
 		return nil, fmt.Errorf("input validation failed: %w", err)
 	}
 
-	// Render template
-	renderedText, err := s.renderer.Render(prompt.Template, req.Inputs)
+	// Render template, keeping low-trust context fenced and out of the system message
+	systemTemplate, _ := prompt.Metadata["system"].(string)
+	systemText, renderedText, err := s.renderer.RenderWithContext(systemTemplate, prompt.Template, req.Inputs, req.Chunks)
 	if err != nil {
 		return nil, fmt.Errorf("template rendering failed: %w", err)
 	}
 
 	// Estimate token count
-	tokenCount := s.estimateTokens(renderedText)
+	tokenCount := s.estimateTokens(systemText + renderedText)
 
 	return &PromptResponse{
 		ID:           prompt.ID,
 		Name:         prompt.Name,
 		Version:      prompt.Version,
+		SystemText:   systemText,
 		RenderedText: renderedText,
 		Metadata:     prompt.Metadata,
 		TokenCount:   tokenCount,
 		IsCanary:     isCanary,
+		ContentHash:  prompt.ContentHash,
 	}, nil
 }
 
@@ -163,6 +300,27 @@ func (s *Service) CreateSuite(ctx context.Context, orgID uuid.UUID, name string,
 	return suite, nil
 }
 
+// SetSuiteEmbeddingModel selects the embedding model a suite's embedding
+// scorers use unless a case overrides it
+func (s *Service) SetSuiteEmbeddingModel(ctx context.Context, orgID uuid.UUID, name, model string) error {
+	query := `UPDATE prompt_suite SET embedding_model = $3 WHERE org_id = $1 AND name = $2`
+
+	result, err := s.db.ExecContext(ctx, query, orgID, name, model)
+	if err != nil {
+		return fmt.Errorf("failed to update suite: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("suite %s not found", name)
+	}
+
+	return nil
+}
+
 // EvaluatePrompt runs an evaluation suite against a prompt version
 func (s *Service) EvaluatePrompt(ctx context.Context, orgID uuid.UUID, req *EvaluateRequest) (*EvaluationRun, error) {
 	// Get prompt template
@@ -179,11 +337,16 @@ func (s *Service) EvaluatePrompt(ctx context.Context, orgID uuid.UUID, req *Eval
 
 	// Run evaluation
 	evalRun := &EvaluationRun{
-		ID:        uuid.New(),
-		PromptID:  prompt.ID,
-		SuiteID:   suite.ID,
-		Status:    EvaluationStatusQueued,
-		StartedAt: time.Now(),
+		ID:            uuid.New(),
+		OrgID:         orgID,
+		PromptID:      prompt.ID,
+		PromptName:    prompt.Name,
+		PromptVersion: prompt.Version,
+		SuiteID:       suite.ID,
+		SuiteName:     suite.Name,
+		Model:         req.Model,
+		Status:        EvaluationStatusQueued,
+		StartedAt:     time.Now(),
 	}
 
 	// Execute evaluation asynchronously
@@ -221,6 +384,7 @@ func (s *Service) UpdateDeployment(ctx context.Context, orgID uuid.UUID, req *De
 	if err := s.saveDeployment(ctx, deployment); err != nil {
 		return nil, fmt.Errorf("failed to save deployment: %w", err)
 	}
+	s.notifyDeployed(ctx, deployment)
 
 	return deployment, nil
 }
@@ -239,7 +403,7 @@ func (s *Service) getNextVersion(ctx context.Context, orgID uuid.UUID, name stri
 	return nextVersion, nil
 }
 
-func (s *Service) savePromptTemplate(ctx context.Context, prompt *PromptTemplate) error {
+func (s *Service) savePromptTemplate(ctx context.Context, prompt *PromptTemplate, signature json.RawMessage) error {
 	schemaJSON, err := json.Marshal(prompt.Schema)
 	if err != nil {
 		return err
@@ -250,12 +414,17 @@ func (s *Service) savePromptTemplate(ctx context.Context, prompt *PromptTemplate
 		return err
 	}
 
-	query := `INSERT INTO prompt_template (id, org_id, name, version, template, schema, metadata, created_at)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	var signatureJSON interface{} // NULL for unsigned prompts
+	if len(signature) > 0 {
+		signatureJSON = []byte(signature)
+	}
+
+	query := `INSERT INTO prompt_template (id, org_id, name, version, template, schema, metadata, created_at, content_hash, signature)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
 
 	_, err = s.db.ExecContext(ctx, query,
 		prompt.ID, prompt.OrgID, prompt.Name, prompt.Version,
-		prompt.Template, schemaJSON, metadataJSON, prompt.CreatedAt,
+		prompt.Template, schemaJSON, metadataJSON, prompt.CreatedAt, prompt.ContentHash, signatureJSON,
 	)
 	return err
 }
@@ -285,23 +454,24 @@ func (s *Service) saveSuite(ctx context.Context, suite *PromptSuite) error {
 		return err
 	}
 
-	query := `INSERT INTO prompt_suite (id, org_id, name, cases, created_at)
-			  VALUES ($1, $2, $3, $4, $5)`
+	query := `INSERT INTO prompt_suite (id, org_id, name, cases, embedding_model, created_at)
+			  VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)`
 
 	_, err = s.db.ExecContext(ctx, query,
-		suite.ID, suite.OrgID, suite.Name, casesJSON, suite.CreatedAt,
+		suite.ID, suite.OrgID, suite.Name, casesJSON, suite.EmbeddingModel, suite.CreatedAt,
 	)
 	return err
 }
 
 func (s *Service) getSuite(ctx context.Context, orgID uuid.UUID, name string) (*PromptSuite, error) {
-	query := `SELECT id, org_id, name, cases, created_at FROM prompt_suite WHERE org_id = $1 AND name = $2`
+	query := `SELECT id, org_id, name, cases, COALESCE(embedding_model, ''), created_at
+			  FROM prompt_suite WHERE org_id = $1 AND name = $2`
 
 	var suite PromptSuite
 	var casesJSON []byte
 
 	err := s.db.QueryRowContext(ctx, query, orgID, name).Scan(
-		&suite.ID, &suite.OrgID, &suite.Name, &casesJSON, &suite.CreatedAt,
+		&suite.ID, &suite.OrgID, &suite.Name, &casesJSON, &suite.EmbeddingModel, &suite.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -314,6 +484,12 @@ func (s *Service) getSuite(ctx context.Context, orgID uuid.UUID, name string) (*
 	return &suite, nil
 }
 
+func (s *Service) notifyDeployed(ctx context.Context, deployment *PromptDeployment) {
+	if s.listener != nil {
+		s.listener.PromptDeployed(ctx, deployment)
+	}
+}
+
 func (s *Service) saveDeployment(ctx context.Context, deployment *PromptDeployment) error {
 	query := `INSERT INTO prompt_deployment (id, org_id, prompt_name, stable_version, canary_version, canary_ratio, created_at, updated_at)
 			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
@@ -344,11 +520,34 @@ func (s *Service) estimateTokens(text string) int {
 }
 
 func (s *Service) executeEvaluation(ctx context.Context, evalRun *EvaluationRun, prompt *PromptTemplate, suite *PromptSuite, parallel int) {
-	// This would be implemented to actually run the evaluation
-	// For now, just mark as completed
+	evalRun.Status = EvaluationStatusRunning
+
+	results := make([]EvaluationResult, 0, len(suite.Cases))
+	for _, testCase := range suite.Cases {
+		// Mock output - in production would execute the rendered prompt against a provider
+		output, err := s.renderer.Render(prompt.Template, testCase.Input)
+		if err != nil {
+			results = append(results, EvaluationResult{CaseID: testCase.ID, Input: testCase.Input, Error: err.Error()})
+			continue
+		}
+
+		result, err := s.evaluator.EvaluateCase(ctx, testCase, output, suite.EmbeddingModel)
+		if err != nil {
+			results = append(results, EvaluationResult{CaseID: testCase.ID, Input: testCase.Input, Error: err.Error()})
+			continue
+		}
+		results = append(results, *result)
+	}
+
+	evalRun.Results = results
+	evalRun.Summary = SummarizeResults(results)
 	evalRun.Status = EvaluationStatusCompleted
 	now := time.Now()
 	evalRun.CompletedAt = &now
+
+	if err := s.saveEvaluationRun(ctx, evalRun); err != nil {
+		log.Printf("Failed to save evaluation run %s: %v", evalRun.ID, err)
+	}
 }
 
 // secureRandFloat64 generates a cryptographically secure random float64 between 0 and 1
@@ -362,3 +561,18 @@ func secureRandFloat64() float64 {
 	// Convert to uint64 and normalize to [0,1)
 	return float64(binary.BigEndian.Uint64(b[:])) / float64(1<<64)
 }
+
+// CanonicalBytes returns the template content a prompt version is addressed by
+func (p *PromptTemplate) CanonicalBytes() ([]byte, error) {
+	canonical := struct {
+		Name     string `json:"name"`
+		Template string `json:"template"`
+		Schema   Schema `json:"schema"`
+	}{p.Name, p.Template, p.Schema}
+
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal prompt: %w", err)
+	}
+	return data, nil
+}
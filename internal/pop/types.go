@@ -1,8 +1,11 @@
 package pop
 
 import (
+	"encoding/json"
 	"time"
 
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/scl"
 	"github.com/google/uuid"
 )
 
@@ -16,6 +19,14 @@ type PromptTemplate struct {
 	Schema    Schema    `json:"schema" db:"schema"`
 	Metadata  Metadata  `json:"metadata" db:"metadata"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	ContentHash string `json:"content_hash,omitempty" db:"content_hash"`
+}
+
+// PromptPage is one page of prompt versions, newest first
+type PromptPage struct {
+	Prompts []PromptTemplate `json:"prompts"`
+	db.PageMeta
 }
 
 // Schema defines the input schema for a prompt template
@@ -35,11 +46,12 @@ type Property struct {
 
 // PromptSuite represents an evaluation suite
 type PromptSuite struct {
-	ID        uuid.UUID  `json:"id" db:"id"`
-	OrgID     uuid.UUID  `json:"org_id" db:"org_id"`
-	Name      string     `json:"name" db:"name"`
-	Cases     []TestCase `json:"cases" db:"cases"`
-	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ID             uuid.UUID  `json:"id" db:"id"`
+	OrgID          uuid.UUID  `json:"org_id" db:"org_id"`
+	Name           string     `json:"name" db:"name"`
+	Cases          []TestCase `json:"cases" db:"cases"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	EmbeddingModel string     `json:"embedding_model,omitempty" db:"embedding_model"`
 }
 
 // TestCase represents a single test case in an evaluation suite
@@ -88,14 +100,19 @@ type PromptDeployment struct {
 
 // EvaluationRun represents a single evaluation execution
 type EvaluationRun struct {
-	ID          uuid.UUID          `json:"id"`
-	PromptID    uuid.UUID          `json:"prompt_id"`
-	SuiteID     uuid.UUID          `json:"suite_id"`
-	Status      EvaluationStatus   `json:"status"`
-	Results     []EvaluationResult `json:"results"`
-	Summary     EvaluationSummary  `json:"summary"`
-	StartedAt   time.Time          `json:"started_at"`
-	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+	ID            uuid.UUID          `json:"id"`
+	OrgID         uuid.UUID          `json:"org_id"`
+	PromptID      uuid.UUID          `json:"prompt_id"`
+	PromptName    string             `json:"prompt_name"`
+	PromptVersion int                `json:"prompt_version"`
+	SuiteID       uuid.UUID          `json:"suite_id"`
+	SuiteName     string             `json:"suite_name"`
+	Model         string             `json:"model,omitempty"`
+	Status        EvaluationStatus   `json:"status"`
+	Results       []EvaluationResult `json:"results"`
+	Summary       EvaluationSummary  `json:"summary"`
+	StartedAt     time.Time          `json:"started_at"`
+	CompletedAt   *time.Time         `json:"completed_at,omitempty"`
 }
 
 type EvaluationStatus string
@@ -108,24 +125,26 @@ const (
 )
 
 type EvaluationResult struct {
-	CaseID    string                 `json:"case_id"`
-	Input     map[string]interface{} `json:"input"`
-	Output    interface{}            `json:"output"`
-	Expected  Expected               `json:"expected"`
-	Score     float64                `json:"score"`
-	Passed    bool                   `json:"passed"`
-	Error     string                 `json:"error,omitempty"`
-	Latency   time.Duration          `json:"latency"`
-	CostCents int64                  `json:"cost_cents"`
+	CaseID             string                 `json:"case_id"`
+	Input              map[string]interface{} `json:"input"`
+	Output             interface{}            `json:"output"`
+	Expected           Expected               `json:"expected"`
+	Score              float64                `json:"score"`
+	Passed             bool                   `json:"passed"`
+	Error              string                 `json:"error,omitempty"`
+	Latency            time.Duration          `json:"latency"`
+	CostCents          int64                  `json:"cost_cents"`
+	EmbeddingCostCents float64                `json:"embedding_cost_cents,omitempty"`
 }
 
 type EvaluationSummary struct {
-	TotalCases     int           `json:"total_cases"`
-	PassedCases    int           `json:"passed_cases"`
-	FailedCases    int           `json:"failed_cases"`
-	AverageScore   float64       `json:"average_score"`
-	TotalCost      int64         `json:"total_cost_cents"`
-	AverageLatency time.Duration `json:"average_latency"`
+	TotalCases         int           `json:"total_cases"`
+	PassedCases        int           `json:"passed_cases"`
+	FailedCases        int           `json:"failed_cases"`
+	AverageScore       float64       `json:"average_score"`
+	TotalCost          int64         `json:"total_cost_cents"`
+	AverageLatency     time.Duration `json:"average_latency"`
+	EmbeddingCostCents float64       `json:"embedding_cost_cents,omitempty"`
 }
 
 // PromptRequest represents a request to resolve a prompt
@@ -135,6 +154,7 @@ type PromptRequest struct {
 	Inputs   map[string]interface{} `json:"inputs"`
 	Context  map[string]interface{} `json:"context,omitempty"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Chunks   []scl.ContextChunk     `json:"chunks,omitempty"` // Prepared context, rendered as .chunks
 }
 
 // PromptResponse represents a resolved prompt
@@ -142,10 +162,12 @@ type PromptResponse struct {
 	ID           uuid.UUID              `json:"id"`
 	Name         string                 `json:"name"`
 	Version      int                    `json:"version"`
+	SystemText   string                 `json:"system_text,omitempty"` // Rendered from the "system" metadata template
 	RenderedText string                 `json:"rendered_text"`
 	Metadata     map[string]interface{} `json:"metadata"`
 	TokenCount   int                    `json:"token_count"`
 	IsCanary     bool                   `json:"is_canary"`
+	ContentHash  string                 `json:"content_hash,omitempty"`
 }
 
 // Metadata is a flexible JSON field
@@ -157,6 +179,10 @@ type CreatePromptRequest struct {
 	Template string   `json:"template"`
 	Schema   Schema   `json:"schema"`
 	Metadata Metadata `json:"metadata,omitempty"`
+
+	// Signature is the verified signature record of a signed apply; it is
+	// never read from request bodies
+	Signature json.RawMessage `json:"-"`
 }
 
 // EvaluateRequest represents a request to evaluate a prompt
@@ -164,9 +190,49 @@ type EvaluateRequest struct {
 	PromptName    string `json:"prompt_name"`
 	PromptVersion int    `json:"prompt_version"`
 	SuiteName     string `json:"suite_name"`
+	Model         string `json:"model,omitempty"`
 	Parallel      int    `json:"parallel,omitempty"`
 }
 
+// EvalHistoryQuery filters a prompt's evaluation history
+type EvalHistoryQuery struct {
+	SuiteName string    `json:"suite_name,omitempty"`
+	Since     time.Time `json:"since,omitempty"`
+	Limit     int       `json:"limit,omitempty"`
+}
+
+// EvalHistoryPoint is one completed evaluation run on the trend line
+type EvalHistoryPoint struct {
+	RunID         uuid.UUID `json:"run_id"`
+	PromptVersion int       `json:"prompt_version"`
+	SuiteName     string    `json:"suite_name"`
+	Model         string    `json:"model,omitempty"`
+	TotalCases    int       `json:"total_cases"`
+	PassRate      float64   `json:"pass_rate"`
+	AverageScore  float64   `json:"average_score"`
+	CostCents     int64     `json:"cost_cents"`
+	CompletedAt   time.Time `json:"completed_at"`
+}
+
+// VersionTrend aggregates evaluation runs of one prompt version
+type VersionTrend struct {
+	PromptVersion int       `json:"prompt_version"`
+	Runs          int       `json:"runs"`
+	AverageScore  float64   `json:"average_score"`
+	MinScore      float64   `json:"min_score"`
+	MaxScore      float64   `json:"max_score"`
+	PassRate      float64   `json:"pass_rate"`
+	ScoreDelta    float64   `json:"score_delta"` // Versus the previous version
+	LastRunAt     time.Time `json:"last_run_at"`
+}
+
+// EvalHistory is a prompt's score trend over time and versions
+type EvalHistory struct {
+	PromptName string             `json:"prompt_name"`
+	Points     []EvalHistoryPoint `json:"points"`
+	Versions   []VersionTrend     `json:"versions"`
+}
+
 // DeploymentRequest represents a request to update deployment
 type DeploymentRequest struct {
 	PromptName    string  `json:"prompt_name"`
@@ -0,0 +1,163 @@
+package scl
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// compressKeepRunes is the shortest a trimmed string is cut down to
+	compressKeepRunes = 128
+	// maxCompressPasses bounds the trim loop on pathological content
+	maxCompressPasses = 1000
+)
+
+// CompressionResult reports what compressing content to a token budget changed
+type CompressionResult struct {
+	OriginalTokens int  `json:"original_tokens"`
+	Tokens         int  `json:"tokens"`
+	TrimmedFields  int  `json:"trimmed_fields"`
+	DroppedItems   int  `json:"dropped_items"`
+	Fits           bool `json:"fits"`
+}
+
+// Compressor shrinks context to fit a token budget, first by collapsing
+// whitespace, then by trimming the longest strings and dropping trailing
+// list items. It never modifies the content it is given.
+type Compressor struct {
+	whitespace *regexp.Regexp
+}
+
+func NewCompressor() *Compressor {
+	return &Compressor{whitespace: regexp.MustCompile(`[ \t]+`)}
+}
+
+// EstimateTokens approximates the tokens content takes up in a prompt
+func (c *Compressor) EstimateTokens(content interface{}) int {
+	if s, ok := content.(string); ok {
+		return len(s) / 4
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return 0
+	}
+	return len(data) / 4 // Rough approximation
+}
+
+// Compress returns a copy of content that fits in maxTokens where possible
+func (c *Compressor) Compress(content interface{}, maxTokens int) (interface{}, *CompressionResult) {
+	result := &CompressionResult{OriginalTokens: c.EstimateTokens(content)}
+	root := c.collapse(content)
+	result.Tokens = c.EstimateTokens(root)
+
+	for pass := 0; pass < maxCompressPasses && result.Tokens > maxTokens; pass++ {
+		excess := (result.Tokens - maxTokens) * 4
+		if c.trimLongest(&root, excess) {
+			result.TrimmedFields++
+		} else if c.dropTrailing(&root) {
+			result.DroppedItems++
+		} else {
+			break
+		}
+		result.Tokens = c.EstimateTokens(root)
+	}
+
+	result.Fits = result.Tokens <= maxTokens
+	return root, result
+}
+
+// collapse deep-copies content, collapsing runs of blank space and blank lines
+func (c *Compressor) collapse(content interface{}) interface{} {
+	switch v := content.(type) {
+	case string:
+		lines := strings.Split(v, "\n")
+		kept := make([]string, 0, len(lines))
+		for _, line := range lines {
+			line = strings.TrimSpace(c.whitespace.ReplaceAllString(line, " "))
+			if line == "" && (len(kept) == 0 || kept[len(kept)-1] == "") {
+				continue
+			}
+			kept = append(kept, line)
+		}
+		return strings.TrimSpace(strings.Join(kept, "\n"))
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = c.collapse(value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = c.collapse(value)
+		}
+		return out
+	default:
+		return content
+	}
+}
+
+// trimLongest cuts the longest string by excess bytes, keeping its beginning
+func (c *Compressor) trimLongest(root *interface{}, excess int) bool {
+	var longest []rune
+	var set func(interface{})
+	walkContent(*root, func(v interface{}) { *root = v }, func(v interface{}, replace func(interface{})) {
+		s, ok := v.(string)
+		if !ok || len(s) <= len(longest) {
+			return
+		}
+		if runes := []rune(s); len(runes) > compressKeepRunes*3/2 {
+			longest, set = runes, replace
+		}
+	})
+	if set == nil {
+		return false
+	}
+
+	keep := len(longest) - excess - 32 // Room for the marker
+	if keep < compressKeepRunes {
+		keep = compressKeepRunes
+	}
+	set(fmt.Sprintf("%s… [%d chars trimmed]", string(longest[:keep]), len(longest)-keep))
+	return true
+}
+
+// dropTrailing removes the last item of the longest list
+func (c *Compressor) dropTrailing(root *interface{}) bool {
+	var longest []interface{}
+	var set func(interface{})
+	walkContent(*root, func(v interface{}) { *root = v }, func(v interface{}, replace func(interface{})) {
+		if list, ok := v.([]interface{}); ok && len(list) > 1 && len(list) > len(longest) {
+			longest, set = list, replace
+		}
+	})
+	if set == nil {
+		return false
+	}
+	set(longest[:len(longest)-1])
+	return true
+}
+
+// walkContent visits every value in content in a stable order, with a
+// function that replaces it in its parent
+func walkContent(v interface{}, replace func(interface{}), visit func(interface{}, func(interface{}))) {
+	visit(v, replace)
+	switch value := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walkContent(value[key], func(n interface{}) { value[key] = n }, visit)
+		}
+	case []interface{}:
+		for i := range value {
+			walkContent(value[i], func(n interface{}) { value[i] = n }, visit)
+		}
+	}
+}
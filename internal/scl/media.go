@@ -0,0 +1,110 @@
+package scl
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"sort"
+	"strings"
+)
+
+// MediaRedaction is what happens to an image or file found to contain PII
+type MediaRedaction string
+
+const (
+	MediaRedactionAllow MediaRedaction = "allow"
+	MediaRedactionBlock MediaRedaction = "block"
+	MediaRedactionBlur  MediaRedaction = "blur"
+)
+
+// ParseMediaRedaction validates a media redaction policy; empty means allow
+func ParseMediaRedaction(value string) (MediaRedaction, error) {
+	switch MediaRedaction(value) {
+	case "", MediaRedactionAllow:
+		return MediaRedactionAllow, nil
+	case MediaRedactionBlock, MediaRedactionBlur:
+		return MediaRedaction(value), nil
+	default:
+		return "", fmt.Errorf("invalid media redaction %q: use allow, block or blur", value)
+	}
+}
+
+// MediaPII returns the PII types found in text known about a piece of media:
+// its OCR text, caption or file name. Pixels are not inspected, so media is
+// only as clean as the text extracted from it.
+func (r *Redactor) MediaPII(texts ...string) []string {
+	var found []string
+	for _, piiType := range r.scrubTypes(ScrubLevelStandard) {
+		pattern, ok := r.piiPatterns[piiType]
+		if !ok {
+			continue
+		}
+		for _, text := range texts {
+			if text != "" && pattern.MatchString(text) {
+				found = append(found, piiType)
+				break
+			}
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// blurBlocks is how many pixel blocks the longer side of a blurred image keeps
+const blurBlocks = 16
+
+// BlurImage pixelates a PNG or JPEG image so text in it is unreadable,
+// returning the image re-encoded in its original format
+func BlurImage(data []byte, mediaType string) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	block := bounds.Dx()
+	if bounds.Dy() > block {
+		block = bounds.Dy()
+	}
+	block = block/blurBlocks + 1
+
+	dst := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += block {
+		for x := bounds.Min.X; x < bounds.Max.X; x += block {
+			cell := image.Rect(x, y, x+block, y+block).Intersect(bounds)
+			draw.Draw(dst, cell, &image.Uniform{C: averageColor(src, cell)}, image.Point{}, draw.Src)
+		}
+	}
+
+	var out bytes.Buffer
+	switch strings.ToLower(mediaType) {
+	case "image/png":
+		err = png.Encode(&out, dst)
+	case "image/jpeg", "image/jpg":
+		err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: 80})
+	default:
+		return nil, fmt.Errorf("cannot blur %s images: use image/png or image/jpeg", mediaType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode blurred image: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+func averageColor(img image.Image, cell image.Rectangle) color.Color {
+	var r, g, b, a, n uint64
+	for y := cell.Min.Y; y < cell.Max.Y; y++ {
+		for x := cell.Min.X; x < cell.Max.X; x++ {
+			cr, cg, cb, ca := img.At(x, y).RGBA()
+			r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+		}
+	}
+	if n == 0 {
+		return color.Transparent
+	}
+	return color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)}
+}
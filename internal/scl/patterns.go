@@ -0,0 +1,311 @@
+package scl
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/config"
+	"github.com/Siddhant-K-code/agentflow-infrastructure/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	// redactionPatternCacheTTL bounds how long a pattern change takes to reach redaction
+	redactionPatternCacheTTL  = time.Minute
+	maxRedactionPatternLength = 500
+	maxRedactionReplacement   = 100
+)
+
+var redactionPatternName = regexp.MustCompile(`^[a-z][a-z0-9_]{1,39}$`)
+
+// ValidateRedactionPattern checks a custom entity type can be merged with the
+// built-in detectors
+func ValidateRedactionPattern(pattern RedactionPattern) error {
+	_, err := compileRedactionPattern(pattern)
+	return err
+}
+
+func compileRedactionPattern(pattern RedactionPattern) (*regexp.Regexp, error) {
+	if !redactionPatternName.MatchString(pattern.Name) {
+		return nil, fmt.Errorf("invalid entity type %q: use 2-40 lowercase letters, digits and underscores", pattern.Name)
+	}
+	if slices.Contains(scrubOrder, pattern.Name) {
+		return nil, fmt.Errorf("%s is a built-in entity type", pattern.Name)
+	}
+	if pattern.Pattern == "" {
+		return nil, fmt.Errorf("pattern for %s is empty", pattern.Name)
+	}
+	if len(pattern.Pattern) > maxRedactionPatternLength {
+		return nil, fmt.Errorf("pattern for %s is longer than %d characters", pattern.Name, maxRedactionPatternLength)
+	}
+	if len(pattern.Replacement) > maxRedactionReplacement {
+		return nil, fmt.Errorf("replacement for %s is longer than %d characters", pattern.Name, maxRedactionReplacement)
+	}
+	re, err := regexp.Compile(pattern.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern for %s: %w", pattern.Name, err)
+	}
+	// A pattern matching nothing at all would insert a placeholder between every character
+	if re.MatchString("") {
+		return nil, fmt.Errorf("pattern for %s matches empty text", pattern.Name)
+	}
+	return re, nil
+}
+
+// NewCustomRedactor returns a redactor detecting the given entity types
+// before the built-in ones
+func NewCustomRedactor(patterns []RedactionPattern) (*Redactor, error) {
+	r := NewRedactor()
+	for _, pattern := range patterns {
+		re, err := compileRedactionPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := r.piiPatterns[pattern.Name]; exists {
+			return nil, fmt.Errorf("duplicate entity type %s", pattern.Name)
+		}
+		r.piiPatterns[pattern.Name] = re
+		r.custom = append(r.custom, pattern)
+	}
+	return r, nil
+}
+
+func (r *Redactor) placeholder(piiType string) string {
+	for _, pattern := range r.custom {
+		if pattern.Name == piiType && pattern.Replacement != "" {
+			return pattern.Replacement
+		}
+	}
+	return fmt.Sprintf("[REDACTED_%s]", strings.ToUpper(piiType))
+}
+
+// mergeRedactionPatterns returns the base patterns followed by the overrides,
+// an override replacing a base pattern of the same name
+func mergeRedactionPatterns(base, overrides []RedactionPattern) []RedactionPattern {
+	merged := make([]RedactionPattern, 0, len(base)+len(overrides))
+	for _, pattern := range base {
+		if !slices.ContainsFunc(overrides, func(o RedactionPattern) bool { return o.Name == pattern.Name }) {
+			merged = append(merged, pattern)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+// RedactionSample is what redaction does to one sample text
+type RedactionSample struct {
+	Text     string         `json:"text"`
+	Matches  []string       `json:"matches"`          // What the pattern matches on its own
+	Redacted string         `json:"redacted"`         // The text scrubbed by the pattern and every other detector
+	Counts   map[string]int `json:"counts,omitempty"` // Replacements per entity type
+}
+
+// RedactionPatternTest reports how a pattern behaves on sample text before
+// it is saved
+type RedactionPatternTest struct {
+	Pattern RedactionPattern  `json:"pattern"`
+	Level   ScrubLevel        `json:"level"`
+	Samples []RedactionSample `json:"samples"`
+}
+
+// PreviewRedactionPattern runs a pattern over samples merged with the existing
+// patterns. Counts show whether built-in or other patterns claim the text first.
+func PreviewRedactionPattern(existing []RedactionPattern, pattern RedactionPattern, samples []string, level ScrubLevel) (*RedactionPatternTest, error) {
+	re, err := compileRedactionPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if level == "" || level == ScrubLevelOff {
+		level = ScrubLevelStandard
+	}
+	redactor, err := NewCustomRedactor(mergeRedactionPatterns(existing, []RedactionPattern{pattern}))
+	if err != nil {
+		return nil, err
+	}
+
+	test := &RedactionPatternTest{Pattern: pattern, Level: level, Samples: make([]RedactionSample, 0, len(samples))}
+	for _, text := range samples {
+		sample := RedactionSample{Text: text, Matches: re.FindAllString(text, -1)}
+		if sample.Matches == nil {
+			sample.Matches = []string{}
+		}
+		scrubbed, counts := redactor.Scrub(text, level)
+		sample.Redacted, _ = scrubbed.(string)
+		if len(counts) > 0 {
+			sample.Counts = counts
+		}
+		test.Samples = append(test.Samples, sample)
+	}
+	return test, nil
+}
+
+type cachedRedactionPatterns struct {
+	patterns  []RedactionPattern
+	redactor  *Redactor
+	expiresAt time.Time
+}
+
+// PatternStore keeps orgs' custom PII entity types, merged with the ones
+// from config
+type PatternStore struct {
+	postgres *db.PostgresDB
+	defaults []RedactionPattern
+
+	mu    sync.RWMutex
+	cache map[uuid.UUID]cachedRedactionPatterns
+}
+
+func NewPatternStore(pg *db.PostgresDB, cfg config.RedactionConfig) *PatternStore {
+	defaults := make([]RedactionPattern, 0, len(cfg.Patterns))
+	for _, p := range cfg.Patterns {
+		pattern := RedactionPattern{Name: p.Name, Pattern: p.Pattern, Replacement: p.Replacement}
+		if err := ValidateRedactionPattern(pattern); err != nil {
+			log.Printf("Ignoring redaction pattern from config: %v", err)
+			continue
+		}
+		defaults = append(defaults, pattern)
+	}
+
+	return &PatternStore{
+		postgres: pg,
+		defaults: defaults,
+		cache:    make(map[uuid.UUID]cachedRedactionPatterns),
+	}
+}
+
+// Patterns returns the entity types in effect for an org: config patterns,
+// then the org's own, which replace config patterns of the same name
+func (ps *PatternStore) Patterns(ctx context.Context, orgID uuid.UUID) ([]RedactionPattern, error) {
+	cached, err := ps.load(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return cached.patterns, nil
+}
+
+// Redactor returns a redactor with the org's entity types merged in. It is
+// shared between callers, so use Scrub or MediaPII rather than Redact. Lookup
+// failures fall back to config patterns so redaction keeps running through a
+// database outage.
+func (ps *PatternStore) Redactor(ctx context.Context, orgID uuid.UUID) *Redactor {
+	cached, err := ps.load(ctx, orgID)
+	if err != nil {
+		log.Printf("Failed to load redaction patterns for org %s, using config patterns: %v", orgID, err)
+		redactor, _ := NewCustomRedactor(ps.defaults)
+		return redactor
+	}
+	return cached.redactor
+}
+
+// List returns the patterns an org registered itself
+func (ps *PatternStore) List(ctx context.Context, orgID uuid.UUID) ([]RedactionPattern, error) {
+	query := `SELECT name, pattern, replacement, updated_at FROM redaction_pattern WHERE org_id = $1 ORDER BY name`
+	rows, err := ps.postgres.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list redaction patterns: %w", err)
+	}
+	defer rows.Close()
+
+	patterns := make([]RedactionPattern, 0)
+	for rows.Next() {
+		var pattern RedactionPattern
+		if err := rows.Scan(&pattern.Name, &pattern.Pattern, &pattern.Replacement, &pattern.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan redaction pattern: %w", err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, rows.Err()
+}
+
+// Set validates and stores an org pattern, replacing one of the same name,
+// and applies it immediately
+func (ps *PatternStore) Set(ctx context.Context, orgID uuid.UUID, pattern RedactionPattern) (*RedactionPattern, error) {
+	if err := ValidateRedactionPattern(pattern); err != nil {
+		return nil, err
+	}
+	pattern.UpdatedAt = time.Now()
+
+	query := `INSERT INTO redaction_pattern (org_id, name, pattern, replacement, updated_at)
+			  VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT (org_id, name) DO UPDATE SET
+				pattern = EXCLUDED.pattern,
+				replacement = EXCLUDED.replacement,
+				updated_at = EXCLUDED.updated_at`
+	if _, err := ps.postgres.ExecContext(ctx, query, orgID, pattern.Name, pattern.Pattern, pattern.Replacement, pattern.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("failed to save redaction pattern: %w", err)
+	}
+	ps.invalidate(orgID)
+	return &pattern, nil
+}
+
+// Delete removes an org pattern
+func (ps *PatternStore) Delete(ctx context.Context, orgID uuid.UUID, name string) error {
+	result, err := ps.postgres.ExecContext(ctx, `DELETE FROM redaction_pattern WHERE org_id = $1 AND name = $2`, orgID, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete redaction pattern: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("redaction pattern not found: %s", name)
+	}
+	ps.invalidate(orgID)
+	return nil
+}
+
+// Test runs a pattern over sample text together with the org's other
+// patterns, without saving it
+func (ps *PatternStore) Test(ctx context.Context, orgID uuid.UUID, pattern RedactionPattern, samples []string, level ScrubLevel) (*RedactionPatternTest, error) {
+	existing, err := ps.Patterns(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return PreviewRedactionPattern(existing, pattern, samples, level)
+}
+
+func (ps *PatternStore) load(ctx context.Context, orgID uuid.UUID) (cachedRedactionPatterns, error) {
+	ps.mu.RLock()
+	cached, ok := ps.cache[orgID]
+	ps.mu.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached, nil
+	}
+
+	patterns := ps.defaults
+	if ps.postgres != nil {
+		stored, err := ps.List(ctx, orgID)
+		if err != nil {
+			return cached, err
+		}
+		// Patterns saved before a built-in took their name are skipped rather
+		// than failing every lookup
+		own := make([]RedactionPattern, 0, len(stored))
+		for _, pattern := range stored {
+			if err := ValidateRedactionPattern(pattern); err != nil {
+				log.Printf("Skipping redaction pattern for org %s: %v", orgID, err)
+				continue
+			}
+			own = append(own, pattern)
+		}
+		patterns = mergeRedactionPatterns(ps.defaults, own)
+	}
+	redactor, err := NewCustomRedactor(patterns)
+	if err != nil {
+		return cached, fmt.Errorf("failed to merge redaction patterns: %w", err)
+	}
+
+	cached = cachedRedactionPatterns{patterns: patterns, redactor: redactor, expiresAt: time.Now().Add(redactionPatternCacheTTL)}
+	ps.mu.Lock()
+	ps.cache[orgID] = cached
+	ps.mu.Unlock()
+	return cached, nil
+}
+
+func (ps *PatternStore) invalidate(orgID uuid.UUID) {
+	ps.mu.Lock()
+	delete(ps.cache, orgID)
+	ps.mu.Unlock()
+}
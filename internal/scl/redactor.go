@@ -9,9 +9,64 @@ import (
 	"time"
 )
 
+// ScrubLevel selects which PII detectors run when scrubbing data before persistence
+type ScrubLevel string
+
+const (
+	ScrubLevelOff      ScrubLevel = "off"
+	ScrubLevelStandard ScrubLevel = "standard"
+	ScrubLevelStrict   ScrubLevel = "strict"
+)
+
+// scrubOrder lists detectors from most to least specific so that broad
+// patterns such as phone or bank account numbers don't split longer matches
+var scrubOrder = []string{
+	"private_key", "db_connection", "jwt", "api_key", "password", "aws_access_key",
+	"email", "url", "credit_card", "ssn", "ipv6", "mac_address", "ip_address", "phone",
+	"aws_secret_key", "passport", "drivers_license", "bank_account",
+}
+
+// strictOnlyScrubTypes are noisy detectors that only run at the strict level
+var strictOnlyScrubTypes = map[string]bool{
+	"url":             true,
+	"aws_secret_key":  true,
+	"passport":        true,
+	"drivers_license": true,
+	"bank_account":    true,
+}
+
+// ParseScrubLevel validates a scrub level name; empty means standard
+func ParseScrubLevel(value string) (ScrubLevel, error) {
+	switch level := ScrubLevel(strings.ToLower(strings.TrimSpace(value))); level {
+	case "":
+		return ScrubLevelStandard, nil
+	case ScrubLevelOff, ScrubLevelStandard, ScrubLevelStrict:
+		return level, nil
+	default:
+		return "", fmt.Errorf("invalid scrub level %q (use off, standard or strict)", value)
+	}
+}
+
+// scrubTypes lists the detectors that run at a level. Custom patterns run at
+// every level before the built-ins, since they are the most specific.
+func (r *Redactor) scrubTypes(level ScrubLevel) []string {
+	types := make([]string, 0, len(r.custom)+len(scrubOrder))
+	for _, pattern := range r.custom {
+		types = append(types, pattern.Name)
+	}
+	for _, piiType := range scrubOrder {
+		if level != ScrubLevelStrict && strictOnlyScrubTypes[piiType] {
+			continue
+		}
+		types = append(types, piiType)
+	}
+	return types
+}
+
 type Redactor struct {
 	piiPatterns map[string]*regexp.Regexp
-	tokenMap    map[string]string // For reversible redaction
+	custom      []RedactionPattern // Org and config patterns, merged into piiPatterns
+	tokenMap    map[string]string  // For reversible redaction
 }
 
 func NewRedactor() *Redactor {
@@ -54,7 +109,11 @@ func (r *Redactor) redactString(input string) (string, map[string]string) {
 	result := input
 
 	// Apply PII patterns
-	for piiType, pattern := range r.piiPatterns {
+	for _, piiType := range r.scrubTypes(ScrubLevelStrict) {
+		pattern, ok := r.piiPatterns[piiType]
+		if !ok {
+			continue
+		}
 		matches := pattern.FindAllString(result, -1)
 		for _, match := range matches {
 			if match == "" {
@@ -150,7 +209,7 @@ func compilePIIPatterns() map[string]*regexp.Regexp {
 		"phone": `\b(?:\+?1[-.\s]?)?\(?([0-9]{3})\)?[-.\s]?([0-9]{3})[-.\s]?([0-9]{4})\b`,
 
 		// Social Security Numbers
-		"ssn": `\b(?!000|666|9\d{2})\d{3}[-\s]?(?!00)\d{2}[-\s]?(?!0000)\d{4}\b`,
+		"ssn": `\b\d{3}-\d{2}-\d{4}\b`,
 
 		// Credit Card Numbers (basic pattern)
 		"credit_card": `\b(?:4[0-9]{12}(?:[0-9]{3})?|5[1-5][0-9]{14}|3[47][0-9]{13}|3[0-9]{13}|6(?:011|5[0-9]{2})[0-9]{12})\b`,
@@ -208,6 +267,60 @@ func compilePIIPatterns() map[string]*regexp.Regexp {
 	return compiled
 }
 
+// Scrub irreversibly replaces detected PII with [REDACTED_<TYPE>] placeholders
+// and returns the number of replacements per type. Unlike Redact it keeps no
+// token map, so it is safe for concurrent use on data being persisted.
+func (r *Redactor) Scrub(content interface{}, level ScrubLevel) (interface{}, map[string]int) {
+	counts := make(map[string]int)
+	if level == ScrubLevelOff {
+		return content, counts
+	}
+	return r.scrubValue(content, r.scrubTypes(level), counts), counts
+}
+
+func (r *Redactor) scrubValue(content interface{}, types []string, counts map[string]int) interface{} {
+	switch v := content.(type) {
+	case string:
+		return r.scrubString(v, types, counts)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			result[key] = r.scrubValue(value, types, counts)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = r.scrubValue(item, types, counts)
+		}
+		return result
+	case []string:
+		result := make([]string, len(v))
+		for i, item := range v {
+			result[i] = r.scrubString(item, types, counts)
+		}
+		return result
+	default:
+		return content
+	}
+}
+
+func (r *Redactor) scrubString(input string, types []string, counts map[string]int) string {
+	result := input
+	for _, piiType := range types {
+		pattern, ok := r.piiPatterns[piiType]
+		if !ok {
+			continue
+		}
+		placeholder := r.placeholder(piiType)
+		result = pattern.ReplaceAllStringFunc(result, func(string) string {
+			counts[piiType]++
+			return placeholder
+		})
+	}
+	return result
+}
+
 // UnredactString reverses redaction using the redaction map
 func (r *Redactor) UnredactString(redacted string, redactionMap map[string]string) string {
 	result := redacted
@@ -222,13 +335,14 @@ func (r *Redactor) GetRedactionStats(redactionMap map[string]string) map[string]
 	stats := make(map[string]int)
 
 	for token := range redactionMap {
-		// Extract PII type from token
-		if strings.HasPrefix(token, "[REDACTED_") {
-			parts := strings.Split(token, "_")
-			if len(parts) >= 2 {
-				piiType := strings.ToLower(parts[1])
-				stats[piiType]++
-			}
+		// Tokens are [REDACTED_<TYPE>_<suffix>]; types may contain underscores
+		inner, ok := strings.CutPrefix(token, "[REDACTED_")
+		if !ok {
+			continue
+		}
+		inner = strings.TrimSuffix(inner, "]")
+		if i := strings.LastIndex(inner, "_"); i > 0 {
+			stats[strings.ToLower(inner[:i])]++
 		}
 	}
 
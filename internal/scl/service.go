@@ -19,6 +19,7 @@ type Service struct {
 	validator *Validator
 	sanitizer *Sanitizer
 	redactor  *Redactor
+	patterns  *PatternStore
 	policy    *PolicyEngine
 }
 
@@ -29,6 +30,7 @@ func NewService(cfg *config.Config, database *db.PostgresDB) *Service {
 		validator: NewValidator(),
 		sanitizer: NewSanitizer(),
 		redactor:  NewRedactor(),
+		patterns:  NewPatternStore(database, cfg.Redaction),
 		policy:    NewPolicyEngine(),
 	}
 }
@@ -84,13 +86,20 @@ func (s *Service) IngestContext(ctx context.Context, orgID uuid.UUID, req *Inges
 		req.Content = sanitizedContent
 	}
 
-	// Step 4: PII redaction
-	redactedContent, redactionMap, err := s.redactor.Redact(req.Content)
+	// Step 4: PII redaction, with the org's custom entity types
+	redactor := s.redactor
+	if patterns, err := s.patterns.Patterns(ctx, orgID); err != nil {
+		response.Warnings = append(response.Warnings, fmt.Sprintf("Custom redaction patterns unavailable: %v", err))
+	} else if len(patterns) > 0 {
+		redactor, _ = NewCustomRedactor(patterns)
+	}
+	redactedContent, redactionMap, err := redactor.Redact(req.Content)
 	if err != nil {
 		response.Errors = append(response.Errors, fmt.Sprintf("Redaction failed: %v", err))
 		response.Status = StatusFailed
 	} else {
 		bundle.RedactionMap = redactionMap
+		response.Redactions = redactor.GetRedactionStats(redactionMap)
 		req.Content = redactedContent
 	}
 
@@ -155,6 +164,13 @@ func (s *Service) PrepareContext(ctx context.Context, orgID uuid.UUID, req *Prep
 	// Rank and select chunks
 	chunks := s.rankAndSelectChunks(filteredBundles, req.Query, req.MaxChunks)
 	response.Chunks = chunks
+	fenced := 0
+	for _, chunk := range chunks {
+		if chunk.RequiresFence() {
+			fenced++
+		}
+	}
+	response.Metadata["fenced_chunks"] = fenced
 
 	// Generate citations
 	response.Citations = s.generateCitations(chunks, filteredBundles)
@@ -187,6 +203,27 @@ func (s *Service) TestPolicy(ctx context.Context, orgID uuid.UUID, req *PolicyTe
 	return response, nil
 }
 
+// ListRedactionPatterns returns the custom PII entity types an org registered
+func (s *Service) ListRedactionPatterns(ctx context.Context, orgID uuid.UUID) ([]RedactionPattern, error) {
+	return s.patterns.List(ctx, orgID)
+}
+
+// SetRedactionPattern registers or replaces a custom PII entity type
+func (s *Service) SetRedactionPattern(ctx context.Context, orgID uuid.UUID, pattern RedactionPattern) (*RedactionPattern, error) {
+	return s.patterns.Set(ctx, orgID, pattern)
+}
+
+// DeleteRedactionPattern removes a custom PII entity type
+func (s *Service) DeleteRedactionPattern(ctx context.Context, orgID uuid.UUID, name string) error {
+	return s.patterns.Delete(ctx, orgID, name)
+}
+
+// TestRedactionPattern shows what a pattern would redact in sample text
+// alongside the built-in and existing custom patterns, without saving it
+func (s *Service) TestRedactionPattern(ctx context.Context, orgID uuid.UUID, pattern RedactionPattern, samples []string, level ScrubLevel) (*RedactionPatternTest, error) {
+	return s.patterns.Test(ctx, orgID, pattern, samples, level)
+}
+
 // Helper methods
 
 func (s *Service) validateSources(ctx context.Context, bundle *ContextBundle, req *IngestRequest) error {
@@ -339,6 +376,7 @@ func (s *Service) rankAndSelectChunks(bundles []*ContextBundle, query string, ma
 			BundleID:   bundle.ID,
 			Rank:       1.0 - float64(i)*0.1, // Mock ranking
 			TrustScore: bundle.TrustScore,
+			TrustTier:  TrustTierFor(bundle.TrustScore),
 			Metadata:   map[string]interface{}{"bundle_hash": bundle.Hash},
 		}
 
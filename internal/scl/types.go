@@ -76,6 +76,7 @@ const (
 	StageRedaction    ProcessingStage = "redaction"
 	StagePolicy       ProcessingStage = "policy"
 	StageEnrichment   ProcessingStage = "enrichment"
+	StageCompression  ProcessingStage = "compression"
 )
 
 type ProcessingResult struct {
@@ -112,6 +113,7 @@ type IngestResponse struct {
 	Status         ProcessingStatus `json:"status"`
 	Warnings       []string         `json:"warnings,omitempty"`
 	Errors         []string         `json:"errors,omitempty"`
+	Redactions     map[string]int   `json:"redactions,omitempty"` // Values redacted per entity type
 	ProcessingTime time.Duration    `json:"processing_time"`
 }
 
@@ -123,7 +125,8 @@ type PrepareRequest struct {
 	Policy    map[string]interface{} `json:"policy,omitempty"`
 }
 
-// PrepareResponse represents prepared context ready for use
+// PrepareResponse represents prepared context ready for use. Chunks whose
+// tier requires it must be rendered through FenceChunk, never in system messages.
 type PrepareResponse struct {
 	Chunks     []ContextChunk         `json:"chunks"`
 	Citations  []Citation             `json:"citations"`
@@ -137,6 +140,7 @@ type ContextChunk struct {
 	BundleID   uuid.UUID              `json:"bundle_id"`
 	Rank       float64                `json:"rank"`
 	TrustScore float64                `json:"trust_score"`
+	TrustTier  TrustTier              `json:"trust_tier"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
 }
 
@@ -178,11 +182,14 @@ type RedactionConfig struct {
 	KMSKeyID   string             `json:"kms_key_id,omitempty"`
 }
 
+// RedactionPattern is a custom PII entity type. Replacement overrides the
+// [REDACTED_<NAME>] placeholder when scrubbing.
 type RedactionPattern struct {
-	Name        string  `json:"name"`
-	Pattern     string  `json:"pattern"`
-	Replacement string  `json:"replacement"`
-	Confidence  float64 `json:"confidence"`
+	Name        string    `json:"name"`
+	Pattern     string    `json:"pattern"`
+	Replacement string    `json:"replacement"`
+	Confidence  float64   `json:"confidence"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
 }
 
 // InjectionFilter configures prompt injection detection
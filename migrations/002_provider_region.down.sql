@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_provider_config_region;
+
+ALTER TABLE provider_config DROP COLUMN IF EXISTS data_residency;
+ALTER TABLE provider_config DROP COLUMN IF EXISTS region;
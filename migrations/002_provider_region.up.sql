@@ -0,0 +1,5 @@
+-- Provider hosting region and data residency
+ALTER TABLE provider_config ADD COLUMN region TEXT NOT NULL DEFAULT '';
+ALTER TABLE provider_config ADD COLUMN data_residency TEXT NOT NULL DEFAULT 'global';
+
+CREATE INDEX idx_provider_config_region ON provider_config(org_id, region);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_provider_telemetry_provider;
+DROP TABLE IF EXISTS provider_telemetry;
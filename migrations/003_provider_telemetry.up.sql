@@ -0,0 +1,14 @@
+-- Observed provider call telemetry
+CREATE TABLE provider_telemetry (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    provider_name TEXT NOT NULL,
+    model_name TEXT NOT NULL,
+    latency_ms BIGINT NOT NULL,
+    error_class TEXT NOT NULL DEFAULT 'none',
+    cost_cents BIGINT DEFAULT 0,
+    tokens_used INTEGER DEFAULT 0,
+    recorded_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_provider_telemetry_provider ON provider_telemetry(provider_name, model_name, recorded_at);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_budget_org_workflow;
+
+ALTER TABLE budget DROP COLUMN IF EXISTS tag;
+ALTER TABLE budget DROP COLUMN IF EXISTS workflow_name;
@@ -0,0 +1,5 @@
+-- Budgets scoped to a workflow name and optional run tag
+ALTER TABLE budget ADD COLUMN workflow_name TEXT;
+ALTER TABLE budget ADD COLUMN tag TEXT;
+
+CREATE INDEX idx_budget_org_workflow ON budget(org_id, workflow_name, period_start, period_end);
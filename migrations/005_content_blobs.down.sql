@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_prompt_template_content_hash;
+DROP INDEX IF EXISTS idx_workflow_spec_content_hash;
+
+ALTER TABLE prompt_template DROP COLUMN IF EXISTS content_hash;
+ALTER TABLE workflow_spec DROP COLUMN IF EXISTS content_hash;
+
+DROP TABLE IF EXISTS content_blob;
@@ -0,0 +1,16 @@
+-- Content-addressed storage for workflow specs and prompt templates
+CREATE TABLE content_blob (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    hash TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('workflow_spec','prompt_template')),
+    size_bytes BIGINT NOT NULL,
+    content BYTEA NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (org_id, hash)
+);
+
+ALTER TABLE workflow_spec ADD COLUMN content_hash TEXT;
+ALTER TABLE prompt_template ADD COLUMN content_hash TEXT;
+
+CREATE INDEX idx_workflow_spec_content_hash ON workflow_spec(content_hash);
+CREATE INDEX idx_prompt_template_content_hash ON prompt_template(content_hash);
@@ -0,0 +1,14 @@
+-- Encrypted provider responses recorded for deterministic replay
+CREATE TABLE llm_cassette (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    run_id UUID NOT NULL REFERENCES workflow_run(id) ON DELETE CASCADE,
+    step_id TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    nonce BYTEA NOT NULL,
+    ciphertext BYTEA NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE(run_id, step_id, request_hash)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_optimization_suggestion_org_status;
+DROP TABLE IF EXISTS optimization_suggestion;
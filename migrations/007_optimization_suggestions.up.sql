@@ -0,0 +1,23 @@
+-- CAS: Persisted optimization suggestions with review state and realized savings
+CREATE TABLE optimization_suggestion (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    fingerprint TEXT NOT NULL,
+    type TEXT NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    potential_saving_cents BIGINT NOT NULL DEFAULT 0,
+    confidence FLOAT NOT NULL DEFAULT 0,
+    impact TEXT NOT NULL,
+    actions JSONB DEFAULT '[]',
+    metadata JSONB DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open','accepted','dismissed')),
+    dismiss_reason TEXT,
+    baseline_daily_cents FLOAT NOT NULL DEFAULT 0,
+    realized_saving_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    decided_at TIMESTAMPTZ,
+    UNIQUE(org_id, fingerprint)
+);
+
+CREATE INDEX idx_optimization_suggestion_org_status ON optimization_suggestion(org_id, status);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_provider_telemetry_org_recorded;
+ALTER TABLE provider_telemetry DROP COLUMN IF EXISTS quality_tier;
+ALTER TABLE provider_telemetry DROP COLUMN IF EXISTS workflow_name;
+
+DROP INDEX IF EXISTS idx_routing_rule_org_priority;
+DROP TABLE IF EXISTS routing_rule;
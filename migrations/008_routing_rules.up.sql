@@ -0,0 +1,21 @@
+-- CAS: User-authored routing rules evaluated before provider scoring
+CREATE TABLE routing_rule (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    expression TEXT NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 100,
+    conditions JSONB NOT NULL DEFAULT '[]',
+    action JSONB NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE(org_id, name)
+);
+
+CREATE INDEX idx_routing_rule_org_priority ON routing_rule(org_id, priority);
+
+-- Request attributes needed to replay traffic through proposed rules
+ALTER TABLE provider_telemetry ADD COLUMN workflow_name TEXT;
+ALTER TABLE provider_telemetry ADD COLUMN quality_tier TEXT;
+
+CREATE INDEX idx_provider_telemetry_org_recorded ON provider_telemetry(org_id, recorded_at);
@@ -0,0 +1,7 @@
+-- CAS: Organization-level provider/model allow and deny lists
+CREATE TABLE org_model_policy (
+    org_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    allow JSONB NOT NULL DEFAULT '[]',
+    deny JSONB NOT NULL DEFAULT '[]',
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
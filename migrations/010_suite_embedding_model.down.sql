@@ -0,0 +1 @@
+ALTER TABLE prompt_suite DROP COLUMN IF EXISTS embedding_model;
@@ -0,0 +1,2 @@
+-- POP: Per-suite embedding model for embedding similarity scoring
+ALTER TABLE prompt_suite ADD COLUMN embedding_model TEXT;
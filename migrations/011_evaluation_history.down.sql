@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_evaluation_case_result_run;
+DROP TABLE IF EXISTS evaluation_case_result;
+DROP INDEX IF EXISTS idx_evaluation_run_prompt;
+DROP TABLE IF EXISTS evaluation_run;
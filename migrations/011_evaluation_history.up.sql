@@ -0,0 +1,35 @@
+-- POP: Persisted evaluation runs and per-case results for score trends
+CREATE TABLE evaluation_run (
+    id UUID PRIMARY KEY,
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    prompt_id UUID REFERENCES prompt_template(id) ON DELETE SET NULL,
+    prompt_name TEXT NOT NULL,
+    prompt_version INTEGER NOT NULL,
+    suite_id UUID REFERENCES prompt_suite(id) ON DELETE SET NULL,
+    suite_name TEXT NOT NULL,
+    model TEXT,
+    status TEXT NOT NULL,
+    total_cases INTEGER NOT NULL DEFAULT 0,
+    passed_cases INTEGER NOT NULL DEFAULT 0,
+    average_score FLOAT NOT NULL DEFAULT 0,
+    total_cost_cents BIGINT NOT NULL DEFAULT 0,
+    started_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_evaluation_run_prompt ON evaluation_run(org_id, prompt_name, completed_at);
+
+CREATE TABLE evaluation_case_result (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    run_id UUID NOT NULL REFERENCES evaluation_run(id) ON DELETE CASCADE,
+    case_id TEXT NOT NULL,
+    score FLOAT NOT NULL DEFAULT 0,
+    passed BOOLEAN NOT NULL DEFAULT FALSE,
+    error TEXT,
+    latency_ms BIGINT NOT NULL DEFAULT 0,
+    cost_cents BIGINT NOT NULL DEFAULT 0,
+    embedding_cost_cents FLOAT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_evaluation_case_result_run ON evaluation_case_result(run_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS saved_run_filter;
+DROP INDEX IF EXISTS idx_workflow_run_created_at_id;
+DROP INDEX IF EXISTS idx_workflow_run_tags;
+ALTER TABLE workflow_run DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,16 @@
+-- AOR: Queryable run tags and per-user saved run filters
+ALTER TABLE workflow_run ADD COLUMN tags JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_workflow_run_tags ON workflow_run USING GIN (tags);
+CREATE INDEX idx_workflow_run_created_at_id ON workflow_run(created_at DESC, id DESC);
+
+CREATE TABLE saved_run_filter (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    owner TEXT NOT NULL,
+    name TEXT NOT NULL,
+    filter JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE(org_id, owner, name)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_workflow_run_sla_open;
+ALTER TABLE workflow_run DROP COLUMN IF EXISTS sla_status;
+ALTER TABLE workflow_run DROP COLUMN IF EXISTS sla_deadline;
@@ -0,0 +1,5 @@
+-- AOR: End-to-end SLA tracking for workflow runs
+ALTER TABLE workflow_run ADD COLUMN sla_deadline TIMESTAMPTZ;
+ALTER TABLE workflow_run ADD COLUMN sla_status TEXT CHECK (sla_status IN ('on_track','at_risk','breached','met'));
+
+CREATE INDEX idx_workflow_run_sla_open ON workflow_run(sla_status) WHERE sla_status IN ('on_track','at_risk');
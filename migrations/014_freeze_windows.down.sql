@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_freeze_window_org_time;
+DROP TABLE IF EXISTS freeze_window;
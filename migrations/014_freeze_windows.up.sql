@@ -0,0 +1,17 @@
+-- AOR: Org-level freeze windows that reject or defer runs
+CREATE TABLE freeze_window (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    mode TEXT NOT NULL DEFAULT 'reject' CHECK (mode IN ('reject','queue')),
+    triggers JSONB NOT NULL DEFAULT '[]',
+    workflows JSONB NOT NULL DEFAULT '[]',
+    tags JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX idx_freeze_window_org_time ON freeze_window(org_id, ends_at);
@@ -0,0 +1 @@
+ALTER TABLE workflow_run DROP COLUMN IF EXISTS dag_overlay;
@@ -0,0 +1,2 @@
+-- AOR: Nodes and edges spawned into a run's DAG at runtime
+ALTER TABLE workflow_run ADD COLUMN dag_overlay JSONB NOT NULL DEFAULT '{"steps":[],"edges":[]}';
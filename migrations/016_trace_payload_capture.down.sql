@@ -0,0 +1 @@
+DROP TABLE IF EXISTS trace_payload_capture;
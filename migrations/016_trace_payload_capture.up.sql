@@ -0,0 +1,7 @@
+-- AOS: Per-org PII scrubbing and payload capture for persisted traces
+CREATE TABLE trace_payload_capture (
+    org_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    scrub_level TEXT NOT NULL DEFAULT 'standard' CHECK (scrub_level IN ('off','standard','strict')),
+    capture_payloads BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_trace_export_org_created;
+DROP TABLE IF EXISTS trace_export;
@@ -0,0 +1,17 @@
+-- AOS: Parquet archival exports of aged trace events
+CREATE TABLE trace_export (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending','running','succeeded','failed')),
+    location TEXT NOT NULL,
+    partitions JSONB NOT NULL DEFAULT '[]',
+    rows_exported BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    CHECK (end_date > start_date)
+);
+
+CREATE INDEX idx_trace_export_org_created ON trace_export(org_id, created_at DESC);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_workflow_run_batch;
+ALTER TABLE workflow_run DROP COLUMN IF EXISTS batch_id;
+DROP TABLE IF EXISTS run_batch;
+DELETE FROM content_blob WHERE kind = 'dataset';
+ALTER TABLE content_blob DROP CONSTRAINT IF EXISTS content_blob_kind_check;
+ALTER TABLE content_blob ADD CONSTRAINT content_blob_kind_check CHECK (kind IN ('workflow_spec','prompt_template'));
@@ -0,0 +1,21 @@
+-- AOR: Batch run submission over JSONL datasets
+ALTER TABLE content_blob DROP CONSTRAINT IF EXISTS content_blob_kind_check;
+ALTER TABLE content_blob ADD CONSTRAINT content_blob_kind_check CHECK (kind IN ('workflow_spec','prompt_template','dataset'));
+
+CREATE TABLE run_batch (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    workflow_name TEXT NOT NULL,
+    workflow_version INTEGER NOT NULL DEFAULT 0,
+    dataset_ref TEXT NOT NULL DEFAULT '',
+    tags JSONB NOT NULL DEFAULT '{}',
+    total_records INTEGER NOT NULL DEFAULT 0,
+    rejected_records INTEGER NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_run_batch_org_created ON run_batch(org_id, created_at DESC);
+
+ALTER TABLE workflow_run ADD COLUMN batch_id UUID REFERENCES run_batch(id) ON DELETE SET NULL;
+CREATE INDEX idx_workflow_run_batch ON workflow_run(batch_id) WHERE batch_id IS NOT NULL;
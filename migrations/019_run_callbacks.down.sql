@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_run_callback_pending;
+DROP TABLE IF EXISTS run_callback;
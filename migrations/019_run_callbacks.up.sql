@@ -0,0 +1,15 @@
+-- AOR: Signed result webhooks delivered when a run finishes
+CREATE TABLE run_callback (
+    run_id UUID PRIMARY KEY REFERENCES workflow_run(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    redact_level TEXT NOT NULL DEFAULT 'off' CHECK (redact_level IN ('off','standard','strict')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending','delivered','failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_status_code INTEGER,
+    last_error TEXT,
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_run_callback_pending ON run_callback(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,10 @@
+-- AOR: Resources owned by declarative apply, so prune never touches hand-made ones
+CREATE TABLE applied_resource (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('Workflow', 'Prompt', 'Budget', 'ProviderConfig')),
+    name TEXT NOT NULL,
+    content_hash TEXT NOT NULL,
+    object_id TEXT NOT NULL DEFAULT '',
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (org_id, kind, name)
+);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_finetune_dataset_example_run;
+DROP TABLE IF EXISTS finetune_dataset_example;
+DROP TABLE IF EXISTS finetune_dataset;
+DROP TABLE IF EXISTS trace_feedback;
+DELETE FROM content_blob WHERE kind = 'finetune_dataset';
+ALTER TABLE content_blob DROP CONSTRAINT IF EXISTS content_blob_kind_check;
+ALTER TABLE content_blob ADD CONSTRAINT content_blob_kind_check CHECK (kind IN ('workflow_spec','prompt_template','dataset'));
@@ -0,0 +1,39 @@
+-- AOS: Feedback on model calls and fine-tuning datasets curated from traces
+ALTER TABLE content_blob DROP CONSTRAINT IF EXISTS content_blob_kind_check;
+ALTER TABLE content_blob ADD CONSTRAINT content_blob_kind_check CHECK (kind IN ('workflow_spec','prompt_template','dataset','finetune_dataset'));
+
+CREATE TABLE trace_feedback (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    run_id UUID NOT NULL,
+    step_id UUID NOT NULL,
+    source TEXT NOT NULL CHECK (source IN ('human','eval')),
+    score DOUBLE PRECISION NOT NULL CHECK (score BETWEEN 0 AND 1),
+    comment TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (org_id, run_id, step_id, source)
+);
+
+CREATE TABLE finetune_dataset (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    format TEXT NOT NULL CHECK (format IN ('openai','anthropic','gemini')),
+    filter JSONB NOT NULL DEFAULT '{}',
+    stats JSONB NOT NULL DEFAULT '{}',
+    example_count INTEGER NOT NULL DEFAULT 0,
+    content_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (org_id, name, version)
+);
+
+-- Lineage: which model calls each dataset version was built from
+CREATE TABLE finetune_dataset_example (
+    dataset_id UUID NOT NULL REFERENCES finetune_dataset(id) ON DELETE CASCADE,
+    run_id UUID NOT NULL,
+    step_id UUID NOT NULL,
+    PRIMARY KEY (dataset_id, run_id, step_id)
+);
+
+CREATE INDEX idx_finetune_dataset_example_run ON finetune_dataset_example(run_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_trace_feedback_prompt;
+ALTER TABLE trace_feedback DROP COLUMN IF EXISTS inputs;
+ALTER TABLE trace_feedback DROP COLUMN IF EXISTS prompt_version;
+ALTER TABLE trace_feedback DROP COLUMN IF EXISTS prompt_ref;
+ALTER TABLE trace_feedback DROP COLUMN IF EXISTS model;
+ALTER TABLE trace_feedback DROP COLUMN IF EXISTS provider;
+ALTER TABLE trace_feedback DROP COLUMN IF EXISTS correction;
@@ -0,0 +1,9 @@
+-- AOS: Corrected outputs and call context on feedback. Run-level feedback uses the nil step ID.
+ALTER TABLE trace_feedback ADD COLUMN correction TEXT NOT NULL DEFAULT '';
+ALTER TABLE trace_feedback ADD COLUMN provider TEXT NOT NULL DEFAULT '';
+ALTER TABLE trace_feedback ADD COLUMN model TEXT NOT NULL DEFAULT '';
+ALTER TABLE trace_feedback ADD COLUMN prompt_ref TEXT NOT NULL DEFAULT '';
+ALTER TABLE trace_feedback ADD COLUMN prompt_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE trace_feedback ADD COLUMN inputs JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_trace_feedback_prompt ON trace_feedback(org_id, prompt_ref, created_at);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_budget_org_label;
+DROP INDEX IF EXISTS idx_provider_telemetry_labels;
+DROP INDEX IF EXISTS idx_workflow_run_labels;
+
+ALTER TABLE budget DROP COLUMN IF EXISTS label;
+ALTER TABLE provider_telemetry DROP COLUMN IF EXISTS labels;
+ALTER TABLE workflow_run DROP COLUMN IF EXISTS labels;
@@ -0,0 +1,8 @@
+-- AOR: Cost allocation labels supplied at run creation, carried onto provider telemetry and usable as budget scopes
+ALTER TABLE workflow_run ADD COLUMN labels JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE provider_telemetry ADD COLUMN labels JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE budget ADD COLUMN label TEXT;
+
+CREATE INDEX idx_workflow_run_labels ON workflow_run USING GIN (labels);
+CREATE INDEX idx_provider_telemetry_labels ON provider_telemetry USING GIN (labels);
+CREATE INDEX idx_budget_org_label ON budget(org_id, label, period_start, period_end) WHERE label IS NOT NULL;
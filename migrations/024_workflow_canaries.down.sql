@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_workflow_deployment_canary;
+DROP INDEX IF EXISTS idx_workflow_deployment_name;
+DROP TABLE IF EXISTS workflow_deployment;
@@ -0,0 +1,17 @@
+-- AOR: Workflow canary deployments splitting unpinned run starts between a stable and a canary spec version
+CREATE TABLE workflow_deployment (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    workflow_name TEXT NOT NULL,
+    stable_version INTEGER NOT NULL,
+    canary_version INTEGER,
+    canary_ratio FLOAT NOT NULL DEFAULT 0 CHECK (canary_ratio BETWEEN 0 AND 1),
+    policy JSONB NOT NULL DEFAULT '{}',
+    canary_started_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(org_id, workflow_name)
+);
+
+CREATE INDEX idx_workflow_deployment_name ON workflow_deployment(workflow_name);
+CREATE INDEX idx_workflow_deployment_canary ON workflow_deployment(canary_version) WHERE canary_version IS NOT NULL;
@@ -0,0 +1,14 @@
+-- AOR: Actions steps were blocked from taking by their sandbox, such as network egress outside the allowlist
+CREATE TABLE guardrail_event (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    run_id UUID NOT NULL REFERENCES workflow_run(id) ON DELETE CASCADE,
+    step_id TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('network_egress', 'filesystem_write')),
+    target TEXT NOT NULL,
+    action TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_guardrail_event_run ON guardrail_event(org_id, run_id, created_at);
+CREATE INDEX idx_guardrail_event_kind ON guardrail_event(org_id, kind, created_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_workflow_run_ended;
+DROP TABLE IF EXISTS deletion_request;
+DROP TABLE IF EXISTS org_retention_policy;
@@ -0,0 +1,23 @@
+-- AOR: Per-org retention for run inputs and outputs, and subject deletion requests
+CREATE TABLE org_retention_policy (
+    org_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    run_io_days INTEGER NOT NULL DEFAULT 0 CHECK (run_io_days >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The subject itself is never stored, only its hash, so the request log does
+-- not retain the identifier it was asked to erase
+CREATE TABLE deletion_request (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    subject_hash TEXT NOT NULL,
+    requested_by TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL CHECK (status IN ('running','completed','failed')),
+    report JSONB NOT NULL DEFAULT '{}',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_deletion_request_org ON deletion_request(org_id, created_at);
+CREATE INDEX idx_workflow_run_ended ON workflow_run(ended_at) WHERE ended_at IS NOT NULL;
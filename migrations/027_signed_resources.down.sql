@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS signature_audit;
+ALTER TABLE prompt_template DROP COLUMN IF EXISTS signature;
+ALTER TABLE workflow_spec DROP COLUMN IF EXISTS signature;
+DROP TABLE IF EXISTS signing_key;
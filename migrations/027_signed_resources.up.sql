@@ -0,0 +1,29 @@
+-- AOR: Org signing keys, verified signatures on workflow specs and prompt templates, and a verification audit log
+CREATE TABLE signing_key (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    algorithm TEXT NOT NULL CHECK (algorithm IN ('ed25519','ecdsa-p256')),
+    public_key TEXT NOT NULL,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ,
+    UNIQUE(org_id, name)
+);
+
+ALTER TABLE workflow_spec ADD COLUMN signature JSONB;
+ALTER TABLE prompt_template ADD COLUMN signature JSONB;
+
+CREATE TABLE signature_audit (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    content_hash TEXT NOT NULL,
+    key_name TEXT NOT NULL DEFAULT '',
+    outcome TEXT NOT NULL CHECK (outcome IN ('verified','unsigned','rejected')),
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_signature_audit_org ON signature_audit(org_id, created_at);
@@ -0,0 +1,16 @@
+-- AOR: Vector store for chunks indexed by rag_embed steps and searched by rag_retrieve
+CREATE TABLE rag_chunk (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    collection TEXT NOT NULL,
+    document_id TEXT NOT NULL,
+    chunk_index INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    embedding DOUBLE PRECISION[] NOT NULL,
+    embedding_model TEXT NOT NULL,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (org_id, collection, document_id, chunk_index)
+);
+
+CREATE INDEX idx_rag_chunk_collection ON rag_chunk(org_id, collection, embedding_model);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS conversation_turn;
+DROP TABLE IF EXISTS conversation_session;
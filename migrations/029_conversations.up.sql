@@ -0,0 +1,31 @@
+-- AOR: Sessions and turns of conversational runs
+CREATE TABLE conversation_session (
+    run_id UUID PRIMARY KEY REFERENCES workflow_run(id) ON DELETE CASCADE,
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    state TEXT NOT NULL DEFAULT 'open' CHECK (state IN ('open', 'closed')),
+    steps TEXT[] NOT NULL,
+    reply_step TEXT NOT NULL,
+    memory_turns INTEGER NOT NULL,
+    max_turns INTEGER NOT NULL DEFAULT 0,
+    idle_timeout_seconds BIGINT NOT NULL,
+    memory JSONB NOT NULL DEFAULT '[]',
+    turns INTEGER NOT NULL DEFAULT 0,
+    last_turn_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ,
+    close_reason TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE conversation_turn (
+    run_id UUID NOT NULL REFERENCES conversation_session(run_id) ON DELETE CASCADE,
+    turn INTEGER NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('running', 'completed', 'failed')),
+    inputs JSONB NOT NULL DEFAULT '{}',
+    output JSONB,
+    error TEXT NOT NULL DEFAULT '',
+    cost_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    PRIMARY KEY (run_id, turn)
+);
+
+CREATE INDEX idx_conversation_session_idle ON conversation_session(last_turn_at) WHERE state = 'open';
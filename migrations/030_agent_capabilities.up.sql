@@ -0,0 +1,14 @@
+-- AOR: Registry of agent and tool capabilities checked at workflow submission
+CREATE TABLE agent_capability (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('agent', 'tool')),
+    name TEXT NOT NULL,
+    version TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    task_types TEXT[] NOT NULL DEFAULT '{}',
+    input_schema JSONB,
+    output_schema JSONB,
+    registered_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (org_id, kind, name)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE provider_telemetry DROP COLUMN IF EXISTS cache_saving_cents;
+ALTER TABLE provider_telemetry DROP COLUMN IF EXISTS cache_write_tokens;
+ALTER TABLE provider_telemetry DROP COLUMN IF EXISTS cache_read_tokens;
+ALTER TABLE provider_telemetry DROP COLUMN IF EXISTS prompt_tokens;
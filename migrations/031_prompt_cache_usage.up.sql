@@ -0,0 +1,5 @@
+-- CAS: Provider prompt cache usage and the savings it realized per call
+ALTER TABLE provider_telemetry ADD COLUMN prompt_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE provider_telemetry ADD COLUMN cache_read_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE provider_telemetry ADD COLUMN cache_write_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE provider_telemetry ADD COLUMN cache_saving_cents BIGINT NOT NULL DEFAULT 0;
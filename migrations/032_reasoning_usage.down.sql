@@ -0,0 +1,2 @@
+ALTER TABLE provider_telemetry DROP COLUMN IF EXISTS reasoning_cents;
+ALTER TABLE provider_telemetry DROP COLUMN IF EXISTS reasoning_tokens;
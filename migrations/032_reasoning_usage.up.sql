@@ -0,0 +1,3 @@
+-- CAS: Thinking tokens billed by reasoning models and their share of call cost
+ALTER TABLE provider_telemetry ADD COLUMN reasoning_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE provider_telemetry ADD COLUMN reasoning_cents BIGINT NOT NULL DEFAULT 0;
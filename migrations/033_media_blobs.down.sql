@@ -0,0 +1,3 @@
+DELETE FROM content_blob WHERE kind = 'media';
+ALTER TABLE content_blob DROP CONSTRAINT IF EXISTS content_blob_kind_check;
+ALTER TABLE content_blob ADD CONSTRAINT content_blob_kind_check CHECK (kind IN ('workflow_spec','prompt_template','dataset','finetune_dataset'));
@@ -0,0 +1,3 @@
+-- AOR: Large image and file inputs are stored as content blobs and referenced by hash
+ALTER TABLE content_blob DROP CONSTRAINT IF EXISTS content_blob_kind_check;
+ALTER TABLE content_blob ADD CONSTRAINT content_blob_kind_check CHECK (kind IN ('workflow_spec','prompt_template','dataset','finetune_dataset','media'));
@@ -0,0 +1 @@
+ALTER TABLE provider_config DROP COLUMN IF EXISTS cost_per_minute;
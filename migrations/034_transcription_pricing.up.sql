@@ -0,0 +1,2 @@
+-- CAS: Per-minute pricing for audio transcription models
+ALTER TABLE provider_config ADD COLUMN cost_per_minute DECIMAL(10,6);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_workflow_run_failure;
+ALTER TABLE workflow_run DROP COLUMN IF EXISTS failure;
+ALTER TABLE workflow_run DROP COLUMN IF EXISTS failure_category;
@@ -0,0 +1,5 @@
+-- AOR: Triaged failure category and remediation of failed runs
+ALTER TABLE workflow_run ADD COLUMN failure_category TEXT CHECK (failure_category IN ('provider_error','budget','policy','schema','tool_error','timeout','user_input'));
+ALTER TABLE workflow_run ADD COLUMN failure JSONB;
+
+CREATE INDEX idx_workflow_run_failure ON workflow_run(failure_category, created_at) WHERE failure_category IS NOT NULL;
@@ -0,0 +1,19 @@
+-- AOR: Warm-ups of workers ahead of scheduled runs, charged to the claiming run or a maintenance budget
+CREATE TABLE workflow_prewarm (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    workflow_name TEXT NOT NULL,
+    workflow_version INTEGER NOT NULL,
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('warming','warm','claimed','expired')),
+    attribution TEXT NOT NULL CHECK (attribution IN ('run','maintenance')),
+    maintenance_budget_id UUID REFERENCES budget(id) ON DELETE SET NULL,
+    targets JSONB NOT NULL DEFAULT '{}',
+    cost_cents BIGINT NOT NULL DEFAULT 0,
+    run_id UUID REFERENCES workflow_run(id) ON DELETE SET NULL,
+    warmed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_workflow_prewarm_open ON workflow_prewarm(org_id, workflow_name, scheduled_for) WHERE status IN ('warming','warm');
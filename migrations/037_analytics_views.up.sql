@@ -0,0 +1,11 @@
+-- AOS: Admin-defined analytics views over trace events, backed by ClickHouse materialized views
+CREATE TABLE analytics_view (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    dimensions TEXT[] NOT NULL,
+    measures TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (org_id, name)
+);
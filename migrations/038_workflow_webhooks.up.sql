@@ -0,0 +1,10 @@
+-- AOR: Webhooks declared by workflow specs, with the secret deliveries are signed with
+CREATE TABLE workflow_webhook (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    workflow_name TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    rotated_at TIMESTAMPTZ,
+    PRIMARY KEY (org_id, name)
+);
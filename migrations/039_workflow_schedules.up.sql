@@ -0,0 +1,15 @@
+-- AOR: Cron schedules declared by workflow specs and when each runs next
+CREATE TABLE workflow_schedule (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    workflow_name TEXT NOT NULL,
+    name TEXT NOT NULL,
+    spec JSONB NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    last_run_id UUID REFERENCES workflow_run(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (org_id, workflow_name, name)
+);
+
+CREATE INDEX idx_workflow_schedule_due ON workflow_schedule(next_run_at) WHERE enabled;
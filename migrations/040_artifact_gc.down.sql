@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_content_blob_touched;
+ALTER TABLE content_blob DROP COLUMN IF EXISTS touched_at;
@@ -0,0 +1,4 @@
+-- AOR: Artifact GC measures its grace period from when a blob was last stored
+ALTER TABLE content_blob ADD COLUMN touched_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX idx_content_blob_touched ON content_blob(org_id, touched_at);
@@ -0,0 +1,9 @@
+-- SCL: Per-org PII entity types detected alongside the built-in redaction patterns
+CREATE TABLE redaction_pattern (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    replacement TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (org_id, name)
+);
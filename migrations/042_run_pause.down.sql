@@ -0,0 +1,4 @@
+UPDATE workflow_run SET status = 'running' WHERE status = 'paused';
+ALTER TABLE workflow_run DROP CONSTRAINT IF EXISTS workflow_run_status_check;
+ALTER TABLE workflow_run ADD CONSTRAINT workflow_run_status_check
+    CHECK (status IN ('queued','running','succeeded','failed','canceled','partial-success'));
@@ -0,0 +1,4 @@
+-- AOR: Runs can be paused by operators and resumed later
+ALTER TABLE workflow_run DROP CONSTRAINT IF EXISTS workflow_run_status_check;
+ALTER TABLE workflow_run ADD CONSTRAINT workflow_run_status_check
+    CHECK (status IN ('queued','running','paused','succeeded','failed','canceled','partial-success'));
@@ -0,0 +1 @@
+ALTER TABLE step_run DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,2 @@
+-- AOR: Executions the worker made of each step run, retries included
+ALTER TABLE step_run ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
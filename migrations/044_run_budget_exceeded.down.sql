@@ -0,0 +1,4 @@
+UPDATE workflow_run SET status = 'failed' WHERE status = 'budget_exceeded';
+ALTER TABLE workflow_run DROP CONSTRAINT IF EXISTS workflow_run_status_check;
+ALTER TABLE workflow_run ADD CONSTRAINT workflow_run_status_check
+    CHECK (status IN ('queued','running','paused','succeeded','failed','canceled','partial-success'));
@@ -0,0 +1,4 @@
+-- AOR: Runs stopped at dispatch for exceeding their own budget
+ALTER TABLE workflow_run DROP CONSTRAINT IF EXISTS workflow_run_status_check;
+ALTER TABLE workflow_run ADD CONSTRAINT workflow_run_status_check
+    CHECK (status IN ('queued','running','paused','succeeded','failed','canceled','partial-success','budget_exceeded'));
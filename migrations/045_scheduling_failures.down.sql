@@ -0,0 +1,4 @@
+UPDATE workflow_run SET failure_category = NULL WHERE failure_category = 'scheduling';
+ALTER TABLE workflow_run DROP CONSTRAINT IF EXISTS workflow_run_failure_category_check;
+ALTER TABLE workflow_run ADD CONSTRAINT workflow_run_failure_category_check
+    CHECK (failure_category IN ('provider_error','budget','policy','schema','tool_error','timeout','user_input'));
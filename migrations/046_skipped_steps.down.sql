@@ -0,0 +1,4 @@
+DELETE FROM step_run WHERE status = 'skipped';
+ALTER TABLE step_run DROP CONSTRAINT IF EXISTS step_run_status_check;
+ALTER TABLE step_run ADD CONSTRAINT step_run_status_check
+    CHECK (status IN ('queued','running','succeeded','failed','canceled'));
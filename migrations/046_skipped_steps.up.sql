@@ -0,0 +1,4 @@
+-- AOR: Steps left out by the branch an assert step didn't take
+ALTER TABLE step_run DROP CONSTRAINT IF EXISTS step_run_status_check;
+ALTER TABLE step_run ADD CONSTRAINT step_run_status_check
+    CHECK (status IN ('queued','running','succeeded','failed','canceled','skipped'));
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS notification_digest_item;
+DROP TABLE IF EXISTS notification_preference;
@@ -0,0 +1,21 @@
+-- AOR: Per-user notification preferences and the events held for hourly digests
+CREATE TABLE notification_preference (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    modes JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE TABLE notification_digest_item (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    category TEXT NOT NULL CHECK (category IN ('run_failure','budget_alert','canary_promotion')),
+    subject TEXT NOT NULL,
+    message TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_notification_digest_item_created ON notification_digest_item(created_at);
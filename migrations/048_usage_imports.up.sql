@@ -0,0 +1,10 @@
+-- AOS: Rows imported from provider usage exports, keyed by file hash and row,
+-- so re-importing a file neither duplicates traces nor charges budgets twice
+CREATE TABLE usage_import_row (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    file_hash TEXT NOT NULL,
+    row_id BIGINT NOT NULL,
+    cost_cents BIGINT NOT NULL DEFAULT 0,
+    imported_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (org_id, file_hash, row_id)
+);
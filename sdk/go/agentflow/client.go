@@ -72,6 +72,11 @@ func (c *Client) Budgets() *BudgetService {
 	return &BudgetService{client: c}
 }
 
+// Agents returns an agent capability registry client
+func (c *Client) Agents() *AgentService {
+	return &AgentService{client: c}
+}
+
 // makeRequest makes an HTTP request to the API
 func (c *Client) makeRequest(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
 	var reqBody io.Reader
@@ -378,3 +383,43 @@ func (bs *BudgetService) GetStatus(ctx context.Context) (*BudgetStatus, error) {
 
 	return &result, nil
 }
+
+// AgentService registers agent and tool capabilities
+type AgentService struct {
+	client *Client
+}
+
+// Register announces an agent's or tool's capabilities; agents call it on
+// startup so workflows referencing them are validated at submission
+func (as *AgentService) Register(ctx context.Context, capability *AgentCapability) (*AgentCapability, error) {
+	resp, err := as.client.makeRequest(ctx, "POST", "/api/v1/capabilities", capability)
+	if err != nil {
+		return nil, err
+	}
+
+	var result AgentCapability
+	if err := as.client.parseResponse(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// List returns registered capabilities, optionally of one kind
+func (as *AgentService) List(ctx context.Context, kind string) ([]AgentCapability, error) {
+	path := "/api/v1/capabilities"
+	if kind != "" {
+		path += "?kind=" + url.QueryEscape(kind)
+	}
+	resp, err := as.client.makeRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []AgentCapability
+	if err := as.client.parseResponse(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -31,6 +31,20 @@ type SubmitWorkflowRequest struct {
 	BudgetCents     int64                  `json:"budget_cents,omitempty"`
 }
 
+// MediaInput is an image, file or audio passed as a workflow input. Set Data
+// for inline content, which is moved to the artifact store when large, URL
+// for content the provider fetches, or Ref for an artifact store hash. Text
+// is OCR output or a caption that media redaction policies check for PII.
+type MediaInput struct {
+	Type      string `json:"type"`       // image, file or audio
+	MediaType string `json:"media_type"` // e.g. image/png, application/pdf
+	Data      []byte `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Ref       string `json:"ref,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Text      string `json:"text,omitempty"`
+}
+
 type ListWorkflowsOptions struct {
 	Status string `json:"status,omitempty"`
 	Limit  int    `json:"limit,omitempty"`
@@ -204,3 +218,17 @@ type BudgetStatus struct {
 	PeriodEnd      time.Time `json:"period_end"`
 	Status         string    `json:"status"`
 }
+
+// Agent capability types
+
+type AgentCapability struct {
+	Kind         string                 `json:"kind"` // agent or tool
+	Name         string                 `json:"name"`
+	Version      string                 `json:"version,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	TaskTypes    []string               `json:"task_types,omitempty"`
+	InputSchema  map[string]interface{} `json:"input_schema,omitempty"`
+	OutputSchema map[string]interface{} `json:"output_schema,omitempty"`
+	RegisteredAt time.Time              `json:"registered_at,omitempty"`
+	LastSeenAt   time.Time              `json:"last_seen_at,omitempty"`
+}
@@ -2,6 +2,8 @@ package agentflow
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -37,6 +39,16 @@ type NodePolicy struct {
 	Timeout    time.Duration `json:"timeout,omitempty"`
 }
 
+// PortType is the kind of value a node port carries
+type PortType string
+
+const (
+	PortString      PortType = "string"
+	PortJSON        PortType = "json"
+	PortArtifactRef PortType = "artifact-ref"
+	PortEmbedding   PortType = "embedding"
+)
+
 // NewWorkflow creates a new workflow builder
 func NewWorkflow(name string) *WorkflowBuilder {
 	return &WorkflowBuilder{
@@ -133,6 +145,10 @@ func (wb *WorkflowBuilder) Build() (*WorkflowSpec, error) {
 		return nil, fmt.Errorf("invalid DAG: %w", err)
 	}
 
+	if err := wb.validatePorts(); err != nil {
+		return nil, err
+	}
+
 	return &WorkflowSpec{
 		Name:    wb.name,
 		Version: wb.version,
@@ -205,6 +221,61 @@ func (wb *WorkflowBuilder) validateDAG() error {
 	return nil
 }
 
+// validatePorts checks that each typed input is wired to a compatible output
+// port; json inputs accept any type
+func (wb *WorkflowBuilder) validatePorts() error {
+	outputs := make(map[string]map[string]PortType, len(wb.nodes))
+	for _, node := range wb.nodes {
+		outputs[node.ID] = nodePorts(node, "outputs")
+	}
+
+	var problems []string
+	for _, node := range wb.nodes {
+		inputs := nodePorts(node, "inputs")
+		bindings, _ := node.Config["inputs"].(map[string]string)
+		names := make([]string, 0, len(inputs))
+		for name := range inputs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			source, ok := bindings[name]
+			if !ok {
+				continue
+			}
+			fromNode, fromPort, _ := strings.Cut(source, ".")
+			upstream, known := outputs[fromNode]
+			if !known {
+				problems = append(problems, fmt.Sprintf("%s.%s reads from unknown node %s", node.ID, name, fromNode))
+				continue
+			}
+			if len(upstream) == 0 {
+				continue // Untyped nodes are checked at runtime
+			}
+			fromType, ok := upstream[fromPort]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s reads %s, which is not an output port of %s", node.ID, name, source, fromNode))
+				continue
+			}
+			if fromType != inputs[name] && inputs[name] != PortJSON {
+				problems = append(problems, fmt.Sprintf("%s.%s expects %s but %s is %s", node.ID, name, inputs[name], source, fromType))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("incompatible ports: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// nodePorts returns a node's declared input or output ports
+func nodePorts(node WorkflowNode, direction string) map[string]PortType {
+	ports, _ := node.Config["ports"].(map[string]map[string]PortType)
+	return ports[direction]
+}
+
 // hasCycle checks for cycles using DFS
 func (wb *WorkflowBuilder) hasCycle(nodeID string, visited, recStack map[string]bool, adjList map[string][]string) bool {
 	visited[nodeID] = true
@@ -288,11 +359,57 @@ func (nb *NodeBuilder) WithInputs(inputs map[string]string) *NodeBuilder {
 		if nb.wb.nodes[nb.nodeIndex].Config == nil {
 			nb.wb.nodes[nb.nodeIndex].Config = make(map[string]interface{})
 		}
-		nb.wb.nodes[nb.nodeIndex].Config["inputs"] = inputs
+		bindings, ok := nb.wb.nodes[nb.nodeIndex].Config["inputs"].(map[string]string)
+		if !ok {
+			bindings = make(map[string]string, len(inputs))
+			nb.wb.nodes[nb.nodeIndex].Config["inputs"] = bindings
+		}
+		for name, source := range inputs {
+			bindings[name] = source
+		}
 	}
 	return nb
 }
 
+// Input declares a typed input port fed by an upstream output port ("node.port")
+func (nb *NodeBuilder) Input(name string, portType PortType, from string) *NodeBuilder {
+	if nb.nodeIndex >= 0 && nb.nodeIndex < len(nb.wb.nodes) {
+		nb.declarePort("inputs", name, portType)
+	}
+	return nb.WithInputs(map[string]string{name: from})
+}
+
+// Output declares a typed output port
+func (nb *NodeBuilder) Output(name string, portType PortType) *NodeBuilder {
+	if nb.nodeIndex >= 0 && nb.nodeIndex < len(nb.wb.nodes) {
+		nb.declarePort("outputs", name, portType)
+	}
+	return nb
+}
+
+// declarePort records a port in the node's config
+func (nb *NodeBuilder) declarePort(direction, name string, portType PortType) {
+	node := &nb.wb.nodes[nb.nodeIndex]
+	switch portType {
+	case PortString, PortJSON, PortArtifactRef, PortEmbedding:
+	default:
+		nb.wb.errors = append(nb.wb.errors, fmt.Errorf("node %s port %s has unknown type %q", node.ID, name, portType))
+		return
+	}
+	if node.Config == nil {
+		node.Config = make(map[string]interface{})
+	}
+	ports, ok := node.Config["ports"].(map[string]map[string]PortType)
+	if !ok {
+		ports = make(map[string]map[string]PortType)
+		node.Config["ports"] = ports
+	}
+	if ports[direction] == nil {
+		ports[direction] = make(map[string]PortType)
+	}
+	ports[direction][name] = portType
+}
+
 // DependsOn adds a dependency from another node to this node
 func (nb *NodeBuilder) DependsOn(fromNodeID string) *NodeBuilder {
 	if nb.nodeIndex >= 0 && nb.nodeIndex < len(nb.wb.nodes) {